@@ -6,6 +6,7 @@
 package addrmgr
 
 import (
+	"bytes"
 	"container/list"
 	crand "crypto/rand" // for seeding
 	"encoding/base32"
@@ -25,6 +26,7 @@ import (
 
 	"github.com/gcash/bchd/chaincfg/chainhash"
 	"github.com/gcash/bchd/wire"
+	"golang.org/x/crypto/sha3"
 )
 
 // AddrManager provides a concurrency safe address manager for caching potential
@@ -32,6 +34,7 @@ import (
 type AddrManager struct {
 	mtx            sync.Mutex
 	peersFile      string
+	anchorsFile    string
 	lookupFunc     func(string) ([]net.IP, error)
 	rand           *rand.Rand
 	key            [32]byte
@@ -47,6 +50,7 @@ type AddrManager struct {
 	lamtx          sync.Mutex
 	localAddresses map[string]*LocalAddress
 	version        int
+	asmap          *ASMap
 }
 
 type serializedKnownAddress struct {
@@ -58,6 +62,10 @@ type serializedKnownAddress struct {
 	LastSuccess int64
 	Services    wire.ServiceFlag
 	SrcServices wire.ServiceFlag
+	// Network and SrcNetwork are only set for CJDNS addresses, which can't
+	// be told apart from IPv6 addresses by their string representation.
+	Network    wire.NetworkID `json:",omitempty"`
+	SrcNetwork wire.NetworkID `json:",omitempty"`
 	// no refcount or tried, that is available from context.
 }
 
@@ -86,7 +94,8 @@ const (
 	// BoundPrio signifies the address has been explicitly bounded to.
 	BoundPrio
 
-	// UpnpPrio signifies the address was obtained from UPnP.
+	// UpnpPrio signifies the address was obtained from the gateway with
+	// UPnP, NAT-PMP or PCP.
 	UpnpPrio
 
 	// HTTPPrio signifies the address was obtained from an external HTTP service.
@@ -383,6 +392,12 @@ func (a *AddrManager) savePeers() {
 			ska.Services = v.na.Services
 			ska.SrcServices = v.srcAddr.Services
 		}
+		if IsCJDNS(v.na) {
+			ska.Network = wire.NetworkCJDNS
+		}
+		if IsCJDNS(v.srcAddr) {
+			ska.SrcNetwork = wire.NetworkCJDNS
+		}
 		// Tried and refs are implicit in the rest of the structure
 		// and will be worked out from context on unserialisation.
 		sam.Addresses[i] = ska
@@ -495,6 +510,12 @@ func (a *AddrManager) deserializePeers(filePath string) error {
 			return fmt.Errorf("failed to deserialize netaddress "+
 				"%s: %v", v.Src, err)
 		}
+		if v.Network == wire.NetworkCJDNS {
+			ka.na.Network = wire.NetworkCJDNS
+		}
+		if v.SrcNetwork == wire.NetworkCJDNS {
+			ka.srcAddr.Network = wire.NetworkCJDNS
+		}
 
 		ka.attempts = v.Attempts
 		ka.lastattempt = time.Unix(v.LastAttempt, 0)
@@ -680,6 +701,12 @@ func (a *AddrManager) AddressCache() []*wire.NetAddress {
 	return allAddr[0:numAddresses]
 }
 
+// Addresses returns all of the addresses known to the address manager.  It
+// must be treated as read-only.
+func (a *AddrManager) Addresses() []*wire.NetAddress {
+	return a.getAddresses()
+}
+
 // getAddresses returns all of the addresses currently found within the
 // manager's address cache.
 func (a *AddrManager) getAddresses() []*wire.NetAddress {
@@ -716,9 +743,29 @@ func (a *AddrManager) reset() {
 }
 
 // HostToNetAddress returns a netaddress given a host address.  If the address
-// is a Tor .onion address this will be taken care of.  Else if the host is
-// not an IP address it will be resolved (via Tor if required).
+// is a Tor .onion or I2P .b32.i2p address this will be taken care of.  Else if
+// the host is not an IP address it will be resolved (via Tor if required).
 func (a *AddrManager) HostToNetAddress(host string, port uint16, services wire.ServiceFlag) (*wire.NetAddress, error) {
+	// Tor v3 address is 56 char base32 + ".onion"
+	if len(host) == 62 && host[56:] == ".onion" {
+		pubKey, err := decodeOnionV3(host[:56])
+		if err != nil {
+			return nil, err
+		}
+		return wire.NewNetAddressV2(time.Now(), services,
+			wire.NetworkTorV3, pubKey, port)
+	}
+
+	// I2P address is 52 char base32 + ".b32.i2p"
+	if len(host) == 60 && host[52:] == ".b32.i2p" {
+		data, err := base32NoPad.DecodeString(strings.ToUpper(host[:52]))
+		if err != nil {
+			return nil, err
+		}
+		return wire.NewNetAddressV2(time.Now(), services,
+			wire.NetworkI2P, data, port)
+	}
+
 	// Tor address is 16 char base32 + ".onion"
 	var ip net.IP
 	if len(host) == 22 && host[16:] == ".onion" {
@@ -746,9 +793,52 @@ func (a *AddrManager) HostToNetAddress(host string, port uint16, services wire.S
 	return wire.NewNetAddressIPPort(ip, port, services), nil
 }
 
+// base32NoPad is the base32 encoding of Tor v3 and I2P addresses.
+var base32NoPad = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// onionV3Checksum returns the checksum of a Tor v3 onion address as defined
+// by the Tor rend-spec-v3.
+func onionV3Checksum(pubKey []byte, version byte) []byte {
+	h := sha3.New256()
+	h.Write([]byte(".onion checksum"))
+	h.Write(pubKey)
+	h.Write([]byte{version})
+	return h.Sum(nil)[:2]
+}
+
+// encodeOnionV3 returns the onion address, without the ".onion" suffix, of
+// the passed Tor v3 public key.
+func encodeOnionV3(pubKey []byte) string {
+	const version = 3
+	data := make([]byte, 0, len(pubKey)+3)
+	data = append(data, pubKey...)
+	data = append(data, onionV3Checksum(pubKey, version)...)
+	data = append(data, version)
+	return strings.ToLower(base32NoPad.EncodeToString(data))
+}
+
+// decodeOnionV3 returns the public key of the passed Tor v3 onion address,
+// without the ".onion" suffix.
+func decodeOnionV3(onion string) ([]byte, error) {
+	data, err := base32NoPad.DecodeString(strings.ToUpper(onion))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != 35 || data[34] != 3 {
+		return nil, fmt.Errorf("invalid tor v3 address %s.onion", onion)
+	}
+	pubKey := data[:32]
+	if !bytes.Equal(data[32:34], onionV3Checksum(pubKey, 3)) {
+		return nil, fmt.Errorf("invalid checksum of tor v3 address "+
+			"%s.onion", onion)
+	}
+	return pubKey, nil
+}
+
 // ipString returns a string for the ip from the provided NetAddress. If the
 // ip is in the range used for Tor addresses then it will be transformed into
-// the relevant .onion address.
+// the relevant .onion address.  Tor v3 and I2P addresses are transformed into
+// their .onion and .b32.i2p addresses.
 func ipString(na *wire.NetAddress) string {
 	if IsOnionCatTor(na) {
 		// We know now that na.IP is long enough.
@@ -756,7 +846,17 @@ func ipString(na *wire.NetAddress) string {
 		return strings.ToLower(base32str) + ".onion"
 	}
 
-	return na.IP.String()
+	switch na.NetworkID() {
+	case wire.NetworkTorV3:
+		return encodeOnionV3(na.Addr) + ".onion"
+	case wire.NetworkI2P:
+		return strings.ToLower(base32NoPad.EncodeToString(na.Addr)) +
+			".b32.i2p"
+	case wire.NetworkIPv4, wire.NetworkIPv6, wire.NetworkCJDNS:
+		return na.IP.String()
+	}
+
+	return fmt.Sprintf("%v:%x", na.NetworkID(), na.Addr)
 }
 
 // NetAddressKey returns a string key in the form of ip:port for IPv4 addresses
@@ -782,59 +882,90 @@ func (a *AddrManager) GetAddress() *KnownAddress {
 
 	// Use a 50% chance for choosing between tried and new table entries.
 	if a.nTried > 0 && (a.nNew == 0 || a.rand.Intn(2) == 0) {
-		// Tried entry.
-		large := 1 << 30
-		factor := 1.0
-		for {
-			// pick a random bucket.
-			bucket := a.rand.Intn(len(a.addrTried))
-			if a.addrTried[bucket].Len() == 0 {
-				continue
-			}
+		return a.pickTriedAddress()
+	}
+	return a.pickNewAddress()
+}
 
-			// Pick a random entry in the list
-			e := a.addrTried[bucket].Front()
-			for i :=
-				a.rand.Int63n(int64(a.addrTried[bucket].Len())); i > 0; i-- {
-				e = e.Next()
-			}
-			ka := e.Value.(*KnownAddress)
-			randval := a.rand.Intn(large)
-			if float64(randval) < (factor * ka.chance() * float64(large)) {
-				log.Tracef("Selected %v from tried bucket",
-					NetAddressKey(ka.na))
-				return ka
-			}
-			factor *= 1.2
+// GetNewAddress returns a single address from the new table, which holds the
+// addresses that have not been connected to successfully yet.  It is used to
+// pick the addresses of feeler connections, which test whether new addresses
+// are reachable and move them to the tried table when they are.
+func (a *AddrManager) GetNewAddress() *KnownAddress {
+	// Protect concurrent access.
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if a.nNew == 0 {
+		return nil
+	}
+	return a.pickNewAddress()
+}
+
+// pickTriedAddress picks a random address from the tried table with preference
+// given to ones that have not been used recently.  The tried table must not be
+// empty.
+//
+// This function MUST be called with the address manager lock held (for
+// writes).
+func (a *AddrManager) pickTriedAddress() *KnownAddress {
+	large := 1 << 30
+	factor := 1.0
+	for {
+		// pick a random bucket.
+		bucket := a.rand.Intn(len(a.addrTried))
+		if a.addrTried[bucket].Len() == 0 {
+			continue
 		}
-	} else {
-		// new node.
-		// XXX use a closure/function to avoid repeating this.
-		large := 1 << 30
-		factor := 1.0
-		for {
-			// Pick a random bucket.
-			bucket := a.rand.Intn(len(a.addrNew))
-			if len(a.addrNew[bucket]) == 0 {
-				continue
-			}
-			// Then, a random entry in it.
-			var ka *KnownAddress
-			nth := a.rand.Intn(len(a.addrNew[bucket]))
-			for _, value := range a.addrNew[bucket] {
-				if nth == 0 {
-					ka = value
-				}
-				nth--
-			}
-			randval := a.rand.Intn(large)
-			if float64(randval) < (factor * ka.chance() * float64(large)) {
-				log.Tracef("Selected %v from new bucket",
-					NetAddressKey(ka.na))
-				return ka
+
+		// Pick a random entry in the list
+		e := a.addrTried[bucket].Front()
+		for i :=
+			a.rand.Int63n(int64(a.addrTried[bucket].Len())); i > 0; i-- {
+			e = e.Next()
+		}
+		ka := e.Value.(*KnownAddress)
+		randval := a.rand.Intn(large)
+		if float64(randval) < (factor * ka.chance() * float64(large)) {
+			log.Tracef("Selected %v from tried bucket",
+				NetAddressKey(ka.na))
+			return ka
+		}
+		factor *= 1.2
+	}
+}
+
+// pickNewAddress picks a random address from the new table with preference
+// given to ones that have not been used recently.  The new table must not be
+// empty.
+//
+// This function MUST be called with the address manager lock held (for
+// writes).
+func (a *AddrManager) pickNewAddress() *KnownAddress {
+	large := 1 << 30
+	factor := 1.0
+	for {
+		// Pick a random bucket.
+		bucket := a.rand.Intn(len(a.addrNew))
+		if len(a.addrNew[bucket]) == 0 {
+			continue
+		}
+		// Then, a random entry in it.
+		var ka *KnownAddress
+		nth := a.rand.Intn(len(a.addrNew[bucket]))
+		for _, value := range a.addrNew[bucket] {
+			if nth == 0 {
+				ka = value
 			}
-			factor *= 1.2
+			nth--
+		}
+		randval := a.rand.Intn(large)
+		if float64(randval) < (factor * ka.chance() * float64(large)) {
+			log.Tracef("Selected %v from new bucket",
+				NetAddressKey(ka.na))
+			return ka
 		}
+		factor *= 1.2
 	}
 }
 
@@ -994,7 +1125,7 @@ func (a *AddrManager) SetServices(addr *wire.NetAddress, services wire.ServiceFl
 // with the given priority.
 func (a *AddrManager) AddLocalAddress(na *wire.NetAddress, priority AddressPriority) error {
 	if !IsRoutable(na) {
-		return fmt.Errorf("address %s is not routable", na.IP)
+		return fmt.Errorf("address %s is not routable", ipString(na))
 	}
 
 	a.lamtx.Lock()
@@ -1015,6 +1146,14 @@ func (a *AddrManager) AddLocalAddress(na *wire.NetAddress, priority AddressPrior
 	return nil
 }
 
+// RemoveLocalAddress removes na from the list of known local addresses, for
+// instance when the external address obtained from the gateway changed.
+func (a *AddrManager) RemoveLocalAddress(na *wire.NetAddress) {
+	a.lamtx.Lock()
+	delete(a.localAddresses, NetAddressKey(na))
+	a.lamtx.Unlock()
+}
+
 // getReachabilityFrom returns the relative reachability of the provided local
 // address to the provided remote address.
 func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
@@ -1032,8 +1171,8 @@ func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
 		return Unreachable
 	}
 
-	if IsOnionCatTor(remoteAddr) {
-		if IsOnionCatTor(localAddr) {
+	if isTor(remoteAddr) {
+		if isTor(localAddr) {
 			return Private
 		}
 
@@ -1044,6 +1183,13 @@ func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
 		return Default
 	}
 
+	if IsI2P(remoteAddr) || IsCJDNS(remoteAddr) {
+		if remoteAddr.NetworkID() == localAddr.NetworkID() {
+			return Private
+		}
+		return Default
+	}
+
 	if IsRFC4380(remoteAddr) {
 		if !IsRoutable(localAddr) {
 			return Default
@@ -1113,15 +1259,14 @@ func (a *AddrManager) GetBestLocalAddress(remoteAddr *wire.NetAddress) *wire.Net
 		}
 	}
 	if bestAddress != nil {
-		log.Debugf("Suggesting address %s:%d for %s:%d", bestAddress.IP,
-			bestAddress.Port, remoteAddr.IP, remoteAddr.Port)
+		log.Debugf("Suggesting address %s for %s",
+			NetAddressKey(bestAddress), NetAddressKey(remoteAddr))
 	} else {
-		log.Debugf("No worthy address for %s:%d", remoteAddr.IP,
-			remoteAddr.Port)
+		log.Debugf("No worthy address for %s", NetAddressKey(remoteAddr))
 
 		// Send something unroutable if nothing suitable.
 		var ip net.IP
-		if !IsIPv4(remoteAddr) && !IsOnionCatTor(remoteAddr) {
+		if !IsIPv4(remoteAddr) && !isTor(remoteAddr) {
 			ip = net.IPv6zero
 		} else {
 			ip = net.IPv4zero
@@ -1147,6 +1292,7 @@ func (a *AddrManager) LocalAddresses() []*LocalAddress {
 func New(dataDir string, lookupFunc func(string) ([]net.IP, error)) *AddrManager {
 	am := AddrManager{
 		peersFile:      filepath.Join(dataDir, "peers.json"),
+		anchorsFile:    filepath.Join(dataDir, "anchors.json"),
 		lookupFunc:     lookupFunc,
 		rand:           rand.New(rand.NewSource(time.Now().UnixNano())),
 		quit:           make(chan struct{}),
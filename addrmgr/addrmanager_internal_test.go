@@ -1,11 +1,13 @@
 package addrmgr
 
 import (
+	"bytes"
 	"io/ioutil"
 	"math/rand"
 	"net"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/gcash/bchd/wire"
 )
@@ -47,6 +49,13 @@ func assertAddr(t *testing.T, got, expected *wire.NetAddress) {
 	if !got.IP.Equal(expected.IP) {
 		t.Fatalf("expected address IP %v, got %v", expected.IP, got.IP)
 	}
+	if got.NetworkID() != expected.NetworkID() {
+		t.Fatalf("expected address network %v, got %v",
+			expected.NetworkID(), got.NetworkID())
+	}
+	if !bytes.Equal(got.Addr, expected.Addr) {
+		t.Fatalf("expected address %x, got %x", expected.Addr, got.Addr)
+	}
 	if got.Port != expected.Port {
 		t.Fatalf("expected address port %d, got %d", expected.Port,
 			got.Port)
@@ -117,6 +126,43 @@ func TestAddrManagerSerialization(t *testing.T) {
 	assertAddrs(t, addrMgr, expectedAddrs)
 }
 
+// TestAddrManagerSerializationAddrV2 ensures that addresses which can only be
+// relayed with addrv2 messages are properly serialized and deserialized.
+func TestAddrManagerSerializationAddrV2(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "addrmgr")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	addrMgr := New(tempDir, nil)
+
+	addrs := []struct {
+		network wire.NetworkID
+		addr    []byte
+	}{
+		{wire.NetworkTorV3, bytes.Repeat([]byte{0x01}, 32)},
+		{wire.NetworkI2P, bytes.Repeat([]byte{0x02}, 32)},
+		{wire.NetworkCJDNS, net.ParseIP("fc00::1")},
+	}
+	expectedAddrs := make(map[string]*wire.NetAddress, len(addrs))
+	for _, addr := range addrs {
+		na, err := wire.NewNetAddressV2(time.Now(), wire.SFNodeNetwork,
+			addr.network, addr.addr, 8333)
+		if err != nil {
+			t.Fatalf("NewNetAddressV2: %v", err)
+		}
+		expectedAddrs[NetAddressKey(na)] = na
+		addrMgr.AddAddress(na, na)
+	}
+	assertAddrs(t, addrMgr, expectedAddrs)
+
+	addrMgr.savePeers()
+	addrMgr = New(tempDir, nil)
+	addrMgr.loadPeers()
+	assertAddrs(t, addrMgr, expectedAddrs)
+}
+
 // TestAddrManagerV1ToV2 ensures that we can properly upgrade the serialized
 // version of the address manager from v1 to v2.
 func TestAddrManagerV1ToV2(t *testing.T) {
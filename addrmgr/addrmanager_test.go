@@ -7,7 +7,9 @@ package addrmgr_test
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
 	"reflect"
 	"testing"
 	"time"
@@ -206,6 +208,16 @@ func TestAddLocalAddress(t *testing.T) {
 			continue
 		}
 	}
+
+	// Removed local addresses are no longer advertised.
+	na := &wire.NetAddress{IP: net.ParseIP("204.124.1.1")}
+	amgr.RemoveLocalAddress(na)
+	for _, la := range amgr.LocalAddresses() {
+		if la.NA.IP.Equal(na.IP) {
+			t.Errorf("TestAddLocalAddress: removed address %s is "+
+				"still known", na.IP)
+		}
+	}
 }
 
 func TestAttempt(t *testing.T) {
@@ -351,6 +363,81 @@ func TestGetAddress(t *testing.T) {
 	}
 }
 
+// TestGetNewAddress ensures GetNewAddress only returns addresses of the new
+// table.
+func TestGetNewAddress(t *testing.T) {
+	n := addrmgr.New("testgetnewaddress", lookupFunc)
+
+	if rv := n.GetNewAddress(); rv != nil {
+		t.Errorf("GetNewAddress failed: got: %v want: %v\n", rv, nil)
+	}
+
+	err := n.AddAddressByIP(someIP + ":8333")
+	if err != nil {
+		t.Fatalf("Adding address failed: %v", err)
+	}
+	ka := n.GetNewAddress()
+	if ka == nil {
+		t.Fatalf("Did not get an address where there is one in the new table")
+	}
+	if ka.NetAddress().IP.String() != someIP {
+		t.Errorf("Wrong IP: got %v, want %v", ka.NetAddress().IP.String(), someIP)
+	}
+
+	// Once the address is good it moves to the tried table.
+	n.Good(ka.NetAddress())
+	if rv := n.GetNewAddress(); rv != nil {
+		t.Errorf("GetNewAddress returned tried address %v", rv.NetAddress())
+	}
+}
+
+// TestAnchors ensures anchors are saved and loaded once.
+func TestAnchors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testanchors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	n := addrmgr.New(dir, lookupFunc)
+	if anchors := n.LoadAnchors(); len(anchors) != 0 {
+		t.Fatalf("LoadAnchors: got %d anchors without anchors file",
+			len(anchors))
+	}
+
+	var addrs []*wire.NetAddress
+	for _, s := range []string{"173.194.115.66:8333", "[2001:470::1]:8334",
+		"1.2.3.4:8333"} {
+
+		na, err := n.DeserializeNetAddress(s, wire.SFNodeNetwork)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs = append(addrs, na)
+	}
+	if err := n.SaveAnchors(addrs); err != nil {
+		t.Fatalf("SaveAnchors: %v", err)
+	}
+
+	anchors := n.LoadAnchors()
+	if len(anchors) != addrmgr.MaxAnchors {
+		t.Fatalf("LoadAnchors: got %d anchors, want %d", len(anchors),
+			addrmgr.MaxAnchors)
+	}
+	for i, na := range anchors {
+		if addrmgr.NetAddressKey(na) != addrmgr.NetAddressKey(addrs[i]) ||
+			na.Services != wire.SFNodeNetwork {
+			t.Errorf("LoadAnchors: got anchor %v, want %v", na, addrs[i])
+		}
+	}
+
+	// The anchors are only loaded once.
+	if anchors := n.LoadAnchors(); len(anchors) != 0 {
+		t.Fatalf("LoadAnchors: got %d anchors after loading them",
+			len(anchors))
+	}
+}
+
 func TestGetBestLocalAddress(t *testing.T) {
 	localAddrs := []wire.NetAddress{
 		{IP: net.ParseIP("192.168.0.100")},
@@ -470,3 +557,41 @@ func TestNetAddressKey(t *testing.T) {
 	}
 
 }
+
+// TestHostToNetAddressV2 ensures Tor v3 and I2P hosts are converted to
+// addresses and back.
+func TestHostToNetAddressV2(t *testing.T) {
+	n := addrmgr.New("testhostv2", lookupFunc)
+	tests := []struct {
+		host    string
+		network wire.NetworkID
+	}{
+		// Example address of the Tor rend-spec-v3.
+		{"pg6mmjiyjmcrsslvykfwnntlaru7p5svn6y2ymmju6nubxndf4pscryd.onion",
+			wire.NetworkTorV3},
+		{"ukeu3k5oycgaauneqgtnvselmt4yemvoilkln7jpvamvfx7dnkdq.b32.i2p",
+			wire.NetworkI2P},
+	}
+	for _, test := range tests {
+		na, err := n.HostToNetAddress(test.host, 8333, wire.SFNodeNetwork)
+		if err != nil {
+			t.Errorf("HostToNetAddress %s: %v", test.host, err)
+			continue
+		}
+		if na.NetworkID() != test.network {
+			t.Errorf("HostToNetAddress %s: got network %v, want %v",
+				test.host, na.NetworkID(), test.network)
+		}
+		want := net.JoinHostPort(test.host, "8333")
+		if key := addrmgr.NetAddressKey(na); key != want {
+			t.Errorf("NetAddressKey: got %s, want %s", key, want)
+		}
+	}
+
+	// A Tor v3 address with an invalid checksum must be rejected.
+	_, err := n.HostToNetAddress("ag6mmjiyjmcrsslvykfwnntlaru7p5svn6y2ymm"+
+		"ju6nubxndf4pscryd.onion", 8333, wire.SFNodeNetwork)
+	if err == nil {
+		t.Error("HostToNetAddress: expected error for invalid checksum")
+	}
+}
@@ -0,0 +1,92 @@
+package addrmgr
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/gcash/bchd/wire"
+)
+
+// MaxAnchors is the maximum number of anchor addresses which are saved.
+const MaxAnchors = 2
+
+// serializedAnchor is the serialized form of an anchor address.
+type serializedAnchor struct {
+	Addr     string
+	Services wire.ServiceFlag
+	// Network is only set for CJDNS addresses, which can't be told apart
+	// from IPv6 addresses by their string representation.
+	Network wire.NetworkID `json:",omitempty"`
+}
+
+// SaveAnchors saves the passed addresses of outbound peers as anchors, which
+// are connected to first by the next run.  Reconnecting to peers which were
+// known to be good makes it harder for an attacker to fill all outbound
+// connection slots of a restarted node with its own peers.  At most
+// MaxAnchors addresses are saved.
+func (a *AddrManager) SaveAnchors(addrs []*wire.NetAddress) error {
+	if len(addrs) > MaxAnchors {
+		addrs = addrs[:MaxAnchors]
+	}
+	anchors := make([]serializedAnchor, 0, len(addrs))
+	for _, na := range addrs {
+		anchor := serializedAnchor{
+			Addr:     NetAddressKey(na),
+			Services: na.Services,
+		}
+		if IsCJDNS(na) {
+			anchor.Network = wire.NetworkCJDNS
+		}
+		anchors = append(anchors, anchor)
+	}
+	b, err := json.Marshal(anchors)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(a.anchorsFile, b, 0600)
+}
+
+// LoadAnchors returns the anchor addresses saved by the previous run.  The
+// anchors file is removed afterwards so that a node which keeps crashing does
+// not keep connecting to the same peers.
+func (a *AddrManager) LoadAnchors() []*wire.NetAddress {
+	b, err := ioutil.ReadFile(a.anchorsFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("Failed to read anchors file %s: %v",
+				a.anchorsFile, err)
+		}
+		return nil
+	}
+	if err := os.Remove(a.anchorsFile); err != nil {
+		log.Warnf("Failed to remove anchors file %s: %v", a.anchorsFile,
+			err)
+	}
+
+	var anchors []serializedAnchor
+	if err := json.Unmarshal(b, &anchors); err != nil {
+		log.Warnf("Failed to parse anchors file %s: %v", a.anchorsFile,
+			err)
+		return nil
+	}
+	addrs := make([]*wire.NetAddress, 0, len(anchors))
+	for _, anchor := range anchors {
+		na, err := a.DeserializeNetAddress(anchor.Addr, anchor.Services)
+		if err != nil {
+			log.Warnf("Failed to deserialize anchor %s: %v",
+				anchor.Addr, err)
+			continue
+		}
+		if anchor.Network == wire.NetworkCJDNS {
+			na.Network = wire.NetworkCJDNS
+		}
+		addrs = append(addrs, na)
+		if len(addrs) == MaxAnchors {
+			break
+		}
+	}
+	log.Infof("Loaded %d anchor addresses from file '%s'", len(addrs),
+		a.anchorsFile)
+	return addrs
+}
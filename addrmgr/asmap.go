@@ -0,0 +1,210 @@
+package addrmgr
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+
+	"github.com/gcash/bchd/wire"
+)
+
+// These constants define the instructions of the asmap interpreter.
+const (
+	asmapReturn  = 0
+	asmapJump    = 1
+	asmapMatch   = 2
+	asmapDefault = 3
+)
+
+// These variables define the bit sizes of the variable length integers the
+// instructions and their arguments are encoded with in an asmap.
+var (
+	asmapTypeBitSizes  = []uint8{0, 0, 1}
+	asmapASNBitSizes   = []uint8{15, 16, 17, 18, 19, 20, 21, 22, 23, 24}
+	asmapMatchBitSizes = []uint8{1, 2, 3, 4, 5, 6, 7, 8}
+	asmapJumpBitSizes  = []uint8{5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17,
+		18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30}
+)
+
+// errASMapEOF is returned when the end of an asmap is reached while decoding
+// an instruction or its arguments.
+var errASMapEOF = errors.New("unexpected end of asmap")
+
+// ASMap maps IP addresses to the autonomous system (AS) they are announced by.
+// It uses the compact binary format of Bitcoin Core, which encodes a program
+// that is interpreted bit by bit against the address.
+type ASMap struct {
+	data []byte
+}
+
+// NewASMap returns an ASMap for the passed encoded asmap.
+func NewASMap(data []byte) (*ASMap, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty asmap")
+	}
+	return &ASMap{data: data}, nil
+}
+
+// LoadASMap reads an encoded asmap from the passed file.
+func LoadASMap(path string) (*ASMap, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewASMap(data)
+}
+
+// bit returns the bit at the passed position.  Bits are stored least
+// significant bit first.
+func (m *ASMap) bit(pos int) uint32 {
+	return uint32(m.data[pos/8]>>(pos%8)) & 1
+}
+
+// decodeBits decodes a variable length integer with the passed minimum value
+// and mantissa bit sizes at the passed position.  It returns the integer and
+// the position after it.
+func (m *ASMap) decodeBits(pos int, minVal uint32, bitSizes []uint8) (uint32, int, error) {
+	end := len(m.data) * 8
+	val := minVal
+	for i, size := range bitSizes {
+		// Every class but the last is preceded by a bit which signals
+		// whether the value is larger than the class.
+		if i != len(bitSizes)-1 {
+			if pos == end {
+				return 0, pos, errASMapEOF
+			}
+			bit := m.bit(pos)
+			pos++
+			if bit == 1 {
+				val += 1 << size
+				continue
+			}
+		}
+		for b := uint8(0); b < size; b++ {
+			if pos == end {
+				return 0, pos, errASMapEOF
+			}
+			val += m.bit(pos) << (size - 1 - b)
+			pos++
+		}
+		return val, pos, nil
+	}
+	return 0, pos, errASMapEOF
+}
+
+// bitLen returns the number of bits required to represent the passed value.
+func bitLen(v uint32) uint32 {
+	var n uint32
+	for ; v != 0; v >>= 1 {
+		n++
+	}
+	return n
+}
+
+// ASN returns the number of the autonomous system the passed IP address is
+// announced by, or 0 when it is unknown.
+func (m *ASMap) ASN(ip net.IP) uint32 {
+	ip = ip.To16()
+	if ip == nil {
+		return 0
+	}
+	ipBit := func(i uint32) uint32 {
+		return uint32(ip[i/8]>>(7-i%8)) & 1
+	}
+
+	end := len(m.data) * 8
+	pos := 0
+	var defaultASN uint32
+	var consumed uint32
+	const ipBits = net.IPv6len * 8
+	for pos < end {
+		opcode, next, err := m.decodeBits(pos, 0, asmapTypeBitSizes)
+		if err != nil {
+			return 0
+		}
+		pos = next
+		switch opcode {
+		case asmapReturn:
+			asn, _, err := m.decodeBits(pos, 1, asmapASNBitSizes)
+			if err != nil {
+				return 0
+			}
+			return asn
+
+		case asmapJump:
+			jump, next, err := m.decodeBits(pos, 17, asmapJumpBitSizes)
+			if err != nil || consumed == ipBits ||
+				int64(jump) >= int64(end-next) {
+				return 0
+			}
+			pos = next
+			if ipBit(consumed) == 1 {
+				pos += int(jump)
+			}
+			consumed++
+
+		case asmapMatch:
+			match, next, err := m.decodeBits(pos, 2, asmapMatchBitSizes)
+			if err != nil {
+				return 0
+			}
+			pos = next
+			matchLen := bitLen(match) - 1
+			if ipBits-consumed < matchLen {
+				return 0
+			}
+			for b := uint32(0); b < matchLen; b++ {
+				if ipBit(consumed) != (match>>(matchLen-1-b))&1 {
+					return defaultASN
+				}
+				consumed++
+			}
+
+		case asmapDefault:
+			asn, next, err := m.decodeBits(pos, 1, asmapASNBitSizes)
+			if err != nil {
+				return 0
+			}
+			pos = next
+			defaultASN = asn
+
+		default:
+			return 0
+		}
+	}
+
+	// The end of the asmap was reached without a return instruction.
+	return 0
+}
+
+// SetASMap sets the asmap used to group addresses by the autonomous system
+// they are announced by.  Passing nil groups addresses by their network prefix
+// only.
+func (a *AddrManager) SetASMap(asmap *ASMap) {
+	a.mtx.Lock()
+	a.asmap = asmap
+	a.mtx.Unlock()
+}
+
+// GroupKey returns the group of the passed address like the package level
+// GroupKey function, except that IP addresses which are mapped to an
+// autonomous system by the asmap of the address manager are grouped by their
+// autonomous system instead.  This keeps peers which are hosted by the same
+// network operator in a single group, even when they are spread over many
+// network prefixes.
+func (a *AddrManager) GroupKey(na *wire.NetAddress) string {
+	a.mtx.Lock()
+	asmap := a.asmap
+	a.mtx.Unlock()
+
+	if asmap != nil && IsRoutable(na) && !IsLocal(na) {
+		switch na.NetworkID() {
+		case wire.NetworkIPv4, wire.NetworkIPv6:
+			if asn := asmap.ASN(na.IP); asn != 0 {
+				return fmt.Sprintf("as%d", asn)
+			}
+		}
+	}
+	return GroupKey(na)
+}
@@ -0,0 +1,154 @@
+package addrmgr_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gcash/bchd/addrmgr"
+	"github.com/gcash/bchd/wire"
+)
+
+// asmapWriter builds an encoded asmap for tests.
+type asmapWriter struct {
+	bits []bool
+}
+
+// writeBits appends the passed value as a variable length integer with the
+// passed minimum value and mantissa bit sizes.
+func (w *asmapWriter) writeBits(val, minVal uint32, bitSizes []uint8) {
+	val -= minVal
+	for i, size := range bitSizes {
+		last := i == len(bitSizes)-1
+		if !last && val >= 1<<size {
+			w.bits = append(w.bits, true)
+			val -= 1 << size
+			continue
+		}
+		if !last {
+			w.bits = append(w.bits, false)
+		}
+		for b := int(size) - 1; b >= 0; b-- {
+			w.bits = append(w.bits, (val>>uint(b))&1 == 1)
+		}
+		return
+	}
+}
+
+var (
+	typeBitSizes  = []uint8{0, 0, 1}
+	asnBitSizes   = []uint8{15, 16, 17, 18, 19, 20, 21, 22, 23, 24}
+	matchBitSizes = []uint8{1, 2, 3, 4, 5, 6, 7, 8}
+	jumpBitSizes  = []uint8{5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17,
+		18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30}
+)
+
+func (w *asmapWriter) ret(asn uint32) {
+	w.writeBits(0, 0, typeBitSizes)
+	w.writeBits(asn, 1, asnBitSizes)
+}
+
+func (w *asmapWriter) jump(offset uint32) {
+	w.writeBits(1, 0, typeBitSizes)
+	w.writeBits(offset, 17, jumpBitSizes)
+}
+
+func (w *asmapWriter) matchByte(b byte) {
+	w.writeBits(2, 0, typeBitSizes)
+	w.writeBits(1<<8|uint32(b), 2, matchBitSizes)
+}
+
+// bytes returns the encoded asmap with the bits stored least significant bit
+// first.
+func (w *asmapWriter) bytes() []byte {
+	b := make([]byte, (len(w.bits)+7)/8)
+	for i, bit := range w.bits {
+		if bit {
+			b[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return b
+}
+
+// testASMap returns an asmap which maps 0.0.0.0/1 to AS100, 128.0.0.0/1 to
+// AS70000 and leaves IPv6 addresses unmapped.
+func testASMap(t *testing.T) *addrmgr.ASMap {
+	var low asmapWriter
+	low.ret(100)
+
+	var w asmapWriter
+	for _, b := range []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff} {
+		w.matchByte(b)
+	}
+	w.jump(uint32(len(low.bits)))
+	w.bits = append(w.bits, low.bits...)
+	w.ret(70000)
+
+	asmap, err := addrmgr.NewASMap(w.bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return asmap
+}
+
+// TestASMap ensures IP addresses are mapped to autonomous systems as expected.
+func TestASMap(t *testing.T) {
+	asmap := testASMap(t)
+	tests := []struct {
+		ip   string
+		want uint32
+	}{
+		{"1.2.3.4", 100},
+		{"127.255.255.255", 100},
+		{"128.0.0.1", 70000},
+		{"255.255.255.255", 70000},
+		{"2001:470::1", 0},
+	}
+	for _, test := range tests {
+		if got := asmap.ASN(net.ParseIP(test.ip)); got != test.want {
+			t.Errorf("ASN(%s): got %d, want %d", test.ip, got, test.want)
+		}
+	}
+
+	// Truncated asmaps don't map any address.
+	truncated, err := addrmgr.NewASMap([]byte{0xff})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := truncated.ASN(net.ParseIP("1.2.3.4")); got != 0 {
+		t.Errorf("ASN: got %d from truncated asmap, want 0", got)
+	}
+}
+
+// TestGroupKeyASMap ensures addresses are grouped by their autonomous system
+// when an asmap is set.
+func TestGroupKeyASMap(t *testing.T) {
+	n := addrmgr.New("testgroupkeyasmap", nil)
+	tests := []struct {
+		ip          string
+		want, asmap string
+	}{
+		{"1.2.3.4", "1.2.0.0", "as100"},
+		{"12.1.2.3", "12.1.0.0", "as100"},
+		{"173.194.115.66", "173.194.0.0", "as70000"},
+		{"2001:470::1", "2001:470::", "2001:470::"},
+		{"127.0.0.1", "local", "local"},
+	}
+	for _, test := range tests {
+		na := wire.NewNetAddressIPPort(net.ParseIP(test.ip), 8333,
+			wire.SFNodeNetwork)
+		if got := n.GroupKey(na); got != test.want {
+			t.Errorf("GroupKey(%s): got %s, want %s", test.ip, got,
+				test.want)
+		}
+	}
+
+	n.SetASMap(testASMap(t))
+	for _, test := range tests {
+		na := wire.NewNetAddressIPPort(net.ParseIP(test.ip), 8333,
+			wire.SFNodeNetwork)
+		if got := n.GroupKey(na); got != test.asmap {
+			t.Errorf("GroupKey(%s) with asmap: got %s, want %s",
+				test.ip, got, test.asmap)
+		}
+	}
+}
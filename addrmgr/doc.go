@@ -34,5 +34,16 @@ provided by the caller about connected, known good, and attempted addresses to
 periodically purge peers which no longer appear to be good peers as well as
 bias the selection toward known good peers.  The general idea is to make a best
 effort at only providing usable addresses.
+
+# Eclipse Attack Resistance
+
+An eclipse attack isolates a node by filling all of its outbound connection
+slots with peers controlled by the attacker.  The address manager provides a few
+tools to make this harder.  Addresses of outbound peers can be saved as anchors
+which are connected to first on the next start, an optional asmap groups
+addresses by the autonomous system they are announced by so outbound peers can
+be spread over network operators, and addresses of the new table can be picked
+for feeler connections which test them and move the reachable ones to the tried
+table.
 */
 package addrmgr
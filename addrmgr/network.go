@@ -84,6 +84,9 @@ var (
 	// { magic 6 bytes, 10 bytes base32 decode of key hash }
 	onionCatNet = ipNet("fd87:d87e:eb43::", 48, 128)
 
+	// cjdnsNet defines the IPv6 address block used by CJDNS (FC00::/8).
+	cjdnsNet = ipNet("FC00::", 8, 128)
+
 	// zero4Net defines the IPv4 address block for address staring with 0
 	// (0.0.0.0/8).
 	zero4Net = ipNet("0.0.0.0", 8, 32)
@@ -117,6 +120,31 @@ func IsOnionCatTor(na *wire.NetAddress) bool {
 	return onionCatNet.Contains(na.IP)
 }
 
+// IsTorV3 returns whether or not the passed address is a Tor v3 onion
+// address.  These addresses can only be relayed with addrv2 messages.
+func IsTorV3(na *wire.NetAddress) bool {
+	return na.NetworkID() == wire.NetworkTorV3
+}
+
+// IsI2P returns whether or not the passed address is an I2P address.  These
+// addresses can only be relayed with addrv2 messages.
+func IsI2P(na *wire.NetAddress) bool {
+	return na.NetworkID() == wire.NetworkI2P
+}
+
+// IsCJDNS returns whether or not the passed address is a CJDNS address.  These
+// addresses are IPv6 addresses in the FC00::/8 range, but can only be relayed
+// with addrv2 messages.
+func IsCJDNS(na *wire.NetAddress) bool {
+	return na.NetworkID() == wire.NetworkCJDNS
+}
+
+// isTor returns whether or not the passed address is a Tor address of either
+// version.
+func isTor(na *wire.NetAddress) bool {
+	return IsOnionCatTor(na) || IsTorV3(na)
+}
+
 // IsRFC1918 returns whether or not the passed address is part of the IPv4
 // private network address space as defined by RFC1918 (10.0.0.0/8,
 // 172.16.0.0/12, or 192.168.0.0/16).
@@ -212,7 +240,20 @@ func IsRFC6598(na *wire.NetAddress) bool {
 // considered invalid under the following circumstances:
 // IPv4: It is either a zero or all bits set address.
 // IPv6: It is either a zero or RFC3849 documentation address.
+// Tor v3 and I2P: The address is not 32 bytes.
+// CJDNS: The address is not in the FC00::/8 range.
+// Other networks: Always.
 func IsValid(na *wire.NetAddress) bool {
+	switch na.NetworkID() {
+	case wire.NetworkTorV3, wire.NetworkI2P:
+		return len(na.Addr) == 32
+	case wire.NetworkCJDNS:
+		return cjdnsNet.Contains(na.IP)
+	case wire.NetworkIPv4, wire.NetworkIPv6, wire.NetworkTorV2:
+	default:
+		return false
+	}
+
 	// IsUnspecified returns if address is 0, so only all bits set, and
 	// RFC3849 need to be explicitly checked.
 	return na.IP != nil && !(na.IP.IsUnspecified() ||
@@ -221,8 +262,12 @@ func IsValid(na *wire.NetAddress) bool {
 
 // IsRoutable returns whether or not the passed address is routable over
 // the public internet.  This is true as long as the address is valid and is not
-// in any reserved ranges.
+// in any reserved ranges.  Valid Tor v3, I2P and CJDNS addresses are always
+// routable over their network.
 func IsRoutable(na *wire.NetAddress) bool {
+	if IsTorV3(na) || IsI2P(na) || IsCJDNS(na) {
+		return IsValid(na)
+	}
 	return IsValid(na) && !(IsRFC1918(na) || IsRFC2544(na) ||
 		IsRFC3927(na) || IsRFC4862(na) || IsRFC3849(na) ||
 		IsRFC4843(na) || IsRFC5737(na) || IsRFC6598(na) ||
@@ -232,8 +277,9 @@ func IsRoutable(na *wire.NetAddress) bool {
 // GroupKey returns a string representing the network group an address is part
 // of.  This is the /16 for IPv4, the /32 (/36 for he.net) for IPv6, the string
 // "local" for a local address, the string "tor:key" where key is the /4 of the
-// onion address for Tor address, and the string "unroutable" for an unroutable
-// address.
+// onion address for Tor address, the strings "torv3:key", "i2p:key" and
+// "cjdns:key" where key is the /4 of the address for addresses of these
+// networks, and the string "unroutable" for an unroutable address.
 func GroupKey(na *wire.NetAddress) string {
 	if IsLocal(na) {
 		return "local"
@@ -241,6 +287,15 @@ func GroupKey(na *wire.NetAddress) string {
 	if !IsRoutable(na) {
 		return "unroutable"
 	}
+	switch na.NetworkID() {
+	case wire.NetworkTorV3:
+		return fmt.Sprintf("torv3:%d", na.Addr[0]>>4)
+	case wire.NetworkI2P:
+		return fmt.Sprintf("i2p:%d", na.Addr[0]>>4)
+	case wire.NetworkCJDNS:
+		// The first byte is the same for all CJDNS addresses.
+		return fmt.Sprintf("cjdns:%d", na.IP[1]>>4)
+	}
 	if IsIPv4(na) {
 		return na.IP.Mask(net.CIDRMask(16, 32)).String()
 	}
@@ -5,8 +5,10 @@
 package addrmgr_test
 
 import (
+	"bytes"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/gcash/bchd/addrmgr"
 	"github.com/gcash/bchd/wire"
@@ -200,3 +202,55 @@ func TestGroupKey(t *testing.T) {
 		}
 	}
 }
+
+// TestAddrV2Networks ensures addresses of the networks which can only be
+// relayed with addrv2 messages are classified as intended.
+func TestAddrV2Networks(t *testing.T) {
+	newAddr := func(network wire.NetworkID, addr []byte) *wire.NetAddress {
+		na, err := wire.NewNetAddressV2(time.Now(), wire.SFNodeNetwork,
+			network, addr, 8333)
+		if err != nil {
+			t.Fatalf("NewNetAddressV2: %v", err)
+		}
+		return na
+	}
+	key := bytes.Repeat([]byte{0xa5}, 32)
+	cjdns := net.ParseIP("fc12:3456::1")
+
+	tests := []struct {
+		name     string
+		in       *wire.NetAddress
+		valid    bool
+		routable bool
+		group    string
+	}{
+		{"torv3", newAddr(wire.NetworkTorV3, key), true, true, "torv3:10"},
+		{"i2p", newAddr(wire.NetworkI2P, key), true, true, "i2p:10"},
+		{"cjdns", newAddr(wire.NetworkCJDNS, cjdns), true, true, "cjdns:1"},
+		{"cjdns outside fc00::/8", newAddr(wire.NetworkCJDNS,
+			net.ParseIP("2602:100::1")), false, false, "unroutable"},
+		{"torv3 short", &wire.NetAddress{Network: wire.NetworkTorV3,
+			Addr: key[:31]}, false, false, "unroutable"},
+		{"unknown network", &wire.NetAddress{Network: 42,
+			Addr: key}, false, false, "unroutable"},
+		// Without an explicit network a CJDNS address is a unique local
+		// IPv6 address.
+		{"ipv6 fc00::/8", wire.NewNetAddressIPPort(cjdns, 8333,
+			wire.SFNodeNetwork), true, false, "unroutable"},
+	}
+
+	for _, test := range tests {
+		if valid := addrmgr.IsValid(test.in); valid != test.valid {
+			t.Errorf("IsValid %s: got %v, want %v", test.name, valid,
+				test.valid)
+		}
+		if routable := addrmgr.IsRoutable(test.in); routable != test.routable {
+			t.Errorf("IsRoutable %s: got %v, want %v", test.name,
+				routable, test.routable)
+		}
+		if group := addrmgr.GroupKey(test.in); group != test.group {
+			t.Errorf("GroupKey %s: got %q, want %q", test.name, group,
+				test.group)
+		}
+	}
+}
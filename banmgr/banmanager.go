@@ -0,0 +1,463 @@
+package banmgr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gcash/bchd/connmgr"
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/wire"
+)
+
+const (
+	// scoreRetention is how long the ban score of a host is remembered
+	// after its last offense.  Scores are kept per host rather than per
+	// connection so a peer can't reset its score by reconnecting.
+	scoreRetention = 24 * time.Hour
+
+	// ReasonManual is the reason of bans added through the API.
+	ReasonManual = "manually added"
+
+	// ReasonMisbehaving is the reason of bans of hosts whose ban score
+	// exceeded the threshold.
+	ReasonMisbehaving = "node misbehaving"
+)
+
+var (
+	// banListBucketName is the name of the database bucket used to house
+	// the ban list.
+	banListBucketName = []byte("banlist")
+
+	// ErrAlreadyBanned is returned when attempting to ban a subnet which
+	// is already banned.
+	ErrAlreadyBanned = errors.New("subnet already banned")
+
+	// ErrNotBanned is returned when attempting to unban a subnet which is
+	// not banned.
+	ErrNotBanned = errors.New("subnet not banned")
+)
+
+// Offense describes a kind of peer misbehavior and the ban score it adds.  The
+// persistent score is kept until the host's score is forgotten, while the
+// transient score decays over time, see connmgr.DynamicBanScore.
+type Offense struct {
+	Name       string
+	Persistent uint32
+	Transient  uint32
+}
+
+// The offenses the server scores.  Flooding with requests which are expensive
+// to serve adds a transient score, so only peers which keep doing it get
+// banned, while protocol violations add a persistent score.
+var (
+	// OffenseMempool is a mempool request sent too frequently.
+	OffenseMempool = Offense{Name: "mempool", Transient: 33}
+
+	// OffenseCFMempool is a getcfmempool request sent too frequently.
+	OffenseCFMempool = Offense{Name: "getcfmempool", Transient: 33}
+
+	// OffenseGetBlockTxns is a getblocktxns request.
+	OffenseGetBlockTxns = Offense{Name: "getblocktxns", Transient: 33}
+
+	// OffenseWeakBlock is an invalid or unsolicited weak block.
+	OffenseWeakBlock = Offense{Name: "weakblock", Transient: 20}
+
+	// OffenseFilterLoad is a filterload request sent too frequently.
+	// Every loaded filter is matched against each relayed transaction, so
+	// reloading it repeatedly is a cheap way to keep the server busy.
+	OffenseFilterLoad = Offense{Name: "filterload", Transient: 10}
+
+	// OffenseFilterAdd is a filteradd request sent too frequently.
+	OffenseFilterAdd = Offense{Name: "filteradd", Transient: 2}
+)
+
+// OffenseGetData returns the offense of a getdata request for the passed
+// number of items.  Its transient score is proportional to the number of items
+// and just below the default threshold for a full message.
+func OffenseGetData(items int) Offense {
+	return Offense{
+		Name:      "getdata",
+		Transient: uint32(items) * 99 / wire.MaxInvPerMsg,
+	}
+}
+
+// OffenseBloomFilter returns the offense of sending the passed bloom filter
+// command to a server which doesn't signal support for bloom filters.
+func OffenseBloomFilter(cmd string) Offense {
+	return Offense{Name: cmd, Persistent: 100}
+}
+
+// Config is a descriptor containing the ban manager configuration.
+type Config struct {
+	// DB is the database the ban list is stored in.  The ban list is only
+	// kept in memory when it is nil.
+	DB database.DB
+
+	// Threshold is the ban score above which a host is banned.
+	Threshold uint32
+
+	// BanDuration is the duration of bans of misbehaving hosts and the
+	// default duration of bans added through the API.
+	BanDuration time.Duration
+}
+
+// Ban describes a banned subnet.
+type Ban struct {
+	Subnet  *net.IPNet
+	Created time.Time
+	Until   time.Time
+	Reason  string
+}
+
+// hostScore is the ban score of a host.
+type hostScore struct {
+	score       connmgr.DynamicBanScore
+	lastOffense time.Time
+}
+
+// BanManager keeps the ban scores of misbehaving hosts and the list of banned
+// subnets.  Bans are persisted in the database so they survive restarts.
+type BanManager struct {
+	cfg Config
+
+	mtx    sync.Mutex
+	scores map[string]*hostScore
+	bans   map[string]*Ban
+}
+
+// New returns a new ban manager which loads the ban list stored in the
+// database.  Expired bans are removed.
+func New(cfg *Config) (*BanManager, error) {
+	m := &BanManager{
+		cfg:    *cfg,
+		scores: make(map[string]*hostScore),
+		bans:   make(map[string]*Ban),
+	}
+	if cfg.DB == nil {
+		return m, nil
+	}
+
+	now := time.Now()
+	err := cfg.DB.Update(func(dbTx database.Tx) error {
+		bucket, err := dbTx.Metadata().CreateBucketIfNotExists(
+			banListBucketName)
+		if err != nil {
+			return err
+		}
+		var expired [][]byte
+		err = bucket.ForEach(func(k, v []byte) error {
+			ban, err := deserializeBan(string(k), v)
+			if err != nil {
+				return err
+			}
+			if !now.Before(ban.Until) {
+				expired = append(expired, k)
+				return nil
+			}
+			m.bans[ban.Subnet.String()] = ban
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Loaded %d banned subnets", len(m.bans))
+	return m, nil
+}
+
+// serializeBan returns the serialization of the passed ban as stored in the
+// database, keyed by its subnet.  The serialized format is:
+//
+//	<created><until><reason>
+//
+//	Field    Type    Size
+//	created  int64   8 bytes
+//	until    int64   8 bytes
+//	reason   string  remaining bytes
+func serializeBan(ban *Ban) []byte {
+	b := make([]byte, 16+len(ban.Reason))
+	binary.LittleEndian.PutUint64(b[0:8], uint64(ban.Created.Unix()))
+	binary.LittleEndian.PutUint64(b[8:16], uint64(ban.Until.Unix()))
+	copy(b[16:], ban.Reason)
+	return b
+}
+
+// deserializeBan returns the ban of the passed subnet from its serialization.
+func deserializeBan(subnet string, b []byte) (*Ban, error) {
+	_, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 16 {
+		return nil, fmt.Errorf("malformed ban of subnet %s", subnet)
+	}
+	return &Ban{
+		Subnet:  ipNet,
+		Created: time.Unix(int64(binary.LittleEndian.Uint64(b[0:8])), 0),
+		Until:   time.Unix(int64(binary.LittleEndian.Uint64(b[8:16])), 0),
+		Reason:  string(b[16:]),
+	}, nil
+}
+
+// putBan stores the passed ban in the database.
+func (m *BanManager) putBan(ban *Ban) error {
+	if m.cfg.DB == nil {
+		return nil
+	}
+	return m.cfg.DB.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(banListBucketName)
+		return bucket.Put([]byte(ban.Subnet.String()), serializeBan(ban))
+	})
+}
+
+// deleteBans removes the bans of the passed subnets from the database.
+func (m *BanManager) deleteBans(subnets []string) error {
+	if m.cfg.DB == nil || len(subnets) == 0 {
+		return nil
+	}
+	return m.cfg.DB.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(banListBucketName)
+		for _, subnet := range subnets {
+			if err := bucket.Delete([]byte(subnet)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// removeExpired removes the bans which expired at the passed time.
+//
+// This function MUST be called with the manager lock held.
+func (m *BanManager) removeExpired(now time.Time) {
+	var expired []string
+	for key, ban := range m.bans {
+		if !now.Before(ban.Until) {
+			log.Infof("Subnet %s is no longer banned", key)
+			expired = append(expired, key)
+			delete(m.bans, key)
+		}
+	}
+	if err := m.deleteBans(expired); err != nil {
+		log.Errorf("Unable to remove expired bans: %v", err)
+	}
+}
+
+// Misbehaving increases the ban score of the passed host by the score of the
+// offense and returns the resulting score.  It also returns whether the score
+// exceeds the ban threshold, in which case the caller is expected to
+// disconnect the peer and ban the host.  Offenses which don't add any score
+// still log a warning when the score is above half of the threshold.
+//
+// This function is safe for concurrent access.
+func (m *BanManager) Misbehaving(host string, offense Offense) (uint32, bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	now := time.Now()
+	for key, hs := range m.scores {
+		if now.Sub(hs.lastOffense) > scoreRetention {
+			delete(m.scores, key)
+		}
+	}
+
+	hs, ok := m.scores[host]
+	if !ok {
+		hs = &hostScore{}
+		m.scores[host] = hs
+	}
+	hs.lastOffense = now
+
+	warnThreshold := m.cfg.Threshold >> 1
+	if offense.Persistent == 0 && offense.Transient == 0 {
+		// The score is not being increased, but a warning message is
+		// still logged if the score is above the warn threshold.
+		score := hs.score.Int()
+		if score > warnThreshold {
+			log.Warnf("Misbehaving host %s: %s -- ban score is %d, "+
+				"it was not increased this time", host,
+				offense.Name, score)
+		}
+		return score, false
+	}
+	score := hs.score.Increase(offense.Persistent, offense.Transient)
+	if score > warnThreshold {
+		log.Warnf("Misbehaving host %s: %s -- ban score increased to %d",
+			host, offense.Name, score)
+	}
+	return score, score > m.cfg.Threshold
+}
+
+// Score returns the current ban score of the passed host.
+//
+// This function is safe for concurrent access.
+func (m *BanManager) Score(host string) uint32 {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	hs, ok := m.scores[host]
+	if !ok {
+		return 0
+	}
+	return hs.score.Int()
+}
+
+// BanHost bans the passed IP address for the configured ban duration because
+// it misbehaved.  Its ban score is reset so it starts over once the ban
+// expires.  Extending an existing ban is not an error.
+//
+// This function is safe for concurrent access.
+func (m *BanManager) BanHost(ip net.IP) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	delete(m.scores, ip.String())
+	now := time.Now()
+	ban := &Ban{
+		Subnet:  HostSubnet(ip),
+		Created: now,
+		Until:   now.Add(m.cfg.BanDuration),
+		Reason:  ReasonMisbehaving,
+	}
+	key := ban.Subnet.String()
+	if existing, ok := m.bans[key]; ok && existing.Until.After(ban.Until) {
+		return nil
+	}
+	m.bans[key] = ban
+	log.Infof("Banned host %s for %v", ip, m.cfg.BanDuration)
+	return m.putBan(ban)
+}
+
+// Ban bans the passed subnet until the passed time, or for the configured ban
+// duration when it is zero.  ErrAlreadyBanned is returned when the subnet is
+// already banned.
+//
+// This function is safe for concurrent access.
+func (m *BanManager) Ban(subnet *net.IPNet, until time.Time) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	now := time.Now()
+	m.removeExpired(now)
+
+	key := subnet.String()
+	if _, ok := m.bans[key]; ok {
+		return ErrAlreadyBanned
+	}
+	if until.IsZero() {
+		until = now.Add(m.cfg.BanDuration)
+	}
+	ban := &Ban{
+		Subnet:  subnet,
+		Created: now,
+		Until:   until,
+		Reason:  ReasonManual,
+	}
+	m.bans[key] = ban
+	log.Infof("Banned subnet %s until %v", key, until)
+	return m.putBan(ban)
+}
+
+// Unban removes the ban of the passed subnet.  ErrNotBanned is returned when
+// the subnet is not banned.
+//
+// This function is safe for concurrent access.
+func (m *BanManager) Unban(subnet *net.IPNet) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	key := subnet.String()
+	if _, ok := m.bans[key]; !ok {
+		return ErrNotBanned
+	}
+	delete(m.bans, key)
+	log.Infof("Unbanned subnet %s", key)
+	return m.deleteBans([]string{key})
+}
+
+// ClearBans removes all bans.
+//
+// This function is safe for concurrent access.
+func (m *BanManager) ClearBans() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	subnets := make([]string, 0, len(m.bans))
+	for key := range m.bans {
+		subnets = append(subnets, key)
+	}
+	m.bans = make(map[string]*Ban)
+	log.Infof("Cleared %d bans", len(subnets))
+	return m.deleteBans(subnets)
+}
+
+// IsBanned returns whether the passed IP address is in a banned subnet.
+//
+// This function is safe for concurrent access.
+func (m *BanManager) IsBanned(ip net.IP) bool {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.removeExpired(time.Now())
+	for _, ban := range m.bans {
+		if ban.Subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Bans returns the bans which have not expired yet sorted by subnet.
+//
+// This function is safe for concurrent access.
+func (m *BanManager) Bans() []Ban {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.removeExpired(time.Now())
+	bans := make([]Ban, 0, len(m.bans))
+	for _, ban := range m.bans {
+		bans = append(bans, *ban)
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		a, b := bans[i].Subnet, bans[j].Subnet
+		if c := bytes.Compare(a.IP, b.IP); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(a.Mask, b.Mask) < 0
+	})
+	return bans
+}
+
+// HostSubnet returns the subnet which only contains the passed IP address.
+func HostSubnet(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip.To16(), Mask: net.CIDRMask(128, 128)}
+}
+
+// ParseSubnet parses a subnet in CIDR notation or a single IP address, which
+// is treated as the subnet only containing it.
+func ParseSubnet(s string) (*net.IPNet, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		return HostSubnet(ip), nil
+	}
+	_, subnet, err := net.ParseCIDR(s)
+	return subnet, err
+}
@@ -0,0 +1,164 @@
+package banmgr
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gcash/bchd/database"
+	_ "github.com/gcash/bchd/database/ffldb"
+	"github.com/gcash/bchd/wire"
+)
+
+// TestMisbehaving ensures ban scores are accumulated per host and exceed the
+// threshold as expected.
+func TestMisbehaving(t *testing.T) {
+	m, err := New(&Config{Threshold: 100, BanDuration: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		score, ban := m.Misbehaving("1.2.3.4", OffenseMempool)
+		if score != uint32(33*(i+1)) || ban {
+			t.Fatalf("Misbehaving #%d: got score %d ban %v", i, score,
+				ban)
+		}
+	}
+	score, ban := m.Misbehaving("1.2.3.4", OffenseGetData(wire.MaxInvPerMsg))
+	if score <= 100 || !ban {
+		t.Fatalf("Misbehaving: got score %d ban %v, want ban", score, ban)
+	}
+	if got := m.Score("1.2.3.5"); got != 0 {
+		t.Fatalf("Score: unexpected score %d of other host", got)
+	}
+	score, ban = m.Misbehaving("1.2.3.5", OffenseBloomFilter("filterload"))
+	if score != 100 || ban {
+		t.Fatalf("Misbehaving: got score %d ban %v", score, ban)
+	}
+
+	// Scores are forgotten once the last offense is old enough.
+	m.scores["1.2.3.5"].lastOffense = time.Now().Add(-scoreRetention - time.Minute)
+	m.Misbehaving("1.2.3.4", Offense{Name: "none"})
+	if got := m.Score("1.2.3.5"); got != 0 {
+		t.Fatalf("Score: got %d after retention, want 0", got)
+	}
+}
+
+// TestBans ensures subnets are banned, unbanned, and expire as expected.
+func TestBans(t *testing.T) {
+	m, err := New(&Config{Threshold: 100, BanDuration: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subnet, err := ParseSubnet("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Ban(subnet, time.Time{}); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+	if err := m.Ban(subnet, time.Time{}); err != ErrAlreadyBanned {
+		t.Fatalf("Ban: got %v, want %v", err, ErrAlreadyBanned)
+	}
+	if !m.IsBanned(net.ParseIP("10.1.2.3")) {
+		t.Fatal("IsBanned: address in banned subnet is not banned")
+	}
+	if m.IsBanned(net.ParseIP("11.1.2.3")) {
+		t.Fatal("IsBanned: address outside banned subnet is banned")
+	}
+
+	host := net.ParseIP("2001:db8::1")
+	if err := m.BanHost(host); err != nil {
+		t.Fatalf("BanHost: %v", err)
+	}
+	bans := m.Bans()
+	if len(bans) != 2 || bans[0].Subnet.String() != "10.0.0.0/8" ||
+		bans[1].Subnet.String() != "2001:db8::1/128" ||
+		bans[1].Reason != ReasonMisbehaving {
+		t.Fatalf("Bans: unexpected bans %v", bans)
+	}
+
+	if err := m.Unban(subnet); err != nil {
+		t.Fatalf("Unban: %v", err)
+	}
+	if err := m.Unban(subnet); err != ErrNotBanned {
+		t.Fatalf("Unban: got %v, want %v", err, ErrNotBanned)
+	}
+
+	// Expired bans are removed.
+	m.bans["2001:db8::1/128"].Until = time.Now().Add(-time.Second)
+	if m.IsBanned(host) || len(m.Bans()) != 0 {
+		t.Fatal("expired ban was not removed")
+	}
+}
+
+// TestBanPersistence ensures the ban list is stored in the database.
+func TestBanPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "banmgr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := database.Create("ffldb", filepath.Join(dir, "db"), wire.SimNet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	cfg := &Config{DB: db, Threshold: 100, BanDuration: time.Hour}
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	until := time.Now().Add(time.Hour).Truncate(time.Second)
+	subnets := []string{"1.2.3.0/24", "2001:db8::/32", "5.6.7.8"}
+	for _, s := range subnets {
+		subnet, err := ParseSubnet(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Ban(subnet, until); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expired, _ := ParseSubnet("9.9.9.9")
+	if err := m.Ban(expired, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	unbanned, _ := ParseSubnet("5.6.7.8")
+	if err := m.Unban(unbanned); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err = New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bans := m.Bans()
+	if len(bans) != 2 {
+		t.Fatalf("got %d bans after reload, want 2", len(bans))
+	}
+	for i, want := range []string{"1.2.3.0/24", "2001:db8::/32"} {
+		if bans[i].Subnet.String() != want || !bans[i].Until.Equal(until) ||
+			bans[i].Reason != ReasonManual {
+			t.Fatalf("unexpected ban %v after reload, want %s", bans[i],
+				want)
+		}
+	}
+
+	if err := m.ClearBans(); err != nil {
+		t.Fatal(err)
+	}
+	m, err = New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Bans()) != 0 {
+		t.Fatal("bans were not cleared")
+	}
+}
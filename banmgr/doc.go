@@ -0,0 +1,15 @@
+/*
+Package banmgr implements the misbehavior scoring and ban list of peers.
+
+Misbehaving peers are scored per offense.  Each offense adds a persistent
+score, a transient score which decays over time, or both, so peers which
+occasionally send an expensive request are tolerated while peers which keep
+flooding or violate the protocol exceed the ban threshold.  Scores are kept per
+host rather than per connection, so a peer can't reset its score by
+reconnecting, and are forgotten a day after the last offense.
+
+Hosts whose score exceeds the threshold are banned for the configured ban
+duration.  Subnets may also be banned and unbanned manually.  The ban list is
+stored in the database, so bans survive restarts until they expire.
+*/
+package banmgr
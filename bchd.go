@@ -94,6 +94,16 @@ func bchdMain(serverChan chan<- *server) error {
 		defer pprof.StopCPUProfile()
 	}
 
+	// Write tracing spans if requested.
+	if cfg.TraceFile != "" {
+		stopTracing, err := startTracing(cfg.TraceFile)
+		if err != nil {
+			bchdLog.Errorf("Unable to start tracing: %v", err)
+			return err
+		}
+		defer stopTracing()
+	}
+
 	// Perform upgrades to bchd as new versions require it.
 	if err := doUpgrades(); err != nil {
 		bchdLog.Errorf("%v", err)
@@ -159,6 +169,80 @@ func bchdMain(serverChan chan<- *server) error {
 
 		return nil
 	}
+	if cfg.DropTokenIndex {
+		if err := indexers.DropTokenIndex(db, interrupt); err != nil {
+			bchdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropStatsIndex {
+		if err := indexers.DropStatsIndex(db, interrupt); err != nil {
+			bchdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropCoinAgeIndex {
+		if err := indexers.DropCoinAgeIndex(db, interrupt); err != nil {
+			bchdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropWatchSetIndex {
+		if err := indexers.DropWatchSetIndex(db, interrupt); err != nil {
+			bchdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+
+	// Drop all of the optional indexes when the chain is to be rebuilt from
+	// the stored blocks, since they would otherwise refer to a chain which
+	// no longer exists.  The enabled indexes are rebuilt along with the
+	// chain.
+	if cfg.Reindex {
+		if err := indexers.DropAddrIndex(db, interrupt); err != nil {
+			bchdLog.Errorf("%v", err)
+			return err
+		}
+		if err := indexers.DropTxIndex(db, interrupt); err != nil {
+			bchdLog.Errorf("%v", err)
+			return err
+		}
+		if err := indexers.DropCfIndex(db, interrupt); err != nil {
+			bchdLog.Errorf("%v", err)
+			return err
+		}
+		if err := indexers.DropSlpIndex(db, interrupt); err != nil {
+			bchdLog.Errorf("%v", err)
+			return err
+		}
+		if err := indexers.DropTokenIndex(db, interrupt); err != nil {
+			bchdLog.Errorf("%v", err)
+			return err
+		}
+		if err := indexers.DropStatsIndex(db, interrupt); err != nil {
+			bchdLog.Errorf("%v", err)
+			return err
+		}
+		if err := indexers.DropCoinAgeIndex(db, interrupt); err != nil {
+			bchdLog.Errorf("%v", err)
+			return err
+		}
+
+		// The watch sets registered by clients are kept, only the
+		// transactions recorded for them are rebuilt.
+		if err := indexers.ResetWatchSetIndex(db, interrupt); err != nil {
+			bchdLog.Errorf("%v", err)
+			return err
+		}
+	}
 
 	// Create server and start it.
 	server, err := newServer(cfg.Listeners, cfg.AgentBlacklist, cfg.AgentWhitelist, db, activeNetParams.Params,
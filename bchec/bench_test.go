@@ -155,3 +155,14 @@ func BenchmarkFieldNormalize(b *testing.B) {
 		f.Normalize()
 	}
 }
+
+// BenchmarkSchnorrBatchVerify benchmarks how long it takes to verify a batch of
+// 64 Schnorr signatures at once.
+func BenchmarkSchnorrBatchVerify(b *testing.B) {
+	batch := signSchnorrBatch(b, 64)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		batch.Verify()
+	}
+}
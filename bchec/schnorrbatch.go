@@ -0,0 +1,278 @@
+package bchec
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"math/big"
+)
+
+// schnorrBatchCoefficientSize is the size in bytes of the random coefficients
+// the signatures of a batch are weighted with.  128-bit coefficients make the
+// probability of an invalid batch passing verification negligible.
+const schnorrBatchCoefficientSize = 16
+
+// SchnorrBatchVerifier verifies multiple Schnorr signatures at once, which is
+// faster than verifying each of them individually since the scalar
+// multiplications of all signatures are combined into a single multi-scalar
+// multiplication.
+//
+// Rather than checking s*G = R + e*P for every signature, the verifier checks
+// that (sum a_i*s_i)*G = sum a_i*R_i + sum (a_i*e_i)*P_i for random
+// coefficients a_i.  The batch only verifies when all signatures in it are
+// valid, save for a negligible probability.  A batch which fails verification
+// contains at least one invalid signature, but it is not known which, so the
+// signatures must be verified individually to find out.
+type SchnorrBatchVerifier struct {
+	sigs    []*Signature
+	hashes  [][]byte
+	pubKeys []*PublicKey
+}
+
+// NewSchnorrBatchVerifier returns a new empty Schnorr batch verifier.
+func NewSchnorrBatchVerifier() *SchnorrBatchVerifier {
+	return &SchnorrBatchVerifier{}
+}
+
+// Add adds the passed Schnorr signature of the passed hash for the passed
+// public key to the batch.
+func (b *SchnorrBatchVerifier) Add(sig *Signature, hash []byte, pubKey *PublicKey) {
+	b.sigs = append(b.sigs, sig)
+	b.hashes = append(b.hashes, hash)
+	b.pubKeys = append(b.pubKeys, pubKey)
+}
+
+// Len returns the number of signatures in the batch.
+func (b *SchnorrBatchVerifier) Len() int {
+	return len(b.sigs)
+}
+
+// Verify returns whether all of the signatures in the batch are valid.  An
+// empty batch is valid, and a batch which contains a signature which is not a
+// Schnorr signature is not.
+func (b *SchnorrBatchVerifier) Verify() bool {
+	switch len(b.sigs) {
+	case 0:
+		return true
+	case 1:
+		// There is nothing to gain from batching a single signature.
+		return b.sigs[0].sigType == SignatureTypeSchnorr &&
+			b.sigs[0].Verify(b.hashes[0], b.pubKeys[0])
+	}
+
+	curve := S256()
+	coefficients := make([]byte, schnorrBatchCoefficientSize*(len(b.sigs)-1))
+	if _, err := rand.Read(coefficients); err != nil {
+		return false
+	}
+
+	// Collect the points and scalars of the right hand side of the batch
+	// equation while summing up the scalar of the left hand side.
+	xs := make([]*fieldVal, 0, 2*len(b.sigs))
+	ys := make([]*fieldVal, 0, 2*len(b.sigs))
+	ks := make([][]byte, 0, 2*len(b.sigs))
+	sumS := new(big.Int)
+	a, e, tmp := new(big.Int), new(big.Int), new(big.Int)
+	for i, sig := range b.sigs {
+		pubKey := b.pubKeys[i]
+		if sig.sigType != SignatureTypeSchnorr {
+			return false
+		}
+		if _, ok := pubKey.Curve.(*KoblitzCurve); !ok {
+			return false
+		}
+
+		// Signature is invalid if s >= order or r >= p.
+		if sig.S.Cmp(curve.N) >= 0 || sig.R.Cmp(curve.P) >= 0 {
+			return false
+		}
+
+		// Recover R as the point whose x coordinate is r and whose y
+		// coordinate is a quadratic residue, which is the only point
+		// individual verification accepts.
+		rx := new(fieldVal).SetByteSlice(sig.R.Bytes())
+		c := new(fieldVal).SquareVal(rx).Mul(rx).AddInt(7)
+		ry, ok := new(fieldVal).SqrtVal(c)
+		if !ok {
+			return false
+		}
+
+		// Compute scalar e = Hash(r || compressed(P) || m) mod N
+		eBytes := sha256.Sum256(append(append(padIntBytes(sig.R),
+			pubKey.SerializeCompressed()...), b.hashes[i]...))
+		e.SetBytes(eBytes[:])
+		e.Mod(e, curve.N)
+
+		// The first signature is weighted with one, which saves a
+		// scalar multiplication without weakening the check.
+		if i == 0 {
+			a.SetInt64(1)
+		} else {
+			start := schnorrBatchCoefficientSize * (i - 1)
+			a.SetBytes(coefficients[start : start+schnorrBatchCoefficientSize])
+		}
+
+		sumS.Add(sumS, tmp.Mul(a, sig.S))
+		px, py := curve.bigAffineToField(pubKey.X, pubKey.Y)
+		xs = append(xs, rx, px)
+		ys = append(ys, ry, py)
+		ks = append(ks, a.Bytes(), tmp.Mul(a, e).Mod(tmp, curve.N).Bytes())
+	}
+	sumS.Mod(sumS, curve.N)
+
+	// The batch is valid when the right hand side minus the left hand side
+	// is the point at infinity.
+	qx, qy, qz := curve.multiScalarMultJacobian(xs, ys, ks)
+	gx, gy, gz := curve.scalarBaseMultJacobian(sumS.Bytes())
+	gy.Normalize()
+	gyNeg := new(fieldVal).NegateVal(gy, 1)
+	rx, ry, rz := new(fieldVal), new(fieldVal), new(fieldVal)
+	curve.addJacobian(qx, qy, qz, gx, gyNeg, gz, rx, ry, rz)
+	return (rx.Normalize().IsZero() && ry.Normalize().IsZero()) ||
+		rz.Normalize().IsZero()
+}
+
+// signedWindowDigits returns the passed number of signed base 2^c digits of
+// the passed big endian scalar, least significant first.  Every digit is in
+// the range [-2^(c-1), 2^(c-1)], so only half as many buckets are needed for
+// each window as with unsigned digits, since -d * P is the same as d * -P.
+// The number of digits must be large enough to absorb the final carry.
+func signedWindowDigits(k []byte, c uint, numDigits int) []int32 {
+	digits := make([]int32, numDigits)
+	var carry int32
+	for i := range digits {
+		var digit int32
+		for bit := uint(0); bit < c; bit++ {
+			pos := uint(i)*c + bit
+			byteIdx := len(k) - 1 - int(pos/8)
+			if byteIdx < 0 {
+				break
+			}
+			digit |= int32(k[byteIdx]>>(pos%8)&1) << bit
+		}
+		digit += carry
+		carry = 0
+		if digit > 1<<(c-1) {
+			digit -= 1 << c
+			carry = 1
+		}
+		digits[i] = digit
+	}
+	return digits
+}
+
+// multiScalarMultJacobian returns the Jacobian coordinates of the sum of
+// k_i*(x_i, y_i) for the passed affine points, whose coordinates must be
+// normalized, and big endian scalars.
+//
+// Every scalar is decomposed with the endomorphism just like in
+// scalarMultJacobian, and the products are then summed up with the bucket
+// method, also known as Pippenger's algorithm.  The scalars are processed in
+// windows of c bits from the most significant to the least significant, and
+// each point is added to the bucket of its digit in the window, so the cost of
+// a window is one mixed addition per point plus a fixed number of additions to
+// sum up the buckets.  This is considerably cheaper than multiplying every
+// point separately when there are many of them.
+func (curve *KoblitzCurve) multiScalarMultJacobian(xs, ys []*fieldVal, ks [][]byte) (*fieldVal, *fieldVal, *fieldVal) {
+	// msmTerm houses a point along with the half of a decomposed scalar it
+	// is multiplied with.
+	type msmTerm struct {
+		x, y, yNeg *fieldVal
+		k          []byte
+		digits     []int32
+	}
+
+	// Decompose every scalar into k1 and k2 such that k * P = k1 * P +
+	// k2 * ϕ(P), where ϕ(x,y) = (βx,y), and flip the points as needed
+	// depending on the signs of k1 and k2.  See scalarMultJacobian for
+	// details.
+	terms := make([]msmTerm, 0, 2*len(ks))
+	maxLen := 0
+	for i, k := range ks {
+		k1, k2, signK1, signK2 := curve.splitK(curve.moduloReduce(k))
+
+		p1y := ys[i]
+		p1yNeg := new(fieldVal).NegateVal(p1y, 1)
+		p2x := new(fieldVal).Mul2(xs[i], curve.beta)
+		p2y := new(fieldVal).Set(p1y)
+		p2yNeg := new(fieldVal).NegateVal(p2y, 1)
+		if signK1 == -1 {
+			p1y, p1yNeg = p1yNeg, p1y
+		}
+		if signK2 == -1 {
+			p2y, p2yNeg = p2yNeg, p2y
+		}
+
+		terms = append(terms, msmTerm{x: xs[i], y: p1y, yNeg: p1yNeg, k: k1},
+			msmTerm{x: p2x, y: p2y, yNeg: p2yNeg, k: k2})
+		maxLen = max(maxLen, len(k1), len(k2))
+	}
+
+	// Choose the window size which minimizes the estimated number of
+	// additions, which is the number of windows times the number of
+	// points plus the number of buckets.
+	bits := maxLen * 8
+	c := uint(2)
+	for w := uint(3); w <= 16; w++ {
+		if (bits/int(w)+1)*(len(terms)+1<<w) <
+			(bits/int(c)+1)*(len(terms)+1<<c) {
+
+			c = w
+		}
+	}
+	numWindows := bits/int(c) + 1
+	for i := range terms {
+		terms[i].digits = signedWindowDigits(terms[i].k, c, numWindows)
+	}
+
+	qx, qy, qz := new(fieldVal), new(fieldVal), new(fieldVal)
+	one := new(fieldVal).SetInt(1)
+	buckets := make([][3]fieldVal, 1<<(c-1))
+	var rx, ry, rz, tx, ty, tz fieldVal
+	for w := numWindows - 1; w >= 0; w-- {
+		// Q = 2^c * Q
+		for i := uint(0); i < c; i++ {
+			curve.doubleJacobian(qx, qy, qz, qx, qy, qz)
+		}
+
+		// Add every point to the bucket of its digit.
+		for i := range buckets {
+			b := &buckets[i]
+			b[0].Zero()
+			b[1].Zero()
+			b[2].Zero()
+		}
+		for i := range terms {
+			term := &terms[i]
+			digit := term.digits[w]
+			switch {
+			case digit > 0:
+				b := &buckets[digit-1]
+				curve.addJacobian(&b[0], &b[1], &b[2], term.x,
+					term.y, one, &b[0], &b[1], &b[2])
+			case digit < 0:
+				b := &buckets[-digit-1]
+				curve.addJacobian(&b[0], &b[1], &b[2], term.x,
+					term.yNeg, one, &b[0], &b[1], &b[2])
+			}
+		}
+
+		// Sum up the buckets weighted with their digit by adding the
+		// running sum of the buckets from the highest digit down.
+		rx.Zero()
+		ry.Zero()
+		rz.Zero()
+		tx.Zero()
+		ty.Zero()
+		tz.Zero()
+		for i := len(buckets) - 1; i >= 0; i-- {
+			b := &buckets[i]
+			curve.addJacobian(&rx, &ry, &rz, &b[0], &b[1], &b[2],
+				&rx, &ry, &rz)
+			curve.addJacobian(&tx, &ty, &tz, &rx, &ry, &rz, &tx, &ty,
+				&tz)
+		}
+		curve.addJacobian(qx, qy, qz, &tx, &ty, &tz, qx, qy, qz)
+	}
+
+	return qx, qy, qz
+}
@@ -0,0 +1,111 @@
+package bchec
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// signSchnorrBatch returns a batch verifier with the passed number of valid
+// Schnorr signatures made with random keys over distinct messages.
+func signSchnorrBatch(t testing.TB, numSigs int) *SchnorrBatchVerifier {
+	b := NewSchnorrBatchVerifier()
+	for i := 0; i < numSigs; i++ {
+		privKey, err := NewPrivateKey(S256())
+		if err != nil {
+			t.Fatalf("failed to create private key: %v", err)
+		}
+		hash := sha256.Sum256([]byte(fmt.Sprintf("message %d", i)))
+		sig, err := privKey.SignSchnorr(hash[:])
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		b.Add(sig, hash[:], privKey.PubKey())
+	}
+	return b
+}
+
+// TestSchnorrBatchVerify ensures batches of Schnorr signatures only verify
+// when all of the signatures in them are valid.
+func TestSchnorrBatchVerify(t *testing.T) {
+	t.Parallel()
+
+	if !NewSchnorrBatchVerifier().Verify() {
+		t.Fatal("empty batch did not verify")
+	}
+
+	for _, numSigs := range []int{1, 2, 3, 16, 65} {
+		b := signSchnorrBatch(t, numSigs)
+		if b.Len() != numSigs {
+			t.Fatalf("batch of %d: unexpected length %d", numSigs,
+				b.Len())
+		}
+		if !b.Verify() {
+			t.Fatalf("batch of %d valid signatures did not verify",
+				numSigs)
+		}
+
+		// Invalidate each of the signatures in turn by signing a
+		// different message and ensure the batch fails.
+		for i := 0; i < numSigs; i++ {
+			orig := b.hashes[i]
+			other := sha256.Sum256(orig)
+			b.hashes[i] = other[:]
+			if b.Verify() {
+				t.Fatalf("batch of %d verified with invalid "+
+					"signature %d", numSigs, i)
+			}
+			b.hashes[i] = orig
+		}
+
+		// Ensure an out of range s and an r which is not the x
+		// coordinate of a point fail.
+		last := numSigs - 1
+		sig := *b.sigs[last]
+		b.sigs[last] = &Signature{R: sig.R, S: S256().N, sigType: sig.sigType}
+		if b.Verify() {
+			t.Fatalf("batch of %d verified with s >= N", numSigs)
+		}
+		b.sigs[last] = &Signature{R: big.NewInt(5), S: sig.S, sigType: sig.sigType}
+		if b.Verify() {
+			t.Fatalf("batch of %d verified with invalid r", numSigs)
+		}
+
+		// Ensure an ECDSA signature fails.
+		b.sigs[last] = &Signature{R: sig.R, S: sig.S, sigType: SignatureTypeECDSA}
+		if b.Verify() {
+			t.Fatalf("batch of %d verified with ECDSA signature",
+				numSigs)
+		}
+		b.sigs[last] = &sig
+		if !b.Verify() {
+			t.Fatalf("batch of %d valid signatures did not verify",
+				numSigs)
+		}
+	}
+}
+
+// TestFieldSqrt ensures the square root of quadratic residues is computed
+// properly and non-residues are detected.
+func TestFieldSqrt(t *testing.T) {
+	t.Parallel()
+
+	p := S256().P
+	for i := int64(1); i < 200; i++ {
+		val := new(fieldVal).SetInt(uint(i))
+		root, ok := new(fieldVal).SqrtVal(val)
+		isResidue := big.Jacobi(big.NewInt(i), p) == 1
+		if ok != isResidue {
+			t.Fatalf("SqrtVal(%d): got residue %v, want %v", i, ok,
+				isResidue)
+		}
+		if !ok {
+			continue
+		}
+		b := root.Bytes()
+		if big.Jacobi(new(big.Int).SetBytes(b[:]), p) != 1 {
+			t.Fatalf("SqrtVal(%d): root is not a quadratic residue", i)
+		}
+	}
+}
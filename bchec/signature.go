@@ -89,6 +89,11 @@ func (sig *Signature) Serialize() []byte {
 	return b
 }
 
+// Type returns the type of the signature.
+func (sig *Signature) Type() SignatureType {
+	return sig.sigType
+}
+
 // Verify verifies either an ECDSA or Schnorr signature depending
 // on the SignatureType of the signature. It returns true if the
 // signature is valid, false otherwise.
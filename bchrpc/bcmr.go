@@ -0,0 +1,51 @@
+package bchrpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gcash/bchd/bchrpc/pb"
+	"github.com/gcash/bchd/bcmr"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GetBcmrTokenMetadata returns the metadata of a CashToken category from the
+// metadata registry most recently published by its authchain.
+//
+// **Requires Bcmr**
+func (s *GrpcServer) GetBcmrTokenMetadata(ctx context.Context, req *pb.GetBcmrTokenMetadataRequest) (*pb.GetBcmrTokenMetadataResponse, error) {
+	if s.bcmr == nil {
+		return nil, status.Error(codes.Unavailable, "bcmr required")
+	}
+	category, err := chainhash.NewHash(req.CategoryId)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid token category id")
+	}
+
+	md, err := s.bcmr.Resolve(ctx, category)
+	switch {
+	case errors.Is(err, bcmr.ErrAuthbaseNotFound), errors.Is(err, bcmr.ErrNoPublication),
+		errors.Is(err, bcmr.ErrIdentityNotFound):
+
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, bcmr.ErrFetchFailed):
+		return nil, status.Error(codes.Unavailable, err.Error())
+	case err != nil:
+		return nil, status.Errorf(codes.Internal, "failed to resolve token metadata: %v", err)
+	}
+
+	return &pb.GetBcmrTokenMetadataResponse{
+		CategoryId:   md.Category[:],
+		Name:         md.Name,
+		Description:  md.Description,
+		HasToken:     md.HasToken,
+		Symbol:       md.Symbol,
+		Decimals:     int32(md.Decimals),
+		Uris:         md.URIs,
+		AuthheadHash: md.AuthHead[:],
+		RegistryHash: md.RegistryHash[:],
+		RegistryUri:  md.RegistryURI,
+	}, nil
+}
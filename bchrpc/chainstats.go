@@ -0,0 +1,90 @@
+package bchrpc
+
+import (
+	"context"
+
+	"github.com/gcash/bchd/bchrpc/pb"
+	"github.com/gcash/bchd/blockchain/indexers"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxChainStatsPeriods is the maximum number of periods returned by a single
+// call to GetChainStats.
+const maxChainStatsPeriods = 10000
+
+// statsInterval returns the interval statistics are aggregated by for the
+// passed interval of a request.
+func statsInterval(interval pb.GetChainStatsRequest_Interval) (indexers.StatsInterval, error) {
+	switch interval {
+	case pb.GetChainStatsRequest_BLOCK:
+		return indexers.StatsPerBlock, nil
+	case pb.GetChainStatsRequest_DAY:
+		return indexers.StatsDaily, nil
+	case pb.GetChainStatsRequest_WEEK:
+		return indexers.StatsWeekly, nil
+	}
+	return 0, status.Error(codes.InvalidArgument, "invalid interval")
+}
+
+// statsEndHeight validates the passed block range of a request and returns
+// the height of its last block, which defaults to the best block when zero.
+func statsEndHeight(startHeight, endHeight, bestHeight int32) (int32, error) {
+	if endHeight == 0 {
+		endHeight = bestHeight
+	}
+	if startHeight < 0 || endHeight < startHeight {
+		return 0, status.Error(codes.InvalidArgument, "invalid block range")
+	}
+	if endHeight > bestHeight {
+		return 0, status.Error(codes.InvalidArgument, "end height is beyond the best block")
+	}
+	return endHeight, nil
+}
+
+// GetChainStats returns the fees, transaction count, size and output volume of
+// a range of blocks, either per block or aggregated by day or week.
+//
+// **Requires StatsIndex**
+func (s *GrpcServer) GetChainStats(ctx context.Context, req *pb.GetChainStatsRequest) (*pb.GetChainStatsResponse, error) {
+	if s.statsIndex == nil {
+		return nil, status.Error(codes.Unavailable, "statsindex required")
+	}
+
+	interval, err := statsInterval(req.Interval)
+	if err != nil {
+		return nil, err
+	}
+	bestHeight := s.chain.BestSnapshot().Height
+	endHeight, err := statsEndHeight(req.StartHeight, req.EndHeight, bestHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	periods, err := s.statsIndex.AggregateStats(req.StartHeight, endHeight,
+		interval, maxChainStatsPeriods)
+	if indexers.IsTooManyPeriodsErr(err) {
+		return nil, status.Errorf(codes.InvalidArgument, "range exceeds the maximum of %d periods", maxChainStatsPeriods)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to load chain stats: %v", err)
+	}
+
+	resp := &pb.GetChainStatsResponse{
+		Periods:    make([]*pb.GetChainStatsResponse_Period, 0, len(periods)),
+		BestHeight: bestHeight,
+	}
+	for _, period := range periods {
+		resp.Periods = append(resp.Periods, &pb.GetChainStatsResponse_Period{
+			PeriodStart:  period.PeriodStart.Unix(),
+			StartHeight:  period.StartHeight,
+			EndHeight:    period.EndHeight,
+			BlockCount:   period.BlockCount,
+			TxCount:      period.TxCount,
+			Size:         period.Size,
+			TotalFees:    period.TotalFees,
+			OutputVolume: period.OutputVolume,
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,107 @@
+package bchrpc
+
+import (
+	"context"
+
+	"github.com/gcash/bchd/bchrpc/pb"
+	"github.com/gcash/bchd/blockchain/indexers"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxUtxoAgeSnapshots is the maximum number of snapshots returned by a single
+// call to GetUtxoAgeDistribution.
+const maxUtxoAgeSnapshots = 1000
+
+// GetCoinDaysDestroyed returns the value of the outputs spent by a range of
+// blocks and the coin days they destroyed, either per block or aggregated by
+// day or week.
+//
+// **Requires CoinAgeIndex**
+func (s *GrpcServer) GetCoinDaysDestroyed(ctx context.Context, req *pb.GetCoinDaysDestroyedRequest) (*pb.GetCoinDaysDestroyedResponse, error) {
+	if s.coinAgeIndex == nil {
+		return nil, status.Error(codes.Unavailable, "coinageindex required")
+	}
+
+	interval, err := statsInterval(req.Interval)
+	if err != nil {
+		return nil, err
+	}
+	bestHeight := s.chain.BestSnapshot().Height
+	endHeight, err := statsEndHeight(req.StartHeight, req.EndHeight, bestHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	periods, err := s.coinAgeIndex.AggregateCoinAge(req.StartHeight,
+		endHeight, interval, maxChainStatsPeriods)
+	if indexers.IsTooManyPeriodsErr(err) {
+		return nil, status.Errorf(codes.InvalidArgument, "range exceeds the maximum of %d periods", maxChainStatsPeriods)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to load coin days destroyed: %v", err)
+	}
+
+	resp := &pb.GetCoinDaysDestroyedResponse{
+		Periods:    make([]*pb.GetCoinDaysDestroyedResponse_Period, 0, len(periods)),
+		BestHeight: bestHeight,
+	}
+	for _, period := range periods {
+		resp.Periods = append(resp.Periods, &pb.GetCoinDaysDestroyedResponse_Period{
+			PeriodStart:       period.PeriodStart.Unix(),
+			StartHeight:       period.StartHeight,
+			EndHeight:         period.EndHeight,
+			BlockCount:        period.BlockCount,
+			ValueDestroyed:    period.ValueDestroyed,
+			CoinDaysDestroyed: period.CoinDaysDestroyed,
+		})
+	}
+	return resp, nil
+}
+
+// GetUtxoAgeDistribution returns snapshots of the value and number of the
+// unspent outputs by age, which are recorded roughly once a day, for a range of
+// blocks.
+//
+// **Requires CoinAgeIndex**
+func (s *GrpcServer) GetUtxoAgeDistribution(ctx context.Context, req *pb.GetUtxoAgeDistributionRequest) (*pb.GetUtxoAgeDistributionResponse, error) {
+	if s.coinAgeIndex == nil {
+		return nil, status.Error(codes.Unavailable, "coinageindex required")
+	}
+
+	bestHeight := s.chain.BestSnapshot().Height
+	endHeight, err := statsEndHeight(req.StartHeight, req.EndHeight, bestHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots, err := s.coinAgeIndex.UtxoAgeSnapshots(req.StartHeight,
+		endHeight, maxUtxoAgeSnapshots)
+	if indexers.IsTooManyPeriodsErr(err) {
+		return nil, status.Errorf(codes.InvalidArgument, "range exceeds the maximum of %d snapshots", maxUtxoAgeSnapshots)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to load utxo age snapshots: %v", err)
+	}
+
+	resp := &pb.GetUtxoAgeDistributionResponse{
+		Snapshots:  make([]*pb.GetUtxoAgeDistributionResponse_Snapshot, 0, len(snapshots)),
+		BestHeight: bestHeight,
+	}
+	for _, snapshot := range snapshots {
+		pbSnapshot := &pb.GetUtxoAgeDistributionResponse_Snapshot{
+			Height:    snapshot.Height,
+			Timestamp: snapshot.Timestamp.Unix(),
+			Bands:     make([]*pb.GetUtxoAgeDistributionResponse_Band, 0, len(snapshot.Bands)),
+		}
+		for _, band := range snapshot.Bands {
+			pbSnapshot.Bands = append(pbSnapshot.Bands, &pb.GetUtxoAgeDistributionResponse_Band{
+				MaxAgeDays: band.MaxAgeDays,
+				Value:      band.Value,
+				Count:      band.Count,
+			})
+		}
+		resp.Snapshots = append(resp.Snapshots, pbSnapshot)
+	}
+	return resp, nil
+}
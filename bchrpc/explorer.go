@@ -0,0 +1,231 @@
+package bchrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+
+	"github.com/gcash/bchd/bchrpc/pb"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxBlocksRangeCount is the maximum number of blocks returned by a
+	// single GetBlocksRange request.
+	maxBlocksRangeCount = 1000
+
+	// defaultAddressPageSize is the number of transactions returned per
+	// page by GetAddressTransactionsPage when no page size is requested.
+	defaultAddressPageSize = 100
+
+	// addressCursorSize is the size of the cursors returned by
+	// GetAddressTransactionsPage.  A cursor consists of the position of
+	// the next transaction in the history of the address followed by the
+	// hash of the transaction preceding it.
+	addressCursorSize = 4 + chainhash.HashSize
+)
+
+// GetBlocksRange streams a range of consecutive blocks of the main chain.
+func (s *GrpcServer) GetBlocksRange(req *pb.GetBlocksRangeRequest, stream pb.Bchrpc_GetBlocksRangeServer) error {
+	count := req.Count
+	if count == 0 {
+		count = maxBlocksRangeCount
+	}
+	if count > maxBlocksRangeCount {
+		return status.Errorf(codes.InvalidArgument, "count exceeds max of %d", maxBlocksRangeCount)
+	}
+
+	startHeight := req.GetHeight()
+	if len(req.GetHash()) > 0 {
+		h, err := chainhash.NewHash(req.GetHash())
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid hash: %v", err)
+		}
+		startHeight, err = s.chain.BlockHeightByHash(h)
+		if err != nil {
+			return status.Error(codes.NotFound, "block not found in main chain")
+		}
+	}
+	if startHeight < 0 {
+		return status.Error(codes.InvalidArgument, "height must not be negative")
+	}
+
+	var prevHash *chainhash.Hash
+	endHeight := startHeight + int32(count)
+	for height := startHeight; height < endHeight; height++ {
+		if err := stream.Context().Err(); err != nil {
+			return status.FromContextError(err).Err()
+		}
+
+		block, err := s.chain.BlockByHeight(height)
+		if err != nil {
+			if height == startHeight {
+				return status.Error(codes.NotFound, "block not found")
+			}
+			// The chain tip was reached.
+			return nil
+		}
+
+		// Make sure the blocks sent so far are still part of the main
+		// chain.
+		if prevHash != nil && !block.MsgBlock().Header.PrevBlock.IsEqual(prevHash) {
+			return status.Error(codes.Aborted, "main chain was reorganized during the request")
+		}
+		prevHash = block.Hash()
+
+		pbBlock, err := s.marshalFullBlock(block, req.FullTransactions)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&pb.GetBlocksRangeResponse{Block: pbBlock}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAddressTransactionsPage returns a page of the confirmed transactions of an
+// address, oldest first.
+//
+// **Requires AddressIndex**
+func (s *GrpcServer) GetAddressTransactionsPage(ctx context.Context, req *pb.GetAddressTransactionsPageRequest) (*pb.GetAddressTransactionsPageResponse, error) {
+	if s.addrIndex == nil {
+		return nil, status.Error(codes.Unavailable, "addrindex required")
+	}
+
+	pageSize := req.PageSize
+	if pageSize == 0 {
+		pageSize = defaultAddressPageSize
+	}
+	if pageSize > maxAddressQuerySize {
+		return nil, status.Errorf(codes.InvalidArgument, "page size exceeds max of %d", maxAddressQuerySize)
+	}
+
+	// Attempt to decode the supplied address.
+	addr, err := bchutil.DecodeAddress(req.Address, s.chainParams)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid address")
+	}
+
+	// use cash address format
+	addr, err = bchutil.ConvertSlpToCashAddress(addr, s.chainParams)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "couldn't convert address to cash address format")
+	}
+
+	// Decode the cursor.  The transaction preceding the page is fetched
+	// along with it to make sure the history of the address did not change
+	// since the cursor was created.
+	var (
+		position uint32
+		prevHash *chainhash.Hash
+	)
+	if len(req.Cursor) > 0 {
+		if len(req.Cursor) != addressCursorSize {
+			return nil, status.Error(codes.InvalidArgument, "invalid cursor")
+		}
+		position = binary.LittleEndian.Uint32(req.Cursor[:4])
+		prevHash, _ = chainhash.NewHash(req.Cursor[4:])
+		if position == 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid cursor")
+		}
+	}
+	numToSkip := position
+	numRequested := pageSize + 1
+	if prevHash != nil {
+		numToSkip--
+		numRequested++
+	}
+
+	txs, err := s.fetchAddressTransactionRange(addr, numToSkip, numRequested)
+	if err != nil {
+		return nil, err
+	}
+	if prevHash != nil {
+		if len(txs) == 0 || txs[0].tx.TxHash() != *prevHash {
+			return nil, status.Error(codes.Aborted, "cursor is no longer valid, the address history was reorganized")
+		}
+		txs = txs[1:]
+	}
+
+	resp := &pb.GetAddressTransactionsPageResponse{}
+
+	// One more transaction than the page size is fetched to tell whether
+	// there is another page.
+	if uint32(len(txs)) > pageSize {
+		txs = txs[:pageSize]
+		lastHash := txs[len(txs)-1].tx.TxHash()
+		cursor := make([]byte, addressCursorSize)
+		binary.LittleEndian.PutUint32(cursor[:4], position+pageSize)
+		copy(cursor[4:], lastHash[:])
+		resp.NextCursor = cursor
+	}
+
+	tip := s.chain.BestSnapshot().Height
+	for _, cTx := range txs {
+		tx := marshalTransaction(bchutil.NewTx(&cTx.tx), tip-cTx.blockHeight+1, cTx.blockHeader, cTx.blockHeight, s)
+		if s.txIndex != nil {
+			if err := s.setInputMetadata(tx); err != nil {
+				return nil, err
+			}
+		}
+		resp.ConfirmedTransactions = append(resp.ConfirmedTransactions, tx)
+	}
+
+	if resp.NextCursor == nil && req.IncludeMempool {
+		resp.UnconfirmedTransactions = s.marshalUnconfirmedAddressTransactions(addr)
+	}
+
+	return resp, nil
+}
+
+// fetchAddressTransactionRange loads the confirmed transactions of the passed
+// address, oldest first, skipping the passed number of transactions.
+func (s *GrpcServer) fetchAddressTransactionRange(addr bchutil.Address, numToSkip, numRequested uint32) ([]retrievedTx, error) {
+	var txs []retrievedTx
+	err := s.db.View(func(dbTx database.Tx) error {
+		regions, _, err := s.addrIndex.TxRegionsForAddress(dbTx, addr,
+			numToSkip, numRequested, false)
+		if err != nil {
+			return err
+		}
+
+		// Load the raw transaction bytes from the database.
+		serializedTxns, err := dbTx.FetchBlockRegions(regions)
+		if err != nil {
+			return err
+		}
+
+		txs = make([]retrievedTx, 0, len(serializedTxns))
+		for i, serializedTx := range serializedTxns {
+			blockHeight, err := s.chain.BlockHeightByHash(regions[i].Hash)
+			if err != nil {
+				return err
+			}
+			header, err := s.chain.HeaderByHash(regions[i].Hash)
+			if err != nil {
+				return err
+			}
+			tx := wire.MsgTx{}
+			if err := tx.BchDecode(bytes.NewReader(serializedTx), wire.ProtocolVersion, wire.BaseEncoding); err != nil {
+				return err
+			}
+			txs = append(txs, retrievedTx{
+				tx:          tx,
+				txBytes:     serializedTx,
+				blockHeight: blockHeight,
+				blockHeader: &header,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to load address index entries")
+	}
+	return txs, nil
+}
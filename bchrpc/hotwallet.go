@@ -0,0 +1,165 @@
+package bchrpc
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/gcash/bchd/bchrpc/pb"
+	"github.com/gcash/bchd/hotwallet"
+	"github.com/gcash/bchd/watchonly"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// HotWalletAuthenticationTokenKey is the key of the context metadata which
+// authenticates the callers of the hot wallet methods.  It is checked in
+// addition to any AuthenticationToken of the gRPC API.
+const HotWalletAuthenticationTokenKey = "HotWalletAuthenticationToken"
+
+// checkHotWallet returns an error unless hot wallets are enabled and the
+// context carries the authentication token of the hot wallets.
+func (s *GrpcServer) checkHotWallet(ctx context.Context) error {
+	if s.hotWallet == nil {
+		return status.Error(codes.Unavailable, "hotwallet required")
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "invalid hot wallet authentication token")
+	}
+	tokens := md.Get(HotWalletAuthenticationTokenKey)
+	if len(tokens) == 0 || !s.hotWallet.Authenticate(tokens[0]) {
+		return status.Error(codes.Unauthenticated, "invalid hot wallet authentication token")
+	}
+	return nil
+}
+
+// hotWalletError converts an error returned by the hot wallet manager to a
+// gRPC status error.
+func hotWalletError(err error) error {
+	switch err {
+	case hotwallet.ErrWalletNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case hotwallet.ErrDecrypt:
+		return status.Error(codes.PermissionDenied, err.Error())
+	case hotwallet.ErrWalletExists, watchonly.ErrAccountExists:
+		return status.Error(codes.AlreadyExists, err.Error())
+	case watchonly.ErrAccountNotFound, watchonly.ErrInsufficientFunds:
+		return watchOnlyError(err)
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// marshalHotWallet converts a hot wallet to its protobuf representation.
+func marshalHotWallet(w *hotwallet.Wallet) *pb.HotWallet {
+	return &pb.HotWallet{
+		Name:    w.Name,
+		Account: w.Account,
+		Xpub:    w.Xpub,
+	}
+}
+
+// CreateHotWallet creates a hot wallet from a new or the provided BIP0039
+// mnemonic and tracks its account as a watch-only account.
+//
+// **Requires HotWallet and the wallet authentication token**
+func (s *GrpcServer) CreateHotWallet(ctx context.Context, req *pb.CreateHotWalletRequest) (*pb.CreateHotWalletResponse, error) {
+	if err := s.checkHotWallet(ctx); err != nil {
+		return nil, err
+	}
+
+	w, mnemonic, err := s.hotWallet.CreateWallet(req.Name, req.Mnemonic, req.Passphrase, req.WalletPassphrase, req.Account, req.GapLimit)
+	switch err {
+	case nil:
+	case hotwallet.ErrWalletExists, watchonly.ErrAccountExists:
+		return nil, hotWalletError(err)
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "invalid wallet: %v", err)
+	}
+	return &pb.CreateHotWalletResponse{
+		Wallet:   marshalHotWallet(w),
+		Mnemonic: mnemonic,
+	}, nil
+}
+
+// RemoveHotWallet removes a hot wallet along with its watch-only account.
+//
+// **Requires HotWallet and the wallet authentication token**
+func (s *GrpcServer) RemoveHotWallet(ctx context.Context, req *pb.RemoveHotWalletRequest) (*pb.RemoveHotWalletResponse, error) {
+	if err := s.checkHotWallet(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := s.hotWallet.RemoveWallet(req.Name); err != nil {
+		return nil, hotWalletError(err)
+	}
+	return &pb.RemoveHotWalletResponse{}, nil
+}
+
+// ListHotWallets returns all of the hot wallets.
+//
+// **Requires HotWallet and the wallet authentication token**
+func (s *GrpcServer) ListHotWallets(ctx context.Context, req *pb.ListHotWalletsRequest) (*pb.ListHotWalletsResponse, error) {
+	if err := s.checkHotWallet(ctx); err != nil {
+		return nil, err
+	}
+
+	wallets, err := s.hotWallet.Wallets()
+	if err != nil {
+		return nil, hotWalletError(err)
+	}
+	resp := &pb.ListHotWalletsResponse{}
+	for _, w := range wallets {
+		resp.Wallets = append(resp.Wallets, marshalHotWallet(w))
+	}
+	return resp, nil
+}
+
+// SendFromHotWallet builds and signs a transaction which pays the requested
+// outputs from the unspent outputs of a hot wallet and broadcasts it unless
+// requested otherwise.
+//
+// **Requires HotWallet and the wallet authentication token**
+func (s *GrpcServer) SendFromHotWallet(ctx context.Context, req *pb.SendFromHotWalletRequest) (*pb.SendFromHotWalletResponse, error) {
+	if err := s.checkHotWallet(ctx); err != nil {
+		return nil, err
+	}
+
+	outputs, err := s.unmarshalWatchOnlyOutputs(req.Outputs)
+	if err != nil {
+		return nil, err
+	}
+
+	signed, err := s.hotWallet.CreateTransaction(req.Name, req.WalletPassphrase, outputs, req.FeePerKb, req.MinConfirmations)
+	switch err {
+	case nil:
+	case hotwallet.ErrWalletNotFound, hotwallet.ErrDecrypt, watchonly.ErrAccountNotFound, watchonly.ErrInsufficientFunds:
+		return nil, hotWalletError(err)
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "failed to create transaction: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := signed.Tx.BchEncode(&buf, wire.ProtocolVersion, wire.BaseEncoding); err != nil {
+		return nil, status.Error(codes.Internal, "error serializing transaction")
+	}
+	tx := bchutil.NewTx(signed.Tx)
+	resp := &pb.SendFromHotWalletResponse{
+		Hash:        tx.Hash().CloneBytes(),
+		Transaction: buf.Bytes(),
+		Fee:         signed.Fee,
+		ChangeIndex: int32(signed.ChangeIndex),
+	}
+	if req.SkipBroadcast {
+		return resp, nil
+	}
+
+	if err := s.submitTransaction(tx); err != nil {
+		return nil, err
+	}
+	log.Infof("Sent transaction %v from hot wallet %q", tx.Hash(), req.Name)
+
+	return resp, nil
+}
@@ -0,0 +1,155 @@
+package bchrpc
+
+import (
+	"bytes"
+	"sync/atomic"
+
+	"github.com/gcash/bchd/bchrpc/pb"
+	"github.com/gcash/bchd/mempool"
+	"github.com/gcash/bchd/wire"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxMempoolSnapshotEntries is the maximum number of entries of a part
+	// of the mempool snapshot sent by SubscribeMempool.
+	maxMempoolSnapshotEntries = 1000
+
+	// maxMempoolSnapshotTxBytes is the maximum total size of the serialized
+	// transactions of a part of the mempool snapshot sent by
+	// SubscribeMempool, which keeps the parts well below the default
+	// maximum message size of gRPC clients.
+	maxMempoolSnapshotTxBytes = 1000000
+)
+
+// rpcEventMempoolChange indicates a transaction was added to or removed from
+// the mempool.
+type rpcEventMempoolChange struct {
+	*mempool.PoolChange
+}
+
+// NotifyMempoolChange is called by the server when a transaction is added to or
+// removed from the mempool.  The changes are only dispatched once the server is
+// started and while clients are subscribed to them since they are notified with
+// the mempool lock held.
+func (s *GrpcServer) NotifyMempoolChange(change *mempool.PoolChange) {
+	if atomic.LoadUint32(&s.ready) == 0 || atomic.LoadInt32(&s.mempoolSubscribers) == 0 {
+		return
+	}
+	s.dispatchEvent(&rpcEventMempoolChange{change})
+}
+
+// SubscribeMempool sends a snapshot of the transactions in the mempool followed
+// by the transactions added to and removed from it, along with the sequence
+// number of the mempool after each update.
+func (s *GrpcServer) SubscribeMempool(req *pb.SubscribeMempoolRequest, stream pb.Bchrpc_SubscribeMempoolServer) error {
+	// Subscribe to the changes before taking the snapshot so none of the
+	// following changes are missed.  The changes the snapshot already
+	// includes are skipped by their sequence number.
+	atomic.AddInt32(&s.mempoolSubscribers, 1)
+	defer atomic.AddInt32(&s.mempoolSubscribers, -1)
+	subscription := s.subscribeEvents()
+	defer subscription.Unsubscribe()
+
+	snapshot := s.txMemPool.Snapshot()
+	if err := sendMempoolSnapshot(snapshot, req.SerializeTx, stream); err != nil {
+		return err
+	}
+
+	sequence := snapshot.Sequence
+	for {
+		select {
+		case event := <-subscription.Events():
+			change, ok := event.(*rpcEventMempoolChange)
+			if !ok || change.Sequence <= sequence {
+				continue
+			}
+			sequence = change.Sequence
+
+			entry, err := marshalMempoolEntry(&change.Entry, req.SerializeTx)
+			if err != nil {
+				return err
+			}
+			toSend := &pb.MempoolUpdate{
+				Type:     pb.MempoolUpdate_ADDED,
+				Sequence: change.Sequence,
+				Entries:  []*pb.MempoolEntry{entry},
+			}
+			if change.Removed {
+				toSend.Type = pb.MempoolUpdate_REMOVED
+			}
+			if err := stream.Send(toSend); err != nil {
+				return err
+			}
+
+		case <-stream.Context().Done():
+			return nil // client disconnected
+		}
+	}
+}
+
+// sendMempoolSnapshot sends the passed mempool snapshot in parts limited in
+// the number of entries and the size of the serialized transactions.  The last
+// part is sent even when the mempool is empty.
+func sendMempoolSnapshot(snapshot *mempool.PoolSnapshot, serializeTx bool, stream pb.Bchrpc_SubscribeMempoolServer) error {
+	toSend := &pb.MempoolUpdate{
+		Type:     pb.MempoolUpdate_SNAPSHOT,
+		Sequence: snapshot.Sequence,
+	}
+	txBytes := 0
+	for i := range snapshot.Entries {
+		entry, err := marshalMempoolEntry(&snapshot.Entries[i], serializeTx)
+		if err != nil {
+			return err
+		}
+
+		if len(toSend.Entries) == maxMempoolSnapshotEntries ||
+			(len(toSend.Entries) > 0 && txBytes+len(entry.SerializedTransaction) > maxMempoolSnapshotTxBytes) {
+
+			if err := stream.Send(toSend); err != nil {
+				return err
+			}
+			toSend = &pb.MempoolUpdate{
+				Type:     pb.MempoolUpdate_SNAPSHOT,
+				Sequence: snapshot.Sequence,
+			}
+			txBytes = 0
+		}
+		toSend.Entries = append(toSend.Entries, entry)
+		txBytes += len(entry.SerializedTransaction)
+	}
+
+	toSend.SnapshotDone = true
+	return stream.Send(toSend)
+}
+
+// marshalMempoolEntry converts the passed mempool entry to its protobuf
+// representation, including the serialized transaction when requested.
+func marshalMempoolEntry(entry *mempool.PoolEntry, serializeTx bool) (*pb.MempoolEntry, error) {
+	msgTx := entry.Tx.MsgTx()
+	pbEntry := &pb.MempoolEntry{
+		TransactionHash: entry.Tx.Hash().CloneBytes(),
+		Size:            int32(msgTx.SerializeSize()),
+		Fee:             entry.Fee,
+		FeePerKb:        entry.FeePerKB,
+		AddedTime:       entry.Added.Unix(),
+		AddedHeight:     entry.Height,
+		AncestorCount:   int32(entry.AncestorCount),
+		AncestorSize:    entry.AncestorSize,
+		AncestorFees:    entry.AncestorFees,
+	}
+	for i := range entry.Depends {
+		pbEntry.Depends = append(pbEntry.Depends, entry.Depends[i].CloneBytes())
+	}
+
+	if serializeTx {
+		var buf bytes.Buffer
+		if err := msgTx.BchEncode(&buf, wire.ProtocolVersion, wire.BaseEncoding); err != nil {
+			return nil, status.Error(codes.Internal, "error serializing transaction")
+		}
+		pbEntry.SerializedTransaction = buf.Bytes()
+	}
+
+	return pbEntry, nil
+}
@@ -0,0 +1,70 @@
+package bchrpc
+
+import (
+	"context"
+	"crypto/sha256"
+
+	"github.com/gcash/bchd/bchrpc/pb"
+	"github.com/gcash/bchd/txscript"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// oracleVerifyFlags are the flags oracle signatures are verified with.  Null
+// fail is excluded so that a signature which is invalid for one of several
+// oracle keys isn't reported as an error.
+const oracleVerifyFlags = txscript.StandardVerifyFlags &^ txscript.ScriptVerifyNullFail
+
+// EncodeOracleMessage serializes an oracle message for OP_CHECKDATASIG
+// contracts.
+func (s *GrpcServer) EncodeOracleMessage(ctx context.Context, req *pb.EncodeOracleMessageRequest) (*pb.EncodeOracleMessageResponse, error) {
+	if req.Message == nil {
+		return nil, status.Error(codes.InvalidArgument, "message is required")
+	}
+	m := &txscript.OracleMessage{
+		Timestamp:       req.Message.Timestamp,
+		MessageSequence: req.Message.MessageSequence,
+		DataSequence:    req.Message.DataSequence,
+		Data:            req.Message.Data,
+	}
+	msg, err := m.Serialize()
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid message: %v", err)
+	}
+	hash := sha256.Sum256(msg)
+	return &pb.EncodeOracleMessageResponse{
+		Message: msg,
+		Hash:    hash[:],
+	}, nil
+}
+
+// VerifyOracleMessage verifies the signature of an oracle message against the
+// public keys of the oracles and decodes the message.
+func (s *GrpcServer) VerifyOracleMessage(ctx context.Context, req *pb.VerifyOracleMessageRequest) (*pb.VerifyOracleMessageResponse, error) {
+	if len(req.Pubkeys) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "at least one public key is required")
+	}
+
+	resp := &pb.VerifyOracleMessageResponse{PubkeyIndex: -1}
+	for i, pubKey := range req.Pubkeys {
+		valid, err := txscript.VerifyOracleSignature(req.Signature, req.Message, pubKey, oracleVerifyFlags)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid signature or public key %d: %v", i, err)
+		}
+		if valid {
+			resp.Valid = true
+			resp.PubkeyIndex = int32(i)
+			break
+		}
+	}
+
+	if m, err := txscript.ParseOracleMessage(req.Message); err == nil {
+		resp.Message = &pb.OracleMessage{
+			Timestamp:       m.Timestamp,
+			MessageSequence: m.MessageSequence,
+			DataSequence:    m.DataSequence,
+			Data:            m.Data,
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,132 @@
+package bchrpc
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gcash/bchd/bchec"
+	"github.com/gcash/bchd/bchrpc/pb"
+	"github.com/gcash/bchd/paymentrequest"
+	prpb "github.com/gcash/bchd/paymentrequest/pb"
+	"github.com/gcash/bchd/wire"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ValidatePayment validates a payment against the payment request it pays.
+//
+// The payment request is provided by the merchant itself, so a malformed
+// request is rejected as an invalid argument.  The payment is provided by the
+// customer, so any problem with it, as well as a request whose signature does
+// not verify, is reported as an invalid payment.
+func (s *GrpcServer) ValidatePayment(ctx context.Context, req *pb.ValidatePaymentRequest) (*pb.ValidatePaymentResponse, error) {
+	now := time.Now()
+	resp := &pb.ValidatePaymentResponse{}
+
+	var request *paymentrequest.Request
+	switch {
+	case req.GetBip70PaymentRequest() != nil:
+		var bip70Req prpb.PaymentRequest
+		if err := proto.Unmarshal(req.GetBip70PaymentRequest(), &bip70Req); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid bip70 payment request: %v", err)
+		}
+		// Verify the certificate chain against the system roots.
+		r, cert, err := paymentrequest.VerifyBIP70(&bip70Req, nil, now)
+		if err != nil {
+			resp.Reason = err.Error()
+			return resp, nil
+		}
+		if cert != nil {
+			resp.Merchant = cert.Subject.CommonName
+		}
+		request = r
+	case req.GetJsonPaymentRequest() != nil:
+		jsonReq, err := paymentrequest.ParseJSONRequest(req.GetJsonPaymentRequest())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		request, err = jsonReq.Request(s.chainParams)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid json payment request: %v", err)
+		}
+		if len(req.MerchantPubkey) > 0 {
+			pubKey, err := bchec.ParsePubKey(req.MerchantPubkey, bchec.S256())
+			if err != nil {
+				return nil, status.Error(codes.InvalidArgument, "invalid merchant public key")
+			}
+			err = paymentrequest.VerifyJSON(req.GetJsonPaymentRequest(), req.JsonSignature, pubKey)
+			if err != nil {
+				resp.Reason = err.Error()
+				return resp, nil
+			}
+			resp.Merchant = hex.EncodeToString(pubKey.SerializeCompressed())
+		}
+	default:
+		return nil, status.Error(codes.InvalidArgument, "payment request is required")
+	}
+	resp.RequestedAmount = int64(request.TotalAmount())
+
+	if req.RequireSignature && resp.Merchant == "" {
+		resp.Reason = "payment request is not signed"
+		return resp, nil
+	}
+	if network := paymentrequest.NetworkName(s.chainParams); request.Network != network {
+		resp.Reason = fmt.Sprintf("payment request is for network %q, not %q",
+			request.Network, network)
+		return resp, nil
+	}
+
+	var (
+		txs []*wire.MsgTx
+		ack []byte
+	)
+	switch {
+	case req.GetBip70Payment() != nil:
+		payment, err := paymentrequest.ParseBIP70Payment(req.GetBip70Payment())
+		if err != nil {
+			resp.Reason = err.Error()
+			return resp, nil
+		}
+		txs, err = request.ValidateBIP70Payment(payment, now)
+		if err != nil {
+			resp.Reason = err.Error()
+			return resp, nil
+		}
+		ack, err = proto.Marshal(paymentrequest.NewBIP70PaymentACK(payment, req.AckMemo))
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to serialize payment ack")
+		}
+	case req.GetJsonPayment() != nil:
+		payment, err := paymentrequest.ParseJSONPayment(req.GetJsonPayment())
+		if err != nil {
+			resp.Reason = err.Error()
+			return resp, nil
+		}
+		txs, err = request.ValidateJSONPayment(payment, now)
+		if err != nil {
+			resp.Reason = err.Error()
+			return resp, nil
+		}
+		ack, err = json.Marshal(&paymentrequest.JSONPaymentACK{
+			Payment: *payment,
+			Memo:    req.AckMemo,
+		})
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to serialize payment ack")
+		}
+	default:
+		return nil, status.Error(codes.InvalidArgument, "payment is required")
+	}
+
+	for _, tx := range txs {
+		txHash := tx.TxHash()
+		resp.TransactionHashes = append(resp.TransactionHashes, txHash.CloneBytes())
+	}
+	resp.Valid = true
+	resp.PaymentAck = ack
+	return resp, nil
+}
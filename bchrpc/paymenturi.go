@@ -0,0 +1,101 @@
+package bchrpc
+
+import (
+	"context"
+	"sort"
+
+	"github.com/gcash/bchd/bchrpc/pb"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/paymenturi"
+	"github.com/gcash/bchutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ParsePaymentURI parses a BIP0021 bitcoincash: payment URI.
+func (s *GrpcServer) ParsePaymentURI(ctx context.Context, req *pb.ParsePaymentURIRequest) (*pb.ParsePaymentURIResponse, error) {
+	u, err := paymenturi.Parse(req.Uri, s.chainParams)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid payment uri: %v", err)
+	}
+
+	payment := &pb.PaymentURI{
+		TokenAware: u.TokenAware,
+		Amount:     int64(u.Amount),
+		Label:      u.Label,
+		Message:    u.Message,
+		OpReturn:   u.OpReturn,
+	}
+	if u.Address != nil {
+		payment.Address = u.Address.String()
+	}
+	if u.Token != nil {
+		payment.Token = &pb.PaymentURI_TokenRequest{
+			CategoryId: u.Token.Category.CloneBytes(),
+			Amount:     u.Token.Amount,
+			Nft:        u.Token.NFT,
+			Capability: pb.PaymentURI_TokenRequest_Capability(u.Token.Capability),
+			Commitment: u.Token.Commitment,
+		}
+	}
+	keys := make([]string, 0, len(u.Params))
+	for key := range u.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		payment.Params = append(payment.Params, &pb.PaymentURI_Parameter{
+			Key:   key,
+			Value: u.Params[key],
+		})
+	}
+	return &pb.ParsePaymentURIResponse{Payment: payment}, nil
+}
+
+// BuildPaymentURI builds a BIP0021 bitcoincash: payment URI.
+func (s *GrpcServer) BuildPaymentURI(ctx context.Context, req *pb.BuildPaymentURIRequest) (*pb.BuildPaymentURIResponse, error) {
+	payment := req.Payment
+	if payment == nil {
+		return nil, status.Error(codes.InvalidArgument, "payment is required")
+	}
+
+	u := &paymenturi.URI{
+		TokenAware: payment.TokenAware,
+		Amount:     bchutil.Amount(payment.Amount),
+		Label:      payment.Label,
+		Message:    payment.Message,
+		OpReturn:   payment.OpReturn,
+	}
+	if payment.Address != "" {
+		addr, err := bchutil.DecodeAddress(payment.Address, s.chainParams)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid address: %s", payment.Address)
+		}
+		u.Address = addr
+	}
+	if payment.Token != nil {
+		category, err := chainhash.NewHash(payment.Token.CategoryId)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid token category id")
+		}
+		u.Token = &paymenturi.TokenRequest{
+			Category:   *category,
+			Amount:     payment.Token.Amount,
+			NFT:        payment.Token.Nft,
+			Capability: byte(payment.Token.Capability),
+			Commitment: payment.Token.Commitment,
+		}
+	}
+	for _, param := range payment.Params {
+		if u.Params == nil {
+			u.Params = make(map[string]string)
+		}
+		u.Params[param.Key] = param.Value
+	}
+
+	uri, err := u.Encode(s.chainParams)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid payment: %v", err)
+	}
+	return &pb.BuildPaymentURIResponse{Uri: uri}, nil
+}
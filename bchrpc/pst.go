@@ -0,0 +1,222 @@
+package bchrpc
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/gcash/bchd/bchrpc/pb"
+	"github.com/gcash/bchd/pst"
+	"github.com/gcash/bchutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// decodePst parses the serialized partially signed transaction of a request.
+func decodePst(b []byte) (*pst.Packet, error) {
+	p, err := pst.NewFromRawBytes(bytes.NewReader(b), false)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid partially signed transaction: %v", err)
+	}
+	return p, nil
+}
+
+// marshalPstUnknowns converts unknown key-value pairs of a partially signed
+// transaction to their protobuf representation.
+func marshalPstUnknowns(unknowns []*pst.Unknown) []*pb.PstUnknown {
+	var ret []*pb.PstUnknown
+	for _, u := range unknowns {
+		ret = append(ret, &pb.PstUnknown{Key: u.Key, Value: u.Value})
+	}
+	return ret
+}
+
+// marshalPstBip32Derivations converts key derivation paths of a partially
+// signed transaction to their protobuf representation.
+func marshalPstBip32Derivations(derivations []*pst.Bip32Derivation) []*pb.PstBip32Derivation {
+	var ret []*pb.PstBip32Derivation
+	for _, d := range derivations {
+		ret = append(ret, &pb.PstBip32Derivation{
+			Pubkey:               d.PubKey,
+			MasterKeyFingerprint: d.MasterKeyFingerprint,
+			Path:                 d.Path,
+		})
+	}
+	return ret
+}
+
+// DecodePst decodes a serialized partially signed transaction.
+func (s *GrpcServer) DecodePst(ctx context.Context, req *pb.DecodePstRequest) (*pb.DecodePstResponse, error) {
+	p, err := decodePst(req.Pst)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := p.UnsignedTx.Serialize(&buf); err != nil {
+		return nil, status.Error(codes.Internal, "error serializing transaction")
+	}
+	resp := &pb.DecodePstResponse{
+		UnsignedTransaction: buf.Bytes(),
+		Unknowns:            marshalPstUnknowns(p.Unknowns),
+	}
+	for i := range p.Inputs {
+		pi := &p.Inputs[i]
+		in := &pb.PstInput{
+			SighashType:          uint32(pi.SighashType),
+			RedeemScript:         pi.RedeemScript,
+			Bip32Derivations:     marshalPstBip32Derivations(pi.Bip32Derivation),
+			FinalSignatureScript: pi.FinalScriptSig,
+			Unknowns:             marshalPstUnknowns(pi.Unknowns),
+		}
+		if pi.Utxo != nil {
+			in.HasUtxo = true
+			in.Value = pi.Utxo.Value
+			in.PubkeyScript = pi.Utxo.PkScript
+			if !pi.Utxo.TokenData.IsEmpty() {
+				in.CashToken = getPbCashTokenDataFromTokenData(pi.Utxo.TokenData)
+			}
+		}
+		for _, sig := range pi.PartialSigs {
+			in.PartialSignatures = append(in.PartialSignatures, &pb.PstInput_PartialSignature{
+				Pubkey:    sig.PubKey,
+				Signature: sig.Signature,
+			})
+		}
+		resp.Inputs = append(resp.Inputs, in)
+	}
+	for i := range p.Outputs {
+		po := &p.Outputs[i]
+		resp.Outputs = append(resp.Outputs, &pb.PstOutput{
+			RedeemScript:     po.RedeemScript,
+			Bip32Derivations: marshalPstBip32Derivations(po.Bip32Derivation),
+			Unknowns:         marshalPstUnknowns(po.Unknowns),
+		})
+	}
+	if fee, err := p.Fee(); err == nil {
+		resp.Fee = fee
+		resp.HasFee = true
+	}
+	return resp, nil
+}
+
+// AnalyzePst reports which role has to act next on each input of a partially
+// signed transaction.
+func (s *GrpcServer) AnalyzePst(ctx context.Context, req *pb.AnalyzePstRequest) (*pb.AnalyzePstResponse, error) {
+	p, err := decodePst(req.Pst)
+	if err != nil {
+		return nil, err
+	}
+
+	a := pst.Analyze(p)
+	resp := &pb.AnalyzePstResponse{
+		Next:   pb.AnalyzePstResponse_Role(a.Next),
+		Fee:    a.Fee,
+		HasFee: a.HasFee,
+	}
+	for _, ia := range a.Inputs {
+		in := &pb.AnalyzePstResponse_Input{
+			Next:              pb.AnalyzePstResponse_Role(ia.Next),
+			HasUtxo:           ia.HasUtxo,
+			IsFinalized:       ia.IsFinalized,
+			MissingSignatures: int32(ia.MissingSigs),
+		}
+		if ia.Err != nil {
+			in.Error = ia.Err.Error()
+		}
+		resp.Inputs = append(resp.Inputs, in)
+	}
+	return resp, nil
+}
+
+// FinalizePst finalizes the inputs of a partially signed transaction which have
+// enough signatures and optionally extracts the signed transaction.
+func (s *GrpcServer) FinalizePst(ctx context.Context, req *pb.FinalizePstRequest) (*pb.FinalizePstResponse, error) {
+	p, err := decodePst(req.Pst)
+	if err != nil {
+		return nil, err
+	}
+
+	// Inputs which can not be finalized yet are left for later, so only
+	// the inputs which are ready are finalized.
+	for i, ia := range pst.Analyze(p).Inputs {
+		if ia.Next == pst.RoleFinalizer {
+			if err := pst.Finalize(p, i); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "failed to finalize input %d: %v", i, err)
+			}
+		}
+	}
+
+	b, err := p.Bytes()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "error serializing partially signed transaction")
+	}
+	resp := &pb.FinalizePstResponse{
+		Pst:      b,
+		Complete: p.IsComplete(),
+	}
+	if req.Extract && resp.Complete {
+		tx, err := pst.Extract(p)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "failed to extract transaction: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := tx.Serialize(&buf); err != nil {
+			return nil, status.Error(codes.Internal, "error serializing transaction")
+		}
+		resp.Transaction = buf.Bytes()
+	}
+	return resp, nil
+}
+
+// CombinePsts merges partially signed transactions of the same transaction into
+// one which carries the information of all of them.
+func (s *GrpcServer) CombinePsts(ctx context.Context, req *pb.CombinePstsRequest) (*pb.CombinePstsResponse, error) {
+	packets := make([]*pst.Packet, 0, len(req.Psts))
+	for _, b := range req.Psts {
+		p, err := decodePst(b)
+		if err != nil {
+			return nil, err
+		}
+		packets = append(packets, p)
+	}
+
+	combined, err := pst.Combine(packets...)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to combine partially signed transactions: %v", err)
+	}
+	b, err := combined.Bytes()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "error serializing partially signed transaction")
+	}
+	return &pb.CombinePstsResponse{Pst: b}, nil
+}
+
+// SubmitPst finalizes a partially signed transaction, extracts the signed
+// transaction and submits it to the mempool.
+func (s *GrpcServer) SubmitPst(ctx context.Context, req *pb.SubmitPstRequest) (*pb.SubmitPstResponse, error) {
+	p, err := decodePst(req.Pst)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pst.MaybeFinalizeAll(p); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to finalize: %v", err)
+	}
+	msgTx, err := pst.Extract(p)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to extract transaction: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := msgTx.Serialize(&buf); err != nil {
+		return nil, status.Error(codes.Internal, "error serializing transaction")
+	}
+
+	tx := bchutil.NewTx(msgTx)
+	if err := s.submitTransaction(tx); err != nil {
+		return nil, err
+	}
+	return &pb.SubmitPstResponse{
+		Hash:        tx.Hash().CloneBytes(),
+		Transaction: buf.Bytes(),
+	}, nil
+}
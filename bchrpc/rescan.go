@@ -0,0 +1,138 @@
+package bchrpc
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/gcash/bchd/bchrpc/pb"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/rescan"
+	"github.com/gcash/bchd/wire"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Rescan streams the blocks of the main chain with transactions which pay to
+// any of the requested output scripts or spend any of the requested outpoints,
+// followed by the progress of the rescan after each batch of blocks.
+//
+// **Requires CfIndex**
+func (s *GrpcServer) Rescan(req *pb.RescanRequest, stream pb.Bchrpc_RescanServer) error {
+	if s.rescanner == nil {
+		return status.Error(codes.Unavailable, "cfindex required")
+	}
+
+	outPoints := make([]wire.OutPoint, 0, len(req.Outpoints))
+	for _, op := range req.Outpoints {
+		hash, err := chainhash.NewHash(op.Hash)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid outpoint hash: %v", err)
+		}
+		outPoints = append(outPoints, wire.OutPoint{Hash: *hash, Index: op.Index})
+	}
+
+	startHeight := req.GetStartHeight()
+	if len(req.GetStartHash()) > 0 {
+		h, err := chainhash.NewHash(req.GetStartHash())
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid hash: %v", err)
+		}
+		startHeight, err = s.chain.BlockHeightByHash(h)
+		if err != nil {
+			return status.Error(codes.NotFound, "block not found in main chain")
+		}
+	}
+	if startHeight < 0 {
+		return status.Error(codes.InvalidArgument, "height must not be negative")
+	}
+
+	var done bool
+	progress, err := s.rescanner.Rescan(stream.Context(), &rescan.Request{
+		Scripts:     req.OutputScripts,
+		OutPoints:   outPoints,
+		StartHeight: startHeight,
+		StopHeight:  req.StopHeight,
+		OnBlock: func(m *rescan.Match) error {
+			block, err := s.marshalRescanBlock(m, req.FullTransactions)
+			if err != nil {
+				return err
+			}
+			return stream.Send(&pb.RescanResponse{
+				Notification: &pb.RescanResponse_Block{Block: block},
+			})
+		},
+		OnProgress: func(p *rescan.Progress) error {
+			done = p.Height >= p.StopHeight
+			return stream.Send(&pb.RescanResponse{
+				Notification: &pb.RescanResponse_Progress{
+					Progress: marshalRescanProgress(p),
+				},
+			})
+		},
+	})
+	switch err {
+	case nil:
+		// Nothing was scanned when the start height is past the stop
+		// height, so report that the rescan is done.
+		if done {
+			return nil
+		}
+		return stream.Send(&pb.RescanResponse{
+			Notification: &pb.RescanResponse_Progress{
+				Progress: marshalRescanProgress(progress),
+			},
+		})
+	case rescan.ErrNothingToRescan:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case rescan.ErrTooManyRescans:
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case rescan.ErrReorganized:
+		return status.Error(codes.Aborted, err.Error())
+	case context.Canceled, context.DeadlineExceeded:
+		return status.FromContextError(err).Err()
+	}
+	return err
+}
+
+// marshalRescanBlock marshals a block with relevant transactions found by a
+// rescan along with either the full transactions or their serialization.
+func (s *GrpcServer) marshalRescanBlock(m *rescan.Match, fullTransactions bool) (*pb.RescanBlock, error) {
+	block := m.Block
+	confirmations := s.chain.BestSnapshot().Height - block.Height() + 1
+	medianTime, err := s.chain.MedianTimeByHash(block.Hash())
+	if err != nil {
+		return nil, status.Error(codes.Internal, "error calculating median time for block")
+	}
+	resp := &pb.RescanBlock{
+		BlockInfo: marshalBlockInfo(block, confirmations, medianTime, s.chainParams),
+	}
+	header := block.MsgBlock().Header
+	for _, tx := range m.Transactions {
+		if fullTransactions {
+			resp.Transactions = append(resp.Transactions,
+				marshalTransaction(tx, confirmations, &header, block.Height(), s))
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := tx.MsgTx().BchEncode(&buf, wire.ProtocolVersion, wire.BaseEncoding); err != nil {
+			return nil, status.Error(codes.Internal, "error serializing transaction")
+		}
+		resp.SerializedTransactions = append(resp.SerializedTransactions, buf.Bytes())
+	}
+	return resp, nil
+}
+
+// marshalRescanProgress marshals the progress of a rescan.
+func marshalRescanProgress(p *rescan.Progress) *pb.RescanProgress {
+	return &pb.RescanProgress{
+		Hash:                p.Hash.CloneBytes(),
+		Height:              p.Height,
+		StopHeight:          p.StopHeight,
+		ScannedBlocks:       p.ScannedBlocks,
+		FetchedBlocks:       p.FetchedBlocks,
+		MatchedBlocks:       p.MatchedBlocks,
+		MatchedTransactions: p.MatchedTransactions,
+		Done:                p.Height >= p.StopHeight,
+	}
+}
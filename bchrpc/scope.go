@@ -0,0 +1,80 @@
+package bchrpc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Scope is a level of access to the gRPC methods.  Each scope includes the
+// methods of the scopes below it.
+type Scope int
+
+const (
+	// ScopeReadOnly allows the methods which query the state of the node
+	// without changing it.
+	ScopeReadOnly Scope = iota
+
+	// ScopeSubmit additionally allows submitting transactions.
+	ScopeSubmit
+
+	// ScopeAdmin allows all methods, including the ones which change the
+	// state of the node, such as InvalidateBlock.
+	ScopeAdmin
+)
+
+// scopeStrings is a map of scopes back to their names as used in the
+// configuration.
+var scopeStrings = map[Scope]string{
+	ScopeReadOnly: "readonly",
+	ScopeSubmit:   "submit",
+	ScopeAdmin:    "admin",
+}
+
+// String returns the Scope as a human-readable name.
+func (s Scope) String() string {
+	if str, ok := scopeStrings[s]; ok {
+		return str
+	}
+	return fmt.Sprintf("Unknown Scope (%d)", int(s))
+}
+
+// ParseScope returns the scope with the passed name.
+func ParseScope(name string) (Scope, error) {
+	for scope, str := range scopeStrings {
+		if strings.EqualFold(name, str) {
+			return scope, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown scope %q", name)
+}
+
+// methodScopes maps the full names of the methods which require more than
+// read-only access to the scope they require.
+var methodScopes = map[string]Scope{
+	"/pb.bchrpc/SubmitTransaction": ScopeSubmit,
+	"/pb.bchrpc/SubmitPst":         ScopeSubmit,
+	"/pb.bchrpc/SendFromHotWallet": ScopeSubmit,
+
+	"/pb.bchrpc/SubmitTrustedTransaction": ScopeAdmin,
+	"/pb.bchrpc/InvalidateBlock":          ScopeAdmin,
+	"/pb.bchrpc/ReconsiderBlock":          ScopeAdmin,
+	"/pb.bchrpc/BackupChainstate":         ScopeAdmin,
+	"/pb.bchrpc/ImportWatchOnlyAccount":   ScopeAdmin,
+	"/pb.bchrpc/RemoveWatchOnlyAccount":   ScopeAdmin,
+	"/pb.bchrpc/ImportMultisigAccount":    ScopeAdmin,
+	"/pb.bchrpc/AddWatchSetScripts":       ScopeAdmin,
+	"/pb.bchrpc/RemoveWatchSetScripts":    ScopeAdmin,
+	"/pb.bchrpc/DeleteWatchSet":           ScopeAdmin,
+	"/pb.bchrpc/CreateHotWallet":          ScopeAdmin,
+	"/pb.bchrpc/RemoveHotWallet":          ScopeAdmin,
+	"/pb.bchrpc/ListHotWallets":           ScopeAdmin,
+}
+
+// MethodScope returns the scope required to invoke the method with the passed
+// full name of the form /package.service/method.
+func MethodScope(fullMethod string) Scope {
+	if scope, ok := methodScopes[fullMethod]; ok {
+		return scope
+	}
+	return ScopeReadOnly
+}
@@ -1,6 +1,7 @@
 package bchrpc
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/hex"
@@ -15,14 +16,18 @@ import (
 	"time"
 
 	"github.com/gcash/bchd/bchrpc/pb"
+	"github.com/gcash/bchd/bcmr"
 	"github.com/gcash/bchd/blockchain"
 	"github.com/gcash/bchd/blockchain/indexers"
 	"github.com/gcash/bchd/chaincfg"
 	"github.com/gcash/bchd/chaincfg/chainhash"
 	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/hotwallet"
 	"github.com/gcash/bchd/mempool"
 	"github.com/gcash/bchd/mining"
+	"github.com/gcash/bchd/rescan"
 	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/watchonly"
 	"github.com/gcash/bchd/wire"
 	"github.com/gcash/bchutil"
 	"github.com/gcash/bchutil/merkleblock"
@@ -30,6 +35,8 @@ import (
 	"github.com/simpleledgerinc/goslp/v1parser"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 )
@@ -38,10 +45,16 @@ import (
 // to return per query.
 const maxAddressQuerySize = 10000
 
+// maxBatchMerkleProofTxns is the max number of transactions
+// to prove per call to GetBatchMerkleProof.
+const maxBatchMerkleProofTxns = 1000
+
 var serviceMap = map[string]interface{}{
 	"pb.bchrpc": &GrpcServer{},
 
+	"grpc.reflection.v1.ServerReflection":      &reflectionServer{},
 	"grpc.reflection.v1alpha.ServerReflection": &reflectionServer{},
+	"grpc.health.v1.Health":                    &healthServer{},
 }
 
 type reflectionServer struct{}
@@ -50,6 +63,19 @@ func (s *reflectionServer) checkReady() bool {
 	return true
 }
 
+// healthServer is always ready since it reports whether the other services
+// are ready itself.
+type healthServer struct{}
+
+func (s *healthServer) checkReady() bool {
+	return true
+}
+
+// HealthServiceName is the name of the grpc health v1 service.  Its methods
+// may be invoked without authentication so load balancers and orchestrators
+// can probe the server.
+const HealthServiceName = "grpc.health.v1.Health"
+
 // ServiceReady returns nil when the service is ready and a gRPC error when not.
 func ServiceReady(service string) error {
 	s, ok := serviceMap[service]
@@ -98,10 +124,26 @@ type GrpcServerConfig struct {
 	TxMemPool   *mempool.TxPool
 	NetMgr      NetManager
 
-	TxIndex   *indexers.TxIndex
-	AddrIndex *indexers.AddrIndex
-	CfIndex   *indexers.CfIndex
-	SlpIndex  *indexers.SlpIndex
+	TxIndex    *indexers.TxIndex
+	AddrIndex  *indexers.AddrIndex
+	CfIndex    *indexers.CfIndex
+	SlpIndex   *indexers.SlpIndex
+	TokenIndex *indexers.TokenIndex
+	StatsIndex *indexers.StatsIndex
+
+	CoinAgeIndex  *indexers.CoinAgeIndex
+	WatchSetIndex *indexers.WatchSetIndex
+
+	WatchOnly *watchonly.Manager
+	Bcmr      *bcmr.Resolver
+	Rescanner *rescan.Rescanner
+	HotWallet *hotwallet.Manager
+
+	// AllowAdminMethods indicates whether methods which change the state
+	// of the node, such as InvalidateBlock, may be invoked.  It should
+	// only be set when clients are required to authenticate, either with
+	// a token or with a client certificate.
+	AllowAdminMethods bool
 }
 
 // GrpcServer is the gRPC server implementation. It holds all the objects
@@ -114,43 +156,73 @@ type GrpcServer struct {
 	txMemPool   *mempool.TxPool
 	netMgr      NetManager
 
-	txIndex   *indexers.TxIndex
-	addrIndex *indexers.AddrIndex
-	cfIndex   *indexers.CfIndex
-	slpIndex  *indexers.SlpIndex
+	txIndex    *indexers.TxIndex
+	addrIndex  *indexers.AddrIndex
+	cfIndex    *indexers.CfIndex
+	slpIndex   *indexers.SlpIndex
+	tokenIndex *indexers.TokenIndex
+	statsIndex *indexers.StatsIndex
+
+	coinAgeIndex  *indexers.CoinAgeIndex
+	watchSetIndex *indexers.WatchSetIndex
+
+	watchOnly *watchonly.Manager
+	bcmr      *bcmr.Resolver
+	rescanner *rescan.Rescanner
+	hotWallet *hotwallet.Manager
 
+	allowAdminMethods bool
+
+	health     *health.Server
 	httpServer *http.Server
 	subscribe  chan *rpcEventSubscription
 	events     chan interface{}
 	quit       chan struct{}
 
-	wg       sync.WaitGroup
-	ready    uint32 // atomic
-	shutdown int32  // atomic
+	wg                 sync.WaitGroup
+	ready              uint32 // atomic
+	shutdown           int32  // atomic
+	mempoolSubscribers int32  // atomic
 }
 
 // NewGrpcServer returns a new GrpcServer which has not yet
 // be started.
 func NewGrpcServer(cfg *GrpcServerConfig) *GrpcServer {
 	s := &GrpcServer{
-		timeSource:  cfg.TimeSource,
-		chain:       cfg.Chain,
-		chainParams: cfg.ChainParams,
-		db:          cfg.DB,
-		txMemPool:   cfg.TxMemPool,
-		netMgr:      cfg.NetMgr,
-		txIndex:     cfg.TxIndex,
-		addrIndex:   cfg.AddrIndex,
-		cfIndex:     cfg.CfIndex,
-		slpIndex:    cfg.SlpIndex,
-		httpServer:  cfg.HTTPServer,
-		subscribe:   make(chan *rpcEventSubscription),
-		events:      make(chan interface{}),
-		quit:        make(chan struct{}),
-		wg:          sync.WaitGroup{},
+		timeSource:    cfg.TimeSource,
+		chain:         cfg.Chain,
+		chainParams:   cfg.ChainParams,
+		db:            cfg.DB,
+		txMemPool:     cfg.TxMemPool,
+		netMgr:        cfg.NetMgr,
+		txIndex:       cfg.TxIndex,
+		addrIndex:     cfg.AddrIndex,
+		cfIndex:       cfg.CfIndex,
+		slpIndex:      cfg.SlpIndex,
+		tokenIndex:    cfg.TokenIndex,
+		statsIndex:    cfg.StatsIndex,
+		coinAgeIndex:  cfg.CoinAgeIndex,
+		watchSetIndex: cfg.WatchSetIndex,
+		watchOnly:     cfg.WatchOnly,
+		bcmr:          cfg.Bcmr,
+		rescanner:     cfg.Rescanner,
+		hotWallet:     cfg.HotWallet,
+		httpServer:    cfg.HTTPServer,
+		health:        health.NewServer(),
+
+		allowAdminMethods: cfg.AllowAdminMethods,
+		subscribe:         make(chan *rpcEventSubscription),
+		events:            make(chan interface{}),
+		quit:              make(chan struct{}),
+		wg:                sync.WaitGroup{},
 	}
 	reflection.Register(cfg.Server)
 	pb.RegisterBchrpcServer(cfg.Server, s)
+
+	// The bchrpc service is reported as serving once the server is started.
+	s.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	s.health.SetServingStatus("pb.bchrpc", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(cfg.Server, s.health)
 	serviceMap["pb.bchrpc"] = s
 
 	// listen to changes in the mempool for adding/removing from slp entry cache
@@ -301,6 +373,7 @@ func (s *GrpcServer) Start() {
 	s.wg.Add(1)
 	s.chain.Subscribe(s.handleBlockchainNotification)
 	go s.runEventDispatcher()
+	s.health.SetServingStatus("pb.bchrpc", healthpb.HealthCheckResponse_SERVING)
 }
 
 // Stop is used by server.go to stop the gRPC listener.
@@ -310,6 +383,7 @@ func (s *GrpcServer) Stop() error {
 		return nil
 	}
 	log.Warnf("gRPC server shutting down")
+	s.health.Shutdown()
 	err := s.httpServer.Close()
 	if err != nil {
 		log.Errorf("Problem shutting down grpc: %v", err)
@@ -398,12 +472,16 @@ func (s *GrpcServer) GetBlockchainInfo(ctx context.Context, req *pb.GetBlockchai
 		net = pb.GetBlockchainInfoResponse_TESTNET3
 	case &chaincfg.TestNet4Params:
 		net = pb.GetBlockchainInfoResponse_TESTNET4
+	case &chaincfg.ChipNetParams:
+		net = pb.GetBlockchainInfoResponse_CHIPNET
+	case &chaincfg.ScaleNetParams:
+		net = pb.GetBlockchainInfoResponse_SCALENET
 	case &chaincfg.RegressionNetParams:
 		net = pb.GetBlockchainInfoResponse_REGTEST
 	case &chaincfg.SimNetParams:
 		net = pb.GetBlockchainInfoResponse_SIMNET
 	default:
-		return nil, status.Error(codes.Internal, "unknown network parameters")
+		net = pb.GetBlockchainInfoResponse_CUSTOMNET
 	}
 
 	gsEnabled := false
@@ -416,6 +494,10 @@ func (s *GrpcServer) GetBlockchainInfo(ctx context.Context, req *pb.GetBlockchai
 		TxIndex:        s.txIndex != nil,
 		SlpIndex:       s.slpIndex != nil,
 		SlpGraphsearch: gsEnabled,
+		TokenIndex:     s.tokenIndex != nil,
+		StatsIndex:     s.statsIndex != nil,
+		CoinAgeIndex:   s.coinAgeIndex != nil,
+		WatchSetIndex:  s.watchSetIndex != nil,
 		BestHeight:     bestSnapShot.Height,
 		BestBlockHash:  bestSnapShot.Hash[:],
 		BitcoinNet:     net,
@@ -481,25 +563,33 @@ func (s *GrpcServer) GetBlock(ctx context.Context, req *pb.GetBlockRequest) (*pb
 		return nil, status.Error(codes.NotFound, "block not found")
 	}
 
+	pbBlock, err := s.marshalFullBlock(block, req.FullTransactions)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetBlockResponse{Block: pbBlock}, nil
+}
+
+// marshalFullBlock marshals the passed block along with either its full
+// transactions or just their hashes.
+func (s *GrpcServer) marshalFullBlock(block *bchutil.Block, fullTransactions bool) (*pb.Block, error) {
 	confirmations := s.chain.BestSnapshot().Height - block.Height() + 1
 	medianTime, err := s.chain.MedianTimeByHash(block.Hash())
 	if err != nil {
 		return nil, status.Error(codes.Internal, "error calculating median time for block")
 	}
-	resp := &pb.GetBlockResponse{
-		Block: &pb.Block{
-			Info: marshalBlockInfo(block, confirmations, medianTime, s.chainParams),
-		},
+	pbBlock := &pb.Block{
+		Info: marshalBlockInfo(block, confirmations, medianTime, s.chainParams),
 	}
 
 	nextHeader, err := s.chain.HeaderByHeight(block.Height() + 1)
 	if err == nil {
 		nextHash := nextHeader.BlockHash()
-		resp.Block.Info.NextBlockHash = nextHash.CloneBytes()
+		pbBlock.Info.NextBlockHash = nextHash.CloneBytes()
 	}
 
 	var spentTxos []blockchain.SpentTxOut
-	if req.FullTransactions {
+	if fullTransactions {
 		spentTxos, err = s.chain.FetchSpendJournal(block)
 		if err != nil {
 			return nil, status.Error(codes.Internal, "error loading spend journal")
@@ -507,7 +597,7 @@ func (s *GrpcServer) GetBlock(ctx context.Context, req *pb.GetBlockRequest) (*pb
 	}
 	spendIdx := 0
 	for idx, tx := range block.Transactions() {
-		if req.FullTransactions {
+		if fullTransactions {
 			header := block.MsgBlock().Header
 			respTx := marshalTransaction(tx, confirmations, &header, block.Height(), s)
 			for i := range tx.MsgTx().TxIn {
@@ -537,20 +627,20 @@ func (s *GrpcServer) GetBlock(ctx context.Context, req *pb.GetBlockRequest) (*pb
 				}
 			}
 
-			resp.Block.TransactionData = append(resp.Block.TransactionData, &pb.Block_TransactionData{
+			pbBlock.TransactionData = append(pbBlock.TransactionData, &pb.Block_TransactionData{
 				TxidsOrTxs: &pb.Block_TransactionData_Transaction{
 					Transaction: respTx,
 				},
 			})
 		} else {
-			resp.Block.TransactionData = append(resp.Block.TransactionData, &pb.Block_TransactionData{
+			pbBlock.TransactionData = append(pbBlock.TransactionData, &pb.Block_TransactionData{
 				TxidsOrTxs: &pb.Block_TransactionData_TransactionHash{
 					TransactionHash: tx.Hash().CloneBytes(),
 				},
 			})
 		}
 	}
-	return resp, nil
+	return pbBlock, nil
 }
 
 // GetRawBlock returns a block in a serialized format.
@@ -582,6 +672,69 @@ func (s *GrpcServer) GetRawBlock(ctx context.Context, req *pb.GetRawBlockRequest
 	return resp, nil
 }
 
+// GetBlockUndoData returns the outputs spent by the transactions of a block, as
+// recorded in its undo data.
+func (s *GrpcServer) GetBlockUndoData(ctx context.Context, req *pb.GetBlockUndoDataRequest) (*pb.GetBlockUndoDataResponse, error) {
+	var (
+		block *bchutil.Block
+		err   error
+	)
+	if len(req.GetHash()) == 0 {
+		block, err = s.chain.BlockByHeight(req.GetHeight())
+	} else {
+		h, err := chainhash.NewHash(req.GetHash())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid hash: %v", err)
+		}
+		block, err = s.chain.BlockByHash(h)
+	}
+	if err != nil || block == nil {
+		return nil, status.Error(codes.NotFound, "block not found")
+	}
+
+	// The undo data of a block is only kept while it is part of the main
+	// chain.
+	if !s.chain.MainChainHasBlock(block.Hash()) {
+		return nil, status.Error(codes.FailedPrecondition, "block is not in the main chain")
+	}
+	spentTxos, err := s.chain.FetchSpendJournal(block)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "error loading spend journal")
+	}
+
+	resp := &pb.GetBlockUndoDataResponse{
+		BlockHash: block.Hash().CloneBytes(),
+		Height:    block.Height(),
+	}
+	spendIdx := 0
+	for _, tx := range block.Transactions()[1:] {
+		numIns := len(tx.MsgTx().TxIn)
+		if spendIdx+numIns > len(spentTxos) {
+			return nil, status.Error(codes.NotFound, "undo data not found")
+		}
+		txUndo := &pb.GetBlockUndoDataResponse_TransactionUndo{
+			TransactionHash: tx.Hash().CloneBytes(),
+			SpentOutputs:    make([]*pb.GetBlockUndoDataResponse_SpentOutput, 0, numIns),
+		}
+		for _, stxo := range spentTxos[spendIdx : spendIdx+numIns] {
+			pk, cashToken, err := getPbCashTokenDataFormPkScriptIfExists(stxo.PkScript)
+			if err != nil {
+				pk, cashToken = stxo.PkScript, nil
+			}
+			txUndo.SpentOutputs = append(txUndo.SpentOutputs, &pb.GetBlockUndoDataResponse_SpentOutput{
+				Value:        stxo.Amount,
+				PubkeyScript: pk,
+				CashToken:    cashToken,
+				BlockHeight:  stxo.Height,
+				IsCoinbase:   stxo.IsCoinBase,
+			})
+		}
+		spendIdx += numIns
+		resp.Transactions = append(resp.Transactions, txUndo)
+	}
+	return resp, nil
+}
+
 // GetBlockFilter returns the compact filter (cf) of a block as a Golomb-Rice encoded set.
 //
 // **Requires CfIndex**
@@ -876,6 +1029,16 @@ func (s *GrpcServer) GetAddressTransactions(ctx context.Context, req *pb.GetAddr
 		resp.ConfirmedTransactions = append(resp.ConfirmedTransactions, tx)
 	}
 
+	resp.UnconfirmedTransactions = s.marshalUnconfirmedAddressTransactions(addr)
+
+	return resp, nil
+}
+
+// marshalUnconfirmedAddressTransactions marshals the transactions in the
+// mempool which involve the passed address along with their input metadata.
+func (s *GrpcServer) marshalUnconfirmedAddressTransactions(addr bchutil.Address) []*pb.MempoolTransaction {
+	var mempoolTxs []*pb.MempoolTransaction
+
 	unconfirmedTxs := s.addrIndex.UnconfirmedTxnsForAddress(addr)
 	for _, uTx := range unconfirmedTxs {
 		tx := marshalTransaction(uTx, 0, nil, 0, s)
@@ -912,10 +1075,10 @@ func (s *GrpcServer) GetAddressTransactions(ctx context.Context, req *pb.GetAddr
 			FeePerKb:         txDesc.Fee / int64(uTx.MsgTx().SerializeSize()),
 			StartingPriority: txDesc.StartingPriority,
 		}
-		resp.UnconfirmedTransactions = append(resp.UnconfirmedTransactions, mempoolTx)
+		mempoolTxs = append(mempoolTxs, mempoolTx)
 	}
 
-	return resp, nil
+	return mempoolTxs
 }
 
 // GetRawAddressTransactions returns the raw transactions for the given address. Offers offset,
@@ -1138,6 +1301,52 @@ func (s *GrpcServer) GetAddressUnspentOutputs(ctx context.Context, req *pb.GetAd
 	return resp, nil
 }
 
+// GetAddressBalance returns the balance of the given address as of the best
+// block or a block in the past.
+//
+// **Requires AddressIndex**
+func (s *GrpcServer) GetAddressBalance(ctx context.Context, req *pb.GetAddressBalanceRequest) (*pb.GetAddressBalanceResponse, error) {
+	if s.addrIndex == nil {
+		return nil, status.Error(codes.Unavailable, "addrindex required")
+	}
+
+	// Attempt to decode the supplied address.
+	addr, err := bchutil.DecodeAddress(req.Address, s.chainParams)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid address")
+	}
+
+	// use cash address format
+	addr, err = bchutil.ConvertSlpToCashAddress(addr, s.chainParams)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "couldn't convert address to cash address format")
+	}
+
+	height := req.Height
+	if height == 0 {
+		height = s.chain.BestSnapshot().Height
+	}
+	if height < 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid height")
+	}
+	blockHash, err := s.chain.BlockHashByHeight(height)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "block not found at height %d", height)
+	}
+
+	balance, err := s.addrIndex.BalanceForAddress(addr, height)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to load address balance: %v", err)
+	}
+
+	resp := &pb.GetAddressBalanceResponse{
+		Balance:   balance,
+		Height:    height,
+		BlockHash: blockHash[:],
+	}
+	return resp, nil
+}
+
 // GetUnspentOutput takes an unspent output in the utxo set and returns
 // the utxo metadata or not found.
 func (s *GrpcServer) GetUnspentOutput(ctx context.Context, req *pb.GetUnspentOutputRequest) (*pb.GetUnspentOutputResponse, error) {
@@ -1307,6 +1516,118 @@ func (s *GrpcServer) GetMerkleProof(ctx context.Context, req *pb.GetMerkleProofR
 	return resp, nil
 }
 
+// GetBatchMerkleProof returns a single Merkle (SPV) proof for a set of
+// transactions in the same block.  For blocks using the canonical
+// transaction ordering, the hashes of the requested transactions are
+// left out of the proof since their order in the block is implied.
+//
+// **Requires TxIndex unless the block is provided**
+func (s *GrpcServer) GetBatchMerkleProof(ctx context.Context, req *pb.GetBatchMerkleProofRequest) (*pb.GetBatchMerkleProofResponse, error) {
+	if len(req.TransactionHashes) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no transaction hashes provided")
+	}
+	if len(req.TransactionHashes) > maxBatchMerkleProofTxns {
+		return nil, status.Errorf(codes.InvalidArgument, "too many transaction hashes, the maximum is %d", maxBatchMerkleProofTxns)
+	}
+
+	txnHashes := make([]*chainhash.Hash, 0, len(req.TransactionHashes))
+	txnSet := make(map[chainhash.Hash]struct{}, len(req.TransactionHashes))
+	for _, b := range req.TransactionHashes {
+		txnHash, err := chainhash.NewHash(b)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid transaction hash %v", err)
+		}
+		if _, ok := txnSet[*txnHash]; ok {
+			continue
+		}
+		txnSet[*txnHash] = struct{}{}
+		txnHashes = append(txnHashes, txnHash)
+	}
+
+	var (
+		block *bchutil.Block
+		err   error
+	)
+	switch hashOrHeight := req.HashOrHeight.(type) {
+	case *pb.GetBatchMerkleProofRequest_BlockHash:
+		blkHash, err := chainhash.NewHash(hashOrHeight.BlockHash)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid hash: %v", err)
+		}
+		block, err = s.chain.BlockByHash(blkHash)
+		if err != nil {
+			return nil, status.Error(codes.NotFound, "block not found")
+		}
+	case *pb.GetBatchMerkleProofRequest_Height:
+		block, err = s.chain.BlockByHeight(hashOrHeight.Height)
+		if err != nil {
+			return nil, status.Error(codes.NotFound, "block not found")
+		}
+	default:
+		if s.txIndex == nil {
+			return nil, status.Error(codes.Unavailable, "txindex required when the block is not provided")
+		}
+
+		// lookup location of the first transaction
+		blockRegion, err := s.txIndex.TxBlockRegion(txnHashes[0])
+		if err != nil || blockRegion == nil {
+			return nil, status.Error(codes.NotFound, "unable to find block for given transaction")
+		}
+		block, err = s.chain.BlockByHash(blockRegion.Hash)
+		if err != nil {
+			return nil, status.Error(codes.NotFound, "unable to find block for given transaction")
+		}
+	}
+
+	// create merkle proof
+	mBlock, matchedIndices := merkleblock.NewMerkleBlockWithTxnSet(block, txnHashes)
+	if len(matchedIndices) != len(txnHashes) {
+		matched := make(map[chainhash.Hash]struct{}, len(matchedIndices))
+		for _, i := range matchedIndices {
+			matched[*block.Transactions()[i].Hash()] = struct{}{}
+		}
+		for _, txnHash := range txnHashes {
+			if _, ok := matched[*txnHash]; !ok {
+				return nil, status.Errorf(codes.NotFound, "transaction %v not found in block %v", txnHash, block.Hash())
+			}
+		}
+	}
+
+	// Since the magnetic anomaly hardfork the transactions of a block, except
+	// the coinbase, are sorted by hash.  The matched leaves of the partial
+	// merkle tree are then in the order of the sorted requested hashes, so the
+	// verifier can fill them in and they don't need to be sent.
+	omitMatched := block.Height() > s.chainParams.MagneticAnonomalyForkHeight
+	coinbaseHash := block.Transactions()[0].Hash()
+
+	hashes := make([][]byte, 0, len(mBlock.Hashes))
+	for _, h := range mBlock.Hashes {
+		if _, ok := txnSet[*h]; ok && omitMatched && !h.IsEqual(coinbaseHash) {
+			continue
+		}
+		hashes = append(hashes, h.CloneBytes())
+	}
+	medianTime, err := s.chain.MedianTimeByHash(block.Hash())
+	if err != nil {
+		return nil, status.Error(codes.Internal, "error calculating median time for block")
+	}
+	resp := &pb.GetBatchMerkleProofResponse{
+		Block:                marshalBlockInfo(block, s.chain.BestSnapshot().Height-block.Height()+1, medianTime, s.chainParams),
+		TransactionCount:     mBlock.Transactions,
+		Hashes:               hashes,
+		Flags:                mBlock.Flags,
+		MatchedHashesOmitted: omitMatched,
+	}
+
+	nextHeader, err := s.chain.HeaderByHeight(block.Height() + 1)
+	if err == nil {
+		nextHash := nextHeader.BlockHash()
+		resp.Block.NextBlockHash = nextHash.CloneBytes()
+	}
+
+	return resp, nil
+}
+
 // GetSlpTokenMetadata returns metadata associated with a Token ID
 func (s *GrpcServer) GetSlpTokenMetadata(ctx context.Context, req *pb.GetSlpTokenMetadataRequest) (*pb.GetSlpTokenMetadataResponse, error) {
 	if s.slpIndex == nil {
@@ -2082,6 +2403,118 @@ func (s *GrpcServer) getSlpIndexEntryAndCheckBurnOtherToken(outpoint wire.OutPoi
 	return slpEntry, nil
 }
 
+// InvalidateBlock permanently marks a block and all of its descendants as
+// invalid and reorganizes to the best remaining valid chain.
+//
+// Since this changes the state of the node it is only available when clients
+// are required to authenticate.
+func (s *GrpcServer) InvalidateBlock(ctx context.Context, req *pb.InvalidateBlockRequest) (*pb.InvalidateBlockResponse, error) {
+	if !s.allowAdminMethods {
+		return nil, status.Error(codes.PermissionDenied, "invalidateblock requires an authentication token or client certificates to be configured")
+	}
+	h, err := chainhash.NewHash(req.GetHash())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid hash: %v", err)
+	}
+	if err := s.chain.InvalidateBlock(h); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to invalidate block: %v", err)
+	}
+
+	best := s.chain.BestSnapshot()
+	return &pb.InvalidateBlockResponse{
+		BestBlockHash: best.Hash.CloneBytes(),
+		BestHeight:    best.Height,
+	}, nil
+}
+
+// ReconsiderBlock removes the invalid status from a block and its descendants
+// which were previously invalidated and reorganizes to the best chain.
+//
+// Since this changes the state of the node it is only available when clients
+// are required to authenticate.
+func (s *GrpcServer) ReconsiderBlock(ctx context.Context, req *pb.ReconsiderBlockRequest) (*pb.ReconsiderBlockResponse, error) {
+	if !s.allowAdminMethods {
+		return nil, status.Error(codes.PermissionDenied, "reconsiderblock requires an authentication token or client certificates to be configured")
+	}
+	h, err := chainhash.NewHash(req.GetHash())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid hash: %v", err)
+	}
+	if err := s.chain.ReconsiderBlock(h); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to reconsider block: %v", err)
+	}
+
+	best := s.chain.BestSnapshot()
+	return &pb.ReconsiderBlockResponse{
+		BestBlockHash: best.Hash.CloneBytes(),
+		BestHeight:    best.Height,
+	}, nil
+}
+
+// backupChunkSize is the maximum size of the data sent in a single
+// BackupChainstateResponse.
+const backupChunkSize = 1024 * 1024
+
+// backupStreamWriter is an io.Writer which sends the data written to it over a
+// BackupChainstate stream in chunks of at most backupChunkSize.
+type backupStreamWriter struct {
+	stream pb.Bchrpc_BackupChainstateServer
+}
+
+// Write sends the passed data over the stream.
+func (w *backupStreamWriter) Write(p []byte) (int, error) {
+	var n int
+	for len(p) > 0 {
+		if err := w.stream.Context().Err(); err != nil {
+			return n, err
+		}
+		chunk := p
+		if len(chunk) > backupChunkSize {
+			chunk = chunk[:backupChunkSize]
+		}
+
+		// The message may be used after Send returns, so the chunk
+		// must not share the buffer of the caller.
+		data := make([]byte, len(chunk))
+		copy(data, chunk)
+		if err := w.stream.Send(&pb.BackupChainstateResponse{Data: data}); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		p = p[len(chunk):]
+	}
+	return n, nil
+}
+
+// BackupChainstate streams a consistent backup of the block database while the
+// node keeps running.  The concatenated data of the responses is a backup
+// archive which can be restored with the restore command of dbtool.
+func (s *GrpcServer) BackupChainstate(req *pb.BackupChainstateRequest, stream pb.Bchrpc_BackupChainstateServer) error {
+	if !s.allowAdminMethods {
+		return status.Error(codes.PermissionDenied, "backupchainstate requires an authentication token or client certificates to be configured")
+	}
+
+	bw := bufio.NewWriterSize(&backupStreamWriter{stream: stream}, backupChunkSize)
+	archive := database.NewBackupArchiveWriter(bw)
+	err := s.db.Backup(archive, req.IncludeBlocks)
+	if err == nil {
+		err = database.FinishBackupArchive(archive)
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		if ctxErr := stream.Context().Err(); ctxErr != nil {
+			return status.FromContextError(ctxErr).Err()
+		}
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return status.Errorf(codes.Internal, "failed to backup chainstate: %v", err)
+	}
+	return nil
+}
+
 // SubmitTransaction submits a transaction to all connected peers.
 //
 // If slp index is enabled it will not allow slp burns unless the burned token is
@@ -2100,8 +2533,22 @@ func (s *GrpcServer) SubmitTransaction(ctx context.Context, req *pb.SubmitTransa
 		}
 	}
 
-	// Use 0 for the tag to represent local node.
 	tx := bchutil.NewTx(msgTx)
+	if err := s.submitTransaction(tx); err != nil {
+		return nil, err
+	}
+
+	resp := &pb.SubmitTransactionResponse{
+		Hash: tx.Hash().CloneBytes(),
+	}
+	return resp, nil
+}
+
+// submitTransaction adds the passed locally submitted transaction to the
+// mempool, relays it to peers, and rebroadcasts it until it makes its way into
+// a block.
+func (s *GrpcServer) submitTransaction(tx *bchutil.Tx) error {
+	// Use 0 for the tag to represent local node.
 	acceptedTxs, err := s.txMemPool.ProcessTransaction(tx, false, false, 0)
 	if err != nil {
 		// When the error is a rule error, it means the transaction was
@@ -2117,7 +2564,7 @@ func (s *GrpcServer) SubmitTransaction(ctx context.Context, req *pb.SubmitTransa
 			log.Errorf("Failed to process transaction %v: %v",
 				tx.Hash(), err)
 		}
-		return nil, status.Errorf(codes.InvalidArgument, "tx rejected: %v", err)
+		return status.Errorf(codes.InvalidArgument, "tx rejected: %v", err)
 	}
 
 	// When the transaction was accepted it should be the first item in the
@@ -2130,7 +2577,7 @@ func (s *GrpcServer) SubmitTransaction(ctx context.Context, req *pb.SubmitTransa
 	if len(acceptedTxs) == 0 || !acceptedTxs[0].Tx.Hash().IsEqual(tx.Hash()) {
 		s.txMemPool.RemoveTransaction(tx, true)
 
-		return nil, status.Errorf(codes.Internal, "transaction %v is not in accepted list", tx.Hash())
+		return status.Errorf(codes.Internal, "transaction %v is not in accepted list", tx.Hash())
 	}
 
 	// Generate and relay inventory vectors for all newly accepted
@@ -2144,8 +2591,54 @@ func (s *GrpcServer) SubmitTransaction(ctx context.Context, req *pb.SubmitTransa
 	iv := wire.NewInvVect(wire.InvTypeTx, txD.Tx.Hash())
 	s.netMgr.AddRebroadcastInventory(iv, txD)
 
-	resp := &pb.SubmitTransactionResponse{
-		Hash: tx.Hash().CloneBytes(),
+	return nil
+}
+
+// SubmitTrustedTransaction adds a locally submitted transaction to the mempool
+// even when it pays less than the relay fee and ensures it is included in the
+// block templates generated by the node.  Transactions which do not pay the
+// relay fee are not relayed to peers since they would reject them.
+//
+// **Requires an authentication token to be configured**
+func (s *GrpcServer) SubmitTrustedTransaction(ctx context.Context, req *pb.SubmitTrustedTransactionRequest) (*pb.SubmitTrustedTransactionResponse, error) {
+	msgTx := &wire.MsgTx{}
+	if err := msgTx.BchDecode(bytes.NewReader(req.Transaction), wire.ProtocolVersion, wire.BaseEncoding); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "unable to deserialize transaction")
+	}
+
+	tx := bchutil.NewTx(msgTx)
+	acceptedTxs, err := s.txMemPool.ProcessTrustedTransaction(tx)
+	if err != nil {
+		if _, ok := err.(mempool.RuleError); ok {
+			log.Debugf("Rejected trusted transaction %v: %v", tx.Hash(),
+				err)
+		} else {
+			log.Errorf("Failed to process trusted transaction %v: %v",
+				tx.Hash(), err)
+		}
+		return nil, status.Errorf(codes.InvalidArgument, "tx rejected: %v", err)
+	}
+	log.Infof("Accepted trusted transaction %v with fee %d", tx.Hash(),
+		acceptedTxs[0].Fee)
+
+	// Only relay the transactions which pay the relay fee.  The orphans
+	// accepted due to the trusted transaction passed the regular policy,
+	// so only the trusted transaction itself may not pay it.
+	txD := acceptedTxs[0]
+	minRelayTxFee := int64(s.txMemPool.Policy().MinRelayTxFee)
+	relayed := txD.FeePerKB >= minRelayTxFee
+	if !relayed {
+		acceptedTxs = acceptedTxs[1:]
+	}
+	s.netMgr.AnnounceNewTransactions(acceptedTxs)
+	if relayed {
+		iv := wire.NewInvVect(wire.InvTypeTx, txD.Tx.Hash())
+		s.netMgr.AddRebroadcastInventory(iv, txD)
+	}
+
+	resp := &pb.SubmitTrustedTransactionResponse{
+		Hash:    tx.Hash().CloneBytes(),
+		Relayed: relayed,
 	}
 	return resp, nil
 }
@@ -2158,6 +2651,12 @@ func (s *GrpcServer) SubmitTransaction(ctx context.Context, req *pb.SubmitTransa
 // you want to update the subscription filter. If you are not using grpc-web
 // then SubscribeTransactionStream is more appropriate.
 //
+// When blocks are disconnected from the main chain, the transactions they
+// included are sent as UNCONFIRMED_AGAIN notifications, and those which are
+// later double spent by a transaction of a connected block as DOUBLE_SPENT
+// notifications.  Notifications for the blocks connected since a given block
+// may be replayed with ReplayFromBlockHash.
+//
 // **Requires TxIndex to receive input metadata**
 func (s *GrpcServer) SubscribeTransactions(req *pb.SubscribeTransactionsRequest, stream pb.Bchrpc_SubscribeTransactionsServer) error {
 	subscription := s.subscribeEvents()
@@ -2170,6 +2669,18 @@ func (s *GrpcServer) SubscribeTransactions(req *pb.SubscribeTransactionsRequest,
 	includeMempool := req.IncludeMempool
 	includeBlocks := req.IncludeInBlock
 	serializeTx := req.SerializeTx
+
+	tracker := newReorgTracker()
+	if len(req.ReplayFromBlockHash) > 0 {
+		if !includeBlocks {
+			return status.Error(codes.InvalidArgument, "replay requires include_in_block")
+		}
+		err := s.replayTransactionNotifications(req.ReplayFromBlockHash, filter,
+			tracker, serializeTx, "SubscribeTransactions replay", stream)
+		if err != nil {
+			return err
+		}
+	}
 	for {
 		select {
 		case event := <-subscription.Events():
@@ -2190,87 +2701,32 @@ func (s *GrpcServer) SubscribeTransactions(req *pb.SubscribeTransactionsRequest,
 					continue
 				}
 
-				toSend := &pb.TransactionNotification{}
-				toSend.Type = pb.TransactionNotification_UNCONFIRMED
-
-				if serializeTx {
-					var buf bytes.Buffer
-					if err := txDesc.Tx.MsgTx().BchEncode(&buf, wire.ProtocolVersion, wire.BaseEncoding); err != nil {
-						return status.Error(codes.Internal, "error serializing transaction")
-					}
-
-					toSend.Transaction = &pb.TransactionNotification_SerializedTransaction{
-						SerializedTransaction: buf.Bytes(),
-					}
-
-				} else {
-					respTx := marshalTransaction(txDesc.Tx, 0, nil, 0, s)
-
-					if view, err := s.txMemPool.FetchInputUtxos(txDesc.Tx); err == nil {
-						s.setInputMetadataFromView(respTx, txDesc, view)
-					}
-
-					toSend.Transaction = &pb.TransactionNotification_UnconfirmedTransaction{
-						UnconfirmedTransaction: &pb.MempoolTransaction{
-							Transaction:      respTx,
-							AddedTime:        txDesc.Added.Unix(),
-							Fee:              txDesc.Fee,
-							FeePerKb:         txDesc.FeePerKB,
-							AddedHeight:      txDesc.Height,
-							StartingPriority: txDesc.StartingPriority,
-						},
-					}
+				toSend, err := s.mempoolTxNotification(txDesc, serializeTx)
+				if err != nil {
+					return err
 				}
-
 				if err := stream.Send(toSend); err != nil {
 					return err
 				}
 
 			case *rpcEventBlockConnected:
-				if !includeBlocks {
+				if !includeBlocks || tracker.skipConnected(event.Block) {
 					continue
 				}
-				// Search for all transactions.
-				block := event
-
-				for _, tx := range block.Transactions() {
-					if !filter.MatchAndUpdate(tx, s.chainParams) {
-						continue
-					}
-
-					if s.slpIndex != nil {
-						s.checkSlpTxOnEvent(tx.MsgTx(), "SubscribeTransactions rpcEventBlockConnected")
-					}
-
-					toSend := &pb.TransactionNotification{}
-					toSend.Type = pb.TransactionNotification_CONFIRMED
-
-					if serializeTx {
-						var buf bytes.Buffer
-						if err := tx.MsgTx().BchEncode(&buf, wire.ProtocolVersion, wire.BaseEncoding); err != nil {
-							return status.Error(codes.Internal, "error serializing transaction")
-						}
-						toSend.Transaction = &pb.TransactionNotification_SerializedTransaction{
-							SerializedTransaction: buf.Bytes(),
-						}
-
-					} else {
-						header := block.MsgBlock().Header
-
-						respTx := marshalTransaction(tx, s.chain.BestSnapshot().Height-block.Height()+1, &header, block.Height(), s)
-						if s.txIndex != nil {
-							if err := s.setInputMetadata(respTx); err != nil {
-								return err
-							}
-						}
-						toSend.Transaction = &pb.TransactionNotification_ConfirmedTransaction{
-							ConfirmedTransaction: respTx,
-						}
-					}
+				err := s.sendConnectedBlockNotifications(event.Block, filter,
+					tracker, serializeTx, "SubscribeTransactions rpcEventBlockConnected", stream)
+				if err != nil {
+					return err
+				}
 
-					if err := stream.Send(toSend); err != nil {
-						return err
-					}
+			case *rpcEventBlockDisconnected:
+				if !includeBlocks {
+					continue
+				}
+				err := s.sendDisconnectedBlockNotifications(event.Block, filter,
+					tracker, serializeTx, stream)
+				if err != nil {
+					return err
 				}
 			}
 
@@ -2287,6 +2743,9 @@ func (s *GrpcServer) SubscribeTransactions(req *pb.SubscribeTransactionsRequest,
 // Because this RPC is using bi-directional streaming it cannot be used with
 // grpc-web.
 //
+// Reorganizations are notified the same way as by SubscribeTransactions.
+// Notifications are replayed whenever a request sets ReplayFromBlockHash.
+//
 // **Requires TxIndex to receive input metadata**
 func (s *GrpcServer) SubscribeTransactionStream(stream pb.Bchrpc_SubscribeTransactionStreamServer) error {
 	// Put the incoming messages on a channel.
@@ -2309,6 +2768,7 @@ func (s *GrpcServer) SubscribeTransactionStream(stream pb.Bchrpc_SubscribeTransa
 	defer subscription.Unsubscribe()
 
 	filter := newTxFilter()
+	tracker := newReorgTracker()
 	var includeMempool, includeBlocks, serializeTx bool
 	for {
 		select {
@@ -2328,6 +2788,17 @@ func (s *GrpcServer) SubscribeTransactionStream(stream pb.Bchrpc_SubscribeTransa
 				return err
 			}
 
+			if len(req.ReplayFromBlockHash) > 0 {
+				if !includeBlocks {
+					return status.Error(codes.InvalidArgument, "replay requires include_in_block")
+				}
+				err := s.replayTransactionNotifications(req.ReplayFromBlockHash, filter,
+					tracker, serializeTx, "SubscribeTransactionStream replay", stream)
+				if err != nil {
+					return err
+				}
+			}
+
 		case event := <-subscription.Events():
 
 			switch event := event.(type) {
@@ -2346,87 +2817,32 @@ func (s *GrpcServer) SubscribeTransactionStream(stream pb.Bchrpc_SubscribeTransa
 					continue
 				}
 
-				toSend := &pb.TransactionNotification{}
-				toSend.Type = pb.TransactionNotification_UNCONFIRMED
-
-				if serializeTx {
-					var buf bytes.Buffer
-					if err := txDesc.Tx.MsgTx().BchEncode(&buf, wire.ProtocolVersion, wire.BaseEncoding); err != nil {
-						return status.Error(codes.Internal, "error serializing transaction")
-					}
-
-					toSend.Transaction = &pb.TransactionNotification_SerializedTransaction{
-						SerializedTransaction: buf.Bytes(),
-					}
-
-				} else {
-					respTx := marshalTransaction(txDesc.Tx, 0, nil, 0, s)
-
-					if view, err := s.txMemPool.FetchInputUtxos(txDesc.Tx); err == nil {
-						s.setInputMetadataFromView(respTx, txDesc, view)
-					}
-
-					toSend.Transaction = &pb.TransactionNotification_UnconfirmedTransaction{
-						UnconfirmedTransaction: &pb.MempoolTransaction{
-							Transaction:      respTx,
-							AddedTime:        txDesc.Added.Unix(),
-							Fee:              txDesc.Fee,
-							FeePerKb:         txDesc.FeePerKB,
-							AddedHeight:      txDesc.Height,
-							StartingPriority: txDesc.StartingPriority,
-						},
-					}
+				toSend, err := s.mempoolTxNotification(txDesc, serializeTx)
+				if err != nil {
+					return err
 				}
-
 				if err := stream.Send(toSend); err != nil {
 					return err
 				}
 
 			case *rpcEventBlockConnected:
-				if !includeBlocks {
+				if !includeBlocks || tracker.skipConnected(event.Block) {
 					continue
 				}
-				// Search for all transactions.
-				block := event
-
-				for _, tx := range block.Transactions() {
-					if !filter.MatchAndUpdate(tx, s.chainParams) {
-						continue
-					}
-
-					if s.slpIndex != nil {
-						s.checkSlpTxOnEvent(tx.MsgTx(), "SubscribeTransactionStream rpcEventBlockConnected")
-					}
-
-					toSend := &pb.TransactionNotification{}
-					toSend.Type = pb.TransactionNotification_CONFIRMED
-
-					if serializeTx {
-						var buf bytes.Buffer
-						if err := tx.MsgTx().BchEncode(&buf, wire.ProtocolVersion, wire.BaseEncoding); err != nil {
-							return status.Error(codes.Internal, "error serializing transaction")
-						}
-						toSend.Transaction = &pb.TransactionNotification_SerializedTransaction{
-							SerializedTransaction: buf.Bytes(),
-						}
-
-					} else {
-						header := block.MsgBlock().Header
-
-						respTx := marshalTransaction(tx, s.chain.BestSnapshot().Height-block.Height()+1, &header, block.Height(), s)
-						if s.txIndex != nil {
-							if err := s.setInputMetadata(respTx); err != nil {
-								return err
-							}
-						}
-						toSend.Transaction = &pb.TransactionNotification_ConfirmedTransaction{
-							ConfirmedTransaction: respTx,
-						}
-					}
+				err := s.sendConnectedBlockNotifications(event.Block, filter,
+					tracker, serializeTx, "SubscribeTransactionStream rpcEventBlockConnected", stream)
+				if err != nil {
+					return err
+				}
 
-					if err := stream.Send(toSend); err != nil {
-						return err
-					}
+			case *rpcEventBlockDisconnected:
+				if !includeBlocks {
+					continue
+				}
+				err := s.sendDisconnectedBlockNotifications(event.Block, filter,
+					tracker, serializeTx, stream)
+				if err != nil {
+					return err
 				}
 			}
 
@@ -0,0 +1,132 @@
+package bchrpc
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/gcash/bchd/bchrpc/pb"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// baseSigHashTypes are the hash types preimages are returned for by default.
+var baseSigHashTypes = []txscript.SigHashType{
+	txscript.SigHashAll | txscript.SigHashForkID,
+	txscript.SigHashNone | txscript.SigHashForkID,
+	txscript.SigHashSingle | txscript.SigHashForkID,
+	txscript.SigHashAll | txscript.SigHashForkID | txscript.SigHashAnyOneCanPay,
+	txscript.SigHashNone | txscript.SigHashForkID | txscript.SigHashAnyOneCanPay,
+	txscript.SigHashSingle | txscript.SigHashForkID | txscript.SigHashAnyOneCanPay,
+}
+
+// isValidSigHashType returns whether the passed hash type is valid under the
+// current consensus rules.
+func isValidSigHashType(hashType txscript.SigHashType) bool {
+	if hashType&txscript.SigHashForkID == 0 {
+		return false
+	}
+	if hashType&txscript.SigHashUTXO != 0 && hashType&txscript.SigHashAnyOneCanPay != 0 {
+		return false
+	}
+	base := hashType &^ (txscript.SigHashForkID | txscript.SigHashUTXO | txscript.SigHashAnyOneCanPay)
+	return base >= txscript.SigHashAll && base <= txscript.SigHashSingle
+}
+
+// GetSignatureHashPreimages returns the signature hash preimage of an input of
+// an unsigned transaction for each requested hash type.
+func (s *GrpcServer) GetSignatureHashPreimages(ctx context.Context, req *pb.GetSignatureHashPreimagesRequest) (*pb.GetSignatureHashPreimagesResponse, error) {
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(req.Transaction)); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "unable to deserialize transaction")
+	}
+	idx := int(req.InputIndex)
+	if idx >= len(tx.TxIn) {
+		return nil, status.Errorf(codes.InvalidArgument, "input index %d out of range", idx)
+	}
+
+	// The outputs spent by all inputs are needed for SIGHASH_UTXOS, while
+	// only the output spent by the input is needed otherwise.
+	allSpent := len(req.SpentOutputs) == len(tx.TxIn)
+	if !allSpent && len(req.SpentOutputs) != 1 {
+		return nil, status.Error(codes.InvalidArgument, "either the output spent by the input or the outputs spent by all inputs are required")
+	}
+	utxoCache := txscript.NewUtxoCache()
+	var spent *wire.TxOut
+	for i, output := range req.SpentOutputs {
+		tokenData, err := unmarshalCashToken(output.CashToken)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid spent output: %v", err)
+		}
+		txOut := wire.NewTxOut(output.Value, output.PubkeyScript, tokenData)
+		if !allSpent {
+			i = idx
+		}
+		if i == idx {
+			spent = txOut
+		}
+		utxoCache.AddEntry(i, *txOut)
+	}
+	sigHashes := txscript.NewTxSigHashes(&tx)
+	sigHashes.AddTxSigHashUtxoFromUtxoCache(&tx, utxoCache)
+
+	scriptCode := req.ScriptCode
+	if len(scriptCode) == 0 {
+		scriptCode = spent.PkScript
+	}
+
+	hashTypes := append([]txscript.SigHashType(nil), baseSigHashTypes...)
+	if allSpent {
+		for _, hashType := range baseSigHashTypes[:3] {
+			hashTypes = append(hashTypes, hashType|txscript.SigHashUTXO)
+		}
+	}
+	if len(req.SighashTypes) > 0 {
+		hashTypes = make([]txscript.SigHashType, 0, len(req.SighashTypes))
+		for _, t := range req.SighashTypes {
+			hashType := txscript.SigHashType(t)
+			if !isValidSigHashType(hashType) {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid sighash type 0x%x", t)
+			}
+			if hashType&txscript.SigHashUTXO != 0 && !allSpent {
+				return nil, status.Error(codes.InvalidArgument, "SIGHASH_UTXOS requires the outputs spent by all inputs")
+			}
+			hashTypes = append(hashTypes, hashType)
+		}
+	}
+
+	resp := &pb.GetSignatureHashPreimagesResponse{}
+	for _, hashType := range hashTypes {
+		preimage, err := txscript.CalcSignatureHashPreimage(scriptCode,
+			sigHashes, hashType, &tx, idx, spent.Value)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "unable to calculate preimage: %v", err)
+		}
+		b := preimage.Bytes()
+		p := &pb.GetSignatureHashPreimagesResponse_Preimage{
+			SighashType:  uint32(hashType),
+			Preimage:     b,
+			Sighash:      chainhash.DoubleHashB(b),
+			Version:      preimage.Version,
+			HashPrevouts: preimage.HashPrevOuts[:],
+			HashSequence: preimage.HashSequence[:],
+			Outpoint: &pb.Transaction_Input_Outpoint{
+				Hash:  preimage.PrevOut.Hash[:],
+				Index: preimage.PrevOut.Index,
+			},
+			TokenPrefix: preimage.TokenPrefix,
+			ScriptCode:  preimage.ScriptCode,
+			Value:       preimage.Amount,
+			Sequence:    preimage.Sequence,
+			HashOutputs: preimage.HashOutputs[:],
+			LockTime:    preimage.LockTime,
+		}
+		if preimage.HasHashUtxos {
+			p.HashUtxos = preimage.HashUtxos[:]
+		}
+		resp.Preimages = append(resp.Preimages, p)
+	}
+	return resp, nil
+}
@@ -0,0 +1,58 @@
+package bchrpc
+
+import (
+	"time"
+
+	"github.com/gcash/bchd/bchrpc/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultSyncProgressInterval is the interval between the progress updates of
+// SyncProgress when the request does not specify one.
+const defaultSyncProgressInterval = 10 * time.Second
+
+// SyncProgress streams estimates of the progress of the initial block download.
+func (s *GrpcServer) SyncProgress(req *pb.SyncProgressRequest, stream pb.Bchrpc_SyncProgressServer) error {
+	interval := defaultSyncProgressInterval
+	if req.Interval > 0 {
+		interval = time.Duration(req.Interval) * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		progress := s.chain.SyncProgress()
+		info, err := s.db.SpaceInfo()
+		if err != nil {
+			return status.Error(codes.Internal, "error fetching database space info")
+		}
+
+		err = stream.Send(&pb.SyncProgressResponse{
+			Height:                     progress.Height,
+			Hash:                       progress.Hash[:],
+			BlockTime:                  progress.BlockTime.Unix(),
+			TotalTransactions:          progress.TotalTxns,
+			EstimatedTotalTransactions: progress.EstimatedTotalTxns,
+			VerificationProgress:       progress.VerificationProgress,
+			TransactionsPerSecond:      progress.TxRate,
+			EstimatedRemainingSeconds:  int64(progress.RemainingTime / time.Second),
+			SizeOnDisk:                 info.MetadataSize + info.BlocksSize,
+			InitialBlockDownload:       !progress.Current,
+		})
+		if err != nil {
+			return err
+		}
+		if req.UntilSynced && progress.Current {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stream.Context().Done():
+			return nil // client disconnected
+		case <-s.quit:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package bchrpc
+
+import (
+	"context"
+
+	"github.com/gcash/bchd/bchrpc/pb"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GetTokenStats returns the genesis transaction and circulating supply of a
+// CashToken category.
+//
+// **Requires TokenIndex**
+func (s *GrpcServer) GetTokenStats(ctx context.Context, req *pb.GetTokenStatsRequest) (*pb.GetTokenStatsResponse, error) {
+	if s.tokenIndex == nil {
+		return nil, status.Error(codes.Unavailable, "tokenindex required")
+	}
+	if len(req.CategoryId) != chainhash.HashSize {
+		return nil, status.Error(codes.InvalidArgument, "invalid token category id")
+	}
+
+	var category [32]byte
+	copy(category[:], req.CategoryId)
+	bestHeight := s.chain.BestSnapshot().Height
+	stats, err := s.tokenIndex.TokenStats(category)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to load token stats: %v", err)
+	}
+	if stats == nil {
+		return nil, status.Error(codes.NotFound, "token category not found")
+	}
+
+	return &pb.GetTokenStatsResponse{
+		CategoryId:      category[:],
+		GenesisHash:     stats.GenesisTx[:],
+		GenesisHeight:   stats.GenesisHeight,
+		FungibleSupply:  stats.FungibleSupply,
+		NftCount:        stats.NftCount,
+		MintingNftCount: stats.MintingNftCount,
+		BestHeight:      bestHeight,
+	}, nil
+}
@@ -0,0 +1,150 @@
+package bchrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/gcash/bchd/bchrpc/pb"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/txbuilder"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// unmarshalCashToken converts the protobuf representation of a CashToken to
+// token data.  A nil token is converted to empty token data.
+func unmarshalCashToken(token *pb.CashToken) (wire.TokenData, error) {
+	var tokenData wire.TokenData
+	if token == nil {
+		return tokenData, nil
+	}
+	if len(token.CategoryId) != chainhash.HashSize {
+		return tokenData, errors.New("invalid token category id")
+	}
+	if len(token.Bitfield) != 1 {
+		return tokenData, errors.New("invalid token bitfield")
+	}
+	copy(tokenData.CategoryID[:], token.CategoryId)
+	tokenData.Amount = token.Amount
+	tokenData.Commitment = token.Commitment
+	tokenData.BitField = token.Bitfield[0]
+	if tokenData.IsEmpty() || !tokenData.IsValidBitfield() {
+		return tokenData, errors.New("invalid token bitfield")
+	}
+	return tokenData, nil
+}
+
+// marshalTxBuilderUtxo converts an unspent output spent by a built transaction
+// to its protobuf representation.
+func marshalTxBuilderUtxo(utxo *txbuilder.Utxo) *pb.BuildTransactionRequest_Utxo {
+	out := &pb.BuildTransactionRequest_Utxo{
+		Outpoint: &pb.Transaction_Input_Outpoint{
+			Hash:  utxo.OutPoint.Hash.CloneBytes(),
+			Index: utxo.OutPoint.Index,
+		},
+		Value:               utxo.Output.Value,
+		PubkeyScript:        utxo.Output.PkScript,
+		SignatureScriptSize: uint32(utxo.SigScriptSize),
+		SigChecks:           uint32(utxo.SigChecks),
+	}
+	if !utxo.Output.TokenData.IsEmpty() {
+		out.CashToken = getPbCashTokenDataFromTokenData(utxo.Output.TokenData)
+	}
+	return out
+}
+
+// BuildTransaction builds an unsigned transaction which pays the requested
+// outputs from the provided unspent outputs.
+func (s *GrpcServer) BuildTransaction(ctx context.Context, req *pb.BuildTransactionRequest) (*pb.BuildTransactionResponse, error) {
+	changeAddr, err := bchutil.DecodeAddress(req.ChangeAddress, s.chainParams)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid change address: %s", req.ChangeAddress)
+	}
+	changeScript, err := txscript.PayToAddrScript(changeAddr)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid change address: %s", req.ChangeAddress)
+	}
+
+	utxos := make([]*txbuilder.Utxo, 0, len(req.Utxos))
+	for _, utxo := range req.Utxos {
+		if utxo.Outpoint == nil {
+			return nil, status.Error(codes.InvalidArgument, "utxo outpoint is required")
+		}
+		hash, err := chainhash.NewHash(utxo.Outpoint.Hash)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid utxo outpoint hash")
+		}
+		tokenData, err := unmarshalCashToken(utxo.CashToken)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid utxo: %v", err)
+		}
+		utxos = append(utxos, &txbuilder.Utxo{
+			OutPoint:      *wire.NewOutPoint(hash, utxo.Outpoint.Index),
+			Output:        *wire.NewTxOut(utxo.Value, utxo.PubkeyScript, tokenData),
+			SigScriptSize: int(utxo.SignatureScriptSize),
+			SigChecks:     int(utxo.SigChecks),
+		})
+	}
+
+	outputs := make([]*wire.TxOut, 0, len(req.Outputs))
+	for _, output := range req.Outputs {
+		pkScript := output.PubkeyScript
+		switch {
+		case output.Address != "" && len(pkScript) > 0:
+			return nil, status.Error(codes.InvalidArgument, "output address and pubkey script are mutually exclusive")
+		case output.Address != "":
+			addr, err := bchutil.DecodeAddress(output.Address, s.chainParams)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid address: %s", output.Address)
+			}
+			pkScript, err = txscript.PayToAddrScript(addr)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid address: %s", output.Address)
+			}
+		case len(pkScript) == 0:
+			return nil, status.Error(codes.InvalidArgument, "output address or pubkey script is required")
+		}
+		tokenData, err := unmarshalCashToken(output.CashToken)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid output: %v", err)
+		}
+		outputs = append(outputs, wire.NewTxOut(output.Value, pkScript, tokenData))
+	}
+
+	unsigned, err := txbuilder.Build(&txbuilder.Request{
+		Utxos:        utxos,
+		Outputs:      outputs,
+		ChangeScript: changeScript,
+		FeePerKb:     req.FeePerKb,
+	})
+	switch {
+	case err == nil:
+	case errors.Is(err, txbuilder.ErrInsufficientFunds), errors.Is(err, txbuilder.ErrInsufficientTokens):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "failed to build transaction: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := unsigned.Tx.Serialize(&buf); err != nil {
+		return nil, status.Error(codes.Internal, "error serializing transaction")
+	}
+	resp := &pb.BuildTransactionResponse{
+		Transaction: buf.Bytes(),
+		Fee:         unsigned.Fee,
+		ChangeIndex: int32(unsigned.ChangeIndex),
+		Size:        uint32(unsigned.Size),
+		SigChecks:   uint32(unsigned.SigChecks),
+	}
+	for _, utxo := range unsigned.Inputs {
+		resp.Inputs = append(resp.Inputs, marshalTxBuilderUtxo(utxo))
+	}
+	for _, idx := range unsigned.TokenChangeIndexes {
+		resp.TokenChangeIndexes = append(resp.TokenChangeIndexes, int32(idx))
+	}
+	return resp, nil
+}
@@ -0,0 +1,374 @@
+package bchrpc
+
+import (
+	"bytes"
+
+	"github.com/gcash/bchd/bchrpc/pb"
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// txNotificationSender is implemented by the streams of the transaction
+// subscription RPCs.
+type txNotificationSender interface {
+	Send(*pb.TransactionNotification) error
+}
+
+// txMatcher is implemented by the filters which select the transactions sent
+// to a transaction subscription.
+type txMatcher interface {
+	MatchAndUpdate(tx *bchutil.Tx, params *chaincfg.Params) bool
+}
+
+// reorgedTx is a transaction matching a transaction subscription which was
+// included in a block that has been disconnected from the main chain.
+type reorgedTx struct {
+	tx        *bchutil.Tx
+	blockHash chainhash.Hash
+}
+
+// reorgTracker tracks the transactions matching a transaction subscription
+// which were included in blocks that have been disconnected from the main
+// chain, until they are either confirmed again or double spent by a
+// transaction in a block connected to the main chain.  Transactions which are
+// neither, such as those which are evicted from the mempool, are tracked for
+// the lifetime of the subscription.
+//
+// It also keeps the blocks sent by a replay, so the notifications of the
+// blocks connected while the replay was running are not sent twice.
+type reorgTracker struct {
+	txs    map[chainhash.Hash]*reorgedTx
+	spends map[wire.OutPoint]chainhash.Hash
+
+	replayed       map[chainhash.Hash]struct{}
+	replayedHeight int32
+}
+
+// newReorgTracker returns a new empty reorg tracker.
+func newReorgTracker() *reorgTracker {
+	return &reorgTracker{
+		txs:    make(map[chainhash.Hash]*reorgedTx),
+		spends: make(map[wire.OutPoint]chainhash.Hash),
+	}
+}
+
+// add starts tracking the passed transaction of the disconnected block with the
+// passed hash.  Coinbase transactions are not tracked since they can not be
+// confirmed again.
+func (t *reorgTracker) add(tx *bchutil.Tx, blockHash *chainhash.Hash) {
+	if blockchain.IsCoinBase(tx) {
+		return
+	}
+	t.txs[*tx.Hash()] = &reorgedTx{tx: tx, blockHash: *blockHash}
+	for _, txIn := range tx.MsgTx().TxIn {
+		t.spends[txIn.PreviousOutPoint] = *tx.Hash()
+	}
+}
+
+// remove stops tracking the transaction with the passed hash and returns it, or
+// nil when it is not tracked.
+func (t *reorgTracker) remove(hash *chainhash.Hash) *reorgedTx {
+	rtx, ok := t.txs[*hash]
+	if !ok {
+		return nil
+	}
+	delete(t.txs, *hash)
+	for _, txIn := range rtx.tx.MsgTx().TxIn {
+		if t.spends[txIn.PreviousOutPoint] == *hash {
+			delete(t.spends, txIn.PreviousOutPoint)
+		}
+	}
+	return rtx
+}
+
+// connected updates the tracker for the passed transaction of a block connected
+// to the main chain.  It returns the tracked transactions which the transaction
+// double spends, including those which spend the outputs of a double spent
+// one.
+func (t *reorgTracker) connected(tx *bchutil.Tx) []*reorgedTx {
+	if len(t.txs) == 0 || blockchain.IsCoinBase(tx) {
+		return nil
+	}
+
+	// The transaction is confirmed again.
+	if t.remove(tx.Hash()) != nil {
+		return nil
+	}
+
+	var queue []chainhash.Hash
+	for _, txIn := range tx.MsgTx().TxIn {
+		if hash, ok := t.spends[txIn.PreviousOutPoint]; ok {
+			queue = append(queue, hash)
+		}
+	}
+
+	var doubleSpent []*reorgedTx
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+
+		rtx := t.remove(&hash)
+		if rtx == nil {
+			continue
+		}
+		doubleSpent = append(doubleSpent, rtx)
+
+		// Transactions spending the outputs of a double spent transaction
+		// can not be confirmed either.
+		for i := range rtx.tx.MsgTx().TxOut {
+			outpoint := wire.OutPoint{Hash: hash, Index: uint32(i)}
+			if spender, ok := t.spends[outpoint]; ok {
+				queue = append(queue, spender)
+			}
+		}
+	}
+	return doubleSpent
+}
+
+// skipConnected returns whether the notifications of the passed connected block
+// were already sent by a replay.
+func (t *reorgTracker) skipConnected(block *bchutil.Block) bool {
+	if t.replayed == nil {
+		return false
+	}
+	if _, ok := t.replayed[*block.Hash()]; ok {
+		delete(t.replayed, *block.Hash())
+		return true
+	}
+
+	// Every block connected after the replay has a greater height than the
+	// blocks it sent, unless the chain reorganized while it was running.
+	if block.Height() > t.replayedHeight {
+		t.replayed = nil
+	}
+	return false
+}
+
+// sendConnectedBlockNotifications sends the notifications for the transactions
+// of the passed block connected to the main chain.  Tracked transactions which
+// are double spent by one of them are sent as DOUBLE_SPENT notifications,
+// whether or not it matches the filter.
+func (s *GrpcServer) sendConnectedBlockNotifications(block *bchutil.Block, filter txMatcher,
+	tracker *reorgTracker, serializeTx bool, slpEventStr string, stream txNotificationSender) error {
+
+	for _, tx := range block.Transactions() {
+		for _, rtx := range tracker.connected(tx) {
+			toSend, err := s.reorgedTxNotification(rtx,
+				pb.TransactionNotification_DOUBLE_SPENT, serializeTx)
+			if err != nil {
+				return err
+			}
+			toSend.DoubleSpentBy = tx.Hash().CloneBytes()
+			if err := stream.Send(toSend); err != nil {
+				return err
+			}
+		}
+
+		if !filter.MatchAndUpdate(tx, s.chainParams) {
+			continue
+		}
+
+		if s.slpIndex != nil {
+			s.checkSlpTxOnEvent(tx.MsgTx(), slpEventStr)
+		}
+
+		toSend := &pb.TransactionNotification{}
+		toSend.Type = pb.TransactionNotification_CONFIRMED
+		toSend.BlockHash = block.Hash().CloneBytes()
+
+		if serializeTx {
+			var buf bytes.Buffer
+			if err := tx.MsgTx().BchEncode(&buf, wire.ProtocolVersion, wire.BaseEncoding); err != nil {
+				return status.Error(codes.Internal, "error serializing transaction")
+			}
+			toSend.Transaction = &pb.TransactionNotification_SerializedTransaction{
+				SerializedTransaction: buf.Bytes(),
+			}
+
+		} else {
+			header := block.MsgBlock().Header
+
+			respTx := marshalTransaction(tx, s.chain.BestSnapshot().Height-block.Height()+1, &header, block.Height(), s)
+			if s.txIndex != nil {
+				if err := s.setInputMetadata(respTx); err != nil {
+					return err
+				}
+			}
+			toSend.Transaction = &pb.TransactionNotification_ConfirmedTransaction{
+				ConfirmedTransaction: respTx,
+			}
+		}
+
+		if err := stream.Send(toSend); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendDisconnectedBlockNotifications sends the transactions of the passed block
+// disconnected from the main chain which match the filter as UNCONFIRMED_AGAIN
+// notifications and starts tracking them.
+func (s *GrpcServer) sendDisconnectedBlockNotifications(block *bchutil.Block, filter txMatcher,
+	tracker *reorgTracker, serializeTx bool, stream txNotificationSender) error {
+
+	for _, tx := range block.Transactions() {
+		if !filter.MatchAndUpdate(tx, s.chainParams) {
+			continue
+		}
+
+		rtx := &reorgedTx{tx: tx, blockHash: *block.Hash()}
+		tracker.add(tx, block.Hash())
+
+		toSend, err := s.reorgedTxNotification(rtx,
+			pb.TransactionNotification_UNCONFIRMED_AGAIN, serializeTx)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(toSend); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mempoolTxNotification returns an UNCONFIRMED notification for the passed
+// transaction accepted to the mempool.
+func (s *GrpcServer) mempoolTxNotification(txDesc *rpcEventTxAccepted, serializeTx bool) (*pb.TransactionNotification, error) {
+	toSend := &pb.TransactionNotification{}
+	toSend.Type = pb.TransactionNotification_UNCONFIRMED
+
+	if serializeTx {
+		var buf bytes.Buffer
+		if err := txDesc.Tx.MsgTx().BchEncode(&buf, wire.ProtocolVersion, wire.BaseEncoding); err != nil {
+			return nil, status.Error(codes.Internal, "error serializing transaction")
+		}
+
+		toSend.Transaction = &pb.TransactionNotification_SerializedTransaction{
+			SerializedTransaction: buf.Bytes(),
+		}
+
+	} else {
+		respTx := marshalTransaction(txDesc.Tx, 0, nil, 0, s)
+
+		if view, err := s.txMemPool.FetchInputUtxos(txDesc.Tx); err == nil {
+			s.setInputMetadataFromView(respTx, txDesc, view)
+		}
+
+		toSend.Transaction = &pb.TransactionNotification_UnconfirmedTransaction{
+			UnconfirmedTransaction: &pb.MempoolTransaction{
+				Transaction:      respTx,
+				AddedTime:        txDesc.Added.Unix(),
+				Fee:              txDesc.Fee,
+				FeePerKb:         txDesc.FeePerKB,
+				AddedHeight:      txDesc.Height,
+				StartingPriority: txDesc.StartingPriority,
+			},
+		}
+	}
+	return toSend, nil
+}
+
+// reorgedTxNotification returns a notification of the passed type for a
+// transaction of a block disconnected from the main chain.  The input metadata
+// is not set since the outputs it spends may no longer exist.
+func (s *GrpcServer) reorgedTxNotification(rtx *reorgedTx, notificationType pb.TransactionNotification_Type,
+	serializeTx bool) (*pb.TransactionNotification, error) {
+
+	toSend := &pb.TransactionNotification{
+		Type:      notificationType,
+		BlockHash: rtx.blockHash.CloneBytes(),
+	}
+
+	if serializeTx {
+		var buf bytes.Buffer
+		if err := rtx.tx.MsgTx().BchEncode(&buf, wire.ProtocolVersion, wire.BaseEncoding); err != nil {
+			return nil, status.Error(codes.Internal, "error serializing transaction")
+		}
+		toSend.Transaction = &pb.TransactionNotification_SerializedTransaction{
+			SerializedTransaction: buf.Bytes(),
+		}
+	} else {
+		toSend.Transaction = &pb.TransactionNotification_ConfirmedTransaction{
+			ConfirmedTransaction: marshalTransaction(rtx.tx, 0, nil, 0, s),
+		}
+	}
+	return toSend, nil
+}
+
+// replayTransactionNotifications sends the notifications for the blocks
+// connected to the main chain after the block with the passed hash.  When the
+// block is no longer in the main chain, the notifications for disconnecting the
+// blocks between it and the main chain are sent first.
+func (s *GrpcServer) replayTransactionNotifications(blockHash []byte, filter *txFilter,
+	tracker *reorgTracker, serializeTx bool, slpEventStr string, stream txNotificationSender) error {
+
+	hash, err := chainhash.NewHash(blockHash)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "invalid replay block hash")
+	}
+
+	// Find the blocks between the replay block and the main chain, starting
+	// with the replay block to disconnect them in the same order as a
+	// reorganization does.
+	forkHash := *hash
+	var orphaned []chainhash.Hash
+	for !s.chain.MainChainHasBlock(&forkHash) {
+		header, err := s.chain.HeaderByHash(&forkHash)
+		if err != nil {
+			return status.Error(codes.NotFound, "replay block not found")
+		}
+		orphaned = append(orphaned, forkHash)
+		forkHash = header.PrevBlock
+	}
+	forkHeight, err := s.chain.BlockHeightByHash(&forkHash)
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to find fork point: %v", err)
+	}
+
+	for i := range orphaned {
+		var blockBytes []byte
+		err := s.db.View(func(dbTx database.Tx) error {
+			var err error
+			blockBytes, err = dbTx.FetchBlock(&orphaned[i])
+			return err
+		})
+		if err != nil {
+			return status.Errorf(codes.Unavailable, "block %v is not available", orphaned[i])
+		}
+		block, err := bchutil.NewBlockFromBytes(blockBytes)
+		if err != nil {
+			return status.Errorf(codes.Internal, "failed to deserialize block: %v", err)
+		}
+		block.SetHeight(forkHeight + int32(len(orphaned)-i))
+
+		err = s.sendDisconnectedBlockNotifications(block, filter, tracker,
+			serializeTx, stream)
+		if err != nil {
+			return err
+		}
+	}
+
+	tracker.replayed = make(map[chainhash.Hash]struct{})
+	tracker.replayedHeight = s.chain.BestSnapshot().Height
+	for height := forkHeight + 1; height <= tracker.replayedHeight; height++ {
+		block, err := s.chain.BlockByHeight(height)
+		if err != nil {
+			return status.Errorf(codes.Unavailable, "block at height %d is not available", height)
+		}
+
+		err = s.sendConnectedBlockNotifications(block, filter, tracker,
+			serializeTx, slpEventStr, stream)
+		if err != nil {
+			return err
+		}
+		tracker.replayed[*block.Hash()] = struct{}{}
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+package bchrpc
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/gcash/bchd/bchrpc/pb"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/watchonly"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// watchOnlyError converts an error returned by the watch-only account manager
+// to a gRPC status error.
+func watchOnlyError(err error) error {
+	switch err {
+	case watchonly.ErrAccountNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case watchonly.ErrAccountExists:
+		return status.Error(codes.AlreadyExists, err.Error())
+	case watchonly.ErrInsufficientFunds:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// marshalWatchOnlyAccount converts a watch-only account to its protobuf
+// representation.
+func marshalWatchOnlyAccount(a *watchonly.Account) *pb.WatchOnlyAccount {
+	receive, change := a.Descriptors()
+	return &pb.WatchOnlyAccount{
+		Name:             a.Name(),
+		Xpub:             a.ExtendedPublicKey(),
+		GapLimit:         a.GapLimit(),
+		Descriptor_:      receive,
+		ChangeDescriptor: change,
+	}
+}
+
+// marshalWatchOnlyUnspentOutput converts an unspent output of a watch-only
+// account to its protobuf representation.
+func marshalWatchOnlyUnspentOutput(utxo *watchonly.UnspentOutput) *pb.WatchOnlyUnspentOutput {
+	out := &pb.WatchOnlyUnspentOutput{
+		Outpoint: &pb.Transaction_Input_Outpoint{
+			Hash:  utxo.OutPoint.Hash.CloneBytes(),
+			Index: utxo.OutPoint.Index,
+		},
+		PubkeyScript: utxo.PkScript,
+		Value:        utxo.Amount,
+		IsCoinbase:   utxo.IsCoinBase,
+		BlockHeight:  utxo.BlockHeight,
+		Address:      utxo.Address.String(),
+		Branch:       utxo.Branch,
+		Index:        utxo.Index,
+	}
+	if !utxo.TokenData.IsEmpty() {
+		out.CashToken = getPbCashTokenDataFromTokenData(utxo.TokenData)
+	}
+	return out
+}
+
+// unmarshalWatchOnlyOutputs converts the outputs to pay of a request to build a
+// transaction from a watch-only account.
+func (s *GrpcServer) unmarshalWatchOnlyOutputs(outputs []*pb.BuildWatchOnlyTransactionRequest_Output) ([]*wire.TxOut, error) {
+	txOuts := make([]*wire.TxOut, 0, len(outputs))
+	for _, output := range outputs {
+		addr, err := bchutil.DecodeAddress(output.Address, s.chainParams)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid address: %s", output.Address)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid address: %s", output.Address)
+		}
+		txOuts = append(txOuts, wire.NewTxOut(output.Value, pkScript, wire.TokenData{}))
+	}
+	return txOuts, nil
+}
+
+// ImportWatchOnlyAccount adds a watch-only account which derives its addresses
+// from an extended public key or from output script descriptors.
+//
+// **Requires WatchOnly and an authentication token to be configured**
+func (s *GrpcServer) ImportWatchOnlyAccount(ctx context.Context, req *pb.ImportWatchOnlyAccountRequest) (*pb.ImportWatchOnlyAccountResponse, error) {
+	if s.watchOnly == nil {
+		return nil, status.Error(codes.Unavailable, "watchonly required")
+	}
+	if !s.allowAdminMethods {
+		return nil, status.Error(codes.PermissionDenied, "importwatchonlyaccount requires an authentication token or client certificates to be configured")
+	}
+
+	var (
+		a   *watchonly.Account
+		err error
+	)
+	switch {
+	case req.Xpub != "" && req.Descriptor_ != "":
+		return nil, status.Error(codes.InvalidArgument, "only one of xpub and descriptor may be set")
+	case req.Xpub != "":
+		a, err = s.watchOnly.ImportAccount(req.Name, req.Xpub, req.GapLimit)
+	case req.Descriptor_ != "":
+		a, err = s.watchOnly.ImportDescriptorAccount(req.Name, req.Descriptor_, req.ChangeDescriptor, req.GapLimit)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "either xpub or descriptor must be set")
+	}
+	switch err {
+	case nil:
+	case watchonly.ErrAccountExists:
+		return nil, watchOnlyError(err)
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "invalid account: %v", err)
+	}
+	return &pb.ImportWatchOnlyAccountResponse{
+		Account: marshalWatchOnlyAccount(a),
+	}, nil
+}
+
+// RemoveWatchOnlyAccount removes a watch-only account.
+//
+// **Requires WatchOnly and an authentication token to be configured**
+func (s *GrpcServer) RemoveWatchOnlyAccount(ctx context.Context, req *pb.RemoveWatchOnlyAccountRequest) (*pb.RemoveWatchOnlyAccountResponse, error) {
+	if s.watchOnly == nil {
+		return nil, status.Error(codes.Unavailable, "watchonly required")
+	}
+	if !s.allowAdminMethods {
+		return nil, status.Error(codes.PermissionDenied, "removewatchonlyaccount requires an authentication token or client certificates to be configured")
+	}
+
+	if err := s.watchOnly.RemoveAccount(req.Name); err != nil {
+		return nil, watchOnlyError(err)
+	}
+	return &pb.RemoveWatchOnlyAccountResponse{}, nil
+}
+
+// ListWatchOnlyAccounts returns all of the watch-only accounts.
+//
+// **Requires WatchOnly**
+func (s *GrpcServer) ListWatchOnlyAccounts(ctx context.Context, req *pb.ListWatchOnlyAccountsRequest) (*pb.ListWatchOnlyAccountsResponse, error) {
+	if s.watchOnly == nil {
+		return nil, status.Error(codes.Unavailable, "watchonly required")
+	}
+
+	resp := &pb.ListWatchOnlyAccountsResponse{}
+	for _, a := range s.watchOnly.Accounts() {
+		resp.Accounts = append(resp.Accounts, marshalWatchOnlyAccount(a))
+	}
+	return resp, nil
+}
+
+// GetWatchOnlyAddress returns the next unused receive or change address of a
+// watch-only account.
+//
+// **Requires WatchOnly**
+func (s *GrpcServer) GetWatchOnlyAddress(ctx context.Context, req *pb.GetWatchOnlyAddressRequest) (*pb.GetWatchOnlyAddressResponse, error) {
+	if s.watchOnly == nil {
+		return nil, status.Error(codes.Unavailable, "watchonly required")
+	}
+
+	addr, err := s.watchOnly.NextAddress(req.Name, req.Change)
+	if err != nil {
+		return nil, watchOnlyError(err)
+	}
+	return &pb.GetWatchOnlyAddressResponse{Address: addr.String()}, nil
+}
+
+// GetWatchOnlyBalance returns the confirmed and unconfirmed balance of a
+// watch-only account.
+//
+// **Requires WatchOnly**
+func (s *GrpcServer) GetWatchOnlyBalance(ctx context.Context, req *pb.GetWatchOnlyBalanceRequest) (*pb.GetWatchOnlyBalanceResponse, error) {
+	if s.watchOnly == nil {
+		return nil, status.Error(codes.Unavailable, "watchonly required")
+	}
+
+	balance, err := s.watchOnly.Balance(req.Name)
+	if err != nil {
+		return nil, watchOnlyError(err)
+	}
+	return &pb.GetWatchOnlyBalanceResponse{
+		Confirmed:   balance.Confirmed,
+		Unconfirmed: balance.Unconfirmed,
+	}, nil
+}
+
+// GetWatchOnlyHistory returns the transactions of a watch-only account, most
+// recent first.
+//
+// **Requires WatchOnly**
+func (s *GrpcServer) GetWatchOnlyHistory(ctx context.Context, req *pb.GetWatchOnlyHistoryRequest) (*pb.GetWatchOnlyHistoryResponse, error) {
+	if s.watchOnly == nil {
+		return nil, status.Error(codes.Unavailable, "watchonly required")
+	}
+
+	history, err := s.watchOnly.History(req.Name)
+	if err != nil {
+		return nil, watchOnlyError(err)
+	}
+	if int(req.NbSkip) >= len(history) {
+		history = nil
+	} else {
+		history = history[req.NbSkip:]
+	}
+	if req.NbFetch > 0 && int(req.NbFetch) < len(history) {
+		history = history[:req.NbFetch]
+	}
+
+	resp := &pb.GetWatchOnlyHistoryResponse{}
+	for _, tx := range history {
+		wtx := &pb.WatchOnlyTransaction{
+			Hash:        tx.Hash.CloneBytes(),
+			BlockHeight: tx.BlockHeight,
+			Received:    tx.Received,
+			Sent:        tx.Sent,
+		}
+		if tx.BlockHash != nil {
+			wtx.BlockHash = tx.BlockHash.CloneBytes()
+		}
+		resp.Transactions = append(resp.Transactions, wtx)
+	}
+	return resp, nil
+}
+
+// GetWatchOnlyUnspentOutputs returns the unspent outputs of a watch-only
+// account.
+//
+// **Requires WatchOnly**
+func (s *GrpcServer) GetWatchOnlyUnspentOutputs(ctx context.Context, req *pb.GetWatchOnlyUnspentOutputsRequest) (*pb.GetWatchOnlyUnspentOutputsResponse, error) {
+	if s.watchOnly == nil {
+		return nil, status.Error(codes.Unavailable, "watchonly required")
+	}
+
+	utxos, err := s.watchOnly.UnspentOutputs(req.Name, req.IncludeMempool)
+	if err != nil {
+		return nil, watchOnlyError(err)
+	}
+
+	resp := &pb.GetWatchOnlyUnspentOutputsResponse{}
+	for _, utxo := range utxos {
+		resp.Outputs = append(resp.Outputs, marshalWatchOnlyUnspentOutput(utxo))
+	}
+	return resp, nil
+}
+
+// BuildWatchOnlyTransaction builds an unsigned transaction which pays the
+// requested outputs from the unspent outputs of a watch-only account.
+//
+// **Requires WatchOnly**
+func (s *GrpcServer) BuildWatchOnlyTransaction(ctx context.Context, req *pb.BuildWatchOnlyTransactionRequest) (*pb.BuildWatchOnlyTransactionResponse, error) {
+	if s.watchOnly == nil {
+		return nil, status.Error(codes.Unavailable, "watchonly required")
+	}
+
+	outputs, err := s.unmarshalWatchOnlyOutputs(req.Outputs)
+	if err != nil {
+		return nil, err
+	}
+
+	unsigned, err := s.watchOnly.BuildTransaction(req.Name, outputs, req.FeePerKb, req.MinConfirmations)
+	switch err {
+	case nil:
+	case watchonly.ErrAccountNotFound, watchonly.ErrInsufficientFunds:
+		return nil, watchOnlyError(err)
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "failed to build transaction: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := unsigned.Tx.Serialize(&buf); err != nil {
+		return nil, status.Error(codes.Internal, "error serializing transaction")
+	}
+	resp := &pb.BuildWatchOnlyTransactionResponse{
+		Transaction: buf.Bytes(),
+		Fee:         unsigned.Fee,
+		ChangeIndex: int32(unsigned.ChangeIndex),
+	}
+	for _, utxo := range unsigned.Inputs {
+		resp.Inputs = append(resp.Inputs, marshalWatchOnlyUnspentOutput(utxo))
+	}
+	return resp, nil
+}
+
+// ImportMultisigAccount adds a watch-only account whose addresses are P2SH
+// addresses of multisig scripts of the keys derived from the extended public
+// keys of the cosigners.
+//
+// **Requires WatchOnly and an authentication token to be configured**
+func (s *GrpcServer) ImportMultisigAccount(ctx context.Context, req *pb.ImportMultisigAccountRequest) (*pb.ImportMultisigAccountResponse, error) {
+	if s.watchOnly == nil {
+		return nil, status.Error(codes.Unavailable, "watchonly required")
+	}
+	if !s.allowAdminMethods {
+		return nil, status.Error(codes.PermissionDenied, "importmultisigaccount requires an authentication token or client certificates to be configured")
+	}
+
+	a, err := s.watchOnly.ImportMultisigAccount(req.Name, int(req.RequiredSignatures), req.Xpubs, req.GapLimit)
+	switch err {
+	case nil:
+	case watchonly.ErrAccountExists:
+		return nil, watchOnlyError(err)
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "invalid account: %v", err)
+	}
+	return &pb.ImportMultisigAccountResponse{
+		Account: marshalWatchOnlyAccount(a),
+	}, nil
+}
+
+// BuildWatchOnlyPst builds a partially signed transaction which pays the
+// requested outputs from the unspent outputs of a watch-only account.
+//
+// **Requires WatchOnly**
+func (s *GrpcServer) BuildWatchOnlyPst(ctx context.Context, req *pb.BuildWatchOnlyPstRequest) (*pb.BuildWatchOnlyPstResponse, error) {
+	if s.watchOnly == nil {
+		return nil, status.Error(codes.Unavailable, "watchonly required")
+	}
+
+	outputs, err := s.unmarshalWatchOnlyOutputs(req.Outputs)
+	if err != nil {
+		return nil, err
+	}
+
+	p, unsigned, err := s.watchOnly.BuildPst(req.Name, outputs, req.FeePerKb, req.MinConfirmations)
+	switch err {
+	case nil:
+	case watchonly.ErrAccountNotFound, watchonly.ErrInsufficientFunds:
+		return nil, watchOnlyError(err)
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "failed to build transaction: %v", err)
+	}
+
+	b, err := p.Bytes()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "error serializing partially signed transaction")
+	}
+	return &pb.BuildWatchOnlyPstResponse{
+		Pst:         b,
+		Fee:         unsigned.Fee,
+		ChangeIndex: int32(unsigned.ChangeIndex),
+	}, nil
+}
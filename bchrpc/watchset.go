@@ -0,0 +1,317 @@
+package bchrpc
+
+import (
+	"context"
+	"math"
+
+	"github.com/gcash/bchd/bchrpc/pb"
+	"github.com/gcash/bchd/blockchain/indexers"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxWatchSetScriptsPerRequest is the maximum number of scripts which
+	// can be added to or removed from a watch set by a single request.
+	maxWatchSetScriptsPerRequest = 100000
+
+	// maxWatchSetTransactions is the maximum number of transactions returned
+	// by a single call to GetWatchSetTransactions.
+	maxWatchSetTransactions = 10000
+
+	// watchSetRecentBlocks is the number of recently connected blocks a
+	// watch set subscription remembers the matching transactions of, so
+	// they can be notified again when the blocks are disconnected.
+	watchSetRecentBlocks = 100
+)
+
+// watchSetError converts an error returned by the watch set index to a gRPC
+// status error.
+func watchSetError(err error) error {
+	switch err {
+	case indexers.ErrWatchSetNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case indexers.ErrInvalidWatchSetName:
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// watchSetFilter selects the transactions of the blocks connected to and
+// disconnected from the main chain which match a watch set.  The matches of a
+// connected block are loaded from the watch set index, and the ones of the
+// most recent blocks are remembered for when they are disconnected since the
+// index no longer has them by then.
+type watchSetFilter struct {
+	idx  *indexers.WatchSetIndex
+	name string
+
+	txs    map[chainhash.Hash]struct{}
+	recent map[chainhash.Hash]map[chainhash.Hash]struct{}
+	order  []chainhash.Hash
+}
+
+// newWatchSetFilter returns a new filter for the named watch set.
+func newWatchSetFilter(idx *indexers.WatchSetIndex, name string) *watchSetFilter {
+	return &watchSetFilter{
+		idx:    idx,
+		name:   name,
+		recent: make(map[chainhash.Hash]map[chainhash.Hash]struct{}),
+	}
+}
+
+// connected loads the transactions of the passed block connected to the main
+// chain which match the watch set.
+func (f *watchSetFilter) connected(block *bchutil.Block) error {
+	matches, _, err := f.idx.WatchSetMatches(f.name, block.Height(),
+		block.Height(), math.MaxInt32)
+	if err != nil {
+		return err
+	}
+
+	f.txs = make(map[chainhash.Hash]struct{}, len(matches))
+	for _, match := range matches {
+		f.txs[match.TxHash] = struct{}{}
+	}
+
+	f.recent[*block.Hash()] = f.txs
+	f.order = append(f.order, *block.Hash())
+	if len(f.order) > watchSetRecentBlocks {
+		delete(f.recent, f.order[0])
+		f.order = f.order[1:]
+	}
+	return nil
+}
+
+// disconnected selects the transactions of the passed block disconnected from
+// the main chain which matched the watch set when it was connected.
+func (f *watchSetFilter) disconnected(block *bchutil.Block) {
+	f.txs = f.recent[*block.Hash()]
+	delete(f.recent, *block.Hash())
+}
+
+// MatchAndUpdate returns whether the transaction of the current block matches
+// the watch set.
+//
+// This is part of the txMatcher interface.
+func (f *watchSetFilter) MatchAndUpdate(tx *bchutil.Tx, params *chaincfg.Params) bool {
+	_, ok := f.txs[*tx.Hash()]
+	return ok
+}
+
+// AddWatchSetScripts adds output scripts to a persistent watch set, creating
+// the set when it does not exist.
+//
+// **Requires WatchSetIndex and an authentication token to be configured**
+func (s *GrpcServer) AddWatchSetScripts(ctx context.Context, req *pb.AddWatchSetScriptsRequest) (*pb.AddWatchSetScriptsResponse, error) {
+	if s.watchSetIndex == nil {
+		return nil, status.Error(codes.Unavailable, "watchsetindex required")
+	}
+	if !s.allowAdminMethods {
+		return nil, status.Error(codes.PermissionDenied, "addwatchsetscripts requires an authentication token or client certificates to be configured")
+	}
+	if len(req.Scripts) > maxWatchSetScriptsPerRequest {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d scripts can be added at once", maxWatchSetScriptsPerRequest)
+	}
+
+	count, err := s.watchSetIndex.AddScripts(req.Name, req.Scripts)
+	if err != nil {
+		return nil, watchSetError(err)
+	}
+	return &pb.AddWatchSetScriptsResponse{ScriptCount: count}, nil
+}
+
+// RemoveWatchSetScripts removes output scripts from a watch set.
+//
+// **Requires WatchSetIndex and an authentication token to be configured**
+func (s *GrpcServer) RemoveWatchSetScripts(ctx context.Context, req *pb.RemoveWatchSetScriptsRequest) (*pb.RemoveWatchSetScriptsResponse, error) {
+	if s.watchSetIndex == nil {
+		return nil, status.Error(codes.Unavailable, "watchsetindex required")
+	}
+	if !s.allowAdminMethods {
+		return nil, status.Error(codes.PermissionDenied, "removewatchsetscripts requires an authentication token or client certificates to be configured")
+	}
+	if len(req.Scripts) > maxWatchSetScriptsPerRequest {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d scripts can be removed at once", maxWatchSetScriptsPerRequest)
+	}
+
+	count, err := s.watchSetIndex.RemoveScripts(req.Name, req.Scripts)
+	if err != nil {
+		return nil, watchSetError(err)
+	}
+	return &pb.RemoveWatchSetScriptsResponse{ScriptCount: count}, nil
+}
+
+// DeleteWatchSet deletes a watch set along with its recorded transactions.
+//
+// **Requires WatchSetIndex and an authentication token to be configured**
+func (s *GrpcServer) DeleteWatchSet(ctx context.Context, req *pb.DeleteWatchSetRequest) (*pb.DeleteWatchSetResponse, error) {
+	if s.watchSetIndex == nil {
+		return nil, status.Error(codes.Unavailable, "watchsetindex required")
+	}
+	if !s.allowAdminMethods {
+		return nil, status.Error(codes.PermissionDenied, "deletewatchset requires an authentication token or client certificates to be configured")
+	}
+
+	if err := s.watchSetIndex.DeleteWatchSet(req.Name); err != nil {
+		return nil, watchSetError(err)
+	}
+	return &pb.DeleteWatchSetResponse{}, nil
+}
+
+// ListWatchSets returns all of the watch sets.
+//
+// **Requires WatchSetIndex**
+func (s *GrpcServer) ListWatchSets(ctx context.Context, req *pb.ListWatchSetsRequest) (*pb.ListWatchSetsResponse, error) {
+	if s.watchSetIndex == nil {
+		return nil, status.Error(codes.Unavailable, "watchsetindex required")
+	}
+
+	sets, err := s.watchSetIndex.WatchSets()
+	if err != nil {
+		return nil, watchSetError(err)
+	}
+	resp := &pb.ListWatchSetsResponse{
+		WatchSets: make([]*pb.WatchSet, 0, len(sets)),
+	}
+	for _, set := range sets {
+		resp.WatchSets = append(resp.WatchSets, &pb.WatchSet{
+			Name:        set.Name,
+			ScriptCount: set.Scripts,
+		})
+	}
+	return resp, nil
+}
+
+// GetWatchSetTransactions returns the confirmed transactions which pay to or
+// spend from the scripts of a watch set for a range of blocks.
+//
+// **Requires WatchSetIndex**
+func (s *GrpcServer) GetWatchSetTransactions(ctx context.Context, req *pb.GetWatchSetTransactionsRequest) (*pb.GetWatchSetTransactionsResponse, error) {
+	if s.watchSetIndex == nil {
+		return nil, status.Error(codes.Unavailable, "watchsetindex required")
+	}
+
+	endHeight, err := statsEndHeight(req.StartHeight, req.EndHeight,
+		s.chain.BestSnapshot().Height)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, more, err := s.watchSetIndex.WatchSetMatches(req.Name,
+		req.StartHeight, endHeight, maxWatchSetTransactions)
+	if err != nil {
+		return nil, watchSetError(err)
+	}
+
+	resp := &pb.GetWatchSetTransactionsResponse{
+		Transactions: make([]*pb.GetWatchSetTransactionsResponse_Transaction, 0, len(matches)),
+	}
+	for _, match := range matches {
+		resp.Transactions = append(resp.Transactions, &pb.GetWatchSetTransactionsResponse_Transaction{
+			Hash:        match.TxHash.CloneBytes(),
+			BlockHeight: match.Height,
+			Index:       match.TxIndex,
+		})
+	}
+	if more {
+		resp.NextHeight = matches[len(matches)-1].Height + 1
+	}
+	return resp, nil
+}
+
+// SubscribeWatchSet creates a subscription for the transactions paying to or
+// spending from the scripts of a watch set, both in the mempool and when they
+// are confirmed.
+//
+// Reorganizations are notified the same way as by SubscribeTransactions for
+// the most recent blocks.
+//
+// **Requires WatchSetIndex**
+func (s *GrpcServer) SubscribeWatchSet(req *pb.SubscribeWatchSetRequest, stream pb.Bchrpc_SubscribeWatchSetServer) error {
+	if s.watchSetIndex == nil {
+		return status.Error(codes.Unavailable, "watchsetindex required")
+	}
+
+	// Make sure the set exists before subscribing.
+	if _, err := s.watchSetIndex.MatchesScripts(req.Name, nil); err != nil {
+		return watchSetError(err)
+	}
+
+	subscription := s.subscribeEvents()
+	defer subscription.Unsubscribe()
+
+	filter := newWatchSetFilter(s.watchSetIndex, req.Name)
+	tracker := newReorgTracker()
+	for {
+		select {
+		case event := <-subscription.Events():
+
+			switch event := event.(type) {
+			case *rpcEventTxAccepted:
+				if !req.IncludeMempool {
+					continue
+				}
+
+				// Match the scripts of the outputs the transaction
+				// spends and creates.
+				var pkScripts [][]byte
+				if view, err := s.txMemPool.FetchInputUtxos(event.Tx); err == nil {
+					for _, txIn := range event.Tx.MsgTx().TxIn {
+						if entry := view.LookupEntry(txIn.PreviousOutPoint); entry != nil {
+							pkScripts = append(pkScripts, entry.PkScript())
+						}
+					}
+				}
+				for _, txOut := range event.Tx.MsgTx().TxOut {
+					pkScripts = append(pkScripts, txOut.PkScript)
+				}
+				matched, err := s.watchSetIndex.MatchesScripts(req.Name, pkScripts)
+				if err != nil {
+					return watchSetError(err)
+				}
+				if !matched {
+					continue
+				}
+
+				toSend, err := s.mempoolTxNotification(event, req.SerializeTx)
+				if err != nil {
+					return err
+				}
+				if err := stream.Send(toSend); err != nil {
+					return err
+				}
+
+			case *rpcEventBlockConnected:
+				if !req.IncludeInBlock {
+					continue
+				}
+				if err := filter.connected(event.Block); err != nil {
+					return watchSetError(err)
+				}
+				err := s.sendConnectedBlockNotifications(event.Block, filter,
+					tracker, req.SerializeTx, "SubscribeWatchSet rpcEventBlockConnected", stream)
+				if err != nil {
+					return err
+				}
+
+			case *rpcEventBlockDisconnected:
+				if !req.IncludeInBlock {
+					continue
+				}
+				filter.disconnected(event.Block)
+				err := s.sendDisconnectedBlockNotifications(event.Block, filter,
+					tracker, req.SerializeTx, stream)
+				if err != nil {
+					return err
+				}
+			}
+
+		case <-stream.Context().Done():
+			return nil // client disconnected
+		}
+	}
+}
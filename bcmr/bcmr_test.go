@@ -0,0 +1,254 @@
+package bcmr
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+)
+
+// publicationScript returns a script publishing a registry with the passed
+// contents at the passed URIs.
+func publicationScript(t *testing.T, contents []byte, uris ...string) []byte {
+	t.Helper()
+
+	hash := sha256.Sum256(contents)
+	builder := txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+		AddData(publicationTag).AddData(hash[:])
+	for _, uri := range uris {
+		builder.AddData([]byte(uri))
+	}
+	script, err := builder.Script()
+	if err != nil {
+		t.Fatalf("unable to build script: %v", err)
+	}
+	return script
+}
+
+// TestParsePublication ensures registry publications are recognized.
+func TestParsePublication(t *testing.T) {
+	contents := []byte("{}")
+	script := publicationScript(t, contents, "example.com/bcmr.json", "ipfs://cid")
+	pub, ok := ParsePublication(script)
+	if !ok {
+		t.Fatalf("publication not recognized")
+	}
+	if pub.ContentHash != sha256.Sum256(contents) {
+		t.Fatalf("unexpected content hash %x", pub.ContentHash)
+	}
+	if len(pub.URIs) != 2 || pub.URIs[0] != "example.com/bcmr.json" ||
+		pub.URIs[1] != "ipfs://cid" {
+
+		t.Fatalf("unexpected URIs %q", pub.URIs)
+	}
+
+	tests := []struct {
+		name   string
+		script []byte
+	}{
+		{"empty", nil},
+		{"not null data", script[1:]},
+		{"wrong tag", append([]byte{txscript.OP_RETURN, 0x04, 'B', 'C', 'M', 'X'}, script[6:]...)},
+		{"short hash", []byte{txscript.OP_RETURN, 0x04, 'B', 'C', 'M', 'R', 0x01, 0x00}},
+		{"no hash", []byte{txscript.OP_RETURN, 0x04, 'B', 'C', 'M', 'R'}},
+		{"not push only", append(append([]byte{}, script...), txscript.OP_DROP)},
+	}
+	for _, test := range tests {
+		if _, ok := ParsePublication(test.script); ok {
+			t.Errorf("%s: unexpected publication", test.name)
+		}
+	}
+}
+
+// TestSnapshot ensures the latest snapshot of an identity which took effect is
+// selected.
+func TestSnapshot(t *testing.T) {
+	authbase := chainhash.Hash{0x01}
+	registry, err := ParseRegistry([]byte(`{
+		"identities": {
+			"` + authbase.String() + `": {
+				"2023-01-01T00:00:00.000Z": {"name": "Old"},
+				"2024-01-01T00:00:00Z": {
+					"name": "Current",
+					"token": {"category": "` + authbase.String() + `", "symbol": "CUR", "decimals": 8}
+				},
+				"2099-01-01T00:00:00Z": {"name": "Future"},
+				"invalid": {"name": "Invalid"}
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseRegistry: %v", err)
+	}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	snapshot, err := registry.Snapshot(&authbase, now)
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if snapshot.Name != "Current" || snapshot.Token.Symbol != "CUR" ||
+		snapshot.Token.Decimals != 8 {
+
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+
+	if _, err := registry.Snapshot(&chainhash.Hash{0x02}, now); !errors.Is(err, ErrIdentityNotFound) {
+		t.Fatalf("unexpected error for unknown identity: %v", err)
+	}
+	past := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := registry.Snapshot(&authbase, past); !errors.Is(err, ErrIdentityNotFound) {
+		t.Fatalf("unexpected error before the first snapshot: %v", err)
+	}
+
+	// The token category of a snapshot must match its identity.
+	registry.Identities[authbase.String()]["2024-01-01T00:00:00Z"].Token.Category = "00"
+	if _, err := registry.Snapshot(&authbase, now); err == nil {
+		t.Fatalf("Snapshot: expected error for mismatched category")
+	}
+}
+
+// mockSource is a transaction source which serves a fixed set of
+// transactions.
+type mockSource struct {
+	txns     map[chainhash.Hash]*wire.MsgTx
+	spenders map[wire.OutPoint]*wire.MsgTx
+}
+
+func (s *mockSource) fetchTx(hash *chainhash.Hash) (*wire.MsgTx, error) {
+	return s.txns[*hash], nil
+}
+
+func (s *mockSource) fetchSpender(op wire.OutPoint, pkScript []byte) (*wire.MsgTx, error) {
+	return s.spenders[op], nil
+}
+
+// TestResolve ensures the metadata of a category is resolved from the
+// registry most recently published by its authchain.
+func TestResolve(t *testing.T) {
+	src := &mockSource{
+		txns:     make(map[chainhash.Hash]*wire.MsgTx),
+		spenders: make(map[wire.OutPoint]*wire.MsgTx),
+	}
+	var head *wire.MsgTx
+	extend := func(pkScripts ...[]byte) {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		if head != nil {
+			op := wire.OutPoint{Hash: head.TxHash(), Index: 0}
+			tx.AddTxIn(wire.NewTxIn(&op, nil))
+			src.spenders[op] = tx
+		} else {
+			tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil))
+		}
+		tx.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}, wire.TokenData{}))
+		for _, pkScript := range pkScripts {
+			tx.AddTxOut(wire.NewTxOut(0, pkScript, wire.TokenData{}))
+		}
+		src.txns[tx.TxHash()] = tx
+		head = tx
+	}
+
+	// The authbase is the first transaction of the authchain.
+	extend()
+	authbase := head.TxHash()
+	registry := func(name string) []byte {
+		return []byte(`{"identities": {"` + authbase.String() + `": {
+			"2020-01-01T00:00:00Z": {
+				"name": "` + name + `",
+				"token": {"category": "` + authbase.String() + `", "symbol": "TOK", "decimals": 2}
+			}
+		}}}`)
+	}
+	first, second := registry("First"), registry("Second")
+	contents := map[string][]byte{
+		"https://example.com/first.json":   first,
+		"https://mirror.example/second":    []byte("tampered"),
+		"https://example.com/second.json":  second,
+		"https://gateway.example/ipfs/cid": first,
+	}
+	var fetched []string
+	r := newResolver(src, nil, &Config{IPFSGateway: "https://gateway.example/ipfs/"})
+	r.fetch = func(ctx context.Context, url string) ([]byte, error) {
+		fetched = append(fetched, url)
+		b, ok := contents[url]
+		if !ok {
+			return nil, errors.New("not found")
+		}
+		return b, nil
+	}
+
+	if _, err := r.Resolve(context.Background(), &authbase); !errors.Is(err, ErrNoPublication) {
+		t.Fatalf("unexpected error without publication: %v", err)
+	}
+	if _, err := r.Resolve(context.Background(), &chainhash.Hash{0x01}); !errors.Is(err, ErrAuthbaseNotFound) {
+		t.Fatalf("unexpected error for unknown authbase: %v", err)
+	}
+
+	// Publish the first registry and then unrelated transactions.
+	extend(publicationScript(t, first, "ftp://example.com", "example.com/first.json"))
+	extend()
+	md, err := r.Resolve(context.Background(), &authbase)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if md.Name != "First" || !md.HasToken || md.Symbol != "TOK" ||
+		md.Decimals != 2 || md.AuthHead != head.TxHash() ||
+		md.RegistryURI != "https://example.com/first.json" {
+
+		t.Fatalf("unexpected metadata %+v", md)
+	}
+
+	// Cached metadata is returned until it expires, after which the
+	// authchain is followed from the previous authhead.  The registry
+	// which does not match its published hash is skipped.
+	extend(publicationScript(t, second, "https://mirror.example/second",
+		"https://example.com/second.json"))
+	if md, _ := r.Resolve(context.Background(), &authbase); md.Name != "First" {
+		t.Fatalf("cached metadata not returned")
+	}
+	r.metadata.Purge()
+	fetched = nil
+	md, err = r.Resolve(context.Background(), &authbase)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if md.Name != "Second" || md.AuthHead != head.TxHash() ||
+		md.RegistryURI != "https://example.com/second.json" {
+
+		t.Fatalf("unexpected metadata %+v", md)
+	}
+	if len(fetched) != 2 {
+		t.Fatalf("unexpected fetches %q", fetched)
+	}
+
+	// Registries are fetched from IPFS through the gateway and registries
+	// which have been fetched before are not fetched again.
+	extend(publicationScript(t, first, "ipfs://cid"))
+	r.metadata.Purge()
+	fetched = nil
+	md, err = r.Resolve(context.Background(), &authbase)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if md.Name != "First" || len(fetched) != 0 {
+		t.Fatalf("unexpected metadata %+v after fetching %q", md, fetched)
+	}
+	delete(r.authchains, authbase)
+	r.registries.Purge()
+	r.metadata.Purge()
+	if md, err := r.Resolve(context.Background(), &authbase); err != nil ||
+		md.RegistryURI != "https://gateway.example/ipfs/cid" {
+
+		t.Fatalf("unexpected metadata %+v, error %v", md, err)
+	}
+
+	// A registry which can not be fetched is reported.
+	extend(publicationScript(t, []byte("missing"), "example.com/missing"))
+	r.metadata.Purge()
+	if _, err := r.Resolve(context.Background(), &authbase); !errors.Is(err, ErrFetchFailed) {
+		t.Fatalf("unexpected error for missing registry: %v", err)
+	}
+}
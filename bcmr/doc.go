@@ -0,0 +1,24 @@
+/*
+Package bcmr implements a resolver for Bitcoin Cash Metadata Registries (BCMR),
+which describe the name, ticker symbol, decimals, and other metadata of
+CashToken categories.
+
+The metadata of a token category is published by the authchain of the category.
+The authchain starts at the authbase, which is the transaction whose hash is the
+category id, and follows the transactions spending the output with index 0 of
+each transaction in the chain.  The last transaction of the chain, whose output
+with index 0 is unspent, is the authhead.  A transaction of the authchain
+publishes a registry by including a null data output of the form:
+
+	OP_RETURN <'BCMR'> <sha256 of the registry> [<uri> ...]
+
+The registry published by the most recent transaction of the authchain is
+fetched from one of its URIs, validated against the committed hash, and the
+latest snapshot of the identity of the category is returned.  URIs without a
+scheme are fetched over HTTPS and IPFS URIs through a configurable gateway.
+
+Following authchains requires the transaction and address indexes.  The
+authchains and registries which have been resolved are cached, so only new
+transactions of an authchain need to be looked up again.
+*/
+package bcmr
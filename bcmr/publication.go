@@ -0,0 +1,58 @@
+package bcmr
+
+import (
+	"bytes"
+	"crypto/sha256"
+
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+)
+
+// publicationTag is the first push of the null data output which publishes a
+// registry.
+var publicationTag = []byte("BCMR")
+
+// Publication describes a registry published by a transaction of an authchain.
+type Publication struct {
+	// ContentHash is the sha256 hash of the registry.
+	ContentHash [sha256.Size]byte
+
+	// URIs are the locations the registry may be fetched from.
+	URIs []string
+}
+
+// ParsePublication returns the registry published by the passed output script
+// and whether the script is a registry publication.
+func ParsePublication(pkScript []byte) (*Publication, bool) {
+	if len(pkScript) == 0 || pkScript[0] != txscript.OP_RETURN ||
+		!txscript.IsPushOnlyScript(pkScript[1:]) {
+
+		return nil, false
+	}
+	pushes, err := txscript.PushedData(pkScript[1:])
+	if err != nil || len(pushes) < 2 ||
+		!bytes.Equal(pushes[0], publicationTag) ||
+		len(pushes[1]) != sha256.Size {
+
+		return nil, false
+	}
+
+	pub := &Publication{}
+	copy(pub.ContentHash[:], pushes[1])
+	for _, uri := range pushes[2:] {
+		pub.URIs = append(pub.URIs, string(uri))
+	}
+	return pub, true
+}
+
+// txPublication returns the last registry published by the outputs of the
+// passed transaction, or nil when it does not publish one.
+func txPublication(tx *wire.MsgTx) *Publication {
+	var pub *Publication
+	for _, txOut := range tx.TxOut {
+		if p, ok := ParsePublication(txOut.PkScript); ok {
+			pub = p
+		}
+	}
+	return pub
+}
@@ -0,0 +1,93 @@
+package bcmr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+)
+
+const (
+	// maxDecimals is the maximum number of decimals of the fungible tokens
+	// of a category.
+	maxDecimals = 18
+)
+
+var (
+	// ErrIdentityNotFound is returned when a registry does not describe
+	// the requested token category.
+	ErrIdentityNotFound = errors.New("identity not found in registry")
+)
+
+// Registry is the subset of a metadata registry which describes the
+// identities of token categories.
+type Registry struct {
+	// Identities maps the authbase of each identity to its snapshots
+	// keyed by the time they took effect.
+	Identities map[string]map[string]*IdentitySnapshot `json:"identities"`
+}
+
+// TokenInfo describes the tokens of an identity.
+type TokenInfo struct {
+	Category string `json:"category"`
+	Symbol   string `json:"symbol"`
+	Decimals int    `json:"decimals"`
+}
+
+// IdentitySnapshot describes an identity from the time the snapshot took
+// effect.
+type IdentitySnapshot struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Token       *TokenInfo        `json:"token"`
+	URIs        map[string]string `json:"uris"`
+}
+
+// ParseRegistry decodes the passed registry contents.
+func ParseRegistry(b []byte) (*Registry, error) {
+	var r Registry
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("invalid registry: %v", err)
+	}
+	return &r, nil
+}
+
+// Snapshot returns the latest snapshot of the identity with the passed
+// authbase which took effect at or before the passed time.  Snapshots with an
+// invalid timestamp are ignored.
+func (r *Registry) Snapshot(authbase *chainhash.Hash, now time.Time) (*IdentitySnapshot, error) {
+	snapshots, ok := r.Identities[authbase.String()]
+	if !ok {
+		return nil, ErrIdentityNotFound
+	}
+
+	var latest *IdentitySnapshot
+	var latestTime time.Time
+	for timestamp, snapshot := range snapshots {
+		t, err := time.Parse(time.RFC3339, timestamp)
+		if err != nil || snapshot == nil || t.After(now) {
+			continue
+		}
+		if latest == nil || t.After(latestTime) {
+			latest = snapshot
+			latestTime = t
+		}
+	}
+	if latest == nil {
+		return nil, ErrIdentityNotFound
+	}
+
+	if latest.Token != nil {
+		if latest.Token.Category != authbase.String() {
+			return nil, fmt.Errorf("token category %q of identity %v "+
+				"does not match", latest.Token.Category, authbase)
+		}
+		if latest.Token.Decimals < 0 || latest.Token.Decimals > maxDecimals {
+			return nil, fmt.Errorf("invalid token decimals %d",
+				latest.Token.Decimals)
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,502 @@
+package bcmr
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluele/gcache"
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/blockchain/indexers"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/mempool"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+)
+
+const (
+	// DefaultIPFSGateway is the gateway IPFS URIs are fetched through by
+	// default.
+	DefaultIPFSGateway = "https://ipfs.io/ipfs/"
+
+	// DefaultCacheSize is the default number of token categories whose
+	// metadata is cached.
+	DefaultCacheSize = 10000
+
+	// DefaultCacheTTL is the default duration the metadata of a token
+	// category is cached before its authchain is checked for a new
+	// publication.
+	DefaultCacheTTL = time.Hour
+
+	// maxRegistrySize is the maximum size of a registry which is fetched.
+	maxRegistrySize = 10 * 1024 * 1024
+
+	// maxAuthchainLength is the maximum number of transactions of an
+	// authchain which are followed.
+	maxAuthchainLength = 100000
+
+	// registryCacheSize is the number of registries which are cached.
+	registryCacheSize = 100
+
+	// fetchTimeout is the time allowed to fetch a registry from one URI.
+	fetchTimeout = 30 * time.Second
+
+	// addrTxFetchBatch is the number of transaction regions fetched from
+	// the address index at a time.
+	addrTxFetchBatch = 1000
+)
+
+var (
+	// ErrNoPublication is returned when the authchain of a token category
+	// has not published a registry.
+	ErrNoPublication = errors.New("authchain has no registry publication")
+
+	// ErrAuthbaseNotFound is returned when the authbase of a token
+	// category is not known.
+	ErrAuthbaseNotFound = errors.New("authbase transaction not found")
+
+	// ErrFetchFailed is returned when a published registry can not be
+	// fetched from any of its URIs.
+	ErrFetchFailed = errors.New("unable to fetch registry")
+)
+
+// Config is a descriptor containing the registry resolver configuration.
+type Config struct {
+	// ChainParams identifies which chain parameters the resolver is
+	// associated with.
+	ChainParams *chaincfg.Params
+
+	// DB is the database transactions are loaded from.
+	DB database.DB
+
+	// Chain is the block chain used to check whether the outputs of an
+	// authchain are spent.
+	Chain *blockchain.BlockChain
+
+	// TxIndex is the transaction index used to load the transactions of
+	// authchains.
+	TxIndex *indexers.TxIndex
+
+	// AddrIndex is the address index used to find the transactions which
+	// spend the outputs of authchains.
+	AddrIndex *indexers.AddrIndex
+
+	// TxMemPool is the memory pool used to follow authchains through
+	// unconfirmed transactions.
+	TxMemPool *mempool.TxPool
+
+	// Dial connects to the servers registries are fetched from.  It
+	// defaults to net.DialTimeout.
+	Dial func(network, addr string, timeout time.Duration) (net.Conn, error)
+
+	// IPFSGateway is the URL prefix IPFS content identifiers are appended
+	// to.  It defaults to DefaultIPFSGateway.
+	IPFSGateway string
+
+	// CacheSize is the number of token categories whose metadata is
+	// cached.  It defaults to DefaultCacheSize.
+	CacheSize int
+
+	// CacheTTL is the duration the metadata of a token category is cached.
+	// It defaults to DefaultCacheTTL.
+	CacheTTL time.Duration
+}
+
+// Metadata describes a token category as published by its authchain.
+type Metadata struct {
+	Category chainhash.Hash
+
+	// Name, Description, and URIs describe the identity of the category.
+	Name        string
+	Description string
+	URIs        map[string]string
+
+	// Symbol and Decimals describe the tokens of the category.  They are
+	// only valid when HasToken is true.
+	HasToken bool
+	Symbol   string
+	Decimals int
+
+	// AuthHead is the hash of the last transaction of the authchain.
+	AuthHead chainhash.Hash
+
+	// RegistryHash is the sha256 hash of the registry and RegistryURI the
+	// URI it was fetched from.
+	RegistryHash [sha256.Size]byte
+	RegistryURI  string
+}
+
+// txSource provides the transactions an authchain consists of.
+type txSource interface {
+	// fetchTx returns the transaction with the passed hash, or nil when
+	// it is not known.
+	fetchTx(hash *chainhash.Hash) (*wire.MsgTx, error)
+
+	// fetchSpender returns the transaction which spends the passed
+	// outpoint, which is locked by the passed script, or nil when it is
+	// unspent.
+	fetchSpender(op wire.OutPoint, pkScript []byte) (*wire.MsgTx, error)
+}
+
+// authchain is the progress made following the authchain of a category.
+type authchain struct {
+	head        chainhash.Hash
+	publication *Publication
+}
+
+// Resolver resolves the metadata of token categories from the registries
+// published by their authchains.
+type Resolver struct {
+	src         txSource
+	fetch       func(ctx context.Context, uri string) ([]byte, error)
+	ipfsGateway string
+
+	metadata   gcache.Cache
+	registries gcache.Cache
+
+	mtx        sync.Mutex
+	authchains map[chainhash.Hash]*authchain
+}
+
+// New returns a new registry resolver.
+func New(cfg *Config) (*Resolver, error) {
+	if cfg.TxIndex == nil || cfg.AddrIndex == nil {
+		return nil, errors.New("the metadata registry resolver requires " +
+			"the transaction and address indexes")
+	}
+
+	dial := cfg.Dial
+	if dial == nil {
+		dial = net.DialTimeout
+	}
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dial(network, addr, fetchTimeout)
+			},
+			TLSHandshakeTimeout: fetchTimeout,
+		},
+		Timeout: fetchTimeout,
+	}
+	return newResolver(&chainSource{cfg: *cfg}, client, cfg), nil
+}
+
+// newResolver returns a new registry resolver which follows authchains using
+// the passed source and fetches registries with the passed client.
+func newResolver(src txSource, client *http.Client, cfg *Config) *Resolver {
+	ipfsGateway := cfg.IPFSGateway
+	if ipfsGateway == "" {
+		ipfsGateway = DefaultIPFSGateway
+	}
+	cacheSize := cfg.CacheSize
+	if cacheSize <= 0 {
+		cacheSize = DefaultCacheSize
+	}
+	cacheTTL := cfg.CacheTTL
+	if cacheTTL <= 0 {
+		cacheTTL = DefaultCacheTTL
+	}
+
+	fetch := func(ctx context.Context, url string) ([]byte, error) {
+		return httpFetch(ctx, client, url)
+	}
+	return &Resolver{
+		src:         src,
+		fetch:       fetch,
+		ipfsGateway: ipfsGateway,
+		metadata:    gcache.New(cacheSize).LRU().Expiration(cacheTTL).Build(),
+		registries:  gcache.New(registryCacheSize).LRU().Build(),
+		authchains:  make(map[chainhash.Hash]*authchain),
+	}
+}
+
+// httpFetch returns the contents at the passed URL.
+func httpFetch(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxRegistrySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxRegistrySize {
+		return nil, errors.New("registry exceeds maximum size")
+	}
+	return b, nil
+}
+
+// resolveURI returns the URL a registry URI is fetched from, or false when
+// the URI is not supported.
+func (r *Resolver) resolveURI(uri string) (string, bool) {
+	switch {
+	case strings.HasPrefix(uri, "ipfs://"):
+		return r.ipfsGateway + strings.TrimPrefix(uri, "ipfs://"), true
+	case strings.HasPrefix(uri, "https://"), strings.HasPrefix(uri, "http://"):
+		return uri, true
+	case strings.Contains(uri, "://"):
+		return "", false
+	default:
+		return "https://" + uri, true
+	}
+}
+
+// followAuthchain returns the authchain of the passed category up to its
+// current authhead, continuing from the progress made by previous calls.
+func (r *Resolver) followAuthchain(category *chainhash.Hash) (*authchain, error) {
+	r.mtx.Lock()
+	prev := r.authchains[*category]
+	r.mtx.Unlock()
+
+	// Continue from the authhead found previously unless it is no longer
+	// known, which is the case when it was removed by a reorganization.
+	var tx *wire.MsgTx
+	chain := &authchain{}
+	if prev != nil {
+		var err error
+		tx, err = r.src.fetchTx(&prev.head)
+		if err != nil {
+			return nil, err
+		}
+		if tx != nil {
+			*chain = *prev
+		}
+	}
+	if tx == nil {
+		var err error
+		tx, err = r.src.fetchTx(category)
+		if err != nil {
+			return nil, err
+		}
+		if tx == nil {
+			return nil, ErrAuthbaseNotFound
+		}
+		chain.head = *category
+		chain.publication = txPublication(tx)
+	}
+
+	for i := 0; i < maxAuthchainLength; i++ {
+		if len(tx.TxOut) == 0 || txscript.IsUnspendable(tx.TxOut[0].PkScript) {
+			break
+		}
+		op := wire.OutPoint{Hash: chain.head, Index: 0}
+		spender, err := r.src.fetchSpender(op, tx.TxOut[0].PkScript)
+		if err != nil {
+			return nil, err
+		}
+		if spender == nil {
+			break
+		}
+		tx = spender
+		chain.head = tx.TxHash()
+		if pub := txPublication(tx); pub != nil {
+			chain.publication = pub
+		}
+	}
+
+	r.mtx.Lock()
+	r.authchains[*category] = chain
+	r.mtx.Unlock()
+	return chain, nil
+}
+
+// fetchRegistry returns the registry of the passed publication, which is
+// fetched from the first of its URIs serving contents matching its hash.
+func (r *Resolver) fetchRegistry(ctx context.Context, pub *Publication) (*Registry, string, error) {
+	type cachedRegistry struct {
+		registry *Registry
+		uri      string
+	}
+	if v, err := r.registries.Get(pub.ContentHash); err == nil {
+		cached := v.(*cachedRegistry)
+		return cached.registry, cached.uri, nil
+	}
+
+	for _, uri := range pub.URIs {
+		url, ok := r.resolveURI(uri)
+		if !ok {
+			continue
+		}
+		fetchCtx, cancel := context.WithTimeout(ctx, fetchTimeout)
+		b, err := r.fetch(fetchCtx, url)
+		cancel()
+		if err != nil {
+			log.Debugf("Unable to fetch registry from %s: %v", url, err)
+			continue
+		}
+		if hash := sha256.Sum256(b); !bytes.Equal(hash[:], pub.ContentHash[:]) {
+			log.Debugf("Registry fetched from %s does not match the "+
+				"published hash %x", url, pub.ContentHash)
+			continue
+		}
+		registry, err := ParseRegistry(b)
+		if err != nil {
+			return nil, "", err
+		}
+		r.registries.Set(pub.ContentHash, &cachedRegistry{registry, url})
+		return registry, url, nil
+	}
+	return nil, "", ErrFetchFailed
+}
+
+// Resolve returns the metadata of the passed token category from the registry
+// most recently published by its authchain.
+//
+// This function is safe for concurrent access.
+func (r *Resolver) Resolve(ctx context.Context, category *chainhash.Hash) (*Metadata, error) {
+	if v, err := r.metadata.Get(*category); err == nil {
+		return v.(*Metadata), nil
+	}
+
+	chain, err := r.followAuthchain(category)
+	if err != nil {
+		return nil, err
+	}
+	if chain.publication == nil {
+		return nil, ErrNoPublication
+	}
+	registry, uri, err := r.fetchRegistry(ctx, chain.publication)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := registry.Snapshot(category, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	md := &Metadata{
+		Category:     *category,
+		Name:         snapshot.Name,
+		Description:  snapshot.Description,
+		URIs:         snapshot.URIs,
+		AuthHead:     chain.head,
+		RegistryHash: chain.publication.ContentHash,
+		RegistryURI:  uri,
+	}
+	if snapshot.Token != nil {
+		md.HasToken = true
+		md.Symbol = snapshot.Token.Symbol
+		md.Decimals = snapshot.Token.Decimals
+	}
+	r.metadata.Set(*category, md)
+	return md, nil
+}
+
+// chainSource provides the transactions of authchains from the memory pool and
+// the transaction and address indexes.
+type chainSource struct {
+	cfg Config
+}
+
+// fetchTx returns the transaction with the passed hash, or nil when it is not
+// known.
+//
+// This is part of the txSource interface.
+func (s *chainSource) fetchTx(hash *chainhash.Hash) (*wire.MsgTx, error) {
+	if s.cfg.TxMemPool != nil {
+		if tx, err := s.cfg.TxMemPool.FetchTransaction(hash); err == nil {
+			return tx.MsgTx(), nil
+		}
+	}
+
+	region, err := s.cfg.TxIndex.TxBlockRegion(hash)
+	if err != nil || region == nil {
+		return nil, err
+	}
+	var msgTx *wire.MsgTx
+	err = s.cfg.DB.View(func(dbTx database.Tx) error {
+		b, err := dbTx.FetchBlockRegion(region)
+		if err != nil {
+			return err
+		}
+		msgTx = &wire.MsgTx{}
+		return msgTx.Deserialize(bytes.NewReader(b))
+	})
+	return msgTx, err
+}
+
+// fetchSpender returns the transaction which spends the passed outpoint, or
+// nil when it is unspent.  Confirmed spenders are found among the transactions
+// of the addresses the passed script pays to.
+//
+// This is part of the txSource interface.
+func (s *chainSource) fetchSpender(op wire.OutPoint, pkScript []byte) (*wire.MsgTx, error) {
+	if s.cfg.TxMemPool != nil {
+		if tx := s.cfg.TxMemPool.CheckSpend(op); tx != nil {
+			return tx.MsgTx(), nil
+		}
+	}
+	entry, err := s.cfg.Chain.FetchUtxoEntry(op)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil && !entry.IsSpent() {
+		return nil, nil
+	}
+
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, s.cfg.ChainParams)
+	if err != nil || len(addrs) == 0 {
+		return nil, fmt.Errorf("unable to find the spender of %v which "+
+			"pays to no address", op)
+	}
+	var spender *wire.MsgTx
+	err = s.cfg.DB.View(func(dbTx database.Tx) error {
+		for _, addr := range addrs {
+			var skip uint32
+			for {
+				regions, _, err := s.cfg.AddrIndex.TxRegionsForAddress(
+					dbTx, addr, skip, addrTxFetchBatch, false)
+				if err != nil {
+					return err
+				}
+				serializedTxns, err := dbTx.FetchBlockRegions(regions)
+				if err != nil {
+					return err
+				}
+				for _, serializedTx := range serializedTxns {
+					var msgTx wire.MsgTx
+					err := msgTx.Deserialize(bytes.NewReader(serializedTx))
+					if err != nil {
+						return err
+					}
+					for _, txIn := range msgTx.TxIn {
+						if txIn.PreviousOutPoint == op {
+							spender = &msgTx
+							return nil
+						}
+					}
+				}
+				if len(regions) < addrTxFetchBatch {
+					break
+				}
+				skip += uint32(len(regions))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if spender == nil {
+		return nil, fmt.Errorf("unable to find the spender of %v", op)
+	}
+	return spender, nil
+}
@@ -0,0 +1,102 @@
+package blockchain
+
+import (
+	"errors"
+
+	"github.com/gcash/bchd/chaincfg"
+)
+
+// NewABLAConfig returns the configuration of the adaptive block size limit
+// algorithm for the passed constants.  The maximum control block size and
+// elastic buffer size are derived such that the arithmetic of the algorithm
+// can't overflow.
+func NewABLAConfig(constants *chaincfg.ABLAConstants) (*ABLAConfig, error) {
+	config := &ABLAConfig{
+		epsilon0:        constants.Epsilon0,
+		beta0:           constants.Beta0,
+		n0:              constants.N0,
+		gammaReciprocal: constants.GammaReciprocal,
+		zetaXB7:         constants.ZetaXB7,
+		thetaReciprocal: constants.ThetaReciprocal,
+		delta:           constants.Delta,
+		fixedSize:       constants.FixedSize,
+	}
+	config.SetMax()
+	if errs := config.IsValid(); errs != nil {
+		return nil, errors.New(errs.String())
+	}
+	return config, nil
+}
+
+// NewABLAState returns the state of the adaptive block size limit algorithm
+// which applies to the block at the passed height.
+func NewABLAState(blockHeight, controlBlockSize, elasticBufferSize uint64) ABLAState {
+	return ABLAState{
+		blockHeight:       blockHeight,
+		controlBlockSize:  controlBlockSize,
+		elasticBufferSize: elasticBufferSize,
+	}
+}
+
+// BlockHeight returns the height of the block the state applies to.
+func (state *ABLAState) BlockHeight() uint64 {
+	return state.blockHeight
+}
+
+// ControlBlockSize returns the control function state, which is the block size
+// the algorithm converges to and the floor of the limit.
+func (state *ABLAState) ControlBlockSize() uint64 {
+	return state.controlBlockSize
+}
+
+// ElasticBufferSize returns the elastic buffer function state, which is the
+// room for blocks larger than the control block size.
+func (state *ABLAState) ElasticBufferSize() uint64 {
+	return state.elasticBufferSize
+}
+
+// BlockSizeLimit returns the maximum size of the block the state applies to.
+func (state *ABLAState) BlockSizeLimit() uint64 {
+	return state.getBlockSizeLimit()
+}
+
+// NextState returns the state which applies to the block after the one the
+// state applies to, given the size of that block.  Sizes above the limit are
+// clamped to the limit.
+func (state *ABLAState) NextState(config *ABLAConfig, blockSize uint64) ABLAState {
+	return state.nextABLAState(config, blockSize)
+}
+
+// ABLAState returns the state of the adaptive block size limit algorithm which
+// applies to the block building on the tip of the main chain.  The algorithm
+// only limits the size of blocks once the ABLA upgrade is active, but its state
+// is tracked for every block.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ABLAState() ABLAState {
+	b.chainLock.RLock()
+	state := b.ablaState
+	b.chainLock.RUnlock()
+	return state
+}
+
+// SimulateABLA projects the state of the adaptive block size limit algorithm
+// forward from the state which applies to the block building on the tip of the
+// main chain, assuming the following blocks have the passed sizes.  The state
+// at index i of the returned slice applies to the block after the block with
+// the size at index i.  Sizes above the limit of their block are clamped to the
+// limit, just like for the blocks of the chain.
+//
+// This allows capacity planners to model how the block size limit responds to
+// the growth of the network.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) SimulateABLA(blockSizes []uint64) []ABLAState {
+	state := b.ABLAState()
+	states := make([]ABLAState, 0, len(blockSizes))
+	for _, blockSize := range blockSizes {
+		state = state.nextABLAState(&b.ablaConfig, blockSize)
+		states = append(states, state)
+	}
+	return states
+}
@@ -0,0 +1,63 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/gcash/bchd/chaincfg"
+)
+
+// TestSimulateABLA ensures the adaptive block size limit state is projected
+// forward from the state of the chain without modifying it.
+func TestSimulateABLA(t *testing.T) {
+	// The regression test network has a fixed block size limit.
+	params := chaincfg.MainNetParams
+	chain, teardownFunc, err := chainSetup("simulateabla", &params)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	state := chain.ABLAState()
+	if limit := state.BlockSizeLimit(); limit != state.ControlBlockSize()+
+		state.ElasticBufferSize() {
+
+		t.Fatalf("BlockSizeLimit: got %d, want %d", limit,
+			state.ControlBlockSize()+state.ElasticBufferSize())
+	}
+
+	// Full blocks grow the limit just like the lookahead of the algorithm,
+	// and blocks larger than the limit are clamped to it.
+	const numBlocks = 10
+	fullSizes := make([]uint64, numBlocks)
+	for i := range fullSizes {
+		fullSizes[i] = ^uint64(0)
+	}
+	states := chain.SimulateABLA(fullSizes)
+	if len(states) != numBlocks {
+		t.Fatalf("SimulateABLA: got %d states, want %d", len(states),
+			numBlocks)
+	}
+	want := state.lookaheadState(&chain.ablaConfig, numBlocks)
+	if states[numBlocks-1] != want {
+		t.Fatalf("SimulateABLA: got state %+v for full blocks, want %+v",
+			states[numBlocks-1], want)
+	}
+	if states[numBlocks-1].BlockSizeLimit() <= state.BlockSizeLimit() {
+		t.Fatal("SimulateABLA: limit did not grow for full blocks")
+	}
+
+	// Empty blocks never push the limit below its initial value.
+	states = chain.SimulateABLA(make([]uint64, numBlocks))
+	for i, s := range states {
+		if s.ControlBlockSize() < chain.ablaConfig.epsilon0 ||
+			s.ElasticBufferSize() < chain.ablaConfig.beta0 {
+
+			t.Fatalf("SimulateABLA: state %d %+v below initial state", i,
+				s)
+		}
+	}
+
+	if chain.ABLAState() != state {
+		t.Fatal("SimulateABLA: state of the chain was modified")
+	}
+}
@@ -38,7 +38,9 @@ func (b *BlockChain) maybeAcceptBlock(block *bchutil.Block, prevHash *chainhash.
 
 	// The block must pass all of the validation rules which depend on the
 	// position of the block within the block chain.
+	endSpan := b.startSpan("checkBlockContext")
 	err := b.checkBlockContext(block, prevNode, flags)
+	endSpan(err)
 	if err != nil {
 		return false, err
 	}
@@ -52,9 +54,11 @@ func (b *BlockChain) maybeAcceptBlock(block *bchutil.Block, prevHash *chainhash.
 	// expensive connection logic.  It also has some other nice properties
 	// such as making blocks that never become part of the main chain or
 	// blocks that fail to connect available for further analysis.
+	endSpan = b.startSpan("dbStoreBlock")
 	err = b.db.Update(func(dbTx database.Tx) error {
 		return dbStoreBlock(dbTx, block)
 	})
+	endSpan(err)
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,148 @@
+// Copyright (c) 2018-2020 The bchd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/wire"
+)
+
+// maxAssumeValidHeaders is the maximum number of headers leading to the block
+// which is assumed to be valid that are tracked.  It bounds the memory a peer
+// is able to waste by sending an endless chain of headers.
+const maxAssumeValidHeaders = 1 << 20
+
+// assumeValidState houses the hashes of a chain of block headers which ends
+// at the block that is assumed to be valid.  It allows skipping the script
+// validation of the blocks leading to that block before its header is known
+// to the block index.
+type assumeValidState struct {
+	// hash is the hash of the block which is assumed to be valid.
+	hash chainhash.Hash
+
+	// startHeight is the height of the first block in hashes.
+	startHeight int32
+
+	// hashes are the hashes of the received headers in order.  The
+	// first header extends a block of the main chain and every other
+	// header extends the previous one.
+	hashes []chainhash.Hash
+
+	// complete is set once the header of the assumed valid block is the
+	// final one in hashes.
+	complete bool
+}
+
+// AssumeValidPending returns the hash of the block which is assumed to be
+// valid when the headers leading to it are still required to skip the script
+// validation of its ancestors.  It returns nil when no block is assumed to be
+// valid, the block is already known, or its headers were already processed.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) AssumeValidPending() *chainhash.Hash {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	if b.assumeValid == nil || b.assumeValid.complete ||
+		b.index.LookupNode(&b.assumeValid.hash) != nil {
+
+		return nil
+	}
+	hash := b.assumeValid.hash
+	return &hash
+}
+
+// ProcessAssumeValidHeaders processes a batch of headers leading to the block
+// which is assumed to be valid.  A header which extends a block of the main
+// chain starts a new header chain, while any other header must extend the one
+// which was processed before it.  Every header must pass the sanity checks,
+// including the proof of work.
+//
+// It returns whether or not the header of the assumed valid block was
+// reached, in which case the script validation of the blocks leading to it is
+// skipped when they are connected.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ProcessAssumeValidHeaders(headers []*wire.BlockHeader) (bool, error) {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	state := b.assumeValid
+	if state == nil {
+		return false, AssertError("no block is assumed to be valid")
+	}
+	if state.complete {
+		return true, nil
+	}
+
+	for _, header := range headers {
+		// Start a new chain of headers when the header extends the
+		// main chain.  Otherwise it must extend the previous header.
+		numHashes := len(state.hashes)
+		prevNode := b.index.LookupNode(&header.PrevBlock)
+		switch {
+		case prevNode != nil && b.bestChain.Contains(prevNode):
+			state.startHeight = prevNode.height + 1
+			state.hashes = state.hashes[:0]
+
+		case numHashes == 0 || state.hashes[numHashes-1] != header.PrevBlock:
+			str := fmt.Sprintf("header %v does not connect to the "+
+				"previous header %v", header.BlockHash(),
+				header.PrevBlock)
+			return false, ruleError(ErrPreviousBlockUnknown, str)
+
+		case numHashes >= maxAssumeValidHeaders:
+			return false, fmt.Errorf("more than %d headers lead "+
+				"to the assumed valid block %v",
+				maxAssumeValidHeaders, state.hash)
+		}
+
+		err := checkBlockHeaderSanity(header, b.chainParams.PowLimit,
+			b.timeSource, BFNone)
+		if err != nil {
+			return false, err
+		}
+
+		hash := header.BlockHash()
+		state.hashes = append(state.hashes, hash)
+		if hash == state.hash {
+			state.complete = true
+			log.Infof("Skipping script validation of the %d blocks "+
+				"leading to assumed valid block %v (height %d)",
+				len(state.hashes)-1, hash,
+				state.startHeight+int32(len(state.hashes))-1)
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// isAssumedValid returns whether or not the passed block node is the block
+// which is assumed to be valid or one of its ancestors, in which case the
+// scripts of the block do not need to be validated.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) isAssumedValid(node *blockNode) bool {
+	state := b.assumeValid
+	if state == nil {
+		return false
+	}
+
+	// Use the block index once it contains the assumed valid block.
+	if avNode := b.index.LookupNode(&state.hash); avNode != nil {
+		return !b.index.NodeStatus(avNode).KnownInvalid() &&
+			avNode.Ancestor(node.height) == node
+	}
+
+	if !state.complete {
+		return false
+	}
+	offset := node.height - state.startHeight
+	return offset >= 0 && offset < int32(len(state.hashes)) &&
+		state.hashes[offset] == node.hash
+}
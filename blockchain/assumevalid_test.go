@@ -0,0 +1,130 @@
+// Copyright (c) 2018-2020 The bchd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/wire"
+)
+
+// TestAssumeValid ensures the headers leading to the block which is assumed to
+// be valid are processed as expected and that only the blocks leading to it
+// are treated as assumed valid.
+func TestAssumeValid(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	chain := newFakeChain(&params)
+	now := time.Unix(time.Now().Unix(), 0)
+	tip := chain.bestChain.Tip()
+	for i := 1; i <= 3; i++ {
+		tip = newFakeNode(tip, 4, params.PowLimitBits,
+			now.Add(time.Duration(i-10)*time.Minute))
+		chain.index.AddNode(tip)
+		chain.bestChain.SetTip(tip)
+	}
+
+	// Create a chain of headers extending the tip which satisfy the proof
+	// of work along with the nodes for them.
+	var headers []*wire.BlockHeader
+	var nodes []*blockNode
+	prevNode := tip
+	for i := 0; i < 6; i++ {
+		header := &wire.BlockHeader{
+			Version:   4,
+			PrevBlock: prevNode.hash,
+			Bits:      params.PowLimitBits,
+			Timestamp: now,
+		}
+		for checkProofOfWork(header, params.PowLimit, BFNone) != nil {
+			header.Nonce++
+		}
+		prevNode = newBlockNode(header, prevNode)
+		headers = append(headers, header)
+		nodes = append(nodes, prevNode)
+	}
+	assumeValid := nodes[4].hash
+	chain.assumeValid = &assumeValidState{hash: assumeValid}
+
+	if hash := chain.AssumeValidPending(); hash == nil || *hash != assumeValid {
+		t.Fatalf("AssumeValidPending: got %v, want %v", hash, assumeValid)
+	}
+
+	// Headers which do not connect must be rejected.
+	_, err := chain.ProcessAssumeValidHeaders(headers[1:])
+	if rerr, ok := err.(RuleError); !ok || rerr.ErrorCode != ErrPreviousBlockUnknown {
+		t.Fatalf("ProcessAssumeValidHeaders: got error %v, want %v",
+			err, ErrPreviousBlockUnknown)
+	}
+
+	// Headers which don't satisfy the proof of work must be rejected.
+	badHeader := *headers[0]
+	for checkProofOfWork(&badHeader, params.PowLimit, BFNone) == nil {
+		badHeader.Nonce++
+	}
+	_, err = chain.ProcessAssumeValidHeaders([]*wire.BlockHeader{&badHeader})
+	if rerr, ok := err.(RuleError); !ok || rerr.ErrorCode != ErrHighHash {
+		t.Fatalf("ProcessAssumeValidHeaders: got error %v, want %v",
+			err, ErrHighHash)
+	}
+
+	// No block is assumed valid before the header chain is complete.
+	reached, err := chain.ProcessAssumeValidHeaders(headers[:2])
+	if err != nil || reached {
+		t.Fatalf("ProcessAssumeValidHeaders: got (%v, %v), want "+
+			"(false, nil)", reached, err)
+	}
+	if chain.isAssumedValid(nodes[0]) {
+		t.Fatal("isAssumedValid: block assumed valid before the header " +
+			"chain is complete")
+	}
+
+	reached, err = chain.ProcessAssumeValidHeaders(headers[2:])
+	if err != nil || !reached {
+		t.Fatalf("ProcessAssumeValidHeaders: got (%v, %v), want "+
+			"(true, nil)", reached, err)
+	}
+	if hash := chain.AssumeValidPending(); hash != nil {
+		t.Fatalf("AssumeValidPending: got %v, want nil", hash)
+	}
+
+	// Only the assumed valid block and its ancestors are assumed valid,
+	// both before and after the assumed valid block is added to the block
+	// index.
+	sibling := newFakeNode(nodes[0], 4, params.PowLimitBits,
+		now.Add(time.Second))
+	tests := []struct {
+		name string
+		node *blockNode
+		want bool
+	}{
+		{"first header", nodes[0], true},
+		{"assumed valid block", nodes[4], true},
+		{"descendant", nodes[5], false},
+		{"sibling", sibling, false},
+	}
+	for _, test := range tests {
+		if got := chain.isAssumedValid(test.node); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	for _, node := range nodes {
+		chain.index.AddNode(node)
+	}
+	for _, test := range tests {
+		if got := chain.isAssumedValid(test.node); got != test.want {
+			t.Errorf("%s (indexed): got %v, want %v", test.name, got,
+				test.want)
+		}
+	}
+
+	// Nothing is assumed valid once the block is known to be invalid.
+	chain.index.SetStatusFlags(nodes[4], statusValidateFailed)
+	if chain.isAssumedValid(nodes[0]) {
+		t.Fatal("isAssumedValid: ancestor of invalid block assumed valid")
+	}
+}
@@ -7,6 +7,7 @@ package blockchain
 import (
 	"testing"
 
+	"github.com/gcash/bchd/wire"
 	"github.com/gcash/bchutil"
 )
 
@@ -29,3 +30,20 @@ func BenchmarkIsCoinBaseTx(b *testing.B) {
 		IsCoinBaseTx(tx)
 	}
 }
+
+// BenchmarkBuildMerkleTreeStore benchmarks building the merkle tree of a block
+// with as many transactions as a full 32MB block of small transactions, whose
+// hashes are already cached.
+func BenchmarkBuildMerkleTreeStore(b *testing.B) {
+	txns := make([]*bchutil.Tx, 150000)
+	for i := range txns {
+		msgTx := wire.NewMsgTx(wire.TxVersion)
+		msgTx.LockTime = uint32(i)
+		txns[i] = bchutil.NewTx(msgTx)
+		txns[i].Hash()
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		BuildMerkleTreeStore(txns)
+	}
+}
@@ -81,24 +81,30 @@ type blockNode struct {
 	// this node.
 	workSum *big.Int
 
+	// header is the full header of the block until the node is written to
+	// the database.  Afterwards it is nil and the header is loaded from the
+	// database on demand, which keeps the index small for chains with
+	// millions of headers.  It must only be accessed using the
+	// concurrent-safe Header method on blockIndex once the node has been
+	// added to the global index.
+	header *wire.BlockHeader
+
 	// height is the position in the block chain.
 	height int32
 
-	// Some fields from block headers to aid in best chain selection and
-	// reconstructing headers from memory.  These must be treated as
-	// immutable and are intentionally ordered to avoid padding on 64-bit
-	// platforms.
-	version    int32
-	bits       uint32
-	nonce      uint32
-	timestamp  int64
-	merkleRoot chainhash.Hash
-
 	// status is a bitfield representing the validation state of the block. The
 	// status field, unlike the other fields, may be written to and so should
 	// only be accessed using the concurrent-safe NodeStatus method on
 	// blockIndex once the node has been added to the global index.
 	status blockStatus
+
+	// Some fields from block headers to aid in best chain selection and
+	// validation.  These must be treated as immutable and are intentionally
+	// ordered to avoid padding on 64-bit platforms.  The remaining fields
+	// of the header are only kept in the database, see header.
+	version   int32
+	bits      uint32
+	timestamp int64
 }
 
 // initBlockNode initializes a block node from the given header and parent node,
@@ -106,14 +112,14 @@ type blockNode struct {
 // This function is NOT safe for concurrent access.  It must only be called when
 // initially creating a node.
 func initBlockNode(node *blockNode, blockHeader *wire.BlockHeader, parent *blockNode) {
+	header := *blockHeader
 	*node = blockNode{
-		hash:       blockHeader.BlockHash(),
-		workSum:    CalcWork(blockHeader.Bits),
-		version:    blockHeader.Version,
-		bits:       blockHeader.Bits,
-		nonce:      blockHeader.Nonce,
-		timestamp:  blockHeader.Timestamp.Unix(),
-		merkleRoot: blockHeader.MerkleRoot,
+		hash:      blockHeader.BlockHash(),
+		workSum:   CalcWork(blockHeader.Bits),
+		version:   blockHeader.Version,
+		bits:      blockHeader.Bits,
+		timestamp: blockHeader.Timestamp.Unix(),
+		header:    &header,
 	}
 	if parent != nil {
 		node.parent = parent
@@ -131,25 +137,6 @@ func newBlockNode(blockHeader *wire.BlockHeader, parent *blockNode) *blockNode {
 	return &node
 }
 
-// Header constructs a block header from the node and returns it.
-//
-// This function is safe for concurrent access.
-func (node *blockNode) Header() wire.BlockHeader {
-	// No lock is needed because all accessed fields are immutable.
-	prevHash := &zeroHash
-	if node.parent != nil {
-		prevHash = &node.parent.hash
-	}
-	return wire.BlockHeader{
-		Version:    node.version,
-		PrevBlock:  *prevHash,
-		MerkleRoot: node.merkleRoot,
-		Timestamp:  time.Unix(node.timestamp, 0),
-		Bits:       node.bits,
-		Nonce:      node.nonce,
-	}
-}
-
 // Ancestor returns the ancestor block node at the provided height by following
 // the chain backwards from this node.  The returned block will be nil when a
 // height is requested that is after the height of the passed node or is less
@@ -286,6 +273,86 @@ func (bi *blockIndex) addNode(node *blockNode) {
 	bi.index[node.hash] = node
 }
 
+// Descendants returns all of the block nodes in the index which descend from
+// the provided node, regardless of whether they are part of the main chain.
+//
+// This function is safe for concurrent access.
+func (bi *blockIndex) Descendants(node *blockNode) []*blockNode {
+	bi.RLock()
+	defer bi.RUnlock()
+
+	var descendants []*blockNode
+	for _, n := range bi.index {
+		if n.height > node.height && n.Ancestor(node.height) == node {
+			descendants = append(descendants, n)
+		}
+	}
+	return descendants
+}
+
+// Header returns the header of the block of the provided node.  Headers of
+// nodes which were written to the database are loaded from it.
+//
+// This function is safe for concurrent access.
+func (bi *blockIndex) Header(node *blockNode) (wire.BlockHeader, error) {
+	headers, err := bi.Headers([]*blockNode{node})
+	if err != nil {
+		return wire.BlockHeader{}, err
+	}
+	return headers[0], nil
+}
+
+// Headers returns the headers of the blocks of the provided nodes.  The headers
+// which need to be loaded from the database are loaded in a single database
+// transaction.
+//
+// This function is safe for concurrent access.
+func (bi *blockIndex) Headers(nodes []*blockNode) ([]wire.BlockHeader, error) {
+	headers := make([]wire.BlockHeader, len(nodes))
+	var stored []int
+	bi.RLock()
+	for i, node := range nodes {
+		if node.header != nil {
+			headers[i] = *node.header
+		} else {
+			stored = append(stored, i)
+		}
+	}
+	bi.RUnlock()
+	if len(stored) == 0 {
+		return headers, nil
+	}
+
+	err := bi.db.View(func(dbTx database.Tx) error {
+		for _, i := range stored {
+			header, err := dbFetchBlockNodeHeader(dbTx, nodes[i])
+			if err != nil {
+				return err
+			}
+			headers[i] = *header
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
+
+// dbFetchHeader returns the header of the block of the provided node using an
+// existing database transaction when it is not held in memory.
+//
+// This function is safe for concurrent access.
+func (bi *blockIndex) dbFetchHeader(dbTx database.Tx, node *blockNode) (*wire.BlockHeader, error) {
+	bi.RLock()
+	header := node.header
+	bi.RUnlock()
+	if header != nil {
+		return header, nil
+	}
+	return dbFetchBlockNodeHeader(dbTx, node)
+}
+
 // NodeStatus provides concurrent-safe access to the status field of a node.
 //
 // This function is safe for concurrent access.
@@ -338,8 +405,12 @@ func (bi *blockIndex) flushToDB() error {
 		return nil
 	})
 
-	// If write was successful, clear the dirty set.
+	// If write was successful, clear the dirty set and release the headers
+	// which can now be loaded from the database.
 	if err == nil {
+		for node := range bi.dirty {
+			node.header = nil
+		}
 		bi.dirty = make(map[*blockNode]struct{})
 	}
 
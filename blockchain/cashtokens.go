@@ -0,0 +1,187 @@
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// nftKey identifies an immutable non-fungible token by its category and
+// commitment.
+type nftKey struct {
+	category   [32]byte
+	commitment string
+}
+
+// checkTokenData returns an error when the passed token data of an output is
+// not encoded as required by the CashTokens consensus rules.
+func checkTokenData(tokenData *wire.TokenData) error {
+	if !tokenData.IsValidBitfield() {
+		return fmt.Errorf("invalid token bitfield 0x%02x",
+			tokenData.BitField)
+	}
+	if tokenData.HasAmount() {
+		if tokenData.Amount < 1 || tokenData.Amount > wire.MAX_FT_AMOUNT {
+			return fmt.Errorf("fungible token amount %d is out of "+
+				"range", tokenData.Amount)
+		}
+	} else if tokenData.Amount != 0 {
+		return fmt.Errorf("fungible token amount %d without amount "+
+			"bit", tokenData.Amount)
+	}
+	if tokenData.HasCommitmentLength() {
+		if len(tokenData.Commitment) < 1 ||
+			len(tokenData.Commitment) > wire.MAX_COMMITMENT_LENGTH {
+
+			return fmt.Errorf("token commitment length %d is out "+
+				"of range", len(tokenData.Commitment))
+		}
+	} else if len(tokenData.Commitment) != 0 {
+		return fmt.Errorf("token commitment without commitment bit")
+	}
+	return nil
+}
+
+// checkTransactionTokens ensures the tokens carried by the outputs of the
+// passed transaction are permitted by the tokens carried by the outputs it
+// spends as specified by the CashTokens token-aware transaction validation
+// algorithm.  The referenced outputs must be available in the passed view.
+//
+// Tokens of a category may only be created by the genesis transaction of the
+// category, which is the transaction spending the output with index 0 of the
+// transaction whose hash is the category id, or by a transaction spending a
+// minting token of the category.  Otherwise, fungible tokens must be spent to
+// be paid, immutable tokens must either be spent or be created from a mutable
+// token of the category, and each mutable token paid must be spent.
+func checkTransactionTokens(tx *bchutil.Tx, utxoView *UtxoViewpoint) error {
+	msgTx := tx.MsgTx()
+
+	genesisCategories := make(map[[32]byte]struct{})
+	mintingCategories := make(map[[32]byte]struct{})
+	availableSums := make(map[[32]byte]uint64)
+	availableMutable := make(map[[32]byte]int)
+	availableImmutable := make(map[nftKey]int)
+	if !IsCoinBase(tx) {
+		for _, txIn := range msgTx.TxIn {
+			if txIn.PreviousOutPoint.Index == 0 {
+				genesisCategories[txIn.PreviousOutPoint.Hash] = struct{}{}
+			}
+
+			utxo := utxoView.LookupEntry(txIn.PreviousOutPoint)
+			if utxo == nil || utxo.tokenData.IsEmpty() {
+				continue
+			}
+			tokenData := &utxo.tokenData
+			category := tokenData.CategoryID
+
+			// The supply of a category can not exceed the maximum
+			// amount, so the sum of the spent tokens can not
+			// overflow.
+			availableSums[category] += tokenData.Amount
+			switch {
+			case tokenData.IsMintingNFT():
+				mintingCategories[category] = struct{}{}
+			case tokenData.IsMutableNFT():
+				availableMutable[category]++
+			case tokenData.IsImmutableNFT():
+				key := nftKey{category, string(tokenData.Commitment)}
+				availableImmutable[key]++
+			}
+		}
+	}
+	for category := range genesisCategories {
+		mintingCategories[category] = struct{}{}
+	}
+
+	txHash := tx.Hash()
+	outputSums := make(map[[32]byte]uint64)
+	var mutableOutputs, immutableOutputs []int
+	for txOutIndex, txOut := range msgTx.TxOut {
+		tokenData := &txOut.TokenData
+		if tokenData.IsEmpty() {
+			continue
+		}
+		category := tokenData.CategoryID
+		categoryHash := chainhash.Hash(category)
+
+		if err := checkTokenData(tokenData); err != nil {
+			str := fmt.Sprintf("transaction %v output %d: %v",
+				txHash, txOutIndex, err)
+			return ruleError(ErrBadTokenData, str)
+		}
+
+		// The fungible tokens of a category which are paid may not
+		// exceed the tokens which are spent unless the transaction is
+		// the genesis of the category, in which case they may not
+		// exceed the maximum amount.
+		sum := outputSums[category] + tokenData.Amount
+		_, isGenesis := genesisCategories[category]
+		if sum < outputSums[category] ||
+			(sum > availableSums[category] && (!isGenesis || sum > wire.MAX_FT_AMOUNT)) {
+
+			str := fmt.Sprintf("transaction %v output %d pays more "+
+				"fungible tokens of category %v than are "+
+				"available", txHash, txOutIndex, categoryHash)
+			return ruleError(ErrTokenAmountInflation, str)
+		}
+		outputSums[category] = sum
+
+		if !tokenData.HasNFT() {
+			continue
+		}
+
+		// Transactions which may mint tokens of a category may pay any
+		// non-fungible tokens of it.
+		if _, ok := mintingCategories[category]; ok {
+			continue
+		}
+		switch {
+		case tokenData.IsMintingNFT():
+			str := fmt.Sprintf("transaction %v output %d pays a "+
+				"minting token of category %v without minting "+
+				"capability", txHash, txOutIndex, categoryHash)
+			return ruleError(ErrTokenMintingNotAllowed, str)
+		case tokenData.IsMutableNFT():
+			mutableOutputs = append(mutableOutputs, txOutIndex)
+		case tokenData.IsImmutableNFT():
+			immutableOutputs = append(immutableOutputs, txOutIndex)
+		}
+	}
+
+	// Each mutable token which is paid must be spent.
+	for _, txOutIndex := range mutableOutputs {
+		category := msgTx.TxOut[txOutIndex].TokenData.CategoryID
+		if availableMutable[category] == 0 {
+			str := fmt.Sprintf("transaction %v output %d pays a "+
+				"mutable token of category %v which is not spent",
+				txHash, txOutIndex, chainhash.Hash(category))
+			return ruleError(ErrTokenMutableNotAllowed, str)
+		}
+		availableMutable[category]--
+	}
+
+	// Each immutable token which is paid must either be spent or be
+	// created from one of the remaining mutable tokens of the category.
+	for _, txOutIndex := range immutableOutputs {
+		tokenData := &msgTx.TxOut[txOutIndex].TokenData
+		category := tokenData.CategoryID
+		key := nftKey{category, string(tokenData.Commitment)}
+		if availableImmutable[key] > 0 {
+			availableImmutable[key]--
+			continue
+		}
+		if availableMutable[category] > 0 {
+			availableMutable[category]--
+			continue
+		}
+		str := fmt.Sprintf("transaction %v output %d pays an immutable "+
+			"token of category %v with commitment %x which is not "+
+			"spent", txHash, txOutIndex, chainhash.Hash(category),
+			tokenData.Commitment)
+		return ruleError(ErrTokenImmutableNotAllowed, str)
+	}
+
+	return nil
+}
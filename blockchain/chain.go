@@ -8,8 +8,11 @@ package blockchain
 import (
 	"bytes"
 	"container/list"
+	"context"
 	"fmt"
 	"math"
+	"math/big"
+	"sort"
 	"sync"
 	"time"
 
@@ -114,12 +117,15 @@ type BlockChain struct {
 	// separate mutex.
 	checkpoints         []chaincfg.Checkpoint
 	checkpointsByHeight map[int32]*chaincfg.Checkpoint
+	minimumChainWork    *big.Int
+	assumeValid         *assumeValidState
 	db                  database.DB
 	chainParams         *chaincfg.Params
 	timeSource          MedianTimeSource
 	sigCache            *txscript.SigCache
 	indexManager        IndexManager
 	hashCache           *txscript.HashCache
+	scriptCache         *txscript.ScriptCache
 	excessiveBlockSize  uint32
 
 	// The following fields are calculated based upon the provided chain
@@ -156,6 +162,14 @@ type BlockChain struct {
 	// to help prevent logic races when blocks are being processed.
 	utxoCache *utxoCache
 
+	// utxoStats holds the statistics of the utxo set at the end of the main
+	// chain when utxoStatsEnabled is set.  They are updated incrementally as
+	// blocks are connected and disconnected and are protected by the chain
+	// lock.  The field is nil when the statistics are not maintained or not
+	// known yet.
+	utxoStatsEnabled bool
+	utxoStats        *utxoStats
+
 	// orphanLock protects the fields related to handling of orphan blocks.
 	// They are protected by a combination of the chain lock and the orphan lock.
 	orphanLock   sync.RWMutex
@@ -182,6 +196,14 @@ type BlockChain struct {
 	stateLock     sync.RWMutex
 	stateSnapshot *BestState
 
+	// txRate measures the rate at which transactions are verified while
+	// blocks are connected.  It is protected by the chain lock.
+	txRate txRateMeter
+
+	// traceCtx is the context of the currently active tracing span of the
+	// block validation pipeline.  It is protected by the chain lock.
+	traceCtx context.Context
+
 	// notificationLock is used to make sure notifications are sent
 	// serially and protect against double mutex unlock panics during reorg.
 	notificationLock sync.Mutex
@@ -224,6 +246,10 @@ type BlockChain struct {
 	pruneMode  bool
 	pruneDepth uint32
 
+	// undoWindow is the number of blocks at the end of the main chain which
+	// can be undone.
+	undoWindow int32
+
 	// isPruned is set to true if the chain was ever run in prune mode or fast
 	// sync mode.
 	isPruned bool
@@ -234,6 +260,16 @@ type BlockChain struct {
 	// fastSyncDone chan is used to signal that the UTXO set download has
 	// finished.
 	fastSyncDone chan struct{}
+
+	// headersOnly is set to true if the chain only tracks the block headers
+	// and passes the downloaded blocks to the index manager without storing
+	// them.
+	//
+	// blockTip is the last block of the main chain which was passed to the
+	// index manager in headers only mode.  It is protected by the chain
+	// lock.
+	headersOnly bool
+	blockTip    *blockNode
 }
 
 // HaveBlock returns whether or not the chain instance has the block represented
@@ -285,6 +321,12 @@ func (b *BlockChain) IsPruned() bool {
 	return b.isPruned
 }
 
+// HeadersOnly returns whether or not the blockchain is running in headers only
+// mode.
+func (b *BlockChain) HeadersOnly() bool {
+	return b.headersOnly
+}
+
 // FastSyncDoneChan returns a channel which signals that the fastsync UTXO download
 // has finished.
 func (b *BlockChain) FastSyncDoneChan() <-chan struct{} {
@@ -729,7 +771,14 @@ func (b *BlockChain) connectBlock(node *blockNode, block *bchutil.Block,
 
 	b.ablaState = b.ablaState.nextABLAState(&b.ablaConfig, blockSize)
 
+	// Update the utxo statistics when they are maintained.
+	var newStats *utxoStats
+	if b.utxoStats != nil && b.utxoStats.hash == *prevHash {
+		newStats = b.utxoStats.connectBlock(node, block, stxos)
+	}
+
 	// Atomically insert info into the database.
+	endSpan := b.startSpan("dbConnectBlock")
 	err = b.db.Update(func(dbTx database.Tx) error {
 		// Update best block state.
 		err := dbPutBestState(dbTx, state, node.workSum)
@@ -757,6 +806,12 @@ func (b *BlockChain) connectBlock(node *blockNode, block *bchutil.Block,
 			return err
 		}
 
+		if newStats != nil {
+			if err := dbPutUtxoStats(dbTx, newStats); err != nil {
+				return err
+			}
+		}
+
 		// Allow the index manager to call each of the currently active
 		// optional indexes with the block being connected so they can
 		// update themselves accordingly.
@@ -769,10 +824,13 @@ func (b *BlockChain) connectBlock(node *blockNode, block *bchutil.Block,
 
 		return nil
 	})
+	endSpan(err)
 	if err != nil {
 		return err
 	}
 
+	b.utxoStats = newStats
+
 	// Commit all modifications made to the view into the utxo state.  This also
 	// prunes these changes from the view.
 	b.stateLock.Lock()
@@ -792,6 +850,7 @@ func (b *BlockChain) connectBlock(node *blockNode, block *bchutil.Block,
 	b.stateLock.Lock()
 	b.stateSnapshot = state
 	b.stateLock.Unlock()
+	b.txRate.update(time.Now(), state.TotalTxns)
 
 	// Notify the caller that the block was connected to the main chain.
 	// The caller would typically want to react with actions such as
@@ -823,7 +882,10 @@ func (b *BlockChain) connectBlock(node *blockNode, block *bchutil.Block,
 			flushMode = FlushRequired
 		}
 	}
-	return b.utxoCache.Flush(flushMode, state)
+	endSpan = b.startSpan("utxoCache.Flush")
+	err = b.utxoCache.Flush(flushMode, state)
+	endSpan(err)
+	return err
 }
 
 // disconnectBlock handles disconnecting the passed node/block from the end of
@@ -866,6 +928,7 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *bchutil.Block, view
 	state := newBestState(prevNode, blockSize, numTxns,
 		newTotalTxns, prevNode.CalcPastMedianTime())
 
+	var newStats *utxoStats
 	err = b.db.Update(func(dbTx database.Tx) error {
 		// Update best block state.
 		err := dbPutBestState(dbTx, state, node.workSum)
@@ -910,6 +973,14 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *bchutil.Block, view
 			return err
 		}
 
+		// Update the utxo statistics when they are maintained.
+		if b.utxoStats != nil && b.utxoStats.hash == node.hash {
+			newStats = b.utxoStats.disconnectBlock(node, block, stxos)
+			if err := dbPutUtxoStats(dbTx, newStats); err != nil {
+				return err
+			}
+		}
+
 		// Allow the index manager to call each of the currently active
 		// optional indexes with the block being disconnected so they
 		// can update themselves accordingly.
@@ -926,6 +997,8 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *bchutil.Block, view
 		return err
 	}
 
+	b.utxoStats = newStats
+
 	// Commit all modifications made to the view into the utxo state.  This also
 	// prunes these changes from the view.
 	b.stateLock.Lock()
@@ -1145,6 +1218,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		if err != nil {
 			if _, ok := err.(RuleError); ok {
 				b.index.SetStatusFlags(n, statusValidateFailed)
+				b.storeInvalidBlockReason(n, err.Error())
 				for de := e.Next(); de != nil; de = de.Next() {
 					dn := de.Value.(*blockNode)
 					b.index.SetStatusFlags(dn, statusInvalidAncestor)
@@ -1239,9 +1313,61 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 	log.Infof("REORGANIZE: New best chain head is %v (height %v)",
 		newBest.hash, newBest.height)
 
+	// Notify the caller of the reorganization as a whole so it does not
+	// have to reconstruct it from the notifications of the individual
+	// blocks.
+	if len(detachBlocks) > 0 {
+		reorg := newReorganizationData(oldBest, newBest, detachBlocks,
+			attachBlocks)
+		b.notificationLock.Lock()
+		b.chainLock.Unlock()
+		b.sendNotification(NTChainReorganized, reorg)
+		b.chainLock.Lock()
+		b.notificationLock.Unlock()
+	}
+
 	return nil
 }
 
+// newReorganizationData returns the details of a reorganization from the passed
+// old tip to the passed new tip which disconnected and connected the passed
+// blocks.
+func newReorganizationData(oldBest, newBest *blockNode, detachBlocks, attachBlocks []*bchutil.Block) *ReorganizationData {
+	forkNode := oldBest.Ancestor(oldBest.height - int32(len(detachBlocks)))
+	reorg := &ReorganizationData{
+		OldHash:      oldBest.hash,
+		OldHeight:    oldBest.height,
+		NewHash:      newBest.hash,
+		NewHeight:    newBest.height,
+		ForkHash:     forkNode.hash,
+		ForkHeight:   forkNode.height,
+		Disconnected: make([]chainhash.Hash, 0, len(detachBlocks)),
+		Connected:    make([]chainhash.Hash, 0, len(attachBlocks)),
+	}
+	confirmed := make(map[chainhash.Hash]struct{})
+	for _, block := range attachBlocks {
+		reorg.Connected = append(reorg.Connected, *block.Hash())
+		for _, tx := range block.Transactions() {
+			confirmed[*tx.Hash()] = struct{}{}
+		}
+	}
+
+	// The blocks are detached starting with the old tip, so iterate them
+	// in reverse to report the transactions in block order.
+	for _, block := range detachBlocks {
+		reorg.Disconnected = append(reorg.Disconnected, *block.Hash())
+	}
+	for i := len(detachBlocks) - 1; i >= 0; i-- {
+		for _, tx := range detachBlocks[i].Transactions() {
+			if _, ok := confirmed[*tx.Hash()]; !ok {
+				reorg.Unconfirmed = append(reorg.Unconfirmed,
+					*tx.Hash())
+			}
+		}
+	}
+	return reorg
+}
+
 // connectBestChain handles connecting the passed block to the chain while
 // respecting proper chain selection according to the chain with the most
 // proof of work.  In the typical case, the new block simply extends the main
@@ -1288,6 +1414,7 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *bchutil.Block, fla
 				b.index.SetStatusFlags(node, statusValid)
 			} else if _, ok := err.(RuleError); ok {
 				b.index.SetStatusFlags(node, statusValidateFailed)
+				b.storeInvalidBlockReason(node, err.Error())
 			} else {
 				return false, err
 			}
@@ -1324,6 +1451,7 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *bchutil.Block, fla
 				b.index.SetStatusFlags(
 					node, statusValidateFailed,
 				)
+				b.storeInvalidBlockReason(node, err.Error())
 			}
 
 			flushIndexState()
@@ -1392,6 +1520,7 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *bchutil.Block, fla
 // factors are used to guess, but the key factors that allow the chain to
 // believe it is current are:
 //   - Latest block height is after the latest checkpoint (if enabled)
+//   - Main chain has at least the minimum chain work (if enabled)
 //   - Latest block has a timestamp newer than 24 hours ago
 //
 // This function MUST be called with the chain state lock held (for reads).
@@ -1403,6 +1532,11 @@ func (b *BlockChain) isCurrent() bool {
 		return false
 	}
 
+	// Not current if the main chain has less than the minimum chain work.
+	if !b.hasMinimumChainWork() {
+		return false
+	}
+
 	// Not current if the latest best block has a timestamp before 24 hours
 	// ago.
 	//
@@ -1412,10 +1546,20 @@ func (b *BlockChain) isCurrent() bool {
 	return b.bestChain.Tip().timestamp >= minus24Hours
 }
 
+// hasMinimumChainWork returns whether or not the main chain has at least the
+// minimum chain work.  It is always true when no minimum is configured.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) hasMinimumChainWork() bool {
+	return b.minimumChainWork == nil ||
+		b.bestChain.Tip().workSum.Cmp(b.minimumChainWork) >= 0
+}
+
 // IsCurrent returns whether or not the chain believes it is current.  Several
 // factors are used to guess, but the key factors that allow the chain to
 // believe it is current are:
 //   - Latest block height is after the latest checkpoint (if enabled)
+//   - Main chain has at least the minimum chain work (if enabled)
 //   - Latest block has a timestamp newer than 24 hours ago
 //
 // This function is safe for concurrent access.
@@ -1448,7 +1592,7 @@ func (b *BlockChain) HeaderByHash(hash *chainhash.Hash) (wire.BlockHeader, error
 		return wire.BlockHeader{}, err
 	}
 
-	return node.Header(), nil
+	return b.index.Header(node)
 }
 
 // HeaderByHeight returns the block header identified by the given height or an
@@ -1461,7 +1605,7 @@ func (b *BlockChain) HeaderByHeight(height int32) (wire.BlockHeader, error) {
 		return wire.BlockHeader{}, err
 	}
 
-	return node.Header(), nil
+	return b.index.Header(node)
 }
 
 // MainChainHasBlock returns whether or not the block with the given hash is in
@@ -1803,12 +1947,18 @@ func (b *BlockChain) locateHeaders(locator BlockLocator, hashStop *chainhash.Has
 		return nil
 	}
 
-	// Populate and return the found headers.
-	headers := make([]wire.BlockHeader, 0, total)
+	// Populate and return the found headers.  Headers which are not held
+	// in memory are loaded from the database all at once.
+	nodes := make([]*blockNode, 0, total)
 	for i := uint32(0); i < total; i++ {
-		headers = append(headers, node.Header())
+		nodes = append(nodes, node)
 		node = b.bestChain.Next(node)
 	}
+	headers, err := b.index.Headers(nodes)
+	if err != nil {
+		log.Errorf("Unable to load headers to locate: %v", err)
+		return nil
+	}
 	return headers
 }
 
@@ -1838,32 +1988,105 @@ func (b *BlockChain) invalidateBlock(hash *chainhash.Hash) error {
 		return err
 	}
 
+	// Refuse to disconnect more main chain blocks than the undo window
+	// allows.
+	if b.bestChain.Contains(node) {
+		b.chainLock.RLock()
+		err := b.checkUndoWindow(node.height - 1)
+		b.chainLock.RUnlock()
+		if err != nil {
+			return fmt.Errorf("unable to invalidate block %s: %v", hash,
+				err)
+		}
+	}
+
 	b.index.SetStatusFlags(node, statusValidateFailed)
 	b.index.UnsetStatusFlags(node, statusValid)
+	b.storeInvalidBlockReason(node, "marked invalid manually")
 
 	b.chainLock.Lock()
 	defer b.chainLock.Unlock()
-	detachNodes, attachNodes := b.getReorganizeNodes(node.parent)
 
-	err := b.reorganizeChain(detachNodes, attachNodes)
-	if err != nil {
-		return err
+	// Disconnect the block along with all of its descendants when it is
+	// part of the main chain.
+	if b.bestChain.Contains(node) {
+		detachNodes, attachNodes := b.getReorganizeNodes(node.parent)
+		err := b.reorganizeChain(detachNodes, attachNodes)
+		if err != nil {
+			return err
+		}
 	}
 
-	for i, e := 0, detachNodes.Front(); e != nil; i, e = i+1, e.Next() {
-		n := e.Value.(*blockNode)
-
+	// Mark all of the descendants of the block as invalid, including the
+	// ones on side chains.
+	for _, n := range b.index.Descendants(node) {
 		b.index.SetStatusFlags(n, statusInvalidAncestor)
 		b.index.UnsetStatusFlags(n, statusValid)
 	}
 
+	// Another chain which does not contain the block might have more work
+	// than the parent of the block, so switch to the best remaining chain.
+	err := b.reorganizeToBestChain()
+
 	if writeErr := b.index.flushToDB(); writeErr != nil {
 		log.Warnf("Error flushing block index changes to disk: %v", writeErr)
 	}
 
+	return err
+}
+
+// bestChainCandidate returns the block node with the most cumulative work that
+// can become the tip of the main chain.  Neither the node nor any of its
+// ancestors may be known to be invalid and the data of all of the blocks which
+// are not part of the main chain yet must be available.  It returns nil when no
+// such node has more work than the current tip.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) bestChainCandidate() *blockNode {
+	tip := b.bestChain.Tip()
+	var candidates []*blockNode
+	b.index.RLock()
+	for _, n := range b.index.index {
+		if n.workSum.Cmp(tip.workSum) > 0 {
+			candidates = append(candidates, n)
+		}
+	}
+	b.index.RUnlock()
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].workSum.Cmp(candidates[j].workSum) > 0
+	})
+
+nextCandidate:
+	for _, candidate := range candidates {
+		for n := candidate; n != nil && !b.bestChain.Contains(n); n = n.parent {
+			status := b.index.NodeStatus(n)
+			if status.KnownInvalid() || !status.HaveData() {
+				continue nextCandidate
+			}
+		}
+		return candidate
+	}
 	return nil
 }
 
+// reorganizeToBestChain reorganizes the chain to the best chain candidate when
+// there is one.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) reorganizeToBestChain() error {
+	candidate := b.bestChainCandidate()
+	if candidate == nil {
+		return nil
+	}
+
+	detachNodes, attachNodes := b.getReorganizeNodes(candidate)
+	if attachNodes.Len() == 0 {
+		return nil
+	}
+	return b.reorganizeChain(detachNodes, attachNodes)
+}
+
 // ReconsiderBlock takes a block hash and allows it to be revalidated.
 //
 // This function is safe for concurrent access.
@@ -1891,12 +2114,28 @@ func (b *BlockChain) reconsiderBlock(hash *chainhash.Hash) error {
 
 	// Find previous node to the point where the blocks are valid again.
 	for n := node; n.status.KnownInvalid(); n = n.parent {
+		if n.status&statusValidateFailed != 0 {
+			b.removeInvalidBlockReason(n)
+		}
 		b.index.UnsetStatusFlags(n, statusInvalidAncestor)
 		b.index.UnsetStatusFlags(n, statusValidateFailed)
 
 		firstNode = n
 	}
 
+	// Also reconsider the descendants of the blocks, including the ones on
+	// side chains, unless they have failed validation themselves or descend
+	// from another block that has.
+nextDescendant:
+	for _, d := range b.index.Descendants(firstNode) {
+		for n := d; n != firstNode; n = n.parent {
+			if b.index.NodeStatus(n)&statusValidateFailed != 0 {
+				continue nextDescendant
+			}
+		}
+		b.index.UnsetStatusFlags(d, statusInvalidAncestor)
+	}
+
 	var blk *bchutil.Block
 	err := b.db.View(func(dbTx database.Tx) error {
 		var err error
@@ -1914,6 +2153,15 @@ func (b *BlockChain) reconsiderBlock(hash *chainhash.Hash) error {
 		return err
 	}
 
+	// The reconsidered descendants might make up a chain with more work
+	// than the current one.
+	b.chainLock.Lock()
+	err = b.reorganizeToBestChain()
+	b.chainLock.Unlock()
+	if err != nil {
+		return err
+	}
+
 	if writeErr := b.index.flushToDB(); writeErr != nil {
 		log.Warnf("Error flushing block index changes to disk: %v", writeErr)
 	}
@@ -2021,9 +2269,7 @@ func (b *BlockChain) prune() error {
 
 		// Loop backwards through the chain and delete the spend journals
 		for ; node.height >= int32(pruneHeight); node = node.parent {
-			hdr := node.Header()
-			blockHash := hdr.BlockHash()
-			if err := dbRemoveSpendJournalEntry(tx, &blockHash); err != nil {
+			if err := dbRemoveSpendJournalEntry(tx, &node.hash); err != nil {
 				return err
 			}
 			if node.height == 0 {
@@ -2183,6 +2429,23 @@ type Config struct {
 	// checkpoints.
 	Checkpoints []chaincfg.Checkpoint
 
+	// MinimumChainWork is the minimum cumulative work of the main chain.
+	// Block headers which fork the main chain from a block with less work
+	// are rejected once the main chain has this much work, and the chain
+	// is not current before it does.  This is usually the MinimumChainWork
+	// of ChainParams.
+	//
+	// This field can be nil to disable the minimum.
+	MinimumChainWork *big.Int
+
+	// AssumeValid is the hash of a block which is assumed to be valid.
+	// The scripts of the block and its ancestors are not validated, while
+	// all other rules are still enforced.  This is usually the AssumeValid
+	// of ChainParams.
+	//
+	// This field can be nil to validate the scripts of all blocks.
+	AssumeValid *chainhash.Hash
+
 	// TimeSource defines the median time source to use for things such as
 	// block processing and determining whether or not the chain is current.
 	//
@@ -2217,6 +2480,15 @@ type Config struct {
 	// signature cache.
 	HashCache *txscript.HashCache
 
+	// ScriptCache defines a script verification cache to use when
+	// validating the scripts of the transactions in a block.  The scripts
+	// of the transactions found in the cache, which are typically added
+	// to it by the transaction memory pool, are not executed again.
+	//
+	// This field can be nil if the caller is not interested in using a
+	// script cache.
+	ScriptCache *txscript.ScriptCache
+
 	// ExcessiveBlockSize is the user-configurable max block size
 	ExcessiveBlockSize uint32
 
@@ -2233,6 +2505,12 @@ type Config struct {
 	// UTXO set from blocks on disk on startup.
 	ReIndexChainState bool
 
+	// ReIndex will delete the block index along with the chain state and
+	// rebuild them from the blocks stored in the database on startup,
+	// rather than downloading the blocks from the network again.  It
+	// implies ReIndexChainState.
+	ReIndex bool
+
 	// FastSync will download, validate, and save the UTXO at the last
 	// checkpoint.
 	FastSync bool
@@ -2244,6 +2522,25 @@ type Config struct {
 	// Proxy is ip:port of an optional socks5 proxy to use when downloading
 	// the UTXO set in fast sync mode.
 	Proxy string
+
+	// UndoWindow is the number of blocks at the end of the main chain which
+	// can be undone in order to reconstruct historical unspent transaction
+	// output sets or to invalidate blocks.
+	//
+	// This field can be zero to use DefaultUndoWindow.
+	UndoWindow int32
+
+	// UtxoStats maintains statistics about the unspent transaction output
+	// set incrementally so FetchUtxoStats doesn't have to scan the entire
+	// set.
+	UtxoStats bool
+
+	// HeadersOnly only tracks the chain of block headers with the most
+	// proof of work.  Blocks are not stored and there is no utxo set, the
+	// blocks of the main chain are only passed to the index manager with
+	// IndexBlock.  The database must not contain blocks from a previous
+	// run in another mode.
+	HeadersOnly bool
 }
 
 // New returns a BlockChain instance using the provided configuration details.
@@ -2317,6 +2614,7 @@ func New(config *Config) (*BlockChain, error) {
 	b := BlockChain{
 		checkpoints:         config.Checkpoints,
 		checkpointsByHeight: checkpointsByHeight,
+		minimumChainWork:    config.MinimumChainWork,
 		db:                  config.DB,
 		chainParams:         params,
 		timeSource:          config.TimeSource,
@@ -2331,6 +2629,7 @@ func New(config *Config) (*BlockChain, error) {
 		index:               newBlockIndex(config.DB, params),
 		utxoCache:           newUtxoCache(config.DB, config.UtxoCacheMaxSize),
 		hashCache:           config.HashCache,
+		scriptCache:         config.ScriptCache,
 		bestChain:           newChainView(nil),
 		orphans:             make(map[chainhash.Hash]*orphanBlock),
 		prevOrphans:         make(map[chainhash.Hash][]*orphanBlock),
@@ -2338,8 +2637,29 @@ func New(config *Config) (*BlockChain, error) {
 		deploymentCaches:    newThresholdCaches(chaincfg.DefinedDeployments),
 		pruneMode:           config.Prune,
 		pruneDepth:          config.PruneDepth,
+		undoWindow:          config.UndoWindow,
+		utxoStatsEnabled:    config.UtxoStats,
 		fastSyncDataDir:     config.FastSyncDataDir,
 		fastSyncDone:        make(chan struct{}),
+		headersOnly:         config.HeadersOnly,
+	}
+	if b.undoWindow <= 0 {
+		b.undoWindow = DefaultUndoWindow
+	}
+	if b.pruneMode && b.undoWindow > int32(b.pruneDepth) {
+		b.undoWindow = int32(b.pruneDepth)
+	}
+	if config.AssumeValid != nil {
+		b.assumeValid = &assumeValidState{hash: *config.AssumeValid}
+	}
+
+	// Remove the chain state when the chain is to be rebuilt from the
+	// blocks stored in the database.
+	if config.ReIndex {
+		log.Info("Removing chain state to rebuild it from stored blocks...")
+		if err := dbResetChainState(b.db); err != nil {
+			return nil, err
+		}
 	}
 
 	// Initialize the chain state from the passed database.  When the db
@@ -2354,9 +2674,12 @@ func New(config *Config) (*BlockChain, error) {
 	config.FastSync = config.FastSync && lastCheckpoint != nil && bestNode.height <= lastCheckpoint.Height
 
 	// Make sure the utxo state is caught up if it was left in an inconsistent
-	// state.
-	if err := b.utxoCache.InitConsistentState(bestNode, config.FastSync, config.Interrupt); err != nil {
-		return nil, err
+	// state.  There is no utxo state in headers only mode.
+	if !b.headersOnly {
+		err := b.utxoCache.InitConsistentState(bestNode, config.FastSync, config.Interrupt)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Perform any upgrades to the various chain-specific buckets as needed.
@@ -2364,6 +2687,12 @@ func New(config *Config) (*BlockChain, error) {
 		return nil, err
 	}
 
+	// Make sure the database was not used in another mode, since it either
+	// contains blocks or is missing them.
+	if err := b.maybeSetHeadersOnlyChainType(); err != nil {
+		return nil, err
+	}
+
 	// If we're running in pruned mode or fast sync mode then set the chain type in the
 	// database. If not load the chain type from the database.
 	if config.Prune || config.FastSync {
@@ -2399,6 +2728,14 @@ func New(config *Config) (*BlockChain, error) {
 		}
 	}
 
+	// Load the last block passed to the index manager in headers only
+	// mode.
+	if b.headersOnly {
+		if err := b.initHeadersOnlyBlockTip(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Initialize rule change threshold state caches.
 	if err := b.initThresholdCaches(); err != nil {
 		return nil, err
@@ -2411,7 +2748,7 @@ func New(config *Config) (*BlockChain, error) {
 		}
 	}
 
-	if config.ReIndexChainState {
+	if config.ReIndexChainState && !config.ReIndex {
 		log.Info("Re-indexing UTXO set from disk. This will take a while...")
 		if err := b.ReIndexChainState(); err != nil {
 			return nil, err
@@ -2419,6 +2756,21 @@ func New(config *Config) (*BlockChain, error) {
 		log.Info("Re-indexing complete")
 	}
 
+	// The utxo set is not known until a fast sync completes, so the utxo
+	// statistics are calculated on first use in that case.
+	if b.utxoStatsEnabled && !config.FastSync {
+		if err := b.initUtxoStats(config.Interrupt); err != nil {
+			return nil, err
+		}
+	}
+
+	// Rebuild the chain from the stored blocks when requested, or when a
+	// previous rebuild was interrupted.
+	if err := b.maybeReindexBlocks(config.Interrupt); err != nil {
+		return nil, err
+	}
+	bestNode = b.bestChain.Tip()
+
 	if config.FastSync {
 		if lastCheckpoint.UtxoSetHash == nil || len(lastCheckpoint.UtxoSetSources) == 0 || lastCheckpoint.UtxoSetSize == 0 {
 			errStr := fmt.Sprintf("chain with %s params does not support fastsync mode", b.chainParams.Name)
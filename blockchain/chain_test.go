@@ -118,6 +118,57 @@ func TestHaveBlock(t *testing.T) {
 	}
 }
 
+// TestHeaderLoading ensures the headers of block nodes which were written to
+// the database are released from memory and loaded from the database on
+// demand.
+func TestHeaderLoading(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("headerloading",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+
+	for i := 1; i < len(blocks); i++ {
+		if _, _, err := chain.ProcessBlock(blocks[i], BFNone); err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v", i, err)
+		}
+	}
+	if err := chain.index.flushToDB(); err != nil {
+		t.Fatalf("flushToDB: unexpected error: %v", err)
+	}
+
+	wantHeaders := make([]wire.BlockHeader, 0, len(blocks)-1)
+	for i := 1; i < len(blocks); i++ {
+		want := blocks[i].MsgBlock().Header
+		wantHeaders = append(wantHeaders, want)
+		node := chain.index.LookupNode(blocks[i].Hash())
+		if node.header != nil {
+			t.Fatalf("block %d: header held in memory after flush", i)
+		}
+		header, err := chain.HeaderByHash(blocks[i].Hash())
+		if err != nil {
+			t.Fatalf("HeaderByHash: unexpected error: %v", err)
+		}
+		if header != want {
+			t.Fatalf("block %d: got header %v, want %v", i, header,
+				want)
+		}
+	}
+
+	locator := BlockLocator{chaincfg.MainNetParams.GenesisHash}
+	headers := chain.LocateHeaders(locator, &zeroHash)
+	if !reflect.DeepEqual(headers, wantHeaders) {
+		t.Fatalf("LocateHeaders: got %v, want %v", headers, wantHeaders)
+	}
+}
+
 // TestCalcSequenceLock tests the LockTimeToSequence function, and the
 // CalcSequenceLock method of a Chain instance. The tests exercise several
 // combinations of inputs to the CalcSequenceLock function in order to ensure
@@ -134,7 +185,7 @@ func TestCalcSequenceLock(t *testing.T) {
 	// Generate enough synthetic blocks to activate CSV.
 	chain := newFakeChain(netParams)
 	node := chain.bestChain.Tip()
-	blockTime := node.Header().Timestamp
+	blockTime := time.Unix(node.timestamp, 0)
 	numBlocksToActivate := (netParams.MinerConfirmationWindow * 3)
 	for i := uint32(0); i < numBlocksToActivate; i++ {
 		blockTime = blockTime.Add(time.Second)
@@ -462,7 +513,7 @@ func nodeHashes(nodes []*blockNode, indexes ...int) []chainhash.Hash {
 func nodeHeaders(nodes []*blockNode, indexes ...int) []wire.BlockHeader {
 	headers := make([]wire.BlockHeader, 0, len(indexes))
 	for _, idx := range indexes {
-		headers = append(headers, nodes[idx].Header())
+		headers = append(headers, *nodes[idx].header)
 	}
 	return headers
 }
@@ -970,3 +1021,53 @@ func TestIntervalBlockHashes(t *testing.T) {
 		}
 	}
 }
+
+// TestInvalidateReconsiderBlock ensures invalidating a block marks all of its
+// descendants invalid and reorganizes to the best remaining chain, and that
+// reconsidering it reverts both.
+func TestInvalidateReconsiderBlock(t *testing.T) {
+	chain, params, tearDown := utxoCacheTestChain("TestInvalidateReconsiderBlock")
+	defer tearDown()
+	genesis := bchutil.NewBlock(params.GenesisBlock)
+
+	// Create the main chain b1 -> b2 -> b3 and a side chain b1 -> c2 with
+	// less work.  The side chain block spends the output of b1 so that it
+	// differs from b2.
+	b1, outs1 := addBlock(chain, genesis, nil)
+	b2, _ := addBlock(chain, b1, nil)
+	b3, _ := addBlock(chain, b2, nil)
+	c2, _ := addBlock(chain, b1, outs1)
+	c2Node := chain.index.LookupNode(c2.Hash())
+	if chain.BestSnapshot().Hash != *b3.Hash() {
+		t.Fatalf("unexpected best hash %v, want %v",
+			chain.BestSnapshot().Hash, b3.Hash())
+	}
+
+	// Invalidating b2 must switch to the side chain and mark b3 invalid.
+	if err := chain.InvalidateBlock(b2.Hash()); err != nil {
+		t.Fatalf("InvalidateBlock: unexpected error: %v", err)
+	}
+	if chain.BestSnapshot().Hash != *c2.Hash() {
+		t.Fatalf("InvalidateBlock: unexpected best hash %v, want %v",
+			chain.BestSnapshot().Hash, c2.Hash())
+	}
+	b3Node := chain.index.LookupNode(b3.Hash())
+	if !chain.index.NodeStatus(b3Node).KnownInvalid() {
+		t.Fatal("InvalidateBlock: descendant not marked invalid")
+	}
+	if chain.index.NodeStatus(c2Node).KnownInvalid() {
+		t.Fatal("InvalidateBlock: side chain marked invalid")
+	}
+
+	// Reconsidering b2 must switch back to the chain with the most work.
+	if err := chain.ReconsiderBlock(b2.Hash()); err != nil {
+		t.Fatalf("ReconsiderBlock: unexpected error: %v", err)
+	}
+	if chain.BestSnapshot().Hash != *b3.Hash() {
+		t.Fatalf("ReconsiderBlock: unexpected best hash %v, want %v",
+			chain.BestSnapshot().Hash, b3.Hash())
+	}
+	if chain.index.NodeStatus(b3Node).KnownInvalid() {
+		t.Fatal("ReconsiderBlock: descendant still marked invalid")
+	}
+}
@@ -730,6 +730,28 @@ func DeserializeUtxoEntry(serialized []byte) (*UtxoEntry, error) {
 	return entry, nil
 }
 
+// serializeUtxoCommitmentFormat serializes a Utxo into the commitment format.  It
+// is the inverse of deserializeUtxoCommitmentFormat.  The passed public key
+// script must include the token data prefix if the output carries tokens.
+func serializeUtxoCommitmentFormat(outpoint *wire.OutPoint, amount int64,
+	pkScript []byte, blockHeight int32, isCoinBase bool) []byte {
+
+	serialized := make([]byte, 52+len(pkScript))
+	copy(serialized[:32], outpoint.Hash[:])
+	binary.LittleEndian.PutUint32(serialized[32:36], outpoint.Index)
+
+	// If this is a coinbase then the least significant bit of the most
+	// significant byte of the height is set to 1.
+	binary.LittleEndian.PutUint32(serialized[36:40], uint32(blockHeight))
+	if isCoinBase {
+		serialized[39] |= 0x01
+	}
+	binary.LittleEndian.PutUint64(serialized[40:48], uint64(amount))
+	binary.LittleEndian.PutUint32(serialized[48:52], uint32(len(pkScript)))
+	copy(serialized[52:], pkScript)
+	return serialized
+}
+
 // deserializeUtxoCommitmentFormat takes a Utxo serialized in the commitment format and
 // deserializes it into an OutPoint and UtxoEntry.
 func deserializeUtxoCommitmentFormat(serialized []byte) (*wire.OutPoint, *UtxoEntry, error) {
@@ -994,8 +1016,8 @@ func dbPutABLAStateIndex(dbTx database.Tx, ablaState ABLAState, height int32) er
 	byteOrder.PutUint32(serializedHeight[:], uint32(height))
 
 	var serializedAblaState [16]byte
-	byteOrder.PutUint64(serializedAblaState[:], ablaState.controlBlockSize)
-	byteOrder.PutUint64(serializedAblaState[:], ablaState.elasticBufferSize)
+	byteOrder.PutUint64(serializedAblaState[0:8], ablaState.controlBlockSize)
+	byteOrder.PutUint64(serializedAblaState[8:16], ablaState.elasticBufferSize)
 
 	// Add the block hash to height mapping to the index.
 	meta := dbTx.Metadata()
@@ -1469,6 +1491,7 @@ func (b *BlockChain) initChainState(fastSync bool) error {
 			node := new(blockNode)
 			initBlockNode(node, header, parent)
 			node.status = status
+			node.header = nil // Loaded on demand.
 			b.index.addNode(node)
 
 			lastNode = node
@@ -1488,18 +1511,20 @@ func (b *BlockChain) initChainState(fastSync bool) error {
 		if err == nil {
 			b.ablaState = *ablaState
 		} else {
-			// check if upgrade is active for block
-			if tip.height > b.chainParams.ABLAForkHeight {
+			// check if upgrade is active for block, the ABLA state
+			// is not tracked without the block sizes.
+			if tip.height > b.chainParams.ABLAForkHeight && !b.headersOnly {
 				return AssertError(fmt.Sprintf("initChainState: cannot find "+
 					"ABLA state index for block at height: %d", tip.height))
 			}
 		}
 
-		// Load the raw block bytes for the best block.
+		// Load the raw block bytes for the best block.  Blocks are not
+		// stored in headers only mode.
 		var block wire.MsgBlock
 		var blockBytes []byte
 		lastCheckpoint := b.LatestCheckpoint()
-		if !fastSync || (lastCheckpoint != nil && tip.height > lastCheckpoint.Height) {
+		if !b.headersOnly && (!fastSync || (lastCheckpoint != nil && tip.height > lastCheckpoint.Height)) {
 			blockBytes, err = dbTx.FetchBlock(&state.hash)
 			if err != nil {
 				return err
@@ -1614,12 +1639,36 @@ func dbFetchBlockByNode(dbTx database.Tx, node *blockNode) (*bchutil.Block, erro
 	return block, nil
 }
 
+// dbFetchBlockNodeHeader uses an existing database transaction to retrieve the
+// header of the provided node from the block index bucket.
+func dbFetchBlockNodeHeader(dbTx database.Tx, node *blockNode) (*wire.BlockHeader, error) {
+	blockIndexBucket := dbTx.Metadata().Bucket(blockIndexBucketName)
+	key := blockIndexKey(&node.hash, uint32(node.height))
+	blockRow := blockIndexBucket.Get(key)
+	if blockRow == nil {
+		return nil, fmt.Errorf("block %s is not in the block index",
+			node.hash)
+	}
+	header, _, err := deserializeBlockRow(blockRow)
+	return header, err
+}
+
 // dbStoreBlockNode stores the block header and validation status to the block
 // index bucket. This overwrites the current entry if there exists one.
 func dbStoreBlockNode(dbTx database.Tx, node *blockNode) error {
+	// The header of a node which was already written is loaded from the
+	// existing entry.
+	header := node.header
+	if header == nil {
+		var err error
+		header, err = dbFetchBlockNodeHeader(dbTx, node)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Serialize block data to be stored.
 	w := bytes.NewBuffer(make([]byte, 0, blockHdrSize+1))
-	header := node.Header()
 	err := header.Serialize(w)
 	if err != nil {
 		return err
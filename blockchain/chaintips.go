@@ -0,0 +1,231 @@
+package blockchain
+
+import (
+	"sort"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/database"
+)
+
+// invalidBlockReasonsBucketName is the name of the db bucket used to house the
+// reasons blocks failed validation for, keyed by block hash.
+var invalidBlockReasonsBucketName = []byte("invalidblockreasons")
+
+// ChainTipStatus describes the state of the branch of the block tree which
+// ends at a chain tip.
+type ChainTipStatus int
+
+const (
+	// ChainTipActive indicates the tip is the tip of the main chain.
+	ChainTipActive ChainTipStatus = iota
+
+	// ChainTipValidFork indicates the branch is fully validated but not
+	// part of the main chain since it has less work.
+	ChainTipValidFork
+
+	// ChainTipValidHeaders indicates all of the blocks of the branch are
+	// available but not all of them are validated yet.
+	ChainTipValidHeaders
+
+	// ChainTipHeadersOnly indicates not all of the blocks of the branch
+	// are available, only their headers.
+	ChainTipHeadersOnly
+
+	// ChainTipInvalid indicates the branch contains at least one block
+	// which failed validation.
+	ChainTipInvalid
+)
+
+// chainTipStatusStrings is a map of chain tip statuses back to their constant
+// names for pretty printing.  They match the names used by other nodes.
+var chainTipStatusStrings = map[ChainTipStatus]string{
+	ChainTipActive:       "active",
+	ChainTipValidFork:    "valid-fork",
+	ChainTipValidHeaders: "valid-headers",
+	ChainTipHeadersOnly:  "headers-only",
+	ChainTipInvalid:      "invalid",
+}
+
+// String returns the ChainTipStatus as a human-readable name.
+func (s ChainTipStatus) String() string {
+	if str, ok := chainTipStatusStrings[s]; ok {
+		return str
+	}
+	return "unknown"
+}
+
+// ChainTip describes a block of the block index which no other block builds
+// on, or the tip of the main chain.
+type ChainTip struct {
+	// Hash is the hash of the tip.
+	Hash chainhash.Hash
+
+	// Height is the height of the tip.
+	Height int32
+
+	// BranchLen is the number of blocks of the branch after the block it
+	// forks off the main chain at.  It is zero for the main chain.
+	BranchLen int32
+
+	// Status is the state of the branch.
+	Status ChainTipStatus
+
+	// InvalidBlock is the hash of the first block of an invalid branch
+	// which failed validation.  It is only set for invalid branches.
+	InvalidBlock *chainhash.Hash
+
+	// InvalidReason is the reason the invalid block failed validation.  It
+	// is empty when the reason is not known, such as for blocks which
+	// failed validation before the reasons were recorded.
+	InvalidReason string
+}
+
+// ChainTips returns all of the chain tips known to the block index, which are
+// the tip of the main chain and the tips of all of the branches forking off it,
+// ordered by descending height and then ascending branch length.  This allows
+// monitoring the network for forks and the reorganizations they cause.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ChainTips() ([]ChainTip, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	// Every block which is not the parent of another block is a tip.  The
+	// parents are tracked by hash since reprocessing a reconsidered block
+	// replaces its node in the index.
+	b.index.RLock()
+	parents := make(map[chainhash.Hash]struct{}, len(b.index.index))
+	for _, node := range b.index.index {
+		if node.parent != nil {
+			parents[node.parent.hash] = struct{}{}
+		}
+	}
+	bestTip := b.bestChain.Tip()
+	var tipNodes []*blockNode
+	for _, node := range b.index.index {
+		if _, ok := parents[node.hash]; !ok || node == bestTip {
+			tipNodes = append(tipNodes, node)
+		}
+	}
+	b.index.RUnlock()
+
+	tips := make([]ChainTip, 0, len(tipNodes))
+	err := b.db.View(func(dbTx database.Tx) error {
+		for _, node := range tipNodes {
+			tip := ChainTip{
+				Hash:      node.hash,
+				Height:    node.height,
+				BranchLen: node.height - b.bestChain.FindFork(node).height,
+			}
+			tip.Status = b.chainTipStatus(node)
+			if tip.Status == ChainTipInvalid {
+				invalid := b.firstInvalidBlock(node)
+				tip.InvalidBlock = &invalid.hash
+				tip.InvalidReason = dbFetchInvalidBlockReason(dbTx,
+					&invalid.hash)
+			}
+			tips = append(tips, tip)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Order the tips by descending height, favoring the shortest branches
+	// so the main chain comes first among tips of the same height.
+	sort.Slice(tips, func(i, j int) bool {
+		if tips[i].Height != tips[j].Height {
+			return tips[i].Height > tips[j].Height
+		}
+		return tips[i].BranchLen < tips[j].BranchLen
+	})
+	return tips, nil
+}
+
+// chainTipStatus returns the state of the branch which ends at the passed tip.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) chainTipStatus(tip *blockNode) ChainTipStatus {
+	if tip == b.bestChain.Tip() {
+		return ChainTipActive
+	}
+	if b.index.NodeStatus(tip).KnownInvalid() {
+		return ChainTipInvalid
+	}
+
+	// Walk the branch back to the main chain to find out whether all of its
+	// blocks are available and validated.
+	status := ChainTipValidFork
+	for n := tip; n != nil && !b.bestChain.Contains(n); n = n.parent {
+		nodeStatus := b.index.NodeStatus(n)
+		if !nodeStatus.HaveData() {
+			return ChainTipHeadersOnly
+		}
+		if !nodeStatus.KnownValid() {
+			status = ChainTipValidHeaders
+		}
+	}
+	return status
+}
+
+// firstInvalidBlock returns the block of the branch which ends at the passed
+// invalid tip which failed validation itself.  It returns the tip when none of
+// its ancestors is marked as failed, which happens when the tip was marked as
+// having an invalid ancestor by an older version.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) firstInvalidBlock(tip *blockNode) *blockNode {
+	invalid := tip
+	for n := tip; n != nil && b.index.NodeStatus(n).KnownInvalid(); n = n.parent {
+		if b.index.NodeStatus(n)&statusValidateFailed != 0 {
+			invalid = n
+		}
+	}
+	return invalid
+}
+
+// dbFetchInvalidBlockReason uses an existing database transaction to retrieve
+// the reason the block with the passed hash failed validation.  It returns an
+// empty string when the reason is not known.
+func dbFetchInvalidBlockReason(dbTx database.Tx, hash *chainhash.Hash) string {
+	bucket := dbTx.Metadata().Bucket(invalidBlockReasonsBucketName)
+	if bucket == nil {
+		return ""
+	}
+	return string(bucket.Get(hash[:]))
+}
+
+// storeInvalidBlockReason persists the reason the passed block failed
+// validation so it can be reported along with the chain tips, including after
+// restarts.  Failing to store it is not fatal, so it is only logged.
+func (b *BlockChain) storeInvalidBlockReason(node *blockNode, reason string) {
+	err := b.db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		bucket, err := meta.CreateBucketIfNotExists(invalidBlockReasonsBucketName)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(node.hash[:], []byte(reason))
+	})
+	if err != nil {
+		log.Warnf("Unable to store the reason block %v is invalid: %v",
+			node.hash, err)
+	}
+}
+
+// removeInvalidBlockReason removes the stored reason the passed block failed
+// validation once it is no longer considered invalid.
+func (b *BlockChain) removeInvalidBlockReason(node *blockNode) {
+	err := b.db.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(invalidBlockReasonsBucketName)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete(node.hash[:])
+	})
+	if err != nil {
+		log.Warnf("Unable to remove the reason block %v is invalid: %v",
+			node.hash, err)
+	}
+}
@@ -0,0 +1,107 @@
+package blockchain
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchutil"
+)
+
+// TestChainTips ensures the chain tips along with their status are reported
+// as expected as blocks are invalidated and reconsidered, and that the reason a
+// block is invalid is persisted until it is reconsidered.
+func TestChainTips(t *testing.T) {
+	chain, params, tearDown := utxoCacheTestChain("TestChainTips")
+	defer tearDown()
+	genesis := bchutil.NewBlock(params.GenesisBlock)
+
+	// Create the main chain b1 -> b2 -> b3 and a side chain b1 -> c2.
+	b1, outs1 := addBlock(chain, genesis, nil)
+	b2, _ := addBlock(chain, b1, nil)
+	b3, _ := addBlock(chain, b2, nil)
+	c2, _ := addBlock(chain, b1, outs1)
+
+	tips, err := chain.ChainTips()
+	if err != nil {
+		t.Fatalf("ChainTips: unexpected error: %v", err)
+	}
+	want := []ChainTip{
+		{Hash: *b3.Hash(), Height: 3, Status: ChainTipActive},
+		{Hash: *c2.Hash(), Height: 2, BranchLen: 1,
+			Status: ChainTipValidHeaders},
+	}
+	if !reflect.DeepEqual(tips, want) {
+		t.Fatalf("ChainTips: unexpected tips -- got %+v, want %+v",
+			tips, want)
+	}
+
+	// Invalidating b2 makes the side chain active and the old main chain
+	// invalid along with the reason.
+	if err := chain.InvalidateBlock(b2.Hash()); err != nil {
+		t.Fatalf("InvalidateBlock: unexpected error: %v", err)
+	}
+	tips, err = chain.ChainTips()
+	if err != nil {
+		t.Fatalf("ChainTips: unexpected error: %v", err)
+	}
+	want = []ChainTip{
+		{Hash: *b3.Hash(), Height: 3, BranchLen: 2,
+			Status: ChainTipInvalid, InvalidBlock: b2.Hash(),
+			InvalidReason: "marked invalid manually"},
+		{Hash: *c2.Hash(), Height: 2, Status: ChainTipActive},
+	}
+	if !reflect.DeepEqual(tips, want) {
+		t.Fatalf("ChainTips: unexpected tips -- got %+v, want %+v",
+			tips, want)
+	}
+
+	// Reconsidering b2 switches back and removes the stored reason.  The
+	// side chain is fully validated now since it was connected.
+	if err := chain.ReconsiderBlock(b2.Hash()); err != nil {
+		t.Fatalf("ReconsiderBlock: unexpected error: %v", err)
+	}
+	tips, err = chain.ChainTips()
+	if err != nil {
+		t.Fatalf("ChainTips: unexpected error: %v", err)
+	}
+	want = []ChainTip{
+		{Hash: *b3.Hash(), Height: 3, Status: ChainTipActive},
+		{Hash: *c2.Hash(), Height: 2, BranchLen: 1,
+			Status: ChainTipValidFork},
+	}
+	if !reflect.DeepEqual(tips, want) {
+		t.Fatalf("ChainTips: unexpected tips -- got %+v, want %+v",
+			tips, want)
+	}
+	err = chain.db.View(func(dbTx database.Tx) error {
+		if reason := dbFetchInvalidBlockReason(dbTx, b2.Hash()); reason != "" {
+			t.Fatalf("unexpected stored reason %q", reason)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+}
+
+// TestChainTipStatusString tests the stringized output for the chain tip
+// status type.
+func TestChainTipStatusString(t *testing.T) {
+	tests := []struct {
+		in   ChainTipStatus
+		want string
+	}{
+		{ChainTipActive, "active"},
+		{ChainTipValidFork, "valid-fork"},
+		{ChainTipValidHeaders, "valid-headers"},
+		{ChainTipHeadersOnly, "headers-only"},
+		{ChainTipInvalid, "invalid"},
+		{0xff, "unknown"},
+	}
+	for _, test := range tests {
+		if got := test.in.String(); got != test.want {
+			t.Errorf("String: got %q, want %q", got, test.want)
+		}
+	}
+}
@@ -6,6 +6,7 @@ package blockchain
 
 import (
 	"fmt"
+	"math/big"
 	"time"
 
 	"github.com/gcash/bchd/chaincfg"
@@ -259,3 +260,57 @@ func (b *BlockChain) IsCheckpointCandidate(block *bchutil.Block) (bool, error) {
 	// All of the checks passed, so the block is a candidate.
 	return true, nil
 }
+
+// CheckpointCandidate is a block of the main chain which is a good checkpoint
+// candidate along with the cumulative work of the main chain up to and
+// including it.
+type CheckpointCandidate struct {
+	chaincfg.Checkpoint
+	Timestamp time.Time
+	ChainWork *big.Int
+}
+
+// CheckpointCandidates returns up to the passed number of checkpoint
+// candidates, newest first, by scanning the main chain backwards starting with
+// the newest block which is deep enough to be a candidate.  At most one
+// candidate is returned for every interval of blocks.  See
+// IsCheckpointCandidate for the factors used to determine a good candidate.
+//
+// The chain work of the newest candidate is a suitable minimum chain work for
+// the network since it is covered by as many confirmations as a checkpoint.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) CheckpointCandidates(count int, interval int32) ([]CheckpointCandidate, error) {
+	if interval < 1 {
+		interval = 1
+	}
+
+	var candidates []CheckpointCandidate
+	height := b.BestSnapshot().Height - CheckpointConfirmations
+	for height > 0 && len(candidates) < count {
+		block, err := b.BlockByHeight(height)
+		if err != nil {
+			return nil, err
+		}
+		isCandidate, err := b.IsCheckpointCandidate(block)
+		if err != nil {
+			return nil, err
+		}
+		if !isCandidate {
+			height--
+			continue
+		}
+
+		node := b.index.LookupNode(block.Hash())
+		candidates = append(candidates, CheckpointCandidate{
+			Checkpoint: chaincfg.Checkpoint{
+				Height: height,
+				Hash:   block.Hash(),
+			},
+			Timestamp: block.MsgBlock().Header.Timestamp,
+			ChainWork: new(big.Int).Set(node.workSum),
+		})
+		height -= interval
+	}
+	return candidates, nil
+}
@@ -6,6 +6,9 @@ package blockchain
 
 import (
 	"fmt"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchutil"
 )
 
 // DeploymentError identifies an error that indicates a deployment ID was
@@ -226,54 +229,95 @@ const (
 
 	// ErrCashTokensValidation indicates the token data is invalid in some way
 	ErrCashTokensValidation
+
+	// ErrBadTokenData indicates an output carries token data which is not
+	// encoded as required by the CashTokens consensus rules, such as an
+	// invalid bitfield or a fungible amount which is out of range.
+	ErrBadTokenData
+
+	// ErrTokenAmountInflation indicates the outputs of a transaction carry
+	// more fungible tokens of a category than its inputs, and the
+	// transaction is not the genesis of the category.
+	ErrTokenAmountInflation
+
+	// ErrTokenMintingNotAllowed indicates an output carries a minting
+	// token of a category the transaction neither spends a minting token
+	// of nor is the genesis of.
+	ErrTokenMintingNotAllowed
+
+	// ErrTokenMutableNotAllowed indicates the outputs of a transaction
+	// carry more mutable tokens of a category than it spends, and the
+	// transaction may not mint tokens of the category.
+	ErrTokenMutableNotAllowed
+
+	// ErrTokenImmutableNotAllowed indicates an output carries an immutable
+	// non-fungible token which the transaction neither spends, nor may
+	// mint, nor creates from a mutable token of the category.
+	ErrTokenImmutableNotAllowed
+
+	// ErrBadSignetSolution indicates the coinbase of a block on a signed
+	// test network does not commit to a signature solution or the solution
+	// does not satisfy the signet challenge of the network.
+	ErrBadSignetSolution
+
+	// ErrStaleWeakBlock indicates a weak block does not extend the tip of
+	// the main chain.
+	ErrStaleWeakBlock
 )
 
 // Map of ErrorCode values back to their constant names for pretty printing.
 var errorCodeStrings = map[ErrorCode]string{
-	ErrDuplicateBlock:        "ErrDuplicateBlock",
-	ErrBlockTooBig:           "ErrBlockTooBig",
-	ErrBlockVersionTooOld:    "ErrBlockVersionTooOld",
-	ErrInvalidTime:           "ErrInvalidTime",
-	ErrTimeTooOld:            "ErrTimeTooOld",
-	ErrTimeTooNew:            "ErrTimeTooNew",
-	ErrDifficultyTooLow:      "ErrDifficultyTooLow",
-	ErrUnexpectedDifficulty:  "ErrUnexpectedDifficulty",
-	ErrHighHash:              "ErrHighHash",
-	ErrBadMerkleRoot:         "ErrBadMerkleRoot",
-	ErrBadCheckpoint:         "ErrBadCheckpoint",
-	ErrForkTooOld:            "ErrForkTooOld",
-	ErrCheckpointTimeTooOld:  "ErrCheckpointTimeTooOld",
-	ErrNoTransactions:        "ErrNoTransactions",
-	ErrNoTxInputs:            "ErrNoTxInputs",
-	ErrNoTxOutputs:           "ErrNoTxOutputs",
-	ErrTxTooBig:              "ErrTxTooBig",
-	ErrTxTooSmall:            "ErrTxTooSmall",
-	ErrBadTxOutValue:         "ErrBadTxOutValue",
-	ErrDuplicateTxInputs:     "ErrDuplicateTxInputs",
-	ErrBadTxInput:            "ErrBadTxInput",
-	ErrMissingTxOut:          "ErrMissingTxOut",
-	ErrSpentTxOut:            "ErrSpentTxOut",
-	ErrUnfinalizedTx:         "ErrUnfinalizedTx",
-	ErrDuplicateTx:           "ErrDuplicateTx",
-	ErrOverwriteTx:           "ErrOverwriteTx",
-	ErrImmatureSpend:         "ErrImmatureSpend",
-	ErrSpendTooHigh:          "ErrSpendTooHigh",
-	ErrBadFees:               "ErrBadFees",
-	ErrFirstTxNotCoinbase:    "ErrFirstTxNotCoinbase",
-	ErrMultipleCoinbases:     "ErrMultipleCoinbases",
-	ErrBadCoinbaseScriptLen:  "ErrBadCoinbaseScriptLen",
-	ErrBadCoinbaseValue:      "ErrBadCoinbaseValue",
-	ErrMissingCoinbaseHeight: "ErrMissingCoinbaseHeight",
-	ErrBadCoinbaseHeight:     "ErrBadCoinbaseHeight",
-	ErrScriptMalformed:       "ErrScriptMalformed",
-	ErrScriptValidation:      "ErrScriptValidation",
-	ErrPreviousBlockUnknown:  "ErrPreviousBlockUnknown",
-	ErrInvalidAncestorBlock:  "ErrInvalidAncestorBlock",
-	ErrPrevBlockNotBest:      "ErrPrevBlockNotBest",
-	ErrInvalidTxOrder:        "ErrInvalidTxOrder",
-	ErrTooManySigChecks:      "ErrTooManySigChecks",
-	ErrTxTooManySigChecks:    "ErrTxTooManySigChecks",
-	ErrCashTokensValidation:  "ErrCashTokensValidation",
+	ErrDuplicateBlock:           "ErrDuplicateBlock",
+	ErrBlockTooBig:              "ErrBlockTooBig",
+	ErrBlockVersionTooOld:       "ErrBlockVersionTooOld",
+	ErrInvalidTime:              "ErrInvalidTime",
+	ErrTimeTooOld:               "ErrTimeTooOld",
+	ErrTimeTooNew:               "ErrTimeTooNew",
+	ErrDifficultyTooLow:         "ErrDifficultyTooLow",
+	ErrUnexpectedDifficulty:     "ErrUnexpectedDifficulty",
+	ErrHighHash:                 "ErrHighHash",
+	ErrBadMerkleRoot:            "ErrBadMerkleRoot",
+	ErrBadCheckpoint:            "ErrBadCheckpoint",
+	ErrForkTooOld:               "ErrForkTooOld",
+	ErrCheckpointTimeTooOld:     "ErrCheckpointTimeTooOld",
+	ErrNoTransactions:           "ErrNoTransactions",
+	ErrNoTxInputs:               "ErrNoTxInputs",
+	ErrNoTxOutputs:              "ErrNoTxOutputs",
+	ErrTxTooBig:                 "ErrTxTooBig",
+	ErrTxTooSmall:               "ErrTxTooSmall",
+	ErrBadTxOutValue:            "ErrBadTxOutValue",
+	ErrDuplicateTxInputs:        "ErrDuplicateTxInputs",
+	ErrBadTxInput:               "ErrBadTxInput",
+	ErrMissingTxOut:             "ErrMissingTxOut",
+	ErrSpentTxOut:               "ErrSpentTxOut",
+	ErrUnfinalizedTx:            "ErrUnfinalizedTx",
+	ErrDuplicateTx:              "ErrDuplicateTx",
+	ErrOverwriteTx:              "ErrOverwriteTx",
+	ErrImmatureSpend:            "ErrImmatureSpend",
+	ErrSpendTooHigh:             "ErrSpendTooHigh",
+	ErrBadFees:                  "ErrBadFees",
+	ErrFirstTxNotCoinbase:       "ErrFirstTxNotCoinbase",
+	ErrMultipleCoinbases:        "ErrMultipleCoinbases",
+	ErrBadCoinbaseScriptLen:     "ErrBadCoinbaseScriptLen",
+	ErrBadCoinbaseValue:         "ErrBadCoinbaseValue",
+	ErrMissingCoinbaseHeight:    "ErrMissingCoinbaseHeight",
+	ErrBadCoinbaseHeight:        "ErrBadCoinbaseHeight",
+	ErrScriptMalformed:          "ErrScriptMalformed",
+	ErrScriptValidation:         "ErrScriptValidation",
+	ErrPreviousBlockUnknown:     "ErrPreviousBlockUnknown",
+	ErrInvalidAncestorBlock:     "ErrInvalidAncestorBlock",
+	ErrPrevBlockNotBest:         "ErrPrevBlockNotBest",
+	ErrInvalidTxOrder:           "ErrInvalidTxOrder",
+	ErrTooManySigChecks:         "ErrTooManySigChecks",
+	ErrTxTooManySigChecks:       "ErrTxTooManySigChecks",
+	ErrCashTokensValidation:     "ErrCashTokensValidation",
+	ErrBadTokenData:             "ErrBadTokenData",
+	ErrTokenAmountInflation:     "ErrTokenAmountInflation",
+	ErrTokenMintingNotAllowed:   "ErrTokenMintingNotAllowed",
+	ErrTokenMutableNotAllowed:   "ErrTokenMutableNotAllowed",
+	ErrTokenImmutableNotAllowed: "ErrTokenImmutableNotAllowed",
+	ErrBadSignetSolution:        "ErrBadSignetSolution",
+	ErrStaleWeakBlock:           "ErrStaleWeakBlock",
 }
 
 // String returns the ErrorCode as a human-readable name.
@@ -290,8 +334,37 @@ func (e ErrorCode) String() string {
 // specifically due to a rule violation and access the ErrorCode field to
 // ascertain the specific reason for the rule violation.
 type RuleError struct {
-	ErrorCode   ErrorCode // Describes the kind of error
-	Description string    // Human readable description of the issue
+	ErrorCode   ErrorCode        // Describes the kind of error
+	Description string           // Human readable description of the issue
+	Detail      *RuleErrorDetail // Location of the violation, if known
+}
+
+// RuleErrorDetail locates a rule violation which was caused by a specific
+// transaction, and for script failures by a specific input and opcode, so that
+// the creator of a rejected block can tell exactly what was wrong with it.
+type RuleErrorDetail struct {
+	// TxIndex is the index of the offending transaction within the block,
+	// or -1 when the transaction was not validated as part of a block.
+	TxIndex int
+
+	// TxHash is the hash of the offending transaction.
+	TxHash chainhash.Hash
+
+	// InputIndex is the index of the offending input, or -1 when the
+	// violation is not specific to an input.
+	InputIndex int
+
+	// ScriptErr is the error returned by the script engine when the
+	// violation is a script failure.  It is a txscript.Error.
+	ScriptErr error
+
+	// ScriptIndex and OpcodeIndex are the index of the script and the
+	// offset of the opcode within it at which script execution failed, as
+	// reported by txscript.Engine.ScriptPosition.  Both are -1 when the
+	// violation is not a script failure or the scripts failed before any
+	// opcode was executed.
+	ScriptIndex int
+	OpcodeIndex int
 }
 
 // Error satisfies the error interface and prints human-readable errors.
@@ -303,3 +376,27 @@ func (e RuleError) Error() string {
 func ruleError(c ErrorCode, desc string) RuleError {
 	return RuleError{ErrorCode: c, Description: desc}
 }
+
+// txRuleError attaches the location of the passed transaction to err when it
+// is a RuleError which has not been located yet.  Other errors are returned
+// unchanged.
+func txRuleError(err error, txIndex int, tx *bchutil.Tx) error {
+	rerr, ok := err.(RuleError)
+	if !ok {
+		return err
+	}
+	if rerr.Detail == nil {
+		rerr.Detail = &RuleErrorDetail{
+			TxIndex:     txIndex,
+			TxHash:      *tx.Hash(),
+			InputIndex:  -1,
+			ScriptIndex: -1,
+			OpcodeIndex: -1,
+		}
+	} else if rerr.Detail.TxIndex == -1 {
+		detail := *rerr.Detail
+		detail.TxIndex = txIndex
+		rerr.Detail = &detail
+	}
+	return rerr
+}
@@ -5,7 +5,11 @@
 package blockchain
 
 import (
+	"errors"
 	"testing"
+
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
 )
 
 // TestErrorCodeStringer tests the stringized output for the ErrorCode type.
@@ -56,6 +60,14 @@ func TestErrorCodeStringer(t *testing.T) {
 		{ErrPrevBlockNotBest, "ErrPrevBlockNotBest"},
 		{ErrTooManySigChecks, "ErrTooManySigChecks"},
 		{ErrTxTooManySigChecks, "ErrTxTooManySigChecks"},
+		{ErrCashTokensValidation, "ErrCashTokensValidation"},
+		{ErrBadTokenData, "ErrBadTokenData"},
+		{ErrTokenAmountInflation, "ErrTokenAmountInflation"},
+		{ErrTokenMintingNotAllowed, "ErrTokenMintingNotAllowed"},
+		{ErrTokenMutableNotAllowed, "ErrTokenMutableNotAllowed"},
+		{ErrTokenImmutableNotAllowed, "ErrTokenImmutableNotAllowed"},
+		{ErrBadSignetSolution, "ErrBadSignetSolution"},
+		{ErrStaleWeakBlock, "ErrStaleWeakBlock"},
 		{0xffff, "Unknown ErrorCode (65535)"},
 	}
 
@@ -97,6 +109,50 @@ func TestRuleError(t *testing.T) {
 	}
 }
 
+// TestTxRuleError ensures rule errors are located at the offending transaction
+// without overwriting a more precise location.
+func TestTxRuleError(t *testing.T) {
+	tx := bchutil.NewTx(wire.NewMsgTx(1))
+
+	// Errors which are not rule errors are returned unchanged.
+	otherErr := errors.New("not a rule error")
+	if err := txRuleError(otherErr, 3, tx); err != otherErr {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// Rule errors without a location are located at the transaction.
+	err := txRuleError(ruleError(ErrBadTxOutValue, "bad"), 3, tx)
+	rerr, ok := err.(RuleError)
+	if !ok || rerr.Detail == nil {
+		t.Fatalf("error %v was not located", err)
+	}
+	if rerr.Detail.TxIndex != 3 || rerr.Detail.TxHash != *tx.Hash() ||
+		rerr.Detail.InputIndex != -1 || rerr.Detail.ScriptIndex != -1 {
+		t.Fatalf("unexpected detail %+v", rerr.Detail)
+	}
+
+	// Script failures located at an input keep their location and only
+	// gain the index of the transaction in the block.
+	scriptErr := RuleError{
+		ErrorCode: ErrScriptValidation,
+		Detail: &RuleErrorDetail{
+			TxIndex:     -1,
+			TxHash:      *tx.Hash(),
+			InputIndex:  1,
+			ScriptIndex: 1,
+			OpcodeIndex: 4,
+		},
+	}
+	rerr = txRuleError(scriptErr, 5, tx).(RuleError)
+	if rerr.Detail.TxIndex != 5 || rerr.Detail.InputIndex != 1 ||
+		rerr.Detail.OpcodeIndex != 4 {
+		t.Fatalf("unexpected detail %+v", rerr.Detail)
+	}
+	if scriptErr.Detail.TxIndex != -1 {
+		t.Fatal("original detail was modified")
+	}
+}
+
 // TestDeploymentError tests the stringized output for the DeploymentError type.
 func TestDeploymentError(t *testing.T) {
 	t.Parallel()
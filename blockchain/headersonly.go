@@ -0,0 +1,304 @@
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+var (
+	// headersOnlyBlockchainEntryValue is the value the corresponds to a
+	// blockchain which only stores the block headers.
+	headersOnlyBlockchainEntryValue = []byte("headersonlyblockchain")
+
+	// headersOnlyBlockTipKeyName is the name of the db key used to store
+	// the hash of the last block of the main chain which was passed to the
+	// index manager in headers only mode.
+	headersOnlyBlockTipKeyName = []byte("headersonlyblocktip")
+)
+
+// dbPutHeadersOnlyBlockTip uses an existing database transaction to update
+// the hash of the last block passed to the index manager in headers only mode.
+func dbPutHeadersOnlyBlockTip(dbTx database.Tx, hash *chainhash.Hash) error {
+	return dbTx.Metadata().Put(headersOnlyBlockTipKeyName, hash[:])
+}
+
+// dbFetchHeadersOnlyBlockTip uses an existing database transaction to retrieve
+// the hash of the last block passed to the index manager in headers only mode.
+// The returned hash is nil if nothing is found.
+func dbFetchHeadersOnlyBlockTip(dbTx database.Tx) (*chainhash.Hash, error) {
+	serialized := dbTx.Metadata().Get(headersOnlyBlockTipKeyName)
+	if serialized == nil {
+		return nil, nil
+	}
+	return chainhash.NewHash(serialized)
+}
+
+// maybeSetHeadersOnlyChainType marks the database as only storing the block
+// headers when the chain runs in headers only mode.  It returns an error when
+// the database was used in another mode before, since a headers only chain is
+// missing the blocks and the utxo set, while other chains contain blocks which
+// are not tracked in headers only mode.
+func (b *BlockChain) maybeSetHeadersOnlyChainType() error {
+	return b.db.Update(func(dbTx database.Tx) error {
+		chainType := dbFetchBlockchainType(dbTx)
+		if bytes.Equal(chainType, headersOnlyBlockchainEntryValue) {
+			if !b.headersOnly {
+				return AssertError("the database was created in " +
+					"headers only mode and can only be used in " +
+					"that mode")
+			}
+			return nil
+		}
+		if !b.headersOnly {
+			return nil
+		}
+
+		// Only a database which does not contain any block other than
+		// the genesis block can be switched to headers only mode.
+		if chainType != nil || b.bestChain.Tip().height > 0 {
+			return AssertError("headers only mode requires a new " +
+				"database")
+		}
+		return dbPutBlockchainType(dbTx, headersOnlyBlockchainEntryValue)
+	})
+}
+
+// initHeadersOnlyBlockTip loads the last block passed to the index manager in
+// headers only mode.  The genesis block is passed to the index manager right
+// away when the chain is created since it does not have to be downloaded.
+func (b *BlockChain) initHeadersOnlyBlockTip() error {
+	var tipHash *chainhash.Hash
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		tipHash, err = dbFetchHeadersOnlyBlockTip(dbTx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if tipHash == nil {
+		return b.indexBlock(bchutil.NewBlock(b.chainParams.GenesisBlock))
+	}
+
+	node := b.index.LookupNode(tipHash)
+	if node == nil || !b.bestChain.Contains(node) {
+		return AssertError(fmt.Sprintf("initHeadersOnlyBlockTip: block "+
+			"tip %s is not in the main chain", tipHash))
+	}
+	b.blockTip = node
+	return nil
+}
+
+// HeadersOnlyBlockTip returns the hash and height of the last block of the main
+// chain which was passed to the index manager in headers only mode.  The blocks
+// after it must be passed to IndexBlock in order.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) HeadersOnlyBlockTip() (*chainhash.Hash, int32) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	if b.blockTip == nil {
+		return nil, -1
+	}
+	return &b.blockTip.hash, b.blockTip.height
+}
+
+// ProcessBlockHeader validates the passed block header and adds it to the block
+// index in headers only mode.  The main chain is the chain of headers with the
+// most proof of work, so the header becomes the new tip when it has more work
+// than the current one, reorganizing the chain if needed.  The headers of the
+// blocks removed from the main chain are disconnected from the index manager
+// when their blocks were passed to it.
+//
+// The first return value indicates whether or not the header is on the main
+// chain.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ProcessBlockHeader(header *wire.BlockHeader, flags BehaviorFlags) (bool, error) {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	if !b.headersOnly {
+		return false, AssertError("ProcessBlockHeader called when " +
+			"not in headers only mode")
+	}
+
+	blockHash := header.BlockHash()
+	if b.index.HaveBlock(&blockHash) {
+		str := fmt.Sprintf("already have block %v", blockHash)
+		return false, ruleError(ErrDuplicateBlock, str)
+	}
+
+	err := checkBlockHeaderSanity(header, b.chainParams.PowLimit, b.timeSource, flags)
+	if err != nil {
+		return false, err
+	}
+
+	prevNode := b.index.LookupNode(&header.PrevBlock)
+	if prevNode == nil {
+		str := fmt.Sprintf("previous block %s is unknown", header.PrevBlock)
+		return false, ruleError(ErrPreviousBlockUnknown, str)
+	} else if b.index.NodeStatus(prevNode).KnownInvalid() {
+		str := fmt.Sprintf("previous block %s is known to be invalid", header.PrevBlock)
+		return false, ruleError(ErrInvalidAncestorBlock, str)
+	}
+
+	if err := b.checkBlockHeaderContext(header, prevNode, flags); err != nil {
+		return false, err
+	}
+
+	// The header is as valid as it can be without the block, so it is
+	// marked as such like the headers added during a fast sync.
+	node := newBlockNode(header, prevNode)
+	node.status = statusValid
+	b.index.AddNode(node)
+	if err := b.index.flushToDB(); err != nil {
+		return false, err
+	}
+
+	if node.workSum.Cmp(b.bestChain.Tip().workSum) <= 0 {
+		log.Debugf("Added block header %v at height %d to a side chain",
+			blockHash, node.height)
+		return false, nil
+	}
+
+	if err := b.setHeadersOnlyTip(node); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// setHeadersOnlyTip makes the passed node the tip of the main chain in headers
+// only mode, removing the nodes which are no longer part of it.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) setHeadersOnlyTip(node *blockNode) error {
+	oldTip := b.bestChain.Tip()
+	fork := b.bestChain.FindFork(node)
+
+	blockTip := b.blockTip
+	state := newBestState(node, 0, 0, 0, node.CalcPastMedianTime())
+	err := b.db.Update(func(dbTx database.Tx) error {
+		// Remove the detached nodes from the main chain, starting with the
+		// tip, and disconnect the blocks which were passed to the index
+		// manager.
+		for n := oldTip; n != nil && n != fork; n = n.parent {
+			if blockTip != nil && n.height <= blockTip.height {
+				if b.indexManager != nil {
+					header, err := b.index.dbFetchHeader(dbTx, n)
+					if err != nil {
+						return err
+					}
+					block := bchutil.NewBlock(wire.NewMsgBlock(header))
+					block.SetHeight(n.height)
+					err = b.indexManager.DisconnectBlock(dbTx, block, nil)
+					if err != nil {
+						return err
+					}
+				}
+				blockTip = n.parent
+			}
+
+			if err := dbRemoveBlockIndex(dbTx, &n.hash, n.height); err != nil {
+				return err
+			}
+		}
+		if blockTip != b.blockTip {
+			if err := dbPutHeadersOnlyBlockTip(dbTx, &blockTip.hash); err != nil {
+				return err
+			}
+		}
+
+		for n := node; n != nil && n != fork; n = n.parent {
+			if err := dbPutBlockIndex(dbTx, &n.hash, n.height); err != nil {
+				return err
+			}
+		}
+
+		return dbPutBestState(dbTx, state, node.workSum)
+	})
+	if err != nil {
+		return err
+	}
+
+	if fork != oldTip {
+		log.Infof("REORGANIZE: Block header %v at height %d replaces "+
+			"%v at height %d", node.hash, node.height, oldTip.hash,
+			oldTip.height)
+	}
+
+	b.bestChain.SetTip(node)
+	b.stateSnapshot = state
+	b.blockTip = blockTip
+	return nil
+}
+
+// IndexBlock passes the transactions of the passed block to the index manager
+// in headers only mode.  The block must be the block of the main chain after
+// the one returned by HeadersOnlyBlockTip.  It is checked against its header,
+// but it is not stored.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) IndexBlock(block *bchutil.Block) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	if !b.headersOnly {
+		return AssertError("IndexBlock called when not in headers " +
+			"only mode")
+	}
+
+	return b.indexBlock(block)
+}
+
+// indexBlock passes the transactions of the passed block to the index manager
+// in headers only mode.  See IndexBlock.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) indexBlock(block *bchutil.Block) error {
+	node := b.index.LookupNode(block.Hash())
+	if node == nil || !b.bestChain.Contains(node) {
+		str := fmt.Sprintf("block %s is not in the main chain", block.Hash())
+		return errNotInMainChain(str)
+	}
+	if node.parent != b.blockTip {
+		return AssertError(fmt.Sprintf("block %s at height %d does not "+
+			"extend the last indexed block", block.Hash(), node.height))
+	}
+	block.SetHeight(node.height)
+
+	flags := BFNone
+	if node.height > b.chainParams.MagneticAnonomalyForkHeight {
+		flags |= BFMagneticAnomaly
+	}
+	if node.height > b.chainParams.Upgrade9ForkHeight {
+		flags |= BFUpgrade9
+	}
+	err := checkBlockSanity(block, b.chainParams.PowLimit, b.timeSource, flags)
+	if err != nil {
+		return err
+	}
+
+	err = b.db.Update(func(dbTx database.Tx) error {
+		if b.indexManager != nil {
+			err := b.indexManager.ConnectBlock(dbTx, block, nil)
+			if err != nil {
+				return err
+			}
+		}
+		return dbPutHeadersOnlyBlockTip(dbTx, block.Hash())
+	})
+	if err != nil {
+		return err
+	}
+
+	b.blockTip = node
+	return nil
+}
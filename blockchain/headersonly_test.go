@@ -0,0 +1,177 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchutil"
+)
+
+// testIndexManager is an index manager which records the hashes of the blocks
+// connected to and disconnected from it.
+type testIndexManager struct {
+	connected    []chainhash.Hash
+	disconnected []chainhash.Hash
+}
+
+func (m *testIndexManager) Init(*BlockChain, <-chan struct{}) error {
+	return nil
+}
+
+func (m *testIndexManager) ConnectBlock(_ database.Tx, block *bchutil.Block, _ []SpentTxOut) error {
+	m.connected = append(m.connected, *block.Hash())
+	return nil
+}
+
+func (m *testIndexManager) DisconnectBlock(_ database.Tx, block *bchutil.Block, _ []SpentTxOut) error {
+	m.disconnected = append(m.disconnected, *block.Hash())
+	return nil
+}
+
+// TestHeadersOnly ensures the main chain is tracked from the block headers
+// alone in headers only mode and the blocks of the main chain are passed to
+// the index manager in order, including across a reorganization.
+func TestHeadersOnly(t *testing.T) {
+	// Build the blocks with a regular chain, with a side chain branching
+	// off at block 2.
+	source, params, tearDownSource := utxoCacheTestChain("TestHeadersOnlySource")
+	defer tearDownSource()
+
+	genesis := bchutil.NewBlock(params.GenesisBlock)
+	b1, outs1 := addBlock(source, genesis, nil)
+	b2, outs2 := addBlock(source, b1, outs1)
+	b3a, _ := addBlock(source, b2, outs2)
+	b3b, outs3 := addBlock(source, b2, nil)
+	b4b, _ := addBlock(source, b3b, outs3)
+
+	setup, tearDown, err := chainSetup("TestHeadersOnly", params)
+	if err != nil {
+		t.Fatalf("failed to setup chain instance: %v", err)
+	}
+	defer tearDown()
+
+	newChain := func(headersOnly bool, indexManager IndexManager) (*BlockChain, error) {
+		return New(&Config{
+			DB:                 setup.db,
+			ChainParams:        params,
+			TimeSource:         NewMedianTime(),
+			SigCache:           txscript.NewSigCache(1000),
+			IndexManager:       indexManager,
+			UtxoCacheMaxSize:   250 * 1024 * 1024,
+			ExcessiveBlockSize: 32000000,
+			HeadersOnly:        headersOnly,
+		})
+	}
+	idx := &testIndexManager{}
+	chain, err := newChain(true, idx)
+	if err != nil {
+		t.Fatalf("failed to create headers only chain instance: %v", err)
+	}
+
+	// The genesis block is indexed when the chain is created.
+	if len(idx.connected) != 1 || idx.connected[0] != *params.GenesisHash {
+		t.Fatalf("unexpected connected blocks %v", idx.connected)
+	}
+
+	assertBlockTip := func(want *bchutil.Block, wantHeight int32) {
+		t.Helper()
+		hash, height := chain.HeadersOnlyBlockTip()
+		if *hash != *want.Hash() || height != wantHeight {
+			t.Fatalf("unexpected block tip %v (height %d), want %v "+
+				"(height %d)", hash, height, want.Hash(), wantHeight)
+		}
+	}
+	assertBestTip := func(want *bchutil.Block, wantHeight int32) {
+		t.Helper()
+		best := chain.BestSnapshot()
+		if best.Hash != *want.Hash() || best.Height != wantHeight {
+			t.Fatalf("unexpected best tip %v (height %d), want %v "+
+				"(height %d)", best.Hash, best.Height, want.Hash(),
+				wantHeight)
+		}
+	}
+	processHeader := func(block *bchutil.Block, wantMainChain bool) {
+		t.Helper()
+		isMainChain, err := chain.ProcessBlockHeader(&block.MsgBlock().Header, BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlockHeader: %v", err)
+		}
+		if isMainChain != wantMainChain {
+			t.Fatalf("unexpected main chain flag for header %v: got "+
+				"%v, want %v", block.Hash(), isMainChain, wantMainChain)
+		}
+	}
+	indexBlock := func(block *bchutil.Block) {
+		t.Helper()
+		if err := chain.IndexBlock(block); err != nil {
+			t.Fatalf("IndexBlock: %v", err)
+		}
+	}
+
+	processHeader(b1, true)
+	processHeader(b2, true)
+	processHeader(b3a, true)
+	assertBestTip(b3a, 3)
+	assertBlockTip(genesis, 0)
+
+	// Blocks must be indexed in order.
+	if err := chain.IndexBlock(b2); err == nil {
+		t.Fatal("IndexBlock: expected error for out of order block")
+	}
+	indexBlock(b1)
+	indexBlock(b2)
+	indexBlock(b3a)
+	assertBlockTip(b3a, 3)
+
+	// Known headers and headers with an unknown parent are rejected.
+	_, err = chain.ProcessBlockHeader(&b2.MsgBlock().Header, BFNone)
+	if !isRuleError(err, ErrDuplicateBlock) {
+		t.Fatalf("ProcessBlockHeader: expected ErrDuplicateBlock, got %v", err)
+	}
+	_, err = chain.ProcessBlockHeader(&b4b.MsgBlock().Header, BFNone)
+	if !isRuleError(err, ErrPreviousBlockUnknown) {
+		t.Fatalf("ProcessBlockHeader: expected ErrPreviousBlockUnknown, "+
+			"got %v", err)
+	}
+
+	// A side chain with the same work does not change the tip, while one
+	// with more work reorganizes the chain and disconnects the indexed
+	// blocks which are no longer in the main chain.
+	processHeader(b3b, false)
+	assertBestTip(b3a, 3)
+	processHeader(b4b, true)
+	assertBestTip(b4b, 4)
+	assertBlockTip(b2, 2)
+	if len(idx.disconnected) != 1 || idx.disconnected[0] != *b3a.Hash() {
+		t.Fatalf("unexpected disconnected blocks %v", idx.disconnected)
+	}
+
+	if err := chain.IndexBlock(b3a); err == nil {
+		t.Fatal("IndexBlock: expected error for block not in the main chain")
+	}
+	indexBlock(b3b)
+	indexBlock(b4b)
+	assertBlockTip(b4b, 4)
+
+	// The state is restored when the chain is loaded again.
+	chain, err = newChain(true, &testIndexManager{})
+	if err != nil {
+		t.Fatalf("failed to reload headers only chain instance: %v", err)
+	}
+	assertBestTip(b4b, 4)
+	assertBlockTip(b4b, 4)
+
+	// The database can not be used outside of headers only mode.
+	if _, err := newChain(false, nil); err == nil {
+		t.Fatal("New: expected error for headers only database")
+	}
+}
+
+// isRuleError returns whether or not the passed error is a rule error with the
+// passed error code.
+func isRuleError(err error, code ErrorCode) bool {
+	rerr, ok := err.(RuleError)
+	return ok && rerr.ErrorCode == code
+}
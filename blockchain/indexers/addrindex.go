@@ -5,6 +5,8 @@
 package indexers
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"sync"
@@ -61,6 +63,15 @@ const (
 	// Size of a transaction entry.  It consists of 4 bytes block id + 4
 	// bytes offset + 4 bytes length.
 	txEntrySize = 4 + 4 + 4
+
+	// balanceKeySize is the number of bytes a balance checkpoint key
+	// consumes.  It consists of the address key + 4 bytes inverted block
+	// height.
+	balanceKeySize = addrKeySize + 4
+
+	// balanceEntrySize is the number of bytes a balance checkpoint
+	// consumes.
+	balanceEntrySize = 8
 )
 
 var (
@@ -68,6 +79,10 @@ var (
 	// to house it.
 	addrIndexKey = []byte("txbyaddridx")
 
+	// addrBalanceBucketName is the name of the bucket nested in the address
+	// index which houses the balance checkpoints of the addresses.
+	addrBalanceBucketName = []byte("balances")
+
 	// errUnsupportedAddressType is an error that is used to signal an
 	// unsupported address type has been used.
 	errUnsupportedAddressType = errors.New("address type is not supported " +
@@ -517,6 +532,80 @@ func dbRemoveAddrIndexEntries(bucket internalBucket, addrKey [addrKeySize]byte,
 	return applyPending()
 }
 
+// -----------------------------------------------------------------------------
+// In addition to the transactions, the address index keeps a running balance
+// checkpoint for every address in each block which credits or debits it, so
+// the balance of an address as of any block can be looked up directly instead
+// of replaying all of its transactions.  Only outputs whose script pays a
+// single address count towards its balance, so the outputs of bare multisig
+// scripts are not attributed to any of their keys.
+//
+// The checkpoints are housed in a bucket nested in the address index.  The
+// block height in the key is inverted so the checkpoints of an address are
+// ordered from newest to oldest, which allows the latest checkpoint at or
+// below a given height to be found with a single seek.
+//
+// The serialized key format is:
+//
+//   <addr key><inverted height>
+//
+//   Field           Type      Size
+//   addr key        [33]byte  33 bytes
+//   inverted height uint32    4 bytes
+//   -----
+//   Total: 37 bytes
+//
+// The serialized value format is:
+//
+//   <balance>
+//
+//   Field           Type      Size
+//   balance         int64     8 bytes
+// -----------------------------------------------------------------------------
+
+// balanceKey returns the key of the balance checkpoint of the passed address
+// key at the passed block height.
+func balanceKey(addrKey [addrKeySize]byte, height int32) []byte {
+	key := make([]byte, balanceKeySize)
+	copy(key, addrKey[:])
+	binary.BigEndian.PutUint32(key[addrKeySize:], ^uint32(height))
+	return key
+}
+
+// dbFetchAddrBalance returns the balance of the passed address key as of the
+// passed block height using the latest checkpoint at or below it.  Addresses
+// without any checkpoint at or below the height have a zero balance.
+func dbFetchAddrBalance(bucket database.Bucket, addrKey [addrKeySize]byte, height int32) (int64, error) {
+	if height < 0 {
+		return 0, nil
+	}
+
+	cursor := bucket.Cursor()
+	if !cursor.Seek(balanceKey(addrKey, height)) ||
+		!bytes.HasPrefix(cursor.Key(), addrKey[:]) {
+
+		return 0, nil
+	}
+
+	serialized := cursor.Value()
+	if len(serialized) < balanceEntrySize {
+		return 0, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt balance checkpoint "+
+				"for address key %x", addrKey),
+		}
+	}
+	return int64(byteOrder.Uint64(serialized)), nil
+}
+
+// dbPutAddrBalance stores the balance checkpoint of the passed address key at
+// the passed block height.
+func dbPutAddrBalance(bucket database.Bucket, addrKey [addrKeySize]byte, height int32, balance int64) error {
+	var serialized [balanceEntrySize]byte
+	byteOrder.PutUint64(serialized[:], uint64(balance))
+	return bucket.Put(balanceKey(addrKey, height), serialized[:])
+}
+
 // addrToKey converts known address types to an addrindex key.  An error is
 // returned for unsupported types.
 func addrToKey(addr bchutil.Address) ([addrKeySize]byte, error) {
@@ -613,13 +702,38 @@ func (idx *AddrIndex) StartBlock() (*chainhash.Hash, int32) {
 	return nil, -1
 }
 
-// Migrate is only provided to satisfy the Indexer interface as there is nothing to
-// migrate this index.
+// Migrate drops address indexes created before balance checkpoints were
+// introduced and writes an uninitialized tip, so the IndexManager rebuilds them
+// from the blockchain as it continues its Init function.  The checkpoints can
+// not be added to an existing index since the spent outputs of the blocks it
+// covers are no longer available.
 //
 // This is part of the Indexer interface.
 func (idx *AddrIndex) Migrate(db database.DB, interrupt <-chan struct{}) error {
-	// Nothing to do.
-	return nil
+	var hasBalances bool
+	err := db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(addrIndexKey)
+		hasBalances = bucket != nil &&
+			bucket.Bucket(addrBalanceBucketName) != nil
+		return nil
+	})
+	if err != nil || hasBalances {
+		return err
+	}
+
+	log.Infof("Migrating %s to include balance checkpoints", addrIndexName)
+	if err := dropIndex(db, addrIndexKey, addrIndexName, interrupt); err != nil {
+		return err
+	}
+	return db.Update(func(dbTx database.Tx) error {
+		if err := idx.Create(dbTx); err != nil {
+			return err
+		}
+
+		// Set the tip for the index to values which represent an
+		// uninitialized index.
+		return dbPutIndexerTip(dbTx, addrIndexKey, &chainhash.Hash{}, -1)
+	})
 }
 
 // Key returns the database key to use for the index as a byte slice.
@@ -642,7 +756,11 @@ func (idx *AddrIndex) Name() string {
 //
 // This is part of the Indexer interface.
 func (idx *AddrIndex) Create(dbTx database.Tx) error {
-	_, err := dbTx.Metadata().CreateBucket(addrIndexKey)
+	bucket, err := dbTx.Metadata().CreateBucket(addrIndexKey)
+	if err != nil {
+		return err
+	}
+	_, err = bucket.CreateBucket(addrBalanceBucketName)
 	return err
 }
 
@@ -717,9 +835,46 @@ func (idx *AddrIndex) indexBlock(data writeIndexData, block *bchutil.Block,
 	}
 }
 
+// balanceAddrKey returns the address key of the single address the passed
+// public key script pays, if any, for the purposes of the balance checkpoints.
+func (idx *AddrIndex) balanceAddrKey(pkScript []byte) ([addrKeySize]byte, bool) {
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript,
+		idx.chainParams)
+	if err != nil || len(addrs) != 1 {
+		return [addrKeySize]byte{}, false
+	}
+	addrKey, err := addrToKey(addrs[0])
+	if err != nil {
+		return [addrKeySize]byte{}, false
+	}
+	return addrKey, true
+}
+
+// balanceChanges returns the net change of the balance of every address the
+// outputs created and spent by the passed block pay.
+func (idx *AddrIndex) balanceChanges(block *bchutil.Block,
+	stxos []blockchain.SpentTxOut) map[[addrKeySize]byte]int64 {
+
+	changes := make(map[[addrKeySize]byte]int64)
+	for _, stxo := range stxos {
+		if addrKey, ok := idx.balanceAddrKey(stxo.PkScript); ok {
+			changes[addrKey] -= stxo.Amount
+		}
+	}
+	for _, tx := range block.Transactions() {
+		for _, txOut := range tx.MsgTx().TxOut {
+			if addrKey, ok := idx.balanceAddrKey(txOut.PkScript); ok {
+				changes[addrKey] += txOut.Value
+			}
+		}
+	}
+	return changes
+}
+
 // ConnectBlock is invoked by the index manager when a new block has been
 // connected to the main chain.  This indexer adds a mapping for each address
-// the transactions in the block involve.
+// the transactions in the block involve along with a balance checkpoint for
+// each address the block credits or debits.
 //
 // This is part of the Indexer interface.
 func (idx *AddrIndex) ConnectBlock(dbTx database.Tx, block *bchutil.Block,
@@ -757,12 +912,30 @@ func (idx *AddrIndex) ConnectBlock(dbTx database.Tx, block *bchutil.Block,
 		}
 	}
 
+	// Add a balance checkpoint for each address the block credits or
+	// debits on top of its previous one.
+	height := block.Height()
+	balanceBucket := addrIdxBucket.Bucket(addrBalanceBucketName)
+	for addrKey, change := range idx.balanceChanges(block, stxos) {
+		balance, err := dbFetchAddrBalance(balanceBucket, addrKey,
+			height-1)
+		if err != nil {
+			return err
+		}
+		err = dbPutAddrBalance(balanceBucket, addrKey, height,
+			balance+change)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 // DisconnectBlock is invoked by the index manager when a block has been
 // disconnected from the main chain.  This indexer removes the address mappings
-// each transaction in the block involve.
+// each transaction in the block involve and the balance checkpoints of the
+// block.
 //
 // This is part of the Indexer interface.
 func (idx *AddrIndex) DisconnectBlock(dbTx database.Tx, block *bchutil.Block,
@@ -784,9 +957,46 @@ func (idx *AddrIndex) DisconnectBlock(dbTx database.Tx, block *bchutil.Block,
 		}
 	}
 
+	// Remove the balance checkpoints of the block.
+	height := block.Height()
+	balanceBucket := bucket.Bucket(addrBalanceBucketName)
+	for addrKey := range idx.balanceChanges(block, stxos) {
+		err := balanceBucket.Delete(balanceKey(addrKey, height))
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// BalanceForAddress returns the balance of the passed address as of the block
+// at the passed height, that is the total value of the outputs paying it which
+// were created at or below the height and not spent by then.
+//
+// NOTE: Only outputs confirmed in blocks are included.
+//
+// This function is safe for concurrent access.
+func (idx *AddrIndex) BalanceForAddress(addr bchutil.Address, height int32) (int64, error) {
+	addrKey, err := addrToKey(addr)
+	if err != nil {
+		return 0, err
+	}
+
+	var balance int64
+	err = idx.db.View(func(dbTx database.Tx) error {
+		addrIdxBucket := dbTx.Metadata().Bucket(addrIndexKey)
+		if addrIdxBucket == nil {
+			return fmt.Errorf("bucket nil for key: %s", addrIndexKey)
+		}
+		var err error
+		balance, err = dbFetchAddrBalance(addrIdxBucket.Bucket(
+			addrBalanceBucketName), addrKey, height)
+		return err
+	})
+	return balance, err
+}
+
 // TxRegionsForAddress returns a slice of block regions which identify each
 // transaction that involves the passed address according to the specified
 // number to skip, number requested, and whether or not the results should be
@@ -7,9 +7,18 @@ package indexers
 import (
 	"bytes"
 	"fmt"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/database"
+	_ "github.com/gcash/bchd/database/ffldb"
+	"github.com/gcash/bchd/txscript"
 	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
 )
 
 // addrIndexBucket provides a mock address index database bucket by implementing
@@ -274,3 +283,172 @@ nextTest:
 		}
 	}
 }
+
+// TestAddrIndexBalances ensures the address index keeps balance checkpoints
+// which return the balance of an address as of any block height, removes them
+// when blocks are disconnected, and rebuilds indexes created without them.
+func TestAddrIndexBalances(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	db, err := database.Create("ffldb", filepath.Join(t.TempDir(), "db"),
+		params.Net)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	txIdx := NewTxIndex(db)
+	idx := NewAddrIndex(db, params)
+	err = db.Update(func(dbTx database.Tx) error {
+		if _, err := dbTx.Metadata().CreateBucket(indexTipsBucketName); err != nil {
+			return err
+		}
+		if err := txIdx.Create(dbTx); err != nil {
+			return err
+		}
+		if err := idx.Create(dbTx); err != nil {
+			return err
+		}
+		return dbPutIndexerTip(dbTx, addrIndexKey, &chainhash.Hash{}, 0)
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	newAddr := func(b byte) bchutil.Address {
+		addr, err := bchutil.NewAddressPubKeyHash(bytes.Repeat([]byte{b},
+			20), params)
+		if err != nil {
+			t.Fatalf("NewAddressPubKeyHash: %v", err)
+		}
+		return addr
+	}
+	addrA, addrB := newAddr(0x01), newAddr(0x02)
+	scriptA, _ := txscript.PayToAddrScript(addrA)
+	scriptB, _ := txscript.PayToAddrScript(addrB)
+
+	newBlock := func(height int32, txns ...*wire.MsgTx) *bchutil.Block {
+		msgBlock := wire.NewMsgBlock(&wire.BlockHeader{
+			Timestamp: time.Unix(int64(height), 0),
+		})
+		for _, tx := range txns {
+			msgBlock.AddTransaction(tx)
+		}
+		block := bchutil.NewBlock(msgBlock)
+		block.SetHeight(height)
+		return block
+	}
+	newTx := func(spends int, outputs ...*wire.TxOut) *wire.MsgTx {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		for i := 0; i < spends; i++ {
+			tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+				uint32(i)), nil))
+		}
+		for _, txOut := range outputs {
+			tx.AddTxOut(txOut)
+		}
+		return tx
+	}
+
+	// The first block pays 5000 to A.  The second one spends it, paying
+	// 3000 to B and 1900 back to A.  The third one pays 100 to B.
+	blocks := []*bchutil.Block{
+		newBlock(1, newTx(0, wire.NewTxOut(5000, scriptA, wire.TokenData{}))),
+		newBlock(2, newTx(0), newTx(1,
+			wire.NewTxOut(3000, scriptB, wire.TokenData{}),
+			wire.NewTxOut(1900, scriptA, wire.TokenData{}))),
+		newBlock(3, newTx(0, wire.NewTxOut(100, scriptB, wire.TokenData{}))),
+	}
+	stxos := [][]blockchain.SpentTxOut{
+		nil,
+		{{Amount: 5000, PkScript: scriptA, Height: 1}},
+		nil,
+	}
+	for i, block := range blocks {
+		err := db.Update(func(dbTx database.Tx) error {
+			if err := txIdx.ConnectBlock(dbTx, block, stxos[i]); err != nil {
+				return err
+			}
+			return idx.ConnectBlock(dbTx, block, stxos[i])
+		})
+		if err != nil {
+			t.Fatalf("ConnectBlock #%d: %v", i, err)
+		}
+	}
+
+	type balanceTest struct {
+		addr   bchutil.Address
+		height int32
+		want   int64
+	}
+	checkBalances := func(name string, tests []balanceTest) {
+		t.Helper()
+		for _, test := range tests {
+			balance, err := idx.BalanceForAddress(test.addr, test.height)
+			if err != nil {
+				t.Fatalf("%s: BalanceForAddress: %v", name, err)
+			}
+			if balance != test.want {
+				t.Errorf("%s: balance of %v at height %d: got %d, "+
+					"want %d", name, test.addr, test.height,
+					balance, test.want)
+			}
+		}
+	}
+	checkBalances("connected", []balanceTest{
+		{addrA, -1, 0},
+		{addrA, 0, 0},
+		{addrA, 1, 5000},
+		{addrA, 2, 1900},
+		{addrA, 100, 1900},
+		{addrB, 1, 0},
+		{addrB, 2, 3000},
+		{addrB, 3, 3100},
+	})
+
+	// Disconnecting the last block removes its checkpoint of B.
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, blocks[2], stxos[2])
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: %v", err)
+	}
+	checkBalances("disconnected", []balanceTest{
+		{addrA, 3, 1900},
+		{addrB, 3, 3000},
+	})
+
+	// An index without the balance checkpoints is dropped by the migration
+	// and its tip reset so it is rebuilt.
+	err = db.Update(func(dbTx database.Tx) error {
+		return dbTx.Metadata().Bucket(addrIndexKey).DeleteBucket(
+			addrBalanceBucketName)
+	})
+	if err != nil {
+		t.Fatalf("DeleteBucket: %v", err)
+	}
+	if err := idx.Migrate(db, nil); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	err = db.View(func(dbTx database.Tx) error {
+		_, height, err := dbFetchIndexerTip(dbTx, addrIndexKey)
+		if err != nil {
+			return err
+		}
+		if height != -1 {
+			t.Errorf("unexpected tip height after migration: %d",
+				height)
+		}
+		if dbTx.Metadata().Bucket(addrIndexKey).Bucket(
+			addrBalanceBucketName) == nil {
+
+			t.Error("balance bucket missing after migration")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: %v", err)
+	}
+	checkBalances("migrated", []balanceTest{
+		{addrA, 3, 0},
+	})
+}
@@ -0,0 +1,203 @@
+package indexers
+
+import (
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchutil"
+)
+
+const (
+	// catchUpWorkers is the number of goroutines loading blocks and their
+	// spend journals from the database ahead of the indexes while they
+	// are caught up.
+	catchUpWorkers = 4
+
+	// catchUpBatchSize is the maximum number of blocks connected to the
+	// indexes in a single database transaction while they are caught up.
+	// The index tips are updated along with each block, so every batch
+	// checkpoints the progress of the indexes and an interrupted catch up
+	// resumes from the last batch.
+	catchUpBatchSize = 100
+
+	// catchUpBatchBytes is the maximum total serialized size of the blocks
+	// in a batch, which bounds the memory used by the pending database
+	// transaction when the blocks are large.
+	catchUpBatchBytes = 32 * 1024 * 1024
+)
+
+// catchUpBlock houses a block loaded ahead of the indexes while they are
+// caught up, along with the outputs spent by the block when any of the indexes
+// needs them.
+type catchUpBlock struct {
+	block     *bchutil.Block
+	spentTxos []blockchain.SpentTxOut
+	err       error
+}
+
+// loadCatchUpBlock loads the main chain block at the passed height and, when
+// requested, its spend journal.
+func loadCatchUpBlock(chain *blockchain.BlockChain, height int32, needsInputs bool) *catchUpBlock {
+	block, err := chain.BlockByHeight(height)
+	if err != nil {
+		return &catchUpBlock{err: err}
+	}
+
+	var spentTxos []blockchain.SpentTxOut
+	if needsInputs {
+		spentTxos, err = chain.FetchSpendJournal(block)
+		if err != nil {
+			return &catchUpBlock{err: err}
+		}
+	}
+	return &catchUpBlock{block: block, spentTxos: spentTxos}
+}
+
+// loadCatchUpBlocks loads the blocks from the passed start height to the
+// passed end height with the passed load function from multiple goroutines and
+// returns a channel which delivers them in order of height.  Every block is
+// delivered on its own channel, which receives the block once it is loaded.
+// Only a limited number of blocks is loaded ahead of the receiver.  Closing
+// the quit channel stops loading further blocks.
+func loadCatchUpBlocks(startHeight, endHeight int32, load func(int32) *catchUpBlock,
+	quit <-chan struct{}) <-chan chan *catchUpBlock {
+
+	results := make(chan chan *catchUpBlock, catchUpWorkers*2)
+	go func() {
+		defer close(results)
+
+		sem := make(chan struct{}, catchUpWorkers)
+		for height := startHeight; height <= endHeight; height++ {
+			result := make(chan *catchUpBlock, 1)
+			select {
+			case results <- result:
+			case <-quit:
+				return
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-quit:
+				return
+			}
+			go func(height int32) {
+				result <- load(height)
+				<-sem
+			}(height)
+		}
+	}()
+	return results
+}
+
+// connectCatchUpBatch connects the passed batch of blocks to every index whose
+// tip is below them in a single database transaction.  The passed heights of
+// the index tips are updated once the transaction is committed.  The
+// transaction is discarded when an interrupt is requested in the meantime, so
+// large batches don't delay the shutdown.
+func (m *Manager) connectCatchUpBatch(batch []*catchUpBlock, indexerHeights []int32,
+	interrupt <-chan struct{}) error {
+
+	err := m.db.Update(func(dbTx database.Tx) error {
+		for _, loaded := range batch {
+			if interruptRequested(interrupt) {
+				return errInterruptRequested
+			}
+
+			height := loaded.block.Height()
+			for i, indexer := range m.enabledIndexes {
+				// Skip indexes that don't need to be updated
+				// with this block.
+				if indexerHeights[i] >= height {
+					continue
+				}
+
+				err := dbIndexConnectBlock(dbTx, indexer,
+					loaded.block, loaded.spentTxos)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	lastHeight := batch[len(batch)-1].block.Height()
+	for i := range indexerHeights {
+		if indexerHeights[i] < lastHeight {
+			indexerHeights[i] = lastHeight
+		}
+	}
+	log.Debugf("Indexes checkpointed at height %d", lastHeight)
+	return nil
+}
+
+// catchUp connects the main chain blocks after the lowest of the passed index
+// tip heights up to the passed best height to the indexes which are behind.
+// The blocks are loaded ahead in parallel and connected in batches, each of
+// which is committed atomically so that an interruption only loses the
+// progress of the current batch.
+func (m *Manager) catchUp(chain *blockchain.BlockChain, indexerHeights []int32,
+	lowestHeight, bestHeight int32, interrupt <-chan struct{}) error {
+
+	// Determine whether the spend journal of a block needs to be loaded
+	// based on the initial tips, since the loading goroutines run ahead
+	// of the indexes.
+	startHeights := make([]int32, len(indexerHeights))
+	copy(startHeights, indexerHeights)
+	load := func(height int32) *catchUpBlock {
+		var needsInputs bool
+		for i, indexer := range m.enabledIndexes {
+			if startHeights[i] < height && indexNeedsInputs(indexer) {
+				needsInputs = true
+				break
+			}
+		}
+		return loadCatchUpBlock(chain, height, needsInputs)
+	}
+
+	// Create a progress logger for the indexing process below.
+	progressLogger := newBlockProgressLogger("Indexed", log)
+
+	quit := make(chan struct{})
+	defer close(quit)
+	results := loadCatchUpBlocks(lowestHeight+1, bestHeight, load, quit)
+
+	batch := make([]*catchUpBlock, 0, catchUpBatchSize)
+	var batchBytes int
+	for result := range results {
+		loaded := <-result
+		if loaded.err != nil {
+			return loaded.err
+		}
+		batch = append(batch, loaded)
+		batchBytes += loaded.block.MsgBlock().SerializeSize()
+
+		height := loaded.block.Height()
+		if len(batch) < catchUpBatchSize &&
+			batchBytes < catchUpBatchBytes && height < bestHeight {
+
+			continue
+		}
+
+		err := m.connectCatchUpBatch(batch, indexerHeights, interrupt)
+		if err != nil {
+			return err
+		}
+
+		// Log indexing progress.
+		for _, loaded := range batch {
+			progressLogger.LogBlockHeight(loaded.block,
+				uint64(bestHeight))
+		}
+		batch = batch[:0]
+		batchBytes = 0
+
+		if interruptRequested(interrupt) {
+			return errInterruptRequested
+		}
+	}
+
+	return nil
+}
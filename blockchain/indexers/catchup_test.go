@@ -0,0 +1,54 @@
+package indexers
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// TestLoadCatchUpBlocks ensures blocks loaded by multiple goroutines are
+// delivered in order of height and that loading stops once requested.
+func TestLoadCatchUpBlocks(t *testing.T) {
+	load := func(height int32) *catchUpBlock {
+		// Finish loading in a random order.
+		time.Sleep(time.Duration(rand.Intn(1000)) * time.Microsecond)
+		block := bchutil.NewBlock(&wire.MsgBlock{})
+		block.SetHeight(height)
+		return &catchUpBlock{block: block}
+	}
+
+	quit := make(chan struct{})
+	results := loadCatchUpBlocks(5, 200, load, quit)
+	wantHeight := int32(5)
+	for result := range results {
+		loaded := <-result
+		if loaded.block.Height() != wantHeight {
+			t.Fatalf("unexpected block height: got %d, want %d",
+				loaded.block.Height(), wantHeight)
+		}
+		wantHeight++
+	}
+	if wantHeight != 201 {
+		t.Fatalf("unexpected number of blocks: got %d, want %d",
+			wantHeight-5, 196)
+	}
+	close(quit)
+
+	// Closing the quit channel stops loading more than the blocks loaded
+	// ahead of the receiver.
+	quit = make(chan struct{})
+	results = loadCatchUpBlocks(0, 1000000, load, quit)
+	<-<-results
+	close(quit)
+	var numBlocks int
+	for range results {
+		numBlocks++
+	}
+	if numBlocks > catchUpWorkers*2+1 {
+		t.Fatalf("unexpected number of blocks after quit: got %d, "+
+			"want at most %d", numBlocks, catchUpWorkers*2+1)
+	}
+}
@@ -0,0 +1,762 @@
+package indexers
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchutil"
+)
+
+const (
+	// coinAgeIndexName is the human-readable name for the index.
+	coinAgeIndexName = "coin age index"
+
+	// CoinAgeSnapshotInterval is the number of blocks between the
+	// snapshots of the age distribution of the unspent outputs.  A
+	// snapshot is recorded for every block whose height is a multiple of
+	// it, which is roughly once a day.
+	CoinAgeSnapshotInterval = 144
+
+	// blockCoinAgeSize is the size of a serialized block entry of the coin
+	// age index.
+	blockCoinAgeSize = chainhash.HashSize + 8 + 8 + 8
+
+	// coinAgeHistEntrySize is the size of a serialized histogram entry of
+	// the coin age index.
+	coinAgeHistEntrySize = 8 + 8
+)
+
+var (
+	// coinAgeIndexKey is the key of the coin age index and the parent db
+	// bucket of its buckets.
+	coinAgeIndexKey = []byte("coinageidx")
+
+	// coinAgeBlocksBucketName is the name of the bucket which houses the
+	// coin days destroyed by every block.
+	coinAgeBlocksBucketName = []byte("blocks")
+
+	// coinAgeHistBucketName is the name of the bucket which houses the
+	// value of the unspent outputs by the day they were created.
+	coinAgeHistBucketName = []byte("hist")
+
+	// coinAgeSnapshotsBucketName is the name of the bucket which houses the
+	// snapshots of the age distribution of the unspent outputs.
+	coinAgeSnapshotsBucketName = []byte("snapshots")
+
+	// UtxoAgeBands are the upper bounds, in days, of the age bands of the
+	// unspent outputs recorded by the coin age index.  Each band includes
+	// the outputs at least as old as the bound of the band before it and
+	// younger than its own bound.  The last band is unbounded.
+	UtxoAgeBands = []uint32{1, 7, 30, 90, 180, 365, 730, 1095, 1825, 2555,
+		3650, math.MaxUint32}
+)
+
+// -----------------------------------------------------------------------------
+// The coin age index consists of three buckets nested in its parent bucket.
+//
+// The blocks bucket holds an entry for every block in the main chain with the
+// value of the outputs spent by the block and the coin days they destroyed,
+// which is the value of each output multiplied by the time between the block
+// that created it and the block that spent it.  The entries are keyed by the
+// big-endian height of the block so a cursor visits them in height order.
+//
+// The hist bucket holds the value and number of the unspent outputs by the
+// UTC day the block that created them was mined in.  It is updated by every
+// block and used to compute the snapshots.  Provably unspendable outputs and
+// the outputs of the genesis block are not included.
+//
+// The snapshots bucket holds the value and number of the unspent outputs in
+// each of the age bands as of every block whose height is a multiple of the
+// snapshot interval, keyed by the big-endian height of the block.
+//
+// The serialized format for keys and values in the blocks bucket is:
+//   <height> = <block hash><timestamp><value destroyed><coin days destroyed>
+//
+//   Field                Type              Size
+//   height               uint32            4 bytes (big-endian)
+//   block hash           chainhash.Hash    32 bytes
+//   timestamp            int64             8 bytes
+//   value destroyed      int64             8 bytes
+//   coin days destroyed  uint64            8 bytes
+//   -----
+//   Total: 56 bytes
+//
+// The coin days destroyed are in satoshi days.
+//
+// The serialized format for keys and values in the hist bucket is:
+//   <day> = <value><count>
+//
+//   Field                Type              Size
+//   day                  uint32            4 bytes (big-endian)
+//   value                int64             8 bytes
+//   count                uint64            8 bytes
+//   -----
+//   Total: 16 bytes
+//
+// The day is the number of days since the unix epoch.
+//
+// The serialized format for keys and values in the snapshots bucket is:
+//   <height> = <timestamp>[<value><count>,...]
+//
+//   Field                Type              Size
+//   height               uint32            4 bytes (big-endian)
+//   timestamp            int64             8 bytes
+//   value                int64             8 bytes
+//   count                uint64            8 bytes
+//
+// There is a value and count for every age band.
+// -----------------------------------------------------------------------------
+
+// BlockCoinAge houses the coin days destroyed by a block recorded by the coin
+// age index.
+type BlockCoinAge struct {
+	// Hash, Height and Timestamp identify the block.
+	Hash      chainhash.Hash
+	Height    int32
+	Timestamp time.Time
+
+	// ValueDestroyed is the sum of the values of the outputs spent by the
+	// block in satoshis.
+	ValueDestroyed int64
+
+	// CoinDaysDestroyed is the sum of the values of the outputs spent by
+	// the block multiplied by their ages in days, in satoshi days.
+	CoinDaysDestroyed uint64
+}
+
+// AggregateCoinAge houses the aggregated coin days destroyed by the blocks of
+// a period.
+type AggregateCoinAge struct {
+	// PeriodStart is the start of the period, or the timestamp of the
+	// block when the coin days destroyed are not aggregated.
+	PeriodStart time.Time
+
+	// StartHeight and EndHeight are the lowest and highest heights of the
+	// blocks of the period, which are BlockCount blocks.
+	StartHeight int32
+	EndHeight   int32
+	BlockCount  uint32
+
+	// ValueDestroyed and CoinDaysDestroyed are the sums of the values of
+	// the same name of the blocks.
+	ValueDestroyed    int64
+	CoinDaysDestroyed uint64
+}
+
+// add adds the coin days destroyed by the passed block to the aggregate.
+func (a *AggregateCoinAge) add(coinAge *BlockCoinAge) {
+	if a.BlockCount == 0 || coinAge.Height < a.StartHeight {
+		a.StartHeight = coinAge.Height
+	}
+	if a.BlockCount == 0 || coinAge.Height > a.EndHeight {
+		a.EndHeight = coinAge.Height
+	}
+	a.BlockCount++
+	a.ValueDestroyed += coinAge.ValueDestroyed
+	a.CoinDaysDestroyed += coinAge.CoinDaysDestroyed
+}
+
+// UtxoAgeBand houses the value and number of the unspent outputs of an age
+// band.
+type UtxoAgeBand struct {
+	// MaxAgeDays is the upper bound of the ages of the outputs in days,
+	// which is math.MaxUint32 for the last band.
+	MaxAgeDays uint32
+
+	// Value is the sum of the values of the outputs in satoshis and Count
+	// their number.
+	Value int64
+	Count uint64
+}
+
+// UtxoAgeSnapshot houses the age distribution of the unspent outputs as of a
+// block.
+type UtxoAgeSnapshot struct {
+	// Height and Timestamp identify the block.
+	Height    int32
+	Timestamp time.Time
+
+	// Bands holds an entry for each of the UtxoAgeBands.
+	Bands []UtxoAgeBand
+}
+
+// coinAgeHistEntry houses the value and number of the unspent outputs created
+// on a day.
+type coinAgeHistEntry struct {
+	value int64
+	count int64
+}
+
+// unixDay returns the number of days since the unix epoch of the passed unix
+// time.  Times before the epoch are assigned to day zero.
+func unixDay(unix int64) uint32 {
+	if unix < 0 {
+		return 0
+	}
+	return uint32(unix / secondsPerDay)
+}
+
+// coinDays returns the value multiplied by the age in days, truncated to a
+// whole number of satoshi days.
+func coinDays(value int64, ageSeconds int64) uint64 {
+	if value <= 0 || ageSeconds <= 0 {
+		return 0
+	}
+	hi, lo := bits.Mul64(uint64(value), uint64(ageSeconds))
+	if hi >= secondsPerDay {
+		return math.MaxUint64
+	}
+	days, _ := bits.Div64(hi, lo, secondsPerDay)
+	return days
+}
+
+// coinAgeEntryKey returns the key of the blocks and snapshots bucket entries of
+// the block at the passed height.
+func coinAgeEntryKey(height int32) []byte {
+	var key [4]byte
+	binary.BigEndian.PutUint32(key[:], uint32(height))
+	return key[:]
+}
+
+// coinAgeHistKey returns the key of the hist bucket entry of the passed day.
+func coinAgeHistKey(day uint32) []byte {
+	var key [4]byte
+	binary.BigEndian.PutUint32(key[:], day)
+	return key[:]
+}
+
+// serializeBlockCoinAge returns the serialized blocks bucket entry for the
+// passed coin age according to the format described above.
+func serializeBlockCoinAge(coinAge *BlockCoinAge) []byte {
+	serialized := make([]byte, blockCoinAgeSize)
+	copy(serialized, coinAge.Hash[:])
+	offset := chainhash.HashSize
+	byteOrder.PutUint64(serialized[offset:], uint64(coinAge.Timestamp.Unix()))
+	offset += 8
+	byteOrder.PutUint64(serialized[offset:], uint64(coinAge.ValueDestroyed))
+	offset += 8
+	byteOrder.PutUint64(serialized[offset:], coinAge.CoinDaysDestroyed)
+	return serialized
+}
+
+// deserializeBlockCoinAge decodes the passed serialized blocks bucket entry of
+// the block at the passed height.
+func deserializeBlockCoinAge(height int32, serialized []byte) (*BlockCoinAge, error) {
+	if len(serialized) != blockCoinAgeSize {
+		return nil, errDeserialize(fmt.Sprintf("unexpected coin age "+
+			"index block entry size %d", len(serialized)))
+	}
+
+	coinAge := BlockCoinAge{Height: height}
+	copy(coinAge.Hash[:], serialized)
+	offset := chainhash.HashSize
+	coinAge.Timestamp = time.Unix(int64(byteOrder.Uint64(serialized[offset:])), 0)
+	offset += 8
+	coinAge.ValueDestroyed = int64(byteOrder.Uint64(serialized[offset:]))
+	offset += 8
+	coinAge.CoinDaysDestroyed = byteOrder.Uint64(serialized[offset:])
+	return &coinAge, nil
+}
+
+// serializeUtxoAgeSnapshot returns the serialized snapshots bucket entry for
+// the passed snapshot according to the format described above.
+func serializeUtxoAgeSnapshot(snapshot *UtxoAgeSnapshot) []byte {
+	serialized := make([]byte, 8+len(snapshot.Bands)*16)
+	byteOrder.PutUint64(serialized, uint64(snapshot.Timestamp.Unix()))
+	offset := 8
+	for _, band := range snapshot.Bands {
+		byteOrder.PutUint64(serialized[offset:], uint64(band.Value))
+		byteOrder.PutUint64(serialized[offset+8:], band.Count)
+		offset += 16
+	}
+	return serialized
+}
+
+// deserializeUtxoAgeSnapshot decodes the passed serialized snapshots bucket
+// entry of the block at the passed height.
+func deserializeUtxoAgeSnapshot(height int32, serialized []byte) (*UtxoAgeSnapshot, error) {
+	if len(serialized) != 8+len(UtxoAgeBands)*16 {
+		return nil, errDeserialize(fmt.Sprintf("unexpected coin age "+
+			"index snapshot size %d", len(serialized)))
+	}
+
+	snapshot := UtxoAgeSnapshot{
+		Height:    height,
+		Timestamp: time.Unix(int64(byteOrder.Uint64(serialized)), 0),
+		Bands:     make([]UtxoAgeBand, len(UtxoAgeBands)),
+	}
+	offset := 8
+	for i := range snapshot.Bands {
+		snapshot.Bands[i] = UtxoAgeBand{
+			MaxAgeDays: UtxoAgeBands[i],
+			Value:      int64(byteOrder.Uint64(serialized[offset:])),
+			Count:      byteOrder.Uint64(serialized[offset+8:]),
+		}
+		offset += 16
+	}
+	return &snapshot, nil
+}
+
+// dbFetchBlockTimestamp returns the timestamp of the block at the passed height
+// recorded in the blocks bucket.
+func dbFetchBlockTimestamp(blocksBucket database.Bucket, height int32) (int64, error) {
+	serialized := blocksBucket.Get(coinAgeEntryKey(height))
+	if serialized == nil {
+		return 0, AssertError(fmt.Sprintf("missing coin age index "+
+			"entry for height %d", height))
+	}
+	coinAge, err := deserializeBlockCoinAge(height, serialized)
+	if err != nil {
+		return 0, err
+	}
+	return coinAge.Timestamp.Unix(), nil
+}
+
+// dbUpdateCoinAgeHist adds the passed changes of the value and number of the
+// unspent outputs of each day to the hist bucket, removing the entries of the
+// days without unspent outputs.
+func dbUpdateCoinAgeHist(histBucket database.Bucket, changes map[uint32]*coinAgeHistEntry) error {
+	for day, change := range changes {
+		key := coinAgeHistKey(day)
+		var entry coinAgeHistEntry
+		if serialized := histBucket.Get(key); serialized != nil {
+			if len(serialized) != coinAgeHistEntrySize {
+				return errDeserialize(fmt.Sprintf("unexpected "+
+					"coin age index histogram entry size "+
+					"%d", len(serialized)))
+			}
+			entry.value = int64(byteOrder.Uint64(serialized))
+			entry.count = int64(byteOrder.Uint64(serialized[8:]))
+		}
+		entry.value += change.value
+		entry.count += change.count
+		if entry.count < 0 || entry.value < 0 {
+			return AssertError(fmt.Sprintf("negative coin age "+
+				"index histogram entry for day %d", day))
+		}
+		if entry.count == 0 {
+			if err := histBucket.Delete(key); err != nil {
+				return err
+			}
+			continue
+		}
+
+		serialized := make([]byte, coinAgeHistEntrySize)
+		byteOrder.PutUint64(serialized, uint64(entry.value))
+		byteOrder.PutUint64(serialized[8:], uint64(entry.count))
+		if err := histBucket.Put(key, serialized); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// calcUtxoAgeSnapshot computes the age distribution of the unspent outputs in
+// the hist bucket as of the passed time.
+func calcUtxoAgeSnapshot(histBucket database.Bucket, height int32,
+	timestamp time.Time) (*UtxoAgeSnapshot, error) {
+
+	snapshot := &UtxoAgeSnapshot{
+		Height:    height,
+		Timestamp: timestamp,
+		Bands:     make([]UtxoAgeBand, len(UtxoAgeBands)),
+	}
+	for i, maxAge := range UtxoAgeBands {
+		snapshot.Bands[i].MaxAgeDays = maxAge
+	}
+
+	today := unixDay(timestamp.Unix())
+	cursor := histBucket.Cursor()
+	for ok := cursor.First(); ok; ok = cursor.Next() {
+		serialized := cursor.Value()
+		if len(serialized) != coinAgeHistEntrySize {
+			return nil, errDeserialize(fmt.Sprintf("unexpected coin "+
+				"age index histogram entry size %d",
+				len(serialized)))
+		}
+
+		// Block timestamps are not strictly increasing, so outputs
+		// may appear to be created after the snapshot.
+		day := binary.BigEndian.Uint32(cursor.Key())
+		var age uint32
+		if day < today {
+			age = today - day
+		}
+		// The last band is unbounded, so every age falls in a band.
+		band := sort.Search(len(UtxoAgeBands)-1, func(i int) bool {
+			return age < UtxoAgeBands[i]
+		})
+		snapshot.Bands[band].Value += int64(byteOrder.Uint64(serialized))
+		snapshot.Bands[band].Count += byteOrder.Uint64(serialized[8:])
+	}
+	return snapshot, nil
+}
+
+// coinAgeChanges returns the coin days destroyed by the passed block which
+// spends the passed outputs, along with the changes of the value and number of
+// the unspent outputs of each day it makes.  The timestamps of the blocks which
+// created the spent outputs are looked up in the passed blocks bucket, which
+// must already contain the entry of the block itself.
+func coinAgeChanges(blocksBucket database.Bucket, block *bchutil.Block,
+	stxos []blockchain.SpentTxOut) (*BlockCoinAge, map[uint32]*coinAgeHistEntry, error) {
+
+	msgBlock := block.MsgBlock()
+	timestamp := msgBlock.Header.Timestamp.Unix()
+	coinAge := &BlockCoinAge{
+		Hash:      *block.Hash(),
+		Height:    block.Height(),
+		Timestamp: msgBlock.Header.Timestamp,
+	}
+	changes := make(map[uint32]*coinAgeHistEntry)
+	change := func(day uint32) *coinAgeHistEntry {
+		entry, ok := changes[day]
+		if !ok {
+			entry = &coinAgeHistEntry{}
+			changes[day] = entry
+		}
+		return entry
+	}
+
+	// The outputs of the genesis block can not be spent.
+	if block.Height() > 0 {
+		today := change(unixDay(timestamp))
+		for _, tx := range msgBlock.Transactions {
+			for _, txOut := range tx.TxOut {
+				if txscript.IsUnspendable(txOut.PkScript) {
+					continue
+				}
+				today.value += txOut.Value
+				today.count++
+			}
+		}
+	}
+
+	stxoIndex := 0
+	createdAt := make(map[int32]int64)
+	for txIdx, tx := range msgBlock.Transactions {
+		// Coinbases do not reference any inputs.
+		if txIdx == 0 {
+			continue
+		}
+		if stxoIndex+len(tx.TxIn) > len(stxos) {
+			return nil, nil, AssertError(fmt.Sprintf("missing spent "+
+				"outputs of block %v", block.Hash()))
+		}
+		for range tx.TxIn {
+			stxo := &stxos[stxoIndex]
+			stxoIndex++
+
+			created, ok := createdAt[stxo.Height]
+			if !ok {
+				var err error
+				created, err = dbFetchBlockTimestamp(blocksBucket,
+					stxo.Height)
+				if err != nil {
+					return nil, nil, err
+				}
+				createdAt[stxo.Height] = created
+			}
+
+			coinAge.ValueDestroyed += stxo.Amount
+			coinAge.CoinDaysDestroyed += coinDays(stxo.Amount,
+				timestamp-created)
+			spentDay := change(unixDay(created))
+			spentDay.value -= stxo.Amount
+			spentDay.count--
+		}
+	}
+	return coinAge, changes, nil
+}
+
+// CoinAgeIndex implements a coin age index.  That is to say, it records the
+// coin days destroyed by every block in the main chain and periodic snapshots
+// of the age distribution of the unspent outputs, which can be queried over
+// ranges of blocks.
+type CoinAgeIndex struct {
+	db database.DB
+}
+
+// Ensure the CoinAgeIndex type implements the Indexer interface.
+var _ Indexer = (*CoinAgeIndex)(nil)
+
+// Ensure the CoinAgeIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*CoinAgeIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *CoinAgeIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *CoinAgeIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// StartBlock is used to indicate the proper start block for the index manager.
+//
+// This is part of the Indexer interface.
+func (idx *CoinAgeIndex) StartBlock() (*chainhash.Hash, int32) {
+	return nil, -1
+}
+
+// Migrate is only provided to satisfy the Indexer interface as there is nothing to
+// migrate this index.
+//
+// This is part of the Indexer interface.
+func (idx *CoinAgeIndex) Migrate(db database.DB, interrupt <-chan struct{}) error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *CoinAgeIndex) Key() []byte {
+	return coinAgeIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *CoinAgeIndex) Name() string {
+	return coinAgeIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the buckets for the coin age
+// index.
+//
+// This is part of the Indexer interface.
+func (idx *CoinAgeIndex) Create(dbTx database.Tx) error {
+	parent, err := dbTx.Metadata().CreateBucket(coinAgeIndexKey)
+	if err != nil {
+		return err
+	}
+	for _, bucketName := range [][]byte{coinAgeBlocksBucketName,
+		coinAgeHistBucketName, coinAgeSnapshotsBucketName} {
+
+		if _, err := parent.CreateBucket(bucketName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer records the coin days destroyed
+// by the block, updates the ages of the unspent outputs and records a snapshot
+// of their distribution when the block is at a snapshot height.
+//
+// This is part of the Indexer interface.
+func (idx *CoinAgeIndex) ConnectBlock(dbTx database.Tx, block *bchutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	parent := dbTx.Metadata().Bucket(coinAgeIndexKey)
+	blocksBucket := parent.Bucket(coinAgeBlocksBucketName)
+	histBucket := parent.Bucket(coinAgeHistBucketName)
+
+	// The entry of the block is stored before the coin days are computed
+	// since the block may spend outputs it creates itself.
+	key := coinAgeEntryKey(block.Height())
+	err := blocksBucket.Put(key, serializeBlockCoinAge(&BlockCoinAge{
+		Hash:      *block.Hash(),
+		Height:    block.Height(),
+		Timestamp: block.MsgBlock().Header.Timestamp,
+	}))
+	if err != nil {
+		return err
+	}
+	coinAge, changes, err := coinAgeChanges(blocksBucket, block, stxos)
+	if err != nil {
+		return err
+	}
+	if err := blocksBucket.Put(key, serializeBlockCoinAge(coinAge)); err != nil {
+		return err
+	}
+	if err := dbUpdateCoinAgeHist(histBucket, changes); err != nil {
+		return err
+	}
+
+	if block.Height()%CoinAgeSnapshotInterval != 0 {
+		return nil
+	}
+	snapshot, err := calcUtxoAgeSnapshot(histBucket, block.Height(),
+		coinAge.Timestamp)
+	if err != nil {
+		return err
+	}
+	return parent.Bucket(coinAgeSnapshotsBucketName).Put(key,
+		serializeUtxoAgeSnapshot(snapshot))
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the coin days
+// destroyed by the block and its snapshot, and reverts the ages of the unspent
+// outputs.
+//
+// This is part of the Indexer interface.
+func (idx *CoinAgeIndex) DisconnectBlock(dbTx database.Tx, block *bchutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	parent := dbTx.Metadata().Bucket(coinAgeIndexKey)
+	blocksBucket := parent.Bucket(coinAgeBlocksBucketName)
+	_, changes, err := coinAgeChanges(blocksBucket, block, stxos)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		change.value = -change.value
+		change.count = -change.count
+	}
+	err = dbUpdateCoinAgeHist(parent.Bucket(coinAgeHistBucketName), changes)
+	if err != nil {
+		return err
+	}
+
+	key := coinAgeEntryKey(block.Height())
+	if err := parent.Bucket(coinAgeSnapshotsBucketName).Delete(key); err != nil {
+		return err
+	}
+	return blocksBucket.Delete(key)
+}
+
+// AggregateCoinAge returns the coin days destroyed by the main chain blocks
+// from startHeight to endHeight inclusive, aggregated by the passed interval
+// and sorted by period.  Blocks are assigned to periods by their timestamp,
+// which is not strictly increasing, so the height ranges of adjacent periods
+// may overlap.  An error for which IsTooManyPeriodsErr returns true is returned
+// when there would be more than maxPeriods periods.
+//
+// This function is safe for concurrent access.
+func (idx *CoinAgeIndex) AggregateCoinAge(startHeight, endHeight int32,
+	interval StatsInterval, maxPeriods int) ([]*AggregateCoinAge, error) {
+
+	periods := make(map[int64]*AggregateCoinAge)
+	err := idx.db.View(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(coinAgeIndexKey).
+			Bucket(coinAgeBlocksBucketName).Cursor()
+		for ok := cursor.Seek(coinAgeEntryKey(startHeight)); ok; ok = cursor.Next() {
+			height := int32(binary.BigEndian.Uint32(cursor.Key()))
+			if height > endHeight {
+				break
+			}
+			coinAge, err := deserializeBlockCoinAge(height, cursor.Value())
+			if err != nil {
+				return database.Error{
+					ErrorCode: database.ErrCorruption,
+					Description: fmt.Sprintf("corrupt coin age "+
+						"index entry for height %d: %v",
+						height, err),
+				}
+			}
+
+			start := interval.periodStart(coinAge.Timestamp.Unix())
+			if interval == StatsPerBlock {
+				start = int64(height)
+			}
+			period, ok := periods[start]
+			if !ok {
+				if len(periods) == maxPeriods {
+					return errTooManyPeriods
+				}
+				period = &AggregateCoinAge{
+					PeriodStart: time.Unix(interval.periodStart(
+						coinAge.Timestamp.Unix()), 0),
+				}
+				periods[start] = period
+			}
+			period.add(coinAge)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*AggregateCoinAge, 0, len(periods))
+	for _, period := range periods {
+		result = append(result, period)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if interval == StatsPerBlock {
+			return result[i].StartHeight < result[j].StartHeight
+		}
+		return result[i].PeriodStart.Before(result[j].PeriodStart)
+	})
+	return result, nil
+}
+
+// UtxoAgeSnapshots returns the snapshots of the age distribution of the unspent
+// outputs recorded for the main chain blocks from startHeight to endHeight
+// inclusive, sorted by height.  Snapshots are only recorded for the blocks
+// whose height is a multiple of CoinAgeSnapshotInterval.  An error for which
+// IsTooManyPeriodsErr returns true is returned when there would be more than
+// maxSnapshots snapshots.
+//
+// This function is safe for concurrent access.
+func (idx *CoinAgeIndex) UtxoAgeSnapshots(startHeight, endHeight int32,
+	maxSnapshots int) ([]*UtxoAgeSnapshot, error) {
+
+	var snapshots []*UtxoAgeSnapshot
+	err := idx.db.View(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(coinAgeIndexKey).
+			Bucket(coinAgeSnapshotsBucketName).Cursor()
+		for ok := cursor.Seek(coinAgeEntryKey(startHeight)); ok; ok = cursor.Next() {
+			height := int32(binary.BigEndian.Uint32(cursor.Key()))
+			if height > endHeight {
+				break
+			}
+			if len(snapshots) == maxSnapshots {
+				return errTooManyPeriods
+			}
+			snapshot, err := deserializeUtxoAgeSnapshot(height,
+				cursor.Value())
+			if err != nil {
+				return database.Error{
+					ErrorCode: database.ErrCorruption,
+					Description: fmt.Sprintf("corrupt coin age "+
+						"index snapshot for height %d: %v",
+						height, err),
+				}
+			}
+			snapshots = append(snapshots, snapshot)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// NewCoinAgeIndex returns a new instance of an indexer that is used to record
+// the coin days destroyed by every block in the main chain and snapshots of the
+// age distribution of the unspent outputs.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewCoinAgeIndex(db database.DB) *CoinAgeIndex {
+	return &CoinAgeIndex{db: db}
+}
+
+// DropCoinAgeIndex drops the coin age index from the provided database if it
+// exists.
+func DropCoinAgeIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, coinAgeIndexKey, coinAgeIndexName, interrupt)
+}
@@ -0,0 +1,217 @@
+package indexers
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/database"
+	_ "github.com/gcash/bchd/database/ffldb"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// TestCoinDays ensures coin days are truncated to whole satoshi days and do
+// not overflow.
+func TestCoinDays(t *testing.T) {
+	tests := []struct {
+		value int64
+		age   int64
+		want  uint64
+	}{
+		{100, secondsPerDay * 3 / 2, 150},
+		{1, secondsPerDay - 1, 0},
+		{100, -secondsPerDay, 0},
+		{2100000000000000, 20 * 365 * secondsPerDay, 15330000000000000000},
+		{2100000000000000, 1000 * 365 * secondsPerDay, 18446744073709551615},
+	}
+	for i, test := range tests {
+		if got := coinDays(test.value, test.age); got != test.want {
+			t.Errorf("test #%d: got %d, want %d", i, got, test.want)
+		}
+	}
+}
+
+// TestCoinAgeIndex ensures the coin age index records the coin days destroyed
+// by connected blocks and snapshots of the age distribution of the unspent
+// outputs, and reverts them when blocks are disconnected.
+func TestCoinAgeIndex(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	db, err := database.Create("ffldb", filepath.Join(t.TempDir(), "db"),
+		params.Net)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	idx := NewCoinAgeIndex(db)
+	if err := db.Update(idx.Create); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	script := []byte{txscript.OP_TRUE}
+	newTx := func(spends int, outputs ...int64) *wire.MsgTx {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		for i := 0; i < spends; i++ {
+			tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+				uint32(i)), nil))
+		}
+		for _, value := range outputs {
+			tx.AddTxOut(wire.NewTxOut(value, script, wire.TokenData{}))
+		}
+		return tx
+	}
+	newBlock := func(height int32, timestamp time.Time, coinbase []int64, txns ...*wire.MsgTx) *bchutil.Block {
+		cb := newTx(0, coinbase...)
+		cb.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex), []byte{0x51, 0x51}))
+		msgBlock := wire.NewMsgBlock(&wire.BlockHeader{Timestamp: timestamp})
+		msgBlock.AddTransaction(cb)
+		for _, tx := range txns {
+			msgBlock.AddTransaction(tx)
+		}
+		block := bchutil.NewBlock(msgBlock)
+		block.SetHeight(height)
+		return block
+	}
+
+	// The block at the snapshot height is mined ten days after the first
+	// block.  It spends one of the outputs of the first block and an
+	// output it creates itself, and creates a provably unspendable output.
+	genesis := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	first := genesis.Add(12 * time.Hour)
+	snapshotTime := first.Add(10 * 24 * time.Hour)
+	spendOwn := newTx(1, 4800)
+	spendOwn.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_RETURN},
+		wire.TokenData{}))
+	blocks := []*bchutil.Block{
+		newBlock(0, genesis, []int64{5000}),
+		newBlock(1, first, []int64{5000, 3000}),
+		newBlock(CoinAgeSnapshotInterval, snapshotTime, []int64{5000},
+			newTx(1, 4900), spendOwn),
+	}
+	stxos := [][]blockchain.SpentTxOut{
+		nil,
+		nil,
+		{
+			{Amount: 5000, PkScript: script, Height: 1, IsCoinBase: true},
+			{Amount: 4900, PkScript: script, Height: CoinAgeSnapshotInterval},
+		},
+	}
+	for i, block := range blocks {
+		err := db.Update(func(dbTx database.Tx) error {
+			return idx.ConnectBlock(dbTx, block, stxos[i])
+		})
+		if err != nil {
+			t.Fatalf("ConnectBlock #%d: %v", i, err)
+		}
+	}
+
+	perBlock, err := idx.AggregateCoinAge(0, CoinAgeSnapshotInterval,
+		StatsPerBlock, 10)
+	if err != nil {
+		t.Fatalf("AggregateCoinAge: %v", err)
+	}
+	if len(perBlock) != 3 {
+		t.Fatalf("got %d blocks, want 3", len(perBlock))
+	}
+	last := perBlock[2]
+	if last.StartHeight != CoinAgeSnapshotInterval ||
+		last.ValueDestroyed != 9900 || last.CoinDaysDestroyed != 50000 ||
+		!last.PeriodStart.Equal(snapshotTime) {
+
+		t.Fatalf("unexpected coin age of the last block: %+v", last)
+	}
+
+	weekly, err := idx.AggregateCoinAge(0, CoinAgeSnapshotInterval,
+		StatsWeekly, 10)
+	if err != nil {
+		t.Fatalf("AggregateCoinAge: %v", err)
+	}
+	if len(weekly) != 2 || weekly[0].BlockCount != 2 ||
+		weekly[0].CoinDaysDestroyed != 0 || weekly[1].BlockCount != 1 ||
+		weekly[1].CoinDaysDestroyed != 50000 {
+
+		t.Fatalf("unexpected weekly coin age: %+v %+v", weekly[0],
+			weekly[1])
+	}
+
+	if _, err := idx.AggregateCoinAge(0, 200, StatsPerBlock, 2); !IsTooManyPeriodsErr(err) {
+		t.Fatalf("unexpected error for too many periods: %v", err)
+	}
+
+	// The snapshot holds the unspent coinbase outputs of the last block and
+	// the output of its last transaction, which are less than a day old,
+	// and the unspent output of the first block, which is ten days old.
+	snapshots, err := idx.UtxoAgeSnapshots(1, 1000, 10)
+	if err != nil {
+		t.Fatalf("UtxoAgeSnapshots: %v", err)
+	}
+	if len(snapshots) != 1 {
+		t.Fatalf("got %d snapshots, want 1", len(snapshots))
+	}
+	snapshot := snapshots[0]
+	if snapshot.Height != CoinAgeSnapshotInterval ||
+		!snapshot.Timestamp.Equal(snapshotTime) ||
+		len(snapshot.Bands) != len(UtxoAgeBands) {
+
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+	for i, band := range snapshot.Bands {
+		want := UtxoAgeBand{MaxAgeDays: UtxoAgeBands[i]}
+		switch band.MaxAgeDays {
+		case 1:
+			want.Value, want.Count = 9800, 2
+		case 30:
+			want.Value, want.Count = 3000, 1
+		}
+		if band != want {
+			t.Errorf("band #%d: got %+v, want %+v", i, band, want)
+		}
+	}
+
+	// Disconnecting the last block removes its coin age and snapshot and
+	// restores the unspent outputs of the first block.
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, blocks[2], stxos[2])
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: %v", err)
+	}
+	perBlock, err = idx.AggregateCoinAge(0, CoinAgeSnapshotInterval,
+		StatsPerBlock, 10)
+	if err != nil {
+		t.Fatalf("AggregateCoinAge: %v", err)
+	}
+	if len(perBlock) != 2 {
+		t.Fatalf("got %d blocks after disconnect, want 2", len(perBlock))
+	}
+	snapshots, err = idx.UtxoAgeSnapshots(1, 1000, 10)
+	if err != nil {
+		t.Fatalf("UtxoAgeSnapshots: %v", err)
+	}
+	if len(snapshots) != 0 {
+		t.Fatalf("got %d snapshots after disconnect, want 0",
+			len(snapshots))
+	}
+	err = db.View(func(dbTx database.Tx) error {
+		histBucket := dbTx.Metadata().Bucket(coinAgeIndexKey).
+			Bucket(coinAgeHistBucketName)
+		snapshot, err := calcUtxoAgeSnapshot(histBucket, 1, first)
+		if err != nil {
+			return err
+		}
+		if snapshot.Bands[0].Value != 8000 || snapshot.Bands[0].Count != 2 {
+			t.Errorf("unexpected unspent outputs after disconnect: "+
+				"%+v", snapshot.Bands[0])
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("calcUtxoAgeSnapshot: %v", err)
+	}
+}
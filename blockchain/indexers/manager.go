@@ -381,6 +381,12 @@ func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{})
 		}
 	}
 
+	// Blocks are not stored in headers only mode, so the indexes are
+	// caught up as the blocks are downloaded instead.
+	if chain.HeadersOnly() {
+		return nil
+	}
+
 	// Fetch the current tip heights for each index along with tracking the
 	// lowest one so the catchup code only needs to start at the earliest
 	// block and is able to skip connecting the block for the indexes that
@@ -413,62 +419,15 @@ func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{})
 		return nil
 	}
 
-	// Create a progress logger for the indexing process below.
-	progressLogger := newBlockProgressLogger("Indexed", log)
-
 	// At this point, one or more indexes are behind the current best chain
-	// tip and need to be caught up, so log the details and loop through
-	// each block that needs to be indexed.
+	// tip and need to be caught up, so log the details and index each block
+	// that needs to be indexed.
 	log.Infof("Catching up indexes from height %d to %d", lowestHeight,
 		bestHeight)
-	for height := lowestHeight + 1; height <= bestHeight; height++ {
-		// Load the block for the height since it is required to index
-		// it.
-		block, err := chain.BlockByHeight(height)
-		if err != nil {
-			return err
-		}
-
-		if interruptRequested(interrupt) {
-			return errInterruptRequested
-		}
-
-		// Connect the block for all indexes that need it.
-		var spentTxos []blockchain.SpentTxOut
-		for i, indexer := range m.enabledIndexes {
-			// Skip indexes that don't need to be updated with this
-			// block.
-			if indexerHeights[i] >= height {
-				continue
-			}
-
-			// When the index requires all of the referenced txouts
-			// and they haven't been loaded yet, they need to be
-			// retrieved from the spend journal.
-			if spentTxos == nil && indexNeedsInputs(indexer) {
-				spentTxos, err = chain.FetchSpendJournal(block)
-				if err != nil {
-					return err
-				}
-			}
-
-			err := m.db.Update(func(dbTx database.Tx) error {
-				return dbIndexConnectBlock(
-					dbTx, indexer, block, spentTxos,
-				)
-			})
-			if err != nil {
-				return err
-			}
-			indexerHeights[i] = height
-		}
-
-		// Log indexing progress.
-		progressLogger.LogBlockHeight(block, uint64(bestHeight))
-
-		if interruptRequested(interrupt) {
-			return errInterruptRequested
-		}
+	err = m.catchUp(chain, indexerHeights, lowestHeight, bestHeight,
+		interrupt)
+	if err != nil {
+		return err
 	}
 
 	log.Infof("Indexes caught up to height %d", bestHeight)
@@ -0,0 +1,426 @@
+package indexers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchutil"
+)
+
+const (
+	// statsIndexName is the human-readable name for the index.
+	statsIndexName = "chain statistics index"
+
+	// blockStatsSize is the size of a serialized chain statistics index
+	// entry.
+	blockStatsSize = chainhash.HashSize + 8 + 4 + 4 + 8 + 8
+
+	// secondsPerDay is the number of seconds in a day.
+	secondsPerDay = 24 * 60 * 60
+)
+
+var (
+	// statsIndexKey is the key of the chain statistics index and the db
+	// bucket used to house it.
+	statsIndexKey = []byte("blockstatsbyheightidx")
+)
+
+// -----------------------------------------------------------------------------
+// The chain statistics index consists of an entry for every block in the main
+// chain which holds aggregates of the block, so that statistics over ranges
+// of blocks can be computed without loading the blocks.  The entries are keyed
+// by the big-endian height of the block so a cursor visits them in height
+// order.
+//
+// The serialized format for keys and values in the bucket is:
+//   <height> = <block hash><timestamp><tx count><size><total fees>
+//              <output volume>
+//
+//   Field           Type              Size
+//   height          uint32            4 bytes (big-endian)
+//   block hash      chainhash.Hash    32 bytes
+//   timestamp       int64             8 bytes
+//   tx count        uint32            4 bytes
+//   size            uint32            4 bytes
+//   total fees      int64             8 bytes
+//   output volume   int64             8 bytes
+//   -----
+//   Total: 64 bytes
+// -----------------------------------------------------------------------------
+
+// BlockStats houses the aggregates of a block recorded by the chain statistics
+// index.
+type BlockStats struct {
+	// Hash, Height and Timestamp identify the block.
+	Hash      chainhash.Hash
+	Height    int32
+	Timestamp time.Time
+
+	// TxCount is the number of transactions in the block, including the
+	// coinbase, and Size its serialized size in bytes.
+	TxCount uint32
+	Size    uint32
+
+	// TotalFees is the sum of the fees paid by the transactions of the
+	// block in satoshis.
+	TotalFees int64
+
+	// OutputVolume is the sum of the values of the outputs of the
+	// transactions of the block in satoshis, excluding the coinbase.
+	OutputVolume int64
+}
+
+// StatsInterval identifies how the chain statistics of a range of blocks are
+// aggregated.
+type StatsInterval int
+
+const (
+	// StatsPerBlock does not aggregate the statistics of blocks.
+	StatsPerBlock StatsInterval = iota
+
+	// StatsDaily aggregates the statistics of the blocks with a timestamp
+	// in the same UTC day.
+	StatsDaily
+
+	// StatsWeekly aggregates the statistics of the blocks with a
+	// timestamp in the same week, starting on Monday 00:00 UTC.
+	StatsWeekly
+)
+
+// periodStart returns the start of the period of the interval containing the
+// passed unix time.
+func (i StatsInterval) periodStart(unix int64) int64 {
+	// Floor division so times before the epoch are handled correctly.
+	day := unix / secondsPerDay
+	if unix%secondsPerDay < 0 {
+		day--
+	}
+	switch i {
+	case StatsDaily:
+		return day * secondsPerDay
+	case StatsWeekly:
+		// The epoch was a Thursday, which is three days after the
+		// start of its week.
+		week := (day + 3) / 7
+		if (day+3)%7 < 0 {
+			week--
+		}
+		return (week*7 - 3) * secondsPerDay
+	}
+	return unix
+}
+
+// AggregateStats houses the aggregated statistics of the blocks of a period.
+type AggregateStats struct {
+	// PeriodStart is the start of the period, or the timestamp of the
+	// block when the statistics are not aggregated.
+	PeriodStart time.Time
+
+	// StartHeight and EndHeight are the lowest and highest heights of the
+	// blocks of the period, which are BlockCount blocks.
+	StartHeight int32
+	EndHeight   int32
+	BlockCount  uint32
+
+	// TxCount, Size, TotalFees and OutputVolume are the sums of the
+	// statistics of the same name of the blocks.
+	TxCount      uint64
+	Size         uint64
+	TotalFees    int64
+	OutputVolume int64
+}
+
+// add adds the statistics of the passed block to the aggregate.
+func (a *AggregateStats) add(stats *BlockStats) {
+	if a.BlockCount == 0 || stats.Height < a.StartHeight {
+		a.StartHeight = stats.Height
+	}
+	if a.BlockCount == 0 || stats.Height > a.EndHeight {
+		a.EndHeight = stats.Height
+	}
+	a.BlockCount++
+	a.TxCount += uint64(stats.TxCount)
+	a.Size += uint64(stats.Size)
+	a.TotalFees += stats.TotalFees
+	a.OutputVolume += stats.OutputVolume
+}
+
+// statsIndexEntryKey returns the key of the chain statistics index entry of the
+// block at the passed height.
+func statsIndexEntryKey(height int32) []byte {
+	var key [4]byte
+	binary.BigEndian.PutUint32(key[:], uint32(height))
+	return key[:]
+}
+
+// serializeBlockStats returns the serialized chain statistics index entry for
+// the passed stats according to the format described above.
+func serializeBlockStats(stats *BlockStats) []byte {
+	serialized := make([]byte, blockStatsSize)
+	copy(serialized, stats.Hash[:])
+	offset := chainhash.HashSize
+	byteOrder.PutUint64(serialized[offset:], uint64(stats.Timestamp.Unix()))
+	offset += 8
+	byteOrder.PutUint32(serialized[offset:], stats.TxCount)
+	offset += 4
+	byteOrder.PutUint32(serialized[offset:], stats.Size)
+	offset += 4
+	byteOrder.PutUint64(serialized[offset:], uint64(stats.TotalFees))
+	offset += 8
+	byteOrder.PutUint64(serialized[offset:], uint64(stats.OutputVolume))
+	return serialized
+}
+
+// deserializeBlockStats decodes the passed serialized chain statistics index
+// entry of the block at the passed height.
+func deserializeBlockStats(height int32, serialized []byte) (*BlockStats, error) {
+	if len(serialized) != blockStatsSize {
+		return nil, errDeserialize(fmt.Sprintf("unexpected chain "+
+			"statistics index entry size %d", len(serialized)))
+	}
+
+	stats := BlockStats{Height: height}
+	copy(stats.Hash[:], serialized)
+	offset := chainhash.HashSize
+	stats.Timestamp = time.Unix(int64(byteOrder.Uint64(serialized[offset:])), 0)
+	offset += 8
+	stats.TxCount = byteOrder.Uint32(serialized[offset:])
+	offset += 4
+	stats.Size = byteOrder.Uint32(serialized[offset:])
+	offset += 4
+	stats.TotalFees = int64(byteOrder.Uint64(serialized[offset:]))
+	offset += 8
+	stats.OutputVolume = int64(byteOrder.Uint64(serialized[offset:]))
+	return &stats, nil
+}
+
+// calcBlockStats computes the chain statistics index entry of the passed block
+// which spends the passed outputs.
+func calcBlockStats(block *bchutil.Block, stxos []blockchain.SpentTxOut) (*BlockStats, error) {
+	msgBlock := block.MsgBlock()
+	stats := &BlockStats{
+		Hash:      *block.Hash(),
+		Height:    block.Height(),
+		Timestamp: msgBlock.Header.Timestamp,
+		TxCount:   uint32(len(msgBlock.Transactions)),
+		Size:      uint32(msgBlock.SerializeSize()),
+	}
+
+	stxoIndex := 0
+	for txIdx, tx := range msgBlock.Transactions {
+		// Coinbases do not reference any inputs and their outputs only
+		// claim the subsidy and fees.
+		if txIdx == 0 {
+			continue
+		}
+		if stxoIndex+len(tx.TxIn) > len(stxos) {
+			return nil, AssertError(fmt.Sprintf("missing spent "+
+				"outputs of block %v", block.Hash()))
+		}
+		for range tx.TxIn {
+			stats.TotalFees += stxos[stxoIndex].Amount
+			stxoIndex++
+		}
+		for _, txOut := range tx.TxOut {
+			stats.TotalFees -= txOut.Value
+			stats.OutputVolume += txOut.Value
+		}
+	}
+	return stats, nil
+}
+
+// StatsIndex implements a chain statistics index.  That is to say, it records
+// aggregates of every block in the main chain, such as its fees and output
+// volume, which can be queried and aggregated over ranges of blocks.
+type StatsIndex struct {
+	db database.DB
+}
+
+// Ensure the StatsIndex type implements the Indexer interface.
+var _ Indexer = (*StatsIndex)(nil)
+
+// Ensure the StatsIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*StatsIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *StatsIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *StatsIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// StartBlock is used to indicate the proper start block for the index manager.
+//
+// This is part of the Indexer interface.
+func (idx *StatsIndex) StartBlock() (*chainhash.Hash, int32) {
+	return nil, -1
+}
+
+// Migrate is only provided to satisfy the Indexer interface as there is nothing to
+// migrate this index.
+//
+// This is part of the Indexer interface.
+func (idx *StatsIndex) Migrate(db database.DB, interrupt <-chan struct{}) error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *StatsIndex) Key() []byte {
+	return statsIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *StatsIndex) Name() string {
+	return statsIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the chain
+// statistics index.
+//
+// This is part of the Indexer interface.
+func (idx *StatsIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(statsIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer records the statistics of the
+// block.
+//
+// This is part of the Indexer interface.
+func (idx *StatsIndex) ConnectBlock(dbTx database.Tx, block *bchutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	stats, err := calcBlockStats(block, stxos)
+	if err != nil {
+		return err
+	}
+	bucket := dbTx.Metadata().Bucket(statsIndexKey)
+	return bucket.Put(statsIndexEntryKey(block.Height()),
+		serializeBlockStats(stats))
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the statistics of
+// the block.
+//
+// This is part of the Indexer interface.
+func (idx *StatsIndex) DisconnectBlock(dbTx database.Tx, block *bchutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	bucket := dbTx.Metadata().Bucket(statsIndexKey)
+	return bucket.Delete(statsIndexEntryKey(block.Height()))
+}
+
+// errTooManyPeriods is returned by AggregateStats when the statistics would
+// consist of more periods than requested.
+var errTooManyPeriods = errors.New("too many periods")
+
+// IsTooManyPeriodsErr returns whether the passed error was returned by
+// AggregateStats because the statistics consist of too many periods.
+func IsTooManyPeriodsErr(err error) bool {
+	return err == errTooManyPeriods
+}
+
+// AggregateStats returns the statistics of the main chain blocks from
+// startHeight to endHeight inclusive, aggregated by the passed interval and
+// sorted by period.  Blocks are assigned to periods by their timestamp, which
+// is not strictly increasing, so the height ranges of adjacent periods may
+// overlap.  An error for which IsTooManyPeriodsErr returns true is returned
+// when there would be more than maxPeriods periods.
+//
+// This function is safe for concurrent access.
+func (idx *StatsIndex) AggregateStats(startHeight, endHeight int32,
+	interval StatsInterval, maxPeriods int) ([]*AggregateStats, error) {
+
+	periods := make(map[int64]*AggregateStats)
+	err := idx.db.View(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(statsIndexKey).Cursor()
+		for ok := cursor.Seek(statsIndexEntryKey(startHeight)); ok; ok = cursor.Next() {
+			height := int32(binary.BigEndian.Uint32(cursor.Key()))
+			if height > endHeight {
+				break
+			}
+			stats, err := deserializeBlockStats(height, cursor.Value())
+			if err != nil {
+				return database.Error{
+					ErrorCode: database.ErrCorruption,
+					Description: fmt.Sprintf("corrupt chain "+
+						"statistics index entry for "+
+						"height %d: %v", height, err),
+				}
+			}
+
+			start := interval.periodStart(stats.Timestamp.Unix())
+			if interval == StatsPerBlock {
+				start = int64(height)
+			}
+			period, ok := periods[start]
+			if !ok {
+				if len(periods) == maxPeriods {
+					return errTooManyPeriods
+				}
+				period = &AggregateStats{
+					PeriodStart: time.Unix(interval.periodStart(
+						stats.Timestamp.Unix()), 0),
+				}
+				periods[start] = period
+			}
+			period.add(stats)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*AggregateStats, 0, len(periods))
+	for _, period := range periods {
+		result = append(result, period)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if interval == StatsPerBlock {
+			return result[i].StartHeight < result[j].StartHeight
+		}
+		return result[i].PeriodStart.Before(result[j].PeriodStart)
+	})
+	return result, nil
+}
+
+// NewStatsIndex returns a new instance of an indexer that is used to record the
+// statistics of every block in the main chain.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewStatsIndex(db database.DB) *StatsIndex {
+	return &StatsIndex{db: db}
+}
+
+// DropStatsIndex drops the chain statistics index from the provided database if
+// it exists.
+func DropStatsIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, statsIndexKey, statsIndexName, interrupt)
+}
@@ -0,0 +1,186 @@
+package indexers
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/database"
+	_ "github.com/gcash/bchd/database/ffldb"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// TestStatsIntervalPeriodStart ensures block times are assigned to the UTC day
+// and the week starting on Monday they fall in.
+func TestStatsIntervalPeriodStart(t *testing.T) {
+	tests := []struct {
+		interval StatsInterval
+		time     time.Time
+		want     time.Time
+	}{
+		{
+			StatsDaily,
+			time.Date(2024, 1, 3, 17, 4, 5, 0, time.UTC),
+			time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			StatsWeekly,
+			time.Date(2024, 1, 3, 17, 4, 5, 0, time.UTC),
+			time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			StatsWeekly,
+			time.Date(2024, 1, 7, 23, 59, 59, 0, time.UTC),
+			time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			StatsWeekly,
+			time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			StatsWeekly,
+			time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(1969, 12, 29, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for i, test := range tests {
+		got := test.interval.periodStart(test.time.Unix())
+		if got != test.want.Unix() {
+			t.Errorf("test #%d: got %v, want %v", i,
+				time.Unix(got, 0).UTC(), test.want)
+		}
+	}
+}
+
+// TestStatsIndex ensures the chain statistics index records the statistics of
+// connected blocks, removes those of disconnected blocks, and aggregates them
+// by the requested interval.
+func TestStatsIndex(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	db, err := database.Create("ffldb", filepath.Join(t.TempDir(), "db"),
+		params.Net)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	idx := NewStatsIndex(db)
+	if err := db.Update(idx.Create); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	script := []byte{txscript.OP_TRUE}
+	newBlock := func(height int32, timestamp time.Time, txns ...*wire.MsgTx) *bchutil.Block {
+		coinbase := wire.NewMsgTx(wire.TxVersion)
+		coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex), []byte{0x51, 0x51}))
+		coinbase.AddTxOut(wire.NewTxOut(5000, script, wire.TokenData{}))
+		msgBlock := wire.NewMsgBlock(&wire.BlockHeader{Timestamp: timestamp})
+		msgBlock.AddTransaction(coinbase)
+		for _, tx := range txns {
+			msgBlock.AddTransaction(tx)
+		}
+		block := bchutil.NewBlock(msgBlock)
+		block.SetHeight(height)
+		return block
+	}
+	spend := func(outputs ...int64) *wire.MsgTx {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, 0), nil))
+		for _, value := range outputs {
+			tx.AddTxOut(wire.NewTxOut(value, script, wire.TokenData{}))
+		}
+		return tx
+	}
+
+	// Two blocks are mined on Monday and one on the Tuesday of the same
+	// week.  Each transaction spends an output of 1000 satoshis.
+	monday := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	blocks := []*bchutil.Block{
+		newBlock(1, monday),
+		newBlock(2, monday.Add(time.Hour), spend(900), spend(500, 400)),
+		newBlock(3, monday.Add(24*time.Hour), spend(990)),
+	}
+	stxos := [][]blockchain.SpentTxOut{
+		nil,
+		{{Amount: 1000, PkScript: script}, {Amount: 1000, PkScript: script}},
+		{{Amount: 1000, PkScript: script}},
+	}
+	for i, block := range blocks {
+		err := db.Update(func(dbTx database.Tx) error {
+			return idx.ConnectBlock(dbTx, block, stxos[i])
+		})
+		if err != nil {
+			t.Fatalf("ConnectBlock #%d: %v", i, err)
+		}
+	}
+
+	perBlock, err := idx.AggregateStats(0, 10, StatsPerBlock, 10)
+	if err != nil {
+		t.Fatalf("AggregateStats: %v", err)
+	}
+	if len(perBlock) != 3 {
+		t.Fatalf("got %d blocks, want 3", len(perBlock))
+	}
+	second := perBlock[1]
+	if second.StartHeight != 2 || second.TxCount != 3 ||
+		second.TotalFees != 200 || second.OutputVolume != 1800 ||
+		second.Size != uint64(blocks[1].MsgBlock().SerializeSize()) ||
+		!second.PeriodStart.Equal(monday.Add(time.Hour)) {
+
+		t.Fatalf("unexpected statistics of block 2: %+v", second)
+	}
+
+	daily, err := idx.AggregateStats(2, 3, StatsDaily, 10)
+	if err != nil {
+		t.Fatalf("AggregateStats: %v", err)
+	}
+	if len(daily) != 2 || daily[0].BlockCount != 1 ||
+		daily[0].TotalFees != 200 || daily[1].StartHeight != 3 ||
+		daily[1].TotalFees != 10 ||
+		!daily[1].PeriodStart.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) {
+
+		t.Fatalf("unexpected daily statistics: %+v %+v", daily[0], daily[1])
+	}
+
+	weekly, err := idx.AggregateStats(0, 3, StatsWeekly, 10)
+	if err != nil {
+		t.Fatalf("AggregateStats: %v", err)
+	}
+	if len(weekly) != 1 || weekly[0].BlockCount != 3 ||
+		weekly[0].StartHeight != 1 || weekly[0].EndHeight != 3 ||
+		weekly[0].TxCount != 6 || weekly[0].TotalFees != 210 ||
+		weekly[0].OutputVolume != 2790 {
+
+		t.Fatalf("unexpected weekly statistics: %+v", weekly[0])
+	}
+
+	if _, err := idx.AggregateStats(0, 3, StatsPerBlock, 2); !IsTooManyPeriodsErr(err) {
+		t.Fatalf("unexpected error for too many periods: %v", err)
+	}
+
+	// Disconnecting the tip removes its statistics.
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, blocks[2], stxos[2])
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: %v", err)
+	}
+	weekly, err = idx.AggregateStats(0, 3, StatsWeekly, 10)
+	if err != nil {
+		t.Fatalf("AggregateStats: %v", err)
+	}
+	if len(weekly) != 1 || weekly[0].BlockCount != 2 ||
+		weekly[0].EndHeight != 2 || weekly[0].TotalFees != 200 {
+
+		t.Fatalf("unexpected weekly statistics after disconnect: %+v",
+			weekly[0])
+	}
+}
@@ -0,0 +1,411 @@
+package indexers
+
+import (
+	"fmt"
+
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+const (
+	// tokenIndexName is the human-readable name for the index.
+	tokenIndexName = "token category index"
+
+	// tokenStatsSize is the size of a serialized token index entry.
+	tokenStatsSize = chainhash.HashSize + 4 + 8 + 8 + 8
+)
+
+var (
+	// tokenIndexKey is the key of the token category index and the db
+	// bucket used to house it.
+	tokenIndexKey = []byte("tokenbycategoryidx")
+)
+
+// -----------------------------------------------------------------------------
+// The token category index consists of an entry for every CashToken category
+// created in the main chain.  Each entry tracks the transaction which created
+// the category along with the tokens of the category which are held by unspent
+// outputs, so the supply of a category can be looked up without scanning the
+// chain.  Tokens held by provably unspendable outputs are burned and thus not
+// counted.
+//
+// The serialized format for keys and values in the bucket is:
+//   <category> = <genesis tx hash><genesis height><fungible supply><nft count>
+//                <minting nft count>
+//
+//   Field              Type              Size
+//   category           [32]byte          32 bytes
+//   genesis tx hash    chainhash.Hash    32 bytes
+//   genesis height     uint32            4 bytes
+//   fungible supply    uint64            8 bytes
+//   nft count          uint64            8 bytes
+//   minting nft count  uint64            8 bytes
+//   -----
+//   Total: 92 bytes
+// -----------------------------------------------------------------------------
+
+// TokenStats describes the tokens of a CashToken category.
+type TokenStats struct {
+	// GenesisTx is the hash of the transaction which created the category
+	// and GenesisHeight the height of the block containing it.
+	GenesisTx     chainhash.Hash
+	GenesisHeight int32
+
+	// FungibleSupply is the amount of fungible tokens held by unspent
+	// outputs.
+	FungibleSupply uint64
+
+	// NftCount is the number of non-fungible tokens held by unspent
+	// outputs, of which MintingNftCount have the minting capability.
+	NftCount        uint64
+	MintingNftCount uint64
+}
+
+// serializeTokenStats returns the serialized token index entry for the passed
+// stats according to the format described above.
+func serializeTokenStats(stats *TokenStats) []byte {
+	serialized := make([]byte, tokenStatsSize)
+	copy(serialized, stats.GenesisTx[:])
+	offset := chainhash.HashSize
+	byteOrder.PutUint32(serialized[offset:], uint32(stats.GenesisHeight))
+	offset += 4
+	byteOrder.PutUint64(serialized[offset:], stats.FungibleSupply)
+	offset += 8
+	byteOrder.PutUint64(serialized[offset:], stats.NftCount)
+	offset += 8
+	byteOrder.PutUint64(serialized[offset:], stats.MintingNftCount)
+	return serialized
+}
+
+// deserializeTokenStats decodes the passed serialized token index entry.
+func deserializeTokenStats(serialized []byte) (*TokenStats, error) {
+	if len(serialized) != tokenStatsSize {
+		return nil, errDeserialize(fmt.Sprintf("unexpected token index "+
+			"entry size %d", len(serialized)))
+	}
+
+	var stats TokenStats
+	copy(stats.GenesisTx[:], serialized)
+	offset := chainhash.HashSize
+	stats.GenesisHeight = int32(byteOrder.Uint32(serialized[offset:]))
+	offset += 4
+	stats.FungibleSupply = byteOrder.Uint64(serialized[offset:])
+	offset += 8
+	stats.NftCount = byteOrder.Uint64(serialized[offset:])
+	offset += 8
+	stats.MintingNftCount = byteOrder.Uint64(serialized[offset:])
+	return &stats, nil
+}
+
+// dbFetchTokenStats retrieves the token index entry for the passed category.
+// When there is no entry for the category, nil will be returned for both the
+// entry and the error.
+func dbFetchTokenStats(dbTx database.Tx, category [32]byte) (*TokenStats, error) {
+	serialized := dbTx.Metadata().Bucket(tokenIndexKey).Get(category[:])
+	if serialized == nil {
+		return nil, nil
+	}
+	stats, err := deserializeTokenStats(serialized)
+	if err != nil {
+		return nil, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt token index entry for %x: %v", category, err),
+		}
+	}
+	return stats, nil
+}
+
+// TokenIndex implements a CashToken category index.  That is to say, it
+// supports querying the genesis transaction and circulating supply of each
+// token category.
+type TokenIndex struct {
+	db          database.DB
+	chainParams *chaincfg.Params
+}
+
+// Ensure the TokenIndex type implements the Indexer interface.
+var _ Indexer = (*TokenIndex)(nil)
+
+// Ensure the TokenIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*TokenIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *TokenIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *TokenIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// StartBlock is used to indicate the proper start block for the index manager.
+//
+// This is part of the Indexer interface.
+func (idx *TokenIndex) StartBlock() (*chainhash.Hash, int32) {
+	return nil, -1
+}
+
+// Migrate is only provided to satisfy the Indexer interface as there is nothing to
+// migrate this index.
+//
+// This is part of the Indexer interface.
+func (idx *TokenIndex) Migrate(db database.DB, interrupt <-chan struct{}) error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *TokenIndex) Key() []byte {
+	return tokenIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *TokenIndex) Name() string {
+	return tokenIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the token
+// category index.
+//
+// This is part of the Indexer interface.
+func (idx *TokenIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(tokenIndexKey)
+	return err
+}
+
+// spentTokenData returns the tokens held by the passed spent output, if any.
+func spentTokenData(stxo *blockchain.SpentTxOut) wire.TokenData {
+	// The tokens are stored as a prefix of the script in the spend
+	// journal.  Since the prefix of spent outputs has already been
+	// validated, any which can not be parsed is not a token prefix.
+	var tokenData wire.TokenData
+	if _, err := tokenData.SeparateTokenDataFromPKScriptIfExists(stxo.PkScript, 0); err != nil {
+		return wire.TokenData{}
+	}
+	return tokenData
+}
+
+// updateTokenStats adds the passed tokens to the passed stats, or removes
+// them when remove is true.
+func updateTokenStats(stats *TokenStats, tokenData *wire.TokenData, remove bool) error {
+	nfts := uint64(0)
+	if tokenData.HasNFT() {
+		nfts = 1
+	}
+	mintingNfts := uint64(0)
+	if tokenData.IsMintingNFT() {
+		mintingNfts = 1
+	}
+
+	if !remove {
+		stats.FungibleSupply += tokenData.Amount
+		stats.NftCount += nfts
+		stats.MintingNftCount += mintingNfts
+		return nil
+	}
+	if stats.FungibleSupply < tokenData.Amount || stats.NftCount < nfts ||
+		stats.MintingNftCount < mintingNfts {
+
+		return AssertError(fmt.Sprintf("token index entry for category "+
+			"%x holds fewer tokens than are removed",
+			tokenData.CategoryID))
+	}
+	stats.FungibleSupply -= tokenData.Amount
+	stats.NftCount -= nfts
+	stats.MintingNftCount -= mintingNfts
+	return nil
+}
+
+// tokenChange describes tokens created or spent by a transaction.
+type tokenChange struct {
+	tokenData *wire.TokenData
+	spent     bool
+	txHash    *chainhash.Hash
+}
+
+// blockTokenChanges returns the tokens created and spent by the transactions
+// of the passed block in the order they are created and spent.
+func (idx *TokenIndex) blockTokenChanges(block *bchutil.Block,
+	stxos []blockchain.SpentTxOut) []tokenChange {
+
+	var changes []tokenChange
+	stxoIndex := 0
+	for txIdx, tx := range block.Transactions() {
+		// Coinbases do not reference any inputs.
+		if txIdx != 0 {
+			for range tx.MsgTx().TxIn {
+				stxo := &stxos[stxoIndex]
+				stxoIndex++
+				if stxo.Height <= idx.chainParams.Upgrade9ForkHeight {
+					continue
+				}
+				tokenData := spentTokenData(stxo)
+				if tokenData.IsEmpty() {
+					continue
+				}
+				changes = append(changes, tokenChange{
+					tokenData: &tokenData,
+					spent:     true,
+				})
+			}
+		}
+
+		for _, txOut := range tx.MsgTx().TxOut {
+			if txOut.TokenData.IsEmpty() ||
+				txscript.IsUnspendable(txOut.PkScript) {
+
+				continue
+			}
+			changes = append(changes, tokenChange{
+				tokenData: &txOut.TokenData,
+				txHash:    tx.Hash(),
+			})
+		}
+	}
+	return changes
+}
+
+// indexBlock updates the token index entries for the tokens created and
+// spent by the passed block.  When disconnect is true, the changes made by
+// connecting the block are reverted instead.
+func (idx *TokenIndex) indexBlock(dbTx database.Tx, block *bchutil.Block,
+	stxos []blockchain.SpentTxOut, disconnect bool) error {
+
+	// Outputs can not hold tokens before CashTokens activated.
+	height := block.Height()
+	if height <= idx.chainParams.Upgrade9ForkHeight {
+		return nil
+	}
+
+	// The changes are reverted in reverse order when disconnecting so
+	// tokens which are created and spent within the block are never
+	// removed before they are added back.
+	changes := idx.blockTokenChanges(block, stxos)
+	if disconnect {
+		for i, j := 0, len(changes)-1; i < j; i, j = i+1, j-1 {
+			changes[i], changes[j] = changes[j], changes[i]
+		}
+	}
+
+	// Load the entries of each category as it is first encountered in the
+	// block and write them back once all of the changes are applied.
+	entries := make(map[[32]byte]*TokenStats)
+	for _, change := range changes {
+		category := change.tokenData.CategoryID
+		stats, ok := entries[category]
+		if !ok {
+			var err error
+			stats, err = dbFetchTokenStats(dbTx, category)
+			if err != nil {
+				return err
+			}
+		}
+
+		// The first transaction paying tokens of a category is its
+		// genesis transaction.
+		if stats == nil {
+			if change.spent || disconnect {
+				return AssertError(fmt.Sprintf("missing token "+
+					"index entry for category %x", category))
+			}
+			stats = &TokenStats{
+				GenesisTx:     *change.txHash,
+				GenesisHeight: height,
+			}
+		}
+		entries[category] = stats
+
+		remove := change.spent != disconnect
+		if err := updateTokenStats(stats, change.tokenData, remove); err != nil {
+			return err
+		}
+	}
+
+	bucket := dbTx.Metadata().Bucket(tokenIndexKey)
+	for category, stats := range entries {
+		// Categories created by a disconnected block no longer exist.
+		if disconnect && stats.GenesisHeight == height {
+			if err := bucket.Delete(category[:]); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := bucket.Put(category[:], serializeTokenStats(stats)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds the tokens paid by the block
+// to the supply of their categories and removes the tokens it spends.
+//
+// This is part of the Indexer interface.
+func (idx *TokenIndex) ConnectBlock(dbTx database.Tx, block *bchutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	return idx.indexBlock(dbTx, block, stxos, false)
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer reverts the changes to the
+// supply of each category made when the block was connected and removes the
+// categories it created.
+//
+// This is part of the Indexer interface.
+func (idx *TokenIndex) DisconnectBlock(dbTx database.Tx, block *bchutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	return idx.indexBlock(dbTx, block, stxos, true)
+}
+
+// TokenStats returns the stats of the passed token category from the token
+// index.  When there is no entry for the provided category, nil will be
+// returned for both the entry and the error.
+//
+// This function is safe for concurrent access.
+func (idx *TokenIndex) TokenStats(category [32]byte) (*TokenStats, error) {
+	var stats *TokenStats
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		stats, err = dbFetchTokenStats(dbTx, category)
+		return err
+	})
+	return stats, err
+}
+
+// NewTokenIndex returns a new instance of an indexer that is used to create a
+// mapping of each CashToken category to its genesis transaction and the tokens
+// of the category in circulation.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewTokenIndex(db database.DB, chainParams *chaincfg.Params) *TokenIndex {
+	return &TokenIndex{db: db, chainParams: chainParams}
+}
+
+// DropTokenIndex drops the token category index from the provided database if
+// it exists.
+func DropTokenIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, tokenIndexKey, tokenIndexName, interrupt)
+}
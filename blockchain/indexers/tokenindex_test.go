@@ -0,0 +1,143 @@
+package indexers
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/database"
+	_ "github.com/gcash/bchd/database/ffldb"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// TestTokenIndex ensures the token index tracks the genesis and circulating
+// supply of token categories as blocks are connected and disconnected.
+func TestTokenIndex(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	db, err := database.Create("ffldb", filepath.Join(t.TempDir(), "db"),
+		params.Net)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	idx := NewTokenIndex(db, params)
+	if err := db.Update(idx.Create); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	category := [32]byte{0x01}
+	fungible := wire.TokenData{
+		CategoryID: category,
+		Amount:     1000,
+		BitField:   wire.HAS_AMOUNT,
+	}
+	minting := wire.TokenData{
+		CategoryID: category,
+		BitField:   wire.HAS_NFT | wire.MINTING,
+	}
+	script := []byte{txscript.OP_TRUE}
+	spent := func(tokenData wire.TokenData, height int32) blockchain.SpentTxOut {
+		buf := tokenData.TokenDataBuffer()
+		buf.Write(script)
+		return blockchain.SpentTxOut{
+			Amount:   1000,
+			PkScript: buf.Bytes(),
+			Height:   height,
+		}
+	}
+	newBlock := func(height int32, txns ...*wire.MsgTx) *bchutil.Block {
+		coinbase := wire.NewMsgTx(wire.TxVersion)
+		coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex), []byte{0x51, 0x51}))
+		coinbase.AddTxOut(wire.NewTxOut(1000, script, wire.TokenData{}))
+		msgBlock := wire.NewMsgBlock(&wire.BlockHeader{})
+		msgBlock.AddTransaction(coinbase)
+		for _, tx := range txns {
+			msgBlock.AddTransaction(tx)
+		}
+		block := bchutil.NewBlock(msgBlock)
+		block.SetHeight(height)
+		return block
+	}
+
+	// The genesis transaction creates fungible tokens and a minting token
+	// and burns some fungible tokens in a null data output.  A second
+	// transaction in the same block spends the fungible tokens and burns
+	// part of them.
+	height := params.Upgrade9ForkHeight + 1
+	genesis := wire.NewMsgTx(wire.TxVersion)
+	genesis.AddTxIn(wire.NewTxIn(wire.NewOutPoint((*chainhash.Hash)(&category), 0), nil))
+	genesis.AddTxOut(wire.NewTxOut(1000, script, fungible))
+	genesis.AddTxOut(wire.NewTxOut(1000, script, minting))
+	genesis.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_RETURN}, fungible))
+	send := wire.NewMsgTx(wire.TxVersion)
+	send.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, 0), nil))
+	sent := fungible
+	sent.Amount = 600
+	send.AddTxOut(wire.NewTxOut(1000, script, sent))
+	block1 := newBlock(height, genesis, send)
+	stxos1 := []blockchain.SpentTxOut{
+		{Amount: 1000, PkScript: script, Height: 1},
+		spent(fungible, height),
+	}
+
+	// The second block spends the minting token.
+	mint := wire.NewMsgTx(wire.TxVersion)
+	mint.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, 1), nil))
+	mint.AddTxOut(wire.NewTxOut(1000, script, wire.TokenData{}))
+	block2 := newBlock(height+1, mint)
+	stxos2 := []blockchain.SpentTxOut{spent(minting, height)}
+
+	checkStats := func(desc string, want *TokenStats) {
+		t.Helper()
+		stats, err := idx.TokenStats(category)
+		if err != nil {
+			t.Fatalf("%s: TokenStats: %v", desc, err)
+		}
+		if !reflect.DeepEqual(stats, want) {
+			t.Fatalf("%s: unexpected stats -- got %+v, want %+v",
+				desc, stats, want)
+		}
+	}
+	update := func(f func(database.Tx, *bchutil.Block, []blockchain.SpentTxOut) error,
+		block *bchutil.Block, stxos []blockchain.SpentTxOut) {
+
+		t.Helper()
+		err := db.Update(func(dbTx database.Tx) error {
+			return f(dbTx, block, stxos)
+		})
+		if err != nil {
+			t.Fatalf("unable to update index: %v", err)
+		}
+	}
+
+	afterBlock1 := &TokenStats{
+		GenesisTx:       genesis.TxHash(),
+		GenesisHeight:   height,
+		FungibleSupply:  600,
+		NftCount:        1,
+		MintingNftCount: 1,
+	}
+	afterBlock2 := *afterBlock1
+	afterBlock2.NftCount = 0
+	afterBlock2.MintingNftCount = 0
+
+	update(idx.ConnectBlock, block1, stxos1)
+	checkStats("connect block 1", afterBlock1)
+	update(idx.ConnectBlock, block2, stxos2)
+	checkStats("connect block 2", &afterBlock2)
+	update(idx.DisconnectBlock, block2, stxos2)
+	checkStats("disconnect block 2", afterBlock1)
+	update(idx.DisconnectBlock, block1, stxos1)
+	checkStats("disconnect block 1", nil)
+
+	// Blocks before CashTokens activated are not indexed.
+	update(idx.ConnectBlock, newBlock(height-1, genesis), stxos1[:1])
+	checkStats("connect block before activation", nil)
+}
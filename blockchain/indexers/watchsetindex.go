@@ -0,0 +1,722 @@
+package indexers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchutil"
+)
+
+const (
+	// watchSetIndexName is the human-readable name for the index.
+	watchSetIndexName = "watch set index"
+
+	// MaxWatchSetNameLen is the maximum length of the name of a watch set.
+	MaxWatchSetNameLen = 64
+
+	// watchSetEntrySize is the size of a serialized watch set entry.  It
+	// consists of 4 bytes set id + 8 bytes script count.
+	watchSetEntrySize = 4 + 8
+
+	// watchSetScriptKeySize is the size of the key of a watched script.  It
+	// consists of the 32 byte script hash + 4 bytes set id.
+	watchSetScriptKeySize = sha256.Size + 4
+
+	// watchSetMatchKeySize is the size of the key of a match.  It consists
+	// of 4 bytes set id + 4 bytes block height + 4 bytes transaction index.
+	watchSetMatchKeySize = 4 + 4 + 4
+
+	// watchSetDeleteBatchSize is the maximum number of entries deleted in
+	// a single database transaction when a watch set is deleted.
+	watchSetDeleteBatchSize = 100000
+)
+
+var (
+	// watchSetIndexKey is the key of the watch set index and the parent db
+	// bucket of its buckets.
+	watchSetIndexKey = []byte("watchsetidx")
+
+	// watchSetNextIDKeyName is the key in the parent bucket which houses the
+	// id the next created watch set is assigned.
+	watchSetNextIDKeyName = []byte("nextid")
+
+	// watchSetSetsBucketName is the name of the bucket which houses the
+	// watch sets by name.
+	watchSetSetsBucketName = []byte("sets")
+
+	// watchSetScriptsBucketName is the name of the bucket which maps the
+	// hashes of the watched scripts to the sets watching them.
+	watchSetScriptsBucketName = []byte("scripts")
+
+	// watchSetSetScriptsBucketName is the name of the bucket which maps the
+	// watch sets to the hashes of the scripts they watch.
+	watchSetSetScriptsBucketName = []byte("setscripts")
+
+	// watchSetMatchesBucketName is the name of the bucket which houses the
+	// transactions in the main chain which match the watch sets.
+	watchSetMatchesBucketName = []byte("matches")
+
+	// ErrWatchSetNotFound is returned when a watch set does not exist.
+	ErrWatchSetNotFound = errors.New("watch set not found")
+
+	// ErrInvalidWatchSetName is returned when the name of a watch set is
+	// empty or longer than MaxWatchSetNameLen.
+	ErrInvalidWatchSetName = errors.New("invalid watch set name")
+)
+
+// -----------------------------------------------------------------------------
+// The watch set index keeps named sets of output scripts registered by clients
+// and records the transactions in the main chain which pay to or spend from any
+// of them.  This allows monitoring a large number of scripts without
+// maintaining the address index for every script in the chain.  Transactions
+// are only matched in the blocks connected after the scripts were added, the
+// chain is not rescanned for them.
+//
+// The index consists of the following buckets nested in the parent bucket:
+//
+//   sets:       <name> -> <set id><script count>
+//   scripts:    <script hash><set id> -> empty
+//   setscripts: <set id><script hash> -> empty
+//   matches:    <set id><height><tx index> -> <tx hash>
+//
+// The script hash is the single SHA256 hash of the script and all integers in
+// the keys are big endian so the entries are ordered by them.
+// -----------------------------------------------------------------------------
+
+// WatchSet describes a set of watched scripts.
+type WatchSet struct {
+	Name    string
+	Scripts uint64
+}
+
+// WatchSetMatch describes a transaction in the main chain which pays to or
+// spends from a script of a watch set.
+type WatchSetMatch struct {
+	Height  int32
+	TxIndex uint32
+	TxHash  chainhash.Hash
+}
+
+// watchSetScriptHash returns the hash the passed script is watched by.
+func watchSetScriptHash(pkScript []byte) [sha256.Size]byte {
+	return sha256.Sum256(pkScript)
+}
+
+// watchSetMatchKey returns the key of the match of the passed transaction of
+// the block at the passed height for the passed set id.
+func watchSetMatchKey(id uint32, height int32, txIdx uint32) []byte {
+	key := make([]byte, watchSetMatchKeySize)
+	binary.BigEndian.PutUint32(key[0:4], id)
+	binary.BigEndian.PutUint32(key[4:8], uint32(height))
+	binary.BigEndian.PutUint32(key[8:12], txIdx)
+	return key
+}
+
+// watchSetScriptKey returns the key of the passed script hash of the set with
+// the passed id in the scripts bucket.
+func watchSetScriptKey(scriptHash []byte, id uint32) []byte {
+	key := make([]byte, watchSetScriptKeySize)
+	copy(key, scriptHash)
+	binary.BigEndian.PutUint32(key[sha256.Size:], id)
+	return key
+}
+
+// watchSetSetScriptKey returns the key of the passed script hash of the set
+// with the passed id in the setscripts bucket.
+func watchSetSetScriptKey(id uint32, scriptHash []byte) []byte {
+	key := make([]byte, watchSetScriptKeySize)
+	binary.BigEndian.PutUint32(key[0:4], id)
+	copy(key[4:], scriptHash)
+	return key
+}
+
+// serializeWatchSet returns the serialized entry of a watch set.
+func serializeWatchSet(id uint32, scripts uint64) []byte {
+	serialized := make([]byte, watchSetEntrySize)
+	byteOrder.PutUint32(serialized[0:4], id)
+	byteOrder.PutUint64(serialized[4:12], scripts)
+	return serialized
+}
+
+// dbFetchWatchSet returns the id and script count of the named watch set.
+// ErrWatchSetNotFound is returned when it does not exist.
+func dbFetchWatchSet(setsBucket database.Bucket, name string) (uint32, uint64, error) {
+	serialized := setsBucket.Get([]byte(name))
+	if serialized == nil {
+		return 0, 0, ErrWatchSetNotFound
+	}
+	if len(serialized) < watchSetEntrySize {
+		return 0, 0, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt watch set entry for "+
+				"%q", name),
+		}
+	}
+	return byteOrder.Uint32(serialized[0:4]),
+		byteOrder.Uint64(serialized[4:12]), nil
+}
+
+// validateWatchSetName returns ErrInvalidWatchSetName when the passed name can
+// not be used for a watch set.
+func validateWatchSetName(name string) error {
+	if len(name) == 0 || len(name) > MaxWatchSetNameLen {
+		return ErrInvalidWatchSetName
+	}
+	return nil
+}
+
+// WatchSetIndex implements an index of the transactions in the main chain which
+// pay to or spend from the scripts of persistent, named watch sets.
+type WatchSetIndex struct {
+	db database.DB
+}
+
+// Ensure the WatchSetIndex type implements the Indexer interface.
+var _ Indexer = (*WatchSetIndex)(nil)
+
+// Ensure the WatchSetIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*WatchSetIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *WatchSetIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *WatchSetIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// StartBlock is used to indicate the proper start block for the index manager.
+//
+// This is part of the Indexer interface.
+func (idx *WatchSetIndex) StartBlock() (*chainhash.Hash, int32) {
+	return nil, -1
+}
+
+// Migrate is only provided to satisfy the Indexer interface as there is nothing to
+// migrate this index.
+//
+// This is part of the Indexer interface.
+func (idx *WatchSetIndex) Migrate(db database.DB, interrupt <-chan struct{}) error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *WatchSetIndex) Key() []byte {
+	return watchSetIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *WatchSetIndex) Name() string {
+	return watchSetIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the buckets for the watch set
+// index.
+//
+// This is part of the Indexer interface.
+func (idx *WatchSetIndex) Create(dbTx database.Tx) error {
+	parent, err := dbTx.Metadata().CreateBucket(watchSetIndexKey)
+	if err != nil {
+		return err
+	}
+	for _, bucketName := range [][]byte{watchSetSetsBucketName,
+		watchSetScriptsBucketName, watchSetSetScriptsBucketName,
+		watchSetMatchesBucketName} {
+
+		if _, err := parent.CreateBucket(bucketName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer records the transactions in the
+// block which pay to or spend from a watched script for each watch set.
+//
+// This is part of the Indexer interface.
+func (idx *WatchSetIndex) ConnectBlock(dbTx database.Tx, block *bchutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	parent := dbTx.Metadata().Bucket(watchSetIndexKey)
+	cursor := parent.Bucket(watchSetScriptsBucketName).Cursor()
+
+	// Nothing to match when no scripts are watched.
+	if !cursor.First() {
+		return nil
+	}
+
+	// Collect the matches before writing any of them since the cursor
+	// must not be used while the database is modified.
+	var matches [][]byte
+	matchScript := func(pkScript []byte, txIdx int, matched map[uint32]struct{}) {
+		scriptHash := watchSetScriptHash(pkScript)
+		for ok := cursor.Seek(scriptHash[:]); ok; ok = cursor.Next() {
+			key := cursor.Key()
+			if !bytes.HasPrefix(key, scriptHash[:]) {
+				break
+			}
+			id := binary.BigEndian.Uint32(key[sha256.Size:])
+			if _, ok := matched[id]; ok {
+				continue
+			}
+			matched[id] = struct{}{}
+			matches = append(matches, watchSetMatchKey(id,
+				block.Height(), uint32(txIdx)))
+		}
+	}
+	stxoIndex := 0
+	for txIdx, tx := range block.Transactions() {
+		matched := make(map[uint32]struct{})
+		if txIdx != 0 {
+			for range tx.MsgTx().TxIn {
+				matchScript(stxos[stxoIndex].PkScript, txIdx, matched)
+				stxoIndex++
+			}
+		}
+		for _, txOut := range tx.MsgTx().TxOut {
+			matchScript(txOut.PkScript, txIdx, matched)
+		}
+	}
+
+	matchesBucket := parent.Bucket(watchSetMatchesBucketName)
+	for _, key := range matches {
+		txIdx := binary.BigEndian.Uint32(key[8:12])
+		txHash := block.Transactions()[txIdx].Hash()
+		if err := matchesBucket.Put(key, txHash[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the matches of the
+// block for every watch set.
+//
+// This is part of the Indexer interface.
+func (idx *WatchSetIndex) DisconnectBlock(dbTx database.Tx, block *bchutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	parent := dbTx.Metadata().Bucket(watchSetIndexKey)
+	matchesBucket := parent.Bucket(watchSetMatchesBucketName)
+
+	// The matches are looked up by the ids of the current sets rather than
+	// by matching the block again, since the scripts of the sets may have
+	// changed since the block was connected.
+	var keys [][]byte
+	err := parent.Bucket(watchSetSetsBucketName).ForEach(func(k, v []byte) error {
+		if len(v) < watchSetEntrySize {
+			return database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: fmt.Sprintf("corrupt watch set entry "+
+					"for %q", k),
+			}
+		}
+		prefix := watchSetMatchKey(byteOrder.Uint32(v[0:4]),
+			block.Height(), 0)[:8]
+		cursor := matchesBucket.Cursor()
+		for ok := cursor.Seek(prefix); ok; ok = cursor.Next() {
+			if !bytes.HasPrefix(cursor.Key(), prefix) {
+				break
+			}
+			keys = append(keys, append([]byte(nil), cursor.Key()...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := matchesBucket.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddScripts adds the passed scripts to the named watch set, which is created
+// when it does not exist yet, and returns the number of scripts in the set.
+// Transactions paying to or spending from the scripts are matched starting
+// with the next block connected to the main chain.
+//
+// This function is safe for concurrent access.
+func (idx *WatchSetIndex) AddScripts(name string, pkScripts [][]byte) (uint64, error) {
+	if err := validateWatchSetName(name); err != nil {
+		return 0, err
+	}
+
+	var count uint64
+	err := idx.db.Update(func(dbTx database.Tx) error {
+		parent := dbTx.Metadata().Bucket(watchSetIndexKey)
+		setsBucket := parent.Bucket(watchSetSetsBucketName)
+		scriptsBucket := parent.Bucket(watchSetScriptsBucketName)
+		setScriptsBucket := parent.Bucket(watchSetSetScriptsBucketName)
+
+		id, scripts, err := dbFetchWatchSet(setsBucket, name)
+		if err == ErrWatchSetNotFound {
+			// Assign the next id to the new set.
+			if serialized := parent.Get(watchSetNextIDKeyName); serialized != nil {
+				id = byteOrder.Uint32(serialized)
+			}
+			var nextID [4]byte
+			byteOrder.PutUint32(nextID[:], id+1)
+			err = parent.Put(watchSetNextIDKeyName, nextID[:])
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, pkScript := range pkScripts {
+			scriptHash := watchSetScriptHash(pkScript)
+			setScriptKey := watchSetSetScriptKey(id, scriptHash[:])
+			if setScriptsBucket.Get(setScriptKey) != nil {
+				continue
+			}
+			if err := setScriptsBucket.Put(setScriptKey, []byte{}); err != nil {
+				return err
+			}
+			scriptKey := watchSetScriptKey(scriptHash[:], id)
+			if err := scriptsBucket.Put(scriptKey, []byte{}); err != nil {
+				return err
+			}
+			scripts++
+		}
+
+		count = scripts
+		return setsBucket.Put([]byte(name), serializeWatchSet(id, scripts))
+	})
+	return count, err
+}
+
+// RemoveScripts removes the passed scripts from the named watch set and returns
+// the number of scripts left in the set.  The matches already recorded for the
+// scripts are kept.
+//
+// This function is safe for concurrent access.
+func (idx *WatchSetIndex) RemoveScripts(name string, pkScripts [][]byte) (uint64, error) {
+	var count uint64
+	err := idx.db.Update(func(dbTx database.Tx) error {
+		parent := dbTx.Metadata().Bucket(watchSetIndexKey)
+		setsBucket := parent.Bucket(watchSetSetsBucketName)
+		scriptsBucket := parent.Bucket(watchSetScriptsBucketName)
+		setScriptsBucket := parent.Bucket(watchSetSetScriptsBucketName)
+
+		id, scripts, err := dbFetchWatchSet(setsBucket, name)
+		if err != nil {
+			return err
+		}
+
+		for _, pkScript := range pkScripts {
+			scriptHash := watchSetScriptHash(pkScript)
+			setScriptKey := watchSetSetScriptKey(id, scriptHash[:])
+			if setScriptsBucket.Get(setScriptKey) == nil {
+				continue
+			}
+			if err := setScriptsBucket.Delete(setScriptKey); err != nil {
+				return err
+			}
+			scriptKey := watchSetScriptKey(scriptHash[:], id)
+			if err := scriptsBucket.Delete(scriptKey); err != nil {
+				return err
+			}
+			scripts--
+		}
+
+		count = scripts
+		return setsBucket.Put([]byte(name), serializeWatchSet(id, scripts))
+	})
+	return count, err
+}
+
+// DeleteWatchSet deletes the named watch set along with its scripts and
+// matches.  Large sets are deleted over several database transactions, so an
+// interrupted deletion leaves a set with fewer scripts behind which can be
+// deleted again.
+//
+// This function is safe for concurrent access.
+func (idx *WatchSetIndex) DeleteWatchSet(name string) error {
+	for done := false; !done; {
+		err := idx.db.Update(func(dbTx database.Tx) error {
+			parent := dbTx.Metadata().Bucket(watchSetIndexKey)
+			setsBucket := parent.Bucket(watchSetSetsBucketName)
+			scriptsBucket := parent.Bucket(watchSetScriptsBucketName)
+			setScriptsBucket := parent.Bucket(watchSetSetScriptsBucketName)
+			matchesBucket := parent.Bucket(watchSetMatchesBucketName)
+
+			id, scripts, err := dbFetchWatchSet(setsBucket, name)
+			if err != nil {
+				return err
+			}
+			var prefix [4]byte
+			binary.BigEndian.PutUint32(prefix[:], id)
+
+			// Collect a batch of the scripts and matches of the set.
+			var setScriptKeys, matchKeys [][]byte
+			cursor := setScriptsBucket.Cursor()
+			for ok := cursor.Seek(prefix[:]); ok; ok = cursor.Next() {
+				if !bytes.HasPrefix(cursor.Key(), prefix[:]) ||
+					len(setScriptKeys) == watchSetDeleteBatchSize {
+
+					break
+				}
+				setScriptKeys = append(setScriptKeys,
+					append([]byte(nil), cursor.Key()...))
+			}
+			cursor = matchesBucket.Cursor()
+			for ok := cursor.Seek(prefix[:]); ok; ok = cursor.Next() {
+				if !bytes.HasPrefix(cursor.Key(), prefix[:]) ||
+					len(setScriptKeys)+len(matchKeys) == watchSetDeleteBatchSize {
+
+					break
+				}
+				matchKeys = append(matchKeys,
+					append([]byte(nil), cursor.Key()...))
+			}
+
+			for _, key := range setScriptKeys {
+				if err := setScriptsBucket.Delete(key); err != nil {
+					return err
+				}
+				scriptKey := watchSetScriptKey(key[4:], id)
+				if err := scriptsBucket.Delete(scriptKey); err != nil {
+					return err
+				}
+			}
+			for _, key := range matchKeys {
+				if err := matchesBucket.Delete(key); err != nil {
+					return err
+				}
+			}
+
+			// Remove the set itself once all of its entries are gone.
+			if len(setScriptKeys)+len(matchKeys) < watchSetDeleteBatchSize {
+				done = true
+				return setsBucket.Delete([]byte(name))
+			}
+			scripts -= uint64(len(setScriptKeys))
+			return setsBucket.Put([]byte(name), serializeWatchSet(id,
+				scripts))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WatchSets returns all of the watch sets sorted by name.
+//
+// This function is safe for concurrent access.
+func (idx *WatchSetIndex) WatchSets() ([]*WatchSet, error) {
+	var sets []*WatchSet
+	err := idx.db.View(func(dbTx database.Tx) error {
+		setsBucket := dbTx.Metadata().Bucket(watchSetIndexKey).
+			Bucket(watchSetSetsBucketName)
+		return setsBucket.ForEach(func(k, v []byte) error {
+			_, scripts, err := dbFetchWatchSet(setsBucket, string(k))
+			if err != nil {
+				return err
+			}
+			sets = append(sets, &WatchSet{
+				Name:    string(k),
+				Scripts: scripts,
+			})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sets, nil
+}
+
+// MatchesScripts returns whether any of the passed scripts is in the named watch
+// set.
+//
+// This function is safe for concurrent access.
+func (idx *WatchSetIndex) MatchesScripts(name string, pkScripts [][]byte) (bool, error) {
+	var matched bool
+	err := idx.db.View(func(dbTx database.Tx) error {
+		parent := dbTx.Metadata().Bucket(watchSetIndexKey)
+		id, _, err := dbFetchWatchSet(parent.Bucket(watchSetSetsBucketName),
+			name)
+		if err != nil {
+			return err
+		}
+
+		setScriptsBucket := parent.Bucket(watchSetSetScriptsBucketName)
+		for _, pkScript := range pkScripts {
+			scriptHash := watchSetScriptHash(pkScript)
+			if setScriptsBucket.Get(watchSetSetScriptKey(id, scriptHash[:])) != nil {
+				matched = true
+				return nil
+			}
+		}
+		return nil
+	})
+	return matched, err
+}
+
+// WatchSetMatches returns the transactions of the main chain blocks from
+// startHeight to endHeight inclusive which match the named watch set, sorted by
+// height and position in the block.  The matches of a block are never split,
+// so fewer blocks are included when the matches would exceed maxMatches,
+// unless the first block alone exceeds it.  It also returns whether matches of
+// later blocks in the range were left out.
+//
+// This function is safe for concurrent access.
+func (idx *WatchSetIndex) WatchSetMatches(name string, startHeight, endHeight int32,
+	maxMatches int) ([]*WatchSetMatch, bool, error) {
+
+	var (
+		matches []*WatchSetMatch
+		more    bool
+	)
+	err := idx.db.View(func(dbTx database.Tx) error {
+		parent := dbTx.Metadata().Bucket(watchSetIndexKey)
+		id, _, err := dbFetchWatchSet(parent.Bucket(watchSetSetsBucketName),
+			name)
+		if err != nil {
+			return err
+		}
+
+		var blockMatches []*WatchSetMatch
+		flush := func() bool {
+			if len(matches) > 0 &&
+				len(matches)+len(blockMatches) > maxMatches {
+
+				return false
+			}
+			matches = append(matches, blockMatches...)
+			blockMatches = nil
+			return true
+		}
+
+		prefix := watchSetMatchKey(id, 0, 0)[:4]
+		cursor := parent.Bucket(watchSetMatchesBucketName).Cursor()
+		for ok := cursor.Seek(watchSetMatchKey(id, startHeight, 0)); ok; ok = cursor.Next() {
+			key := cursor.Key()
+			if !bytes.HasPrefix(key, prefix) {
+				break
+			}
+			height := int32(binary.BigEndian.Uint32(key[4:8]))
+			if height > endHeight {
+				break
+			}
+			if len(blockMatches) > 0 && blockMatches[0].Height != height {
+				if !flush() {
+					more = true
+					return nil
+				}
+			}
+
+			match := &WatchSetMatch{
+				Height:  height,
+				TxIndex: binary.BigEndian.Uint32(key[8:12]),
+			}
+			copy(match.TxHash[:], cursor.Value())
+			blockMatches = append(blockMatches, match)
+		}
+		more = !flush()
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return matches, more, nil
+}
+
+// NewWatchSetIndex returns a new instance of an indexer that is used to record
+// the transactions in the main chain which pay to or spend from the scripts of
+// persistent watch sets.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewWatchSetIndex(db database.DB) *WatchSetIndex {
+	return &WatchSetIndex{db: db}
+}
+
+// DropWatchSetIndex drops the watch set index from the provided database if it
+// exists.
+func DropWatchSetIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, watchSetIndexKey, watchSetIndexName, interrupt)
+}
+
+// ResetWatchSetIndex removes the recorded transactions from the watch set index
+// in the provided database if it exists and marks it uninitialized, so it is
+// rebuilt from the blockchain on the next start.  Unlike dropping the index,
+// the watch sets and their scripts are kept, which means the rebuilt index also
+// records the transactions of the blocks connected before they were added.
+func ResetWatchSetIndex(db database.DB, interrupt <-chan struct{}) error {
+	var exists bool
+	err := db.View(func(dbTx database.Tx) error {
+		indexesBucket := dbTx.Metadata().Bucket(indexTipsBucketName)
+		exists = indexesBucket != nil &&
+			indexesBucket.Get(watchSetIndexKey) != nil
+		return nil
+	})
+	if err != nil || !exists {
+		return err
+	}
+
+	log.Infof("Resetting the %s", watchSetIndexName)
+	for done := false; !done; {
+		if interruptRequested(interrupt) {
+			return errInterruptRequested
+		}
+
+		err := db.Update(func(dbTx database.Tx) error {
+			matchesBucket := dbTx.Metadata().Bucket(watchSetIndexKey).
+				Bucket(watchSetMatchesBucketName)
+
+			var keys [][]byte
+			cursor := matchesBucket.Cursor()
+			for ok := cursor.First(); ok; ok = cursor.Next() {
+				if len(keys) == watchSetDeleteBatchSize {
+					break
+				}
+				keys = append(keys, append([]byte(nil), cursor.Key()...))
+			}
+			for _, key := range keys {
+				if err := matchesBucket.Delete(key); err != nil {
+					return err
+				}
+			}
+
+			if len(keys) < watchSetDeleteBatchSize {
+				done = true
+				return dbPutIndexerTip(dbTx, watchSetIndexKey,
+					&chainhash.Hash{}, -1)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package indexers
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/database"
+	_ "github.com/gcash/bchd/database/ffldb"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// TestWatchSetIndex ensures the watch set index records the transactions which
+// pay to or spend from the scripts of each watch set, removes them when blocks
+// are disconnected and keeps the scripts of the sets apart.
+func TestWatchSetIndex(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	db, err := database.Create("ffldb", filepath.Join(t.TempDir(), "db"),
+		params.Net)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	idx := NewWatchSetIndex(db)
+	if err := db.Update(idx.Create); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	scriptA := []byte{0x51, 0x01, 0xaa}
+	scriptB := []byte{0x51, 0x01, 0xbb}
+	scriptC := []byte{0x51, 0x01, 0xcc}
+
+	if _, err := idx.AddScripts("", [][]byte{scriptA}); err != ErrInvalidWatchSetName {
+		t.Fatalf("unexpected error for an empty name: %v", err)
+	}
+	count, err := idx.AddScripts("exchange", [][]byte{scriptA, scriptB, scriptA})
+	if err != nil {
+		t.Fatalf("AddScripts: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("got %d scripts, want 2", count)
+	}
+	if _, err := idx.AddScripts("other", [][]byte{scriptC}); err != nil {
+		t.Fatalf("AddScripts: %v", err)
+	}
+
+	newTx := func(spends int, outputs ...[]byte) *wire.MsgTx {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		for i := 0; i < spends; i++ {
+			tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+				uint32(i)), nil))
+		}
+		for _, pkScript := range outputs {
+			tx.AddTxOut(wire.NewTxOut(1000, pkScript, wire.TokenData{}))
+		}
+		return tx
+	}
+	newBlock := func(height int32, txns ...*wire.MsgTx) *bchutil.Block {
+		msgBlock := wire.NewMsgBlock(&wire.BlockHeader{
+			Timestamp: time.Unix(int64(height), 0),
+		})
+		for _, tx := range txns {
+			msgBlock.AddTransaction(tx)
+		}
+		block := bchutil.NewBlock(msgBlock)
+		block.SetHeight(height)
+		return block
+	}
+
+	// The first block pays A in its coinbase and C in its second
+	// transaction.  The second block spends A and pays B in separate
+	// transactions, and has a transaction matching no set.
+	blocks := []*bchutil.Block{
+		newBlock(1, newTx(0, scriptA), newTx(0, scriptC)),
+		newBlock(2, newTx(0), newTx(1, []byte{0x51}), newTx(0, scriptB),
+			newTx(0, []byte{0x52})),
+	}
+	stxos := [][]blockchain.SpentTxOut{
+		nil,
+		{{Amount: 1000, PkScript: scriptA, Height: 1}},
+	}
+	for i, block := range blocks {
+		err := db.Update(func(dbTx database.Tx) error {
+			return idx.ConnectBlock(dbTx, block, stxos[i])
+		})
+		if err != nil {
+			t.Fatalf("ConnectBlock #%d: %v", i, err)
+		}
+	}
+
+	checkMatches := func(name string, maxMatches int, wantMore bool, want ...*wire.MsgTx) {
+		t.Helper()
+		matches, more, err := idx.WatchSetMatches(name, 0, 10, maxMatches)
+		if err != nil {
+			t.Fatalf("WatchSetMatches: %v", err)
+		}
+		if len(matches) != len(want) || more != wantMore {
+			t.Fatalf("%s: got %d matches (more %v), want %d (more %v)",
+				name, len(matches), more, len(want), wantMore)
+		}
+		for i, match := range matches {
+			if match.TxHash != want[i].TxHash() {
+				t.Errorf("%s: match #%d: got %v, want %v", name, i,
+					match.TxHash, want[i].TxHash())
+			}
+		}
+	}
+	txns := func(block int, idxs ...int) []*wire.MsgTx {
+		var result []*wire.MsgTx
+		for _, i := range idxs {
+			result = append(result, blocks[block].MsgBlock().Transactions[i])
+		}
+		return result
+	}
+	checkMatches("exchange", 10, false, append(txns(0, 0), txns(1, 1, 2)...)...)
+	checkMatches("exchange", 1, true, txns(0, 0)...)
+	checkMatches("exchange", 2, true, txns(0, 0)...)
+	checkMatches("other", 10, false, txns(0, 1)...)
+
+	matched, err := idx.MatchesScripts("other", [][]byte{scriptA, scriptC})
+	if err != nil || !matched {
+		t.Fatalf("MatchesScripts: got %v, %v, want match", matched, err)
+	}
+	matched, err = idx.MatchesScripts("other", [][]byte{scriptA})
+	if err != nil || matched {
+		t.Fatalf("MatchesScripts: got %v, %v, want no match", matched, err)
+	}
+	if _, err := idx.MatchesScripts("missing", nil); err != ErrWatchSetNotFound {
+		t.Fatalf("unexpected error for a missing set: %v", err)
+	}
+
+	// Disconnecting the second block removes its matches.
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, blocks[1], stxos[1])
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: %v", err)
+	}
+	checkMatches("exchange", 10, false, txns(0, 0)...)
+
+	// Removed scripts are no longer matched and deleted sets are gone.
+	count, err = idx.RemoveScripts("exchange", [][]byte{scriptB, scriptC})
+	if err != nil || count != 1 {
+		t.Fatalf("RemoveScripts: got %d, %v, want 1", count, err)
+	}
+	if err := idx.DeleteWatchSet("other"); err != nil {
+		t.Fatalf("DeleteWatchSet: %v", err)
+	}
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.ConnectBlock(dbTx, blocks[1], stxos[1])
+	})
+	if err != nil {
+		t.Fatalf("ConnectBlock: %v", err)
+	}
+	checkMatches("exchange", 10, false, append(txns(0, 0), txns(1, 1)...)...)
+
+	sets, err := idx.WatchSets()
+	if err != nil {
+		t.Fatalf("WatchSets: %v", err)
+	}
+	if len(sets) != 1 || sets[0].Name != "exchange" || sets[0].Scripts != 1 {
+		t.Fatalf("unexpected watch sets: %+v", sets)
+	}
+
+	// A new set with the name of a deleted one does not inherit its
+	// matches.
+	if _, err := idx.AddScripts("other", [][]byte{scriptC}); err != nil {
+		t.Fatalf("AddScripts: %v", err)
+	}
+	checkMatches("other", 10, false)
+
+	// Resetting the index removes the recorded transactions but keeps the
+	// watch sets.
+	err = db.Update(func(dbTx database.Tx) error {
+		if _, err := dbTx.Metadata().CreateBucket(indexTipsBucketName); err != nil {
+			return err
+		}
+		return dbPutIndexerTip(dbTx, watchSetIndexKey, blocks[1].Hash(), 2)
+	})
+	if err != nil {
+		t.Fatalf("dbPutIndexerTip: %v", err)
+	}
+	if err := ResetWatchSetIndex(db, nil); err != nil {
+		t.Fatalf("ResetWatchSetIndex: %v", err)
+	}
+	checkMatches("exchange", 10, false)
+	if sets, err := idx.WatchSets(); err != nil || len(sets) != 2 {
+		t.Fatalf("unexpected watch sets after reset: %v, %v", sets, err)
+	}
+	err = db.View(func(dbTx database.Tx) error {
+		_, height, err := dbFetchIndexerTip(dbTx, watchSetIndexKey)
+		if err == nil && height != -1 {
+			t.Errorf("unexpected tip height after reset: %d", height)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("dbFetchIndexerTip: %v", err)
+	}
+}
@@ -6,11 +6,62 @@ package blockchain
 
 import (
 	"math"
+	"runtime"
+	"sync"
 
 	"github.com/gcash/bchd/chaincfg/chainhash"
 	"github.com/gcash/bchutil"
 )
 
+const (
+	// parallelTxHashThreshold is the minimum number of transactions whose
+	// hashes are computed by multiple goroutines.
+	parallelTxHashThreshold = 128
+
+	// parallelMerkleThreshold is the minimum number of nodes at one level
+	// of a merkle tree which are computed by multiple goroutines.  Smaller
+	// levels are hashed faster than the goroutines are started.
+	parallelMerkleThreshold = 1024
+)
+
+// parallelize calls fn with consecutive ranges [start, end) which together
+// cover [0, n).  The work is split among a goroutine per CPU when n is at least
+// the passed threshold, otherwise fn is called once for the whole range.
+func parallelize(n, threshold int, fn func(start, end int)) {
+	numWorkers := runtime.NumCPU()
+	if n < threshold || numWorkers < 2 {
+		fn(0, n)
+		return
+	}
+
+	chunkSize := (n + numWorkers - 1) / numWorkers
+	var wg sync.WaitGroup
+	for start := 0; start < n; start += chunkSize {
+		end := start + chunkSize
+		if end > n {
+			end = n
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			fn(start, end)
+		}(start, end)
+	}
+	wg.Wait()
+}
+
+// cacheTxHashes computes the hashes of the passed transactions, which caches
+// them in the transactions.  The hashes of large numbers of transactions are
+// computed in parallel.
+func cacheTxHashes(transactions []*bchutil.Tx) {
+	parallelize(len(transactions), parallelTxHashThreshold,
+		func(start, end int) {
+			for _, tx := range transactions[start:end] {
+				tx.Hash()
+			}
+		})
+}
+
 // nextPowerOfTwo returns the next highest power of two from a given number if
 // it is not already a power of two.  This is a helper function used during the
 // calculation of a merkle tree.
@@ -29,13 +80,36 @@ func nextPowerOfTwo(n int) int {
 // nodes, and returns the hash of their concatenation.  This is a helper
 // function used to aid in the generation of a merkle tree.
 func HashMerkleBranches(left *chainhash.Hash, right *chainhash.Hash) *chainhash.Hash {
+	var newHash chainhash.Hash
+	hashMerkleBranches(&newHash, left, right)
+	return &newHash
+}
+
+// hashMerkleBranches stores the hash of the concatenation of the left and right
+// tree nodes in dst.
+func hashMerkleBranches(dst, left, right *chainhash.Hash) {
 	// Concatenate the left and right nodes.
 	var hash [chainhash.HashSize * 2]byte
 	copy(hash[:chainhash.HashSize], left[:])
 	copy(hash[chainhash.HashSize:], right[:])
 
-	newHash := chainhash.DoubleHashH(hash[:])
-	return &newHash
+	*dst = chainhash.DoubleHashH(hash[:])
+}
+
+// hashMerkleLevel stores the parents of the passed children of a merkle tree
+// level in parents.  A last child without a sibling is hashed with itself.  The
+// pairs of children are hashed in batches, and the batches of large levels are
+// hashed in parallel.
+func hashMerkleLevel(parents, children []chainhash.Hash) {
+	numPairs := len(children) / 2
+	parallelize(numPairs, parallelMerkleThreshold, func(first, last int) {
+		chainhash.DoubleHashPairs(parents[first:last],
+			children[first*2:last*2])
+	})
+	if len(children)%2 == 1 {
+		last := &children[len(children)-1]
+		hashMerkleBranches(&parents[numPairs], last, last)
+	}
 }
 
 // BuildMerkleTreeStore creates a merkle tree from a slice of transactions,
@@ -79,33 +153,180 @@ func BuildMerkleTreeStore(transactions []*bchutil.Tx) []*chainhash.Hash {
 	merkles := make([]*chainhash.Hash, arraySize)
 
 	// Create the base transaction hashes and populate the array with them.
+	// The hashes are copied into a single array along with the nodes of
+	// every level since the children of a level are hashed in batches.
+	// Entries with no children remain nil.
+	cacheTxHashes(transactions)
+	numNodes := 0
+	for width := len(transactions); width > 1; width = (width + 1) / 2 {
+		numNodes += width
+	}
+	nodes := make([]chainhash.Hash, numNodes+1)
+	children := nodes[:len(transactions)]
 	for i, tx := range transactions {
-		merkles[i] = tx.Hash()
+		children[i] = *tx.Hash()
+		merkles[i] = &children[i]
 	}
 
-	// Start the array offset after the last transaction and adjusted to the
-	// next power of two.
-	offset := nextPoT
-	for i := 0; i < arraySize-1; i += 2 {
-		switch {
-		// When there is no left child node, the parent is nil too.
-		case merkles[i] == nil:
-			merkles[offset] = nil
-
-		// When there is no right child, the parent is generated by
-		// hashing the concatenation of the left child with itself.
-		case merkles[i+1] == nil:
-			newHash := HashMerkleBranches(merkles[i], merkles[i])
-			merkles[offset] = newHash
-
-		// The normal case sets the parent node to the double sha256
-		// of the concatentation of the left and right children.
-		default:
-			newHash := HashMerkleBranches(merkles[i], merkles[i+1])
-			merkles[offset] = newHash
+	// Compute the parent nodes one level at a time, starting with the
+	// parents of the transactions.  When there is no right child, the
+	// parent is the double sha256 of the concatenation of the left child
+	// with itself.
+	offset := len(children)
+	for start, width := 0, nextPoT; width > 1; start, width = start+width, width/2 {
+		numParents := (len(children) + 1) / 2
+		parents := nodes[offset : offset+numParents]
+		hashMerkleLevel(parents, children)
+		for i := range parents {
+			merkles[start+width+i] = &parents[i]
 		}
-		offset++
+		children = parents
+		offset += numParents
 	}
 
 	return merkles
 }
+
+// MerkleTree is a merkle tree of transaction hashes which is updated in place
+// as transactions are replaced, inserted, or removed.  Only the nodes which
+// depend on the changed hashes are recomputed, which makes updating the merkle
+// root of a block template after changing its coinbase or adding a few
+// transactions to its end much cheaper than building the entire tree again.
+//
+// The tree yields the same merkle root as BuildMerkleTreeStore.
+//
+// This type is NOT safe for concurrent access.
+type MerkleTree struct {
+	// levels houses the nodes of each level of the tree, starting with the
+	// transaction hashes and ending with the level of the root.  A level
+	// with an odd number of nodes has its last node hashed with itself.
+	levels [][]chainhash.Hash
+}
+
+// NewMerkleTree returns a merkle tree of the passed transaction hashes.
+func NewMerkleTree(hashes []chainhash.Hash) *MerkleTree {
+	leaves := make([]chainhash.Hash, len(hashes))
+	copy(leaves, hashes)
+	t := &MerkleTree{levels: [][]chainhash.Hash{leaves}}
+	t.rebuild(0)
+	return t
+}
+
+// NewMerkleTreeFromTransactions returns a merkle tree of the hashes of the
+// passed transactions.
+func NewMerkleTreeFromTransactions(transactions []*bchutil.Tx) *MerkleTree {
+	cacheTxHashes(transactions)
+	leaves := make([]chainhash.Hash, len(transactions))
+	for i, tx := range transactions {
+		leaves[i] = *tx.Hash()
+	}
+	t := &MerkleTree{levels: [][]chainhash.Hash{leaves}}
+	t.rebuild(0)
+	return t
+}
+
+// Len returns the number of transaction hashes in the tree.
+func (t *MerkleTree) Len() int {
+	return len(t.levels[0])
+}
+
+// Hash returns the transaction hash at the passed index.
+func (t *MerkleTree) Hash(index int) chainhash.Hash {
+	return t.levels[0][index]
+}
+
+// Root returns the merkle root of the tree.  The root of an empty tree is the
+// zero hash.
+func (t *MerkleTree) Root() chainhash.Hash {
+	top := t.levels[len(t.levels)-1]
+	if len(top) == 0 {
+		return chainhash.Hash{}
+	}
+	return top[0]
+}
+
+// Update replaces the transaction hash at the passed index, such as the one of
+// a coinbase after its extra nonce changed.  Only the ancestors of the hash are
+// recomputed.
+func (t *MerkleTree) Update(index int, hash *chainhash.Hash) {
+	t.levels[0][index] = *hash
+	for level := 1; level < len(t.levels); level++ {
+		index /= 2
+		t.hashNode(level, index)
+	}
+}
+
+// Append adds the passed transaction hashes to the end of the tree.  Only the
+// nodes to the right of the last existing hash are recomputed.
+func (t *MerkleTree) Append(hashes ...chainhash.Hash) {
+	t.Insert(t.Len(), hashes...)
+}
+
+// Insert inserts the passed transaction hashes before the hash at the passed
+// index.  The nodes which depend on the hashes at or after the index are
+// recomputed.
+func (t *MerkleTree) Insert(index int, hashes ...chainhash.Hash) {
+	if len(hashes) == 0 {
+		return
+	}
+	leaves := append(t.levels[0], hashes...)
+	copy(leaves[index+len(hashes):], leaves[index:])
+	copy(leaves[index:], hashes)
+	t.levels[0] = leaves
+	t.rebuild(index)
+}
+
+// Remove removes the transaction hashes in the range [start, end).  The nodes
+// which depend on the hashes after the range are recomputed.
+func (t *MerkleTree) Remove(start, end int) {
+	if start == end {
+		return
+	}
+	leaves := t.levels[0]
+	t.levels[0] = append(leaves[:start], leaves[end:]...)
+	t.rebuild(start)
+}
+
+// hashNode recomputes the node at the passed index of the passed level from its
+// children.
+func (t *MerkleTree) hashNode(level, index int) {
+	children := t.levels[level-1]
+	left := &children[index*2]
+	right := left
+	if index*2+1 < len(children) {
+		right = &children[index*2+1]
+	}
+	hashMerkleBranches(&t.levels[level][index], left, right)
+}
+
+// rebuild recomputes the nodes which depend on the transaction hashes at or
+// after the passed index and resizes the levels above the transaction hashes to
+// the number of hashes.
+func (t *MerkleTree) rebuild(index int) {
+	level := 1
+	for width := len(t.levels[0]); width > 1; level++ {
+		width = (width + 1) / 2
+		index /= 2
+		if level == len(t.levels) {
+			t.levels = append(t.levels, nil)
+		}
+
+		// Grow or shrink the level to the number of parents of the
+		// level below, keeping the nodes left of the index which are
+		// still valid.
+		nodes := t.levels[level]
+		if cap(nodes) < width {
+			grown := make([]chainhash.Hash, width, width*2)
+			copy(grown, nodes[:index])
+			nodes = grown
+		}
+		t.levels[level] = nodes[:width]
+
+		hashMerkleLevel(t.levels[level][index:],
+			t.levels[level-1][index*2:])
+	}
+	for i := level; i < len(t.levels); i++ {
+		t.levels[i] = nil
+	}
+	t.levels = t.levels[:level]
+}
@@ -5,8 +5,12 @@
 package blockchain
 
 import (
-	"github.com/gcash/bchutil"
+	"math/rand"
 	"testing"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
 )
 
 // TestMerkle tests the BuildMerkleTreeStore API.
@@ -20,3 +24,186 @@ func TestMerkle(t *testing.T) {
 			"got %v, want %v", calculatedMerkleRoot, wantMerkle)
 	}
 }
+
+// TestMerkleKnownRoots ensures the merkle roots of transactions whose only
+// difference is their lock time match known answers, including the odd counts
+// which duplicate the last node of one or more levels.
+func TestMerkleKnownRoots(t *testing.T) {
+	tests := []struct {
+		numTxns int
+		root    string
+	}{
+		{1, "d21633ba23f70118185227be58a63527675641ad37967e2aa461559f577aec43"},
+		{2, "d509a89780666da0dbfd328d0d04b2197b1bd7c35122f7513ac072eaeaf54f27"},
+		{3, "967096e98e166e9f3eacbc287fdd6e177dc9de06795a47051b882abcfa35ee92"},
+		{5, "a0dc7de994199bbd4201a8ab0d8ba49b674a9648d880c53d698a9a48b148b681"},
+		{6, "d5e39a00da81fc7f5e213e79ec1ee9895d084f1dc2202a95c0e243b562a5894b"},
+		{9, "a72ecd99050bbc4c0a47591d9af4e304d42f045ca0d88754a26e008f7d4fdb8b"},
+		{17, "e86587dd786b27c79a9cd84ef44620e5debed3c79fd875eee1b04287f21399f8"},
+		{19, "79cfca46a82f46a4628f357a12ad71975ee1be879e328de58f6213e3a1cfa758"},
+	}
+	for _, test := range tests {
+		txns := make([]*bchutil.Tx, 0, test.numTxns)
+		for i := 0; i < test.numTxns; i++ {
+			msgTx := wire.NewMsgTx(wire.TxVersion)
+			msgTx.LockTime = uint32(i)
+			txns = append(txns, bchutil.NewTx(msgTx))
+		}
+		merkles := BuildMerkleTreeStore(txns)
+		if root := merkles[len(merkles)-1].String(); root != test.root {
+			t.Errorf("%d transactions: got merkle root %v, want %v",
+				test.numTxns, root, test.root)
+		}
+	}
+}
+
+// TestMerkleParallel ensures merkle trees which are large enough to be built in
+// batches and in parallel match the trees built one node at a time.
+func TestMerkleParallel(t *testing.T) {
+	for _, numTxns := range []int{1, 2, 3, 9, 17, 33,
+		parallelMerkleThreshold*2 + 1, parallelMerkleThreshold * 4} {
+
+		txns := make([]*bchutil.Tx, 0, numTxns)
+		for i := 0; i < numTxns; i++ {
+			msgTx := wire.NewMsgTx(wire.TxVersion)
+			msgTx.LockTime = uint32(i)
+			txns = append(txns, bchutil.NewTx(msgTx))
+		}
+		merkles := BuildMerkleTreeStore(txns)
+
+		// Build the tree by hashing the pairs of every level in order.
+		level := make([]*chainhash.Hash, 0, numTxns)
+		for _, tx := range txns {
+			level = append(level, tx.Hash())
+		}
+		nodes := append([]*chainhash.Hash(nil), level...)
+		for width := nextPowerOfTwo(numTxns); width > 1; width /= 2 {
+			var next []*chainhash.Hash
+			for i := 0; i < len(level); i += 2 {
+				right := level[i]
+				if i+1 < len(level) {
+					right = level[i+1]
+				}
+				next = append(next, HashMerkleBranches(level[i], right))
+			}
+			for i := len(level); i < width; i++ {
+				nodes = append(nodes, nil)
+			}
+			nodes = append(nodes, next...)
+			level = next
+		}
+		for i := len(nodes); i < len(merkles); i++ {
+			nodes = append(nodes, nil)
+		}
+		for i, node := range merkles {
+			if (node == nil) != (nodes[i] == nil) ||
+				node != nil && *node != *nodes[i] {
+
+				t.Fatalf("%d transactions: node %d: got %v, want %v",
+					numTxns, i, node, nodes[i])
+			}
+		}
+
+		if root := merkles[len(merkles)-1]; !root.IsEqual(level[0]) {
+			t.Fatalf("%d transactions: got merkle root %v, want %v",
+				numTxns, root, level[0])
+		}
+		if len(merkles) != nextPowerOfTwo(numTxns)*2-1 {
+			t.Fatalf("%d transactions: got %d nodes", numTxns,
+				len(merkles))
+		}
+	}
+}
+
+// TestMerkleTree ensures the merkle root of a merkle tree matches the one of a
+// tree built from scratch as hashes are updated, inserted, and removed.
+func TestMerkleTree(t *testing.T) {
+	// wantRoot returns the merkle root built from scratch by hashing the
+	// pairs of every level in order.
+	wantRoot := func(hashes []chainhash.Hash) chainhash.Hash {
+		if len(hashes) == 0 {
+			return chainhash.Hash{}
+		}
+		level := append([]chainhash.Hash(nil), hashes...)
+		for len(level) > 1 {
+			var next []chainhash.Hash
+			for i := 0; i < len(level); i += 2 {
+				right := &level[i]
+				if i+1 < len(level) {
+					right = &level[i+1]
+				}
+				next = append(next, *HashMerkleBranches(&level[i], right))
+			}
+			level = next
+		}
+		return level[0]
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	randHashes := func(n int) []chainhash.Hash {
+		hashes := make([]chainhash.Hash, n)
+		for i := range hashes {
+			rng.Read(hashes[i][:])
+		}
+		return hashes
+	}
+
+	hashes := randHashes(5)
+	tree := NewMerkleTree(hashes)
+	check := func(op string) {
+		t.Helper()
+		if tree.Len() != len(hashes) {
+			t.Fatalf("%s: got %d hashes, want %d", op, tree.Len(),
+				len(hashes))
+		}
+		if got, want := tree.Root(), wantRoot(hashes); got != want {
+			t.Fatalf("%s with %d hashes: got merkle root %v, want %v",
+				op, len(hashes), got, want)
+		}
+	}
+	check("new")
+
+	for i := 0; i < 300; i++ {
+		switch op := rng.Intn(4); {
+		case op == 0 && len(hashes) > 0:
+			index := rng.Intn(len(hashes))
+			hash := randHashes(1)[0]
+			hashes[index] = hash
+			tree.Update(index, &hash)
+			check("update")
+
+		case op == 1:
+			added := randHashes(rng.Intn(40))
+			hashes = append(hashes, added...)
+			tree.Append(added...)
+			check("append")
+
+		case op == 2:
+			index := rng.Intn(len(hashes) + 1)
+			added := randHashes(rng.Intn(5) + 1)
+			hashes = append(hashes[:index], append(added,
+				hashes[index:]...)...)
+			tree.Insert(index, added...)
+			check("insert")
+
+		case op == 3 && len(hashes) > 0:
+			start := rng.Intn(len(hashes))
+			end := start + rng.Intn(len(hashes)-start+1)
+			if rng.Intn(10) == 0 {
+				start, end = 0, len(hashes)
+			}
+			hashes = append(hashes[:start], hashes[end:]...)
+			tree.Remove(start, end)
+			check("remove")
+		}
+	}
+
+	// A tree built from the transactions of a block has the merkle root of
+	// the block.
+	block := bchutil.NewBlock(&Block100000)
+	tree = NewMerkleTreeFromTransactions(block.Transactions())
+	if root := tree.Root(); root != Block100000.Header.MerkleRoot {
+		t.Fatalf("got merkle root %v, want %v", root,
+			Block100000.Header.MerkleRoot)
+	}
+}
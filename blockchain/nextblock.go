@@ -0,0 +1,122 @@
+package blockchain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/wire"
+)
+
+// NextBlockParams houses the consensus parameters which are enforced on the
+// block building on a given block.  External miners and pool operators can
+// use them to check their own implementations of the rules.
+type NextBlockParams struct {
+	// PrevHash is the hash of the block the next block builds on.
+	PrevHash chainhash.Hash
+
+	// Height is the height of the next block.
+	Height int32
+
+	// Timestamp is the timestamp of the next block the difficulty was
+	// calculated for.
+	Timestamp time.Time
+
+	// Bits is the difficulty the next block must meet in compact form.
+	Bits uint32
+
+	// MedianTimePast is the median time of the blocks prior to the next
+	// block.  The timestamp of the next block must be after it.
+	MedianTimePast time.Time
+
+	// MaxBlockSize is the maximum serialized size of the next block.  It
+	// is zero when it is unknown, which is the case when the adaptive
+	// block size limit algorithm is active and the block the next block
+	// builds on is not in the main chain, since the limit then depends on
+	// the sizes of blocks which are not available.
+	MaxBlockSize uint64
+}
+
+// CalcNextBlockParams returns the consensus parameters of the block building on
+// the block with the passed hash, or the tip of the main chain when it is nil,
+// extended by the passed headers.  The headers do not need to be known and are
+// not validated beyond connecting to each other, which allows callers to
+// calculate the parameters for header chains they are working on.  The
+// difficulty is calculated for the passed timestamp, or the current adjusted
+// time when it is zero, which is bumped to one second after the median time
+// past when it is not after it.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) CalcNextBlockParams(prevHash *chainhash.Hash, headers []wire.BlockHeader, timestamp time.Time) (*NextBlockParams, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	prevNode := b.bestChain.Tip()
+	if prevHash != nil {
+		prevNode = b.index.LookupNode(prevHash)
+		if prevNode == nil {
+			return nil, fmt.Errorf("block %s is not known", prevHash)
+		}
+	}
+
+	// Link temporary nodes for the passed headers onto the known block.
+	tip := prevNode
+	for i := range headers {
+		header := &headers[i]
+		if header.PrevBlock != tip.hash {
+			return nil, fmt.Errorf("header %d does not connect to the "+
+				"previous block %s", i, tip.hash)
+		}
+		tip = newBlockNode(header, tip)
+	}
+
+	medianTime := tip.CalcPastMedianTime()
+	if timestamp.IsZero() {
+		timestamp = b.timeSource.AdjustedTime()
+	}
+	if !timestamp.After(medianTime) {
+		timestamp = medianTime.Add(time.Second)
+	}
+	bits, err := b.calcNextRequiredDifficulty(tip, timestamp,
+		b.SelectDifficultyAdjustmentAlgorithm(tip))
+	if err != nil {
+		return nil, err
+	}
+
+	params := &NextBlockParams{
+		PrevHash:       tip.hash,
+		Height:         tip.height + 1,
+		Timestamp:      timestamp,
+		Bits:           bits,
+		MedianTimePast: medianTime,
+	}
+
+	// The adaptive block size limit state is only known for the blocks in
+	// the main chain.  The state of the tip is kept in memory while the
+	// states of the other blocks are loaded from the database.
+	uahfActive := params.Height > b.chainParams.UahfForkHeight
+	ablaActive := params.Height > b.chainParams.ABLAForkHeight
+	switch {
+	case !ablaActive:
+		params.MaxBlockSize = b.MaxBlockSize(uahfActive, false)
+
+	case tip == b.bestChain.Tip():
+		params.MaxBlockSize = b.ablaState.getBlockSizeLimit()
+
+	case b.bestChain.Contains(tip):
+		err := b.db.View(func(dbTx database.Tx) error {
+			ablaState, err := dbFetchAblaStateByHeight(dbTx, tip.height)
+			if err != nil {
+				return err
+			}
+			params.MaxBlockSize = ablaState.getBlockSizeLimit()
+			return nil
+		})
+		if err != nil && !isNotInMainChainErr(err) {
+			return nil, err
+		}
+	}
+
+	return params, nil
+}
@@ -0,0 +1,74 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/wire"
+)
+
+// TestCalcNextBlockParams ensures the parameters of the next block are
+// calculated for the tip of the main chain as well as for header chains
+// extending it.
+func TestCalcNextBlockParams(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	chain, teardownFunc, err := chainSetup("nextblockparams", &params)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	genesisTime := params.GenesisBlock.Header.Timestamp
+	nextParams, err := chain.CalcNextBlockParams(nil, nil, time.Time{})
+	if err != nil {
+		t.Fatalf("CalcNextBlockParams: unexpected error: %v", err)
+	}
+	if nextParams.PrevHash != *params.GenesisHash || nextParams.Height != 1 ||
+		nextParams.Bits != params.PowLimitBits ||
+		!nextParams.MedianTimePast.Equal(genesisTime) ||
+		!nextParams.Timestamp.After(genesisTime) {
+
+		t.Fatalf("CalcNextBlockParams: unexpected params for tip: %+v",
+			nextParams)
+	}
+	if want := chain.MaxBlockSize(true, false); nextParams.MaxBlockSize != want {
+		t.Fatalf("CalcNextBlockParams: got max block size %d, want %d",
+			nextParams.MaxBlockSize, want)
+	}
+
+	// The difficulty is not adjusted on the regression test network, so
+	// the next block inherits the bits of the last header.
+	header := wire.BlockHeader{
+		Version:   4,
+		PrevBlock: *params.GenesisHash,
+		Timestamp: genesisTime.Add(time.Minute),
+		Bits:      0x207ffffe,
+	}
+	timestamp := genesisTime.Add(time.Hour)
+	nextParams, err = chain.CalcNextBlockParams(params.GenesisHash,
+		[]wire.BlockHeader{header}, timestamp)
+	if err != nil {
+		t.Fatalf("CalcNextBlockParams: unexpected error: %v", err)
+	}
+	if nextParams.PrevHash != header.BlockHash() || nextParams.Height != 2 ||
+		nextParams.Bits != header.Bits ||
+		!nextParams.Timestamp.Equal(timestamp) {
+
+		t.Fatalf("CalcNextBlockParams: unexpected params for header "+
+			"chain: %+v", nextParams)
+	}
+
+	header.PrevBlock = chainhash.Hash{0x01}
+	_, err = chain.CalcNextBlockParams(nil, []wire.BlockHeader{header},
+		time.Time{})
+	if err == nil {
+		t.Fatal("CalcNextBlockParams: no error for unconnected header")
+	}
+	_, err = chain.CalcNextBlockParams(&chainhash.Hash{0x01}, nil,
+		time.Time{})
+	if err == nil {
+		t.Fatal("CalcNextBlockParams: no error for unknown block")
+	}
+}
@@ -6,6 +6,8 @@ package blockchain
 
 import (
 	"fmt"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
 )
 
 // NotificationType represents the type of a notification message.
@@ -29,6 +31,11 @@ const (
 	// NTBlockDisconnected indicates the associated block was disconnected
 	// from the main chain.
 	NTBlockDisconnected
+
+	// NTChainReorganized indicates the main chain was reorganized.  It is
+	// sent after the notifications of the individual blocks disconnected
+	// and connected by the reorganization.
+	NTChainReorganized
 )
 
 // notificationTypeStrings is a map of notification types back to their constant
@@ -37,6 +44,7 @@ var notificationTypeStrings = map[NotificationType]string{
 	NTBlockAccepted:     "NTBlockAccepted",
 	NTBlockConnected:    "NTBlockConnected",
 	NTBlockDisconnected: "NTBlockDisconnected",
+	NTChainReorganized:  "NTChainReorganized",
 }
 
 // String returns the NotificationType in human-readable form.
@@ -53,11 +61,42 @@ func (n NotificationType) String() string {
 //   - NTBlockAccepted:     *bchutil.Block
 //   - NTBlockConnected:    *bchutil.Block
 //   - NTBlockDisconnected: *bchutil.Block
+//   - NTChainReorganized:  *ReorganizationData
 type Notification struct {
 	Type NotificationType
 	Data interface{}
 }
 
+// ReorganizationData houses the details of a reorganization of the main chain
+// which are sent with NTChainReorganized notifications.
+type ReorganizationData struct {
+	// OldHash and OldHeight identify the tip of the main chain before the
+	// reorganization.
+	OldHash   chainhash.Hash
+	OldHeight int32
+
+	// NewHash and NewHeight identify the tip of the main chain after the
+	// reorganization.
+	NewHash   chainhash.Hash
+	NewHeight int32
+
+	// ForkHash and ForkHeight identify the common ancestor of the old and
+	// new main chain.
+	ForkHash   chainhash.Hash
+	ForkHeight int32
+
+	// Disconnected contains the hashes of the disconnected blocks, starting
+	// with the old tip, and Connected the hashes of the connected blocks,
+	// ending with the new tip.
+	Disconnected []chainhash.Hash
+	Connected    []chainhash.Hash
+
+	// Unconfirmed contains the hashes of the transactions of the
+	// disconnected blocks which are not included in any of the connected
+	// blocks, in block order.
+	Unconfirmed []chainhash.Hash
+}
+
 // Subscribe to block chain notifications. Registers a callback to be executed
 // when various events take place. See the documentation on Notification and
 // NotificationType for details on the types and contents of notifications.
@@ -5,9 +5,12 @@
 package blockchain
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchutil"
 )
 
 // TestNotifications ensures that notification callbacks are fired on events.
@@ -49,3 +52,63 @@ func TestNotifications(t *testing.T) {
 			"times, found %d", numSubscribers, notificationCount)
 	}
 }
+
+// TestChainReorganizedNotification ensures a reorganization of the main chain
+// is reported along with the common ancestor and the transactions which are
+// no longer confirmed.
+func TestChainReorganizedNotification(t *testing.T) {
+	chain, params, tearDown := utxoCacheTestChain("TestChainReorganizedNotification")
+	defer tearDown()
+	genesis := bchutil.NewBlock(params.GenesisBlock)
+
+	var reorgs []*ReorganizationData
+	chain.Subscribe(func(n *Notification) {
+		if n.Type == NTChainReorganized {
+			reorgs = append(reorgs, n.Data.(*ReorganizationData))
+		}
+	})
+
+	// Create the main chain b1 -> b2, where b2 spends an output of b1, and
+	// a side chain b1 -> c2 -> c3 which has more work.
+	b1, outs1 := addBlock(chain, genesis, nil)
+	b2, _ := addBlock(chain, b1, outs1)
+	c2, _ := addBlock(chain, b1, nil)
+	if len(reorgs) != 0 {
+		t.Fatalf("unexpected reorganization notification")
+	}
+	c3, _ := addBlock(chain, c2, nil)
+	if len(reorgs) != 1 {
+		t.Fatalf("got %d reorganization notifications, want 1",
+			len(reorgs))
+	}
+
+	reorg := reorgs[0]
+	if reorg.OldHash != *b2.Hash() || reorg.OldHeight != 2 {
+		t.Errorf("unexpected old tip %v (%d)", reorg.OldHash,
+			reorg.OldHeight)
+	}
+	if reorg.NewHash != *c3.Hash() || reorg.NewHeight != 3 {
+		t.Errorf("unexpected new tip %v (%d)", reorg.NewHash,
+			reorg.NewHeight)
+	}
+	if reorg.ForkHash != *b1.Hash() || reorg.ForkHeight != 1 {
+		t.Errorf("unexpected fork point %v (%d)", reorg.ForkHash,
+			reorg.ForkHeight)
+	}
+	if len(reorg.Disconnected) != 1 || reorg.Disconnected[0] != *b2.Hash() {
+		t.Errorf("unexpected disconnected blocks %v", reorg.Disconnected)
+	}
+	if len(reorg.Connected) != 2 || reorg.Connected[0] != *c2.Hash() ||
+		reorg.Connected[1] != *c3.Hash() {
+
+		t.Errorf("unexpected connected blocks %v", reorg.Connected)
+	}
+	var want []chainhash.Hash
+	for _, tx := range b2.Transactions() {
+		want = append(want, *tx.Hash())
+	}
+	if !reflect.DeepEqual(reorg.Unconfirmed, want) {
+		t.Errorf("unexpected unconfirmed transactions %v, want %v",
+			reorg.Unconfirmed, want)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/gcash/bchd/chaincfg/chainhash"
 	"github.com/gcash/bchd/database"
 	"github.com/gcash/bchutil"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // BehaviorFlags is a bitmask defining tweaks to the normal behavior when
@@ -164,6 +165,25 @@ func (b *BlockChain) ProcessBlock(block *bchutil.Block, flags BehaviorFlags) (bo
 	b.chainLock.Lock()
 	defer b.chainLock.Unlock()
 
+	// There is no utxo set to connect the block to in headers only mode.
+	if b.headersOnly {
+		return false, false, AssertError("ProcessBlock called in " +
+			"headers only mode")
+	}
+
+	endSpan := b.startSpan("ProcessBlock",
+		attribute.String("block.hash", block.Hash().String()),
+		attribute.Int("block.size", block.MsgBlock().SerializeSize()),
+		attribute.Int("block.txns", len(block.Transactions())))
+	isMainChain, isOrphan, err := b.processBlock(block, flags)
+	endSpan(err)
+	return isMainChain, isOrphan, err
+}
+
+// processBlock is the main workhorse for ProcessBlock.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) processBlock(block *bchutil.Block, flags BehaviorFlags) (bool, bool, error) {
 	blockHash := block.Hash()
 	log.Tracef("Processing block %v", blockHash)
 
@@ -201,7 +221,9 @@ func (b *BlockChain) ProcessBlock(block *bchutil.Block, flags BehaviorFlags) (bo
 	}
 
 	// Perform preliminary sanity checks on the block and its transactions.
+	endSpan := b.startSpan("checkBlockSanity")
 	err := checkBlockSanity(block, b.chainParams.PowLimit, b.timeSource, flags)
+	endSpan(err)
 	if err != nil {
 		return false, false, err
 	}
@@ -0,0 +1,174 @@
+// Copyright (c) 2018-2020 The bchd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"math"
+	"time"
+
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+)
+
+const (
+	// minTxRateInterval is the minimum duration a sample of the verification
+	// rate spans.  Shorter samples are dominated by the size of individual
+	// blocks.
+	minTxRateInterval = 10 * time.Second
+
+	// maxTxRateInterval is the maximum duration between two connected blocks
+	// for which the verification rate is measured.  Blocks connected further
+	// apart are not being synced, so the measurement restarts.
+	maxTxRateInterval = 2 * time.Minute
+
+	// txRateSmoothing is the weight of the latest sample in the moving
+	// average of the verification rate.
+	txRateSmoothing = 0.3
+)
+
+// txRateMeter measures the rate at which transactions are verified while blocks
+// are connected to the main chain.
+type txRateMeter struct {
+	lastTime time.Time
+	lastTxns uint64
+	rate     float64
+}
+
+// update records the total number of transactions of the main chain after a
+// block was connected at the passed time.
+func (m *txRateMeter) update(now time.Time, totalTxns uint64) {
+	elapsed := now.Sub(m.lastTime)
+	switch {
+	// Restart the measurement when the chain was idle or reorganized.
+	case m.lastTime.IsZero() || elapsed > maxTxRateInterval ||
+		totalTxns < m.lastTxns:
+
+		m.lastTime = now
+		m.lastTxns = totalTxns
+		m.rate = 0
+		return
+
+	case elapsed < minTxRateInterval:
+		return
+	}
+
+	rate := float64(totalTxns-m.lastTxns) / elapsed.Seconds()
+	if m.rate == 0 {
+		m.rate = rate
+	} else {
+		m.rate += txRateSmoothing * (rate - m.rate)
+	}
+	m.lastTime = now
+	m.lastTxns = totalTxns
+}
+
+// currentRate returns the measured verification rate in transactions per
+// second, or zero when no blocks were connected recently.
+func (m *txRateMeter) currentRate(now time.Time) float64 {
+	if now.Sub(m.lastTime) > maxTxRateInterval {
+		return 0
+	}
+	return m.rate
+}
+
+// SyncProgress describes the progress of the main chain towards the tip of the
+// network.
+type SyncProgress struct {
+	// Height and Hash identify the tip of the main chain and BlockTime is
+	// its timestamp.
+	Height    int32
+	Hash      chainhash.Hash
+	BlockTime time.Time
+
+	// TotalTxns is the number of transactions in the main chain, while
+	// EstimatedTotalTxns is the estimated number of transactions in the
+	// chain of the network.
+	TotalTxns          uint64
+	EstimatedTotalTxns uint64
+
+	// VerificationProgress is the ratio of TotalTxns to EstimatedTotalTxns
+	// in the range [0, 1].
+	VerificationProgress float64
+
+	// TxRate is the number of transactions verified per second while
+	// blocks are connected.  It is zero when no blocks were connected
+	// recently.
+	TxRate float64
+
+	// RemainingTime is the estimated time it takes to verify the remaining
+	// transactions at TxRate.  It is zero when the rate is unknown or the
+	// chain is current.
+	RemainingTime time.Duration
+
+	// Current is whether or not the chain is believed to be current as
+	// returned by IsCurrent.
+	Current bool
+}
+
+// estimateTotalTxns estimates the number of transactions in the chain of the
+// network at the passed time given the total number of transactions up to a
+// block with the passed timestamp.  The growth of the chain is extrapolated
+// from the transaction data of the network when the block precedes it, and
+// from the average rate of the chain when the network has no data.  Since every
+// block has a coinbase, the rate is at least one transaction per block.
+func estimateTotalTxns(params *chaincfg.Params, blockTime time.Time, totalTxns uint64, now time.Time) float64 {
+	secondsSince := func(t time.Time) float64 {
+		return math.Max(now.Sub(t).Seconds(), 0)
+	}
+
+	data := &params.ChainTxData
+	if data.TxCount > 0 && totalTxns <= data.TxCount {
+		return float64(data.TxCount) + secondsSince(data.Time)*data.TxRate
+	}
+
+	rate := data.TxRate
+	if rate == 0 {
+		genesisTime := params.GenesisBlock.Header.Timestamp
+		if elapsed := blockTime.Sub(genesisTime).Seconds(); elapsed > 0 {
+			rate = float64(totalTxns) / elapsed
+		}
+		rate = math.Max(rate, 1/params.TargetTimePerBlock.Seconds())
+	}
+	return float64(totalTxns) + secondsSince(blockTime)*rate
+}
+
+// SyncProgress returns an estimate of the progress of the main chain towards
+// the tip of the network based on the number of transactions, which reflects
+// the remaining verification work better than the number of blocks.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) SyncProgress() *SyncProgress {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	tip := b.bestChain.Tip()
+	b.stateLock.RLock()
+	totalTxns := b.stateSnapshot.TotalTxns
+	b.stateLock.RUnlock()
+
+	now := b.timeSource.AdjustedTime()
+	blockTime := time.Unix(tip.timestamp, 0)
+	estimate := estimateTotalTxns(b.chainParams, blockTime, totalTxns, now)
+
+	progress := &SyncProgress{
+		Height:               tip.height,
+		Hash:                 tip.hash,
+		BlockTime:            blockTime,
+		TotalTxns:            totalTxns,
+		EstimatedTotalTxns:   uint64(math.Max(estimate, float64(totalTxns))),
+		VerificationProgress: 1,
+		TxRate:               b.txRate.currentRate(time.Now()),
+		Current:              b.isCurrent(),
+	}
+	if estimate > float64(totalTxns) {
+		progress.VerificationProgress = float64(totalTxns) / estimate
+	}
+	if !progress.Current && progress.TxRate > 0 {
+		remaining := float64(progress.EstimatedTotalTxns-totalTxns) /
+			progress.TxRate
+		progress.RemainingTime = time.Duration(remaining * float64(time.Second))
+	}
+	return progress
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2018-2020 The bchd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gcash/bchd/chaincfg"
+)
+
+// TestEstimateTotalTxns ensures the number of transactions of the network is
+// extrapolated as expected.
+func TestEstimateTotalTxns(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	params.TargetTimePerBlock = 1000 * time.Second
+	genesis := params.GenesisBlock.Header.Timestamp
+	dataTime := genesis.Add(1000 * time.Second)
+	now := dataTime.Add(100 * time.Second)
+	data := chaincfg.ChainTxData{Time: dataTime, TxCount: 5000, TxRate: 2}
+
+	tests := []struct {
+		name      string
+		data      chaincfg.ChainTxData
+		blockTime time.Time
+		totalTxns uint64
+		want      float64
+	}{{
+		name:      "block before data",
+		data:      data,
+		blockTime: genesis.Add(500 * time.Second),
+		totalTxns: 2000,
+		want:      5000 + 100*2,
+	}, {
+		name:      "block after data",
+		data:      data,
+		blockTime: dataTime.Add(50 * time.Second),
+		totalTxns: 6000,
+		want:      6000 + 50*2,
+	}, {
+		name:      "no data uses average rate",
+		blockTime: genesis.Add(500 * time.Second),
+		totalTxns: 1000,
+		want:      1000 + 600*2,
+	}, {
+		name:      "block in the future",
+		blockTime: now.Add(time.Hour),
+		totalTxns: 1000,
+		want:      1000,
+	}, {
+		name:      "no data at genesis uses one txn per block",
+		blockTime: genesis,
+		totalTxns: 1,
+		want:      1 + 1100.0/1000,
+	}}
+	for _, test := range tests {
+		params.ChainTxData = test.data
+		got := estimateTotalTxns(&params, test.blockTime,
+			test.totalTxns, now)
+		if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// TestTxRateMeter ensures the verification rate is measured as expected.
+func TestTxRateMeter(t *testing.T) {
+	var m txRateMeter
+	now := time.Unix(1000000, 0)
+	m.update(now, 100)
+	if rate := m.currentRate(now); rate != 0 {
+		t.Fatalf("initial rate: got %v, want 0", rate)
+	}
+
+	// Samples shorter than the minimum interval are accumulated.
+	m.update(now.Add(5*time.Second), 150)
+	if rate := m.currentRate(now); rate != 0 {
+		t.Fatalf("short sample rate: got %v, want 0", rate)
+	}
+	now = now.Add(minTxRateInterval)
+	m.update(now, 300)
+	if rate := m.currentRate(now); rate != 20 {
+		t.Fatalf("first sample rate: got %v, want 20", rate)
+	}
+
+	// Later samples are averaged.
+	now = now.Add(minTxRateInterval)
+	m.update(now, 700)
+	want := 20 + txRateSmoothing*(40-20)
+	if rate := m.currentRate(now); math.Abs(rate-want) > 1e-9 {
+		t.Fatalf("averaged rate: got %v, want %v", rate, want)
+	}
+
+	// The rate is unknown once no blocks were connected recently, and the
+	// measurement restarts afterwards.
+	now = now.Add(maxTxRateInterval + time.Second)
+	if rate := m.currentRate(now); rate != 0 {
+		t.Fatalf("idle rate: got %v, want 0", rate)
+	}
+	m.update(now, 800)
+	if rate := m.currentRate(now); rate != 0 {
+		t.Fatalf("restarted rate: got %v, want 0", rate)
+	}
+}
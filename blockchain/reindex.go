@@ -0,0 +1,191 @@
+package blockchain
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+var (
+	// reindexKeyName is the name of the db key used to mark that the chain
+	// is being rebuilt from the blocks stored in the database.  It is
+	// removed once the rebuild completes, so an interrupted rebuild is
+	// resumed on the next start.
+	reindexKeyName = []byte("reindexinprogress")
+)
+
+// dbResetChainState removes the block index, the utxo set, the spend journal
+// and the related chain state from the database while keeping the stored
+// blocks, and marks that the chain is being rebuilt from them.
+func dbResetChainState(db database.DB) error {
+	return db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if bytes.Equal(dbFetchBlockchainType(dbTx), prunedBlockchainEntryValue) {
+			return AssertError("unable to reindex a pruned blockchain")
+		}
+		if bytes.Equal(dbFetchBlockchainType(dbTx), headersOnlyBlockchainEntryValue) {
+			return AssertError("unable to reindex a headers only blockchain")
+		}
+
+		buckets := [][]byte{
+			blockIndexBucketName,
+			hashIndexBucketName,
+			heightIndexBucketName,
+			ablaStateBucketName,
+			invalidBlockReasonsBucketName,
+			spendJournalBucketName,
+			utxoSetBucketName,
+		}
+		for _, bucketName := range buckets {
+			if meta.Bucket(bucketName) == nil {
+				continue
+			}
+			if err := meta.DeleteBucket(bucketName); err != nil {
+				return err
+			}
+		}
+
+		keys := [][]byte{
+			chainStateKeyName,
+			spendJournalVersionKeyName,
+			utxoSetVersionKeyName,
+			utxoStateConsistencyKeyName,
+			utxoStatsKeyName,
+			pruneHeightKeyName,
+		}
+		for _, key := range keys {
+			if err := meta.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		return meta.Put(reindexKeyName, []byte{})
+	})
+}
+
+// maybeReindexBlocks rebuilds the chain from the blocks stored in the database
+// when a rebuild was started by dbResetChainState and has not completed yet.
+func (b *BlockChain) maybeReindexBlocks(interrupt <-chan struct{}) error {
+	var inProgress bool
+	err := b.db.View(func(dbTx database.Tx) error {
+		inProgress = dbTx.Metadata().Get(reindexKeyName) != nil
+		return nil
+	})
+	if err != nil || !inProgress {
+		return err
+	}
+
+	if err := b.reindexBlocks(interrupt); err != nil {
+		return err
+	}
+	log.Info("Rebuilding the chain from stored blocks complete")
+	return nil
+}
+
+// reindexBlocks rebuilds the chain from the blocks stored in the database by
+// processing them in order of height, starting from the genesis block, exactly
+// as if they were received from the network.  Blocks which are already known
+// are skipped, which allows an interrupted rebuild to be resumed.  Blocks which
+// fail validation are skipped along with all of their descendants.
+func (b *BlockChain) reindexBlocks(interrupt <-chan struct{}) error {
+	// Map every stored block to its children so the blocks can be processed
+	// in order of height without loading them all into memory.
+	log.Info("Loading stored block headers...")
+	children := make(map[chainhash.Hash][]chainhash.Hash)
+	var numBlocks int
+	err := b.db.View(func(dbTx database.Tx) error {
+		return dbTx.ForEachBlockHash(func(hash *chainhash.Hash) error {
+			if interruptRequested(interrupt) {
+				return errInterruptRequested
+			}
+
+			headerBytes, err := dbTx.FetchBlockHeader(hash)
+			if err != nil {
+				return err
+			}
+
+			var header wire.BlockHeader
+			err = header.Deserialize(bytes.NewReader(headerBytes))
+			if err != nil {
+				return err
+			}
+			prevHash := header.PrevBlock
+			children[prevHash] = append(children[prevHash], *hash)
+			numBlocks++
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	log.Infof("Rebuilding the chain from %d stored blocks...", numBlocks)
+
+	var numProcessed int
+	lastLog := time.Now()
+	queue := []chainhash.Hash{*b.chainParams.GenesisHash}
+	for len(queue) > 0 {
+		if interruptRequested(interrupt) {
+			return errInterruptRequested
+		}
+
+		parentHash := queue[0]
+		queue = queue[1:]
+		for i := range children[parentHash] {
+			hash := &children[parentHash][i]
+			if b.index.HaveBlock(hash) {
+				queue = append(queue, *hash)
+				continue
+			}
+
+			var blockBytes []byte
+			err := b.db.View(func(dbTx database.Tx) error {
+				var err error
+				blockBytes, err = dbTx.FetchBlock(hash)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			block, err := bchutil.NewBlockFromBytes(blockBytes)
+			if err != nil {
+				return err
+			}
+
+			_, _, err = b.ProcessBlock(block, BFNone)
+			if err != nil {
+				if _, ok := err.(RuleError); !ok {
+					return err
+				}
+				log.Warnf("Skipping stored block %v and its "+
+					"descendants: %v", hash, err)
+				continue
+			}
+			queue = append(queue, *hash)
+
+			numProcessed++
+			if time.Since(lastLog) >= time.Second*10 {
+				tip := b.BestSnapshot()
+				log.Infof("Rebuilt %d of %d stored blocks (height %d, "+
+					"hash %v)", numProcessed, numBlocks, tip.Height,
+					tip.Hash)
+				lastLog = time.Now()
+			}
+		}
+		delete(children, parentHash)
+	}
+
+	// Flush the rebuilt utxo set before marking the rebuild as complete.
+	b.chainLock.Lock()
+	err = b.utxoCache.Flush(FlushRequired, b.stateSnapshot)
+	b.chainLock.Unlock()
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(dbTx database.Tx) error {
+		return dbTx.Metadata().Delete(reindexKeyName)
+	})
+}
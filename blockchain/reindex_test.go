@@ -0,0 +1,91 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchutil"
+)
+
+// TestReindex ensures the chain rebuilt from the blocks stored in the
+// database matches the original chain, including the side chain blocks.
+func TestReindex(t *testing.T) {
+	chain, params, tearDown := utxoCacheTestChain("TestReindex")
+	defer tearDown()
+
+	// Build a chain with a side chain branching off at block 2.
+	tip := bchutil.NewBlock(params.GenesisBlock)
+	b1, outs1 := addBlock(chain, tip, nil)
+	b2, outs2 := addBlock(chain, b1, outs1)
+	b3a, _ := addBlock(chain, b2, outs2)
+	b3b, outs3 := addBlock(chain, b2, nil)
+	b4b, _ := addBlock(chain, b3b, outs3)
+	if err := chain.FlushCachedState(FlushRequired); err != nil {
+		t.Fatalf("unexpected error while flushing cache: %v", err)
+	}
+	want := chain.BestSnapshot()
+	if want.Hash != *b4b.Hash() {
+		t.Fatalf("unexpected tip %v, want %v", want.Hash, b4b.Hash())
+	}
+
+	// Rebuild the chain from the stored blocks with a new chain instance.
+	reindexed, err := New(&Config{
+		DB:                 chain.db,
+		ChainParams:        chain.chainParams,
+		TimeSource:         NewMedianTime(),
+		SigCache:           txscript.NewSigCache(1000),
+		UtxoCacheMaxSize:   250 * 1024 * 1024,
+		ExcessiveBlockSize: 32000000,
+		ReIndex:            true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create reindexed chain instance: %v", err)
+	}
+
+	got := reindexed.BestSnapshot()
+	if got.Hash != want.Hash || got.Height != want.Height ||
+		got.TotalTxns != want.TotalTxns {
+
+		t.Fatalf("unexpected reindexed tip: got %v (height %d, %d "+
+			"txns), want %v (height %d, %d txns)", got.Hash,
+			got.Height, got.TotalTxns, want.Hash, want.Height,
+			want.TotalTxns)
+	}
+	if !reindexed.index.HaveBlock(b3a.Hash()) {
+		t.Fatalf("side chain block %v was not reindexed", b3a.Hash())
+	}
+
+	// The rebuilt utxo set must contain the outputs of the best chain.
+	entry, err := reindexed.FetchUtxoEntry(outs3[0].prevOut)
+	if err != nil {
+		t.Fatalf("FetchUtxoEntry: %v", err)
+	}
+	if entry != nil && !entry.IsSpent() {
+		t.Fatalf("output %v spent in block 4b is unspent",
+			outs3[0].prevOut)
+	}
+	for _, out := range outs2 {
+		entry, err := reindexed.FetchUtxoEntry(out.prevOut)
+		if err != nil {
+			t.Fatalf("FetchUtxoEntry: %v", err)
+		}
+		if entry == nil || entry.IsSpent() {
+			t.Fatalf("missing unspent output %v", out.prevOut)
+		}
+	}
+
+	// The rebuild is complete, so it must not run again.
+	if err := reindexed.maybeReindexBlocks(nil); err != nil {
+		t.Fatalf("maybeReindexBlocks: %v", err)
+	}
+	err = reindexed.db.View(func(dbTx database.Tx) error {
+		if dbTx.Metadata().Get(reindexKeyName) != nil {
+			t.Fatal("reindex still marked as in progress")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
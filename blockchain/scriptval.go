@@ -18,11 +18,57 @@ import (
 
 // txValidateItem holds a transaction along with which input to validate.
 type txValidateItem struct {
+	txIndex     int // index of the transaction in its block, or -1
 	txInIndex   int
 	txIn        *wire.TxIn
 	tx          *bchutil.Tx
 	sigHashes   *txscript.TxSigHashes
 	txSigChecks *uint32
+	txMetrics   *TxScriptMetrics
+}
+
+// scriptRuleError creates a RuleError for a script failure of the input, which
+// is located by the position the passed engine failed at when it is not nil.
+func (txVI *txValidateItem) scriptRuleError(c ErrorCode, desc string,
+	scriptErr error, vm *txscript.Engine) RuleError {
+
+	detail := &RuleErrorDetail{
+		TxIndex:     txVI.txIndex,
+		TxHash:      *txVI.tx.Hash(),
+		InputIndex:  txVI.txInIndex,
+		ScriptErr:   scriptErr,
+		ScriptIndex: -1,
+		OpcodeIndex: -1,
+	}
+	if vm != nil {
+		if scriptIdx, opcodeIdx, ok := vm.ScriptPosition(); ok {
+			detail.ScriptIndex = scriptIdx
+			detail.OpcodeIndex = opcodeIdx
+		}
+	}
+	return RuleError{ErrorCode: c, Description: desc, Detail: detail}
+}
+
+// TxScriptMetrics houses the script execution metrics of all of the inputs of a
+// transaction, which describe how costly it is to validate its scripts.  The
+// costs are computed according to the VM limits rules, which are enforced when
+// the ScriptAllowMay2025 flag is set, but they are available regardless so
+// they can be used for policy purposes.
+type TxScriptMetrics struct {
+	// OpCost is the sum of the composite operation costs of the inputs,
+	// which include the costs of hashing and signature checks.
+	OpCost int64
+
+	// OpCostLimit is the sum of the operation cost limits of the inputs.
+	OpCostLimit int64
+
+	// HashDigestIterations is the total number of hash digest iterations
+	// performed by the scripts of the inputs.
+	HashDigestIterations int64
+
+	// SigChecks is the total number of signature checks performed by the
+	// scripts of the inputs.
+	SigChecks uint32
 }
 
 // txValidator provides a type which asynchronously validates transaction
@@ -35,6 +81,7 @@ type txValidator struct {
 	utxoView           *UtxoViewpoint
 	flags              txscript.ScriptFlags
 	sigCache           *txscript.SigCache
+	schnorrBatch       *txscript.SchnorrBatch
 	hashCache          *txscript.HashCache
 	sigChecks          uint32
 	maxSigChecks       uint32
@@ -117,13 +164,6 @@ out:
 				break out
 			}
 
-			if v.flags.HasFlag(txscript.ScriptAllowCashTokens) {
-				_, err := wire.RunCashTokensValidityAlgorithm(utxoEntryCache, txVI.tx.MsgTx())
-				if err != nil {
-					v.sendResult(err)
-				}
-			}
-
 			vm, err := txscript.NewEngine(pkScript, txVI.tx.MsgTx(),
 				txVI.txInIndex, v.flags, v.sigCache, txVI.sigHashes,
 				utxoEntryCache, inputAmount)
@@ -135,10 +175,12 @@ out:
 					txVI.tx.Hash(), txVI.txInIndex,
 					txIn.PreviousOutPoint, err,
 					sigScript, pkScript)
-				err := ruleError(ErrScriptMalformed, str)
+				err := txVI.scriptRuleError(ErrScriptMalformed,
+					str, err, nil)
 				v.sendResult(err)
 				break out
 			}
+			vm.SetSchnorrBatch(v.schnorrBatch)
 
 			// Execute the script pair.
 			if err := vm.Execute(); err != nil {
@@ -149,12 +191,30 @@ out:
 					txVI.tx.Hash(), txVI.txInIndex,
 					txIn.PreviousOutPoint, err,
 					sigScript, pkScript)
-				err := ruleError(ErrScriptValidation, str)
+				err := txVI.scriptRuleError(ErrScriptValidation,
+					str, err, vm)
+				vm.Release()
 				v.sendResult(err)
 				break out
 			}
 
-			txSigChecks := atomic.AddUint32(txVI.txSigChecks, uint32(vm.SigChecks()))
+			vmSigChecks := uint32(vm.SigChecks())
+			txSigChecks := atomic.AddUint32(txVI.txSigChecks, vmSigChecks)
+
+			if txVI.txMetrics != nil {
+				metrics := vm.GetMetrics()
+				isStandard := v.flags.HasFlag(txscript.ScriptAllowMay2025StandardOnly)
+				atomic.AddInt64(&txVI.txMetrics.OpCost,
+					metrics.GetCompositeOPCost(isStandard))
+				atomic.AddInt64(&txVI.txMetrics.OpCostLimit,
+					metrics.GetMaxOpCostLimit())
+				atomic.AddInt64(&txVI.txMetrics.HashDigestIterations,
+					metrics.GetHashDigestIterations())
+			}
+
+			// The engine is no longer needed, so return it to the
+			// pool for the next input.
+			vm.Release()
 
 			if v.flags.HasFlag(txscript.ScriptReportSigChecks) && txSigChecks > MaxTransactionSigChecks {
 				str := fmt.Sprintf("transaction %s too many sig checks",
@@ -165,7 +225,7 @@ out:
 			}
 
 			if v.maxSigChecks > 0 && v.flags.HasFlag(txscript.ScriptReportSigChecks) {
-				if atomic.AddUint32(&v.sigChecks, uint32(vm.SigChecks())) > v.maxSigChecks {
+				if atomic.AddUint32(&v.sigChecks, vmSigChecks) > v.maxSigChecks {
 					str := "block too many sig checks"
 					err := ruleError(ErrTooManySigChecks, str)
 					v.sendResult(err)
@@ -263,6 +323,23 @@ func ValidateTransactionScripts(tx *bchutil.Tx, utxoView *UtxoViewpoint,
 	flags txscript.ScriptFlags, sigCache *txscript.SigCache,
 	hashCache *txscript.HashCache, upgrade9ForkHeight int32) (uint32, error) {
 
+	metrics, err := ValidateTransactionScriptsWithMetrics(tx, utxoView,
+		flags, sigCache, hashCache, upgrade9ForkHeight)
+	if err != nil {
+		return 0, err
+	}
+	return metrics.SigChecks, nil
+}
+
+// ValidateTransactionScriptsWithMetrics validates the scripts for the passed
+// transaction using multiple goroutines just like ValidateTransactionScripts.
+// It returns the script execution metrics of the transaction, which callers
+// such as the mempool may use to apply policy based on the cost of validating
+// it.
+func ValidateTransactionScriptsWithMetrics(tx *bchutil.Tx, utxoView *UtxoViewpoint,
+	flags txscript.ScriptFlags, sigCache *txscript.SigCache,
+	hashCache *txscript.HashCache, upgrade9ForkHeight int32) (*TxScriptMetrics, error) {
+
 	// If the HashCache is present, and it doesn't yet contain the
 	// partial sighashes for this transaction, then we add the
 	// sighashes for the transaction. This allows us to take
@@ -298,7 +375,7 @@ func ValidateTransactionScripts(tx *bchutil.Tx, utxoView *UtxoViewpoint,
 
 	// Collect all of the transaction inputs and required information for
 	// validation.
-	sigChecks := uint32(0)
+	metrics := new(TxScriptMetrics)
 	txIns := tx.MsgTx().TxIn
 	txValItems := make([]*txValidateItem, 0, len(txIns))
 	for txInIdx, txIn := range txIns {
@@ -308,10 +385,12 @@ func ValidateTransactionScripts(tx *bchutil.Tx, utxoView *UtxoViewpoint,
 		}
 
 		txVI := &txValidateItem{
+			txIndex:     -1,
 			txInIndex:   txInIdx,
 			txIn:        txIn,
 			tx:          tx,
-			txSigChecks: &sigChecks,
+			txSigChecks: &metrics.SigChecks,
+			txMetrics:   metrics,
 			sigHashes:   cachedHashes,
 		}
 		txValItems = append(txValItems, txVI)
@@ -320,9 +399,9 @@ func ValidateTransactionScripts(tx *bchutil.Tx, utxoView *UtxoViewpoint,
 	// Validate all of the inputs.
 	validator := newTxValidator(utxoView, flags, sigCache, hashCache, 0, upgrade9ForkHeight)
 	if err := validator.Validate(txValItems); err != nil {
-		return 0, err
+		return nil, err
 	}
-	return sigChecks, nil
+	return metrics, nil
 }
 
 // Checks if the input contains pre-activation token-forgery output.
@@ -339,11 +418,57 @@ func IsPATFO(tokenData wire.TokenData, pkScript []byte, utxoBlockHeight int32, u
 	return isPATFO
 }
 
+// precomputeSigHashes computes the partial BIP0143 sighashes of all of the
+// transactions in the passed block which are not in the passed hash cache yet
+// and adds them to it.  The sighashes are computed by multiple goroutines in
+// the background, and the returned channel is closed once all of them have
+// been added to the cache.
+//
+// This allows hashing the transactions to overlap with other work needed to
+// connect the block, such as loading the utxos it spends, rather than it
+// delaying the execution of the scripts.
+func precomputeSigHashes(block *bchutil.Block, hashCache *txscript.HashCache) <-chan struct{} {
+	done := make(chan struct{})
+	txns := block.Transactions()
+
+	numWorkers := runtime.NumCPU()
+	if numWorkers > len(txns) {
+		numWorkers = len(txns)
+	}
+	if numWorkers <= 0 {
+		close(done)
+		return done
+	}
+
+	var next, remaining int32 = -1, int32(numWorkers)
+	for i := 0; i < numWorkers; i++ {
+		go func() {
+			for {
+				idx := int(atomic.AddInt32(&next, 1))
+				if idx >= len(txns) {
+					break
+				}
+				tx := txns[idx]
+				if !hashCache.ContainsHashes(tx.Hash()) {
+					hashCache.AddTxSigHashes(tx.Hash(), tx.MsgTx())
+				}
+			}
+			if atomic.AddInt32(&remaining, -1) == 0 {
+				close(done)
+			}
+		}()
+	}
+	return done
+}
+
 // checkBlockScripts executes and validates the scripts for all transactions in
-// the passed block using multiple goroutines.
+// the passed block using multiple goroutines.  The scripts of the transactions
+// found in the passed script cache are not executed again, but their signature
+// checks still count towards the limit of the block.
 func checkBlockScripts(block *bchutil.Block, utxoView *UtxoViewpoint,
 	scriptFlags txscript.ScriptFlags, sigCache *txscript.SigCache,
-	hashCache *txscript.HashCache, maxSigChecks uint32, upgrade9ForkHeight int32) error {
+	hashCache *txscript.HashCache, scriptCache *txscript.ScriptCache,
+	maxSigChecks uint32, upgrade9ForkHeight int32) error {
 
 	// Collect all of the transaction inputs and required information for
 	// validation for all transactions in the block into a single slice.
@@ -352,9 +477,19 @@ func checkBlockScripts(block *bchutil.Block, utxoView *UtxoViewpoint,
 		numInputs += len(tx.MsgTx().TxIn)
 	}
 	txValItems := make([]*txValidateItem, 0, numInputs)
-	for _, tx := range block.Transactions() {
+	var cachedSigChecks uint32
+	for txIdx, tx := range block.Transactions() {
 		sigChecks := uint32(0)
 
+		// Skip the transactions whose scripts are already known to be
+		// valid under the script flags of the block.
+		if scriptCache != nil && txIdx > 0 {
+			if txSigChecks, ok := scriptCache.Lookup(tx.Hash(), scriptFlags); ok {
+				cachedSigChecks += txSigChecks
+				continue
+			}
+		}
+
 		// If the HashCache is present, and it doesn't yet contain the
 		// partial sighashes for this transaction, then we add the
 		// sighashes for the transaction. This allows us to take
@@ -364,7 +499,7 @@ func checkBlockScripts(block *bchutil.Block, utxoView *UtxoViewpoint,
 		if scriptFlags.HasFlag(txscript.ScriptVerifyBip143SigHash) && hashCache != nil &&
 			!hashCache.ContainsHashes(hash) {
 
-			hashCache.AddSigHashes(tx.MsgTx())
+			hashCache.AddTxSigHashes(hash, tx.MsgTx())
 		}
 
 		var cachedHashes *txscript.TxSigHashes
@@ -395,6 +530,7 @@ func checkBlockScripts(block *bchutil.Block, utxoView *UtxoViewpoint,
 			}
 
 			txVI := &txValidateItem{
+				txIndex:     txIdx,
 				txInIndex:   txInIdx,
 				txIn:        txIn,
 				tx:          tx,
@@ -405,12 +541,29 @@ func checkBlockScripts(block *bchutil.Block, utxoView *UtxoViewpoint,
 		}
 	}
 
-	// Validate all of the inputs.
+	// Validate all of the inputs.  The Schnorr signatures are verified in
+	// batches when the sig checks rules are active since any invalid
+	// signature makes the block invalid under them.
 	validator := newTxValidator(utxoView, scriptFlags, sigCache, hashCache, maxSigChecks, upgrade9ForkHeight)
+	if scriptFlags.HasFlag(txscript.ScriptReportSigChecks) {
+		if maxSigChecks > 0 && cachedSigChecks > maxSigChecks {
+			str := "block too many sig checks"
+			return ruleError(ErrTooManySigChecks, str)
+		}
+		validator.sigChecks = cachedSigChecks
+		validator.schnorrBatch = txscript.NewSchnorrBatch(sigCache)
+	}
 	start := time.Now()
 	if err := validator.Validate(txValItems); err != nil {
 		return err
 	}
+	if validator.schnorrBatch != nil {
+		if err := validator.schnorrBatch.Verify(); err != nil {
+			str := fmt.Sprintf("failed to validate signatures in "+
+				"block %v - %v", block.Hash(), err)
+			return ruleError(ErrScriptValidation, str)
+		}
+	}
 
 	elapsed := time.Since(start)
 
@@ -9,6 +9,7 @@ import (
 	"runtime"
 	"testing"
 
+	"github.com/gcash/bchd/chaincfg"
 	"github.com/gcash/bchd/txscript"
 )
 
@@ -41,9 +42,114 @@ func TestCheckBlockScripts(t *testing.T) {
 	}
 
 	scriptFlags := txscript.ScriptBip16
-	err = checkBlockScripts(blocks[0], view, scriptFlags, nil, nil, 0, 0)
+	err = checkBlockScripts(blocks[0], view, scriptFlags, nil, nil, nil, 0, 0)
 	if err != nil {
 		t.Errorf("Transaction script validation failed: %v\n", err)
 		return
 	}
 }
+
+// TestCheckBlockScriptsCache ensures the scripts of the transactions in the
+// script cache are not executed again while their signature checks still count
+// towards the limit of the block.
+func TestCheckBlockScriptsCache(t *testing.T) {
+	blocks, err := loadBlocks("277647.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v", err)
+	}
+	block := blocks[0]
+
+	// The scripts of all transactions would fail to validate against an
+	// empty utxo view, so the block only passes when none of them are
+	// executed.
+	view := NewUtxoViewpoint()
+	scriptFlags := txscript.ScriptBip16 | txscript.ScriptReportSigChecks
+	scriptCache := txscript.NewScriptCache(uint(len(block.Transactions())))
+	for _, tx := range block.Transactions()[1:] {
+		scriptCache.Add(tx.Hash(), scriptFlags, 1)
+	}
+	err = checkBlockScripts(block, view, scriptFlags, nil, nil, scriptCache,
+		0, 0)
+	if err != nil {
+		t.Fatalf("checkBlockScripts: unexpected error: %v", err)
+	}
+
+	// The cached signature checks exceed the limit of the block.
+	maxSigChecks := uint32(len(block.Transactions()) - 2)
+	err = checkBlockScripts(block, view, scriptFlags, nil, nil, scriptCache,
+		maxSigChecks, 0)
+	if !isRuleError(err, ErrTooManySigChecks) {
+		t.Fatalf("checkBlockScripts: unexpected error for too many sig "+
+			"checks: %v", err)
+	}
+
+	// The scripts are executed when the block is validated with other
+	// script flags than the transactions were cached with.
+	err = checkBlockScripts(block, view, txscript.ScriptBip16, nil, nil,
+		scriptCache, 0, 0)
+	if !isRuleError(err, ErrMissingTxOut) {
+		t.Fatalf("checkBlockScripts: unexpected error for uncached "+
+			"script flags: %v", err)
+	}
+}
+
+// TestPrecomputeSigHashes ensures the partial sighashes of all transactions in
+// a block are added to the hash cache by precomputeSigHashes.
+func TestPrecomputeSigHashes(t *testing.T) {
+	blocks, err := loadBlocks("277647.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v", err)
+	}
+	block := blocks[0]
+
+	// Add the sighashes of the first transaction up front to ensure the
+	// transactions which are already cached are handled too.
+	hashCache := txscript.NewHashCache(uint(len(block.Transactions())))
+	hashCache.AddSigHashes(block.Transactions()[0].MsgTx())
+	<-precomputeSigHashes(block, hashCache)
+
+	for _, tx := range block.Transactions() {
+		sigHashes, ok := hashCache.GetSigHashes(tx.Hash())
+		if !ok {
+			t.Fatalf("sighashes for tx %v were not computed", tx.Hash())
+		}
+		want := txscript.NewTxSigHashes(tx.MsgTx())
+		if sigHashes.HashPrevOuts != want.HashPrevOuts ||
+			sigHashes.HashSequence != want.HashSequence ||
+			sigHashes.HashOutputs != want.HashOutputs {
+
+			t.Fatalf("sighashes for tx %v don't match: got %v, want %v",
+				tx.Hash(), sigHashes, want)
+		}
+	}
+}
+
+// TestNextBlockScriptFlags ensures the script flags of the next block reflect
+// the rules active after the tip of the main chain.
+func TestNextBlockScriptFlags(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	chain, teardownFunc, err := chainSetup("nextblockscriptflags", &params)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	scriptFlags, err := chain.NextBlockScriptFlags()
+	if err != nil {
+		t.Fatalf("NextBlockScriptFlags: unexpected error: %v", err)
+	}
+	want := txscript.ScriptBip16 | txscript.ScriptVerifyStrictEncoding |
+		txscript.ScriptVerifyBip143SigHash | txscript.ScriptVerifyLowS |
+		txscript.ScriptVerifyNullFail
+	if scriptFlags&want != want {
+		t.Fatalf("NextBlockScriptFlags: got flags %x, want %x set",
+			scriptFlags, want)
+	}
+
+	// The sig checks rules of the Phonon upgrade only activate at a later
+	// height on the regression test network.
+	if scriptFlags.HasFlag(txscript.ScriptReportSigChecks) {
+		t.Fatalf("NextBlockScriptFlags: got flags %x with inactive sig "+
+			"checks rules", scriptFlags)
+	}
+}
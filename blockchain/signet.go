@@ -0,0 +1,156 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// SignetHeader is the prefix of the data pushed by the coinbase output which
+// commits to the signature solution of a block on a signed test network.
+var SignetHeader = [4]byte{0xec, 0xc7, 0xda, 0xa2}
+
+// signetBlockDataLen is the length of the header data the signature solution
+// of a block commits to.  It consists of the version, previous block hash,
+// signet merkle root and timestamp of the block.
+const signetBlockDataLen = 72
+
+// signetScriptFlags are the script flags signature solutions are validated
+// with.
+const signetScriptFlags = txscript.StandardVerifyFlags
+
+// SignetCommitmentScript returns the public key script of the coinbase output
+// which commits to the passed signature solution.  The script of a block which
+// is still to be signed commits to an empty solution.
+func SignetCommitmentScript(solution []byte) ([]byte, error) {
+	data := make([]byte, 0, len(SignetHeader)+len(solution))
+	data = append(data, SignetHeader[:]...)
+	data = append(data, solution...)
+	return txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+		AddData(data).Script()
+}
+
+// SignetCommitment returns the index of the output of the passed coinbase which
+// commits to the signature solution of the block along with the solution.  It
+// is the last output whose script is an OP_RETURN followed by a single push of
+// data starting with the signet header.  The index is -1 when no output commits
+// to a solution.
+func SignetCommitment(coinbase *wire.MsgTx) (int, []byte) {
+	for i := len(coinbase.TxOut) - 1; i >= 0; i-- {
+		pkScript := coinbase.TxOut[i].PkScript
+		if len(pkScript) == 0 || pkScript[0] != txscript.OP_RETURN {
+			continue
+		}
+		if !txscript.IsPushOnlyScript(pkScript[1:]) {
+			continue
+		}
+		pushes, err := txscript.PushedData(pkScript[1:])
+		if err != nil || len(pushes) != 1 {
+			continue
+		}
+		data := pushes[0]
+		if !bytes.HasPrefix(data, SignetHeader[:]) {
+			continue
+		}
+		return i, data[len(SignetHeader):]
+	}
+	return -1, nil
+}
+
+// SignetSigningTx returns the transaction whose only input must be signed to
+// sign the passed block on the signed test network with the passed challenge.
+// The signature script of the input is the signature solution of the block.
+//
+// The input spends an output of zero value with the challenge as public key
+// script from a virtual transaction which commits to the block.  It commits to
+// the header of the block without its bits and nonce, and to the transactions
+// of the block with the solution stripped from the coinbase commitment.  The
+// solution thus remains valid while the proof of work is solved, but the block
+// must be signed again whenever its transactions, including the extra nonce of
+// the coinbase, change.
+func SignetSigningTx(block *wire.MsgBlock, challenge []byte) (*wire.MsgTx, error) {
+	if len(block.Transactions) == 0 {
+		return nil, ruleError(ErrBadSignetSolution, "block does not "+
+			"contain any transactions")
+	}
+	idx, _ := SignetCommitment(block.Transactions[0])
+	if idx < 0 {
+		return nil, ruleError(ErrBadSignetSolution, "coinbase does not "+
+			"commit to a signet solution")
+	}
+
+	// Calculate the merkle root of the block with the solution stripped
+	// from the commitment.
+	coinbase := block.Transactions[0].Copy()
+	pkScript, err := SignetCommitmentScript(nil)
+	if err != nil {
+		return nil, err
+	}
+	coinbase.TxOut[idx].PkScript = pkScript
+	txns := make([]*bchutil.Tx, 0, len(block.Transactions))
+	txns = append(txns, bchutil.NewTx(coinbase))
+	for _, tx := range block.Transactions[1:] {
+		txns = append(txns, bchutil.NewTx(tx))
+	}
+	merkles := BuildMerkleTreeStore(txns)
+	merkleRoot := merkles[len(merkles)-1]
+
+	blockData := make([]byte, signetBlockDataLen)
+	binary.LittleEndian.PutUint32(blockData[0:4], uint32(block.Header.Version))
+	copy(blockData[4:36], block.Header.PrevBlock[:])
+	copy(blockData[36:68], merkleRoot[:])
+	binary.LittleEndian.PutUint32(blockData[68:72],
+		uint32(block.Header.Timestamp.Unix()))
+	sigScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).
+		AddData(blockData).Script()
+	if err != nil {
+		return nil, err
+	}
+
+	toSpend := wire.NewMsgTx(0)
+	toSpend.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		SignatureScript: sigScript,
+	})
+	toSpend.AddTxOut(wire.NewTxOut(0, challenge, wire.TokenData{}))
+
+	toSpendHash := toSpend.TxHash()
+	toSign := wire.NewMsgTx(0)
+	toSign.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&toSpendHash, 0),
+	})
+	toSign.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_RETURN},
+		wire.TokenData{}))
+	return toSign, nil
+}
+
+// CheckSignetSolution ensures the coinbase of the passed block commits to a
+// signature solution which satisfies the passed signet challenge.
+func CheckSignetSolution(block *wire.MsgBlock, challenge []byte) error {
+	toSign, err := SignetSigningTx(block, challenge)
+	if err != nil {
+		return err
+	}
+	_, solution := SignetCommitment(block.Transactions[0])
+	toSign.TxIn[0].SignatureScript = solution
+
+	utxoCache := txscript.NewUtxoCache()
+	utxoCache.AddEntry(0, *wire.NewTxOut(0, challenge, wire.TokenData{}))
+	vm, err := txscript.NewEngine(challenge, toSign, 0, signetScriptFlags,
+		nil, nil, utxoCache, 0)
+	if err == nil {
+		err = vm.Execute()
+	}
+	if err != nil {
+		str := fmt.Sprintf("signet solution of block %v is invalid: %v",
+			block.BlockHash(), err)
+		return ruleError(ErrBadSignetSolution, str)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gcash/bchd/bchec"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// newSignetTestBlock returns a block with a coinbase committing to an empty
+// signet solution and a second transaction.
+func newSignetTestBlock(t *testing.T) *wire.MsgBlock {
+	commitment, err := SignetCommitmentScript(nil)
+	if err != nil {
+		t.Fatalf("SignetCommitmentScript: unexpected error: %v", err)
+	}
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		SignatureScript: []byte{0x51, 0x51},
+	})
+	coinbase.AddTxOut(wire.NewTxOut(5000000000, []byte{txscript.OP_TRUE},
+		wire.TokenData{}))
+	coinbase.AddTxOut(wire.NewTxOut(0, commitment, wire.TokenData{}))
+
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{0x01}, 0),
+	})
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE},
+		wire.TokenData{}))
+
+	block := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   4,
+			PrevBlock: chainhash.Hash{0x02},
+			Timestamp: time.Unix(1700000000, 0),
+			Bits:      0x1e0377ae,
+		},
+		Transactions: []*wire.MsgTx{coinbase, tx},
+	}
+	return block
+}
+
+// signSignetTestBlock signs the passed block for the passed challenge with the
+// passed key and commits to the solution in the coinbase.
+func signSignetTestBlock(t *testing.T, block *wire.MsgBlock, challenge []byte,
+	key *bchec.PrivateKey) {
+
+	toSign, err := SignetSigningTx(block, challenge)
+	if err != nil {
+		t.Fatalf("SignetSigningTx: unexpected error: %v", err)
+	}
+	solution, err := txscript.SignTxOutput(&chaincfg.SigNetParams, toSign,
+		0, 0, challenge, txscript.SigHashAll|txscript.SigHashForkID,
+		txscript.KeyClosure(func(bchutil.Address) (*bchec.PrivateKey, bool, error) {
+			return key, true, nil
+		}), nil, nil)
+	if err != nil {
+		t.Fatalf("SignTxOutput: unexpected error: %v", err)
+	}
+	commitment, err := SignetCommitmentScript(solution)
+	if err != nil {
+		t.Fatalf("SignetCommitmentScript: unexpected error: %v", err)
+	}
+	idx, _ := SignetCommitment(block.Transactions[0])
+	block.Transactions[0].TxOut[idx].PkScript = commitment
+}
+
+// TestCheckSignetSolution ensures signature solutions are checked against the
+// signet challenge and commit to the block.
+func TestCheckSignetSolution(t *testing.T) {
+	key, err := bchec.NewPrivateKey(bchec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	challenge, err := txscript.NewScriptBuilder().
+		AddData(key.PubKey().SerializeCompressed()).
+		AddOp(txscript.OP_CHECKSIG).Script()
+	if err != nil {
+		t.Fatalf("unexpected error building challenge: %v", err)
+	}
+
+	block := newSignetTestBlock(t)
+	err = CheckSignetSolution(block, challenge)
+	if !isRuleError(err, ErrBadSignetSolution) {
+		t.Fatalf("unsigned block: unexpected error %v", err)
+	}
+
+	signSignetTestBlock(t, block, challenge, key)
+	if err := CheckSignetSolution(block, challenge); err != nil {
+		t.Fatalf("signed block: unexpected error %v", err)
+	}
+
+	// The solution does not commit to the proof of work.
+	block.Header.Nonce++
+	block.Header.Bits = 0x1d00ffff
+	if err := CheckSignetSolution(block, challenge); err != nil {
+		t.Fatalf("block with new nonce: unexpected error %v", err)
+	}
+
+	// The solution commits to the header and the transactions.
+	block.Header.Timestamp = block.Header.Timestamp.Add(time.Second)
+	err = CheckSignetSolution(block, challenge)
+	if !isRuleError(err, ErrBadSignetSolution) {
+		t.Fatalf("block with new timestamp: unexpected error %v", err)
+	}
+	block.Header.Timestamp = block.Header.Timestamp.Add(-time.Second)
+	block.Transactions[1].TxOut[0].Value++
+	err = CheckSignetSolution(block, challenge)
+	if !isRuleError(err, ErrBadSignetSolution) {
+		t.Fatalf("block with new transaction: unexpected error %v", err)
+	}
+	block.Transactions[1].TxOut[0].Value--
+
+	// The solution is only valid for its challenge.
+	otherChallenge := append([]byte(nil), challenge...)
+	otherChallenge[1] ^= 0x01
+	err = CheckSignetSolution(block, otherChallenge)
+	if !isRuleError(err, ErrBadSignetSolution) {
+		t.Fatalf("other challenge: unexpected error %v", err)
+	}
+
+	// Blocks without commitment are rejected.
+	block.Transactions[0].TxOut = block.Transactions[0].TxOut[:1]
+	err = CheckSignetSolution(block, challenge)
+	if !isRuleError(err, ErrBadSignetSolution) {
+		t.Fatalf("block without commitment: unexpected error %v", err)
+	}
+
+	// A trivial challenge is satisfied by an empty solution.
+	block = newSignetTestBlock(t)
+	if err := CheckSignetSolution(block, []byte{txscript.OP_TRUE}); err != nil {
+		t.Fatalf("trivial challenge: unexpected error %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package blockchain
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the OpenTelemetry spans which instrument the block validation
+// pipeline.  It is obtained from the global tracer provider, which does not
+// record anything unless the application registers one, so tracing is a no-op
+// by default.
+var tracer = otel.Tracer("github.com/gcash/bchd/blockchain")
+
+// startSpan starts a span with the passed name as a child of the span which is
+// currently active on the chain, if any, and makes it the active span.  It
+// returns a function which must be called with the result of the traced
+// operation to end the span and make its parent active again.
+//
+// The active span is tracked on the chain instance rather than passed through
+// the validation functions, so this function MUST be called with the chain
+// lock held for writes.
+func (b *BlockChain) startSpan(name string, attrs ...attribute.KeyValue) func(error) {
+	parent := b.traceCtx
+	ctx := parent
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, span := tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+	b.traceCtx = ctx
+	return func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		b.traceCtx = parent
+	}
+}
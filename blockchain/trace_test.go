@@ -0,0 +1,67 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/gcash/bchutil"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestProcessBlockSpans ensures processing a block records spans for the
+// stages of the validation pipeline nested under the span of the block.
+func TestProcessBlockSpans(t *testing.T) {
+	chain, params, tearDown := utxoCacheTestChain("TestProcessBlockSpans")
+	defer tearDown()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	origTracer := tracer
+	tracer = provider.Tracer("github.com/gcash/bchd/blockchain")
+	defer func() { tracer = origTracer }()
+
+	addBlock(chain, bchutil.NewBlock(params.GenesisBlock), nil)
+	if chain.traceCtx != nil {
+		t.Fatal("active span not reset after processing block")
+	}
+
+	spans := recorder.Ended()
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range spans {
+		byName[span.Name()] = span
+	}
+	root, ok := byName["ProcessBlock"]
+	if !ok {
+		t.Fatal("no ProcessBlock span recorded")
+	}
+	if root.Parent().IsValid() {
+		t.Fatal("ProcessBlock span has a parent")
+	}
+	tests := []struct {
+		name   string
+		parent string
+	}{
+		{name: "checkBlockSanity", parent: "ProcessBlock"},
+		{name: "checkBlockContext", parent: "ProcessBlock"},
+		{name: "dbStoreBlock", parent: "ProcessBlock"},
+		{name: "checkConnectBlock", parent: "ProcessBlock"},
+		{name: "checkBlockScripts", parent: "checkConnectBlock"},
+		{name: "dbConnectBlock", parent: "ProcessBlock"},
+		{name: "utxoCache.Flush", parent: "ProcessBlock"},
+	}
+	for _, test := range tests {
+		span, ok := byName[test.name]
+		if !ok {
+			t.Errorf("no %s span recorded", test.name)
+			continue
+		}
+		parent := byName[test.parent]
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("%s span is not a child of %s", test.name,
+				test.parent)
+		}
+		if span.SpanContext().TraceID() != root.SpanContext().TraceID() {
+			t.Errorf("%s span is not part of the block trace", test.name)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/wire"
+)
+
+// DefaultUndoWindow is the default number of blocks at the end of the main
+// chain which can be undone, either to reconstruct the unspent transaction
+// output set at a historical height or to invalidate a block.
+const DefaultUndoWindow = 2016
+
+// UndoWindow returns the number of blocks at the end of the main chain which
+// can be undone.  In prune mode it never exceeds the prune depth, since the
+// blocks and spend journals needed to undo deeper blocks have been deleted.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) UndoWindow() int32 {
+	return b.undoWindow
+}
+
+// checkUndoWindow returns an error when the main chain can not be undone back
+// to the passed height because the height does not exist or is outside of the
+// undo window.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) checkUndoWindow(height int32) error {
+	tip := b.bestChain.Tip()
+	if height < 0 || height > tip.height {
+		return fmt.Errorf("height %d is out of range (best height %d)",
+			height, tip.height)
+	}
+	if tip.height-height > b.undoWindow {
+		return fmt.Errorf("height %d is outside of the undo window of %d "+
+			"blocks (best height %d)", height, b.undoWindow, tip.height)
+	}
+	return nil
+}
+
+// utxoViewAtHeight reconstructs the changes to the unspent transaction output
+// set made by the main chain blocks after the passed height by undoing them
+// using their spend journals.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) utxoViewAtHeight(height int32) (*UtxoViewpoint, error) {
+	if err := b.checkUndoWindow(height); err != nil {
+		return nil, err
+	}
+
+	view := NewUtxoViewpoint()
+	err := b.db.View(func(dbTx database.Tx) error {
+		for node := b.bestChain.Tip(); node.height > height; node = node.parent {
+			block, err := dbFetchBlockByNode(dbTx, node)
+			if err != nil {
+				return err
+			}
+			stxos, err := dbFetchSpendJournalEntry(dbTx, block)
+			if err != nil {
+				return err
+			}
+			if err := disconnectTransactions(view, block, stxos); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	view.bestHash = b.bestChain.NodeByHeight(height).hash
+	return view, nil
+}
+
+// UtxoViewAtHeight reconstructs the unspent transaction output set as of the
+// main chain block at the passed height, which must be within the undo window.
+//
+// The returned view only contains the outputs that were created or spent by the
+// main chain blocks after the passed height.  Outputs created by those blocks
+// are marked spent and outputs spent by them are restored.  Any output not in
+// the view was not affected by those blocks, so its state as of the passed
+// height is the same as its current state.
+//
+// This function is safe for concurrent access however the returned view is NOT.
+func (b *BlockChain) UtxoViewAtHeight(height int32) (*UtxoViewpoint, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+	return b.utxoViewAtHeight(height)
+}
+
+// FetchUtxoEntryAtHeight returns the requested unspent transaction output as of
+// the main chain block at the passed height, which must be within the undo
+// window.  Both the entry and the error are nil when the output did not exist
+// or was spent at that height.
+//
+// This undoes all blocks after the passed height, so callers that need to look
+// up many outputs should use UtxoViewAtHeight instead.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) FetchUtxoEntryAtHeight(outpoint wire.OutPoint, height int32) (*UtxoEntry, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	view, err := b.utxoViewAtHeight(height)
+	if err != nil {
+		return nil, err
+	}
+	if entry, ok := view.Entries()[outpoint]; ok {
+		if entry == nil || entry.IsSpent() {
+			return nil, nil
+		}
+		return entry.Clone(), nil
+	}
+	return b.utxoCache.FetchEntry(outpoint)
+}
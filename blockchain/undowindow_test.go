@@ -0,0 +1,86 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/gcash/bchutil"
+)
+
+// TestUtxoViewAtHeight ensures the unspent transaction output set can be
+// reconstructed at historical heights within the undo window.
+func TestUtxoViewAtHeight(t *testing.T) {
+	chain, params, tearDown := utxoCacheTestChain("TestUtxoViewAtHeight")
+	defer tearDown()
+	tip := bchutil.NewBlock(params.GenesisBlock)
+
+	// Create block 1 and spend its outputs in block 2.
+	b1, outs1 := addBlock(chain, tip, nil)
+	b2, outs2 := addBlock(chain, b1, outs1)
+
+	view, err := chain.UtxoViewAtHeight(1)
+	if err != nil {
+		t.Fatalf("UtxoViewAtHeight: unexpected error: %v", err)
+	}
+	if view.bestHash != *b1.Hash() {
+		t.Fatalf("UtxoViewAtHeight: unexpected best hash %v, want %v",
+			view.bestHash, b1.Hash())
+	}
+	for _, out := range outs1 {
+		entry := view.LookupEntry(out.prevOut)
+		if entry == nil || entry.IsSpent() {
+			t.Fatalf("UtxoViewAtHeight: output %v should be unspent",
+				out.prevOut)
+		}
+	}
+	for _, out := range outs2 {
+		entry := view.LookupEntry(out.prevOut)
+		if entry == nil || !entry.IsSpent() {
+			t.Fatalf("UtxoViewAtHeight: output %v should not exist",
+				out.prevOut)
+		}
+	}
+
+	// Individual outputs must reflect the state at the requested height.
+	tests := []struct {
+		out    *spendableOut
+		height int32
+		exists bool
+	}{
+		{out: outs1[0], height: 1, exists: true},
+		{out: outs1[0], height: 2, exists: false},
+		{out: outs2[0], height: 1, exists: false},
+		{out: outs2[0], height: 2, exists: true},
+	}
+	for i, test := range tests {
+		entry, err := chain.FetchUtxoEntryAtHeight(test.out.prevOut,
+			test.height)
+		if err != nil {
+			t.Fatalf("FetchUtxoEntryAtHeight #%d: unexpected error: %v",
+				i, err)
+		}
+		if (entry != nil) != test.exists {
+			t.Fatalf("FetchUtxoEntryAtHeight #%d: got entry %v, want "+
+				"exists %v", i, entry, test.exists)
+		}
+	}
+
+	// Heights outside of the undo window must be rejected.
+	chain.undoWindow = 1
+	for _, height := range []int32{-1, 0, 3} {
+		if _, err := chain.UtxoViewAtHeight(height); err == nil {
+			t.Fatalf("UtxoViewAtHeight: expected error for height %d",
+				height)
+		}
+	}
+	if err := chain.InvalidateBlock(b1.Hash()); err == nil {
+		t.Fatal("InvalidateBlock: expected error for block outside of " +
+			"the undo window")
+	}
+	if err := chain.InvalidateBlock(b2.Hash()); err != nil {
+		t.Fatalf("InvalidateBlock: unexpected error: %v", err)
+	}
+	if chain.BestSnapshot().Hash != *b1.Hash() {
+		t.Fatalf("InvalidateBlock: unexpected best hash %v, want %v",
+			chain.BestSnapshot().Hash, b1.Hash())
+	}
+}
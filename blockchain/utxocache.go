@@ -5,8 +5,10 @@
 package blockchain
 
 import (
+	"bytes"
 	"container/list"
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/gcash/bchd/txscript"
@@ -32,6 +34,27 @@ const (
 	// utxoFlushPeriodicThreshold is the threshold percentage at which a flush is
 	// performed when the flush mode FlushPeriodic is used.
 	utxoFlushPeriodicThreshold = 90
+
+	// utxoDirtyFlushThreshold is the percentage of the maximum cache size
+	// the modified entries may use before they are incrementally written to
+	// the database when the flush mode FlushIfNeeded is used.
+	utxoDirtyFlushThreshold = 50
+
+	// utxoDirtyFlushTarget is the percentage of the maximum cache size the
+	// modified entries are reduced to by an incremental flush.  The gap to
+	// utxoDirtyFlushThreshold keeps incremental flushes from happening for
+	// every handful of new entries.
+	utxoDirtyFlushTarget = 40
+
+	// utxoEvictTarget is the percentage of the maximum cache size the cache
+	// is reduced to by evicting unmodified entries once it exceeds its
+	// maximum size.
+	utxoEvictTarget = 90
+
+	// parallelUtxoFetchThreshold is the minimum number of entries missing
+	// from the cache which are fetched from the database by multiple
+	// goroutines.
+	parallelUtxoFetchThreshold = 256
 )
 
 const (
@@ -42,6 +65,13 @@ const (
 	// This value is calculated by running the following on a 64-bit system:
 	//   unsafe.Sizeof(wire.TokenData{}) assuming commitment of length 40
 	baseUtxoEntryTokenDataSize = 88
+
+	// utxoCacheMapEntrySize is the estimated memory usage in bytes of a single
+	// entry in the map of cached entries on a 64-bit system.  Each map slot
+	// holds a wire.OutPoint key and a pointer value padded to 48 bytes plus a
+	// control byte, and since the map grows by doubling its capacity, it is
+	// on average about two thirds full.
+	utxoCacheMapEntrySize = 75
 )
 
 // txoFlags is a bitmask defining additional information and state for a
@@ -161,7 +191,7 @@ func (entry *UtxoEntry) memoryUsage() uint64 {
 
 	baseEntrySize := uint64(baseUtxoEntrySizeWithoutTokenData + baseUtxoEntryTokenDataSize)
 
-	return baseEntrySize + uint64(len(entry.pkScript))
+	return baseEntrySize + uint64(cap(entry.pkScript))
 }
 
 // Spend marks the output as spent.  Spending an output that is already spent
@@ -238,6 +268,25 @@ type utxoCache struct {
 	totalEntryMemory uint64 // Total memory usage in bytes.
 	lastFlushHash    chainhash.Hash
 
+	// peakEntries is the largest number of entries the map of cached entries
+	// held since it was created.  Maps do not shrink when entries are
+	// deleted, so the memory used by the map itself is based on this value.
+	peakEntries int
+
+	// dirtyEntries and dirtyEntryMemory are the number and the memory usage in
+	// bytes of the cached entries that are marked modified and therefore
+	// still need to be written to the database.
+	dirtyEntries     int
+	dirtyEntryMemory uint64
+
+	// The following fields keep track of the effectiveness of the cache.
+	hits               uint64
+	misses             uint64
+	flushedEntries     uint64
+	evictedEntries     uint64
+	incrementalFlushes uint64
+	fullFlushes        uint64
+
 	// flushInProgress reports whether the cache is currently being flushed
 	flushInProgress bool
 }
@@ -257,16 +306,40 @@ func newUtxoCache(db database.DB, maxTotalMemoryUsage uint64) *utxoCache {
 //
 // This method should be called with the state lock held.
 func (s *utxoCache) totalMemoryUsage() uint64 {
-	// This value is calculated by running the following on a 64-bit system:
-	// unsafe.Sizeof(wire.OutPoint{})
-	outpointSize := uint64(36)
+	// Total size is the size of the map holding the keys and the pointers
+	// plus the total size of the elements held in the pointers.
+	return uint64(s.peakEntries)*utxoCacheMapEntrySize + s.totalEntryMemory
+}
 
-	// Total memory is all the keys plus the total memory of all the entries.
-	nbEntries := uint64(len(s.cachedEntries))
+// trackEntry accounts for the memory usage of the passed entry, which has been
+// stored in the cache.  The entry must not be changed until untrackEntry is
+// called for it.
+//
+// This method should be called with the state lock held.
+func (s *utxoCache) trackEntry(entry *UtxoEntry) {
+	memUsage := entry.memoryUsage()
+	s.totalEntryMemory += memUsage
+	if entry != nil && entry.isModified() {
+		s.dirtyEntries++
+		s.dirtyEntryMemory += memUsage
+	}
+	if len(s.cachedEntries) > s.peakEntries {
+		s.peakEntries = len(s.cachedEntries)
+	}
+}
 
-	// Total size is total size of the keys + total size of the pointers in the
-	// map + total size of the elements held in the pointers.
-	return nbEntries*outpointSize + nbEntries*8 + s.totalEntryMemory
+// untrackEntry removes the memory usage of the passed entry from the
+// accounting of the cache.  It must be called before the entry is changed or
+// removed from the cache.
+//
+// This method should be called with the state lock held.
+func (s *utxoCache) untrackEntry(entry *UtxoEntry) {
+	memUsage := entry.memoryUsage()
+	s.totalEntryMemory -= memUsage
+	if entry != nil && entry.isModified() {
+		s.dirtyEntries--
+		s.dirtyEntryMemory -= memUsage
+	}
 }
 
 // TotalMemoryUsage returns the total memory usage in bytes of the UTXO cache.
@@ -298,7 +371,8 @@ func (s *utxoCache) fetchAndCacheEntry(outpoint wire.OutPoint) (*UtxoEntry, erro
 	// NOTE: When the fetched entry is nil, it is still added to the cache as a
 	// miss; this prevents future lookups to perform the same database fetch.
 	s.cachedEntries[outpoint] = entry
-	s.totalEntryMemory += entry.memoryUsage()
+	s.trackEntry(entry)
+	s.misses++
 
 	return entry, nil
 }
@@ -311,12 +385,108 @@ func (s *utxoCache) fetchAndCacheEntry(outpoint wire.OutPoint) (*UtxoEntry, erro
 // The returned entry is NOT safe for concurrent access.
 func (s *utxoCache) getEntry(outpoint wire.OutPoint) (*UtxoEntry, error) {
 	if entry, found := s.cachedEntries[outpoint]; found {
+		s.hits++
 		return entry, nil
 	}
 
 	return s.fetchAndCacheEntry(outpoint)
 }
 
+// outpointKeyLess returns whether or not the database key of outpoint a sorts
+// before the one of outpoint b.  Since the index is serialized as a VLQ, the
+// keys sort by the hash and then by the index.
+func outpointKeyLess(a, b *wire.OutPoint) bool {
+	if cmp := bytes.Compare(a.Hash[:], b.Hash[:]); cmp != 0 {
+		return cmp < 0
+	}
+	return a.Index < b.Index
+}
+
+// fetchAndCacheEntries fetches the entries for the passed outpoints from the
+// database and caches them like fetchAndCacheEntry.  The outpoints must be
+// distinct and not be in the cache.  They are sorted by their database keys and
+// split into consecutive ranges which are read by multiple goroutines when
+// there are enough of them, so every goroutine accesses its keys in order.
+// The returned entries correspond to the sorted outpoints.
+//
+// This method should be called with the state lock held.
+func (s *utxoCache) fetchAndCacheEntries(outpoints []wire.OutPoint) ([]*UtxoEntry, error) {
+	sort.Slice(outpoints, func(i, j int) bool {
+		return outpointKeyLess(&outpoints[i], &outpoints[j])
+	})
+
+	entries := make([]*UtxoEntry, len(outpoints))
+	var errMtx sync.Mutex
+	var fetchErr error
+	parallelize(len(outpoints), parallelUtxoFetchThreshold,
+		func(start, end int) {
+			err := s.db.View(func(dbTx database.Tx) error {
+				for i := start; i < end; i++ {
+					entry, err := dbFetchUtxoEntry(dbTx,
+						outpoints[i])
+					if err != nil {
+						return err
+					}
+					entries[i] = entry
+				}
+				return nil
+			})
+			if err != nil {
+				errMtx.Lock()
+				if fetchErr == nil {
+					fetchErr = err
+				}
+				errMtx.Unlock()
+			}
+		})
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+
+	// Add the entries to the memory cache, including the ones which were
+	// not found to prevent repeated lookups.
+	for i, outpoint := range outpoints {
+		s.cachedEntries[outpoint] = entries[i]
+		s.trackEntry(entries[i])
+	}
+	s.misses += uint64(len(outpoints))
+
+	return entries, nil
+}
+
+// getEntries returns the UTXO entries for the given distinct outpoints in the
+// same order, with nil for the outpoints which have no entry in the UTXO state.
+// The entries which are not cached are fetched from the database in a batch.
+//
+// This method should be called with the state lock held.
+// The returned entries are NOT safe for concurrent access.
+func (s *utxoCache) getEntries(outpoints []wire.OutPoint) ([]*UtxoEntry, error) {
+	entries := make([]*UtxoEntry, len(outpoints))
+	var missing []wire.OutPoint
+	missingIdx := make(map[wire.OutPoint]int)
+	for i, outpoint := range outpoints {
+		if entry, found := s.cachedEntries[outpoint]; found {
+			s.hits++
+			entries[i] = entry
+			continue
+		}
+		missing = append(missing, outpoint)
+		missingIdx[outpoint] = i
+	}
+	if len(missing) == 0 {
+		return entries, nil
+	}
+
+	fetched, err := s.fetchAndCacheEntries(missing)
+	if err != nil {
+		return nil, err
+	}
+	for i, outpoint := range missing {
+		entries[missingIdx[outpoint]] = fetched[i]
+	}
+	return entries, nil
+}
+
 // FetchEntry returns the UTXO entry for the given outpoint.  It returns nil if
 // there is no entry for the outpoint in the UTXO state.
 //
@@ -357,7 +527,7 @@ func (s *utxoCache) spendEntry(outpoint wire.OutPoint, addIfNil *UtxoEntry) erro
 		if err := s.addEntry(outpoint, addIfNil, false); err != nil {
 			return err
 		}
-		entry = addIfNil
+		entry = s.cachedEntries[outpoint]
 	}
 
 	// If it's nil or already spent, nothing to do.
@@ -371,19 +541,19 @@ func (s *utxoCache) spendEntry(outpoint wire.OutPoint, addIfNil *UtxoEntry) erro
 		// We don't delete it from the map, but set the value to nil, so that
 		// later lookups for the entry know that the entry does not exist in the
 		// database.
+		s.untrackEntry(entry)
 		s.cachedEntries[outpoint] = nil
-		s.totalEntryMemory -= entry.memoryUsage()
 		return nil
 	}
 
 	// Mark the output as spent and modified.
+	s.untrackEntry(entry)
 	entry.packedFlags |= tfSpent | tfModified
 
 	//TODO(stevenroose) check if it's ok to drop the pkScript
 	// Since we don't need it anymore, drop the pkScript value of the entry.
-	s.totalEntryMemory -= entry.memoryUsage()
 	entry.pkScript = nil
-	s.totalEntryMemory += entry.memoryUsage()
+	s.trackEntry(entry)
 
 	return nil
 }
@@ -432,10 +602,10 @@ func (s *utxoCache) addEntry(outpoint wire.OutPoint, entry *UtxoEntry, overwrite
 		}
 	}
 
+	s.untrackEntry(cachedEntry)
 	entry.packedFlags |= tfModified
 	s.cachedEntries[outpoint] = entry
-	s.totalEntryMemory -= cachedEntry.memoryUsage() // 0 for nil
-	s.totalEntryMemory += entry.memoryUsage()
+	s.trackEntry(entry)
 	return nil
 }
 
@@ -528,7 +698,121 @@ func (s *utxoCache) Commit(view *UtxoViewpoint) error {
 	return nil
 }
 
-// flush flushes the UTXO state to the database.
+// writeDirtyEntries uses an existing database transaction to write modified
+// entries to the database until either the passed maximum number of entries
+// has been written or the memory usage of the modified entries no longer
+// exceeds the passed target.  Written entries that are unspent stay in the
+// cache as unmodified entries so they keep serving lookups, while spent entries
+// are removed from the cache.
+//
+// This method should be called with the state lock held.
+func (s *utxoCache) writeDirtyEntries(dbTx database.Tx, maxEntries int, targetMemory uint64) error {
+	var (
+		// Form a batch by storing all entries to be put and deleted.
+		nbBatchEntries = 0
+		entriesPut     = make(map[wire.OutPoint]*UtxoEntry)
+		entriesDelete  = make([]wire.OutPoint, 0)
+	)
+	for outpoint, entry := range s.cachedEntries {
+		// End this batch when the maximum number of entries per batch has
+		// been reached or enough entries have been written.
+		if nbBatchEntries >= maxEntries || s.dirtyEntryMemory <= targetMemory {
+			break
+		}
+
+		// Unmodified entries don't need to be written.
+		if entry == nil || !entry.isModified() {
+			continue
+		}
+
+		s.untrackEntry(entry)
+		if entry.IsSpent() {
+			entriesDelete = append(entriesDelete, outpoint)
+			delete(s.cachedEntries, outpoint)
+		} else {
+			// The entry is known to the database from now on, so it
+			// is neither modified nor fresh anymore.
+			entriesPut[outpoint] = entry
+			entry.packedFlags &^= tfModified | tfFresh
+			s.trackEntry(entry)
+		}
+		nbBatchEntries++
+	}
+	s.flushedEntries += uint64(nbBatchEntries)
+
+	// Apply the batched additions and deletions.
+	if err := dbPutUtxoEntries(dbTx, entriesPut); err != nil {
+		return err
+	}
+
+	return dbDeleteUtxoEntries(dbTx, entriesDelete)
+}
+
+// flushDirty incrementally writes modified entries to the database until their
+// memory usage no longer exceeds the passed target.  The consistency status is
+// left at the last flush hash while entries of later blocks are being written,
+// which allows the state to be recovered in the same way as after an
+// interrupted full flush.  Once all modified entries have been written, the
+// state is consistent up to the given best state.
+//
+// This method should be called with the state lock held.
+func (s *utxoCache) flushDirty(bestState *BestState, targetMemory uint64) error {
+	log.Debugf("Writing ~%v MiB of modified UTXO cache entries to disk",
+		(s.dirtyEntryMemory-targetMemory)/(1024*1024)+1)
+
+	s.flushInProgress = true
+	defer func() { s.flushInProgress = false }()
+	for s.dirtyEntries > 0 && s.dirtyEntryMemory > targetMemory {
+		err := s.db.Update(func(dbTx database.Tx) error {
+			err := dbPutUtxoStateConsistency(dbTx, ucsFlushOngoing,
+				&s.lastFlushHash)
+			if err != nil {
+				return err
+			}
+			err = s.writeDirtyEntries(dbTx, utxoBatchSizeEntries, targetMemory)
+			if err != nil {
+				return err
+			}
+			if s.dirtyEntries > 0 {
+				return nil
+			}
+			return dbPutUtxoStateConsistency(dbTx, ucsConsistent,
+				&bestState.Hash)
+		})
+		if err != nil {
+			return err
+		}
+		if s.dirtyEntries == 0 {
+			s.lastFlushHash = bestState.Hash
+		}
+	}
+	s.incrementalFlushes++
+	return nil
+}
+
+// evictCleanEntries removes unmodified entries from the cache until its total
+// memory usage no longer exceeds the passed target.  Since these entries match
+// the database, evicting them does not require any database writes.  It
+// returns whether the target was reached.
+//
+// This method should be called with the state lock held.
+func (s *utxoCache) evictCleanEntries(targetMemory uint64) bool {
+	for outpoint, entry := range s.cachedEntries {
+		if s.totalMemoryUsage() <= targetMemory {
+			break
+		}
+		if entry != nil && entry.isModified() {
+			continue
+		}
+
+		s.untrackEntry(entry)
+		delete(s.cachedEntries, outpoint)
+		s.evictedEntries++
+	}
+	return s.totalMemoryUsage() <= targetMemory
+}
+
+// flush flushes the UTXO state to the database and empties the cache.
 //
 // This method should be called with the state lock held.
 func (s *utxoCache) flush(bestState *BestState) error {
@@ -541,9 +825,9 @@ func (s *utxoCache) flush(bestState *BestState) error {
 	}
 
 	// Add one to round up the integer division.
-	totalMiB := s.totalMemoryUsage()/(1024*1024) + 1
-	log.Infof("Flushing UTXO cache of ~%v MiB to disk. For large sizes, "+
-		"this can take up to several minutes...", totalMiB)
+	dirtyMiB := s.dirtyEntryMemory/(1024*1024) + 1
+	log.Infof("Flushing UTXO cache with ~%v MiB of modified entries to disk. "+
+		"For large sizes, this can take up to several minutes...", dirtyMiB)
 
 	// First update the database to indicate that a utxo state flush is started.
 	// This allows us to recover when the node shuts down in the middle of this
@@ -555,53 +839,13 @@ func (s *utxoCache) flush(bestState *BestState) error {
 		return err
 	}
 
-	// Store all entries in batches.
-	flushBatch := func(dbTx database.Tx) error {
-		var (
-			// Form a batch by storing all entries to be put and deleted.
-			nbBatchEntries = 0
-			entriesPut     = make(map[wire.OutPoint]*UtxoEntry)
-			entriesDelete  = make([]wire.OutPoint, 0)
-		)
-		for outpoint, entry := range s.cachedEntries {
-			// Nil entries or unmodified entries can just be pruned.
-			// They don't count for the batch size.
-			if entry == nil || !entry.isModified() {
-				s.totalEntryMemory -= entry.memoryUsage()
-				delete(s.cachedEntries, outpoint)
-				continue
-			}
-
-			if entry.IsSpent() {
-				entriesDelete = append(entriesDelete, outpoint)
-			} else {
-				entriesPut[outpoint] = entry
-			}
-			nbBatchEntries++
-
-			s.totalEntryMemory -= entry.memoryUsage()
-			delete(s.cachedEntries, outpoint)
-
-			// End this batch when the maximum number of entries per batch has
-			// been reached.
-			if nbBatchEntries >= utxoBatchSizeEntries {
-				break
-			}
-		}
-
-		// Apply the batched additions and deletions.
-		if err := dbPutUtxoEntries(dbTx, entriesPut); err != nil {
-			return err
-		}
-
-		return dbDeleteUtxoEntries(dbTx, entriesDelete)
-	}
+	// Store all modified entries in batches.
 	s.flushInProgress = true
 	defer func() { s.flushInProgress = false }()
-	for len(s.cachedEntries) > 0 {
-		log.Tracef("Flushing %d more entries...", len(s.cachedEntries))
+	for s.dirtyEntries > 0 {
+		log.Tracef("Flushing %d more entries...", s.dirtyEntries)
 		err := s.db.Update(func(dbTx database.Tx) error {
-			return flushBatch(dbTx)
+			return s.writeDirtyEntries(dbTx, utxoBatchSizeEntries, 0)
 		})
 		if err != nil {
 			return err
@@ -617,12 +861,28 @@ func (s *utxoCache) flush(bestState *BestState) error {
 		return err
 	}
 	s.lastFlushHash = bestState.Hash
+
+	// All remaining entries match the database now.  The map is recreated
+	// rather than emptied since maps never release their memory.
+	s.evictedEntries += uint64(len(s.cachedEntries))
+	s.cachedEntries = make(map[wire.OutPoint]*UtxoEntry)
+	s.totalEntryMemory = 0
+	s.peakEntries = 0
+	s.fullFlushes++
+
 	log.Debug("Done flushing UTXO cache to disk")
 	return nil
 }
 
 // Flush flushes the UTXO state to the database.
 //
+// The cache is tiered in order to avoid long stalls caused by writing the
+// entire cache at once.  With the flush mode FlushIfNeeded, modified entries
+// are written incrementally once they use more than utxoDirtyFlushThreshold
+// percent of the maximum cache size, and unmodified entries are evicted once
+// the cache exceeds its maximum size.  A full flush only happens when that is
+// not enough to bring the cache back under its maximum size.
+//
 // This function is safe for concurrent access.
 func (s *utxoCache) Flush(mode FlushMode, bestState *BestState) error {
 	s.mtx.Lock()
@@ -634,6 +894,19 @@ func (s *utxoCache) Flush(mode FlushMode, bestState *BestState) error {
 		threshold = 0
 
 	case FlushIfNeeded:
+		dirtyThreshold := (utxoDirtyFlushThreshold * s.maxTotalMemoryUsage) / 100
+		if s.dirtyEntryMemory > dirtyThreshold {
+			target := (utxoDirtyFlushTarget * s.maxTotalMemoryUsage) / 100
+			if err := s.flushDirty(bestState, target); err != nil {
+				return err
+			}
+		}
+		if s.totalMemoryUsage() > s.maxTotalMemoryUsage {
+			target := (utxoEvictTarget * s.maxTotalMemoryUsage) / 100
+			if s.evictCleanEntries(target) {
+				return nil
+			}
+		}
 		threshold = s.maxTotalMemoryUsage
 
 	case FlushPeriodic:
@@ -646,6 +919,85 @@ func (s *utxoCache) Flush(mode FlushMode, bestState *BestState) error {
 	return nil
 }
 
+// UtxoCacheStats houses statistics about the UTXO cache.
+type UtxoCacheStats struct {
+	// Entries is the number of cached entries including the entries which
+	// are known to not exist in the database.
+	Entries int
+
+	// DirtyEntries is the number of cached entries that have been modified
+	// and still need to be written to the database.
+	DirtyEntries int
+
+	// TotalMemory is the estimated memory usage of the cache in bytes.
+	TotalMemory uint64
+
+	// DirtyMemory is the estimated memory usage of the modified entries in
+	// bytes.
+	DirtyMemory uint64
+
+	// MaxMemory is the maximum memory usage of the cache in bytes.
+	MaxMemory uint64
+
+	// Hits and Misses are the number of lookups which were served from the
+	// cache and from the database respectively.
+	Hits   uint64
+	Misses uint64
+
+	// FlushedEntries is the number of entries written to or deleted from
+	// the database.
+	FlushedEntries uint64
+
+	// EvictedEntries is the number of unmodified entries removed from the
+	// cache.
+	EvictedEntries uint64
+
+	// IncrementalFlushes and FullFlushes are the number of incremental
+	// writes of modified entries and full flushes of the cache respectively.
+	IncrementalFlushes uint64
+	FullFlushes        uint64
+}
+
+// HitRate returns the fraction of the lookups which were served from the cache.
+func (stats *UtxoCacheStats) HitRate() float64 {
+	lookups := stats.Hits + stats.Misses
+	if lookups == 0 {
+		return 0
+	}
+	return float64(stats.Hits) / float64(lookups)
+}
+
+// Stats returns statistics about the UTXO cache.
+//
+// This function is safe for concurrent access.
+func (s *utxoCache) Stats() UtxoCacheStats {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return UtxoCacheStats{
+		Entries:            len(s.cachedEntries),
+		DirtyEntries:       s.dirtyEntries,
+		TotalMemory:        s.totalMemoryUsage(),
+		DirtyMemory:        s.dirtyEntryMemory,
+		MaxMemory:          s.maxTotalMemoryUsage,
+		Hits:               s.hits,
+		Misses:             s.misses,
+		FlushedEntries:     s.flushedEntries,
+		EvictedEntries:     s.evictedEntries,
+		IncrementalFlushes: s.incrementalFlushes,
+		FullFlushes:        s.fullFlushes,
+	}
+}
+
+// UtxoCacheStats returns statistics about the UTXO cache such as its memory
+// usage and hit rate.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) UtxoCacheStats() UtxoCacheStats {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+	return b.utxoCache.Stats()
+}
+
 // rollBackBlock rolls back the effects of the block when the state was left in
 // an inconsistent state.  This means that no errors will be raised when the
 // state is invalid.
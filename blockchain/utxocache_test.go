@@ -611,3 +611,202 @@ func TestUtxoCache_InitConsistentState(t *testing.T) {
 		assertNbEntriesOnDisk(t, chain, len(spendableOuts4))
 	})
 }
+
+// assertDirtyAccounting asserts that the tracked number and memory usage of the
+// modified entries in the cache match the cached entries.
+func assertDirtyAccounting(t *testing.T, cache *utxoCache) {
+	var nbDirty int
+	var dirtyMemory, totalMemory uint64
+	for _, entry := range cache.cachedEntries {
+		totalMemory += entry.memoryUsage()
+		if entry != nil && entry.isModified() {
+			nbDirty++
+			dirtyMemory += entry.memoryUsage()
+		}
+	}
+	if cache.dirtyEntries != nbDirty {
+		t.Fatalf("Expected %d dirty entries, tracked %d instead", nbDirty,
+			cache.dirtyEntries)
+	}
+	if cache.dirtyEntryMemory != dirtyMemory {
+		t.Fatalf("Expected %d bytes of dirty entries, tracked %d instead",
+			dirtyMemory, cache.dirtyEntryMemory)
+	}
+	if cache.totalEntryMemory != totalMemory {
+		t.Fatalf("Expected %d bytes of entries, tracked %d instead",
+			totalMemory, cache.totalEntryMemory)
+	}
+}
+
+func TestUtxoCache_IncrementalFlush(t *testing.T) {
+	chain, params, tearDown := utxoCacheTestChain("TestUtxoCache_IncrementalFlush")
+	defer tearDown()
+	cache := chain.utxoCache
+	tip := bchutil.NewBlock(params.GenesisBlock)
+
+	// Add 10 utxos and spend some of them without flushing.
+	var outs []*spendableOut
+	for i := 0; i < 10; i++ {
+		tip, outs = addBlock(chain, tip, nil)
+	}
+	tip, _ = addBlock(chain, tip, outs)
+	assertDirtyAccounting(t, cache)
+	var nbUnspent int
+	for outpoint, entry := range cache.cachedEntries {
+		if entry == nil {
+			continue
+		}
+		if !entry.isModified() {
+			t.Fatalf("Entry %v should be marked modified", outpoint)
+		}
+		if !entry.IsSpent() {
+			nbUnspent++
+		}
+	}
+
+	// Set the limit such that the dirty entries exceed the incremental flush
+	// threshold while the cache as a whole is within its limit.
+	dirtyMemory := cache.dirtyEntryMemory
+	cache.maxTotalMemoryUsage = dirtyMemory*100/utxoDirtyFlushThreshold - 1
+	if cache.totalMemoryUsage() > cache.maxTotalMemoryUsage {
+		t.Fatalf("Cache of %d bytes unexpectedly exceeds limit of %d bytes",
+			cache.totalMemoryUsage(), cache.maxTotalMemoryUsage)
+	}
+	nbEntries := len(cache.cachedEntries)
+	if err := cache.Flush(FlushIfNeeded, chain.BestSnapshot()); err != nil {
+		t.Fatalf("unexpected error while flushing cache: %v", err)
+	}
+	assertDirtyAccounting(t, cache)
+
+	// Some of the entries should have been written while all unspent ones
+	// stay cached.
+	target := utxoDirtyFlushTarget * cache.maxTotalMemoryUsage / 100
+	if cache.dirtyEntryMemory > target {
+		t.Fatalf("Expected at most %d bytes of dirty entries, has %d",
+			target, cache.dirtyEntryMemory)
+	}
+	stats := cache.Stats()
+	if stats.FlushedEntries == 0 || stats.IncrementalFlushes != 1 {
+		t.Fatalf("Expected one incremental flush, got %v", spew.Sdump(stats))
+	}
+	if stats.EvictedEntries != 0 || stats.FullFlushes != 0 {
+		t.Fatalf("Expected no evictions, got %v", spew.Sdump(stats))
+	}
+	if len(cache.cachedEntries) < nbEntries-int(stats.FlushedEntries) {
+		t.Fatalf("Expected written unspent entries to stay cached")
+	}
+	if cache.dirtyEntries > 0 {
+		assertConsistencyState(t, chain, ucsFlushOngoing, params.GenesisHash)
+	}
+
+	// Writing out all dirty entries makes the state consistent and lets the
+	// unmodified entries be evicted without a full flush.
+	cache.maxTotalMemoryUsage = 0
+	if err := cache.Flush(FlushIfNeeded, chain.BestSnapshot()); err != nil {
+		t.Fatalf("unexpected error while flushing cache: %v", err)
+	}
+	assertDirtyAccounting(t, cache)
+	assertConsistencyState(t, chain, ucsConsistent, tip.Hash())
+	assertNbEntriesOnDisk(t, chain, nbUnspent)
+	if len(cache.cachedEntries) != 0 {
+		t.Fatalf("Expected 0 entries, has %d instead", len(cache.cachedEntries))
+	}
+	stats = cache.Stats()
+	if stats.IncrementalFlushes != 2 || stats.FullFlushes != 0 {
+		t.Fatalf("Expected two incremental flushes, got %v", spew.Sdump(stats))
+	}
+
+	// Lookups of the now evicted entry miss the cache once.
+	misses, hits := stats.Misses, stats.Hits
+	chain.utxoCache.maxTotalMemoryUsage = 10 * 1024 * 1024
+	outpoint := wire.OutPoint{Hash: *tip.Transactions()[0].Hash()}
+	for i := 0; i < 2; i++ {
+		entry, err := chain.FetchUtxoEntry(outpoint)
+		if err != nil {
+			t.Fatalf("unexpected error fetching utxo: %v", err)
+		}
+		if entry == nil {
+			t.Fatalf("expected utxo %v to exist", outpoint)
+		}
+	}
+	stats = chain.UtxoCacheStats()
+	if stats.Misses != misses+1 || stats.Hits != hits+1 {
+		t.Fatalf("Expected one miss and one hit, got %v", spew.Sdump(stats))
+	}
+}
+
+func TestUtxoCache_GetEntries(t *testing.T) {
+	chain, _, tearDown := utxoCacheTestChain("TestUtxoCache_GetEntries")
+	defer tearDown()
+	cache := chain.utxoCache
+
+	// Write enough entries to the database for them to be fetched by
+	// multiple goroutines.
+	numEntries := parallelUtxoFetchThreshold + 10
+	outpoints := make([]wire.OutPoint, 0, numEntries+1)
+	for i := 0; i < numEntries; i++ {
+		outpoint := wire.OutPoint{Index: uint32(i % 3)}
+		binary.LittleEndian.PutUint32(outpoint.Hash[:], uint32(i/3))
+		txOut := wire.NewTxOut(int64(i+1), opTrueScript, wire.TokenData{})
+		err := cache.addEntry(outpoint, NewUtxoEntry(txOut, 1, false), false)
+		if err != nil {
+			t.Fatalf("unexpected error adding entry: %v", err)
+		}
+		outpoints = append(outpoints, outpoint)
+	}
+	maxMemory := cache.maxTotalMemoryUsage
+	cache.maxTotalMemoryUsage = 0
+	if err := cache.Flush(FlushIfNeeded, chain.BestSnapshot()); err != nil {
+		t.Fatalf("unexpected error while flushing cache: %v", err)
+	}
+	cache.maxTotalMemoryUsage = maxMemory
+	assertNbEntriesOnDisk(t, chain, numEntries)
+	if len(cache.cachedEntries) != 0 {
+		t.Fatalf("Expected 0 entries, has %d instead", len(cache.cachedEntries))
+	}
+
+	// Cache one of the entries and request an outpoint without an entry
+	// along with the others in reverse order.
+	if _, err := cache.getEntry(outpoints[5]); err != nil {
+		t.Fatalf("unexpected error fetching entry: %v", err)
+	}
+	outpoints = append(outpoints, wire.OutPoint{Index: 7})
+	for i, j := 0, len(outpoints)-1; i < j; i, j = i+1, j-1 {
+		outpoints[i], outpoints[j] = outpoints[j], outpoints[i]
+	}
+	stats := cache.Stats()
+	entries, err := cache.getEntries(outpoints)
+	if err != nil {
+		t.Fatalf("unexpected error fetching entries: %v", err)
+	}
+	if entries[0] != nil {
+		t.Fatalf("Expected no entry for %v", outpoints[0])
+	}
+	for i, entry := range entries[1:] {
+		want := int64(numEntries - i)
+		if entry == nil || entry.Amount() != want {
+			t.Fatalf("Expected entry for %v with amount %d, got %v",
+				outpoints[i+1], want, spew.Sdump(entry))
+		}
+	}
+	assertDirtyAccounting(t, cache)
+	newStats := cache.Stats()
+	if newStats.Hits != stats.Hits+1 ||
+		newStats.Misses != stats.Misses+uint64(numEntries) {
+
+		t.Fatalf("Expected 1 hit and %d misses, got %v", numEntries,
+			spew.Sdump(newStats))
+	}
+
+	// All entries are cached now, including the missing one.
+	if len(cache.cachedEntries) != len(outpoints) {
+		t.Fatalf("Expected %d entries, has %d instead", len(outpoints),
+			len(cache.cachedEntries))
+	}
+	if _, err := cache.getEntries(outpoints); err != nil {
+		t.Fatalf("unexpected error fetching entries: %v", err)
+	}
+	if stats := cache.Stats(); stats.Misses != newStats.Misses {
+		t.Fatalf("Expected no misses, got %v", spew.Sdump(stats))
+	}
+}
@@ -0,0 +1,398 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/binary"
+	"math/big"
+	"runtime"
+
+	"github.com/gcash/bchd/bchec"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+var (
+	// utxoStatsKeyName is the name of the db key used to store the
+	// incrementally maintained statistics of the unspent transaction output
+	// set.
+	utxoStatsKeyName = []byte("utxostats")
+
+	// block91722Hash and block91812Hash are the blocks whose coinbase
+	// outputs were overwritten by the duplicate coinbase transactions in
+	// blocks 91842 and 91880.  Their outputs are not part of the unspent
+	// transaction output set.
+	block91722Hash = newHashFromStr("00000000000271a2dc26e7667f8419f2e15416dc6955e5a6c6cdf3f2574dd08e")
+	block91812Hash = newHashFromStr("00000000000af0aed4792b1acee3d966af36cf5def14935db8de83d6f9306f2f")
+)
+
+// serializedUtxoStatsLen is the length of serialized utxo statistics.  It is
+// the block hash, height, number of utxos, total amount, serialized size and
+// the x and y coordinates of the multiset.
+const serializedUtxoStatsLen = chainhash.HashSize + 4 + 8 + 8 + 8 + 32 + 32
+
+// UtxoStats houses statistics about the unspent transaction output set at the
+// block with the given hash and height.
+type UtxoStats struct {
+	// Height and Hash identify the block the statistics are for.
+	Height int32
+	Hash   chainhash.Hash
+
+	// Utxos is the number of unspent transaction outputs.
+	Utxos uint64
+
+	// TotalAmount is the total amount of all unspent transaction outputs
+	// in satoshi.
+	TotalAmount int64
+
+	// SerializedSize is the size in bytes of all unspent transaction
+	// outputs serialized in the utxo commitment format.
+	SerializedSize uint64
+
+	// Commitment is the ECMH hash of all unspent transaction outputs
+	// serialized in the utxo commitment format, as used by the utxo set
+	// hashes of checkpoints.
+	Commitment chainhash.Hash
+}
+
+// utxoStats tracks the statistics of the unspent transaction output set.
+// Since the multiset hash of a set does not depend on the order of its
+// elements, the statistics can be updated incrementally as blocks are
+// connected and disconnected.
+type utxoStats struct {
+	hash           chainhash.Hash
+	height         int32
+	utxos          uint64
+	totalAmount    int64
+	serializedSize uint64
+	multiset       *bchec.Multiset
+}
+
+// newUtxoStats returns statistics of an empty unspent transaction output set.
+func newUtxoStats() *utxoStats {
+	return &utxoStats{multiset: bchec.NewMultiset(bchec.S256())}
+}
+
+// clone returns a deep copy of the statistics.
+func (s *utxoStats) clone() *utxoStats {
+	c := *s
+	x, y := s.multiset.Point()
+	c.multiset = bchec.NewMultisetFromPoint(bchec.S256(), x, y)
+	return &c
+}
+
+// stats returns the exported form of the statistics.
+func (s *utxoStats) stats() *UtxoStats {
+	return &UtxoStats{
+		Height:         s.height,
+		Hash:           s.hash,
+		Utxos:          s.utxos,
+		TotalAmount:    s.totalAmount,
+		SerializedSize: s.serializedSize,
+		Commitment:     s.multiset.Hash(),
+	}
+}
+
+// add adds the passed utxo serialized in the commitment format to the
+// statistics.
+func (s *utxoStats) add(serialized []byte, amount int64) {
+	s.utxos++
+	s.totalAmount += amount
+	s.serializedSize += uint64(len(serialized))
+	s.multiset.Add(serialized)
+}
+
+// remove removes the passed utxo serialized in the commitment format from the
+// statistics.
+func (s *utxoStats) remove(serialized []byte, amount int64) {
+	s.utxos--
+	s.totalAmount -= amount
+	s.serializedSize -= uint64(len(serialized))
+	s.multiset.Remove(serialized)
+}
+
+// isBIP30Unspendable returns whether the coinbase outputs of the passed block
+// were overwritten by a later duplicate coinbase transaction.
+func isBIP30Unspendable(node *blockNode) bool {
+	return (node.height == 91722 && node.hash.IsEqual(block91722Hash)) ||
+		(node.height == 91812 && node.hash.IsEqual(block91812Hash))
+}
+
+// createdUtxos calls the passed function with every output the passed block
+// adds to the unspent transaction output set, serialized in the commitment
+// format.
+func createdUtxos(node *blockNode, block *bchutil.Block, fn func([]byte, int64)) {
+	for i, tx := range block.Transactions() {
+		if i == 0 && isBIP30Unspendable(node) {
+			continue
+		}
+		outpoint := wire.OutPoint{Hash: *tx.Hash()}
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			// Provably unspendable outputs are never added to the
+			// utxo set.
+			if txscript.IsUnspendable(txOut.PkScript) {
+				continue
+			}
+			pkScript := txOut.PkScript
+			if !txOut.TokenData.IsEmpty() {
+				buf := txOut.TokenData.TokenDataBuffer()
+				buf.Write(pkScript)
+				pkScript = buf.Bytes()
+			}
+			outpoint.Index = uint32(txOutIdx)
+			fn(serializeUtxoCommitmentFormat(&outpoint, txOut.Value,
+				pkScript, node.height, i == 0), txOut.Value)
+		}
+	}
+}
+
+// spentUtxos calls the passed function with every output the passed block
+// spends, serialized in the commitment format.
+func spentUtxos(block *bchutil.Block, stxos []SpentTxOut, fn func([]byte, int64)) {
+	var stxoIdx int
+	for _, tx := range block.Transactions()[1:] {
+		for _, txIn := range tx.MsgTx().TxIn {
+			stxo := &stxos[stxoIdx]
+			stxoIdx++
+			fn(serializeUtxoCommitmentFormat(&txIn.PreviousOutPoint,
+				stxo.Amount, stxo.PkScript, stxo.Height,
+				stxo.IsCoinBase), stxo.Amount)
+		}
+	}
+}
+
+// connectBlock returns the statistics after connecting the passed block.
+//
+// Outputs are added before spent outputs are removed since removing an
+// element from an empty multiset has no effect.
+func (s *utxoStats) connectBlock(node *blockNode, block *bchutil.Block, stxos []SpentTxOut) *utxoStats {
+	c := s.clone()
+	createdUtxos(node, block, c.add)
+	spentUtxos(block, stxos, c.remove)
+	c.hash = node.hash
+	c.height = node.height
+	return c
+}
+
+// disconnectBlock returns the statistics after disconnecting the passed block.
+func (s *utxoStats) disconnectBlock(node *blockNode, block *bchutil.Block, stxos []SpentTxOut) *utxoStats {
+	c := s.clone()
+	spentUtxos(block, stxos, c.add)
+	createdUtxos(node, block, c.remove)
+	c.hash = node.parent.hash
+	c.height = node.parent.height
+	return c
+}
+
+// calcUtxoStats calculates the statistics of the unspent transaction output
+// set stored in the database by scanning all of its entries.  The entries are
+// hashed onto the multiset by multiple workers since that is by far the most
+// expensive part.
+func calcUtxoStats(dbTx database.Tx, interrupt <-chan struct{}) (*utxoStats, error) {
+	numWorkers := runtime.NumCPU()
+	jobs := make(chan []byte, numWorkers*16)
+	results := make(chan *bchec.Multiset)
+	for i := 0; i < numWorkers; i++ {
+		go func() {
+			m := bchec.NewMultiset(bchec.S256())
+			for serialized := range jobs {
+				m.Add(serialized)
+			}
+			results <- m
+		}()
+	}
+
+	stats := newUtxoStats()
+	bucket := dbTx.Metadata().Bucket(utxoSetBucketName)
+	err := bucket.ForEach(func(k, v []byte) error {
+		if interruptRequested(interrupt) {
+			return errInterruptRequested
+		}
+		entry, err := DeserializeUtxoEntry(v)
+		if err != nil {
+			return err
+		}
+		pkScript := entry.PkScript()
+		if !entry.tokenData.IsEmpty() {
+			buf := entry.tokenData.TokenDataBuffer()
+			buf.Write(pkScript)
+			pkScript = buf.Bytes()
+		}
+		serialized := serializeUtxoCommitmentFormat(
+			DeserializeOutpointKey(k), entry.Amount(), pkScript,
+			entry.BlockHeight(), entry.IsCoinBase())
+		stats.utxos++
+		stats.totalAmount += entry.Amount()
+		stats.serializedSize += uint64(len(serialized))
+		jobs <- serialized
+		return nil
+	})
+	close(jobs)
+	for i := 0; i < numWorkers; i++ {
+		stats.multiset.Merge(<-results)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// serializeUtxoStats returns the serialization of the passed statistics.
+func serializeUtxoStats(s *utxoStats) []byte {
+	serialized := make([]byte, serializedUtxoStatsLen)
+	copy(serialized[0:32], s.hash[:])
+	binary.LittleEndian.PutUint32(serialized[32:36], uint32(s.height))
+	binary.LittleEndian.PutUint64(serialized[36:44], s.utxos)
+	binary.LittleEndian.PutUint64(serialized[44:52], uint64(s.totalAmount))
+	binary.LittleEndian.PutUint64(serialized[52:60], s.serializedSize)
+	x, y := s.multiset.Point()
+	x.FillBytes(serialized[60:92])
+	y.FillBytes(serialized[92:124])
+	return serialized
+}
+
+// deserializeUtxoStats deserializes the passed statistics.
+func deserializeUtxoStats(serialized []byte) (*utxoStats, error) {
+	if len(serialized) != serializedUtxoStatsLen {
+		return nil, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt utxo statistics",
+		}
+	}
+	s := &utxoStats{
+		height:         int32(binary.LittleEndian.Uint32(serialized[32:36])),
+		utxos:          binary.LittleEndian.Uint64(serialized[36:44]),
+		totalAmount:    int64(binary.LittleEndian.Uint64(serialized[44:52])),
+		serializedSize: binary.LittleEndian.Uint64(serialized[52:60]),
+	}
+	copy(s.hash[:], serialized[0:32])
+	x := new(big.Int).SetBytes(serialized[60:92])
+	y := new(big.Int).SetBytes(serialized[92:124])
+	s.multiset = bchec.NewMultisetFromPoint(bchec.S256(), x, y)
+	return s, nil
+}
+
+// dbPutUtxoStats uses an existing database transaction to store the passed
+// utxo statistics.
+func dbPutUtxoStats(dbTx database.Tx, s *utxoStats) error {
+	return dbTx.Metadata().Put(utxoStatsKeyName, serializeUtxoStats(s))
+}
+
+// dbFetchUtxoStats uses an existing database transaction to fetch the stored
+// utxo statistics.  Nil is returned when no statistics are stored.
+func dbFetchUtxoStats(dbTx database.Tx) (*utxoStats, error) {
+	serialized := dbTx.Metadata().Get(utxoStatsKeyName)
+	if serialized == nil {
+		return nil, nil
+	}
+	return deserializeUtxoStats(serialized)
+}
+
+// initUtxoStats loads the incrementally maintained utxo statistics from the
+// database.  They are calculated from scratch when they are missing or don't
+// match the current best chain, for example because the node ran without
+// maintaining them.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) initUtxoStats(interrupt <-chan struct{}) error {
+	tip := b.bestChain.Tip()
+	var stats *utxoStats
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		stats, err = dbFetchUtxoStats(dbTx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if stats != nil && stats.hash == tip.hash {
+		b.utxoStats = stats
+		return nil
+	}
+
+	log.Info("Calculating UTXO set statistics.  This might take a while...")
+	if err := b.utxoCache.Flush(FlushRequired, b.stateSnapshot); err != nil {
+		return err
+	}
+	err = b.db.View(func(dbTx database.Tx) error {
+		var err error
+		stats, err = calcUtxoStats(dbTx, interrupt)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	stats.hash = tip.hash
+	stats.height = tip.height
+	err = b.db.Update(func(dbTx database.Tx) error {
+		return dbPutUtxoStats(dbTx, stats)
+	})
+	if err != nil {
+		return err
+	}
+	b.utxoStats = stats
+	log.Infof("UTXO set statistics calculated (%d utxos)", stats.utxos)
+	return nil
+}
+
+// FetchUtxoStats returns statistics about the unspent transaction output set
+// at the end of the main chain.
+//
+// When the chain maintains the statistics incrementally they are returned
+// right away.  Otherwise the entire utxo set is scanned, which can take a long
+// time.  The chain lock is only held while the utxo cache is flushed, so blocks
+// can still be processed during the scan.  The scan stops with the error of the
+// passed context once it is canceled.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) FetchUtxoStats(ctx context.Context) (*UtxoStats, error) {
+	b.chainLock.Lock()
+	if b.utxoStats != nil {
+		stats := b.utxoStats.stats()
+		b.chainLock.Unlock()
+		return stats, nil
+	}
+
+	// Flush the utxo cache so the database contains the entire utxo set
+	// and scan a snapshot of it.
+	tip := b.bestChain.Tip()
+	if err := b.utxoCache.Flush(FlushRequired, b.stateSnapshot); err != nil {
+		b.chainLock.Unlock()
+		return nil, err
+	}
+	dbTx, err := b.db.Begin(false)
+	b.chainLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	stats, err := calcUtxoStats(dbTx, ctx.Done())
+	dbTx.Rollback()
+	if err == errInterruptRequested {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	stats.hash = tip.hash
+	stats.height = tip.height
+
+	// Resume maintaining the statistics incrementally when they were lost,
+	// for example after a fast sync, and the main chain did not change in
+	// the meantime.
+	if b.utxoStatsEnabled {
+		b.chainLock.Lock()
+		if b.utxoStats == nil && b.bestChain.Tip() == tip {
+			err := b.db.Update(func(dbTx database.Tx) error {
+				return dbPutUtxoStats(dbTx, stats)
+			})
+			if err == nil {
+				b.utxoStats = stats.clone()
+			}
+		}
+		b.chainLock.Unlock()
+	}
+
+	return stats.stats(), nil
+}
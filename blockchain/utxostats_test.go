@@ -0,0 +1,109 @@
+package blockchain
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchutil"
+)
+
+// scanUtxoStats returns the utxo statistics calculated by scanning the utxo
+// set of the passed chain.
+func scanUtxoStats(t *testing.T, chain *BlockChain) *UtxoStats {
+	t.Helper()
+	if err := chain.FlushCachedState(FlushRequired); err != nil {
+		t.Fatalf("unexpected error while flushing cache: %v", err)
+	}
+	var stats *utxoStats
+	err := chain.db.View(func(dbTx database.Tx) error {
+		var err error
+		stats, err = calcUtxoStats(dbTx, nil)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("calcUtxoStats: %v", err)
+	}
+	tip := chain.bestChain.Tip()
+	stats.hash = tip.hash
+	stats.height = tip.height
+	return stats.stats()
+}
+
+// TestUtxoStats ensures the incrementally maintained utxo statistics match
+// the statistics calculated by scanning the utxo set, including across a
+// reorg and a restart.
+func TestUtxoStats(t *testing.T) {
+	chain, params, tearDown := utxoCacheTestChain("TestUtxoStats")
+	defer tearDown()
+	chain.utxoStatsEnabled = true
+	if err := chain.initUtxoStats(nil); err != nil {
+		t.Fatalf("initUtxoStats: %v", err)
+	}
+
+	assertStats := func() {
+		t.Helper()
+		got, err := chain.FetchUtxoStats(context.Background())
+		if err != nil {
+			t.Fatalf("FetchUtxoStats: %v", err)
+		}
+		want := scanUtxoStats(t, chain)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("mismatched utxo stats: got %+v, want %+v", got,
+				want)
+		}
+		if got.Utxos == 0 || got.TotalAmount == 0 {
+			t.Fatalf("unexpected empty utxo stats %+v", got)
+		}
+	}
+
+	tip := bchutil.NewBlock(params.GenesisBlock)
+	b1, outs1 := addBlock(chain, tip, nil)
+	b2, outs2 := addBlock(chain, b1, outs1)
+	assertStats()
+
+	// Build a chain spending the outputs of block 2 and reorg to an
+	// alternative chain which doesn't spend them.
+	b3a, outs3 := addBlock(chain, b2, outs2)
+	addBlock(chain, b3a, outs3)
+	assertStats()
+	b3b, _ := addBlock(chain, b2, nil)
+	b4b, _ := addBlock(chain, b3b, nil)
+	addBlock(chain, b4b, nil)
+	assertStats()
+
+	// The statistics are loaded from the database after a restart.
+	stored := chain.utxoStats.stats()
+	chain.utxoStats = nil
+	if err := chain.initUtxoStats(nil); err != nil {
+		t.Fatalf("initUtxoStats: %v", err)
+	}
+	if got := chain.utxoStats.stats(); !reflect.DeepEqual(got, stored) {
+		t.Fatalf("mismatched stored utxo stats: got %+v, want %+v", got,
+			stored)
+	}
+}
+
+// TestUtxoStatsSerialization ensures utxo statistics survive a round trip
+// through their serialization.
+func TestUtxoStatsSerialization(t *testing.T) {
+	stats := newUtxoStats()
+	stats.hash = *newHashFromStr("00000000000af0aed4792b1acee3d966af36cf5def14935db8de83d6f9306f2f")
+	stats.height = 91812
+	stats.add([]byte{0x01, 0x02}, 5000000000)
+	stats.add([]byte{0x03}, 1000)
+
+	got, err := deserializeUtxoStats(serializeUtxoStats(stats))
+	if err != nil {
+		t.Fatalf("deserializeUtxoStats: %v", err)
+	}
+	if !reflect.DeepEqual(got.stats(), stats.stats()) {
+		t.Fatalf("mismatched utxo stats: got %+v, want %+v",
+			got.stats(), stats.stats())
+	}
+
+	if _, err := deserializeUtxoStats([]byte{0x00}); err == nil {
+		t.Fatal("deserializeUtxoStats: expected error for short data")
+	}
+}
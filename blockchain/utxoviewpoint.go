@@ -135,10 +135,31 @@ func (view *UtxoViewpoint) AddTxOuts(tx *bchutil.Tx, blockHeight int32) {
 	}
 }
 
+// fetchEntries returns the entries of the passed source for the passed distinct
+// outpoints in the same order.  A utxo cache fetches the entries it does not
+// contain from the database in a batch.
+func fetchEntries(source utxoView, outpoints []wire.OutPoint) ([]*UtxoEntry, error) {
+	if cache, ok := source.(*utxoCache); ok {
+		return cache.getEntries(outpoints)
+	}
+
+	entries := make([]*UtxoEntry, len(outpoints))
+	for i, outpoint := range outpoints {
+		entry, err := source.getEntry(outpoint)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = entry
+	}
+	return entries, nil
+}
+
 // addInputUtxos adds the unspent transaction outputs for the inputs referenced
 // by the transactions in the given block to the view.  In particular, referenced
 // entries that are earlier in the block are added to the view and entries that
-// are already in the view are not modified.
+// are already in the view are not modified.  The entries which are not in the
+// block are fetched from the source together, which allows a utxo cache to read
+// the ones it does not contain from the database in a batch.
 func (view *UtxoViewpoint) addInputUtxos(source utxoView, block *bchutil.Block, ignoreOutOfOrder bool) error {
 	// Build a map of in-flight transactions because some of the inputs in
 	// this block could be referencing other transactions earlier in this
@@ -150,7 +171,10 @@ func (view *UtxoViewpoint) addInputUtxos(source utxoView, block *bchutil.Block,
 	}
 
 	// Loop through all of the transaction inputs (except for the coinbase
-	// which has no inputs).
+	// which has no inputs) and collect the outpoints which have to be
+	// fetched from the source.
+	var needed []wire.OutPoint
+	neededSet := make(map[wire.OutPoint]struct{})
 	for i, tx := range block.Transactions()[1:] {
 		for _, txIn := range tx.MsgTx().TxIn {
 			originHash := &txIn.PreviousOutPoint.Hash
@@ -165,12 +189,25 @@ func (view *UtxoViewpoint) addInputUtxos(source utxoView, block *bchutil.Block,
 			if _, ok := view.entries[txIn.PreviousOutPoint]; ok {
 				continue
 			}
-			// Add the entry from the source.
-			entry, err := source.getEntry(txIn.PreviousOutPoint)
-			if err == nil && entry != nil {
-				view.entries[txIn.PreviousOutPoint] = entry.Clone()
+			if _, ok := neededSet[txIn.PreviousOutPoint]; ok {
+				continue
 			}
+			neededSet[txIn.PreviousOutPoint] = struct{}{}
+			needed = append(needed, txIn.PreviousOutPoint)
+		}
+	}
+
+	// Add the entries from the source unless an entry was added for an
+	// output of the block in the meantime.
+	entries, err := fetchEntries(source, needed)
+	if err != nil {
+		return err
+	}
+	for i, outpoint := range needed {
+		if _, ok := view.entries[outpoint]; ok || entries[i] == nil {
+			continue
 		}
+		view.entries[outpoint] = entries[i].Clone()
 	}
 	return nil
 }
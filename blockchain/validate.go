@@ -16,6 +16,7 @@ import (
 	"github.com/gcash/bchd/txscript"
 	"github.com/gcash/bchd/wire"
 	"github.com/gcash/bchutil"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -484,6 +485,10 @@ func checkBlockSanity(block *bchutil.Block, powLimit *big.Int, timeSource Median
 			txscript.ScriptVerifyCheckDataSig
 	}
 
+	// Compute the hashes of the transactions in parallel up front since
+	// they are needed to check their order below.
+	cacheTxHashes(transactions)
+
 	// Do some preliminary checks on each transaction to ensure they are
 	// sane before continuing.
 	var lastTxid *chainhash.Hash
@@ -496,7 +501,7 @@ func checkBlockSanity(block *bchutil.Block, powLimit *big.Int, timeSource Median
 		lastTxid = tx.Hash()
 		err := CheckTransactionSanity(tx, magneticAnomaly, upgrade9, scriptFlags)
 		if err != nil {
-			return err
+			return txRuleError(err, i, tx)
 		}
 	}
 
@@ -690,6 +695,20 @@ func (b *BlockChain) checkBlockHeaderContext(header *wire.BlockHeader, prevNode
 		return ruleError(ErrForkTooOld, str)
 	}
 
+	// Prevent blocks which fork the main chain from a block with less than
+	// the minimum chain work once the main chain has at least that much
+	// work.  Like the checkpoint check above, this prevents storage of
+	// headers and blocks building off of old blocks at a much easier
+	// difficulty, but without relying on hardcoded checkpoints.
+	if b.minimumChainWork != nil && b.hasMinimumChainWork() &&
+		prevNode.workSum.Cmp(b.minimumChainWork) < 0 {
+
+		str := fmt.Sprintf("block at height %d forks the main chain "+
+			"from a block with less than the minimum chain work",
+			blockHeight)
+		return ruleError(ErrForkTooOld, str)
+	}
+
 	// Reject outdated block versions once a majority of the network
 	// has upgraded.  These were originally voted on by BIP0034,
 	// BIP0065, and BIP0066.
@@ -725,6 +744,17 @@ func (b *BlockChain) checkBlockContext(block *bchutil.Block, prevNode *blockNode
 		return err
 	}
 
+	// Blocks on signed test networks must carry a signature solution which
+	// satisfies the signet challenge.  Like the proof of work, it is not
+	// checked for block templates which are still to be signed.
+	challenge := b.chainParams.SignetChallenge
+	if challenge != nil && !flags.HasFlag(BFNoPoWCheck) {
+		err := CheckSignetSolution(block.MsgBlock(), challenge)
+		if err != nil {
+			return err
+		}
+	}
+
 	uahfActive := block.Height() > b.chainParams.UahfForkHeight
 	ablaActive := block.Height() > b.chainParams.ABLAForkHeight
 
@@ -784,13 +814,14 @@ func (b *BlockChain) checkBlockContext(block *bchutil.Block, prevNode *blockNode
 		blockHeight := prevNode.height + 1
 
 		// Ensure all transactions in the block are finalized.
-		for _, tx := range block.Transactions() {
+		for i, tx := range block.Transactions() {
 			if !IsFinalizedTransaction(tx, blockHeight,
 				blockTime) {
 
 				str := fmt.Sprintf("block contains unfinalized "+
 					"transaction %v", tx.Hash())
-				return ruleError(ErrUnfinalizedTx, str)
+				return txRuleError(ruleError(ErrUnfinalizedTx, str),
+					i, tx)
 			}
 		}
 
@@ -857,15 +888,23 @@ func (b *BlockChain) checkBIP0030(block *bchutil.Block, view *UtxoViewpoint) err
 // include verifying all inputs exist, ensuring the coinbase seasoning
 // requirements are met, detecting double spends, validating all values and fees
 // are in the legal range and the total output amount doesn't exceed the input
-// amount, and verifying the signatures to prove the spender was the owner of
-// the bitcoins and therefore allowed to spend them.  As it checks the inputs,
-// it also calculates the total fees for the transaction and returns that value.
+// amount, ensuring the tokens carried by the outputs are permitted by the
+// tokens which are spent once CashTokens are active, and verifying the
+// signatures to prove the spender was the owner of the bitcoins and therefore
+// allowed to spend them.  As it checks the inputs, it also calculates the total
+// fees for the transaction and returns that value.
 //
 // NOTE: The transaction MUST have already been sanity checked with the
 // CheckTransactionSanity function prior to calling this function.
 func CheckTransactionInputs(tx *bchutil.Tx, txHeight int32, utxoView *UtxoViewpoint, chainParams *chaincfg.Params) (int64, error) {
-	// Coinbase transactions have no inputs.
+	// Coinbase transactions have no inputs.  Since they do not spend any
+	// tokens either, their outputs may not carry any once CashTokens are
+	// active.
+	upgrade9Active := txHeight > chainParams.Upgrade9ForkHeight
 	if IsCoinBase(tx) {
+		if upgrade9Active {
+			return 0, checkTransactionTokens(tx, utxoView)
+		}
 		return 0, nil
 	}
 
@@ -938,6 +977,14 @@ func CheckTransactionInputs(tx *bchutil.Tx, txHeight int32, utxoView *UtxoViewpo
 		}
 	}
 
+	// Ensure the tokens carried by the outputs of the transaction are
+	// permitted by the tokens it spends.
+	if upgrade9Active {
+		if err := checkTransactionTokens(tx, utxoView); err != nil {
+			return 0, err
+		}
+	}
+
 	// Calculate the total output amount for this transaction.  It is safe
 	// to ignore overflow and out of range errors here because those error
 	// conditions would have already been caught by checkTransactionSanity.
@@ -961,6 +1008,122 @@ func CheckTransactionInputs(tx *bchutil.Tx, txHeight int32, utxoView *UtxoViewpo
 	return txFeeInSatoshi, nil
 }
 
+// blockScriptFlags returns the script flags the scripts of the transactions in
+// a block with the passed version are validated with when the block is
+// connected as the passed node.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) blockScriptFlags(node *blockNode, version int32) (txscript.ScriptFlags, error) {
+	// BIP0016 describes a pay-to-script-hash type that is considered a
+	// "standard" type.  The rules for this BIP only apply to transactions
+	// after the timestamp defined by txscript.Bip16Activation.  See
+	// https://en.bitcoin.it/wiki/BIP_0016 for more details.
+	//
+	// Blocks created after the BIP0016 activation time need to have the
+	// pay-to-script-hash checks enabled.
+	var scriptFlags txscript.ScriptFlags
+	if node.timestamp >= txscript.Bip16Activation.Unix() {
+		scriptFlags |= txscript.ScriptBip16
+	}
+
+	// Enforce DER signatures for block versions 3+ once the historical
+	// activation threshold has been reached.  This is part of BIP0066.
+	if version >= 3 && node.height >= b.chainParams.BIP0066Height {
+		scriptFlags |= txscript.ScriptVerifyDERSignatures
+	}
+
+	// Enforce CHECKLOCKTIMEVERIFY for block versions 4+ once the historical
+	// activation threshold has been reached.  This is part of BIP0065.
+	if version >= 4 && node.height >= b.chainParams.BIP0065Height {
+		scriptFlags |= txscript.ScriptVerifyCheckLockTimeVerify
+	}
+
+	// If Uahf is active we must enforce strict encoding on all signatures and enforce
+	// the replay protected sighash.
+	if node.height > b.chainParams.UahfForkHeight {
+		scriptFlags |= txscript.ScriptVerifyStrictEncoding | txscript.ScriptVerifyBip143SigHash
+	}
+
+	// If Daa is active enforce Low S and Nullfail script validation rules.
+	if node.height > b.chainParams.DaaForkHeight {
+		scriptFlags |= txscript.ScriptVerifyLowS | txscript.ScriptVerifyNullFail
+	}
+
+	// If MagneticAnomaly hardfork is active we must enforce PushOnly and CleanStack
+	// and enable OP_CHECKDATASIG and OP_CHECKDATASIGVERIFY.
+	if node.height > b.chainParams.MagneticAnonomalyForkHeight {
+		scriptFlags |= txscript.ScriptVerifySigPushOnly |
+			txscript.ScriptVerifyCleanStack |
+			txscript.ScriptVerifyCheckDataSig
+	}
+
+	// If GreatWall hardfork is active enforce Schnorr and AllowSegwitRecovery script flags.
+	if node.height > b.chainParams.GreatWallForkHeight {
+		scriptFlags |= txscript.ScriptVerifySchnorr | txscript.ScriptVerifyAllowSegwitRecovery
+	}
+
+	// If Graviton hardfork is active enforce MinimalData and SchnorrMultisig script flag.
+	if node.height > b.chainParams.GravitonForkHeight {
+		scriptFlags |= txscript.ScriptVerifyMinimalData | txscript.ScriptVerifySchnorrMultisig
+	}
+
+	// If Phonon hardfork is active we need to check the sig checks for both blocks and
+	// transactions as well as activate OP_REVERSEBYTES.
+	if node.height > b.chainParams.PhononForkHeight {
+		scriptFlags |= txscript.ScriptReportSigChecks | txscript.ScriptVerifyReverseBytes
+	}
+
+	// If CosmicInflation hardfork is active enforce 64BitIntegers and NativeIntrospection
+	parentMedianTime := node.parent.CalcPastMedianTime().Unix()
+	if parentMedianTime >= int64(b.chainParams.CosmicInflationActivationTime) {
+		scriptFlags |= txscript.ScriptVerify64BitIntegers | txscript.ScriptVerifyNativeIntrospection
+	}
+
+	if node.height > b.chainParams.Upgrade9ForkHeight {
+		scriptFlags |= txscript.ScriptAllowCashTokens
+	}
+
+	if parentMedianTime >= int64(b.chainParams.Upgrade11ActivationTime) {
+		scriptFlags |= txscript.ScriptAllowMay2025
+	}
+
+	// Enforce CHECKSEQUENCEVERIFY once the soft-fork deployment is fully
+	// active.
+	csvState, err := b.deploymentState(node.parent, chaincfg.DeploymentCSV)
+	if err != nil {
+		return 0, err
+	}
+	if csvState == ThresholdActive {
+		scriptFlags |= txscript.ScriptVerifyCheckSequenceVerify
+	}
+
+	return scriptFlags, nil
+}
+
+// NextBlockScriptFlags returns the script flags the scripts of the transactions
+// in a block building on the current tip of the main chain are validated with.
+// Callers such as the mempool use them to add the transactions they validated
+// to the script cache so their scripts are not executed again once they are
+// included in a block.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) NextBlockScriptFlags() (txscript.ScriptFlags, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	tip := b.bestChain.Tip()
+	timestamp := b.timeSource.AdjustedTime()
+	if medianTime := tip.CalcPastMedianTime(); !timestamp.After(medianTime) {
+		timestamp = medianTime.Add(time.Second)
+	}
+	header := wire.BlockHeader{
+		Version:   vbTopBits,
+		PrevBlock: tip.hash,
+		Timestamp: timestamp,
+	}
+	return b.blockScriptFlags(newBlockNode(&header, tip), header.Version)
+}
+
 // checkConnectBlock performs several checks to confirm connecting the passed
 // block to the chain represented by the passed view does not violate any rules.
 // In addition, the passed view is updated to spend all of the referenced
@@ -982,7 +1145,11 @@ func CheckTransactionInputs(tx *bchutil.Tx, txHeight int32, utxoView *UtxoViewpo
 // with that node.
 //
 // This function MUST be called with the chain state lock held (for writes).
-func (b *BlockChain) checkConnectBlock(node *blockNode, block *bchutil.Block, view *UtxoViewpoint, stxos *[]SpentTxOut) error {
+func (b *BlockChain) checkConnectBlock(node *blockNode, block *bchutil.Block, view *UtxoViewpoint, stxos *[]SpentTxOut) (err error) {
+	endSpan := b.startSpan("checkConnectBlock",
+		attribute.Int("block.height", int(node.height)))
+	defer func() { endSpan(err) }()
+
 	// If the side chain blocks end up in the database, a call to
 	// CheckBlockSanity should be done here in case a previous version
 	// allowed a block that is no longer valid.  However, since the
@@ -1000,32 +1167,10 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *bchutil.Block, vi
 	// using the excessiveBlockSize rather than the LegacyBlockSize
 	uahfActive := node.height > b.chainParams.UahfForkHeight
 
-	// If Daa hardfork is active then we need to use the new difficulty
-	// adjustment algorithm and also enforce Low S and Nullfail.
-	daaActive := node.height > b.chainParams.DaaForkHeight
-
 	// If MagneticAnomaly hardfork is active we must enforce PushOnly and CleanStack
 	// and enable OP_CHECKDATASIG and OP_CHECKDATASIGVERIFY and CTOR.
 	magneticAnomalyActive := node.height > b.chainParams.MagneticAnonomalyForkHeight
 
-	// If GreatWall hardfork is active then we must enforce the Schnorr and AllowSegitRecovery
-	// script flags.
-	greatWallActive := node.height > b.chainParams.GreatWallForkHeight
-
-	// If Graviton hardfork is active we must enforce MinimalData
-	gravitonActive := node.height > b.chainParams.GravitonForkHeight
-
-	// If Phonon hardfork is active we must enforce the new sig check rules and
-	// OP_REVERSEBYTES.
-	phononActive := node.height > b.chainParams.PhononForkHeight
-
-	// If CosmicInflation is active we enforce 64BitIntegers and NativeIntrospection
-	cosmicInflationActive := node.parent.CalcPastMedianTime().Unix() >= int64(b.chainParams.CosmicInflationActivationTime)
-
-	upgrade9Active := node.height > b.chainParams.Upgrade9ForkHeight
-
-	upgrade11Active := node.parent.CalcPastMedianTime().Unix() >= int64(b.chainParams.Upgrade11ActivationTime)
-
 	// BIP0030 added a rule to prevent blocks which contain duplicate
 	// transactions that 'overwrite' older transactions which are not fully
 	// spent.  See the documentation for checkBIP0030 for more details.
@@ -1049,88 +1194,58 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *bchutil.Block, vi
 		}
 	}
 
+	// Don't run scripts if this node is before the latest known good
+	// checkpoint since the validity is verified via the checkpoints (all
+	// transactions are included in the merkle root hash and any changes
+	// will therefore be detected by the next checkpoint).  This is a huge
+	// optimization because running the scripts is the most time consuming
+	// portion of block handling.
+	checkpoint := b.LatestCheckpoint()
+	runScripts := true
+	if checkpoint != nil && node.height <= checkpoint.Height {
+		runScripts = false
+	}
+
+	// Likewise, don't run scripts for the block which is assumed to be
+	// valid and its ancestors.  Unlike checkpoints, it does not constrain
+	// the chain, so all other rules are still enforced.
+	if runScripts && b.isAssumedValid(node) {
+		runScripts = false
+	}
+
+	// Start computing the partial sighashes of the transactions in the
+	// background when the scripts will be executed so that the hashing
+	// overlaps with loading the utxos and the remaining checks.  The
+	// sighashes are normally purged once the scripts are validated, so
+	// make sure they are also purged when the block is rejected before.
+	var sigHashesDone <-chan struct{}
+	if runScripts && uahfActive && b.hashCache != nil {
+		sigHashesDone = precomputeSigHashes(block, b.hashCache)
+		defer func() {
+			<-sigHashesDone
+			if err != nil {
+				for _, tx := range block.Transactions() {
+					b.hashCache.PurgeSigHashes(tx.Hash())
+				}
+			}
+		}()
+	}
+
 	// Load all of the utxos referenced by the inputs for all transactions
 	// in the block don't already exist in the utxo view from the cache.
 	//
 	// These utxo entries are needed for verification of things such as
 	// transaction inputs, counting pay-to-script-hashes, and scripts.
-	err := view.addInputUtxos(b.utxoCache, block, magneticAnomalyActive)
+	err = view.addInputUtxos(b.utxoCache, block, magneticAnomalyActive)
 	if err != nil {
 		return err
 	}
 
-	// BIP0016 describes a pay-to-script-hash type that is considered a
-	// "standard" type.  The rules for this BIP only apply to transactions
-	// after the timestamp defined by txscript.Bip16Activation.  See
-	// https://en.bitcoin.it/wiki/BIP_0016 for more details.
-	enforceBIP0016 := node.timestamp >= txscript.Bip16Activation.Unix()
-
-	// Blocks created after the BIP0016 activation time need to have the
-	// pay-to-script-hash checks enabled.
-	var scriptFlags txscript.ScriptFlags
-	if enforceBIP0016 {
-		scriptFlags |= txscript.ScriptBip16
-	}
-
-	// Enforce DER signatures for block versions 3+ once the historical
-	// activation threshold has been reached.  This is part of BIP0066.
-	blockHeader := &block.MsgBlock().Header
-	if blockHeader.Version >= 3 && node.height >= b.chainParams.BIP0066Height {
-		scriptFlags |= txscript.ScriptVerifyDERSignatures
-	}
-
-	// Enforce CHECKLOCKTIMEVERIFY for block versions 4+ once the historical
-	// activation threshold has been reached.  This is part of BIP0065.
-	if blockHeader.Version >= 4 && node.height >= b.chainParams.BIP0065Height {
-		scriptFlags |= txscript.ScriptVerifyCheckLockTimeVerify
-	}
-
-	// If Uahf is active we must enforce strict encoding on all signatures and enforce
-	// the replay protected sighash.
-	if uahfActive {
-		scriptFlags |= txscript.ScriptVerifyStrictEncoding | txscript.ScriptVerifyBip143SigHash
-	}
-
-	// If Daa is active enforce Low S and Nullfail script validation rules.
-	if daaActive {
-		scriptFlags |= txscript.ScriptVerifyLowS | txscript.ScriptVerifyNullFail
-	}
-
-	// If MagneticAnomaly hardfork is active we must enforce PushOnly and CleanStack
-	// and enable OP_CHECKDATASIG and OP_CHECKDATASIGVERIFY.
-	if magneticAnomalyActive {
-		scriptFlags |= txscript.ScriptVerifySigPushOnly |
-			txscript.ScriptVerifyCleanStack |
-			txscript.ScriptVerifyCheckDataSig
-	}
-
-	// If GreatWall hardfork is active enforce Schnorr and AllowSegwitRecovery script flags.
-	if greatWallActive {
-		scriptFlags |= txscript.ScriptVerifySchnorr | txscript.ScriptVerifyAllowSegwitRecovery
-	}
-
-	// If Graviton hardfork is active enforce MinimalData and SchnorrMultisig script flag.
-	if gravitonActive {
-		scriptFlags |= txscript.ScriptVerifyMinimalData | txscript.ScriptVerifySchnorrMultisig
-	}
-
-	// If Phonon hardfork is active we need to check the sig checks for both blocks and
-	// transactions as well as activate OP_REVERSEBYTES.
-	if phononActive {
-		scriptFlags |= txscript.ScriptReportSigChecks | txscript.ScriptVerifyReverseBytes
-	}
-
-	// If CosmicInflation hardfork is active enforce 64BitIntegers and NativeIntrospection
-	if cosmicInflationActive {
-		scriptFlags |= txscript.ScriptVerify64BitIntegers | txscript.ScriptVerifyNativeIntrospection
-	}
-
-	if upgrade9Active {
-		scriptFlags |= txscript.ScriptAllowCashTokens
-	}
-
-	if upgrade11Active {
-		scriptFlags |= txscript.ScriptAllowMay2025
+	// Determine the script flags the scripts of the transactions in the
+	// block are validated with.
+	scriptFlags, err := b.blockScriptFlags(node, block.MsgBlock().Header.Version)
+	if err != nil {
+		return err
 	}
 
 	// Perform several checks on the inputs for each transaction.  Also
@@ -1143,10 +1258,10 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *bchutil.Block, vi
 	transactions := block.Transactions()
 
 	var totalFees int64
-	for _, tx := range transactions {
+	for i, tx := range transactions {
 		txFee, err := CheckTransactionInputs(tx, node.height, view, b.chainParams)
 		if err != nil {
-			return err
+			return txRuleError(err, i, tx)
 		}
 
 		// Sum the total fees and ensure we don't overflow the
@@ -1201,18 +1316,6 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *bchutil.Block, vi
 		return ruleError(ErrBadCoinbaseValue, str)
 	}
 
-	// Don't run scripts if this node is before the latest known good
-	// checkpoint since the validity is verified via the checkpoints (all
-	// transactions are included in the merkle root hash and any changes
-	// will therefore be detected by the next checkpoint).  This is a huge
-	// optimization because running the scripts is the most time consuming
-	// portion of block handling.
-	checkpoint := b.LatestCheckpoint()
-	runScripts := true
-	if checkpoint != nil && node.height <= checkpoint.Height {
-		runScripts = false
-	}
-
 	// Enforce CHECKSEQUENCEVERIFY during all block validation checks once
 	// the soft-fork deployment is fully active.
 	csvState, err := b.deploymentState(node.parent, chaincfg.DeploymentCSV)
@@ -1220,11 +1323,6 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *bchutil.Block, vi
 		return err
 	}
 	if csvState == ThresholdActive {
-		// If the CSV soft-fork is now active, then modify the
-		// scriptFlags to ensure that the CSV op code is properly
-		// validated during the script checks bleow.
-		scriptFlags |= txscript.ScriptVerifyCheckSequenceVerify
-
 		// We obtain the MTP of the *previous* block in order to
 		// determine if transactions in the current block are final.
 		medianTime := node.parent.CalcPastMedianTime()
@@ -1233,7 +1331,7 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *bchutil.Block, vi
 		// then we also enforce the relative sequence number based
 		// lock-times within the inputs of all transactions in this
 		// candidate block.
-		for _, tx := range block.Transactions() {
+		for i, tx := range block.Transactions() {
 			// A transaction can only be included within a block
 			// once the sequence locks of *all* its inputs are
 			// active.
@@ -1247,7 +1345,8 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *bchutil.Block, vi
 				str := fmt.Sprintf("block contains " +
 					"transaction whose input sequence " +
 					"locks are not met")
-				return ruleError(ErrUnfinalizedTx, str)
+				return txRuleError(ruleError(ErrUnfinalizedTx,
+					str), i, tx)
 			}
 		}
 	}
@@ -1257,9 +1356,15 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *bchutil.Block, vi
 	// expensive ECDSA signature check scripts.  Doing this last helps
 	// prevent CPU exhaustion attacks.
 	if runScripts {
+		if sigHashesDone != nil {
+			<-sigHashesDone
+		}
 		maxSigChecks := uint32(b.ablaState.getBlockSizeLimit()) / BlockMaxBytesMaxSigChecksRatio // TODO change this to uint64
+		endSpan := b.startSpan("checkBlockScripts")
 		err := checkBlockScripts(block, view, scriptFlags, b.sigCache,
-			b.hashCache, maxSigChecks, b.chainParams.Upgrade9ForkHeight)
+			b.hashCache, b.scriptCache, maxSigChecks,
+			b.chainParams.Upgrade9ForkHeight)
+		endSpan(err)
 		if err != nil {
 			return err
 		}
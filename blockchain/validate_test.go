@@ -6,6 +6,7 @@ package blockchain
 
 import (
 	"math"
+	"math/big"
 	"reflect"
 	"testing"
 	"time"
@@ -205,6 +206,66 @@ func repeatScript(n int, newBytes ...byte) []byte {
 	return script
 }
 
+// TestMinimumChainWork ensures the chain is not current before its main chain
+// has the minimum chain work and that blocks forking the main chain from a
+// block with less work are rejected afterwards.
+func TestMinimumChainWork(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	chain := newFakeChain(&params)
+	now := time.Unix(time.Now().Unix(), 0)
+	nodes := []*blockNode{chain.bestChain.Tip()}
+	for i := 1; i <= 10; i++ {
+		node := newFakeNode(nodes[i-1], 4, params.PowLimitBits,
+			now.Add(time.Duration(i-10)*time.Minute))
+		chain.index.AddNode(node)
+		chain.bestChain.SetTip(node)
+		nodes = append(nodes, node)
+	}
+	tip := nodes[10]
+
+	tests := []struct {
+		name     string
+		minWork  *big.Int
+		current  bool
+		prevNode *blockNode
+		fork     bool
+	}{
+		{"no minimum", nil, true, nodes[3], false},
+		{"below minimum fork", nodes[5].workSum, true, nodes[3], true},
+		{"at minimum fork", nodes[5].workSum, true, nodes[5], false},
+		{"extend tip", nodes[5].workSum, true, tip, false},
+		{"main chain below minimum",
+			new(big.Int).Add(tip.workSum, big.NewInt(1)), false,
+			nodes[3], false},
+	}
+	for _, test := range tests {
+		chain.minimumChainWork = test.minWork
+		if current := chain.IsCurrent(); current != test.current {
+			t.Fatalf("%s: got current %v, want %v", test.name,
+				current, test.current)
+		}
+
+		header := &wire.BlockHeader{
+			Version:   4,
+			PrevBlock: test.prevNode.hash,
+			Bits:      params.PowLimitBits,
+			Timestamp: now,
+			Nonce:     1,
+		}
+		err := chain.checkBlockHeaderContext(header, test.prevNode,
+			BFFastAdd)
+		if test.fork {
+			rerr, ok := err.(RuleError)
+			if !ok || rerr.ErrorCode != ErrForkTooOld {
+				t.Fatalf("%s: got error %v, want %v", test.name,
+					err, ErrForkTooOld)
+			}
+		} else if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+}
+
 // TestCheckBlockSanity tests the CheckBlockSanity function to ensure it works
 // as expected.
 func TestCheckBlockSanity(t *testing.T) {
@@ -291,6 +352,216 @@ func TestCheckSerializedHeight(t *testing.T) {
 	}
 }
 
+// TestCheckTransactionInputsTokens ensures the CashTokens rules enforced by
+// CheckTransactionInputs accept and reject transactions as expected.
+func TestCheckTransactionInputsTokens(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	activeHeight := params.Upgrade9ForkHeight + 1
+
+	category := [32]byte{0x01}
+	otherCategory := [32]byte{0x02}
+	fungible := func(cat [32]byte, amount uint64) wire.TokenData {
+		return wire.TokenData{
+			CategoryID: cat,
+			Amount:     amount,
+			BitField:   wire.HAS_AMOUNT,
+		}
+	}
+	nft := func(cat [32]byte, capability byte, commitment []byte) wire.TokenData {
+		bitField := wire.HAS_NFT | capability
+		if len(commitment) > 0 {
+			bitField |= wire.HAS_COMMITMENT_LENGTH
+		}
+		return wire.TokenData{
+			CategoryID: cat,
+			Commitment: commitment,
+			BitField:   bitField,
+		}
+	}
+
+	// The genesis input spends the output with index 0 of the transaction
+	// whose hash is the category id, while the other inputs do not.
+	genesisOutPoint := wire.OutPoint{Hash: chainhash.Hash(category), Index: 0}
+	otherOutPoint := func(i int) wire.OutPoint {
+		return wire.OutPoint{Hash: chainhash.Hash{0xff}, Index: uint32(i + 1)}
+	}
+
+	tests := []struct {
+		name    string
+		genesis bool
+		inputs  []wire.TokenData
+		outputs []wire.TokenData
+		height  int32
+		err     ErrorCode
+		valid   bool
+	}{
+		{
+			name:    "genesis of fungible tokens",
+			genesis: true,
+			outputs: []wire.TokenData{fungible(category, wire.MAX_FT_AMOUNT)},
+			valid:   true,
+		},
+		{
+			name:    "genesis exceeding the maximum amount",
+			genesis: true,
+			outputs: []wire.TokenData{
+				fungible(category, wire.MAX_FT_AMOUNT),
+				fungible(category, 1),
+			},
+			err: ErrTokenAmountInflation,
+		},
+		{
+			name:    "genesis of another category",
+			genesis: true,
+			outputs: []wire.TokenData{fungible(otherCategory, 1)},
+			err:     ErrTokenAmountInflation,
+		},
+		{
+			name:   "split fungible tokens",
+			inputs: []wire.TokenData{fungible(category, 100)},
+			outputs: []wire.TokenData{
+				fungible(category, 60),
+				fungible(category, 40),
+			},
+			valid: true,
+		},
+		{
+			name:    "burn fungible tokens",
+			inputs:  []wire.TokenData{fungible(category, 100)},
+			outputs: []wire.TokenData{fungible(category, 1)},
+			valid:   true,
+		},
+		{
+			name:    "inflate fungible tokens",
+			inputs:  []wire.TokenData{fungible(category, 100)},
+			outputs: []wire.TokenData{fungible(category, 101)},
+			err:     ErrTokenAmountInflation,
+		},
+		{
+			name:   "mint with minting token",
+			inputs: []wire.TokenData{nft(category, wire.MINTING, nil)},
+			outputs: []wire.TokenData{
+				nft(category, wire.MINTING, nil),
+				nft(category, wire.MUTABLE, []byte{0x01}),
+				nft(category, wire.NONE, []byte{0x02}),
+			},
+			valid: true,
+		},
+		{
+			name:    "minting token from mutable token",
+			inputs:  []wire.TokenData{nft(category, wire.MUTABLE, nil)},
+			outputs: []wire.TokenData{nft(category, wire.MINTING, nil)},
+			err:     ErrTokenMintingNotAllowed,
+		},
+		{
+			name:    "mutable token from immutable token",
+			inputs:  []wire.TokenData{nft(category, wire.NONE, nil)},
+			outputs: []wire.TokenData{nft(category, wire.MUTABLE, nil)},
+			err:     ErrTokenMutableNotAllowed,
+		},
+		{
+			name:    "modify mutable token",
+			inputs:  []wire.TokenData{nft(category, wire.MUTABLE, []byte{0x01})},
+			outputs: []wire.TokenData{nft(category, wire.MUTABLE, []byte{0x02})},
+			valid:   true,
+		},
+		{
+			name:    "downgrade mutable token",
+			inputs:  []wire.TokenData{nft(category, wire.MUTABLE, nil)},
+			outputs: []wire.TokenData{nft(category, wire.NONE, []byte{0x01})},
+			valid:   true,
+		},
+		{
+			name:   "duplicate mutable token",
+			inputs: []wire.TokenData{nft(category, wire.MUTABLE, nil)},
+			outputs: []wire.TokenData{
+				nft(category, wire.MUTABLE, nil),
+				nft(category, wire.NONE, []byte{0x01}),
+			},
+			err: ErrTokenImmutableNotAllowed,
+		},
+		{
+			name:    "transfer immutable token",
+			inputs:  []wire.TokenData{nft(category, wire.NONE, []byte{0x01})},
+			outputs: []wire.TokenData{nft(category, wire.NONE, []byte{0x01})},
+			valid:   true,
+		},
+		{
+			name:    "modify immutable token",
+			inputs:  []wire.TokenData{nft(category, wire.NONE, []byte{0x01})},
+			outputs: []wire.TokenData{nft(category, wire.NONE, []byte{0x02})},
+			err:     ErrTokenImmutableNotAllowed,
+		},
+		{
+			name:   "amount without amount bit",
+			inputs: []wire.TokenData{fungible(category, 100)},
+			outputs: []wire.TokenData{{
+				CategoryID: category,
+				Amount:     100,
+				BitField:   wire.HAS_NFT,
+			}},
+			err: ErrBadTokenData,
+		},
+		{
+			name:    "inflate fungible tokens before activation",
+			inputs:  []wire.TokenData{fungible(category, 100)},
+			outputs: []wire.TokenData{fungible(category, 101)},
+			height:  params.Upgrade9ForkHeight,
+			valid:   true,
+		},
+	}
+
+	for _, test := range tests {
+		view := NewUtxoViewpoint()
+		tx := wire.NewMsgTx(wire.TxVersion)
+		if test.genesis {
+			txOut := wire.NewTxOut(1000, []byte{txscript.OP_TRUE}, wire.TokenData{})
+			view.Entries()[genesisOutPoint] = NewUtxoEntry(txOut, 1, false)
+			tx.AddTxIn(wire.NewTxIn(&genesisOutPoint, nil))
+		}
+		for i, tokenData := range test.inputs {
+			prevOut := otherOutPoint(i)
+			txOut := wire.NewTxOut(1000, []byte{txscript.OP_TRUE}, tokenData)
+			view.Entries()[prevOut] = NewUtxoEntry(txOut, 1, false)
+			tx.AddTxIn(wire.NewTxIn(&prevOut, nil))
+		}
+		for _, tokenData := range test.outputs {
+			tx.AddTxOut(wire.NewTxOut(100, []byte{txscript.OP_TRUE}, tokenData))
+		}
+
+		height := test.height
+		if height == 0 {
+			height = activeHeight
+		}
+		_, err := CheckTransactionInputs(bchutil.NewTx(tx), height, view, params)
+		if test.valid {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		rerr, ok := err.(RuleError)
+		if !ok || rerr.ErrorCode != test.err {
+			t.Errorf("%s: unexpected error -- got %v, want %v",
+				test.name, err, test.err)
+		}
+	}
+
+	// Coinbase transactions can not carry tokens since they do not spend
+	// any.
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+		math.MaxUint32), []byte{0x51, 0x51}))
+	coinbase.AddTxOut(wire.NewTxOut(100, []byte{txscript.OP_TRUE},
+		fungible(category, 1)))
+	_, err := CheckTransactionInputs(bchutil.NewTx(coinbase), activeHeight,
+		NewUtxoViewpoint(), params)
+	if rerr, ok := err.(RuleError); !ok || rerr.ErrorCode != ErrTokenAmountInflation {
+		t.Errorf("coinbase with tokens: unexpected error -- got %v, "+
+			"want %v", err, ErrTokenAmountInflation)
+	}
+}
+
 // Block100000 defines block 100,000 of the block chain.  It is used to
 // test Block operations.
 var Block100000 = wire.MsgBlock{
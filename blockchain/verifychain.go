@@ -0,0 +1,139 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchutil"
+)
+
+// These constants define the levels of VerifyChain.  Every level includes the
+// checks of the levels below it.
+const (
+	// VerifyLevelRead ensures each block can be loaded from the database.
+	VerifyLevelRead int32 = iota
+
+	// VerifyLevelSanity performs context-free sanity checks on each block.
+	VerifyLevelSanity
+
+	// VerifyLevelUndo ensures the spend journal of each block can be used
+	// to undo it.
+	VerifyLevelUndo
+
+	// VerifyLevelConnect reconnects the undone blocks with full validation,
+	// including script execution.
+	VerifyLevelConnect
+)
+
+// VerifyChain verifies the last depth blocks of the main chain at the passed
+// level, which lets operators audit the integrity of the database, for example
+// after a crash.  A depth of zero or less verifies the entire chain.  Levels
+// above VerifyLevelSanity undo blocks in memory, so their depth is limited to
+// the undo window.
+//
+// Scripts of blocks before the latest checkpoint are not executed, just like
+// when the blocks are first connected.
+//
+// The chain lock is held for the duration of the verification, so no blocks
+// are processed in the meantime.  The verification stops with the error of the
+// passed context once it is canceled.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) VerifyChain(ctx context.Context, level, depth int32) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	tip := b.bestChain.Tip()
+	if depth <= 0 || depth > tip.height {
+		depth = tip.height
+	}
+	if level >= VerifyLevelUndo && depth > b.undoWindow {
+		log.Infof("Verification depth limited to the undo window of %d "+
+			"blocks", b.undoWindow)
+		depth = b.undoWindow
+	}
+	log.Infof("Verifying chain for %d blocks at level %d", depth, level)
+
+	// Walk back from the tip, undoing each block in the view so that it
+	// holds the utxos spent by the verified blocks once done.
+	view := NewUtxoViewpoint()
+	var blocks []*bchutil.Block
+	err := b.db.View(func(dbTx database.Tx) error {
+		node := tip
+		for i := int32(0); i < depth; i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			block, err := dbFetchBlockByNode(dbTx, node)
+			if err != nil {
+				return fmt.Errorf("unable to fetch block %v at "+
+					"height %d: %v", node.hash, node.height, err)
+			}
+
+			if level >= VerifyLevelSanity {
+				flags := BFNone
+				if node.height > b.chainParams.MagneticAnonomalyForkHeight {
+					flags |= BFMagneticAnomaly
+				}
+				if node.height > b.chainParams.Upgrade9ForkHeight {
+					flags |= BFUpgrade9
+				}
+				err := checkBlockSanity(block, b.chainParams.PowLimit,
+					b.timeSource, flags)
+				if err != nil {
+					return fmt.Errorf("block %v at height %d "+
+						"failed sanity checks: %v", node.hash,
+						node.height, err)
+				}
+			}
+
+			if level >= VerifyLevelUndo {
+				stxos, err := dbFetchSpendJournalEntry(dbTx, block)
+				if err != nil {
+					return fmt.Errorf("unable to fetch spend "+
+						"journal of block %v at height %d: %v",
+						node.hash, node.height, err)
+				}
+				err = disconnectTransactions(view, block, stxos)
+				if err != nil {
+					return fmt.Errorf("unable to undo block %v "+
+						"at height %d: %v", node.hash,
+						node.height, err)
+				}
+			}
+
+			if level >= VerifyLevelConnect {
+				blocks = append(blocks, block)
+			}
+			node = node.parent
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Chain verification failed: %v", err)
+		return err
+	}
+
+	// Reconnect the undone blocks oldest first.  Connecting a block adds
+	// its outputs to the view, which the following blocks may spend.
+	for i := len(blocks) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			log.Errorf("Chain verification failed: %v", err)
+			return err
+		}
+
+		block := blocks[i]
+		node := b.index.LookupNode(block.Hash())
+		if err := b.checkConnectBlock(node, block, view, nil); err != nil {
+			err = fmt.Errorf("unable to reconnect block %v at height "+
+				"%d: %v", node.hash, node.height, err)
+			log.Errorf("Chain verification failed: %v", err)
+			return err
+		}
+	}
+
+	log.Infof("Chain verification completed successfully")
+	return nil
+}
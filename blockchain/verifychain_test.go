@@ -0,0 +1,68 @@
+package blockchain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchutil"
+)
+
+// TestVerifyChain ensures the chain verifies at every level and that
+// corrupted spend journals are detected from the undo level on.
+func TestVerifyChain(t *testing.T) {
+	chain, params, tearDown := utxoCacheTestChain("TestVerifyChain")
+	defer tearDown()
+
+	tip := bchutil.NewBlock(params.GenesisBlock)
+	var outs []*spendableOut
+	var blocks []*bchutil.Block
+	for i := 0; i < 5; i++ {
+		tip, outs = addBlock(chain, tip, outs)
+		blocks = append(blocks, tip)
+	}
+
+	for level := VerifyLevelRead; level <= VerifyLevelConnect; level++ {
+		for _, depth := range []int32{0, 1, 3} {
+			if err := chain.VerifyChain(context.Background(), level, depth); err != nil {
+				t.Fatalf("VerifyChain(%d, %d): unexpected error: %v",
+					level, depth, err)
+			}
+		}
+	}
+
+	// Ensure the verification stops once its context is canceled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := chain.VerifyChain(ctx, VerifyLevelConnect, 0)
+	if err != context.Canceled {
+		t.Fatalf("VerifyChain: got error %v, want %v", err,
+			context.Canceled)
+	}
+
+	// Drop the spend journal entries of the fourth block.
+	err = chain.db.Update(func(dbTx database.Tx) error {
+		return dbPutSpendJournalEntry(dbTx, blocks[3].Hash(), nil)
+	})
+	if err != nil {
+		t.Fatalf("unable to corrupt spend journal: %v", err)
+	}
+
+	tests := []struct {
+		level, depth int32
+		fail         bool
+	}{
+		{VerifyLevelSanity, 0, false},
+		{VerifyLevelUndo, 1, false},
+		{VerifyLevelUndo, 2, true},
+		{VerifyLevelConnect, 0, true},
+	}
+	for _, test := range tests {
+		err := chain.VerifyChain(context.Background(), test.level,
+			test.depth)
+		if (err != nil) != test.fail {
+			t.Fatalf("VerifyChain(%d, %d): got error %v, want failure %v",
+				test.level, test.depth, err, test.fail)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package blockchain
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/gcash/bchd/wire"
+)
+
+// WeakBlockTargetFactor is the factor by which the target a weak block must
+// meet exceeds the target of its bits.  A weak block is thus found that many
+// times as often as a block on average.
+const WeakBlockTargetFactor = 32
+
+// CalcWeakBlockTarget returns the target the hash of a weak block with the
+// passed bits must not exceed.
+func CalcWeakBlockTarget(bits uint32) *big.Int {
+	target := CompactToBig(bits)
+	return target.Mul(target, big.NewInt(WeakBlockTargetFactor))
+}
+
+// IsWeakBlock returns whether the hash of the passed header meets the weak
+// block target of its bits without meeting the target itself.
+func IsWeakBlock(header *wire.BlockHeader) bool {
+	hash := header.BlockHash()
+	hashNum := HashToBig(&hash)
+	return hashNum.Cmp(CalcWeakBlockTarget(header.Bits)) <= 0 &&
+		hashNum.Cmp(CompactToBig(header.Bits)) > 0
+}
+
+// CheckWeakBlockHeader ensures the passed header is a valid header of a weak
+// block which extends the tip of the main chain.  The header must pass the same
+// checks as the header of a block, except that its hash only has to meet the
+// weak block target of its bits.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) CheckWeakBlockHeader(header *wire.BlockHeader) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	tip := b.bestChain.Tip()
+	if header.PrevBlock != tip.hash {
+		str := fmt.Sprintf("weak block previous block %v is not the tip "+
+			"of the main chain %v", header.PrevBlock, tip.hash)
+		return ruleError(ErrStaleWeakBlock, str)
+	}
+
+	err := checkBlockHeaderSanity(header, b.chainParams.PowLimit,
+		b.timeSource, BFNoPoWCheck)
+	if err != nil {
+		return err
+	}
+	hash := header.BlockHash()
+	hashNum := HashToBig(&hash)
+	target := CalcWeakBlockTarget(header.Bits)
+	if hashNum.Cmp(target) > 0 {
+		str := fmt.Sprintf("weak block hash of %064x is higher than "+
+			"expected max of %064x", hashNum, target)
+		return ruleError(ErrHighHash, str)
+	}
+
+	return b.checkBlockHeaderContext(header, tip, BFNone)
+}
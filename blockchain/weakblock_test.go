@@ -0,0 +1,61 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/wire"
+)
+
+// TestCheckWeakBlockHeader ensures weak block headers are only accepted when
+// they extend the tip of the main chain and meet the weak block target.
+func TestCheckWeakBlockHeader(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	chain, teardownFunc, err := chainSetup("weakblock", &params)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	// The weak block target of the regression test network is met by any
+	// hash, so the nonce decides whether the header is a weak block or a
+	// block.
+	header := wire.BlockHeader{
+		Version:   4,
+		PrevBlock: *params.GenesisHash,
+		Timestamp: time.Unix(params.GenesisBlock.Header.Timestamp.Unix()+1, 0),
+		Bits:      params.PowLimitBits,
+	}
+	for IsWeakBlock(&header) {
+		header.Nonce++
+	}
+	blockHeader := header
+	for !IsWeakBlock(&header) {
+		header.Nonce++
+	}
+
+	if err := chain.CheckWeakBlockHeader(&header); err != nil {
+		t.Fatalf("CheckWeakBlockHeader: unexpected error: %v", err)
+	}
+	if err := chain.CheckWeakBlockHeader(&blockHeader); err != nil {
+		t.Fatalf("CheckWeakBlockHeader: unexpected error for block: %v",
+			err)
+	}
+
+	stale := header
+	stale.PrevBlock = *chaincfg.MainNetParams.GenesisHash
+	err = chain.CheckWeakBlockHeader(&stale)
+	if !isRuleError(err, ErrStaleWeakBlock) {
+		t.Fatalf("CheckWeakBlockHeader: unexpected error for stale weak "+
+			"block: %v", err)
+	}
+
+	badBits := header
+	badBits.Bits = 0x1d00ffff
+	err = chain.CheckWeakBlockHeader(&badBits)
+	if !isRuleError(err, ErrHighHash) {
+		t.Fatalf("CheckWeakBlockHeader: unexpected error for weak block "+
+			"with bad bits: %v", err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/gcash/bchd/wire"
 )
@@ -47,6 +48,37 @@ func NewAddNodeCmd(addr string, subCmd AddNodeSubCmd) *AddNodeCmd {
 	}
 }
 
+// AnalyzeTransactionCmd defines the analyzetransaction JSON-RPC command.
+type AnalyzeTransactionCmd struct {
+	HexTx string
+}
+
+// NewAnalyzeTransactionCmd returns a new instance which can be used to issue
+// an analyzetransaction JSON-RPC command.
+func NewAnalyzeTransactionCmd(hexTx string) *AnalyzeTransactionCmd {
+	return &AnalyzeTransactionCmd{
+		HexTx: hexTx,
+	}
+}
+
+// BackupChainstateCmd defines the backupchainstate JSON-RPC command.
+type BackupChainstateCmd struct {
+	Destination   string
+	IncludeBlocks *bool `jsonrpcdefault:"true"`
+}
+
+// NewBackupChainstateCmd returns a new instance which can be used to issue a
+// backupchainstate JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewBackupChainstateCmd(destination string, includeBlocks *bool) *BackupChainstateCmd {
+	return &BackupChainstateCmd{
+		Destination:   destination,
+		IncludeBlocks: includeBlocks,
+	}
+}
+
 // TransactionInput represents the inputs to a transaction.  Specifically a
 // transaction hash and output number pair.
 type TransactionInput struct {
@@ -62,6 +94,24 @@ type TransactionOutputCashToken struct {
 	Commitment string // hex
 }
 
+// ClearBannedCmd defines the clearbanned JSON-RPC command.
+type ClearBannedCmd struct{}
+
+// NewClearBannedCmd returns a new instance which can be used to issue a
+// clearbanned JSON-RPC command.
+func NewClearBannedCmd() *ClearBannedCmd {
+	return &ClearBannedCmd{}
+}
+
+// CompactDatabaseCmd defines the compactdatabase JSON-RPC command.
+type CompactDatabaseCmd struct{}
+
+// NewCompactDatabaseCmd returns a new instance which can be used to issue a
+// compactdatabase JSON-RPC command.
+func NewCompactDatabaseCmd() *CompactDatabaseCmd {
+	return &CompactDatabaseCmd{}
+}
+
 // CreateRawTransactionCmd defines the createrawtransaction JSON-RPC command.
 type CreateRawTransactionCmd struct {
 	Inputs     []TransactionInput
@@ -120,6 +170,57 @@ func NewDecodeScriptCmd(hexScript string) *DecodeScriptCmd {
 	}
 }
 
+// GenerateBlockCmd defines the generateblock JSON-RPC command.
+type GenerateBlockCmd struct {
+	Address      string
+	Transactions []string
+}
+
+// NewGenerateBlockCmd returns a new instance which can be used to issue a
+// generateblock JSON-RPC command.
+func NewGenerateBlockCmd(address string, transactions []string) *GenerateBlockCmd {
+	return &GenerateBlockCmd{
+		Address:      address,
+		Transactions: transactions,
+	}
+}
+
+// GenerateToAddressCmd defines the generatetoaddress JSON-RPC command.
+type GenerateToAddressCmd struct {
+	NumBlocks uint32
+	Address   string
+	MaxTries  *int64 `jsonrpcdefault:"1000000"`
+}
+
+// NewGenerateToAddressCmd returns a new instance which can be used to issue a
+// generatetoaddress JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGenerateToAddressCmd(numBlocks uint32, address string, maxTries *int64) *GenerateToAddressCmd {
+	return &GenerateToAddressCmd{
+		NumBlocks: numBlocks,
+		Address:   address,
+		MaxTries:  maxTries,
+	}
+}
+
+// GetABLAStateCmd defines the getablastate JSON-RPC command.
+type GetABLAStateCmd struct {
+	BlockSizes *[]uint64
+}
+
+// NewGetABLAStateCmd returns a new instance which can be used to issue a
+// getablastate JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetABLAStateCmd(blockSizes *[]uint64) *GetABLAStateCmd {
+	return &GetABLAStateCmd{
+		BlockSizes: blockSizes,
+	}
+}
+
 // GetAddedNodeInfoCmd defines the getaddednodeinfo JSON-RPC command.
 type GetAddedNodeInfoCmd struct {
 	DNS  bool
@@ -160,6 +261,8 @@ func (v *VerbosityLevel) UnmarshalJSON(dat []byte) error {
 		*v = 1
 	case "2":
 		*v = 2
+	case "3":
+		*v = 3
 	default:
 		return errors.New("invalid VerbosityLevel value")
 	}
@@ -247,6 +350,69 @@ func NewGetBlockHeaderCmd(hash string, verbose *bool) *GetBlockHeaderCmd {
 	}
 }
 
+// HashOrHeight identifies a block by either its hex-encoded hash or its height.
+// It unmarshals from either a JSON string or a JSON number, and marshals heights
+// as numbers.
+type HashOrHeight string
+
+// UnmarshalJSON allows a HashOrHeight to unmarshal from either a string or a
+// number.
+func (h *HashOrHeight) UnmarshalJSON(dat []byte) error {
+	var height int32
+	if err := json.Unmarshal(dat, &height); err == nil {
+		*h = HashOrHeight(strconv.FormatInt(int64(height), 10))
+		return nil
+	}
+	var hash string
+	if err := json.Unmarshal(dat, &hash); err != nil {
+		return errors.New("invalid HashOrHeight value")
+	}
+	*h = HashOrHeight(hash)
+	return nil
+}
+
+// MarshalJSON marshals a HashOrHeight which holds a height as a number and
+// one which holds a hash as a string.
+func (h HashOrHeight) MarshalJSON() ([]byte, error) {
+	if _, err := strconv.ParseInt(string(h), 10, 32); err == nil {
+		return []byte(h), nil
+	}
+	return json.Marshal(string(h))
+}
+
+// GetBlockStatsCmd defines the getblockstats JSON-RPC command.
+type GetBlockStatsCmd struct {
+	HashOrHeight HashOrHeight
+	Stats        *[]string
+	EndHeight    *int32
+}
+
+// NewGetBlockStatsCmd returns a new instance which can be used to issue a
+// getblockstats JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetBlockStatsCmd(hashOrHeight HashOrHeight, stats *[]string, endHeight *int32) *GetBlockStatsCmd {
+	return &GetBlockStatsCmd{
+		HashOrHeight: hashOrHeight,
+		Stats:        stats,
+		EndHeight:    endHeight,
+	}
+}
+
+// GetBlockUndoDataCmd defines the getblockundodata JSON-RPC command.
+type GetBlockUndoDataCmd struct {
+	HashOrHeight HashOrHeight
+}
+
+// NewGetBlockUndoDataCmd returns a new instance which can be used to issue a
+// getblockundodata JSON-RPC command.
+func NewGetBlockUndoDataCmd(hashOrHeight HashOrHeight) *GetBlockUndoDataCmd {
+	return &GetBlockUndoDataCmd{
+		HashOrHeight: hashOrHeight,
+	}
+}
+
 // TemplateRequest is a request object as defined in BIP22
 // (https://en.bitcoin.it/wiki/BIP_0022), it is optionally provided as an
 // pointer argument to GetBlockTemplateCmd.
@@ -375,6 +541,25 @@ func NewGetChainTipsCmd() *GetChainTipsCmd {
 	return &GetChainTipsCmd{}
 }
 
+// GetCheckpointCandidatesCmd defines the getcheckpointcandidates JSON-RPC
+// command.
+type GetCheckpointCandidatesCmd struct {
+	Count    *int   `jsonrpcdefault:"1"`
+	Interval *int32 `jsonrpcdefault:"2016"`
+}
+
+// NewGetCheckpointCandidatesCmd returns a new instance which can be used to
+// issue a getcheckpointcandidates JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetCheckpointCandidatesCmd(count *int, interval *int32) *GetCheckpointCandidatesCmd {
+	return &GetCheckpointCandidatesCmd{
+		Count:    count,
+		Interval: interval,
+	}
+}
+
 // GetConnectionCountCmd defines the getconnectioncount JSON-RPC command.
 type GetConnectionCountCmd struct{}
 
@@ -384,6 +569,15 @@ func NewGetConnectionCountCmd() *GetConnectionCountCmd {
 	return &GetConnectionCountCmd{}
 }
 
+// GetDatabaseInfoCmd defines the getdatabaseinfo JSON-RPC command.
+type GetDatabaseInfoCmd struct{}
+
+// NewGetDatabaseInfoCmd returns a new instance which can be used to issue a
+// getdatabaseinfo JSON-RPC command.
+func NewGetDatabaseInfoCmd() *GetDatabaseInfoCmd {
+	return &GetDatabaseInfoCmd{}
+}
+
 // GetDifficultyCmd defines the getdifficulty JSON-RPC command.
 type GetDifficultyCmd struct{}
 
@@ -393,6 +587,19 @@ func NewGetDifficultyCmd() *GetDifficultyCmd {
 	return &GetDifficultyCmd{}
 }
 
+// GetFirstSeenCmd defines the getfirstseen JSON-RPC command.
+type GetFirstSeenCmd struct {
+	Hash string
+}
+
+// NewGetFirstSeenCmd returns a new instance which can be used to issue a
+// getfirstseen JSON-RPC command.
+func NewGetFirstSeenCmd(hash string) *GetFirstSeenCmd {
+	return &GetFirstSeenCmd{
+		Hash: hash,
+	}
+}
+
 // GetGenerateCmd defines the getgenerate JSON-RPC command.
 type GetGenerateCmd struct{}
 
@@ -487,6 +694,26 @@ func NewGetNetworkHashPSCmd(numBlocks, height *int) *GetNetworkHashPSCmd {
 	}
 }
 
+// GetNextBlockParamsCmd defines the getnextblockparams JSON-RPC command.
+type GetNextBlockParamsCmd struct {
+	PrevHash  *string
+	Headers   *[]string
+	Timestamp *int64
+}
+
+// NewGetNextBlockParamsCmd returns a new instance which can be used to issue a
+// getnextblockparams JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetNextBlockParamsCmd(prevHash *string, headers *[]string, timestamp *int64) *GetNextBlockParamsCmd {
+	return &GetNextBlockParamsCmd{
+		PrevHash:  prevHash,
+		Headers:   headers,
+		Timestamp: timestamp,
+	}
+}
+
 // GetPeerInfoCmd defines the getpeerinfo JSON-RPC command.
 type GetPeerInfoCmd struct{}
 
@@ -625,6 +852,15 @@ func NewInvalidateBlockCmd(blockHash string) *InvalidateBlockCmd {
 	}
 }
 
+// ListBannedCmd defines the listbanned JSON-RPC command.
+type ListBannedCmd struct{}
+
+// NewListBannedCmd returns a new instance which can be used to issue a
+// listbanned JSON-RPC command.
+func NewListBannedCmd() *ListBannedCmd {
+	return &ListBannedCmd{}
+}
+
 // PingCmd defines the ping JSON-RPC command.
 type PingCmd struct{}
 
@@ -660,6 +896,15 @@ func NewReconsiderBlockCmd(blockHash string) *ReconsiderBlockCmd {
 	}
 }
 
+// ReloadConfigCmd defines the reloadconfig JSON-RPC command.
+type ReloadConfigCmd struct{}
+
+// NewReloadConfigCmd returns a new instance which can be used to issue a
+// reloadconfig JSON-RPC command.
+func NewReloadConfigCmd() *ReloadConfigCmd {
+	return &ReloadConfigCmd{}
+}
+
 // SearchRawTransactionsCmd defines the searchrawtransactions JSON-RPC command.
 type SearchRawTransactionsCmd struct {
 	Address     string
@@ -706,6 +951,41 @@ func NewSendRawTransactionCmd(hexTx string, allowHighFees *bool) *SendRawTransac
 	}
 }
 
+// SetBanSubCmd defines the type used in the setban JSON-RPC command for the
+// sub command field.
+type SetBanSubCmd string
+
+const (
+	// SBAdd indicates the specified IP or subnet should be banned.
+	SBAdd SetBanSubCmd = "add"
+
+	// SBRemove indicates the ban of the specified IP or subnet should be
+	// removed.
+	SBRemove SetBanSubCmd = "remove"
+)
+
+// SetBanCmd defines the setban JSON-RPC command.
+type SetBanCmd struct {
+	SubNet   string
+	SubCmd   SetBanSubCmd `jsonrpcusage:"\"add|remove\""`
+	BanTime  *int64       `jsonrpcdefault:"0"`
+	Absolute *bool        `jsonrpcdefault:"false"`
+}
+
+// NewSetBanCmd returns a new instance which can be used to issue a setban
+// JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewSetBanCmd(subNet string, subCmd SetBanSubCmd, banTime *int64, absolute *bool) *SetBanCmd {
+	return &SetBanCmd{
+		SubNet:   subNet,
+		SubCmd:   subCmd,
+		BanTime:  banTime,
+		Absolute: absolute,
+	}
+}
+
 // SetGenerateCmd defines the setgenerate JSON-RPC command.
 type SetGenerateCmd struct {
 	Generate     bool
@@ -724,6 +1004,33 @@ func NewSetGenerateCmd(generate bool, genProcLimit *int) *SetGenerateCmd {
 	}
 }
 
+// RelayPolicyOptions represents the relay policy parameters provided with a
+// SetRelayPolicyCmd command.  Parameters which are not set are left unchanged.
+type RelayPolicyOptions struct {
+	MinRelayTxFee        *float64  `json:"minrelaytxfee,omitempty"`
+	DustRelayFee         *float64  `json:"dustrelayfee,omitempty"`
+	MaxTxSigChecks       *int      `json:"maxtxsigchecks,omitempty"`
+	DataCarrierSize      *int      `json:"datacarriersize,omitempty"`
+	MaxDataCarriers      *int      `json:"maxdatacarriers,omitempty"`
+	DataCarrierProtocols *[]string `json:"datacarrierprotocols,omitempty"`
+}
+
+// SetRelayPolicyCmd defines the setrelaypolicy JSON-RPC command.
+type SetRelayPolicyCmd struct {
+	Options *RelayPolicyOptions
+}
+
+// NewSetRelayPolicyCmd returns a new instance which can be used to issue a
+// setrelaypolicy JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewSetRelayPolicyCmd(options *RelayPolicyOptions) *SetRelayPolicyCmd {
+	return &SetRelayPolicyCmd{
+		Options: options,
+	}
+}
+
 // StopCmd defines the stop JSON-RPC command.
 type StopCmd struct{}
 
@@ -738,6 +1045,10 @@ func NewStopCmd() *StopCmd {
 type SubmitBlockOptions struct {
 	// must be provided if server provided a workid with template.
 	WorkID string `json:"workid,omitempty"`
+
+	// Verbose requests a SubmitBlockResult describing why the block was
+	// rejected instead of the rejection reason string.
+	Verbose bool `json:"verbose,omitempty"`
 }
 
 // SubmitBlockCmd defines the submitblock JSON-RPC command.
@@ -758,6 +1069,19 @@ func NewSubmitBlockCmd(hexBlock string, options *SubmitBlockOptions) *SubmitBloc
 	}
 }
 
+// TestMempoolAcceptCmd defines the testmempoolaccept JSON-RPC command.
+type TestMempoolAcceptCmd struct {
+	RawTxs []string
+}
+
+// NewTestMempoolAcceptCmd returns a new instance which can be used to issue a
+// testmempoolaccept JSON-RPC command.
+func NewTestMempoolAcceptCmd(rawTxs []string) *TestMempoolAcceptCmd {
+	return &TestMempoolAcceptCmd{
+		RawTxs: rawTxs,
+	}
+}
+
 // UptimeCmd defines the uptime JSON-RPC command.
 type UptimeCmd struct{}
 
@@ -832,22 +1156,34 @@ func init() {
 	flags := UsageFlag(0)
 
 	MustRegisterCmd("addnode", (*AddNodeCmd)(nil), flags)
+	MustRegisterCmd("analyzetransaction", (*AnalyzeTransactionCmd)(nil), flags)
+	MustRegisterCmd("backupchainstate", (*BackupChainstateCmd)(nil), flags)
+	MustRegisterCmd("clearbanned", (*ClearBannedCmd)(nil), flags)
+	MustRegisterCmd("compactdatabase", (*CompactDatabaseCmd)(nil), flags)
 	MustRegisterCmd("createrawtransaction", (*CreateRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("decoderawtransaction", (*DecodeRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("decodescript", (*DecodeScriptCmd)(nil), flags)
+	MustRegisterCmd("generateblock", (*GenerateBlockCmd)(nil), flags)
+	MustRegisterCmd("generatetoaddress", (*GenerateToAddressCmd)(nil), flags)
 	MustRegisterCmd("getaddednodeinfo", (*GetAddedNodeInfoCmd)(nil), flags)
+	MustRegisterCmd("getablastate", (*GetABLAStateCmd)(nil), flags)
 	MustRegisterCmd("getbestblockhash", (*GetBestBlockHashCmd)(nil), flags)
 	MustRegisterCmd("getblock", (*GetBlockCmd)(nil), flags)
 	MustRegisterCmd("getblockchaininfo", (*GetBlockChainInfoCmd)(nil), flags)
 	MustRegisterCmd("getblockcount", (*GetBlockCountCmd)(nil), flags)
 	MustRegisterCmd("getblockhash", (*GetBlockHashCmd)(nil), flags)
 	MustRegisterCmd("getblockheader", (*GetBlockHeaderCmd)(nil), flags)
+	MustRegisterCmd("getblockstats", (*GetBlockStatsCmd)(nil), flags)
+	MustRegisterCmd("getblockundodata", (*GetBlockUndoDataCmd)(nil), flags)
 	MustRegisterCmd("getblocktemplate", (*GetBlockTemplateCmd)(nil), flags)
 	MustRegisterCmd("getcfilter", (*GetCFilterCmd)(nil), flags)
 	MustRegisterCmd("getcfilterheader", (*GetCFilterHeaderCmd)(nil), flags)
 	MustRegisterCmd("getchaintips", (*GetChainTipsCmd)(nil), flags)
+	MustRegisterCmd("getcheckpointcandidates", (*GetCheckpointCandidatesCmd)(nil), flags)
 	MustRegisterCmd("getconnectioncount", (*GetConnectionCountCmd)(nil), flags)
+	MustRegisterCmd("getdatabaseinfo", (*GetDatabaseInfoCmd)(nil), flags)
 	MustRegisterCmd("getdifficulty", (*GetDifficultyCmd)(nil), flags)
+	MustRegisterCmd("getfirstseen", (*GetFirstSeenCmd)(nil), flags)
 	MustRegisterCmd("getgenerate", (*GetGenerateCmd)(nil), flags)
 	MustRegisterCmd("gethashespersec", (*GetHashesPerSecCmd)(nil), flags)
 	MustRegisterCmd("getinfo", (*GetInfoCmd)(nil), flags)
@@ -857,6 +1193,7 @@ func init() {
 	MustRegisterCmd("getnetworkinfo", (*GetNetworkInfoCmd)(nil), flags)
 	MustRegisterCmd("getnettotals", (*GetNetTotalsCmd)(nil), flags)
 	MustRegisterCmd("getnetworkhashps", (*GetNetworkHashPSCmd)(nil), flags)
+	MustRegisterCmd("getnextblockparams", (*GetNextBlockParamsCmd)(nil), flags)
 	MustRegisterCmd("getpeerinfo", (*GetPeerInfoCmd)(nil), flags)
 	MustRegisterCmd("getrawmempool", (*GetRawMempoolCmd)(nil), flags)
 	MustRegisterCmd("getrawtransaction", (*GetRawTransactionCmd)(nil), flags)
@@ -866,14 +1203,19 @@ func init() {
 	MustRegisterCmd("getwork", (*GetWorkCmd)(nil), flags)
 	MustRegisterCmd("help", (*HelpCmd)(nil), flags)
 	MustRegisterCmd("invalidateblock", (*InvalidateBlockCmd)(nil), flags)
+	MustRegisterCmd("listbanned", (*ListBannedCmd)(nil), flags)
 	MustRegisterCmd("ping", (*PingCmd)(nil), flags)
 	MustRegisterCmd("preciousblock", (*PreciousBlockCmd)(nil), flags)
 	MustRegisterCmd("reconsiderblock", (*ReconsiderBlockCmd)(nil), flags)
+	MustRegisterCmd("reloadconfig", (*ReloadConfigCmd)(nil), flags)
 	MustRegisterCmd("searchrawtransactions", (*SearchRawTransactionsCmd)(nil), flags)
 	MustRegisterCmd("sendrawtransaction", (*SendRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("setban", (*SetBanCmd)(nil), flags)
 	MustRegisterCmd("setgenerate", (*SetGenerateCmd)(nil), flags)
+	MustRegisterCmd("setrelaypolicy", (*SetRelayPolicyCmd)(nil), flags)
 	MustRegisterCmd("stop", (*StopCmd)(nil), flags)
 	MustRegisterCmd("submitblock", (*SubmitBlockCmd)(nil), flags)
+	MustRegisterCmd("testmempoolaccept", (*TestMempoolAcceptCmd)(nil), flags)
 	MustRegisterCmd("uptime", (*UptimeCmd)(nil), flags)
 	MustRegisterCmd("validateaddress", (*ValidateAddressCmd)(nil), flags)
 	MustRegisterCmd("verifychain", (*VerifyChainCmd)(nil), flags)
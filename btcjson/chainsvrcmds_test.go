@@ -41,6 +41,69 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"addnode","params":["127.0.0.1","remove"],"id":1}`,
 			unmarshalled: &btcjson.AddNodeCmd{Addr: "127.0.0.1", SubCmd: btcjson.ANRemove},
 		},
+		{
+			name: "analyzetransaction",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("analyzetransaction", "123")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAnalyzeTransactionCmd("123")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"analyzetransaction","params":["123"],"id":1}`,
+			unmarshalled: &btcjson.AnalyzeTransactionCmd{
+				HexTx: "123",
+			},
+		},
+		{
+			name: "backupchainstate",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("backupchainstate", "/tmp/chainstate.bak")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewBackupChainstateCmd("/tmp/chainstate.bak", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"backupchainstate","params":["/tmp/chainstate.bak"],"id":1}`,
+			unmarshalled: &btcjson.BackupChainstateCmd{
+				Destination:   "/tmp/chainstate.bak",
+				IncludeBlocks: btcjson.Bool(true),
+			},
+		},
+		{
+			name: "backupchainstate optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("backupchainstate", "/tmp/chainstate.bak", false)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewBackupChainstateCmd("/tmp/chainstate.bak", btcjson.Bool(false))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"backupchainstate","params":["/tmp/chainstate.bak",false],"id":1}`,
+			unmarshalled: &btcjson.BackupChainstateCmd{
+				Destination:   "/tmp/chainstate.bak",
+				IncludeBlocks: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "clearbanned",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("clearbanned")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewClearBannedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"clearbanned","params":[],"id":1}`,
+			unmarshalled: &btcjson.ClearBannedCmd{},
+		},
+		{
+			name: "compactdatabase",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("compactdatabase")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCompactDatabaseCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"compactdatabase","params":[],"id":1}`,
+			unmarshalled: &btcjson.CompactDatabaseCmd{},
+		},
 		{
 			name: "createrawtransaction",
 			newCmd: func() (interface{}, error) {
@@ -124,6 +187,35 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"decodescript","params":["00"],"id":1}`,
 			unmarshalled: &btcjson.DecodeScriptCmd{HexScript: "00"},
 		},
+		{
+			name: "generateblock",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generateblock", "bchreg:qq", []string{"00"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGenerateBlockCmd("bchreg:qq", []string{"00"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generateblock","params":["bchreg:qq",["00"]],"id":1}`,
+			unmarshalled: &btcjson.GenerateBlockCmd{
+				Address:      "bchreg:qq",
+				Transactions: []string{"00"},
+			},
+		},
+		{
+			name: "generatetoaddress",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generatetoaddress", 1, "bchreg:qq")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGenerateToAddressCmd(1, "bchreg:qq", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generatetoaddress","params":[1,"bchreg:qq"],"id":1}`,
+			unmarshalled: &btcjson.GenerateToAddressCmd{
+				NumBlocks: 1,
+				Address:   "bchreg:qq",
+				MaxTries:  btcjson.Int64(1000000),
+			},
+		},
 		{
 			name: "getaddednodeinfo",
 			newCmd: func() (interface{}, error) {
@@ -149,6 +241,30 @@ func TestChainSvrCmds(t *testing.T) {
 				Node: btcjson.String("127.0.0.1"),
 			},
 		},
+		{
+			name: "getablastate",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getablastate")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetABLAStateCmd(nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getablastate","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetABLAStateCmd{},
+		},
+		{
+			name: "getablastate optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getablastate", `[32000000,1000]`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetABLAStateCmd(&[]uint64{32000000, 1000})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getablastate","params":[[32000000,1000]],"id":1}`,
+			unmarshalled: &btcjson.GetABLAStateCmd{
+				BlockSizes: &[]uint64{32000000, 1000},
+			},
+		},
 		{
 			name: "getbestblockhash",
 			newCmd: func() (interface{}, error) {
@@ -216,6 +332,20 @@ func TestChainSvrCmds(t *testing.T) {
 				Verbosity: btcjson.Verbositylevel(2),
 			},
 		},
+		{
+			name: "getblock required optional3",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblock", "123", btcjson.Int(3))
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockCmd("123", btcjson.Verbositylevel(3))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblock","params":["123",3],"id":1}`,
+			unmarshalled: &btcjson.GetBlockCmd{
+				Hash:      "123",
+				Verbosity: btcjson.Verbositylevel(3),
+			},
+		},
 		{
 			name: "getblock required optional string true",
 			newCmd: func() (interface{}, error) {
@@ -319,6 +449,48 @@ func TestChainSvrCmds(t *testing.T) {
 				Verbose: btcjson.Bool(true),
 			},
 		},
+		{
+			name: "getblockstats height",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblockstats", "1000")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockStatsCmd("1000", nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockstats","params":[1000],"id":1}`,
+			unmarshalled: &btcjson.GetBlockStatsCmd{
+				HashOrHeight: "1000",
+			},
+		},
+		{
+			name: "getblockstats optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblockstats", "000000000000000000a3b3a2", `["txs","totalfee"]`, 1010)
+			},
+			staticCmd: func() interface{} {
+				stats := []string{"txs", "totalfee"}
+				return btcjson.NewGetBlockStatsCmd("000000000000000000a3b3a2", &stats, btcjson.Int32(1010))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockstats","params":["000000000000000000a3b3a2",["txs","totalfee"],1010],"id":1}`,
+			unmarshalled: &btcjson.GetBlockStatsCmd{
+				HashOrHeight: "000000000000000000a3b3a2",
+				Stats:        &[]string{"txs", "totalfee"},
+				EndHeight:    btcjson.Int32(1010),
+			},
+		},
+		{
+			name: "getblockundodata",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblockundodata", "000000000000000000a3b3a2")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockUndoDataCmd("000000000000000000a3b3a2")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockundodata","params":["000000000000000000a3b3a2"],"id":1}`,
+			unmarshalled: &btcjson.GetBlockUndoDataCmd{
+				HashOrHeight: "000000000000000000a3b3a2",
+			},
+		},
 		{
 			name: "getblocktemplate",
 			newCmd: func() (interface{}, error) {
@@ -445,6 +617,35 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getchaintips","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetChainTipsCmd{},
 		},
+		{
+			name: "getcheckpointcandidates",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getcheckpointcandidates")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetCheckpointCandidatesCmd(nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getcheckpointcandidates","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetCheckpointCandidatesCmd{
+				Count:    btcjson.Int(1),
+				Interval: btcjson.Int32(2016),
+			},
+		},
+		{
+			name: "getcheckpointcandidates optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getcheckpointcandidates", 3, 1000)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetCheckpointCandidatesCmd(
+					btcjson.Int(3), btcjson.Int32(1000))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getcheckpointcandidates","params":[3,1000],"id":1}`,
+			unmarshalled: &btcjson.GetCheckpointCandidatesCmd{
+				Count:    btcjson.Int(3),
+				Interval: btcjson.Int32(1000),
+			},
+		},
 		{
 			name: "getconnectioncount",
 			newCmd: func() (interface{}, error) {
@@ -456,6 +657,17 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getconnectioncount","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetConnectionCountCmd{},
 		},
+		{
+			name: "getdatabaseinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getdatabaseinfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetDatabaseInfoCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getdatabaseinfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetDatabaseInfoCmd{},
+		},
 		{
 			name: "getdifficulty",
 			newCmd: func() (interface{}, error) {
@@ -467,6 +679,19 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getdifficulty","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetDifficultyCmd{},
 		},
+		{
+			name: "getfirstseen",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getfirstseen", "123")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetFirstSeenCmd("123")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getfirstseen","params":["123"],"id":1}`,
+			unmarshalled: &btcjson.GetFirstSeenCmd{
+				Hash: "123",
+			},
+		},
 		{
 			name: "getgenerate",
 			newCmd: func() (interface{}, error) {
@@ -599,6 +824,34 @@ func TestChainSvrCmds(t *testing.T) {
 				Height: btcjson.Int(123),
 			},
 		},
+		{
+			name: "getnextblockparams",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getnextblockparams")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetNextBlockParamsCmd(nil, nil, nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getnextblockparams","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetNextBlockParamsCmd{},
+		},
+		{
+			name: "getnextblockparams optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getnextblockparams", "123", `["00","01"]`, 1600000000)
+			},
+			staticCmd: func() interface{} {
+				headers := []string{"00", "01"}
+				return btcjson.NewGetNextBlockParamsCmd(btcjson.String("123"),
+					&headers, btcjson.Int64(1600000000))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getnextblockparams","params":["123",["00","01"],1600000000],"id":1}`,
+			unmarshalled: &btcjson.GetNextBlockParamsCmd{
+				PrevHash:  btcjson.String("123"),
+				Headers:   &[]string{"00", "01"},
+				Timestamp: btcjson.Int64(1600000000),
+			},
+		},
 		{
 			name: "getpeerinfo",
 			newCmd: func() (interface{}, error) {
@@ -814,6 +1067,17 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "123",
 			},
 		},
+		{
+			name: "listbanned",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("listbanned")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewListBannedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"listbanned","params":[],"id":1}`,
+			unmarshalled: &btcjson.ListBannedCmd{},
+		},
 		{
 			name: "ping",
 			newCmd: func() (interface{}, error) {
@@ -851,6 +1115,17 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "123",
 			},
 		},
+		{
+			name: "reloadconfig",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("reloadconfig")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewReloadConfigCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"reloadconfig","params":[],"id":1}`,
+			unmarshalled: &btcjson.ReloadConfigCmd{},
+		},
 		{
 			name: "searchrawtransactions",
 			newCmd: func() (interface{}, error) {
@@ -1037,6 +1312,39 @@ func TestChainSvrCmds(t *testing.T) {
 				AllowHighFees: btcjson.Bool(false),
 			},
 		},
+		{
+			name: "setban",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setban", "10.0.0.0/8", btcjson.SBAdd)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetBanCmd("10.0.0.0/8", btcjson.SBAdd, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setban","params":["10.0.0.0/8","add"],"id":1}`,
+			unmarshalled: &btcjson.SetBanCmd{
+				SubNet:   "10.0.0.0/8",
+				SubCmd:   btcjson.SBAdd,
+				BanTime:  btcjson.Int64(0),
+				Absolute: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "setban optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setban", "1.2.3.4", btcjson.SBAdd, 1700000000, true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetBanCmd("1.2.3.4", btcjson.SBAdd,
+					btcjson.Int64(1700000000), btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setban","params":["1.2.3.4","add",1700000000,true],"id":1}`,
+			unmarshalled: &btcjson.SetBanCmd{
+				SubNet:   "1.2.3.4",
+				SubCmd:   btcjson.SBAdd,
+				BanTime:  btcjson.Int64(1700000000),
+				Absolute: btcjson.Bool(true),
+			},
+		},
 		{
 			name: "setgenerate",
 			newCmd: func() (interface{}, error) {
@@ -1065,6 +1373,40 @@ func TestChainSvrCmds(t *testing.T) {
 				GenProcLimit: btcjson.Int(6),
 			},
 		},
+		{
+			name: "setrelaypolicy",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setrelaypolicy")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetRelayPolicyCmd(nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setrelaypolicy","params":[],"id":1}`,
+			unmarshalled: &btcjson.SetRelayPolicyCmd{
+				Options: nil,
+			},
+		},
+		{
+			name: "setrelaypolicy optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setrelaypolicy",
+					`{"minrelaytxfee":0.0001,"datacarrierprotocols":["534c5000"]}`)
+			},
+			staticCmd: func() interface{} {
+				options := btcjson.RelayPolicyOptions{
+					MinRelayTxFee:        btcjson.Float64(0.0001),
+					DataCarrierProtocols: &[]string{"534c5000"},
+				}
+				return btcjson.NewSetRelayPolicyCmd(&options)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setrelaypolicy","params":[{"minrelaytxfee":0.0001,"datacarrierprotocols":["534c5000"]}],"id":1}`,
+			unmarshalled: &btcjson.SetRelayPolicyCmd{
+				Options: &btcjson.RelayPolicyOptions{
+					MinRelayTxFee:        btcjson.Float64(0.0001),
+					DataCarrierProtocols: &[]string{"534c5000"},
+				},
+			},
+		},
 		{
 			name: "stop",
 			newCmd: func() (interface{}, error) {
@@ -1109,6 +1451,38 @@ func TestChainSvrCmds(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "submitblock verbose",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("submitblock", "112233", `{"verbose":true}`)
+			},
+			staticCmd: func() interface{} {
+				options := btcjson.SubmitBlockOptions{
+					Verbose: true,
+				}
+				return btcjson.NewSubmitBlockCmd("112233", &options)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"submitblock","params":["112233",{"verbose":true}],"id":1}`,
+			unmarshalled: &btcjson.SubmitBlockCmd{
+				HexBlock: "112233",
+				Options: &btcjson.SubmitBlockOptions{
+					Verbose: true,
+				},
+			},
+		},
+		{
+			name: "testmempoolaccept",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("testmempoolaccept", []string{"1122", "3344"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewTestMempoolAcceptCmd([]string{"1122", "3344"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"testmempoolaccept","params":[["1122","3344"]],"id":1}`,
+			unmarshalled: &btcjson.TestMempoolAcceptCmd{
+				RawTxs: []string{"1122", "3344"},
+			},
+		},
 		{
 			name: "uptime",
 			newCmd: func() (interface{}, error) {
@@ -80,6 +80,26 @@ type AddMultisigAddressResult struct {
 	RedeemScript string `json:"redeemScript"`
 }
 
+// BackupChainstateResult models the data from the backupchainstate command.
+type BackupChainstateResult struct {
+	Destination string  `json:"destination"`
+	Size        int64   `json:"size"`
+	Duration    float64 `json:"duration"`
+}
+
+// CompactDatabaseResult models the data from the compactdatabase command.
+type CompactDatabaseResult struct {
+	SizeBefore int64   `json:"sizebefore"`
+	SizeAfter  int64   `json:"sizeafter"`
+	Reclaimed  int64   `json:"reclaimed"`
+	Duration   float64 `json:"duration"`
+}
+
+// GenerateBlockResult models the data from the generateblock command.
+type GenerateBlockResult struct {
+	Hash string `json:"hash"`
+}
+
 // CreateMultiSigResult models the data returned from the createmultisig
 // command.
 type CreateMultiSigResult struct {
@@ -130,6 +150,25 @@ type Bip9SoftForkDescription struct {
 	Since     int32  `json:"since"`
 }
 
+// ABLAStateResult models the state of the adaptive block size limit algorithm
+// which applies to a block.
+type ABLAStateResult struct {
+	Height            int32  `json:"height"`
+	ControlBlockSize  uint64 `json:"controlblocksize"`
+	ElasticBufferSize uint64 `json:"elasticbuffersize"`
+	SizeLimit         uint64 `json:"sizelimit"`
+}
+
+// GetABLAStateResult models the data returned from the getablastate command.
+type GetABLAStateResult struct {
+	Height            int32             `json:"height"`
+	Active            bool              `json:"active"`
+	ControlBlockSize  uint64            `json:"controlblocksize"`
+	ElasticBufferSize uint64            `json:"elasticbuffersize"`
+	SizeLimit         uint64            `json:"sizelimit"`
+	Projection        []ABLAStateResult `json:"projection,omitempty"`
+}
+
 // GetBlockChainInfoResult models the data returned from the getblockchaininfo
 // command.
 type GetBlockChainInfoResult struct {
@@ -140,6 +179,9 @@ type GetBlockChainInfoResult struct {
 	Difficulty           float64                             `json:"difficulty"`
 	MedianTime           int64                               `json:"mediantime"`
 	VerificationProgress float64                             `json:"verificationprogress,omitempty"`
+	InitialBlockDownload bool                                `json:"initialblockdownload"`
+	EstimatedRemaining   int64                               `json:"remainingtime,omitempty"`
+	SizeOnDisk           int64                               `json:"size_on_disk"`
 	SyncHeight           uint64                              `json:"syncheight,omitempty"`
 	Pruned               bool                                `json:"pruned"`
 	PruneHeight          int32                               `json:"pruneheight,omitempty"`
@@ -165,6 +207,53 @@ type GetBlockTemplateResultAux struct {
 	Flags string `json:"flags"`
 }
 
+// GetBlockStatsResult models the data returned from the getblockstats command.
+// Only the statistics which were requested are set.  Amounts are in satoshis,
+// sizes in bytes and fee rates in satoshis per byte.
+type GetBlockStatsResult struct {
+	AvgFee             *int64  `json:"avgfee,omitempty"`
+	AvgFeeRate         *int64  `json:"avgfeerate,omitempty"`
+	AvgTxSize          *int64  `json:"avgtxsize,omitempty"`
+	BlockHash          string  `json:"blockhash,omitempty"`
+	FeeRatePercentiles []int64 `json:"feerate_percentiles,omitempty"`
+	Height             *int32  `json:"height,omitempty"`
+	Ins                *int64  `json:"ins,omitempty"`
+	MaxFee             *int64  `json:"maxfee,omitempty"`
+	MaxFeeRate         *int64  `json:"maxfeerate,omitempty"`
+	MaxTxSize          *int64  `json:"maxtxsize,omitempty"`
+	MedianFee          *int64  `json:"medianfee,omitempty"`
+	MedianTime         *int64  `json:"mediantime,omitempty"`
+	MedianTxSize       *int64  `json:"mediantxsize,omitempty"`
+	MinFee             *int64  `json:"minfee,omitempty"`
+	MinFeeRate         *int64  `json:"minfeerate,omitempty"`
+	MinTxSize          *int64  `json:"mintxsize,omitempty"`
+	Outs               *int64  `json:"outs,omitempty"`
+	SigChecks          *int64  `json:"sigchecks,omitempty"`
+	Subsidy            *int64  `json:"subsidy,omitempty"`
+	Time               *int64  `json:"time,omitempty"`
+	TotalOut           *int64  `json:"total_out,omitempty"`
+	TotalSize          *int64  `json:"total_size,omitempty"`
+	TotalFee           *int64  `json:"totalfee,omitempty"`
+	Txs                *int64  `json:"txs,omitempty"`
+	UtxoIncrease       *int64  `json:"utxo_increase,omitempty"`
+	UtxoSizeInc        *int64  `json:"utxo_size_inc,omitempty"`
+}
+
+// TxUndoResult models the outputs spent by the inputs of a transaction as
+// returned from the getblockundodata command.
+type TxUndoResult struct {
+	Txid     string              `json:"txid"`
+	PrevOuts []SpentOutputResult `json:"prevouts"`
+}
+
+// GetBlockUndoDataResult models the data returned from the getblockundodata
+// command.
+type GetBlockUndoDataResult struct {
+	Hash   string         `json:"hash"`
+	Height int32          `json:"height"`
+	Tx     []TxUndoResult `json:"tx"`
+}
+
 // GetBlockTemplateResult models the data returned from the getblocktemplate
 // command.
 type GetBlockTemplateResult struct {
@@ -204,6 +293,42 @@ type GetBlockTemplateResult struct {
 	RejectReasion string   `json:"reject-reason,omitempty"`
 }
 
+// CheckpointCandidateResult models a checkpoint candidate returned by the
+// getcheckpointcandidates command.
+type CheckpointCandidateResult struct {
+	Height     int32  `json:"height"`
+	Hash       string `json:"hash"`
+	Time       int64  `json:"time"`
+	ChainWork  string `json:"chainwork"`
+	Checkpoint string `json:"checkpoint"`
+}
+
+// GetCheckpointCandidatesResult models the data returned from the
+// getcheckpointcandidates command.
+type GetCheckpointCandidatesResult struct {
+	MinimumChainWork string                      `json:"minimumchainwork,omitempty"`
+	Candidates       []CheckpointCandidateResult `json:"candidates"`
+}
+
+// GetChainTipsResult models the data returned from the getchaintips command.
+type GetChainTipsResult struct {
+	Height        int32  `json:"height"`
+	Hash          string `json:"hash"`
+	BranchLen     int32  `json:"branchlen"`
+	Status        string `json:"status"`
+	InvalidBlock  string `json:"invalidblock,omitempty"`
+	InvalidReason string `json:"invalidreason,omitempty"`
+}
+
+// GetDatabaseInfoResult models the data from the getdatabaseinfo command.
+type GetDatabaseInfoResult struct {
+	Type         string `json:"type"`
+	MetadataSize int64  `json:"metadatasize"`
+	BlocksSize   int64  `json:"blockssize"`
+	DeletedKeys  uint64 `json:"deletedkeys"`
+	AutoCompact  bool   `json:"autocompact"`
+}
+
 // GetMempoolEntryResult models the data returned from the getmempoolentry
 // command.
 type GetMempoolEntryResult struct {
@@ -226,8 +351,10 @@ type GetMempoolEntryResult struct {
 // GetMempoolInfoResult models the data returned from the getmempoolinfo
 // command.
 type GetMempoolInfoResult struct {
-	Size  int64 `json:"size"`
-	Bytes int64 `json:"bytes"`
+	Size          int64   `json:"size"`
+	Bytes         int64   `json:"bytes"`
+	MaxMempool    int64   `json:"maxmempool"`
+	MempoolMinFee float64 `json:"mempoolminfee"`
 }
 
 // NetworksResult models the networks data from the getnetworkinfo command.
@@ -265,6 +392,17 @@ type GetNetworkInfoResult struct {
 	Warnings        string                 `json:"warnings"`
 }
 
+// ListBannedResult models the data returned for each entry of the listbanned
+// command.
+type ListBannedResult struct {
+	Address       string `json:"address"`
+	BanCreated    int64  `json:"ban_created"`
+	BannedUntil   int64  `json:"banned_until"`
+	BanDuration   int64  `json:"ban_duration"`
+	TimeRemaining int64  `json:"time_remaining"`
+	BanReason     string `json:"ban_reason"`
+}
+
 // GetPeerInfoResult models the data returned from the getpeerinfo command.
 type GetPeerInfoResult struct {
 	ID             int32   `json:"id"`
@@ -290,6 +428,18 @@ type GetPeerInfoResult struct {
 	Whitelisted    bool    `json:"whitelisted"`
 	FeeFilter      int64   `json:"feefilter"`
 	SyncNode       bool    `json:"syncnode"`
+	MinPing        float64 `json:"minping,omitempty"`
+
+	BytesSentPerMsg map[string]uint64 `json:"bytessent_per_msg"`
+	BytesRecvPerMsg map[string]uint64 `json:"bytesrecv_per_msg"`
+
+	TxInvsReceived uint64  `json:"txinvsreceived"`
+	TxInvsNew      uint64  `json:"txinvsnew"`
+	TxInvHitRate   float64 `json:"txinvhitrate"`
+
+	CmpctBlocksReceived      uint64 `json:"cmpctblocksreceived"`
+	CmpctBlocksReconstructed uint64 `json:"cmpctblocksreconstructed"`
+	CmpctBlocksRoundTrip     uint64 `json:"cmpctblocksroundtrip"`
 }
 
 // GetRawMempoolVerboseResult models the data returned from the getrawmempool
@@ -324,11 +474,33 @@ type GetTxOutResult struct {
 	Coinbase      bool               `json:"coinbase"`
 }
 
+// GetTxOutSetInfoResult models the data from the gettxoutsetinfo command.
+type GetTxOutSetInfoResult struct {
+	Height         int32   `json:"height"`
+	BestBlock      string  `json:"bestblock"`
+	TxOuts         uint64  `json:"txouts"`
+	SerializedSize uint64  `json:"serialized_size"`
+	ECMH           string  `json:"ecmh"`
+	TotalAmount    float64 `json:"total_amount"`
+}
+
+// GetNetTotalsUploadTarget models the upload target data returned from the
+// getnettotals command.
+type GetNetTotalsUploadTarget struct {
+	TimeFrame             int64  `json:"timeframe"`
+	Target                uint64 `json:"target"`
+	TargetReached         bool   `json:"target_reached"`
+	ServeHistoricalBlocks bool   `json:"serve_historical_blocks"`
+	BytesLeftInCycle      uint64 `json:"bytes_left_in_cycle"`
+	TimeLeftInCycle       int64  `json:"time_left_in_cycle"`
+}
+
 // GetNetTotalsResult models the data returned from the getnettotals command.
 type GetNetTotalsResult struct {
-	TotalBytesRecv uint64 `json:"totalbytesrecv"`
-	TotalBytesSent uint64 `json:"totalbytessent"`
-	TimeMillis     int64  `json:"timemillis"`
+	TotalBytesRecv uint64                   `json:"totalbytesrecv"`
+	TotalBytesSent uint64                   `json:"totalbytessent"`
+	TimeMillis     int64                    `json:"timemillis"`
+	UploadTarget   GetNetTotalsUploadTarget `json:"uploadtarget"`
 }
 
 // ScriptSig models a signature script.  It is defined separately since it only
@@ -343,11 +515,12 @@ type ScriptSig struct {
 // getrawtransaction, decoderawtransaction, and searchrawtransaction use the
 // same structure.
 type Vin struct {
-	Coinbase  string     `json:"coinbase"`
-	Txid      string     `json:"txid"`
-	Vout      uint32     `json:"vout"`
-	ScriptSig *ScriptSig `json:"scriptSig"`
-	Sequence  uint32     `json:"sequence"`
+	Coinbase  string             `json:"coinbase"`
+	Txid      string             `json:"txid"`
+	Vout      uint32             `json:"vout"`
+	ScriptSig *ScriptSig         `json:"scriptSig"`
+	Sequence  uint32             `json:"sequence"`
+	PrevOut   *SpentOutputResult `json:"prevout,omitempty"`
 }
 
 // IsCoinBase returns a bool to show if a Vin is a Coinbase one or not.
@@ -369,19 +542,43 @@ func (v *Vin) MarshalJSON() ([]byte, error) {
 	}
 
 	txStruct := struct {
-		Txid      string     `json:"txid"`
-		Vout      uint32     `json:"vout"`
-		ScriptSig *ScriptSig `json:"scriptSig"`
-		Sequence  uint32     `json:"sequence"`
+		Txid      string             `json:"txid"`
+		Vout      uint32             `json:"vout"`
+		ScriptSig *ScriptSig         `json:"scriptSig"`
+		Sequence  uint32             `json:"sequence"`
+		PrevOut   *SpentOutputResult `json:"prevout,omitempty"`
 	}{
 		Txid:      v.Txid,
 		Vout:      v.Vout,
 		ScriptSig: v.ScriptSig,
 		Sequence:  v.Sequence,
+		PrevOut:   v.PrevOut,
 	}
 	return json.Marshal(txStruct)
 }
 
+// SpentOutputResult models the output spent by an input of a transaction.
+type SpentOutputResult struct {
+	Generated    bool               `json:"generated"`
+	Height       int32              `json:"height"`
+	Value        float64            `json:"value"`
+	ScriptPubKey ScriptPubKeyResult `json:"scriptPubKey"`
+	TokenData    *TokenDataResult   `json:"tokenData,omitempty"`
+}
+
+// TokenDataResult models the CashToken data of a transaction output.
+type TokenDataResult struct {
+	Category string          `json:"category"`
+	Amount   string          `json:"amount"`
+	NFT      *TokenNFTResult `json:"nft,omitempty"`
+}
+
+// TokenNFTResult models the non-fungible token of a transaction output.
+type TokenNFTResult struct {
+	Capability string `json:"capability"`
+	Commitment string `json:"commitment"`
+}
+
 // PrevOut represents previous output for an input Vin.
 type PrevOut struct {
 	Addresses []string `json:"addresses,omitempty"`
@@ -438,6 +635,7 @@ type Vout struct {
 	Value        float64            `json:"value"`
 	N            uint32             `json:"n"`
 	ScriptPubKey ScriptPubKeyResult `json:"scriptPubKey"`
+	TokenData    *TokenDataResult   `json:"tokenData,omitempty"`
 }
 
 // GetMiningInfoResult models the data from the getmininginfo command.
@@ -455,6 +653,20 @@ type GetMiningInfoResult struct {
 	TestNet          bool    `json:"testnet"`
 }
 
+// GetNextBlockParamsResult models the data returned from the getnextblockparams
+// command.
+type GetNextBlockParamsResult struct {
+	PreviousHash string  `json:"previousblockhash"`
+	Height       int32   `json:"height"`
+	Time         int64   `json:"time"`
+	Bits         string  `json:"bits"`
+	Target       string  `json:"target"`
+	Difficulty   float64 `json:"difficulty"`
+	MedianTime   int64   `json:"mediantime"`
+	MinTime      int64   `json:"mintime"`
+	SizeLimit    uint64  `json:"sizelimit,omitempty"`
+}
+
 // GetWorkResult models the data from the getwork command.
 type GetWorkResult struct {
 	Data     string `json:"data"`
@@ -463,6 +675,105 @@ type GetWorkResult struct {
 	Target   string `json:"target"`
 }
 
+// SubmitBlockResult models the data returned from the submitblock command when
+// the verbose option is set.  The location of the rule violation is only set
+// when the block was rejected because of a specific transaction, and the
+// script fields only when it failed script validation.
+type SubmitBlockResult struct {
+	Hash        string `json:"hash"`
+	Accepted    bool   `json:"accepted"`
+	Reason      string `json:"reason,omitempty"`
+	ErrorCode   string `json:"errorcode,omitempty"`
+	TxIndex     *int   `json:"txindex,omitempty"`
+	TxID        string `json:"txid,omitempty"`
+	InputIndex  *int   `json:"inputindex,omitempty"`
+	ScriptError string `json:"scripterror,omitempty"`
+	ScriptIndex *int   `json:"scriptindex,omitempty"`
+	OpcodeIndex *int   `json:"opcodeindex,omitempty"`
+	SavedFile   string `json:"savedfile,omitempty"`
+}
+
+// TestMempoolAcceptResult models the data returned for each transaction by the
+// testmempoolaccept command.  The size, fee and sigchecks are only set when
+// the transaction would be accepted, and the reject reason when it would not.
+type TestMempoolAcceptResult struct {
+	TxID         string   `json:"txid"`
+	Allowed      bool     `json:"allowed"`
+	Size         int32    `json:"size,omitempty"`
+	Fee          *float64 `json:"fee,omitempty"`
+	SigChecks    *uint32  `json:"sigchecks,omitempty"`
+	RejectReason string   `json:"reject-reason,omitempty"`
+}
+
+// PolicyViolationResult models a standardness rule violated by a transaction
+// as returned by the analyzetransaction command.  The input or output is only
+// set when the rule is violated by a single input or output.
+type PolicyViolationResult struct {
+	Rule       string `json:"rule"`
+	Input      *int   `json:"input,omitempty"`
+	Output     *int   `json:"output,omitempty"`
+	RejectCode string `json:"rejectcode"`
+	Reason     string `json:"reason"`
+}
+
+// AnalyzeTransactionResult models the data returned by the analyzetransaction
+// command.
+type AnalyzeTransactionResult struct {
+	TxID          string                  `json:"txid"`
+	Standard      bool                    `json:"standard"`
+	Violations    []PolicyViolationResult `json:"violations"`
+	MissingInputs []string                `json:"missinginputs,omitempty"`
+}
+
+// SetRelayPolicyResult models the data returned by the setrelaypolicy command.
+// It holds the relay policy in effect after the update and the ids of the
+// transactions which were removed from the memory pool because they no longer
+// meet it.
+type SetRelayPolicyResult struct {
+	MinRelayTxFee        float64  `json:"minrelaytxfee"`
+	DustRelayFee         float64  `json:"dustrelayfee"`
+	MaxTxSigChecks       int      `json:"maxtxsigchecks"`
+	DataCarrierSize      int      `json:"datacarriersize"`
+	MaxDataCarriers      int      `json:"maxdatacarriers"`
+	DataCarrierProtocols []string `json:"datacarrierprotocols"`
+	Removed              []string `json:"removed"`
+}
+
+// ReloadedOptionResult models a configuration option which was applied by the
+// reloadconfig command.  The value is omitted for options holding secrets.
+type ReloadedOptionResult struct {
+	Option string `json:"option"`
+	Value  string `json:"value,omitempty"`
+}
+
+// ReloadConfigResult models the data returned by the reloadconfig command.  It
+// holds the options which changed and were applied along with the ids of the
+// transactions which were removed from the memory pool because they no longer
+// meet the reloaded relay policy.
+type ReloadConfigResult struct {
+	Applied []ReloadedOptionResult `json:"applied"`
+	Removed []string               `json:"removed"`
+}
+
+// FirstSightingResult models when, from which peer and in which message a
+// block or transaction was first seen as returned by the getfirstseen command.
+type FirstSightingResult struct {
+	Time   int64  `json:"time"`
+	Peer   string `json:"peer"`
+	Source string `json:"source"`
+}
+
+// GetFirstSeenResult models the data returned by the getfirstseen command.
+// The received sighting is only set once the full block or transaction was
+// received.
+type GetFirstSeenResult struct {
+	Hash      string               `json:"hash"`
+	Type      string               `json:"type"`
+	Announced FirstSightingResult  `json:"announced"`
+	Received  *FirstSightingResult `json:"received,omitempty"`
+	Delay     *int64               `json:"delay,omitempty"`
+}
+
 // InfoChainResult models the data returned by the chain server getinfo command.
 type InfoChainResult struct {
 	Version         int32   `json:"version"`
@@ -491,6 +802,10 @@ type TxRawResult struct {
 	Confirmations uint64 `json:"confirmations,omitempty"`
 	Time          int64  `json:"time,omitempty"`
 	Blocktime     int64  `json:"blocktime,omitempty"`
+
+	// Fee and SigChecks are only set when the spent outputs are included.
+	Fee       *float64 `json:"fee,omitempty"`
+	SigChecks *uint32  `json:"sigchecks,omitempty"`
 }
 
 // SearchRawTransactionsResult models the data from the searchrawtransaction
@@ -43,6 +43,35 @@ func TestChainSvrCustomResults(t *testing.T) {
 			},
 			expected: `{"txid":"123","vout":1,"scriptSig":{"asm":"0","hex":"00"},"sequence":4294967295}`,
 		},
+		{
+			name: "custom vin marshal with spent output",
+			result: &btcjson.Vin{
+				Txid: "123",
+				Vout: 1,
+				ScriptSig: &btcjson.ScriptSig{
+					Asm: "0",
+					Hex: "00",
+				},
+				Sequence: 4294967295,
+				PrevOut: &btcjson.SpentOutputResult{
+					Height: 100,
+					Value:  1,
+					ScriptPubKey: btcjson.ScriptPubKeyResult{
+						Hex:  "51",
+						Type: "nonstandard",
+					},
+					TokenData: &btcjson.TokenDataResult{
+						Category: "ab",
+						Amount:   "10",
+						NFT: &btcjson.TokenNFTResult{
+							Capability: "minting",
+							Commitment: "",
+						},
+					},
+				},
+			},
+			expected: `{"txid":"123","vout":1,"scriptSig":{"asm":"0","hex":"00"},"sequence":4294967295,"prevout":{"generated":false,"height":100,"value":1,"scriptPubKey":{"asm":"","hex":"51","type":"nonstandard"},"tokenData":{"category":"ab","amount":"10","nft":{"capability":"minting","commitment":""}}}}`,
+		},
 		{
 			name: "custom vinprevout marshal with coinbase",
 			result: &btcjson.VinPrevOut{
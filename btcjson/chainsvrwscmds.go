@@ -57,6 +57,42 @@ func NewNotifyNewTransactionsCmd(verbose *bool) *NotifyNewTransactionsCmd {
 	}
 }
 
+// NotifyDoubleSpendCmd defines the notifydoublespend JSON-RPC command.
+type NotifyDoubleSpendCmd struct{}
+
+// NewNotifyDoubleSpendCmd returns a new instance which can be used to issue a
+// notifydoublespend JSON-RPC command.
+func NewNotifyDoubleSpendCmd() *NotifyDoubleSpendCmd {
+	return &NotifyDoubleSpendCmd{}
+}
+
+// StopNotifyDoubleSpendCmd defines the stopnotifydoublespend JSON-RPC command.
+type StopNotifyDoubleSpendCmd struct{}
+
+// NewStopNotifyDoubleSpendCmd returns a new instance which can be used to issue
+// a stopnotifydoublespend JSON-RPC command.
+func NewStopNotifyDoubleSpendCmd() *StopNotifyDoubleSpendCmd {
+	return &StopNotifyDoubleSpendCmd{}
+}
+
+// NotifyChainReorgCmd defines the notifychainreorg JSON-RPC command.
+type NotifyChainReorgCmd struct{}
+
+// NewNotifyChainReorgCmd returns a new instance which can be used to issue a
+// notifychainreorg JSON-RPC command.
+func NewNotifyChainReorgCmd() *NotifyChainReorgCmd {
+	return &NotifyChainReorgCmd{}
+}
+
+// StopNotifyChainReorgCmd defines the stopnotifychainreorg JSON-RPC command.
+type StopNotifyChainReorgCmd struct{}
+
+// NewStopNotifyChainReorgCmd returns a new instance which can be used to issue
+// a stopnotifychainreorg JSON-RPC command.
+func NewStopNotifyChainReorgCmd() *StopNotifyChainReorgCmd {
+	return &StopNotifyChainReorgCmd{}
+}
+
 // SessionCmd defines the session JSON-RPC command.
 type SessionCmd struct{}
 
@@ -228,11 +264,15 @@ func init() {
 	MustRegisterCmd("authenticate", (*AuthenticateCmd)(nil), flags)
 	MustRegisterCmd("loadtxfilter", (*LoadTxFilterCmd)(nil), flags)
 	MustRegisterCmd("notifyblocks", (*NotifyBlocksCmd)(nil), flags)
+	MustRegisterCmd("notifychainreorg", (*NotifyChainReorgCmd)(nil), flags)
+	MustRegisterCmd("notifydoublespend", (*NotifyDoubleSpendCmd)(nil), flags)
 	MustRegisterCmd("notifynewtransactions", (*NotifyNewTransactionsCmd)(nil), flags)
 	MustRegisterCmd("notifyreceived", (*NotifyReceivedCmd)(nil), flags)
 	MustRegisterCmd("notifyspent", (*NotifySpentCmd)(nil), flags)
 	MustRegisterCmd("session", (*SessionCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyblocks", (*StopNotifyBlocksCmd)(nil), flags)
+	MustRegisterCmd("stopnotifychainreorg", (*StopNotifyChainReorgCmd)(nil), flags)
+	MustRegisterCmd("stopnotifydoublespend", (*StopNotifyDoubleSpendCmd)(nil), flags)
 	MustRegisterCmd("stopnotifynewtransactions", (*StopNotifyNewTransactionsCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyspent", (*StopNotifySpentCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyreceived", (*StopNotifyReceivedCmd)(nil), flags)
@@ -63,6 +63,50 @@ func TestChainSvrWsCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifyblocks","params":[],"id":1}`,
 			unmarshalled: &btcjson.StopNotifyBlocksCmd{},
 		},
+		{
+			name: "notifydoublespend",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifydoublespend")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyDoubleSpendCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"notifydoublespend","params":[],"id":1}`,
+			unmarshalled: &btcjson.NotifyDoubleSpendCmd{},
+		},
+		{
+			name: "stopnotifydoublespend",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("stopnotifydoublespend")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStopNotifyDoubleSpendCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifydoublespend","params":[],"id":1}`,
+			unmarshalled: &btcjson.StopNotifyDoubleSpendCmd{},
+		},
+		{
+			name: "notifychainreorg",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifychainreorg")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyChainReorgCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"notifychainreorg","params":[],"id":1}`,
+			unmarshalled: &btcjson.NotifyChainReorgCmd{},
+		},
+		{
+			name: "stopnotifychainreorg",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("stopnotifychainreorg")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStopNotifyChainReorgCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifychainreorg","params":[],"id":1}`,
+			unmarshalled: &btcjson.StopNotifyChainReorgCmd{},
+		},
 		{
 			name: "notifynewtransactions",
 			newCmd: func() (interface{}, error) {
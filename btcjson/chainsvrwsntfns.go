@@ -75,6 +75,15 @@ const (
 	// from the chain server that inform a client that a transaction that
 	// matches the loaded filter was accepted by the mempool.
 	RelevantTxAcceptedNtfnMethod = "relevanttxaccepted"
+
+	// DoubleSpendNtfnMethod is the method used for notifications from the
+	// chain server that a transaction which double spends a transaction in
+	// the mempool has been detected.
+	DoubleSpendNtfnMethod = "doublespend"
+
+	// ChainReorgNtfnMethod is the method used for notifications from the
+	// chain server that the main chain has been reorganized.
+	ChainReorgNtfnMethod = "chainreorg"
 )
 
 // BlockConnectedNtfn defines the blockconnected JSON-RPC notification.
@@ -285,6 +294,46 @@ func NewRelevantTxAcceptedNtfn(txHex string) *RelevantTxAcceptedNtfn {
 	return &RelevantTxAcceptedNtfn{Transaction: txHex}
 }
 
+// DoubleSpendNtfn defines the doublespend JSON-RPC notification.
+type DoubleSpendNtfn struct {
+	OutPoint    OutPoint `json:"outpoint"`
+	FirstSeenTx string   `json:"firstseentx"`
+	ConflictTx  string   `json:"conflicttx"`
+	Confirmed   bool     `json:"confirmed"`
+}
+
+// NewDoubleSpendNtfn returns a new instance which can be used to issue a
+// doublespend JSON-RPC notification.
+func NewDoubleSpendNtfn(outPoint OutPoint, firstSeenTx, conflictTx string, confirmed bool) *DoubleSpendNtfn {
+	return &DoubleSpendNtfn{
+		OutPoint:    outPoint,
+		FirstSeenTx: firstSeenTx,
+		ConflictTx:  conflictTx,
+		Confirmed:   confirmed,
+	}
+}
+
+// ChainReorgNtfn defines the chainreorg JSON-RPC notification.
+type ChainReorgNtfn struct {
+	ForkHash       string   `json:"forkhash"`
+	ForkHeight     int32    `json:"forkheight"`
+	Disconnected   []string `json:"disconnected"`
+	Connected      []string `json:"connected"`
+	UnconfirmedTxs []string `json:"unconfirmedtxs"`
+}
+
+// NewChainReorgNtfn returns a new instance which can be used to issue a
+// chainreorg JSON-RPC notification.
+func NewChainReorgNtfn(forkHash string, forkHeight int32, disconnected, connected, unconfirmedTxs []string) *ChainReorgNtfn {
+	return &ChainReorgNtfn{
+		ForkHash:       forkHash,
+		ForkHeight:     forkHeight,
+		Disconnected:   disconnected,
+		Connected:      connected,
+		UnconfirmedTxs: unconfirmedTxs,
+	}
+}
+
 func init() {
 	// The commands in this file are only usable by websockets and are
 	// notifications.
@@ -301,4 +350,6 @@ func init() {
 	MustRegisterCmd(TxAcceptedNtfnMethod, (*TxAcceptedNtfn)(nil), flags)
 	MustRegisterCmd(TxAcceptedVerboseNtfnMethod, (*TxAcceptedVerboseNtfn)(nil), flags)
 	MustRegisterCmd(RelevantTxAcceptedNtfnMethod, (*RelevantTxAcceptedNtfn)(nil), flags)
+	MustRegisterCmd(DoubleSpendNtfnMethod, (*DoubleSpendNtfn)(nil), flags)
+	MustRegisterCmd(ChainReorgNtfnMethod, (*ChainReorgNtfn)(nil), flags)
 }
@@ -225,6 +225,39 @@ func TestChainSvrWsNtfns(t *testing.T) {
 				Transaction: "001122",
 			},
 		},
+		{
+			name: "doublespend",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("doublespend", `{"hash":"123","index":1}`, "456", "789", true)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewDoubleSpendNtfn(btcjson.OutPoint{Hash: "123", Index: 1}, "456", "789", true)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"doublespend","params":[{"hash":"123","index":1},"456","789",true],"id":null}`,
+			unmarshalled: &btcjson.DoubleSpendNtfn{
+				OutPoint:    btcjson.OutPoint{Hash: "123", Index: 1},
+				FirstSeenTx: "456",
+				ConflictTx:  "789",
+				Confirmed:   true,
+			},
+		},
+		{
+			name: "chainreorg",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("chainreorg", "123", 100, `["456"]`, `["789","abc"]`, `["def"]`)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewChainReorgNtfn("123", 100, []string{"456"}, []string{"789", "abc"}, []string{"def"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"chainreorg","params":["123",100,["456"],["789","abc"],["def"]],"id":null}`,
+			unmarshalled: &btcjson.ChainReorgNtfn{
+				ForkHash:       "123",
+				ForkHeight:     100,
+				Disconnected:   []string{"456"},
+				Connected:      []string{"789", "abc"},
+				UnconfirmedTxs: []string{"def"},
+			},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -0,0 +1,25 @@
+// Copyright (c) 2018-2020 The bchd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chainhash
+
+// DoubleHashPairs stores the double sha256 of the concatenation of every two
+// consecutive hashes of src in dst, which must hold half as many hashes as src.
+// These are the parents of the nodes of a level of a merkle tree.
+//
+// Batches of messages are hashed at once with SIMD instructions when the CPU
+// supports them, which is considerably faster than hashing them one by one.
+func DoubleHashPairs(dst, src []Hash) {
+	if len(src) != len(dst)*2 {
+		panic("chainhash: DoubleHashPairs requires two source hashes " +
+			"per destination hash")
+	}
+
+	var buf [HashSize * 2]byte
+	for i := doubleHashPairsBatch(dst, src); i < len(dst); i++ {
+		copy(buf[:HashSize], src[i*2][:])
+		copy(buf[HashSize:], src[i*2+1][:])
+		dst[i] = DoubleHashH(buf[:])
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright (c) 2018-2020 The bchd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build amd64 && !purego
+
+package chainhash
+
+import "golang.org/x/sys/cpu"
+
+// useAVX2 is whether or not batches of eight messages are hashed with AVX2.
+var useAVX2 = cpu.X86.HasAVX2
+
+// sha256d64x8AVX2 stores the double sha256 of the eight 64-byte messages
+// starting at in to the eight hashes starting at out.
+//
+//go:noescape
+func sha256d64x8AVX2(out *Hash, in *byte)
+
+// doubleHashPairsBatch hashes the pairs of hashes of src in batches of eight
+// with AVX2 when it is supported.  It returns the number of hashes of dst which
+// were computed.
+func doubleHashPairsBatch(dst, src []Hash) int {
+	if !useAVX2 {
+		return 0
+	}
+	n := len(dst) &^ 7
+	for i := 0; i < n; i += 8 {
+		sha256d64x8AVX2(&dst[i], &src[i*2][0])
+	}
+	return n
+}
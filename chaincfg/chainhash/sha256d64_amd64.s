@@ -0,0 +1,484 @@
+// Copyright (c) 2018-2020 The bchd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// This file implements the double SHA-256 of eight 64-byte messages at once,
+// such as the concatenated children of merkle tree nodes.  Each AVX2 register
+// holds the same 32-bit word of the eight messages, so every instruction
+// advances all eight hashes.  The padding block of the first hash is the same
+// for every 64-byte message, so its message schedule is precomputed.
+//
+// Register usage:
+//
+//	Y0-Y7   working variables a-h of the eight hashes
+//	Y8-Y10  round temporaries
+//	Y11-Y15 message schedule temporaries
+//	AX      pointer to the current message schedule word
+//	BX      pointer to the current round constant
+//	CX      loop counter
+//	R8      hash pass (0: message, 1: padding, 2: second hash)
+//
+// The stack frame houses the message schedule of 64 words of the eight
+// messages followed by the intermediate state of the first hash.
+
+#define schedule 0
+#define state 2048
+
+// ROUND performs a round of SHA-256 on the working variables, where i is the
+// index of the round within the current group of eight rounds.  Rather than
+// moving the variables between registers, the callers rotate the registers
+// passed as the variables.
+#define ROUND(a, b, c, d, e, f, g, h, i) \
+	VPBROADCASTD (i*4)(BX), Y8; \
+	VPADDD       (i*32)(AX), Y8, Y8; \
+	VPADDD       Y8, h, h; \
+	VPSRLD       $6, e, Y9; \
+	VPSLLD       $26, e, Y10; \
+	VPXOR        Y10, Y9, Y9; \
+	VPSRLD       $11, e, Y10; \
+	VPXOR        Y10, Y9, Y9; \
+	VPSLLD       $21, e, Y10; \
+	VPXOR        Y10, Y9, Y9; \
+	VPSRLD       $25, e, Y10; \
+	VPXOR        Y10, Y9, Y9; \
+	VPSLLD       $7, e, Y10; \
+	VPXOR        Y10, Y9, Y9; \
+	VPADDD       Y9, h, h; \
+	VPXOR        g, f, Y9; \
+	VPAND        e, Y9, Y9; \
+	VPXOR        g, Y9, Y9; \
+	VPADDD       Y9, h, h; \
+	VPADDD       h, d, d; \
+	VPSRLD       $2, a, Y9; \
+	VPSLLD       $30, a, Y10; \
+	VPXOR        Y10, Y9, Y9; \
+	VPSRLD       $13, a, Y10; \
+	VPXOR        Y10, Y9, Y9; \
+	VPSLLD       $19, a, Y10; \
+	VPXOR        Y10, Y9, Y9; \
+	VPSRLD       $22, a, Y10; \
+	VPXOR        Y10, Y9, Y9; \
+	VPSLLD       $10, a, Y10; \
+	VPXOR        Y10, Y9, Y9; \
+	VPADDD       Y9, h, h; \
+	VPOR         b, a, Y9; \
+	VPAND        c, Y9, Y9; \
+	VPAND        b, a, Y10; \
+	VPOR         Y10, Y9, Y9; \
+	VPADDD       Y9, h, h
+
+// sha256K houses the round constants of SHA-256.
+DATA sha256K<>+0x00(SB)/4, $0x428a2f98
+DATA sha256K<>+0x04(SB)/4, $0x71374491
+DATA sha256K<>+0x08(SB)/4, $0xb5c0fbcf
+DATA sha256K<>+0x0c(SB)/4, $0xe9b5dba5
+DATA sha256K<>+0x10(SB)/4, $0x3956c25b
+DATA sha256K<>+0x14(SB)/4, $0x59f111f1
+DATA sha256K<>+0x18(SB)/4, $0x923f82a4
+DATA sha256K<>+0x1c(SB)/4, $0xab1c5ed5
+DATA sha256K<>+0x20(SB)/4, $0xd807aa98
+DATA sha256K<>+0x24(SB)/4, $0x12835b01
+DATA sha256K<>+0x28(SB)/4, $0x243185be
+DATA sha256K<>+0x2c(SB)/4, $0x550c7dc3
+DATA sha256K<>+0x30(SB)/4, $0x72be5d74
+DATA sha256K<>+0x34(SB)/4, $0x80deb1fe
+DATA sha256K<>+0x38(SB)/4, $0x9bdc06a7
+DATA sha256K<>+0x3c(SB)/4, $0xc19bf174
+DATA sha256K<>+0x40(SB)/4, $0xe49b69c1
+DATA sha256K<>+0x44(SB)/4, $0xefbe4786
+DATA sha256K<>+0x48(SB)/4, $0x0fc19dc6
+DATA sha256K<>+0x4c(SB)/4, $0x240ca1cc
+DATA sha256K<>+0x50(SB)/4, $0x2de92c6f
+DATA sha256K<>+0x54(SB)/4, $0x4a7484aa
+DATA sha256K<>+0x58(SB)/4, $0x5cb0a9dc
+DATA sha256K<>+0x5c(SB)/4, $0x76f988da
+DATA sha256K<>+0x60(SB)/4, $0x983e5152
+DATA sha256K<>+0x64(SB)/4, $0xa831c66d
+DATA sha256K<>+0x68(SB)/4, $0xb00327c8
+DATA sha256K<>+0x6c(SB)/4, $0xbf597fc7
+DATA sha256K<>+0x70(SB)/4, $0xc6e00bf3
+DATA sha256K<>+0x74(SB)/4, $0xd5a79147
+DATA sha256K<>+0x78(SB)/4, $0x06ca6351
+DATA sha256K<>+0x7c(SB)/4, $0x14292967
+DATA sha256K<>+0x80(SB)/4, $0x27b70a85
+DATA sha256K<>+0x84(SB)/4, $0x2e1b2138
+DATA sha256K<>+0x88(SB)/4, $0x4d2c6dfc
+DATA sha256K<>+0x8c(SB)/4, $0x53380d13
+DATA sha256K<>+0x90(SB)/4, $0x650a7354
+DATA sha256K<>+0x94(SB)/4, $0x766a0abb
+DATA sha256K<>+0x98(SB)/4, $0x81c2c92e
+DATA sha256K<>+0x9c(SB)/4, $0x92722c85
+DATA sha256K<>+0xa0(SB)/4, $0xa2bfe8a1
+DATA sha256K<>+0xa4(SB)/4, $0xa81a664b
+DATA sha256K<>+0xa8(SB)/4, $0xc24b8b70
+DATA sha256K<>+0xac(SB)/4, $0xc76c51a3
+DATA sha256K<>+0xb0(SB)/4, $0xd192e819
+DATA sha256K<>+0xb4(SB)/4, $0xd6990624
+DATA sha256K<>+0xb8(SB)/4, $0xf40e3585
+DATA sha256K<>+0xbc(SB)/4, $0x106aa070
+DATA sha256K<>+0xc0(SB)/4, $0x19a4c116
+DATA sha256K<>+0xc4(SB)/4, $0x1e376c08
+DATA sha256K<>+0xc8(SB)/4, $0x2748774c
+DATA sha256K<>+0xcc(SB)/4, $0x34b0bcb5
+DATA sha256K<>+0xd0(SB)/4, $0x391c0cb3
+DATA sha256K<>+0xd4(SB)/4, $0x4ed8aa4a
+DATA sha256K<>+0xd8(SB)/4, $0x5b9cca4f
+DATA sha256K<>+0xdc(SB)/4, $0x682e6ff3
+DATA sha256K<>+0xe0(SB)/4, $0x748f82ee
+DATA sha256K<>+0xe4(SB)/4, $0x78a5636f
+DATA sha256K<>+0xe8(SB)/4, $0x84c87814
+DATA sha256K<>+0xec(SB)/4, $0x8cc70208
+DATA sha256K<>+0xf0(SB)/4, $0x90befffa
+DATA sha256K<>+0xf4(SB)/4, $0xa4506ceb
+DATA sha256K<>+0xf8(SB)/4, $0xbef9a3f7
+DATA sha256K<>+0xfc(SB)/4, $0xc67178f2
+GLOBL sha256K<>(SB), RODATA|NOPTR, $256
+
+// sha256IV houses the initial hash values of SHA-256.
+DATA sha256IV<>+0x00(SB)/4, $0x6a09e667
+DATA sha256IV<>+0x04(SB)/4, $0xbb67ae85
+DATA sha256IV<>+0x08(SB)/4, $0x3c6ef372
+DATA sha256IV<>+0x0c(SB)/4, $0xa54ff53a
+DATA sha256IV<>+0x10(SB)/4, $0x510e527f
+DATA sha256IV<>+0x14(SB)/4, $0x9b05688c
+DATA sha256IV<>+0x18(SB)/4, $0x1f83d9ab
+DATA sha256IV<>+0x1c(SB)/4, $0x5be0cd19
+GLOBL sha256IV<>(SB), RODATA|NOPTR, $32
+
+// sha256Pad64 houses the expanded message schedule of the padding block which
+// follows a 64-byte message.
+DATA sha256Pad64<>+0x00(SB)/4, $0x80000000
+DATA sha256Pad64<>+0x04(SB)/4, $0x00000000
+DATA sha256Pad64<>+0x08(SB)/4, $0x00000000
+DATA sha256Pad64<>+0x0c(SB)/4, $0x00000000
+DATA sha256Pad64<>+0x10(SB)/4, $0x00000000
+DATA sha256Pad64<>+0x14(SB)/4, $0x00000000
+DATA sha256Pad64<>+0x18(SB)/4, $0x00000000
+DATA sha256Pad64<>+0x1c(SB)/4, $0x00000000
+DATA sha256Pad64<>+0x20(SB)/4, $0x00000000
+DATA sha256Pad64<>+0x24(SB)/4, $0x00000000
+DATA sha256Pad64<>+0x28(SB)/4, $0x00000000
+DATA sha256Pad64<>+0x2c(SB)/4, $0x00000000
+DATA sha256Pad64<>+0x30(SB)/4, $0x00000000
+DATA sha256Pad64<>+0x34(SB)/4, $0x00000000
+DATA sha256Pad64<>+0x38(SB)/4, $0x00000000
+DATA sha256Pad64<>+0x3c(SB)/4, $0x00000200
+DATA sha256Pad64<>+0x40(SB)/4, $0x80000000
+DATA sha256Pad64<>+0x44(SB)/4, $0x01400000
+DATA sha256Pad64<>+0x48(SB)/4, $0x00205000
+DATA sha256Pad64<>+0x4c(SB)/4, $0x00005088
+DATA sha256Pad64<>+0x50(SB)/4, $0x22000800
+DATA sha256Pad64<>+0x54(SB)/4, $0x22550014
+DATA sha256Pad64<>+0x58(SB)/4, $0x05089742
+DATA sha256Pad64<>+0x5c(SB)/4, $0xa0000020
+DATA sha256Pad64<>+0x60(SB)/4, $0x5a880000
+DATA sha256Pad64<>+0x64(SB)/4, $0x005c9400
+DATA sha256Pad64<>+0x68(SB)/4, $0x0016d49d
+DATA sha256Pad64<>+0x6c(SB)/4, $0xfa801f00
+DATA sha256Pad64<>+0x70(SB)/4, $0xd33225d0
+DATA sha256Pad64<>+0x74(SB)/4, $0x11675959
+DATA sha256Pad64<>+0x78(SB)/4, $0xf6e6bfda
+DATA sha256Pad64<>+0x7c(SB)/4, $0xb30c1549
+DATA sha256Pad64<>+0x80(SB)/4, $0x08b2b050
+DATA sha256Pad64<>+0x84(SB)/4, $0x9d7c4c27
+DATA sha256Pad64<>+0x88(SB)/4, $0x0ce2a393
+DATA sha256Pad64<>+0x8c(SB)/4, $0x88e6e1ea
+DATA sha256Pad64<>+0x90(SB)/4, $0xa52b4335
+DATA sha256Pad64<>+0x94(SB)/4, $0x67a16f49
+DATA sha256Pad64<>+0x98(SB)/4, $0xd732016f
+DATA sha256Pad64<>+0x9c(SB)/4, $0x4eeb2e91
+DATA sha256Pad64<>+0xa0(SB)/4, $0x5dbf55e5
+DATA sha256Pad64<>+0xa4(SB)/4, $0x8eee2335
+DATA sha256Pad64<>+0xa8(SB)/4, $0xe2bc5ec2
+DATA sha256Pad64<>+0xac(SB)/4, $0xa83f4394
+DATA sha256Pad64<>+0xb0(SB)/4, $0x45ad78f7
+DATA sha256Pad64<>+0xb4(SB)/4, $0x36f3d0cd
+DATA sha256Pad64<>+0xb8(SB)/4, $0xd99c05e8
+DATA sha256Pad64<>+0xbc(SB)/4, $0xb0511dc7
+DATA sha256Pad64<>+0xc0(SB)/4, $0x69bc7ac4
+DATA sha256Pad64<>+0xc4(SB)/4, $0xbd11375b
+DATA sha256Pad64<>+0xc8(SB)/4, $0xe3ba71e5
+DATA sha256Pad64<>+0xcc(SB)/4, $0x3b209ff2
+DATA sha256Pad64<>+0xd0(SB)/4, $0x18feee17
+DATA sha256Pad64<>+0xd4(SB)/4, $0xe25ad9e7
+DATA sha256Pad64<>+0xd8(SB)/4, $0x13375046
+DATA sha256Pad64<>+0xdc(SB)/4, $0x0515089d
+DATA sha256Pad64<>+0xe0(SB)/4, $0x4f0d0f04
+DATA sha256Pad64<>+0xe4(SB)/4, $0x2627484e
+DATA sha256Pad64<>+0xe8(SB)/4, $0x310128d2
+DATA sha256Pad64<>+0xec(SB)/4, $0xc668b434
+DATA sha256Pad64<>+0xf0(SB)/4, $0x420841cc
+DATA sha256Pad64<>+0xf4(SB)/4, $0x62d311b8
+DATA sha256Pad64<>+0xf8(SB)/4, $0xe59ba771
+DATA sha256Pad64<>+0xfc(SB)/4, $0x85a7a484
+GLOBL sha256Pad64<>(SB), RODATA|NOPTR, $256
+
+// sha256Pad32 houses the final eight message words of a block which holds a
+// 32-byte message and its padding.
+DATA sha256Pad32<>+0x00(SB)/4, $0x80000000
+DATA sha256Pad32<>+0x04(SB)/4, $0x00000000
+DATA sha256Pad32<>+0x08(SB)/4, $0x00000000
+DATA sha256Pad32<>+0x0c(SB)/4, $0x00000000
+DATA sha256Pad32<>+0x10(SB)/4, $0x00000000
+DATA sha256Pad32<>+0x14(SB)/4, $0x00000000
+DATA sha256Pad32<>+0x18(SB)/4, $0x00000000
+DATA sha256Pad32<>+0x1c(SB)/4, $0x00000100
+GLOBL sha256Pad32<>(SB), RODATA|NOPTR, $32
+
+// byteSwapMask reverses the bytes of each 32-bit word.
+DATA byteSwapMask<>+0x00(SB)/4, $0x00010203
+DATA byteSwapMask<>+0x04(SB)/4, $0x04050607
+DATA byteSwapMask<>+0x08(SB)/4, $0x08090a0b
+DATA byteSwapMask<>+0x0c(SB)/4, $0x0c0d0e0f
+DATA byteSwapMask<>+0x10(SB)/4, $0x00010203
+DATA byteSwapMask<>+0x14(SB)/4, $0x04050607
+DATA byteSwapMask<>+0x18(SB)/4, $0x08090a0b
+DATA byteSwapMask<>+0x1c(SB)/4, $0x0c0d0e0f
+GLOBL byteSwapMask<>(SB), RODATA|NOPTR, $32
+
+// gatherIndex houses the offsets of the eight 64-byte inputs.
+DATA gatherIndex<>+0x00(SB)/4, $0x00000000
+DATA gatherIndex<>+0x04(SB)/4, $0x00000040
+DATA gatherIndex<>+0x08(SB)/4, $0x00000080
+DATA gatherIndex<>+0x0c(SB)/4, $0x000000c0
+DATA gatherIndex<>+0x10(SB)/4, $0x00000100
+DATA gatherIndex<>+0x14(SB)/4, $0x00000140
+DATA gatherIndex<>+0x18(SB)/4, $0x00000180
+DATA gatherIndex<>+0x1c(SB)/4, $0x000001c0
+GLOBL gatherIndex<>(SB), RODATA|NOPTR, $32
+
+// func sha256d64x8AVX2(out *Hash, in *byte)
+TEXT ·sha256d64x8AVX2(SB), 0, $2304-16
+	MOVQ in+8(FP), SI
+
+	// Load the sixteen message words of the eight messages.
+	VMOVDQU gatherIndex<>(SB), Y15
+	VMOVDQU byteSwapMask<>(SB), Y14
+	LEAQ    schedule(SP), AX
+	MOVQ    $16, CX
+
+load:
+	VPCMPEQD   Y13, Y13, Y13
+	VPGATHERDD Y13, (SI)(Y15*1), Y12
+	VPSHUFB    Y14, Y12, Y12
+	VMOVDQU    Y12, (AX)
+	ADDQ       $4, SI
+	ADDQ       $32, AX
+	DECQ       CX
+	JNZ        load
+	MOVQ       $0, R8
+
+	// Expand the remaining words of the message schedule.
+expand:
+	LEAQ (schedule+16*32)(SP), AX
+	MOVQ $48, CX
+
+expandLoop:
+	VMOVDQU (-15*32)(AX), Y11
+	VPSRLD  $7, Y11, Y12
+	VPSLLD  $25, Y11, Y13
+	VPXOR   Y13, Y12, Y12
+	VPSRLD  $18, Y11, Y13
+	VPXOR   Y13, Y12, Y12
+	VPSLLD  $14, Y11, Y13
+	VPXOR   Y13, Y12, Y12
+	VPSRLD  $3, Y11, Y13
+	VPXOR   Y13, Y12, Y12
+	VMOVDQU (-2*32)(AX), Y11
+	VPSRLD  $17, Y11, Y13
+	VPSLLD  $15, Y11, Y14
+	VPXOR   Y14, Y13, Y13
+	VPSRLD  $19, Y11, Y14
+	VPXOR   Y14, Y13, Y13
+	VPSLLD  $13, Y11, Y14
+	VPXOR   Y14, Y13, Y13
+	VPSRLD  $10, Y11, Y14
+	VPXOR   Y14, Y13, Y13
+	VPADDD  Y13, Y12, Y12
+	VPADDD  (-7*32)(AX), Y12, Y12
+	VPADDD  (-16*32)(AX), Y12, Y12
+	VMOVDQU Y12, (AX)
+	ADDQ    $32, AX
+	DECQ    CX
+	JNZ     expandLoop
+
+	// Both hashes start with the initial hash values.
+	VPBROADCASTD sha256IV<>+0(SB), Y0
+	VPBROADCASTD sha256IV<>+4(SB), Y1
+	VPBROADCASTD sha256IV<>+8(SB), Y2
+	VPBROADCASTD sha256IV<>+12(SB), Y3
+	VPBROADCASTD sha256IV<>+16(SB), Y4
+	VPBROADCASTD sha256IV<>+20(SB), Y5
+	VPBROADCASTD sha256IV<>+24(SB), Y6
+	VPBROADCASTD sha256IV<>+28(SB), Y7
+
+	// Perform the 64 rounds in groups of eight, after which the working
+	// variables are back in their original registers.
+rounds:
+	LEAQ schedule(SP), AX
+	LEAQ sha256K<>(SB), BX
+	MOVQ $8, CX
+
+roundsLoop:
+	ROUND(Y0, Y1, Y2, Y3, Y4, Y5, Y6, Y7, 0)
+	ROUND(Y7, Y0, Y1, Y2, Y3, Y4, Y5, Y6, 1)
+	ROUND(Y6, Y7, Y0, Y1, Y2, Y3, Y4, Y5, 2)
+	ROUND(Y5, Y6, Y7, Y0, Y1, Y2, Y3, Y4, 3)
+	ROUND(Y4, Y5, Y6, Y7, Y0, Y1, Y2, Y3, 4)
+	ROUND(Y3, Y4, Y5, Y6, Y7, Y0, Y1, Y2, 5)
+	ROUND(Y2, Y3, Y4, Y5, Y6, Y7, Y0, Y1, 6)
+	ROUND(Y1, Y2, Y3, Y4, Y5, Y6, Y7, Y0, 7)
+	ADDQ $(8*32), AX
+	ADDQ $(8*4), BX
+	DECQ CX
+	JNZ  roundsLoop
+
+	CMPQ R8, $1
+	JEQ  padding
+	JGT  done
+
+	// The message block of the first hash is done.  Add the initial hash
+	// values, save the intermediate state and continue with the padding
+	// block, whose message schedule is the same for every message.
+	VPBROADCASTD sha256IV<>+0(SB), Y8
+	VPADDD       Y8, Y0, Y0
+	VPBROADCASTD sha256IV<>+4(SB), Y8
+	VPADDD       Y8, Y1, Y1
+	VPBROADCASTD sha256IV<>+8(SB), Y8
+	VPADDD       Y8, Y2, Y2
+	VPBROADCASTD sha256IV<>+12(SB), Y8
+	VPADDD       Y8, Y3, Y3
+	VPBROADCASTD sha256IV<>+16(SB), Y8
+	VPADDD       Y8, Y4, Y4
+	VPBROADCASTD sha256IV<>+20(SB), Y8
+	VPADDD       Y8, Y5, Y5
+	VPBROADCASTD sha256IV<>+24(SB), Y8
+	VPADDD       Y8, Y6, Y6
+	VPBROADCASTD sha256IV<>+28(SB), Y8
+	VPADDD       Y8, Y7, Y7
+	VMOVDQU      Y0, (state+0*32)(SP)
+	VMOVDQU      Y1, (state+1*32)(SP)
+	VMOVDQU      Y2, (state+2*32)(SP)
+	VMOVDQU      Y3, (state+3*32)(SP)
+	VMOVDQU      Y4, (state+4*32)(SP)
+	VMOVDQU      Y5, (state+5*32)(SP)
+	VMOVDQU      Y6, (state+6*32)(SP)
+	VMOVDQU      Y7, (state+7*32)(SP)
+
+	LEAQ schedule(SP), AX
+	LEAQ sha256Pad64<>(SB), BX
+	MOVQ $64, CX
+
+padLoop:
+	VPBROADCASTD (BX), Y8
+	VMOVDQU      Y8, (AX)
+	ADDQ         $4, BX
+	ADDQ         $32, AX
+	DECQ         CX
+	JNZ          padLoop
+	MOVQ         $1, R8
+	JMP          rounds
+
+	// The first hash is done.  Add the intermediate state and hash the
+	// result along with its padding.
+padding:
+	VPADDD  (state+0*32)(SP), Y0, Y0
+	VPADDD  (state+1*32)(SP), Y1, Y1
+	VPADDD  (state+2*32)(SP), Y2, Y2
+	VPADDD  (state+3*32)(SP), Y3, Y3
+	VPADDD  (state+4*32)(SP), Y4, Y4
+	VPADDD  (state+5*32)(SP), Y5, Y5
+	VPADDD  (state+6*32)(SP), Y6, Y6
+	VPADDD  (state+7*32)(SP), Y7, Y7
+	VMOVDQU Y0, (schedule+0*32)(SP)
+	VMOVDQU Y1, (schedule+1*32)(SP)
+	VMOVDQU Y2, (schedule+2*32)(SP)
+	VMOVDQU Y3, (schedule+3*32)(SP)
+	VMOVDQU Y4, (schedule+4*32)(SP)
+	VMOVDQU Y5, (schedule+5*32)(SP)
+	VMOVDQU Y6, (schedule+6*32)(SP)
+	VMOVDQU Y7, (schedule+7*32)(SP)
+
+	LEAQ (schedule+8*32)(SP), AX
+	LEAQ sha256Pad32<>(SB), BX
+	MOVQ $8, CX
+
+pad32Loop:
+	VPBROADCASTD (BX), Y8
+	VMOVDQU      Y8, (AX)
+	ADDQ         $4, BX
+	ADDQ         $32, AX
+	DECQ         CX
+	JNZ          pad32Loop
+	MOVQ         $2, R8
+	JMP          expand
+
+	// The second hash is done.  Add the initial hash values and store the
+	// hashes of the eight messages in big endian.
+done:
+	VMOVDQU      byteSwapMask<>(SB), Y9
+	VPBROADCASTD sha256IV<>+0(SB), Y8
+	VPADDD       Y8, Y0, Y0
+	VPSHUFB      Y9, Y0, Y0
+	VMOVDQU      Y0, (schedule+0*32)(SP)
+	VPBROADCASTD sha256IV<>+4(SB), Y8
+	VPADDD       Y8, Y1, Y1
+	VPSHUFB      Y9, Y1, Y1
+	VMOVDQU      Y1, (schedule+1*32)(SP)
+	VPBROADCASTD sha256IV<>+8(SB), Y8
+	VPADDD       Y8, Y2, Y2
+	VPSHUFB      Y9, Y2, Y2
+	VMOVDQU      Y2, (schedule+2*32)(SP)
+	VPBROADCASTD sha256IV<>+12(SB), Y8
+	VPADDD       Y8, Y3, Y3
+	VPSHUFB      Y9, Y3, Y3
+	VMOVDQU      Y3, (schedule+3*32)(SP)
+	VPBROADCASTD sha256IV<>+16(SB), Y8
+	VPADDD       Y8, Y4, Y4
+	VPSHUFB      Y9, Y4, Y4
+	VMOVDQU      Y4, (schedule+4*32)(SP)
+	VPBROADCASTD sha256IV<>+20(SB), Y8
+	VPADDD       Y8, Y5, Y5
+	VPSHUFB      Y9, Y5, Y5
+	VMOVDQU      Y5, (schedule+5*32)(SP)
+	VPBROADCASTD sha256IV<>+24(SB), Y8
+	VPADDD       Y8, Y6, Y6
+	VPSHUFB      Y9, Y6, Y6
+	VMOVDQU      Y6, (schedule+6*32)(SP)
+	VPBROADCASTD sha256IV<>+28(SB), Y8
+	VPADDD       Y8, Y7, Y7
+	VPSHUFB      Y9, Y7, Y7
+	VMOVDQU      Y7, (schedule+7*32)(SP)
+	VZEROUPPER
+
+	// Transpose the words of the hashes into the output.
+	MOVQ out+0(FP), DI
+	LEAQ schedule(SP), AX
+	MOVQ $8, CX
+
+store:
+	MOVL (0*32)(AX), R9
+	MOVL R9, 0(DI)
+	MOVL (1*32)(AX), R9
+	MOVL R9, 4(DI)
+	MOVL (2*32)(AX), R9
+	MOVL R9, 8(DI)
+	MOVL (3*32)(AX), R9
+	MOVL R9, 12(DI)
+	MOVL (4*32)(AX), R9
+	MOVL R9, 16(DI)
+	MOVL (5*32)(AX), R9
+	MOVL R9, 20(DI)
+	MOVL (6*32)(AX), R9
+	MOVL R9, 24(DI)
+	MOVL (7*32)(AX), R9
+	MOVL R9, 28(DI)
+	ADDQ $4, AX
+	ADDQ $32, DI
+	DECQ CX
+	JNZ  store
+	RET
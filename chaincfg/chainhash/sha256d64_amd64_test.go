@@ -0,0 +1,35 @@
+// Copyright (c) 2018-2020 The bchd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build amd64 && !purego
+
+package chainhash
+
+import (
+	"testing"
+
+	"golang.org/x/sys/cpu"
+)
+
+// TestDoubleHashPairsAVX2 ensures both the AVX2 batches and the generic path
+// return the known answers regardless of the features of the CPU the tests
+// happen to run on.
+func TestDoubleHashPairsAVX2(t *testing.T) {
+	defer func(old bool) { useAVX2 = old }(useAVX2)
+
+	useAVX2 = false
+	if n := doubleHashPairsBatch(make([]Hash, 8), make([]Hash, 16)); n != 0 {
+		t.Fatalf("generic path hashed %d pairs in batches", n)
+	}
+	testDoubleHashPairsVectors(t)
+
+	if !cpu.X86.HasAVX2 {
+		t.Skip("AVX2 is not supported")
+	}
+	useAVX2 = true
+	if n := doubleHashPairsBatch(make([]Hash, 9), make([]Hash, 18)); n != 8 {
+		t.Fatalf("AVX2 path hashed %d pairs in batches, want 8", n)
+	}
+	testDoubleHashPairsVectors(t)
+}
@@ -0,0 +1,13 @@
+// Copyright (c) 2018-2020 The bchd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build !amd64 || purego
+
+package chainhash
+
+// doubleHashPairsBatch returns zero since there is no SIMD implementation for
+// the architecture, so every pair is hashed by DoubleHashH.
+func doubleHashPairsBatch(dst, src []Hash) int {
+	return 0
+}
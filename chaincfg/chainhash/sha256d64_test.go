@@ -0,0 +1,92 @@
+// Copyright (c) 2018-2020 The bchd developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chainhash
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestDoubleHashPairs ensures the hashes of pairs of hashes computed in batches
+// match the ones computed one by one, including the ones of the pairs which
+// don't fill a batch.
+func TestDoubleHashPairs(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for n := 0; n <= 41; n++ {
+		src := make([]Hash, n*2)
+		for i := range src {
+			rng.Read(src[i][:])
+		}
+		dst := make([]Hash, n)
+		DoubleHashPairs(dst, src)
+
+		for i := range dst {
+			var buf [HashSize * 2]byte
+			copy(buf[:HashSize], src[i*2][:])
+			copy(buf[HashSize:], src[i*2+1][:])
+			if want := DoubleHashH(buf[:]); dst[i] != want {
+				t.Fatalf("DoubleHashPairs(%d): hash %d: got %v, "+
+					"want %v", n, i, dst[i], want)
+			}
+		}
+	}
+
+	// Mismatched lengths must panic.
+	defer func() {
+		if recover() == nil {
+			t.Fatal("DoubleHashPairs: no panic for mismatched lengths")
+		}
+	}()
+	DoubleHashPairs(make([]Hash, 2), make([]Hash, 3))
+}
+
+// doubleHashPairsTests are known answers of DoubleHashPairs for the pairs of
+// hashes whose bytes all equal their index in the source, which fill one batch
+// of eight and leave one pair over.
+var doubleHashPairsTests = []string{
+	"6db7479c5346abc47ef4196df47836190a79ce42b078a9e5c36f47429dde5e70",
+	"1b595fc4fcbf0af71c4cdda29dc42e88211fff2aa54acfcce6ab6fca42c1121b",
+	"a065857d51c3cae7bc0284d9c3f66df0a14abed216c538c3f602062e5b82ee67",
+	"5cc6cdbb1c8aebe44de16efaf0139c7c47fef151073ff21a0a34a188b64b4dcd",
+	"002d44b135864e17859e4e4dffbb0afc23a81bb657c72536604ebe1ef3c4aea5",
+	"2ef331ed684f3f1e3d7f171fce38ed73a2b2b3e14cd6c2688d6879e4872d6a1d",
+	"85ed2b80c7b301d3a4bf38667afe56c6e10eea67298751bae6c1bd07bc87b690",
+	"f850e426368cec87ac391c5ca1919a63998aa20c5ec82b72c8f94214657fcc37",
+	"7d943d0ffac4a502d3cc1387bebd7a23d4f952cc0bf3e24edb7bab27bfd27586",
+}
+
+// testDoubleHashPairsVectors ensures DoubleHashPairs returns the known answers
+// of doubleHashPairsTests.
+func testDoubleHashPairsVectors(t *testing.T) {
+	src := make([]Hash, len(doubleHashPairsTests)*2)
+	for i := range src {
+		for j := range src[i] {
+			src[i][j] = byte(i)
+		}
+	}
+	dst := make([]Hash, len(doubleHashPairsTests))
+	DoubleHashPairs(dst, src)
+	for i, want := range doubleHashPairsTests {
+		if got := dst[i].String(); got != want {
+			t.Fatalf("DoubleHashPairs: hash %d: got %v, want %v", i,
+				got, want)
+		}
+	}
+}
+
+// TestDoubleHashPairsVectors ensures DoubleHashPairs returns known answers.
+func TestDoubleHashPairsVectors(t *testing.T) {
+	testDoubleHashPairsVectors(t)
+}
+
+// BenchmarkDoubleHashPairs benchmarks hashing a level of 4096 pairs of hashes.
+func BenchmarkDoubleHashPairs(b *testing.B) {
+	src := make([]Hash, 8192)
+	dst := make([]Hash, len(src)/2)
+	b.SetBytes(int64(len(src) * HashSize))
+	for i := 0; i < b.N; i++ {
+		DoubleHashPairs(dst, src)
+	}
+}
@@ -0,0 +1,200 @@
+package chaincfg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/wire"
+)
+
+// customNetConfig is the JSON representation of a custom network.  The
+// network starts from the parameters of a base network and every field which
+// is set overrides the matching base parameter.
+type customNetConfig struct {
+	Name              string   `json:"name"`
+	Base              string   `json:"base"`
+	Net               uint32   `json:"net"`
+	DefaultPort       string   `json:"defaultport"`
+	DNSSeeds          []string `json:"dnsseeds"`
+	Checkpoints       []string `json:"checkpoints"`
+	CashAddressPrefix string   `json:"cashaddressprefix"`
+
+	CSVHeight                     *int32  `json:"csvheight"`
+	UahfForkHeight                *int32  `json:"uahfforkheight"`
+	DaaForkHeight                 *int32  `json:"daaforkheight"`
+	MagneticAnonomalyForkHeight   *int32  `json:"magneticanomalyforkheight"`
+	GreatWallForkHeight           *int32  `json:"greatwallforkheight"`
+	GravitonForkHeight            *int32  `json:"gravitonforkheight"`
+	PhononForkHeight              *int32  `json:"phononforkheight"`
+	AxionActivationHeight         *int32  `json:"axionactivationheight"`
+	CosmicInflationActivationTime *uint64 `json:"cosmicinflationactivationtime"`
+	Upgrade9ForkHeight            *int32  `json:"upgrade9forkheight"`
+	ABLAForkHeight                *int32  `json:"ablaforkheight"`
+	Upgrade11ActivationTime       *uint64 `json:"upgrade11activationtime"`
+
+	ABLAConfig *ABLAConstants `json:"ablaconfig"`
+
+	AsertDifficultyAnchorHeight          *int32  `json:"asertanchorheight"`
+	AsertDifficultyAnchorParentTimestamp *int64  `json:"asertanchorparenttimestamp"`
+	AsertDifficultyAnchorBits            *uint32 `json:"asertanchorbits"`
+
+	RelayNonStdTxs    *bool `json:"relaynonstdtxs"`
+	GenerateSupported *bool `json:"generatesupported"`
+
+	SignetChallenge string `json:"signetchallenge"`
+}
+
+// baseNets are the networks a custom network may be derived from.
+var baseNets = []*Params{
+	&MainNetParams,
+	&TestNet3Params,
+	&TestNet4Params,
+	&ChipNetParams,
+	&ScaleNetParams,
+	&RegressionNetParams,
+	&SimNetParams,
+	&SigNetParams,
+}
+
+// NewCustomNetParams returns the parameters of the custom network described by
+// the passed JSON document.  The document names a base network whose
+// parameters, including the genesis block, are copied and then overridden by
+// the remaining fields.  A name and a network magic distinct from the base
+// network are required so the custom network can neither share a data
+// directory nor peers with a standard network.
+func NewCustomNetParams(data []byte) (*Params, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var cfg customNetConfig
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid custom network: %v", err)
+	}
+
+	if cfg.Name == "" {
+		return nil, errors.New("custom network name is required")
+	}
+	var base *Params
+	for _, p := range baseNets {
+		if p.Name == cfg.Base {
+			base = p
+			break
+		}
+	}
+	if base == nil {
+		return nil, fmt.Errorf("unknown base network %q", cfg.Base)
+	}
+	if cfg.Net == 0 {
+		return nil, errors.New("custom network magic is required")
+	}
+	for _, p := range baseNets {
+		if p.Net == wire.BitcoinNet(cfg.Net) {
+			return nil, fmt.Errorf("network magic %#08x is used by %s",
+				cfg.Net, p.Name)
+		}
+	}
+
+	params := *base
+	params.Name = cfg.Name
+	params.Net = wire.BitcoinNet(cfg.Net)
+	if cfg.DefaultPort != "" {
+		if _, err := strconv.ParseUint(cfg.DefaultPort, 10, 16); err != nil {
+			return nil, fmt.Errorf("invalid default port %q",
+				cfg.DefaultPort)
+		}
+		params.DefaultPort = cfg.DefaultPort
+	}
+	if cfg.DNSSeeds != nil {
+		params.DNSSeeds = make([]DNSSeed, 0, len(cfg.DNSSeeds))
+		for _, host := range cfg.DNSSeeds {
+			params.DNSSeeds = append(params.DNSSeeds, DNSSeed{Host: host})
+		}
+	}
+	if cfg.Checkpoints != nil {
+		params.Checkpoints = make([]Checkpoint, 0, len(cfg.Checkpoints))
+		for _, s := range cfg.Checkpoints {
+			checkpoint, err := parseCheckpoint(s)
+			if err != nil {
+				return nil, err
+			}
+			params.Checkpoints = append(params.Checkpoints, checkpoint)
+		}
+	}
+	if cfg.CashAddressPrefix != "" {
+		params.CashAddressPrefix = cfg.CashAddressPrefix
+	}
+
+	setInt32 := func(dst *int32, v *int32) {
+		if v != nil {
+			*dst = *v
+		}
+	}
+	setUint64 := func(dst *uint64, v *uint64) {
+		if v != nil {
+			*dst = *v
+		}
+	}
+	setInt32(&params.CSVHeight, cfg.CSVHeight)
+	setInt32(&params.UahfForkHeight, cfg.UahfForkHeight)
+	setInt32(&params.DaaForkHeight, cfg.DaaForkHeight)
+	setInt32(&params.MagneticAnonomalyForkHeight, cfg.MagneticAnonomalyForkHeight)
+	setInt32(&params.GreatWallForkHeight, cfg.GreatWallForkHeight)
+	setInt32(&params.GravitonForkHeight, cfg.GravitonForkHeight)
+	setInt32(&params.PhononForkHeight, cfg.PhononForkHeight)
+	setInt32(&params.AxionActivationHeight, cfg.AxionActivationHeight)
+	setUint64(&params.CosmicInflationActivationTime, cfg.CosmicInflationActivationTime)
+	setInt32(&params.Upgrade9ForkHeight, cfg.Upgrade9ForkHeight)
+	setInt32(&params.ABLAForkHeight, cfg.ABLAForkHeight)
+	setUint64(&params.Upgrade11ActivationTime, cfg.Upgrade11ActivationTime)
+	setInt32(&params.AsertDifficultyAnchorHeight, cfg.AsertDifficultyAnchorHeight)
+	if cfg.AsertDifficultyAnchorParentTimestamp != nil {
+		params.AsertDifficultyAnchorParentTimestamp = *cfg.AsertDifficultyAnchorParentTimestamp
+	}
+	if cfg.AsertDifficultyAnchorBits != nil {
+		params.AsertDifficultyAnchorBits = *cfg.AsertDifficultyAnchorBits
+	}
+	if cfg.ABLAConfig != nil {
+		params.ABLAConfig = *cfg.ABLAConfig
+	}
+	if cfg.RelayNonStdTxs != nil {
+		params.RelayNonStdTxs = *cfg.RelayNonStdTxs
+	}
+	if cfg.GenerateSupported != nil {
+		params.GenerateSupported = *cfg.GenerateSupported
+	}
+	if cfg.SignetChallenge != "" {
+		challenge, err := hex.DecodeString(cfg.SignetChallenge)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signet challenge %q",
+				cfg.SignetChallenge)
+		}
+		params.SignetChallenge = challenge
+	}
+
+	return &params, nil
+}
+
+// parseCheckpoint parses a checkpoint in the form '<height>:<hash>'.
+func parseCheckpoint(s string) (Checkpoint, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return Checkpoint{}, fmt.Errorf("invalid checkpoint %q -- use "+
+			"the syntax <height>:<hash>", s)
+	}
+	height, err := strconv.ParseInt(parts[0], 10, 32)
+	if err != nil || height < 0 {
+		return Checkpoint{}, fmt.Errorf("invalid checkpoint height %q",
+			parts[0])
+	}
+	hash, err := chainhash.NewHashFromStr(parts[1])
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("invalid checkpoint hash %q: %v",
+			parts[1], err)
+	}
+	return Checkpoint{Height: int32(height), Hash: hash}, nil
+}
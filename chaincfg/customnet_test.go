@@ -0,0 +1,68 @@
+package chaincfg
+
+import (
+	"testing"
+
+	"github.com/gcash/bchd/wire"
+)
+
+// TestNewCustomNetParams ensures custom networks override the parameters of
+// their base network and that invalid documents are rejected.
+func TestNewCustomNetParams(t *testing.T) {
+	params, err := NewCustomNetParams([]byte(`{
+		"name": "upgradetest",
+		"base": "chipnet",
+		"net": 3735928559,
+		"defaultport": "58333",
+		"dnsseeds": ["seed.example.com"],
+		"checkpoints": ["10:000000001dd410c49a788668ce26751718cc797474d3152a5fc073dd44fd9f7b"],
+		"upgrade9forkheight": 200000,
+		"ablaconfig": {"epsilon0": 1, "beta0": 2, "fixedsize": true}
+	}`))
+	if err != nil {
+		t.Fatalf("NewCustomNetParams: %v", err)
+	}
+	if params.Name != "upgradetest" || params.Net != wire.BitcoinNet(0xdeadbeef) ||
+		params.DefaultPort != "58333" {
+		t.Fatalf("unexpected network identity: %s %v %s", params.Name,
+			params.Net, params.DefaultPort)
+	}
+	if len(params.DNSSeeds) != 1 || params.DNSSeeds[0].Host != "seed.example.com" {
+		t.Fatalf("unexpected dns seeds %v", params.DNSSeeds)
+	}
+	if len(params.Checkpoints) != 1 || params.Checkpoints[0].Height != 10 {
+		t.Fatalf("unexpected checkpoints %v", params.Checkpoints)
+	}
+	if params.Upgrade9ForkHeight != 200000 {
+		t.Fatalf("unexpected upgrade9 height %d", params.Upgrade9ForkHeight)
+	}
+	if params.ABLAConfig != (ABLAConstants{Epsilon0: 1, Beta0: 2, FixedSize: true}) {
+		t.Fatalf("unexpected abla config %+v", params.ABLAConfig)
+	}
+
+	// Fields which are not overridden are inherited from the base network
+	// and the base network itself is left untouched.
+	if params.GenesisHash != ChipNetParams.GenesisHash ||
+		params.ABLAForkHeight != ChipNetParams.ABLAForkHeight {
+		t.Fatal("custom network did not inherit base parameters")
+	}
+	if ChipNetParams.Upgrade9ForkHeight == 200000 || ChipNetParams.Name != "chipnet" {
+		t.Fatal("base network parameters were modified")
+	}
+
+	invalid := []string{
+		`{"base": "chipnet", "net": 1}`,
+		`{"name": "x", "base": "nonet", "net": 1}`,
+		`{"name": "x", "base": "chipnet"}`,
+		`{"name": "x", "base": "chipnet", "net": 3908297187}`,
+		`{"name": "x", "base": "chipnet", "net": 1, "defaultport": "port"}`,
+		`{"name": "x", "base": "chipnet", "net": 1, "checkpoints": ["10"]}`,
+		`{"name": "x", "base": "chipnet", "net": 1, "unknown": 1}`,
+		`{"name": "x", "base": "signet", "net": 1, "signetchallenge": "zz"}`,
+	}
+	for _, doc := range invalid {
+		if _, err := NewCustomNetParams([]byte(doc)); err == nil {
+			t.Errorf("NewCustomNetParams(%s): expected error", doc)
+		}
+	}
+}
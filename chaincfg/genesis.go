@@ -171,6 +171,34 @@ var testNet4GenesisBlock = wire.MsgBlock{
 	Transactions: []*wire.MsgTx{&genesisCoinbaseTx},
 }
 
+// scaleNetGenesisHash is the hash of the first block in the block chain for
+// the scaling test network.
+var scaleNetGenesisHash = chainhash.Hash([chainhash.HashSize]byte{ // Make go vet happy.
+	0x52, 0xbe, 0x30, 0x04, 0x9f, 0x59, 0xa4, 0x91,
+	0x02, 0x7d, 0xe8, 0xb8, 0xdb, 0x11, 0xeb, 0x02,
+	0x60, 0xf8, 0x23, 0x90, 0xa1, 0xff, 0xe1, 0xdf,
+	0xc2, 0x3d, 0x45, 0xe6, 0x00, 0x00, 0x00, 0x00,
+})
+
+// scaleNetGenesisMerkleRoot is the hash of the first transaction in the
+// genesis block for the scaling test network.  It is the same as the merkle
+// root for the main network.
+var scaleNetGenesisMerkleRoot = genesisMerkleRoot
+
+// scaleNetGenesisBlock defines the genesis block of the block chain which
+// serves as the public transaction ledger for the scaling test network.
+var scaleNetGenesisBlock = wire.MsgBlock{
+	Header: wire.BlockHeader{
+		Version:    1,
+		PrevBlock:  chainhash.Hash{},          // 0000000000000000000000000000000000000000000000000000000000000000
+		MerkleRoot: scaleNetGenesisMerkleRoot, // 4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b
+		Timestamp:  time.Unix(1598282438, 0),  // 2020-08-24 15:20:38 +0000 UTC
+		Bits:       0x1d00ffff,                // 486604799 [00000000ffff0000000000000000000000000000000000000000000000000000]
+		Nonce:      2727663012,
+	},
+	Transactions: []*wire.MsgTx{&genesisCoinbaseTx},
+}
+
 // simNetGenesisHash is the hash of the first block in the block chain for the
 // simulation test network.
 var simNetGenesisHash = chainhash.Hash([chainhash.HashSize]byte{ // Make go vet happy.
@@ -198,3 +226,33 @@ var simNetGenesisBlock = wire.MsgBlock{
 	},
 	Transactions: []*wire.MsgTx{&genesisCoinbaseTx},
 }
+
+// sigNetGenesisHash is the hash of the first block in the block chain for the
+// signed test network.
+var sigNetGenesisHash = chainhash.Hash([chainhash.HashSize]byte{ // Make go vet happy.
+	0xf6, 0x1e, 0xee, 0x3b, 0x63, 0xa3, 0x80, 0xa4,
+	0x77, 0xa0, 0x63, 0xaf, 0x32, 0xb2, 0xbb, 0xc9,
+	0x7c, 0x9f, 0xf9, 0xf0, 0x1f, 0x2c, 0x42, 0x25,
+	0xe9, 0x73, 0x98, 0x81, 0x08, 0x00, 0x00, 0x00,
+})
+
+// sigNetGenesisMerkleRoot is the hash of the first transaction in the genesis
+// block for the signed test network.  It is the same as the merkle root for
+// the main network.
+var sigNetGenesisMerkleRoot = genesisMerkleRoot
+
+// sigNetGenesisBlock defines the genesis block of the block chain which serves
+// as the public transaction ledger for the signed test network.  The genesis
+// block is not signed and is shared by all signed test networks regardless of
+// their challenge.
+var sigNetGenesisBlock = wire.MsgBlock{
+	Header: wire.BlockHeader{
+		Version:    1,
+		PrevBlock:  chainhash.Hash{},         // 0000000000000000000000000000000000000000000000000000000000000000
+		MerkleRoot: sigNetGenesisMerkleRoot,  // 4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b
+		Timestamp:  time.Unix(1598918400, 0), // 2020-09-01 00:00:00 +0000 UTC
+		Bits:       0x1e0377ae,               // 503543726 [00000377ae000000000000000000000000000000000000000000000000000000]
+		Nonce:      52613770,
+	},
+	Transactions: []*wire.MsgTx{&genesisCoinbaseTx},
+}
@@ -118,6 +118,38 @@ func TestSimNetGenesisBlock(t *testing.T) {
 	}
 }
 
+// TestScaleNetGenesisBlock tests the genesis block of the scaling test network
+// for validity by checking its hash.
+func TestScaleNetGenesisBlock(t *testing.T) {
+	hash := ScaleNetParams.GenesisBlock.BlockHash()
+	if !ScaleNetParams.GenesisHash.IsEqual(&hash) {
+		t.Fatalf("TestScaleNetGenesisBlock: Genesis block hash does "+
+			"not appear valid - got %v, want %v", spew.Sdump(hash),
+			spew.Sdump(ScaleNetParams.GenesisHash))
+	}
+	want := "00000000e6453dc2dfe1ffa19023f86002eb11dbb8e87d0291a4599f0430be52"
+	if hash.String() != want {
+		t.Fatalf("TestScaleNetGenesisBlock: got hash %v, want %v",
+			hash, want)
+	}
+}
+
+// TestSigNetGenesisBlock tests the genesis block of the signed test network
+// for validity by checking its hash.
+func TestSigNetGenesisBlock(t *testing.T) {
+	hash := SigNetParams.GenesisBlock.BlockHash()
+	if !SigNetParams.GenesisHash.IsEqual(&hash) {
+		t.Fatalf("TestSigNetGenesisBlock: Genesis block hash does "+
+			"not appear valid - got %v, want %v", spew.Sdump(hash),
+			spew.Sdump(SigNetParams.GenesisHash))
+	}
+	want := "00000008819873e925422c1ff0f99f7cc9bbb232af63a077a480a3633bee1ef6"
+	if hash.String() != want {
+		t.Fatalf("TestSigNetGenesisBlock: got hash %v, want %v",
+			hash, want)
+	}
+}
+
 // genesisBlockBytes are the wire encoded bytes for the genesis block of the
 // main network as of protocol version 60002.
 var genesisBlockBytes = []byte{
@@ -38,6 +38,11 @@ var (
 	// simNetPowLimit is the highest proof of work value a Bitcoin block
 	// can have for the simulation test network.  It is the value 2^255 - 1.
 	simNetPowLimit = new(big.Int).Sub(new(big.Int).Lsh(bigOne, 255), bigOne)
+
+	// sigNetPowLimit is the highest proof of work value a Bitcoin block
+	// can have for the signed test network.  It is the value
+	// 0x0377ae * 2^216.
+	sigNetPowLimit = new(big.Int).Lsh(big.NewInt(0x0377ae), 216)
 )
 
 // Checkpoint identifies a known good point in the block chain.  Using
@@ -55,6 +60,23 @@ type Checkpoint struct {
 	UtxoSetSize    uint32
 }
 
+// ChainTxData describes the number of transactions of a network at a point in
+// time along with the rate it grows at afterwards.  It is used to estimate the
+// progress of the initial block download, where the number of transactions is
+// a better measure of the remaining work than the number of blocks.
+type ChainTxData struct {
+	// Time is the timestamp of the block the data was taken at.
+	Time time.Time
+
+	// TxCount is the total number of transactions in the chain up to and
+	// including that block.
+	TxCount uint64
+
+	// TxRate is the estimated number of transactions per second after
+	// that block.
+	TxRate float64
+}
+
 // DNSSeed identifies a DNS seed.
 type DNSSeed struct {
 	// Host defines the hostname of the seed.
@@ -149,9 +171,9 @@ type Params struct {
 	BIP0065Height int32
 	BIP0066Height int32
 
-	// Only testnet4 and chipnet uses CSV activation by height. All the others use the
-	// deployment schedule. If this value is set to anything other than zero
-	// then it will activate at this height.
+	// Only testnet4, chipnet and scalenet use CSV activation by height. All
+	// the others use the deployment schedule. If this value is set to
+	// anything other than zero then it will activate at this height.
 	CSVHeight int32
 
 	// The following are the heights at which the Bitcoin Cash specific forks
@@ -229,9 +251,32 @@ type Params struct {
 	// GenerateSupported specifies whether or not CPU mining is allowed.
 	GenerateSupported bool
 
+	// SignetChallenge is the script the coinbase of every block but the
+	// genesis block must provide a signature solution for on signed test
+	// networks.  It is nil on all other networks.
+	SignetChallenge []byte
+
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints []Checkpoint
 
+	// MinimumChainWork is the cumulative work the main chain of the
+	// network is known to have at least.  Block headers which fork the
+	// main chain from a block with less work are rejected once the main
+	// chain has this much work, and the chain is not considered current
+	// before it does.  It is nil when no minimum is known.  Candidates are
+	// reported by the getcheckpointcandidates RPC.
+	MinimumChainWork *big.Int
+
+	// AssumeValid is the hash of a block which is known to be valid.  The
+	// scripts of the block and its ancestors are not validated during the
+	// initial sync.  It is nil when all scripts are validated.
+	AssumeValid *chainhash.Hash
+
+	// ChainTxData describes the growth of the number of transactions of
+	// the network.  The sync progress is estimated from the average rate
+	// of the chain when it is not set.
+	ChainTxData ChainTxData
+
 	// These fields are related to voting on consensus rule changes as
 	// defined by BIP0009.
 	//
@@ -418,6 +463,15 @@ var MainNetParams = Params{
 		},
 	},
 
+	// Data as of block
+	// 000000000000000001d2ce557406b017a928be25ee98906397d339c3f68eec5d
+	// (height 523992).
+	ChainTxData: ChainTxData{
+		Time:    time.Unix(1522608016, 0),
+		TxCount: 248589038,
+		TxRate:  3.2,
+	},
+
 	// Consensus rule change deployments.
 	//
 	// The miner confirmation window is defined as:
@@ -644,6 +698,15 @@ var TestNet3Params = Params{
 		{Height: 1421482, Hash: newHashFromStr("0000000023e0680a8a062b3cc289a4a341124ce7fcb6340ede207e194d73b60a")},
 	},
 
+	// Data as of block
+	// 000000000005b07ecf85563034d13efd81c1a29e47e22b20f4fc6919d5b09cd6
+	// (height 1223263).
+	ChainTxData: ChainTxData{
+		Time:    time.Unix(1522608381, 0),
+		TxCount: 15052068,
+		TxRate:  0.15,
+	},
+
 	// Consensus rule change deployments.
 	//
 	// The miner confirmation window is defined as:
@@ -688,6 +751,8 @@ var TestNet3Params = Params{
 	SlpAddressPrefix:    "slptest",
 }
 
+// ChipNetParams defines the network parameters for the chip network.  This
+// network activates upcoming network upgrades six months before mainnet.
 var ChipNetParams = Params{
 	Name:        "chipnet",
 	Net:         wire.ChipNet,
@@ -794,6 +859,115 @@ var ChipNetParams = Params{
 	SlpAddressPrefix:    "slptest",
 }
 
+// ScaleNetParams defines the network parameters for the scaling test network.
+// This network is used to test the node with very large blocks.
+var ScaleNetParams = Params{
+	Name:        "scalenet",
+	Net:         wire.ScaleNet,
+	DefaultPort: "38333",
+	DNSSeeds: []DNSSeed{
+		{"scalenet-seed-bch.toom.im", true},
+		{"seed.sbch.loping.net", true},
+	},
+
+	// Chain parameters
+	GenesisBlock:  &scaleNetGenesisBlock,
+	GenesisHash:   &scaleNetGenesisHash,
+	PowLimit:      testNet3PowLimit, // Same value as testnet3
+	PowLimitBits:  0x1d00ffff,
+	BIP0034Height: 2,
+	BIP0065Height: 3,
+	BIP0066Height: 4,
+	CSVHeight:     5,
+
+	UahfForkHeight:              5,
+	DaaForkHeight:               3000,
+	MagneticAnonomalyForkHeight: 3999,
+	GreatWallForkHeight:         0,
+	GravitonForkHeight:          4999,
+	PhononForkHeight:            0,
+	AxionActivationHeight:       16868,
+
+	CosmicInflationActivationTime: 1637694000,
+
+	Upgrade9ForkHeight: 16868,
+	ABLAForkHeight:     16868,
+
+	Upgrade11ActivationTime: 1731672000,
+
+	// Scalenet starts with a 256MB block size limit.
+	ABLAConfig: ABLAConstants{
+		Epsilon0:        128000000,
+		Beta0:           128000000,
+		N0:              16868,
+		GammaReciprocal: 37938,
+		ZetaXB7:         192,
+		ThetaReciprocal: 37938,
+		Delta:           10,
+		FixedSize:       false,
+	},
+
+	CoinbaseMaturity:                     100,
+	SubsidyReductionInterval:             210000,
+	TargetTimespan:                       time.Hour * 24 * 14, // 14 days
+	TargetTimePerBlock:                   time.Minute * 10,    // 10 minutes
+	RetargetAdjustmentFactor:             4,                   // 25% less, 400% more
+	ReduceMinDifficulty:                  true,
+	NoDifficultyAdjustment:               false,
+	MinDiffReductionTime:                 time.Minute * 20, // TargetTimePerBlock * 2
+	AsertDifficultyHalflife:              3600,             // 1 hour
+	AsertDifficultyAnchorHeight:          16868,
+	AsertDifficultyAnchorParentTimestamp: 1605448590,
+	AsertDifficultyAnchorBits:            0x1d00ffff,
+	GenerateSupported:                    false,
+
+	// Checkpoints ordered from oldest to newest.
+	Checkpoints: nil,
+
+	// Consensus rule change deployments.
+	//
+	// The miner confirmation window is defined as:
+	//   target proof of work timespan / target proof of work spacing
+	RuleChangeActivationThreshold: 1512, // 75% of MinerConfirmationWindow
+	MinerConfirmationWindow:       2016,
+	Deployments: [DefinedDeployments]ConsensusDeployment{
+		DeploymentTestDummy: {
+			BitNumber:  28,
+			StartTime:  1199145601, // January 1, 2008 UTC
+			ExpireTime: 1230767999, // December 31, 2008 UTC
+		},
+		DeploymentCSV: {
+			BitNumber:  0,
+			StartTime:  1456790400, // March 1st, 2016
+			ExpireTime: 1493596800, // May 1st, 2017
+		},
+	},
+
+	// Mempool parameters
+	RelayNonStdTxs: true,
+
+	// The prefix for the cashaddress
+	CashAddressPrefix: "bchtest", // always bchtest for testnet
+
+	// Address encoding magics
+	LegacyPubKeyHashAddrID: 0x6f, // starts with m or n
+	LegacyScriptHashAddrID: 0xc4, // starts with 2
+	PrivateKeyID:           0xef, // starts with 9 (uncompressed) or c (compressed)
+
+	// BIP32 hierarchical deterministic extended key magics
+	HDPrivateKeyID: [4]byte{0x04, 0x35, 0x83, 0x94}, // starts with tprv
+	HDPublicKeyID:  [4]byte{0x04, 0x35, 0x87, 0xcf}, // starts with tpub
+
+	// BIP44 coin type used in the hierarchical deterministic path for
+	// address generation.
+	HDCoinType: 1, // all coins use 1
+
+	// slp indexer parameters
+	SlpIndexStartHeight: -1,
+	SlpIndexStartHash:   &chainhash.Hash{},
+	SlpAddressPrefix:    "slptest",
+}
+
 // TestNet4Params defines the network parameters for the test Bitcoin network
 // (version 4).  Not to be confused with the regression test network, this
 // network is sometimes simply called "testnet".
@@ -1004,6 +1178,14 @@ var SimNetParams = Params{
 	HDCoinType: 115, // ASCII for s
 }
 
+// SigNetParams defines the network parameters for the signed test Bitcoin
+// network.  Besides proof of work, blocks on this network require a signature
+// satisfying the signet challenge, which makes it a stable test network that
+// can't be reorganized or flooded with blocks by anyone not holding the
+// signing keys.  Signed test networks with other challenges are created with
+// CustomSignetParams.
+var SigNetParams = CustomSignetParams(DefaultSignetChallenge, nil)
+
 var (
 	// ErrDuplicateNet describes an error where the parameters for a Bitcoin
 	// network could not be set due to the network already being a standard
@@ -1134,4 +1316,5 @@ func init() {
 	mustRegister(&TestNet3Params)
 	mustRegister(&RegressionNetParams)
 	mustRegister(&SimNetParams)
+	mustRegister(&SigNetParams)
 }
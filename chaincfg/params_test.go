@@ -86,3 +86,29 @@ func TestTestNet3Seeds(t *testing.T) {
 		}
 	}
 }
+
+// TestChainTxData ensures the networks with transaction data to estimate the
+// sync progress from have consistent data.
+func TestChainTxData(t *testing.T) {
+	tests := []struct {
+		name   string
+		params *Params
+	}{
+		{"mainnet", &MainNetParams},
+		{"testnet3", &TestNet3Params},
+	}
+	for _, test := range tests {
+		data := test.params.ChainTxData
+		if data.TxCount == 0 || data.TxRate <= 0 {
+			t.Errorf("%s: missing transaction data: %+v", test.name,
+				data)
+			continue
+		}
+		genesisTime := test.params.GenesisBlock.Header.Timestamp
+		if !data.Time.After(genesisTime) {
+			t.Errorf("%s: transaction data time %v precedes the "+
+				"genesis block time %v", test.name, data.Time,
+				genesisTime)
+		}
+	}
+}
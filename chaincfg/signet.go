@@ -0,0 +1,149 @@
+package chaincfg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"time"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/wire"
+)
+
+// DefaultSignetChallenge is the challenge of the default signed test network.
+// It is a 1-of-2 multisig script, which is the challenge of the reference
+// signet.
+var DefaultSignetChallenge = mustDecodeHex("512103ad5e0edad18cb1f0fc0d28a3d4f1f3" +
+	"e445640337489abb10404f2d1e086be430210359ef5021964fe22d6f8e05b2463c95" +
+	"40ce96883fe3b278760f048f5189f2e6c452ae")
+
+// signetMagic returns the network magic of the signed test network with the
+// passed challenge, which is the first four bytes of the double sha256 of the
+// serialized challenge.
+func signetMagic(challenge []byte) wire.BitcoinNet {
+	var buf bytes.Buffer
+	wire.WriteVarBytes(&buf, 0, challenge)
+	hash := chainhash.DoubleHashB(buf.Bytes())
+	return wire.BitcoinNet(binary.LittleEndian.Uint32(hash[:4]))
+}
+
+// CustomSignetParams returns the network parameters of the signed test network
+// with the passed challenge and DNS seeds.  The network magic is derived from
+// the challenge so nodes of signed test networks with different challenges do
+// not connect to each other.  All signed test networks share the genesis block
+// and all network upgrades are active from the first block on.
+func CustomSignetParams(challenge []byte, dnsSeeds []DNSSeed) Params {
+	return Params{
+		Name:        "signet",
+		Net:         signetMagic(challenge),
+		DefaultPort: "38333",
+		DNSSeeds:    dnsSeeds,
+
+		// Chain parameters
+		GenesisBlock:  &sigNetGenesisBlock,
+		GenesisHash:   &sigNetGenesisHash,
+		PowLimit:      sigNetPowLimit,
+		PowLimitBits:  0x1e0377ae,
+		BIP0034Height: 1,
+		BIP0065Height: 1,
+		BIP0066Height: 1,
+		CSVHeight:     1,
+
+		UahfForkHeight:              0, // Always active on signet
+		DaaForkHeight:               0, // Always active on signet
+		MagneticAnonomalyForkHeight: 0, // Always active on signet
+		GreatWallForkHeight:         0, // Always active on signet
+		GravitonForkHeight:          0, // Always active on signet
+		PhononForkHeight:            0, // Always active on signet
+		AxionActivationHeight:       0, // Always active on signet
+
+		CosmicInflationActivationTime: 0, // Always active on signet
+
+		Upgrade9ForkHeight:      0, // Always active on signet
+		ABLAForkHeight:          0, // Always active on signet
+		Upgrade11ActivationTime: 0, // Always active on signet
+
+		ABLAConfig: ABLAConstants{
+			Epsilon0:        1000000,
+			Beta0:           1000000,
+			N0:              0,
+			GammaReciprocal: 37938,
+			ZetaXB7:         192,
+			ThetaReciprocal: 37938,
+			Delta:           10,
+			FixedSize:       true,
+		},
+
+		CoinbaseMaturity:                     100,
+		SubsidyReductionInterval:             210000,
+		TargetTimespan:                       time.Hour * 24 * 14, // 14 days
+		TargetTimePerBlock:                   time.Minute * 10,    // 10 minutes
+		RetargetAdjustmentFactor:             4,                   // 25% less, 400% more
+		ReduceMinDifficulty:                  false,
+		NoDifficultyAdjustment:               false,
+		MinDiffReductionTime:                 0,
+		AsertDifficultyHalflife:              3600, // 1 hour
+		AsertDifficultyAnchorHeight:          0,
+		AsertDifficultyAnchorParentTimestamp: sigNetGenesisBlock.Header.Timestamp.Unix(),
+		AsertDifficultyAnchorBits:            sigNetGenesisBlock.Header.Bits,
+		GenerateSupported:                    true,
+		SignetChallenge:                      challenge,
+
+		// Checkpoints ordered from oldest to newest.
+		Checkpoints: nil,
+
+		// Consensus rule change deployments.
+		//
+		// The miner confirmation window is defined as:
+		//   target proof of work timespan / target proof of work spacing
+		RuleChangeActivationThreshold: 1512, // 75% of MinerConfirmationWindow
+		MinerConfirmationWindow:       2016,
+		Deployments: [DefinedDeployments]ConsensusDeployment{
+			DeploymentTestDummy: {
+				BitNumber:  28,
+				StartTime:  0,             // Always available for vote
+				ExpireTime: math.MaxInt64, // Never expires
+			},
+			DeploymentCSV: {
+				BitNumber:  0,
+				StartTime:  0,             // Always available for vote
+				ExpireTime: math.MaxInt64, // Never expires
+			},
+		},
+
+		// Mempool parameters
+		RelayNonStdTxs: false,
+
+		// The prefix for the cashaddress
+		CashAddressPrefix: "bchtest", // always bchtest for testnet
+
+		// Address encoding magics
+		LegacyPubKeyHashAddrID: 0x6f, // starts with m or n
+		LegacyScriptHashAddrID: 0xc4, // starts with 2
+		PrivateKeyID:           0xef, // starts with 9 (uncompressed) or c (compressed)
+
+		// BIP32 hierarchical deterministic extended key magics
+		HDPrivateKeyID: [4]byte{0x04, 0x35, 0x83, 0x94}, // starts with tprv
+		HDPublicKeyID:  [4]byte{0x04, 0x35, 0x87, 0xcf}, // starts with tpub
+
+		// BIP44 coin type used in the hierarchical deterministic path for
+		// address generation.
+		HDCoinType: 1, // all coins use 1
+
+		// slp indexer parameters
+		SlpIndexStartHeight: -1,
+		SlpIndexStartHash:   &chainhash.Hash{},
+		SlpAddressPrefix:    "slptest",
+	}
+}
+
+// mustDecodeHex decodes the passed hard-coded hex string.  It panics on an
+// error since it must only be called with known good strings.
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
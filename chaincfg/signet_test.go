@@ -0,0 +1,38 @@
+package chaincfg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gcash/bchd/wire"
+)
+
+// TestCustomSignetParams ensures signed test networks derive their network
+// magic from their challenge.
+func TestCustomSignetParams(t *testing.T) {
+	if SigNetParams.Net != wire.SigNet {
+		t.Fatalf("unexpected default signet magic - got %v, want %v",
+			SigNetParams.Net, wire.SigNet)
+	}
+	if !bytes.Equal(SigNetParams.SignetChallenge, DefaultSignetChallenge) {
+		t.Fatal("default signet does not use the default challenge")
+	}
+
+	challenge := []byte{0x51}
+	params := CustomSignetParams(challenge, []DNSSeed{{Host: "seed.example.com"}})
+	if params.Net == wire.SigNet {
+		t.Fatal("custom signet uses the default signet magic")
+	}
+	if params.Net != signetMagic(challenge) ||
+		!bytes.Equal(params.SignetChallenge, challenge) {
+
+		t.Fatalf("unexpected custom signet %v with challenge %x",
+			params.Net, params.SignetChallenge)
+	}
+	if len(params.DNSSeeds) != 1 || params.DNSSeeds[0].Host != "seed.example.com" {
+		t.Fatalf("unexpected dns seeds %v", params.DNSSeeds)
+	}
+	if *params.GenesisHash != *SigNetParams.GenesisHash {
+		t.Fatal("custom signet does not share the genesis block")
+	}
+}
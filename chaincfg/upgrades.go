@@ -0,0 +1,74 @@
+package chaincfg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxActivationHeight is the largest height an upgrade may be scheduled at.
+const maxActivationHeight = 1<<31 - 1
+
+// upgradeActivations maps the name of each network upgrade whose activation
+// may be overridden to a function which sets its activation.  Upgrades which
+// activate by height take the height of the last block before the upgrade,
+// while those which activate by time take the median time past at which the
+// upgrade activates.
+var upgradeActivations = map[string]func(p *Params, activation int64){
+	"magneticanomaly": func(p *Params, v int64) { p.MagneticAnonomalyForkHeight = int32(v) },
+	"greatwall":       func(p *Params, v int64) { p.GreatWallForkHeight = int32(v) },
+	"graviton":        func(p *Params, v int64) { p.GravitonForkHeight = int32(v) },
+	"phonon":          func(p *Params, v int64) { p.PhononForkHeight = int32(v) },
+	"axion":           func(p *Params, v int64) { p.AxionActivationHeight = int32(v) },
+	"cosmicinflation": func(p *Params, v int64) { p.CosmicInflationActivationTime = uint64(v) },
+	"upgrade9":        func(p *Params, v int64) { p.Upgrade9ForkHeight = int32(v) },
+	"abla":            func(p *Params, v int64) { p.ABLAForkHeight = int32(v) },
+	"upgrade11":       func(p *Params, v int64) { p.Upgrade11ActivationTime = uint64(v) },
+}
+
+// timeActivatedUpgrades are the upgrades which activate by median time past
+// rather than by height.
+var timeActivatedUpgrades = map[string]struct{}{
+	"cosmicinflation": {},
+	"upgrade11":       {},
+}
+
+// UpgradeNames returns the names of the network upgrades whose activation may
+// be overridden with SetUpgradeActivation.
+func UpgradeNames() []string {
+	names := make([]string, 0, len(upgradeActivations))
+	for name := range upgradeActivations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetUpgradeActivation overrides the activation of the named network upgrade.
+// For upgrades which activate by height the activation is the height of the
+// last block before the upgrade, so an activation of 0 enforces the upgrade
+// from the first block after genesis.  For upgrades which activate by time it
+// is the median time past, in seconds since the epoch, from which the upgrade
+// is enforced.
+//
+// This is intended to let test networks exercise upgrade boundaries and must
+// not be used on public networks.
+func (p *Params) SetUpgradeActivation(upgrade string, activation int64) error {
+	upgrade = strings.ToLower(upgrade)
+	set, ok := upgradeActivations[upgrade]
+	if !ok {
+		return fmt.Errorf("unknown upgrade %q -- valid upgrades are %s",
+			upgrade, strings.Join(UpgradeNames(), ", "))
+	}
+	if _, ok := timeActivatedUpgrades[upgrade]; ok {
+		if activation < 0 {
+			return fmt.Errorf("invalid activation time %d for %s",
+				activation, upgrade)
+		}
+	} else if activation < 0 || activation > maxActivationHeight {
+		return fmt.Errorf("invalid activation height %d for %s",
+			activation, upgrade)
+	}
+	set(p, activation)
+	return nil
+}
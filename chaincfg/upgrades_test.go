@@ -0,0 +1,42 @@
+package chaincfg
+
+import "testing"
+
+// TestSetUpgradeActivation ensures upgrade activations can be overridden and
+// that invalid overrides are rejected.
+func TestSetUpgradeActivation(t *testing.T) {
+	params := RegressionNetParams
+	if err := params.SetUpgradeActivation("upgrade9", 150); err != nil {
+		t.Fatalf("SetUpgradeActivation: %v", err)
+	}
+	if err := params.SetUpgradeActivation("Upgrade11", 1747310400); err != nil {
+		t.Fatalf("SetUpgradeActivation: %v", err)
+	}
+	if params.Upgrade9ForkHeight != 150 {
+		t.Fatalf("unexpected upgrade9 height %d", params.Upgrade9ForkHeight)
+	}
+	if params.Upgrade11ActivationTime != 1747310400 {
+		t.Fatalf("unexpected upgrade11 time %d",
+			params.Upgrade11ActivationTime)
+	}
+	if RegressionNetParams.Upgrade9ForkHeight == 150 {
+		t.Fatal("regtest parameters were modified")
+	}
+
+	tests := []struct {
+		upgrade    string
+		activation int64
+	}{
+		{"upgrade12", 0},
+		{"upgrade9", -1},
+		{"abla", 1 << 31},
+		{"cosmicinflation", -1},
+	}
+	for _, test := range tests {
+		err := params.SetUpgradeActivation(test.upgrade, test.activation)
+		if err == nil {
+			t.Errorf("SetUpgradeActivation(%s, %d): expected error",
+				test.upgrade, test.activation)
+		}
+	}
+}
@@ -2,8 +2,6 @@ package main
 
 import (
 	"bufio"
-	"bytes"
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -19,17 +17,17 @@ const (
 )
 
 // commandUsage display the usage for a specific command.
-func commandUsage(method string) {
+func commandUsage(w io.Writer, method string) {
 	usage, err := btcjson.MethodUsageText(method)
 	if err != nil {
 		// This should never happen since the method was already checked
 		// before calling this function, but be safe.
-		fmt.Fprintln(os.Stderr, "Failed to obtain command usage:", err)
+		fmt.Fprintln(w, "Failed to obtain command usage:", err)
 		return
 	}
 
-	fmt.Fprintln(os.Stderr, "Usage:")
-	fmt.Fprintf(os.Stderr, "  %s\n", usage)
+	fmt.Fprintln(w, "Usage:")
+	fmt.Fprintf(w, "  %s\n", usage)
 }
 
 // usage displays the general usage when the help flag is not displayed and
@@ -40,36 +38,52 @@ func usage(errorMessage string) {
 	appName = strings.TrimSuffix(appName, filepath.Ext(appName))
 	fmt.Fprintln(os.Stderr, errorMessage)
 	fmt.Fprintln(os.Stderr, "Usage:")
-	fmt.Fprintf(os.Stderr, "  %s [OPTIONS] <command> <args...>\n\n",
+	fmt.Fprintf(os.Stderr, "  %s [OPTIONS] <command> <args...>\n",
 		appName)
+	fmt.Fprintf(os.Stderr, "  %s [OPTIONS] -i\n\n", appName)
 	fmt.Fprintln(os.Stderr, showHelpMessage)
 	fmt.Fprintln(os.Stderr, listCmdMessage)
 }
 
-func main() {
-	cfg, args, err := loadConfig()
-	if err != nil {
-		os.Exit(1)
+// sendCommand sends the passed command to the server using the user-specified
+// connection configuration and returns the JSON-encoded result.
+func sendCommand(cmd interface{}, cfg *config) ([]byte, error) {
+	if cfg.GRPC {
+		return sendGRPCRequest(cmd, cfg)
 	}
-	if len(args) < 1 {
-		usage("No command specified")
-		os.Exit(1)
+
+	// Marshal the command into a JSON-RPC byte slice in preparation for
+	// sending it to the RPC server.
+	marshalledJSON, err := btcjson.MarshalCmd("1.0", 1, cmd)
+	if err != nil {
+		return nil, err
 	}
 
+	// Send the JSON-RPC request to the server using the user-specified
+	// connection configuration.
+	return sendPostRequest(marshalledJSON, cfg)
+}
+
+// runCommand creates the command identified by the first of the passed args
+// with the remaining args as its parameters, sends it to the server and
+// writes the result to out in the configured format.  Errors are written to
+// errOut.  Arguments of '-' are read from stdin, which is nil when they are
+// not supported.  It returns whether or not the command succeeded.
+func runCommand(cfg *config, args []string, stdin *bufio.Reader, out, errOut io.Writer) bool {
 	// Ensure the specified method identifies a valid registered command and
 	// is one of the usable types.
 	method := args[0]
 	usageFlags, err := btcjson.MethodUsageFlags(method)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Unrecognized command '%s'\n", method)
-		fmt.Fprintln(os.Stderr, listCmdMessage)
-		os.Exit(1)
+		fmt.Fprintf(errOut, "Unrecognized command '%s'\n", method)
+		fmt.Fprintln(errOut, listCmdMessage)
+		return false
 	}
 	if usageFlags&unusableFlags != 0 {
-		fmt.Fprintf(os.Stderr, "The '%s' command can only be used via "+
+		fmt.Fprintf(errOut, "The '%s' command can only be used via "+
 			"websockets\n", method)
-		fmt.Fprintln(os.Stderr, listCmdMessage)
-		os.Exit(1)
+		fmt.Fprintln(errOut, listCmdMessage)
+		return false
 	}
 
 	// Convert remaining command line args to a slice of interface values
@@ -79,20 +93,19 @@ func main() {
 	// too large for the Operating System to allow as a normal command line
 	// parameter, support using '-' as an argument to allow the argument
 	// to be read from a stdin pipe.
-	bio := bufio.NewReader(os.Stdin)
 	params := make([]interface{}, 0, len(args[1:]))
 	for _, arg := range args[1:] {
-		if arg == "-" {
-			param, err := bio.ReadString('\n')
+		if arg == "-" && stdin != nil {
+			param, err := stdin.ReadString('\n')
 			if err != nil && err != io.EOF {
-				fmt.Fprintf(os.Stderr, "Failed to read data "+
+				fmt.Fprintf(errOut, "Failed to read data "+
 					"from stdin: %v\n", err)
-				os.Exit(1)
+				return false
 			}
 			if err == io.EOF && len(param) == 0 {
-				fmt.Fprintln(os.Stderr, "Not enough lines "+
+				fmt.Fprintln(errOut, "Not enough lines "+
 					"provided on stdin")
-				os.Exit(1)
+				return false
 			}
 			param = strings.TrimRight(param, "\r\n")
 			params = append(params, param)
@@ -111,57 +124,55 @@ func main() {
 		// NewCmd function is only supposed to return errors of that
 		// type.
 		if jerr, ok := err.(btcjson.Error); ok {
-			fmt.Fprintf(os.Stderr, "%s command: %v (code: %s)\n",
+			fmt.Fprintf(errOut, "%s command: %v (code: %s)\n",
 				method, err, jerr.ErrorCode)
-			commandUsage(method)
-			os.Exit(1)
+			commandUsage(errOut, method)
+			return false
 		}
 
 		// The error is not a btcjson.Error and this really should not
 		// happen.  Nevertheless, fallback to just showing the error
 		// if it should happen due to a bug in the package.
-		fmt.Fprintf(os.Stderr, "%s command: %v\n", method, err)
-		commandUsage(method)
-		os.Exit(1)
+		fmt.Fprintf(errOut, "%s command: %v\n", method, err)
+		commandUsage(errOut, method)
+		return false
 	}
 
-	// Marshal the command into a JSON-RPC byte slice in preparation for
-	// sending it to the RPC server.
-	marshalledJSON, err := btcjson.MarshalCmd("1.0", 1, cmd)
+	result, err := sendCommand(cmd, cfg)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		fmt.Fprintln(errOut, err)
+		return false
 	}
 
-	// Send the JSON-RPC request to the server using the user-specified
-	// connection configuration.
-	result, err := sendPostRequest(marshalledJSON, cfg)
+	if err := writeResult(out, result, cfg.Format); err != nil {
+		fmt.Fprintln(errOut, err)
+		return false
+	}
+	return true
+}
+
+func main() {
+	cfg, args, err := loadConfig()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
-
-	// Choose how to display the result based on its type.
-	strResult := string(result)
-	if strings.HasPrefix(strResult, "{") || strings.HasPrefix(strResult, "[") {
-		var dst bytes.Buffer
-		if err := json.Indent(&dst, result, "", "  "); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to format result: %v",
-				err)
+	if cfg.Interactive {
+		if len(args) > 0 {
+			usage("Commands can't be specified in interactive mode")
 			os.Exit(1)
 		}
-		fmt.Println(dst.String())
-
-	} else if strings.HasPrefix(strResult, `"`) {
-		var str string
-		if err := json.Unmarshal(result, &str); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to unmarshal result: %v",
-				err)
+		if err := runInteractive(cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
-		fmt.Println(str)
+		return
+	}
+	if len(args) < 1 {
+		usage("No command specified")
+		os.Exit(1)
+	}
 
-	} else if strResult != "null" {
-		fmt.Println(strResult)
+	if !runCommand(cfg, args, bufio.NewReader(os.Stdin), os.Stdout, os.Stderr) {
+		os.Exit(1)
 	}
 }
@@ -32,6 +32,8 @@ var (
 	bchwalletHomeDir      = bchutil.AppDataDir("bchwallet", false)
 	defaultConfigFile     = filepath.Join(bchctlHomeDir, "bchctl.conf")
 	defaultRPCServer      = "localhost"
+	defaultGRPCServer     = "localhost"
+	defaultFormat         = formatJSON
 	defaultRPCCertFile    = filepath.Join(bchdHomeDir, "rpc.cert")
 	defaultWalletCertFile = filepath.Join(bchwalletHomeDir, "rpc.cert")
 )
@@ -98,6 +100,7 @@ type config struct {
 	ConfigFile    string `short:"C" long:"configfile" description:"Path to configuration file"`
 	RPCUser       string `short:"u" long:"rpcuser" description:"RPC username"`
 	RPCPassword   string `short:"P" long:"rpcpass" default-mask:"-" description:"RPC password"`
+	RPCCookieFile string `long:"rpccookiefile" description:"File containing the cookie credential of the RPC server to authenticate with instead of rpcuser/rpcpass (default: .cookie in the data directory of bchd when no rpcuser/rpcpass is specified)"`
 	RPCServer     string `short:"s" long:"rpcserver" description:"RPC server to connect to"`
 	RPCCert       string `short:"c" long:"rpccert" description:"RPC server certificate chain for validation"`
 	NoTLS         bool   `long:"notls" description:"Disable TLS"`
@@ -108,6 +111,11 @@ type config struct {
 	SimNet        bool   `long:"simnet" description:"Connect to the simulation test network"`
 	TLSSkipVerify bool   `long:"skipverify" description:"Do not verify tls certificates (not recommended!)"`
 	Wallet        bool   `long:"wallet" description:"Connect to wallet"`
+	Interactive   bool   `short:"i" long:"interactive" description:"Start an interactive shell which sends each entered command to the server"`
+	Format        string `short:"f" long:"format" description:"Format to display the results in {json, table, csv}"`
+	GRPC          bool   `long:"grpc" description:"Send the commands to the gRPC API instead of the JSON-RPC server -- Only the commands with an equivalent gRPC method are supported"`
+	GRPCServer    string `long:"grpcserver" description:"gRPC server to connect to"`
+	GRPCAuthToken string `long:"grpcauthtoken" default-mask:"-" description:"Authentication token for the gRPC API"`
 }
 
 // normalizeAddress returns addr with the passed default port appended if
@@ -142,6 +150,26 @@ func normalizeAddress(addr string, useTestNet3, useSimNet, useWallet bool) strin
 	return addr
 }
 
+// normalizeGRPCAddress returns addr with the default gRPC port of the selected
+// network appended if there is not already a port specified.
+func normalizeGRPCAddress(addr string, useTestNet3, useSimNet bool) string {
+	_, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		var defaultPort string
+		switch {
+		case useTestNet3:
+			defaultPort = "18335"
+		case useSimNet:
+			defaultPort = "18557"
+		default:
+			defaultPort = "8335"
+		}
+
+		return net.JoinHostPort(addr, defaultPort)
+	}
+	return addr
+}
+
 // cleanAndExpandPath expands environement variables and leading ~ in the
 // passed path, cleans the result, and returns it.
 func cleanAndExpandPath(path string) string {
@@ -174,6 +202,8 @@ func loadConfig() (*config, []string, error) {
 		ConfigFile: defaultConfigFile,
 		RPCServer:  defaultRPCServer,
 		RPCCert:    defaultRPCCertFile,
+		Format:     defaultFormat,
+		GRPCServer: defaultGRPCServer,
 	}
 
 	// Pre-parse the command line options to see if an alternative config
@@ -190,7 +220,9 @@ func loadConfig() (*config, []string, error) {
 			fmt.Fprintln(os.Stderr, "The special parameter `-` "+
 				"indicates that a parameter should be read "+
 				"from the\nnext unread line from standard "+
-				"input.")
+				"input.\n\nIn interactive mode (-i) commands are "+
+				"read from standard input\nand their arguments "+
+				"may be quoted.  Enter exit or quit to leave.")
 			return nil, nil, err
 		}
 	}
@@ -263,6 +295,25 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Validate the output format.
+	if !validFormat(cfg.Format) {
+		str := "%s: The specified format [%v] is invalid -- " +
+			"supported formats are json, table and csv"
+		err := fmt.Errorf(str, "loadConfig", cfg.Format)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The wallet does not provide a gRPC API.
+	if cfg.GRPC && cfg.Wallet {
+		str := "%s: The grpc and wallet options can't be used together"
+		err := fmt.Errorf(str, "loadConfig")
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Override the RPC certificate if the --wallet flag was specified and
 	// the user did not specify one.
 	if cfg.Wallet && cfg.RPCCert == defaultRPCCertFile {
@@ -272,14 +323,61 @@ func loadConfig() (*config, []string, error) {
 	// Handle environment variable expansion in the RPC certificate path.
 	cfg.RPCCert = cleanAndExpandPath(cfg.RPCCert)
 
+	// Authenticate with the cookie credential of the RPC server when the
+	// cookie file is specified, or when no username and password are and
+	// the default cookie file exists.
+	if cfg.RPCCookieFile != "" {
+		if err := readCookieFile(&cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, err
+		}
+	} else if cfg.RPCUser == "" && cfg.RPCPassword == "" && !cfg.Wallet {
+		// The error is ignored since the server may not use cookie
+		// authentication.
+		_ = readCookieFile(&cfg)
+	}
+
 	// Add default port to RPC server based on --testnet and --wallet flags
 	// if needed.
 	cfg.RPCServer = normalizeAddress(cfg.RPCServer, cfg.TestNet3,
 		cfg.SimNet, cfg.Wallet)
 
+	// Add default port to gRPC server based on --testnet and --simnet
+	// flags if needed.
+	cfg.GRPCServer = normalizeGRPCAddress(cfg.GRPCServer, cfg.TestNet3,
+		cfg.SimNet)
+
 	return &cfg, remainingArgs, nil
 }
 
+// readCookieFile sets the RPC username and password to the credential in the
+// cookie file of the RPC server.  The file in the data directory of bchd for
+// the selected network is used when none is specified.
+func readCookieFile(cfg *config) error {
+	path := cfg.RPCCookieFile
+	if path == "" {
+		netName := "mainnet"
+		switch {
+		case cfg.TestNet3:
+			netName = "testnet"
+		case cfg.SimNet:
+			netName = "simnet"
+		}
+		path = filepath.Join(bchdHomeDir, "data", netName, ".cookie")
+	}
+	contents, err := ioutil.ReadFile(cleanAndExpandPath(path))
+	if err != nil {
+		return err
+	}
+	user, password, ok := strings.Cut(strings.TrimSpace(string(contents)), ":")
+	if !ok {
+		return fmt.Errorf("cookie file %s is malformed", path)
+	}
+	cfg.RPCUser = user
+	cfg.RPCPassword = password
+	return nil
+}
+
 // createDefaultConfig creates a basic config file at the given destination path.
 // For this it tries to read the config file for the RPC server (either bchd or
 // bchwallet), and extract the RPC user and password from it.
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// These constants define the formats the results may be displayed in.
+const (
+	formatJSON  = "json"
+	formatTable = "table"
+	formatCSV   = "csv"
+)
+
+// validFormat returns whether or not format is a supported output format.
+func validFormat(format string) bool {
+	switch format {
+	case formatJSON, formatTable, formatCSV:
+		return true
+	}
+	return false
+}
+
+// writeResult writes the passed JSON-encoded result to w in the passed format.
+// Results which are null are not written.
+func writeResult(w io.Writer, result []byte, format string) error {
+	result = bytes.TrimSpace(result)
+	if len(result) == 0 || string(result) == "null" {
+		return nil
+	}
+
+	switch format {
+	case formatTable, formatCSV:
+		header, rows, err := tabulate(result)
+		if err != nil {
+			return err
+		}
+		if format == formatCSV {
+			return writeCSV(w, header, rows)
+		}
+		return writeTable(w, header, rows)
+	}
+
+	// Choose how to display the result based on its type.
+	switch result[0] {
+	case '{', '[':
+		var dst bytes.Buffer
+		if err := json.Indent(&dst, result, "", "  "); err != nil {
+			return fmt.Errorf("failed to format result: %v", err)
+		}
+		fmt.Fprintln(w, dst.String())
+
+	case '"':
+		var str string
+		if err := json.Unmarshal(result, &str); err != nil {
+			return fmt.Errorf("failed to unmarshal result: %v", err)
+		}
+		fmt.Fprintln(w, str)
+
+	default:
+		fmt.Fprintln(w, string(result))
+	}
+	return nil
+}
+
+// objectKeys returns the keys of the passed JSON object in the order they
+// appear in.
+func objectKeys(object []byte) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(object))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	var keys []string
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key.(string))
+
+		// Skip the value.
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// cell returns the passed JSON value as the text of a table cell.  Strings are
+// shown without quotes and other values in their compact JSON encoding.
+func cell(value json.RawMessage) string {
+	var str string
+	if err := json.Unmarshal(value, &str); err == nil {
+		return str
+	}
+	var dst bytes.Buffer
+	if err := json.Compact(&dst, value); err != nil {
+		return string(value)
+	}
+	return dst.String()
+}
+
+// tabulate converts the passed JSON-encoded result to the rows of a table.  An
+// array of objects has a column for each key of the objects and a row for each
+// object, an object has a row for each of its keys and an array of other values
+// has a row for each value.  The header is nil for a single value and an
+// empty array.
+func tabulate(result []byte) ([]string, [][]string, error) {
+	switch result[0] {
+	case '{':
+		keys, err := objectKeys(result)
+		if err != nil {
+			return nil, nil, err
+		}
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(result, &object); err != nil {
+			return nil, nil, err
+		}
+		rows := make([][]string, 0, len(keys))
+		for _, key := range keys {
+			rows = append(rows, []string{key, cell(object[key])})
+		}
+		return []string{"key", "value"}, rows, nil
+
+	case '[':
+		var values []json.RawMessage
+		if err := json.Unmarshal(result, &values); err != nil {
+			return nil, nil, err
+		}
+		if len(values) == 0 {
+			return nil, nil, nil
+		}
+
+		// Arrays of other values than objects have a single column.
+		objects := true
+		for _, value := range values {
+			if len(value) == 0 || value[0] != '{' {
+				objects = false
+				break
+			}
+		}
+		if !objects {
+			rows := make([][]string, 0, len(values))
+			for _, value := range values {
+				rows = append(rows, []string{cell(value)})
+			}
+			return []string{"value"}, rows, nil
+		}
+
+		// Collect the keys of all of the objects in the order they
+		// first appear in.
+		var header []string
+		seen := make(map[string]struct{})
+		parsed := make([]map[string]json.RawMessage, 0, len(values))
+		for _, value := range values {
+			keys, err := objectKeys(value)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, key := range keys {
+				if _, ok := seen[key]; !ok {
+					seen[key] = struct{}{}
+					header = append(header, key)
+				}
+			}
+			var object map[string]json.RawMessage
+			if err := json.Unmarshal(value, &object); err != nil {
+				return nil, nil, err
+			}
+			parsed = append(parsed, object)
+		}
+		rows := make([][]string, 0, len(parsed))
+		for _, object := range parsed {
+			row := make([]string, 0, len(header))
+			for _, key := range header {
+				var text string
+				if value, ok := object[key]; ok {
+					text = cell(value)
+				}
+				row = append(row, text)
+			}
+			rows = append(rows, row)
+		}
+		return header, rows, nil
+	}
+
+	return nil, [][]string{{cell(result)}}, nil
+}
+
+// writeTable writes the passed rows as a table with aligned columns.
+func writeTable(w io.Writer, header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if header != nil {
+		upper := make([]string, 0, len(header))
+		for _, column := range header {
+			upper = append(upper, strings.ToUpper(column))
+		}
+		fmt.Fprintln(tw, strings.Join(upper, "\t"))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// writeCSV writes the passed rows as comma-separated values.
+func writeCSV(w io.Writer, header []string, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	if header != nil {
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/btcsuite/go-socks/socks"
+	"github.com/gcash/bchd/bchrpc/pb"
+	"github.com/gcash/bchd/btcjson"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// grpcAuthTokenKey is the metadata key of the authentication token of the
+// gRPC API.
+const grpcAuthTokenKey = "AuthenticationToken"
+
+// grpcTimeout is the time a command sent to the gRPC API may take.
+const grpcTimeout = time.Minute
+
+// grpcHandler sends a command to the gRPC API and returns the result to display.
+// Protocol buffer messages are displayed in their JSON encoding, in which
+// bytes are base64-encoded, while other results are displayed the same way as
+// the results of the JSON-RPC server.
+type grpcHandler func(ctx context.Context, c pb.BchrpcClient, cmd interface{}) (interface{}, error)
+
+// grpcHandlers maps the commands which may be sent to the gRPC API to their
+// handlers.  The gRPC API has methods of its own, so only the commands with an
+// equivalent method are supported.
+var grpcHandlers = map[string]grpcHandler{
+	"getbestblockhash":   grpcGetBestBlockHash,
+	"getblock":           grpcGetBlock,
+	"getblockchaininfo":  grpcGetBlockchainInfo,
+	"getblockcount":      grpcGetBlockCount,
+	"getblockheader":     grpcGetBlockHeader,
+	"getmempoolinfo":     grpcGetMempoolInfo,
+	"getrawmempool":      grpcGetRawMempool,
+	"getrawtransaction":  grpcGetRawTransaction,
+	"sendrawtransaction": grpcSendRawTransaction,
+}
+
+// grpcHash returns the little-endian bytes of the passed hash string as used
+// by the gRPC API.
+func grpcHash(str string) ([]byte, error) {
+	hash, err := chainhash.NewHashFromStr(str)
+	if err != nil {
+		return nil, err
+	}
+	return hash[:], nil
+}
+
+// grpcHashString returns the passed little-endian hash of the gRPC API as a
+// hash string.
+func grpcHashString(b []byte) (string, error) {
+	hash, err := chainhash.NewHash(b)
+	if err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
+
+// grpcGetBestBlockHash implements the getbestblockhash command.
+func grpcGetBestBlockHash(ctx context.Context, c pb.BchrpcClient, cmd interface{}) (interface{}, error) {
+	resp, err := c.GetBlockchainInfo(ctx, &pb.GetBlockchainInfoRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return grpcHashString(resp.BestBlockHash)
+}
+
+// grpcGetBlock implements the getblock command.
+func grpcGetBlock(ctx context.Context, c pb.BchrpcClient, cmd interface{}) (interface{}, error) {
+	c2 := cmd.(*btcjson.GetBlockCmd)
+	hash, err := grpcHash(c2.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	// The raw block is returned for verbosity 0 like the JSON-RPC server
+	// does.
+	if c2.Verbosity != nil && *c2.Verbosity == 0 {
+		resp, err := c.GetRawBlock(ctx, &pb.GetRawBlockRequest{
+			HashOrHeight: &pb.GetRawBlockRequest_Hash{Hash: hash},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return hex.EncodeToString(resp.Block), nil
+	}
+	return c.GetBlock(ctx, &pb.GetBlockRequest{
+		HashOrHeight:     &pb.GetBlockRequest_Hash{Hash: hash},
+		FullTransactions: c2.Verbosity != nil && *c2.Verbosity > 1,
+	})
+}
+
+// grpcGetBlockchainInfo implements the getblockchaininfo command.
+func grpcGetBlockchainInfo(ctx context.Context, c pb.BchrpcClient, cmd interface{}) (interface{}, error) {
+	return c.GetBlockchainInfo(ctx, &pb.GetBlockchainInfoRequest{})
+}
+
+// grpcGetBlockCount implements the getblockcount command.
+func grpcGetBlockCount(ctx context.Context, c pb.BchrpcClient, cmd interface{}) (interface{}, error) {
+	resp, err := c.GetBlockchainInfo(ctx, &pb.GetBlockchainInfoRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.BestHeight, nil
+}
+
+// grpcGetBlockHeader implements the getblockheader command.
+func grpcGetBlockHeader(ctx context.Context, c pb.BchrpcClient, cmd interface{}) (interface{}, error) {
+	c2 := cmd.(*btcjson.GetBlockHeaderCmd)
+	hash, err := grpcHash(c2.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetBlockInfo(ctx, &pb.GetBlockInfoRequest{
+		HashOrHeight: &pb.GetBlockInfoRequest_Hash{Hash: hash},
+	})
+}
+
+// grpcGetMempoolInfo implements the getmempoolinfo command.
+func grpcGetMempoolInfo(ctx context.Context, c pb.BchrpcClient, cmd interface{}) (interface{}, error) {
+	return c.GetMempoolInfo(ctx, &pb.GetMempoolInfoRequest{})
+}
+
+// grpcGetRawMempool implements the getrawmempool command.
+func grpcGetRawMempool(ctx context.Context, c pb.BchrpcClient, cmd interface{}) (interface{}, error) {
+	c2 := cmd.(*btcjson.GetRawMempoolCmd)
+	verbose := c2.Verbose != nil && *c2.Verbose
+	resp, err := c.GetMempool(ctx, &pb.GetMempoolRequest{
+		FullTransactions: verbose,
+	})
+	if err != nil || verbose {
+		return resp, err
+	}
+
+	hashes := make([]string, 0, len(resp.TransactionData))
+	for _, data := range resp.TransactionData {
+		hash, err := grpcHashString(data.GetTransactionHash())
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// grpcGetRawTransaction implements the getrawtransaction command.
+func grpcGetRawTransaction(ctx context.Context, c pb.BchrpcClient, cmd interface{}) (interface{}, error) {
+	c2 := cmd.(*btcjson.GetRawTransactionCmd)
+	hash, err := grpcHash(c2.Txid)
+	if err != nil {
+		return nil, err
+	}
+
+	// The decoded transaction is returned when verbose like the JSON-RPC
+	// server does.
+	if c2.Verbose != nil && *c2.Verbose != 0 {
+		return c.GetTransaction(ctx, &pb.GetTransactionRequest{
+			Hash: hash,
+		})
+	}
+	resp, err := c.GetRawTransaction(ctx, &pb.GetRawTransactionRequest{
+		Hash: hash,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hex.EncodeToString(resp.Transaction), nil
+}
+
+// grpcSendRawTransaction implements the sendrawtransaction command.
+func grpcSendRawTransaction(ctx context.Context, c pb.BchrpcClient, cmd interface{}) (interface{}, error) {
+	c2 := cmd.(*btcjson.SendRawTransactionCmd)
+	tx, err := hex.DecodeString(c2.HexTx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.SubmitTransaction(ctx, &pb.SubmitTransactionRequest{
+		Transaction: tx,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return grpcHashString(resp.Hash)
+}
+
+// newGRPCClient returns a new client of the gRPC API which is configured
+// according to the proxy and TLS settings in the associated connection
+// configuration.  The gRPC API always uses TLS.
+func newGRPCClient(cfg *config) (*grpc.ClientConn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.TLSSkipVerify}
+	if cfg.RPCCert != "" {
+		pem, err := ioutil.ReadFile(cfg.RPCCert)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(pem)
+		tlsConfig.RootCAs = pool
+	}
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)),
+	}
+
+	// Configure proxy if needed.
+	if cfg.Proxy != "" {
+		proxy := &socks.Proxy{
+			Addr:     cfg.Proxy,
+			Username: cfg.ProxyUser,
+			Password: cfg.ProxyPass,
+		}
+		opts = append(opts, grpc.WithContextDialer(
+			func(ctx context.Context, addr string) (net.Conn, error) {
+				return proxy.Dial("tcp", addr)
+			}))
+	}
+
+	return grpc.NewClient(cfg.GRPCServer, opts...)
+}
+
+// sendGRPCRequest sends the passed command to the gRPC API of the server
+// described in the passed config struct and returns the JSON encoding of the
+// result.
+func sendGRPCRequest(cmd interface{}, cfg *config) ([]byte, error) {
+	method, err := btcjson.CmdMethod(cmd)
+	if err != nil {
+		return nil, err
+	}
+	handler, ok := grpcHandlers[method]
+	if !ok {
+		return nil, fmt.Errorf("the '%s' command is not supported by "+
+			"the gRPC API", method)
+	}
+
+	conn, err := newGRPCClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), grpcTimeout)
+	defer cancel()
+	if cfg.GRPCAuthToken != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, grpcAuthTokenKey,
+			cfg.GRPCAuthToken)
+	}
+	result, err := handler(ctx, pb.NewBchrpcClient(conn), cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg, ok := result.(proto.Message); ok {
+		return protojson.Marshal(msg)
+	}
+	return json.Marshal(result)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gcash/bchd/btcjson"
+	"golang.org/x/term"
+)
+
+// interactivePrompt is the prompt displayed in interactive mode when standard
+// input is a terminal.
+const interactivePrompt = "bchctl> "
+
+// completionMethods returns the sorted methods commands may be completed to.
+// The methods are taken from the help of the server so only the commands it
+// supports are completed, and from the registered commands which are usable by
+// this utility when the help isn't available.
+func completionMethods(cfg *config) []string {
+	var methods []string
+	if cfg.GRPC {
+		for method := range grpcHandlers {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		return methods
+	}
+
+	// The help of the server lists the one-line usage of each command it
+	// supports, which starts with its method.
+	result, err := sendCommand(btcjson.NewHelpCmd(nil), cfg)
+	if err == nil {
+		var help string
+		if err := json.Unmarshal(result, &help); err == nil {
+			for _, line := range strings.Split(help, "\n") {
+				fields := strings.Fields(line)
+				if len(fields) == 0 {
+					continue
+				}
+				methods = append(methods, fields[0])
+			}
+		}
+	}
+	if len(methods) == 0 {
+		for _, method := range btcjson.RegisteredCmdMethods() {
+			usageFlags, err := btcjson.MethodUsageFlags(method)
+			if err != nil || usageFlags&unusableFlags != 0 {
+				continue
+			}
+			methods = append(methods, method)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// splitArgs splits the passed line into the command and its arguments.
+// Arguments are separated by whitespace unless it is quoted with single or
+// double quotes or escaped with a backslash, so JSON arguments may be entered
+// as they would be in a shell.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	var quote rune
+	var inArg, escaped bool
+	for _, r := range line {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+
+		case quote != 0:
+			if r == quote {
+				quote = 0
+				continue
+			}
+			arg.WriteRune(r)
+
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if escaped {
+		return nil, errors.New("unterminated escape")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// completer completes the methods of commands entered in interactive mode.
+type completer struct {
+	methods []string
+	out     io.Writer
+}
+
+// complete implements the AutoCompleteCallback of a terminal.  The method being
+// entered as the command, or as the argument of the help command, is completed
+// when tab is pressed.  The candidates are listed when the method can't be
+// completed any further.
+func (c *completer) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+
+	// Find the word being completed, which must either be the command or
+	// the argument of the help command.
+	head := line[:pos]
+	start := strings.LastIndexAny(head, " \t") + 1
+	before := strings.Fields(head[:start])
+	if len(before) > 1 || len(before) == 1 && before[0] != "help" {
+		return "", 0, false
+	}
+	prefix := head[start:]
+
+	var candidates []string
+	for _, method := range c.methods {
+		if strings.HasPrefix(method, prefix) {
+			candidates = append(candidates, method)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", 0, false
+	}
+
+	// Complete a single candidate along with the following space and
+	// otherwise the prefix shared by all of the candidates.
+	completion := candidates[0]
+	if len(candidates) == 1 {
+		completion += " "
+	} else {
+		for _, candidate := range candidates[1:] {
+			for !strings.HasPrefix(candidate, completion) {
+				completion = completion[:len(completion)-1]
+			}
+		}
+		if completion == prefix {
+			fmt.Fprintln(c.out, strings.Join(candidates, "  "))
+			return "", 0, false
+		}
+	}
+	newLine := head[:start] + completion + line[pos:]
+	return newLine, start + len(completion), true
+}
+
+// runLine runs the command entered on the passed line and returns whether or
+// not the interactive session should end.
+func runLine(cfg *config, line string, out, errOut io.Writer) bool {
+	args, err := splitArgs(line)
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		return false
+	}
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "exit", "quit":
+		return true
+	}
+
+	runCommand(cfg, args, nil, out, errOut)
+	return false
+}
+
+// runInteractive reads commands from standard input and runs them until the
+// input ends or the session is ended with the exit or quit command.  A prompt,
+// line editing, history and completion are provided when standard input is a
+// terminal.
+func runInteractive(cfg *config) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		scanner := bufio.NewScanner(os.Stdin)
+		scanner.Buffer(nil, 1<<24)
+		for scanner.Scan() {
+			if runLine(cfg, scanner.Text(), os.Stdout, os.Stderr) {
+				return nil
+			}
+		}
+		return scanner.Err()
+	}
+
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, oldState)
+
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, interactivePrompt)
+	if width, height, err := term.GetSize(fd); err == nil && width > 0 {
+		t.SetSize(width, height)
+	}
+	c := &completer{methods: completionMethods(cfg), out: t}
+	t.AutoCompleteCallback = c.complete
+
+	for {
+		line, err := t.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil && err != term.ErrPasteIndicator {
+			return err
+		}
+		if runLine(cfg, line, t, t) {
+			return nil
+		}
+	}
+}
@@ -9,10 +9,13 @@ import (
 	"bytes"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -24,14 +27,20 @@ import (
 	"github.com/gcash/bchd/mining"
 
 	"github.com/btcsuite/go-socks/socks"
+	"github.com/gcash/bchd/bchec"
+	"github.com/gcash/bchd/bchrpc"
+	"github.com/gcash/bchd/blockchain"
 	"github.com/gcash/bchd/chaincfg"
 	"github.com/gcash/bchd/chaincfg/chainhash"
 	"github.com/gcash/bchd/connmgr"
 	"github.com/gcash/bchd/database"
 	_ "github.com/gcash/bchd/database/ffldb"
 	"github.com/gcash/bchd/mempool"
+	"github.com/gcash/bchd/mining/stratum"
 	"github.com/gcash/bchd/peer"
+	"github.com/gcash/bchd/structlog"
 	"github.com/gcash/bchd/version"
+	"github.com/gcash/bchd/wire"
 	"github.com/gcash/bchutil"
 
 	flags "github.com/jessevdk/go-flags"
@@ -41,6 +50,7 @@ const (
 	defaultConfigFilename          = "bchd.conf"
 	defaultDataDirname             = "data"
 	defaultLogLevel                = "info"
+	defaultLogFormat               = "text"
 	defaultLogDirname              = "logs"
 	defaultLogFilename             = "bchd.log"
 	defaultMaxPeers                = 125
@@ -51,30 +61,44 @@ const (
 	defaultMaxRPCClients           = 10
 	defaultMaxRPCWebsockets        = 25
 	defaultMaxRPCConcurrentReqs    = 20
+	defaultRPCRateBurst            = 20
 	defaultDbType                  = "ffldb"
 	defaultFreeTxRelayLimit        = 0
 	defaultTrickleInterval         = peer.DefaultTrickleInterval
 	defaultExcessiveBlockSize      = 32000000
+	scaleNetExcessiveBlockSize     = 256000000
 	defaultBlockMinSize            = 0
 	defaultBlockMaxSize            = 31999000
 	blockMaxSizeMin                = 1000
 	defaultGenerate                = false
 	defaultMaxOrphanTransactions   = 100
 	defaultMaxOrphanTxSize         = 100000
+	defaultMaxMempool              = 300
 	defaultSigCacheMaxSize         = 100000
+	defaultScriptCacheMaxSize      = 100000
 	defaultTxIndex                 = false
 	defaultAddrIndex               = false
 	defaultSlpIndex                = false
 	defaultSlpCacheMaxSize         = 100000
 	defaultSlpGraphSearch          = false
-	defaultUtxoCacheMaxSizeMiB     = 450
+	defaultTokenIndex              = false
+	defaultStatsIndex              = false
+	defaultCoinAgeIndex            = false
+	defaultWatchSetIndex           = false
+	defaultUtxoCacheMaxMB          = 450
 	defaultMinSyncPeerNetworkSpeed = 51200
 	defaultPruneDepth              = 4320
 	defaultTargetOutboundPeers     = uint32(8)
+	defaultBlockRelayOnlyPeers     = uint32(2)
+	defaultMaxBloomFilterMem       = 4096
+	defaultMaxBloomFilterAdds      = 1000
 	minPruneDepth                  = 288
 	defaultDBCacheSize             = 500
 	defaultDBFlushSecs             = 1800
 	defaultRPCAuthTimeout          = 10
+	defaultStratumPort             = "3333"
+	defaultSeederPort              = "53"
+	defaultTorControl              = "127.0.0.1:9051"
 )
 
 var (
@@ -106,27 +130,46 @@ type config struct {
 	MaxPeers                int           `long:"maxpeers" description:"Max number of inbound and outbound peers"`
 	MaxPeersPerIP           int           `long:"maxpeersperip" description:"Max number of inbound and outbound peers per IP"`
 	MinSyncPeerNetworkSpeed uint64        `long:"minsyncpeernetworkspeed" description:"Disconnect sync peers slower than this threshold in bytes/sec"`
+	MaxUploadTarget         uint64        `long:"maxuploadtarget" description:"Try to keep the upload traffic under the target in MiB per 24h.  Historical blocks are no longer served to peers which are not whitelisted once the target is close to being reached. 0 disables the target"`
+	MaxUploadRate           uint64        `long:"maxuploadrate" description:"Max upload rate to all peers in KiB/s, 0 disables the limit"`
+	MaxDownloadRate         uint64        `long:"maxdownloadrate" description:"Max download rate from all peers in KiB/s, 0 disables the limit"`
+	MaxPeerUploadRate       uint64        `long:"maxpeeruploadrate" description:"Max upload rate to a single peer in KiB/s, 0 disables the limit"`
+	MaxPeerDownloadRate     uint64        `long:"maxpeerdownloadrate" description:"Max download rate from a single peer in KiB/s, 0 disables the limit"`
 	DisableBanning          bool          `long:"nobanning" description:"Disable banning of misbehaving peers"`
 	BanDuration             time.Duration `long:"banduration" description:"How long to ban misbehaving peers.  Valid time units are {s, m, h}.  Minimum 1 second"`
 	BanThreshold            uint32        `long:"banthreshold" description:"Maximum allowed ban score before disconnecting and banning misbehaving peers."`
 	Whitelists              []string      `long:"whitelist" description:"Add an IP network or IP that will not be banned. (eg. 192.168.1.0/24 or ::1)"`
+	ASMap                   string        `long:"asmap" description:"Path to an asmap file in the Bitcoin Core format which is used to spread outbound peers over autonomous systems instead of network prefixes"`
 	AgentBlacklist          []string      `long:"agentblacklist" description:"A comma separated list of user-agent substrings which will cause bchd to reject any peers whose user-agent contains any of the blacklisted substrings."`
 	AgentWhitelist          []string      `long:"agentwhitelist" description:"A comma separated list of user-agent substrings which will cause bchd to require all peers' user-agents to contain one of the whitelisted substrings. The blacklist is applied before the whitelist, and an empty whitelist will allow all agents that do not fail the blacklist."`
 	RPCUser                 string        `short:"u" long:"rpcuser" description:"Username for RPC connections"`
 	RPCPass                 string        `short:"P" long:"rpcpass" default-mask:"-" description:"Password for RPC connections"`
 	RPCLimitUser            string        `long:"rpclimituser" description:"Username for limited RPC connections"`
 	RPCLimitPass            string        `long:"rpclimitpass" default-mask:"-" description:"Password for limited RPC connections"`
+	RPCAuth                 []string      `long:"rpcauth" description:"Add a user for RPC connections in the form <user>:<salt>$<hash>[:<role>], where hash is the hex encoded HMAC-SHA256 of the password keyed by the salt and role is admin (default), wallet or readonly"`
+	RPCWhitelists           []string      `long:"rpcwhitelist" description:"Restrict an RPC user to the listed methods in the form <user>:<method>,<method>,... -- Users listed more than once may only call the methods in all of the lists"`
+	RPCCookieFile           string        `long:"rpccookiefile" description:"File to write the credential for cookie authentication to when no rpcuser/rpcpass is specified (default: .cookie in the data directory)"`
+	NoRPCCookie             bool          `long:"norpccookie" description:"Disable cookie authentication for the RPC server"`
 	RPCListeners            []string      `long:"rpclisten" description:"Add an interface/port to listen for RPC connections (default port: 8334, testnet: 18334)"`
 	RPCCert                 string        `long:"rpccert" description:"File containing the certificate file"`
 	RPCKey                  string        `long:"rpckey" description:"File containing the certificate key"`
 	RPCMaxClients           int           `long:"rpcmaxclients" description:"Max number of RPC clients for standard connections"`
 	RPCMaxWebsockets        int           `long:"rpcmaxwebsockets" description:"Max number of RPC websocket connections"`
 	RPCMaxConcurrentReqs    int           `long:"rpcmaxconcurrentreqs" description:"Max number of concurrent RPC requests that may be processed concurrently"`
+	RPCRateLimit            float64       `long:"rpcratelimit" description:"Max number of RPC requests per second from a single client address -- 0 disables the limit"`
+	RPCUserRateLimit        float64       `long:"rpcuserratelimit" description:"Max number of RPC requests per second of a single RPC user across all of its addresses -- 0 disables the limit"`
+	RPCRateBurst            int           `long:"rpcrateburst" description:"Number of RPC requests allowed at once above the RPC rate limits"`
+	RPCMaxClientReqs        int           `long:"rpcmaxclientreqs" description:"Max number of RPC requests of a single client address or RPC user that may be processed concurrently -- 0 disables the limit"`
+	RPCResponseBudget       uint64        `long:"rpcresponsebudget" description:"Max size in KiB of the RPC responses to a single client address or RPC user per minute, after which its requests are rejected until the budget is replenished -- 0 disables the limit"`
 	RPCQuirks               bool          `long:"rpcquirks" description:"Mirror some JSON-RPC quirks of Bitcoin Core -- NOTE: Discouraged unless interoperability issues need to be worked around"`
+	RPCRest                 bool          `long:"rest" description:"Accept public REST requests on the RPC listeners without authentication"`
 	RPCAuthTimeout          uint          `long:"rpcauthtimeout" description:"The number of seconds a connection to the RPC server is allowed to stay open without authenticating. To disable the timeout use 0."`
-	DisableRPC              bool          `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass or rpclimituser/rpclimitpass is specified"`
+	DisableRPC              bool          `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no RPC credentials are specified and cookie authentication is disabled"`
 	DisableTLS              bool          `long:"notls" description:"Disable TLS for the RPC server -- NOTE: This is only allowed if the RPC server is bound to localhost"`
 	DisableDNSSeed          bool          `long:"nodnsseed" description:"Disable DNS seeding for peers"`
+	SeederHost              string        `long:"seederhost" description:"Run a DNS seeder which crawls the network and answers DNS queries for this host name with the addresses of good nodes"`
+	SeederNameserver        string        `long:"seedernameserver" description:"Host name of the name server returned for NS queries to the DNS seeder"`
+	SeederListeners         []string      `long:"seederlisten" description:"Add an interface/port for the DNS seeder to answer UDP queries on (default port: 53)"`
 	ExternalIPs             []string      `long:"externalip" description:"Add an ip to the list of local addresses we claim to listen on to peers"`
 	Proxy                   string        `long:"proxy" description:"Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
 	ProxyUser               string        `long:"proxyuser" description:"Username for proxy server"`
@@ -135,40 +178,81 @@ type config struct {
 	OnionProxyUser          string        `long:"onionuser" description:"Username for onion proxy server"`
 	OnionProxyPass          string        `long:"onionpass" default-mask:"-" description:"Password for onion proxy server"`
 	NoOnion                 bool          `long:"noonion" description:"Disable connecting to tor hidden services"`
-	TorIsolation            bool          `long:"torisolation" description:"Enable Tor stream isolation by randomizing user credentials for each connection."`
+	TorIsolation            bool          `long:"torisolation" description:"Enable Tor stream isolation by using distinct proxy credentials for each peer."`
+	ListenOnion             bool          `long:"listenonion" description:"Automatically create a Tor v3 onion service for inbound connections using the Tor control port"`
+	TorControl              string        `long:"torcontrol" description:"Tor control port used to create the onion service"`
+	TorPassword             string        `long:"torpassword" default-mask:"-" description:"Password for the Tor control port, if it requires password authentication"`
 	TestNet3                bool          `long:"testnet" description:"Use the test network"`
 	TestNet4                bool          `long:"testnet4" description:"Use the test 4 network"`
 	ChipNet                 bool          `long:"chipnet" description:"Use the chip network"`
+	ScaleNet                bool          `long:"scalenet" description:"Use the scaling test network"`
+	SigNet                  bool          `long:"signet" description:"Use the signed test network"`
+	SignetChallenge         string        `long:"signetchallenge" description:"Hex encoded challenge script block signatures must satisfy on the signed test network, which selects a custom signed test network instead of the default one"`
+	CustomNet               string        `long:"customnet" description:"Use a custom network with the parameters loaded from the given JSON file"`
 	RegressionTest          bool          `long:"regtest" description:"Use the regression test network"`
 	RegressionTestAnyHost   bool          `long:"regtestanyhost" description:"In regression test mode, allow connections from any host, not just localhost"`
 	RegressionTestNoReset   bool          `long:"regtestnoreset" description:"In regression test mode, don't reset the network db on node restart"`
 	SimNet                  bool          `long:"simnet" description:"Use the simulation test network"`
+	UpgradeActivations      []string      `long:"upgradeactivation" description:"Override the activation of a network upgrade on regtest or simnet.  Format: '<upgrade>:<activation>' where activation is the height of the last block before the upgrade, or the median time past for time activated upgrades"`
 	AddCheckpoints          []string      `long:"addcheckpoint" description:"Add a custom checkpoint.  Format: '<height>:<hash>'"`
 	DisableCheckpoints      bool          `long:"nocheckpoints" description:"Disable built-in checkpoints.  Don't do this unless you know what you're doing."`
+	MinimumChainWork        string        `long:"minimumchainwork" description:"Hex encoded minimum cumulative work of the main chain, which overrides the one of the network.  The node is not considered synced before the main chain has this much work and rejects headers forking it from blocks with less work afterwards"`
+	AssumeValid             string        `long:"assumevalid" description:"Hash of a block which is assumed to be valid, which overrides the one of the network.  The scripts of the block and its ancestors are not validated while all other rules are still enforced.  Use 0 to validate all scripts"`
 	DbType                  string        `long:"dbtype" description:"Database backend to use for the Block Chain"`
+	DbAutoCompact           bool          `long:"dbautocompact" description:"Compact the database in the background while the node is idle after a large number of keys was deleted, such as after a deep reorg or dropping an index"`
 	Profile                 string        `long:"profile" description:"Enable HTTP profiling on given port -- NOTE port must be between 1024 and 65536"`
 	CPUProfile              string        `long:"cpuprofile" description:"Write CPU profile to the specified file"`
+	TraceFile               string        `long:"tracefile" description:"Write OpenTelemetry spans of the block validation pipeline to the specified file"`
 	DebugLevel              string        `short:"d" long:"debuglevel" description:"Logging level for all subsystems {trace, debug, info, warn, error, critical} -- You may also specify <subsystem>=<level>,<subsystem2>=<level>,... to set the log level for individual subsystems -- Use show to list available subsystems"`
-	Upnp                    bool          `long:"upnp" description:"Use UPnP to map our listening port outside of NAT"`
+	LogFormat               string        `long:"logformat" description:"Format of the log output {text, json} -- json writes each message as a JSON record with the time, level, subsystem and message"`
+	LogSampling             uint32        `long:"logsampling" description:"Log at most <n> trace, debug and info messages with the same format per subsystem and second -- 0 to disable"`
+	Upnp                    bool          `long:"upnp" description:"Use PCP, NAT-PMP or UPnP to map our listening port outside of NAT and open an IPv6 pinhole with PCP"`
 	ExcessiveBlockSize      uint32        `long:"excessiveblocksize" description:"The maximum size block (in bytes) this node will accept. Cannot be less than 32000000."`
 	MinRelayTxFee           float64       `long:"minrelaytxfee" description:"The minimum transaction fee in BCH/kB to be considered a non-zero fee."`
+	DustRelayFee            float64       `long:"dustrelayfee" description:"Do not accept transactions with outputs whose value is less than three times the fee in BCH/kB of spending them -- 0 to disable"`
+	MaxTxSigChecks          int           `long:"maxtxsigchecks" description:"Do not accept transactions whose scripts perform more than <n> signature checks -- 0 to disable"`
 	FreeTxRelayLimit        float64       `long:"limitfreerelay" description:"Limit relay of transactions with no transaction fee to the given amount in thousands of bytes per minute"`
 	NoRelayPriority         bool          `long:"norelaypriority" description:"Do not require free or low-fee transactions to have high priority for relaying"`
 	TrickleInterval         time.Duration `long:"trickleinterval" description:"Minimum time between attempts to send new inventory to a connected peer"`
+	TxReconciliation        bool          `long:"txreconciliation" description:"Relay transactions to peers which support it by periodic set reconciliation instead of announcing each of them, which saves bandwidth at the cost of slower propagation"`
 	MaxOrphanTxs            int           `long:"maxorphantx" description:"Max number of orphan transactions to keep in memory"`
+	MaxMempool              int           `long:"maxmempool" description:"Keep the transaction memory pool below <n> megabytes by evicting the transactions with the lowest fee rates -- 0 to disable"`
+	MempoolExpiry           time.Duration `long:"mempoolexpiry" description:"Remove transactions from the memory pool which were not confirmed within the given duration -- 0 to disable"`
+	MempoolReplacement      bool          `long:"mempoolreplacement" description:"Accept transactions which replace a memory pool transaction spending the same outputs by paying a higher fee"`
+	LimitAncestorCount      int           `long:"limitancestorcount" description:"Do not accept transactions with more than <n> unconfirmed ancestors, including the transaction itself -- 0 to disable"`
+	LimitAncestorSize       int           `long:"limitancestorsize" description:"Do not accept transactions whose unconfirmed ancestors, including the transaction itself, exceed <n> kilobytes -- 0 to disable"`
+	LimitDescendantCount    int           `long:"limitdescendantcount" description:"Do not accept transactions if any unconfirmed ancestor would have more than <n> descendants, including itself -- 0 to disable"`
+	LimitDescendantSize     int           `long:"limitdescendantsize" description:"Do not accept transactions if the descendants of any unconfirmed ancestor, including itself, would exceed <n> kilobytes -- 0 to disable"`
+	DataCarrierSize         int           `long:"datacarriersize" description:"Do not accept transactions whose null data (OP_RETURN) output scripts exceed <n> bytes in total"`
+	MaxDataCarriers         int           `long:"maxdatacarriers" description:"Do not accept transactions with more than <n> null data (OP_RETURN) outputs -- 0 to disable"`
+	DataCarrierProtocols    []string      `long:"datacarrierprotocol" description:"Only accept null data (OP_RETURN) outputs carrying data of the protocol identified by the given hex-encoded prefix of the first data push, such as 534c5000 for SLP -- May be specified multiple times"`
 	Generate                bool          `long:"generate" description:"Generate (mine) bitcoins using the CPU"`
 	MiningAddrs             []string      `long:"miningaddr" description:"Add the specified payment address to the list of addresses to use for generated blocks -- At least one address is required if the generate option is set"`
 	BlockMinSize            uint32        `long:"blockminsize" description:"Minimum block size in bytes to be used when creating a block"`
 	BlockMaxSize            uint32        `long:"blockmaxsize" description:"Maximum block size in bytes to be used when creating a block"`
 	BlockPrioritySize       uint32        `long:"blockprioritysize" description:"Size in bytes for high-priority/low-fee transactions when creating a block"`
+	StratumListeners        []string      `long:"stratumlisten" description:"Add an interface/port to listen for stratum mining connections (default port: 3333) -- NOTE: At least one mining address is required"`
+	StratumPass             string        `long:"stratumpass" default-mask:"-" description:"Password stratum workers must authorize with, any password is accepted if not set"`
+	StratumDifficulty       float64       `long:"stratumdifficulty" description:"Initial and minimum share difficulty assigned to stratum workers"`
 	CoinbaseFlags           string        `long:"cbflags" description:"Comment to append to the coinbase input when generating a block template." default:"/bchd/"`
+	CoinbaseData            string        `long:"cbdata" description:"Hex encoded data to push onto the coinbase input after the coinbase flags when generating a block template, such as a merged mining tag"`
+	SignetKeys              []string      `long:"signetkey" default-mask:"-" description:"Add the WIF encoded private key to the keys generated blocks are signed with on the signed test network -- At least one key is required if the generate option is set"`
+	CoinbaseOutputs         []string      `long:"cboutput" description:"Add an output to the coinbase when generating a block template as <hex script>[:<amount in satoshis>] -- The amount is deducted from the block reward"`
+	SaveRejectedBlocks      bool          `long:"saverejectedblocks" description:"Save blocks rejected by submitblock to the rejectedblocks directory in the data directory for inspection"`
 	UserAgentComments       []string      `long:"uacomment" description:"Comment to add to the user agent -- See BIP 14 for more information."`
 	NoPeerBloomFilters      bool          `long:"nopeerbloomfilters" description:"Disable bloom filtering support"`
+	MaxBloomFilterSize      uint32        `long:"maxbloomfiltersize" description:"Max size in bytes of the bloom filter a peer may load (max: 36000)"`
+	MaxBloomFilterMem       uint64        `long:"maxbloomfiltermem" description:"Max memory in KiB used by the bloom filters of all peers.  Peers loading a filter once it is reached are disconnected. 0 disables the limit"`
+	MaxBloomFilterAdds      uint32        `long:"maxbloomfilteradds" description:"Max number of filteradd messages a peer may send for a single loaded bloom filter, 0 disables the limit"`
+	NoMerkleBlockNets       []string      `long:"nomerkleblocknet" description:"Add an IP network or IP whose peers may not load bloom filters and are not served merkle blocks. (eg. 192.168.1.0/24 or ::1)"`
 	NoCFilters              bool          `long:"nocfilters" description:"Disable committed filtering (CF) support"`
 	DropCfIndex             bool          `long:"dropcfindex" description:"Deletes the index used for committed filtering (CF) support from the database on start up and then exits."`
 	SigCacheMaxSize         uint          `long:"sigcachemaxsize" description:"The maximum number of entries in the signature verification cache"`
-	UtxoCacheMaxSizeMiB     uint          `long:"utxocachemaxsize" description:"The maximum size in MiB of the UTXO cache"`
-	BlocksOnly              bool          `long:"blocksonly" description:"Do not accept transactions from remote peers."`
+	ScriptCacheMaxSize      uint          `long:"scriptcachemaxsize" description:"The maximum number of entries in the script verification cache"`
+	UtxoCacheMaxMB          uint          `long:"utxocachemaxmb" description:"The maximum size in MiB of the UTXO cache"`
+	UtxoCacheMaxSizeMiB     uint          `long:"utxocachemaxsize" description:"Deprecated: use --utxocachemaxmb"`
+	WeakBlocks              bool          `long:"weakblocks" description:"Relay weak blocks, which are blocks meeting a fraction of the proof of work target found by miners, with peers which support them and stage their transactions for the reconstruction of compact blocks"`
+	BlocksOnly              bool          `long:"blocksonly" description:"Do not accept transactions from remote peers and only download full blocks. Peers are asked not to relay transactions and are disconnected when they announce them anyway."`
 	TxIndex                 bool          `long:"txindex" description:"Maintain a full hash-based transaction index which makes all transactions available via the getrawtransaction RPC"`
 	DropTxIndex             bool          `long:"droptxindex" description:"Deletes the hash-based transaction index from the database on start up and then exits."`
 	AddrIndex               bool          `long:"addrindex" description:"Maintain a full address-based transaction index which makes the searchrawtransactions RPC available"`
@@ -177,15 +261,44 @@ type config struct {
 	SlpCacheMaxSize         uint          `long:"slpcachemaxsize" description:"The maximum number of entries in the slp indexer cache"`
 	DropSlpIndex            bool          `long:"dropslpindex" description:"Deletes the slp index from the database on start up and then exits."`
 	SlpGraphSearch          bool          `long:"slpgraphsearch" description:"Enables gRPC calls related to slp graph search."`
+	TokenIndex              bool          `long:"tokenindex" description:"Maintain an index of CashToken categories which makes the genesis and circulating supply of each category available via the GetTokenStats gRPC method"`
+	DropTokenIndex          bool          `long:"droptokenindex" description:"Deletes the token category index from the database on start up and then exits."`
+	StatsIndex              bool          `long:"statsindex" description:"Maintain an index of per-block statistics which makes the fees, transaction count, size and output volume of ranges of blocks available via the GetChainStats gRPC method"`
+	DropStatsIndex          bool          `long:"dropstatsindex" description:"Deletes the chain statistics index from the database on start up and then exits."`
+	CoinAgeIndex            bool          `long:"coinageindex" description:"Maintain an index of the coin days destroyed by each block and daily snapshots of the age distribution of the UTXO set, which are available via the GetCoinDaysDestroyed and GetUtxoAgeDistribution gRPC methods"`
+	DropCoinAgeIndex        bool          `long:"dropcoinageindex" description:"Deletes the coin age index from the database on start up and then exits."`
+	WatchSetIndex           bool          `long:"watchsetindex" description:"Maintain persistent watch sets of output scripts registered over gRPC and index the transactions paying to or spending from them, which are available via the watch set gRPC methods"`
+	DropWatchSetIndex       bool          `long:"dropwatchsetindex" description:"Deletes the watch set index, including the watch sets, from the database on start up and then exits."`
+	Bcmr                    bool          `long:"bcmr" description:"Resolve the metadata of CashToken categories from the Bitcoin Cash Metadata Registries published by their authchains and make the GetBcmrTokenMetadata gRPC method available -- Requires --addrindex"`
+	BcmrIPFSGateway         string        `long:"bcmripfsgateway" description:"The URL prefix IPFS content identifiers of metadata registries are fetched through"`
+	WatchOnly               bool          `long:"watchonly" description:"Track watch-only accounts derived from extended public keys and make the watch-only gRPC methods available -- Requires --addrindex"`
+	HotWallet               bool          `long:"hotwallet" description:"Hold the keys of BIP0044 wallets created from BIP0039 mnemonics and make the gRPC methods which send from them available -- Requires --watchonly and --hotwalletauthtoken"`
+	HotWalletAuthToken      string        `long:"hotwalletauthtoken" default-mask:"-" description:"The token which authenticates the callers of the hot wallet gRPC methods -- The seeds are encrypted with the passphrases of the wallets instead"`
+	Webhooks                []string      `long:"webhook" description:"Add a URL to post JSON notifications of connected and disconnected blocks, confirmed transactions of the webhook addresses, mempool double spends and expired mempool transactions to"`
+	WebhookAddrs            []string      `long:"webhookaddr" description:"Add an address whose confirmed transactions are posted to the webhooks"`
+	FirstSeen               bool          `long:"firstseen" description:"Record the time and announcing peer of the first sighting of each block and transaction and make them available via the getfirstseen RPC"`
+	FirstSeenExport         string        `long:"firstseenexport" description:"Append each first sighting of a block or transaction to the specified file as a JSON line -- Requires --firstseen"`
 	RelayNonStd             bool          `long:"relaynonstd" description:"Relay non-standard transactions regardless of the default settings for the active network."`
 	RejectNonStd            bool          `long:"rejectnonstd" description:"Reject non-standard transactions regardless of the default settings for the active network."`
 	Prune                   bool          `long:"prune" description:"Delete historical blocks from the chain. A buffer of blocks will be retained in case of a reorg."`
 	PruneDepth              uint32        `long:"prunedepth" description:"The number of blocks to retain when running in pruned mode. Cannot be less than 288."`
+	UndoWindow              int32         `long:"undowindow" description:"The number of blocks at the end of the chain which can be undone by invalidateblock or to reconstruct historical UTXO sets. Limited to the prune depth in pruned mode."`
 	TargetOutboundPeers     uint32        `long:"targetoutboundpeers" description:"Number of outbound connections to maintain"`
+	BlockRelayOnlyPeers     uint32        `long:"blockrelayonlypeers" description:"Number of additional outbound connections to maintain which only relay blocks and neither relay transactions nor addresses"`
+	Reindex                 bool          `long:"reindex" description:"Rebuild the block index, the UTXO database and all optional indexes from the blocks stored on disk rather than downloading them again."`
 	ReIndexChainState       bool          `long:"reindexchainstate" description:"Rebuild the UTXO database from currently indexed blocks on disk."`
+	UtxoStats               bool          `long:"utxostats" description:"Maintain statistics about the UTXO set incrementally so the gettxoutsetinfo RPC returns without scanning the entire set"`
 	FastSync                bool          `long:"fastsync" description:"Sync full blocks from the last checkpoint to the tip rather than from genesis."`
+	HeadersOnly             bool          `long:"headersonly" description:"Sync and serve only the block headers and compact filters. Blocks are downloaded to build the filters, but they are not stored and there is no UTXO set. Implies --blocksonly and requires a new data directory."`
 	GrpcListeners           []string      `long:"grpclisten" description:"Add an interface/port to listen for experimental gRPC connections (default port: 8335, testnet: 18335)"`
 	GrpcAuthToken           string        `long:"grpcauthtoken" description:"An authentication token for the gRPC API to authenticate clients"`
+	GrpcClientCA            string        `long:"grpcclientca" description:"File containing the certificate authorities gRPC client certificates are verified against -- When set, clients must authenticate with a certificate signed by one of them"`
+	GrpcClientScopes        []string      `long:"grpcclientscope" description:"Set the scope of the gRPC client certificates with the specified common name in the form <common name>=<scope>, where the scope is readonly, submit or admin -- Certificates without a scope are read-only"`
+	GrpcRateLimit           float64       `long:"grpcratelimit" description:"Max number of gRPC requests per second from a single client address -- 0 disables the limit"`
+	GrpcUserRateLimit       float64       `long:"grpcuserratelimit" description:"Max number of gRPC requests per second of a single client certificate across all of its addresses -- 0 disables the limit"`
+	GrpcRateBurst           int           `long:"grpcrateburst" description:"Number of gRPC requests allowed at once above the gRPC rate limits"`
+	GrpcMaxClientReqs       int           `long:"grpcmaxclientreqs" description:"Max number of gRPC requests, including open streams, of a single client address or client certificate that may be processed concurrently -- 0 disables the limit"`
+	GrpcResponseBudget      uint64        `long:"grpcresponsebudget" description:"Max size in KiB of the gRPC responses to a single client address or client certificate per minute, after which its requests are rejected until the budget is replenished -- 0 disables the limit"`
 	DBCacheSize             uint64        `long:"dbcachesize" description:"The maximum size in MiB of the database cache"`
 	DBFlushInterval         uint32        `long:"dbflushinterval" description:"The number of seconds between database flushes"`
 	PrometheusListen        string        `long:"prometheus" description:"Specify an (addr):port to serve prometheus metrics (for example :9000 or my-interface:9000, default disabled)"`
@@ -193,9 +306,21 @@ type config struct {
 	oniondial               func(string, string, time.Duration) (net.Conn, error)
 	dial                    func(string, string, time.Duration) (net.Conn, error)
 	addCheckpoints          []chaincfg.Checkpoint
+	minimumChainWork        *big.Int
+	assumeValid             *chainhash.Hash
 	miningAddrs             []bchutil.Address
+	webhookAddrs            []bchutil.Address
+	grpcClientScopes        map[string]bchrpc.Scope
+	rpcCredentials          []*rpcCredential
+	rpcCookie               string
+	coinbaseData            []byte
+	coinbaseOutputs         []*wire.TxOut
+	signetKeys              []*bchec.PrivateKey
 	minRelayTxFee           bchutil.Amount
+	dustRelayFee            bchutil.Amount
+	dataCarrierProtocols    [][]byte
 	whitelists              []*net.IPNet
+	noMerkleBlockNets       []*net.IPNet
 }
 
 // serviceOptions defines the configuration options for the daemon as a service on
@@ -251,32 +376,34 @@ func supportedSubsystems() []string {
 	return subsystems
 }
 
-// parseAndSetDebugLevels attempts to parse the specified debug level and set
-// the levels accordingly.  An appropriate error is returned if anything is
-// invalid.
-func parseAndSetDebugLevels(debugLevel string) error {
+// parseDebugLevels attempts to parse the specified debug level and returns the
+// log level of each subsystem it sets.  An appropriate error is returned if
+// anything is invalid.
+func parseDebugLevels(debugLevel string) (map[string]string, error) {
 	// When the specified string doesn't have any delimters, treat it as
 	// the log level for all subsystems.
 	if !strings.Contains(debugLevel, ",") && !strings.Contains(debugLevel, "=") {
 		// Validate debug log level.
 		if !validLogLevel(debugLevel) {
 			str := "The specified debug level [%v] is invalid"
-			return fmt.Errorf(str, debugLevel)
+			return nil, fmt.Errorf(str, debugLevel)
 		}
 
-		// Change the logging level for all subsystems.
-		setLogLevels(debugLevel)
-
-		return nil
+		levels := make(map[string]string, len(subsystemLoggers))
+		for subsysID := range subsystemLoggers {
+			levels[subsysID] = debugLevel
+		}
+		return levels, nil
 	}
 
 	// Split the specified string into subsystem/level pairs while detecting
-	// issues and update the log levels accordingly.
+	// issues.
+	levels := make(map[string]string)
 	for _, logLevelPair := range strings.Split(debugLevel, ",") {
 		if !strings.Contains(logLevelPair, "=") {
 			str := "The specified debug level contains an invalid " +
 				"subsystem/level pair [%v]"
-			return fmt.Errorf(str, logLevelPair)
+			return nil, fmt.Errorf(str, logLevelPair)
 		}
 
 		// Extract the specified subsystem and log level.
@@ -287,15 +414,30 @@ func parseAndSetDebugLevels(debugLevel string) error {
 		if _, exists := subsystemLoggers[subsysID]; !exists {
 			str := "The specified subsystem [%v] is invalid -- " +
 				"supported subsytems %v"
-			return fmt.Errorf(str, subsysID, supportedSubsystems())
+			return nil, fmt.Errorf(str, subsysID, supportedSubsystems())
 		}
 
 		// Validate log level.
 		if !validLogLevel(logLevel) {
 			str := "The specified debug level [%v] is invalid"
-			return fmt.Errorf(str, logLevel)
+			return nil, fmt.Errorf(str, logLevel)
 		}
 
+		levels[subsysID] = logLevel
+	}
+
+	return levels, nil
+}
+
+// parseAndSetDebugLevels attempts to parse the specified debug level and set
+// the levels accordingly.  An appropriate error is returned if anything is
+// invalid, in which case no level is changed.
+func parseAndSetDebugLevels(debugLevel string) error {
+	levels, err := parseDebugLevels(debugLevel)
+	if err != nil {
+		return err
+	}
+	for subsysID, logLevel := range levels {
 		setLogLevel(subsysID, logLevel)
 	}
 
@@ -347,6 +489,42 @@ func normalizeAddresses(addrs []string, defaultPort string) []string {
 	return removeDuplicateAddresses(addrs)
 }
 
+// parseIPNet parses an IP network in CIDR notation or a single IP address, in
+// which case the returned network only contains that address.  It returns nil
+// when the passed string is neither.
+func parseIPNet(addr string) *net.IPNet {
+	_, ipnet, err := net.ParseCIDR(addr)
+	if err == nil {
+		return ipnet
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil
+	}
+	var bits int
+	if ip.To4() == nil {
+		// IPv6
+		bits = 128
+	} else {
+		bits = 32
+	}
+	return &net.IPNet{
+		IP:   ip,
+		Mask: net.CIDRMask(bits, bits),
+	}
+}
+
+// isolatedProxyDial returns a dial function which connects through the passed
+// proxy using the credentials derived by the passed stream isolator for each
+// peer address.
+func isolatedProxyDial(proxy *socks.Proxy, isolator *connmgr.TorStreamIsolator) func(string, string, time.Duration) (net.Conn, error) {
+	return func(network, addr string, timeout time.Duration) (net.Conn, error) {
+		p := *proxy
+		p.Username, p.Password = isolator.Credentials(addr)
+		return p.DialTimeout(network, addr, timeout)
+	}
+}
+
 // newCheckpointFromStr parses checkpoints in the '<height>:<hash>' format.
 func newCheckpointFromStr(checkpoint string) (chaincfg.Checkpoint, error) {
 	parts := strings.Split(checkpoint, ":")
@@ -395,6 +573,89 @@ func parseCheckpoints(checkpointStrings []string) ([]chaincfg.Checkpoint, error)
 	return checkpoints, nil
 }
 
+// loadCustomNetParams reads the parameters of a custom network from the JSON
+// file at the passed path and registers the network with chaincfg so its
+// addresses and keys can be decoded.
+func loadCustomNetParams(path string) (*params, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read custom network file: %v", err)
+	}
+	netParams, err := chaincfg.NewCustomNetParams(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := chaincfg.Register(netParams); err != nil {
+		return nil, fmt.Errorf("unable to register custom network: %v", err)
+	}
+	return &params{
+		Params:   netParams,
+		rpcPort:  "18334",
+		gRRPPort: "18335",
+	}, nil
+}
+
+// loadSignetParams returns the parameters of the signed test network with the
+// passed hex encoded challenge and registers the network.
+func loadSignetParams(challengeHex string) (*params, error) {
+	challenge, err := hex.DecodeString(challengeHex)
+	if err != nil || len(challenge) == 0 {
+		return nil, fmt.Errorf("invalid signet challenge %q", challengeHex)
+	}
+	netParams := chaincfg.CustomSignetParams(challenge, nil)
+	if err := chaincfg.Register(&netParams); err != nil {
+		return nil, fmt.Errorf("unable to register signed test network: %v",
+			err)
+	}
+	return &params{
+		Params:   &netParams,
+		rpcPort:  sigNetParams.rpcPort,
+		gRRPPort: sigNetParams.gRRPPort,
+	}, nil
+}
+
+// setUpgradeActivation parses an upgrade activation override in the form
+// '<upgrade>:<activation>' and applies it to the passed network parameters.
+func setUpgradeActivation(netParams *chaincfg.Params, upgradeActivation string) error {
+	parts := strings.Split(upgradeActivation, ":")
+	if len(parts) != 2 {
+		return fmt.Errorf("unable to parse upgrade activation %q -- use "+
+			"the syntax <upgrade>:<activation>", upgradeActivation)
+	}
+	activation, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("unable to parse upgrade activation %q: %v",
+			upgradeActivation, err)
+	}
+	return netParams.SetUpgradeActivation(parts[0], activation)
+}
+
+// parseCoinbaseOutput parses a coinbase output in the form
+// '<hex script>[:<amount in satoshis>]'.  The amount defaults to zero, which
+// suits commitments in provably unspendable outputs.
+func parseCoinbaseOutput(coinbaseOutput string) (*wire.TxOut, error) {
+	parts := strings.Split(coinbaseOutput, ":")
+	if len(parts) > 2 {
+		return nil, fmt.Errorf("unable to parse coinbase output %q -- use "+
+			"the syntax <hex script>[:<amount in satoshis>]",
+			coinbaseOutput)
+	}
+	pkScript, err := hex.DecodeString(parts[0])
+	if err != nil || len(pkScript) == 0 {
+		return nil, fmt.Errorf("invalid coinbase output script %q",
+			parts[0])
+	}
+	var amount int64
+	if len(parts) == 2 {
+		amount, err = strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || amount < 0 || amount > bchutil.MaxSatoshi {
+			return nil, fmt.Errorf("invalid coinbase output amount %q",
+				parts[1])
+		}
+	}
+	return wire.NewTxOut(amount, pkScript, wire.TokenData{}), nil
+}
+
 // filesExists reports whether the named file or directory exists.
 func fileExists(name string) bool {
 	if _, err := os.Stat(name); err != nil {
@@ -414,24 +675,15 @@ func newConfigParser(cfg *config, so *serviceOptions, options flags.Options) *fl
 	return parser
 }
 
-// loadConfig initializes and parses the config using a config file and command
-// line options.
-//
-// The configuration proceeds as follows:
-//  1. Start with a default config with sane settings
-//  2. Pre-parse the command line to check for an alternative config file
-//  3. Load configuration file overwriting defaults with any specified options
-//  4. Parse CLI options and overwrite/add any specified options
-//
-// The above results in bchd functioning properly without any config settings
-// while still allowing the user to override settings with config files and
-// command line options.  Command line options always take precedence.
-func loadConfig() (*config, []string, error) {
-	// Default config.
-	cfg := config{
+// defaultConfig returns a config with the default settings, which are
+// overwritten by the options of the config file and the command line.
+func defaultConfig() config {
+	return config{
 		ConfigFile:              defaultConfigFile,
 		DebugLevel:              defaultLogLevel,
+		LogFormat:               defaultLogFormat,
 		MaxPeers:                defaultMaxPeers,
+		TorControl:              defaultTorControl,
 		MaxPeersPerIP:           defaultMaxPeersPerIP,
 		MinSyncPeerNetworkSpeed: defaultMinSyncPeerNetworkSpeed,
 		BanDuration:             defaultBanDuration,
@@ -439,6 +691,8 @@ func loadConfig() (*config, []string, error) {
 		RPCMaxClients:           defaultMaxRPCClients,
 		RPCMaxWebsockets:        defaultMaxRPCWebsockets,
 		RPCMaxConcurrentReqs:    defaultMaxRPCConcurrentReqs,
+		RPCRateBurst:            defaultRPCRateBurst,
+		GrpcRateBurst:           defaultRPCRateBurst,
 		DataDir:                 defaultDataDir,
 		LogDir:                  defaultLogDir,
 		DbType:                  defaultDbType,
@@ -446,15 +700,22 @@ func loadConfig() (*config, []string, error) {
 		RPCCert:                 defaultRPCCertFile,
 		ExcessiveBlockSize:      defaultExcessiveBlockSize,
 		MinRelayTxFee:           mempool.DefaultMinRelayTxFee.ToBCH(),
+		DustRelayFee:            mempool.DefaultDustRelayFee.ToBCH(),
+		MaxTxSigChecks:          mempool.DefaultMaxTxSigChecks,
 		FreeTxRelayLimit:        defaultFreeTxRelayLimit,
 		TrickleInterval:         defaultTrickleInterval,
 		BlockMinSize:            defaultBlockMinSize,
 		BlockMaxSize:            defaultBlockMaxSize,
 		CoinbaseFlags:           mining.CoinbaseFlags,
+		StratumDifficulty:       stratum.DefaultDifficulty,
 		BlockPrioritySize:       mempool.DefaultBlockPrioritySize,
 		MaxOrphanTxs:            defaultMaxOrphanTransactions,
+		MaxMempool:              defaultMaxMempool,
+		MempoolExpiry:           mempool.DefaultMempoolExpiry,
+		DataCarrierSize:         mempool.DefaultDataCarrierPolicy.MaxSize,
 		SigCacheMaxSize:         defaultSigCacheMaxSize,
-		UtxoCacheMaxSizeMiB:     defaultUtxoCacheMaxSizeMiB,
+		ScriptCacheMaxSize:      defaultScriptCacheMaxSize,
+		UtxoCacheMaxMB:          defaultUtxoCacheMaxMB,
 		Generate:                defaultGenerate,
 		TxIndex:                 defaultTxIndex,
 		RPCAuthTimeout:          defaultRPCAuthTimeout,
@@ -462,12 +723,38 @@ func loadConfig() (*config, []string, error) {
 		SlpIndex:                defaultSlpIndex,
 		SlpCacheMaxSize:         defaultSlpCacheMaxSize,
 		SlpGraphSearch:          defaultSlpGraphSearch,
+		TokenIndex:              defaultTokenIndex,
+		StatsIndex:              defaultStatsIndex,
+		CoinAgeIndex:            defaultCoinAgeIndex,
+		WatchSetIndex:           defaultWatchSetIndex,
 		PruneDepth:              defaultPruneDepth,
+		UndoWindow:              blockchain.DefaultUndoWindow,
 		TargetOutboundPeers:     defaultTargetOutboundPeers,
+		BlockRelayOnlyPeers:     defaultBlockRelayOnlyPeers,
+		MaxBloomFilterSize:      wire.MaxFilterLoadFilterSize,
+		MaxBloomFilterMem:       defaultMaxBloomFilterMem,
+		MaxBloomFilterAdds:      defaultMaxBloomFilterAdds,
 		DBCacheSize:             defaultDBCacheSize,
 		DBFlushInterval:         defaultDBFlushSecs,
 		PrometheusListen:        "",
 	}
+}
+
+// loadConfig initializes and parses the config using a config file and command
+// line options.
+//
+// The configuration proceeds as follows:
+//  1. Start with a default config with sane settings
+//  2. Pre-parse the command line to check for an alternative config file
+//  3. Load configuration file overwriting defaults with any specified options
+//  4. Parse CLI options and overwrite/add any specified options
+//
+// The above results in bchd functioning properly without any config settings
+// while still allowing the user to override settings with config files and
+// command line options.  Command line options always take precedence.
+func loadConfig() (*config, []string, error) {
+	// Default config.
+	cfg := defaultConfig()
 
 	// Service options which are only added on Windows.
 	serviceOpts := serviceOptions{}
@@ -582,6 +869,39 @@ func loadConfig() (*config, []string, error) {
 		numNets++
 		activeNetParams = &chipNetParams
 	}
+	if cfg.ScaleNet {
+		numNets++
+		activeNetParams = &scaleNetParams
+	}
+	if cfg.SigNet {
+		numNets++
+		activeNetParams = &sigNetParams
+		if cfg.SignetChallenge != "" {
+			customSigNetParams, err := loadSignetParams(cfg.SignetChallenge)
+			if err != nil {
+				err := fmt.Errorf("%s: %v", funcName, err)
+				fmt.Fprintln(os.Stderr, err)
+				return nil, nil, err
+			}
+			activeNetParams = customSigNetParams
+		}
+	} else if cfg.SignetChallenge != "" {
+		str := "%s: the signetchallenge option can only be used with signet"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.CustomNet != "" {
+		numNets++
+		customNetParams, err := loadCustomNetParams(cleanAndExpandPath(cfg.CustomNet))
+		if err != nil {
+			err := fmt.Errorf("%s: %v", funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, err
+		}
+		activeNetParams = customNetParams
+	}
 	if cfg.RegressionTest {
 		numNets++
 		activeNetParams = &regressionNetParams
@@ -593,15 +913,44 @@ func loadConfig() (*config, []string, error) {
 		cfg.DisableDNSSeed = true
 	}
 	if numNets > 1 {
-		str := "%s: The testnet, chipnet, regtest, segnet, and simnet params " +
-			"can't be used together -- choose one of the four"
+		str := "%s: The testnet, chipnet, scalenet, signet, customnet, " +
+			"regtest, and simnet params can't be used together -- choose one"
 		err := fmt.Errorf(str, funcName)
 		fmt.Fprintln(os.Stderr, err)
 		fmt.Fprintln(os.Stderr, usageMessage)
 		return nil, nil, err
 	}
 
+	// Upgrade activations may only be overridden on the networks which are
+	// used for testing.  The overrides are applied to the parameters of the
+	// active network directly so the rest of the node sees them as usual.
+	if len(cfg.UpgradeActivations) > 0 {
+		if !(cfg.RegressionTest || cfg.SimNet) {
+			str := "%s: upgradeactivation can only be used with regtest or simnet"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		for _, upgradeActivation := range cfg.UpgradeActivations {
+			err := setUpgradeActivation(activeNetParams.Params, upgradeActivation)
+			if err != nil {
+				err := fmt.Errorf("%s: %v", funcName, err)
+				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(os.Stderr, usageMessage)
+				return nil, nil, err
+			}
+		}
+	}
+
 	// Re-indexing and pruning don't mix.
+	if cfg.Reindex && cfg.Prune {
+		str := "%s: reindex can not be used with a pruned blockchain."
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
 	if cfg.ReIndexChainState && cfg.Prune {
 		str := "%s: reindexchainstate can not be used with a pruned blockchain."
 		err := fmt.Errorf(str, funcName)
@@ -611,6 +960,13 @@ func loadConfig() (*config, []string, error) {
 	}
 
 	// Re-indexing and fast sync don't mix either.
+	if cfg.Reindex && cfg.FastSync {
+		str := "%s: reindex can not be used with fast sync mode."
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
 	if cfg.ReIndexChainState && cfg.FastSync {
 		str := "%s: reindexchainstate can not be used with fast sync mode."
 		err := fmt.Errorf(str, funcName)
@@ -630,8 +986,10 @@ func loadConfig() (*config, []string, error) {
 
 	// Indexing also doesn't work with fast sync as the indexes will not go
 	// back to genesis.
-	if (cfg.TxIndex || cfg.AddrIndex) && cfg.FastSync {
-		str := "%s: txindex and addrindex can not be used with fast sync mode."
+	if (cfg.TxIndex || cfg.AddrIndex || cfg.TokenIndex || cfg.StatsIndex ||
+		cfg.CoinAgeIndex || cfg.WatchSetIndex) && cfg.FastSync {
+
+		str := "%s: txindex, addrindex, tokenindex, statsindex, coinageindex, and watchsetindex can not be used with fast sync mode."
 		err := fmt.Errorf(str, funcName)
 		fmt.Fprintln(os.Stderr, err)
 		fmt.Fprintln(os.Stderr, usageMessage)
@@ -647,6 +1005,33 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Headers only mode neither stores the blocks nor keeps a UTXO set, so
+	// it doesn't mix with the options relying on them.
+	if cfg.HeadersOnly && (cfg.Prune || cfg.FastSync || cfg.Reindex ||
+		cfg.ReIndexChainState || cfg.UtxoStats || cfg.Generate) {
+
+		str := "%s: headersonly can not be used with prune, fastsync, reindex, reindexchainstate, utxostats, or generate."
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.HeadersOnly && (cfg.TxIndex || cfg.AddrIndex || cfg.SlpIndex ||
+		cfg.TokenIndex || cfg.StatsIndex || cfg.CoinAgeIndex ||
+		cfg.WatchSetIndex || cfg.NoCFilters) {
+
+		str := "%s: headersonly only supports the committed filter index and can not be used with txindex, addrindex, slpindex, tokenindex, statsindex, coinageindex, watchsetindex, or nocfilters."
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Transactions can not be validated without a UTXO set.
+	if cfg.HeadersOnly {
+		cfg.BlocksOnly = true
+	}
+
 	// SlpGraphSearch doesn't work without txindex and slpindex
 	if cfg.SlpGraphSearch && (!cfg.TxIndex || !cfg.SlpIndex) {
 		str := "%s: slpgraphsearch can not be used without both txindex and slpindex."
@@ -656,6 +1041,48 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Authchains are followed using the address index.
+	if cfg.Bcmr && !cfg.AddrIndex {
+		str := "%s: bcmr can not be used without addrindex."
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Watch-only accounts are tracked using the address index.
+	if cfg.WatchOnly && !cfg.AddrIndex {
+		str := "%s: watchonly can not be used without addrindex."
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Hot wallets are tracked as watch-only accounts and their callers are
+	// authenticated by a token of their own.
+	if cfg.HotWallet && !cfg.WatchOnly {
+		str := "%s: hotwallet can not be used without watchonly."
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.HotWallet && cfg.HotWalletAuthToken == "" {
+		str := "%s: hotwallet can not be used without hotwalletauthtoken."
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.HotWallet && cfg.HotWalletAuthToken == cfg.GrpcAuthToken {
+		str := "%s: hotwalletauthtoken must differ from grpcauthtoken."
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Set the default policy for relaying non-standard transactions
 	// according to the default of the active network. The set
 	// configuration value takes precedence over the default value for the
@@ -690,6 +1117,10 @@ func loadConfig() (*config, []string, error) {
 	cfg.LogDir = cleanAndExpandPath(cfg.LogDir)
 	cfg.LogDir = filepath.Join(cfg.LogDir, netName(activeNetParams))
 
+	if cfg.ASMap != "" {
+		cfg.ASMap = cleanAndExpandPath(cfg.ASMap)
+	}
+
 	// Special show command to list supported subsystems and exit.
 	if cfg.DebugLevel == "show" {
 		fmt.Println("Supported subsystems", supportedSubsystems())
@@ -700,6 +1131,19 @@ func loadConfig() (*config, []string, error) {
 	// logger variables may be used.
 	initLogRotator(filepath.Join(cfg.LogDir, defaultLogFilename))
 
+	// Validate and set the log format and sampling.
+	logFormat, ok := structlog.ParseFormat(cfg.LogFormat)
+	if !ok {
+		str := "%s: The specified log format [%v] is invalid -- " +
+			"supported formats are text and json"
+		err := fmt.Errorf(str, funcName, cfg.LogFormat)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	backendLog.SetFormat(logFormat)
+	backendLog.SetSampling(cfg.LogSampling)
+
 	// Parse, validate, and set debug log level(s).
 	if err := parseAndSetDebugLevels(cfg.DebugLevel); err != nil {
 		err := fmt.Errorf("%s: %v", funcName, err.Error())
@@ -747,38 +1191,66 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	if cfg.UndoWindow <= 0 {
+		str := "%s: The undowindow option must be greater than 0 -- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.UndoWindow)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The utxocachemaxsize option is deprecated in favor of utxocachemaxmb,
+	// but is still honored when utxocachemaxmb is not changed.
+	if cfg.UtxoCacheMaxSizeMiB != 0 && cfg.UtxoCacheMaxMB == defaultUtxoCacheMaxMB {
+		cfg.UtxoCacheMaxMB = cfg.UtxoCacheMaxSizeMiB
+	}
+
 	// Validate any given whitelisted IP addresses and networks.
 	if len(cfg.Whitelists) > 0 {
-		var ip net.IP
 		cfg.whitelists = make([]*net.IPNet, 0, len(cfg.Whitelists))
 
 		for _, addr := range cfg.Whitelists {
-			_, ipnet, err := net.ParseCIDR(addr)
-			if err != nil {
-				ip = net.ParseIP(addr)
-				if ip == nil {
-					str := "%s: The whitelist value of '%s' is invalid"
-					err = fmt.Errorf(str, funcName, addr)
-					fmt.Fprintln(os.Stderr, err)
-					fmt.Fprintln(os.Stderr, usageMessage)
-					return nil, nil, err
-				}
-				var bits int
-				if ip.To4() == nil {
-					// IPv6
-					bits = 128
-				} else {
-					bits = 32
-				}
-				ipnet = &net.IPNet{
-					IP:   ip,
-					Mask: net.CIDRMask(bits, bits),
-				}
+			ipnet := parseIPNet(addr)
+			if ipnet == nil {
+				str := "%s: The whitelist value of '%s' is invalid"
+				err := fmt.Errorf(str, funcName, addr)
+				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(os.Stderr, usageMessage)
+				return nil, nil, err
 			}
 			cfg.whitelists = append(cfg.whitelists, ipnet)
 		}
 	}
 
+	// Validate the IP addresses and networks which are not served merkle
+	// blocks.
+	if len(cfg.NoMerkleBlockNets) > 0 {
+		cfg.noMerkleBlockNets = make([]*net.IPNet, 0, len(cfg.NoMerkleBlockNets))
+
+		for _, addr := range cfg.NoMerkleBlockNets {
+			ipnet := parseIPNet(addr)
+			if ipnet == nil {
+				str := "%s: The nomerkleblocknet value of '%s' is invalid"
+				err := fmt.Errorf(str, funcName, addr)
+				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(os.Stderr, usageMessage)
+				return nil, nil, err
+			}
+			cfg.noMerkleBlockNets = append(cfg.noMerkleBlockNets, ipnet)
+		}
+	}
+
+	// The bloom filter size limit may not exceed the protocol limit.
+	if cfg.MaxBloomFilterSize > wire.MaxFilterLoadFilterSize {
+		str := "%s: The maxbloomfiltersize option may not be more than %d " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, wire.MaxFilterLoadFilterSize,
+			cfg.MaxBloomFilterSize)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// --addPeer and --connect do not mix.
 	if len(cfg.AddPeers) > 0 && len(cfg.ConnectPeers) > 0 {
 		str := "%s: the --addpeer and --connect options can not be " +
@@ -789,10 +1261,19 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
-	// --proxy or --connect without --listen disables listening.
+	// --proxy or --connect without --listen disables listening.  When an
+	// onion service is requested through a proxy, only the loopback
+	// interface which Tor forwards inbound connections to is listened on.
 	if (cfg.Proxy != "" || len(cfg.ConnectPeers) > 0) &&
 		len(cfg.Listeners) == 0 {
-		cfg.DisableListen = true
+
+		if cfg.ListenOnion && len(cfg.ConnectPeers) == 0 {
+			cfg.Listeners = []string{
+				net.JoinHostPort("127.0.0.1", activeNetParams.DefaultPort),
+			}
+		} else {
+			cfg.DisableListen = true
+		}
 	}
 
 	// Connect means no DNS seeding.
@@ -809,29 +1290,37 @@ func loadConfig() (*config, []string, error) {
 		}
 	}
 
-	// Check to make sure limited and admin users don't have the same username
-	if cfg.RPCUser == cfg.RPCLimitUser && cfg.RPCUser != "" {
-		str := "%s: --rpcuser and --rpclimituser must not specify the " +
-			"same username"
-		err := fmt.Errorf(str, funcName)
-		fmt.Fprintln(os.Stderr, err)
-		fmt.Fprintln(os.Stderr, usageMessage)
-		return nil, nil, err
+	// Local clients may authenticate with the credential in the cookie file
+	// when no admin password is configured.
+	var cookieCredential *rpcCredential
+	if !cfg.DisableRPC && !cfg.NoRPCCookie &&
+		(cfg.RPCUser == "" || cfg.RPCPass == "") {
+
+		password, credential, err := newRPCCookie()
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.rpcCookie = password
+		cookieCredential = credential
+		if cfg.RPCCookieFile == "" {
+			cfg.RPCCookieFile = filepath.Join(cfg.DataDir,
+				rpcCookieFilename)
+		}
+		cfg.RPCCookieFile = cleanAndExpandPath(cfg.RPCCookieFile)
 	}
 
-	// Check to make sure limited and admin users don't have the same password
-	if cfg.RPCPass == cfg.RPCLimitPass && cfg.RPCPass != "" {
-		str := "%s: --rpcpass and --rpclimitpass must not specify the " +
-			"same password"
-		err := fmt.Errorf(str, funcName)
+	// Build the credentials of the RPC users.
+	cfg.rpcCredentials, err = buildRPCCredentials(&cfg, cookieCredential)
+	if err != nil {
+		err := fmt.Errorf("%s: %v", funcName, err)
 		fmt.Fprintln(os.Stderr, err)
 		fmt.Fprintln(os.Stderr, usageMessage)
 		return nil, nil, err
 	}
 
-	// The RPC server is disabled if no username or password is provided.
-	if (cfg.RPCUser == "" || cfg.RPCPass == "") &&
-		(cfg.RPCLimitUser == "" || cfg.RPCLimitPass == "") {
+	// The RPC server is disabled if there are no credentials to
+	// authenticate with.
+	if len(cfg.rpcCredentials) == 0 {
 		cfg.DisableRPC = true
 	}
 
@@ -861,6 +1350,31 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Validate the request quotas of the RPC servers.
+	quotaLimits := []struct {
+		name  string
+		value float64
+	}{
+		{"rpcratelimit", cfg.RPCRateLimit},
+		{"rpcuserratelimit", cfg.RPCUserRateLimit},
+		{"rpcrateburst", float64(cfg.RPCRateBurst)},
+		{"rpcmaxclientreqs", float64(cfg.RPCMaxClientReqs)},
+		{"grpcratelimit", cfg.GrpcRateLimit},
+		{"grpcuserratelimit", cfg.GrpcUserRateLimit},
+		{"grpcrateburst", float64(cfg.GrpcRateBurst)},
+		{"grpcmaxclientreqs", float64(cfg.GrpcMaxClientReqs)},
+	}
+	for _, limit := range quotaLimits {
+		if limit.value < 0 {
+			str := "%s: The %s option may not be less than 0 " +
+				"-- parsed [%v]"
+			err := fmt.Errorf(str, funcName, limit.name, limit.value)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
 	// Validate the minrelaytxfee.
 	cfg.minRelayTxFee, err = bchutil.NewAmount(cfg.MinRelayTxFee)
 	if err != nil {
@@ -871,6 +1385,16 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Validate the dustrelayfee.
+	cfg.dustRelayFee, err = bchutil.NewAmount(cfg.DustRelayFee)
+	if err != nil {
+		str := "%s: invalid dustrelayfee: %v"
+		err := fmt.Errorf(str, funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Limit the max orphan count to a sane value.
 	if cfg.MaxOrphanTxs < 0 {
 		str := "%s: The maxorphantx option may not be less than 0 " +
@@ -881,9 +1405,64 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// The mempool expiry may not be negative.
+	if cfg.MempoolExpiry < 0 {
+		str := "%s: The mempoolexpiry option may not be less than 0 " +
+			"-- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.MempoolExpiry)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The mempool size and package limits may not be negative.
+	mempoolLimits := []struct {
+		name  string
+		value int
+	}{
+		{"maxmempool", cfg.MaxMempool},
+		{"limitancestorcount", cfg.LimitAncestorCount},
+		{"limitancestorsize", cfg.LimitAncestorSize},
+		{"limitdescendantcount", cfg.LimitDescendantCount},
+		{"limitdescendantsize", cfg.LimitDescendantSize},
+		{"datacarriersize", cfg.DataCarrierSize},
+		{"maxdatacarriers", cfg.MaxDataCarriers},
+		{"maxtxsigchecks", cfg.MaxTxSigChecks},
+	}
+	for _, limit := range mempoolLimits {
+		if limit.value < 0 {
+			str := "%s: The %s option may not be less than 0 " +
+				"-- parsed [%d]"
+			err := fmt.Errorf(str, funcName, limit.name, limit.value)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
+	// Parse the prefixes of the allowed null data protocols.
+	for _, prefix := range cfg.DataCarrierProtocols {
+		protocol, err := hex.DecodeString(prefix)
+		if err != nil || len(protocol) == 0 {
+			str := "%s: The datacarrierprotocol option must be a " +
+				"non-empty hex string -- parsed [%s]"
+			err := fmt.Errorf(str, funcName, prefix)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.dataCarrierProtocols = append(cfg.dataCarrierProtocols, protocol)
+	}
+
 	// Excessive blocksize cannot be set less than the default but it can be higher.
 	cfg.ExcessiveBlockSize = max(cfg.ExcessiveBlockSize, defaultExcessiveBlockSize)
 
+	// Scalenet exists to test large blocks so its blocks may be much larger
+	// than those of the other networks.
+	if cfg.ScaleNet {
+		cfg.ExcessiveBlockSize = max(cfg.ExcessiveBlockSize, scaleNetExcessiveBlockSize)
+	}
+
 	// Limit the max block size to a sane value.
 	blockMaxSizeMax := cfg.ExcessiveBlockSize - 1000
 	if cfg.BlockMaxSize < blockMaxSizeMin || cfg.BlockMaxSize >
@@ -958,6 +1537,46 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// --tokenindex and --droptokenindex do not mix.
+	if cfg.TokenIndex && cfg.DropTokenIndex {
+		err := fmt.Errorf("%s: the --tokenindex and --droptokenindex "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --statsindex and --dropstatsindex do not mix.
+	if cfg.StatsIndex && cfg.DropStatsIndex {
+		err := fmt.Errorf("%s: the --statsindex and --dropstatsindex "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --coinageindex and --dropcoinageindex do not mix.
+	if cfg.CoinAgeIndex && cfg.DropCoinAgeIndex {
+		err := fmt.Errorf("%s: the --coinageindex and --dropcoinageindex "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --watchsetindex and --dropwatchsetindex do not mix.
+	if cfg.WatchSetIndex && cfg.DropWatchSetIndex {
+		err := fmt.Errorf("%s: the --watchsetindex and --dropwatchsetindex "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Check mining addresses are valid and saved parsed versions.
 	cfg.miningAddrs = make([]bchutil.Address, 0, len(cfg.MiningAddrs))
 	for _, strAddr := range cfg.MiningAddrs {
@@ -979,6 +1598,122 @@ func loadConfig() (*config, []string, error) {
 		cfg.miningAddrs = append(cfg.miningAddrs, addr)
 	}
 
+	// Check the webhook URLs and addresses are valid and save the parsed
+	// versions of the addresses.
+	for _, strURL := range cfg.Webhooks {
+		u, err := url.Parse(strURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+			u.Host == "" {
+
+			str := "%s: webhook '%s' is not a valid HTTP or HTTPS URL"
+			err := fmt.Errorf(str, funcName, strURL)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+	if len(cfg.WebhookAddrs) > 0 && len(cfg.Webhooks) == 0 {
+		str := "%s: the webhookaddr option requires at least one webhook"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	cfg.webhookAddrs = make([]bchutil.Address, 0, len(cfg.WebhookAddrs))
+	for _, strAddr := range cfg.WebhookAddrs {
+		addr, err := bchutil.DecodeAddress(strAddr, activeNetParams.Params)
+		if err != nil {
+			str := "%s: webhook address '%s' failed to decode: %v"
+			err := fmt.Errorf(str, funcName, strAddr, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if !addr.IsForNet(activeNetParams.Params) {
+			str := "%s: webhook address '%s' is on the wrong network"
+			err := fmt.Errorf(str, funcName, strAddr)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.webhookAddrs = append(cfg.webhookAddrs, addr)
+	}
+
+	// The first seen export requires first seen tracking.
+	if cfg.FirstSeenExport != "" {
+		if !cfg.FirstSeen {
+			str := "%s: the firstseenexport option requires the " +
+				"firstseen option"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.FirstSeenExport = cleanAndExpandPath(cfg.FirstSeenExport)
+	}
+
+	// Check the coinbase data and outputs are valid and save the parsed
+	// versions.
+	if cfg.CoinbaseData != "" {
+		cfg.coinbaseData, err = hex.DecodeString(cfg.CoinbaseData)
+		if err != nil {
+			str := "%s: unable to decode coinbase data: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+	if err := mining.CheckCoinbaseData(cfg.CoinbaseFlags, cfg.coinbaseData); err != nil {
+		err := fmt.Errorf("%s: %v", funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	for _, coinbaseOutput := range cfg.CoinbaseOutputs {
+		txOut, err := parseCoinbaseOutput(coinbaseOutput)
+		if err != nil {
+			err := fmt.Errorf("%s: %v", funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.coinbaseOutputs = append(cfg.coinbaseOutputs, txOut)
+	}
+
+	// Check the signet keys are valid and save the parsed versions.
+	if len(cfg.SignetKeys) > 0 && activeNetParams.SignetChallenge == nil {
+		str := "%s: the signetkey option can only be used on a signed " +
+			"test network"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	for _, signetKey := range cfg.SignetKeys {
+		wif, err := bchutil.DecodeWIF(signetKey)
+		if err != nil {
+			str := "%s: signet key failed to decode: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.signetKeys = append(cfg.signetKeys, wif.PrivKey)
+	}
+
+	// Blocks generated on a signed test network must be signed.
+	if cfg.Generate && activeNetParams.SignetChallenge != nil &&
+		len(cfg.signetKeys) == 0 {
+
+		str := "%s: the generate flag is set, but there are no signet " +
+			"keys specified "
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Ensure there is at least one mining address when the generate flag is
 	// set.
 	if cfg.Generate && len(cfg.MiningAddrs) == 0 {
@@ -990,6 +1725,40 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Ensure there is at least one mining address when the stratum server
+	// is enabled.
+	if len(cfg.StratumListeners) > 0 && len(cfg.MiningAddrs) == 0 {
+		str := "%s: the stratumlisten option is set, but there are no " +
+			"mining addresses specified "
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The stratum share difficulty must be positive.
+	if cfg.StratumDifficulty <= 0 {
+		str := "%s: the stratumdifficulty option must be greater than " +
+			"zero -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.StratumDifficulty)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The DNS seeder needs the host name it answers queries for.
+	if len(cfg.SeederListeners) > 0 && cfg.SeederHost == "" {
+		str := "%s: the seederlisten option is set, but the " +
+			"seederhost option is not"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.SeederHost != "" && len(cfg.SeederListeners) == 0 {
+		cfg.SeederListeners = []string{":" + defaultSeederPort}
+	}
+
 	// Add default port to all listener addresses if needed and remove
 	// duplicate addresses.
 	cfg.Listeners = normalizeAddresses(cfg.Listeners,
@@ -1005,6 +1774,16 @@ func loadConfig() (*config, []string, error) {
 	cfg.GrpcListeners = normalizeAddresses(cfg.GrpcListeners,
 		activeNetParams.gRRPPort)
 
+	// Add default port to all stratum listener addresses if needed and
+	// remove duplicate addresses.
+	cfg.StratumListeners = normalizeAddresses(cfg.StratumListeners,
+		defaultStratumPort)
+
+	// Add default port to all DNS seeder listener addresses if needed and
+	// remove duplicate addresses.
+	cfg.SeederListeners = normalizeAddresses(cfg.SeederListeners,
+		defaultSeederPort)
+
 	// Only allow TLS to be disabled if the RPC or gRPC is bound to localhost
 	// addresses.
 	if !cfg.DisableRPC && cfg.DisableTLS {
@@ -1049,6 +1828,40 @@ func loadConfig() (*config, []string, error) {
 		}
 	}
 
+	// Check the gRPC client certificate scopes are valid and save the
+	// parsed versions.
+	if len(cfg.GrpcClientScopes) > 0 && cfg.GrpcClientCA == "" {
+		str := "%s: the grpcclientscope option requires grpcclientca"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.GrpcClientCA != "" {
+		cfg.GrpcClientCA = cleanAndExpandPath(cfg.GrpcClientCA)
+	}
+	cfg.grpcClientScopes = make(map[string]bchrpc.Scope, len(cfg.GrpcClientScopes))
+	for _, clientScope := range cfg.GrpcClientScopes {
+		i := strings.LastIndex(clientScope, "=")
+		if i <= 0 {
+			str := "%s: gRPC client scope '%s' is not of the " +
+				"form <common name>=<scope>"
+			err := fmt.Errorf(str, funcName, clientScope)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		scope, err := bchrpc.ParseScope(clientScope[i+1:])
+		if err != nil {
+			str := "%s: gRPC client scope '%s' is invalid: %v"
+			err := fmt.Errorf(str, funcName, clientScope, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.grpcClientScopes[clientScope[:i]] = scope
+	}
+
 	// Add default port to all added peer addresses if needed and remove
 	// duplicate addresses.
 	cfg.AddPeers = normalizeAddresses(cfg.AddPeers,
@@ -1075,6 +1888,35 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Parse the minimum chain work override.
+	if cfg.MinimumChainWork != "" {
+		work, ok := new(big.Int).SetString(cfg.MinimumChainWork, 16)
+		if !ok || work.Sign() < 0 {
+			str := "%s: The minimumchainwork option must be a hex " +
+				"encoded number -- parsed [%v]"
+			err := fmt.Errorf(str, funcName, cfg.MinimumChainWork)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.minimumChainWork = work
+	}
+
+	// Parse the assumed valid block override.  It remains nil when all
+	// scripts are to be validated.
+	if cfg.AssumeValid != "" && cfg.AssumeValid != "0" {
+		hash, err := chainhash.NewHashFromStr(cfg.AssumeValid)
+		if err != nil {
+			str := "%s: The assumevalid option must be a block " +
+				"hash or 0 -- parsed [%v]"
+			err := fmt.Errorf(str, funcName, cfg.AssumeValid)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.assumeValid = hash
+	}
+
 	// Tor stream isolation requires either proxy or onion proxy to be set.
 	if cfg.TorIsolation && cfg.Proxy == "" && cfg.OnionProxy == "" {
 		str := "%s: Tor stream isolation requires either proxy or " +
@@ -1085,6 +1927,39 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Creating an onion service requires accepting inbound connections and
+	// connecting to onion addresses.
+	if cfg.ListenOnion && (cfg.DisableListen || cfg.NoOnion) {
+		str := "%s: the --listenonion option may not be used when " +
+			"listening or tor is disabled"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.ListenOnion {
+		_, _, err := net.SplitHostPort(cfg.TorControl)
+		if err != nil {
+			str := "%s: Tor control address '%s' is invalid: %v"
+			err := fmt.Errorf(str, funcName, cfg.TorControl, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
+	// Tor stream isolation uses distinct proxy credentials for each peer.
+	var torIsolator *connmgr.TorStreamIsolator
+	if cfg.TorIsolation {
+		torIsolator, err = connmgr.NewTorStreamIsolator()
+		if err != nil {
+			str := "%s: Unable to initialize Tor stream isolation: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, err
+		}
+	}
+
 	// Setup dial and DNS resolution (lookup) functions depending on the
 	// specified options.  The default is to use the standard
 	// net.DialTimeout function as well as the system DNS resolver.  When a
@@ -1106,22 +1981,21 @@ func loadConfig() (*config, []string, error) {
 		// Tor isolation flag means proxy credentials will be overridden
 		// unless there is also an onion proxy configured in which case
 		// that one will be overridden.
-		torIsolation := false
-		if cfg.TorIsolation && cfg.OnionProxy == "" &&
-			(cfg.ProxyUser != "" || cfg.ProxyPass != "") {
-
-			torIsolation = true
+		torIsolation := cfg.TorIsolation && cfg.OnionProxy == ""
+		if torIsolation && (cfg.ProxyUser != "" || cfg.ProxyPass != "") {
 			fmt.Fprintln(os.Stderr, "Tor isolation set -- "+
 				"overriding specified proxy user credentials")
 		}
 
 		proxy := &socks.Proxy{
-			Addr:         cfg.Proxy,
-			Username:     cfg.ProxyUser,
-			Password:     cfg.ProxyPass,
-			TorIsolation: torIsolation,
+			Addr:     cfg.Proxy,
+			Username: cfg.ProxyUser,
+			Password: cfg.ProxyPass,
 		}
 		cfg.dial = proxy.DialTimeout
+		if torIsolation {
+			cfg.dial = isolatedProxyDial(proxy, torIsolator)
+		}
 
 		// Treat the proxy as tor and perform DNS resolution through it
 		// unless the --noonion flag is set or there is an
@@ -1158,14 +2032,14 @@ func loadConfig() (*config, []string, error) {
 				"credentials ")
 		}
 
-		cfg.oniondial = func(network, addr string, timeout time.Duration) (net.Conn, error) {
-			proxy := &socks.Proxy{
-				Addr:         cfg.OnionProxy,
-				Username:     cfg.OnionProxyUser,
-				Password:     cfg.OnionProxyPass,
-				TorIsolation: cfg.TorIsolation,
-			}
-			return proxy.DialTimeout(network, addr, timeout)
+		proxy := &socks.Proxy{
+			Addr:     cfg.OnionProxy,
+			Username: cfg.OnionProxyUser,
+			Password: cfg.OnionProxyPass,
+		}
+		cfg.oniondial = proxy.DialTimeout
+		if cfg.TorIsolation {
+			cfg.oniondial = isolatedProxyDial(proxy, torIsolator)
 		}
 
 		// When configured in bridge mode (both --onion and --proxy are
@@ -84,3 +84,32 @@ func TestCreateDefaultConfigFile(t *testing.T) {
 		t.Error("Could not find rpcpass in generated default config file.")
 	}
 }
+
+// TestParseIPNet ensures IP networks and single IP addresses are parsed as
+// expected.
+func TestParseIPNet(t *testing.T) {
+	tests := []struct {
+		addr    string
+		want    string
+		invalid bool
+	}{
+		{addr: "192.168.1.0/24", want: "192.168.1.0/24"},
+		{addr: "192.168.1.7", want: "192.168.1.7/32"},
+		{addr: "::1", want: "::1/128"},
+		{addr: "2001:db8::/32", want: "2001:db8::/32"},
+		{addr: "localhost", invalid: true},
+		{addr: "192.168.1.0/33", invalid: true},
+	}
+	for _, test := range tests {
+		ipnet := parseIPNet(test.addr)
+		if test.invalid {
+			if ipnet != nil {
+				t.Errorf("%s: unexpected network %v", test.addr, ipnet)
+			}
+			continue
+		}
+		if ipnet == nil || ipnet.String() != test.want {
+			t.Errorf("%s: got %v, want %s", test.addr, ipnet, test.want)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gcash/bchd/btcjson"
+	"github.com/gcash/bchd/connmgr"
+	"github.com/gcash/bchd/mempool"
+	"github.com/gcash/bchutil"
+	flags "github.com/jessevdk/go-flags"
+)
+
+// reloadSignals defines the signals to catch in order to reload the
+// configuration.  This may be modified during init depending on the platform.
+var reloadSignals []os.Signal
+
+// reloadedConfig holds a configuration loaded again from the config file and
+// the command line along with the validated values of its reloadable options.
+type reloadedConfig struct {
+	*config
+
+	logLevels   map[string]string
+	newPeers    map[string]net.Addr
+	credentials []*rpcCredential
+}
+
+// loadReloadableConfig loads the configuration again from the config file and
+// the command line the same way loadConfig does and validates the options
+// which may be reloaded while running.  Only the config file bchd was started
+// with is read.
+func loadReloadableConfig(loaded *config, cookie *rpcCredential) (*reloadedConfig, error) {
+	newCfg := defaultConfig()
+	parser := newConfigParser(&newCfg, &serviceOptions{}, flags.None)
+	if !(cfg.RegressionTest || cfg.SimNet) || cfg.ConfigFile !=
+		defaultConfigFile {
+
+		err := flags.NewIniParser(parser).ParseFile(cfg.ConfigFile)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing config file: %v",
+				err)
+		}
+	}
+
+	// Don't add peers from the config file when in regression test mode.
+	if cfg.RegressionTest {
+		newCfg.AddPeers = nil
+	}
+
+	// Parse command line options again to ensure they take precedence.
+	if _, err := parser.Parse(); err != nil {
+		return nil, fmt.Errorf("error parsing command line: %v", err)
+	}
+
+	reloaded := &reloadedConfig{config: &newCfg}
+
+	// Validate the debug level.
+	var err error
+	reloaded.logLevels, err = parseDebugLevels(newCfg.DebugLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate the relay policy.
+	newCfg.minRelayTxFee, err = bchutil.NewAmount(newCfg.MinRelayTxFee)
+	if err != nil {
+		return nil, fmt.Errorf("invalid minrelaytxfee: %v", err)
+	}
+	newCfg.dustRelayFee, err = bchutil.NewAmount(newCfg.DustRelayFee)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dustrelayfee: %v", err)
+	}
+	policyLimits := []struct {
+		name  string
+		value int
+	}{
+		{"datacarriersize", newCfg.DataCarrierSize},
+		{"maxdatacarriers", newCfg.MaxDataCarriers},
+		{"maxtxsigchecks", newCfg.MaxTxSigChecks},
+	}
+	for _, limit := range policyLimits {
+		if limit.value < 0 {
+			str := "the %s option may not be less than 0 -- " +
+				"parsed [%d]"
+			return nil, fmt.Errorf(str, limit.name, limit.value)
+		}
+	}
+	for _, prefix := range newCfg.DataCarrierProtocols {
+		protocol, err := hex.DecodeString(prefix)
+		if err != nil || len(protocol) == 0 {
+			str := "the datacarrierprotocol option must be a " +
+				"non-empty hex string -- parsed [%s]"
+			return nil, fmt.Errorf(str, prefix)
+		}
+		newCfg.dataCarrierProtocols = append(newCfg.dataCarrierProtocols,
+			protocol)
+	}
+
+	// Validate the credentials of the RPC users.  The RPC server can't be
+	// enabled while running, so they are left alone when it is disabled.
+	if !cfg.DisableRPC {
+		reloaded.credentials, err = buildRPCCredentials(&newCfg, cookie)
+		if err != nil {
+			return nil, err
+		}
+		if len(reloaded.credentials) == 0 {
+			return nil, errors.New("no RPC credentials are configured")
+		}
+	}
+
+	// Resolve the peers which were added to the config file.  The peers
+	// are only added when bchd doesn't connect to specific peers only.
+	newCfg.AddPeers = normalizeAddresses(newCfg.AddPeers,
+		activeNetParams.DefaultPort)
+	if len(cfg.ConnectPeers) == 0 {
+		reloaded.newPeers = make(map[string]net.Addr)
+		for _, addr := range newCfg.AddPeers {
+			if slices.Contains(loaded.AddPeers, addr) {
+				continue
+			}
+			netAddr, err := addrStringToNetAddr(addr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid addpeer %s: %v",
+					addr, err)
+			}
+			reloaded.newPeers[addr] = netAddr
+		}
+	}
+
+	return reloaded, nil
+}
+
+// reloadConfig loads the configuration again and applies the options which
+// changed among the ones that may be changed while running: the debug level,
+// the relay policy, the credentials and whitelists of the RPC users and the
+// peers to add.  Nothing is applied when any of them is invalid.  Peers which
+// were removed from the configuration stay connected until bchd is restarted.
+//
+// This function is safe for concurrent access.
+func (s *server) reloadConfig() (*btcjson.ReloadConfigResult, error) {
+	s.reloadMtx.Lock()
+	defer s.reloadMtx.Unlock()
+
+	var cookie *rpcCredential
+	if s.rpcServer != nil {
+		cookie = s.rpcServer.cookieCredential()
+	}
+	reloaded, err := loadReloadableConfig(s.loadedCfg, cookie)
+	if err != nil {
+		return nil, err
+	}
+	loaded := s.loadedCfg
+	result := &btcjson.ReloadConfigResult{
+		Applied: make([]btcjson.ReloadedOptionResult, 0),
+		Removed: make([]string, 0),
+	}
+	applied := func(option, value string) {
+		result.Applied = append(result.Applied, btcjson.ReloadedOptionResult{
+			Option: option,
+			Value:  value,
+		})
+	}
+
+	// Apply the debug level.
+	if reloaded.DebugLevel != loaded.DebugLevel {
+		for subsysID, logLevel := range reloaded.logLevels {
+			setLogLevel(subsysID, logLevel)
+		}
+		applied("debuglevel", reloaded.DebugLevel)
+	}
+
+	// Apply the relay policy options which differ from the policy in
+	// effect, which may have been changed with the setrelaypolicy command
+	// since the configuration was loaded.
+	policy := s.txMemPool.Policy()
+	protocols := make([]string, 0, len(reloaded.dataCarrierProtocols))
+	for _, protocol := range reloaded.dataCarrierProtocols {
+		protocols = append(protocols, hex.EncodeToString(protocol))
+	}
+	currentProtocols := make([]string, 0, len(policy.DataCarrier.Protocols))
+	for _, protocol := range policy.DataCarrier.Protocols {
+		currentProtocols = append(currentProtocols,
+			hex.EncodeToString(protocol))
+	}
+	var policyChanged bool
+	policyOption := func(option string, changed bool, value string) {
+		if changed {
+			policyChanged = true
+			applied(option, value)
+		}
+	}
+	policyOption("minrelaytxfee", reloaded.minRelayTxFee != policy.MinRelayTxFee,
+		reloaded.minRelayTxFee.String())
+	policyOption("dustrelayfee", reloaded.dustRelayFee != policy.DustRelayFee,
+		reloaded.dustRelayFee.String())
+	policyOption("maxtxsigchecks", reloaded.MaxTxSigChecks != policy.MaxTxSigChecks,
+		strconv.Itoa(reloaded.MaxTxSigChecks))
+	policyOption("datacarriersize", reloaded.DataCarrierSize != policy.DataCarrier.MaxSize,
+		strconv.Itoa(reloaded.DataCarrierSize))
+	policyOption("maxdatacarriers", reloaded.MaxDataCarriers != policy.DataCarrier.MaxOutputs,
+		strconv.Itoa(reloaded.MaxDataCarriers))
+	policyOption("datacarrierprotocol", !slices.Equal(protocols, currentProtocols),
+		strings.Join(protocols, ","))
+	if policyChanged {
+		removed := s.txMemPool.UpdatePolicy(func(policy *mempool.Policy) {
+			policy.MinRelayTxFee = reloaded.minRelayTxFee
+			policy.DustRelayFee = reloaded.dustRelayFee
+			policy.MaxTxSigChecks = reloaded.MaxTxSigChecks
+			policy.DataCarrier.MaxSize = reloaded.DataCarrierSize
+			policy.DataCarrier.MaxOutputs = reloaded.MaxDataCarriers
+			policy.DataCarrier.Protocols = reloaded.dataCarrierProtocols
+		})
+		for _, tx := range removed {
+			result.Removed = append(result.Removed, tx.Hash().String())
+		}
+	}
+
+	// Apply the credentials of the RPC users.  Passwords are not reported.
+	if s.rpcServer != nil {
+		var credentialsChanged bool
+		credentialOption := func(option string, changed bool, value string) {
+			if changed {
+				credentialsChanged = true
+				applied(option, value)
+			}
+		}
+		credentialOption("rpcuser", reloaded.RPCUser != loaded.RPCUser,
+			reloaded.RPCUser)
+		credentialOption("rpcpass", reloaded.RPCPass != loaded.RPCPass, "")
+		credentialOption("rpclimituser",
+			reloaded.RPCLimitUser != loaded.RPCLimitUser,
+			reloaded.RPCLimitUser)
+		credentialOption("rpclimitpass",
+			reloaded.RPCLimitPass != loaded.RPCLimitPass, "")
+		var authUsers []string
+		for _, auth := range reloaded.RPCAuth {
+			authUsers = append(authUsers, strings.SplitN(auth, ":", 2)[0])
+		}
+		credentialOption("rpcauth", !slices.Equal(reloaded.RPCAuth,
+			loaded.RPCAuth), strings.Join(authUsers, ","))
+		credentialOption("rpcwhitelist", !slices.Equal(reloaded.RPCWhitelists,
+			loaded.RPCWhitelists), strings.Join(reloaded.RPCWhitelists, " "))
+		if credentialsChanged {
+			s.rpcServer.setCredentials(reloaded.credentials)
+		}
+	}
+
+	// Connect to the peers which were added.
+	for addr, netAddr := range reloaded.newPeers {
+		go s.connManager.Connect(&connmgr.ConnReq{
+			Addr:      netAddr,
+			Permanent: true,
+		})
+		applied("addpeer", addr)
+	}
+
+	s.loadedCfg = reloaded.config
+	return result, nil
+}
+
+// reloadHandler reloads the configuration whenever one of the reload signals
+// is received.  It must be run as a goroutine.
+func (s *server) reloadHandler() {
+	reloadChannel := make(chan os.Signal, 1)
+	signal.Notify(reloadChannel, reloadSignals...)
+
+out:
+	for {
+		select {
+		case sig := <-reloadChannel:
+			srvrLog.Infof("Received signal (%s).  Reloading the "+
+				"configuration...", sig)
+			result, err := s.reloadConfig()
+			if err != nil {
+				srvrLog.Errorf("Unable to reload the configuration: "+
+					"%v", err)
+				continue
+			}
+			for _, option := range result.Applied {
+				if option.Value == "" {
+					srvrLog.Infof("Applied %s", option.Option)
+					continue
+				}
+				srvrLog.Infof("Applied %s %s", option.Option,
+					option.Value)
+			}
+			if len(result.Removed) > 0 {
+				srvrLog.Infof("Removed %d transactions which no "+
+					"longer meet the relay policy",
+					len(result.Removed))
+			}
+			srvrLog.Infof("Reloaded the configuration (%d options "+
+				"applied)", len(result.Applied))
+
+		case <-s.quit:
+			break out
+		}
+	}
+
+	signal.Stop(reloadChannel)
+	s.wg.Done()
+}
@@ -35,6 +35,10 @@ var (
 	// defaultTargetOutbound is the default number of outbound connections to
 	// maintain.
 	defaultTargetOutbound = uint32(8)
+
+	// defaultFeelerInterval is the default interval between feeler
+	// connections.
+	defaultFeelerInterval = time.Minute * 2
 )
 
 // ConnState represents the state of the requested connection.
@@ -53,13 +57,20 @@ const (
 )
 
 // ConnReq is the connection request to a network address. If permanent, the
-// connection will be retried on disconnection.
+// connection will be retried on disconnection.  A feeler connection is a short
+// lived connection which only tests whether the address is reachable.  It does
+// not count toward the target number of outbound connections and is neither
+// retried nor replaced.  A block-relay-only connection is only used to relay
+// blocks, which makes it harder to observe and thus to eclipse.  It counts
+// toward the separate target number of block-relay-only connections.
 type ConnReq struct {
 	// The following variables must only be used atomically.
 	id uint64
 
-	Addr      net.Addr
-	Permanent bool
+	Addr           net.Addr
+	Permanent      bool
+	Feeler         bool
+	BlockRelayOnly bool
 
 	conn       net.Conn
 	state      ConnState
@@ -124,6 +135,12 @@ type Config struct {
 	// maintain. Defaults to 8.
 	TargetOutbound uint32
 
+	// TargetBlockRelayOnly is the number of outbound block-relay-only
+	// network connections to maintain in addition to TargetOutbound.  They
+	// are made with addresses from GetNewAddress as well.  Defaults to 0,
+	// which disables them.
+	TargetBlockRelayOnly uint32
+
 	// RetryDuration is the duration to wait before retrying connection
 	// requests. Defaults to 5s.
 	RetryDuration time.Duration
@@ -140,6 +157,16 @@ type Config struct {
 	// to.  If nil, no new connections will be made automatically.
 	GetNewAddress func() (net.Addr, error)
 
+	// GetFeelerAddress is a way to get an address to make a feeler
+	// connection to.  Feeler connections are only made while the target
+	// number of outbound connections is reached.  If nil, no feeler
+	// connections will be made.
+	GetFeelerAddress func() (net.Addr, error)
+
+	// FeelerInterval is the interval between feeler connections.  Defaults
+	// to 2 minutes.
+	FeelerInterval time.Duration
+
 	// Dial connects to the address on the named network. It cannot be nil.
 	Dial func(net.Addr) (net.Conn, error)
 }
@@ -198,7 +225,7 @@ type ConnManager struct {
 // After maxFailedConnectionAttempts new connections will be retried after the
 // configured retry duration.
 func (cm *ConnManager) handleFailedConn(c *ConnReq) {
-	if atomic.LoadInt32(&cm.stop) != 0 {
+	if atomic.LoadInt32(&cm.stop) != 0 || c.Feeler {
 		return
 	}
 	if c.Permanent {
@@ -218,10 +245,10 @@ func (cm *ConnManager) handleFailedConn(c *ConnReq) {
 				"-- retrying connection in: %v", maxFailedAttempts,
 				cm.cfg.RetryDuration)
 			time.AfterFunc(cm.cfg.RetryDuration, func() {
-				cm.NewConnReq()
+				cm.newConnReq(c.BlockRelayOnly)
 			})
 		} else {
-			go cm.NewConnReq()
+			go cm.newConnReq(c.BlockRelayOnly)
 		}
 	}
 }
@@ -241,11 +268,52 @@ func (cm *ConnManager) connHandler() {
 
 		// conns represents the set of all actively connected peers.
 		conns = make(map[uint64]*ConnReq, cm.cfg.TargetOutbound)
+
+		// feelerC triggers feeler connections.  It is nil when
+		// feeler connections are disabled.
+		feelerC <-chan time.Time
 	)
+	if cm.cfg.GetFeelerAddress != nil {
+		feelerTicker := time.NewTicker(cm.cfg.FeelerInterval)
+		defer feelerTicker.Stop()
+		feelerC = feelerTicker.C
+	}
+
+	// numOutbound returns the number of active connections which count
+	// toward the target number of outbound connections.
+	numOutbound := func() uint32 {
+		var n uint32
+		for _, c := range conns {
+			if !c.Feeler && !c.BlockRelayOnly {
+				n++
+			}
+		}
+		return n
+	}
+
+	// numBlockRelayOnly returns the number of active block-relay-only
+	// connections.
+	numBlockRelayOnly := func() uint32 {
+		var n uint32
+		for _, c := range conns {
+			if c.BlockRelayOnly {
+				n++
+			}
+		}
+		return n
+	}
 
 out:
 	for {
 		select {
+		case <-feelerC:
+			// Feeler connections are only made once the target
+			// number of outbound connections is reached so they do
+			// not delay regular connections.
+			if numOutbound() >= cm.cfg.TargetOutbound {
+				go cm.newFeelerReq()
+			}
+
 		case req := <-cm.requests:
 			switch msg := req.(type) {
 
@@ -334,21 +402,26 @@ out:
 				}
 
 				// All internal state has been cleaned up, if
-				// this connection is being removed, we will
-				// make no further attempts with this request.
-				if !msg.retry {
+				// this connection is being removed or is a
+				// feeler connection, we will make no further
+				// attempts with this request.
+				if !msg.retry || connReq.Feeler {
 					connReq.updateState(ConnDisconnected)
 					continue
 				}
 
 				// Otherwise, we will attempt a reconnection if
-				// we do not have enough peers, or if this is a
-				// persistent peer. The connection request is
-				// re added to the pending map, so that
-				// subsequent processing of connections and
+				// we do not have enough peers of its kind, or
+				// if this is a persistent peer. The connection
+				// request is re added to the pending map, so
+				// that subsequent processing of connections and
 				// failures do not ignore the request.
-				if uint32(len(conns)) < cm.cfg.TargetOutbound ||
-					connReq.Permanent {
+				needMore := numOutbound() < cm.cfg.TargetOutbound
+				if connReq.BlockRelayOnly {
+					needMore = numBlockRelayOnly() <
+						cm.cfg.TargetBlockRelayOnly
+				}
+				if needMore || connReq.Permanent {
 
 					connReq.updateState(ConnPending)
 					log.Debugf("Reconnecting to %v",
@@ -387,6 +460,18 @@ out:
 // NewConnReq creates a new connection request and connects to the
 // corresponding address.
 func (cm *ConnManager) NewConnReq() {
+	cm.newConnReq(false)
+}
+
+// NewBlockRelayOnlyConnReq creates a new block-relay-only connection request
+// and connects to the corresponding address.
+func (cm *ConnManager) NewBlockRelayOnlyConnReq() {
+	cm.newConnReq(true)
+}
+
+// newConnReq creates a new connection request, which is block-relay-only when
+// the passed flag is set, and connects to the corresponding address.
+func (cm *ConnManager) newConnReq(blockRelayOnly bool) {
 	if atomic.LoadInt32(&cm.stop) != 0 {
 		return
 	}
@@ -394,7 +479,7 @@ func (cm *ConnManager) NewConnReq() {
 		return
 	}
 
-	c := &ConnReq{}
+	c := &ConnReq{BlockRelayOnly: blockRelayOnly}
 	atomic.StoreUint64(&c.id, atomic.AddUint64(&cm.connReqCount, 1))
 
 	// Submit a request of a pending connection attempt to the connection
@@ -445,6 +530,37 @@ func (cm *ConnManager) NewConnReq() {
 	cm.Connect(c)
 }
 
+// newFeelerReq creates a new feeler connection request and connects to the
+// corresponding address.
+func (cm *ConnManager) newFeelerReq() {
+	if atomic.LoadInt32(&cm.stop) != 0 {
+		return
+	}
+
+	addr, err := cm.cfg.GetFeelerAddress()
+	if err != nil {
+		log.Debugf("No feeler address: %v", err)
+		return
+	}
+
+	resp := make(chan bool)
+	select {
+	case cm.requests <- checkDuplicate{addr: addr, resp: resp}:
+	case <-cm.quit:
+		return
+	}
+	select {
+	case dup := <-resp:
+		if dup {
+			return
+		}
+	case <-cm.quit:
+		return
+	}
+
+	cm.Connect(&ConnReq{Addr: addr, Feeler: true})
+}
+
 // Connect assigns an id and dials a connection to the address of the
 // connection request.
 func (cm *ConnManager) Connect(c *ConnReq) {
@@ -572,6 +688,9 @@ func (cm *ConnManager) Start() {
 	for i := atomic.LoadUint64(&cm.connReqCount); i < uint64(cm.cfg.TargetOutbound); i++ {
 		go cm.NewConnReq()
 	}
+	for i := uint32(0); i < cm.cfg.TargetBlockRelayOnly; i++ {
+		go cm.NewBlockRelayOnlyConnReq()
+	}
 }
 
 // Wait blocks until the connection manager halts gracefully.
@@ -611,6 +730,9 @@ func New(cfg *Config) (*ConnManager, error) {
 	if cfg.TargetOutbound == 0 {
 		cfg.TargetOutbound = defaultTargetOutbound
 	}
+	if cfg.FeelerInterval <= 0 {
+		cfg.FeelerInterval = defaultFeelerInterval
+	}
 	cm := ConnManager{
 		cfg:      *cfg, // Copy so caller can't mutate
 		requests: make(chan interface{}),
@@ -218,6 +218,141 @@ func TestTargetOutbound(t *testing.T) {
 	cmgr.Stop()
 }
 
+// TestBlockRelayOnly tests that block-relay-only connections are maintained in
+// addition to the target number of outbound connections and that disconnected
+// block-relay-only connections are replaced by block-relay-only connections.
+func TestBlockRelayOnly(t *testing.T) {
+	targetOutbound := uint32(3)
+	targetBlockRelayOnly := uint32(2)
+	connected := make(chan *ConnReq)
+	port := 18555
+	cmgr, err := New(&Config{
+		TargetOutbound:       targetOutbound,
+		TargetBlockRelayOnly: targetBlockRelayOnly,
+		Dial:                 mockDialer,
+		GetNewAddress: func() (net.Addr, error) {
+			port++
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.1"),
+				Port: port,
+			}, nil
+		},
+		OnConnection: func(c *ConnReq, _ net.Conn) {
+			connected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+	defer cmgr.Stop()
+
+	var numOutbound, numBlockRelayOnly uint32
+	var blockRelayOnly *ConnReq
+	for i := uint32(0); i < targetOutbound+targetBlockRelayOnly; i++ {
+		c := <-connected
+		if c.BlockRelayOnly {
+			numBlockRelayOnly++
+			blockRelayOnly = c
+		} else {
+			numOutbound++
+		}
+	}
+	if numOutbound != targetOutbound {
+		t.Fatalf("outbound connections: got %d, want %d", numOutbound,
+			targetOutbound)
+	}
+	if numBlockRelayOnly != targetBlockRelayOnly {
+		t.Fatalf("block-relay-only connections: got %d, want %d",
+			numBlockRelayOnly, targetBlockRelayOnly)
+	}
+
+	select {
+	case c := <-connected:
+		t.Fatalf("got unexpected connection - %v", c.Addr)
+	case <-time.After(time.Millisecond):
+	}
+
+	// Disconnecting a block-relay-only connection must lead to a new
+	// block-relay-only connection.
+	cmgr.Disconnect(blockRelayOnly.ID())
+	select {
+	case c := <-connected:
+		if !c.BlockRelayOnly {
+			t.Fatalf("block-relay-only connection was replaced by "+
+				"regular connection %v", c.Addr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("block-relay-only connection was not replaced")
+	}
+}
+
+// TestFeelerConnections tests that feeler connections are made once the target
+// number of outbound connections is reached and that disconnected feeler
+// connections are not replaced.
+func TestFeelerConnections(t *testing.T) {
+	targetOutbound := uint32(2)
+	connected := make(chan *ConnReq)
+	port := 18555
+	feelerPort := 18655
+	cmgr, err := New(&Config{
+		TargetOutbound: targetOutbound,
+		FeelerInterval: 10 * time.Millisecond,
+		Dial:           mockDialer,
+		GetNewAddress: func() (net.Addr, error) {
+			port++
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.1"),
+				Port: port,
+			}, nil
+		},
+		GetFeelerAddress: func() (net.Addr, error) {
+			feelerPort++
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.1"),
+				Port: feelerPort,
+			}, nil
+		},
+		OnConnection: func(c *ConnReq, _ net.Conn) {
+			connected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+	defer cmgr.Stop()
+
+	var feeler *ConnReq
+	for feeler == nil {
+		select {
+		case c := <-connected:
+			if c.Feeler {
+				feeler = c
+			}
+		case <-time.After(time.Second):
+			t.Fatal("feeler connection was not made")
+		}
+	}
+
+	// Disconnecting the feeler connection must not lead to a new regular
+	// connection.
+	cmgr.Disconnect(feeler.ID())
+	timeout := time.After(50 * time.Millisecond)
+	for {
+		select {
+		case c := <-connected:
+			if !c.Feeler {
+				t.Fatalf("feeler connection was replaced by %v",
+					c.Addr)
+			}
+			cmgr.Disconnect(c.ID())
+		case <-timeout:
+			return
+		}
+	}
+}
+
 // TestRetryPermanent tests that permanent connection requests are retried.
 //
 // We make a permanent connection request using Connect, disconnect it using
@@ -0,0 +1,142 @@
+package connmgr
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits the rate of a byte stream with a token bucket.  The
+// bucket holds up to one second worth of bytes, which allows short bursts
+// above the rate.  A single rate limiter may be shared by many connections to
+// limit their combined rate.
+type RateLimiter struct {
+	mtx    sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a new rate limiter which limits the rate to the
+// passed number of bytes per second.
+func NewRateLimiter(bytesPerSec uint64) *RateLimiter {
+	return &RateLimiter{
+		rate:   float64(bytesPerSec),
+		tokens: float64(bytesPerSec),
+		last:   time.Now(),
+	}
+}
+
+// Burst returns the maximum number of bytes which may be transferred at once
+// without waiting.
+func (r *RateLimiter) Burst() int {
+	return int(r.rate)
+}
+
+// reserve takes the passed number of bytes from the bucket and returns how long
+// the caller must wait before transferring them.
+func (r *RateLimiter) reserve(n int) time.Duration {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	now := time.Now()
+	r.tokens += now.Sub(r.last).Seconds() * r.rate
+	if r.tokens > r.rate {
+		r.tokens = r.rate
+	}
+	r.last = now
+	r.tokens -= float64(n)
+	if r.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-r.tokens / r.rate * float64(time.Second))
+}
+
+// Wait blocks until the passed number of bytes may be transferred.
+func (r *RateLimiter) Wait(n int) {
+	if d := r.reserve(n); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// rateLimitedConn is a net.Conn which limits the rate of reads and writes.
+type rateLimitedConn struct {
+	net.Conn
+	readLimiters  []*RateLimiter
+	writeLimiters []*RateLimiter
+	chunk         int
+}
+
+// NewRateLimitedConn returns a connection which limits the rate of reads and
+// writes on the passed connection with the passed rate limiters.  Nil rate
+// limiters are ignored, and the connection is returned as is when there are no
+// rate limiters.
+func NewRateLimitedConn(conn net.Conn, readLimiters, writeLimiters []*RateLimiter) net.Conn {
+	c := &rateLimitedConn{Conn: conn}
+	for _, r := range readLimiters {
+		if r != nil {
+			c.readLimiters = append(c.readLimiters, r)
+		}
+	}
+	for _, r := range writeLimiters {
+		if r != nil {
+			c.writeLimiters = append(c.writeLimiters, r)
+		}
+	}
+	if len(c.readLimiters) == 0 && len(c.writeLimiters) == 0 {
+		return conn
+	}
+
+	// Transfer in chunks no larger than the smallest burst so that large
+	// messages are spread evenly over time.
+	for _, limiters := range [][]*RateLimiter{c.readLimiters, c.writeLimiters} {
+		for _, r := range limiters {
+			if c.chunk == 0 || r.Burst() < c.chunk {
+				c.chunk = r.Burst()
+			}
+		}
+	}
+	if c.chunk < 1 {
+		c.chunk = 1
+	}
+	return c
+}
+
+// Read reads data from the connection at the limited rate.
+func (c *rateLimitedConn) Read(b []byte) (int, error) {
+	if len(c.readLimiters) == 0 {
+		return c.Conn.Read(b)
+	}
+	if len(b) > c.chunk {
+		b = b[:c.chunk]
+	}
+	n, err := c.Conn.Read(b)
+	for _, r := range c.readLimiters {
+		r.Wait(n)
+	}
+	return n, err
+}
+
+// Write writes data to the connection at the limited rate.
+func (c *rateLimitedConn) Write(b []byte) (int, error) {
+	if len(c.writeLimiters) == 0 {
+		return c.Conn.Write(b)
+	}
+	var written int
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > c.chunk {
+			chunk = chunk[:c.chunk]
+		}
+		for _, r := range c.writeLimiters {
+			r.Wait(len(chunk))
+		}
+		n, err := c.Conn.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
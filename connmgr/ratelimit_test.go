@@ -0,0 +1,73 @@
+package connmgr
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestRateLimiter ensures the rate limiter allows a burst of one second and
+// delays transfers beyond it.
+func TestRateLimiter(t *testing.T) {
+	r := NewRateLimiter(1000)
+	if burst := r.Burst(); burst != 1000 {
+		t.Fatalf("Burst: got %d, want 1000", burst)
+	}
+	if d := r.reserve(1000); d != 0 {
+		t.Fatalf("reserve: got delay %v within burst, want 0", d)
+	}
+	d := r.reserve(500)
+	if d < 400*time.Millisecond || d > 500*time.Millisecond {
+		t.Fatalf("reserve: got delay %v beyond burst, want ~500ms", d)
+	}
+}
+
+// TestRateLimitedConn ensures rate limited connections transfer data intact
+// and are only wrapped when there are rate limiters.
+func TestRateLimitedConn(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	if conn := NewRateLimitedConn(c1, nil, []*RateLimiter{nil}); conn != c1 {
+		t.Fatal("NewRateLimitedConn: connection without rate limiters " +
+			"was wrapped")
+	}
+
+	// The data is larger than the burst to exercise writing in chunks.
+	limited := NewRateLimitedConn(c1, []*RateLimiter{NewRateLimiter(1 << 20)},
+		[]*RateLimiter{NewRateLimiter(1 << 20), nil})
+	data := bytes.Repeat([]byte{0x5a}, 3<<19)
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := limited.Write(data)
+		errChan <- err
+	}()
+	got := make([]byte, len(data))
+	if _, err := io.ReadFull(c2, got); err != nil {
+		t.Fatalf("ReadFull: %v", err)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("Write: data mismatch")
+	}
+
+	go func() {
+		_, err := c2.Write(data[:100])
+		errChan <- err
+	}()
+	got = make([]byte, 100)
+	if _, err := io.ReadFull(limited, got); err != nil {
+		t.Fatalf("ReadFull: %v", err)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if !bytes.Equal(got, data[:100]) {
+		t.Fatal("Read: data mismatch")
+	}
+}
@@ -5,7 +5,11 @@
 package connmgr
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"net"
 )
@@ -127,3 +131,31 @@ func TorLookupIP(host, proxy string) ([]net.IP, error) {
 
 	return addr, nil
 }
+
+// TorStreamIsolator derives SOCKS5 proxy credentials which isolate the Tor
+// streams of connections to different peers.  Tor builds a separate circuit
+// for each distinct set of credentials, so connections to the same peer share
+// a circuit while connections to different peers never do.  The credentials
+// are keyed by a random secret so they do not reveal the peer address to the
+// proxy.
+type TorStreamIsolator struct {
+	key [32]byte
+}
+
+// NewTorStreamIsolator returns a stream isolator with a new random secret.
+func NewTorStreamIsolator() (*TorStreamIsolator, error) {
+	var s TorStreamIsolator
+	if _, err := rand.Read(s.key[:]); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Credentials returns the SOCKS5 username and password to use for
+// connections to the passed peer address.
+func (s *TorStreamIsolator) Credentials(addr string) (string, string) {
+	mac := hmac.New(sha256.New, s.key[:])
+	mac.Write([]byte(addr))
+	sum := mac.Sum(nil)
+	return hex.EncodeToString(sum[:16]), hex.EncodeToString(sum[16:])
+}
@@ -0,0 +1,310 @@
+package connmgr
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// torControlTimeout is the timeout of a single request to the Tor
+	// control port.
+	torControlTimeout = 30 * time.Second
+
+	// torSafeCookieServerKey and torSafeCookieClientKey are the HMAC keys
+	// of the SAFECOOKIE authentication method.
+	torSafeCookieServerKey = "Tor safe cookie authentication server-to-controller hash"
+	torSafeCookieClientKey = "Tor safe cookie authentication controller-to-server hash"
+
+	// torCookieLen is the length of the Tor authentication cookie.
+	torCookieLen = 32
+
+	// OnionV3KeyType is the key type of v3 onion services in the private
+	// keys returned by TorController.AddOnion.
+	OnionV3KeyType = "ED25519-V3"
+)
+
+var (
+	// ErrTorNoAuthMethod indicates none of the authentication methods
+	// offered by the Tor control port can be used.
+	ErrTorNoAuthMethod = errors.New("no supported tor control " +
+		"authentication method")
+)
+
+// TorControlError describes an error reply of the Tor control port.
+type TorControlError struct {
+	Code    int
+	Message string
+}
+
+// Error returns the error reply as a human-readable string.
+func (e TorControlError) Error() string {
+	return fmt.Sprintf("tor control error %d: %s", e.Code, e.Message)
+}
+
+// TorController is a client of the Tor control protocol which is used to
+// create onion services.  Onion services created with AddOnion are removed by
+// Tor when the controller is closed.
+type TorController struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// DialTorControl connects to the Tor control port at the passed address.
+func DialTorControl(addr string) (*TorController, error) {
+	conn, err := net.DialTimeout("tcp", addr, torControlTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &TorController{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+	}, nil
+}
+
+// Close closes the connection to the control port.
+func (c *TorController) Close() error {
+	return c.conn.Close()
+}
+
+// Wait blocks until the connection to the control port is closed and returns
+// the error which closed it.  Asynchronous events sent by the control port are
+// discarded.  No requests may be made while waiting.
+func (c *TorController) Wait() error {
+	for {
+		if _, err := c.reader.ReadString('\n'); err != nil {
+			return err
+		}
+	}
+}
+
+// request sends the passed command and returns the lines of a successful
+// reply with the status code and separator removed.
+func (c *TorController) request(cmd string) ([]string, error) {
+	c.conn.SetDeadline(time.Now().Add(torControlTimeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	if _, err := c.conn.Write([]byte(cmd + "\r\n")); err != nil {
+		return nil, err
+	}
+
+	// A reply consists of lines which start with a three digit status code
+	// followed by a separator.  All lines but the last one are separated
+	// by '-', or by '+' when followed by a data block terminated by a line
+	// containing a single period.
+	var lines []string
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) < 4 {
+			return nil, fmt.Errorf("malformed tor control reply %q", line)
+		}
+		code, err := strconv.Atoi(line[:3])
+		if err != nil {
+			return nil, fmt.Errorf("malformed tor control reply %q", line)
+		}
+		if code != 250 {
+			return nil, TorControlError{Code: code, Message: line[4:]}
+		}
+		lines = append(lines, line[4:])
+
+		switch line[3] {
+		case ' ':
+			return lines, nil
+		case '+':
+			for {
+				data, err := c.reader.ReadString('\n')
+				if err != nil {
+					return nil, err
+				}
+				if strings.TrimRight(data, "\r\n") == "." {
+					break
+				}
+			}
+		}
+	}
+}
+
+// parseReplyArgs parses the space separated KEY=VALUE arguments of a reply
+// line.  Quoted values are unquoted.
+func parseReplyArgs(line string) map[string]string {
+	args := make(map[string]string)
+	for len(line) > 0 {
+		line = strings.TrimLeft(line, " ")
+		eq := strings.IndexAny(line, "= ")
+		if eq == -1 || line[eq] == ' ' {
+			// An argument without a value.
+			if eq == -1 {
+				eq = len(line)
+			}
+			args[line[:eq]] = ""
+			line = line[eq:]
+			continue
+		}
+		key := line[:eq]
+		line = line[eq+1:]
+		if strings.HasPrefix(line, "\"") {
+			value, err := strconv.QuotedPrefix(line)
+			if err == nil {
+				line = line[len(value):]
+				if unquoted, err := strconv.Unquote(value); err == nil {
+					value = unquoted
+				}
+				args[key] = value
+				continue
+			}
+		}
+		end := strings.IndexByte(line, ' ')
+		if end == -1 {
+			end = len(line)
+		}
+		args[key] = line[:end]
+		line = line[end:]
+	}
+	return args
+}
+
+// Authenticate authenticates with the control port using the best method it
+// offers.  The password is only used when the control port requires
+// password authentication.
+func (c *TorController) Authenticate(password string) error {
+	lines, err := c.request("PROTOCOLINFO 1")
+	if err != nil {
+		return err
+	}
+	var methods map[string]bool
+	var cookieFile string
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "AUTH ") {
+			continue
+		}
+		args := parseReplyArgs(line[len("AUTH "):])
+		methods = make(map[string]bool)
+		for _, method := range strings.Split(args["METHODS"], ",") {
+			methods[method] = true
+		}
+		cookieFile = args["COOKIEFILE"]
+	}
+
+	switch {
+	case methods["NULL"]:
+		_, err = c.request("AUTHENTICATE")
+	case methods["HASHEDPASSWORD"] && password != "":
+		_, err = c.request("AUTHENTICATE " + strconv.Quote(password))
+	case methods["SAFECOOKIE"] && cookieFile != "":
+		err = c.authenticateSafeCookie(cookieFile)
+	case methods["COOKIE"] && cookieFile != "":
+		var cookie []byte
+		cookie, err = readTorCookie(cookieFile)
+		if err == nil {
+			_, err = c.request("AUTHENTICATE " + hex.EncodeToString(cookie))
+		}
+	case methods["HASHEDPASSWORD"]:
+		err = errors.New("tor control port requires a password")
+	default:
+		err = ErrTorNoAuthMethod
+	}
+	return err
+}
+
+// readTorCookie reads the authentication cookie from the passed file.
+func readTorCookie(cookieFile string) ([]byte, error) {
+	cookie, err := ioutil.ReadFile(cookieFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(cookie) != torCookieLen {
+		return nil, fmt.Errorf("tor cookie file %s has length %d, "+
+			"expected %d", cookieFile, len(cookie), torCookieLen)
+	}
+	return cookie, nil
+}
+
+// authenticateSafeCookie authenticates using the SAFECOOKIE method, which
+// proves knowledge of the cookie without revealing it and verifies that the
+// control port knows it as well.
+func (c *TorController) authenticateSafeCookie(cookieFile string) error {
+	cookie, err := readTorCookie(cookieFile)
+	if err != nil {
+		return err
+	}
+	clientNonce := make([]byte, 32)
+	if _, err := rand.Read(clientNonce); err != nil {
+		return err
+	}
+	lines, err := c.request("AUTHCHALLENGE SAFECOOKIE " +
+		hex.EncodeToString(clientNonce))
+	if err != nil {
+		return err
+	}
+	args := parseReplyArgs(strings.TrimPrefix(lines[0], "AUTHCHALLENGE "))
+	serverHash, err := hex.DecodeString(args["SERVERHASH"])
+	if err != nil {
+		return errors.New("invalid tor control server hash")
+	}
+	serverNonce, err := hex.DecodeString(args["SERVERNONCE"])
+	if err != nil {
+		return errors.New("invalid tor control server nonce")
+	}
+
+	msg := make([]byte, 0, len(cookie)+len(clientNonce)+len(serverNonce))
+	msg = append(msg, cookie...)
+	msg = append(msg, clientNonce...)
+	msg = append(msg, serverNonce...)
+	if !hmac.Equal(serverHash, torSafeCookieHash(torSafeCookieServerKey, msg)) {
+		return errors.New("tor control server hash mismatch")
+	}
+	clientHash := torSafeCookieHash(torSafeCookieClientKey, msg)
+	_, err = c.request("AUTHENTICATE " + hex.EncodeToString(clientHash))
+	return err
+}
+
+// torSafeCookieHash returns the HMAC-SHA256 of the passed message.
+func torSafeCookieHash(key string, msg []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(msg)
+	return mac.Sum(nil)
+}
+
+// AddOnion creates a v3 onion service which forwards connections to the
+// passed virtual port to the passed target address.  A new key is created
+// when the passed private key is empty.  It returns the onion address of the
+// service, without port, and the private key of the service, which may be
+// passed to a later call to recreate the service with the same address.
+func (c *TorController) AddOnion(privateKey string, virtPort uint16, target string) (string, string, error) {
+	key := "NEW:" + OnionV3KeyType
+	if privateKey != "" {
+		key = privateKey
+	}
+	lines, err := c.request(fmt.Sprintf("ADD_ONION %s Port=%d,%s", key,
+		virtPort, target))
+	if err != nil {
+		return "", "", err
+	}
+	var serviceID string
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "ServiceID="):
+			serviceID = strings.TrimPrefix(line, "ServiceID=")
+		case strings.HasPrefix(line, "PrivateKey="):
+			privateKey = strings.TrimPrefix(line, "PrivateKey=")
+		}
+	}
+	if serviceID == "" {
+		return "", "", errors.New("tor control reply is missing the " +
+			"service id")
+	}
+	return serviceID + ".onion", privateKey, nil
+}
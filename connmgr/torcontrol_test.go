@@ -0,0 +1,165 @@
+package connmgr
+
+import (
+	"bufio"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeTorControl serves a single Tor control connection on a local listener
+// which offers the SAFECOOKIE authentication method with the passed cookie
+// file and creates onion services.  The commands received are sent on the
+// returned channel.
+func fakeTorControl(t *testing.T, cookie []byte, cookieFile string) (string, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	cmds := make(chan string, 10)
+	go func() {
+		defer listener.Close()
+		defer close(cmds)
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		serverNonce := make([]byte, 32)
+		var clientNonce []byte
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimRight(line, "\r\n")
+			cmds <- cmd
+			switch {
+			case cmd == "PROTOCOLINFO 1":
+				conn.Write([]byte("250-PROTOCOLINFO 1\r\n" +
+					"250-AUTH METHODS=COOKIE,SAFECOOKIE COOKIEFILE=\"" +
+					cookieFile + "\"\r\n" +
+					"250-VERSION Tor=\"0.4.8.9\"\r\n" +
+					"250 OK\r\n"))
+			case strings.HasPrefix(cmd, "AUTHCHALLENGE SAFECOOKIE "):
+				clientNonce, _ = hex.DecodeString(cmd[len("AUTHCHALLENGE SAFECOOKIE "):])
+				msg := append(append(append([]byte{}, cookie...),
+					clientNonce...), serverNonce...)
+				conn.Write([]byte("250 AUTHCHALLENGE SERVERHASH=" +
+					hex.EncodeToString(torSafeCookieHash(torSafeCookieServerKey, msg)) +
+					" SERVERNONCE=" + hex.EncodeToString(serverNonce) + "\r\n"))
+			case strings.HasPrefix(cmd, "AUTHENTICATE "):
+				msg := append(append(append([]byte{}, cookie...),
+					clientNonce...), serverNonce...)
+				want := hex.EncodeToString(torSafeCookieHash(torSafeCookieClientKey, msg))
+				if cmd[len("AUTHENTICATE "):] != want {
+					conn.Write([]byte("515 Authentication failed\r\n"))
+					continue
+				}
+				conn.Write([]byte("250 OK\r\n"))
+			case strings.HasPrefix(cmd, "ADD_ONION NEW:ED25519-V3 "):
+				conn.Write([]byte("250-ServiceID=exampleonion\r\n" +
+					"250-PrivateKey=ED25519-V3:secret\r\n" +
+					"250 OK\r\n"))
+			case strings.HasPrefix(cmd, "ADD_ONION "):
+				conn.Write([]byte("250-ServiceID=exampleonion\r\n" +
+					"250 OK\r\n"))
+			default:
+				conn.Write([]byte("510 Unrecognized command\r\n"))
+			}
+		}
+	}()
+	return listener.Addr().String(), cmds
+}
+
+// TestTorController ensures the Tor controller authenticates with the
+// SAFECOOKIE method and creates onion services.
+func TestTorController(t *testing.T) {
+	dir, err := ioutil.TempDir("", "torcontrol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cookie := make([]byte, torCookieLen)
+	for i := range cookie {
+		cookie[i] = byte(i)
+	}
+	cookieFile := filepath.Join(dir, "control_auth_cookie")
+	if err := ioutil.WriteFile(cookieFile, cookie, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	addr, cmds := fakeTorControl(t, cookie, cookieFile)
+	ctrl, err := DialTorControl(addr)
+	if err != nil {
+		t.Fatalf("DialTorControl: %v", err)
+	}
+	defer ctrl.Close()
+
+	if err := ctrl.Authenticate(""); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	onion, key, err := ctrl.AddOnion("", 8333, "127.0.0.1:8333")
+	if err != nil {
+		t.Fatalf("AddOnion: %v", err)
+	}
+	if onion != "exampleonion.onion" || key != "ED25519-V3:secret" {
+		t.Fatalf("unexpected onion service %s with key %s", onion, key)
+	}
+	onion, key, err = ctrl.AddOnion(key, 8333, "127.0.0.1:8333")
+	if err != nil {
+		t.Fatalf("AddOnion: %v", err)
+	}
+	if onion != "exampleonion.onion" || key != "ED25519-V3:secret" {
+		t.Fatalf("unexpected onion service %s with key %s", onion, key)
+	}
+	ctrl.Close()
+	var got []string
+	for cmd := range cmds {
+		got = append(got, strings.Fields(cmd)[0])
+	}
+	want := []string{"PROTOCOLINFO", "AUTHCHALLENGE", "AUTHENTICATE",
+		"ADD_ONION", "ADD_ONION"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected commands %v, want %v", got, want)
+	}
+}
+
+// TestParseReplyArgs ensures the arguments of Tor control replies are parsed
+// as expected.
+func TestParseReplyArgs(t *testing.T) {
+	got := parseReplyArgs(`METHODS=COOKIE,SAFECOOKIE COOKIEFILE="/var/lib/tor/control \"auth\" cookie" FLAG`)
+	want := map[string]string{
+		"METHODS":    "COOKIE,SAFECOOKIE",
+		"COOKIEFILE": `/var/lib/tor/control "auth" cookie`,
+		"FLAG":       "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected arguments %v, want %v", got, want)
+	}
+}
+
+// TestTorStreamIsolator ensures stream isolation credentials are stable for
+// a peer and distinct between peers.
+func TestTorStreamIsolator(t *testing.T) {
+	isolator, err := NewTorStreamIsolator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user1, pass1 := isolator.Credentials("example.onion:8333")
+	user2, pass2 := isolator.Credentials("example.onion:8333")
+	if user1 != user2 || pass1 != pass2 {
+		t.Fatal("credentials of the same peer differ")
+	}
+	user3, pass3 := isolator.Credentials("1.2.3.4:8333")
+	if user1 == user3 || pass1 == pass3 {
+		t.Fatal("credentials of different peers are equal")
+	}
+}
@@ -0,0 +1,104 @@
+package connmgr
+
+import (
+	"sync"
+	"time"
+)
+
+// UploadTarget tracks the bytes sent within a cycle of a fixed timeframe
+// against a target.  Once the target is close to being reached, serving data
+// which is not needed to stay in sync with the network, such as historical
+// blocks, should be refused so the remaining bytes are left for relaying new
+// blocks and transactions.
+type UploadTarget struct {
+	mtx        sync.Mutex
+	target     uint64
+	reserve    uint64
+	timeframe  time.Duration
+	cycleStart time.Time
+	sent       uint64
+}
+
+// NewUploadTarget returns a new upload target of the passed number of bytes
+// per timeframe.  Historical data is only served while more than the passed
+// reserve of bytes is left in the cycle.  A target of zero disables the
+// target.
+func NewUploadTarget(target, reserve uint64, timeframe time.Duration) *UploadTarget {
+	return &UploadTarget{
+		target:     target,
+		reserve:    reserve,
+		timeframe:  timeframe,
+		cycleStart: time.Now(),
+	}
+}
+
+// maybeStartCycle starts a new cycle when the current one is over.
+//
+// This function MUST be called with the upload target lock held.
+func (u *UploadTarget) maybeStartCycle(now time.Time) {
+	if now.Sub(u.cycleStart) >= u.timeframe {
+		u.cycleStart = now
+		u.sent = 0
+	}
+}
+
+// AddBytes adds the passed number of bytes to the bytes sent in the current
+// cycle.
+func (u *UploadTarget) AddBytes(n uint64) {
+	u.mtx.Lock()
+	u.maybeStartCycle(time.Now())
+	u.sent += n
+	u.mtx.Unlock()
+}
+
+// Target returns the target number of bytes per timeframe, or zero when the
+// target is disabled.
+func (u *UploadTarget) Target() uint64 {
+	return u.target
+}
+
+// Timeframe returns the length of a cycle.
+func (u *UploadTarget) Timeframe() time.Duration {
+	return u.timeframe
+}
+
+// Reached returns whether the target is reached.
+func (u *UploadTarget) Reached() bool {
+	return u.target != 0 && u.BytesLeft() == 0
+}
+
+// ServeHistorical returns whether historical data may still be served, which
+// is the case while more than the reserve is left in the cycle.
+func (u *UploadTarget) ServeHistorical() bool {
+	return u.target == 0 || u.BytesLeft() > u.reserve
+}
+
+// BytesLeft returns the number of bytes left in the current cycle, or zero
+// when the target is disabled.
+func (u *UploadTarget) BytesLeft() uint64 {
+	if u.target == 0 {
+		return 0
+	}
+
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	u.maybeStartCycle(time.Now())
+	if u.sent >= u.target {
+		return 0
+	}
+	return u.target - u.sent
+}
+
+// TimeLeftInCycle returns the time left until the next cycle starts, or zero
+// when the target is disabled.
+func (u *UploadTarget) TimeLeftInCycle() time.Duration {
+	if u.target == 0 {
+		return 0
+	}
+
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	now := time.Now()
+	u.maybeStartCycle(now)
+	return u.cycleStart.Add(u.timeframe).Sub(now)
+}
@@ -0,0 +1,46 @@
+package connmgr
+
+import (
+	"testing"
+	"time"
+)
+
+// TestUploadTarget ensures the upload target tracks the bytes sent against
+// the target and starts a new cycle after the timeframe.
+func TestUploadTarget(t *testing.T) {
+	// A disabled target always serves historical data.
+	u := NewUploadTarget(0, 0, time.Hour)
+	u.AddBytes(1 << 30)
+	if u.Reached() || !u.ServeHistorical() || u.BytesLeft() != 0 ||
+		u.TimeLeftInCycle() != 0 {
+		t.Fatal("disabled upload target is not disabled")
+	}
+
+	u = NewUploadTarget(1000, 250, time.Hour)
+	u.AddBytes(700)
+	if u.Reached() || !u.ServeHistorical() {
+		t.Fatal("upload target reached too early")
+	}
+	if left := u.BytesLeft(); left != 300 {
+		t.Fatalf("BytesLeft: got %d, want 300", left)
+	}
+	u.AddBytes(50)
+	if u.Reached() || u.ServeHistorical() {
+		t.Fatal("historical data served within reserve")
+	}
+	u.AddBytes(500)
+	if !u.Reached() || u.BytesLeft() != 0 {
+		t.Fatal("upload target not reached")
+	}
+	if left := u.TimeLeftInCycle(); left <= 0 || left > time.Hour {
+		t.Fatalf("TimeLeftInCycle: got %v", left)
+	}
+
+	// Start a new cycle once the timeframe is over.
+	u.mtx.Lock()
+	u.cycleStart = u.cycleStart.Add(-time.Hour)
+	u.mtx.Unlock()
+	if u.Reached() || !u.ServeHistorical() || u.BytesLeft() != 1000 {
+		t.Fatal("upload target not reset after timeframe")
+	}
+}
@@ -0,0 +1,285 @@
+package database
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/gcash/bchd/wire"
+)
+
+const (
+	// backupVersion is the current version of the backup archive format.
+	backupVersion = 1
+
+	// maxBackupValueSize is the maximum size of a metadata key or value in a
+	// backup archive.  It prevents malformed archives from causing huge
+	// allocations.
+	maxBackupValueSize = 256 * 1024 * 1024
+
+	// maxBackupBlockFiles is the maximum number of block files in the
+	// manifest of a backup archive.
+	maxBackupBlockFiles = 1 << 20
+)
+
+// Record types which make up a backup archive after its header.
+const (
+	backupRecordEnd       byte = 0
+	backupRecordMetadata  byte = 1
+	backupRecordBlockFile byte = 2
+)
+
+// backupMagic identifies a backup archive.
+var backupMagic = [8]byte{'b', 'c', 'h', 'd', 'b', 'k', 'u', 'p'}
+
+// BackupBlockFile describes a flat file holding blocks in a backup.
+type BackupBlockFile struct {
+	// FileNum is the number of the file.
+	FileNum uint32
+
+	// Size is the size of the file in bytes at the time of the snapshot.
+	// Any data past this size was written after the snapshot was taken and
+	// is not part of the backup.
+	Size int64
+}
+
+// BackupWriter receives a consistent snapshot of a database from DB.Backup.
+// The methods are invoked in the order they are declared.
+type BackupWriter interface {
+	// WriteManifest is invoked once, before anything else, with the type
+	// of the database and the block files which are part of the snapshot.
+	WriteManifest(dbType string, files []BackupBlockFile) error
+
+	// WriteMetadata is invoked with every metadata key/value pair of the
+	// snapshot in order of key.  The passed slices are only valid until
+	// the method returns.
+	WriteMetadata(key, value []byte) error
+
+	// WriteBlockFile is invoked with every block file of the manifest
+	// along with a reader of its contents when the backup includes the
+	// blocks.  Otherwise, the block files must be copied separately, which
+	// is safe while the database is in use since they are only appended
+	// to.
+	WriteBlockFile(file BackupBlockFile, r io.Reader) error
+}
+
+// backupArchiveWriter is a BackupWriter which serializes a backup to a stream.
+type backupArchiveWriter struct {
+	w        io.Writer
+	checksum hash.Hash
+	started  bool
+}
+
+// Enforce backupArchiveWriter implements the BackupWriter interface.
+var _ BackupWriter = (*backupArchiveWriter)(nil)
+
+// NewBackupArchiveWriter returns a BackupWriter which serializes the backup it
+// receives to the passed writer.  The archive must be finished by calling
+// FinishBackupArchive once the backup completes, which writes the checksum the
+// archive is verified with when it is read.
+func NewBackupArchiveWriter(w io.Writer) BackupWriter {
+	checksum := sha256.New()
+	return &backupArchiveWriter{
+		w:        io.MultiWriter(w, checksum),
+		checksum: checksum,
+	}
+}
+
+// WriteManifest writes the archive header followed by the manifest.
+//
+// This is part of the BackupWriter interface implementation.
+func (a *backupArchiveWriter) WriteManifest(dbType string, files []BackupBlockFile) error {
+	if a.started {
+		return makeError(ErrInvalid, "manifest already written", nil)
+	}
+	a.started = true
+
+	var buf bytes.Buffer
+	buf.Write(backupMagic[:])
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(backupVersion))
+	_ = wire.WriteVarString(&buf, 0, dbType)
+	_ = wire.WriteVarInt(&buf, 0, uint64(len(files)))
+	for _, file := range files {
+		_ = binary.Write(&buf, binary.LittleEndian, file.FileNum)
+		_ = binary.Write(&buf, binary.LittleEndian, uint64(file.Size))
+	}
+	_, err := a.w.Write(buf.Bytes())
+	return err
+}
+
+// WriteMetadata writes a metadata record.
+//
+// This is part of the BackupWriter interface implementation.
+func (a *backupArchiveWriter) WriteMetadata(key, value []byte) error {
+	var buf bytes.Buffer
+	buf.WriteByte(backupRecordMetadata)
+	_ = wire.WriteVarBytes(&buf, 0, key)
+	_ = wire.WriteVarBytes(&buf, 0, value)
+	_, err := a.w.Write(buf.Bytes())
+	return err
+}
+
+// WriteBlockFile writes a block file record with the contents of the file.
+//
+// This is part of the BackupWriter interface implementation.
+func (a *backupArchiveWriter) WriteBlockFile(file BackupBlockFile, r io.Reader) error {
+	var header [13]byte
+	header[0] = backupRecordBlockFile
+	binary.LittleEndian.PutUint32(header[1:5], file.FileNum)
+	binary.LittleEndian.PutUint64(header[5:13], uint64(file.Size))
+	if _, err := a.w.Write(header[:]); err != nil {
+		return err
+	}
+
+	n, err := io.Copy(a.w, io.LimitReader(r, file.Size))
+	if err != nil {
+		return err
+	}
+	if n != file.Size {
+		str := fmt.Sprintf("block file %d is %d bytes, expected %d",
+			file.FileNum, n, file.Size)
+		return makeError(ErrCorruption, str, nil)
+	}
+	return nil
+}
+
+// FinishBackupArchive writes the end of a backup archive, including the
+// checksum of its contents, to the archive writer returned by
+// NewBackupArchiveWriter.
+func FinishBackupArchive(w BackupWriter) error {
+	a, ok := w.(*backupArchiveWriter)
+	if !ok || !a.started {
+		return makeError(ErrInvalid, "not a started backup archive", nil)
+	}
+
+	if _, err := a.w.Write([]byte{backupRecordEnd}); err != nil {
+		return err
+	}
+	_, err := a.w.Write(a.checksum.Sum(nil))
+	return err
+}
+
+// ReadBackupArchive reads a backup archive written with NewBackupArchiveWriter
+// from the passed reader and replays it to the passed backup writer.  An error
+// is returned when the archive is malformed, incomplete or its checksum does
+// not match, in which case the backup writer may have received part of it.
+func ReadBackupArchive(r io.Reader, w BackupWriter) error {
+	checksum := sha256.New()
+	tr := io.TeeReader(r, checksum)
+
+	corrupt := func(str string) error {
+		return makeError(ErrCorruption, "invalid backup archive: "+str, nil)
+	}
+
+	var magic [8]byte
+	if _, err := io.ReadFull(tr, magic[:]); err != nil {
+		return err
+	}
+	if magic != backupMagic {
+		return corrupt("bad magic")
+	}
+	var version uint32
+	if err := binary.Read(tr, binary.LittleEndian, &version); err != nil {
+		return err
+	}
+	if version != backupVersion {
+		return corrupt(fmt.Sprintf("unsupported version %d", version))
+	}
+
+	dbType, err := wire.ReadVarString(tr, 0)
+	if err != nil {
+		return err
+	}
+	numFiles, err := wire.ReadVarInt(tr, 0)
+	if err != nil {
+		return err
+	}
+	if numFiles > maxBackupBlockFiles {
+		return corrupt(fmt.Sprintf("too many block files (%d)", numFiles))
+	}
+	files := make([]BackupBlockFile, 0, numFiles)
+	manifest := make(map[uint32]int64, numFiles)
+	for i := uint64(0); i < numFiles; i++ {
+		var fileNum uint32
+		var size uint64
+		if err := binary.Read(tr, binary.LittleEndian, &fileNum); err != nil {
+			return err
+		}
+		if err := binary.Read(tr, binary.LittleEndian, &size); err != nil {
+			return err
+		}
+		files = append(files, BackupBlockFile{FileNum: fileNum, Size: int64(size)})
+		manifest[fileNum] = int64(size)
+	}
+	if err := w.WriteManifest(dbType, files); err != nil {
+		return err
+	}
+
+	for {
+		var recordType [1]byte
+		if _, err := io.ReadFull(tr, recordType[:]); err != nil {
+			return err
+		}
+
+		switch recordType[0] {
+		case backupRecordMetadata:
+			key, err := wire.ReadVarBytes(tr, 0, maxBackupValueSize,
+				"key")
+			if err != nil {
+				return err
+			}
+			value, err := wire.ReadVarBytes(tr, 0, maxBackupValueSize,
+				"value")
+			if err != nil {
+				return err
+			}
+			if err := w.WriteMetadata(key, value); err != nil {
+				return err
+			}
+
+		case backupRecordBlockFile:
+			var header [12]byte
+			if _, err := io.ReadFull(tr, header[:]); err != nil {
+				return err
+			}
+			file := BackupBlockFile{
+				FileNum: binary.LittleEndian.Uint32(header[0:4]),
+				Size:    int64(binary.LittleEndian.Uint64(header[4:12])),
+			}
+			if size, ok := manifest[file.FileNum]; !ok || size != file.Size {
+				return corrupt(fmt.Sprintf("block file %d does "+
+					"not match the manifest", file.FileNum))
+			}
+
+			lr := &io.LimitedReader{R: tr, N: file.Size}
+			if err := w.WriteBlockFile(file, lr); err != nil {
+				return err
+			}
+			if _, err := io.Copy(io.Discard, lr); err != nil {
+				return err
+			}
+			if lr.N != 0 {
+				return corrupt(fmt.Sprintf("block file %d is "+
+					"truncated", file.FileNum))
+			}
+
+		case backupRecordEnd:
+			want := checksum.Sum(nil)
+			var got [sha256.Size]byte
+			if _, err := io.ReadFull(r, got[:]); err != nil {
+				return err
+			}
+			if !bytes.Equal(got[:], want) {
+				return corrupt("checksum mismatch")
+			}
+			return nil
+
+		default:
+			return corrupt(fmt.Sprintf("unknown record type %d",
+				recordType[0]))
+		}
+	}
+}
@@ -89,6 +89,13 @@ func realMain() error {
 	parser.AddCommand("fetchblockregion",
 		"Fetch the specified block region from the database", "",
 		&blockRegionCfg)
+	parser.AddCommand("restore",
+		"Restore the block database from a backup",
+		"Restore the block database from a backup taken with the "+
+			"backupchainstate RPC.  Use - to read the backup from "+
+			"stdin.  The block files of a backup which does not "+
+			"include them must be copied to the database directory "+
+			"first.", &restoreCfg)
 
 	// Parse command line and invoke the Execute function for the specified
 	// command.
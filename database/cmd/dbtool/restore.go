@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gcash/bchd/database"
+)
+
+// restoreCmd defines the configuration options for the restore command.
+type restoreCmd struct{}
+
+var (
+	// restoreCfg defines the configuration options for the command.
+	restoreCfg = restoreCmd{}
+)
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *restoreCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	if len(args) < 1 {
+		return errors.New("required backup file parameter not specified")
+	}
+
+	// Read the backup from stdin when requested so it can be piped
+	// directly from the node it is taken from.
+	var r io.Reader = os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	// The database name is based on the database type.
+	dbName := blockDbNamePrefix + "_" + cfg.DbType
+	dbPath := filepath.Join(cfg.DataDir, dbName)
+
+	log.Infof("Restoring block database to '%s'", dbPath)
+	startTime := time.Now()
+	err := database.Restore(cfg.DbType, func(w database.BackupWriter) error {
+		return database.ReadBackupArchive(bufio.NewReader(r), w)
+	}, dbPath, activeNetParams.Net)
+	if err != nil {
+		return err
+	}
+	log.Infof("Restored block database in %v", time.Since(startTime))
+	return nil
+}
+
+// Usage overrides the usage display for the command.
+func (cmd *restoreCmd) Usage() string {
+	return "<backup-file>"
+}
@@ -27,6 +27,12 @@ type Driver struct {
 	// ErrDbDoesNotExist if the database has not already been created.
 	Open func(args ...interface{}) (DB, error)
 
+	// Restore is the function that will be invoked with all user-specified
+	// arguments to create the database from a backup, which is replayed to
+	// the new database by the passed function.  This function must return
+	// ErrDbExists if the database already exists.
+	Restore func(replay func(w BackupWriter) error, args ...interface{}) error
+
 	// UseLogger uses a specified Logger to output package logging info.
 	UseLogger func(logger bchlog.Logger)
 }
@@ -87,3 +93,25 @@ func Open(dbType string, args ...interface{}) (DB, error) {
 
 	return drv.Open(args...)
 }
+
+// Restore creates a database of the specified type from a backup written by
+// DB.Backup, which is replayed to the new database by the passed function, for
+// example by reading a backup archive with ReadBackupArchive.  The arguments
+// are specific to the database type driver and match those of Create.  See the
+// documentation for the database driver for further details.
+//
+// ErrDbUnknownType will be returned if the database type is not registered.
+func Restore(dbType string, replay func(w BackupWriter) error, args ...interface{}) error {
+	drv, exists := drivers[dbType]
+	if !exists {
+		str := fmt.Sprintf("driver %q is not registered", dbType)
+		return makeError(ErrDbUnknownType, str, nil)
+	}
+	if drv.Restore == nil {
+		str := fmt.Sprintf("driver %q does not support restoring "+
+			"backups", dbType)
+		return makeError(ErrInvalid, str, nil)
+	}
+
+	return drv.Restore(replay, args...)
+}
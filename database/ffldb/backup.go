@@ -0,0 +1,312 @@
+package ffldb
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/goleveldb/leveldb/util"
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/wire"
+)
+
+// restoreBatchSize is the size of the metadata written to the metadata store in
+// a single batch while a backup is restored.
+const restoreBatchSize = 16 * 1024 * 1024
+
+// backupBlockFiles returns the block files which are part of a snapshot whose
+// write cursor is at the passed file number and offset.  All files before the
+// current write file are complete, while the data in the current write file
+// past the offset was written after the snapshot was taken.
+func backupBlockFiles(basePath string, curFileNum, curOffset uint32) ([]database.BackupBlockFile, error) {
+	entries, err := os.ReadDir(basePath)
+	if err != nil {
+		str := fmt.Sprintf("failed to read directory %q", basePath)
+		return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+
+	var files []database.BackupBlockFile
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".fdb") {
+			continue
+		}
+		fileNum, err := strconv.ParseUint(strings.TrimSuffix(entry.Name(),
+			".fdb"), 10, 32)
+		if err != nil || uint32(fileNum) > curFileNum {
+			continue
+		}
+
+		size := int64(curOffset)
+		if uint32(fileNum) != curFileNum {
+			info, err := entry.Info()
+			if err != nil {
+				str := fmt.Sprintf("failed to stat %q", entry.Name())
+				return nil, makeDbErr(database.ErrDriverSpecific,
+					str, err)
+			}
+			size = info.Size()
+		}
+		files = append(files, database.BackupBlockFile{
+			FileNum: uint32(fileNum),
+			Size:    size,
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].FileNum < files[j].FileNum
+	})
+	return files, nil
+}
+
+// Backup writes a consistent snapshot of the database to the passed backup
+// writer.  The snapshot consists of all metadata as of a read-only transaction
+// along with the block files up to the write cursor stored in that metadata.
+// Since the block files are only appended to, any blocks stored while the
+// backup is written are beyond the write cursor and are not part of it.
+//
+// NOTE: Pruned block files might be removed while the backup is written, in
+// which case writing the backup fails.
+//
+// This function is part of the database.DB interface implementation.
+func (db *db) Backup(w database.BackupWriter, includeBlocks bool) error {
+	tx, err := db.begin(false)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	writeRow := tx.snapshot.Get(bucketizedKey(metadataBucketID,
+		writeLocKeyName))
+	if writeRow == nil {
+		str := "write cursor does not exist"
+		return makeDbErr(database.ErrCorruption, str, nil)
+	}
+	curFileNum, curOffset, err := deserializeWriteRow(writeRow)
+	if err != nil {
+		return err
+	}
+	files, err := backupBlockFiles(db.store.basePath, curFileNum, curOffset)
+	if err != nil {
+		return err
+	}
+	if err := w.WriteManifest(db.dbType, files); err != nil {
+		return err
+	}
+
+	iter := tx.snapshot.NewIterator(&util.Range{})
+	for ok := iter.First(); ok; ok = iter.Next() {
+		if err := w.WriteMetadata(iter.Key(), iter.Value()); err != nil {
+			iter.Release()
+			return err
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return convertErr("failed to iterate metadata", err)
+	}
+
+	if !includeBlocks {
+		return nil
+	}
+	for _, file := range files {
+		if err := backupBlockFile(db.store.basePath, file, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backupBlockFile writes the contents of the passed block file up to its size
+// in the snapshot to the passed backup writer.
+func backupBlockFile(basePath string, file database.BackupBlockFile, w database.BackupWriter) error {
+	f, err := os.Open(blockFilePath(basePath, file.FileNum))
+	if err != nil {
+		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	defer f.Close()
+
+	return w.WriteBlockFile(file, io.LimitReader(f, file.Size))
+}
+
+// restoreWriter is a database.BackupWriter which restores a backup to a new
+// database.
+type restoreWriter struct {
+	dbPath      string
+	metaDB      metadataStore
+	manifest    []database.BackupBlockFile
+	created     []string
+	pending     [][2][]byte
+	pendingSize int
+}
+
+// Enforce restoreWriter implements the database.BackupWriter interface.
+var _ database.BackupWriter = (*restoreWriter)(nil)
+
+// WriteManifest records the block files which are part of the backup.
+//
+// This is part of the database.BackupWriter interface implementation.
+func (rw *restoreWriter) WriteManifest(dbType string, files []database.BackupBlockFile) error {
+	log.Infof("Restoring %s backup with %d block files", dbType, len(files))
+	rw.manifest = files
+	return nil
+}
+
+// flushMetadata writes the pending metadata to the metadata store.
+func (rw *restoreWriter) flushMetadata() error {
+	err := rw.metaDB.Update(func(w metadataWriter) error {
+		for _, pair := range rw.pending {
+			if err := w.Put(pair[0], pair[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return convertErr("failed to write metadata", err)
+	}
+
+	rw.pending = rw.pending[:0]
+	rw.pendingSize = 0
+	return nil
+}
+
+// WriteMetadata writes the passed metadata key/value pair to the metadata store
+// in batches.
+//
+// This is part of the database.BackupWriter interface implementation.
+func (rw *restoreWriter) WriteMetadata(key, value []byte) error {
+	rw.pending = append(rw.pending, [2][]byte{copySlice(key),
+		copySlice(value)})
+	rw.pendingSize += len(key) + len(value)
+	if rw.pendingSize < restoreBatchSize {
+		return nil
+	}
+	return rw.flushMetadata()
+}
+
+// WriteBlockFile writes the passed block file to the database directory.
+//
+// This is part of the database.BackupWriter interface implementation.
+func (rw *restoreWriter) WriteBlockFile(file database.BackupBlockFile, r io.Reader) error {
+	filePath := blockFilePath(rw.dbPath, file.FileNum)
+	f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	rw.created = append(rw.created, filePath)
+
+	n, err := io.Copy(f, r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	if n != file.Size {
+		str := fmt.Sprintf("block file %d is %d bytes, expected %d",
+			file.FileNum, n, file.Size)
+		return makeDbErr(database.ErrCorruption, str, nil)
+	}
+	return nil
+}
+
+// finish writes the remaining metadata and ensures all of the block files of
+// the manifest are present.  The block files which were not part of the backup
+// must have been copied to the database directory separately, and are
+// truncated to their size in the manifest to remove any data written after the
+// snapshot.
+func (rw *restoreWriter) finish() error {
+	if err := rw.flushMetadata(); err != nil {
+		return err
+	}
+
+	for _, file := range rw.manifest {
+		filePath := blockFilePath(rw.dbPath, file.FileNum)
+		info, err := os.Stat(filePath)
+		if err != nil {
+			str := fmt.Sprintf("block file %d is not part of the "+
+				"backup and must be copied to %q", file.FileNum,
+				filePath)
+			return makeDbErr(database.ErrDriverSpecific, str, err)
+		}
+		if info.Size() < file.Size {
+			str := fmt.Sprintf("block file %d is %d bytes, expected "+
+				"at least %d", file.FileNum, info.Size(), file.Size)
+			return makeDbErr(database.ErrCorruption, str, nil)
+		}
+		if info.Size() > file.Size {
+			if err := os.Truncate(filePath, file.Size); err != nil {
+				return makeDbErr(database.ErrDriverSpecific,
+					err.Error(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// restoreDBDriver returns the callback provided during driver registration that
+// creates a database of the passed type from a backup.
+func restoreDBDriver(dbType string) func(replay func(w database.BackupWriter) error, args ...interface{}) error {
+	return func(replay func(w database.BackupWriter) error, args ...interface{}) error {
+		dbPath, network, _, _, err := parseArgs(dbType, "Restore",
+			args...)
+		if err != nil {
+			return err
+		}
+
+		return restoreDB(dbType, dbPath, network, replay)
+	}
+}
+
+// restoreDB creates a database of the passed type at the passed path from the
+// backup replayed by the passed function.  The restored database is opened
+// once to reconcile and verify it.  Nothing but the block files which were
+// copied to the database directory beforehand is left behind when restoring
+// fails, so it can be retried.
+func restoreDB(dbType, dbPath string, network wire.BitcoinNet, replay func(w database.BackupWriter) error) error {
+	metadataDbPath := filepath.Join(dbPath, metadataDbName)
+	if fileExists(metadataDbPath) {
+		str := fmt.Sprintf("database %q already exists", metadataDbPath)
+		return makeDbErr(database.ErrDbExists, str, nil)
+	}
+
+	// The error can be ignored here since opening the metadata store will
+	// fail if the directory couldn't be created.
+	_ = os.MkdirAll(dbPath, 0700)
+	metaDB, err := openMetadataStore(dbType, metadataDbPath, true)
+	if err != nil {
+		return err
+	}
+
+	rw := &restoreWriter{dbPath: dbPath, metaDB: metaDB}
+	err = replay(rw)
+	if err == nil {
+		err = rw.finish()
+	}
+	if closeErr := metaDB.Close(); err == nil && closeErr != nil {
+		err = convertErr("failed to close metadata", closeErr)
+	}
+	if err == nil {
+		var pdb database.DB
+		pdb, err = openDB(dbType, dbPath, network, false, 0, 0)
+		if err == nil {
+			err = pdb.Close()
+		}
+	}
+	if err != nil {
+		_ = os.RemoveAll(metadataDbPath)
+		for _, filePath := range rw.created {
+			_ = os.Remove(filePath)
+		}
+		return err
+	}
+	return nil
+}
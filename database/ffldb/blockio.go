@@ -15,6 +15,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -250,9 +251,11 @@ func (s *blockStore) openWriteFile(fileNum uint32) (filer, error) {
 }
 
 // openFile returns a read-only file handle for the passed flat file number.
-// The function also keeps track of the open files, performs least recently
-// used tracking, and limits the number of open files to maxOpenFiles by closing
-// the least recently used file as needed.
+// The file is memory mapped when possible so reads are served from the mapping
+// without a system call per read.  The function also keeps track of the open
+// files, performs least recently used tracking, and limits the number of open
+// and mapped files to maxOpenFiles by closing the least recently used file as
+// needed.
 //
 // This function MUST be called with the overall files mutex (s.obfMutex) locked
 // for WRITES.
@@ -264,7 +267,7 @@ func (s *blockStore) openFile(fileNum uint32) (*lockableFile, error) {
 		return nil, makeDbErr(database.ErrDriverSpecific, err.Error(),
 			err)
 	}
-	blockFile := &lockableFile{file: file}
+	blockFile := &lockableFile{file: openMappedFile(file)}
 
 	// Close the least recently used file if the file exceeds the max
 	// allowed open files.  This is not done until after the file open in
@@ -302,6 +305,37 @@ func (s *blockStore) openFile(fileNum uint32) (*lockableFile, error) {
 	return blockFile, nil
 }
 
+// closeFile closes the read-only file handle for the passed flat file number
+// and removes it from the least recently used tracking when it is open.  It
+// must be invoked before the file is deleted or truncated so readers never use
+// a stale handle or a memory mapping which extends past the end of the file.
+//
+// This function MUST NOT be called with the overall files mutex (s.obfMutex) or
+// any of the mutexes after it in the locking order held.
+func (s *blockStore) closeFile(fileNum uint32) {
+	s.obfMutex.Lock()
+	defer s.obfMutex.Unlock()
+
+	blockFile, ok := s.openBlockFiles[fileNum]
+	if !ok {
+		return
+	}
+	s.lruMutex.Lock()
+	if elem, ok := s.fileNumToLRUElem[fileNum]; ok {
+		s.openBlocksLRU.Remove(elem)
+		delete(s.fileNumToLRUElem, fileNum)
+	}
+	s.lruMutex.Unlock()
+
+	// Close the file under the write lock for the file in case any readers
+	// are currently reading from it so it's not closed out from under them.
+	blockFile.Lock()
+	_ = blockFile.file.Close()
+	blockFile.Unlock()
+
+	delete(s.openBlockFiles, fileNum)
+}
+
 // deleteFile removes the block file for the passed flat file number.  The file
 // must already be closed and it is the responsibility of the caller to do any
 // other state cleanup necessary.
@@ -537,7 +571,7 @@ func (s *blockStore) readBlock(hash *chainhash.Hash, loc blockLocation) ([]byte,
 	}
 
 	serializedData := make([]byte, loc.blockLen)
-	n, err := blockFile.file.ReadAt(serializedData, int64(loc.fileOffset))
+	_, err = blockFile.file.ReadAt(serializedData, int64(loc.fileOffset))
 	blockFile.RUnlock()
 	if err != nil {
 		str := fmt.Sprintf("failed to read block %s from file %d, "+
@@ -546,10 +580,97 @@ func (s *blockStore) readBlock(hash *chainhash.Hash, loc blockLocation) ([]byte,
 		return nil, makeDbErr(database.ErrDriverSpecific, str, err)
 	}
 
+	return s.blockFromRecord(hash, serializedData)
+}
+
+// readBlocks reads the specified block records and returns the serialized
+// blocks in the same order as the passed hashes and locations.  The records are
+// read grouped by file, and records which are stored back to back, as is
+// typically the case for a run of blocks requested by a syncing peer, are read
+// with a single vectored read under a single acquisition of the file.  The
+// integrity of every block is ensured the same way as by readBlock.
+//
+// Returns ErrDriverSpecific if the data fails to read for any reason and
+// ErrCorruption if the checksum of the read data doesn't match the checksum
+// read from the file.
+func (s *blockStore) readBlocks(hashes []chainhash.Hash, locs []blockLocation) ([][]byte, error) {
+	// Sort the reads by filenum:offset so they are grouped by file and
+	// linear within each file.
+	order := make([]int, len(locs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := &locs[order[i]], &locs[order[j]]
+		if a.blockFileNum != b.blockFileNum {
+			return a.blockFileNum < b.blockFileNum
+		}
+		return a.fileOffset < b.fileOffset
+	})
+
+	blocks := make([][]byte, len(locs))
+	for start := 0; start < len(order); {
+		// Find the run of records stored back to back in the same file
+		// starting with the current one.
+		first := &locs[order[start]]
+		end := start + 1
+		nextOffset := uint64(first.fileOffset) + uint64(first.blockLen)
+		for ; end < len(order); end++ {
+			loc := &locs[order[end]]
+			if loc.blockFileNum != first.blockFileNum ||
+				uint64(loc.fileOffset) != nextOffset {
+
+				break
+			}
+			nextOffset += uint64(loc.blockLen)
+		}
+
+		bufs := make([][]byte, end-start)
+		for i := range bufs {
+			bufs[i] = make([]byte, locs[order[start+i]].blockLen)
+		}
+
+		// Get the referenced block file handle opening the file as
+		// needed and read the entire run at once.
+		blockFile, err := s.blockFile(first.blockFileNum)
+		if err != nil {
+			return nil, err
+		}
+		_, err = readvAt(blockFile.file, bufs, int64(first.fileOffset))
+		blockFile.RUnlock()
+		if err != nil {
+			str := fmt.Sprintf("failed to read %d blocks from file "+
+				"%d, offset %d: %v", len(bufs), first.blockFileNum,
+				first.fileOffset, err)
+			return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+		}
+
+		for i, serializedData := range bufs {
+			idx := order[start+i]
+			blocks[idx], err = s.blockFromRecord(&hashes[idx],
+				serializedData)
+			if err != nil {
+				return nil, err
+			}
+		}
+		start = end
+	}
+
+	return blocks, nil
+}
+
+// blockFromRecord ensures the integrity of the passed serialized block record
+// read from a flat file and returns the serialized block it contains.
+//
+// Returns ErrCorruption if the checksum of the record doesn't match the
+// checksum stored in it and ErrDriverSpecific if the record is for another
+// network.
+func (s *blockStore) blockFromRecord(hash *chainhash.Hash, serializedData []byte) ([]byte, error) {
 	// Calculate the checksum of the read data and ensure it matches the
 	// serialized checksum.  This will detect any data corruption in the
 	// flat file without having to do much more expensive merkle root
 	// calculations on the loaded block.
+	n := len(serializedData)
 	serializedChecksum := binary.BigEndian.Uint32(serializedData[n-4:])
 	calculatedChecksum := crc32.Checksum(serializedData[:n-4], castagnoli)
 	if serializedChecksum != calculatedChecksum {
@@ -592,18 +713,14 @@ func (s *blockStore) deleteBlocks(deleteBefore uint32) error {
 	}
 
 	s.fbhMutex.Lock()
+	defer s.fbhMutex.Unlock()
 	for _, n := range toDelete {
-		blockFile, err := s.blockFile(n)
-		if err != nil {
-			return err
-		}
-		blockFile.file.Close()
+		s.closeFile(n)
 		if err := s.deleteFile(n); err != nil {
 			return err
 		}
 		delete(s.fileBlockHeights, n)
 	}
-	s.fbhMutex.Unlock()
 	return nil
 }
 
@@ -695,6 +812,20 @@ func (s *blockStore) syncBlocks() error {
 // Therefore, any errors are simply logged at a warning level rather than being
 // returned since there is nothing more that could be done about it anyways.
 func (s *blockStore) handleRollback(oldBlockFileNum, oldBlockOffset uint32) {
+	// Close any read-only handles for the files which are about to be
+	// deleted or truncated.  The file the write cursor is rolled back to
+	// might have been opened read-only after writes moved on to the next
+	// file, and a memory mapping of it must not outlive the truncation.
+	//
+	// NOTE: The write cursor file number is only changed during a write
+	// transaction, which is also the only time this function is called,
+	// so it is safe to read without the write cursor lock here.  The files
+	// must be closed before the lock is grabbed to respect the locking
+	// order.
+	for fileNum := oldBlockFileNum; fileNum < s.writeCursor.curFileNum; fileNum++ {
+		s.closeFile(fileNum)
+	}
+
 	// Grab the write cursor mutex since it is modified throughout this
 	// function.
 	wc := s.writeCursor
@@ -13,14 +13,14 @@ import (
 	"runtime"
 	"sort"
 	"sync"
+	"sync/atomic"
 
 	"github.com/btcsuite/goleveldb/leveldb"
 	"github.com/btcsuite/goleveldb/leveldb/comparer"
 	ldberrors "github.com/btcsuite/goleveldb/leveldb/errors"
-	"github.com/btcsuite/goleveldb/leveldb/filter"
 	"github.com/btcsuite/goleveldb/leveldb/iterator"
-	"github.com/btcsuite/goleveldb/leveldb/opt"
 	"github.com/btcsuite/goleveldb/leveldb/util"
+	"github.com/cockroachdb/pebble"
 	"github.com/gcash/bchd/chaincfg/chainhash"
 	"github.com/gcash/bchd/database"
 	"github.com/gcash/bchd/database/internal/treap"
@@ -138,9 +138,9 @@ func makeDbErr(c database.ErrorCode, desc string, err error) database.Error {
 	return database.Error{ErrorCode: c, Description: desc, Err: err}
 }
 
-// convertErr converts the passed leveldb error into a database error with an
-// equivalent error code  and the passed description.  It also sets the passed
-// error as the underlying error.
+// convertErr converts the passed leveldb or pebble error into a database error
+// with an equivalent error code  and the passed description.  It also sets the
+// passed error as the underlying error.
 func convertErr(desc string, ldbErr error) database.Error {
 	// Use the driver-specific error code by default.  The code below will
 	// update this with the converted error if it's recognized.
@@ -150,6 +150,8 @@ func convertErr(desc string, ldbErr error) database.Error {
 	// Database corruption errors.
 	case ldberrors.IsCorrupted(ldbErr):
 		code = database.ErrCorruption
+	case pebble.IsCorruptionError(ldbErr):
+		code = database.ErrCorruption
 
 	// Database open/create errors.
 	case ldbErr == leveldb.ErrClosed:
@@ -1269,6 +1271,29 @@ func (tx *transaction) HasBlocks(hashes []chainhash.Hash) ([]bool, error) {
 	return results, nil
 }
 
+// ForEachBlockHash invokes the passed function with the hash of every block
+// stored in the database, in no particular order.  Blocks which are still
+// pending to be stored by the transaction are not included.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) ForEachBlockHash(fn func(hash *chainhash.Hash) error) error {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return err
+	}
+
+	return tx.blockIdxBucket.ForEach(func(k, _ []byte) error {
+		hash, err := chainhash.NewHash(k)
+		if err != nil {
+			return err
+		}
+		return fn(hash)
+	})
+}
+
 // fetchBlockRow fetches the metadata stored in the block index for the provided
 // hash.  It will return ErrBlockNotFound if there is no entry.
 func (tx *transaction) fetchBlockRow(hash *chainhash.Hash) ([]byte, error) {
@@ -1406,14 +1431,37 @@ func (tx *transaction) FetchBlocks(hashes []chainhash.Hash) ([][]byte, error) {
 	// callers will not typically be calling this function with invalid
 	// values, so optimize for the common case.
 
-	// Load the blocks.
+	// Grab the bytes of the blocks which are pending to be written on
+	// commit and look up the location of the rest in the block index so
+	// they can be read from the flat files together.
 	blocks := make([][]byte, len(hashes))
+	fetchHashes := make([]chainhash.Hash, 0, len(hashes))
+	fetchLocs := make([]blockLocation, 0, len(hashes))
+	fetchIdxs := make([]int, 0, len(hashes))
 	for i := range hashes {
-		var err error
-		blocks[i], err = tx.FetchBlock(&hashes[i])
+		if idx, exists := tx.pendingBlocks[hashes[i]]; exists {
+			blocks[i] = tx.pendingBlockData[idx].bytes
+			continue
+		}
+
+		blockRow, err := tx.fetchBlockRow(&hashes[i])
 		if err != nil {
 			return nil, err
 		}
+		fetchHashes = append(fetchHashes, hashes[i])
+		fetchLocs = append(fetchLocs, deserializeBlockLoc(blockRow))
+		fetchIdxs = append(fetchIdxs, i)
+	}
+
+	// Read the blocks from the appropriate locations.  Blocks which are
+	// stored back to back are read at once and every block is checksummed
+	// to detect data corruption.
+	fetched, err := tx.db.store.readBlocks(fetchHashes, fetchLocs)
+	if err != nil {
+		return nil, err
+	}
+	for i, blockBytes := range fetched {
+		blocks[fetchIdxs[i]] = blockBytes
 	}
 
 	return blocks, nil
@@ -1782,11 +1830,13 @@ func (tx *transaction) Rollback() error {
 // the database.DB interface.  All database access is performed through
 // transactions which are obtained through the specific Namespace.
 type db struct {
-	writeLock sync.Mutex   // Limit to one write transaction at a time.
-	closeLock sync.RWMutex // Make database close block while txns active.
-	closed    bool         // Is the database closed?
-	store     *blockStore  // Handles read/writing blocks to flat files.
-	cache     *dbCache     // Cache layer which wraps underlying leveldb DB.
+	writeLock   sync.Mutex   // Limit to one write transaction at a time.
+	closeLock   sync.RWMutex // Make database close block while txns active.
+	compactLock sync.Mutex   // Limit to one compaction at a time.
+	closed      bool         // Is the database closed?
+	store       *blockStore  // Handles read/writing blocks to flat files.
+	cache       *dbCache     // Cache layer which wraps underlying metadata store.
+	dbType      string       // Driver type the database was created with.
 }
 
 // Enforce db implements the database.DB interface.
@@ -1797,7 +1847,7 @@ var _ database.DB = (*db)(nil)
 //
 // This function is part of the database.DB interface implementation.
 func (db *db) Type() string {
-	return dbType
+	return db.dbType
 }
 
 // begin is the implementation function for the Begin database method.  See its
@@ -1970,6 +2020,110 @@ func (db *db) Update(fn func(database.Tx) error) error {
 	return tx.Commit()
 }
 
+// Compact flushes the database cache and then compacts the underlying
+// metadata store, which reclaims the space held by deleted and overwritten
+// keys.  Transactions may be used while the store is compacted.
+//
+// This function is part of the database.DB interface implementation.
+func (db *db) Compact() error {
+	// Hold a read lock on the close mutex for the duration so the database
+	// can't be closed while it is compacted.
+	db.closeLock.RLock()
+	defer db.closeLock.RUnlock()
+	if db.closed {
+		return makeDbErr(database.ErrDbNotOpen, errDbNotOpenStr, nil)
+	}
+
+	db.compactLock.Lock()
+	defer db.compactLock.Unlock()
+
+	// Flush the cache first so the keys deleted by the transactions which
+	// are only held in the cache are compacted as well.
+	db.writeLock.Lock()
+	err := db.cache.flush()
+	db.writeLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Keys deleted while the store is compacted are not necessarily
+	// compacted, so only discount the deletions known beforehand.
+	deletedKeys := atomic.LoadUint64(&db.cache.deletedKeys)
+	if err := db.cache.metaDB.Compact(); err != nil {
+		return convertErr("failed to compact metadata", err)
+	}
+	atomic.AddUint64(&db.cache.deletedKeys, ^(deletedKeys - 1))
+	return nil
+}
+
+// SpaceInfo returns information about the disk space used by the database.
+//
+// This function is part of the database.DB interface implementation.
+func (db *db) SpaceInfo() (*database.SpaceInfo, error) {
+	db.closeLock.RLock()
+	defer db.closeLock.RUnlock()
+	if db.closed {
+		return nil, makeDbErr(database.ErrDbNotOpen, errDbNotOpenStr, nil)
+	}
+
+	metadataSize, err := dirSize(filepath.Join(db.store.basePath,
+		metadataDbName), true)
+	if err != nil {
+		return nil, err
+	}
+	blocksSize, err := dirSize(db.store.basePath, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &database.SpaceInfo{
+		MetadataSize: metadataSize,
+		BlocksSize:   blocksSize,
+		DeletedKeys:  atomic.LoadUint64(&db.cache.deletedKeys),
+	}, nil
+}
+
+// dirSize returns the total size of the regular files in the passed directory,
+// including those in its subdirectories when the recursive flag is set.
+func dirSize(path string, recursive bool) (int64, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		str := fmt.Sprintf("failed to read directory %q", path)
+		return 0, makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+
+	var size int64
+	for _, entry := range entries {
+		if entry.IsDir() {
+			if !recursive {
+				continue
+			}
+			subSize, err := dirSize(filepath.Join(path, entry.Name()),
+				true)
+			if err != nil {
+				return 0, err
+			}
+			size += subSize
+			continue
+		}
+
+		// Files might be removed concurrently, such as by a compaction,
+		// so ignore files which no longer exist.
+		info, err := entry.Info()
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			str := fmt.Sprintf("failed to stat %q", entry.Name())
+			return 0, makeDbErr(database.ErrDriverSpecific, str, err)
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+	}
+	return size, nil
+}
+
 // Close cleanly shuts down the database and syncs all data.  It will block
 // until all database transactions have been finalized (rolled back or
 // committed).
@@ -2025,25 +2179,33 @@ func fileExists(name string) bool {
 
 // initDB creates the initial buckets and values used by the package.  This is
 // mainly in a separate function for testing purposes.
-func initDB(ldb *leveldb.DB) error {
-	// The starting block file write cursor location is file num 0, offset
-	// 0.
-	batch := new(leveldb.Batch)
-	batch.Put(bucketizedKey(metadataBucketID, writeLocKeyName),
-		serializeWriteRow(0, 0))
-
-	// Create block and file index buckets and set the current bucket id.
-	//
-	// NOTE: Since buckets are virtualized through the use of prefixes,
-	// there is no need to store the bucket index data for the metadata
-	// bucket in the database.  However, the first bucket ID to use does
-	// need to account for it to ensure there are no key collisions.
-	batch.Put(bucketIndexKey(metadataBucketID, blockIdxBucketName),
-		blockIdxBucketID[:])
-	batch.Put(curBucketIDKeyName, blockIdxBucketID[:])
-
+func initDB(metaDB metadataStore) error {
 	// Write everything as a single batch.
-	if err := ldb.Write(batch, nil); err != nil {
+	err := metaDB.Update(func(w metadataWriter) error {
+		// The starting block file write cursor location is file num 0,
+		// offset 0.
+		err := w.Put(bucketizedKey(metadataBucketID, writeLocKeyName),
+			serializeWriteRow(0, 0))
+		if err != nil {
+			return err
+		}
+
+		// Create block and file index buckets and set the current
+		// bucket id.
+		//
+		// NOTE: Since buckets are virtualized through the use of
+		// prefixes, there is no need to store the bucket index data for
+		// the metadata bucket in the database.  However, the first
+		// bucket ID to use does need to account for it to ensure there
+		// are no key collisions.
+		err = w.Put(bucketIndexKey(metadataBucketID, blockIdxBucketName),
+			blockIdxBucketID[:])
+		if err != nil {
+			return err
+		}
+		return w.Put(curBucketIDKeyName, blockIdxBucketID[:])
+	})
+	if err != nil {
 		str := fmt.Sprintf("failed to initialize metadata database: %v",
 			err)
 		return convertErr(str, err)
@@ -2052,9 +2214,10 @@ func initDB(ldb *leveldb.DB) error {
 	return nil
 }
 
-// openDB opens the database at the provided path.  database.ErrDbDoesNotExist
-// is returned if the database doesn't exist and the create flag is not set.
-func openDB(dbPath string, network wire.BitcoinNet, create bool, cacheSize uint64, flushSecs uint32) (database.DB, error) {
+// openDB opens the database of the provided driver type at the provided path.
+// database.ErrDbDoesNotExist is returned if the database doesn't exist and the
+// create flag is not set.
+func openDB(dbType, dbPath string, network wire.BitcoinNet, create bool, cacheSize uint64, flushSecs uint32) (database.DB, error) {
 	// Error if the database doesn't exist and the create flag is not set.
 	metadataDbPath := filepath.Join(dbPath, metadataDbName)
 	dbExists := fileExists(metadataDbPath)
@@ -2065,31 +2228,25 @@ func openDB(dbPath string, network wire.BitcoinNet, create bool, cacheSize uint6
 
 	// Ensure the full path to the database exists.
 	if !dbExists {
-		// The error can be ignored here since the call to
-		// leveldb.OpenFile will fail if the directory couldn't be
-		// created.
+		// The error can be ignored here since opening the metadata
+		// store will fail if the directory couldn't be created.
 		_ = os.MkdirAll(dbPath, 0700)
 	}
 
 	// Open the metadata database (will create it if needed).
-	opts := opt.Options{
-		ErrorIfExist: create,
-		Strict:       opt.DefaultStrict,
-		Compression:  opt.NoCompression,
-		Filter:       filter.NewBloomFilter(10),
-	}
-	ldb, err := leveldb.OpenFile(metadataDbPath, &opts)
+	metaDB, err := openMetadataStore(dbType, metadataDbPath, create)
 	if err != nil {
-		return nil, convertErr(err.Error(), err)
+		return nil, err
 	}
 
 	// Create the block store which includes scanning the existing flat
 	// block files to find what the current write cursor position is
 	// according to the data that is actually on disk.  Also create the
-	// database cache which wraps the underlying leveldb database to provide
+	// database cache which wraps the underlying metadata store to provide
 	// write caching.
 	store, err := newBlockStore(dbPath, network)
 	if err != nil {
+		_ = metaDB.Close()
 		return nil, err
 	}
 	if cacheSize == 0 {
@@ -2098,8 +2255,8 @@ func openDB(dbPath string, network wire.BitcoinNet, create bool, cacheSize uint6
 	if flushSecs == 0 {
 		flushSecs = defaultFlushSecs
 	}
-	cache := newDbCache(ldb, store, cacheSize, flushSecs)
-	pdb := &db{store: store, cache: cache}
+	cache := newDbCache(metaDB, store, cacheSize, flushSecs)
+	pdb := &db{store: store, cache: cache, dbType: dbType}
 
 	// Perform any reconciliation needed between the block and metadata as
 	// well as database initialization, if needed.
@@ -8,9 +8,9 @@ import (
 	"bytes"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/btcsuite/goleveldb/leveldb"
 	"github.com/btcsuite/goleveldb/leveldb/iterator"
 	"github.com/btcsuite/goleveldb/leveldb/util"
 	"github.com/gcash/bchd/database/internal/treap"
@@ -285,7 +285,7 @@ func (iter *dbCacheIterator) Error() error {
 // dbCacheSnapshot defines a snapshot of the database cache and underlying
 // database at a particular point in time.
 type dbCacheSnapshot struct {
-	dbSnapshot    *leveldb.Snapshot
+	dbSnapshot    metadataSnapshot
 	pendingKeys   *treap.Immutable
 	pendingRemove *treap.Immutable
 }
@@ -301,7 +301,7 @@ func (snap *dbCacheSnapshot) Has(key []byte) bool {
 	}
 
 	// Consult the database.
-	hasKey, _ := snap.dbSnapshot.Has(key, nil)
+	hasKey, _ := snap.dbSnapshot.Has(key)
 	return hasKey
 }
 
@@ -317,7 +317,7 @@ func (snap *dbCacheSnapshot) Get(key []byte) []byte {
 	}
 
 	// Consult the database.
-	value, err := snap.dbSnapshot.Get(key, nil)
+	value, err := snap.dbSnapshot.Get(key)
 	if err != nil {
 		return nil
 	}
@@ -340,7 +340,7 @@ func (snap *dbCacheSnapshot) Release() {
 // can be nil if the functionality is not desired.
 func (snap *dbCacheSnapshot) NewIterator(slice *util.Range) *dbCacheIterator {
 	return &dbCacheIterator{
-		dbIter:        snap.dbSnapshot.NewIterator(slice, nil),
+		dbIter:        snap.dbSnapshot.NewIterator(slice),
 		cacheIter:     newLdbCacheIter(snap, slice),
 		cacheSnapshot: snap,
 	}
@@ -354,8 +354,13 @@ func (snap *dbCacheSnapshot) NewIterator(slice *util.Range) *dbCacheIterator {
 // can commit transactions at will without incurring large performance hits due
 // to frequent disk syncs.
 type dbCache struct {
-	// ldb is the underlying leveldb DB for metadata.
-	ldb *leveldb.DB
+	// deletedKeys is the number of keys deleted from the underlying store
+	// since the cache was created or the store was last compacted.  It
+	// must be accessed atomically.
+	deletedKeys uint64 // Must be first for 64-bit alignment.
+
+	// metaDB is the underlying store for metadata.
+	metaDB metadataStore
 
 	// store is used to sync blocks to flat files.
 	store *blockStore
@@ -396,7 +401,7 @@ type dbCache struct {
 //
 // The snapshot must be released after use by calling Release.
 func (c *dbCache) Snapshot() (*dbCacheSnapshot, error) {
-	dbSnapshot, err := c.ldb.GetSnapshot()
+	dbSnapshot, err := c.metaDB.Snapshot()
 	if err != nil {
 		str := "failed to open transaction"
 		return nil, convertErr(str, err)
@@ -415,26 +420,24 @@ func (c *dbCache) Snapshot() (*dbCacheSnapshot, error) {
 	return cacheSnapshot, nil
 }
 
-// updateDB invokes the passed function in the context of a managed leveldb
-// transaction.  Any errors returned from the user-supplied function will cause
-// the transaction to be rolled back and are returned from this function.
-// Otherwise, the transaction is committed when the user-supplied function
-// returns a nil error.
-func (c *dbCache) updateDB(fn func(ldbTx *leveldb.Transaction) error) error {
-	// Start a leveldb transaction.
-	ldbTx, err := c.ldb.OpenTransaction()
-	if err != nil {
-		return convertErr("failed to open ldb transaction", err)
-	}
-
-	if err := fn(ldbTx); err != nil {
-		ldbTx.Discard()
-		return err
+// updateDB invokes the passed function in the context of a managed metadata
+// store update.  Any errors returned from the user-supplied function will cause
+// the update to be rolled back and are returned from this function.
+// Otherwise, the update is committed when the user-supplied function returns a
+// nil error.
+func (c *dbCache) updateDB(fn func(w metadataWriter) error) error {
+	var fnErr error
+	err := c.metaDB.Update(func(w metadataWriter) error {
+		fnErr = fn(w)
+		return fnErr
+	})
+	if fnErr != nil {
+		return fnErr
 	}
 
-	// Commit the leveldb transaction and convert any errors as needed.
-	if err := ldbTx.Commit(); err != nil {
-		return convertErr("failed to commit leveldb transaction", err)
+	// Convert any errors committing the update as needed.
+	if err != nil {
+		return convertErr("failed to commit metadata update", err)
 	}
 	return nil
 }
@@ -450,13 +453,14 @@ type TreapForEacher interface {
 // commitTreaps atomically commits all of the passed pending add/update/remove
 // updates to the underlying database.
 func (c *dbCache) commitTreaps(pendingKeys, pendingRemove TreapForEacher) error {
-	// Perform all leveldb updates using an atomic transaction.
-	return c.updateDB(func(ldbTx *leveldb.Transaction) error {
+	// Perform all metadata updates using an atomic transaction.
+	var numDeleted uint64
+	err := c.updateDB(func(w metadataWriter) error {
 		var innerErr error
 		pendingKeys.ForEach(func(k, v []byte) bool {
-			if dbErr := ldbTx.Put(k, v, nil); dbErr != nil {
+			if dbErr := w.Put(k, v); dbErr != nil {
 				str := fmt.Sprintf("failed to put key %q to "+
-					"ldb transaction", k)
+					"metadata transaction", k)
 				innerErr = convertErr(str, dbErr)
 				return false
 			}
@@ -467,17 +471,24 @@ func (c *dbCache) commitTreaps(pendingKeys, pendingRemove TreapForEacher) error
 		}
 
 		pendingRemove.ForEach(func(k, _ []byte) bool {
-			if dbErr := ldbTx.Delete(k, nil); dbErr != nil {
+			if dbErr := w.Delete(k); dbErr != nil {
 				str := fmt.Sprintf("failed to delete "+
-					"key %q from ldb transaction",
+					"key %q from metadata transaction",
 					k)
 				innerErr = convertErr(str, dbErr)
 				return false
 			}
+			numDeleted++
 			return true
 		})
 		return innerErr
 	})
+	if err != nil {
+		return err
+	}
+
+	atomic.AddUint64(&c.deletedKeys, numDeleted)
+	return nil
 }
 
 // flush flushes the database cache to persistent storage.  This involes syncing
@@ -509,7 +520,7 @@ func (c *dbCache) flush() error {
 		return nil
 	}
 
-	// Perform all leveldb updates using an atomic transaction.
+	// Perform all metadata updates using an atomic transaction.
 	if err := c.commitTreaps(cachedKeys, cachedRemove); err != nil {
 		return err
 	}
@@ -572,7 +583,7 @@ func (c *dbCache) commitTx(tx *transaction) error {
 			return err
 		}
 
-		// Perform all leveldb updates using an atomic transaction.
+		// Perform all metadata updates using an atomic transaction.
 		err := c.commitTreaps(tx.pendingKeys, tx.pendingRemove)
 		if err != nil {
 			return err
@@ -621,7 +632,7 @@ func (c *dbCache) commitTx(tx *transaction) error {
 }
 
 // Close cleanly shuts down the database cache by syncing all data and closing
-// the underlying leveldb database.
+// the underlying metadata store.
 //
 // This function MUST be called with the database write lock held.
 func (c *dbCache) Close() error {
@@ -630,13 +641,13 @@ func (c *dbCache) Close() error {
 		// Even if there is an error while flushing, attempt to close
 		// the underlying database.  The error is ignored since it would
 		// mask the flush error.
-		_ = c.ldb.Close()
+		_ = c.metaDB.Close()
 		return err
 	}
 
-	// Close the underlying leveldb database.
-	if err := c.ldb.Close(); err != nil {
-		str := "failed to close underlying leveldb database"
+	// Close the underlying metadata store.
+	if err := c.metaDB.Close(); err != nil {
+		str := "failed to close underlying metadata store"
 		return convertErr(str, err)
 	}
 
@@ -644,12 +655,12 @@ func (c *dbCache) Close() error {
 }
 
 // newDbCache returns a new database cache instance backed by the provided
-// leveldb instance.  The cache will be flushed to leveldb when the max size
+// metadata store.  The cache will be flushed to the store when the max size
 // exceeds the provided value or it has been longer than the provided interval
 // since the last flush.
-func newDbCache(ldb *leveldb.DB, store *blockStore, maxSize uint64, flushIntervalSecs uint32) *dbCache {
+func newDbCache(metaDB metadataStore, store *blockStore, maxSize uint64, flushIntervalSecs uint32) *dbCache {
 	return &dbCache{
-		ldb:           ldb,
+		metaDB:        metaDB,
 		store:         store,
 		maxSize:       maxSize,
 		flushInterval: time.Second * time.Duration(flushIntervalSecs),
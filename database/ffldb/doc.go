@@ -10,6 +10,11 @@ This driver is the recommended driver for use with bchd.  It makes use leveldb
 for the metadata, flat files for block storage, and checksums in key areas to
 ensure data integrity.
 
+The package also provides the database type of "ffpebble", which stores the
+metadata in pebble instead of leveldb and is otherwise identical.  Pebble
+compacts concurrently with writes, which avoids the write stalls leveldb can
+suffer from during the initial block download on slow disks.
+
 # Usage
 
 This package is a driver to the database package and provides the database type
@@ -16,10 +16,14 @@ var log = bchlog.Disabled
 
 const (
 	dbType = "ffldb"
+
+	// pebbleDbType is the type of the driver which stores the metadata in
+	// pebble rather than leveldb.
+	pebbleDbType = "ffpebble"
 )
 
 // parseArgs parses the arguments from the database Open/Create methods.
-func parseArgs(funcName string, args ...interface{}) (string, wire.BitcoinNet, uint64, uint32, error) {
+func parseArgs(dbType, funcName string, args ...interface{}) (string, wire.BitcoinNet, uint64, uint32, error) {
 	if len(args) < 2 || len(args) > 4 {
 		return "", 0, 0, 0, fmt.Errorf("invalid arguments to %s.%s -- "+
 			"expected database path and block network with optional cache size "+
@@ -59,26 +63,34 @@ func parseArgs(funcName string, args ...interface{}) (string, wire.BitcoinNet, u
 	return dbPath, network, cacheSize, flushSecs, nil
 }
 
-// openDBDriver is the callback provided during driver registration that opens
-// an existing database for use.
-func openDBDriver(args ...interface{}) (database.DB, error) {
-	dbPath, network, cacheSize, flushSecs, err := parseArgs("Open", args...)
-	if err != nil {
-		return nil, err
-	}
+// openDBDriver returns the callback provided during driver registration that
+// opens an existing database of the passed type for use.
+func openDBDriver(dbType string) func(args ...interface{}) (database.DB, error) {
+	return func(args ...interface{}) (database.DB, error) {
+		dbPath, network, cacheSize, flushSecs, err := parseArgs(dbType,
+			"Open", args...)
+		if err != nil {
+			return nil, err
+		}
 
-	return openDB(dbPath, network, false, cacheSize, flushSecs)
+		return openDB(dbType, dbPath, network, false, cacheSize,
+			flushSecs)
+	}
 }
 
-// createDBDriver is the callback provided during driver registration that
-// creates, initializes, and opens a database for use.
-func createDBDriver(args ...interface{}) (database.DB, error) {
-	dbPath, network, cacheSize, flushSecs, err := parseArgs("Create", args...)
-	if err != nil {
-		return nil, err
-	}
+// createDBDriver returns the callback provided during driver registration that
+// creates, initializes, and opens a database of the passed type for use.
+func createDBDriver(dbType string) func(args ...interface{}) (database.DB, error) {
+	return func(args ...interface{}) (database.DB, error) {
+		dbPath, network, cacheSize, flushSecs, err := parseArgs(dbType,
+			"Create", args...)
+		if err != nil {
+			return nil, err
+		}
 
-	return openDB(dbPath, network, true, cacheSize, flushSecs)
+		return openDB(dbType, dbPath, network, true, cacheSize,
+			flushSecs)
+	}
 }
 
 // useLogger is the callback provided during driver registration that sets the
@@ -88,15 +100,18 @@ func useLogger(logger bchlog.Logger) {
 }
 
 func init() {
-	// Register the driver.
-	driver := database.Driver{
-		DbType:    dbType,
-		Create:    createDBDriver,
-		Open:      openDBDriver,
-		UseLogger: useLogger,
-	}
-	if err := database.RegisterDriver(driver); err != nil {
-		panic(fmt.Sprintf("Failed to regiser database driver '%s': %v",
-			dbType, err))
+	// Register the drivers.
+	for _, dbType := range []string{dbType, pebbleDbType} {
+		driver := database.Driver{
+			DbType:    dbType,
+			Create:    createDBDriver(dbType),
+			Open:      openDBDriver(dbType),
+			Restore:   restoreDBDriver(dbType),
+			UseLogger: useLogger,
+		}
+		if err := database.RegisterDriver(driver); err != nil {
+			panic(fmt.Sprintf("Failed to regiser database driver '%s': %v",
+				dbType, err))
+		}
 	}
 }
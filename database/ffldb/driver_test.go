@@ -5,6 +5,7 @@
 package ffldb_test
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -140,6 +141,18 @@ func TestCreateOpenFail(t *testing.T) {
 		return
 	}
 
+	wantErrCode = database.ErrDbNotOpen
+	err = db.Compact()
+	if !checkDbError(t, "Compact", err, wantErrCode) {
+		return
+	}
+
+	wantErrCode = database.ErrDbNotOpen
+	_, err = db.SpaceInfo()
+	if !checkDbError(t, "SpaceInfo", err, wantErrCode) {
+		return
+	}
+
 	wantErrCode = database.ErrDbNotOpen
 	err = db.Close()
 	if !checkDbError(t, "Close", err, wantErrCode) {
@@ -257,9 +270,21 @@ func TestPersistence(t *testing.T) {
 // TestInterface performs all interfaces tests for this database driver.
 func TestInterface(t *testing.T) {
 	t.Parallel()
+	testDriverInterface(t, dbType)
+}
 
+// TestPebbleInterface performs all interfaces tests for the database driver
+// which stores the metadata in pebble.
+func TestPebbleInterface(t *testing.T) {
+	t.Parallel()
+	testDriverInterface(t, "ffpebble")
+}
+
+// testDriverInterface performs all interfaces tests for the passed database
+// driver type.
+func testDriverInterface(t *testing.T, dbType string) {
 	// Create a new database to run tests against.
-	dbPath := filepath.Join(os.TempDir(), "ffldb-interfacetest")
+	dbPath := filepath.Join(os.TempDir(), dbType+"-interfacetest")
 	_ = os.RemoveAll(dbPath)
 	db, err := database.Create(dbType, dbPath, blockDataNet)
 	if err != nil {
@@ -286,3 +311,193 @@ func TestInterface(t *testing.T) {
 		testInterface(t, db)
 	})
 }
+
+// storingBackupWriter is a database.BackupWriter which stores a block along
+// with a key named after its hash to the database it backs up once it receives
+// the manifest in order to ensure writes made while a backup is written are not
+// part of it.
+type storingBackupWriter struct {
+	database.BackupWriter
+	db    database.DB
+	block *bchutil.Block
+}
+
+// WriteManifest stores the block and the key before passing the manifest on.
+func (w *storingBackupWriter) WriteManifest(dbType string, files []database.BackupBlockFile) error {
+	err := w.db.Update(func(tx database.Tx) error {
+		if err := tx.Metadata().Put(w.block.Hash()[:], []byte{1}); err != nil {
+			return err
+		}
+		return tx.StoreBlock(w.block)
+	})
+	if err != nil {
+		return err
+	}
+	return w.BackupWriter.WriteManifest(dbType, files)
+}
+
+// TestBackupRestore ensures a backup of a database taken while it is written to
+// restores to a database with the contents as of the start of the backup, both
+// with the blocks included in the backup and with the block files copied
+// separately.
+func TestBackupRestore(t *testing.T) {
+	t.Parallel()
+
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		t.Fatalf("loadBlocks: unexpected error: %v", err)
+	}
+
+	dbPath := filepath.Join(os.TempDir(), "ffldb-backuptest")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer db.Close()
+
+	err = db.Update(func(tx database.Tx) error {
+		if err := tx.Metadata().Put([]byte("beforebackup"), []byte{1}); err != nil {
+			return err
+		}
+		for _, block := range blocks[:10] {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	// Take a full and a metadata only backup, each of which stores another
+	// block while it is written.
+	backup := func(block *bchutil.Block, includeBlocks bool) []byte {
+		var buf bytes.Buffer
+		archive := database.NewBackupArchiveWriter(&buf)
+		w := &storingBackupWriter{archive, db, block}
+		if err := db.Backup(w, includeBlocks); err != nil {
+			t.Fatalf("Backup: unexpected error: %v", err)
+		}
+		if err := database.FinishBackupArchive(archive); err != nil {
+			t.Fatalf("FinishBackupArchive: unexpected error: %v", err)
+		}
+		return buf.Bytes()
+	}
+	fullBackup := backup(blocks[10], true)
+	metadataBackup := backup(blocks[11], false)
+
+	// checkRestored ensures the restored database contains the blocks and
+	// keys stored before the backup was taken, but not after.
+	checkRestored := func(restoreType, restorePath string, numBlocks int) {
+		t.Helper()
+		restored, err := database.Open(restoreType, restorePath, blockDataNet)
+		if err != nil {
+			t.Fatalf("Open: unexpected error: %v", err)
+		}
+		defer restored.Close()
+
+		err = restored.View(func(tx database.Tx) error {
+			if tx.Metadata().Get([]byte("beforebackup")) == nil {
+				return fmt.Errorf("key stored before the backup is missing")
+			}
+			if tx.Metadata().Get(blocks[numBlocks].Hash()[:]) != nil {
+				return fmt.Errorf("key stored after the backup exists")
+			}
+			for i, block := range blocks[:numBlocks+1] {
+				blockBytes, err := tx.FetchBlock(block.Hash())
+				if i == numBlocks {
+					if err == nil {
+						return fmt.Errorf("block %d stored after "+
+							"the backup exists", i)
+					}
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				wantBytes, _ := block.Bytes()
+				if !bytes.Equal(blockBytes, wantBytes) {
+					return fmt.Errorf("block %d mismatch", i)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("View: %v", err)
+		}
+
+		// The restored database must accept new blocks.
+		err = restored.Update(func(tx database.Tx) error {
+			return tx.StoreBlock(blocks[numBlocks])
+		})
+		if err != nil {
+			t.Fatalf("StoreBlock: unexpected error: %v", err)
+		}
+	}
+
+	// Restore the full backup to both database types.
+	for _, restoreType := range []string{dbType, "ffpebble"} {
+		restorePath := filepath.Join(os.TempDir(), "ffldb-restoretest-"+
+			restoreType)
+		_ = os.RemoveAll(restorePath)
+		defer os.RemoveAll(restorePath)
+
+		err := database.Restore(restoreType, func(w database.BackupWriter) error {
+			return database.ReadBackupArchive(bytes.NewReader(fullBackup), w)
+		}, restorePath, blockDataNet)
+		if err != nil {
+			t.Fatalf("Restore (%s): unexpected error: %v", restoreType, err)
+		}
+		checkRestored(restoreType, restorePath, 10)
+
+		// Restoring to an existing database must fail.
+		err = database.Restore(restoreType, func(w database.BackupWriter) error {
+			return database.ReadBackupArchive(bytes.NewReader(fullBackup), w)
+		}, restorePath, blockDataNet)
+		if !checkDbError(t, "Restore", err, database.ErrDbExists) {
+			return
+		}
+	}
+
+	// Restoring the metadata only backup fails until the block files are
+	// copied, which then are truncated to their size in the backup.
+	restorePath := filepath.Join(os.TempDir(), "ffldb-restoretest-metadata")
+	_ = os.RemoveAll(restorePath)
+	defer os.RemoveAll(restorePath)
+	replay := func(w database.BackupWriter) error {
+		return database.ReadBackupArchive(bytes.NewReader(metadataBackup), w)
+	}
+	err = database.Restore(dbType, replay, restorePath, blockDataNet)
+	if err == nil {
+		t.Fatal("Restore: unexpected success without block files")
+	}
+	blockFile, err := os.ReadFile(filepath.Join(dbPath, "000000000.fdb"))
+	if err != nil {
+		t.Fatalf("ReadFile: unexpected error: %v", err)
+	}
+	err = os.WriteFile(filepath.Join(restorePath, "000000000.fdb"),
+		blockFile, 0666)
+	if err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+	if err := database.Restore(dbType, replay, restorePath, blockDataNet); err != nil {
+		t.Fatalf("Restore: unexpected error: %v", err)
+	}
+	checkRestored(dbType, restorePath, 11)
+
+	// Ensure a corrupted archive is rejected.
+	corrupted := append([]byte(nil), fullBackup...)
+	corrupted[len(corrupted)/2] ^= 0xff
+	restorePath = filepath.Join(os.TempDir(), "ffldb-restoretest-corrupt")
+	_ = os.RemoveAll(restorePath)
+	defer os.RemoveAll(restorePath)
+	err = database.Restore(dbType, func(w database.BackupWriter) error {
+		return database.ReadBackupArchive(bytes.NewReader(corrupted), w)
+	}, restorePath, blockDataNet)
+	if err == nil {
+		t.Fatal("Restore: unexpected success with a corrupted archive")
+	}
+}
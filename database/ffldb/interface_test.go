@@ -1641,6 +1641,32 @@ func testBlockIOTxInterface(tc *testContext) bool {
 			return errSubTestFail
 		}
 
+		// Ensure iterating the stored block hashes returns all of the
+		// committed blocks.
+		wantHashes := make(map[chainhash.Hash]struct{}, len(tc.blocks))
+		for _, block := range tc.blocks {
+			wantHashes[*block.Hash()] = struct{}{}
+		}
+		var numHashes int
+		err := tx.ForEachBlockHash(func(hash *chainhash.Hash) error {
+			if _, ok := wantHashes[*hash]; !ok {
+				return fmt.Errorf("ForEachBlockHash: unexpected "+
+					"block %v", hash)
+			}
+			numHashes++
+			return nil
+		})
+		if err != nil {
+			tc.t.Errorf("%v", err)
+			return errSubTestFail
+		}
+		if numHashes != len(wantHashes) {
+			tc.t.Errorf("ForEachBlockHash: unexpected number of "+
+				"blocks - got %d, want %d", numHashes,
+				len(wantHashes))
+			return errSubTestFail
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -1926,6 +1952,13 @@ func testClosedTxInterface(tc *testContext, tx database.Tx) bool {
 		return false
 	}
 
+	// Ensure ForEachBlockHash returns expected error.
+	testName = "ForEachBlockHash on closed tx"
+	err = tx.ForEachBlockHash(func(*chainhash.Hash) error { return nil })
+	if !checkDbError(tc.t, testName, err, wantErrCode) {
+		return false
+	}
+
 	// ---------------
 	// Commit/Rollback
 	// ---------------
@@ -2256,6 +2289,79 @@ func testConcurrentClose(tc *testContext) bool {
 	return true
 }
 
+// testCompact ensures compacting the database works as expected and that the
+// deleted keys it reclaims are no longer reported afterwards.
+func testCompact(tc *testContext) bool {
+	// Store and then remove a number of keys so there is something to
+	// compact.
+	const numKeys = 100
+	keyName := func(i int) []byte {
+		return []byte(fmt.Sprintf("compactkey%d", i))
+	}
+	err := tc.db.Update(func(tx database.Tx) error {
+		for i := 0; i < numKeys; i++ {
+			err := tx.Metadata().Put(keyName(i), []byte("value"))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		tc.t.Errorf("Update: unexpected error: %v", err)
+		return false
+	}
+	err = tc.db.Update(func(tx database.Tx) error {
+		for i := 0; i < numKeys; i++ {
+			if err := tx.Metadata().Delete(keyName(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		tc.t.Errorf("Update: unexpected error: %v", err)
+		return false
+	}
+
+	if err := tc.db.Compact(); err != nil {
+		tc.t.Errorf("Compact: unexpected error: %v", err)
+		return false
+	}
+
+	info, err := tc.db.SpaceInfo()
+	if err != nil {
+		tc.t.Errorf("SpaceInfo: unexpected error: %v", err)
+		return false
+	}
+	if info.DeletedKeys != 0 {
+		tc.t.Errorf("SpaceInfo: unexpected deleted keys after "+
+			"compaction - got %d, want 0", info.DeletedKeys)
+		return false
+	}
+	if info.MetadataSize <= 0 || info.BlocksSize <= 0 {
+		tc.t.Errorf("SpaceInfo: unexpected sizes - metadata %d, "+
+			"blocks %d", info.MetadataSize, info.BlocksSize)
+		return false
+	}
+
+	// Ensure the remaining data is still intact after the compaction.
+	err = tc.db.View(func(tx database.Tx) error {
+		if tx.Metadata().Get(keyName(0)) != nil {
+			return fmt.Errorf("deleted key %s still exists",
+				keyName(0))
+		}
+		_, err := tx.FetchBlock(tc.blocks[0].Hash())
+		return err
+	})
+	if err != nil {
+		tc.t.Errorf("View: unexpected error: %v", err)
+		return false
+	}
+
+	return true
+}
+
 // testInterface tests performs tests for the various interfaces of the database
 // package which require state in the database for the given database type.
 func testInterface(t *testing.T, db database.DB) {
@@ -2295,6 +2401,11 @@ func testInterface(t *testing.T, db database.DB) {
 		return
 	}
 
+	// Test compacting the database and reporting its space usage.
+	if !testCompact(&context) {
+		return
+	}
+
 	// Test that closing the database with open transactions blocks until
 	// the transactions are finished.
 	//
@@ -0,0 +1,198 @@
+package ffldb
+
+import (
+	"github.com/btcsuite/goleveldb/leveldb"
+	"github.com/btcsuite/goleveldb/leveldb/filter"
+	"github.com/btcsuite/goleveldb/leveldb/iterator"
+	"github.com/btcsuite/goleveldb/leveldb/opt"
+	"github.com/btcsuite/goleveldb/leveldb/util"
+)
+
+// metadataStore is the key/value store which persists the metadata, such as
+// the buckets and the block index, while the blocks themselves are stored in
+// flat files.  It allows the store backing the database cache to be swapped
+// out without changing how the metadata is laid out.
+type metadataStore interface {
+	// Snapshot returns a consistent read-only view of the store at the
+	// current point in time.  The snapshot must be released after use by
+	// calling Release.
+	Snapshot() (metadataSnapshot, error)
+
+	// Update invokes the passed function with a writer whose puts and
+	// deletes are atomically applied to the store once the function
+	// returns.  Any error returned from the function causes the writes to
+	// be discarded and is returned from Update.
+	Update(fn func(w metadataWriter) error) error
+
+	// Compact compacts the entire store, which discards deleted and
+	// overwritten entries and reclaims their space.  Reads and writes are
+	// allowed while the store is compacted.
+	Compact() error
+
+	// Close closes the store.
+	Close() error
+}
+
+// metadataSnapshot is a consistent read-only view of a metadataStore.
+type metadataSnapshot interface {
+	// Has returns whether or not the passed key exists.
+	Has(key []byte) (bool, error)
+
+	// Get returns the value for the passed key, or nil when the key does
+	// not exist.
+	Get(key []byte) ([]byte, error)
+
+	// NewIterator returns an iterator over the keys within the passed
+	// range, which can be nil to iterate all keys.  The start key of the
+	// range is inclusive and the limit key is exclusive.  Just like a
+	// leveldb iterator, the returned iterator is not pointing to a valid
+	// item until it is positioned, and its keys and values are only valid
+	// until it is moved.
+	NewIterator(slice *util.Range) iterator.Iterator
+
+	// Release releases the snapshot.
+	Release()
+}
+
+// metadataWriter writes to a metadataStore within Update.
+type metadataWriter interface {
+	// Put sets the value for the passed key.
+	Put(key, value []byte) error
+
+	// Delete removes the passed key.
+	Delete(key []byte) error
+}
+
+// openMetadataStore opens the metadata store used by the passed database type
+// at the passed path, which is created when it doesn't exist yet.  An error is
+// returned when the create flag is set and the store already exists.
+func openMetadataStore(dbType, path string, create bool) (metadataStore, error) {
+	if dbType == pebbleDbType {
+		return openPebbleStore(path, create)
+	}
+	return openLdbStore(path, create)
+}
+
+// ldbStore is a metadataStore backed by leveldb.
+type ldbStore struct {
+	ldb *leveldb.DB
+}
+
+// Enforce ldbStore implements the metadataStore interface.
+var _ metadataStore = (*ldbStore)(nil)
+
+// openLdbStore opens the leveldb metadata store at the passed path, which is
+// created when it doesn't exist yet.  An error is returned when the create
+// flag is set and the store already exists.
+func openLdbStore(path string, create bool) (metadataStore, error) {
+	opts := opt.Options{
+		ErrorIfExist: create,
+		Strict:       opt.DefaultStrict,
+		Compression:  opt.NoCompression,
+		Filter:       filter.NewBloomFilter(10),
+	}
+	ldb, err := leveldb.OpenFile(path, &opts)
+	if err != nil {
+		return nil, convertErr(err.Error(), err)
+	}
+	return &ldbStore{ldb: ldb}, nil
+}
+
+// Snapshot returns a consistent read-only view of the store.
+//
+// This is part of the metadataStore interface implementation.
+func (s *ldbStore) Snapshot() (metadataSnapshot, error) {
+	snap, err := s.ldb.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &ldbSnapshot{snap: snap}, nil
+}
+
+// Update atomically applies the writes made by the passed function using a
+// leveldb transaction.
+//
+// This is part of the metadataStore interface implementation.
+func (s *ldbStore) Update(fn func(w metadataWriter) error) error {
+	ldbTx, err := s.ldb.OpenTransaction()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(ldbWriter{ldbTx}); err != nil {
+		ldbTx.Discard()
+		return err
+	}
+	return ldbTx.Commit()
+}
+
+// Compact compacts the entire key range of the underlying leveldb database.
+//
+// This is part of the metadataStore interface implementation.
+func (s *ldbStore) Compact() error {
+	return s.ldb.CompactRange(util.Range{})
+}
+
+// Close closes the underlying leveldb database.
+//
+// This is part of the metadataStore interface implementation.
+func (s *ldbStore) Close() error {
+	return s.ldb.Close()
+}
+
+// ldbSnapshot is a metadataSnapshot backed by a leveldb snapshot.
+type ldbSnapshot struct {
+	snap *leveldb.Snapshot
+}
+
+// Has returns whether or not the passed key exists.
+//
+// This is part of the metadataSnapshot interface implementation.
+func (s *ldbSnapshot) Has(key []byte) (bool, error) {
+	return s.snap.Has(key, nil)
+}
+
+// Get returns the value for the passed key, or nil when the key does not
+// exist.
+//
+// This is part of the metadataSnapshot interface implementation.
+func (s *ldbSnapshot) Get(key []byte) ([]byte, error) {
+	value, err := s.snap.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	return value, err
+}
+
+// NewIterator returns an iterator over the keys within the passed range.
+//
+// This is part of the metadataSnapshot interface implementation.
+func (s *ldbSnapshot) NewIterator(slice *util.Range) iterator.Iterator {
+	return s.snap.NewIterator(slice, nil)
+}
+
+// Release releases the snapshot.
+//
+// This is part of the metadataSnapshot interface implementation.
+func (s *ldbSnapshot) Release() {
+	s.snap.Release()
+}
+
+// ldbWriter is a metadataWriter which writes to a leveldb transaction.
+type ldbWriter struct {
+	ldbTx *leveldb.Transaction
+}
+
+// Put sets the value for the passed key.
+//
+// This is part of the metadataWriter interface implementation.
+func (w ldbWriter) Put(key, value []byte) error {
+	return w.ldbTx.Put(key, value, nil)
+}
+
+// Delete removes the passed key.
+//
+// This is part of the metadataWriter interface implementation.
+func (w ldbWriter) Delete(key []byte) error {
+	return w.ldbTx.Delete(key, nil)
+}
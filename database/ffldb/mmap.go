@@ -0,0 +1,143 @@
+package ffldb
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// errReadOnlyFile is returned when attempting to modify a memory-mapped block
+// file.
+var errReadOnlyFile = errors.New("memory-mapped block file is read-only")
+
+// vectoredReaderAt is implemented by filers which are able to read consecutive
+// data into multiple buffers at once.
+type vectoredReaderAt interface {
+	// ReadvAt reads consecutive data starting at the passed offset into
+	// the passed buffers in order.  It returns the total number of bytes
+	// read and an error when fewer bytes than the combined length of the
+	// buffers were read.
+	ReadvAt(bufs [][]byte, off int64) (int, error)
+}
+
+// readvAt reads consecutive data starting at the passed offset of the passed
+// file into the passed buffers.  Files which don't implement vectoredReaderAt
+// are read one buffer at a time.
+func readvAt(file filer, bufs [][]byte, off int64) (int, error) {
+	if vr, ok := file.(vectoredReaderAt); ok {
+		return vr.ReadvAt(bufs, off)
+	}
+
+	var total int
+	for _, buf := range bufs {
+		n, err := file.ReadAt(buf, off)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		off += int64(n)
+	}
+	return total, nil
+}
+
+// mmapFile is a filer for a read-only block file which serves reads from a
+// memory mapping of the file rather than with a system call per read.  Block
+// files other than the current write file are never modified, so the mapping
+// covers the entire file.
+//
+// The mapping is only valid until the file is closed, which the lockableFile
+// holding it guarantees does not happen while it is being read from.
+type mmapFile struct {
+	file *os.File
+	data []byte
+}
+
+// Enforce mmapFile implements the filer and vectoredReaderAt interfaces.
+var _ filer = (*mmapFile)(nil)
+var _ vectoredReaderAt = (*mmapFile)(nil)
+
+// openMappedFile returns a filer for the passed read-only block file which
+// serves reads from a memory mapping of it.  The file itself is returned when
+// memory mapping is not supported on the platform or the file could not be
+// mapped, such as when it is empty.
+func openMappedFile(file *os.File) filer {
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 || int64(int(info.Size())) != info.Size() {
+		return file
+	}
+
+	data, err := mmapFileData(file, int(info.Size()))
+	if err != nil {
+		log.Debugf("Unable to memory map %s, falling back to reads: %v",
+			file.Name(), err)
+		return file
+	}
+	return &mmapFile{file: file, data: data}
+}
+
+// ReadAt copies the mapped data at the passed offset into the passed buffer.
+//
+// This is part of the filer interface implementation.
+func (f *mmapFile) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.data[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// ReadvAt copies the mapped data starting at the passed offset into the passed
+// buffers.
+//
+// This is part of the vectoredReaderAt interface implementation.
+func (f *mmapFile) ReadvAt(bufs [][]byte, off int64) (int, error) {
+	var total int
+	for _, buf := range bufs {
+		n, err := f.ReadAt(buf, off)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		off += int64(n)
+	}
+	return total, nil
+}
+
+// WriteAt always fails since the file is read-only.
+//
+// This is part of the filer interface implementation.
+func (f *mmapFile) WriteAt(b []byte, off int64) (int, error) {
+	return 0, errReadOnlyFile
+}
+
+// Truncate always fails since the file is read-only.
+//
+// This is part of the filer interface implementation.
+func (f *mmapFile) Truncate(size int64) error {
+	return errReadOnlyFile
+}
+
+// Sync does nothing since the file is read-only.
+//
+// This is part of the filer interface implementation.
+func (f *mmapFile) Sync() error {
+	return nil
+}
+
+// Close unmaps the file and closes it.
+//
+// This is part of the filer interface implementation.
+func (f *mmapFile) Close() error {
+	err := munmapFileData(f.data)
+	f.data = nil
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
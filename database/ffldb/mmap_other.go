@@ -0,0 +1,20 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package ffldb
+
+import (
+	"errors"
+	"os"
+)
+
+// mmapFileData always fails since memory mapping the block files is not
+// supported on this platform, in which case they are read from directly.
+func mmapFileData(file *os.File, size int) ([]byte, error) {
+	return nil, errors.New("memory mapping is not supported")
+}
+
+// munmapFileData does nothing since nothing is ever mapped on this platform.
+func munmapFileData(data []byte) error {
+	return nil
+}
@@ -0,0 +1,21 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package ffldb
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapFileData maps the first size bytes of the passed file into memory
+// read-only.
+func mmapFileData(file *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ,
+		syscall.MAP_SHARED)
+}
+
+// munmapFileData unmaps data previously mapped with mmapFileData.
+func munmapFileData(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,301 @@
+package ffldb
+
+import (
+	"errors"
+
+	"github.com/btcsuite/goleveldb/leveldb/iterator"
+	"github.com/btcsuite/goleveldb/leveldb/util"
+	"github.com/cockroachdb/pebble"
+)
+
+// pebbleStore is a metadataStore backed by pebble.  Unlike leveldb, pebble
+// compacts concurrently with writes, which avoids the write stalls leveldb
+// suffers from during the initial block download, particularly on spinning
+// disks.
+type pebbleStore struct {
+	pdb *pebble.DB
+}
+
+// Enforce pebbleStore implements the metadataStore interface.
+var _ metadataStore = (*pebbleStore)(nil)
+
+// pebbleLogger forwards the log messages of pebble to the package logger.
+type pebbleLogger struct{}
+
+// Infof logs the passed message at the debug level since pebble is rather
+// chatty about its internal operations.
+func (pebbleLogger) Infof(format string, args ...interface{}) {
+	log.Debugf(format, args...)
+}
+
+// Fatalf logs the passed message at the critical level and panics.
+func (pebbleLogger) Fatalf(format string, args ...interface{}) {
+	log.Criticalf(format, args...)
+	panic(errors.New("pebble: fatal error"))
+}
+
+// openPebbleStore opens the pebble metadata store at the passed path, which is
+// created when it doesn't exist yet.  An error is returned when the create flag
+// is set and the store already exists.
+func openPebbleStore(path string, create bool) (metadataStore, error) {
+	opts := &pebble.Options{
+		ErrorIfExists: create,
+		Logger:        pebbleLogger{},
+	}
+	pdb, err := pebble.Open(path, opts)
+	if err != nil {
+		return nil, convertErr(err.Error(), err)
+	}
+	return &pebbleStore{pdb: pdb}, nil
+}
+
+// Snapshot returns a consistent read-only view of the store.
+//
+// This is part of the metadataStore interface implementation.
+func (s *pebbleStore) Snapshot() (metadataSnapshot, error) {
+	return &pebbleSnapshot{snap: s.pdb.NewSnapshot()}, nil
+}
+
+// Update atomically applies the writes made by the passed function using a
+// pebble batch.
+//
+// This is part of the metadataStore interface implementation.
+func (s *pebbleStore) Update(fn func(w metadataWriter) error) error {
+	batch := s.pdb.NewBatch()
+	defer batch.Close()
+
+	if err := fn(pebbleWriter{batch}); err != nil {
+		return err
+	}
+	return batch.Commit(pebble.Sync)
+}
+
+// Compact compacts the entire key range of the underlying pebble database.
+//
+// This is part of the metadataStore interface implementation.
+func (s *pebbleStore) Compact() error {
+	// Pebble requires explicit bounds, so determine the first and last
+	// keys in the store.
+	iter, err := s.pdb.NewIter(nil)
+	if err != nil {
+		return err
+	}
+	var first, last []byte
+	if iter.First() {
+		first = copySlice(iter.Key())
+	}
+	if iter.Last() {
+		last = copySlice(iter.Key())
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+	if first == nil || last == nil {
+		return nil
+	}
+
+	// The end of the range is exclusive, so extend it past the last key.
+	return s.pdb.Compact(first, append(last, 0x00), true)
+}
+
+// Close closes the underlying pebble database.
+//
+// This is part of the metadataStore interface implementation.
+func (s *pebbleStore) Close() error {
+	return s.pdb.Close()
+}
+
+// pebbleSnapshot is a metadataSnapshot backed by a pebble snapshot.
+type pebbleSnapshot struct {
+	snap *pebble.Snapshot
+}
+
+// Has returns whether or not the passed key exists.
+//
+// This is part of the metadataSnapshot interface implementation.
+func (s *pebbleSnapshot) Has(key []byte) (bool, error) {
+	_, closer, err := s.snap.Get(key)
+	if err == pebble.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, closer.Close()
+}
+
+// Get returns the value for the passed key, or nil when the key does not
+// exist.
+//
+// This is part of the metadataSnapshot interface implementation.
+func (s *pebbleSnapshot) Get(key []byte) ([]byte, error) {
+	value, closer, err := s.snap.Get(key)
+	if err == pebble.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The value is only valid until the closer is closed.
+	value = copySlice(value)
+	return value, closer.Close()
+}
+
+// NewIterator returns an iterator over the keys within the passed range.
+//
+// This is part of the metadataSnapshot interface implementation.
+func (s *pebbleSnapshot) NewIterator(slice *util.Range) iterator.Iterator {
+	var opts pebble.IterOptions
+	if slice != nil {
+		opts.LowerBound = slice.Start
+		opts.UpperBound = slice.Limit
+	}
+	iter, err := s.snap.NewIter(&opts)
+	if err != nil {
+		return iterator.NewEmptyIterator(err)
+	}
+	return &pebbleIterator{iter: iter}
+}
+
+// Release releases the snapshot.
+//
+// This is part of the metadataSnapshot interface implementation.
+func (s *pebbleSnapshot) Release() {
+	s.snap.Close()
+}
+
+// pebbleIterator wraps a pebble iterator to satisfy the leveldb
+// iterator.Iterator interface, which is what the database cache iterators are
+// built on.
+type pebbleIterator struct {
+	iter       *pebble.Iterator
+	positioned bool
+	released   bool
+	releaser   util.Releaser
+}
+
+// Enforce pebbleIterator implements the leveldb iterator.Iterator interface.
+var _ iterator.Iterator = (*pebbleIterator)(nil)
+
+// First moves the iterator to the first key/value pair.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *pebbleIterator) First() bool {
+	iter.positioned = true
+	return iter.iter.First()
+}
+
+// Last moves the iterator to the last key/value pair.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *pebbleIterator) Last() bool {
+	iter.positioned = true
+	return iter.iter.Last()
+}
+
+// Seek moves the iterator to the first key/value pair whose key is greater
+// than or equal to the given key.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *pebbleIterator) Seek(key []byte) bool {
+	iter.positioned = true
+	return iter.iter.SeekGE(key)
+}
+
+// Next moves the iterator to the next key/value pair.  An iterator which is
+// not positioned yet is moved to the first pair, just like a leveldb iterator.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *pebbleIterator) Next() bool {
+	if !iter.positioned {
+		return iter.First()
+	}
+	return iter.iter.Next()
+}
+
+// Prev moves the iterator to the previous key/value pair.  An iterator which is
+// not positioned yet is moved to the last pair, just like a leveldb iterator.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *pebbleIterator) Prev() bool {
+	if !iter.positioned {
+		return iter.Last()
+	}
+	return iter.iter.Prev()
+}
+
+// Valid returns whether the iterator is positioned at a valid key/value pair.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *pebbleIterator) Valid() bool {
+	return iter.positioned && !iter.released && iter.iter.Valid()
+}
+
+// Key returns the current key the iterator is pointing to.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *pebbleIterator) Key() []byte {
+	if !iter.Valid() {
+		return nil
+	}
+	return iter.iter.Key()
+}
+
+// Value returns the current value the iterator is pointing to.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *pebbleIterator) Value() []byte {
+	if !iter.Valid() {
+		return nil
+	}
+	return iter.iter.Value()
+}
+
+// Error returns any accumulated error.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *pebbleIterator) Error() error {
+	return iter.iter.Error()
+}
+
+// SetReleaser sets a releaser which is invoked when the iterator is released.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *pebbleIterator) SetReleaser(releaser util.Releaser) {
+	iter.releaser = releaser
+}
+
+// Release closes the iterator.
+//
+// This is part of the leveldb iterator.Iterator interface implementation.
+func (iter *pebbleIterator) Release() {
+	if iter.released {
+		return
+	}
+	iter.released = true
+	iter.iter.Close()
+	if iter.releaser != nil {
+		iter.releaser.Release()
+		iter.releaser = nil
+	}
+}
+
+// pebbleWriter is a metadataWriter which writes to a pebble batch.
+type pebbleWriter struct {
+	batch *pebble.Batch
+}
+
+// Put sets the value for the passed key.
+//
+// This is part of the metadataWriter interface implementation.
+func (w pebbleWriter) Put(key, value []byte) error {
+	return w.batch.Set(key, value, nil)
+}
+
+// Delete removes the passed key.
+//
+// This is part of the metadataWriter interface implementation.
+func (w pebbleWriter) Delete(key []byte) error {
+	return w.batch.Delete(key, nil)
+}
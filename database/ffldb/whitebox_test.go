@@ -8,6 +8,7 @@
 package ffldb
 
 import (
+	"bytes"
 	"compress/bzip2"
 	"encoding/binary"
 	"fmt"
@@ -20,6 +21,7 @@ import (
 	"github.com/btcsuite/goleveldb/leveldb"
 	ldberrors "github.com/btcsuite/goleveldb/leveldb/errors"
 	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
 	"github.com/gcash/bchd/database"
 	"github.com/gcash/bchd/wire"
 	"github.com/gcash/bchutil"
@@ -189,7 +191,7 @@ func TestCornerCases(t *testing.T) {
 	// directory is needed.
 	testName := "openDB: fail due to file at target location"
 	wantErrCode := database.ErrDriverSpecific
-	idb, err := openDB(dbPath, blockDataNet, true, 0, 0)
+	idb, err := openDB(dbType, dbPath, blockDataNet, true, 0, 0)
 	if !checkDbError(t, testName, err, wantErrCode) {
 		if err == nil {
 			idb.Close()
@@ -201,7 +203,7 @@ func TestCornerCases(t *testing.T) {
 	// Remove the file and create the database to run tests against.  It
 	// should be successful this time.
 	_ = os.RemoveAll(dbPath)
-	idb, err = openDB(dbPath, blockDataNet, true, 0, 0)
+	idb, err = openDB(dbType, dbPath, blockDataNet, true, 0, 0)
 	if err != nil {
 		t.Errorf("openDB: unexpected error: %v", err)
 		return
@@ -225,14 +227,14 @@ func TestCornerCases(t *testing.T) {
 	_ = os.RemoveAll(filePath)
 
 	// Close the underlying leveldb database out from under the database.
-	ldb := idb.(*db).cache.ldb
-	ldb.Close()
+	metaDB := idb.(*db).cache.metaDB
+	metaDB.Close()
 
 	// Ensure initilization errors in the underlying database work as
 	// expected.
 	testName = "initDB: reinitialization"
 	wantErrCode = database.ErrDbNotOpen
-	err = initDB(ldb)
+	err = initDB(metaDB)
 	if !checkDbError(t, testName, err, wantErrCode) {
 		return
 	}
@@ -714,3 +716,87 @@ func TestFailureScenarios(t *testing.T) {
 	// Test various corruption scenarios.
 	testCorruption(tc)
 }
+
+// TestMappedBlockFiles ensures blocks are read correctly from memory-mapped
+// block files, including when multiple blocks are fetched at once and read with
+// vectored reads.
+func TestMappedBlockFiles(t *testing.T) {
+	// Create a new database to run tests against.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-mappedblockfiles")
+	_ = os.RemoveAll(dbPath)
+	idb, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer idb.Close()
+
+	// Change the maximum file size to a small value to force multiple flat
+	// files with the test data set so most of the blocks are read from
+	// read-only files.
+	store := idb.(*db).store
+	store.maxBlockFileSize = 2048
+
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		t.Fatalf("loadBlocks: Unexpected error: %v", err)
+	}
+	err = idb.Update(func(tx database.Tx) error {
+		for _, block := range blocks {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StoreBlock: unexpected error: %v", err)
+	}
+
+	// Fetch the blocks in reverse order along with a duplicate to ensure
+	// the results are returned in the requested order regardless of how
+	// they are grouped into reads.
+	hashes := make([]chainhash.Hash, 0, len(blocks)+1)
+	for i := len(blocks) - 1; i >= 0; i-- {
+		hashes = append(hashes, *blocks[i].Hash())
+	}
+	hashes = append(hashes, *blocks[0].Hash())
+	err = idb.View(func(tx database.Tx) error {
+		gotBlocks, err := tx.FetchBlocks(hashes)
+		if err != nil {
+			return err
+		}
+		for i := range hashes {
+			gotBlock, err := tx.FetchBlock(&hashes[i])
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(gotBlocks[i], gotBlock) {
+				return fmt.Errorf("FetchBlocks #%d: mismatched "+
+					"block %s", i, hashes[i])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("FetchBlocks: unexpected error: %v", err)
+	}
+
+	// Ensure reads past the end of a mapped file fail.
+	for fileNum, blockFile := range store.openBlockFiles {
+		mapped, ok := blockFile.file.(*mmapFile)
+		if !ok {
+			continue
+		}
+		var buf [8]byte
+		size := int64(len(mapped.data))
+		if _, err := mapped.ReadAt(buf[:], size-4); err != io.EOF {
+			t.Fatalf("ReadAt file %d: unexpected error - got %v, "+
+				"want %v", fileNum, err, io.EOF)
+		}
+		if _, err := mapped.ReadAt(buf[:], size); err != io.EOF {
+			t.Fatalf("ReadAt file %d: unexpected error - got %v, "+
+				"want %v", fileNum, err, io.EOF)
+		}
+	}
+}
@@ -260,6 +260,19 @@ type Tx interface {
 	// Other errors are possible depending on the implementation.
 	HasBlocks(hashes []chainhash.Hash) ([]bool, error)
 
+	// ForEachBlockHash invokes the passed function with the hash of every
+	// block stored in the database, in no particular order.  Blocks which
+	// are still pending to be stored by the transaction are not included.
+	// When the function returns an error, the iteration is stopped and the
+	// error is returned to the caller.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrTxClosed if the transaction has already been closed
+	//
+	// Other errors are possible depending on the implementation.
+	ForEachBlockHash(fn func(hash *chainhash.Hash) error) error
+
 	// FetchBlockHeader returns the raw serialized bytes for the block
 	// header identified by the given hash.  The raw bytes are in the format
 	// returned by Serialize on a wire.BlockHeader.
@@ -470,8 +483,39 @@ type DB interface {
 	// user-supplied function will result in a panic.
 	Update(fn func(tx Tx) error) error
 
+	// Compact compacts the underlying metadata storage, which reclaims the
+	// space held by deleted and overwritten keys.  The database remains
+	// usable while it is compacted, however, compacting a large database
+	// is an expensive operation which may take a long time.
+	Compact() error
+
+	// SpaceInfo returns information about the disk space used by the
+	// database.
+	SpaceInfo() (*SpaceInfo, error)
+
+	// Backup writes a consistent snapshot of the database to the passed
+	// backup writer while the database remains usable.  The contents of
+	// the block files are only written when the include blocks flag is
+	// set.
+	Backup(w BackupWriter, includeBlocks bool) error
+
 	// Close cleanly shuts down the database and syncs all data.  It will
 	// block until all database transactions have been finalized (rolled
 	// back or committed).
 	Close() error
 }
+
+// SpaceInfo houses information about the disk space used by a database.
+type SpaceInfo struct {
+	// MetadataSize is the size of the metadata on disk in bytes.
+	MetadataSize int64
+
+	// BlocksSize is the size of the stored blocks on disk in bytes.
+	BlocksSize int64
+
+	// DeletedKeys is the number of metadata keys removed from the
+	// underlying storage since the database was opened or last compacted.
+	// The space held by deleted keys is only reclaimed once the database
+	// is compacted.
+	DeletedKeys uint64
+}
@@ -63,6 +63,13 @@ Application Options:
 	    --notls               Disable TLS for the RPC server -- NOTE: This is only
 	                          allowed if the RPC server is bound to localhost
 	    --nodnsseed           Disable DNS seeding for peers
+	    --seederhost=         Run a DNS seeder which crawls the network and
+	                          answers DNS queries for this host name with the
+	                          addresses of good nodes
+	    --seedernameserver=   Host name of the name server returned for NS
+	                          queries to the DNS seeder
+	    --seederlisten=       Add an interface/port for the DNS seeder to answer
+	                          UDP queries on (default port: 53)
 	    --externalip=         Add an ip to the list of local addresses we claim to
 	                          listen on to peers
 	    --proxy=              Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)
@@ -78,9 +85,27 @@ Application Options:
 	    --testnet             Use the test network
 	    --regtest             Use the regression test network
 	    --simnet              Use the simulation test network
+	    --scalenet            Use the scaling test network
+	    --signet              Use the signed test network
+	    --signetchallenge=    Hex encoded challenge script block signatures
+	                          must satisfy on the signed test network, which
+	                          selects a custom signed test network instead of
+	                          the default one
+	    --customnet=          Use a custom network with the parameters loaded
+	                          from the given JSON file
 	    --addcheckpoint=      Add a custom checkpoint.  Format: '<height>:<hash>'
 	    --nocheckpoints       Disable built-in checkpoints.  Don't do this unless
 	                          you know what you're doing.
+	    --minimumchainwork=   Hex encoded minimum cumulative work of the main
+	                          chain, which overrides the one of the network.
+	                          The node is not considered synced before the main
+	                          chain has this much work and rejects headers
+	                          forking it from blocks with less work afterwards
+	    --assumevalid=        Hash of a block which is assumed to be valid,
+	                          which overrides the one of the network.  The
+	                          scripts of the block and its ancestors are not
+	                          validated while all other rules are still
+	                          enforced.  Use 0 to validate all scripts
 	    --uacomment=          Comment to add to the user agent --
 	                          See BIP 14 for more information.
 	    --dbtype=             Database backend to use for the Block Chain (ffldb)
@@ -92,9 +117,22 @@ Application Options:
 	                          <subsystem>=<level>,<subsystem2>=<level>,... to set
 	                          the log level for individual subsystems -- Use show
 	                          to list available subsystems (info)
-	    --upnp                Use UPnP to map our listening port outside of NAT
+	    --logformat=          Format of the log output {text, json} -- json writes
+	                          each message as a JSON record with the time, level,
+	                          subsystem and message (text)
+	    --logsampling=        Log at most <n> trace, debug and info messages with
+	                          the same format per subsystem and second -- 0 to
+	                          disable
+	    --upnp                Use PCP, NAT-PMP or UPnP to map our listening port
+	                          outside of NAT and open an IPv6 pinhole with PCP
 	    --minrelaytxfee=      The minimum transaction fee in BCH/kB to be
 	                          considered a non-zero fee.
+	    --dustrelayfee=       Do not accept transactions with outputs whose value
+	                          is less than three times the fee in BCH/kB of
+	                          spending them -- 0 to disable (1e-05)
+	    --maxtxsigchecks=     Do not accept transactions whose scripts perform
+	                          more than <n> signature checks -- 0 to disable
+	                          (3000)
 	    --limitfreerelay=     Limit relay of transactions with no transaction fee
 	                          to the given amount in thousands of bytes per
 	                          minute (15)
@@ -102,6 +140,12 @@ Application Options:
 	                          high priority for relaying
 	    --maxorphantx=        Max number of orphan transactions to keep in memory
 	                          (100)
+	    --mempoolexpiry=      Remove transactions from the memory pool which were
+	                          not confirmed within the given duration -- 0 to
+	                          disable (336h0m0s)
+	    --mempoolreplacement  Accept transactions which replace a memory pool
+	                          transaction spending the same outputs by paying a
+	                          higher fee
 	    --generate            Generate (mine) bitcoins using the CPU
 	    --miningaddr=         Add the specified payment address to the list of
 	                          addresses to use for generated blocks -- At least
@@ -114,17 +158,58 @@ Application Options:
 	    --blockprioritysize=  Size in bytes for high-priority/low-fee transactions
 	                          when creating a block (50000)
 	    --nopeerbloomfilters  Disable bloom filtering support.
+	    --maxbloomfiltersize= Max size in bytes of the bloom filter a peer may
+	                          load (36000)
+	    --maxbloomfiltermem=  Max memory in KiB used by the bloom filters of all
+	                          peers, 0 disables the limit (4096)
+	    --maxbloomfilteradds= Max number of filteradd messages a peer may send
+	                          for a single loaded bloom filter, 0 disables the
+	                          limit (1000)
+	    --nomerkleblocknet=   Add an IP network or IP whose peers may not load
+	                          bloom filters and are not served merkle blocks
 	    --nocfilters          Disable committed filtering (CF) support.
 	    --sigcachemaxsize=    The maximum number of entries in the signature
 	                          verification cache.
-	    --blocksonly          Do not accept transactions from remote peers.
+	    --scriptcachemaxsize= The maximum number of entries in the script
+	                          verification cache.
+	    --blocksonly          Do not accept transactions from remote peers and
+	                          only download full blocks. Peers are asked not to
+	                          relay transactions and are disconnected when they
+	                          announce them anyway.
+	    --weakblocks          Relay weak blocks, which are blocks meeting a
+	                          fraction of the proof of work target found by
+	                          miners, with peers which support them and stage
+	                          their transactions for the reconstruction of
+	                          compact blocks.
+	    --txreconciliation    Relay transactions to peers which support it by
+	                          periodic set reconciliation instead of announcing
+	                          each of them, which saves bandwidth at the cost of
+	                          slower propagation.
 	    --relaynonstd         Relay non-standard transactions regardless of the
 	                          default settings for the active network.
 	    --rejectnonstd        Reject non-standard transactions regardless of the
 	                          default settings for the active network.
+	    --firstseen           Record the time and announcing peer of the first
+	                          sighting of each block and transaction and make
+	                          them available via the getfirstseen RPC
+	    --firstseenexport=    Append each first sighting of a block or
+	                          transaction to the specified file as a JSON line
+	                          -- Requires --firstseen
+	    --hotwallet           Hold the keys of BIP0044 wallets created from
+	                          BIP0039 mnemonics and make the gRPC methods which
+	                          send from them available -- Requires --watchonly
+	                          and --hotwalletauthtoken
+	    --hotwalletauthtoken= The token which authenticates the callers of the
+	                          hot wallet gRPC methods -- The seeds are
+	                          encrypted with the passphrases of the wallets
+	                          instead
 
 Help Options:
 
 	-h, --help           Show this help message
+
+The debuglevel, addpeer, RPC user and relay policy options may be reloaded from
+the config file without restarting by sending SIGHUP or issuing the reloadconfig
+RPC command.
 */
 package main
@@ -0,0 +1,17 @@
+/*
+Package firstseen implements a tracker of the time blocks and transactions were
+first seen on the network, which supports propagation research and debugging
+the latency of miners.
+
+For each block and transaction, the tracker records when and from which peer it
+was first announced, by an inv, headers or cmpctblock message, and when and
+from which peer its full data was first received.  The time between the two is
+the time it took to download it, while the time of the first announcement can be
+compared between nodes to measure how blocks and transactions propagate.
+
+The records are kept in memory only.  The number of records of each kind is
+bounded, and the oldest records are forgotten first.  The records may also be
+exported to a writer as JSON lines as they are made, so they can be collected
+over longer periods and analyzed elsewhere.
+*/
+package firstseen
@@ -0,0 +1,259 @@
+package firstseen
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+)
+
+const (
+	// DefaultMaxBlocks is the default number of block records kept.
+	DefaultMaxBlocks = 10000
+
+	// DefaultMaxTxns is the default number of transaction records kept.
+	DefaultMaxTxns = 200000
+)
+
+// Kind identifies whether a record describes a block or a transaction.
+type Kind string
+
+// The kinds of records.
+const (
+	KindBlock Kind = "block"
+	KindTx    Kind = "tx"
+)
+
+// Source names the message a block or transaction was seen in.
+type Source string
+
+// The sources blocks and transactions are seen in.
+const (
+	SourceInv        Source = "inv"
+	SourceHeaders    Source = "headers"
+	SourceCmpctBlock Source = "cmpctblock"
+	SourceBlock      Source = "block"
+	SourceTx         Source = "tx"
+)
+
+// Sighting describes when, from which peer and in which message a block or
+// transaction was seen.
+type Sighting struct {
+	Time   time.Time
+	Peer   string
+	Source Source
+}
+
+// Record holds the first sightings of a block or transaction.
+type Record struct {
+	Hash chainhash.Hash
+	Kind Kind
+
+	// Announced is the first time the block or transaction was announced
+	// or received, whichever happened first.
+	Announced Sighting
+
+	// Received is the first time the full data of the block or
+	// transaction was received.  It is nil until then.
+	Received *Sighting
+}
+
+// Config is a descriptor containing the tracker configuration.
+type Config struct {
+	// MaxBlocks and MaxTxns are the number of block and transaction
+	// records kept.  They default to DefaultMaxBlocks and DefaultMaxTxns.
+	MaxBlocks int
+	MaxTxns   int
+
+	// Export is the optional writer every new sighting is written to as a
+	// JSON line.  Writing stops after the first error.
+	Export io.Writer
+
+	// Now returns the current time.  It defaults to time.Now.
+	Now func() time.Time
+}
+
+// records holds the records of one kind along with the order they were made
+// in, which is used to forget the oldest records first.
+type records struct {
+	byHash map[chainhash.Hash]*Record
+	order  []chainhash.Hash
+	max    int
+}
+
+// add adds the passed record, forgetting the oldest record when the maximum
+// number of records is exceeded.
+func (r *records) add(record *Record) {
+	if len(r.order) >= r.max {
+		delete(r.byHash, r.order[0])
+		r.order[0] = chainhash.Hash{}
+		r.order = r.order[1:]
+	}
+	r.byHash[record.Hash] = record
+	r.order = append(r.order, record.Hash)
+}
+
+// Tracker records the first sightings of blocks and transactions.
+type Tracker struct {
+	mtx    sync.Mutex
+	blocks records
+	txns   records
+	export *json.Encoder
+	now    func() time.Time
+}
+
+// New returns a new tracker with the passed configuration.
+func New(cfg *Config) *Tracker {
+	maxBlocks := cfg.MaxBlocks
+	if maxBlocks <= 0 {
+		maxBlocks = DefaultMaxBlocks
+	}
+	maxTxns := cfg.MaxTxns
+	if maxTxns <= 0 {
+		maxTxns = DefaultMaxTxns
+	}
+	now := cfg.Now
+	if now == nil {
+		now = time.Now
+	}
+
+	t := &Tracker{
+		blocks: records{
+			byHash: make(map[chainhash.Hash]*Record),
+			max:    maxBlocks,
+		},
+		txns: records{
+			byHash: make(map[chainhash.Hash]*Record),
+			max:    maxTxns,
+		},
+		now: now,
+	}
+	if cfg.Export != nil {
+		t.export = json.NewEncoder(cfg.Export)
+	}
+	return t
+}
+
+// exportedSighting is the JSON line written for every new sighting.
+type exportedSighting struct {
+	Kind   Kind   `json:"kind"`
+	Hash   string `json:"hash"`
+	Event  string `json:"event"`
+	Source Source `json:"source"`
+	Peer   string `json:"peer"`
+	Time   int64  `json:"time"`
+}
+
+// Announced records the announcement of the block or transaction with the
+// passed hash by the passed peer when it is the first one.
+//
+// This function is safe for concurrent access.
+func (t *Tracker) Announced(kind Kind, hash *chainhash.Hash, source Source, peer string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if record := t.lookup(kind, hash); record != nil {
+		return
+	}
+	sighting := Sighting{Time: t.now(), Peer: peer, Source: source}
+	t.recordsOf(kind).add(&Record{
+		Hash:      *hash,
+		Kind:      kind,
+		Announced: sighting,
+	})
+	t.exportSighting(kind, hash, "announced", &sighting)
+}
+
+// Received records the receipt of the full data of the block or transaction
+// with the passed hash from the passed peer when it is the first one.  The
+// receipt also counts as the announcement when the block or transaction was not
+// announced before.
+//
+// This function is safe for concurrent access.
+func (t *Tracker) Received(kind Kind, hash *chainhash.Hash, source Source, peer string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	sighting := Sighting{Time: t.now(), Peer: peer, Source: source}
+	record := t.lookup(kind, hash)
+	if record == nil {
+		record = &Record{
+			Hash:      *hash,
+			Kind:      kind,
+			Announced: sighting,
+		}
+		t.recordsOf(kind).add(record)
+		t.exportSighting(kind, hash, "announced", &sighting)
+	}
+	if record.Received != nil {
+		return
+	}
+	record.Received = &sighting
+	t.exportSighting(kind, hash, "received", &sighting)
+}
+
+// Lookup returns a copy of the record of the block or transaction with the
+// passed hash.  Blocks are looked up before transactions.  It returns nil when
+// there is no record of the hash.
+//
+// This function is safe for concurrent access.
+func (t *Tracker) Lookup(hash *chainhash.Hash) *Record {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	record := t.lookup(KindBlock, hash)
+	if record == nil {
+		record = t.lookup(KindTx, hash)
+	}
+	if record == nil {
+		return nil
+	}
+
+	recordCopy := *record
+	if record.Received != nil {
+		received := *record.Received
+		recordCopy.Received = &received
+	}
+	return &recordCopy
+}
+
+// lookup returns the record of the passed kind with the passed hash or nil
+// when there is none.
+//
+// This function MUST be called with the tracker lock held.
+func (t *Tracker) lookup(kind Kind, hash *chainhash.Hash) *Record {
+	return t.recordsOf(kind).byHash[*hash]
+}
+
+// recordsOf returns the records of the passed kind.
+func (t *Tracker) recordsOf(kind Kind) *records {
+	if kind == KindBlock {
+		return &t.blocks
+	}
+	return &t.txns
+}
+
+// exportSighting writes the passed sighting to the export writer if one is
+// configured.  Exporting stops after the first error.
+//
+// This function MUST be called with the tracker lock held.
+func (t *Tracker) exportSighting(kind Kind, hash *chainhash.Hash, event string, sighting *Sighting) {
+	if t.export == nil {
+		return
+	}
+	err := t.export.Encode(&exportedSighting{
+		Kind:   kind,
+		Hash:   hash.String(),
+		Event:  event,
+		Source: sighting.Source,
+		Peer:   sighting.Peer,
+		Time:   sighting.Time.UnixNano() / int64(time.Millisecond),
+	})
+	if err != nil {
+		log.Errorf("Unable to export first seen record, exporting "+
+			"stopped: %v", err)
+		t.export = nil
+	}
+}
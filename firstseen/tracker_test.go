@@ -0,0 +1,136 @@
+package firstseen
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+)
+
+// testClock returns a time source for tests which advances by one second each
+// time it is read.
+func testClock() func() time.Time {
+	now := time.Unix(1600000000, 0)
+	return func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+}
+
+// TestFirstSightings ensures only the first announcement and receipt of blocks
+// and transactions are recorded.
+func TestFirstSightings(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	tracker := New(&Config{Now: testClock()})
+
+	blockHash := chainhash.Hash{0x01}
+	txHash := chainhash.Hash{0x02}
+	tracker.Announced(KindBlock, &blockHash, SourceHeaders, "1.2.3.4:8333")
+	tracker.Announced(KindBlock, &blockHash, SourceInv, "1.2.3.5:8333")
+	tracker.Received(KindBlock, &blockHash, SourceBlock, "1.2.3.5:8333")
+	tracker.Received(KindBlock, &blockHash, SourceBlock, "1.2.3.4:8333")
+	tracker.Received(KindTx, &txHash, SourceTx, "1.2.3.6:8333")
+
+	record := tracker.Lookup(&blockHash)
+	if record == nil {
+		t.Fatal("Lookup: no record of block")
+	}
+	wantAnnounced := Sighting{
+		Time:   start.Add(time.Second),
+		Peer:   "1.2.3.4:8333",
+		Source: SourceHeaders,
+	}
+	wantReceived := Sighting{
+		Time:   start.Add(2 * time.Second),
+		Peer:   "1.2.3.5:8333",
+		Source: SourceBlock,
+	}
+	if record.Kind != KindBlock || record.Announced != wantAnnounced ||
+		record.Received == nil || *record.Received != wantReceived {
+
+		t.Fatalf("Lookup: unexpected block record %+v", record)
+	}
+
+	// A transaction received without an announcement is announced by its
+	// receipt.
+	record = tracker.Lookup(&txHash)
+	if record == nil {
+		t.Fatal("Lookup: no record of transaction")
+	}
+	if record.Kind != KindTx || record.Received == nil ||
+		record.Announced != *record.Received ||
+		record.Announced.Source != SourceTx {
+
+		t.Fatalf("Lookup: unexpected transaction record %+v", record)
+	}
+
+	// Records are copied so they can't be modified by callers.
+	record.Received.Peer = "modified"
+	if tracker.Lookup(&txHash).Received.Peer != "1.2.3.6:8333" {
+		t.Fatal("Lookup: record modified through returned copy")
+	}
+
+	if record := tracker.Lookup(&chainhash.Hash{0x03}); record != nil {
+		t.Fatalf("Lookup: unexpected record %+v of unknown hash", record)
+	}
+}
+
+// TestRecordLimits ensures the oldest records of each kind are forgotten first
+// once the limits are exceeded.
+func TestRecordLimits(t *testing.T) {
+	tracker := New(&Config{MaxBlocks: 2, MaxTxns: 3, Now: testClock()})
+
+	for i := byte(0); i < 5; i++ {
+		tracker.Announced(KindBlock, &chainhash.Hash{0x01, i}, SourceInv,
+			"1.2.3.4:8333")
+		tracker.Announced(KindTx, &chainhash.Hash{0x02, i}, SourceInv,
+			"1.2.3.4:8333")
+	}
+
+	for i := byte(0); i < 5; i++ {
+		haveBlock := tracker.Lookup(&chainhash.Hash{0x01, i}) != nil
+		if haveBlock != (i >= 3) {
+			t.Fatalf("Lookup: block %d recorded %v", i, haveBlock)
+		}
+		haveTx := tracker.Lookup(&chainhash.Hash{0x02, i}) != nil
+		if haveTx != (i >= 2) {
+			t.Fatalf("Lookup: transaction %d recorded %v", i, haveTx)
+		}
+	}
+}
+
+// TestExport ensures each first sighting is exported as a JSON line.
+func TestExport(t *testing.T) {
+	var buf bytes.Buffer
+	tracker := New(&Config{Export: &buf, Now: testClock()})
+
+	blockHash := chainhash.Hash{0x01}
+	tracker.Announced(KindBlock, &blockHash, SourceCmpctBlock, "1.2.3.4:8333")
+	tracker.Announced(KindBlock, &blockHash, SourceInv, "1.2.3.5:8333")
+	tracker.Received(KindBlock, &blockHash, SourceCmpctBlock, "1.2.3.4:8333")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Export: got %d lines, want 2: %q", len(lines), lines)
+	}
+	wantEvents := []string{"announced", "received"}
+	for i, line := range lines {
+		var exported exportedSighting
+		if err := json.Unmarshal([]byte(line), &exported); err != nil {
+			t.Fatalf("Export: line %d is not valid JSON: %v", i, err)
+		}
+		wantTime := time.Unix(1600000000, 0).Add(time.Duration(i+1)*
+			time.Second).UnixNano() / int64(time.Millisecond)
+		if exported.Kind != KindBlock ||
+			exported.Hash != blockHash.String() ||
+			exported.Event != wantEvents[i] ||
+			exported.Source != SourceCmpctBlock ||
+			exported.Peer != "1.2.3.4:8333" || exported.Time != wantTime {
+
+			t.Fatalf("Export: unexpected line %d: %s", i, line)
+		}
+	}
+}
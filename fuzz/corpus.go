@@ -0,0 +1,336 @@
+package fuzz
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+)
+
+// Seed is an input of a fuzz target built from a reference test vector.
+type Seed struct {
+	// Name identifies the test vector.
+	Name string
+
+	// Data is the input of the target.
+	Data []byte
+
+	// Outcome is the outcome the reference implementation expects.
+	Outcome Outcome
+}
+
+// TxSeeds returns the seeds of the tx target built from the transaction test
+// vectors in the passed txscript data directory.  The transactions of both
+// the valid and the invalid vectors deserialize successfully, they are only
+// invalid by the rules of their scripts.
+func TxSeeds(dataDir string) ([]Seed, error) {
+	var seeds []Seed
+	for _, fileName := range []string{"tx_valid.json", "tx_invalid.json"} {
+		file, err := os.ReadFile(filepath.Join(dataDir, fileName))
+		if err != nil {
+			return nil, err
+		}
+		var tests [][]interface{}
+		if err := json.Unmarshal(file, &tests); err != nil {
+			return nil, fmt.Errorf("%s: %v", fileName, err)
+		}
+
+		for i, test := range tests {
+			// Tests of a single string are comments.
+			if len(test) < 2 {
+				continue
+			}
+			serializedTx, ok := test[1].(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: test %d has no "+
+					"serialized transaction", fileName, i)
+			}
+			data, err := hex.DecodeString(serializedTx)
+			if err != nil {
+				return nil, fmt.Errorf("%s: test %d: %v", fileName,
+					i, err)
+			}
+			seeds = append(seeds, Seed{
+				Name:    fmt.Sprintf("%s/%d", fileName, i),
+				Data:    data,
+				Outcome: Accepted,
+			})
+		}
+	}
+	return seeds, nil
+}
+
+// vmbRuleSets maps the directories of the VMB test vectors to the rule sets of
+// the scripts target.
+var vmbRuleSets = map[string]byte{
+	"bch_2023": 0,
+	"bch_2025": 1,
+}
+
+// ScriptSeeds returns the seeds of the scripts target built from the VMB test
+// vectors in the passed directory.  The transactions of the standard and
+// nonstandard vectors are valid by the consensus rules, while the ones of the
+// invalid vectors are not.  The benchmark vectors are skipped since their large
+// transactions slow down fuzzing without covering more of the VM.
+func ScriptSeeds(vmbDir string) ([]Seed, error) {
+	paths, err := filepath.Glob(filepath.Join(vmbDir, "*", "*.vmb_tests.json.gz"))
+	if err != nil {
+		return nil, err
+	}
+
+	var seeds []Seed
+	for _, path := range paths {
+		if strings.HasPrefix(filepath.Base(path), "core.benchmarks.") {
+			continue
+		}
+		dir := filepath.Base(filepath.Dir(path))
+		split := strings.LastIndex(dir, "_")
+		if split < 0 {
+			return nil, fmt.Errorf("unknown VMB test directory %s", dir)
+		}
+		ruleSet, ok := vmbRuleSets[dir[:split]]
+		if !ok {
+			return nil, fmt.Errorf("unknown VMB test directory %s", dir)
+		}
+		outcome := Accepted
+		if dir[split+1:] == "invalid" {
+			outcome = Rejected
+		}
+
+		file, err := txscript.ReadGzFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var tests [][]interface{}
+		if err := json.Unmarshal(file, &tests); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+
+		name := filepath.Join(dir, filepath.Base(path))
+		for i, test := range tests {
+			if len(test) < 6 {
+				return nil, fmt.Errorf("%s: test %d is too short",
+					name, i)
+			}
+			id, _ := test[0].(string)
+			tx, err1 := hexField(test[4])
+			utxos, err2 := hexField(test[5])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("%s: test %s has invalid "+
+					"transaction or outputs", name, id)
+			}
+
+			data := make([]byte, 0, 1+len(tx)+len(utxos))
+			data = append(data, ruleSet)
+			data = append(data, tx...)
+			data = append(data, utxos...)
+			seeds = append(seeds, Seed{
+				Name:    name + "/" + id,
+				Data:    data,
+				Outcome: outcome,
+			})
+		}
+	}
+	return seeds, nil
+}
+
+// hexField decodes a hex encoded JSON string.
+func hexField(field interface{}) ([]byte, error) {
+	str, ok := field.(string)
+	if !ok {
+		return nil, fmt.Errorf("field is not a string")
+	}
+	return hex.DecodeString(str)
+}
+
+// TokenDataSeeds returns the seeds of the tokendata target built from the
+// output scripts which carry tokens of the transactions and spent outputs of
+// the passed seeds of the scripts target which are accepted.
+func TokenDataSeeds(scriptSeeds []Seed) []Seed {
+	seen := make(map[string]struct{})
+	var seeds []Seed
+	addOutput := func(name string, txOut *wire.TxOut) {
+		if txOut.TokenData.IsEmpty() {
+			return
+		}
+		buf := txOut.TokenData.TokenDataBuffer()
+		buf.Write(txOut.PkScript)
+		if _, ok := seen[buf.String()]; ok {
+			return
+		}
+		seen[buf.String()] = struct{}{}
+		seeds = append(seeds, Seed{
+			Name:    name,
+			Data:    buf.Bytes(),
+			Outcome: Accepted,
+		})
+	}
+
+	for _, seed := range scriptSeeds {
+		if seed.Outcome != Accepted {
+			continue
+		}
+		var msgTx wire.MsgTx
+		r := bytes.NewReader(seed.Data[1:])
+		if err := msgTx.BchDecode(r, 0, wire.BaseEncoding); err != nil {
+			continue
+		}
+		for i, txOut := range msgTx.TxOut {
+			addOutput(fmt.Sprintf("%s/out%d", seed.Name, i), txOut)
+		}
+		count, err := wire.ReadVarInt(r, 0)
+		if err != nil {
+			continue
+		}
+		for i := uint64(0); i < count; i++ {
+			var txOut wire.TxOut
+			if _, err := wire.ReadTxOut(r, 0, 0, &txOut); err != nil {
+				break
+			}
+			addOutput(fmt.Sprintf("%s/utxo%d", seed.Name, i), &txOut)
+		}
+	}
+	return seeds
+}
+
+// ebaaConfig is the configuration of the EBAA test vectors.
+type ebaaConfig struct {
+	Epsilon0        string `json:"epsilon0"`
+	Beta0           string `json:"beta0"`
+	N0              uint64 `json:"n0"`
+	Zeta            uint64 `json:"zeta"`
+	GammaReciprocal uint64 `json:"gammaReciprocal"`
+	Delta           uint64 `json:"delta"`
+	ThetaReciprocal uint64 `json:"thetaReciprocal"`
+	Options         string `json:"options"`
+}
+
+// ebaaState is an ABLA state of the EBAA test vectors.
+type ebaaState struct {
+	N       uint64 `json:"n"`
+	Epsilon string `json:"epsilon"`
+	Beta    string `json:"beta"`
+}
+
+// ebaaTests are the EBAA test vectors of a single file.
+type ebaaTests struct {
+	Config  ebaaConfig `json:"ABLAConfig"`
+	Initial ebaaState  `json:"ABLAStateInitial"`
+	Vectors []struct {
+		Blocksize string    `json:"blocksize"`
+		Next      ebaaState `json:"ABLAStateForNextBlock"`
+		Lookahead string    `json:"lookahead"`
+	} `json:"testVector"`
+}
+
+// ABLASeeds returns the seeds of the ABLA target built from the EBAA test
+// vectors in the passed directory.  Each file whose configuration is one of
+// the target becomes a seed of its block sizes up to the first look-ahead
+// vector, which the target doesn't support.  Files which lift the temporary
+// limit of the block size to 2GB are skipped.
+func ABLASeeds(ebaaDir string) ([]Seed, error) {
+	paths, err := filepath.Glob(filepath.Join(ebaaDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var seeds []Seed
+	for _, path := range paths {
+		file, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var tests ebaaTests
+		if err := json.Unmarshal(file, &tests); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if tests.Config.Options != "" {
+			continue
+		}
+		configIdx := ablaConstantsIndex(&tests.Config)
+		if configIdx < 0 {
+			continue
+		}
+
+		data := []byte{byte(configIdx)}
+		data = appendUint64(data, tests.Initial.N)
+		data = appendUint64(data, parseUint64(tests.Initial.Epsilon))
+		data = appendUint64(data, parseUint64(tests.Initial.Beta))
+		final := tests.Initial
+		for _, vector := range tests.Vectors {
+			if parseUint64(vector.Lookahead) != 0 {
+				break
+			}
+			data = appendUint64(data, parseUint64(vector.Blocksize))
+			final = vector.Next
+		}
+
+		seeds = append(seeds, Seed{
+			Name: filepath.Base(path),
+			Data: data,
+			Outcome: Outcome(fmt.Sprintf("%d:%d:%d", final.N,
+				parseUint64(final.Epsilon), parseUint64(final.Beta))),
+		})
+	}
+	return seeds, nil
+}
+
+// ablaConstantsIndex returns the index of the ABLA configuration of the
+// target which matches the passed EBAA configuration, or -1 when there is
+// none.
+func ablaConstantsIndex(config *ebaaConfig) int {
+	for i, constants := range ablaConstants {
+		if *constants == (chaincfg.ABLAConstants{
+			Epsilon0:        parseUint64(config.Epsilon0),
+			Beta0:           parseUint64(config.Beta0),
+			N0:              config.N0,
+			GammaReciprocal: config.GammaReciprocal,
+			ZetaXB7:         config.Zeta,
+			ThetaReciprocal: config.ThetaReciprocal,
+			Delta:           config.Delta,
+		}) {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseUint64 parses a decimal number of the EBAA test vectors, which is zero
+// when it is empty.
+func parseUint64(str string) uint64 {
+	n, _ := strconv.ParseUint(str, 10, 64)
+	return n
+}
+
+// appendUint64 appends a big-endian 64-bit integer.
+func appendUint64(data []byte, n uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], n)
+	return append(data, buf[:]...)
+}
+
+// WriteCorpus writes the inputs of the passed seeds to a corpus directory in
+// the format of go-fuzz, which is a file per input named by its hash.
+func WriteCorpus(dir string, seeds []Seed) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	for _, seed := range seeds {
+		hash := sha256.Sum256(seed.Data)
+		path := filepath.Join(dir, hex.EncodeToString(hash[:]))
+		if err := os.WriteFile(path, seed.Data, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+/*
+Package fuzz implements deterministic fuzz targets for the consensus critical
+components of bchd.
+
+Each target runs a single input through a component and returns its outcome,
+which is "ok" or "rejected" for the targets which accept or reject inputs and
+the resulting state for the ABLA target.  An error is returned when an
+invariant of the component is violated, such as a deserialized message which
+doesn't serialize to the same bytes again.  The targets are:
+
+  - tx, block and message: wire deserialization of transactions, blocks and
+    network messages
+  - tokendata: parsing of the CashToken prefix of output scripts
+  - scripts: sanity, input and script validation of a transaction spending
+    the passed outputs under the May 2023 or May 2025 consensus rules
+  - abla: state transitions of the adaptive block size limit algorithm
+
+Targets don't depend on anything but their input, so an input which violates
+an invariant reproduces the violation every time.
+
+# Corpus
+
+Seeds are built from the reference test vectors in the repository, which are
+shared with Bitcoin Cash Node and Libauth: the transaction vectors of txscript,
+the VMB test vectors and the EBAA (ABLA) vectors of blockchain.  Seeds carry
+the outcome the reference implementation expects.  They can be written to a
+directory for go-fuzz with WriteCorpus.
+
+# Differential Comparison
+
+Compare runs a target on an input and compares its outcome with the one an
+Oracle expects.  VectorOracle knows the outcomes of the seeds, and other
+implementations of the Oracle interface may query a reference node for the
+inputs generated by the fuzzer.
+
+# Running
+
+The native fuzz tests of the package run the seeds as part of go test, only a
+sample of them in short mode, and fuzzing is started with:
+
+	go test -run=^$ -fuzz=^FuzzScripts$ ./fuzz
+
+The go-fuzz entry points are built with the gofuzz build tag:
+
+	go-fuzz-build -func GoFuzzScripts github.com/gcash/bchd/fuzz
+*/
+package fuzz
@@ -0,0 +1,226 @@
+package fuzz
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/wire"
+)
+
+// knownMismatches are the VMB test vector files whose invalid transactions
+// bchd is known to accept.  bchd doesn't limit the results of the arithmetic
+// opcodes to the maximum length of VM numbers.
+var knownMismatches = []string{
+	"bch_2025_invalid/core.bigint-limits.binary.vmb_tests.json.gz/",
+	"bch_2025_invalid/core.bigint-limits.unary.vmb_tests.json.gz/",
+}
+
+var (
+	seedsOnce sync.Once
+	seeds     map[string][]Seed
+	oracle    VectorOracle
+	seedsErr  error
+)
+
+// loadSeeds builds the seeds of all targets from the test vectors of the
+// repository and the oracle which knows their outcomes.
+func loadSeeds() (map[string][]Seed, VectorOracle, error) {
+	seedsOnce.Do(func() {
+		seeds = make(map[string][]Seed)
+		oracle = make(VectorOracle)
+
+		txSeeds, err := TxSeeds(filepath.Join("..", "txscript", "data"))
+		if err != nil {
+			seedsErr = err
+			return
+		}
+		scriptSeeds, err := ScriptSeeds(filepath.Join("..", "txscript",
+			"data", "vmb_tests"))
+		if err != nil {
+			seedsErr = err
+			return
+		}
+		ablaSeeds, err := ABLASeeds(filepath.Join("..", "blockchain",
+			"testdata", "ebaa_vectors"))
+		if err != nil {
+			seedsErr = err
+			return
+		}
+
+		seeds["tx"] = txSeeds
+		seeds["block"] = blockSeeds()
+		seeds["message"] = messageSeeds()
+		seeds["tokendata"] = TokenDataSeeds(scriptSeeds)
+		seeds["scripts"] = scriptSeeds
+		seeds["abla"] = ablaSeeds
+
+		for target, targetSeeds := range seeds {
+			var known []Seed
+			for _, seed := range targetSeeds {
+				if !isKnownMismatch(seed.Name) {
+					known = append(known, seed)
+				}
+			}
+			oracle.Add(target, known)
+		}
+	})
+	return seeds, oracle, seedsErr
+}
+
+// isKnownMismatch returns whether the seed with the passed name is built from
+// a test vector of the known mismatches.
+func isKnownMismatch(name string) bool {
+	for _, prefix := range knownMismatches {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockSeeds returns the seeds of the block target, which are the genesis
+// blocks of the networks.
+func blockSeeds() []Seed {
+	var seeds []Seed
+	for _, params := range []*chaincfg.Params{&chaincfg.MainNetParams,
+		&chaincfg.TestNet3Params, &chaincfg.RegressionNetParams} {
+
+		var buf bytes.Buffer
+		if err := params.GenesisBlock.Serialize(&buf); err != nil {
+			continue
+		}
+		seeds = append(seeds, Seed{
+			Name:    params.Name,
+			Data:    buf.Bytes(),
+			Outcome: Accepted,
+		})
+	}
+	return seeds
+}
+
+// messageSeeds returns the seeds of the message target, which are a few
+// messages of the main network.
+func messageSeeds() []Seed {
+	genesis := chaincfg.MainNetParams.GenesisBlock
+	genesisHash := genesis.BlockHash()
+	getBlocks := wire.NewMsgGetBlocks(&genesisHash)
+	getBlocks.AddBlockLocatorHash(&genesisHash)
+	inv := wire.NewMsgInv()
+	inv.AddInvVect(wire.NewInvVect(wire.InvTypeBlock, &genesisHash))
+	msgs := []wire.Message{
+		wire.NewMsgVerAck(),
+		wire.NewMsgPing(1),
+		wire.NewMsgFeeFilter(1000),
+		getBlocks,
+		inv,
+		genesis,
+		genesis.Transactions[0],
+	}
+
+	var seeds []Seed
+	for _, msg := range msgs {
+		var buf bytes.Buffer
+		_, err := wire.WriteMessageN(&buf, msg, wire.ProtocolVersion,
+			wire.MainNet)
+		if err != nil {
+			continue
+		}
+		seeds = append(seeds, Seed{
+			Name:    msg.Command(),
+			Data:    buf.Bytes(),
+			Outcome: Accepted,
+		})
+	}
+	return seeds
+}
+
+// shortSeedInterval is the interval of the seeds of a target which are run
+// in short mode.
+const shortSeedInterval = 20
+
+// fuzzTarget adds the seeds of the named target to the fuzz test and fuzzes
+// the target, comparing the outcomes of the seeds with the reference outcomes.
+func fuzzTarget(f *testing.F, target string) {
+	seeds, oracle, err := loadSeeds()
+	if err != nil {
+		f.Fatalf("unable to load seeds: %v", err)
+	}
+	for i, seed := range seeds[target] {
+		if testing.Short() && i%shortSeedInterval != 0 {
+			continue
+		}
+		f.Add(seed.Data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if _, err := Compare(oracle, target, data); err != nil {
+			t.Fatalf("%v for input %x", err, data)
+		}
+	})
+}
+
+func FuzzTx(f *testing.F)        { fuzzTarget(f, "tx") }
+func FuzzBlock(f *testing.F)     { fuzzTarget(f, "block") }
+func FuzzMessage(f *testing.F)   { fuzzTarget(f, "message") }
+func FuzzTokenData(f *testing.F) { fuzzTarget(f, "tokendata") }
+func FuzzScripts(f *testing.F)   { fuzzTarget(f, "scripts") }
+func FuzzABLA(f *testing.F)      { fuzzTarget(f, "abla") }
+
+// TestSeedOutcomes ensures the seeds of every target are built and that the
+// known mismatches are still mismatches, so they are removed from the list
+// once bchd agrees with the reference implementation.
+func TestSeedOutcomes(t *testing.T) {
+	seeds, _, err := loadSeeds()
+	if err != nil {
+		t.Fatalf("unable to load seeds: %v", err)
+	}
+	for target := range Targets {
+		if len(seeds[target]) == 0 {
+			t.Errorf("no seeds for target %s", target)
+		}
+	}
+
+	for _, prefix := range knownMismatches {
+		var mismatched bool
+		for _, seed := range seeds["scripts"] {
+			if !strings.HasPrefix(seed.Name, prefix) {
+				continue
+			}
+			outcome, err := Scripts(seed.Data)
+			if err != nil {
+				t.Fatalf("%s: %v", seed.Name, err)
+			}
+			if outcome != seed.Outcome {
+				mismatched = true
+				break
+			}
+		}
+		if !mismatched {
+			t.Errorf("%s is no longer a known mismatch", prefix)
+		}
+	}
+}
+
+// TestWriteCorpus ensures the corpus is written as a file per distinct input.
+func TestWriteCorpus(t *testing.T) {
+	seeds, _, err := loadSeeds()
+	if err != nil {
+		t.Fatalf("unable to load seeds: %v", err)
+	}
+	dir := t.TempDir()
+	if err := WriteCorpus(dir, seeds["abla"]); err != nil {
+		t.Fatalf("WriteCorpus: %v", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatalf("unable to list corpus: %v", err)
+	}
+	if len(files) != len(seeds["abla"]) {
+		t.Fatalf("got %d corpus files for %d seeds", len(files),
+			len(seeds["abla"]))
+	}
+}
@@ -0,0 +1,36 @@
+//go:build gofuzz
+// +build gofuzz
+
+package fuzz
+
+// goFuzz runs the named target for go-fuzz.  It panics when an invariant of
+// the target is violated so go-fuzz records the input as a crasher, and
+// returns 1 for accepted inputs to give them priority in the corpus.
+func goFuzz(target string, data []byte) int {
+	outcome, err := Compare(nil, target, data)
+	if err != nil {
+		panic(err)
+	}
+	if outcome == Rejected {
+		return 0
+	}
+	return 1
+}
+
+// GoFuzzTx is the go-fuzz entry point of the tx target.
+func GoFuzzTx(data []byte) int { return goFuzz("tx", data) }
+
+// GoFuzzBlock is the go-fuzz entry point of the block target.
+func GoFuzzBlock(data []byte) int { return goFuzz("block", data) }
+
+// GoFuzzMessage is the go-fuzz entry point of the message target.
+func GoFuzzMessage(data []byte) int { return goFuzz("message", data) }
+
+// GoFuzzTokenData is the go-fuzz entry point of the tokendata target.
+func GoFuzzTokenData(data []byte) int { return goFuzz("tokendata", data) }
+
+// GoFuzzScripts is the go-fuzz entry point of the scripts target.
+func GoFuzzScripts(data []byte) int { return goFuzz("scripts", data) }
+
+// GoFuzzABLA is the go-fuzz entry point of the abla target.
+func GoFuzzABLA(data []byte) int { return goFuzz("abla", data) }
@@ -0,0 +1,87 @@
+package fuzz
+
+import (
+	"fmt"
+)
+
+// Oracle returns the outcome a reference implementation expects for an input
+// of a target.  It returns false when the outcome is unknown, as it is for
+// most of the inputs generated by the fuzzer.
+type Oracle interface {
+	Outcome(target string, data []byte) (Outcome, bool)
+}
+
+// VectorOracle is an Oracle which knows the outcomes of seeds built from
+// reference test vectors.
+type VectorOracle map[string]map[string]Outcome
+
+// Add adds the outcomes of the passed seeds of a target.
+func (o VectorOracle) Add(target string, seeds []Seed) {
+	outcomes, ok := o[target]
+	if !ok {
+		outcomes = make(map[string]Outcome, len(seeds))
+		o[target] = outcomes
+	}
+	for _, seed := range seeds {
+		outcomes[string(seed.Data)] = seed.Outcome
+	}
+}
+
+// Outcome returns the outcome of the seed of the target with the passed input.
+//
+// This is part of the Oracle interface.
+func (o VectorOracle) Outcome(target string, data []byte) (Outcome, bool) {
+	outcome, ok := o[target][string(data)]
+	return outcome, ok
+}
+
+// MismatchError describes an input for which a target and the reference
+// implementation disagree.
+type MismatchError struct {
+	Target    string
+	Outcome   Outcome
+	Reference Outcome
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("%s: outcome %q differs from the reference outcome %q",
+		e.Target, e.Outcome, e.Reference)
+}
+
+// Compare runs the named target on an input twice and compares its outcome
+// with the one the oracle expects, if any.  The oracle may be nil.  An error
+// is returned when an invariant of the target is violated, the outcome of the
+// second run differs, since the targets must be deterministic, or the outcome
+// differs from the reference outcome, in which case it is a *MismatchError.
+func Compare(oracle Oracle, target string, data []byte) (Outcome, error) {
+	fn, ok := Targets[target]
+	if !ok {
+		return "", fmt.Errorf("unknown fuzz target %s", target)
+	}
+
+	outcome, err := fn(data)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", target, err)
+	}
+	again, err := fn(data)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v on the second run", target, err)
+	}
+	if again != outcome {
+		return "", fmt.Errorf("%s: outcome %q of the second run differs "+
+			"from %q", target, again, outcome)
+	}
+
+	if oracle == nil {
+		return outcome, nil
+	}
+	if reference, ok := oracle.Outcome(target, data); ok && reference != outcome {
+		return outcome, &MismatchError{
+			Target:    target,
+			Outcome:   outcome,
+			Reference: reference,
+		}
+	}
+	return outcome, nil
+}
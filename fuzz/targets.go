@@ -0,0 +1,395 @@
+package fuzz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// Outcome is the result of running a target on an input.
+type Outcome string
+
+const (
+	// Accepted is the outcome of an input which a target accepts.
+	Accepted Outcome = "ok"
+
+	// Rejected is the outcome of an input which a target rejects.
+	Rejected Outcome = "rejected"
+)
+
+// Func runs a fuzz target on an input.  It returns the outcome of the input
+// and an error when an invariant of the fuzzed component is violated.
+type Func func(data []byte) (Outcome, error)
+
+// Targets are the fuzz targets by name.
+var Targets = map[string]Func{
+	"tx":        Tx,
+	"block":     Block,
+	"message":   Message,
+	"tokendata": TokenData,
+	"scripts":   Scripts,
+	"abla":      ABLA,
+}
+
+// Tx deserializes a transaction.  The input is accepted when it is exactly one
+// transaction, which must serialize to the same bytes again.
+func Tx(data []byte) (Outcome, error) {
+	var tx wire.MsgTx
+	r := bytes.NewReader(data)
+	if err := tx.Deserialize(r); err != nil || r.Len() != 0 {
+		return Rejected, nil
+	}
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return "", fmt.Errorf("unable to serialize transaction: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		return "", fmt.Errorf("transaction serializes to %x", buf.Bytes())
+	}
+	if tx.SerializeSize() != len(data) {
+		return "", fmt.Errorf("serialize size %d of transaction is not "+
+			"its length %d", tx.SerializeSize(), len(data))
+	}
+	return Accepted, nil
+}
+
+// Block deserializes a block.  The input is accepted when it is exactly one
+// block, which must serialize to the same bytes again and whose transaction
+// locations must point to the serialized transactions.
+func Block(data []byte) (Outcome, error) {
+	var block wire.MsgBlock
+	r := bytes.NewReader(data)
+	if err := block.Deserialize(r); err != nil || r.Len() != 0 {
+		return Rejected, nil
+	}
+
+	var buf bytes.Buffer
+	if err := block.Serialize(&buf); err != nil {
+		return "", fmt.Errorf("unable to serialize block: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		return "", fmt.Errorf("block serializes to %x", buf.Bytes())
+	}
+
+	var locBlock wire.MsgBlock
+	txLocs, err := locBlock.DeserializeTxLoc(bytes.NewBuffer(data))
+	if err != nil {
+		return "", fmt.Errorf("unable to deserialize transaction "+
+			"locations: %v", err)
+	}
+	if len(txLocs) != len(block.Transactions) {
+		return "", fmt.Errorf("got %d transaction locations for %d "+
+			"transactions", len(txLocs), len(block.Transactions))
+	}
+	for i, tx := range block.Transactions {
+		buf.Reset()
+		if err := tx.Serialize(&buf); err != nil {
+			return "", fmt.Errorf("unable to serialize transaction "+
+				"%d: %v", i, err)
+		}
+		loc := txLocs[i]
+		if !bytes.Equal(data[loc.TxStart:loc.TxStart+loc.TxLen], buf.Bytes()) {
+			return "", fmt.Errorf("location %d:%d of transaction %d "+
+				"doesn't hold it", loc.TxStart, loc.TxLen, i)
+		}
+	}
+	return Accepted, nil
+}
+
+// Message reads a network message of the main network.  The input is accepted
+// when it starts with a message, which must be written and read back to the
+// same message.
+func Message(data []byte) (Outcome, error) {
+	_, msg, _, err := wire.ReadMessageN(bytes.NewReader(data),
+		wire.ProtocolVersion, wire.MainNet)
+	if err != nil {
+		return Rejected, nil
+	}
+
+	var first, second bytes.Buffer
+	if _, err := wire.WriteMessageN(&first, msg, wire.ProtocolVersion,
+		wire.MainNet); err != nil {
+		return "", fmt.Errorf("unable to write %s message: %v",
+			msg.Command(), err)
+	}
+	_, reread, _, err := wire.ReadMessageN(bytes.NewReader(first.Bytes()),
+		wire.ProtocolVersion, wire.MainNet)
+	if err != nil {
+		return "", fmt.Errorf("unable to read written %s message: %v",
+			msg.Command(), err)
+	}
+	if _, err := wire.WriteMessageN(&second, reread, wire.ProtocolVersion,
+		wire.MainNet); err != nil {
+		return "", fmt.Errorf("unable to write read %s message: %v",
+			msg.Command(), err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		return "", fmt.Errorf("%s message is written as %x and then as "+
+			"%x", msg.Command(), first.Bytes(), second.Bytes())
+	}
+	return Accepted, nil
+}
+
+// TokenData separates the CashToken prefix from an output script.  The input
+// is accepted when it has no prefix or a valid one, in which case the prefix
+// and the remaining script must serialize to the input again.
+func TokenData(data []byte) (Outcome, error) {
+	var tokenData wire.TokenData
+	pkScript, err := tokenData.SeparateTokenDataFromPKScriptIfExists(data, 0)
+	if err != nil {
+		return Rejected, nil
+	}
+
+	if len(data) < wire.BASE_TOKEN_DATA_LENGTH || data[0] != wire.PREFIX_BYTE {
+		if !bytes.Equal(pkScript, data) {
+			return "", fmt.Errorf("script without prefix parsed as %x",
+				pkScript)
+		}
+		return Accepted, nil
+	}
+
+	if !tokenData.IsValidBitfield() {
+		return "", fmt.Errorf("accepted invalid bitfield %x",
+			tokenData.BitField)
+	}
+	if tokenData.HasCommitmentLength() && (len(tokenData.Commitment) == 0 ||
+		len(tokenData.Commitment) > wire.MAX_COMMITMENT_LENGTH) {
+		return "", fmt.Errorf("accepted commitment of %d bytes",
+			len(tokenData.Commitment))
+	}
+	if tokenData.HasAmount() && (tokenData.Amount == 0 ||
+		tokenData.Amount > wire.MAX_FT_AMOUNT) {
+		return "", fmt.Errorf("accepted amount %d", tokenData.Amount)
+	}
+
+	buf := tokenData.TokenDataBuffer()
+	buf.Write(pkScript)
+	if !bytes.Equal(buf.Bytes(), data) {
+		return "", fmt.Errorf("token data serializes to %x", buf.Bytes())
+	}
+	return Accepted, nil
+}
+
+// scriptRuleSets are the script flags blocks are validated with after the
+// upgrades selected by the first byte of the input of the scripts target.
+var scriptRuleSets = []txscript.ScriptFlags{
+	// May 2023, which activated CashTokens.
+	txscript.ScriptBip16 |
+		txscript.ScriptVerifyDERSignatures |
+		txscript.ScriptVerifyCheckLockTimeVerify |
+		txscript.ScriptVerifyStrictEncoding |
+		txscript.ScriptVerifyBip143SigHash |
+		txscript.ScriptVerifyLowS |
+		txscript.ScriptVerifyNullFail |
+		txscript.ScriptVerifySigPushOnly |
+		txscript.ScriptVerifyCleanStack |
+		txscript.ScriptVerifyCheckDataSig |
+		txscript.ScriptVerifySchnorr |
+		txscript.ScriptVerifyAllowSegwitRecovery |
+		txscript.ScriptVerifyMinimalData |
+		txscript.ScriptVerifySchnorrMultisig |
+		txscript.ScriptReportSigChecks |
+		txscript.ScriptVerifyReverseBytes |
+		txscript.ScriptVerify64BitIntegers |
+		txscript.ScriptVerifyNativeIntrospection |
+		txscript.ScriptAllowCashTokens |
+		txscript.ScriptVerifyCheckSequenceVerify,
+
+	// May 2025, which activated the VM limits and big integers.
+	txscript.ScriptBip16 |
+		txscript.ScriptVerifyDERSignatures |
+		txscript.ScriptVerifyCheckLockTimeVerify |
+		txscript.ScriptVerifyStrictEncoding |
+		txscript.ScriptVerifyBip143SigHash |
+		txscript.ScriptVerifyLowS |
+		txscript.ScriptVerifyNullFail |
+		txscript.ScriptVerifySigPushOnly |
+		txscript.ScriptVerifyCleanStack |
+		txscript.ScriptVerifyCheckDataSig |
+		txscript.ScriptVerifySchnorr |
+		txscript.ScriptVerifyAllowSegwitRecovery |
+		txscript.ScriptVerifyMinimalData |
+		txscript.ScriptVerifySchnorrMultisig |
+		txscript.ScriptReportSigChecks |
+		txscript.ScriptVerifyReverseBytes |
+		txscript.ScriptVerify64BitIntegers |
+		txscript.ScriptVerifyNativeIntrospection |
+		txscript.ScriptAllowCashTokens |
+		txscript.ScriptAllowMay2025 |
+		txscript.ScriptVerifyCheckSequenceVerify,
+}
+
+// scriptsTxHeight is the height the transactions of the scripts target are
+// validated at.  The outputs they spend are one block older.
+var scriptsTxHeight = chaincfg.MainNetParams.Upgrade9ForkHeight + 1
+
+// scriptsSigCache caches the valid signatures of the scripts target.  Since
+// only valid signatures are cached it doesn't change the outcome of any input,
+// while it saves verifying the signatures of the seeds again on every run.
+var scriptsSigCache = txscript.NewSigCache(100000)
+
+// Scripts validates a transaction spending the passed outputs.  The input is
+// the rule set, followed by the serialized transaction and the outputs it
+// spends in the format of the VMB test vectors, which is the number of outputs
+// followed by the serialized outputs in the order of the inputs.
+//
+// The input is accepted when the transaction passes the sanity checks, the
+// checks of its inputs including the CashToken rules and the validation of its
+// scripts with the consensus rules of the rule set.
+func Scripts(data []byte) (Outcome, error) {
+	if len(data) == 0 || int(data[0]) >= len(scriptRuleSets) {
+		return Rejected, nil
+	}
+	flags := scriptRuleSets[data[0]]
+
+	var msgTx wire.MsgTx
+	r := bytes.NewReader(data[1:])
+	if err := msgTx.BchDecode(r, 0, wire.BaseEncoding); err != nil {
+		return Rejected, nil
+	}
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil || count != uint64(len(msgTx.TxIn)) {
+		return Rejected, nil
+	}
+	view := blockchain.NewUtxoViewpoint()
+	for _, txIn := range msgTx.TxIn {
+		var txOut wire.TxOut
+		if _, err := wire.ReadTxOut(r, 0, 0, &txOut); err != nil {
+			return Rejected, nil
+		}
+		view.Entries()[txIn.PreviousOutPoint] = blockchain.NewUtxoEntry(
+			&txOut, scriptsTxHeight-1, false)
+	}
+	if r.Len() != 0 {
+		return Rejected, nil
+	}
+
+	tx := bchutil.NewTx(&msgTx)
+	if blockchain.IsCoinBase(tx) {
+		return Rejected, nil
+	}
+	if err := blockchain.CheckTransactionSanity(tx, true, true, flags); err != nil {
+		return Rejected, nil
+	}
+	_, err = blockchain.CheckTransactionInputs(tx, scriptsTxHeight, view,
+		&chaincfg.MainNetParams)
+	if err != nil {
+		return Rejected, nil
+	}
+	_, err = blockchain.ValidateTransactionScripts(tx, view, flags,
+		scriptsSigCache, nil, chaincfg.MainNetParams.Upgrade9ForkHeight)
+	if err != nil {
+		return Rejected, nil
+	}
+	return Accepted, nil
+}
+
+// ablaConstants are the configurations of the adaptive block size limit
+// algorithm selected by the first byte of the input of the ABLA target.
+var ablaConstants = []*chaincfg.ABLAConstants{
+	&chaincfg.MainNetParams.ABLAConfig,
+	&chaincfg.TestNet4Params.ABLAConfig,
+	&chaincfg.ChipNetParams.ABLAConfig,
+	&chaincfg.ScaleNetParams.ABLAConfig,
+	&chaincfg.RegressionNetParams.ABLAConfig,
+
+	// The configurations of the EBAA reference test vectors.
+	{
+		Epsilon0:        16000000,
+		Beta0:           16000000,
+		N0:              100000,
+		GammaReciprocal: 37938,
+		ZetaXB7:         192,
+		ThetaReciprocal: 37938,
+		Delta:           10,
+	},
+	{
+		Epsilon0:        16000000,
+		Beta0:           16000000,
+		N0:              100000,
+		GammaReciprocal: 9484,
+		ZetaXB7:         256,
+		ThetaReciprocal: 151744,
+		Delta:           32,
+	},
+}
+
+var (
+	ablaConfigsOnce sync.Once
+	ablaConfigs     []*blockchain.ABLAConfig
+	ablaConfigsErr  error
+)
+
+// loadABLAConfigs derives the ABLA configurations from their constants once.
+func loadABLAConfigs() ([]*blockchain.ABLAConfig, error) {
+	ablaConfigsOnce.Do(func() {
+		for _, constants := range ablaConstants {
+			config, err := blockchain.NewABLAConfig(constants)
+			if err != nil {
+				ablaConfigsErr = err
+				return
+			}
+			ablaConfigs = append(ablaConfigs, config)
+		}
+	})
+	return ablaConfigs, ablaConfigsErr
+}
+
+// ablaHeaderLen is the length of the configuration and initial state which
+// start the input of the ABLA target.
+const ablaHeaderLen = 1 + 3*8
+
+// ABLA advances the state of the adaptive block size limit algorithm.  The
+// input is the configuration, the initial block height, control block size
+// and elastic buffer size and then the sizes of the following blocks, all as
+// big-endian 64-bit integers.
+//
+// The input is rejected when the initial state is not valid for the
+// configuration, otherwise the outcome is the final block height, control
+// block size and elastic buffer size separated by colons.  Every state must be
+// valid and apply to the block after the one of the previous state.
+func ABLA(data []byte) (Outcome, error) {
+	configs, err := loadABLAConfigs()
+	if err != nil {
+		return "", fmt.Errorf("invalid ABLA configuration: %v", err)
+	}
+	if len(data) < ablaHeaderLen || int(data[0]) >= len(configs) {
+		return Rejected, nil
+	}
+	config := configs[data[0]]
+
+	state := blockchain.NewABLAState(binary.BigEndian.Uint64(data[1:9]),
+		binary.BigEndian.Uint64(data[9:17]),
+		binary.BigEndian.Uint64(data[17:25]))
+	if state.IsValid(config) != nil {
+		return Rejected, nil
+	}
+
+	for sizes := data[ablaHeaderLen:]; len(sizes) >= 8; sizes = sizes[8:] {
+		next := state.NextState(config, binary.BigEndian.Uint64(sizes))
+		if next.BlockHeight() != state.BlockHeight()+1 {
+			return "", fmt.Errorf("state of block %d follows the one "+
+				"of block %d", next.BlockHeight(), state.BlockHeight())
+		}
+		if errs := next.IsValid(config); errs != nil {
+			return "", fmt.Errorf("invalid state of block %d: %s",
+				next.BlockHeight(), errs.String())
+		}
+		state = next
+	}
+	return ablaOutcome(state), nil
+}
+
+// ablaOutcome returns the outcome of the ABLA target for the final state.
+func ablaOutcome(state blockchain.ABLAState) Outcome {
+	return Outcome(fmt.Sprintf("%d:%d:%d", state.BlockHeight(),
+		state.ControlBlockSize(), state.ElasticBufferSize()))
+}
@@ -2,21 +2,30 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/gcash/bchd/bchrpc"
+	"github.com/gcash/bchd/blockchain"
+	"github.com/golang/protobuf/proto"
 	"github.com/gorilla/mux"
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/improbable-eng/grpc-web/go/grpcweb"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 // AuthenticationTokenKey is the key used in the context to authenticate clients.
@@ -26,15 +35,20 @@ const AuthenticationTokenKey = "AuthenticationToken"
 
 var prometheusEnabled = false
 
+// grpcQuotas are the request quotas of the gRPC clients.
+var grpcQuotas rpcQuotas
+
 func newGrpcServer(netAddrs []net.Addr, rpcCfg *bchrpc.GrpcServerConfig, svr *server) (*bchrpc.GrpcServer, error) {
+	grpcQuotas = newRPCQuotas(cfg.GrpcRateLimit, cfg.GrpcUserRateLimit,
+		cfg.GrpcRateBurst, cfg.GrpcMaxClientReqs, cfg.GrpcResponseBudget)
 	for _, addr := range netAddrs {
 		rpcCfg.NetMgr = svr
 		opts := []grpc.ServerOption{grpc.StreamInterceptor(interceptStreaming), grpc.UnaryInterceptor(interceptUnary)}
-		creds, err := credentials.NewServerTLSFromFile(cfg.RPCCert, cfg.RPCKey)
+		tlsConfig, err := grpcTLSConfig()
 		if err != nil {
 			return nil, err
 		}
-		opts = append(opts, grpc.Creds(creds))
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
 		server := grpc.NewServer(opts...)
 
 		allowAllOrigins := grpcweb.WithOriginFunc(func(origin string) bool {
@@ -53,8 +67,9 @@ func newGrpcServer(netAddrs []net.Addr, rpcCfg *bchrpc.GrpcServerConfig, svr *se
 		}
 
 		httpServer := &http.Server{
-			Addr:    addr.String(),
-			Handler: http.HandlerFunc(handler),
+			Addr:      addr.String(),
+			Handler:   http.HandlerFunc(handler),
+			TLSConfig: tlsConfig,
 		}
 
 		rpcCfg.HTTPServer = httpServer
@@ -64,7 +79,7 @@ func newGrpcServer(netAddrs []net.Addr, rpcCfg *bchrpc.GrpcServerConfig, svr *se
 		grpcLog.Infof("Experimental gRPC server listening on %s", addr)
 
 		go func() {
-			if err := httpServer.ListenAndServeTLS(cfg.RPCCert, cfg.RPCKey); err != nil {
+			if err := httpServer.ListenAndServeTLS("", ""); err != nil {
 				grpcLog.Tracef("Finished serving expimental gRPC: %v", err)
 			}
 		}()
@@ -73,6 +88,7 @@ func newGrpcServer(netAddrs []net.Addr, rpcCfg *bchrpc.GrpcServerConfig, svr *se
 			// init Prometheus metrics
 			grpc_prometheus.EnableHandlingTimeHistogram()
 			grpc_prometheus.Register(server)
+			registerUtxoCacheMetrics(svr.chain)
 
 			router := mux.NewRouter()
 			router.Handle("/metrics", promhttp.Handler())
@@ -99,6 +115,89 @@ func newGrpcServer(netAddrs []net.Addr, rpcCfg *bchrpc.GrpcServerConfig, svr *se
 	return nil, nil
 }
 
+// grpcTLSConfig returns the TLS configuration of the gRPC server.  Client
+// certificates are verified against the configured certificate authorities
+// when there are any.  They are not required by the handshake so that the
+// health service can be probed without one, but all other methods require them.
+func grpcTLSConfig() (*tls.Config, error) {
+	keyPair, err := tls.LoadX509KeyPair(cfg.RPCCert, cfg.RPCKey)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{keyPair},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.GrpcClientCA != "" {
+		pemCerts, err := os.ReadFile(cfg.GrpcClientCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemCerts) {
+			return nil, fmt.Errorf("no certificates found in %s",
+				cfg.GrpcClientCA)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+// registerUtxoCacheMetrics registers Prometheus metrics which report the
+// memory usage and the effectiveness of the UTXO cache of the passed chain.
+func registerUtxoCacheMetrics(chain *blockchain.BlockChain) {
+	gauge := func(name, help string, value func(*blockchain.UtxoCacheStats) float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "bchd",
+			Subsystem: "utxocache",
+			Name:      name,
+			Help:      help,
+		}, func() float64 {
+			stats := chain.UtxoCacheStats()
+			return value(&stats)
+		})
+	}
+	counter := func(name, help string, value func(*blockchain.UtxoCacheStats) uint64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: "bchd",
+			Subsystem: "utxocache",
+			Name:      name,
+			Help:      help,
+		}, func() float64 {
+			stats := chain.UtxoCacheStats()
+			return float64(value(&stats))
+		})
+	}
+
+	prometheus.MustRegister(
+		gauge("entries", "Number of cached UTXO entries.",
+			func(s *blockchain.UtxoCacheStats) float64 { return float64(s.Entries) }),
+		gauge("dirty_entries", "Number of modified UTXO entries not yet written to the database.",
+			func(s *blockchain.UtxoCacheStats) float64 { return float64(s.DirtyEntries) }),
+		gauge("memory_bytes", "Estimated memory usage of the UTXO cache.",
+			func(s *blockchain.UtxoCacheStats) float64 { return float64(s.TotalMemory) }),
+		gauge("dirty_memory_bytes", "Estimated memory usage of the modified UTXO entries.",
+			func(s *blockchain.UtxoCacheStats) float64 { return float64(s.DirtyMemory) }),
+		gauge("max_memory_bytes", "Maximum memory usage of the UTXO cache.",
+			func(s *blockchain.UtxoCacheStats) float64 { return float64(s.MaxMemory) }),
+		gauge("hit_rate", "Fraction of UTXO lookups served from the cache.",
+			func(s *blockchain.UtxoCacheStats) float64 { return s.HitRate() }),
+		counter("hits_total", "Number of UTXO lookups served from the cache.",
+			func(s *blockchain.UtxoCacheStats) uint64 { return s.Hits }),
+		counter("misses_total", "Number of UTXO lookups served from the database.",
+			func(s *blockchain.UtxoCacheStats) uint64 { return s.Misses }),
+		counter("flushed_entries_total", "Number of UTXO entries written to the database.",
+			func(s *blockchain.UtxoCacheStats) uint64 { return s.FlushedEntries }),
+		counter("evicted_entries_total", "Number of unmodified UTXO entries evicted from the cache.",
+			func(s *blockchain.UtxoCacheStats) uint64 { return s.EvictedEntries }),
+		counter("incremental_flushes_total", "Number of incremental writes of modified UTXO entries.",
+			func(s *blockchain.UtxoCacheStats) uint64 { return s.IncrementalFlushes }),
+		counter("full_flushes_total", "Number of full flushes of the UTXO cache.",
+			func(s *blockchain.UtxoCacheStats) uint64 { return s.FullFlushes }),
+	)
+}
+
 // serviceName returns the package.service segment from the full gRPC method
 // name `/package.service/method`.
 func serviceName(method string) string {
@@ -109,29 +208,51 @@ func serviceName(method string) string {
 }
 
 func interceptStreaming(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-	// collect prometheus metrics before auth
-	if prometheusEnabled {
-		if err := grpc_prometheus.StreamServerInterceptor(srv, ss, info, handler); err != nil {
-			return err
-		}
-	}
-
 	p, ok := peer.FromContext(ss.Context())
 	if ok {
 		grpcLog.Infof("Streaming method %s invoked by %s", info.FullMethod,
 			p.Addr.String())
 	}
 
-	err := validateAuthenticationToken(ss.Context())
-	if err != nil {
-		return err
+	authorizedHandler := func(srv interface{}, ss grpc.ServerStream) error {
+		err := authorizeMethod(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		err = bchrpc.ServiceReady(serviceName(info.FullMethod))
+		if err != nil {
+			return err
+		}
+		if serviceName(info.FullMethod) == bchrpc.HealthServiceName {
+			return handler(srv, ss)
+		}
+
+		// Streams count towards the concurrent requests for as long as
+		// they are open, and each sent message is charged to the
+		// response budget.
+		addr, user := grpcQuotaClient(ss.Context())
+		release, err := grpcQuotas.Acquire(addr, user)
+		if err != nil {
+			return status.Error(codes.ResourceExhausted, err.Error())
+		}
+		defer release()
+		return handler(srv, &quotaServerStream{
+			ServerStream: ss,
+			addr:         addr,
+			user:         user,
+		})
 	}
 
-	err = bchrpc.ServiceReady(serviceName(info.FullMethod))
-	if err != nil {
-		return err
+	// Collect prometheus metrics of all requests, including the ones
+	// which are not authorized.
+	var err error
+	if prometheusEnabled {
+		err = grpc_prometheus.StreamServerInterceptor(srv, ss, info,
+			authorizedHandler)
+	} else {
+		err = authorizedHandler(srv, ss)
 	}
-	err = handler(srv, ss)
 	if err != nil && ok {
 		grpcLog.Errorf("Streaming method %s invoked by %s errored: %v",
 			info.FullMethod, p.Addr.String(), err)
@@ -140,29 +261,47 @@ func interceptStreaming(srv interface{}, ss grpc.ServerStream, info *grpc.Stream
 }
 
 func interceptUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-	if prometheusEnabled {
-		resp, err = grpc_prometheus.UnaryServerInterceptor(ctx, req, info, handler)
-		if err != nil {
-			return resp, err
-		}
-	}
-
 	p, ok := peer.FromContext(ctx)
 	if ok {
 		grpcLog.Infof("Unary method %s invoked by %s", info.FullMethod,
 			p.Addr.String())
 	}
 
-	err = validateAuthenticationToken(ctx)
-	if err != nil {
-		return nil, err
+	authorizedHandler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		err := authorizeMethod(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		err = bchrpc.ServiceReady(serviceName(info.FullMethod))
+		if err != nil {
+			return nil, err
+		}
+		if serviceName(info.FullMethod) == bchrpc.HealthServiceName {
+			return handler(ctx, req)
+		}
+
+		addr, user := grpcQuotaClient(ctx)
+		release, err := grpcQuotas.Acquire(addr, user)
+		if err != nil {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+		defer release()
+		resp, err := handler(ctx, req)
+		if msg, ok := resp.(proto.Message); ok {
+			grpcQuotas.ChargeResponse(addr, user, proto.Size(msg))
+		}
+		return resp, err
 	}
 
-	err = bchrpc.ServiceReady(serviceName(info.FullMethod))
-	if err != nil {
-		return nil, err
+	// Collect prometheus metrics of all requests, including the ones
+	// which are not authorized.
+	if prometheusEnabled {
+		resp, err = grpc_prometheus.UnaryServerInterceptor(ctx, req, info,
+			authorizedHandler)
+	} else {
+		resp, err = authorizedHandler(ctx, req)
 	}
-	resp, err = handler(ctx, req)
 	if err != nil && ok {
 		grpcLog.Errorf("Unary method %s invoked by %s errored: %v",
 			info.FullMethod, p.Addr.String(), err)
@@ -170,6 +309,91 @@ func interceptUnary(ctx context.Context, req interface{}, info *grpc.UnaryServer
 	return resp, err
 }
 
+// quotaServerStream is a grpc.ServerStream which charges the messages it sends
+// to the response budget of its client.
+type quotaServerStream struct {
+	grpc.ServerStream
+	addr string
+	user string
+}
+
+// SendMsg sends the passed message and charges its size to the response
+// budget of the client.
+func (s *quotaServerStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if msg, ok := m.(proto.Message); ok && err == nil {
+		grpcQuotas.ChargeResponse(s.addr, s.user, proto.Size(msg))
+	}
+	return err
+}
+
+// grpcQuotaClient returns the address and the user the quotas of the client of
+// the passed context are enforced for.  The user is the common name of the
+// client certificate when client certificates are required, and empty
+// otherwise.
+func grpcQuotaClient(ctx context.Context) (string, string) {
+	var addr, user string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr = p.Addr.String()
+	}
+	if cfg.GrpcClientCA != "" {
+		user, _ = clientCertificateName(ctx)
+	}
+	return addr, user
+}
+
+// authorizeMethod returns an error when the client of the passed context is
+// not allowed to invoke the passed method.  The health service may be invoked
+// by any client.  Otherwise the authentication token must match when one is
+// configured, and the scope of the client certificate must include the method
+// when client certificates are required.
+func authorizeMethod(ctx context.Context, fullMethod string) error {
+	if serviceName(fullMethod) == bchrpc.HealthServiceName {
+		return nil
+	}
+
+	err := validateAuthenticationToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	if cfg.GrpcClientCA == "" {
+		return nil
+	}
+	commonName, err := clientCertificateName(ctx)
+	if err != nil {
+		return err
+	}
+	scope, ok := cfg.grpcClientScopes[commonName]
+	if !ok {
+		scope = bchrpc.ScopeReadOnly
+	}
+	if required := bchrpc.MethodScope(fullMethod); scope < required {
+		return status.Errorf(codes.PermissionDenied,
+			"client certificate %q has the %v scope, but %s requires "+
+				"the %v scope", commonName, scope, fullMethod, required)
+	}
+	return nil
+}
+
+// clientCertificateName returns the common name of the verified client
+// certificate of the passed context.
+func clientCertificateName(ctx context.Context) (string, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated,
+			"client certificate required")
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 ||
+		len(tlsInfo.State.VerifiedChains[0]) == 0 {
+
+		return "", status.Error(codes.Unauthenticated,
+			"client certificate required")
+	}
+	return tlsInfo.State.VerifiedChains[0][0].Subject.CommonName, nil
+}
+
 func validateAuthenticationToken(ctx context.Context) error {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if cfg.GrpcAuthToken != "" && (!ok || len(md.Get(AuthenticationTokenKey)) == 0 || md.Get(AuthenticationTokenKey)[0] != cfg.GrpcAuthToken) {
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/gcash/bchd/bchrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// TestAuthorizeMethod ensures gRPC methods are only authorized for clients
+// which present the configured authentication token and whose client
+// certificate has the scope the method requires.
+func TestAuthorizeMethod(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+
+	// clientContext returns a context of a client with a verified
+	// certificate with the passed common name, or without a certificate
+	// when it is empty, and which presents the passed token.
+	clientContext := func(commonName, token string) context.Context {
+		var state tls.ConnectionState
+		if commonName != "" {
+			state.VerifiedChains = [][]*x509.Certificate{{{
+				Subject: pkix.Name{CommonName: commonName},
+			}}}
+		}
+		ctx := peer.NewContext(context.Background(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{State: state},
+		})
+		if token != "" {
+			ctx = metadata.NewIncomingContext(ctx,
+				metadata.Pairs(AuthenticationTokenKey, token))
+		}
+		return ctx
+	}
+
+	const (
+		getBlock   = "/pb.bchrpc/GetBlock"
+		submitTx   = "/pb.bchrpc/SubmitTransaction"
+		invalidate = "/pb.bchrpc/InvalidateBlock"
+		sendHot    = "/pb.bchrpc/SendFromHotWallet"
+		createHot  = "/pb.bchrpc/CreateHotWallet"
+		check      = "/grpc.health.v1.Health/Check"
+	)
+	tests := []struct {
+		name       string
+		token      string
+		clientCA   string
+		commonName string
+		reqToken   string
+		method     string
+		code       codes.Code
+	}{
+		{
+			name:   "no authentication configured",
+			method: invalidate,
+			code:   codes.OK,
+		},
+		{
+			name:   "missing token",
+			token:  "secret",
+			method: getBlock,
+			code:   codes.Unknown,
+		},
+		{
+			name:     "valid token",
+			token:    "secret",
+			reqToken: "secret",
+			method:   invalidate,
+			code:     codes.OK,
+		},
+		{
+			name:   "health without token",
+			token:  "secret",
+			method: check,
+			code:   codes.OK,
+		},
+		{
+			name:     "missing certificate",
+			clientCA: "ca.cert",
+			method:   getBlock,
+			code:     codes.Unauthenticated,
+		},
+		{
+			name:     "health without certificate",
+			clientCA: "ca.cert",
+			method:   check,
+			code:     codes.OK,
+		},
+		{
+			name:       "read-only default scope",
+			clientCA:   "ca.cert",
+			commonName: "unknown",
+			method:     getBlock,
+			code:       codes.OK,
+		},
+		{
+			name:       "read-only default scope submit",
+			clientCA:   "ca.cert",
+			commonName: "unknown",
+			method:     submitTx,
+			code:       codes.PermissionDenied,
+		},
+		{
+			name:       "read-only default scope hot wallet send",
+			token:      "secret",
+			reqToken:   "secret",
+			clientCA:   "ca.cert",
+			commonName: "unknown",
+			method:     sendHot,
+			code:       codes.PermissionDenied,
+		},
+		{
+			name:       "submit scope hot wallet send",
+			clientCA:   "ca.cert",
+			commonName: "wallet",
+			method:     sendHot,
+			code:       codes.OK,
+		},
+		{
+			name:       "submit scope hot wallet create",
+			clientCA:   "ca.cert",
+			commonName: "wallet",
+			method:     createHot,
+			code:       codes.PermissionDenied,
+		},
+		{
+			name:       "submit scope",
+			clientCA:   "ca.cert",
+			commonName: "wallet",
+			method:     submitTx,
+			code:       codes.OK,
+		},
+		{
+			name:       "submit scope admin",
+			clientCA:   "ca.cert",
+			commonName: "wallet",
+			method:     invalidate,
+			code:       codes.PermissionDenied,
+		},
+		{
+			name:       "admin scope",
+			clientCA:   "ca.cert",
+			commonName: "operator",
+			method:     invalidate,
+			code:       codes.OK,
+		},
+		{
+			name:       "admin scope missing token",
+			token:      "secret",
+			clientCA:   "ca.cert",
+			commonName: "operator",
+			method:     invalidate,
+			code:       codes.Unknown,
+		},
+	}
+
+	for _, test := range tests {
+		cfg = &config{
+			GrpcAuthToken: test.token,
+			GrpcClientCA:  test.clientCA,
+			grpcClientScopes: map[string]bchrpc.Scope{
+				"wallet":   bchrpc.ScopeSubmit,
+				"operator": bchrpc.ScopeAdmin,
+			},
+		}
+		ctx := clientContext(test.commonName, test.reqToken)
+		err := authorizeMethod(ctx, test.method)
+		if code := status.Code(err); code != test.code {
+			t.Errorf("%s: unexpected code -- got %v, want %v (%v)",
+				test.name, code, test.code, err)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+/*
+Package hotwallet implements an optional hot wallet which holds the keys of
+BIP0044 accounts and signs the transactions spending their funds.
+
+Each wallet is created from a BIP0039 mnemonic, which is either generated or
+provided to restore an existing wallet, along with an optional passphrase.  The
+keys of the wallet are derived along the BIP0044 path m/44'/145'/account' of
+the seed, using the coin type of the network.
+
+The wallets build on the watch-only accounts of the watchonly package.  The
+extended public key of each wallet is imported as a watch-only account with the
+name of the wallet, which provides the address generation, the balances, the
+transaction history, and the unspent outputs.  The hot wallet signs the
+transactions built from those unspent outputs.
+
+The seed of each wallet is stored in the database encrypted with a key derived
+from a passphrase of the wallet using scrypt.  The passphrase is chosen when the
+wallet is created and is never stored, so it must be provided again to send
+from the wallet.  The authentication token of the wallets only authenticates
+their callers and doesn't protect the seeds.
+*/
+package hotwallet
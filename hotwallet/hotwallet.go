@@ -0,0 +1,511 @@
+package hotwallet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/watchonly"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil/hdkeychain"
+	"github.com/tyler-smith/go-bip39"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// mnemonicEntropyBits is the number of bits of entropy of generated
+	// mnemonics, which makes them 24 words long.
+	mnemonicEntropyBits = 256
+
+	// The scrypt parameters used to derive the key which encrypts a seed
+	// from the passphrase of its wallet.
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+
+	// saltSize and nonceSize are the sizes of the random scrypt salt and
+	// secretbox nonce stored along with each encrypted seed.
+	saltSize  = 16
+	nonceSize = 24
+
+	// maxXpubLen is the maximum length of the serialized extended public
+	// key stored along with each encrypted seed.
+	maxXpubLen = 255
+)
+
+var (
+	// walletsBucketName is the name of the database bucket used to house
+	// the encrypted seeds of the wallets.
+	walletsBucketName = []byte("hotwallets")
+
+	// byteOrder is the preferred byte order used for serializing wallets.
+	byteOrder = binary.LittleEndian
+
+	// ErrWalletNotFound is returned when the requested wallet does not
+	// exist.
+	ErrWalletNotFound = errors.New("hot wallet not found")
+
+	// ErrWalletExists is returned when attempting to create a wallet with
+	// the name of an existing wallet.
+	ErrWalletExists = errors.New("hot wallet already exists")
+
+	// ErrDecrypt is returned when the seed of a wallet can't be decrypted
+	// with the passed wallet passphrase.
+	ErrDecrypt = errors.New("incorrect hot wallet passphrase")
+)
+
+// Config is a descriptor containing the hot wallet manager configuration.
+type Config struct {
+	// ChainParams identifies which chain parameters the wallets are
+	// associated with.
+	ChainParams *chaincfg.Params
+
+	// DB is the database the encrypted seeds are stored in.
+	DB database.DB
+
+	// WatchOnly is the watch-only account manager the accounts of the
+	// wallets are imported into.
+	WatchOnly *watchonly.Manager
+
+	// AuthToken is the token which authenticates the callers of the
+	// wallets.  It only authenticates, the seeds are encrypted with the
+	// passphrases of the wallets, which are never stored.
+	AuthToken string
+}
+
+// Wallet describes a hot wallet.
+type Wallet struct {
+	Name string
+
+	// Account is the BIP0044 account number of the wallet.
+	Account uint32
+
+	// Xpub is the serialized extended public key of the account, which
+	// is imported as the watch-only account of the wallet.
+	Xpub string
+}
+
+// Transaction is a signed transaction which spends the funds of a wallet.
+type Transaction struct {
+	Tx *wire.MsgTx
+
+	// Fee is the fee paid by the transaction.
+	Fee int64
+
+	// ChangeIndex is the index of the change output or -1 when the
+	// transaction does not have one.
+	ChangeIndex int
+}
+
+// wallet houses the account number and the extended public key of the
+// account of a wallet along with its encrypted seed.
+type wallet struct {
+	account uint32
+	xpub    string
+	salt    [saltSize]byte
+	nonce   [nonceSize]byte
+	sealed  []byte
+}
+
+// Manager keeps track of a set of hot wallets and signs the transactions
+// spending their funds.
+type Manager struct {
+	cfg Config
+
+	mtx     sync.Mutex
+	wallets map[string]*wallet
+}
+
+// New returns a new hot wallet manager which loads the wallets stored in the
+// database.  The seeds stay encrypted until a transaction is signed.  The watch-only accounts of any wallets which are
+// missing from the watch-only account manager are imported again.
+func New(cfg *Config) (*Manager, error) {
+	if cfg.WatchOnly == nil {
+		return nil, errors.New("hot wallets require watch-only accounts")
+	}
+	if cfg.AuthToken == "" {
+		return nil, errors.New("hot wallets require an authentication " +
+			"token")
+	}
+
+	m := &Manager{
+		cfg:     *cfg,
+		wallets: make(map[string]*wallet),
+	}
+	err := cfg.DB.Update(func(dbTx database.Tx) error {
+		bucket, err := dbTx.Metadata().CreateBucketIfNotExists(
+			walletsBucketName)
+		if err != nil {
+			return err
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			w, err := deserializeWallet(v)
+			if err != nil {
+				return fmt.Errorf("hot wallet %q: %v", k, err)
+			}
+			m.wallets[string(k)] = w
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make(map[string]struct{})
+	for _, a := range cfg.WatchOnly.Accounts() {
+		accounts[a.Name()] = struct{}{}
+	}
+	for name, w := range m.wallets {
+		if _, ok := accounts[name]; ok {
+			continue
+		}
+		log.Warnf("Importing missing watch-only account of hot "+
+			"wallet %q", name)
+		if _, err := cfg.WatchOnly.ImportAccount(name, w.xpub, 0); err != nil {
+			return nil, err
+		}
+	}
+	log.Infof("Loaded %d hot wallets", len(m.wallets))
+	return m, nil
+}
+
+// Authenticate returns whether the passed token is the authentication token of
+// the wallets.
+//
+// This function is safe for concurrent access.
+func (m *Manager) Authenticate(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token),
+		[]byte(m.cfg.AuthToken)) == 1
+}
+
+// CreateWallet adds a new wallet with the passed name for the passed BIP0044
+// account of the seed of the passed mnemonic and passphrase.  A new mnemonic is
+// generated and returned when the passed one is empty, otherwise the returned
+// mnemonic is empty.  The seed is stored encrypted with a key derived from the
+// passed wallet passphrase, which is required to send from the wallet.  The
+// account of the wallet is imported as a watch-only account with the passed gap
+// limit, so any existing transactions of a restored wallet are found.
+//
+// This function is safe for concurrent access.
+func (m *Manager) CreateWallet(name, mnemonic, passphrase, walletPassphrase string, account, gapLimit uint32) (*Wallet, string, error) {
+	if name == "" {
+		return nil, "", errors.New("wallet name must not be empty")
+	}
+	if walletPassphrase == "" {
+		return nil, "", errors.New("wallet passphrase must not be empty")
+	}
+	if account >= hdkeychain.HardenedKeyStart {
+		return nil, "", fmt.Errorf("account number %d is out of range",
+			account)
+	}
+
+	var generated string
+	if mnemonic == "" {
+		entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+		if err != nil {
+			return nil, "", err
+		}
+		generated, err = bip39.NewMnemonic(entropy)
+		if err != nil {
+			return nil, "", err
+		}
+		mnemonic = generated
+	}
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid mnemonic: %v", err)
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if _, ok := m.wallets[name]; ok {
+		return nil, "", ErrWalletExists
+	}
+	w, err := newWallet(account, seed, walletPassphrase, m.cfg.ChainParams)
+	if err != nil {
+		return nil, "", err
+	}
+	serialized := serializeWallet(w)
+
+	if _, err := m.cfg.WatchOnly.ImportAccount(name, w.xpub, gapLimit); err != nil {
+		return nil, "", err
+	}
+	err = m.cfg.DB.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(walletsBucketName)
+		return bucket.Put([]byte(name), serialized)
+	})
+	if err != nil {
+		if err := m.cfg.WatchOnly.RemoveAccount(name); err != nil {
+			log.Errorf("Unable to remove watch-only account of hot "+
+				"wallet %q: %v", name, err)
+		}
+		return nil, "", err
+	}
+	m.wallets[name] = w
+
+	log.Infof("Created hot wallet %q", name)
+	return &Wallet{Name: name, Account: account, Xpub: w.xpub}, generated, nil
+}
+
+// RemoveWallet removes the wallet with the passed name along with its
+// watch-only account.
+//
+// This function is safe for concurrent access.
+func (m *Manager) RemoveWallet(name string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if _, ok := m.wallets[name]; !ok {
+		return ErrWalletNotFound
+	}
+	err := m.cfg.DB.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(walletsBucketName)
+		return bucket.Delete([]byte(name))
+	})
+	if err != nil {
+		return err
+	}
+	delete(m.wallets, name)
+
+	err = m.cfg.WatchOnly.RemoveAccount(name)
+	if err != nil && err != watchonly.ErrAccountNotFound {
+		return err
+	}
+
+	log.Infof("Removed hot wallet %q", name)
+	return nil
+}
+
+// Wallets returns all of the wallets sorted by name.
+//
+// This function is safe for concurrent access.
+func (m *Manager) Wallets() ([]*Wallet, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	wallets := make([]*Wallet, 0, len(m.wallets))
+	for name, w := range m.wallets {
+		wallets = append(wallets, &Wallet{
+			Name:    name,
+			Account: w.account,
+			Xpub:    w.xpub,
+		})
+	}
+	sort.Slice(wallets, func(i, j int) bool {
+		return wallets[i].Name < wallets[j].Name
+	})
+	return wallets, nil
+}
+
+// CreateTransaction builds and signs a transaction which pays the passed
+// outputs from the unspent outputs of the wallet with the passed name.  The seed
+// of the wallet is decrypted with the passed wallet passphrase.  The outputs are
+// selected and change is paid as described by the BuildTransaction method of
+// the watch-only account manager.  The transaction is not broadcast.
+//
+// This function is safe for concurrent access.
+func (m *Manager) CreateTransaction(name, walletPassphrase string, outputs []*wire.TxOut, feePerKb int64, minConf int32) (*Transaction, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	w, ok := m.wallets[name]
+	if !ok {
+		return nil, ErrWalletNotFound
+	}
+	key, err := w.accountKey(walletPassphrase, m.cfg.ChainParams)
+	if err != nil {
+		return nil, err
+	}
+	unsigned, err := m.cfg.WatchOnly.BuildTransaction(name, outputs,
+		feePerKb, minConf)
+	if err != nil {
+		return nil, err
+	}
+	if err := sign(key, unsigned, m.cfg.ChainParams); err != nil {
+		return nil, err
+	}
+	return &Transaction{
+		Tx:          unsigned.Tx,
+		Fee:         unsigned.Fee,
+		ChangeIndex: unsigned.ChangeIndex,
+	}, nil
+}
+
+// newWallet returns a wallet for the passed BIP0044 account of the passed seed,
+// which is encrypted with a key derived from the passed wallet passphrase.
+func newWallet(account uint32, seed []byte, walletPassphrase string, params *chaincfg.Params) (*wallet, error) {
+	key, err := deriveAccountKey(seed, account, params)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := key.Neuter()
+	if err != nil {
+		return nil, err
+	}
+	w := &wallet{account: account, xpub: pub.String()}
+	if len(w.xpub) > maxXpubLen {
+		return nil, fmt.Errorf("extended public key of %d bytes is "+
+			"too long", len(w.xpub))
+	}
+	if _, err := io.ReadFull(rand.Reader, w.salt[:]); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(rand.Reader, w.nonce[:]); err != nil {
+		return nil, err
+	}
+	encKey, err := encryptionKey(walletPassphrase, w.salt[:])
+	if err != nil {
+		return nil, err
+	}
+	w.sealed = secretbox.Seal(nil, seed, &w.nonce, encKey)
+	return w, nil
+}
+
+// accountKey decrypts the seed of the wallet with a key derived from the passed
+// wallet passphrase and returns the extended private key of its account.
+func (w *wallet) accountKey(walletPassphrase string, params *chaincfg.Params) (*hdkeychain.ExtendedKey, error) {
+	encKey, err := encryptionKey(walletPassphrase, w.salt[:])
+	if err != nil {
+		return nil, err
+	}
+	seed, ok := secretbox.Open(nil, w.sealed, &w.nonce, encKey)
+	if !ok {
+		return nil, ErrDecrypt
+	}
+	return deriveAccountKey(seed, w.account, params)
+}
+
+// sign signs all of the inputs of the passed unsigned transaction, which spend
+// pay-to-pubkey-hash outputs of the account of the passed extended private key,
+// with Schnorr signatures.
+func sign(key *hdkeychain.ExtendedKey, unsigned *watchonly.UnsignedTransaction, params *chaincfg.Params) error {
+	var branches [2]*hdkeychain.ExtendedKey
+	for i, utxo := range unsigned.Inputs {
+		if utxo.Branch >= uint32(len(branches)) {
+			return fmt.Errorf("input %d is derived from unknown "+
+				"branch %d", i, utxo.Branch)
+		}
+		if branches[utxo.Branch] == nil {
+			branch, err := key.Child(utxo.Branch)
+			if err != nil {
+				return err
+			}
+			branches[utxo.Branch] = branch
+		}
+		child, err := branches[utxo.Branch].Child(utxo.Index)
+		if err != nil {
+			return err
+		}
+		addr, err := child.Address(params)
+		if err != nil {
+			return err
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(pkScript, utxo.PkScript) {
+			return fmt.Errorf("input %d does not spend an output "+
+				"of the wallet", i)
+		}
+		privKey, err := child.ECPrivKey()
+		if err != nil {
+			return err
+		}
+		sigScript, err := txscript.SignatureScript(unsigned.Tx, i,
+			utxo.Amount, utxo.PkScript,
+			txscript.SigHashAll|txscript.SigHashForkID, privKey, true)
+		if err != nil {
+			return err
+		}
+		unsigned.Tx.TxIn[i].SignatureScript = sigScript
+	}
+	return nil
+}
+
+// deriveAccountKey returns the extended private key of the passed BIP0044
+// account, m/44'/coin_type'/account', of the passed seed.
+func deriveAccountKey(seed []byte, account uint32, params *chaincfg.Params) (*hdkeychain.ExtendedKey, error) {
+	key, err := hdkeychain.NewMaster(seed, params)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range []uint32{44, params.HDCoinType, account} {
+		key, err = key.Child(hdkeychain.HardenedKeyStart + i)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// encryptionKey returns the key which encrypts seeds derived from the passed
+// wallet passphrase and salt.
+func encryptionKey(walletPassphrase string, salt []byte) (*[scryptKeyLen]byte, error) {
+	derived, err := scrypt.Key([]byte(walletPassphrase), salt, scryptN,
+		scryptR, scryptP, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	var key [scryptKeyLen]byte
+	copy(key[:], derived)
+	return &key, nil
+}
+
+// serializeWallet returns the serialization of the passed wallet.  Only the seed
+// is encrypted, so the wallets can be loaded without their passphrases.
+//
+// The serialized format is:
+//
+//	<account><xpub length><xpub><salt><nonce><encrypted seed>
+//
+//	Field           Type      Size
+//	account         uint32    4
+//	xpub length     uint8     1
+//	xpub            string    variable
+//	salt            []byte    16
+//	nonce           []byte    24
+//	encrypted seed  []byte    variable
+func serializeWallet(w *wallet) []byte {
+	serialized := make([]byte, 0, 5+len(w.xpub)+saltSize+nonceSize+
+		len(w.sealed))
+	serialized = byteOrder.AppendUint32(serialized, w.account)
+	serialized = append(serialized, byte(len(w.xpub)))
+	serialized = append(serialized, w.xpub...)
+	serialized = append(serialized, w.salt[:]...)
+	serialized = append(serialized, w.nonce[:]...)
+	return append(serialized, w.sealed...)
+}
+
+// deserializeWallet returns the wallet of the passed serialization.  The seed
+// stays encrypted.
+func deserializeWallet(serialized []byte) (*wallet, error) {
+	if len(serialized) < 5 {
+		return nil, errors.New("unexpected end of data")
+	}
+	xpubLen := int(serialized[4])
+	if len(serialized) < 5+xpubLen+saltSize+nonceSize+secretbox.Overhead {
+		return nil, errors.New("unexpected end of data")
+	}
+	w := &wallet{
+		account: byteOrder.Uint32(serialized),
+		xpub:    string(serialized[5 : 5+xpubLen]),
+	}
+	offset := 5 + xpubLen
+	offset += copy(w.salt[:], serialized[offset:])
+	offset += copy(w.nonce[:], serialized[offset:])
+	w.sealed = append([]byte(nil), serialized[offset:]...)
+	return w, nil
+}
@@ -0,0 +1,173 @@
+package hotwallet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/watchonly"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// testMnemonic is the all zero entropy mnemonic of the BIP0039 test vectors.
+const testMnemonic = "abandon abandon abandon abandon abandon abandon " +
+	"abandon abandon abandon abandon abandon about"
+
+// TestDeriveAccountKey ensures the account keys are derived along the BIP0044
+// path using the coin type of the network.
+func TestDeriveAccountKey(t *testing.T) {
+	seed := bip39.NewSeed(testMnemonic, "")
+
+	// The well known first account of the mnemonic for coin type 0.
+	params := chaincfg.MainNetParams
+	params.HDCoinType = 0
+	key, err := deriveAccountKey(seed, 0, &params)
+	if err != nil {
+		t.Fatalf("deriveAccountKey: unexpected error: %v", err)
+	}
+	pub, err := key.Neuter()
+	if err != nil {
+		t.Fatalf("Neuter: unexpected error: %v", err)
+	}
+	xpub := pub.String()
+	want := "xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj"
+	if xpub != want {
+		t.Fatalf("got xpub %s, want %s", xpub, want)
+	}
+
+	// The coin type of Bitcoin Cash and the account number change the key.
+	bchKey, err := deriveAccountKey(seed, 0, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("deriveAccountKey: unexpected error: %v", err)
+	}
+	secondKey, err := deriveAccountKey(seed, 1, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("deriveAccountKey: unexpected error: %v", err)
+	}
+	if bchKey.String() == key.String() || bchKey.String() == secondKey.String() {
+		t.Fatal("account keys of different paths are equal")
+	}
+}
+
+// TestSerializeWallet ensures wallets round trip through their serialization,
+// which only encrypts the seed, and that the seed can't be decrypted with
+// another wallet passphrase.
+func TestSerializeWallet(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	seed := bip39.NewSeed(testMnemonic, "passphrase")
+	w, err := newWallet(7, seed, "wallet passphrase", params)
+	if err != nil {
+		t.Fatalf("newWallet: unexpected error: %v", err)
+	}
+	serialized := serializeWallet(w)
+	if bytes.Contains(serialized, seed) {
+		t.Fatal("serialized wallet contains the plaintext seed")
+	}
+
+	w, err = deserializeWallet(serialized)
+	if err != nil {
+		t.Fatalf("deserializeWallet: unexpected error: %v", err)
+	}
+	want, err := deriveAccountKey(seed, 7, params)
+	if err != nil {
+		t.Fatalf("deriveAccountKey: unexpected error: %v", err)
+	}
+	wantPub, err := want.Neuter()
+	if err != nil {
+		t.Fatalf("Neuter: unexpected error: %v", err)
+	}
+	if w.account != 7 || w.xpub != wantPub.String() {
+		t.Fatalf("unexpected wallet %d %s", w.account, w.xpub)
+	}
+	key, err := w.accountKey("wallet passphrase", params)
+	if err != nil {
+		t.Fatalf("accountKey: unexpected error: %v", err)
+	}
+	if key.String() != want.String() {
+		t.Fatalf("got account key %s, want %s", key, want)
+	}
+
+	if _, err := w.accountKey("other", params); err != ErrDecrypt {
+		t.Fatalf("got error %v, want %v", err, ErrDecrypt)
+	}
+	if _, err := deserializeWallet(serialized[:20]); err == nil {
+		t.Fatal("deserializeWallet: expected error for truncated data")
+	}
+}
+
+// TestSign ensures the inputs of transactions built from the outputs of a
+// wallet are signed with valid signatures, and that inputs which don't spend
+// outputs of the wallet are rejected.
+func TestSign(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	key, err := deriveAccountKey(bip39.NewSeed(testMnemonic, ""), 0, params)
+	if err != nil {
+		t.Fatalf("deriveAccountKey: unexpected error: %v", err)
+	}
+
+	// utxo returns an unspent output of the wallet at the passed path.
+	utxo := func(branch, index uint32, amount int64) *watchonly.UnspentOutput {
+		branchKey, _ := key.Child(branch)
+		child, _ := branchKey.Child(index)
+		addr, err := child.Address(params)
+		if err != nil {
+			t.Fatalf("Address: unexpected error: %v", err)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+		}
+		return &watchonly.UnspentOutput{
+			OutPoint: wire.OutPoint{Hash: chainhash.Hash{byte(branch)}, Index: index},
+			Amount:   amount,
+			PkScript: pkScript,
+			Address:  addr,
+			Branch:   branch,
+			Index:    index,
+		}
+	}
+	inputs := []*watchonly.UnspentOutput{
+		utxo(watchonly.ExternalBranch, 3, 100000),
+		utxo(watchonly.InternalBranch, 0, 50000),
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	for _, input := range inputs {
+		tx.AddTxIn(wire.NewTxIn(&input.OutPoint, nil))
+	}
+	payee, err := bchutil.NewAddressPubKeyHash(make([]byte, 20), params)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash: unexpected error: %v", err)
+	}
+	pkScript, _ := txscript.PayToAddrScript(payee)
+	tx.AddTxOut(wire.NewTxOut(140000, pkScript, wire.TokenData{}))
+
+	unsigned := &watchonly.UnsignedTransaction{Tx: tx, Inputs: inputs}
+	if err := sign(key, unsigned, params); err != nil {
+		t.Fatalf("sign: unexpected error: %v", err)
+	}
+	for i, input := range inputs {
+		vm, err := txscript.NewEngine(input.PkScript, tx, i,
+			txscript.StandardVerifyFlags, nil, nil, nil, input.Amount)
+		if err != nil {
+			t.Fatalf("NewEngine: unexpected error: %v", err)
+		}
+		if err := vm.Execute(); err != nil {
+			t.Fatalf("input %d: invalid signature: %v", i, err)
+		}
+	}
+
+	// Claim the first input was derived at another index.
+	inputs[0].Index = 4
+	if err := sign(key, unsigned, params); err == nil {
+		t.Fatal("sign: expected error for input of another key")
+	}
+	inputs[0].Index = 3
+	inputs[0].Branch = 2
+	if err := sign(key, unsigned, params); err == nil {
+		t.Fatal("sign: expected error for input of unknown branch")
+	}
+}
@@ -12,11 +12,17 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime/debug"
 	"testing"
 
+	"github.com/gcash/bchd/btcjson"
 	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
 	"github.com/gcash/bchd/integration/rpctest"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
 )
 
 func testGetBestBlock(r *rpctest.Harness, t *testing.T) {
@@ -95,10 +101,482 @@ func testGetBlockHash(r *rpctest.Harness, t *testing.T) {
 	}
 }
 
+func testGenerateToAddress(r *rpctest.Harness, t *testing.T) {
+	addr, err := r.NewAddress()
+	if err != nil {
+		t.Fatalf("Unable to generate address: %v", err)
+	}
+	blockHashes, err := r.Node.GenerateToAddress(2, addr)
+	if err != nil {
+		t.Fatalf("Call to `generatetoaddress` failed: %v", err)
+	}
+	if len(blockHashes) != 2 {
+		t.Fatalf("Expected 2 block hashes, got %d", len(blockHashes))
+	}
+
+	// The coinbase of each block should pay to the requested address.
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("Unable to create script: %v", err)
+	}
+	for _, blockHash := range blockHashes {
+		block, err := r.Node.GetBlock(blockHash)
+		if err != nil {
+			t.Fatalf("Call to `getblock` failed: %v", err)
+		}
+		coinbaseScript := block.Transactions[0].TxOut[0].PkScript
+		if !bytes.Equal(coinbaseScript, pkScript) {
+			t.Fatalf("Coinbase of block %v pays to %x, wanted %x",
+				blockHash, coinbaseScript, pkScript)
+		}
+	}
+}
+
+func testGenerateBlock(r *rpctest.Harness, t *testing.T) {
+	addr, err := r.NewAddress()
+	if err != nil {
+		t.Fatalf("Unable to generate address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("Unable to create script: %v", err)
+	}
+
+	// The transaction is never submitted to the mempool, but should still
+	// be included in the generated block.
+	output := wire.NewTxOut(bchutil.SatoshiPerBitcoin, pkScript, wire.TokenData{})
+	tx, err := r.CreateTransaction([]*wire.TxOut{output}, 10, true)
+	if err != nil {
+		t.Fatalf("Unable to create transaction: %v", err)
+	}
+	blockHash, err := r.Node.GenerateBlock(addr, []*bchutil.Tx{bchutil.NewTx(tx)})
+	if err != nil {
+		t.Fatalf("Call to `generateblock` failed: %v", err)
+	}
+	block, err := r.Node.GetBlock(blockHash)
+	if err != nil {
+		t.Fatalf("Call to `getblock` failed: %v", err)
+	}
+	if len(block.Transactions) != 2 ||
+		block.Transactions[1].TxHash() != tx.TxHash() {
+		t.Fatalf("Generated block does not contain exactly the " +
+			"requested transaction")
+	}
+
+	// A block which double spends the transaction must be rejected.
+	if _, err := r.Node.GenerateBlock(addr, []*bchutil.Tx{bchutil.NewTx(tx)}); err == nil {
+		t.Fatalf("Call to `generateblock` with a spent transaction " +
+			"should have failed")
+	}
+}
+
+func testGetBlockStats(r *rpctest.Harness, t *testing.T) {
+	addr, err := r.NewAddress()
+	if err != nil {
+		t.Fatalf("Unable to generate address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("Unable to create script: %v", err)
+	}
+	output := wire.NewTxOut(bchutil.SatoshiPerBitcoin, pkScript, wire.TokenData{})
+	tx, err := r.CreateTransaction([]*wire.TxOut{output}, 10, true)
+	if err != nil {
+		t.Fatalf("Unable to create transaction: %v", err)
+	}
+	blockHash, err := r.Node.GenerateBlock(addr, []*bchutil.Tx{bchutil.NewTx(tx)})
+	if err != nil {
+		t.Fatalf("Call to `generateblock` failed: %v", err)
+	}
+	block, err := r.Node.GetBlock(blockHash)
+	if err != nil {
+		t.Fatalf("Call to `getblock` failed: %v", err)
+	}
+
+	stats, err := r.Node.GetBlockStats(btcjson.HashOrHeight(blockHash.String()), nil)
+	if err != nil {
+		t.Fatalf("Call to `getblockstats` failed: %v", err)
+	}
+	if stats.BlockHash != blockHash.String() || stats.Height == nil {
+		t.Fatalf("Unexpected block %s", stats.BlockHash)
+	}
+	if *stats.Txs != 2 || *stats.Ins != int64(len(tx.TxIn)) ||
+		*stats.Outs != int64(len(tx.TxOut)+len(block.Transactions[0].TxOut)) {
+		t.Fatalf("Unexpected counts: %d txs, %d ins, %d outs",
+			*stats.Txs, *stats.Ins, *stats.Outs)
+	}
+	if *stats.TotalSize != int64(tx.SerializeSize()) {
+		t.Fatalf("Unexpected total size %d, want %d", *stats.TotalSize,
+			tx.SerializeSize())
+	}
+
+	// The coinbase claims exactly the subsidy and the fees.
+	var coinbaseValue int64
+	for _, txOut := range block.Transactions[0].TxOut {
+		coinbaseValue += txOut.Value
+	}
+	if *stats.TotalFee <= 0 || *stats.TotalFee != coinbaseValue-*stats.Subsidy {
+		t.Fatalf("Unexpected total fee %d, coinbase %d, subsidy %d",
+			*stats.TotalFee, coinbaseValue, *stats.Subsidy)
+	}
+	if *stats.SigChecks != int64(len(tx.TxIn)) {
+		t.Fatalf("Unexpected sig checks %d", *stats.SigChecks)
+	}
+	for _, feeRate := range stats.FeeRatePercentiles {
+		if feeRate != *stats.AvgFeeRate {
+			t.Fatalf("Unexpected fee rate percentiles %v",
+				stats.FeeRatePercentiles)
+		}
+	}
+
+	// Only the selected statistics are returned for each block of a range.
+	height := *stats.Height
+	rangeStats, err := r.Node.GetBlockStatsRange(height-1, height,
+		[]string{"txs"})
+	if err != nil {
+		t.Fatalf("Call to `getblockstats` failed: %v", err)
+	}
+	if len(rangeStats) != 2 || rangeStats[1].Txs == nil ||
+		*rangeStats[1].Txs != 2 || rangeStats[1].TotalFee != nil {
+		t.Fatalf("Unexpected range statistics %+v", rangeStats)
+	}
+}
+
+func testTestMempoolAccept(r *rpctest.Harness, t *testing.T) {
+	// The parent pays to a script anyone can spend, so the child spending
+	// it does not have to be signed.
+	redeemScript := []byte{txscript.OP_TRUE}
+	addr, err := bchutil.NewAddressScriptHash(redeemScript, r.ActiveNet)
+	if err != nil {
+		t.Fatalf("Unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("Unable to create script: %v", err)
+	}
+	output := wire.NewTxOut(bchutil.SatoshiPerBitcoin, pkScript, wire.TokenData{})
+	parent, err := r.CreateTransaction([]*wire.TxOut{output}, 10, true)
+	if err != nil {
+		t.Fatalf("Unable to create transaction: %v", err)
+	}
+	defer r.UnlockOutputs(parent.TxIn)
+
+	sigScript, err := txscript.NewScriptBuilder().AddData(redeemScript).Script()
+	if err != nil {
+		t.Fatalf("Unable to create script: %v", err)
+	}
+	parentHash := parent.TxHash()
+	child := wire.NewMsgTx(wire.TxVersion)
+	child.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&parentHash, 0), sigScript))
+	child.AddTxOut(wire.NewTxOut(output.Value-1000, pkScript, wire.TokenData{}))
+
+	// The child is only accepted when it follows its parent.
+	results, err := r.Node.TestMempoolAccept([]*wire.MsgTx{parent, child})
+	if err != nil {
+		t.Fatalf("Call to `testmempoolaccept` failed: %v", err)
+	}
+	if len(results) != 2 || !results[0].Allowed || !results[1].Allowed {
+		t.Fatalf("Unexpected results %+v", results)
+	}
+	if results[0].TxID != parent.TxHash().String() ||
+		results[1].Fee == nil || *results[1].Fee != 0.00001 ||
+		results[0].SigChecks == nil || *results[0].SigChecks == 0 {
+
+		t.Fatalf("Unexpected results %+v", results)
+	}
+	results, err = r.Node.TestMempoolAccept([]*wire.MsgTx{child})
+	if err != nil {
+		t.Fatalf("Call to `testmempoolaccept` failed: %v", err)
+	}
+	if results[0].Allowed || results[0].RejectReason == "" {
+		t.Fatalf("Unexpected result %+v", results[0])
+	}
+
+	// Neither transaction was added to the mempool.
+	mempool, err := r.Node.GetRawMempool()
+	if err != nil {
+		t.Fatalf("Call to `getrawmempool` failed: %v", err)
+	}
+	if len(mempool) != 0 {
+		t.Fatalf("Unexpected transactions in the mempool: %v", mempool)
+	}
+}
+
+func testTxOutProof(r *rpctest.Harness, t *testing.T) {
+	blockHashes, err := r.Node.Generate(1)
+	if err != nil {
+		t.Fatalf("Unable to generate block: %v", err)
+	}
+	block, err := r.Node.GetBlock(blockHashes[0])
+	if err != nil {
+		t.Fatalf("Call to `getblock` failed: %v", err)
+	}
+	txid := block.Transactions[0].TxHash().String()
+
+	// The harness does not run with a transaction index, so the block hash
+	// is required to create the proof but not to verify it.
+	blockHash := blockHashes[0].String()
+	proof, err := r.Node.GetTxOutProof([]string{txid}, &blockHash)
+	if err != nil {
+		t.Fatalf("Call to `gettxoutproof` failed: %v", err)
+	}
+	txids, err := r.Node.VerifyTxOutProof(proof)
+	if err != nil {
+		t.Fatalf("Call to `verifytxoutproof` failed: %v", err)
+	}
+	if len(txids) != 1 || txids[0] != txid {
+		t.Fatalf("Proof commits to %v, want %v", txids, txid)
+	}
+}
+
+func testAnalyzeTransaction(r *rpctest.Harness, t *testing.T) {
+	addr, err := r.NewAddress()
+	if err != nil {
+		t.Fatalf("Unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("Unable to create script: %v", err)
+	}
+
+	// The first output is dust while the second one is not.
+	outputs := []*wire.TxOut{
+		wire.NewTxOut(100, pkScript, wire.TokenData{}),
+		wire.NewTxOut(bchutil.SatoshiPerBitcoin, pkScript, wire.TokenData{}),
+	}
+	tx, err := r.CreateTransaction(outputs, 10, true)
+	if err != nil {
+		t.Fatalf("Unable to create transaction: %v", err)
+	}
+	defer r.UnlockOutputs(tx.TxIn)
+
+	result, err := r.Node.AnalyzeTransaction(tx)
+	if err != nil {
+		t.Fatalf("Call to `analyzetransaction` failed: %v", err)
+	}
+	if result.TxID != tx.TxHash().String() || result.Standard ||
+		len(result.Violations) != 1 || len(result.MissingInputs) != 0 {
+
+		t.Fatalf("Unexpected result %+v", result)
+	}
+	violation := result.Violations[0]
+	if violation.Rule != "dust" || violation.Output == nil ||
+		*violation.Output != 0 || violation.Input != nil ||
+		violation.RejectCode != wire.RejectDust.String() {
+
+		t.Fatalf("Unexpected violation %+v", violation)
+	}
+
+	// The transaction was not submitted.
+	mempool, err := r.Node.GetRawMempool()
+	if err != nil {
+		t.Fatalf("Call to `getrawmempool` failed: %v", err)
+	}
+	if len(mempool) != 0 {
+		t.Fatalf("Unexpected transactions in the mempool: %v", mempool)
+	}
+}
+
+func testSetRelayPolicy(r *rpctest.Harness, t *testing.T) {
+	addr, err := r.NewAddress()
+	if err != nil {
+		t.Fatalf("Unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("Unable to create script: %v", err)
+	}
+
+	// Submit a transaction paying 10 satoshi per byte.
+	output := wire.NewTxOut(bchutil.SatoshiPerBitcoin, pkScript, wire.TokenData{})
+	tx, err := r.CreateTransaction([]*wire.TxOut{output}, 10, true)
+	if err != nil {
+		t.Fatalf("Unable to create transaction: %v", err)
+	}
+	defer r.UnlockOutputs(tx.TxIn)
+	if _, err := r.Node.SendRawTransaction(tx, true); err != nil {
+		t.Fatalf("Call to `sendrawtransaction` failed: %v", err)
+	}
+
+	// Without options the policy is returned unchanged.
+	result, err := r.Node.SetRelayPolicy(nil)
+	if err != nil {
+		t.Fatalf("Call to `setrelaypolicy` failed: %v", err)
+	}
+	minRelayTxFee := result.MinRelayTxFee
+	if minRelayTxFee != 0.00001 || len(result.Removed) != 0 {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+
+	// Raising the minimum relay fee above the fee rate of the transaction
+	// removes it from the mempool.
+	result, err = r.Node.SetRelayPolicy(&btcjson.RelayPolicyOptions{
+		MinRelayTxFee: btcjson.Float64(0.0002),
+	})
+	if err != nil {
+		t.Fatalf("Call to `setrelaypolicy` failed: %v", err)
+	}
+	defer r.Node.SetRelayPolicy(&btcjson.RelayPolicyOptions{
+		MinRelayTxFee: btcjson.Float64(minRelayTxFee),
+	})
+	if result.MinRelayTxFee != 0.0002 || len(result.Removed) != 1 ||
+		result.Removed[0] != tx.TxHash().String() {
+
+		t.Fatalf("Unexpected result %+v", result)
+	}
+	mempool, err := r.Node.GetRawMempool()
+	if err != nil {
+		t.Fatalf("Call to `getrawmempool` failed: %v", err)
+	}
+	if len(mempool) != 0 {
+		t.Fatalf("Unexpected transactions in the mempool: %v", mempool)
+	}
+	info, err := r.Node.GetMempoolInfo()
+	if err != nil {
+		t.Fatalf("Call to `getmempoolinfo` failed: %v", err)
+	}
+	if info.MempoolMinFee != 0.0002 {
+		t.Fatalf("Unexpected mempool minimum fee %v", info.MempoolMinFee)
+	}
+}
+
+func testGetFirstSeen(r *rpctest.Harness, t *testing.T) {
+	// Tracking is disabled on the primary harness.
+	bestHash, _, err := r.Node.GetBestBlock()
+	if err != nil {
+		t.Fatalf("Call to `getbestblock` failed: %v", err)
+	}
+	if _, err := r.Node.GetFirstSeen(bestHash); err == nil {
+		t.Fatalf("getfirstseen succeeded with tracking disabled")
+	}
+
+	// Create a harness tracking the blocks it sees and connect it to the
+	// primary harness.
+	tracker, err := rpctest.New(&chaincfg.SimNetParams, nil,
+		[]string{"--firstseen"})
+	if err != nil {
+		t.Fatalf("Unable to create harness: %v", err)
+	}
+	defer tracker.TearDown()
+	if err := tracker.SetUp(false, 0); err != nil {
+		t.Fatalf("Unable to setup harness: %v", err)
+	}
+	if err := rpctest.ConnectNode(tracker, r); err != nil {
+		t.Fatalf("Unable to connect harnesses: %v", err)
+	}
+	nodes := []*rpctest.Harness{r, tracker}
+	if err := rpctest.JoinNodes(nodes, rpctest.Blocks); err != nil {
+		t.Fatalf("Unable to join harnesses: %v", err)
+	}
+
+	blockHashes, err := r.Node.Generate(1)
+	if err != nil {
+		t.Fatalf("Unable to generate block: %v", err)
+	}
+	if err := rpctest.JoinNodes(nodes, rpctest.Blocks); err != nil {
+		t.Fatalf("Unable to join harnesses: %v", err)
+	}
+
+	result, err := tracker.Node.GetFirstSeen(blockHashes[0])
+	if err != nil {
+		t.Fatalf("Call to `getfirstseen` failed: %v", err)
+	}
+	if result.Hash != blockHashes[0].String() || result.Type != "block" ||
+		result.Announced.Peer == "" || result.Received == nil ||
+		result.Delay == nil || *result.Delay < 0 {
+
+		t.Fatalf("Unexpected result %+v", result)
+	}
+
+	// Hashes which were never seen have no record.
+	if _, err := tracker.Node.GetFirstSeen(&chainhash.Hash{}); err == nil {
+		t.Fatalf("getfirstseen succeeded for an unknown hash")
+	}
+}
+
+func testReloadConfig(r *rpctest.Harness, t *testing.T) {
+	// Create a harness reading a config file.
+	configFile := filepath.Join(t.TempDir(), "bchd.conf")
+	writeConfig := func(contents string) {
+		err := os.WriteFile(configFile, []byte(contents), 0600)
+		if err != nil {
+			t.Fatalf("Unable to write config file: %v", err)
+		}
+	}
+	writeConfig("minrelaytxfee=0.00002\n")
+	harness, err := rpctest.New(&chaincfg.SimNetParams, nil,
+		[]string{"--configfile=" + configFile})
+	if err != nil {
+		t.Fatalf("Unable to create harness: %v", err)
+	}
+	defer harness.TearDown()
+	if err := harness.SetUp(false, 0); err != nil {
+		t.Fatalf("Unable to setup harness: %v", err)
+	}
+
+	// Nothing is applied when the configuration didn't change.
+	result, err := harness.Node.ReloadConfig()
+	if err != nil {
+		t.Fatalf("Call to `reloadconfig` failed: %v", err)
+	}
+	if len(result.Applied) != 0 || len(result.Removed) != 0 {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+
+	// The changed options are applied and reported without the secrets.
+	writeConfig("minrelaytxfee=0.00003\nrpcauth=auditor:cb77f0957de88ff" +
+		"388cf817ddbc7273$" + fmt.Sprintf("%064x", 0) + "\n")
+	result, err = harness.Node.ReloadConfig()
+	if err != nil {
+		t.Fatalf("Call to `reloadconfig` failed: %v", err)
+	}
+	want := []btcjson.ReloadedOptionResult{
+		{Option: "minrelaytxfee", Value: "0.00003 BCH"},
+		{Option: "rpcauth", Value: "auditor"},
+	}
+	if len(result.Applied) != len(want) {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+	for i := range want {
+		if result.Applied[i] != want[i] {
+			t.Fatalf("Unexpected result %+v", result)
+		}
+	}
+	policy, err := harness.Node.SetRelayPolicy(nil)
+	if err != nil {
+		t.Fatalf("Call to `setrelaypolicy` failed: %v", err)
+	}
+	if policy.MinRelayTxFee != 0.00003 {
+		t.Fatalf("Unexpected minimum relay fee %v", policy.MinRelayTxFee)
+	}
+
+	// Nothing is applied when any of the options is invalid.
+	writeConfig("minrelaytxfee=0.00004\ndatacarrierprotocol=zz\n")
+	if _, err := harness.Node.ReloadConfig(); err == nil {
+		t.Fatalf("reloadconfig succeeded with an invalid option")
+	}
+	policy, err = harness.Node.SetRelayPolicy(nil)
+	if err != nil {
+		t.Fatalf("Call to `setrelaypolicy` failed: %v", err)
+	}
+	if policy.MinRelayTxFee != 0.00003 {
+		t.Fatalf("Unexpected minimum relay fee %v", policy.MinRelayTxFee)
+	}
+}
+
 var rpcTestCases = []rpctest.HarnessTestCase{
 	testGetBestBlock,
 	testGetBlockCount,
 	testGetBlockHash,
+	testGenerateToAddress,
+	testGenerateBlock,
+	testGetBlockStats,
+	testTestMempoolAccept,
+	testAnalyzeTransaction,
+	testSetRelayPolicy,
+	testTxOutProof,
+	testGetFirstSeen,
+	testReloadConfig,
 }
 
 var primaryHarness *rpctest.Harness
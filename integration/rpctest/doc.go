@@ -5,6 +5,11 @@
 // creating new addresses, and crafting fully signed transactions paying to an
 // arbitrary set of outputs.
 //
+// Harnesses may be run with a custom network upgrade schedule, and a Network of
+// connected harnesses may be created to test the propagation of blocks and
+// transactions between nodes, including reorganizations after a partition. The
+// in-memory wallet is also able to create and spend CashTokens.
+//
 // This package was designed specifically to act as an RPC testing harness for
 // `bchd`. However, the constructs presented are general enough to be adapted to
 // any project wishing to programmatically drive a `bchd` instance of its
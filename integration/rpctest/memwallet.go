@@ -15,12 +15,19 @@ import (
 	"github.com/gcash/bchd/chaincfg"
 	"github.com/gcash/bchd/chaincfg/chainhash"
 	"github.com/gcash/bchd/rpcclient"
+	"github.com/gcash/bchd/txbuilder"
 	"github.com/gcash/bchd/txscript"
 	"github.com/gcash/bchd/wire"
 	"github.com/gcash/bchutil"
 	"github.com/gcash/bchutil/hdkeychain"
 )
 
+const (
+	// spendSize is the largest number of bytes of a sigScript which spends
+	// a p2pkh output: OP_DATA_73 <sig> OP_DATA_33 <pubkey>
+	spendSize = 1 + 73 + 1 + 33
+)
+
 var (
 	// hdSeed is the BIP 32 seed used by the memWallet to initialize it's
 	// HD root key. This value is hard coded in order to ensure
@@ -39,6 +46,7 @@ var (
 type utxo struct {
 	pkScript       []byte
 	value          bchutil.Amount
+	tokenData      wire.TokenData
 	keyIndex       uint32
 	maturityHeight int32
 	isLocked       bool
@@ -275,6 +283,7 @@ func (m *memWallet) evalOutputs(outputs []*wire.TxOut, txHash *chainhash.Hash,
 				keyIndex:       keyIndex,
 				maturityHeight: maturityHeight,
 				pkScript:       pkScript,
+				tokenData:      output.TokenData,
 			}
 			undo.utxosCreated = append(undo.utxosCreated, op)
 		}
@@ -383,12 +392,6 @@ func (m *memWallet) NewAddress() (bchutil.Address, error) {
 func (m *memWallet) fundTx(tx *wire.MsgTx, amt bchutil.Amount,
 	feeRate bchutil.Amount, change bool) ([]bchutil.Amount, error) {
 
-	const (
-		// spendSize is the largest number of bytes of a sigScript
-		// which spends a p2pkh output: OP_DATA_73 <sig> OP_DATA_33 <pubkey>
-		spendSize = 1 + 73 + 1 + 33
-	)
-
 	var (
 		amtSelected  bchutil.Amount
 		txSize       int
@@ -396,9 +399,10 @@ func (m *memWallet) fundTx(tx *wire.MsgTx, amt bchutil.Amount,
 	)
 
 	for outPoint, utxo := range m.utxos {
-		// Skip any outputs that are still currently immature or are
-		// currently locked.
-		if !utxo.isMature(m.currentHeight) || utxo.isLocked {
+		// Skip any outputs that are still currently immature, are
+		// currently locked, or carry tokens which would be burned.
+		if !utxo.isMature(m.currentHeight) || utxo.isLocked ||
+			!utxo.tokenData.IsEmpty() {
 			continue
 		}
 
@@ -499,50 +503,218 @@ func (m *memWallet) CreateTransaction(outputs []*wire.TxOut,
 	}
 
 	// Attempt to fund the transaction with spendable utxos.
-	inputAmounts, err := m.fundTx(tx, outputAmt, feeRate, change)
+	_, err := m.fundTx(tx, outputAmt, feeRate, change)
+	if err != nil {
+		return nil, err
+	}
+
+	// Populate all the selected inputs with valid sigScript for spending,
+	// then lock the outputs being spent in order to avoid a potential
+	// double spend.
+	if err := m.signTx(tx); err != nil {
+		return nil, err
+	}
+	m.lockInputs(tx)
+
+	return tx, nil
+}
+
+// CreateTokenGenesis returns a fully signed transaction creating the tokens of
+// a new category and paying them to the specified outputs while observing the
+// desired fee rate. The passed fee rate should be expressed in
+// satoshis-per-byte. The category of the tokens of every output is set to the
+// new category, which is the hash of the transaction of the wallet output
+// spent by the first input. Change is paid to a fresh wallet address and the
+// spent output is locked as it is by CreateTransaction.
+//
+// This function is safe for concurrent access.
+func (m *memWallet) CreateTokenGenesis(outputs []*wire.TxOut,
+	feeRate bchutil.Amount) (*wire.MsgTx, error) {
+
+	m.Lock()
+	defer m.Unlock()
+
+	var outputAmt bchutil.Amount
+	for _, output := range outputs {
+		outputAmt += bchutil.Amount(output.Value)
+	}
+
+	// Tokens may only be created by an input spending the first output of
+	// a transaction, so find a spendable one large enough to pay for the
+	// outputs.
+	var genesisOutPoint *wire.OutPoint
+	for outPoint, utxo := range m.utxos {
+		if outPoint.Index != 0 || !utxo.isMature(m.currentHeight) ||
+			utxo.isLocked || !utxo.tokenData.IsEmpty() ||
+			utxo.value <= outputAmt {
+			continue
+		}
+		outPoint := outPoint
+		genesisOutPoint = &outPoint
+		break
+	}
+	if genesisOutPoint == nil {
+		return nil, fmt.Errorf("no spendable output to create tokens from")
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(genesisOutPoint, nil))
+	for _, output := range outputs {
+		tokenData := output.TokenData
+		tokenData.CategoryID = genesisOutPoint.Hash
+		tx.AddTxOut(wire.NewTxOut(output.Value, output.PkScript, tokenData))
+	}
+
+	addr, err := m.newAddress()
 	if err != nil {
 		return nil, err
 	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+	changeOutput := wire.NewTxOut(0, pkScript, wire.TokenData{})
+	tx.AddTxOut(changeOutput)
+
+	txSize := tx.SerializeSize() + spendSize
+	reqFee := bchutil.Amount(txSize * int(feeRate))
+	changeVal := m.utxos[*genesisOutPoint].value - outputAmt - reqFee
+	if changeVal <= 0 {
+		return nil, fmt.Errorf("not enough funds to create tokens")
+	}
+	changeOutput.Value = int64(changeVal)
 
-	// Populate all the selected inputs with valid sigScript for spending.
-	// Along the way record all outputs being spent in order to avoid a
-	// potential double spend.
-	spentOutputs := make([]*utxo, 0, len(tx.TxIn))
+	if err := m.signTx(tx); err != nil {
+		return nil, err
+	}
+	m.lockInputs(tx)
+
+	return tx, nil
+}
+
+// CreateTokenTransaction returns a fully signed transaction paying to the
+// specified outputs, which may carry tokens, while observing the desired fee
+// rate. The passed fee rate should be expressed in satoshis-per-byte. The
+// wallet outputs carrying the tokens paid to the outputs are spent along with
+// outputs without tokens paying for the outputs and the fee. Change, including
+// the tokens which are not paid to the outputs, is paid to a fresh wallet
+// address. The spent outputs are locked as they are by CreateTransaction.
+//
+// This function is safe for concurrent access.
+func (m *memWallet) CreateTokenTransaction(outputs []*wire.TxOut,
+	feeRate bchutil.Amount) (*wire.MsgTx, error) {
+
+	m.Lock()
+	defer m.Unlock()
+
+	var utxos []*txbuilder.Utxo
+	for outPoint, utxo := range m.utxos {
+		if !utxo.isMature(m.currentHeight) || utxo.isLocked {
+			continue
+		}
+		utxos = append(utxos, &txbuilder.Utxo{
+			OutPoint: outPoint,
+			Output: *wire.NewTxOut(int64(utxo.value), utxo.pkScript,
+				utxo.tokenData),
+		})
+	}
+
+	addr, err := m.newAddress()
+	if err != nil {
+		return nil, err
+	}
+	changeScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+	unsigned, err := txbuilder.Build(&txbuilder.Request{
+		Utxos:        utxos,
+		Outputs:      outputs,
+		ChangeScript: changeScript,
+		FeePerKb:     int64(feeRate) * 1000,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tx := unsigned.Tx
+	if err := m.signTx(tx); err != nil {
+		return nil, err
+	}
+	m.lockInputs(tx)
+
+	return tx, nil
+}
+
+// signTx populates every input of the passed transaction, all of which must
+// spend wallet outputs, with a valid sigScript. The spent outputs are
+// committed to when signing so inputs spending outputs which carry tokens are
+// signed correctly.
+//
+// NOTE: The memWallet's mutex must be held when this function is called.
+func (m *memWallet) signTx(tx *wire.MsgTx) error {
+	utxoCache := txscript.NewUtxoCache()
+	for i, txIn := range tx.TxIn {
+		utxo, ok := m.utxos[txIn.PreviousOutPoint]
+		if !ok {
+			return fmt.Errorf("output %v is not spendable by the wallet",
+				txIn.PreviousOutPoint)
+		}
+		utxoCache.AddEntry(i, *wire.NewTxOut(int64(utxo.value),
+			utxo.pkScript, utxo.tokenData))
+	}
+	sigHashes := txscript.NewTxSigHashes(tx)
+	sigHashes.AddTxSigHashUtxoFromUtxoCache(tx, utxoCache)
+
+	hashType := txscript.SigHashAll | txscript.SigHashForkID
 	for i, txIn := range tx.TxIn {
-		outPoint := txIn.PreviousOutPoint
-		utxo := m.utxos[outPoint]
+		utxo := m.utxos[txIn.PreviousOutPoint]
 
 		extendedKey, err := m.hdRoot.Child(utxo.keyIndex)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		privKey, err := extendedKey.ECPrivKey()
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		sigScript, err := txscript.SignatureScript(tx, i,
-			int64(inputAmounts[i].ToUnit(bchutil.AmountSatoshi)), utxo.pkScript,
-			txscript.SigHashAll, privKey, true)
+		hash, _, err := txscript.CalcSignatureHash(utxo.pkScript,
+			sigHashes, hashType, tx, i, int64(utxo.value), true)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		sig, err := privKey.SignSchnorr(hash)
+		if err != nil {
+			return err
 		}
 
+		sigScript, err := txscript.NewScriptBuilder().
+			AddData(append(sig.Serialize(), byte(hashType))).
+			AddData(privKey.PubKey().SerializeCompressed()).
+			Script()
+		if err != nil {
+			return err
+		}
 		txIn.SignatureScript = sigScript
-
-		spentOutputs = append(spentOutputs, utxo)
 	}
 
-	// As these outputs are now being spent by this newly created
-	// transaction, mark the outputs are "locked". This action ensures
-	// these outputs won't be double spent by any subsequent transactions.
-	// These locked outputs can be freed via a call to UnlockOutputs.
-	for _, utxo := range spentOutputs {
-		utxo.isLocked = true
-	}
+	return nil
+}
 
-	return tx, nil
+// lockInputs marks the outputs spent by the passed transaction as "locked".
+// This action ensures these outputs won't be double spent by any subsequent
+// transactions. These locked outputs can be freed via a call to
+// UnlockOutputs.
+//
+// NOTE: The memWallet's mutex must be held when this function is called.
+func (m *memWallet) lockInputs(tx *wire.MsgTx) {
+	for _, txIn := range tx.TxIn {
+		if utxo, ok := m.utxos[txIn.PreviousOutPoint]; ok {
+			utxo.isLocked = true
+		}
+	}
 }
 
 // UnlockOutputs unlocks any outputs which were previously locked due to
@@ -584,6 +756,27 @@ func (m *memWallet) ConfirmedBalance() bchutil.Amount {
 	return balance
 }
 
+// TokenBalance returns the confirmed amount of the fungible tokens of the
+// passed category held by the wallet.
+//
+// This function is safe for concurrent access.
+func (m *memWallet) TokenBalance(category [32]byte) uint64 {
+	m.RLock()
+	defer m.RUnlock()
+
+	var balance uint64
+	for _, utxo := range m.utxos {
+		if !utxo.isMature(m.currentHeight) || utxo.isLocked ||
+			utxo.tokenData.CategoryID != category {
+			continue
+		}
+
+		balance += utxo.tokenData.Amount
+	}
+
+	return balance
+}
+
 // keyToAddr maps the passed private to corresponding p2pkh address.
 func keyToAddr(key *bchec.PrivateKey, net *chaincfg.Params) (bchutil.Address, error) {
 	serializedKey := key.PubKey().SerializeCompressed()
@@ -0,0 +1,97 @@
+package rpctest
+
+import (
+	"fmt"
+
+	"github.com/gcash/bchd/chaincfg"
+)
+
+// Network is a set of test harnesses whose nodes are connected to each other
+// in order to test how blocks and transactions propagate between nodes.  Every
+// node is connected to every node which precedes it in Nodes, so the network
+// may be partitioned with DisconnectNode to let the partitions mine competing
+// chains, and joined again with ConnectNode to trigger a reorganization.
+type Network struct {
+	// Nodes are the harnesses of the nodes of the network.  The first node
+	// mines the initial chain and therefore holds the mature coinbase
+	// outputs.
+	Nodes []*Harness
+}
+
+// NewNetwork creates a network of numNodes harnesses which all run with the
+// passed upgrade schedule and extra arguments.  The upgrade schedule may be
+// nil to use the one of activeNet.
+//
+// NOTE: This function is safe for concurrent access.
+func NewNetwork(activeNet *chaincfg.Params, numNodes int,
+	upgrades UpgradeSchedule, extraArgs []string) (*Network, error) {
+
+	if numNodes < 1 {
+		return nil, fmt.Errorf("a network requires at least one node")
+	}
+
+	n := &Network{Nodes: make([]*Harness, 0, numNodes)}
+	for i := 0; i < numNodes; i++ {
+		args := make([]string, len(extraArgs))
+		copy(args, extraArgs)
+		h, err := NewWithUpgrades(activeNet, upgrades, nil, args)
+		if err != nil {
+			n.TearDown()
+			return nil, err
+		}
+		n.Nodes = append(n.Nodes, h)
+	}
+
+	return n, nil
+}
+
+// SetUp starts every node of the network, generates a test chain with the
+// passed number of mature coinbase outputs on the first node, connects the
+// nodes to each other, and blocks until every node has synced the chain.
+//
+// NOTE: This method and TearDown should always be called from the same
+// goroutine as they are not concurrent safe.
+func (n *Network) SetUp(numMatureOutputs uint32) error {
+	for i, h := range n.Nodes {
+		if err := h.SetUp(i == 0, numMatureOutputs); err != nil {
+			return err
+		}
+	}
+
+	for i, from := range n.Nodes {
+		for _, to := range n.Nodes[:i] {
+			if err := ConnectNode(from, to); err != nil {
+				return err
+			}
+		}
+	}
+
+	return n.Sync()
+}
+
+// Sync blocks until every node of the network reports the same best chain.
+func (n *Network) Sync() error {
+	return JoinNodes(n.Nodes, Blocks)
+}
+
+// SyncMempools blocks until every node of the network has an identical
+// mempool.
+func (n *Network) SyncMempools() error {
+	return JoinNodes(n.Nodes, Mempools)
+}
+
+// TearDown stops every node of the network.  All created processes are killed,
+// and temporary directories removed.  The last error encountered, if any, is
+// returned.
+//
+// NOTE: This method and SetUp should always be called from the same goroutine
+// as they are not concurrent safe.
+func (n *Network) TearDown() error {
+	var returnErr error
+	for _, h := range n.Nodes {
+		if err := h.TearDown(); err != nil {
+			returnErr = err
+		}
+	}
+	return returnErr
+}
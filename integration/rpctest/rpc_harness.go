@@ -100,9 +100,34 @@ type Harness struct {
 func New(activeNet *chaincfg.Params, handlers *rpcclient.NotificationHandlers,
 	extraArgs []string) (*Harness, error) {
 
+	return NewWithUpgrades(activeNet, nil, handlers, extraArgs)
+}
+
+// NewWithUpgrades is like New except the node activates the network upgrades
+// according to the passed schedule rather than the one of activeNet. The
+// ActiveNet of the returned harness is a copy of activeNet with the schedule
+// applied. Overriding the schedule is only supported on regtest and simnet.
+//
+// NOTE: This function is safe for concurrent access.
+func NewWithUpgrades(activeNet *chaincfg.Params, upgrades UpgradeSchedule,
+	handlers *rpcclient.NotificationHandlers, extraArgs []string) (*Harness, error) {
+
 	harnessStateMtx.Lock()
 	defer harnessStateMtx.Unlock()
 
+	if len(upgrades) > 0 {
+		if activeNet.Net != wire.TestNet && activeNet.Net != wire.SimNet {
+			return nil, fmt.Errorf("upgrade schedules are only " +
+				"supported on regtest and simnet")
+		}
+		params, err := upgrades.apply(activeNet)
+		if err != nil {
+			return nil, err
+		}
+		activeNet = params
+		extraArgs = append(extraArgs, upgrades.args()...)
+	}
+
 	// Add a flag for the appropriate network type based on the provided
 	// chain params.
 	switch activeNet.Net {
@@ -398,6 +423,77 @@ func (h *Harness) UnlockOutputs(inputs []*wire.TxIn) {
 	h.wallet.UnlockOutputs(inputs)
 }
 
+// CreateTokenGenesis returns a fully signed transaction creating the tokens of
+// a new category and paying them to the specified outputs while observing the
+// desired fee rate. The passed fee rate should be expressed in
+// satoshis-per-byte. The category of the tokens of every output is replaced
+// with the new category, which is the hash of the transaction of the output
+// spent by the first input. As with CreateTransaction, the spent output is
+// locked until the transaction is mined or UnlockOutputs is called.
+//
+// This function is safe for concurrent access.
+func (h *Harness) CreateTokenGenesis(targetOutputs []*wire.TxOut,
+	feeRate bchutil.Amount) (*wire.MsgTx, error) {
+
+	return h.wallet.CreateTokenGenesis(targetOutputs, feeRate)
+}
+
+// CreateTokenTransaction returns a fully signed transaction paying to the
+// specified outputs, which may carry tokens held by the harness' wallet, while
+// observing the desired fee rate. The passed fee rate should be expressed in
+// satoshis-per-byte. Tokens spent but not paid to the outputs are returned to
+// the wallet along with the change. As with CreateTransaction, the spent
+// outputs are locked until the transaction is mined or UnlockOutputs is
+// called.
+//
+// This function is safe for concurrent access.
+func (h *Harness) CreateTokenTransaction(targetOutputs []*wire.TxOut,
+	feeRate bchutil.Amount) (*wire.MsgTx, error) {
+
+	return h.wallet.CreateTokenTransaction(targetOutputs, feeRate)
+}
+
+// TokenBalance returns the confirmed amount of the fungible tokens of the
+// passed category held by the Harness' internal wallet.
+//
+// This function is safe for concurrent access.
+func (h *Harness) TokenBalance(category [32]byte) uint64 {
+	return h.wallet.TokenBalance(category)
+}
+
+// MempoolTxs returns the transactions in the mempool of the harness' node.
+func (h *Harness) MempoolTxs() ([]*bchutil.Tx, error) {
+	hashes, err := h.Node.GetRawMempool()
+	if err != nil {
+		return nil, err
+	}
+	txns := make([]*bchutil.Tx, 0, len(hashes))
+	for _, hash := range hashes {
+		tx, err := h.Node.GetRawTransaction(hash)
+		if err != nil {
+			return nil, err
+		}
+		txns = append(txns, tx)
+	}
+	return txns, nil
+}
+
+// BestBlock returns the block at the tip of the best chain of the harness'
+// node.
+func (h *Harness) BestBlock() (*bchutil.Block, error) {
+	hash, height, err := h.Node.GetBestBlock()
+	if err != nil {
+		return nil, err
+	}
+	mBlock, err := h.Node.GetBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+	block := bchutil.NewBlock(mBlock)
+	block.SetHeight(height)
+	return block, nil
+}
+
 // RPCConfig returns the harnesses current rpc configuration. This allows other
 // potential RPC clients created within tests to connect to a given test
 // harness instance.
@@ -452,16 +548,10 @@ func (h *Harness) GenerateAndSubmitBlockWithCustomCoinbaseOutputs(
 		blockVersion = BlockVersion
 	}
 
-	prevBlockHash, prevBlockHeight, err := h.Node.GetBestBlock()
-	if err != nil {
-		return nil, err
-	}
-	mBlock, err := h.Node.GetBlock(prevBlockHash)
+	prevBlock, err := h.BestBlock()
 	if err != nil {
 		return nil, err
 	}
-	prevBlock := bchutil.NewBlock(mBlock)
-	prevBlock.SetHeight(prevBlockHeight)
 
 	// Create a new block including the specified transactions
 	newBlock, err := CreateBlock(prevBlock, txns, blockVersion,
@@ -557,6 +557,216 @@ func testMemWalletLockedOutputs(r *Harness, t *testing.T) {
 	}
 }
 
+func testSubmitBlockRejection(r *Harness, t *testing.T) {
+	// Create a transaction and replace the signature script of its first
+	// input with one that pushes an empty public key, which fails the
+	// OP_EQUALVERIFY of the pay-to-pubkey-hash output it spends.
+	addr, err := r.NewAddress()
+	if err != nil {
+		t.Fatalf("unable to generate new address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create script: %v", err)
+	}
+	output := wire.NewTxOut(bchutil.SatoshiPerBitcoin, pkScript, wire.TokenData{})
+	tx, err := r.CreateTransaction([]*wire.TxOut{output}, 10, true)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	defer r.UnlockOutputs(tx.TxIn)
+	tx.TxIn[0].SignatureScript = []byte{txscript.OP_0, txscript.OP_0}
+
+	prevBlock, err := r.BestBlock()
+	if err != nil {
+		t.Fatalf("unable to get best block: %v", err)
+	}
+	block, err := CreateBlock(prevBlock, []*bchutil.Tx{bchutil.NewTx(tx)},
+		BlockVersion, time.Time{}, r.wallet.coinbaseAddr, nil, r.ActiveNet)
+	if err != nil {
+		t.Fatalf("unable to create block: %v", err)
+	}
+
+	// The node must report the failing input and opcode.
+	result, err := r.Node.SubmitBlockVerbose(block)
+	if err != nil {
+		t.Fatalf("unable to submit block: %v", err)
+	}
+	if result.Accepted {
+		t.Fatal("block with invalid input script was accepted")
+	}
+	if result.ErrorCode != "ErrScriptValidation" ||
+		result.ScriptError != "ErrEqualVerify" {
+		t.Fatalf("unexpected rejection %q (%s, %s)", result.Reason,
+			result.ErrorCode, result.ScriptError)
+	}
+	if result.TxIndex == nil || *result.TxIndex != 1 ||
+		result.TxID != tx.TxHash().String() {
+		t.Fatalf("unexpected offending transaction %v %s",
+			result.TxIndex, result.TxID)
+	}
+	if result.InputIndex == nil || *result.InputIndex != 0 {
+		t.Fatalf("unexpected offending input %v", result.InputIndex)
+	}
+	if result.ScriptIndex == nil || *result.ScriptIndex != 1 ||
+		result.OpcodeIndex == nil || *result.OpcodeIndex != 3 {
+		t.Fatalf("unexpected script position %v:%v",
+			result.ScriptIndex, result.OpcodeIndex)
+	}
+}
+
+// waitForWalletSync blocks until the wallet of the passed harness has synced
+// to the tip of the best chain of its node.
+func waitForWalletSync(t *testing.T, h *Harness) {
+	_, height, err := h.Node.GetBestBlock()
+	if err != nil {
+		t.Fatalf("unable to get best block: %v", err)
+	}
+	timeout := time.After(time.Minute)
+	for h.wallet.SyncedHeight() != height {
+		select {
+		case <-timeout:
+			t.Fatalf("wallet never synced to height %d", height)
+		case <-time.After(time.Millisecond * 100):
+		}
+	}
+}
+
+func testNetworkReorg(r *Harness, t *testing.T) {
+	network, err := NewNetwork(&chaincfg.SimNetParams, 3, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer network.TearDown()
+	if err := network.SetUp(5); err != nil {
+		t.Fatalf("unable to set up network: %v", err)
+	}
+
+	// Partition the third node from the rest of the network and let both
+	// partitions mine, the majority one mining the longer chain.
+	nodes := network.Nodes
+	for _, to := range nodes[:2] {
+		if err := DisconnectNode(nodes[2], to); err != nil {
+			t.Fatalf("unable to disconnect nodes: %v", err)
+		}
+	}
+	minorityHashes, err := nodes[2].Node.Generate(2)
+	if err != nil {
+		t.Fatalf("unable to generate blocks: %v", err)
+	}
+	if _, err := nodes[0].Node.Generate(3); err != nil {
+		t.Fatalf("unable to generate blocks: %v", err)
+	}
+	if err := JoinNodes(nodes[:2], Blocks); err != nil {
+		t.Fatalf("unable to join nodes on blocks: %v", err)
+	}
+
+	// Rejoining the partitions must reorganize the minority node onto the
+	// longer chain.
+	for _, to := range nodes[:2] {
+		if err := ConnectNode(nodes[2], to); err != nil {
+			t.Fatalf("unable to connect nodes: %v", err)
+		}
+	}
+	if err := network.Sync(); err != nil {
+		t.Fatalf("unable to sync network: %v", err)
+	}
+	best, err := nodes[2].BestBlock()
+	if err != nil {
+		t.Fatalf("unable to get best block: %v", err)
+	}
+	expectedHeight := int32(nodes[0].ActiveNet.CoinbaseMaturity) + 5 + 3
+	if best.Height() != expectedHeight {
+		t.Fatalf("best height is %d, should be %d", best.Height(),
+			expectedHeight)
+	}
+	for _, hash := range minorityHashes {
+		if *best.Hash() == *hash {
+			t.Fatalf("minority block %v is still the tip", hash)
+		}
+	}
+}
+
+func testTokenTransactions(r *Harness, t *testing.T) {
+	// Tokens are only valid once upgrade9 has activated, so activate it
+	// from the start.
+	harness, err := NewWithUpgrades(&chaincfg.SimNetParams,
+		UpgradeSchedule{"upgrade9": 0}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer harness.TearDown()
+	if err := harness.SetUp(true, 5); err != nil {
+		t.Fatalf("unable to complete rpctest setup: %v", err)
+	}
+
+	addr, err := harness.NewAddress()
+	if err != nil {
+		t.Fatalf("unable to generate new address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create script: %v", err)
+	}
+	token := wire.TokenData{Amount: 1000, BitField: wire.HAS_AMOUNT}
+	genesis, err := harness.CreateTokenGenesis([]*wire.TxOut{
+		wire.NewTxOut(10000, pkScript, token),
+	}, 10)
+	if err != nil {
+		t.Fatalf("unable to create token genesis: %v", err)
+	}
+	category := genesis.TxIn[0].PreviousOutPoint.Hash
+	if _, err := harness.Node.SendRawTransaction(genesis, true); err != nil {
+		t.Fatalf("unable to send token genesis: %v", err)
+	}
+
+	// The genesis transaction should be in the mempool of the node and,
+	// once mined, the tokens should be held by the wallet.
+	mempool, err := harness.MempoolTxs()
+	if err != nil {
+		t.Fatalf("unable to get mempool: %v", err)
+	}
+	if len(mempool) != 1 || *mempool[0].Hash() != genesis.TxHash() {
+		t.Fatalf("token genesis not found in mempool")
+	}
+	if _, err := harness.Node.Generate(1); err != nil {
+		t.Fatalf("unable to generate block: %v", err)
+	}
+	waitForWalletSync(t, harness)
+	if balance := harness.TokenBalance(category); balance != 1000 {
+		t.Fatalf("token balance is %d, should be 1000", balance)
+	}
+
+	// Pay some of the tokens to the main harness.  The remainder should be
+	// returned to the wallet as change.
+	addr, err = r.NewAddress()
+	if err != nil {
+		t.Fatalf("unable to generate new address: %v", err)
+	}
+	pkScript, err = txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create script: %v", err)
+	}
+	token.CategoryID = category
+	token.Amount = 400
+	tx, err := harness.CreateTokenTransaction([]*wire.TxOut{
+		wire.NewTxOut(10000, pkScript, token),
+	}, 10)
+	if err != nil {
+		t.Fatalf("unable to create token transaction: %v", err)
+	}
+	if _, err := harness.Node.SendRawTransaction(tx, true); err != nil {
+		t.Fatalf("unable to send token transaction: %v", err)
+	}
+	if _, err := harness.Node.Generate(1); err != nil {
+		t.Fatalf("unable to generate block: %v", err)
+	}
+	waitForWalletSync(t, harness)
+	if balance := harness.TokenBalance(category); balance != 600 {
+		t.Fatalf("token balance is %d, should be 600", balance)
+	}
+}
+
 var harnessTestCases = []HarnessTestCase{
 	testSendOutputs,
 	testConnectNode,
@@ -567,6 +777,9 @@ var harnessTestCases = []HarnessTestCase{
 	testGenerateAndSubmitBlockWithCustomCoinbaseOutputs,
 	testMemWalletReorg,
 	testMemWalletLockedOutputs,
+	testNetworkReorg,
+	testTokenTransactions,
+	testSubmitBlockRejection,
 }
 
 var mainHarness *Harness
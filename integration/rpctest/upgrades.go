@@ -0,0 +1,38 @@
+package rpctest
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gcash/bchd/chaincfg"
+)
+
+// UpgradeSchedule maps the names of network upgrades, as returned by
+// chaincfg.UpgradeNames, to their activation.  Upgrades which activate by
+// height take the height of the last block before the upgrade, while those
+// which activate by time take the median time past at which the upgrade
+// activates.
+type UpgradeSchedule map[string]int64
+
+// apply returns a copy of the passed parameters with the schedule applied.
+func (s UpgradeSchedule) apply(params *chaincfg.Params) (*chaincfg.Params, error) {
+	p := *params
+	for upgrade, activation := range s {
+		if err := p.SetUpgradeActivation(upgrade, activation); err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
+
+// args returns the command line arguments which apply the schedule to a node.
+// They are sorted to keep the command line of a node deterministic.
+func (s UpgradeSchedule) args() []string {
+	args := make([]string, 0, len(s))
+	for upgrade, activation := range s {
+		args = append(args, fmt.Sprintf("--upgradeactivation=%s:%d",
+			upgrade, activation))
+	}
+	sort.Strings(args)
+	return args
+}
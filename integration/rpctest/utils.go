@@ -134,6 +134,45 @@ func ConnectNode(from *Harness, to *Harness) error {
 	return nil
 }
 
+// DisconnectNode removes the persistent peer-to-peer connection established
+// from the "from" harness to the "to" harness by ConnectNode.  It blocks until
+// both harnesses have dropped the connection, which allows tests to partition
+// a network in order to create competing chains.
+func DisconnectNode(from *Harness, to *Harness) error {
+	fromPeers, err := from.Node.GetPeerInfo()
+	if err != nil {
+		return err
+	}
+	toPeers, err := to.Node.GetPeerInfo()
+	if err != nil {
+		return err
+	}
+
+	targetAddr := to.node.config.listen
+	if err := from.Node.AddNode(targetAddr, rpcclient.ANRemove); err != nil {
+		return err
+	}
+
+	// Block until both sides of the connection have been torn down.
+	for _, h := range []struct {
+		harness  *Harness
+		numPeers int
+	}{{from, len(fromPeers)}, {to, len(toPeers)}} {
+		for {
+			peerInfo, err := h.harness.Node.GetPeerInfo()
+			if err != nil {
+				return err
+			}
+			if len(peerInfo) < h.numPeers {
+				break
+			}
+			time.Sleep(time.Millisecond * 100)
+		}
+	}
+
+	return nil
+}
+
 // TearDownAll tears down all active test harnesses.
 func TearDownAll() error {
 	harnessStateMtx.Lock()
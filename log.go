@@ -12,16 +12,26 @@ import (
 	"path/filepath"
 
 	"github.com/gcash/bchd/addrmgr"
+	"github.com/gcash/bchd/banmgr"
+	"github.com/gcash/bchd/bcmr"
 	"github.com/gcash/bchd/blockchain"
 	"github.com/gcash/bchd/blockchain/indexers"
 	"github.com/gcash/bchd/connmgr"
 	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/firstseen"
+	"github.com/gcash/bchd/hotwallet"
 	"github.com/gcash/bchd/mempool"
 	"github.com/gcash/bchd/mining"
 	"github.com/gcash/bchd/mining/cpuminer"
+	"github.com/gcash/bchd/mining/stratum"
 	"github.com/gcash/bchd/netsync"
 	"github.com/gcash/bchd/peer"
+	"github.com/gcash/bchd/rescan"
+	"github.com/gcash/bchd/seeder"
+	"github.com/gcash/bchd/structlog"
 	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/watchonly"
+	"github.com/gcash/bchd/webhook"
 
 	"github.com/gcash/bchlog"
 	"github.com/jrick/logrotate/rotator"
@@ -48,8 +58,9 @@ func (logWriter) Write(p []byte) (n int, err error) {
 var (
 	// backendLog is the logging backend used to create all subsystem loggers.
 	// The backend must not be used before the log rotator has been initialized,
-	// or data races and/or nil pointer dereferences will occur.
-	backendLog = bchlog.NewBackend(logWriter{})
+	// or data races and/or nil pointer dereferences will occur.  Its format and
+	// sampling are configured once the config is loaded.
+	backendLog = structlog.NewBackend(logWriter{})
 
 	// logRotator is one of the logging outputs.  It should be closed on
 	// application shutdown.
@@ -57,56 +68,81 @@ var (
 
 	adxrLog = backendLog.Logger("ADXR")
 	amgrLog = backendLog.Logger("AMGR")
+	banmLog = backendLog.Logger("BANM")
+	bcmrLog = backendLog.Logger("BCMR")
 	cmgrLog = backendLog.Logger("CMGR")
 	bcdbLog = backendLog.Logger("BCDB")
 	bchdLog = backendLog.Logger("BCHD")
 	chanLog = backendLog.Logger("CHAN")
 	discLog = backendLog.Logger("DISC")
+	fsenLog = backendLog.Logger("FSEN")
+	hwltLog = backendLog.Logger("HWLT")
 	indxLog = backendLog.Logger("INDX")
 	minrLog = backendLog.Logger("MINR")
 	peerLog = backendLog.Logger("PEER")
+	rscnLog = backendLog.Logger("RSCN")
 	rpcsLog = backendLog.Logger("RPCS")
 	scrpLog = backendLog.Logger("SCRP")
+	seedLog = backendLog.Logger("SEED")
 	srvrLog = backendLog.Logger("SRVR")
 	syncLog = backendLog.Logger("SYNC")
 	txmpLog = backendLog.Logger("TXMP")
 	grpcLog = backendLog.Logger("GRPC")
+	wlltLog = backendLog.Logger("WLLT")
+	whokLog = backendLog.Logger("WHOK")
 )
 
 // Initialize package-global logger variables.
 func init() {
 	addrmgr.UseLogger(amgrLog)
+	banmgr.UseLogger(banmLog)
+	bcmr.UseLogger(bcmrLog)
 	connmgr.UseLogger(cmgrLog)
 	database.UseLogger(bcdbLog)
+	firstseen.UseLogger(fsenLog)
+	hotwallet.UseLogger(hwltLog)
 	blockchain.UseLogger(chanLog)
 	indexers.UseLogger(indxLog)
 	mining.UseLogger(minrLog)
 	cpuminer.UseLogger(minrLog)
+	stratum.UseLogger(minrLog)
 	peer.UseLogger(peerLog)
+	rescan.UseLogger(rscnLog)
 	txscript.UseLogger(scrpLog)
+	seeder.UseLogger(seedLog)
 	netsync.UseLogger(syncLog)
 	mempool.UseLogger(txmpLog)
 	bchrpc.UseLogger(grpcLog)
+	watchonly.UseLogger(wlltLog)
+	webhook.UseLogger(whokLog)
 }
 
 // subsystemLoggers maps each subsystem identifier to its associated logger.
 var subsystemLoggers = map[string]bchlog.Logger{
 	"ADXR": adxrLog,
 	"AMGR": amgrLog,
+	"BANM": banmLog,
+	"BCMR": bcmrLog,
 	"CMGR": cmgrLog,
 	"BCDB": bcdbLog,
 	"BCHD": bchdLog,
 	"CHAN": chanLog,
 	"DISC": discLog,
+	"FSEN": fsenLog,
+	"HWLT": hwltLog,
 	"INDX": indxLog,
 	"MINR": minrLog,
 	"PEER": peerLog,
+	"RSCN": rscnLog,
 	"RPCS": rpcsLog,
 	"SCRP": scrpLog,
+	"SEED": seedLog,
 	"SRVR": srvrLog,
 	"SYNC": syncLog,
 	"TXMP": txmpLog,
 	"GRPC": grpcLog,
+	"WLLT": wlltLog,
+	"WHOK": whokLog,
 }
 
 // initLogRotator initializes the logging rotater to write logs to logFile and
@@ -143,17 +179,6 @@ func setLogLevel(subsystemID string, logLevel string) {
 	logger.SetLevel(level)
 }
 
-// setLogLevels sets the log level for all subsystem loggers to the passed
-// level.  It also dynamically creates the subsystem loggers as needed, so it
-// can be used to initialize the logging system.
-func setLogLevels(logLevel string) {
-	// Configure all sub-systems with the new logging level.  Dynamically
-	// create loggers as needed.
-	for subsystemID := range subsystemLoggers {
-		setLogLevel(subsystemID, logLevel)
-	}
-}
-
 // directionString is a helper function that returns a string that represents
 // the direction of a connection (inbound or outbound).
 func directionString(inbound bool) string {
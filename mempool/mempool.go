@@ -12,6 +12,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -44,6 +45,11 @@ const (
 	// orphanExpireScanInterval is the minimum amount of time in between
 	// scans of the orphan pool to evict expired transactions.
 	orphanExpireScanInterval = time.Minute * 5
+
+	// rollingMinFeeHalfLife is the time it takes for the minimum fee rate
+	// required to enter the pool after transactions were evicted to decay
+	// to half its value.
+	rollingMinFeeHalfLife = time.Hour * 12
 )
 
 // Tag represents an identifier to use for tagging orphan transactions.  The
@@ -91,6 +97,18 @@ type Config struct {
 	// HashCache defines the transaction hash mid-state cache to use.
 	HashCache *txscript.HashCache
 
+	// ScriptCache defines the script verification cache to add the
+	// accepted transactions to, so their scripts are not executed again
+	// when they are included in a block.  This can be nil if the script
+	// cache is not used.
+	ScriptCache *txscript.ScriptCache
+
+	// NextBlockScriptFlags defines the function to use to obtain the
+	// script flags the transactions in the next block are validated with.
+	// The transactions are only added to the script cache under these
+	// flags.
+	NextBlockScriptFlags func() (txscript.ScriptFlags, error)
+
 	// AddrIndex defines the optional address index instance to use for
 	// indexing the unconfirmed transactions in the memory pool.
 	// This can be nil if the address index is not enabled.
@@ -99,6 +117,90 @@ type Config struct {
 	// FeeEstimatator provides a feeEstimator. If it is not nil, the mempool
 	// records all new transactions it observes into the feeEstimator.
 	FeeEstimator *FeeEstimator
+
+	// NotifyDoubleSpend defines the function to call when a transaction
+	// which double spends a transaction in the pool is detected.  It may
+	// be nil, in which case double spends are not reported.  It is called
+	// with the mempool lock held, so it must not call back into the pool.
+	NotifyDoubleSpend func(*DoubleSpend)
+
+	// NotifyPoolChange defines the function to call when a transaction is
+	// added to or removed from the pool.  It may be nil, in which case the
+	// changes are not reported.  It is called with the mempool lock held
+	// in the order of the changes, so it must not call back into the pool.
+	NotifyPoolChange func(*PoolChange)
+
+	// NotifyExpired defines the function to call with the transactions
+	// removed from the pool by ExpireTransactions.  It may be nil, in
+	// which case the expired transactions are not reported.  It is called
+	// without the mempool lock held.
+	NotifyExpired func([]*TxDesc)
+}
+
+// PoolEntry describes a transaction in the memory pool along with the
+// transactions in the pool it depends on.
+type PoolEntry struct {
+	*TxDesc
+
+	// Depends holds the hashes of the transactions in the pool whose
+	// outputs the transaction spends.
+	Depends []chainhash.Hash
+
+	// AncestorCount, AncestorSize and AncestorFees are the number, the
+	// total serialized size and the total fees of the transactions in the
+	// pool the transaction depends on, directly or indirectly, including
+	// the transaction itself.
+	AncestorCount int
+	AncestorSize  int64
+	AncestorFees  int64
+}
+
+// PoolChange describes a transaction which was added to or removed from the
+// memory pool.
+type PoolChange struct {
+	// Sequence is the sequence number of the pool after the change.  The
+	// sequence number is incremented by each change, so the changes
+	// following a snapshot of the pool are those with higher sequence
+	// numbers than the snapshot.
+	Sequence uint64
+
+	// Removed is true when the transaction was removed from the pool and
+	// false when it was added to it.
+	Removed bool
+
+	// Entry describes the transaction.  The transactions it depends on
+	// are only set when it was added to the pool.
+	Entry PoolEntry
+}
+
+// PoolSnapshot holds the transactions in the memory pool at a point in time.
+type PoolSnapshot struct {
+	// Sequence is the sequence number of the pool when the snapshot was
+	// taken.
+	Sequence uint64
+
+	// Entries describes the transactions in the pool, ordered such that
+	// each transaction comes after the transactions it depends on.
+	Entries []PoolEntry
+}
+
+// DoubleSpend describes a transaction which spends an output already spent by
+// a transaction in the memory pool.
+type DoubleSpend struct {
+	// OutPoint is the output spent by both transactions.
+	OutPoint wire.OutPoint
+
+	// FirstSeen is the transaction in the pool which spent the output
+	// first.
+	FirstSeen *bchutil.Tx
+
+	// Conflict is the transaction which double spends the output.
+	Conflict *bchutil.Tx
+
+	// Confirmed is true when the conflicting transaction was confirmed in
+	// a block, in which case the first seen transaction was removed from
+	// the pool.  Otherwise the conflicting transaction was rejected.
+	Confirmed bool
 }
 
 // Policy houses the policy (configuration parameters) which is used to
@@ -135,9 +237,61 @@ type Policy struct {
 	// of signature checks in each transaction.
 	LimitSigChecks bool
 
+	// DataCarrier defines the standardness rules for null data outputs.
+	DataCarrier DataCarrierPolicy
+
 	// MinRelayTxFee defines the minimum transaction fee in BCH/kB to be
 	// considered a non-zero fee.
 	MinRelayTxFee bchutil.Amount
+
+	// DustRelayFee defines the fee rate in BCH/kB used to determine
+	// whether the value of an output is dust.  An output is dust when
+	// spending it would cost more than a third of its value at this fee
+	// rate.  A value of zero disables the dust limit.
+	DustRelayFee bchutil.Amount
+
+	// MaxTxSigChecks is the maximum number of signature checks performed
+	// by the scripts of a standard transaction.  A value of zero disables
+	// the limit.
+	MaxTxSigChecks int
+
+	// MaxPoolSize is the maximum total serialized size in bytes of the
+	// transactions in the pool.  When it is exceeded, the transactions
+	// with the lowest fee rates are evicted along with their descendants.
+	// A value of zero disables the limit.
+	MaxPoolSize int64
+
+	// LimitAncestorCount is the maximum number of unconfirmed ancestors of
+	// a transaction, including the transaction itself.  A value of zero
+	// disables the limit.
+	LimitAncestorCount int
+
+	// LimitAncestorSize is the maximum total serialized size in bytes of
+	// the unconfirmed ancestors of a transaction, including the
+	// transaction itself.  A value of zero disables the limit.
+	LimitAncestorSize int64
+
+	// LimitDescendantCount is the maximum number of descendants of any
+	// transaction in the pool, including the transaction itself.  A value
+	// of zero disables the limit.
+	LimitDescendantCount int
+
+	// LimitDescendantSize is the maximum total serialized size in bytes of
+	// the descendants of any transaction in the pool, including the
+	// transaction itself.  A value of zero disables the limit.
+	LimitDescendantSize int64
+
+	// Expiry is the maximum amount of time a transaction may stay in the
+	// pool.  Expired transactions are removed along with their descendants
+	// by ExpireTransactions.  A value of zero disables the expiry.
+	Expiry time.Duration
+
+	// AllowReplacement defines whether a transaction spending exactly the
+	// same outputs as a transaction in the pool may replace it by paying
+	// more than the replaced transaction and its descendants, plus the
+	// minimum relay fee for its own size.  Otherwise, all transactions
+	// double spending a transaction in the pool are rejected.
+	AllowReplacement bool
 }
 
 // TxDesc is a descriptor containing a transaction in the mempool along with
@@ -148,6 +302,10 @@ type TxDesc struct {
 	// StartingPriority is the priority of the transaction when it was added
 	// to the pool.
 	StartingPriority float64
+
+	// ScriptMetrics houses the costs of validating the scripts of the
+	// transaction which were measured when it was added to the pool.
+	ScriptMetrics blockchain.TxScriptMetrics
 }
 
 // orphanTx is normal transaction that references an ancestor transaction
@@ -174,12 +332,28 @@ type TxPool struct {
 	outpoints     map[wire.OutPoint]*bchutil.Tx
 	pennyTotal    float64 // exponentially decaying total for penny spends.
 	lastPennyUnix int64   // unix time of last ``penny spend''
+	poolSize      int64   // total serialized size of the pool transactions.
+	sequence      uint64  // number of changes to the pool transactions.
+
+	// rollingMinFee is the minimum fee rate in satoshi per 1000 bytes
+	// required to enter the pool after transactions were evicted from it.
+	// It decays over time from the moment of its last update.
+	rollingMinFee       float64
+	lastRollingFeeDecay time.Time
 
 	// nextExpireScan is the time after which the orphan pool will be
 	// scanned in order to evict orphans.  This is NOT a hard deadline as
 	// the scan will only run when an orphan is added to the pool as opposed
 	// to on an unconditional timer.
 	nextExpireScan time.Time
+
+	// weakBlocks holds the weak blocks extending the tip of the main chain
+	// by hash in the order they were added, and weakTxs the transactions
+	// they include along with their total serialized size.
+	weakBlocks     map[chainhash.Hash]*wire.MsgBlock
+	weakBlockOrder []chainhash.Hash
+	weakTxs        map[chainhash.Hash]*weakTx
+	weakTxsSize    int
 }
 
 // Ensure the TxPool type implements the mining.TxSource interface.
@@ -481,7 +655,17 @@ func (mp *TxPool) removeTransaction(tx *bchutil.Tx, removeRedeemers bool) {
 			delete(mp.outpoints, txIn.PreviousOutPoint)
 		}
 		delete(mp.pool, *txHash)
+		mp.poolSize -= int64(txDesc.Tx.MsgTx().SerializeSize())
 		atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+
+		mp.sequence++
+		if mp.cfg.NotifyPoolChange != nil {
+			mp.cfg.NotifyPoolChange(&PoolChange{
+				Sequence: mp.sequence,
+				Removed:  true,
+				Entry:    PoolEntry{TxDesc: txDesc},
+			})
+		}
 	}
 }
 
@@ -512,18 +696,81 @@ func (mp *TxPool) RemoveDoubleSpends(tx *bchutil.Tx) {
 		if txRedeemer, ok := mp.outpoints[txIn.PreviousOutPoint]; ok {
 			if !txRedeemer.Hash().IsEqual(tx.Hash()) {
 				mp.removeTransaction(txRedeemer, true)
+				mp.notifyDoubleSpend(txIn.PreviousOutPoint,
+					txRedeemer, tx, true)
 			}
 		}
 	}
 	mp.mtx.Unlock()
 }
 
+// ExpireTransactions removes the transactions which have been in the pool for
+// longer than the expiry of the policy along with the transactions which depend
+// on them.  Trusted transactions and their ancestors do not expire.  It returns
+// the removed transactions, which are also passed to the NotifyExpired
+// callback.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) ExpireTransactions() []*TxDesc {
+	mp.mtx.Lock()
+	expiry := mp.cfg.Policy.Expiry
+	if expiry <= 0 {
+		mp.mtx.Unlock()
+		return nil
+	}
+
+	cutoff := time.Now().Add(-expiry)
+	expired := make(map[chainhash.Hash]*TxDesc)
+	for txHash, txD := range mp.pool {
+		if !txD.Added.Before(cutoff) || txD.Trusted {
+			continue
+		}
+		descendants := make(map[chainhash.Hash]*TxDesc)
+		mp.txDescendants(txD.Tx, descendants)
+		protected := false
+		for _, descendant := range descendants {
+			if descendant.Trusted {
+				protected = true
+				break
+			}
+		}
+		if protected {
+			continue
+		}
+		expired[txHash] = txD
+		for descHash, descendant := range descendants {
+			expired[descHash] = descendant
+		}
+	}
+
+	removed := make([]*TxDesc, 0, len(expired))
+	for _, txD := range expired {
+		// The transaction may already have been removed as the
+		// descendant of another one.
+		if mp.isTransactionInPool(txD.Tx.Hash()) {
+			mp.removeTransaction(txD.Tx, true)
+		}
+		removed = append(removed, txD)
+	}
+	mp.mtx.Unlock()
+
+	if len(removed) > 0 {
+		log.Debugf("Expired %d %s", len(removed),
+			pickNoun(len(removed), "transaction", "transactions"))
+		if mp.cfg.NotifyExpired != nil {
+			mp.cfg.NotifyExpired(removed)
+		}
+	}
+
+	return removed
+}
+
 // addTransaction adds the passed transaction to the memory pool.  It should
 // not be called directly as it doesn't perform any validation.  This is a
 // helper for maybeAcceptTransaction.
 //
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *bchutil.Tx, height int32, fee int64) *TxDesc {
+func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *bchutil.Tx, height int32, fee int64, scriptMetrics *blockchain.TxScriptMetrics, trusted bool) *TxDesc {
 	// Add the transaction to the pool and mark the referenced outpoints
 	// as spent by the pool.
 	txD := &TxDesc{
@@ -533,14 +780,17 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *bchutil
 			Height:   height,
 			Fee:      fee,
 			FeePerKB: fee * 1000 / int64(tx.MsgTx().SerializeSize()),
+			Trusted:  trusted,
 		},
 		StartingPriority: mining.CalcPriority(tx.MsgTx(), utxoView, height),
+		ScriptMetrics:    *scriptMetrics,
 	}
 
 	mp.pool[*tx.Hash()] = txD
 	for _, txIn := range tx.MsgTx().TxIn {
 		mp.outpoints[txIn.PreviousOutPoint] = tx
 	}
+	mp.poolSize += int64(tx.MsgTx().SerializeSize())
 	atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
 
 	// Add unconfirmed address index entries associated with the transaction
@@ -549,14 +799,513 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *bchutil
 		mp.cfg.AddrIndex.AddUnconfirmedTx(tx, utxoView)
 	}
 
-	// Record this tx for fee estimation if enabled.
-	if mp.cfg.FeeEstimator != nil {
+	// Record this tx for fee estimation if enabled.  Trusted transactions
+	// are not required to pay the relay fee, so they are left out of the
+	// estimates.
+	if mp.cfg.FeeEstimator != nil && !trusted {
 		mp.cfg.FeeEstimator.ObserveTransaction(txD)
 	}
 
+	mp.sequence++
+	if mp.cfg.NotifyPoolChange != nil {
+		mp.cfg.NotifyPoolChange(&PoolChange{
+			Sequence: mp.sequence,
+			Entry:    mp.poolEntry(txD),
+		})
+	}
+
 	return txD
 }
 
+// poolEntry returns the entry describing the passed transaction in the pool
+// along with the transactions in the pool it depends on.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) poolEntry(txD *TxDesc) PoolEntry {
+	entry := PoolEntry{
+		TxDesc:        txD,
+		AncestorCount: 1,
+		AncestorSize:  int64(txD.Tx.MsgTx().SerializeSize()),
+		AncestorFees:  txD.Fee,
+	}
+	for _, txIn := range txD.Tx.MsgTx().TxIn {
+		hash := txIn.PreviousOutPoint.Hash
+		if _, exists := mp.pool[hash]; !exists {
+			continue
+		}
+		var seen bool
+		for i := range entry.Depends {
+			if entry.Depends[i] == hash {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			entry.Depends = append(entry.Depends, hash)
+		}
+	}
+	if len(entry.Depends) == 0 {
+		return entry
+	}
+
+	ancestors := make(map[chainhash.Hash]*TxDesc)
+	mp.txAncestors(txD.Tx, ancestors)
+	for _, ancestor := range ancestors {
+		entry.AncestorCount++
+		entry.AncestorSize += int64(ancestor.Tx.MsgTx().SerializeSize())
+		entry.AncestorFees += ancestor.Fee
+	}
+	return entry
+}
+
+// txAncestors adds the transactions in the pool which the passed transaction
+// depends on, directly or indirectly, to the passed map.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) txAncestors(tx *bchutil.Tx, ancestors map[chainhash.Hash]*TxDesc) {
+	for _, txIn := range tx.MsgTx().TxIn {
+		hash := txIn.PreviousOutPoint.Hash
+		if _, exists := ancestors[hash]; exists {
+			continue
+		}
+		if txD, exists := mp.pool[hash]; exists {
+			ancestors[hash] = txD
+			mp.txAncestors(txD.Tx, ancestors)
+		}
+	}
+}
+
+// txDescendants adds the transactions in the pool which depend on the passed
+// transaction, directly or indirectly, to the passed map.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) txDescendants(tx *bchutil.Tx, descendants map[chainhash.Hash]*TxDesc) {
+	prevOut := wire.OutPoint{Hash: *tx.Hash()}
+	for txOutIdx := range tx.MsgTx().TxOut {
+		prevOut.Index = uint32(txOutIdx)
+		txRedeemer, exists := mp.outpoints[prevOut]
+		if !exists {
+			continue
+		}
+		hash := *txRedeemer.Hash()
+		if _, exists := descendants[hash]; exists {
+			continue
+		}
+		if txD, exists := mp.pool[hash]; exists {
+			descendants[hash] = txD
+			mp.txDescendants(txD.Tx, descendants)
+		}
+	}
+}
+
+// checkPackageLimits ensures adding the passed transaction to the pool does not
+// exceed the configured limits on the number and size of its unconfirmed
+// ancestors, and on the number and size of the descendants of each of those
+// ancestors.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) checkPackageLimits(tx *bchutil.Tx, txSize int64) error {
+	policy := &mp.cfg.Policy
+	checkAncestors := policy.LimitAncestorCount > 0 ||
+		policy.LimitAncestorSize > 0
+	checkDescendants := policy.LimitDescendantCount > 0 ||
+		policy.LimitDescendantSize > 0
+	if !checkAncestors && !checkDescendants {
+		return nil
+	}
+
+	ancestors := make(map[chainhash.Hash]*TxDesc)
+	mp.txAncestors(tx, ancestors)
+
+	ancestorSize := txSize
+	for _, txD := range ancestors {
+		ancestorSize += int64(txD.Tx.MsgTx().SerializeSize())
+	}
+	if policy.LimitAncestorCount > 0 &&
+		len(ancestors)+1 > policy.LimitAncestorCount {
+
+		str := fmt.Sprintf("transaction %v has too many unconfirmed "+
+			"ancestors (%d > %d)", tx.Hash(), len(ancestors)+1,
+			policy.LimitAncestorCount)
+		return txRuleError(wire.RejectNonstandard, str)
+	}
+	if policy.LimitAncestorSize > 0 &&
+		ancestorSize > policy.LimitAncestorSize {
+
+		str := fmt.Sprintf("transaction %v has unconfirmed ancestors "+
+			"of %d bytes which exceeds the limit of %d bytes",
+			tx.Hash(), ancestorSize, policy.LimitAncestorSize)
+		return txRuleError(wire.RejectNonstandard, str)
+	}
+
+	if !checkDescendants {
+		return nil
+	}
+	for hash, ancestor := range ancestors {
+		// The descendants of the ancestor include the ancestor itself
+		// and would include the passed transaction.
+		descendants := make(map[chainhash.Hash]*TxDesc)
+		mp.txDescendants(ancestor.Tx, descendants)
+		descendantCount := len(descendants) + 2
+		descendantSize := txSize + int64(ancestor.Tx.MsgTx().SerializeSize())
+		for _, txD := range descendants {
+			descendantSize += int64(txD.Tx.MsgTx().SerializeSize())
+		}
+
+		if policy.LimitDescendantCount > 0 &&
+			descendantCount > policy.LimitDescendantCount {
+
+			str := fmt.Sprintf("transaction %v would exceed the "+
+				"limit of %d descendants of unconfirmed "+
+				"transaction %v", tx.Hash(),
+				policy.LimitDescendantCount, hash)
+			return txRuleError(wire.RejectNonstandard, str)
+		}
+		if policy.LimitDescendantSize > 0 &&
+			descendantSize > policy.LimitDescendantSize {
+
+			str := fmt.Sprintf("transaction %v would exceed the "+
+				"limit of %d bytes of descendants of "+
+				"unconfirmed transaction %v", tx.Hash(),
+				policy.LimitDescendantSize, hash)
+			return txRuleError(wire.RejectNonstandard, str)
+		}
+	}
+
+	return nil
+}
+
+// limitPoolSize evicts transactions from the pool, along with the transactions
+// which depend on them, until the total size of the pool no longer exceeds the
+// configured maximum.  Transactions are evicted in order of their fee rate,
+// where the fee rate of a transaction is the higher of its own fee rate and the
+// combined fee rate of it and its descendants, so a transaction whose
+// descendants pay for it is evicted after them.  The minimum fee rate required
+// to enter the pool is then raised above the highest evicted fee rate.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) limitPoolSize() {
+	maxSize := mp.cfg.Policy.MaxPoolSize
+	if maxSize <= 0 || mp.poolSize <= maxSize {
+		return
+	}
+
+	type evictionCandidate struct {
+		tx       *bchutil.Tx
+		feePerKB int64
+	}
+	candidates := make([]evictionCandidate, 0, len(mp.pool))
+nextTx:
+	for _, txD := range mp.pool {
+		// Trusted transactions and their ancestors are never evicted.
+		if txD.Trusted {
+			continue
+		}
+		descendants := make(map[chainhash.Hash]*TxDesc)
+		mp.txDescendants(txD.Tx, descendants)
+		for _, descendant := range descendants {
+			if descendant.Trusted {
+				continue nextTx
+			}
+		}
+		fee := txD.Fee
+		size := int64(txD.Tx.MsgTx().SerializeSize())
+		for _, descendant := range descendants {
+			fee += descendant.Fee
+			size += int64(descendant.Tx.MsgTx().SerializeSize())
+		}
+		feePerKB := fee * 1000 / size
+		if txD.FeePerKB > feePerKB {
+			feePerKB = txD.FeePerKB
+		}
+		candidates = append(candidates, evictionCandidate{
+			tx:       txD.Tx,
+			feePerKB: feePerKB,
+		})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].feePerKB < candidates[j].feePerKB
+	})
+
+	var maxEvictedFeePerKB int64
+	for _, candidate := range candidates {
+		if mp.poolSize <= maxSize {
+			break
+		}
+
+		// The transaction may already have been evicted as the
+		// descendant of another one.
+		if !mp.isTransactionInPool(candidate.tx.Hash()) {
+			continue
+		}
+		log.Debugf("Evicting transaction %v with fee rate %d sat/kB "+
+			"(pool size: %d bytes)", candidate.tx.Hash(),
+			candidate.feePerKB, mp.poolSize)
+		mp.removeTransaction(candidate.tx, true)
+		maxEvictedFeePerKB = candidate.feePerKB
+	}
+
+	// Require transactions entering the pool to pay more than the evicted
+	// ones, so they are not simply replaced by transactions paying the
+	// same fee rate.
+	minFee := float64(maxEvictedFeePerKB + int64(mp.cfg.Policy.MinRelayTxFee))
+	if minFee > mp.rollingMinFeeRate() {
+		mp.rollingMinFee = minFee
+		mp.lastRollingFeeDecay = time.Now()
+	}
+}
+
+// rollingMinFeeRate returns the minimum fee rate in satoshi per 1000 bytes
+// required to enter the pool after transactions were evicted from it, or zero
+// when no such minimum is in effect.  The rate halves every
+// rollingMinFeeHalfLife and is reset once it falls below half the minimum
+// relay fee.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) rollingMinFeeRate() float64 {
+	if mp.rollingMinFee == 0 {
+		return 0
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(mp.lastRollingFeeDecay)
+	mp.rollingMinFee /= math.Pow(2, float64(elapsed)/
+		float64(rollingMinFeeHalfLife))
+	mp.lastRollingFeeDecay = now
+	if mp.rollingMinFee < float64(mp.cfg.Policy.MinRelayTxFee)/2 {
+		mp.rollingMinFee = 0
+	}
+
+	return mp.rollingMinFee
+}
+
+// MinFeeRate returns the minimum fee rate in satoshi per 1000 bytes which
+// transactions must pay to enter the pool since it was last full.  It is zero
+// when the pool has not been full recently, in which case only the minimum
+// relay fee applies.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MinFeeRate() int64 {
+	mp.mtx.Lock()
+	rate := mp.rollingMinFeeRate()
+	mp.mtx.Unlock()
+
+	return int64(rate)
+}
+
+// Snapshot returns the transactions in the pool along with the sequence number
+// of the pool.  Together with the changes reported by the NotifyPoolChange
+// function of the configuration, whose sequence numbers are higher than the
+// one of the snapshot, it allows mirroring the pool without missing changes.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Snapshot() *PoolSnapshot {
+	mp.mtx.RLock()
+	snapshot := &PoolSnapshot{
+		Sequence: mp.sequence,
+		Entries:  make([]PoolEntry, 0, len(mp.pool)),
+	}
+	for _, txD := range mp.pool {
+		snapshot.Entries = append(snapshot.Entries, mp.poolEntry(txD))
+	}
+	mp.mtx.RUnlock()
+
+	// A transaction has more ancestors than any transaction it depends
+	// on, so ordering by the number of ancestors puts it after them.
+	sort.Slice(snapshot.Entries, func(i, j int) bool {
+		a, b := &snapshot.Entries[i], &snapshot.Entries[j]
+		if a.AncestorCount != b.AncestorCount {
+			return a.AncestorCount < b.AncestorCount
+		}
+		return a.Added.Before(b.Added)
+	})
+
+	return snapshot
+}
+
+// Policy returns a copy of the policy of the pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Policy() Policy {
+	mp.mtx.RLock()
+	policy := mp.cfg.Policy
+	mp.mtx.RUnlock()
+
+	return policy
+}
+
+// UpdatePolicy calls the passed function with the policy of the pool to adjust
+// it at runtime, then removes the transactions in the pool which no longer
+// meet the updated policy along with the transactions which depend on them.
+// The function must not modify the data carrier protocols in place since they
+// are shared with copies of the policy returned by Policy, but it may replace
+// them.
+//
+// Transactions which are removed are those which are not standard under the
+// updated policy, perform more signature checks than it allows, or paid the
+// minimum relay fee required before the update but not the one required
+// after it.  Transactions which were accepted without paying the minimum relay
+// fee because of their priority are kept.  The removed transactions are
+// returned.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) UpdatePolicy(update func(policy *Policy)) []*bchutil.Tx {
+	// Protect concurrent access.
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	oldPolicy := mp.cfg.Policy
+	update(&mp.cfg.Policy)
+
+	medianTimePast := mp.cfg.MedianTimePast()
+	nextBlockHeight := mp.cfg.BestHeight() + 1
+	upgrade9Active := nextBlockHeight > mp.cfg.ChainParams.Upgrade9ForkHeight
+
+	var removed []*bchutil.Tx
+	inPool := make([]*TxDesc, 0, len(mp.pool))
+	for _, txD := range mp.pool {
+		inPool = append(inPool, txD)
+	}
+	for _, txD := range inPool {
+		// The transaction may already have been removed as the
+		// descendant of another one.
+		if !mp.isTransactionInPool(txD.Tx.Hash()) {
+			continue
+		}
+		err := mp.checkPolicyUpdate(txD, &oldPolicy, nextBlockHeight,
+			medianTimePast, upgrade9Active)
+		if err == nil {
+			continue
+		}
+
+		log.Debugf("Removing transaction %v which no longer meets the "+
+			"policy: %v", txD.Tx.Hash(), err)
+		descendants := make(map[chainhash.Hash]*TxDesc)
+		mp.txDescendants(txD.Tx, descendants)
+		removed = append(removed, txD.Tx)
+		for _, descendant := range descendants {
+			removed = append(removed, descendant.Tx)
+		}
+		mp.removeTransaction(txD.Tx, true)
+	}
+	if len(removed) > 0 {
+		log.Infof("Removed %d transactions which no longer meet the "+
+			"updated policy", len(removed))
+	}
+
+	return removed
+}
+
+// checkPolicyUpdate returns an error when the passed transaction in the pool,
+// which was accepted under the passed previous policy, doesn't meet the current
+// policy of the pool.  See UpdatePolicy for the rules which are checked.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) checkPolicyUpdate(txD *TxDesc, oldPolicy *Policy,
+	nextBlockHeight int32, medianTimePast time.Time, upgrade9Active bool) error {
+
+	policy := &mp.cfg.Policy
+	if !policy.AcceptNonStd {
+		err := checkTransactionStandard(txD.Tx, nextBlockHeight,
+			medianTimePast, policy.DustRelayFee, policy.MaxTxVersion,
+			upgrade9Active, &policy.DataCarrier)
+		if err != nil {
+			return err
+		}
+
+		maxTxSigChecks := policy.MaxTxSigChecks
+		if maxTxSigChecks > 0 &&
+			txD.ScriptMetrics.SigChecks > uint32(maxTxSigChecks) {
+
+			str := fmt.Sprintf("transaction performs %d signature "+
+				"checks which exceeds the maximum of %d",
+				txD.ScriptMetrics.SigChecks, maxTxSigChecks)
+			return txRuleError(wire.RejectNonstandard, str)
+		}
+	}
+
+	serializedSize := int64(txD.Tx.MsgTx().SerializeSize())
+	oldMinFee := calcMinRequiredTxRelayFee(serializedSize,
+		oldPolicy.MinRelayTxFee)
+	minFee := calcMinRequiredTxRelayFee(serializedSize,
+		policy.MinRelayTxFee)
+	if !txD.Trusted && txD.Fee >= oldMinFee && txD.Fee < minFee {
+		str := fmt.Sprintf("transaction has %d fees which is under "+
+			"the required amount of %d", txD.Fee, minFee)
+		return txRuleError(wire.RejectInsufficientFee, str)
+	}
+
+	return nil
+}
+
+// replaceableTransaction returns the transaction in the pool which the passed
+// transaction may replace when the policy allows replacements.  This is the case
+// when all of the outputs spent by the passed transaction are spent by the same
+// transaction in the pool, which spends no other outputs and is not trusted.
+// It returns nil otherwise.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) replaceableTransaction(tx *bchutil.Tx) *TxDesc {
+	if !mp.cfg.Policy.AllowReplacement {
+		return nil
+	}
+
+	var conflict *bchutil.Tx
+	for _, txIn := range tx.MsgTx().TxIn {
+		txR, exists := mp.outpoints[txIn.PreviousOutPoint]
+		if !exists || (conflict != nil && txR != conflict) {
+			return nil
+		}
+		conflict = txR
+	}
+	if conflict == nil ||
+		len(conflict.MsgTx().TxIn) != len(tx.MsgTx().TxIn) {
+
+		return nil
+	}
+	txD := mp.pool[*conflict.Hash()]
+	if txD == nil || txD.Trusted {
+		return nil
+	}
+	return txD
+}
+
+// checkReplacementFees checks whether the passed transaction paying the passed
+// fee pays enough to replace the passed transaction in the pool.  It must pay
+// more than the replaced transaction along with its descendants plus the minimum
+// relay fee for its own size, and a higher fee rate than the replaced
+// transaction.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) checkReplacementFees(tx *bchutil.Tx, txFee int64, replaced *TxDesc) error {
+	descendants := make(map[chainhash.Hash]*TxDesc)
+	mp.txDescendants(replaced.Tx, descendants)
+	replacedFees := replaced.Fee
+	for _, descendant := range descendants {
+		replacedFees += descendant.Fee
+	}
+
+	serializedSize := int64(tx.MsgTx().SerializeSize())
+	minFee := replacedFees + calcMinRequiredTxRelayFee(serializedSize,
+		mp.cfg.Policy.MinRelayTxFee)
+	if txFee < minFee {
+		str := fmt.Sprintf("replacement transaction %v has %d fees "+
+			"which is under the required amount of %d to replace "+
+			"transaction %v and its %d descendants", tx.Hash(), txFee,
+			minFee, replaced.Tx.Hash(), len(descendants))
+		return txRuleError(wire.RejectInsufficientFee, str)
+	}
+	if feePerKB := txFee * 1000 / serializedSize; feePerKB <= replaced.FeePerKB {
+		str := fmt.Sprintf("replacement transaction %v has a fee rate "+
+			"of %d sat/kB which does not exceed the fee rate of %d "+
+			"sat/kB of transaction %v", tx.Hash(), feePerKB,
+			replaced.FeePerKB, replaced.Tx.Hash())
+		return txRuleError(wire.RejectInsufficientFee, str)
+	}
+
+	return nil
+}
+
 // checkPoolDoubleSpend checks whether or not the passed transaction is
 // attempting to spend coins already spent by other transactions in the pool.
 // Note it does not check for double spends against transactions already in the
@@ -566,6 +1315,7 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *bchutil
 func (mp *TxPool) checkPoolDoubleSpend(tx *bchutil.Tx) error {
 	for _, txIn := range tx.MsgTx().TxIn {
 		if txR, exists := mp.outpoints[txIn.PreviousOutPoint]; exists {
+			mp.notifyDoubleSpend(txIn.PreviousOutPoint, txR, tx, false)
 			str := fmt.Sprintf("output %v already spent by "+
 				"transaction %v in the memory pool",
 				txIn.PreviousOutPoint, txR.Hash())
@@ -576,6 +1326,23 @@ func (mp *TxPool) checkPoolDoubleSpend(tx *bchutil.Tx) error {
 	return nil
 }
 
+// notifyDoubleSpend reports that the passed conflicting transaction spends the
+// passed outpoint, which is already spent by the passed transaction in the
+// pool, if double spend notifications are enabled.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) notifyDoubleSpend(op wire.OutPoint, firstSeen, conflict *bchutil.Tx, confirmed bool) {
+	if mp.cfg.NotifyDoubleSpend == nil {
+		return
+	}
+	mp.cfg.NotifyDoubleSpend(&DoubleSpend{
+		OutPoint:  op,
+		FirstSeen: firstSeen,
+		Conflict:  conflict,
+		Confirmed: confirmed,
+	})
+}
+
 // CheckSpend checks whether the passed outpoint is already spent by a
 // transaction in the mempool. If that's the case the spending transaction will
 // be returned, if not nil will be returned.
@@ -709,12 +1476,74 @@ func (mp *TxPool) FetchTxDesc(txHash *chainhash.Hash) (*TxDesc, error) {
 	return nil, fmt.Errorf("transaction is not in the pool")
 }
 
-// maybeAcceptTransaction is the internal function which implements the public
-// MaybeAcceptTransaction.  See the comment for MaybeAcceptTransaction for
-// more details.
+// txPackage houses the transactions of a package tested for acceptance which
+// would be accepted, so the transactions following them in the package may be
+// tested as though they were in the pool.
+type txPackage struct {
+	txns      map[chainhash.Hash]*bchutil.Tx
+	outpoints map[wire.OutPoint]*bchutil.Tx
+}
+
+// add adds the passed transaction to the package.
+func (pkg *txPackage) add(tx *bchutil.Tx) {
+	pkg.txns[*tx.Hash()] = tx
+	for _, txIn := range tx.MsgTx().TxIn {
+		pkg.outpoints[txIn.PreviousOutPoint] = tx
+	}
+}
+
+// checkPackageDoubleSpend checks whether or not the passed transaction is
+// attempting to spend coins already spent by other transactions in the pool or
+// by the preceding transactions of the package.  Unlike checkPoolDoubleSpend,
+// double spends are not notified since the transaction is only tested.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) checkPackageDoubleSpend(tx *bchutil.Tx, pkg *txPackage) error {
+	for _, txIn := range tx.MsgTx().TxIn {
+		if txR, exists := mp.outpoints[txIn.PreviousOutPoint]; exists {
+			str := fmt.Sprintf("output %v already spent by "+
+				"transaction %v in the memory pool",
+				txIn.PreviousOutPoint, txR.Hash())
+			return txRuleError(wire.RejectDuplicate, str)
+		}
+		if txR, exists := pkg.outpoints[txIn.PreviousOutPoint]; exists {
+			str := fmt.Sprintf("output %v already spent by "+
+				"transaction %v in the package",
+				txIn.PreviousOutPoint, txR.Hash())
+			return txRuleError(wire.RejectDuplicate, str)
+		}
+	}
+
+	return nil
+}
+
+// txAcceptance houses what is learned about a transaction while checking it
+// may be accepted into the pool which is needed to add it.
+type txAcceptance struct {
+	utxoView      *blockchain.UtxoViewpoint
+	height        int32
+	fee           int64
+	scriptMetrics *blockchain.TxScriptMetrics
+	trusted       bool
+	replaced      *TxDesc
+}
+
+// checkAcceptance performs all of the checks which decide whether the passed
+// transaction may be accepted into the pool without modifying it, apart from
+// the state of the rate limiter when the rate limit flag is set.  Like
+// maybeAcceptTransaction, the unknown parents of the transaction are returned
+// when it is an orphan.
+//
+// When a package is passed, the transaction is only tested.  The transactions
+// of the package are treated as though they were in the pool and double spends
+// are not notified.  The limits on unconfirmed ancestors only account for the
+// ancestors in the pool.
+//
+// Trusted transactions are exempt from the minimum relay fee, the priority
+// requirement and the rolling minimum fee rate of the pool.
 //
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) maybeAcceptTransaction(tx *bchutil.Tx, isNew, rateLimit, rejectDupOrphans bool) ([]*chainhash.Hash, *TxDesc, error) {
+func (mp *TxPool) checkAcceptance(tx *bchutil.Tx, isNew, rateLimit, rejectDupOrphans, trusted bool, pkg *txPackage) ([]*chainhash.Hash, *txAcceptance, error) {
 	txHash := tx.Hash()
 
 	// Don't accept the transaction if it already exists in the pool.  This
@@ -727,6 +1556,13 @@ func (mp *TxPool) maybeAcceptTransaction(tx *bchutil.Tx, isNew, rateLimit, rejec
 		str := fmt.Sprintf("already have transaction %v", txHash)
 		return nil, nil, txRuleError(wire.RejectDuplicate, str)
 	}
+	if pkg != nil {
+		if _, exists := pkg.txns[*txHash]; exists {
+			str := fmt.Sprintf("transaction %v is already in the "+
+				"package", txHash)
+			return nil, nil, txRuleError(wire.RejectDuplicate, str)
+		}
+	}
 
 	medianTimePast := mp.cfg.MedianTimePast()
 
@@ -745,24 +1581,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *bchutil.Tx, isNew, rateLimit, rejec
 		upgrade9Active = true
 	}
 
-	upgrade11Active := medianTimePast.Unix() >= int64(mp.cfg.ChainParams.Upgrade11ActivationTime)
-
-	scriptFlags := txscript.StandardVerifyFlags
-	if !mp.cfg.Policy.LimitSigChecks {
-		scriptFlags ^= txscript.ScriptVerifyInputSigChecks
-	}
-
-	if upgrade9Active {
-		scriptFlags |= txscript.ScriptAllowCashTokens
-	}
-
-	if upgrade11Active {
-		scriptFlags |= txscript.ScriptAllowMay2025
-		if !mp.cfg.Policy.AcceptNonStd {
-			scriptFlags |= txscript.ScriptAllowMay2025StandardOnly
-		}
-
-	}
+	scriptFlags := mp.policyScriptFlags(upgrade9Active, medianTimePast)
 
 	// Perform preliminary sanity checks on the transaction.  This makes
 	// use of blockchain which contains the invariant rules for what
@@ -786,8 +1605,9 @@ func (mp *TxPool) maybeAcceptTransaction(tx *bchutil.Tx, isNew, rateLimit, rejec
 	// forbid their acceptance.
 	if !mp.cfg.Policy.AcceptNonStd {
 		err = checkTransactionStandard(tx, nextBlockHeight,
-			medianTimePast, mp.cfg.Policy.MinRelayTxFee,
-			mp.cfg.Policy.MaxTxVersion, upgrade9Active)
+			medianTimePast, mp.cfg.Policy.DustRelayFee,
+			mp.cfg.Policy.MaxTxVersion, upgrade9Active,
+			&mp.cfg.Policy.DataCarrier)
 		if err != nil {
 			// Attempt to extract a reject code from the error so
 			// it can be retained.  When not possible, fall back to
@@ -810,7 +1630,19 @@ func (mp *TxPool) maybeAcceptTransaction(tx *bchutil.Tx, isNew, rateLimit, rejec
 	// at this point.  There is a more in-depth check that happens later
 	// after fetching the referenced transaction inputs from the main chain
 	// which examines the actual spend data and prevents double spends.
-	err = mp.checkPoolDoubleSpend(tx)
+	//
+	// When the policy allows replacements, a transaction spending exactly
+	// the same outputs as a transaction in the pool may replace it as long
+	// as it pays enough fees, which is checked below.
+	var replaced *TxDesc
+	if pkg != nil {
+		err = mp.checkPackageDoubleSpend(tx, pkg)
+	} else {
+		replaced = mp.replaceableTransaction(tx)
+		if replaced == nil {
+			err = mp.checkPoolDoubleSpend(tx)
+		}
+	}
 	if err != nil {
 		return nil, nil, err
 	}
@@ -826,6 +1658,19 @@ func (mp *TxPool) maybeAcceptTransaction(tx *bchutil.Tx, isNew, rateLimit, rejec
 		}
 		return nil, nil, err
 	}
+	if pkg != nil {
+		for _, txIn := range tx.MsgTx().TxIn {
+			prevOut := &txIn.PreviousOutPoint
+			entry := utxoView.LookupEntry(*prevOut)
+			if entry != nil && !entry.IsSpent() {
+				continue
+			}
+			if pkgTx, exists := pkg.txns[prevOut.Hash]; exists {
+				utxoView.AddTxOut(pkgTx, prevOut.Index,
+					mining.UnminedHeight)
+			}
+		}
+	}
 
 	// Don't allow the transaction if it exists in the main chain and is not
 	// not already fully spent.
@@ -920,7 +1765,9 @@ func (mp *TxPool) maybeAcceptTransaction(tx *bchutil.Tx, isNew, rateLimit, rejec
 	serializedSize := int64(tx.MsgTx().SerializeSize())
 	minFee := calcMinRequiredTxRelayFee(serializedSize,
 		mp.cfg.Policy.MinRelayTxFee)
-	if serializedSize >= (DefaultBlockPrioritySize-1000) && txFee < minFee {
+	if !trusted && serializedSize >= (DefaultBlockPrioritySize-1000) &&
+		txFee < minFee {
+
 		str := fmt.Sprintf("transaction %v has %d fees which is under "+
 			"the required amount of %d", txHash, txFee,
 			minFee)
@@ -931,7 +1778,9 @@ func (mp *TxPool) maybeAcceptTransaction(tx *bchutil.Tx, isNew, rateLimit, rejec
 	// in the next block.  Transactions which are being added back to the
 	// memory pool from blocks that have been disconnected during a reorg
 	// are exempted.
-	if isNew && !mp.cfg.Policy.DisableRelayPriority && txFee < minFee {
+	if isNew && !trusted && !mp.cfg.Policy.DisableRelayPriority &&
+		txFee < minFee {
+
 		currentPriority := mining.CalcPriority(tx.MsgTx(), utxoView,
 			nextBlockHeight)
 		if currentPriority <= mining.MinHighPriority {
@@ -942,6 +1791,40 @@ func (mp *TxPool) maybeAcceptTransaction(tx *bchutil.Tx, isNew, rateLimit, rejec
 		}
 	}
 
+	// Don't allow transactions paying less than the fee rate required since
+	// transactions were last evicted from the full pool.  Transactions
+	// which are being added back to the memory pool from blocks that have
+	// been disconnected during a reorg are exempted.
+	if isNew && !trusted {
+		minFeePerKB := int64(mp.rollingMinFeeRate())
+		if minFeePerKB > 0 && txFee*1000/serializedSize < minFeePerKB {
+			str := fmt.Sprintf("transaction %v has a fee rate of %d "+
+				"sat/kB which is under the mempool minimum of "+
+				"%d sat/kB", txHash, txFee*1000/serializedSize,
+				minFeePerKB)
+			return nil, nil, txRuleError(wire.RejectInsufficientFee, str)
+		}
+	}
+
+	// Don't allow replacements which do not pay more than the transactions
+	// they replace, both in total and in fee rate, since they would
+	// otherwise allow relaying the same inputs repeatedly for free.
+	if replaced != nil {
+		err := mp.checkReplacementFees(tx, txFee, replaced)
+		if err != nil {
+			mp.notifyDoubleSpend(tx.MsgTx().TxIn[0].PreviousOutPoint,
+				replaced.Tx, tx, false)
+			return nil, nil, err
+		}
+	}
+
+	// Don't allow the transaction to exceed the limits on its unconfirmed
+	// ancestors and their descendants.
+	err = mp.checkPackageLimits(tx, serializedSize)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Free-to-relay transactions are rate limited here to prevent
 	// penny-flooding with tiny transactions as a form of attack.
 	if rateLimit && txFee < minFee {
@@ -968,8 +1851,9 @@ func (mp *TxPool) maybeAcceptTransaction(tx *bchutil.Tx, isNew, rateLimit, rejec
 
 	// Verify crypto signatures for each input and reject the transaction if
 	// any don't verify.
-	_, err = blockchain.ValidateTransactionScripts(tx, utxoView, scriptFlags,
-		mp.cfg.SigCache, mp.cfg.HashCache, mp.cfg.ChainParams.Upgrade9ForkHeight)
+	scriptMetrics, err := blockchain.ValidateTransactionScriptsWithMetrics(tx,
+		utxoView, scriptFlags, mp.cfg.SigCache, mp.cfg.HashCache,
+		mp.cfg.ChainParams.Upgrade9ForkHeight)
 	if err != nil {
 		if cerr, ok := err.(blockchain.RuleError); ok {
 			return nil, nil, chainRuleError(cerr)
@@ -977,8 +1861,120 @@ func (mp *TxPool) maybeAcceptTransaction(tx *bchutil.Tx, isNew, rateLimit, rejec
 		return nil, nil, err
 	}
 
+	// Don't allow transactions whose scripts perform more signature checks
+	// than the policy allows if the network parameters forbid the
+	// acceptance of non-standard transactions.
+	maxTxSigChecks := mp.cfg.Policy.MaxTxSigChecks
+	if !mp.cfg.Policy.AcceptNonStd && maxTxSigChecks > 0 &&
+		scriptMetrics.SigChecks > uint32(maxTxSigChecks) {
+
+		str := fmt.Sprintf("transaction %v performs %d signature "+
+			"checks which exceeds the maximum of %d", txHash,
+			scriptMetrics.SigChecks, maxTxSigChecks)
+		return nil, nil, txRuleError(wire.RejectNonstandard, str)
+	}
+	mp.addToScriptCache(tx, utxoView)
+
+	return nil, &txAcceptance{
+		utxoView:      utxoView,
+		height:        bestHeight,
+		fee:           txFee,
+		scriptMetrics: scriptMetrics,
+		trusted:       trusted,
+		replaced:      replaced,
+	}, nil
+}
+
+// policyScriptFlags returns the script flags the policy validates the scripts
+// of transactions with, given whether the CashTokens upgrade is active in the
+// next block and the median time past of the main chain.
+func (mp *TxPool) policyScriptFlags(upgrade9Active bool, medianTimePast time.Time) txscript.ScriptFlags {
+	upgrade11Active := medianTimePast.Unix() >= int64(mp.cfg.ChainParams.Upgrade11ActivationTime)
+
+	scriptFlags := txscript.StandardVerifyFlags
+	if !mp.cfg.Policy.LimitSigChecks {
+		scriptFlags ^= txscript.ScriptVerifyInputSigChecks
+	}
+
+	if upgrade9Active {
+		scriptFlags |= txscript.ScriptAllowCashTokens
+	}
+
+	if upgrade11Active {
+		scriptFlags |= txscript.ScriptAllowMay2025
+		if !mp.cfg.Policy.AcceptNonStd {
+			scriptFlags |= txscript.ScriptAllowMay2025StandardOnly
+		}
+
+	}
+
+	return scriptFlags
+}
+
+// addToScriptCache validates the scripts of the passed transaction, which are
+// known to be valid under the policy script flags, with the script flags of the
+// next block and adds it to the script cache when they are valid.  The
+// signatures are already in the signature cache at this point, so this is
+// considerably cheaper than the initial validation, while it saves executing
+// the scripts again when the transaction is included in a block.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) addToScriptCache(tx *bchutil.Tx, utxoView *blockchain.UtxoViewpoint) {
+	if mp.cfg.ScriptCache == nil || mp.cfg.NextBlockScriptFlags == nil {
+		return
+	}
+	scriptFlags, err := mp.cfg.NextBlockScriptFlags()
+	if err != nil {
+		log.Errorf("Unable to obtain script flags of next block: %v", err)
+		return
+	}
+	if _, ok := mp.cfg.ScriptCache.Lookup(tx.Hash(), scriptFlags); ok {
+		return
+	}
+	sigChecks, err := blockchain.ValidateTransactionScripts(tx, utxoView,
+		scriptFlags, mp.cfg.SigCache, mp.cfg.HashCache,
+		mp.cfg.ChainParams.Upgrade9ForkHeight)
+	if err != nil {
+		log.Debugf("Transaction %v not added to script cache: %v",
+			tx.Hash(), err)
+		return
+	}
+	mp.cfg.ScriptCache.Add(tx.Hash(), scriptFlags, sigChecks)
+}
+
+// maybeAcceptTransaction is the internal function which implements the public
+// MaybeAcceptTransaction.  See the comment for MaybeAcceptTransaction for
+// more details.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) maybeAcceptTransaction(tx *bchutil.Tx, isNew, rateLimit, rejectDupOrphans, trusted bool) ([]*chainhash.Hash, *TxDesc, error) {
+	missingParents, acceptance, err := mp.checkAcceptance(tx, isNew,
+		rateLimit, rejectDupOrphans, trusted, nil)
+	if err != nil || len(missingParents) > 0 {
+		return missingParents, nil, err
+	}
+
+	// Remove the transaction being replaced along with the transactions
+	// which depend on it.
+	txHash := tx.Hash()
+	if acceptance.replaced != nil {
+		log.Debugf("Replacing transaction %v with %v",
+			acceptance.replaced.Tx.Hash(), txHash)
+		mp.removeTransaction(acceptance.replaced.Tx, true)
+	}
+
 	// Add to transaction pool.
-	txD := mp.addTransaction(utxoView, tx, bestHeight, txFee)
+	txD := mp.addTransaction(acceptance.utxoView, tx, acceptance.height,
+		acceptance.fee, acceptance.scriptMetrics, acceptance.trusted)
+
+	// Evict transactions if the pool grew beyond its maximum size and
+	// reject the transaction if it was evicted itself.
+	mp.limitPoolSize()
+	if !mp.isTransactionInPool(txHash) {
+		str := fmt.Sprintf("transaction %v was evicted because the "+
+			"mempool is full", txHash)
+		return nil, nil, txRuleError(wire.RejectInsufficientFee, str)
+	}
 
 	log.Debugf("Accepted transaction %v (pool size: %v)", txHash,
 		len(mp.pool))
@@ -1000,12 +1996,135 @@ func (mp *TxPool) maybeAcceptTransaction(tx *bchutil.Tx, isNew, rateLimit, rejec
 func (mp *TxPool) MaybeAcceptTransaction(tx *bchutil.Tx, isNew, rateLimit bool) ([]*chainhash.Hash, *TxDesc, error) {
 	// Protect concurrent access.
 	mp.mtx.Lock()
-	hashes, txD, err := mp.maybeAcceptTransaction(tx, isNew, rateLimit, true)
+	hashes, txD, err := mp.maybeAcceptTransaction(tx, isNew, rateLimit, true,
+		false)
 	mp.mtx.Unlock()
 
 	return hashes, txD, err
 }
 
+// TxAcceptance describes whether a transaction tested by CheckAcceptance would
+// be accepted into the pool.
+type TxAcceptance struct {
+	// Tx is the tested transaction.
+	Tx *bchutil.Tx
+
+	// Allowed is whether the transaction would be accepted, in which case
+	// Fee and ScriptMetrics are set.
+	Allowed       bool
+	Fee           int64
+	ScriptMetrics blockchain.TxScriptMetrics
+
+	// MissingParents are the unknown transactions the transaction spends
+	// outputs of when it is an orphan.
+	MissingParents []*chainhash.Hash
+
+	// Err is the reason the transaction would be rejected.
+	Err error
+}
+
+// CheckAcceptance tests whether the passed transactions would be accepted into
+// the pool by performing the same checks as MaybeAcceptTransaction, without
+// adding them to the pool.  The transactions are tested in order, and those
+// which would be accepted are treated as though they were in the pool when
+// testing the transactions following them, so a package of transactions which
+// depend on each other may be tested as long as parents precede their
+// children.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) CheckAcceptance(txns []*bchutil.Tx) []*TxAcceptance {
+	pkg := &txPackage{
+		txns:      make(map[chainhash.Hash]*bchutil.Tx),
+		outpoints: make(map[wire.OutPoint]*bchutil.Tx),
+	}
+
+	// Protect concurrent access.
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	results := make([]*TxAcceptance, 0, len(txns))
+	for _, tx := range txns {
+		result := &TxAcceptance{Tx: tx}
+		results = append(results, result)
+
+		missingParents, acceptance, err := mp.checkAcceptance(tx, true,
+			false, true, false, pkg)
+		if err != nil {
+			result.Err = err
+			continue
+		}
+		if len(missingParents) > 0 {
+			result.MissingParents = missingParents
+			str := fmt.Sprintf("transaction %v spends outputs of "+
+				"unknown transactions", tx.Hash())
+			result.Err = txRuleError(wire.RejectInvalid, str)
+			continue
+		}
+
+		result.Allowed = true
+		result.Fee = acceptance.fee
+		result.ScriptMetrics = *acceptance.scriptMetrics
+		pkg.add(tx)
+	}
+
+	return results
+}
+
+// TxAnalysis describes the standardness of a transaction as determined by
+// AnalyzeTransaction.
+type TxAnalysis struct {
+	// Tx is the analyzed transaction.
+	Tx *bchutil.Tx
+
+	// Violations are the standardness rules the transaction violates.
+	Violations []PolicyViolation
+
+	// MissingInputs are the outputs spent by the transaction which are
+	// neither in the main chain nor in the pool.  The inputs spending them
+	// are not checked and the scripts of the transaction are not validated
+	// when there are any.
+	MissingInputs []wire.OutPoint
+}
+
+// AnalyzeTransaction checks the passed transaction against every standardness
+// rule of the policy of the pool and returns all of the rules it violates, as
+// described by the package level AnalyzeTransaction.  The transaction is
+// checked as though it were mined in the next block and its inputs may spend
+// the outputs of the main chain and of the transactions in the pool.  Unlike
+// MaybeAcceptTransaction, the rules are applied even when the policy accepts
+// non-standard transactions.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) AnalyzeTransaction(tx *bchutil.Tx) (*TxAnalysis, error) {
+	medianTimePast := mp.cfg.MedianTimePast()
+	nextBlockHeight := mp.cfg.BestHeight() + 1
+	upgrade9Active := nextBlockHeight > mp.cfg.ChainParams.Upgrade9ForkHeight
+
+	// The policy may be updated concurrently, so copy it along with the
+	// script flags derived from it while the lock is held.
+	mp.mtx.RLock()
+	policy := mp.cfg.Policy
+	scriptFlags := mp.policyScriptFlags(upgrade9Active, medianTimePast)
+	utxoView, err := mp.fetchInputUtxos(tx)
+	mp.mtx.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	analysis := &TxAnalysis{Tx: tx}
+	for _, txIn := range tx.MsgTx().TxIn {
+		entry := utxoView.LookupEntry(txIn.PreviousOutPoint)
+		if entry == nil || entry.IsSpent() {
+			analysis.MissingInputs = append(analysis.MissingInputs,
+				txIn.PreviousOutPoint)
+		}
+	}
+
+	analysis.Violations = AnalyzeTransaction(tx, utxoView, nextBlockHeight,
+		medianTimePast, scriptFlags, upgrade9Active, &policy)
+	return analysis, nil
+}
+
 // processOrphans is the internal function which implements the public
 // ProcessOrphans.  See the comment for ProcessOrphans for more details.
 //
@@ -1043,7 +2162,7 @@ func (mp *TxPool) processOrphans(acceptedTx *bchutil.Tx) []*TxDesc {
 			// Potentially accept an orphan into the tx pool.
 			for _, tx := range orphans {
 				missing, txD, err := mp.maybeAcceptTransaction(
-					tx, true, true, false)
+					tx, true, true, false, false)
 				if err != nil {
 					// The orphan is now invalid, so there
 					// is no way any other orphans which
@@ -1127,7 +2246,7 @@ func (mp *TxPool) ProcessTransaction(tx *bchutil.Tx, allowOrphan, rateLimit bool
 
 	// Potentially accept the transaction to the memory pool.
 	missingParents, txD, err := mp.maybeAcceptTransaction(tx, true, rateLimit,
-		true)
+		true, false)
 	if err != nil {
 		return nil, err
 	}
@@ -1171,6 +2290,46 @@ func (mp *TxPool) ProcessTransaction(tx *bchutil.Tx, allowOrphan, rateLimit bool
 	return nil, err
 }
 
+// ProcessTrustedTransaction is used to handle a transaction submitted by the
+// local operator which is accepted even when it pays less than the relay fee.
+// The transaction is marked as trusted, which exempts it from the fee related
+// policy and from being evicted when the pool is full, and ensures it is
+// included ahead of the other transactions in block templates.  It must
+// otherwise pass all of the checks of ProcessTransaction, and orphans are
+// rejected.
+//
+// Like ProcessTransaction, it returns a slice of transactions added to the
+// mempool, starting with the passed transaction.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) ProcessTrustedTransaction(tx *bchutil.Tx) ([]*TxDesc, error) {
+	log.Tracef("Processing trusted transaction %v", tx.Hash())
+
+	// Protect concurrent access.
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	missingParents, txD, err := mp.maybeAcceptTransaction(tx, true, false,
+		true, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(missingParents) > 0 {
+		str := fmt.Sprintf("orphan transaction %v references "+
+			"outputs of unknown or fully-spent "+
+			"transaction %v", tx.Hash(), missingParents[0])
+		return nil, txRuleError(wire.RejectDuplicate, str)
+	}
+
+	// Accept any orphan transactions that depend on this transaction.
+	newTxs := mp.processOrphans(tx)
+	acceptedTxs := make([]*TxDesc, len(newTxs)+1)
+	acceptedTxs[0] = txD
+	copy(acceptedTxs[1:], newTxs)
+
+	return acceptedTxs, nil
+}
+
 // Count returns the number of transactions in the main pool.  It does not
 // include the orphan pool.
 //
@@ -1307,6 +2466,11 @@ func (mp *TxPool) DecodeCompressedBlock(iBlock interface{}) (*wire.MsgBlock, err
 	mp.mtx.RLock()
 	defer mp.mtx.RUnlock()
 
+	// Weak blocks are encoded like compact blocks.
+	if weakBlock, ok := iBlock.(*wire.MsgWeakBlock); ok {
+		iBlock = (*wire.MsgCmpctBlock)(weakBlock)
+	}
+
 	switch block := iBlock.(type) {
 	case *wire.MsgCmpctBlock:
 		msgBlock := wire.NewMsgBlock(&block.Header)
@@ -1375,6 +2539,16 @@ func (mp *TxPool) DecodeCompressedBlock(iBlock interface{}) (*wire.MsgBlock, err
 			}
 		}
 
+		// Iterate over the transactions of the weak blocks and see if
+		// any txs match
+		for txid, wtx := range mp.weakTxs {
+			shortID := calcShortID(txid)
+			index, ok := shortIDMap[shortID]
+			if ok && recoveredTxs[index] == nil {
+				recoveredTxs[index] = wtx.tx
+			}
+		}
+
 		var pop *wire.MsgTx
 		for i, tx := range msgBlock.Transactions {
 			// This was a prefilled tx so we can continue
@@ -1401,5 +2575,7 @@ func New(cfg *Config) *TxPool {
 		orphansByPrev:  make(map[wire.OutPoint]map[chainhash.Hash]*bchutil.Tx),
 		nextExpireScan: time.Now().Add(orphanExpireScanInterval),
 		outpoints:      make(map[wire.OutPoint]*bchutil.Tx),
+		weakBlocks:     make(map[chainhash.Hash]*wire.MsgBlock),
+		weakTxs:        make(map[chainhash.Hash]*weakTx),
 	}
 }
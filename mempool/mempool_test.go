@@ -222,9 +222,10 @@ func (p *poolHarness) CreateSignedTx(inputs []spendableOutput, numOutputs uint32
 	}
 
 	// Sign the new transaction.
-	for i := range tx.TxIn {
-		sigScript, err := txscript.LegacySignatureScript(tx, i, p.payScript,
-			txscript.SigHashAll, p.signKey, true)
+	for i, input := range inputs {
+		sigScript, err := txscript.SignatureScript(tx, i,
+			int64(input.amount), p.payScript, txscript.SigHashAll,
+			p.signKey, true)
 		if err != nil {
 			return nil, err
 		}
@@ -317,7 +318,9 @@ func newPoolHarness(chainParams *chaincfg.Params) (*poolHarness, []spendableOutp
 				MaxOrphanTxs:         5,
 				MaxOrphanTxSize:      1000,
 				LimitSigChecks:       true,
+				DataCarrier:          DefaultDataCarrierPolicy,
 				MinRelayTxFee:        1000, // 1 Satoshi per byte
+				DustRelayFee:         1000,
 				MaxTxVersion:         1,
 			},
 			ChainParams:      chainParams,
@@ -870,6 +873,69 @@ func TestCheckSpend(t *testing.T) {
 	}
 }
 
+// TestDoubleSpendNotification ensures double spends of transactions in the
+// pool are reported both when the conflicting transaction is rejected and when
+// it is confirmed in a block.
+func TestDoubleSpendNotification(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	var notifications []*DoubleSpend
+	harness.txPool.cfg.NotifyDoubleSpend = func(ds *DoubleSpend) {
+		notifications = append(notifications, ds)
+	}
+
+	// Create two transactions which spend the same output.
+	firstSeen, err := harness.CreateSignedTx(outputs[:1], 1)
+	if err != nil {
+		t.Fatalf("unable to create signed tx: %v", err)
+	}
+	conflict, err := harness.CreateSignedTx(outputs[:1], 2)
+	if err != nil {
+		t.Fatalf("unable to create signed tx: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(firstSeen, true, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: failed to accept tx: %v", err)
+	}
+	if len(notifications) != 0 {
+		t.Fatalf("unexpected double spend notification")
+	}
+
+	checkNotification := func(confirmed bool) {
+		t.Helper()
+		if len(notifications) != 1 {
+			t.Fatalf("got %d double spend notifications, want 1",
+				len(notifications))
+		}
+		ds := notifications[0]
+		if ds.OutPoint != outputs[0].outPoint || ds.FirstSeen != firstSeen ||
+			ds.Conflict != conflict || ds.Confirmed != confirmed {
+
+			t.Fatalf("unexpected double spend notification %+v", ds)
+		}
+		notifications = nil
+	}
+
+	// The conflicting transaction must be rejected and reported.
+	_, err = harness.txPool.ProcessTransaction(conflict, true, false, 0)
+	if err == nil {
+		t.Fatalf("ProcessTransaction: accepted double spend")
+	}
+	checkNotification(false)
+
+	// Confirming the conflicting transaction must evict the first seen
+	// transaction and report it.
+	harness.txPool.RemoveDoubleSpends(conflict)
+	checkNotification(true)
+	if harness.txPool.HaveTransaction(firstSeen.Hash()) {
+		t.Fatalf("double spent transaction still in pool")
+	}
+}
+
 // TestTxPool_DecodeCompressedBlock tests that a compact block is decoded
 // correctly against the mempool.
 func TestTxPool_DecodeCompressedBlock(t *testing.T) {
@@ -938,3 +1004,814 @@ func TestTxPool_DecodeCompressedBlock(t *testing.T) {
 		}
 	}
 }
+
+// createTxWithFee creates a signed transaction which spends the provided output
+// to a single output paying the harness payment script and leaves the passed
+// amount as fee.
+func (p *poolHarness) createTxWithFee(input spendableOutput, fee bchutil.Amount) (*bchutil.Tx, error) {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: input.outPoint,
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	tx.AddTxOut(&wire.TxOut{
+		PkScript: p.payScript,
+		Value:    int64(input.amount - fee),
+	})
+	sigScript, err := txscript.SignatureScript(tx, 0, int64(input.amount),
+		p.payScript, txscript.SigHashAll, p.signKey, true)
+	if err != nil {
+		return nil, err
+	}
+	tx.TxIn[0].SignatureScript = sigScript
+
+	return bchutil.NewTx(tx), nil
+}
+
+// TestPackageLimits ensures transactions exceeding the configured limits on
+// their unconfirmed ancestors or the descendants of those are rejected.
+func TestPackageLimits(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+	harness.txPool.cfg.Policy.LimitAncestorCount = 3
+	harness.txPool.cfg.Policy.LimitDescendantCount = 3
+
+	// Create a parent with two outputs and a chain of three transactions
+	// spending its first output.
+	parent, err := harness.CreateSignedTx(outputs, 2)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	chainedTxns, err := harness.CreateTxChain(txOutToSpendableOut(parent, 0), 3)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	sibling, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 1),
+	}, 1)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+
+	// The parent and the first two chained transactions are within the
+	// limits, while the third one would have four unconfirmed ancestors
+	// including itself.
+	for _, tx := range append([]*bchutil.Tx{parent}, chainedTxns[:2]...) {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: failed to accept tx: %v", err)
+		}
+	}
+	_, err = harness.txPool.ProcessTransaction(chainedTxns[2], false, false, 0)
+	if code, _ := extractRejectCode(err); code != wire.RejectNonstandard {
+		t.Fatalf("ProcessTransaction: unexpected error for ancestor "+
+			"limit: %v", err)
+	}
+	testPoolMembership(tc, chainedTxns[2], false, false)
+
+	// The sibling only has the parent as ancestor, but the parent already
+	// has three descendants including itself.
+	_, err = harness.txPool.ProcessTransaction(sibling, false, false, 0)
+	if code, _ := extractRejectCode(err); code != wire.RejectNonstandard {
+		t.Fatalf("ProcessTransaction: unexpected error for descendant "+
+			"limit: %v", err)
+	}
+	testPoolMembership(tc, sibling, false, false)
+
+	// Lifting the limits allows both transactions.
+	harness.txPool.cfg.Policy.LimitAncestorCount = 0
+	harness.txPool.cfg.Policy.LimitDescendantCount = 0
+	for _, tx := range []*bchutil.Tx{chainedTxns[2], sibling} {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: failed to accept tx: %v", err)
+		}
+	}
+}
+
+// TestPoolSizeEviction ensures the transactions with the lowest fee rates are
+// evicted when the pool exceeds its maximum size and that the minimum fee rate
+// to enter the pool is raised afterwards.
+func TestPoolSizeEviction(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	// Create a parent without fee and three children paying increasing
+	// fees.  The combined fee rate of the parent and its children is
+	// higher than the fee rate of the first child, so it is evicted first.
+	parent, err := harness.CreateSignedTx(outputs, 3)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	children := make([]*bchutil.Tx, 0, 3)
+	for i := uint32(0); i < 3; i++ {
+		child, err := harness.createTxWithFee(
+			txOutToSpendableOut(parent, i), bchutil.Amount(i+1)*1000)
+		if err != nil {
+			t.Fatalf("unable to create transaction: %v", err)
+		}
+		children = append(children, child)
+	}
+	for _, tx := range append([]*bchutil.Tx{parent}, children[:2]...) {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: failed to accept tx: %v", err)
+		}
+	}
+	if rate := harness.txPool.MinFeeRate(); rate != 0 {
+		t.Fatalf("MinFeeRate: unexpected rate %d before eviction", rate)
+	}
+
+	// Limit the pool to its current size so adding the last child evicts
+	// the first one.
+	harness.txPool.cfg.Policy.MaxPoolSize = harness.txPool.poolSize
+	_, err = harness.txPool.ProcessTransaction(children[2], false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: failed to accept tx: %v", err)
+	}
+	testPoolMembership(tc, parent, false, true)
+	testPoolMembership(tc, children[0], false, false)
+	testPoolMembership(tc, children[1], false, true)
+	testPoolMembership(tc, children[2], false, true)
+	if harness.txPool.poolSize > harness.txPool.cfg.Policy.MaxPoolSize {
+		t.Fatalf("pool size %d exceeds the maximum of %d",
+			harness.txPool.poolSize, harness.txPool.cfg.Policy.MaxPoolSize)
+	}
+
+	// The evicted child no longer pays the minimum fee rate to enter the
+	// pool.
+	evictedFeePerKB := int64(1000 * 1000 / children[0].MsgTx().SerializeSize())
+	if rate := harness.txPool.MinFeeRate(); rate <= evictedFeePerKB {
+		t.Fatalf("MinFeeRate: got %d, want more than %d", rate,
+			evictedFeePerKB)
+	}
+	_, err = harness.txPool.ProcessTransaction(children[0], false, false, 0)
+	if code, _ := extractRejectCode(err); code != wire.RejectInsufficientFee {
+		t.Fatalf("ProcessTransaction: unexpected error for evicted "+
+			"tx: %v", err)
+	}
+}
+
+// TestScriptMetrics ensures the costs of validating the scripts of accepted
+// transactions are recorded in their descriptors.
+func TestScriptMetrics(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+
+	tx, err := harness.CreateSignedTx(outputs, 1)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	acceptedTxns, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: failed to accept tx: %v", err)
+	}
+	if len(acceptedTxns) != 1 {
+		t.Fatalf("ProcessTransaction: reported %d accepted transactions "+
+			"instead of 1", len(acceptedTxns))
+	}
+
+	// The input of the transaction is a pay-to-pubkey-hash spend, which
+	// performs a single signature check.
+	metrics := acceptedTxns[0].ScriptMetrics
+	if metrics.SigChecks != 1 {
+		t.Fatalf("unexpected sig checks: got %d, want 1",
+			metrics.SigChecks)
+	}
+	if metrics.OpCost <= txscript.SigCheckCostFactor ||
+		metrics.OpCost > metrics.OpCostLimit {
+
+		t.Fatalf("unexpected op cost %d with limit %d", metrics.OpCost,
+			metrics.OpCostLimit)
+	}
+	if metrics.HashDigestIterations == 0 {
+		t.Fatal("no hash digest iterations were recorded")
+	}
+}
+
+// TestUpdatePolicy ensures updating the policy of the pool removes the
+// transactions which no longer meet it along with their descendants, while
+// keeping the transactions which were accepted without paying the minimum
+// relay fee.
+func TestUpdatePolicy(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	// Create a free transaction splitting the output into four, two
+	// transactions paying fees which meet the minimum relay fee and a
+	// child of the one paying the lower fee.
+	split, err := harness.CreateSignedTx(outputs, 4)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	low, err := harness.createTxWithFee(txOutToSpendableOut(split, 0), 1000)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	lowChild, err := harness.createTxWithFee(txOutToSpendableOut(low, 0), 5000)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	high, err := harness.createTxWithFee(txOutToSpendableOut(split, 1), 5000)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	for _, tx := range []*bchutil.Tx{split, low, lowChild, high} {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: failed to accept tx: %v", err)
+		}
+	}
+
+	// Raising the minimum relay fee removes the transaction which paid the
+	// previous minimum but not the new one along with its child.
+	removed := harness.txPool.UpdatePolicy(func(policy *Policy) {
+		policy.MinRelayTxFee = 10000
+	})
+	if len(removed) != 2 {
+		t.Fatalf("UpdatePolicy: removed %d transactions, want 2",
+			len(removed))
+	}
+	testPoolMembership(tc, split, false, true)
+	testPoolMembership(tc, low, false, false)
+	testPoolMembership(tc, lowChild, false, false)
+	testPoolMembership(tc, high, false, true)
+	if fee := harness.txPool.Policy().MinRelayTxFee; fee != 10000 {
+		t.Fatalf("Policy: got minimum relay fee %v, want 10000", fee)
+	}
+
+	// Lowering the maximum number of signature checks removes the
+	// transaction which spends two inputs and rejects it afterwards.
+	spendTwo, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(split, 2), txOutToSpendableOut(split, 3),
+	}, 1)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(spendTwo, false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: failed to accept tx: %v", err)
+	}
+	removed = harness.txPool.UpdatePolicy(func(policy *Policy) {
+		policy.MaxTxSigChecks = 1
+	})
+	if len(removed) != 1 || *removed[0].Hash() != *spendTwo.Hash() {
+		t.Fatalf("UpdatePolicy: unexpected removed transactions %v",
+			removed)
+	}
+	testPoolMembership(tc, split, false, true)
+	testPoolMembership(tc, high, false, true)
+	_, err = harness.txPool.ProcessTransaction(spendTwo, false, false, 0)
+	if code, _ := extractRejectCode(err); code != wire.RejectNonstandard {
+		t.Fatalf("ProcessTransaction: unexpected error for tx "+
+			"exceeding the signature checks: %v", err)
+	}
+}
+
+// TestProcessTrustedTransaction ensures trusted transactions are accepted
+// without paying the required fees, are marked as trusted for block templates
+// and are not evicted when the pool is full.
+func TestProcessTrustedTransaction(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	split, err := harness.CreateSignedTx(outputs, 2)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(split, false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: failed to accept tx: %v", err)
+	}
+
+	// A free transaction is rejected while the pool requires a minimum
+	// fee rate, unless it is trusted.
+	harness.txPool.rollingMinFee = 100000
+	harness.txPool.lastRollingFeeDecay = time.Now()
+	free, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(split, 0),
+	}, 1)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(free, false, false, 0)
+	if code, _ := extractRejectCode(err); code != wire.RejectInsufficientFee {
+		t.Fatalf("ProcessTransaction: unexpected error for free tx: %v",
+			err)
+	}
+	acceptedTxs, err := harness.txPool.ProcessTrustedTransaction(free)
+	if err != nil {
+		t.Fatalf("ProcessTrustedTransaction: failed to accept tx: %v",
+			err)
+	}
+	if len(acceptedTxs) != 1 || !acceptedTxs[0].Trusted {
+		t.Fatalf("ProcessTrustedTransaction: unexpected accepted "+
+			"transactions %v", acceptedTxs)
+	}
+	for _, desc := range harness.txPool.MiningDescs() {
+		if trusted := *desc.Tx.Hash() == *free.Hash(); desc.Trusted != trusted {
+			t.Fatalf("MiningDescs: tx %v trusted %v, want %v",
+				desc.Tx.Hash(), desc.Trusted, trusted)
+		}
+	}
+
+	// Filling the pool evicts the transaction paying fees rather than the
+	// trusted transaction and its parent.
+	harness.txPool.rollingMinFee = 0
+	harness.txPool.cfg.Policy.MaxPoolSize = harness.txPool.poolSize
+	paid, err := harness.createTxWithFee(txOutToSpendableOut(split, 1), 5000)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(paid, false, false, 0)
+	if code, _ := extractRejectCode(err); code != wire.RejectInsufficientFee {
+		t.Fatalf("ProcessTransaction: unexpected error for evicted "+
+			"tx: %v", err)
+	}
+	testPoolMembership(tc, split, false, true)
+	testPoolMembership(tc, free, false, true)
+	testPoolMembership(tc, paid, false, false)
+
+	// Trusted orphans are rejected.
+	orphan, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(paid, 0),
+	}, 1)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTrustedTransaction(orphan)
+	if err == nil {
+		t.Fatal("ProcessTrustedTransaction: accepted orphan")
+	}
+	testPoolMembership(tc, orphan, false, false)
+}
+
+// TestExpireTransactions ensures transactions which have been in the pool for
+// longer than the expiry are removed along with their descendants and reported,
+// unless they are trusted or have trusted descendants.
+func TestExpireTransactions(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+	var notified []*TxDesc
+	harness.txPool.cfg.NotifyExpired = func(txns []*TxDesc) {
+		notified = append(notified, txns...)
+	}
+
+	// Create a transaction splitting the output into three, a child of the
+	// first output, and a trusted child of the second output.
+	split, err := harness.CreateSignedTx(outputs, 3)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	child, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(split, 0),
+	}, 1)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	trusted, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(split, 1),
+	}, 1)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	for _, tx := range []*bchutil.Tx{split, child} {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: failed to accept tx: %v", err)
+		}
+	}
+	_, err = harness.txPool.ProcessTrustedTransaction(trusted)
+	if err != nil {
+		t.Fatalf("ProcessTrustedTransaction: failed to accept tx: %v",
+			err)
+	}
+
+	// Nothing expires while the expiry is disabled.
+	harness.txPool.cfg.Policy.Expiry = 0
+	for _, txD := range harness.txPool.pool {
+		txD.Added = txD.Added.Add(-2 * time.Hour)
+	}
+	if expired := harness.txPool.ExpireTransactions(); len(expired) != 0 {
+		t.Fatalf("ExpireTransactions: expired %d transactions with "+
+			"the expiry disabled", len(expired))
+	}
+
+	// The split transaction does not expire since it has a trusted
+	// descendant, while its other child does.
+	harness.txPool.cfg.Policy.Expiry = time.Hour
+	expired := harness.txPool.ExpireTransactions()
+	if len(expired) != 1 || *expired[0].Tx.Hash() != *child.Hash() {
+		t.Fatalf("ExpireTransactions: unexpected expired transactions "+
+			"%v", expired)
+	}
+	if len(notified) != 1 || notified[0] != expired[0] {
+		t.Fatalf("NotifyExpired: unexpected notified transactions %v",
+			notified)
+	}
+	testPoolMembership(tc, split, false, true)
+	testPoolMembership(tc, child, false, false)
+	testPoolMembership(tc, trusted, false, true)
+
+	// Once the trusted transaction is removed, the split transaction
+	// expires.
+	harness.txPool.RemoveTransaction(trusted, false)
+	expired = harness.txPool.ExpireTransactions()
+	if len(expired) != 1 || *expired[0].Tx.Hash() != *split.Hash() {
+		t.Fatalf("ExpireTransactions: unexpected expired transactions "+
+			"%v", expired)
+	}
+	testPoolMembership(tc, split, false, false)
+}
+
+// TestReplacement ensures transactions spending the same outputs as a
+// transaction in the pool only replace it when the policy allows it and they
+// pay enough fees.
+func TestReplacement(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	split, err := harness.CreateSignedTx(outputs, 2)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	original, err := harness.createTxWithFee(txOutToSpendableOut(split, 0), 1000)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	child, err := harness.createTxWithFee(txOutToSpendableOut(original, 0), 1000)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	for _, tx := range []*bchutil.Tx{split, original, child} {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: failed to accept tx: %v", err)
+		}
+	}
+
+	// Double spends are rejected while replacements are not allowed.
+	replacement, err := harness.createTxWithFee(txOutToSpendableOut(split, 0),
+		5000)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(replacement, false, false, 0)
+	if code, _ := extractRejectCode(err); code != wire.RejectDuplicate {
+		t.Fatalf("ProcessTransaction: unexpected error for double "+
+			"spend: %v", err)
+	}
+
+	// A replacement which does not pay for the replaced transaction and
+	// its child along with its own relay fee is rejected.
+	harness.txPool.cfg.Policy.AllowReplacement = true
+	lowReplacement, err := harness.createTxWithFee(
+		txOutToSpendableOut(split, 0), 2000)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(lowReplacement, false, false, 0)
+	if code, _ := extractRejectCode(err); code != wire.RejectInsufficientFee {
+		t.Fatalf("ProcessTransaction: unexpected error for low fee "+
+			"replacement: %v", err)
+	}
+
+	// A transaction spending other outputs besides the same ones is not a
+	// replacement.
+	spendBoth, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(split, 0), txOutToSpendableOut(split, 1),
+	}, 1)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(spendBoth, false, false, 0)
+	if code, _ := extractRejectCode(err); code != wire.RejectDuplicate {
+		t.Fatalf("ProcessTransaction: unexpected error for double "+
+			"spend: %v", err)
+	}
+
+	// A replacement paying enough fees replaces the transaction along with
+	// its child.
+	_, err = harness.txPool.ProcessTransaction(replacement, false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: failed to accept replacement: %v",
+			err)
+	}
+	testPoolMembership(tc, original, false, false)
+	testPoolMembership(tc, child, false, false)
+	testPoolMembership(tc, replacement, false, true)
+}
+
+// TestPoolChanges ensures the changes to the pool are reported in order with
+// increasing sequence numbers and that snapshots of the pool are consistent
+// with them.
+func TestPoolChanges(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	var changes []*PoolChange
+	harness.txPool.cfg.NotifyPoolChange = func(change *PoolChange) {
+		changes = append(changes, change)
+	}
+
+	chain, err := harness.CreateTxChain(outputs[0], 2)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	for _, tx := range chain {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: failed to accept tx: %v", err)
+		}
+	}
+
+	// The child depends on the parent, so both are its ancestors.
+	if len(changes) != 2 {
+		t.Fatalf("got %d changes, want 2", len(changes))
+	}
+	for i, change := range changes {
+		if change.Sequence != uint64(i+1) || change.Removed ||
+			*change.Entry.Tx.Hash() != *chain[i].Hash() {
+
+			t.Fatalf("unexpected change %d: %+v", i, change)
+		}
+	}
+	child := changes[1].Entry
+	if len(child.Depends) != 1 || child.Depends[0] != *chain[0].Hash() ||
+		child.AncestorCount != 2 || child.AncestorSize !=
+		int64(chain[0].MsgTx().SerializeSize()+chain[1].MsgTx().SerializeSize()) {
+
+		t.Fatalf("unexpected entry of child: %+v", child)
+	}
+
+	// The snapshot has the sequence number of the last change and orders
+	// the parent before the child.
+	snapshot := harness.txPool.Snapshot()
+	if snapshot.Sequence != 2 || len(snapshot.Entries) != 2 ||
+		*snapshot.Entries[0].Tx.Hash() != *chain[0].Hash() ||
+		*snapshot.Entries[1].Tx.Hash() != *chain[1].Hash() {
+
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+
+	// Removing the parent removes the child first.
+	harness.txPool.RemoveTransaction(chain[0], true)
+	if len(changes) != 4 {
+		t.Fatalf("got %d changes, want 4", len(changes))
+	}
+	for i, change := range changes[2:] {
+		want := chain[len(chain)-1-i]
+		if change.Sequence != uint64(i+3) || !change.Removed ||
+			*change.Entry.Tx.Hash() != *want.Hash() {
+
+			t.Fatalf("unexpected change %d: %+v", i+2, change)
+		}
+	}
+	if snapshot := harness.txPool.Snapshot(); snapshot.Sequence != 4 ||
+		len(snapshot.Entries) != 0 {
+
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+}
+
+// TestCheckAcceptance ensures testing the acceptance of a package of
+// transactions reports whether each would be accepted and why not, treating
+// the accepted transactions of the package as though they were in the pool,
+// without adding any of them to the pool.
+func TestCheckAcceptance(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	parent, err := harness.createTxWithFee(outputs[0], 1000)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	child, err := harness.createTxWithFee(txOutToSpendableOut(parent, 0), 2000)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	conflict, err := harness.createTxWithFee(outputs[0], 3000)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	orphan, err := harness.createTxWithFee(txOutToSpendableOut(conflict, 0), 1000)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+
+	// The child is only accepted since it follows its parent, while the
+	// conflict spends the same output as the parent, which also makes its
+	// child an orphan.
+	results := harness.txPool.CheckAcceptance([]*bchutil.Tx{parent, child,
+		conflict, orphan})
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+	for i, fee := range []int64{1000, 2000} {
+		result := results[i]
+		if !result.Allowed || result.Err != nil || result.Fee != fee ||
+			result.ScriptMetrics.SigChecks != 1 {
+
+			t.Fatalf("unexpected result for tx #%d: %+v", i, result)
+		}
+	}
+	if code, _ := extractRejectCode(results[2].Err); results[2].Allowed ||
+		code != wire.RejectDuplicate {
+
+		t.Fatalf("unexpected result for the conflict: %+v", results[2])
+	}
+	if results[3].Allowed || len(results[3].MissingParents) != 1 ||
+		*results[3].MissingParents[0] != *conflict.Hash() {
+
+		t.Fatalf("unexpected result for the orphan: %+v", results[3])
+	}
+	for _, tx := range []*bchutil.Tx{parent, child, conflict, orphan} {
+		testPoolMembership(tc, tx, false, false)
+	}
+
+	// Once the parent is in the pool, the child is accepted on its own and
+	// the conflict is rejected as a double spend of the pool.
+	_, err = harness.txPool.ProcessTransaction(parent, false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: failed to accept tx: %v", err)
+	}
+	results = harness.txPool.CheckAcceptance([]*bchutil.Tx{child, conflict})
+	if !results[0].Allowed || results[1].Allowed {
+		t.Fatalf("unexpected results: %+v %+v", results[0], results[1])
+	}
+	testPoolMembership(tc, child, false, false)
+}
+
+// TestScriptCache ensures accepted transactions are added to the script cache
+// under the script flags of the next block.
+func TestScriptCache(t *testing.T) {
+	t.Parallel()
+
+	harness, spendableOuts, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	blockFlags := txscript.ScriptBip16 | txscript.ScriptVerifyStrictEncoding |
+		txscript.ScriptVerifyBip143SigHash | txscript.ScriptVerifySchnorr
+	harness.txPool.cfg.ScriptCache = txscript.NewScriptCache(10)
+	harness.txPool.cfg.NextBlockScriptFlags = func() (txscript.ScriptFlags, error) {
+		return blockFlags, nil
+	}
+
+	tx, err := harness.CreateSignedTx(spendableOuts[:1], 1)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	if _, err := harness.txPool.ProcessTransaction(tx, false, false, 0); err != nil {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+	if _, ok := harness.txPool.cfg.ScriptCache.Lookup(tx.Hash(), blockFlags); !ok {
+		t.Fatal("accepted transaction not added to script cache")
+	}
+}
+
+// TestAnalyzeTransactionPool ensures the pool reports the standardness rules
+// violated by the inputs and scripts of a transaction along with the inputs it
+// can't find.
+func TestAnalyzeTransactionPool(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+
+	// A standard transaction violates no rules.
+	tx, err := harness.createTxWithFee(outputs[0], 1000)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	analysis, err := harness.txPool.AnalyzeTransaction(tx)
+	if err != nil {
+		t.Fatalf("AnalyzeTransaction: %v", err)
+	}
+	if len(analysis.Violations) != 0 || len(analysis.MissingInputs) != 0 {
+		t.Fatalf("unexpected analysis of standard tx: %+v", analysis)
+	}
+
+	// An output whose script checks the same signature four times is not of
+	// a standard form and spending it exceeds the signature check density
+	// allowed for the signature script.
+	pubKey := harness.signKey.PubKey().SerializeCompressed()
+	pkScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_2DUP).AddOp(txscript.OP_CHECKSIGVERIFY).
+		AddOp(txscript.OP_2DUP).AddOp(txscript.OP_CHECKSIGVERIFY).
+		AddOp(txscript.OP_2DUP).AddOp(txscript.OP_CHECKSIGVERIFY).
+		AddOp(txscript.OP_CHECKSIG).Script()
+	if err != nil {
+		t.Fatalf("unable to create script: %v", err)
+	}
+	funding := wire.NewMsgTx(wire.TxVersion)
+	funding.AddTxIn(&wire.TxIn{PreviousOutPoint: wire.OutPoint{Index: 1}})
+	funding.AddTxOut(wire.NewTxOut(100000000, pkScript, wire.TokenData{}))
+	harness.chain.utxos.AddTxOuts(bchutil.NewTx(funding), 1)
+
+	fundingHash := funding.TxHash()
+	spend := wire.NewMsgTx(wire.TxVersion)
+	spend.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{0x01}, 0), nil))
+	spend.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&fundingHash, 0), nil))
+	spend.AddTxOut(wire.NewTxOut(99990000, harness.payScript, wire.TokenData{}))
+	sig, err := txscript.RawTxInSchnorrSignature(spend, 1, pkScript,
+		txscript.SigHashAll|txscript.SigHashForkID, harness.signKey,
+		100000000)
+	if err != nil {
+		t.Fatalf("unable to sign transaction: %v", err)
+	}
+	sigScript, err := txscript.NewScriptBuilder().AddData(sig).
+		AddData(pubKey).Script()
+	if err != nil {
+		t.Fatalf("unable to create script: %v", err)
+	}
+	spend.TxIn[1].SignatureScript = sigScript
+
+	// The first input is missing, so only the second one is checked.
+	analysis, err = harness.txPool.AnalyzeTransaction(bchutil.NewTx(spend))
+	if err != nil {
+		t.Fatalf("AnalyzeTransaction: %v", err)
+	}
+	if len(analysis.MissingInputs) != 1 ||
+		analysis.MissingInputs[0] != spend.TxIn[0].PreviousOutPoint {
+
+		t.Fatalf("unexpected missing inputs: %v", analysis.MissingInputs)
+	}
+	if len(analysis.Violations) != 1 ||
+		analysis.Violations[0].Rule != RuleInputScriptType ||
+		analysis.Violations[0].Input != 1 {
+
+		t.Fatalf("unexpected violations: %+v", analysis.Violations)
+	}
+
+	// Once every input is found, the scripts are validated as well.
+	spend.TxIn = spend.TxIn[1:]
+	sig, err = txscript.RawTxInSchnorrSignature(spend, 0, pkScript,
+		txscript.SigHashAll|txscript.SigHashForkID, harness.signKey,
+		100000000)
+	if err != nil {
+		t.Fatalf("unable to sign transaction: %v", err)
+	}
+	spend.TxIn[0].SignatureScript, err = txscript.NewScriptBuilder().
+		AddData(sig).AddData(pubKey).Script()
+	if err != nil {
+		t.Fatalf("unable to create script: %v", err)
+	}
+	analysis, err = harness.txPool.AnalyzeTransaction(bchutil.NewTx(spend))
+	if err != nil {
+		t.Fatalf("AnalyzeTransaction: %v", err)
+	}
+	if len(analysis.Violations) != 2 ||
+		analysis.Violations[0].Rule != RuleInputScriptType ||
+		analysis.Violations[1].Rule != RuleSigChecks ||
+		analysis.Violations[1].Input != 0 {
+
+		t.Fatalf("unexpected violations: %+v", analysis.Violations)
+	}
+	testPoolMembership(&testContext{t, harness}, bchutil.NewTx(spend), false,
+		false)
+}
@@ -5,6 +5,7 @@
 package mempool
 
 import (
+	"bytes"
 	"fmt"
 	"time"
 
@@ -41,6 +42,19 @@ const (
 	// for larger transactions.  This value is in Satoshi/1000 bytes.
 	DefaultMinRelayTxFee = bchutil.Amount(1000)
 
+	// DefaultDustRelayFee is the default fee rate used to determine whether
+	// the value of an output is dust.  This value is in Satoshi/1000 bytes.
+	DefaultDustRelayFee = bchutil.Amount(1000)
+
+	// DefaultMaxTxSigChecks is the default maximum number of signature
+	// checks performed by the scripts of a standard transaction, which is
+	// the consensus limit.
+	DefaultMaxTxSigChecks = blockchain.MaxTransactionSigChecks
+
+	// DefaultMempoolExpiry is the default amount of time a transaction may
+	// stay in the mempool before it expires.
+	DefaultMempoolExpiry = time.Hour * 336
+
 	// maxStandardMultiSigKeys is the maximum number of public keys allowed
 	// in a multi-signature transaction output script for it to be
 	// considered standard.
@@ -50,6 +64,157 @@ const (
 	maxStandardTxSize = 100000
 )
 
+// DataCarrierPolicy houses the standardness rules for null data (OP_RETURN)
+// outputs, which only carry data.  These rules are policy and have no bearing
+// on consensus.
+type DataCarrierPolicy struct {
+	// MaxSize is the maximum total size in bytes of the public key
+	// scripts of the null data outputs of a transaction.
+	MaxSize int
+
+	// MaxOutputs is the maximum number of null data outputs of a
+	// transaction.  A value of zero disables the limit.
+	MaxOutputs int
+
+	// Protocols restricts the data carried by null data outputs to the
+	// listed protocols when it is not empty.  A protocol is identified by
+	// a prefix of the first data push of the script, such as the lokad id
+	// of SLP or the prefix of a memo.cash action.  Outputs which only
+	// consist of an OP_RETURN are always allowed.
+	Protocols [][]byte
+}
+
+// DefaultDataCarrierPolicy is the data carrier policy which allows any number
+// of null data outputs carrying data of any protocol as long as their scripts
+// total at most txscript.MaxDataCarrierSize bytes.
+var DefaultDataCarrierPolicy = DataCarrierPolicy{
+	MaxSize: txscript.MaxDataCarrierSize,
+}
+
+// allowsProtocol returns whether the passed null data script carries data of a
+// protocol allowed by the policy.
+func (p *DataCarrierPolicy) allowsProtocol(pkScript []byte) bool {
+	if len(p.Protocols) == 0 {
+		return true
+	}
+
+	pushes, err := txscript.PushedData(pkScript)
+	if err != nil {
+		return false
+	}
+	if len(pushes) == 0 {
+		return len(pkScript) == 1
+	}
+	for _, prefix := range p.Protocols {
+		if bytes.HasPrefix(pushes[0], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// PolicyRule identifies a standardness rule of the memory pool policy.
+type PolicyRule string
+
+// These constants identify the standardness rules a transaction may violate.
+const (
+	// RuleVersion is violated by a transaction version outside of the
+	// range accepted by the policy.
+	RuleVersion PolicyRule = "version"
+
+	// RuleFinality is violated by a transaction which is not final in the
+	// next block.
+	RuleFinality PolicyRule = "finality"
+
+	// RuleTxSize is violated by a transaction larger than the maximum
+	// standard size.
+	RuleTxSize PolicyRule = "tx-size"
+
+	// RuleSigScriptSize is violated by a signature script larger than the
+	// maximum standard size.
+	RuleSigScriptSize PolicyRule = "sigscript-size"
+
+	// RuleSigScriptPushOnly is violated by a signature script which
+	// contains opcodes other than pushes.
+	RuleSigScriptPushOnly PolicyRule = "sigscript-pushonly"
+
+	// RuleTokens is violated by an output carrying tokens before they
+	// are activated.
+	RuleTokens PolicyRule = "tokens"
+
+	// RuleScriptType is violated by an output script which is not of a
+	// standard form.
+	RuleScriptType PolicyRule = "script-type"
+
+	// RuleDust is violated by an output whose value is dust.
+	RuleDust PolicyRule = "dust"
+
+	// RuleDataCarrier is violated by null data outputs which don't conform
+	// to the data carrier policy.
+	RuleDataCarrier PolicyRule = "datacarrier"
+
+	// RuleInputScriptType is violated by an input spending an output
+	// script which is not of a standard form.
+	RuleInputScriptType PolicyRule = "input-script-type"
+
+	// RuleSigChecks is violated by an input whose scripts perform more
+	// signature checks than the density allowed for the size of its
+	// signature script.
+	RuleSigChecks PolicyRule = "sigchecks"
+
+	// RuleTxSigChecks is violated by a transaction whose scripts perform
+	// more signature checks in total than the policy allows.
+	RuleTxSigChecks PolicyRule = "tx-sigchecks"
+
+	// RuleScript is violated by an input whose scripts fail to validate
+	// for any other reason.
+	RuleScript PolicyRule = "script"
+)
+
+// PolicyViolation describes a standardness rule violated by a transaction.
+type PolicyViolation struct {
+	// Rule is the violated rule.
+	Rule PolicyRule
+
+	// Input is the index of the input which violates the rule, or -1
+	// when the rule doesn't apply to a single input.
+	Input int
+
+	// Output is the index of the output which violates the rule, or -1
+	// when the rule doesn't apply to a single output.
+	Output int
+
+	// RejectCode is the code the transaction is rejected with.
+	RejectCode wire.RejectCode
+
+	// Reason describes the violation.
+	Reason string
+}
+
+// ruleError returns the error the memory pool rejects a transaction with for
+// the violation.
+func (v *PolicyViolation) ruleError() RuleError {
+	return txRuleError(v.RejectCode, v.Reason)
+}
+
+// txViolation returns a violation of a rule by the whole transaction.
+func txViolation(rule PolicyRule, code wire.RejectCode, reason string) PolicyViolation {
+	return PolicyViolation{Rule: rule, Input: -1, Output: -1,
+		RejectCode: code, Reason: reason}
+}
+
+// inputViolation returns a violation of a rule by an input.
+func inputViolation(rule PolicyRule, input int, code wire.RejectCode, reason string) PolicyViolation {
+	return PolicyViolation{Rule: rule, Input: input, Output: -1,
+		RejectCode: code, Reason: reason}
+}
+
+// outputViolation returns a violation of a rule by an output.
+func outputViolation(rule PolicyRule, output int, code wire.RejectCode, reason string) PolicyViolation {
+	return PolicyViolation{Rule: rule, Input: -1, Output: output,
+		RejectCode: code, Reason: reason}
+}
+
 // calcMinRequiredTxRelayFee returns the minimum transaction fee required for a
 // transaction with the passed serialized size to be accepted into the memory
 // pool and relayed.
@@ -87,22 +252,34 @@ func checkInputsStandard(tx *bchutil.Tx, utxoView *blockchain.UtxoViewpoint, _ t
 	// NOTE: The reference implementation also does a coinbase check here,
 	// but coinbases have already been rejected prior to calling this
 	// function so no need to recheck.
+	violations := analyzeInputsStandard(tx, utxoView)
+	if len(violations) > 0 {
+		return violations[0].ruleError()
+	}
+	return nil
+}
 
+// analyzeInputsStandard returns every violation of the standardness rules for
+// the inputs of a transaction checked by checkInputsStandard.  Inputs whose
+// referenced outputs are not in the passed view are skipped.
+func analyzeInputsStandard(tx *bchutil.Tx, utxoView *blockchain.UtxoViewpoint) []PolicyViolation {
+	var violations []PolicyViolation
 	for i, txIn := range tx.MsgTx().TxIn {
-		// It is safe to elide existence and index checks here since
-		// they have already been checked prior to calling this
-		// function.
 		entry := utxoView.LookupEntry(txIn.PreviousOutPoint)
+		if entry == nil || entry.IsSpent() {
+			continue
+		}
 		originPkScript := entry.PkScript()
 		switch txscript.GetScriptClass(originPkScript) {
 		case txscript.NonStandardTy:
 			str := fmt.Sprintf("transaction input #%d has a "+
 				"non-standard script form", i)
-			return txRuleError(wire.RejectNonstandard, str)
+			violations = append(violations, inputViolation(
+				RuleInputScriptType, i, wire.RejectNonstandard, str))
 		}
 	}
 
-	return nil
+	return violations
 }
 
 // checkPkScriptStandard performs a series of checks on a transaction output
@@ -156,11 +333,11 @@ func checkPkScriptStandard(pkScript []byte, scriptClass txscript.ScriptClass) er
 }
 
 // isDust returns whether or not the passed transaction output amount is
-// considered dust or not based on the passed minimum transaction relay fee.
-// Dust is defined in terms of the minimum transaction relay fee.  In
-// particular, if the cost to the network to spend coins is more than 1/3 of the
-// minimum transaction relay fee, it is considered dust.
-func isDust(txOut *wire.TxOut, minRelayTxFee bchutil.Amount) bool {
+// considered dust or not based on the passed dust relay fee.  In particular,
+// if the cost to the network to spend coins is more than 1/3 of the dust relay
+// fee, it is considered dust.  A dust relay fee of zero considers no output
+// dust.
+func isDust(txOut *wire.TxOut, dustRelayFee bchutil.Amount) bool {
 	// The total serialized size consists of the output and the associated
 	// input script to redeem it.  Since there is no input script
 	// to redeem it yet, use the minimum size of a typical input script.
@@ -210,18 +387,16 @@ func isDust(txOut *wire.TxOut, minRelayTxFee bchutil.Amount) bool {
 	totalSize := txOut.SerializeSize() + 41 + 107
 
 	// The output is considered dust if the cost to the network to spend the
-	// coins is more than 1/3 of the minimum free transaction relay fee.
-	// minFreeTxRelayFee is in Satoshi/KB, so multiply by 1000 to
-	// convert to bytes.
+	// coins is more than 1/3 of the dust relay fee.  dustRelayFee is in
+	// Satoshi/KB, so multiply by 1000 to convert to bytes.
 	//
 	// Using the typical values for a pay-to-pubkey-hash transaction from
-	// the breakdown above and the default minimum free transaction relay
-	// fee of 1000, this equates to values less than 546 satoshi being
-	// considered dust.
+	// the breakdown above and the default dust relay fee of 1000, this
+	// equates to values less than 546 satoshi being considered dust.
 	//
 	// The following is equivalent to (value/totalSize) * (1/3) * 1000
 	// without needing to do floating point math.
-	return txOut.Value*1000/(3*int64(totalSize)) < int64(minRelayTxFee)
+	return txOut.Value*1000/(3*int64(totalSize)) < int64(dustRelayFee)
 }
 
 // checkTransactionStandard performs a series of checks on a transaction to
@@ -230,10 +405,29 @@ func isDust(txOut *wire.TxOut, minRelayTxFee bchutil.Amount) bool {
 // "sane" transaction such as having a version in the supported range, being
 // finalized, conforming to more stringent size constraints, having scripts
 // of recognized forms, and not containing "dust" outputs (those that are
-// so small it costs more to process them than they are worth).
+// so small it costs more to process them than they are worth).  Null data
+// outputs must conform to the passed data carrier policy.
 func checkTransactionStandard(tx *bchutil.Tx, height int32,
-	medianTimePast time.Time, minRelayTxFee bchutil.Amount,
-	maxTxVersion int32, upgrade9Active bool) error {
+	medianTimePast time.Time, dustRelayFee bchutil.Amount,
+	maxTxVersion int32, upgrade9Active bool,
+	dataCarrier *DataCarrierPolicy) error {
+
+	violations := analyzeTransactionStandard(tx, height, medianTimePast,
+		dustRelayFee, maxTxVersion, upgrade9Active, dataCarrier)
+	if len(violations) > 0 {
+		return violations[0].ruleError()
+	}
+	return nil
+}
+
+// analyzeTransactionStandard returns every violation of the standardness rules
+// checked by checkTransactionStandard in the order they are checked.
+func analyzeTransactionStandard(tx *bchutil.Tx, height int32,
+	medianTimePast time.Time, dustRelayFee bchutil.Amount,
+	maxTxVersion int32, upgrade9Active bool,
+	dataCarrier *DataCarrierPolicy) []PolicyViolation {
+
+	var violations []PolicyViolation
 
 	// The transaction must be a currently supported version.
 	msgTx := tx.MsgTx()
@@ -241,14 +435,15 @@ func checkTransactionStandard(tx *bchutil.Tx, height int32,
 		str := fmt.Sprintf("transaction version %d is not in the "+
 			"valid range of %d-%d", msgTx.Version, 1,
 			maxTxVersion)
-		return txRuleError(wire.RejectNonstandard, str)
+		violations = append(violations, txViolation(RuleVersion,
+			wire.RejectNonstandard, str))
 	}
 
 	// The transaction must be finalized to be standard and therefore
 	// considered for inclusion in a block.
 	if !blockchain.IsFinalizedTransaction(tx, height, medianTimePast) {
-		return txRuleError(wire.RejectNonstandard,
-			"transaction is not finalized")
+		violations = append(violations, txViolation(RuleFinality,
+			wire.RejectNonstandard, "transaction is not finalized"))
 	}
 
 	// Since extremely large transactions with a lot of inputs can cost
@@ -259,7 +454,8 @@ func checkTransactionStandard(tx *bchutil.Tx, height int32,
 	if txSize > maxStandardTxSize {
 		str := fmt.Sprintf("size of transaction %v is larger than max "+
 			"allowed size of %v", txSize, maxStandardTxSize)
-		return txRuleError(wire.RejectNonstandard, str)
+		violations = append(violations, txViolation(RuleTxSize,
+			wire.RejectNonstandard, str))
 	}
 
 	for i, txIn := range msgTx.TxIn {
@@ -272,7 +468,8 @@ func checkTransactionStandard(tx *bchutil.Tx, height int32,
 				"script size of %d bytes is large than max "+
 				"allowed size of %d bytes", i, sigScriptLen,
 				maxStandardSigScriptSize)
-			return txRuleError(wire.RejectNonstandard, str)
+			violations = append(violations, inputViolation(
+				RuleSigScriptSize, i, wire.RejectNonstandard, str))
 		}
 
 		// Each transaction input signature script must only contain
@@ -280,22 +477,34 @@ func checkTransactionStandard(tx *bchutil.Tx, height int32,
 		if !txscript.IsPushOnlyScript(txIn.SignatureScript) {
 			str := fmt.Sprintf("transaction input %d: signature "+
 				"script is not push only", i)
-			return txRuleError(wire.RejectNonstandard, str)
+			violations = append(violations, inputViolation(
+				RuleSigScriptPushOnly, i, wire.RejectNonstandard,
+				str))
 		}
 	}
 
 	// None of the output public key scripts can be a non-standard script or
 	// be "dust" (except when the script is a null data script).
 	dataCarrierSize := 0
+	dataCarrierOutputs := 0
 	for i, txOut := range msgTx.TxOut {
 
 		if !upgrade9Active && !txOut.TokenData.IsEmpty() {
 			rejectCode := wire.RejectNonstandard
 			str := "txn-tokens-before-activation"
-			return txRuleError(rejectCode, str)
+			violations = append(violations, outputViolation(
+				RuleTokens, i, rejectCode, str))
 		}
 
+		// Null data scripts carrying more than the default amount of
+		// data are classified as non-standard, however the amount of
+		// data is limited by the data carrier policy below instead.
 		scriptClass := txscript.GetScriptClass(txOut.PkScript)
+		if scriptClass == txscript.NonStandardTy &&
+			txscript.IsNullDataScript(txOut.PkScript) {
+
+			scriptClass = txscript.NullDataTy
+		}
 		err := checkPkScriptStandard(txOut.PkScript, scriptClass)
 		if err != nil {
 			// Attempt to extract a reject code from the error so
@@ -306,33 +515,146 @@ func checkTransactionStandard(tx *bchutil.Tx, height int32,
 				rejectCode = rejCode
 			}
 			str := fmt.Sprintf("transaction output %d: %v", i, err)
-			return txRuleError(rejectCode, str)
+			violations = append(violations, outputViolation(
+				RuleScriptType, i, rejectCode, str))
+			continue
 		}
 
 		// Accumulate the script size of outputs which only carry data.  For
 		// all other script types, ensure the output value is not
 		// "dust".
 		if scriptClass == txscript.NullDataTy {
+			if !dataCarrier.allowsProtocol(txOut.PkScript) {
+				str := fmt.Sprintf("transaction output %d: "+
+					"nulldata protocol is not allowed", i)
+				violations = append(violations, outputViolation(
+					RuleDataCarrier, i, wire.RejectNonstandard,
+					str))
+			}
 			dataCarrierSize += len(txOut.PkScript)
-		} else if txscript.IsUnspendable(txOut.PkScript) || isDust(txOut, minRelayTxFee) {
+			dataCarrierOutputs++
+		} else if txscript.IsUnspendable(txOut.PkScript) || isDust(txOut, dustRelayFee) {
 			str := fmt.Sprintf("transaction output %d: payment "+
 				"of %d is dust", i, txOut.Value)
-			return txRuleError(wire.RejectDust, str)
+			violations = append(violations, outputViolation(RuleDust,
+				i, wire.RejectDust, str))
 		}
 	}
 
-	// A standard transaction cannot have null data exceeding txscript.MaxDataCarrierSize
-	if dataCarrierSize > txscript.MaxDataCarrierSize {
-		str := fmt.Sprintf("transaction nulldata exceeds %d bytes", txscript.MaxDataCarrierSize)
-		return txRuleError(wire.RejectNonstandard, str)
+	// A standard transaction cannot have null data exceeding the maximum
+	// size of the data carrier policy.
+	if dataCarrierSize > dataCarrier.MaxSize {
+		str := fmt.Sprintf("transaction nulldata exceeds %d bytes",
+			dataCarrier.MaxSize)
+		violations = append(violations, txViolation(RuleDataCarrier,
+			wire.RejectNonstandard, str))
 	}
 
-	return nil
+	// Nor can it have more null data outputs than the policy allows.
+	if dataCarrier.MaxOutputs > 0 && dataCarrierOutputs > dataCarrier.MaxOutputs {
+		str := fmt.Sprintf("transaction has %d nulldata outputs which "+
+			"exceeds the maximum of %d", dataCarrierOutputs,
+			dataCarrier.MaxOutputs)
+		violations = append(violations, txViolation(RuleDataCarrier,
+			wire.RejectNonstandard, str))
+	}
+
+	return violations
+}
+
+// AnalyzeTransaction checks the passed transaction against every standardness
+// rule of the policy and returns all of the rules it violates instead of only
+// the first, in the order the memory pool checks them.  This allows callers to
+// find out why a transaction would be rejected as non-standard before
+// submitting it.  The transaction is checked as though it were included in a
+// block at the passed height with the passed median time past.
+//
+// The inputs are checked against the outputs they spend in the passed view,
+// which may be nil.  The scripts, including the signature check density of
+// each input and the signature checks of the whole transaction, are only
+// validated with the passed script flags when the view has all of the spent
+// outputs.  Note that the policy is applied regardless of
+// whether it accepts non-standard transactions.
+func AnalyzeTransaction(tx *bchutil.Tx, utxoView *blockchain.UtxoViewpoint,
+	height int32, medianTimePast time.Time, scriptFlags txscript.ScriptFlags,
+	upgrade9Active bool, policy *Policy) []PolicyViolation {
+
+	violations := analyzeTransactionStandard(tx, height, medianTimePast,
+		policy.DustRelayFee, policy.MaxTxVersion, upgrade9Active,
+		&policy.DataCarrier)
+	if utxoView == nil {
+		return violations
+	}
+
+	violations = append(violations, analyzeInputsStandard(tx, utxoView)...)
+	return append(violations, analyzeInputScripts(tx, utxoView, scriptFlags,
+		policy.MaxTxSigChecks)...)
+}
+
+// analyzeInputScripts validates the scripts of each input of the passed
+// transaction and returns a violation for each input which fails, followed by
+// a violation of the transaction when its scripts perform more than the passed
+// maximum number of signature checks.  A maximum of zero disables the limit.
+// No inputs are validated unless the passed view has all of the spent outputs.
+func analyzeInputScripts(tx *bchutil.Tx, utxoView *blockchain.UtxoViewpoint,
+	scriptFlags txscript.ScriptFlags, maxTxSigChecks int) []PolicyViolation {
+
+	msgTx := tx.MsgTx()
+	utxoCache := txscript.NewUtxoCache()
+	for i, txIn := range msgTx.TxIn {
+		entry := utxoView.LookupEntry(txIn.PreviousOutPoint)
+		if entry == nil || entry.IsSpent() {
+			return nil
+		}
+		utxoCache.AddEntry(i, *wire.NewTxOut(entry.Amount(),
+			entry.PkScript(), entry.TokenData()))
+	}
+	sigHashes := txscript.NewTxSigHashes(msgTx)
+	if scriptFlags.HasFlag(txscript.ScriptAllowCashTokens) {
+		sigHashes.AddTxSigHashUtxoFromUtxoCache(msgTx, utxoCache)
+	}
+
+	var violations []PolicyViolation
+	txSigChecks := 0
+	for i := range msgTx.TxIn {
+		utxo, _ := utxoCache.GetEntry(i)
+		vm, err := txscript.NewEngine(utxo.PkScript, msgTx, i,
+			scriptFlags, nil, sigHashes, utxoCache, utxo.Value)
+		if err == nil {
+			err = vm.Execute()
+			if err == nil {
+				txSigChecks += vm.SigChecks()
+			}
+			vm.Release()
+		}
+		if err == nil {
+			continue
+		}
+
+		rule := RuleScript
+		if txscript.IsErrorCode(err, txscript.ErrInputSigChecks) {
+			rule = RuleSigChecks
+		}
+		str := fmt.Sprintf("transaction input %d: %v", i, err)
+		violations = append(violations, inputViolation(rule, i,
+			wire.RejectInvalid, str))
+	}
+
+	if maxTxSigChecks > 0 && txSigChecks > maxTxSigChecks {
+		str := fmt.Sprintf("transaction performs %d signature checks "+
+			"which exceeds the maximum of %d", txSigChecks,
+			maxTxSigChecks)
+		violations = append(violations, txViolation(RuleTxSigChecks,
+			wire.RejectNonstandard, str))
+	}
+
+	return violations
 }
 
-func CheckTransactionStandard(tx *bchutil.Tx, height int32, medianTimePast time.Time, minRelayTxFee bchutil.Amount,
+func CheckTransactionStandard(tx *bchutil.Tx, height int32, medianTimePast time.Time, dustRelayFee bchutil.Amount,
 	maxTxVersion int32, upgrade9Active bool) error {
-	return checkTransactionStandard(tx, height, medianTimePast, minRelayTxFee, maxTxVersion, upgrade9Active)
+	return checkTransactionStandard(tx, height, medianTimePast, dustRelayFee, maxTxVersion, upgrade9Active,
+		&DefaultDataCarrierPolicy)
 }
 
 func CheckInputsStandard(tx *bchutil.Tx, utxoView *blockchain.UtxoViewpoint, scriptFlags txscript.ScriptFlags) error {
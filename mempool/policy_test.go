@@ -645,7 +645,8 @@ func TestCheckTransactionStandard(t *testing.T) {
 	for _, test := range tests {
 		// Ensure standardness is as expected.
 		err := checkTransactionStandard(bchutil.NewTx(&test.tx),
-			test.height, pastMedianTime, DefaultMinRelayTxFee, 1, false)
+			test.height, pastMedianTime, DefaultMinRelayTxFee, 1, false,
+			&DefaultDataCarrierPolicy)
 		if err == nil && test.isStandard {
 			// Test passes since function returned standard for a
 			// transaction which is intended to be standard.
@@ -685,3 +686,177 @@ func TestCheckTransactionStandard(t *testing.T) {
 		}
 	}
 }
+
+// TestDataCarrierPolicy ensures the null data outputs of transactions are
+// checked against the configured data carrier policy.
+func TestDataCarrierPolicy(t *testing.T) {
+	prevOutHash, err := chainhash.NewHashFromStr("01")
+	if err != nil {
+		t.Fatalf("NewShaHashFromStr: unexpected error: %v", err)
+	}
+	txIn := wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: *prevOutHash, Index: 1},
+		SignatureScript:  bytes.Repeat([]byte{0x00}, 65),
+		Sequence:         wire.MaxTxInSequenceNum,
+	}
+	nullData := func(data ...[]byte) *wire.TxOut {
+		builder := txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN)
+		for _, d := range data {
+			builder.AddFullData(d)
+		}
+		script, err := builder.Script()
+		if err != nil {
+			t.Fatalf("unable to build script: %v", err)
+		}
+		return &wire.TxOut{PkScript: script}
+	}
+	slp := []byte("SLP\x00")
+	memo := []byte{0x6d, 0x02}
+
+	tests := []struct {
+		name       string
+		policy     DataCarrierPolicy
+		txOuts     []*wire.TxOut
+		isStandard bool
+	}{
+		{
+			name:       "default policy",
+			policy:     DefaultDataCarrierPolicy,
+			txOuts:     []*wire.TxOut{nullData(slp), nullData(memo)},
+			isStandard: true,
+		},
+		{
+			name:       "larger aggregate size",
+			policy:     DataCarrierPolicy{MaxSize: 1000},
+			txOuts:     []*wire.TxOut{nullData(make([]byte, 300)), nullData(make([]byte, 300))},
+			isStandard: true,
+		},
+		{
+			name:       "exceeds larger aggregate size",
+			policy:     DataCarrierPolicy{MaxSize: 500},
+			txOuts:     []*wire.TxOut{nullData(make([]byte, 300)), nullData(make([]byte, 300))},
+			isStandard: false,
+		},
+		{
+			name:       "no data carriers allowed",
+			policy:     DataCarrierPolicy{MaxSize: 0},
+			txOuts:     []*wire.TxOut{nullData()},
+			isStandard: false,
+		},
+		{
+			name:       "too many outputs",
+			policy:     DataCarrierPolicy{MaxSize: 223, MaxOutputs: 1},
+			txOuts:     []*wire.TxOut{nullData(slp), nullData(memo)},
+			isStandard: false,
+		},
+		{
+			name:       "allowed protocols",
+			policy:     DataCarrierPolicy{MaxSize: 223, Protocols: [][]byte{slp, memo[:1]}},
+			txOuts:     []*wire.TxOut{nullData(slp, []byte{0x01}), nullData(memo), nullData()},
+			isStandard: true,
+		},
+		{
+			name:       "protocol not allowed",
+			policy:     DataCarrierPolicy{MaxSize: 223, Protocols: [][]byte{slp}},
+			txOuts:     []*wire.TxOut{nullData(memo)},
+			isStandard: false,
+		},
+	}
+
+	for _, test := range tests {
+		tx := wire.MsgTx{
+			Version: 1,
+			TxIn:    []*wire.TxIn{&txIn},
+			TxOut:   test.txOuts,
+		}
+		err := checkTransactionStandard(bchutil.NewTx(&tx), 300000,
+			time.Now(), DefaultMinRelayTxFee, 1, false, &test.policy)
+		if (err == nil) != test.isStandard {
+			t.Errorf("%s: unexpected result -- got %v, want standard %v",
+				test.name, err, test.isStandard)
+		}
+	}
+}
+
+// TestAnalyzeTransaction ensures every standardness rule a transaction violates
+// is reported instead of only the first.
+func TestAnalyzeTransaction(t *testing.T) {
+	prevOutHash, err := chainhash.NewHashFromStr("01")
+	if err != nil {
+		t.Fatalf("NewShaHashFromStr: unexpected error: %v", err)
+	}
+	addrHash := [20]byte{0x01}
+	addr, err := bchutil.NewAddressPubKeyHash(addrHash[:],
+		&chaincfg.TestNet3Params)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash: unexpected error: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+	nullData, err := txscript.NullDataScript(make([]byte, 200))
+	if err != nil {
+		t.Fatalf("NullDataScript: unexpected error: %v", err)
+	}
+
+	// The transaction has an unsupported version, an input which is not
+	// push only, a dust output, an output of a non-standard form and too
+	// much null data.
+	tx := wire.MsgTx{
+		Version: 3,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Hash: *prevOutHash},
+			SignatureScript:  []byte{txscript.OP_1, txscript.OP_DUP},
+			Sequence:         wire.MaxTxInSequenceNum,
+		}},
+		TxOut: []*wire.TxOut{
+			{Value: 100000000, PkScript: pkScript},
+			{Value: 1, PkScript: pkScript},
+			{Value: 100000000, PkScript: []byte{txscript.OP_TRUE}},
+			{PkScript: nullData},
+			{PkScript: nullData},
+		},
+	}
+	policy := Policy{
+		MaxTxVersion:  2,
+		DataCarrier:   DefaultDataCarrierPolicy,
+		MinRelayTxFee: DefaultMinRelayTxFee,
+		DustRelayFee:  DefaultDustRelayFee,
+	}
+	violations := AnalyzeTransaction(bchutil.NewTx(&tx), nil, 300000,
+		time.Now(), txscript.StandardVerifyFlags, true, &policy)
+
+	want := []struct {
+		rule   PolicyRule
+		input  int
+		output int
+		code   wire.RejectCode
+	}{
+		{RuleVersion, -1, -1, wire.RejectNonstandard},
+		{RuleSigScriptPushOnly, 0, -1, wire.RejectNonstandard},
+		{RuleDust, -1, 1, wire.RejectDust},
+		{RuleScriptType, -1, 2, wire.RejectNonstandard},
+		{RuleDataCarrier, -1, -1, wire.RejectNonstandard},
+	}
+	if len(violations) != len(want) {
+		t.Fatalf("got %d violations, want %d: %+v", len(violations),
+			len(want), violations)
+	}
+	for i, v := range violations {
+		if v.Rule != want[i].rule || v.Input != want[i].input ||
+			v.Output != want[i].output || v.RejectCode != want[i].code ||
+			v.Reason == "" {
+
+			t.Errorf("violation #%d: got %+v, want %+v", i, v, want[i])
+		}
+	}
+
+	// The first violation is the error the transaction is rejected with.
+	err = checkTransactionStandard(bchutil.NewTx(&tx), 300000, time.Now(),
+		policy.MinRelayTxFee, policy.MaxTxVersion, true, &policy.DataCarrier)
+	if err == nil || err.Error() != violations[0].Reason {
+		t.Fatalf("checkTransactionStandard: got %v, want %v", err,
+			violations[0].Reason)
+	}
+}
@@ -0,0 +1,148 @@
+package mempool
+
+import (
+	"fmt"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/wire"
+)
+
+const (
+	// maxWeakBlocks is the maximum number of weak blocks kept by the pool.
+	// The oldest weak block is evicted when a new weak block exceeds the
+	// limit.
+	maxWeakBlocks = 64
+
+	// maxWeakTxsSize is the maximum total serialized size of the distinct
+	// transactions of the weak blocks kept by the pool.  The oldest weak
+	// blocks are evicted while a new weak block exceeds the limit.
+	maxWeakTxsSize = 64 * 1000 * 1000
+)
+
+// weakTx is a transaction of a weak block along with the number of weak blocks
+// in the pool which include it.
+type weakTx struct {
+	tx   *wire.MsgTx
+	refs int
+}
+
+// AddWeakBlock adds the passed weak block to the pool.  The transactions of the
+// weak blocks in the pool are staged for the reconstruction of compact blocks
+// since they are likely to be included in the next block, even when they were
+// never accepted to the pool.  It returns false when the weak block is already
+// in the pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) AddWeakBlock(block *wire.MsgBlock) bool {
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	hash := block.BlockHash()
+	if _, ok := mp.weakBlocks[hash]; ok {
+		return false
+	}
+	if len(mp.weakBlockOrder) >= maxWeakBlocks {
+		mp.removeWeakBlock(mp.weakBlockOrder[0])
+	}
+	mp.weakBlocks[hash] = block
+	mp.weakBlockOrder = append(mp.weakBlockOrder, hash)
+	for _, tx := range block.Transactions[1:] {
+		txHash := tx.TxHash()
+		wtx, ok := mp.weakTxs[txHash]
+		if !ok {
+			wtx = &weakTx{tx: tx}
+			mp.weakTxs[txHash] = wtx
+			mp.weakTxsSize += tx.SerializeSize()
+		}
+		wtx.refs++
+	}
+
+	// The new weak block is kept even when it exceeds the size limit on its
+	// own.  Its size is bounded by the excessive block size.
+	for mp.weakTxsSize > maxWeakTxsSize && len(mp.weakBlockOrder) > 1 {
+		mp.removeWeakBlock(mp.weakBlockOrder[0])
+	}
+	return true
+}
+
+// removeWeakBlock removes the weak block with the passed hash from the pool
+// along with the transactions no other weak block includes.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) removeWeakBlock(hash chainhash.Hash) {
+	block, ok := mp.weakBlocks[hash]
+	if !ok {
+		return
+	}
+	delete(mp.weakBlocks, hash)
+	for i, h := range mp.weakBlockOrder {
+		if h == hash {
+			mp.weakBlockOrder = append(mp.weakBlockOrder[:i],
+				mp.weakBlockOrder[i+1:]...)
+			break
+		}
+	}
+	for _, tx := range block.Transactions[1:] {
+		txHash := tx.TxHash()
+		wtx, ok := mp.weakTxs[txHash]
+		if !ok {
+			continue
+		}
+		wtx.refs--
+		if wtx.refs == 0 {
+			delete(mp.weakTxs, txHash)
+			mp.weakTxsSize -= tx.SerializeSize()
+		}
+	}
+}
+
+// PruneWeakBlocks removes all weak blocks which do not extend the passed block
+// from the pool.  It is called whenever the tip of the main chain changes since
+// the weak blocks built on the previous tip are stale.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) PruneWeakBlocks(tip *chainhash.Hash) {
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	for hash, block := range mp.weakBlocks {
+		if block.Header.PrevBlock != *tip {
+			mp.removeWeakBlock(hash)
+		}
+	}
+}
+
+// HaveWeakBlock returns whether the weak block with the passed hash is in the
+// pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) HaveWeakBlock(hash *chainhash.Hash) bool {
+	mp.mtx.RLock()
+	_, ok := mp.weakBlocks[*hash]
+	mp.mtx.RUnlock()
+	return ok
+}
+
+// FetchWeakBlock returns the weak block with the passed hash from the pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) FetchWeakBlock(hash *chainhash.Hash) (*wire.MsgBlock, error) {
+	mp.mtx.RLock()
+	block, ok := mp.weakBlocks[*hash]
+	mp.mtx.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("weak block %v is not in the pool", hash)
+	}
+	return block, nil
+}
+
+// WeakBlockCount returns the number of weak blocks in the pool along with the
+// number of distinct transactions they include.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) WeakBlockCount() (int, int) {
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	return len(mp.weakBlocks), len(mp.weakTxs)
+}
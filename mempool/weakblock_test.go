@@ -0,0 +1,160 @@
+package mempool
+
+import (
+	"testing"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/wire"
+)
+
+// newWeakTestBlock returns a block on the passed previous block with a coinbase
+// and the passed transactions.
+func newWeakTestBlock(prevBlock chainhash.Hash, nonce uint32, txns ...*wire.MsgTx) *wire.MsgBlock {
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		SignatureScript: []byte{0x51, 0x51},
+	})
+	coinbase.AddTxOut(wire.NewTxOut(5000000000, []byte{0x51},
+		wire.TokenData{}))
+	block := wire.NewMsgBlock(&wire.BlockHeader{
+		PrevBlock: prevBlock,
+		Nonce:     nonce,
+	})
+	block.AddTransaction(coinbase)
+	for _, tx := range txns {
+		block.AddTransaction(tx)
+	}
+	return block
+}
+
+// newWeakTestTx returns a transaction spending the output with the passed
+// index of an unknown transaction.
+func newWeakTestTx(index uint32) *wire.MsgTx {
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{0x01}, index),
+	})
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}, wire.TokenData{}))
+	return tx
+}
+
+// TestWeakBlocks ensures weak blocks are tracked and pruned by the pool and
+// their transactions are used to reconstruct compact blocks.
+func TestWeakBlocks(t *testing.T) {
+	mp := New(&Config{})
+	tip := chainhash.Hash{0x02}
+	tx1, tx2 := newWeakTestTx(0), newWeakTestTx(1)
+
+	weak1 := newWeakTestBlock(tip, 1, tx1)
+	weak2 := newWeakTestBlock(tip, 2, tx1, tx2)
+	if !mp.AddWeakBlock(weak1) || !mp.AddWeakBlock(weak2) {
+		t.Fatal("AddWeakBlock: weak block not added")
+	}
+	if mp.AddWeakBlock(weak1) {
+		t.Fatal("AddWeakBlock: duplicate weak block added")
+	}
+	if blocks, txns := mp.WeakBlockCount(); blocks != 2 || txns != 2 {
+		t.Fatalf("WeakBlockCount: got %d blocks and %d transactions, "+
+			"want 2 and 2", blocks, txns)
+	}
+	hash := weak2.BlockHash()
+	if !mp.HaveWeakBlock(&hash) {
+		t.Fatal("HaveWeakBlock: weak block not found")
+	}
+	if _, err := mp.FetchWeakBlock(&hash); err != nil {
+		t.Fatalf("FetchWeakBlock: unexpected error: %v", err)
+	}
+
+	// A compact block of a block including the transactions of the weak
+	// blocks is reconstructed without round trip although none of them is
+	// in the pool.
+	block := newWeakTestBlock(tip, 3, tx2, tx1)
+	knownTxs := map[chainhash.Hash]bool{
+		tx1.TxHash(): true,
+		tx2.TxHash(): true,
+	}
+	cmpctBlock, err := wire.NewMsgCmpctBlockFromBlock(block, knownTxs)
+	if err != nil {
+		t.Fatalf("NewMsgCmpctBlockFromBlock: unexpected error: %v", err)
+	}
+	decoded, err := mp.DecodeCompressedBlock(cmpctBlock)
+	if err != nil {
+		t.Fatalf("DecodeCompressedBlock: unexpected error: %v", err)
+	}
+	for i, tx := range decoded.Transactions {
+		if tx == nil || tx.TxHash() != block.Transactions[i].TxHash() {
+			t.Fatalf("DecodeCompressedBlock: transaction %d not "+
+				"reconstructed", i)
+		}
+	}
+
+	// Weak blocks on other blocks than the new tip are pruned along with
+	// the transactions only they include.
+	weak3 := newWeakTestBlock(chainhash.Hash{0x03}, 4, tx2)
+	mp.AddWeakBlock(weak3)
+	mp.PruneWeakBlocks(&chainhash.Hash{0x03})
+	if blocks, txns := mp.WeakBlockCount(); blocks != 1 || txns != 1 {
+		t.Fatalf("WeakBlockCount: got %d blocks and %d transactions, "+
+			"want 1 and 1", blocks, txns)
+	}
+	if mp.HaveWeakBlock(&hash) {
+		t.Fatal("HaveWeakBlock: stale weak block not pruned")
+	}
+
+	// The oldest weak block is evicted once the limit is reached.
+	for i := 0; i < maxWeakBlocks; i++ {
+		mp.AddWeakBlock(newWeakTestBlock(chainhash.Hash{0x03},
+			uint32(i+5)))
+	}
+	hash = weak3.BlockHash()
+	if mp.HaveWeakBlock(&hash) {
+		t.Fatal("AddWeakBlock: oldest weak block not evicted")
+	}
+	if blocks, txns := mp.WeakBlockCount(); blocks != maxWeakBlocks ||
+		txns != 0 {
+
+		t.Fatalf("WeakBlockCount: got %d blocks and %d transactions, "+
+			"want %d and 0", blocks, txns, maxWeakBlocks)
+	}
+
+	// The oldest weak blocks are evicted once the size of their
+	// transactions exceeds the limit.  The transactions share their
+	// script to keep the memory usage of the test low.
+	script := make([]byte, maxWeakTxsSize/3)
+	var large []*wire.MsgBlock
+	for i := 0; i < 4; i++ {
+		tx := newWeakTestTx(uint32(i + 2))
+		tx.TxOut[0].PkScript = script
+		large = append(large, newWeakTestBlock(chainhash.Hash{0x03},
+			uint32(i+maxWeakBlocks+5), tx))
+	}
+	for _, block := range large[:2] {
+		mp.AddWeakBlock(block)
+	}
+	if blocks, txns := mp.WeakBlockCount(); blocks != maxWeakBlocks ||
+		txns != 2 {
+
+		t.Fatalf("WeakBlockCount: got %d blocks and %d transactions, "+
+			"want %d and 2", blocks, txns, maxWeakBlocks)
+	}
+	for _, block := range large[2:] {
+		mp.AddWeakBlock(block)
+	}
+	for i, block := range large {
+		hash := block.BlockHash()
+		if want := i >= 2; mp.HaveWeakBlock(&hash) != want {
+			t.Fatalf("HaveWeakBlock: large weak block %d: got %v, "+
+				"want %v", i, !want, want)
+		}
+	}
+	if blocks, txns := mp.WeakBlockCount(); blocks != 2 || txns != 2 {
+		t.Fatalf("WeakBlockCount: got %d blocks and %d transactions, "+
+			"want 2 and 2", blocks, txns)
+	}
+	if mp.weakTxsSize > maxWeakTxsSize {
+		t.Fatalf("weak transactions size %d exceeds the limit %d",
+			mp.weakTxsSize, maxWeakTxsSize)
+	}
+}
@@ -0,0 +1,123 @@
+package mining
+
+import (
+	"github.com/gcash/bchd/chaincfg/chainhash"
+)
+
+// txGraph tracks the ancestor and descendant relationships between the
+// transactions in a transaction source that are being considered for
+// inclusion in a block template.  It allows the block template generator to
+// evaluate transactions as packages consisting of a transaction along with all
+// of its ancestors which have not been included in the block yet, so that a
+// child paying a high fee can pull in its low-fee parents (child-pays-for-
+// parent).
+type txGraph struct {
+	items map[chainhash.Hash]*txPrioItem
+}
+
+// newTxGraph returns a new empty transaction graph that reserves the passed
+// amount of space for the items.
+func newTxGraph(reserve int) *txGraph {
+	return &txGraph{
+		items: make(map[chainhash.Hash]*txPrioItem, reserve),
+	}
+}
+
+// addItem adds the passed item to the graph.  The relationships to other items
+// are not established until link is called.
+func (g *txGraph) addItem(item *txPrioItem) {
+	g.items[*item.tx.Hash()] = item
+}
+
+// link connects every item in the graph to the items it spends from and the
+// items that spend from it.  Any parent which is referenced by an item but is
+// not part of the graph, for example because it was skipped as non-final, is
+// recorded with a nil entry so that the item and all of its descendants are
+// treated as unavailable.
+func (g *txGraph) link() {
+	for _, item := range g.items {
+		for parentHash := range item.parents {
+			parent := g.items[parentHash]
+			item.parents[parentHash] = parent
+			if parent == nil {
+				continue
+			}
+			if parent.children == nil {
+				parent.children = make(map[chainhash.Hash]*txPrioItem)
+			}
+			parent.children[*item.tx.Hash()] = item
+		}
+	}
+}
+
+// ancestorPackage returns the passed item along with all of its ancestors
+// which have not yet been included in the block, ordered such that every
+// transaction comes after all of the transactions it depends on.  The passed
+// item is always the final entry.  It returns false when any of the ancestors
+// is unavailable or has been rejected, in which case the item can never be
+// included in the block.
+func ancestorPackage(item *txPrioItem) ([]*txPrioItem, bool) {
+	var pkg []*txPrioItem
+	visited := make(map[*txPrioItem]struct{})
+
+	var visit func(*txPrioItem) bool
+	visit = func(p *txPrioItem) bool {
+		if _, ok := visited[p]; ok {
+			return true
+		}
+		visited[p] = struct{}{}
+		if p.rejected {
+			return false
+		}
+		for _, parent := range p.parents {
+			if parent == nil {
+				return false
+			}
+			if parent.included {
+				continue
+			}
+			if !visit(parent) {
+				return false
+			}
+		}
+		pkg = append(pkg, p)
+		return true
+	}
+	if !visit(item) {
+		return nil, false
+	}
+	return pkg, true
+}
+
+// packageFees returns the total fee and total serialized size of all of the
+// items in the passed package.
+func packageFees(pkg []*txPrioItem) (int64, int64) {
+	var fee, size int64
+	for _, item := range pkg {
+		fee += item.fee
+		size += item.size
+	}
+	return fee, size
+}
+
+// updatePackageFee recalculates the ancestor package fee rate of the passed
+// item from its unincluded ancestors.  It returns false when the item can no
+// longer be included in the block.
+func updatePackageFee(item *txPrioItem) bool {
+	pkg, ok := ancestorPackage(item)
+	if !ok {
+		return false
+	}
+	fee, size := packageFees(pkg)
+	item.pkgFeePerKB = calcFeePerKB(fee, size)
+	return true
+}
+
+// calcFeePerKB returns the fee rate in Satoshi per 1000 bytes for the passed
+// fee and serialized size.
+func calcFeePerKB(fee, size int64) int64 {
+	if size <= 0 {
+		return 0
+	}
+	return fee * 1000 / size
+}
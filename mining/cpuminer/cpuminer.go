@@ -66,6 +66,11 @@ type Config struct {
 	// rules and handling as any other block coming from the network.
 	ProcessBlock func(*bchutil.Block, blockchain.BehaviorFlags) (bool, error)
 
+	// ProcessWeakBlock defines the function to call with any weak blocks
+	// found while solving a block.  Weak blocks are not announced when it
+	// is nil.
+	ProcessWeakBlock func(*wire.MsgBlock)
+
 	// ConnectedCount defines the function to use to obtain how many other
 	// peers the server is connected to.  This is used by the automatic
 	// persistent mining routine to determine whether or it should attempt
@@ -204,7 +209,7 @@ func (m *CPUMiner) submitBlock(block *bchutil.Block) bool {
 // This function will return early with false when conditions that trigger a
 // stale block such as a new block showing up or periodically when there are
 // new transactions and enough time has elapsed without finding a solution.
-func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight int32,
+func (m *CPUMiner) solveBlock(template *mining.BlockTemplate,
 	ticker *time.Ticker, quit chan struct{}) bool {
 
 	// Choose a random extra nonce offset for this block template and
@@ -217,8 +222,10 @@ func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight int32,
 	}
 
 	// Create some convenience variables.
+	msgBlock := template.Block
 	header := &msgBlock.Header
 	targetDifficulty := blockchain.CompactToBig(header.Bits)
+	weakTarget := blockchain.CalcWeakBlockTarget(header.Bits)
 
 	// Initial state.
 	lastGenerated := time.Now()
@@ -232,7 +239,7 @@ func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight int32,
 		// Update the extra nonce in the block template with the
 		// new value by regenerating the coinbase script and
 		// setting the merkle root to the new value.
-		m.g.UpdateExtraNonce(msgBlock, blockHeight, extraNonce+enOffset)
+		m.g.UpdateExtraNonce(template, extraNonce+enOffset)
 
 		// Search through the entire nonce range for a solution while
 		// periodically checking for early quit and stale block
@@ -279,10 +286,23 @@ func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight int32,
 
 			// The block is solved when the new block hash is less
 			// than the target difficulty.  Yay!
-			if blockchain.HashToBig(&hash).Cmp(targetDifficulty) <= 0 {
+			hashNum := blockchain.HashToBig(&hash)
+			if hashNum.Cmp(targetDifficulty) <= 0 {
 				m.updateHashes <- hashesCompleted
 				return true
 			}
+
+			// Announce weak blocks with a copy of the coinbase
+			// since it is modified while solving the block.
+			if m.cfg.ProcessWeakBlock != nil &&
+				hashNum.Cmp(weakTarget) <= 0 {
+
+				weakBlock := wire.NewMsgBlock(header)
+				weakBlock.Transactions = append([]*wire.MsgTx{
+					msgBlock.Transactions[0].Copy()},
+					msgBlock.Transactions[1:]...)
+				m.cfg.ProcessWeakBlock(weakBlock)
+			}
 		}
 	}
 
@@ -354,7 +374,7 @@ out:
 		// with false when conditions that trigger a stale block, so
 		// a new block template can be generated.  When the return is
 		// true a solution was found, so submit the solved block.
-		if m.solveBlock(template.Block, curHeight+1, ticker, quit) {
+		if m.solveBlock(template, ticker, quit) {
 			block := bchutil.NewBlock(template.Block)
 			m.submitBlock(block)
 		}
@@ -545,12 +565,66 @@ func (m *CPUMiner) NumWorkers() int32 {
 // generating a new block template.  When a block is solved, it is submitted.
 // The function returns a list of the hashes of generated blocks.
 func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
+	return m.generateNBlocks(n, nil)
+}
+
+// GenerateNBlocksToAddress generates the requested number of blocks in the
+// same way as GenerateNBlocks, except that the coinbase of every block pays
+// to the passed address instead of one of the configured mining addresses.
+func (m *CPUMiner) GenerateNBlocksToAddress(n uint32, payToAddr bchutil.Address) ([]*chainhash.Hash, error) {
+	return m.generateNBlocks(n, payToAddr)
+}
+
+// GenerateBlock generates a single block which pays to the passed address and
+// contains exactly the passed transactions.  Unlike GenerateNBlocks, an error
+// is returned if the block can not be created or is rejected.
+func (m *CPUMiner) GenerateBlock(payToAddr bchutil.Address, txns []*bchutil.Tx) (*chainhash.Hash, error) {
+	if err := m.startDiscreteMining(); err != nil {
+		return nil, err
+	}
+	defer m.stopDiscreteMining()
+
+	ticker := time.NewTicker(time.Second * hashUpdateSecs)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.updateNumWorkers:
+		default:
+		}
+
+		m.submitBlockLock.Lock()
+		template, err := m.g.NewBlockTemplateWithTxs(payToAddr, txns)
+		m.submitBlockLock.Unlock()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create new block "+
+				"template: %v", err)
+		}
+
+		// Only retry when the template went stale before a solution was
+		// found.  The transactions are fixed, so any other failure
+		// would happen again.
+		if !m.solveBlock(template, ticker, nil) {
+			continue
+		}
+		block := bchutil.NewBlock(template.Block)
+		if !m.submitBlock(block) {
+			return nil, fmt.Errorf("generated block %v was rejected",
+				block.Hash())
+		}
+		return block.Hash(), nil
+	}
+}
+
+// startDiscreteMining marks the miner as generating a discrete number of
+// blocks.  An error is returned if the miner is already running.
+func (m *CPUMiner) startDiscreteMining() error {
 	m.Lock()
+	defer m.Unlock()
 
 	// Respond with an error if server is already mining.
 	if m.started || m.discreteMining {
-		m.Unlock()
-		return nil, errors.New("server is already CPU mining. Please call " +
+		return errors.New("server is already CPU mining. Please call " +
 			"`setgenerate 0` before calling discrete `generate` commands")
 	}
 
@@ -560,8 +634,25 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 	m.speedMonitorQuit = make(chan struct{})
 	m.wg.Add(1)
 	go m.speedMonitor()
+	return nil
+}
 
+// stopDiscreteMining stops the speed monitor started by startDiscreteMining.
+func (m *CPUMiner) stopDiscreteMining() {
+	m.Lock()
+	close(m.speedMonitorQuit)
+	m.wg.Wait()
+	m.started = false
+	m.discreteMining = false
 	m.Unlock()
+}
+
+// generateNBlocks generates the requested number of blocks which pay to the
+// passed address, or to a random mining address when it is nil.
+func (m *CPUMiner) generateNBlocks(n uint32, payToAddr bchutil.Address) ([]*chainhash.Hash, error) {
+	if err := m.startDiscreteMining(); err != nil {
+		return nil, err
+	}
 
 	log.Tracef("Generating %d blocks", n)
 
@@ -586,16 +677,18 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 		// be changing and this would otherwise end up building a new block
 		// template on a block that is in the process of becoming stale.
 		m.submitBlockLock.Lock()
-		curHeight := m.g.BestSnapshot().Height
 
-		// Choose a payment address at random.
-		rand.Seed(time.Now().UnixNano())
-		payToAddr := m.cfg.MiningAddrs[rand.Intn(len(m.cfg.MiningAddrs))]
+		// Choose a payment address at random if none was provided.
+		blockPayToAddr := payToAddr
+		if blockPayToAddr == nil {
+			rand.Seed(time.Now().UnixNano())
+			blockPayToAddr = m.cfg.MiningAddrs[rand.Intn(len(m.cfg.MiningAddrs))]
+		}
 
 		// Create a new block template using the available transactions
 		// in the memory pool as a source of transactions to potentially
 		// include in the block.
-		template, err := m.g.NewBlockTemplate(payToAddr)
+		template, err := m.g.NewBlockTemplate(blockPayToAddr)
 		m.submitBlockLock.Unlock()
 		if err != nil {
 			errStr := fmt.Sprintf("Failed to create new block "+
@@ -608,19 +701,14 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 		// with false when conditions that trigger a stale block, so
 		// a new block template can be generated.  When the return is
 		// true a solution was found, so submit the solved block.
-		if m.solveBlock(template.Block, curHeight+1, ticker, nil) {
+		if m.solveBlock(template, ticker, nil) {
 			block := bchutil.NewBlock(template.Block)
 			m.submitBlock(block)
 			blockHashes[i] = block.Hash()
 			i++
 			if i == n {
 				log.Tracef("Generated %d blocks", i)
-				m.Lock()
-				close(m.speedMonitorQuit)
-				m.wg.Wait()
-				m.started = false
-				m.discreteMining = false
-				m.Unlock()
+				m.stopDiscreteMining()
 				return blockHashes, nil
 			}
 		}
@@ -0,0 +1,114 @@
+package mining
+
+import (
+	"sync"
+
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchutil"
+)
+
+// txEval houses the result of evaluating a transaction from the transaction
+// source against the chain state a block template extends.  None of it
+// changes until the best chain does, so it is cached between templates.
+type txEval struct {
+	tx *bchutil.Tx
+
+	// utxos contains the outputs referenced by the transaction which are
+	// in the main chain.  The entries must not be modified since they are
+	// shared by every template generated from the cache.
+	utxos *blockchain.UtxoViewpoint
+
+	// parents are the hashes of the transactions in the source pool which
+	// the transaction spends outputs of.
+	parents []chainhash.Hash
+
+	// priority is the priority of the transaction in the next block.
+	priority float64
+
+	// validated is set once the transaction scripts have been validated,
+	// along with either the number of signature checks they perform or
+	// the error which caused them to fail validation.
+	validated bool
+	sigChecks uint32
+	scriptErr error
+}
+
+// txEvalCache caches the evaluation of the transactions in the transaction
+// source between block templates.  Evaluating a transaction requires loading
+// the outputs it spends from the chain and validating its scripts, which is
+// what makes generating a template from a large source pool slow.  Since the
+// evaluation only depends on the chain state, only the transactions added to
+// the source since the previous template need to be evaluated as long as the
+// best chain has not changed, while the evaluations of the transactions which
+// have been removed are dropped.
+type txEvalCache struct {
+	// tipHash is the hash of the block the cached evaluations extend.
+	tipHash chainhash.Hash
+
+	// evals are the evaluations of the transactions considered for the
+	// previous template and used are those of the transactions considered
+	// for the template being generated.  The latter replace the former
+	// once the template has been generated.
+	evals map[chainhash.Hash]*txEval
+	used  map[chainhash.Hash]*txEval
+
+	sync.Mutex
+}
+
+// newTxEvalCache returns a new empty transaction evaluation cache.
+func newTxEvalCache() *txEvalCache {
+	return &txEvalCache{
+		evals: make(map[chainhash.Hash]*txEval),
+	}
+}
+
+// begin prepares the cache for generating a template which extends the block
+// with the passed hash.  All cached evaluations are discarded when it is not
+// the block the previous template extended.
+//
+// This function MUST be called with the cache lock held.
+func (c *txEvalCache) begin(tipHash *chainhash.Hash) {
+	if c.tipHash != *tipHash {
+		c.tipHash = *tipHash
+		c.evals = make(map[chainhash.Hash]*txEval)
+	}
+	c.used = make(map[chainhash.Hash]*txEval, len(c.evals))
+}
+
+// lookup returns the cached evaluation of the transaction with the passed
+// hash, or nil when it has not been evaluated yet.  The evaluation is kept for
+// the next template.
+//
+// This function MUST be called with the cache lock held.
+func (c *txEvalCache) lookup(hash *chainhash.Hash) *txEval {
+	eval := c.evals[*hash]
+	if eval != nil {
+		c.used[*hash] = eval
+	}
+	return eval
+}
+
+// add adds the passed evaluation to the cache.
+//
+// This function MUST be called with the cache lock held.
+func (c *txEvalCache) add(eval *txEval) {
+	c.used[*eval.tx.Hash()] = eval
+}
+
+// finish drops the evaluations of the transactions which were not considered
+// for the template being generated, since they are no longer in the source
+// pool.  It returns the number of cached evaluations which were reused.
+//
+// This function MUST be called with the cache lock held.
+func (c *txEvalCache) finish() int {
+	var reused int
+	for hash := range c.used {
+		if _, ok := c.evals[hash]; ok {
+			reused++
+		}
+	}
+	c.evals = c.used
+	c.used = nil
+	return reused
+}
@@ -0,0 +1,60 @@
+package mining
+
+import (
+	"testing"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// TestTxEvalCache ensures the transaction evaluation cache keeps the
+// evaluations of the transactions which remain in the source pool, drops those
+// of the transactions which were removed, and is reset when the best chain
+// changes.
+func TestTxEvalCache(t *testing.T) {
+	newEval := func(lockTime uint32) *txEval {
+		msgTx := wire.NewMsgTx(wire.TxVersion)
+		msgTx.LockTime = lockTime
+		return &txEval{tx: bchutil.NewTx(msgTx)}
+	}
+	evalA, evalB, evalC := newEval(1), newEval(2), newEval(3)
+	tipA, tipB := chainhash.Hash{0x01}, chainhash.Hash{0x02}
+
+	cache := newTxEvalCache()
+	cache.begin(&tipA)
+	if eval := cache.lookup(evalA.tx.Hash()); eval != nil {
+		t.Fatal("lookup found evaluation in empty cache")
+	}
+	cache.add(evalA)
+	cache.add(evalB)
+	if reused := cache.finish(); reused != 0 {
+		t.Fatalf("finish: got %d reused evaluations, want 0", reused)
+	}
+
+	// Only the evaluation of the transaction which is still in the source
+	// pool should be kept for the next template.
+	cache.begin(&tipA)
+	if eval := cache.lookup(evalA.tx.Hash()); eval != evalA {
+		t.Fatal("lookup did not find cached evaluation")
+	}
+	cache.add(evalC)
+	if reused := cache.finish(); reused != 1 {
+		t.Fatalf("finish: got %d reused evaluations, want 1", reused)
+	}
+	cache.begin(&tipA)
+	if eval := cache.lookup(evalB.tx.Hash()); eval != nil {
+		t.Fatal("lookup found evaluation of removed transaction")
+	}
+	if eval := cache.lookup(evalC.tx.Hash()); eval != evalC {
+		t.Fatal("lookup did not find cached evaluation")
+	}
+	cache.finish()
+
+	// Every evaluation is discarded once the best chain changes.
+	cache.begin(&tipB)
+	if eval := cache.lookup(evalC.tx.Hash()); eval != nil {
+		t.Fatal("lookup found evaluation made against previous tip")
+	}
+	cache.finish()
+}
@@ -6,7 +6,9 @@ package mining
 
 import (
 	"container/heap"
+	"errors"
 	"fmt"
+	"math"
 	"time"
 
 	"sort"
@@ -54,6 +56,11 @@ type TxDesc struct {
 
 	// FeePerKB is the fee the transaction pays in Satoshi per 1000 bytes.
 	FeePerKB int64
+
+	// Trusted indicates the transaction was submitted by the local operator
+	// and is included ahead of the other transactions regardless of the
+	// fee it pays.
+	Trusted bool
 }
 
 // TxSource represents a source of transactions to consider for inclusion in
@@ -80,15 +87,38 @@ type TxSource interface {
 // which have not been mined into a block yet.
 type txPrioItem struct {
 	tx       *bchutil.Tx
+	eval     *txEval
 	fee      int64
+	size     int64
 	priority float64
 	feePerKB int64
 
-	// dependsOn holds a map of transaction hashes which this one depends
-	// on.  It will only be set when the transaction references other
-	// transactions in the source pool and hence must come after them in
-	// a block.
-	dependsOn map[chainhash.Hash]struct{}
+	// trusted indicates the transaction was submitted by the local operator
+	// and is sorted before all other transactions regardless of its
+	// priority and fee.
+	trusted bool
+
+	// pkgFeePerKB is the fee per kilobyte of the transaction combined with
+	// all of its ancestors which have not been included in the block yet.
+	// It is the value used to order transactions by fee so that children
+	// paying high fees are able to pull in their low-fee parents.
+	pkgFeePerKB int64
+
+	// parents holds a map of the items in the source pool which this one
+	// depends on.  It will only be set when the transaction references
+	// other transactions in the source pool and hence must come after them
+	// in a block.  A nil entry indicates the referenced transaction is not
+	// available for inclusion.
+	parents map[chainhash.Hash]*txPrioItem
+
+	// children holds a map of the items in the source pool which depend
+	// on this one.
+	children map[chainhash.Hash]*txPrioItem
+
+	// included and rejected track whether the transaction has been added to
+	// the block or can never be added to the block, respectively.
+	included bool
+	rejected bool
 }
 
 // txPriorityQueueLessFunc describes a function that can be used as a compare
@@ -145,27 +175,35 @@ func (pq *txPriorityQueue) SetLessFunc(lessFunc txPriorityQueueLessFunc) {
 	heap.Init(pq)
 }
 
-// txPQByPriority sorts a txPriorityQueue by transaction priority and then fees
-// per kilobyte.
+// txPQByPriority sorts a txPriorityQueue by whether transactions are trusted,
+// transaction priority and then fees per kilobyte.
 func txPQByPriority(pq *txPriorityQueue, i, j int) bool {
 	// Using > here so that pop gives the highest priority item as opposed
-	// to the lowest.  Sort by priority first, then fee.
+	// to the lowest.  Sort trusted transactions first, then by priority,
+	// then fee.
+	if pq.items[i].trusted != pq.items[j].trusted {
+		return pq.items[i].trusted
+	}
 	if pq.items[i].priority == pq.items[j].priority {
-		return pq.items[i].feePerKB > pq.items[j].feePerKB
+		return pq.items[i].pkgFeePerKB > pq.items[j].pkgFeePerKB
 	}
 	return pq.items[i].priority > pq.items[j].priority
 
 }
 
-// txPQByFee sorts a txPriorityQueue by fees per kilobyte and then transaction
-// priority.
+// txPQByFee sorts a txPriorityQueue by whether transactions are trusted,
+// ancestor package fees per kilobyte and then transaction priority.
 func txPQByFee(pq *txPriorityQueue, i, j int) bool {
 	// Using > here so that pop gives the highest fee item as opposed
-	// to the lowest.  Sort by fee first, then priority.
-	if pq.items[i].feePerKB == pq.items[j].feePerKB {
+	// to the lowest.  Sort trusted transactions first, then by fee, then
+	// priority.
+	if pq.items[i].trusted != pq.items[j].trusted {
+		return pq.items[i].trusted
+	}
+	if pq.items[i].pkgFeePerKB == pq.items[j].pkgFeePerKB {
 		return pq.items[i].priority > pq.items[j].priority
 	}
-	return pq.items[i].feePerKB > pq.items[j].feePerKB
+	return pq.items[i].pkgFeePerKB > pq.items[j].pkgFeePerKB
 }
 
 // newTxPriorityQueue returns a new transaction priority queue that reserves the
@@ -221,19 +259,41 @@ type BlockTemplate struct {
 	// MaxSigChecks is the total sigchecks allowed in the block given the
 	// consensus rules.
 	MaxSigChecks uint32
+
+	// MerkleTree is the merkle tree of the transactions of the block.  It
+	// must be kept in sync with the transactions of the block so the merkle
+	// root can be updated without recomputing the entire tree when the
+	// coinbase changes or transactions are added or removed.
+	MerkleTree *blockchain.MerkleTree
+}
+
+// UpdateCoinbase updates the merkle tree and merkle root of the block of the
+// template after its coinbase transaction was modified.  The hashes of the
+// other transactions are not recalculated.
+func (t *BlockTemplate) UpdateCoinbase() {
+	if t.MerkleTree == nil {
+		block := bchutil.NewBlock(t.Block)
+		t.MerkleTree = blockchain.NewMerkleTreeFromTransactions(
+			block.Transactions())
+	} else {
+		coinbaseHash := t.Block.Transactions[0].TxHash()
+		t.MerkleTree.Update(0, &coinbaseHash)
+	}
+	t.Block.Header.MerkleRoot = t.MerkleTree.Root()
 }
 
 // mergeUtxoView adds all of the entries in viewB to viewA.  The result is that
 // viewA will contain all of its original entries plus all of the entries
 // in viewB.  It will replace any entries in viewB which also exist in viewA
-// if the entry in viewA is spent.
+// if the entry in viewA is spent.  The entries of viewB are copied so viewB
+// is left untouched when the entries of viewA are spent.
 func mergeUtxoView(viewA *blockchain.UtxoViewpoint, viewB *blockchain.UtxoViewpoint) {
 	viewAEntries := viewA.Entries()
 	for outpoint, entryB := range viewB.Entries() {
 		if entryA, exists := viewAEntries[outpoint]; !exists ||
 			entryA == nil || entryA.IsSpent() {
 
-			viewAEntries[outpoint] = entryB
+			viewAEntries[outpoint] = entryB.Clone()
 		}
 	}
 }
@@ -241,20 +301,51 @@ func mergeUtxoView(viewA *blockchain.UtxoViewpoint, viewB *blockchain.UtxoViewpo
 // standardCoinbaseScript returns a standard script suitable for use as the
 // signature script of the coinbase transaction of a new block.  In particular,
 // it starts with the block height that is required by version 2 blocks and adds
-// the extra nonce as well as additional coinbase flags.
-func standardCoinbaseScript(nextBlockHeight int32, extraNonce uint64) ([]byte, error) {
-	return txscript.NewScriptBuilder().AddInt64(int64(nextBlockHeight)).
-		AddInt64(int64(extraNonce)).AddData([]byte(CoinbaseFlags)).
-		Script()
+// the extra nonce as well as additional coinbase flags, followed by the passed
+// extra data when there is any.
+func standardCoinbaseScript(nextBlockHeight int32, extraNonce uint64, extraData []byte) ([]byte, error) {
+	builder := txscript.NewScriptBuilder().AddInt64(int64(nextBlockHeight)).
+		AddInt64(int64(extraNonce)).AddData([]byte(CoinbaseFlags))
+	if len(extraData) > 0 {
+		builder.AddData(extraData)
+	}
+	return builder.Script()
+}
+
+// CheckCoinbaseData returns an error when the signature script of the coinbase
+// of a block generated with the passed coinbase flags and extra data could
+// exceed the maximum length allowed by the consensus rules.  The script is
+// checked with the largest possible block height and an extra nonce of 8 bytes,
+// which is the largest one used by any miner.
+func CheckCoinbaseData(flags string, extraData []byte) error {
+	builder := txscript.NewScriptBuilder().AddInt64(math.MaxInt32).
+		AddData(make([]byte, 8)).AddData([]byte(flags))
+	if len(extraData) > 0 {
+		builder.AddData(extraData)
+	}
+	script, err := builder.Script()
+	if err != nil {
+		return err
+	}
+	if len(script) > blockchain.MaxCoinbaseScriptLen {
+		return fmt.Errorf("coinbase script length of %d with the "+
+			"coinbase flags and data exceeds the maximum of %d",
+			len(script), blockchain.MaxCoinbaseScriptLen)
+	}
+	return nil
 }
 
 // createCoinbaseTx returns a coinbase transaction paying an appropriate subsidy
 // based on the passed block height to the provided address.  When the address
-// is nil, the coinbase transaction will instead be redeemable by anyone.
+// is nil, the coinbase transaction will instead be redeemable by anyone.  The
+// passed extra outputs follow the payout output and their values are deducted
+// from the subsidy it pays.
 //
 // See the comment for NewBlockTemplate for more information about why the nil
 // address handling is useful.
-func createCoinbaseTx(params *chaincfg.Params, coinbaseScript []byte, nextBlockHeight int32, addr bchutil.Address) (*bchutil.Tx, error) {
+func createCoinbaseTx(params *chaincfg.Params, coinbaseScript []byte, nextBlockHeight int32,
+	addr bchutil.Address, extraOutputs []*wire.TxOut) (*bchutil.Tx, error) {
+
 	// Create the script to pay to the provided payment address if one was
 	// specified.  Otherwise create a script that allows the coinbase to be
 	// redeemable by anyone.
@@ -283,10 +374,29 @@ func createCoinbaseTx(params *chaincfg.Params, coinbaseScript []byte, nextBlockH
 		SignatureScript: coinbaseScript,
 		Sequence:        wire.MaxTxInSequenceNum,
 	})
-	tx.AddTxOut(&wire.TxOut{
+	payout := &wire.TxOut{
 		Value:    blockchain.CalcBlockSubsidy(nextBlockHeight, params),
 		PkScript: pkScript,
-	})
+	}
+	tx.AddTxOut(payout)
+	for _, txOut := range extraOutputs {
+		if txOut.Value < 0 || txOut.Value > payout.Value {
+			return nil, fmt.Errorf("coinbase outputs pay more than "+
+				"the block subsidy of %d", blockchain.CalcBlockSubsidy(
+				nextBlockHeight, params))
+		}
+		payout.Value -= txOut.Value
+		tx.AddTxOut(wire.NewTxOut(txOut.Value, txOut.PkScript,
+			wire.TokenData{}))
+	}
+
+	// Blocks on signed test networks commit to their signature solution
+	// in the coinbase.
+	if params.SignetChallenge != nil {
+		if err := AddSignetCommitment(tx); err != nil {
+			return nil, err
+		}
+	}
 	padCoinbaseScript(tx)
 
 	return bchutil.NewTx(tx), nil
@@ -368,6 +478,7 @@ type BlkTmplGenerator struct {
 	timeSource  blockchain.MedianTimeSource
 	sigCache    *txscript.SigCache
 	hashCache   *txscript.HashCache
+	evalCache   *txEvalCache
 }
 
 // NewBlkTmplGenerator returns a new block template generator for the given
@@ -390,6 +501,7 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 		timeSource:  timeSource,
 		sigCache:    sigCache,
 		hashCache:   hashCache,
+		evalCache:   newTxEvalCache(),
 	}
 }
 
@@ -410,14 +522,16 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 // higher fee per kilobyte are preferred.  Finally, the block generation related
 // policy settings are all taken into account.
 //
-// Transactions which only spend outputs from other transactions already in the
-// block chain are immediately added to a priority queue which either
-// prioritizes based on the priority (then fee per kilobyte) or the fee per
-// kilobyte (then priority) depending on whether or not the BlockPrioritySize
-// policy setting allots space for high-priority transactions.  Transactions
-// which spend outputs from other transactions in the source pool are added to a
-// dependency map so they can be added to the priority queue once the
-// transactions they depend on have been included.
+// Every transaction is evaluated as a package consisting of the transaction
+// itself along with all of its ancestors in the source pool which have not been
+// included in the block yet.  The packages are added to a priority queue which
+// either prioritizes based on the priority (then package fee per kilobyte) or
+// the package fee per kilobyte (then priority) depending on whether or not the
+// BlockPrioritySize policy setting allots space for high-priority transactions.
+// When a transaction is selected, all of its unincluded ancestors are added to
+// the block before it.  This allows a child transaction which pays a high fee
+// to pull in low-fee parents (child-pays-for-parent).  The package fee rates of
+// the remaining transactions are updated as their ancestors are included.
 //
 // Once the high-priority area (if configured) has been filled with
 // transactions, or the priority falls below what is considered high-priority,
@@ -429,6 +543,10 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 // nonzero, in which case the block will be filled with the low-fee/free
 // transactions until the block size reaches that minimum size.
 //
+// Trusted transactions, which were submitted by the local operator, are selected
+// before all others along with their ancestors and are never skipped for
+// paying too little fee.
+//
 // Any transactions which would cause the block to exceed the BlockMaxSize
 // policy setting, exceed the maximum allowed signature operations per block, or
 // otherwise cause the block to be invalid are skipped.
@@ -475,12 +593,13 @@ func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress bchutil.Address) (*Bloc
 	// same value to the same public key address would otherwise be an
 	// identical transaction for block version 1).
 	extraNonce := uint64(0)
-	coinbaseScript, err := standardCoinbaseScript(nextBlockHeight, extraNonce)
+	coinbaseScript, err := standardCoinbaseScript(nextBlockHeight, extraNonce,
+		g.policy.CoinbaseData)
 	if err != nil {
 		return nil, err
 	}
 	coinbaseTx, err := createCoinbaseTx(g.chainParams, coinbaseScript,
-		nextBlockHeight, payToAddress)
+		nextBlockHeight, payToAddress, g.policy.CoinbaseOutputs)
 	if err != nil {
 		return nil, err
 	}
@@ -502,12 +621,11 @@ func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress bchutil.Address) (*Bloc
 	blockTxns := make([]*bchutil.Tx, 0, len(sourceTxns))
 	blockUtxos := blockchain.NewUtxoViewpoint()
 
-	// dependers is used to track transactions which depend on another
-	// transaction in the source pool.  This, in conjunction with the
-	// dependsOn map kept with each dependent transaction helps quickly
-	// determine which dependent transactions are now eligible for inclusion
-	// in the block once each transaction has been included.
-	dependers := make(map[chainhash.Hash]map[chainhash.Hash]*txPrioItem)
+	// txns tracks the ancestor and descendant relationships between the
+	// transactions in the source pool.  This allows each transaction to be
+	// evaluated as a package along with all of the transactions it depends
+	// on which have not been included in the block yet.
+	txns := newTxGraph(len(sourceTxns))
 
 	// Create slices to hold the fees and number of signature operations
 	// for each of the selected transactions and add an entry for the
@@ -523,7 +641,18 @@ func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress bchutil.Address) (*Bloc
 	log.Debugf("Considering %d transactions for inclusion to new block",
 		len(sourceTxns))
 
-mempoolLoop:
+	// Only the transactions which have been added to the source pool since
+	// the previous template need to be evaluated against the chain unless
+	// the best chain has changed.
+	g.evalCache.Lock()
+	defer g.evalCache.Unlock()
+	g.evalCache.begin(&best.Hash)
+	defer func() {
+		reused := g.evalCache.finish()
+		log.Debugf("Reused the evaluation of %d of %d transactions",
+			reused, len(sourceTxns))
+	}()
+
 	for _, txDesc := range sourceTxns {
 		// A block can't have more than one coinbase or contain
 		// non-finalized transactions.
@@ -539,79 +668,58 @@ mempoolLoop:
 			continue
 		}
 
-		// Fetch all of the utxos referenced by the this transaction.
-		// NOTE: This intentionally does not fetch inputs from the
-		// mempool since a transaction which depends on other
-		// transactions in the mempool must come after those
-		// dependencies in the final generated block.
-		utxos, err := g.chain.FetchUtxoView(tx)
-		if err != nil {
-			log.Warnf("Unable to fetch utxo view for tx %s: %v",
-				tx.Hash(), err)
-			continue
+		eval := g.evalCache.lookup(tx.Hash())
+		if eval == nil {
+			eval = g.evalTx(tx, nextBlockHeight)
+			if eval == nil {
+				continue
+			}
+			g.evalCache.add(eval)
 		}
 
 		// Setup dependencies for any transactions which reference
 		// other transactions in the mempool so they can be properly
-		// ordered below.
-		prioItem := &txPrioItem{tx: tx}
-		for _, txIn := range tx.MsgTx().TxIn {
-			originHash := &txIn.PreviousOutPoint.Hash
-			entry := utxos.LookupEntry(txIn.PreviousOutPoint)
-			if entry == nil || entry.IsSpent() {
-				if !g.txSource.HaveTransaction(originHash) {
-					log.Tracef("Skipping tx %s because it "+
-						"references unspent output %s "+
-						"which is not available",
-						tx.Hash(), txIn.PreviousOutPoint)
-					continue mempoolLoop
-				}
-
-				// The transaction is referencing another
-				// transaction in the source pool, so setup an
-				// ordering dependency.
-				deps, exists := dependers[*originHash]
-				if !exists {
-					deps = make(map[chainhash.Hash]*txPrioItem)
-					dependers[*originHash] = deps
-				}
-				deps[*prioItem.tx.Hash()] = prioItem
-				if prioItem.dependsOn == nil {
-					prioItem.dependsOn = make(
-						map[chainhash.Hash]struct{})
-				}
-				prioItem.dependsOn[*originHash] = struct{}{}
-
-				// Skip the check below. We already know the
-				// referenced transaction is available.
-				continue
+		// ordered below.  The parents are linked once all of the
+		// transactions have been seen.
+		prioItem := &txPrioItem{tx: tx, eval: eval}
+		if len(eval.parents) > 0 {
+			prioItem.parents = make(map[chainhash.Hash]*txPrioItem,
+				len(eval.parents))
+			for _, parentHash := range eval.parents {
+				prioItem.parents[parentHash] = nil
 			}
 		}
 
-		// Calculate the final transaction priority using the input
-		// value age sum as well as the adjusted transaction size.  The
-		// formula is: sum(inputValue * inputAge) / adjustedTxSize
-		prioItem.priority = CalcPriority(tx.MsgTx(), utxos,
-			nextBlockHeight)
-
-		// Calculate the fee in Satoshi/kB.
+		prioItem.priority = eval.priority
 		prioItem.feePerKB = txDesc.FeePerKB
 		prioItem.fee = txDesc.Fee
-
-		// Add the transaction to the priority queue to mark it ready
-		// for inclusion in the block unless it has dependencies.
-		if prioItem.dependsOn == nil {
-			heap.Push(priorityQueue, prioItem)
-		}
+		prioItem.trusted = txDesc.Trusted
+		prioItem.size = int64(tx.MsgTx().SerializeSize())
+		txns.addItem(prioItem)
 
 		// Merge the referenced outputs from the input transactions to
 		// this transaction into the block utxo view.  This allows the
 		// code below to avoid a second lookup.
-		mergeUtxoView(blockUtxos, utxos)
+		mergeUtxoView(blockUtxos, eval.utxos)
 	}
 
-	log.Tracef("Priority queue len %d, dependers len %d",
-		priorityQueue.Len(), len(dependers))
+	// Link the transactions to the transactions they depend on and add
+	// them to the priority queue along with the fee rate of their ancestor
+	// package.  Transactions which depend on a transaction that is not
+	// available can never be included, so they are skipped.
+	txns.link()
+	for _, prioItem := range txns.items {
+		if !updatePackageFee(prioItem) {
+			log.Tracef("Skipping tx %s since it depends on a "+
+				"transaction which is not available",
+				prioItem.tx.Hash())
+			continue
+		}
+		heap.Push(priorityQueue, prioItem)
+	}
+
+	log.Tracef("Priority queue len %d, source txns len %d",
+		priorityQueue.Len(), len(txns.items))
 
 	// The starting block size is the size of the block header plus the max
 	// possible transaction count size, plus the size of the coinbase
@@ -622,45 +730,73 @@ mempoolLoop:
 
 	// Choose which transactions make it into the block.
 	for priorityQueue.Len() > 0 {
-		// Grab the highest priority (or highest fee per kilobyte
+		// Grab the highest priority (or highest package fee per kilobyte
 		// depending on the sort order) transaction.
 		prioItem := heap.Pop(priorityQueue).(*txPrioItem)
 		tx := prioItem.tx
 
-		// Grab any transactions which depend on this one.
-		deps := dependers[*tx.Hash()]
+		// Skip transactions which were already included as an ancestor
+		// of another transaction or which can never be included.
+		if prioItem.included || prioItem.rejected {
+			continue
+		}
+
+		// Gather the transaction along with all of its ancestors which
+		// have not been included yet.  The package fee rate changes as
+		// ancestors are included by other packages, so put the
+		// transaction back into the priority queue when the rate it was
+		// sorted by is out of date.
+		pkg, ok := ancestorPackage(prioItem)
+		if !ok {
+			log.Tracef("Skipping tx %s since it depends on a "+
+				"rejected transaction", tx.Hash())
+			prioItem.rejected = true
+			logSkippedDeps(tx, prioItem.children)
+			continue
+		}
+		pkgFee, pkgSize := packageFees(pkg)
+		pkgFeePerKB := calcFeePerKB(pkgFee, pkgSize)
+		if pkgFeePerKB != prioItem.pkgFeePerKB {
+			prioItem.pkgFeePerKB = pkgFeePerKB
+			heap.Push(priorityQueue, prioItem)
+			continue
+		}
 
-		// Enforce maximum block size.  Also check for overflow.
-		txSize := uint32(tx.MsgTx().SerializeSize())
+		// Enforce maximum block size for the entire package.  Also
+		// check for overflow.
+		txSize := uint32(pkgSize)
 		blockPlusTxSize := blockSize + txSize
 		if blockPlusTxSize < txSize ||
 			blockPlusTxSize >= g.policy.BlockMaxSize {
 
-			log.Tracef("Skipping tx %s because it would exceed "+
-				"the max block size", tx.Hash())
-			logSkippedDeps(tx, deps)
+			log.Tracef("Skipping tx %s because its package of %d "+
+				"transactions would exceed the max block size",
+				tx.Hash(), len(pkg))
+			logSkippedDeps(tx, prioItem.children)
 			continue
 		}
 
 		// Skip free transactions once the block is larger than the
-		// minimum block size.
-		if sortedByFee &&
-			prioItem.feePerKB < int64(g.policy.TxMinFreeFee) &&
+		// minimum block size.  Trusted transactions are always
+		// included.
+		if sortedByFee && !prioItem.trusted &&
+			prioItem.pkgFeePerKB < int64(g.policy.TxMinFreeFee) &&
 			blockPlusTxSize >= g.policy.BlockMinSize {
 
-			log.Tracef("Skipping tx %s with feePerKB %d "+
+			log.Tracef("Skipping tx %s with package feePerKB %d "+
 				"< TxMinFreeFee %d and block size %d >= "+
-				"minBlockSize %d", tx.Hash(), prioItem.feePerKB,
+				"minBlockSize %d", tx.Hash(), prioItem.pkgFeePerKB,
 				g.policy.TxMinFreeFee, blockPlusTxSize,
 				g.policy.BlockMinSize)
-			logSkippedDeps(tx, deps)
+			logSkippedDeps(tx, prioItem.children)
 			continue
 		}
 
 		// Prioritize by fee per kilobyte once the block is larger than
 		// the priority size or there are no more high-priority
-		// transactions.
-		if !sortedByFee && (blockPlusTxSize >= g.policy.BlockPrioritySize ||
+		// transactions.  Trusted transactions are sorted first either
+		// way, so they are added right away.
+		if !sortedByFee && !prioItem.trusted && (blockPlusTxSize >= g.policy.BlockPrioritySize ||
 			prioItem.priority <= MinHighPriority) {
 
 			log.Tracef("Switching to sort by fees per "+
@@ -686,73 +822,245 @@ mempoolLoop:
 			}
 		}
 
-		// Ensure the transaction inputs pass all of the necessary
-		// preconditions before allowing it to be added to the block.
-		_, err = blockchain.CheckTransactionInputs(tx, nextBlockHeight,
-			blockUtxos, g.chainParams)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"CheckTransactionInputs: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
+		// Add each transaction in the package to the block, parents
+		// first.  Any ancestors which are added before a transaction
+		// in the package fails to validate are valid on their own, so
+		// they are kept.
+		for _, pkgItem := range pkg {
+			pkgTx := pkgItem.tx
+
+			// Ensure the transaction inputs pass all of the necessary
+			// preconditions before allowing it to be added to the
+			// block.
+			_, err = blockchain.CheckTransactionInputs(pkgTx,
+				nextBlockHeight, blockUtxos, g.chainParams)
+			if err != nil {
+				log.Tracef("Skipping tx %s due to error in "+
+					"CheckTransactionInputs: %v", pkgTx.Hash(), err)
+				pkgItem.rejected = true
+				logSkippedDeps(pkgTx, pkgItem.children)
+				break
+			}
+
+			// The scripts only need to be validated once for as
+			// long as the best chain does not change.
+			eval := pkgItem.eval
+			if !eval.validated {
+				sigchecks, err := blockchain.ValidateTransactionScripts(pkgTx,
+					blockUtxos, txscript.StandardVerifyFlags, g.sigCache,
+					g.hashCache, g.chainParams.Upgrade9ForkHeight)
+				eval.validated = true
+				eval.sigChecks, eval.scriptErr = sigchecks, err
+			}
+			if eval.scriptErr != nil {
+				log.Tracef("Skipping tx %s due to error in "+
+					"ValidateTransactionScripts: %v", pkgTx.Hash(),
+					eval.scriptErr)
+				pkgItem.rejected = true
+				logSkippedDeps(pkgTx, pkgItem.children)
+				break
+			}
+			sigchecks := eval.sigChecks
+
+			if blockSigChecks+int64(sigchecks) < blockSigChecks ||
+				blockSigChecks+int64(sigchecks) > int64(maxSigChecks) {
+				log.Tracef("Skipping tx %s because it would "+
+					"exceed the maximum sigchecks per block",
+					pkgTx.Hash())
+				logSkippedDeps(pkgTx, pkgItem.children)
+				break
+			}
+
+			// Spend the transaction inputs in the block utxo view and
+			// add an entry for it to ensure any transactions which
+			// reference this one have it available as an input and
+			// can ensure they aren't double spending.
+			spendTransaction(blockUtxos, pkgTx, nextBlockHeight)
+
+			// Add the transaction to the block, increment counters,
+			// and save the fees and signature operation counts to
+			// the block template.
+			pkgItem.included = true
+			blockTxns = append(blockTxns, pkgTx)
+			blockSize += uint32(pkgItem.size)
+			blockSigChecks += int64(sigchecks)
+			totalFees += pkgItem.fee
+			txFees = append(txFees, pkgItem.fee)
+			txSigChecks = append(txSigChecks, int64(sigchecks))
+
+			log.Tracef("Adding tx %s (priority %.2f, feePerKB %d, "+
+				"package feePerKB %d)", pkgTx.Hash(),
+				pkgItem.priority, pkgItem.feePerKB, pkgFeePerKB)
 		}
-		sigchecks, err := blockchain.ValidateTransactionScripts(tx, blockUtxos,
-			txscript.StandardVerifyFlags, g.sigCache,
-			g.hashCache, g.chainParams.Upgrade9ForkHeight)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"ValidateTransactionScripts: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
+	}
+
+	// Now that the actual transactions have been selected, update the
+	// block size for the real transaction count and coinbase value with
+	// the total fees accordingly.
+	blockSize -= wire.MaxVarIntPayload - uint32(wire.VarIntSerializeSize(uint64(len(blockTxns))))
+	coinbaseTx.MsgTx().TxOut[0].Value += totalFees
+	txFees[0] = -totalFees
+
+	return g.assembleBlockTemplate(best, ts, payToAddress, coinbaseTx, blockTxns,
+		txFees, txSigChecks, blockSize, blockSigChecks, maxBlockSize,
+		maxSigChecks)
+}
+
+// evalTx evaluates the passed transaction from the transaction source against
+// the current best chain.  It returns nil when the transaction can't be
+// included in a block because it spends an output which is neither in the
+// main chain nor created by a transaction in the source pool.
+func (g *BlkTmplGenerator) evalTx(tx *bchutil.Tx, nextBlockHeight int32) *txEval {
+	// Fetch all of the utxos referenced by the this transaction.
+	// NOTE: This intentionally does not fetch inputs from the mempool
+	// since a transaction which depends on other transactions in the
+	// mempool must come after those dependencies in the final generated
+	// block.
+	utxos, err := g.chain.FetchUtxoView(tx)
+	if err != nil {
+		log.Warnf("Unable to fetch utxo view for tx %s: %v",
+			tx.Hash(), err)
+		return nil
+	}
+
+	eval := &txEval{tx: tx, utxos: utxos}
+	for _, txIn := range tx.MsgTx().TxIn {
+		originHash := &txIn.PreviousOutPoint.Hash
+		entry := utxos.LookupEntry(txIn.PreviousOutPoint)
+		if entry != nil && !entry.IsSpent() {
 			continue
 		}
+		if !g.txSource.HaveTransaction(originHash) {
+			log.Tracef("Skipping tx %s because it references "+
+				"unspent output %s which is not available",
+				tx.Hash(), txIn.PreviousOutPoint)
+			return nil
+		}
 
-		if blockSigChecks+int64(sigchecks) < blockSigChecks ||
-			blockSigChecks+int64(sigchecks) > int64(maxSigChecks) {
-			log.Tracef("Skipping tx %s because it would "+
-				"exceed the maximum sigchecks per block", tx.Hash())
-			logSkippedDeps(tx, deps)
-			continue
+		// The transaction is referencing another transaction in the
+		// source pool, so it must come after it in the block.
+		eval.parents = append(eval.parents, *originHash)
+	}
+
+	// Calculate the final transaction priority using the input value age
+	// sum as well as the adjusted transaction size.  The formula is:
+	// sum(inputValue * inputAge) / adjustedTxSize
+	eval.priority = CalcPriority(tx.MsgTx(), utxos, nextBlockHeight)
+
+	return eval
+}
+
+// NewBlockTemplateWithTxs returns a new block template that is ready to be
+// solved and contains exactly the passed transactions along with a coinbase
+// that pays to the passed address.  Unlike NewBlockTemplate, the transaction
+// source is not consulted and no policy is applied, so the template is only
+// subject to the consensus rules.  The transactions may spend the outputs of
+// transactions which come before them in the passed slice, and are reordered
+// as required by the consensus rules.  An error is returned if any of them can
+// not be included in the block.
+//
+// This is primarily useful to deterministically generate blocks on test
+// networks.
+func (g *BlkTmplGenerator) NewBlockTemplateWithTxs(payToAddress bchutil.Address,
+	txns []*bchutil.Tx) (*BlockTemplate, error) {
+
+	best := g.chain.BestSnapshot()
+	nextBlockHeight := best.Height + 1
+	ts := medianAdjustedTime(best, g.timeSource)
+	maxBlockSize := g.chain.MaxBlockSize(true, false)
+	maxSigChecks := maxBlockSize / blockchain.BlockMaxBytesMaxSigChecksRatio
+
+	coinbaseScript, err := standardCoinbaseScript(nextBlockHeight, 0,
+		g.policy.CoinbaseData)
+	if err != nil {
+		return nil, err
+	}
+	coinbaseTx, err := createCoinbaseTx(g.chainParams, coinbaseScript,
+		nextBlockHeight, payToAddress, g.policy.CoinbaseOutputs)
+	if err != nil {
+		return nil, err
+	}
+
+	blockTxns := make([]*bchutil.Tx, 0, len(txns))
+	blockUtxos := blockchain.NewUtxoViewpoint()
+	txFees := make([]int64, 0, len(txns)+1)
+	txSigChecks := make([]int64, 0, len(txns)+1)
+	txFees = append(txFees, -1)          // Updated once known
+	txSigChecks = append(txSigChecks, 0) // Coinbase has zero sigchecks
+	blockSize := uint32(blockHeaderOverhead * coinbaseTx.MsgTx().SerializeSize())
+	blockSigChecks := int64(0)
+	totalFees := int64(0)
+	for _, tx := range txns {
+		if blockchain.IsCoinBase(tx) {
+			return nil, fmt.Errorf("transaction %v is a coinbase",
+				tx.Hash())
 		}
+		if !blockchain.IsFinalizedTransaction(tx, nextBlockHeight,
+			g.timeSource.AdjustedTime()) {
 
-		// Spend the transaction inputs in the block utxo view and add
-		// an entry for it to ensure any transactions which reference
-		// this one have it available as an input and can ensure they
-		// aren't double spending.
+			return nil, fmt.Errorf("transaction %v is not finalized",
+				tx.Hash())
+		}
+
+		// Outputs created by earlier transactions in the block are
+		// already in the block utxo view, so only fetch the others
+		// from the chain.
+		utxos, err := g.chain.FetchUtxoView(tx)
+		if err != nil {
+			return nil, err
+		}
+		blockEntries := blockUtxos.Entries()
+		for outpoint, entry := range utxos.Entries() {
+			if _, exists := blockEntries[outpoint]; !exists {
+				blockEntries[outpoint] = entry
+			}
+		}
+
+		fee, err := blockchain.CheckTransactionInputs(tx,
+			nextBlockHeight, blockUtxos, g.chainParams)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %v: %v", tx.Hash(), err)
+		}
+		sigchecks, err := blockchain.ValidateTransactionScripts(tx,
+			blockUtxos, txscript.StandardVerifyFlags, g.sigCache,
+			g.hashCache, g.chainParams.Upgrade9ForkHeight)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %v: %v", tx.Hash(), err)
+		}
 		spendTransaction(blockUtxos, tx, nextBlockHeight)
 
-		// Add the transaction to the block, increment counters, and
-		// save the fees and signature operation counts to the block
-		// template.
 		blockTxns = append(blockTxns, tx)
-		blockSize = blockPlusTxSize
+		blockSize += uint32(tx.MsgTx().SerializeSize())
 		blockSigChecks += int64(sigchecks)
-		totalFees += prioItem.fee
-		txFees = append(txFees, prioItem.fee)
+		totalFees += fee
+		txFees = append(txFees, fee)
 		txSigChecks = append(txSigChecks, int64(sigchecks))
-
-		log.Tracef("Adding tx %s (priority %.2f, feePerKB %.2f)",
-			prioItem.tx.Hash(), prioItem.priority, prioItem.feePerKB)
-
-		// Add transactions which depend on this one (and also do not
-		// have any other unsatisified dependencies) to the priority
-		// queue.
-		for _, item := range deps {
-			// Add the transaction to the priority queue if there
-			// are no more dependencies after this one.
-			delete(item.dependsOn, *tx.Hash())
-			if len(item.dependsOn) == 0 {
-				heap.Push(priorityQueue, item)
-			}
-		}
+	}
+	if blockSigChecks > int64(maxSigChecks) {
+		return nil, errors.New("transactions exceed the maximum " +
+			"sigchecks per block")
 	}
 
-	// Now that the actual transactions have been selected, update the
-	// block size for the real transaction count and coinbase value with
-	// the total fees accordingly.
 	blockSize -= wire.MaxVarIntPayload - uint32(wire.VarIntSerializeSize(uint64(len(blockTxns))))
 	coinbaseTx.MsgTx().TxOut[0].Value += totalFees
 	txFees[0] = -totalFees
 
+	return g.assembleBlockTemplate(best, ts, payToAddress, coinbaseTx, blockTxns,
+		txFees, txSigChecks, blockSize, blockSigChecks, maxBlockSize,
+		maxSigChecks)
+}
+
+// assembleBlockTemplate creates a block template which extends the passed best
+// state with the passed coinbase and transactions, and checks it against the
+// consensus rules.
+func (g *BlkTmplGenerator) assembleBlockTemplate(best *blockchain.BestState,
+	ts time.Time, payToAddress bchutil.Address, coinbaseTx *bchutil.Tx,
+	blockTxns []*bchutil.Tx, txFees, txSigChecks []int64, blockSize uint32,
+	blockSigChecks int64, maxBlockSize, maxSigChecks uint64) (*BlockTemplate, error) {
+
+	nextBlockHeight := best.Height + 1
+	totalFees := -txFees[0]
+
 	// Calculate the required difficulty for the block.  The timestamp
 	// is potentially adjusted to ensure it comes after the median time of
 	// the last several blocks per the chain consensus rules.
@@ -776,12 +1084,12 @@ mempoolLoop:
 	blockTxns = append([]*bchutil.Tx{coinbaseTx}, blockTxns...)
 
 	// Create a new block ready to be solved.
-	merkles := blockchain.BuildMerkleTreeStore(blockTxns)
+	merkleTree := blockchain.NewMerkleTreeFromTransactions(blockTxns)
 	var msgBlock wire.MsgBlock
 	msgBlock.Header = wire.BlockHeader{
 		Version:    nextBlockVersion,
 		PrevBlock:  best.Hash,
-		MerkleRoot: *merkles[len(merkles)-1],
+		MerkleRoot: merkleTree.Root(),
 		Timestamp:  ts,
 		Bits:       reqDifficulty,
 	}
@@ -790,6 +1098,9 @@ mempoolLoop:
 			return nil, err
 		}
 	}
+	if err := g.signBlock(&msgBlock); err != nil {
+		return nil, err
+	}
 
 	// Finally, perform a full check on the created block against the chain
 	// consensus rules to ensure it properly connects to the current best
@@ -813,6 +1124,7 @@ mempoolLoop:
 		Height:          nextBlockHeight,
 		ValidPayAddress: payToAddress != nil,
 		MaxBlockSize:    uint32(maxBlockSize),
+		MerkleTree:      merkleTree,
 	}, nil
 }
 
@@ -838,15 +1150,18 @@ func (g *BlkTmplGenerator) UpdateBlockTime(msgBlock *wire.MsgBlock) error {
 		msgBlock.Header.Bits = difficulty
 	}
 
-	return nil
+	// The signature solution commits to the timestamp.
+	return g.signBlock(msgBlock)
 }
 
-// UpdateExtraNonce updates the extra nonce in the coinbase script of the passed
-// block by regenerating the coinbase script with the passed value and block
-// height.  It also recalculates and updates the new merkle root that results
-// from changing the coinbase script.
-func (g *BlkTmplGenerator) UpdateExtraNonce(msgBlock *wire.MsgBlock, blockHeight int32, extraNonce uint64) error {
-	coinbaseScript, err := standardCoinbaseScript(blockHeight, extraNonce)
+// UpdateExtraNonce updates the extra nonce in the coinbase script of the block
+// of the passed template by regenerating the coinbase script with the passed
+// value and the height of the template.  It also recalculates and updates the
+// new merkle root that results from changing the coinbase script.
+func (g *BlkTmplGenerator) UpdateExtraNonce(template *BlockTemplate, extraNonce uint64) error {
+	msgBlock := template.Block
+	coinbaseScript, err := standardCoinbaseScript(template.Height, extraNonce,
+		g.policy.CoinbaseData)
 	if err != nil {
 		return err
 	}
@@ -861,15 +1176,12 @@ func (g *BlkTmplGenerator) UpdateExtraNonce(msgBlock *wire.MsgBlock, blockHeight
 	// Make sure the coinbase is above the minimum size threshold.
 	padCoinbaseScript((msgBlock.Transactions[0]))
 
-	// TODO(davec): A bchutil.Block should use saved in the state to avoid
-	// recalculating all of the other transaction hashes.
-	// block.Transactions[0].InvalidateCache()
+	// Recalculate the merkle root with the updated extra nonce.  Only the
+	// nodes along the path of the coinbase are recomputed.
+	template.UpdateCoinbase()
 
-	// Recalculate the merkle root with the updated extra nonce.
-	block := bchutil.NewBlock(msgBlock)
-	merkles := blockchain.BuildMerkleTreeStore(block.Transactions())
-	msgBlock.Header.MerkleRoot = *merkles[len(merkles)-1]
-	return nil
+	// The signature solution commits to the coinbase.
+	return g.signBlock(msgBlock)
 }
 
 // BestSnapshot returns information about the current best chain block and
@@ -888,3 +1200,10 @@ func (g *BlkTmplGenerator) BestSnapshot() *blockchain.BestState {
 func (g *BlkTmplGenerator) TxSource() TxSource {
 	return g.txSource
 }
+
+// Policy returns the policy used to generate block templates.
+//
+// This function is safe for concurrent access.
+func (g *BlkTmplGenerator) Policy() *Policy {
+	return g.policy
+}
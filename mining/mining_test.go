@@ -5,13 +5,16 @@
 package mining
 
 import (
+	"bytes"
 	"container/heap"
 	"math/rand"
 	"testing"
 
 	"github.com/gcash/bchd/blockchain"
 	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
 	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
 
 	"github.com/gcash/bchutil"
 )
@@ -22,18 +25,18 @@ func TestTxFeePrioHeap(t *testing.T) {
 	// Create some fake priority items that exercise the expected sort
 	// edge conditions.
 	testItems := []*txPrioItem{
-		{feePerKB: 5678, priority: 3},
-		{feePerKB: 5678, priority: 1},
-		{feePerKB: 5678, priority: 1}, // Duplicate fee and prio
-		{feePerKB: 5678, priority: 5},
-		{feePerKB: 5678, priority: 2},
-		{feePerKB: 1234, priority: 3},
-		{feePerKB: 1234, priority: 1},
-		{feePerKB: 1234, priority: 5},
-		{feePerKB: 1234, priority: 5}, // Duplicate fee and prio
-		{feePerKB: 1234, priority: 2},
-		{feePerKB: 10000, priority: 0}, // Higher fee, smaller prio
-		{feePerKB: 0, priority: 10000}, // Higher prio, lower fee
+		{pkgFeePerKB: 5678, priority: 3},
+		{pkgFeePerKB: 5678, priority: 1},
+		{pkgFeePerKB: 5678, priority: 1}, // Duplicate fee and prio
+		{pkgFeePerKB: 5678, priority: 5},
+		{pkgFeePerKB: 5678, priority: 2},
+		{pkgFeePerKB: 1234, priority: 3},
+		{pkgFeePerKB: 1234, priority: 1},
+		{pkgFeePerKB: 1234, priority: 5},
+		{pkgFeePerKB: 1234, priority: 5}, // Duplicate fee and prio
+		{pkgFeePerKB: 1234, priority: 2},
+		{pkgFeePerKB: 10000, priority: 0}, // Higher fee, smaller prio
+		{pkgFeePerKB: 0, priority: 10000}, // Higher prio, lower fee
 	}
 
 	// Add random data in addition to the edge conditions already manually
@@ -47,8 +50,8 @@ func TestTxFeePrioHeap(t *testing.T) {
 	prng := rand.New(rand.NewSource(randSeed))
 	for i := 0; i < 1000; i++ {
 		testItems = append(testItems, &txPrioItem{
-			feePerKB: int64(prng.Float64() * bchutil.SatoshiPerBitcoin),
-			priority: prng.Float64() * 100,
+			pkgFeePerKB: int64(prng.Float64() * bchutil.SatoshiPerBitcoin),
+			priority:    prng.Float64() * 100,
 		})
 	}
 
@@ -60,7 +63,7 @@ func TestTxFeePrioHeap(t *testing.T) {
 		if highest == nil {
 			highest = prioItem
 		}
-		if prioItem.feePerKB >= highest.feePerKB &&
+		if prioItem.pkgFeePerKB >= highest.pkgFeePerKB &&
 			prioItem.priority > highest.priority {
 
 			highest = prioItem
@@ -70,14 +73,14 @@ func TestTxFeePrioHeap(t *testing.T) {
 
 	for i := 0; i < len(testItems); i++ {
 		prioItem := heap.Pop(priorityQueue).(*txPrioItem)
-		if prioItem.feePerKB >= highest.feePerKB &&
+		if prioItem.pkgFeePerKB >= highest.pkgFeePerKB &&
 			prioItem.priority > highest.priority {
 
 			t.Fatalf("fee sort: item (fee per KB: %v, "+
 				"priority: %v) higher than than prev "+
 				"(fee per KB: %v, priority %v)",
-				prioItem.feePerKB, prioItem.priority,
-				highest.feePerKB, highest.priority)
+				prioItem.pkgFeePerKB, prioItem.priority,
+				highest.pkgFeePerKB, highest.priority)
 		}
 		highest = prioItem
 	}
@@ -91,7 +94,7 @@ func TestTxFeePrioHeap(t *testing.T) {
 			highest = prioItem
 		}
 		if prioItem.priority >= highest.priority &&
-			prioItem.feePerKB > highest.feePerKB {
+			prioItem.pkgFeePerKB > highest.pkgFeePerKB {
 
 			highest = prioItem
 		}
@@ -101,21 +104,42 @@ func TestTxFeePrioHeap(t *testing.T) {
 	for i := 0; i < len(testItems); i++ {
 		prioItem := heap.Pop(priorityQueue).(*txPrioItem)
 		if prioItem.priority >= highest.priority &&
-			prioItem.feePerKB > highest.feePerKB {
+			prioItem.pkgFeePerKB > highest.pkgFeePerKB {
 
 			t.Fatalf("priority sort: item (fee per KB: %v, "+
 				"priority: %v) higher than than prev "+
 				"(fee per KB: %v, priority %v)",
-				prioItem.feePerKB, prioItem.priority,
-				highest.feePerKB, highest.priority)
+				prioItem.pkgFeePerKB, prioItem.priority,
+				highest.pkgFeePerKB, highest.priority)
 		}
 		highest = prioItem
 	}
+
+	// Test trusted items sort before all others with either sort order.
+	trustedItems := []*txPrioItem{
+		{pkgFeePerKB: 0, priority: 0, trusted: true},
+		{pkgFeePerKB: 0, priority: 0, trusted: true},
+	}
+	for _, sortByFee := range []bool{true, false} {
+		priorityQueue = newTxPriorityQueue(len(testItems), sortByFee)
+		for _, prioItem := range append(testItems, trustedItems...) {
+			heap.Push(priorityQueue, prioItem)
+		}
+		for i := 0; i < len(trustedItems); i++ {
+			prioItem := heap.Pop(priorityQueue).(*txPrioItem)
+			if !prioItem.trusted {
+				t.Fatalf("trusted sort (by fee %v): item (fee per "+
+					"KB: %v, priority: %v) before trusted items",
+					sortByFee, prioItem.pkgFeePerKB,
+					prioItem.priority)
+			}
+		}
+	}
 }
 
 // Test_createCoinbaseTx tests that the coinbase is padded to be over the minimum transaction size.
 func Test_createCoinbaseTx(t *testing.T) {
-	coinbaseScript, err := standardCoinbaseScript(584412, 123456789)
+	coinbaseScript, err := standardCoinbaseScript(584412, 123456789, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -123,7 +147,7 @@ func Test_createCoinbaseTx(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	coinbase, err := createCoinbaseTx(&chaincfg.MainNetParams, coinbaseScript[:len(coinbaseScript)-2], 584412, miningAddr)
+	coinbase, err := createCoinbaseTx(&chaincfg.MainNetParams, coinbaseScript[:len(coinbaseScript)-2], 584412, miningAddr, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -132,3 +156,146 @@ func Test_createCoinbaseTx(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestAncestorPackage ensures transaction packages are built from the
+// unincluded ancestors of a transaction in dependency order and that the
+// package fee rate allows a high-fee child to pay for its parent.
+func TestAncestorPackage(t *testing.T) {
+	// Create a chain of transactions where a zero-fee parent is spent by a
+	// child paying a high fee, along with an unrelated transaction paying a
+	// moderate fee.
+	newItem := func(lockTime uint32, fee int64) *txPrioItem {
+		msgTx := wire.NewMsgTx(wire.TxVersion)
+		msgTx.LockTime = lockTime
+		tx := bchutil.NewTx(msgTx)
+		return &txPrioItem{
+			tx:       tx,
+			fee:      fee,
+			size:     250,
+			feePerKB: calcFeePerKB(fee, 250),
+		}
+	}
+	parent := newItem(1, 0)
+	child := newItem(2, 1000)
+	other := newItem(3, 400)
+	child.parents = map[chainhash.Hash]*txPrioItem{*parent.tx.Hash(): nil}
+
+	txns := newTxGraph(3)
+	txns.addItem(parent)
+	txns.addItem(child)
+	txns.addItem(other)
+	txns.link()
+
+	if _, ok := parent.children[*child.tx.Hash()]; !ok {
+		t.Fatal("child was not linked to parent")
+	}
+
+	pkg, ok := ancestorPackage(child)
+	if !ok {
+		t.Fatal("ancestorPackage: unexpected unavailable package")
+	}
+	if len(pkg) != 2 || pkg[0] != parent || pkg[1] != child {
+		t.Fatalf("ancestorPackage: unexpected package order %v", pkg)
+	}
+
+	// The child package must sort before the unrelated transaction even
+	// though the parent pays no fee.
+	for _, item := range []*txPrioItem{parent, child, other} {
+		if !updatePackageFee(item) {
+			t.Fatalf("updatePackageFee: unexpected unavailable tx %v",
+				item.tx.Hash())
+		}
+	}
+	if child.pkgFeePerKB != 2000 {
+		t.Fatalf("unexpected child package fee rate: got %d, want %d",
+			child.pkgFeePerKB, 2000)
+	}
+	pq := newTxPriorityQueue(3, true)
+	heap.Push(pq, parent)
+	heap.Push(pq, child)
+	heap.Push(pq, other)
+	if item := heap.Pop(pq).(*txPrioItem); item != child {
+		t.Fatalf("unexpected first package: got %v, want %v",
+			item.tx.Hash(), child.tx.Hash())
+	}
+
+	// Once the parent is included the child is its own package.
+	parent.included = true
+	pkg, ok = ancestorPackage(child)
+	if !ok || len(pkg) != 1 || pkg[0] != child {
+		t.Fatalf("ancestorPackage: unexpected package after parent "+
+			"included %v", pkg)
+	}
+
+	// A rejected parent makes the child unavailable.
+	parent.included = false
+	parent.rejected = true
+	if _, ok := ancestorPackage(child); ok {
+		t.Fatal("ancestorPackage: expected rejected parent to make " +
+			"package unavailable")
+	}
+
+	// A parent which is missing from the graph makes the child
+	// unavailable.
+	orphan := newItem(4, 1000)
+	orphan.parents = map[chainhash.Hash]*txPrioItem{{0x01}: nil}
+	if updatePackageFee(orphan) {
+		t.Fatal("updatePackageFee: expected missing parent to make " +
+			"package unavailable")
+	}
+}
+
+// TestCoinbaseCommitments ensures the coinbase data is pushed onto the
+// coinbase script, the coinbase outputs are paid from the block subsidy, and
+// coinbase data which could exceed the maximum coinbase script length is
+// rejected.
+func TestCoinbaseCommitments(t *testing.T) {
+	const height = 584412
+	data := []byte("merged mining tag")
+	coinbaseScript, err := standardCoinbaseScript(height, 0, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(coinbaseScript, data) {
+		t.Fatalf("coinbase script %x does not end with the coinbase data",
+			coinbaseScript)
+	}
+
+	commitment := wire.NewTxOut(0, []byte{txscript.OP_RETURN, 0x01, 0x01},
+		wire.TokenData{})
+	poolFee := wire.NewTxOut(1000, []byte{txscript.OP_TRUE}, wire.TokenData{})
+	coinbase, err := createCoinbaseTx(&chaincfg.MainNetParams, coinbaseScript,
+		height, nil, []*wire.TxOut{commitment, poolFee})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txOuts := coinbase.MsgTx().TxOut
+	if len(txOuts) != 3 {
+		t.Fatalf("coinbase has %d outputs, want 3", len(txOuts))
+	}
+	subsidy := blockchain.CalcBlockSubsidy(height, &chaincfg.MainNetParams)
+	if txOuts[0].Value != subsidy-poolFee.Value {
+		t.Fatalf("payout is %d, want %d", txOuts[0].Value,
+			subsidy-poolFee.Value)
+	}
+	if !bytes.Equal(txOuts[1].PkScript, commitment.PkScript) ||
+		txOuts[2].Value != poolFee.Value {
+
+		t.Fatal("coinbase outputs do not match")
+	}
+
+	tooLarge := wire.NewTxOut(subsidy+1, []byte{txscript.OP_TRUE},
+		wire.TokenData{})
+	_, err = createCoinbaseTx(&chaincfg.MainNetParams, coinbaseScript,
+		height, nil, []*wire.TxOut{tooLarge})
+	if err == nil {
+		t.Fatal("coinbase outputs paying more than the subsidy accepted")
+	}
+
+	if err := CheckCoinbaseData("/bchd/", make([]byte, 60)); err != nil {
+		t.Fatalf("CheckCoinbaseData: unexpected error: %v", err)
+	}
+	if err := CheckCoinbaseData("/bchd/", make([]byte, 80)); err == nil {
+		t.Fatal("CheckCoinbaseData: oversized coinbase data accepted")
+	}
+}
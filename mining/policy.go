@@ -5,6 +5,7 @@
 package mining
 
 import (
+	"github.com/gcash/bchd/bchec"
 	"github.com/gcash/bchd/blockchain"
 	"github.com/gcash/bchd/wire"
 	"github.com/gcash/bchutil"
@@ -37,6 +38,23 @@ type Policy struct {
 	// required for a transaction to be treated as free for mining purposes
 	// (block template generation).
 	TxMinFreeFee bchutil.Amount
+
+	// CoinbaseData is pushed onto the signature script of the coinbase of
+	// generated blocks after the coinbase flags.  It allows miners to
+	// commit to data such as a merged mining tag or a pool identifier.
+	// Its size must be checked with CheckCoinbaseData.
+	CoinbaseData []byte
+
+	// CoinbaseOutputs are added to the coinbase of generated blocks after
+	// the output paying the block reward, whose value is reduced by their
+	// values.  They may not pay more than the block subsidy.
+	CoinbaseOutputs []*wire.TxOut
+
+	// SignetKeys are the private keys generated blocks are signed with on
+	// signed test networks.  The signatures of all keys which are part of
+	// the signet challenge are added, so blocks are co-signed when the
+	// challenge requires signatures of several keys.
+	SignetKeys []*bchec.PrivateKey
 }
 
 // calcInputValueAge is a helper function used to calculate the input age of
@@ -0,0 +1,122 @@
+package mining
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/gcash/bchd/bchec"
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// signetHashType is the signature hash type blocks are signed with.
+const signetHashType = txscript.SigHashAll | txscript.SigHashForkID
+
+// errNoSignetKey is returned by the key lookup of SignetKeyDB for addresses
+// none of the keys belongs to.
+var errNoSignetKey = errors.New("no signet key for address")
+
+// SignetKeyDB returns a key database which looks up the passed private keys by
+// their public key or the hash of their public key.  It is meant to be passed to
+// SignBlock.
+func SignetKeyDB(keys []*bchec.PrivateKey) txscript.KeyDB {
+	return txscript.KeyClosure(func(addr bchutil.Address) (*bchec.PrivateKey, bool, error) {
+		scriptAddr := addr.ScriptAddress()
+		for _, key := range keys {
+			compressed := key.PubKey().SerializeCompressed()
+			uncompressed := key.PubKey().SerializeUncompressed()
+			switch {
+			case bytes.Equal(scriptAddr, compressed),
+				bytes.Equal(scriptAddr, bchutil.Hash160(compressed)):
+				return key, true, nil
+			case bytes.Equal(scriptAddr, uncompressed),
+				bytes.Equal(scriptAddr, bchutil.Hash160(uncompressed)):
+				return key, false, nil
+			}
+		}
+		return nil, false, errNoSignetKey
+	})
+}
+
+// AddSignetCommitment adds the coinbase output committing to the signature
+// solution of the block to the passed coinbase, unless it already has one.  The
+// commitment is created with an empty solution.
+func AddSignetCommitment(coinbase *wire.MsgTx) error {
+	if idx, _ := blockchain.SignetCommitment(coinbase); idx >= 0 {
+		return nil
+	}
+	pkScript, err := blockchain.SignetCommitmentScript(nil)
+	if err != nil {
+		return err
+	}
+	coinbase.AddTxOut(wire.NewTxOut(0, pkScript, wire.TokenData{}))
+	return nil
+}
+
+// SignBlock adds the signatures of the keys looked up with the passed key and
+// script databases to the signature solution of the passed block on the signed
+// test network with the passed parameters, and updates the merkle root of the
+// block accordingly.  The signatures are merged with the signatures already in
+// the solution, so several signers can co-sign a block one after another when
+// the signet challenge requires signatures of several keys.  The block must
+// not change between the signers except for its nonce and bits, or else the
+// signatures added before become invalid.
+func SignBlock(block *wire.MsgBlock, params *chaincfg.Params, kdb txscript.KeyDB,
+	sdb txscript.ScriptDB) error {
+
+	if params.SignetChallenge == nil {
+		return errors.New("network does not require signed blocks")
+	}
+	if sdb == nil {
+		sdb = txscript.ScriptClosure(func(bchutil.Address) ([]byte, error) {
+			return nil, errors.New("no script database")
+		})
+	}
+	toSign, err := blockchain.SignetSigningTx(block, params.SignetChallenge)
+	if err != nil {
+		return err
+	}
+	coinbase := block.Transactions[0]
+	idx, prevSolution := blockchain.SignetCommitment(coinbase)
+
+	solution, err := txscript.SignTxOutput(params, toSign, 0, 0,
+		params.SignetChallenge, signetHashType, kdb, sdb, prevSolution)
+	if err != nil {
+		return err
+	}
+	pkScript, err := blockchain.SignetCommitmentScript(solution)
+	if err != nil {
+		return err
+	}
+	coinbase.TxOut[idx].PkScript = pkScript
+
+	// Recalculate the merkle root with the updated solution.
+	merkles := blockchain.BuildMerkleTreeStore(bchutil.NewBlock(block).Transactions())
+	block.Header.MerkleRoot = *merkles[len(merkles)-1]
+	return nil
+}
+
+// signBlock signs the passed block with the signet keys of the policy of the
+// generator.  Signatures from before the block was modified are discarded.  It
+// does nothing when the network does not require signed blocks or the policy
+// has no signet keys, in which case the block has to be signed externally.
+func (g *BlkTmplGenerator) signBlock(msgBlock *wire.MsgBlock) error {
+	if g.chainParams.SignetChallenge == nil || len(g.policy.SignetKeys) == 0 {
+		return nil
+	}
+	coinbase := msgBlock.Transactions[0]
+	idx, _ := blockchain.SignetCommitment(coinbase)
+	if idx < 0 {
+		return errors.New("coinbase does not commit to a signet solution")
+	}
+	pkScript, err := blockchain.SignetCommitmentScript(nil)
+	if err != nil {
+		return err
+	}
+	coinbase.TxOut[idx].PkScript = pkScript
+	return SignBlock(msgBlock, g.chainParams, SignetKeyDB(g.policy.SignetKeys),
+		nil)
+}
@@ -0,0 +1,101 @@
+package mining
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gcash/bchd/bchec"
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// TestSignBlock ensures blocks are signed for multisig challenges by
+// co-signing them with SignBlock.
+func TestSignBlock(t *testing.T) {
+	keys := make([]*bchec.PrivateKey, 2)
+	for i := range keys {
+		key, err := bchec.NewPrivateKey(bchec.S256())
+		if err != nil {
+			t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+		}
+		keys[i] = key
+	}
+	challenge, err := txscript.NewScriptBuilder().AddOp(txscript.OP_2).
+		AddData(keys[0].PubKey().SerializeCompressed()).
+		AddData(keys[1].PubKey().SerializeCompressed()).
+		AddOp(txscript.OP_2).AddOp(txscript.OP_CHECKMULTISIG).Script()
+	if err != nil {
+		t.Fatalf("unexpected error building challenge: %v", err)
+	}
+	params := chaincfg.CustomSignetParams(challenge, nil)
+
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		SignatureScript: []byte{0x51, 0x51},
+	})
+	coinbase.AddTxOut(wire.NewTxOut(5000000000, []byte{txscript.OP_TRUE},
+		wire.TokenData{}))
+	if err := AddSignetCommitment(coinbase); err != nil {
+		t.Fatalf("AddSignetCommitment: unexpected error: %v", err)
+	}
+	if err := AddSignetCommitment(coinbase); err != nil {
+		t.Fatalf("AddSignetCommitment: unexpected error: %v", err)
+	}
+	if len(coinbase.TxOut) != 2 {
+		t.Fatalf("AddSignetCommitment: got %d outputs, want 2",
+			len(coinbase.TxOut))
+	}
+	block := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   4,
+			PrevBlock: *params.GenesisHash,
+			Timestamp: time.Unix(1700000000, 0),
+			Bits:      params.PowLimitBits,
+		},
+		Transactions: []*wire.MsgTx{coinbase},
+	}
+
+	// A key which is not part of the challenge cannot sign the block.
+	other, err := bchec.NewPrivateKey(bchec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	err = SignBlock(block, &params, SignetKeyDB([]*bchec.PrivateKey{other}), nil)
+	if err != nil {
+		t.Fatalf("SignBlock: unexpected error: %v", err)
+	}
+	err = blockchain.CheckSignetSolution(block, challenge)
+	if err == nil {
+		t.Fatal("CheckSignetSolution: accepted block signed with unknown key")
+	}
+
+	// A single signature does not satisfy the challenge.
+	err = SignBlock(block, &params, SignetKeyDB(keys[:1]), nil)
+	if err != nil {
+		t.Fatalf("SignBlock: unexpected error: %v", err)
+	}
+	err = blockchain.CheckSignetSolution(block, challenge)
+	if err == nil {
+		t.Fatal("CheckSignetSolution: accepted block with one signature")
+	}
+
+	// The second signer adds its signature to the first one.
+	err = SignBlock(block, &params, SignetKeyDB(keys[1:]), nil)
+	if err != nil {
+		t.Fatalf("SignBlock: unexpected error: %v", err)
+	}
+	if err := blockchain.CheckSignetSolution(block, challenge); err != nil {
+		t.Fatalf("CheckSignetSolution: unexpected error: %v", err)
+	}
+	merkles := blockchain.BuildMerkleTreeStore(
+		bchutil.NewBlock(block).Transactions())
+	if block.Header.MerkleRoot != *merkles[len(merkles)-1] {
+		t.Fatal("SignBlock: merkle root not updated")
+	}
+}
@@ -0,0 +1,469 @@
+package stratum
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gcash/bchd/blockchain"
+)
+
+const (
+	// maxRequestSize is the maximum size of a single line sent by a client.
+	maxRequestSize = 1 << 14
+
+	// readTimeout is the amount of time a client may stay idle before it is
+	// disconnected.
+	readTimeout = 10 * time.Minute
+
+	// writeTimeout is the maximum amount of time allowed to write a single
+	// message to a client.
+	writeTimeout = 30 * time.Second
+
+	// targetShareInterval is the desired average amount of time in between
+	// shares from a single client.
+	targetShareInterval = 10 * time.Second
+
+	// retargetInterval is the amount of time in between share difficulty
+	// adjustments for a client.
+	retargetInterval = 90 * time.Second
+
+	// retargetShares is the number of shares which causes an early share
+	// difficulty adjustment so clients with a difficulty far too low for
+	// their hash rate are adjusted quickly.
+	retargetShares = 30
+
+	// maxRetargetFactor is the maximum factor the share difficulty of a
+	// client is changed by in a single adjustment.
+	maxRetargetFactor = 4.0
+
+	// retargetThreshold is the minimum relative change in difficulty which
+	// causes a new difficulty to be sent to the client.
+	retargetThreshold = 0.1
+)
+
+// Stratum error codes as used by the common pool software.
+const (
+	errCodeOther          = 20
+	errCodeJobNotFound    = 21
+	errCodeDuplicateShare = 22
+	errCodeLowDifficulty  = 23
+	errCodeUnauthorized   = 24
+	errCodeNotSubscribed  = 25
+)
+
+// request is a message sent from a client to the server.
+type request struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// response is a reply from the server to a request.
+type response struct {
+	ID     json.RawMessage `json:"id"`
+	Result interface{}     `json:"result"`
+	Error  interface{}     `json:"error"`
+}
+
+// notification is a message sent from the server to a client which does not
+// expect a reply.
+type notification struct {
+	ID     interface{}   `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+// stratumError returns the error representation used by stratum for the passed
+// code and message.
+func stratumError(code int, message string) []interface{} {
+	return []interface{}{code, message, nil}
+}
+
+// client houses the state of a single stratum connection.
+type client struct {
+	server      *Server
+	conn        net.Conn
+	extraNonce1 []byte
+
+	writeMtx sync.Mutex
+
+	// The following fields are only accessed by the client handler, with
+	// the exception of subscribed and the difficulty fields which are also
+	// read when notifying the client of a new job.
+	mtx            sync.Mutex
+	subscribed     bool
+	authorized     bool
+	worker         string
+	difficulty     float64
+	prevDifficulty float64
+	lastRetarget   time.Time
+	retargetShares int
+	acceptedShares uint64
+	rejectedShares uint64
+}
+
+// newClient returns a new client for the passed connection using the passed
+// value as its extra nonce.
+func newClient(s *Server, conn net.Conn, extraNonce1 uint32) *client {
+	en1 := make([]byte, extraNonce1Size)
+	binary.BigEndian.PutUint32(en1, extraNonce1)
+	return &client{
+		server:         s,
+		conn:           conn,
+		extraNonce1:    en1,
+		difficulty:     s.cfg.MinDifficulty,
+		prevDifficulty: s.cfg.MinDifficulty,
+		lastRetarget:   time.Now(),
+	}
+}
+
+// handler reads and processes requests from the client until the connection
+// is closed.  It must be run as a goroutine.
+func (c *client) handler() {
+	log.Debugf("New stratum client %s", c.conn.RemoteAddr())
+
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 0, 1024), maxRequestSize)
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(readTimeout))
+		if !scanner.Scan() {
+			break
+		}
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			log.Debugf("Malformed stratum request from %s: %v",
+				c.conn.RemoteAddr(), err)
+			break
+		}
+		c.handleRequest(&req)
+	}
+
+	c.disconnect()
+	c.server.removeClient(c)
+	c.mtx.Lock()
+	log.Debugf("Stratum client %s (worker %q) disconnected after %d "+
+		"accepted and %d rejected shares", c.conn.RemoteAddr(), c.worker,
+		c.acceptedShares, c.rejectedShares)
+	c.mtx.Unlock()
+	c.server.wg.Done()
+}
+
+// disconnect closes the connection of the client.
+func (c *client) disconnect() {
+	c.conn.Close()
+}
+
+// send writes the passed message to the client as a single line of JSON.
+func (c *client) send(msg interface{}) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		log.Errorf("Failed to marshal stratum message: %v", err)
+		return
+	}
+	b = append(b, '\n')
+
+	c.writeMtx.Lock()
+	defer c.writeMtx.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := c.conn.Write(b); err != nil {
+		log.Debugf("Failed to write to stratum client %s: %v",
+			c.conn.RemoteAddr(), err)
+		c.disconnect()
+	}
+}
+
+// reply sends a response to the passed request.
+func (c *client) reply(req *request, result interface{}, err []interface{}) {
+	resp := response{ID: req.ID, Result: result}
+	if err != nil {
+		resp.Error = err
+	}
+	c.send(&resp)
+}
+
+// notify sends the passed job to the client if it has subscribed.  Any pending
+// share difficulty change is sent along with it.
+func (c *client) notify(j *job, cleanJobs bool) {
+	c.mtx.Lock()
+	if !c.subscribed {
+		c.mtx.Unlock()
+		return
+	}
+	c.retarget()
+	difficulty := c.difficulty
+	c.mtx.Unlock()
+
+	c.send(&notification{
+		Method: "mining.set_difficulty",
+		Params: []interface{}{difficulty},
+	})
+	c.send(&notification{
+		Method: "mining.notify",
+		Params: j.notifyParams(cleanJobs),
+	})
+}
+
+// handleRequest dispatches the passed request to the appropriate handler.
+func (c *client) handleRequest(req *request) {
+	switch req.Method {
+	case "mining.subscribe":
+		c.handleSubscribe(req)
+	case "mining.authorize":
+		c.handleAuthorize(req)
+	case "mining.submit":
+		c.handleSubmit(req)
+	case "mining.configure":
+		// No extensions such as version rolling are supported.
+		c.reply(req, map[string]interface{}{}, nil)
+	case "mining.extranonce.subscribe":
+		// The extra nonce of a client never changes.
+		c.reply(req, true, nil)
+	default:
+		c.reply(req, nil, stratumError(errCodeOther,
+			fmt.Sprintf("unknown method %q", req.Method)))
+	}
+}
+
+// handleSubscribe handles the mining.subscribe request.
+func (c *client) handleSubscribe(req *request) {
+	c.mtx.Lock()
+	c.subscribed = true
+	difficulty := c.difficulty
+	c.mtx.Unlock()
+
+	subscriptionID := hex.EncodeToString(c.extraNonce1)
+	c.reply(req, []interface{}{
+		[][]string{
+			{"mining.set_difficulty", subscriptionID},
+			{"mining.notify", subscriptionID},
+		},
+		hex.EncodeToString(c.extraNonce1),
+		extraNonce2Size,
+	}, nil)
+
+	c.send(&notification{
+		Method: "mining.set_difficulty",
+		Params: []interface{}{difficulty},
+	})
+	if j := c.server.clientSubscribed(); j != nil {
+		c.send(&notification{
+			Method: "mining.notify",
+			Params: j.notifyParams(true),
+		})
+	}
+}
+
+// handleAuthorize handles the mining.authorize request.
+func (c *client) handleAuthorize(req *request) {
+	var worker, password string
+	if len(req.Params) > 0 {
+		json.Unmarshal(req.Params[0], &worker)
+	}
+	if len(req.Params) > 1 {
+		json.Unmarshal(req.Params[1], &password)
+	}
+
+	if c.server.cfg.Password != "" && password != c.server.cfg.Password {
+		log.Warnf("Stratum authorization failed for worker %q from %s",
+			worker, c.conn.RemoteAddr())
+		c.reply(req, false, stratumError(errCodeUnauthorized,
+			"unauthorized worker"))
+		return
+	}
+
+	c.mtx.Lock()
+	c.authorized = true
+	c.worker = worker
+	c.mtx.Unlock()
+	log.Infof("Stratum worker %q authorized from %s", worker,
+		c.conn.RemoteAddr())
+	c.reply(req, true, nil)
+}
+
+// parseHexUint32 parses a big-endian hex encoded 32-bit value as sent by
+// stratum clients for the timestamp and nonce of a share.
+func parseHexUint32(s string) (uint32, error) {
+	v, err := strconv.ParseUint(s, 16, 32)
+	return uint32(v), err
+}
+
+// handleSubmit handles the mining.submit request by validating the share and
+// submitting the resulting block when it also satisfies the network target.
+func (c *client) handleSubmit(req *request) {
+	c.mtx.Lock()
+	subscribed, authorized := c.subscribed, c.authorized
+	c.mtx.Unlock()
+	if !subscribed {
+		c.reply(req, false, stratumError(errCodeNotSubscribed,
+			"not subscribed"))
+		return
+	}
+	if !authorized {
+		c.reply(req, false, stratumError(errCodeUnauthorized,
+			"unauthorized worker"))
+		return
+	}
+
+	var params [5]string
+	if len(req.Params) < len(params) {
+		c.reject(req, errCodeOther, "invalid parameters")
+		return
+	}
+	for i := range params {
+		if err := json.Unmarshal(req.Params[i], &params[i]); err != nil {
+			c.reject(req, errCodeOther, "invalid parameters")
+			return
+		}
+	}
+	jobID, extraNonce2Hex, nTimeHex, nonceHex := params[1], params[2],
+		params[3], params[4]
+
+	j := c.server.lookupJob(jobID)
+	if j == nil {
+		c.reject(req, errCodeJobNotFound, "job not found")
+		return
+	}
+	extraNonce2, err := hex.DecodeString(extraNonce2Hex)
+	if err != nil || len(extraNonce2) != extraNonce2Size {
+		c.reject(req, errCodeOther, "invalid extranonce2")
+		return
+	}
+	nTime, err := parseHexUint32(nTimeHex)
+	if err != nil {
+		c.reject(req, errCodeOther, "invalid ntime")
+		return
+	}
+	if t := time.Unix(int64(nTime), 0); t.Before(j.minTime) ||
+		t.After(j.created.Add(maxTimeOffset)) {
+
+		c.reject(req, errCodeOther, "ntime out of range")
+		return
+	}
+	nonce, err := parseHexUint32(nonceHex)
+	if err != nil {
+		c.reject(req, errCodeOther, "invalid nonce")
+		return
+	}
+
+	key := hex.EncodeToString(c.extraNonce1) + extraNonce2Hex + nTimeHex +
+		nonceHex
+	if c.server.recordSubmission(j, key) {
+		c.reject(req, errCodeDuplicateShare, "duplicate share")
+		return
+	}
+
+	header, coinbase, err := j.header(c.extraNonce1, extraNonce2, nTime,
+		nonce)
+	if err != nil {
+		c.reject(req, errCodeOther, "invalid coinbase")
+		return
+	}
+	hash := header.BlockHash()
+	hashNum := blockchain.HashToBig(&hash)
+
+	// Shares are accepted at the previous difficulty as well since the
+	// client may have been working on a share when the difficulty changed.
+	c.mtx.Lock()
+	difficulty := min(c.difficulty, c.prevDifficulty)
+	c.mtx.Unlock()
+	if hashNum.Cmp(difficultyToTarget(difficulty)) > 0 {
+		c.reject(req, errCodeLowDifficulty, "low difficulty share")
+		return
+	}
+
+	if hashNum.Cmp(blockchain.CompactToBig(j.bits)) <= 0 {
+		log.Infof("Stratum worker %q found block %s at height %d",
+			params[0], hash, j.height)
+		c.server.submitBlock(j.block(header, coinbase))
+	} else if c.server.cfg.ProcessWeakBlock != nil &&
+		hashNum.Cmp(blockchain.CalcWeakBlockTarget(j.bits)) <= 0 {
+
+		log.Debugf("Stratum worker %q found weak block %s at height %d",
+			params[0], hash, j.height)
+		c.server.cfg.ProcessWeakBlock(j.block(header, coinbase).MsgBlock())
+	}
+
+	c.mtx.Lock()
+	c.acceptedShares++
+	c.retargetShares++
+	retarget := c.retarget()
+	difficulty = c.difficulty
+	c.mtx.Unlock()
+
+	c.reply(req, true, nil)
+	if retarget {
+		c.send(&notification{
+			Method: "mining.set_difficulty",
+			Params: []interface{}{difficulty},
+		})
+	}
+}
+
+// reject replies to the passed share submission with the passed error and
+// tracks the rejection.
+func (c *client) reject(req *request, code int, message string) {
+	c.mtx.Lock()
+	c.rejectedShares++
+	worker := c.worker
+	c.mtx.Unlock()
+	log.Debugf("Rejected share from stratum worker %q: %s", worker,
+		message)
+	c.reply(req, false, stratumError(code, message))
+}
+
+// retarget adjusts the share difficulty of the client when enough time has
+// elapsed or enough shares have been submitted since the previous adjustment.
+// It returns whether the difficulty was changed.
+//
+// This function MUST be called with the client mutex held.
+func (c *client) retarget() bool {
+	elapsed := time.Since(c.lastRetarget)
+	if elapsed < retargetInterval && c.retargetShares < retargetShares {
+		return false
+	}
+
+	newDifficulty := calcVarDiff(c.difficulty, c.retargetShares, elapsed,
+		c.server.cfg.MinDifficulty)
+	c.lastRetarget = time.Now()
+	c.retargetShares = 0
+
+	change := newDifficulty/c.difficulty - 1
+	if change < retargetThreshold && change > -retargetThreshold {
+		return false
+	}
+	log.Debugf("Adjusting share difficulty of stratum worker %q from "+
+		"%v to %v", c.worker, c.difficulty, newDifficulty)
+	c.prevDifficulty = c.difficulty
+	c.difficulty = newDifficulty
+	return true
+}
+
+// calcVarDiff returns the share difficulty that results in shares arriving at
+// the target share interval given the number of shares submitted at the passed
+// difficulty over the passed duration.  The change is limited to a factor of
+// maxRetargetFactor and the result is never below the passed minimum.
+func calcVarDiff(difficulty float64, shares int, elapsed time.Duration,
+	minDifficulty float64) float64 {
+
+	var factor float64
+	if shares == 0 {
+		factor = 1 / maxRetargetFactor
+	} else {
+		interval := elapsed / time.Duration(shares)
+		factor = float64(targetShareInterval) / float64(interval)
+	}
+	factor = max(min(factor, maxRetargetFactor), 1/maxRetargetFactor)
+	return max(difficulty*factor, minDifficulty)
+}
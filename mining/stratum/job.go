@@ -0,0 +1,277 @@
+package stratum
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/mining"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+const (
+	// extraNonce1Size is the number of bytes of the extra nonce which are
+	// assigned to each client by the server.
+	extraNonce1Size = 4
+
+	// extraNonce2Size is the number of bytes of the extra nonce which are
+	// rolled by the mining hardware.
+	extraNonce2Size = 4
+
+	// extraNonceSize is the total size of the extra nonce placeholder in
+	// the coinbase script.
+	extraNonceSize = extraNonce1Size + extraNonce2Size
+
+	// maxTimeOffset is the maximum amount of time a submitted share
+	// timestamp may be ahead of the time the job was created.  It mirrors
+	// the maximum allowed block timestamp offset of the consensus rules.
+	maxTimeOffset = 2 * time.Hour
+)
+
+var (
+	// diff1Target is the target which represents a share difficulty of
+	// one using the conventional pool difficulty definition.
+	diff1Target = new(big.Int).Lsh(big.NewInt(0xffff), 208)
+)
+
+// job houses a unit of work derived from a block template which is handed out
+// to the stratum clients.  The coinbase transaction is split around the extra
+// nonce so that each client is able to produce unique work.
+type job struct {
+	id           string
+	template     *mining.BlockTemplate
+	height       int32
+	prevHash     chainhash.Hash
+	coinbase1    []byte
+	coinbase2    []byte
+	merkleBranch []*chainhash.Hash
+	version      int32
+	bits         uint32
+	timestamp    time.Time
+	minTime      time.Time
+	created      time.Time
+
+	// submissions tracks the shares which have been submitted for the job
+	// in order to reject duplicates.  It is protected by the server mutex.
+	submissions map[string]struct{}
+}
+
+// coinbaseScript returns the signature script for the coinbase of a stratum
+// job.  It consists of the block height followed by a push of the zeroed
+// extra nonce placeholder, the coinbase flags, and the passed coinbase data
+// when there is any.  The returned offset is the position of the extra nonce
+// placeholder within the script.
+func coinbaseScript(height int32, coinbaseData []byte) ([]byte, int, error) {
+	prefix, err := txscript.NewScriptBuilder().AddInt64(int64(height)).Script()
+	if err != nil {
+		return nil, 0, err
+	}
+	builder := txscript.NewScriptBuilder().AddData([]byte(mining.CoinbaseFlags))
+	if len(coinbaseData) > 0 {
+		builder.AddData(coinbaseData)
+	}
+	suffix, err := builder.Script()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	script := make([]byte, 0, len(prefix)+1+extraNonceSize+len(suffix))
+	script = append(script, prefix...)
+	script = append(script, txscript.OP_DATA_8)
+	offset := len(script)
+	script = append(script, make([]byte, extraNonceSize)...)
+	script = append(script, suffix...)
+	return script, offset, nil
+}
+
+// merkleBranch returns the hashes needed to calculate the merkle root of a
+// block from the hash of its coinbase transaction.  The passed hashes are the
+// hashes of all of the transactions in the block except the coinbase.
+func merkleBranch(txHashes []*chainhash.Hash) []*chainhash.Hash {
+	var branch []*chainhash.Hash
+	level := txHashes
+	for len(level) > 0 {
+		// The first hash is always paired with the hash that leads
+		// back to the coinbase, so it is part of the branch while the
+		// remaining hashes are combined to form the next level.  A
+		// lone hash at the end is paired with itself.
+		branch = append(branch, level[0])
+		rest := level[1:]
+		next := make([]*chainhash.Hash, 0, (len(rest)+1)/2)
+		for i := 0; i < len(rest); i += 2 {
+			right := rest[i]
+			if i+1 < len(rest) {
+				right = rest[i+1]
+			}
+			next = append(next, blockchain.HashMerkleBranches(rest[i], right))
+		}
+		level = next
+	}
+	return branch
+}
+
+// merkleRoot calculates the merkle root from the passed coinbase hash and
+// merkle branch.
+func merkleRoot(coinbaseHash *chainhash.Hash, branch []*chainhash.Hash) *chainhash.Hash {
+	root := coinbaseHash
+	for _, hash := range branch {
+		root = blockchain.HashMerkleBranches(root, hash)
+	}
+	return root
+}
+
+// newJob creates a new job from the passed block template.  The coinbase of
+// the template is replaced by one which contains an extra nonce placeholder
+// that is split out of the serialized transaction, followed by the passed
+// coinbase data.
+func newJob(id string, template *mining.BlockTemplate, minTime time.Time,
+	coinbaseData []byte) (*job, error) {
+
+	msgBlock := template.Block
+	if len(msgBlock.Transactions) == 0 {
+		return nil, errors.New("block template has no coinbase")
+	}
+
+	script, offset, err := coinbaseScript(template.Height, coinbaseData)
+	if err != nil {
+		return nil, err
+	}
+	coinbase := msgBlock.Transactions[0].Copy()
+	coinbase.TxIn[0].SignatureScript = script
+	if size := coinbase.SerializeSize(); size < blockchain.MinTransactionSize {
+		coinbase.TxIn[0].SignatureScript = append(script,
+			make([]byte, blockchain.MinTransactionSize-size)...)
+	}
+	if len(coinbase.TxIn[0].SignatureScript) > blockchain.MaxCoinbaseScriptLen {
+		return nil, fmt.Errorf("coinbase transaction script length "+
+			"of %d is out of range (max: %d)",
+			len(coinbase.TxIn[0].SignatureScript),
+			blockchain.MaxCoinbaseScriptLen)
+	}
+
+	var buf bytes.Buffer
+	if err := coinbase.Serialize(&buf); err != nil {
+		return nil, err
+	}
+	serialized := buf.Bytes()
+
+	// The extra nonce placeholder is located after the version, input
+	// count, previous outpoint, and script length of the single coinbase
+	// input.
+	scriptLen := uint64(len(coinbase.TxIn[0].SignatureScript))
+	start := 4 + wire.VarIntSerializeSize(1) + chainhash.HashSize + 4 +
+		wire.VarIntSerializeSize(scriptLen) + offset
+
+	txHashes := make([]*chainhash.Hash, 0, len(msgBlock.Transactions)-1)
+	for _, tx := range msgBlock.Transactions[1:] {
+		hash := tx.TxHash()
+		txHashes = append(txHashes, &hash)
+	}
+
+	return &job{
+		id:           id,
+		template:     template,
+		height:       template.Height,
+		prevHash:     msgBlock.Header.PrevBlock,
+		coinbase1:    serialized[:start],
+		coinbase2:    serialized[start+extraNonceSize:],
+		merkleBranch: merkleBranch(txHashes),
+		version:      msgBlock.Header.Version,
+		bits:         msgBlock.Header.Bits,
+		timestamp:    msgBlock.Header.Timestamp,
+		minTime:      minTime,
+		created:      time.Now(),
+		submissions:  make(map[string]struct{}),
+	}, nil
+}
+
+// stratumPrevHash returns the previous block hash of the job in the encoding
+// expected by stratum clients, which is the internal byte order of the hash
+// with each 32-bit word byte swapped.
+func (j *job) stratumPrevHash() string {
+	var swapped [chainhash.HashSize]byte
+	for i := 0; i < chainhash.HashSize; i += 4 {
+		word := binary.LittleEndian.Uint32(j.prevHash[i : i+4])
+		binary.BigEndian.PutUint32(swapped[i:i+4], word)
+	}
+	return hex.EncodeToString(swapped[:])
+}
+
+// notifyParams returns the parameters of a mining.notify message for the job.
+func (j *job) notifyParams(cleanJobs bool) []interface{} {
+	branch := make([]string, 0, len(j.merkleBranch))
+	for _, hash := range j.merkleBranch {
+		branch = append(branch, hex.EncodeToString(hash[:]))
+	}
+	return []interface{}{
+		j.id,
+		j.stratumPrevHash(),
+		hex.EncodeToString(j.coinbase1),
+		hex.EncodeToString(j.coinbase2),
+		branch,
+		fmt.Sprintf("%08x", uint32(j.version)),
+		fmt.Sprintf("%08x", j.bits),
+		fmt.Sprintf("%08x", uint32(j.timestamp.Unix())),
+		cleanJobs,
+	}
+}
+
+// header returns the block header and coinbase transaction which result from
+// the passed extra nonces, timestamp, and nonce.
+func (j *job) header(extraNonce1, extraNonce2 []byte, nTime, nonce uint32) (*wire.BlockHeader, *wire.MsgTx, error) {
+	serialized := make([]byte, 0, len(j.coinbase1)+extraNonceSize+
+		len(j.coinbase2))
+	serialized = append(serialized, j.coinbase1...)
+	serialized = append(serialized, extraNonce1...)
+	serialized = append(serialized, extraNonce2...)
+	serialized = append(serialized, j.coinbase2...)
+
+	var coinbase wire.MsgTx
+	if err := coinbase.Deserialize(bytes.NewReader(serialized)); err != nil {
+		return nil, nil, err
+	}
+	coinbaseHash := coinbase.TxHash()
+
+	return &wire.BlockHeader{
+		Version:    j.version,
+		PrevBlock:  j.prevHash,
+		MerkleRoot: *merkleRoot(&coinbaseHash, j.merkleBranch),
+		Timestamp:  time.Unix(int64(nTime), 0),
+		Bits:       j.bits,
+		Nonce:      nonce,
+	}, &coinbase, nil
+}
+
+// block returns the full block which results from the passed header and
+// coinbase transaction.
+func (j *job) block(header *wire.BlockHeader, coinbase *wire.MsgTx) *bchutil.Block {
+	txns := j.template.Block.Transactions
+	msgBlock := &wire.MsgBlock{
+		Header:       *header,
+		Transactions: make([]*wire.MsgTx, 0, len(txns)),
+	}
+	msgBlock.Transactions = append(msgBlock.Transactions, coinbase)
+	msgBlock.Transactions = append(msgBlock.Transactions, txns[1:]...)
+	block := bchutil.NewBlock(msgBlock)
+	block.SetHeight(j.height)
+	return block
+}
+
+// difficultyToTarget converts the passed share difficulty to the target a
+// share hash must not exceed.
+func difficultyToTarget(difficulty float64) *big.Int {
+	if difficulty <= 0 {
+		return new(big.Int).Set(diff1Target)
+	}
+	target, _ := new(big.Float).Quo(new(big.Float).SetInt(diff1Target),
+		big.NewFloat(difficulty)).Int(nil)
+	return target
+}
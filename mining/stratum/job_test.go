@@ -0,0 +1,151 @@
+package stratum
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/mining"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// newTestTemplate returns a block template with a coinbase and the passed
+// number of additional transactions.
+func newTestTemplate(numTxns int) *mining.BlockTemplate {
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		SignatureScript: []byte{0x01, 0x02},
+		Sequence:        wire.MaxTxInSequenceNum,
+	})
+	coinbase.AddTxOut(&wire.TxOut{Value: 5000000000, PkScript: []byte{0x51}})
+
+	msgBlock := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   0x20000000,
+			PrevBlock: chainhash.Hash{0x01, 0x02, 0x03, 0x04},
+			Timestamp: time.Unix(1600000000, 0),
+			Bits:      0x207fffff,
+		},
+	}
+	msgBlock.AddTransaction(coinbase)
+	for i := 0; i < numTxns; i++ {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.LockTime = uint32(i)
+		msgBlock.AddTransaction(tx)
+	}
+	return &mining.BlockTemplate{Block: msgBlock, Height: 1000}
+}
+
+// TestMerkleBranch ensures the merkle branch of the coinbase produces the same
+// merkle root as the full merkle tree for various transaction counts.
+func TestMerkleBranch(t *testing.T) {
+	for numTxns := 0; numTxns < 12; numTxns++ {
+		block := bchutil.NewBlock(newTestTemplate(numTxns).Block)
+		txns := block.Transactions()
+		merkles := blockchain.BuildMerkleTreeStore(txns)
+		want := merkles[len(merkles)-1]
+
+		hashes := make([]*chainhash.Hash, 0, len(txns)-1)
+		for _, tx := range txns[1:] {
+			hashes = append(hashes, tx.Hash())
+		}
+		got := merkleRoot(txns[0].Hash(), merkleBranch(hashes))
+		if !got.IsEqual(want) {
+			t.Errorf("merkle root mismatch with %d transactions: "+
+				"got %v, want %v", numTxns+1, got, want)
+		}
+	}
+}
+
+// TestJobHeader ensures the coinbase of a job is split around the extra nonce
+// and the header built from a share commits to the reassembled coinbase.
+func TestJobHeader(t *testing.T) {
+	template := newTestTemplate(5)
+	j, err := newJob("1", template, time.Unix(1600000000, 0), nil)
+	if err != nil {
+		t.Fatalf("newJob: %v", err)
+	}
+
+	extraNonce1 := []byte{0xde, 0xad, 0xbe, 0xef}
+	extraNonce2 := []byte{0x01, 0x02, 0x03, 0x04}
+	header, coinbase, err := j.header(extraNonce1, extraNonce2, 1600000100, 42)
+	if err != nil {
+		t.Fatalf("header: %v", err)
+	}
+
+	script := coinbase.TxIn[0].SignatureScript
+	extraNonce := append(append([]byte{}, extraNonce1...), extraNonce2...)
+	if !bytes.Contains(script, extraNonce) {
+		t.Fatalf("coinbase script %x does not contain extra nonce %x",
+			script, extraNonce)
+	}
+	if coinbase.SerializeSize() < blockchain.MinTransactionSize {
+		t.Fatalf("coinbase size %d is below the minimum",
+			coinbase.SerializeSize())
+	}
+	if coinbase.TxOut[0].Value != template.Block.Transactions[0].TxOut[0].Value {
+		t.Fatal("coinbase output does not match the template")
+	}
+
+	block := j.block(header, coinbase)
+	merkles := blockchain.BuildMerkleTreeStore(block.Transactions())
+	if !header.MerkleRoot.IsEqual(merkles[len(merkles)-1]) {
+		t.Fatalf("header merkle root %v does not match block %v",
+			header.MerkleRoot, merkles[len(merkles)-1])
+	}
+	if header.Nonce != 42 || header.Timestamp.Unix() != 1600000100 ||
+		header.PrevBlock != template.Block.Header.PrevBlock {
+
+		t.Fatalf("unexpected header nonce %d, timestamp %v, prev "+
+			"block %v", header.Nonce, header.Timestamp,
+			header.PrevBlock)
+	}
+
+	// The previous block hash is sent with each 32-bit word swapped.
+	if got := j.stratumPrevHash()[:8]; got != "04030201" {
+		t.Fatalf("unexpected stratum prev hash prefix %s", got)
+	}
+}
+
+// TestCalcVarDiff ensures share difficulty adjustments move towards the target
+// share interval and are bounded.
+func TestCalcVarDiff(t *testing.T) {
+	tests := []struct {
+		name       string
+		difficulty float64
+		shares     int
+		elapsed    time.Duration
+		min        float64
+		want       float64
+	}{
+		{"on target", 1024, 9, 90 * time.Second, 1, 1024},
+		{"twice as fast", 1024, 18, 90 * time.Second, 1, 2048},
+		{"far too fast", 1024, 30, time.Second, 1, 4096},
+		{"no shares", 1024, 0, 90 * time.Second, 1, 256},
+		{"minimum", 1024, 0, 90 * time.Second, 512, 512},
+	}
+	for _, test := range tests {
+		got := calcVarDiff(test.difficulty, test.shares, test.elapsed,
+			test.min)
+		if got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// TestDifficultyToTarget ensures share difficulties are converted to the
+// expected targets.
+func TestDifficultyToTarget(t *testing.T) {
+	if difficultyToTarget(1).Cmp(diff1Target) != 0 {
+		t.Fatal("difficulty 1 does not map to the diff1 target")
+	}
+	want := blockchain.CompactToBig(0x1c7fff80)
+	if got := difficultyToTarget(2); got.Cmp(want) != 0 {
+		t.Fatalf("difficulty 2: got %x, want %x", got, want)
+	}
+}
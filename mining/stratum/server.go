@@ -0,0 +1,396 @@
+package stratum
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/mining"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+const (
+	// jobPollInterval is the interval at which the server checks whether
+	// the current job is stale.
+	jobPollInterval = time.Second
+
+	// jobRefreshInterval is the minimum amount of time in between new jobs
+	// which only add new transactions from the transaction source.
+	jobRefreshInterval = time.Minute
+
+	// maxJobs is the maximum number of jobs that are kept around so shares
+	// for recent jobs which are still building on the best chain can be
+	// accepted.
+	maxJobs = 8
+
+	// DefaultDifficulty is the default initial share difficulty assigned
+	// to new clients.
+	DefaultDifficulty = 1024
+)
+
+// Config is a descriptor containing the stratum server configuration.
+type Config struct {
+	// ChainParams identifies which chain parameters the stratum server is
+	// associated with.
+	ChainParams *chaincfg.Params
+
+	// BlockTemplateGenerator identifies the instance to use in order to
+	// generate block templates that the work handed out to clients is
+	// derived from.
+	BlockTemplateGenerator *mining.BlkTmplGenerator
+
+	// MiningAddrs is a list of payment addresses to use for the generated
+	// blocks.  Each job will randomly choose one of them.
+	MiningAddrs []bchutil.Address
+
+	// ProcessBlock defines the function to call with any solved blocks.
+	// It typically must run the provided block through the same set of
+	// rules and handling as any other block coming from the network.
+	ProcessBlock func(*bchutil.Block, blockchain.BehaviorFlags) (bool, error)
+
+	// ProcessWeakBlock defines the function to call with any weak blocks
+	// found by the clients.  Weak blocks are not announced when it is nil.
+	ProcessWeakBlock func(*wire.MsgBlock)
+
+	// IsCurrent defines the function to use to obtain whether or not the
+	// block chain is current.  No work is handed out while the chain is not
+	// current since any solved blocks would be on a side chain.
+	IsCurrent func() bool
+
+	// Listeners defines a slice of listeners for which the stratum server
+	// will take ownership of and accept connections.
+	Listeners []net.Listener
+
+	// Password is the password clients must provide in mining.authorize.
+	// Any password is accepted when it is empty.
+	Password string
+
+	// MinDifficulty is the initial and minimum share difficulty assigned to
+	// clients.  The difficulty of each client is adjusted from there to
+	// reach the target share rate.
+	MinDifficulty float64
+}
+
+// Server serves work derived from block templates to mining hardware using the
+// stratum v1 protocol.  It assigns a unique extra nonce to each client, adjusts
+// the share difficulty of each client to its hash rate, validates the submitted
+// shares, and submits any shares which solve a block to the network.
+type Server struct {
+	started  int32
+	shutdown int32
+
+	cfg Config
+	g   *mining.BlkTmplGenerator
+
+	mtx             sync.Mutex
+	clients         map[*client]struct{}
+	jobs            map[string]*job
+	jobOrder        []string
+	currentJob      *job
+	lastJobID       uint64
+	lastExtraNonce1 uint32
+	lastTxUpdate    time.Time
+
+	submitBlockLock sync.Mutex
+	jobRequest      chan struct{}
+	wg              sync.WaitGroup
+	quit            chan struct{}
+}
+
+// listenHandler accepts incoming connections on the passed listener.  It must
+// be run as a goroutine.
+func (s *Server) listenHandler(listener net.Listener) {
+	log.Infof("Stratum server listening on %s", listener.Addr())
+	for atomic.LoadInt32(&s.shutdown) == 0 {
+		conn, err := listener.Accept()
+		if err != nil {
+			// Only log the error if not forcibly shutting down.
+			if atomic.LoadInt32(&s.shutdown) == 0 {
+				log.Errorf("Can't accept connection: %v", err)
+			}
+			continue
+		}
+		if atomic.LoadInt32(&s.shutdown) != 0 {
+			conn.Close()
+			break
+		}
+
+		c := s.addClient(conn)
+		s.wg.Add(1)
+		go c.handler()
+	}
+	s.wg.Done()
+	log.Tracef("Stratum listener done for %s", listener.Addr())
+}
+
+// addClient creates a client for the passed connection and assigns it a
+// unique extra nonce.
+func (s *Server) addClient(conn net.Conn) *client {
+	s.mtx.Lock()
+	s.lastExtraNonce1++
+	c := newClient(s, conn, s.lastExtraNonce1)
+	s.clients[c] = struct{}{}
+	s.mtx.Unlock()
+	return c
+}
+
+// removeClient removes the passed client from the set of clients which are
+// notified of new jobs.
+func (s *Server) removeClient(c *client) {
+	s.mtx.Lock()
+	delete(s.clients, c)
+	s.mtx.Unlock()
+}
+
+// clientSubscribed is invoked by a client once it has subscribed to work.  It
+// returns the current job, if any, and signals the job handler to create one
+// otherwise.
+func (s *Server) clientSubscribed() *job {
+	s.mtx.Lock()
+	j := s.currentJob
+	s.mtx.Unlock()
+	if j == nil {
+		select {
+		case s.jobRequest <- struct{}{}:
+		default:
+		}
+	}
+	return j
+}
+
+// lookupJob returns the job with the passed id or nil when it does not exist
+// or is no longer valid.
+func (s *Server) lookupJob(id string) *job {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.jobs[id]
+}
+
+// recordSubmission records the passed share for the job and returns whether
+// it was already submitted.
+func (s *Server) recordSubmission(j *job, key string) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if _, ok := j.submissions[key]; ok {
+		return true
+	}
+	j.submissions[key] = struct{}{}
+	return false
+}
+
+// updateJob creates a new job from a fresh block template and sends it to all
+// of the subscribed clients.  When cleanJobs is set, all previous jobs are
+// discarded since they no longer build on the best chain.
+func (s *Server) updateJob(cleanJobs bool) {
+	// Grab the same lock as used for block submission, since the current
+	// block will be changing and this would otherwise end up building a
+	// new block template on a block that is in the process of becoming
+	// stale.
+	s.submitBlockLock.Lock()
+	payToAddr := s.cfg.MiningAddrs[rand.Intn(len(s.cfg.MiningAddrs))]
+	lastTxUpdate := s.g.TxSource().LastUpdated()
+	template, err := s.g.NewBlockTemplate(payToAddr)
+	minTime := mining.MinimumMedianTime(s.g.BestSnapshot())
+	s.submitBlockLock.Unlock()
+	if err != nil {
+		log.Errorf("Failed to create new block template: %v", err)
+		return
+	}
+
+	s.mtx.Lock()
+	s.lastJobID++
+	j, err := newJob(strconv.FormatUint(s.lastJobID, 16), template, minTime,
+		s.g.Policy().CoinbaseData)
+	if err != nil {
+		s.mtx.Unlock()
+		log.Errorf("Failed to create stratum job: %v", err)
+		return
+	}
+	if cleanJobs {
+		s.jobs = make(map[string]*job)
+		s.jobOrder = s.jobOrder[:0]
+	}
+	s.jobs[j.id] = j
+	s.jobOrder = append(s.jobOrder, j.id)
+	if len(s.jobOrder) > maxJobs {
+		delete(s.jobs, s.jobOrder[0])
+		s.jobOrder = s.jobOrder[1:]
+	}
+	s.currentJob = j
+	s.lastTxUpdate = lastTxUpdate
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mtx.Unlock()
+
+	log.Debugf("New stratum job %s at height %d with %d transactions "+
+		"(clean %v)", j.id, j.height, len(template.Block.Transactions),
+		cleanJobs)
+
+	for _, c := range clients {
+		c.notify(j, cleanJobs)
+	}
+}
+
+// jobHandler periodically checks whether the current job is stale and creates
+// a new one when needed.  The job is stale when the best chain has changed or
+// when the transaction source has been updated and enough time has elapsed.
+//
+// It must be run as a goroutine.
+func (s *Server) jobHandler() {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+out:
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.jobRequest:
+		case <-s.quit:
+			break out
+		}
+
+		s.mtx.Lock()
+		current := s.currentJob
+		numClients := len(s.clients)
+		lastTxUpdate := s.lastTxUpdate
+		s.mtx.Unlock()
+
+		// There is no point in creating templates when nobody is mining
+		// or the chain is not synced.
+		best := s.g.BestSnapshot()
+		if numClients == 0 || (best.Height != 0 && !s.cfg.IsCurrent()) {
+			continue
+		}
+
+		switch {
+		case current == nil || !current.prevHash.IsEqual(&best.Hash):
+			s.updateJob(true)
+
+		case !lastTxUpdate.Equal(s.g.TxSource().LastUpdated()) &&
+			time.Since(current.created) >= jobRefreshInterval:
+			s.updateJob(false)
+		}
+	}
+
+	s.wg.Done()
+	log.Tracef("Stratum job handler done")
+}
+
+// submitBlock submits the passed block to network after ensuring it passes all
+// of the consensus validation rules.
+func (s *Server) submitBlock(block *bchutil.Block) bool {
+	s.submitBlockLock.Lock()
+	defer s.submitBlockLock.Unlock()
+
+	// Ensure the block is not stale since a new block could have shown up
+	// while the solution was being found.
+	msgBlock := block.MsgBlock()
+	if !msgBlock.Header.PrevBlock.IsEqual(&s.g.BestSnapshot().Hash) {
+		log.Debugf("Block submitted via stratum with previous block %s "+
+			"is stale", msgBlock.Header.PrevBlock)
+		return false
+	}
+
+	// Process this block using the same rules as blocks coming from other
+	// nodes.  This will in turn relay it to the network like normal.
+	isOrphan, err := s.cfg.ProcessBlock(block, blockchain.BFNone)
+	if err != nil {
+		// Anything other than a rule violation is an unexpected error,
+		// so log that error as an internal error.
+		if _, ok := err.(blockchain.RuleError); !ok {
+			log.Errorf("Unexpected error while processing block "+
+				"submitted via stratum: %v", err)
+			return false
+		}
+
+		log.Debugf("Block submitted via stratum rejected: %v", err)
+		return false
+	}
+	if isOrphan {
+		log.Debugf("Block submitted via stratum is an orphan")
+		return false
+	}
+
+	// The block was accepted.
+	coinbaseTx := block.MsgBlock().Transactions[0].TxOut[0]
+	log.Infof("Block submitted via stratum accepted (hash %s, amount %v)",
+		block.Hash(), bchutil.Amount(coinbaseTx.Value))
+
+	// Hand out new work right away rather than waiting for the job handler
+	// to notice the new block.
+	select {
+	case s.jobRequest <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// Start begins accepting connections and handing out work.
+func (s *Server) Start() {
+	if atomic.AddInt32(&s.started, 1) != 1 {
+		return
+	}
+
+	log.Trace("Starting stratum server")
+	for _, listener := range s.cfg.Listeners {
+		s.wg.Add(1)
+		go s.listenHandler(listener)
+	}
+	s.wg.Add(1)
+	go s.jobHandler()
+}
+
+// Stop stops the stratum server by closing all listeners and client
+// connections and waiting for all goroutines to finish.
+func (s *Server) Stop() error {
+	if atomic.AddInt32(&s.shutdown, 1) != 1 {
+		log.Infof("Stratum server is already in the process of shutting down")
+		return nil
+	}
+	log.Warnf("Stratum server shutting down")
+	for _, listener := range s.cfg.Listeners {
+		if err := listener.Close(); err != nil {
+			log.Errorf("Problem shutting down stratum: %v", err)
+			return err
+		}
+	}
+
+	s.mtx.Lock()
+	for c := range s.clients {
+		c.disconnect()
+	}
+	s.mtx.Unlock()
+
+	close(s.quit)
+	s.wg.Wait()
+	log.Infof("Stratum server shutdown complete")
+	return nil
+}
+
+// New returns a new instance of a stratum server for the provided
+// configuration.  Use Start to begin accepting connections.
+func New(cfg *Config) (*Server, error) {
+	if len(cfg.MiningAddrs) == 0 {
+		return nil, errors.New("no mining addresses specified")
+	}
+	if cfg.MinDifficulty <= 0 {
+		cfg.MinDifficulty = DefaultDifficulty
+	}
+	return &Server{
+		cfg:        *cfg,
+		g:          cfg.BlockTemplateGenerator,
+		clients:    make(map[*client]struct{}),
+		jobs:       make(map[string]*job),
+		jobRequest: make(chan struct{}, 1),
+		quit:       make(chan struct{}),
+	}, nil
+}
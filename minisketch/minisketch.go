@@ -0,0 +1,346 @@
+// Package minisketch implements PinSketch set sketches of 32-bit elements,
+// which allow two parties to find the differences between their sets with
+// communication proportional to the size of the difference.
+package minisketch
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	// ErrCapacityMismatch is returned when merging sketches with different
+	// capacities.
+	ErrCapacityMismatch = errors.New("sketch capacities do not match")
+
+	// ErrInvalidSketch is returned when deserializing a sketch which is
+	// not a whole number of elements.
+	ErrInvalidSketch = errors.New("invalid serialized sketch")
+
+	// ErrDecodeFailed is returned when a sketch can not be decoded because
+	// the set it represents has more elements than the capacity of the
+	// sketch.
+	ErrDecodeFailed = errors.New("sketch could not be decoded")
+)
+
+// ElementSize is the size in bytes of a serialized sketch element.
+const ElementSize = 4
+
+// Sketch is a PinSketch of a set of nonzero 32-bit elements over GF(2^32).  It
+// consists of the odd power sums of the elements, so adding an element twice
+// removes it again and merging two sketches gives the sketch of the symmetric
+// difference of their sets.  A sketch with capacity c can be decoded as long as
+// its set has at most c elements.
+type Sketch struct {
+	syndromes []uint32
+}
+
+// New returns an empty sketch with the passed capacity.
+func New(capacity int) *Sketch {
+	return &Sketch{syndromes: make([]uint32, capacity)}
+}
+
+// Capacity returns the maximum number of elements which can be decoded from
+// the sketch.
+func (s *Sketch) Capacity() int {
+	return len(s.syndromes)
+}
+
+// Add adds the passed element to the sketch, or removes it when it was already
+// added.  The zero element is not supported and is ignored.
+func (s *Sketch) Add(element uint32) {
+	if element == 0 {
+		return
+	}
+	sqr := gfMul(element, element)
+	power := element
+	for i := range s.syndromes {
+		s.syndromes[i] ^= power
+		power = gfMul(power, sqr)
+	}
+}
+
+// Merge merges the passed sketch into the sketch, so it represents the
+// symmetric difference of both sets.
+func (s *Sketch) Merge(other *Sketch) error {
+	if len(s.syndromes) != len(other.syndromes) {
+		return ErrCapacityMismatch
+	}
+	for i, syndrome := range other.syndromes {
+		s.syndromes[i] ^= syndrome
+	}
+	return nil
+}
+
+// Serialize returns the serialized sketch, which is ElementSize bytes per unit
+// of capacity.
+func (s *Sketch) Serialize() []byte {
+	b := make([]byte, len(s.syndromes)*ElementSize)
+	for i, syndrome := range s.syndromes {
+		binary.LittleEndian.PutUint32(b[i*ElementSize:], syndrome)
+	}
+	return b
+}
+
+// Deserialize returns the sketch serialized in the passed bytes.
+func Deserialize(b []byte) (*Sketch, error) {
+	if len(b)%ElementSize != 0 {
+		return nil, ErrInvalidSketch
+	}
+	s := New(len(b) / ElementSize)
+	for i := range s.syndromes {
+		s.syndromes[i] = binary.LittleEndian.Uint32(b[i*ElementSize:])
+	}
+	return s, nil
+}
+
+// Decode returns the elements of the set represented by the sketch.  It returns
+// ErrDecodeFailed when the set has more elements than the capacity.
+func (s *Sketch) Decode() ([]uint32, error) {
+	// Compute all of the power sums from the odd ones, since the power sum
+	// of an even power 2i is the square of the power sum of i.
+	c := len(s.syndromes)
+	sums := make([]uint32, 2*c)
+	for i := 0; i < c; i++ {
+		sums[2*i] = s.syndromes[i]
+		sums[2*i+1] = gfMul(sums[i], sums[i])
+	}
+
+	// Find the polynomial with the inverses of the elements as its roots.
+	locator := berlekampMassey(sums)
+	degree := len(locator) - 1
+	if degree == 0 {
+		return nil, nil
+	}
+	if degree > c || locator[degree] == 0 {
+		return nil, ErrDecodeFailed
+	}
+
+	// Reversing the coefficients gives the monic polynomial with the
+	// elements themselves as its roots.  It must have as many distinct
+	// roots as its degree, which is the case when it divides x^(2^32) - x.
+	poly := make([]uint32, degree+1)
+	for i := range poly {
+		poly[i] = locator[degree-i]
+	}
+	x := []uint32{0, 1}
+	frob := x
+	for i := 0; i < 32; i++ {
+		frob = polySqrMod(frob, poly)
+	}
+	if !polyEqual(frob, polyMod(x, poly)) {
+		return nil, ErrDecodeFailed
+	}
+
+	roots := make([]uint32, 0, degree)
+	if !findRoots(poly, &roots) {
+		return nil, ErrDecodeFailed
+	}
+	return roots, nil
+}
+
+// berlekampMassey returns the shortest linear feedback shift register, given
+// as its connection polynomial, generating the passed sequence.
+func berlekampMassey(seq []uint32) []uint32 {
+	conn := []uint32{1}
+	prev := []uint32{1}
+	prevDiscrepancy := uint32(1)
+	length := 0
+	shift := 1
+	for n := range seq {
+		discrepancy := seq[n]
+		for i := 1; i <= length && i < len(conn); i++ {
+			discrepancy ^= gfMul(conn[i], seq[n-i])
+		}
+		if discrepancy == 0 {
+			shift++
+			continue
+		}
+
+		coef := gfMul(discrepancy, gfInv(prevDiscrepancy))
+		next := make([]uint32, max(len(conn), len(prev)+shift))
+		copy(next, conn)
+		for i, p := range prev {
+			next[i+shift] ^= gfMul(coef, p)
+		}
+		if 2*length <= n {
+			prev = conn
+			prevDiscrepancy = discrepancy
+			length = n + 1 - length
+			shift = 1
+		} else {
+			shift++
+		}
+		conn = next
+	}
+	return polyTrim(conn[:min(len(conn), length+1)])
+}
+
+// findRoots appends the roots of the passed monic polynomial to roots,
+// assuming it has as many distinct roots as its degree.  The polynomial is
+// split recursively using the Berlekamp trace algorithm.
+func findRoots(poly []uint32, roots *[]uint32) bool {
+	degree := len(poly) - 1
+	switch degree {
+	case 0:
+		return true
+	case 1:
+		*roots = append(*roots, poly[0])
+		return true
+	}
+
+	// The trace of beta*x is either 0 or 1 for every root, so the gcd with
+	// the polynomial splits the roots into two groups.  Trying every basis
+	// element as beta separates any two distinct roots.
+	for i := 0; i < 32; i++ {
+		beta := uint32(1) << uint(i)
+		term := polyMod([]uint32{0, beta}, poly)
+		trace := term
+		for j := 1; j < 32; j++ {
+			term = polySqrMod(term, poly)
+			trace = polyAdd(trace, term)
+		}
+
+		factor := polyGCD(poly, trace)
+		if len(factor) <= 1 || len(factor) == len(poly) {
+			continue
+		}
+		return findRoots(factor, roots) &&
+			findRoots(polyDiv(poly, factor), roots)
+	}
+	return false
+}
+
+// gfMul returns the product of a and b in GF(2^32), using the irreducible
+// polynomial x^32 + x^7 + x^3 + x^2 + 1.
+func gfMul(a, b uint32) uint32 {
+	var r uint64
+	wide := uint64(a)
+	for i := uint(0); i < 32; i++ {
+		r ^= (wide << i) & -(uint64(b>>i) & 1)
+	}
+	for hi := r >> 32; hi != 0; hi = r >> 32 {
+		r = (r & 0xffffffff) ^ hi ^ hi<<2 ^ hi<<3 ^ hi<<7
+	}
+	return uint32(r)
+}
+
+// gfInv returns the multiplicative inverse of the nonzero element a in
+// GF(2^32), which is a^(2^32-2).
+func gfInv(a uint32) uint32 {
+	result := uint32(1)
+	sqr := a
+	for i := 1; i < 32; i++ {
+		sqr = gfMul(sqr, sqr)
+		result = gfMul(result, sqr)
+	}
+	return result
+}
+
+// polyTrim removes the zero coefficients of the highest degrees from the
+// passed polynomial, which has its coefficients ordered from the lowest degree.
+func polyTrim(p []uint32) []uint32 {
+	for len(p) > 0 && p[len(p)-1] == 0 {
+		p = p[:len(p)-1]
+	}
+	return p
+}
+
+// polyEqual returns whether or not the passed polynomials are equal.
+func polyEqual(a, b []uint32) bool {
+	a, b = polyTrim(a), polyTrim(b)
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// polyAdd returns the sum of the passed polynomials.
+func polyAdd(a, b []uint32) []uint32 {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	sum := make([]uint32, len(a))
+	copy(sum, a)
+	for i, c := range b {
+		sum[i] ^= c
+	}
+	return polyTrim(sum)
+}
+
+// polyMod returns the remainder of the division of a by the monic polynomial m.
+func polyMod(a, m []uint32) []uint32 {
+	r := make([]uint32, len(a))
+	copy(r, a)
+	degree := len(m) - 1
+	for i := len(r) - 1; i >= degree; i-- {
+		coef := r[i]
+		if coef == 0 {
+			continue
+		}
+		for j := 0; j <= degree; j++ {
+			r[i-degree+j] ^= gfMul(coef, m[j])
+		}
+	}
+	return polyTrim(r[:min(len(r), degree)])
+}
+
+// polySqrMod returns the square of a modulo the monic polynomial m.  Squaring
+// is linear in characteristic 2, so the square has the squared coefficients
+// at the even degrees.
+func polySqrMod(a, m []uint32) []uint32 {
+	if len(a) == 0 {
+		return nil
+	}
+	sqr := make([]uint32, 2*len(a)-1)
+	for i, c := range a {
+		sqr[2*i] = gfMul(c, c)
+	}
+	return polyMod(sqr, m)
+}
+
+// polyMonic returns the passed nonzero polynomial scaled to have a leading
+// coefficient of 1.
+func polyMonic(p []uint32) []uint32 {
+	inv := gfInv(p[len(p)-1])
+	monic := make([]uint32, len(p))
+	for i, c := range p {
+		monic[i] = gfMul(c, inv)
+	}
+	return monic
+}
+
+// polyGCD returns the monic greatest common divisor of the passed polynomials,
+// where a is monic.
+func polyGCD(a, b []uint32) []uint32 {
+	b = polyTrim(b)
+	for len(b) > 0 {
+		b = polyMonic(b)
+		a, b = b, polyMod(a, b)
+	}
+	return a
+}
+
+// polyDiv returns the quotient of the division of a by the monic polynomial m.
+func polyDiv(a, m []uint32) []uint32 {
+	r := make([]uint32, len(a))
+	copy(r, a)
+	degree := len(m) - 1
+	q := make([]uint32, len(a)-degree)
+	for i := len(r) - 1; i >= degree; i-- {
+		coef := r[i]
+		q[i-degree] = coef
+		if coef == 0 {
+			continue
+		}
+		for j := 0; j <= degree; j++ {
+			r[i-degree+j] ^= gfMul(coef, m[j])
+		}
+	}
+	return q
+}
@@ -0,0 +1,148 @@
+package minisketch
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// TestGFArithmetic ensures the field multiplication and inversion are
+// consistent.
+func TestGFArithmetic(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		a, b, c := rng.Uint32(), rng.Uint32(), rng.Uint32()
+		if gfMul(a, b) != gfMul(b, a) {
+			t.Fatalf("multiplication of %x and %x is not commutative", a, b)
+		}
+		if gfMul(a, b^c) != gfMul(a, b)^gfMul(a, c) {
+			t.Fatalf("multiplication of %x is not distributive", a)
+		}
+		if gfMul(gfMul(a, b), c) != gfMul(a, gfMul(b, c)) {
+			t.Fatalf("multiplication of %x, %x and %x is not "+
+				"associative", a, b, c)
+		}
+		if a != 0 && gfMul(a, gfInv(a)) != 1 {
+			t.Fatalf("inverse of %x is wrong", a)
+		}
+	}
+}
+
+// TestSketchDecode ensures sketches of sets up to their capacity are decoded
+// to the original elements and sketches of larger sets fail to decode.
+func TestSketchDecode(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	tests := []struct {
+		capacity int
+		size     int
+	}{
+		{capacity: 1, size: 0},
+		{capacity: 1, size: 1},
+		{capacity: 4, size: 3},
+		{capacity: 10, size: 10},
+		{capacity: 50, size: 37},
+		{capacity: 120, size: 120},
+	}
+
+	for i, test := range tests {
+		elements := make(map[uint32]struct{})
+		for len(elements) < test.size {
+			if e := rng.Uint32(); e != 0 {
+				elements[e] = struct{}{}
+			}
+		}
+		want := make([]uint32, 0, test.size)
+		sketch := New(test.capacity)
+		for e := range elements {
+			want = append(want, e)
+			sketch.Add(e)
+		}
+
+		got, err := sketch.Decode()
+		if err != nil {
+			t.Errorf("Decode #%d: unexpected error: %v", i, err)
+			continue
+		}
+		sortElements(got)
+		sortElements(want)
+		if len(got) != 0 || len(want) != 0 {
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Decode #%d: got %v, want %v", i, got, want)
+			}
+		}
+	}
+
+	// A set which exceeds the capacity can not be decoded.  Very small
+	// sketches are skipped since they decode to a wrong set with a high
+	// probability instead.
+	for capacity := 8; capacity <= 20; capacity++ {
+		sketch := New(capacity)
+		for i := 0; i < capacity+5; i++ {
+			sketch.Add(rng.Uint32() | 1)
+		}
+		if _, err := sketch.Decode(); err != ErrDecodeFailed {
+			t.Errorf("Decode capacity %d: expected ErrDecodeFailed, "+
+				"got %v", capacity, err)
+		}
+	}
+}
+
+// TestSketchMerge ensures merging sketches yields the symmetric difference of
+// their sets and that sketches survive serialization.
+func TestSketchMerge(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	const capacity = 20
+
+	local, remote := New(capacity), New(capacity)
+	for i := 0; i < 500; i++ {
+		e := rng.Uint32() | 1
+		local.Add(e)
+		remote.Add(e)
+	}
+	var want []uint32
+	for i := 0; i < 8; i++ {
+		e := rng.Uint32() | 1
+		local.Add(e)
+		want = append(want, e)
+	}
+	for i := 0; i < 7; i++ {
+		e := rng.Uint32() | 1
+		remote.Add(e)
+		want = append(want, e)
+	}
+
+	serialized := remote.Serialize()
+	if len(serialized) != capacity*ElementSize {
+		t.Fatalf("unexpected serialized size %d", len(serialized))
+	}
+	remote, err := Deserialize(serialized)
+	if err != nil {
+		t.Fatalf("Deserialize: unexpected error: %v", err)
+	}
+	if err := local.Merge(remote); err != nil {
+		t.Fatalf("Merge: unexpected error: %v", err)
+	}
+	got, err := local.Decode()
+	if err != nil {
+		t.Fatalf("Decode: unexpected error: %v", err)
+	}
+	sortElements(got)
+	sortElements(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Decode: got %v, want %v", got, want)
+	}
+
+	if err := local.Merge(New(capacity + 1)); err != ErrCapacityMismatch {
+		t.Fatalf("Merge: expected ErrCapacityMismatch, got %v", err)
+	}
+	if _, err := Deserialize(make([]byte, 5)); err != ErrInvalidSketch {
+		t.Fatalf("Deserialize: expected ErrInvalidSketch, got %v", err)
+	}
+}
+
+func sortElements(elements []uint32) {
+	sort.Slice(elements, func(i, j int) bool {
+		return elements[i] < elements[j]
+	})
+}
@@ -0,0 +1,15 @@
+/*
+Package nat implements mapping the listening port of bchd on NAT gateways so it
+can accept inbound connections.
+
+Gateways are discovered and spoken to with the Port Control Protocol (PCP,
+RFC 6887), its predecessor NAT-PMP (RFC 6886) or UPnP, in that order of
+preference.  Gateways of IPv6 networks which do not translate addresses but
+block inbound connections in their firewall can be asked with PCP to open a
+pinhole for the listening port instead.
+
+Mappings expire after the lifetime granted by the gateway, so they need to be
+renewed periodically by calling AddPortMapping again.  The external address of
+the gateway may change on renewals.
+*/
+package nat
@@ -0,0 +1,104 @@
+package nat
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// rtfGateway is the route flag marking routes via a gateway.
+const rtfGateway = 0x2
+
+// parseRoutes returns the default IPv4 gateway with the lowest metric of the
+// routing table in the format of /proc/net/route.
+func parseRoutes(r io.Reader) (*net.IPAddr, error) {
+	var gateway *net.IPAddr
+	var bestMetric uint64
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[1] != "00000000" ||
+			fields[7] != "00000000" {
+
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&rtfGateway == 0 {
+			continue
+		}
+		metric, err := strconv.ParseUint(fields[6], 10, 32)
+		if err != nil {
+			continue
+		}
+		b, err := hex.DecodeString(fields[2])
+		if err != nil || len(b) != net.IPv4len {
+			continue
+		}
+		if gateway != nil && metric >= bestMetric {
+			continue
+		}
+
+		// The gateway is in host byte order.
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, binary.NativeEndian.Uint32(b))
+		gateway = &net.IPAddr{IP: ip}
+		bestMetric = metric
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if gateway == nil {
+		return nil, ErrNoGateway
+	}
+	return gateway, nil
+}
+
+// parseIPv6Routes returns the default IPv6 gateway with the lowest metric of
+// the routing table in the format of /proc/net/ipv6_route.  Link-local
+// gateways are returned with the interface they are reached on as zone.
+func parseIPv6Routes(r io.Reader) (*net.IPAddr, error) {
+	var gateway *net.IPAddr
+	var bestMetric uint64
+	zero := strings.Repeat("0", 2*net.IPv6len)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[0] != zero || fields[1] != "00" ||
+			fields[4] == zero {
+
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[8], 16, 32)
+		if err != nil || flags&rtfGateway == 0 {
+			continue
+		}
+		metric, err := strconv.ParseUint(fields[5], 16, 32)
+		if err != nil {
+			continue
+		}
+		ip, err := hex.DecodeString(fields[4])
+		if err != nil || len(ip) != net.IPv6len {
+			continue
+		}
+		if gateway != nil && metric >= bestMetric {
+			continue
+		}
+
+		gateway = &net.IPAddr{IP: ip}
+		if gateway.IP.IsLinkLocalUnicast() {
+			gateway.Zone = fields[9]
+		}
+		bestMetric = metric
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if gateway == nil {
+		return nil, ErrNoGateway
+	}
+	return gateway, nil
+}
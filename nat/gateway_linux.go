@@ -0,0 +1,29 @@
+//go:build linux
+// +build linux
+
+package nat
+
+import (
+	"net"
+	"os"
+)
+
+// defaultGateway returns the default IPv4 or IPv6 gateway from the routing
+// tables of the kernel.
+func defaultGateway(ipv6 bool) (*net.IPAddr, error) {
+	if ipv6 {
+		f, err := os.Open("/proc/net/ipv6_route")
+		if err != nil {
+			return nil, ErrNoGateway
+		}
+		defer f.Close()
+		return parseIPv6Routes(f)
+	}
+
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, ErrNoGateway
+	}
+	defer f.Close()
+	return parseRoutes(f)
+}
@@ -0,0 +1,45 @@
+//go:build !linux
+// +build !linux
+
+package nat
+
+import (
+	"net"
+)
+
+// defaultGateway guesses the default IPv4 gateway to be the first address of
+// the private network of the first interface which is up.  The routing table
+// is not read on this platform, so IPv6 gateways are not found.
+func defaultGateway(ipv6 bool) (*net.IPAddr, error) {
+	if ipv6 {
+		return nil, ErrNoGateway
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, ErrNoGateway
+	}
+	for _, ifi := range ifaces {
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			ip := ipNet.IP.To4()
+			if ip == nil || !ip.IsPrivate() {
+				continue
+			}
+			gateway := ip.Mask(ipNet.Mask)
+			gateway[3] |= 1
+			return &net.IPAddr{IP: gateway}, nil
+		}
+	}
+	return nil, ErrNoGateway
+}
@@ -0,0 +1,172 @@
+package nat
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	// initialTimeout is the time to wait for the first response to a
+	// request sent to the gateway.  The timeout doubles with every
+	// retransmission as recommended by RFC 6886.
+	initialTimeout = 250 * time.Millisecond
+
+	// maxAttempts is the number of times a request is sent before giving
+	// up on the gateway.
+	maxAttempts = 4
+
+	// maxResponseSize is the maximum size of the responses read from the
+	// gateway.
+	maxResponseSize = 1100
+)
+
+var (
+	// ErrNoGateway is returned when the default gateway can not be
+	// determined.
+	ErrNoGateway = errors.New("nat: no default gateway found")
+
+	// ErrNoResponse is returned when the gateway did not respond to a
+	// request.
+	ErrNoResponse = errors.New("nat: no response from gateway")
+)
+
+// NAT is an interface representing a NAT traversal option, for example UPnP,
+// NAT-PMP or PCP.  It provides methods to query and manipulate this traversal
+// to allow access to services.
+type NAT interface {
+	// String returns the name of the protocol spoken with the gateway.
+	String() string
+
+	// ExternalAddress returns the external address of the gateway.
+	ExternalAddress() (net.IP, error)
+
+	// AddPortMapping adds or renews a mapping for protocol ("udp" or
+	// "tcp") from the external port to the internal port for the requested
+	// lifetime.  It returns the external port and the lifetime actually
+	// granted by the gateway, which may both differ from the requested
+	// ones.
+	AddPortMapping(protocol string, internalPort, externalPort int,
+		lifetime time.Duration) (int, time.Duration, error)
+
+	// DeletePortMapping removes a previously added port mapping from the
+	// external port to the internal port.
+	DeletePortMapping(protocol string, internalPort, externalPort int) error
+}
+
+// Discover searches for a gateway which maps ports of its IPv4 address with
+// PCP, NAT-PMP or UPnP, in that order of preference.
+func Discover() (NAT, error) {
+	gateway, err := defaultGateway(false)
+	if err == nil {
+		if n, err := DiscoverPCP(gateway); err == nil {
+			return n, nil
+		}
+		if n, err := DiscoverNATPMP(gateway); err == nil {
+			return n, nil
+		}
+	}
+	return DiscoverUPnP()
+}
+
+// DiscoverIPv6 searches for an IPv6 gateway which opens pinholes for inbound
+// connections to the local IPv6 address in its firewall with PCP.  The
+// external address of the pinholes is the local address itself.
+func DiscoverIPv6() (NAT, error) {
+	gateway, err := defaultGateway(true)
+	if err != nil {
+		return nil, err
+	}
+	return DiscoverPCP(gateway)
+}
+
+// localAddr returns the local IP address which is used to reach the passed
+// address.
+func localAddr(addr string) (net.IP, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+// globalAddr returns the first global IPv6 address of the interface with the
+// passed name.  It is used as source address of requests sent to link-local
+// gateways, which would otherwise be sent from the link-local address.
+func globalAddr(iface string) (net.IP, error) {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipNet.IP
+		if ip.To4() == nil && ip.IsGlobalUnicast() && !ip.IsPrivate() {
+			return ip, nil
+		}
+	}
+	return nil, fmt.Errorf("nat: no global IPv6 address on %s", iface)
+}
+
+// clientAddr returns the local address requests to the passed gateway are sent
+// from.
+func clientAddr(gateway *net.UDPAddr) (*net.UDPAddr, error) {
+	if gateway.IP.To4() == nil && gateway.Zone != "" {
+		ip, err := globalAddr(gateway.Zone)
+		if err != nil {
+			return nil, err
+		}
+		return &net.UDPAddr{IP: ip}, nil
+	}
+	ip, err := localAddr(gateway.String())
+	if err != nil {
+		return nil, err
+	}
+	return &net.UDPAddr{IP: ip}, nil
+}
+
+// roundTrip sends the passed request from the passed local address, which may
+// be nil, to the gateway at the passed address and returns the first response
+// for which the passed function returns true.  The request is retransmitted
+// with an increasing timeout until a response is received.
+func roundTrip(local, gateway *net.UDPAddr, req []byte, accept func([]byte) bool) ([]byte, error) {
+	conn, err := net.DialUDP("udp", local, gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	buf := make([]byte, maxResponseSize)
+	timeout := initialTimeout
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(timeout)
+		if err := conn.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				break
+			}
+			if accept(buf[:n]) {
+				resp := make([]byte, n)
+				copy(resp, buf)
+				return resp, nil
+			}
+		}
+		timeout *= 2
+	}
+	return nil, ErrNoResponse
+}
@@ -0,0 +1,285 @@
+package nat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGateway is a gateway answering requests with the passed handler.  It
+// records all received requests.
+type fakeGateway struct {
+	conn     *net.UDPConn
+	handler  func(req []byte) []byte
+	mtx      sync.Mutex
+	requests [][]byte
+}
+
+// newFakeGateway starts a fake gateway listening on the loopback address.
+func newFakeGateway(t *testing.T, handler func(req []byte) []byte) *fakeGateway {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("ListenUDP: unexpected error: %v", err)
+	}
+	g := &fakeGateway{conn: conn, handler: handler}
+	go func() {
+		buf := make([]byte, maxResponseSize)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			req := append([]byte(nil), buf[:n]...)
+			g.mtx.Lock()
+			g.requests = append(g.requests, req)
+			g.mtx.Unlock()
+			if resp := handler(req); resp != nil {
+				conn.WriteToUDP(resp, addr)
+			}
+		}
+	}()
+	t.Cleanup(func() { conn.Close() })
+	return g
+}
+
+// addr returns the address of the fake gateway.
+func (g *fakeGateway) addr() *net.UDPAddr {
+	return g.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// lastRequest returns the last request received by the fake gateway.
+func (g *fakeGateway) lastRequest() []byte {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	return g.requests[len(g.requests)-1]
+}
+
+// TestParseRoutes ensures the default gateways are found in the kernel routing
+// tables.
+func TestParseRoutes(t *testing.T) {
+	routes := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+		"eth1\t00000000\t0A00000A\t0003\t0\t0\t600\t00000000\t0\t0\t0\n" +
+		"eth0\t00000000\t0101A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n" +
+		"eth0\t0001A8C0\t00000000\t0001\t0\t0\t100\t00FFFFFF\t0\t0\t0\n"
+	gateway, err := parseRoutes(strings.NewReader(routes))
+	if err != nil {
+		t.Fatalf("parseRoutes: unexpected error: %v", err)
+	}
+	if !gateway.IP.Equal(net.IPv4(192, 168, 1, 1)) || gateway.Zone != "" {
+		t.Errorf("parseRoutes: unexpected gateway %v", gateway)
+	}
+
+	_, err = parseRoutes(strings.NewReader(strings.Join(
+		strings.Split(routes, "\n")[3:], "\n")))
+	if err != ErrNoGateway {
+		t.Errorf("parseRoutes: unexpected error - got %v, want %v", err,
+			ErrNoGateway)
+	}
+
+	ipv6Routes := "fd000000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth0\n" +
+		"00000000000000000000000000000000 00 00000000000000000000000000000000 00 fd000000000000000000000000000001 00000400 00000001 00000000 00000003     eth0\n" +
+		"00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000200 00000001 00000000 00000003     wlan0\n" +
+		"00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200       lo\n"
+	gateway, err = parseIPv6Routes(strings.NewReader(ipv6Routes))
+	if err != nil {
+		t.Fatalf("parseIPv6Routes: unexpected error: %v", err)
+	}
+	if !gateway.IP.Equal(net.ParseIP("fe80::1")) || gateway.Zone != "wlan0" {
+		t.Errorf("parseIPv6Routes: unexpected gateway %v", gateway)
+	}
+
+	_, err = parseIPv6Routes(strings.NewReader(strings.Join(
+		strings.Split(ipv6Routes, "\n")[3:], "\n")))
+	if err != ErrNoGateway {
+		t.Errorf("parseIPv6Routes: unexpected error - got %v, want %v",
+			err, ErrNoGateway)
+	}
+}
+
+// TestNATPMP ensures ports are mapped with NAT-PMP.
+func TestNATPMP(t *testing.T) {
+	externalIP := net.IPv4(203, 0, 113, 7).To4()
+	g := newFakeGateway(t, func(req []byte) []byte {
+		switch {
+		case len(req) == 2 && req[1] == natpmpOpExternalAddress:
+			resp := []byte{natpmpVersion, natpmpOpResponse, 0, 0, 0, 0, 0, 1}
+			return append(resp, externalIP...)
+
+		case len(req) == 12 && req[1] == natpmpOpMapTCP:
+			// Map to the next port and grant half the lifetime.
+			resp := make([]byte, 16)
+			resp[1] = natpmpOpResponse + req[1]
+			copy(resp[8:10], req[4:6])
+			external := binary.BigEndian.Uint16(req[6:8])
+			if external != 0 {
+				external++
+			}
+			binary.BigEndian.PutUint16(resp[10:12], external)
+			lifetime := binary.BigEndian.Uint32(req[8:12])
+			binary.BigEndian.PutUint32(resp[12:16], lifetime/2)
+			return resp
+
+		default:
+			// Unsupported opcode.
+			return []byte{natpmpVersion, natpmpOpResponse + req[1], 0, 5}
+		}
+	})
+
+	n, err := newNATPMP(g.addr())
+	if err != nil {
+		t.Fatalf("newNATPMP: unexpected error: %v", err)
+	}
+	ip, err := n.ExternalAddress()
+	if err != nil || !ip.Equal(externalIP) {
+		t.Fatalf("ExternalAddress: unexpected address %v (err %v)", ip, err)
+	}
+
+	port, lifetime, err := n.AddPortMapping("tcp", 8333, 8333, time.Hour)
+	if err != nil {
+		t.Fatalf("AddPortMapping: unexpected error: %v", err)
+	}
+	if port != 8334 || lifetime != 30*time.Minute {
+		t.Errorf("AddPortMapping: unexpected mapping to %d for %v", port,
+			lifetime)
+	}
+
+	if err := n.DeletePortMapping("tcp", 8333, port); err != nil {
+		t.Fatalf("DeletePortMapping: unexpected error: %v", err)
+	}
+	want := []byte{0, natpmpOpMapTCP, 0, 0, 0x20, 0x8d, 0, 0, 0, 0, 0, 0}
+	if req := g.lastRequest(); !bytes.Equal(req, want) {
+		t.Errorf("DeletePortMapping: unexpected request %x, want %x", req,
+			want)
+	}
+
+	if _, _, err := n.AddPortMapping("udp", 8333, 8333, time.Hour); err == nil {
+		t.Error("AddPortMapping: mapped port with unsupported opcode")
+	}
+	if _, _, err := n.AddPortMapping("sctp", 8333, 8333, time.Hour); err == nil {
+		t.Error("AddPortMapping: mapped port of unknown protocol")
+	}
+}
+
+// TestPCP ensures ports are mapped with PCP and renewals and deletions refer to
+// the same mapping.
+func TestPCP(t *testing.T) {
+	externalIP := net.IPv4(203, 0, 113, 7)
+	g := newFakeGateway(t, func(req []byte) []byte {
+		if len(req) < pcpHeaderSize || req[0] != pcpVersion {
+			return nil
+		}
+		resp := make([]byte, len(req))
+		resp[0] = pcpVersion
+		resp[1] = pcpOpResponse | req[1]
+		switch {
+		case req[1] == pcpOpAnnounce:
+
+		case req[1] == pcpOpMap && len(req) == pcpHeaderSize+pcpMapSize:
+			copy(resp[4:8], req[4:8])
+			copy(resp[pcpHeaderSize:], req[pcpHeaderSize:])
+			mapping := resp[pcpHeaderSize:]
+			binary.BigEndian.PutUint16(mapping[18:20], 18333)
+			copy(mapping[20:36], externalIP.To16())
+
+		default:
+			resp[3] = 4
+		}
+		return resp
+	})
+
+	n, err := newPCP(g.addr())
+	if err != nil {
+		t.Fatalf("newPCP: unexpected error: %v", err)
+	}
+	if _, err := n.ExternalAddress(); err == nil {
+		t.Fatal("ExternalAddress: address known before first mapping")
+	}
+
+	port, lifetime, err := n.AddPortMapping("tcp", 8333, 8333, time.Hour)
+	if err != nil {
+		t.Fatalf("AddPortMapping: unexpected error: %v", err)
+	}
+	if port != 18333 || lifetime != time.Hour {
+		t.Errorf("AddPortMapping: unexpected mapping to %d for %v", port,
+			lifetime)
+	}
+	ip, err := n.ExternalAddress()
+	if err != nil || !ip.Equal(externalIP) {
+		t.Fatalf("ExternalAddress: unexpected address %v (err %v)", ip, err)
+	}
+	req := g.lastRequest()
+	if !net.IP(req[8:24]).Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("AddPortMapping: unexpected client address %v",
+			net.IP(req[8:24]))
+	}
+	if req[pcpHeaderSize+12] != protocolTCP {
+		t.Errorf("AddPortMapping: unexpected protocol %d",
+			req[pcpHeaderSize+12])
+	}
+	nonce := req[pcpHeaderSize : pcpHeaderSize+pcpNonceSize]
+
+	// Renewing and deleting the mapping must use the same nonce.
+	if _, _, err := n.AddPortMapping("tcp", 8333, port, time.Hour); err != nil {
+		t.Fatalf("AddPortMapping: unexpected error: %v", err)
+	}
+	req = g.lastRequest()
+	if !bytes.Equal(req[pcpHeaderSize:pcpHeaderSize+pcpNonceSize], nonce) {
+		t.Error("AddPortMapping: renewal with different nonce")
+	}
+	if err := n.DeletePortMapping("tcp", 8333, port); err != nil {
+		t.Fatalf("DeletePortMapping: unexpected error: %v", err)
+	}
+	req = g.lastRequest()
+	if !bytes.Equal(req[pcpHeaderSize:pcpHeaderSize+pcpNonceSize], nonce) {
+		t.Error("DeletePortMapping: deletion with different nonce")
+	}
+	if lifetime := binary.BigEndian.Uint32(req[4:8]); lifetime != 0 {
+		t.Errorf("DeletePortMapping: unexpected lifetime %d", lifetime)
+	}
+
+	// Mappings of other ports have their own nonce.
+	if _, _, err := n.AddPortMapping("udp", 8333, 8333, time.Hour); err != nil {
+		t.Fatalf("AddPortMapping: unexpected error: %v", err)
+	}
+	req = g.lastRequest()
+	if bytes.Equal(req[pcpHeaderSize:pcpHeaderSize+pcpNonceSize], nonce) {
+		t.Error("AddPortMapping: new mapping with same nonce")
+	}
+}
+
+// TestPCPFallback ensures gateways which only speak NAT-PMP are not taken for
+// PCP gateways.
+func TestPCPFallback(t *testing.T) {
+	g := newFakeGateway(t, func(req []byte) []byte {
+		// Unsupported version.
+		return []byte{natpmpVersion, natpmpOpResponse + req[1], 0, 1}
+	})
+	if _, err := newPCP(g.addr()); err != errPCPUnsupported {
+		t.Fatalf("newPCP: unexpected error - got %v, want %v", err,
+			errPCPUnsupported)
+	}
+}
+
+// TestNoResponse ensures requests to unresponsive gateways fail.
+func TestNoResponse(t *testing.T) {
+	g := newFakeGateway(t, func([]byte) []byte { return nil })
+	start := time.Now()
+	if _, err := newNATPMP(g.addr()); err != ErrNoResponse {
+		t.Fatalf("newNATPMP: unexpected error - got %v, want %v", err,
+			ErrNoResponse)
+	}
+	g.mtx.Lock()
+	attempts := len(g.requests)
+	g.mtx.Unlock()
+	if attempts != maxAttempts {
+		t.Errorf("unexpected number of attempts - got %d, want %d",
+			attempts, maxAttempts)
+	}
+	if elapsed := time.Since(start); elapsed < 15*initialTimeout {
+		t.Errorf("gave up after %v", elapsed)
+	}
+}
@@ -0,0 +1,151 @@
+package nat
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// natpmpPort is the port gateways listen for NAT-PMP and PCP requests
+	// on.
+	natpmpPort = 5351
+
+	// natpmpVersion is the version of NAT-PMP.
+	natpmpVersion = 0
+
+	// The NAT-PMP opcodes.  Responses have the opcode of the request with
+	// the most significant bit set.
+	natpmpOpExternalAddress = 0
+	natpmpOpMapUDP          = 1
+	natpmpOpMapTCP          = 2
+	natpmpOpResponse        = 128
+)
+
+// natpmpResultErrors maps the NAT-PMP result codes to human-readable errors.
+var natpmpResultErrors = map[uint16]string{
+	1: "unsupported version",
+	2: "not authorized",
+	3: "network failure",
+	4: "out of resources",
+	5: "unsupported opcode",
+}
+
+// natpmpResultError returns the error of the passed NAT-PMP result code.
+func natpmpResultError(code uint16) error {
+	if str, ok := natpmpResultErrors[code]; ok {
+		return fmt.Errorf("NAT-PMP: %s", str)
+	}
+	return fmt.Errorf("NAT-PMP: result code %d", code)
+}
+
+// natpmpNAT implements the NAT interface for gateways which speak NAT-PMP as
+// described in RFC 6886.
+type natpmpNAT struct {
+	gateway *net.UDPAddr
+}
+
+// DiscoverNATPMP returns the NAT of the passed gateway if it speaks NAT-PMP.
+func DiscoverNATPMP(gateway *net.IPAddr) (NAT, error) {
+	return newNATPMP(&net.UDPAddr{
+		IP:   gateway.IP,
+		Port: natpmpPort,
+		Zone: gateway.Zone,
+	})
+}
+
+// newNATPMP returns the NAT of the gateway at the passed address if it responds
+// to NAT-PMP requests.
+func newNATPMP(gateway *net.UDPAddr) (*natpmpNAT, error) {
+	n := &natpmpNAT{gateway: gateway}
+	if _, err := n.ExternalAddress(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// String returns the name of the protocol.  This is part of the NAT interface.
+func (n *natpmpNAT) String() string {
+	return "NAT-PMP"
+}
+
+// request sends the passed request to the gateway and returns the response
+// with the expected size once its result code indicates success.
+func (n *natpmpNAT) request(req []byte, size int) ([]byte, error) {
+	op := req[1]
+	resp, err := roundTrip(nil, n.gateway, req, func(resp []byte) bool {
+		return len(resp) >= 4 && resp[0] == natpmpVersion &&
+			resp[1] == natpmpOpResponse+op
+	})
+	if err != nil {
+		return nil, err
+	}
+	if code := binary.BigEndian.Uint16(resp[2:4]); code != 0 {
+		return nil, natpmpResultError(code)
+	}
+	if len(resp) < size {
+		return nil, fmt.Errorf("NAT-PMP: short response of %d bytes",
+			len(resp))
+	}
+	return resp, nil
+}
+
+// ExternalAddress returns the external IPv4 address of the gateway.  This is
+// part of the NAT interface.
+func (n *natpmpNAT) ExternalAddress() (net.IP, error) {
+	resp, err := n.request([]byte{natpmpVersion, natpmpOpExternalAddress}, 12)
+	if err != nil {
+		return nil, err
+	}
+	return net.IPv4(resp[8], resp[9], resp[10], resp[11]), nil
+}
+
+// mapPort requests a mapping from the external port to the internal port.  A
+// lifetime of zero deletes the mapping.
+func (n *natpmpNAT) mapPort(protocol string, internalPort, externalPort int,
+	lifetime time.Duration) (int, time.Duration, error) {
+
+	var op byte
+	switch strings.ToLower(protocol) {
+	case "udp":
+		op = natpmpOpMapUDP
+	case "tcp":
+		op = natpmpOpMapTCP
+	default:
+		return 0, 0, fmt.Errorf("NAT-PMP: unsupported protocol %s",
+			protocol)
+	}
+
+	req := make([]byte, 12)
+	req[0] = natpmpVersion
+	req[1] = op
+	binary.BigEndian.PutUint16(req[4:6], uint16(internalPort))
+	binary.BigEndian.PutUint16(req[6:8], uint16(externalPort))
+	binary.BigEndian.PutUint32(req[8:12], uint32(lifetime/time.Second))
+	resp, err := n.request(req, 16)
+	if err != nil {
+		return 0, 0, err
+	}
+	mappedPort := int(binary.BigEndian.Uint16(resp[10:12]))
+	granted := time.Duration(binary.BigEndian.Uint32(resp[12:16])) *
+		time.Second
+	return mappedPort, granted, nil
+}
+
+// AddPortMapping maps the external port to the internal port.  This is part of
+// the NAT interface.
+func (n *natpmpNAT) AddPortMapping(protocol string, internalPort, externalPort int,
+	lifetime time.Duration) (int, time.Duration, error) {
+
+	return n.mapPort(protocol, internalPort, externalPort, lifetime)
+}
+
+// DeletePortMapping removes the mapping of the internal port, which is done by
+// requesting a mapping with a lifetime of zero.  This is part of the NAT
+// interface.
+func (n *natpmpNAT) DeletePortMapping(protocol string, internalPort, externalPort int) error {
+	_, _, err := n.mapPort(protocol, internalPort, 0, 0)
+	return err
+}
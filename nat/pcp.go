@@ -0,0 +1,246 @@
+package nat
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// pcpVersion is the version of the Port Control Protocol.
+	pcpVersion = 2
+
+	// The PCP opcodes used.  Responses have the opcode of the request with
+	// the most significant bit set.
+	pcpOpAnnounce = 0
+	pcpOpMap      = 1
+	pcpOpResponse = 0x80
+
+	// pcpHeaderSize is the size of the common request and response header.
+	pcpHeaderSize = 24
+
+	// pcpMapSize is the size of the payload of MAP requests and responses.
+	pcpMapSize = 36
+
+	// pcpNonceSize is the size of the nonce identifying a mapping.
+	pcpNonceSize = 12
+
+	// The IANA protocol numbers of the protocols mappings can be requested
+	// for.
+	protocolTCP = 6
+	protocolUDP = 17
+)
+
+// pcpResultErrors maps the PCP result codes to human-readable errors.
+var pcpResultErrors = map[byte]string{
+	1:  "unsupported version",
+	2:  "not authorized",
+	3:  "malformed request",
+	4:  "unsupported opcode",
+	5:  "unsupported option",
+	6:  "malformed option",
+	7:  "network failure",
+	8:  "no resources",
+	9:  "unsupported protocol",
+	10: "user exceeded quota",
+	11: "cannot provide external address",
+	12: "address mismatch",
+	13: "excessive remote peers",
+}
+
+// pcpResultError returns the error of the passed PCP result code.
+func pcpResultError(code byte) error {
+	if str, ok := pcpResultErrors[code]; ok {
+		return fmt.Errorf("PCP: %s", str)
+	}
+	return fmt.Errorf("PCP: result code %d", code)
+}
+
+// errPCPUnsupported is returned when the gateway answers PCP requests with
+// NAT-PMP responses, which means it only speaks NAT-PMP.
+var errPCPUnsupported = errors.New("PCP: gateway only supports NAT-PMP")
+
+// pcpNAT implements the NAT interface for gateways which speak the Port
+// Control Protocol as described in RFC 6887.  For IPv6 gateways the mappings
+// are pinholes in the firewall of the gateway.
+type pcpNAT struct {
+	gateway *net.UDPAddr
+	client  *net.UDPAddr
+
+	mtx        sync.Mutex
+	nonces     map[string][pcpNonceSize]byte
+	externalIP net.IP
+}
+
+// DiscoverPCP returns the NAT of the passed gateway if it speaks PCP.
+func DiscoverPCP(gateway *net.IPAddr) (NAT, error) {
+	return newPCP(&net.UDPAddr{
+		IP:   gateway.IP,
+		Port: natpmpPort,
+		Zone: gateway.Zone,
+	})
+}
+
+// newPCP returns the NAT of the gateway at the passed address if it responds to
+// PCP requests.
+func newPCP(gateway *net.UDPAddr) (*pcpNAT, error) {
+	client, err := clientAddr(gateway)
+	if err != nil {
+		return nil, err
+	}
+	n := &pcpNAT{
+		gateway: gateway,
+		client:  client,
+		nonces:  make(map[string][pcpNonceSize]byte),
+	}
+	if _, err := n.request(pcpOpAnnounce, 0, nil); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// String returns the name of the protocol.  This is part of the NAT interface.
+func (n *pcpNAT) String() string {
+	return "PCP"
+}
+
+// request sends a request with the passed opcode, lifetime and payload to the
+// gateway.  It returns the response once its result code indicates success.
+func (n *pcpNAT) request(op byte, lifetime time.Duration, payload []byte) ([]byte, error) {
+	req := make([]byte, pcpHeaderSize+len(payload))
+	req[0] = pcpVersion
+	req[1] = op
+	binary.BigEndian.PutUint32(req[4:8], uint32(lifetime/time.Second))
+	copy(req[8:24], n.client.IP.To16())
+	copy(req[pcpHeaderSize:], payload)
+
+	resp, err := roundTrip(n.client, n.gateway, req, func(resp []byte) bool {
+		// Gateways which only speak NAT-PMP respond with their own
+		// version and an error.
+		if len(resp) >= 2 && resp[0] == natpmpVersion {
+			return true
+		}
+		if len(resp) < pcpHeaderSize+len(payload) ||
+			resp[0] != pcpVersion || resp[1] != pcpOpResponse|op {
+
+			return false
+		}
+		// The response to a MAP request must be for the same mapping.
+		nonceEnd := pcpHeaderSize + min(len(payload), pcpNonceSize)
+		return bytes.Equal(resp[pcpHeaderSize:nonceEnd],
+			req[pcpHeaderSize:nonceEnd])
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp[0] != pcpVersion {
+		return nil, errPCPUnsupported
+	}
+	if code := resp[3]; code != 0 {
+		return nil, pcpResultError(code)
+	}
+	return resp, nil
+}
+
+// ExternalAddress returns the external address of the gateway, which is learnt
+// from the responses to MAP requests.  This is part of the NAT interface.
+func (n *pcpNAT) ExternalAddress() (net.IP, error) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	if n.externalIP == nil {
+		return nil, errors.New("PCP: external address not known before " +
+			"first mapping")
+	}
+	return n.externalIP, nil
+}
+
+// nonce returns the nonce of the mapping of the passed protocol and internal
+// port.  Renewals and deletions of a mapping must use the nonce of its
+// creation.
+func (n *pcpNAT) nonce(protocol byte, internalPort int) ([pcpNonceSize]byte, error) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	key := fmt.Sprintf("%d:%d", protocol, internalPort)
+	nonce, ok := n.nonces[key]
+	if !ok {
+		if _, err := rand.Read(nonce[:]); err != nil {
+			return nonce, err
+		}
+		n.nonces[key] = nonce
+	}
+	return nonce, nil
+}
+
+// mapPort requests a mapping from the external port to the internal port.  A
+// lifetime of zero deletes the mapping.
+func (n *pcpNAT) mapPort(protocol string, internalPort, externalPort int,
+	lifetime time.Duration) (int, time.Duration, error) {
+
+	var proto byte
+	switch strings.ToLower(protocol) {
+	case "udp":
+		proto = protocolUDP
+	case "tcp":
+		proto = protocolTCP
+	default:
+		return 0, 0, fmt.Errorf("PCP: unsupported protocol %s", protocol)
+	}
+	nonce, err := n.nonce(proto, internalPort)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// The suggested external address is left unspecified, which is the
+	// IPv4-mapped zero address for IPv4 gateways.
+	payload := make([]byte, pcpMapSize)
+	copy(payload[0:12], nonce[:])
+	payload[12] = proto
+	binary.BigEndian.PutUint16(payload[16:18], uint16(internalPort))
+	binary.BigEndian.PutUint16(payload[18:20], uint16(externalPort))
+	if n.client.IP.To4() != nil {
+		copy(payload[20:36], net.IPv4zero.To16())
+	}
+	resp, err := n.request(pcpOpMap, lifetime, payload)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	granted := time.Duration(binary.BigEndian.Uint32(resp[4:8])) *
+		time.Second
+	mapping := resp[pcpHeaderSize:]
+	mappedPort := int(binary.BigEndian.Uint16(mapping[18:20]))
+	externalIP := net.IP(append([]byte(nil), mapping[20:36]...))
+	if ip4 := externalIP.To4(); ip4 != nil {
+		externalIP = ip4
+	}
+	if lifetime != 0 {
+		n.mtx.Lock()
+		n.externalIP = externalIP
+		n.mtx.Unlock()
+	}
+	return mappedPort, granted, nil
+}
+
+// AddPortMapping maps the external port to the internal port.  This is part of
+// the NAT interface.
+func (n *pcpNAT) AddPortMapping(protocol string, internalPort, externalPort int,
+	lifetime time.Duration) (int, time.Duration, error) {
+
+	return n.mapPort(protocol, internalPort, externalPort, lifetime)
+}
+
+// DeletePortMapping removes the mapping of the internal port, which is done by
+// requesting a mapping with a lifetime of zero.  This is part of the NAT
+// interface.
+func (n *pcpNAT) DeletePortMapping(protocol string, internalPort, externalPort int) error {
+	_, _, err := n.mapPort(protocol, internalPort, 0, 0)
+	return err
+}
@@ -1,4 +1,4 @@
-package main
+package nat
 
 // Upnp code taken from Taipei Torrent license is below:
 // Copyright (c) 2010 Jack Palevich. All rights reserved.
@@ -39,34 +39,23 @@ import (
 	"fmt"
 	"net"
 	"net/http"
-	"os"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// NAT is an interface representing a NAT traversal options for example UPNP or
-// NAT-PMP. It provides methods to query and manipulate this traversal to allow
-// access to services.
-type NAT interface {
-	// Get the external address from outside the NAT.
-	GetExternalAddress() (addr net.IP, err error)
-	// Add a port mapping for protocol ("udp" or "tcp") from external port to
-	// internal port with description lasting for timeout.
-	AddPortMapping(protocol string, externalPort, internalPort int, description string, timeout int) (mappedExternalPort int, err error)
-	// Remove a previously added port mapping from external port to
-	// internal port.
-	DeletePortMapping(protocol string, externalPort, internalPort int) (err error)
-}
+// upnpDescription is the description of the port mappings added with UPnP.
+const upnpDescription = "bchd listen port"
 
 type upnpNAT struct {
 	serviceURL string
 	ourIP      string
 }
 
-// Discover searches the local network for a UPnP router returning a NAT
+// DiscoverUPnP searches the local network for a UPnP router returning a NAT
 // for the network if so, nil if not.
-func Discover() (nat NAT, err error) {
+func DiscoverUPnP() (nat NAT, err error) {
 	ssdp, err := net.ResolveUDPAddr("udp4", "239.255.255.250:1900")
 	if err != nil {
 		return
@@ -127,7 +116,7 @@ func Discover() (nat NAT, err error) {
 			return
 		}
 		var ourIP string
-		ourIP, err = getOurIP()
+		ourIP, err = getOurIP(serviceURL)
 		if err != nil {
 			return
 		}
@@ -212,13 +201,22 @@ func getChildService(d *device, serviceType string) *service {
 	return nil
 }
 
-// getOurIP returns a best guess at what the local IP is.
-func getOurIP() (ip string, err error) {
-	hostname, err := os.Hostname()
+// getOurIP returns the local IP the router at the given service url is reached
+// from, which is the internal client of the port mappings.
+func getOurIP(serviceURL string) (ip string, err error) {
+	u, err := url.Parse(serviceURL)
 	if err != nil {
 		return
 	}
-	return net.LookupCNAME(hostname)
+	port := u.Port()
+	if port == "" {
+		port = "80"
+	}
+	localIP, err := localAddr(net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return
+	}
+	return localIP.String(), nil
 }
 
 // getServiceURL parses the xml description at the given root url to find the
@@ -334,9 +332,14 @@ type getExternalIPAddressResponse struct {
 	ExternalIPAddress string   `xml:"NewExternalIPAddress"`
 }
 
-// GetExternalAddress implements the NAT interface by fetching the external IP
+// String returns the name of the protocol.  This is part of the NAT interface.
+func (n *upnpNAT) String() string {
+	return "UPnP"
+}
+
+// ExternalAddress implements the NAT interface by fetching the external IP
 // from the UPnP router.
-func (n *upnpNAT) GetExternalAddress() (addr net.IP, err error) {
+func (n *upnpNAT) ExternalAddress() (addr net.IP, err error) {
 	message := "<u:GetExternalIPAddress xmlns:u=\"urn:schemas-upnp-org:service:WANIPConnection:1\"/>\r\n"
 	response, err := soapRequest(n.serviceURL, "GetExternalIPAddress", message)
 	if err != nil {
@@ -358,7 +361,10 @@ func (n *upnpNAT) GetExternalAddress() (addr net.IP, err error) {
 
 // AddPortMapping implements the NAT interface by setting up a port forwarding
 // from the UPnP router to the local machine with the given ports and protocol.
-func (n *upnpNAT) AddPortMapping(protocol string, externalPort, internalPort int, description string, timeout int) (mappedExternalPort int, err error) {
+// UPnP routers grant the requested lifetime.
+func (n *upnpNAT) AddPortMapping(protocol string, internalPort, externalPort int, lifetime time.Duration) (mappedExternalPort int, grantedLifetime time.Duration, err error) {
+	description := upnpDescription
+	timeout := int(lifetime / time.Second)
 	// A single concatenation would break ARM compilation.
 	message := "<u:AddPortMapping xmlns:u=\"urn:schemas-upnp-org:service:WANIPConnection:1\">\r\n" +
 		"<NewRemoteHost></NewRemoteHost><NewExternalPort>" + strconv.Itoa(externalPort)
@@ -380,13 +386,14 @@ func (n *upnpNAT) AddPortMapping(protocol string, externalPort, internalPort int
 	// it. Not sure about wildcard yet. miniupnpc just checks for error
 	// codes here.
 	mappedExternalPort = externalPort
+	grantedLifetime = lifetime
 	_ = response
 	return
 }
 
 // DeletePortMapping implements the NAT interface by removing up a port forwarding
 // from the UPnP router to the local machine with the given ports and.
-func (n *upnpNAT) DeletePortMapping(protocol string, externalPort, internalPort int) (err error) {
+func (n *upnpNAT) DeletePortMapping(protocol string, internalPort, externalPort int) (err error) {
 
 	message := "<u:DeletePortMapping xmlns:u=\"urn:schemas-upnp-org:service:WANIPConnection:1\">\r\n" +
 		"<NewRemoteHost></NewRemoteHost><NewExternalPort>" + strconv.Itoa(externalPort) +
@@ -0,0 +1,238 @@
+package netsync
+
+import (
+	"time"
+
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/database"
+	peerpkg "github.com/gcash/bchd/peer"
+	"github.com/gcash/bchd/wire"
+)
+
+// handleHeadersOnlyHeadersMsg handles block header messages in headers only
+// mode.  The headers are added to the chain as they are received and the blocks
+// of the main chain which still have to be indexed are requested from the peer.
+func (sm *SyncManager) handleHeadersOnlyHeadersMsg(hmsg *headersMsg) {
+	peer := hmsg.peer
+	msg := hmsg.headers
+	numHeaders := len(msg.Headers)
+	if numHeaders == 0 {
+		sm.fetchIndexBlocks(peer)
+		return
+	}
+
+	var finalHash *chainhash.Hash
+	for _, blockHeader := range msg.Headers {
+		blockHash := blockHeader.BlockHash()
+		finalHash = &blockHash
+
+		if !sm.processHeadersOnlyHeader(blockHeader, peer) {
+			return
+		}
+	}
+
+	if peer == sm.syncPeer {
+		sm.syncPeerState.lastBlockTime = time.Now()
+	}
+
+	// Update this peer's latest block height, for future potential sync
+	// node candidacy.
+	height, err := sm.chain.BlockHeightByHash(finalHash)
+	if err == nil && height > peer.LastBlock() {
+		peer.UpdateLastBlockHeight(height)
+	}
+
+	// A full headers message means the peer has more headers, so request
+	// the next batch starting from the final one.
+	if numHeaders == wire.MaxBlockHeadersPerMsg {
+		log.Infof("Downloaded block headers up to height %d from peer %s",
+			sm.chain.BestSnapshot().Height, peer.Addr())
+		locator := blockchain.BlockLocator([]*chainhash.Hash{finalHash})
+		err := peer.PushGetHeadersMsg(locator, &zeroHash)
+		if err != nil {
+			log.Warnf("Failed to send getheaders message to "+
+				"peer %s: %v", peer.Addr(), err)
+		}
+	}
+
+	sm.fetchIndexBlocks(peer)
+}
+
+// processHeadersOnlyHeader adds the passed block header received from the peer
+// to the chain in headers only mode.  It returns whether or not the header is
+// known to the chain afterwards.
+func (sm *SyncManager) processHeadersOnlyHeader(header *wire.BlockHeader, peer *peerpkg.Peer) bool {
+	_, err := sm.chain.ProcessBlockHeader(header, blockchain.BFNone)
+	if err == nil {
+		return true
+	}
+
+	blockHash := header.BlockHash()
+	rerr, ok := err.(blockchain.RuleError)
+	switch {
+	case ok && rerr.ErrorCode == blockchain.ErrDuplicateBlock:
+		return true
+
+	// The peer announced a header which does not connect to the known
+	// headers, so request the headers in between.
+	case ok && rerr.ErrorCode == blockchain.ErrPreviousBlockUnknown:
+		locator, err := sm.chain.LatestBlockLocator()
+		if err != nil {
+			log.Warnf("Failed to get block locator for the latest "+
+				"block: %v", err)
+			return false
+		}
+		peer.PushGetHeadersMsg(locator, &zeroHash)
+		return false
+
+	case ok:
+		log.Infof("Rejected block header %v from %s: %v -- "+
+			"disconnecting", blockHash, peer, err)
+		peer.Disconnect()
+		return false
+	}
+
+	log.Errorf("Failed to process block header %v: %v", blockHash, err)
+	if dbErr, ok := err.(database.Error); ok && dbErr.ErrorCode ==
+		database.ErrCorruption {
+		panic(dbErr)
+	}
+	return false
+}
+
+// handleHeadersOnlyBlockMsg handles block messages in headers only mode.  The
+// downloaded blocks are passed to the chain in order to index them and are
+// discarded afterwards.
+func (sm *SyncManager) handleHeadersOnlyBlockMsg(bmsg *blockMsg, state *peerSyncState) {
+	peer := bmsg.peer
+	blockHash := bmsg.block.Hash()
+
+	// If we didn't ask for this block then the peer is misbehaving, unless
+	// it is allowed to relay blocks directly.  The header of such a block
+	// may not be known yet.
+	if _, exists := state.requestedBlocks[*blockHash]; !exists {
+		if !peer.AllowDirectBlockRelay() &&
+			sm.chainParams != &chaincfg.RegressionNetParams {
+
+			log.Warnf("Got unrequested block %v from %s -- "+
+				"disconnecting", blockHash, peer.Addr())
+			peer.Disconnect()
+			return
+		}
+		if !sm.processHeadersOnlyHeader(&bmsg.block.MsgBlock().Header, peer) {
+			return
+		}
+	}
+	delete(state.requestedBlocks, *blockHash)
+	delete(sm.requestedBlocks, *blockHash)
+
+	// The block may have been removed from the main chain by a
+	// reorganization while it was requested.
+	if !sm.chain.MainChainHasBlock(blockHash) {
+		sm.fetchIndexBlocks(peer)
+		return
+	}
+	sm.pendingBlocks[*blockHash] = bmsg.block
+
+	if peer == sm.syncPeer {
+		sm.syncPeerState.lastBlockTime = time.Now()
+	}
+
+	// Index the downloaded blocks which follow the last indexed block.
+	best := sm.chain.BestSnapshot()
+	for {
+		_, tipHeight := sm.chain.HeadersOnlyBlockTip()
+		hash, err := sm.chain.BlockHashByHeight(tipHeight + 1)
+		if err != nil {
+			break
+		}
+		block, exists := sm.pendingBlocks[*hash]
+		if !exists {
+			break
+		}
+		delete(sm.pendingBlocks, *hash)
+
+		// A block which fails the checks against its header is dropped
+		// and requested again.
+		if err := sm.chain.IndexBlock(block); err != nil {
+			if _, ok := err.(blockchain.RuleError); ok {
+				log.Infof("Rejected block %v: %v", hash, err)
+			} else {
+				log.Errorf("Failed to index block %v: %v", hash, err)
+			}
+			if dbErr, ok := err.(database.Error); ok && dbErr.ErrorCode ==
+				database.ErrCorruption {
+				panic(dbErr)
+			}
+			break
+		}
+
+		sm.progressLogger.LogBlockHeight(block, uint64(best.Height), sm.chain)
+	}
+
+	// Drop the pending blocks which are no longer in the main chain.
+	for hash := range sm.pendingBlocks {
+		if !sm.chain.MainChainHasBlock(&hash) {
+			delete(sm.pendingBlocks, hash)
+		}
+	}
+
+	sm.fetchIndexBlocks(peer)
+}
+
+// fetchIndexBlocks requests the blocks of the main chain after the last indexed
+// block from the passed peer in headers only mode, unless enough blocks are
+// already being downloaded from it.
+func (sm *SyncManager) fetchIndexBlocks(peer *peerpkg.Peer) {
+	state, exists := sm.peerStates[peer]
+	if !exists || len(state.requestedBlocks) >= minInFlightBlocks {
+		return
+	}
+
+	_, tipHeight := sm.chain.HeadersOnlyBlockTip()
+	best := sm.chain.BestSnapshot()
+	gdmsg := wire.NewMsgGetData()
+	for height := tipHeight + 1; height <= best.Height; height++ {
+		hash, err := sm.chain.BlockHashByHeight(height)
+		if err != nil {
+			log.Warnf("Failed to fetch block hash at height %d: %v",
+				height, err)
+			break
+		}
+		if _, exists := sm.requestedBlocks[*hash]; exists {
+			continue
+		}
+		if _, exists := sm.pendingBlocks[*hash]; exists {
+			continue
+		}
+
+		sm.requestedBlocks[*hash] = struct{}{}
+		state.requestedBlocks[*hash] = struct{}{}
+		gdmsg.AddInvVect(wire.NewInvVect(wire.InvTypeBlock, hash))
+		if len(gdmsg.InvList) >= wire.MaxInvPerMsg {
+			break
+		}
+	}
+	if len(gdmsg.InvList) > 0 {
+		peer.QueueMessage(gdmsg, nil)
+	}
+}
+
+// requestAnnouncedHeaders requests the headers up to the passed block announced
+// by the peer in headers only mode when the block is not known yet.
+func (sm *SyncManager) requestAnnouncedHeaders(peer *peerpkg.Peer, hash *chainhash.Hash) {
+	haveBlock, err := sm.chain.HaveBlock(hash)
+	if err != nil || haveBlock {
+		return
+	}
+
+	locator, err := sm.chain.LatestBlockLocator()
+	if err != nil {
+		log.Warnf("Failed to get block locator for the latest block: %v",
+			err)
+		return
+	}
+	peer.PushGetHeadersMsg(locator, hash)
+}
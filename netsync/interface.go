@@ -43,5 +43,14 @@ type Config struct {
 
 	FastSyncMode bool
 
+	// HeadersOnlyMode syncs the block headers and only downloads the
+	// blocks to pass them to the index manager of the chain.
+	HeadersOnlyMode bool
+
+	// BlocksOnly indicates transactions are not relayed, so full blocks
+	// are requested instead of compact blocks since there is no mempool
+	// to reconstruct them from.
+	BlocksOnly bool
+
 	RegTestSyncAnyHost bool
 }
@@ -240,6 +240,10 @@ type SyncManager struct {
 	startHeader      *list.Element
 	nextCheckpoint   *chaincfg.Checkpoint
 
+	// assumeValidMode is set while the headers leading to the block which
+	// is assumed to be valid are downloaded from the sync peer.
+	assumeValidMode bool
+
 	// An optional fee estimator.
 	feeEstimator *mempool.FeeEstimator
 
@@ -256,6 +260,18 @@ type SyncManager struct {
 	// to make the standard getblocks request.
 	fastSyncMode bool
 
+	// headersOnlyMode syncs the chain from the block headers alone.  The
+	// blocks of the main chain are downloaded in order to pass them to the
+	// index manager of the chain, but they are not stored.  pendingBlocks
+	// holds the downloaded blocks which can not be indexed yet because a
+	// block before them is still missing.
+	headersOnlyMode bool
+	pendingBlocks   map[chainhash.Hash]*bchutil.Block
+
+	// blocksOnly disables requesting compact blocks since transactions
+	// are not relayed.
+	blocksOnly bool
+
 	// regTestSyncAnyHost allows any host when regression test network is
 	// being used.  For example, when running regression test network in
 	// docker containers the host is not a localhost.
@@ -266,6 +282,7 @@ type SyncManager struct {
 // syncing from a new peer.
 func (sm *SyncManager) resetHeaderState(newestHash *chainhash.Hash, newestHeight int32) {
 	sm.headersFirstMode = false
+	sm.assumeValidMode = false
 	sm.headerList.Init()
 	sm.startHeader = nil
 
@@ -399,7 +416,16 @@ func (sm *SyncManager) startSync() {
 		// and fully validate them.  Finally, regression test mode does
 		// not support the headers-first approach so do normal block
 		// downloads when in regression test mode.
-		if sm.nextCheckpoint != nil &&
+		if sm.headersOnlyMode {
+			// In headers only mode all of the headers are
+			// downloaded first and the blocks are requested as
+			// the headers become known.
+			bestPeer.PushGetHeadersMsg(locator, &zeroHash)
+			log.Infof("Downloading headers for blocks %d to %d "+
+				"from peer %s", best.Height+1, bestPeer.LastBlock(),
+				bestPeer.Addr())
+			sm.fetchIndexBlocks(bestPeer)
+		} else if sm.nextCheckpoint != nil &&
 			best.Height < sm.nextCheckpoint.Height &&
 			sm.chainParams != &chaincfg.RegressionNetParams {
 
@@ -422,8 +448,11 @@ func (sm *SyncManager) startSync() {
 			// in fast sync mode. If we are in fast sync mode we will
 			// set this bool to false once the UTXO download/verification
 			// finishes and then we can proceed as if we are syncing
-			// normally.
-			bestPeer.PushGetBlocksMsg(locator, &zeroHash)
+			// normally.  The headers leading to the block which is
+			// assumed to be valid are downloaded first when needed.
+			if !sm.fetchAssumeValidHeaders(bestPeer, locator) {
+				bestPeer.PushGetBlocksMsg(locator, &zeroHash)
+			}
 		}
 
 		bestPeer.SetSyncPeer(true)
@@ -534,6 +563,13 @@ func (sm *SyncManager) handleCheckSyncPeer() {
 		return
 	}
 
+	// Request the blocks which are still missing from the indexes in
+	// headers only mode, such as those the previous sync peer did not
+	// send.
+	if sm.headersOnlyMode {
+		sm.fetchIndexBlocks(sm.syncPeer)
+	}
+
 	// Check if a majority of our sync peer candidates are at a greater height than our current sync peer.
 	// If they are, it is time to select a new peer, as ours is obviously behind.
 	if sm.topBlock() < sm.medianSyncPeerCandidateBlockHeight() {
@@ -799,6 +835,11 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 		return
 	}
 
+	if sm.headersOnlyMode {
+		sm.handleHeadersOnlyBlockMsg(bmsg, state)
+		return
+	}
+
 	// If we didn't ask for this block then the peer is misbehaving.
 	blockHash := bmsg.block.Hash()
 
@@ -999,6 +1040,9 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 	sm.headerList.Init()
 	log.Infof("Reached the final checkpoint -- switching to normal mode")
 	locator := blockchain.BlockLocator([]*chainhash.Hash{blockHash})
+	if sm.fetchAssumeValidHeaders(peer, locator) {
+		return
+	}
 	err = peer.PushGetBlocksMsg(locator, &zeroHash)
 	if err != nil {
 		log.Warnf("Failed to send getblocks message to peer %s: %v",
@@ -1070,6 +1114,72 @@ func (sm *SyncManager) fetchHeaderBlocks() {
 	}
 }
 
+// fetchAssumeValidHeaders requests the headers leading to the block which is
+// assumed to be valid from the passed peer when the chain still needs them to
+// skip the script validation of the blocks before it.  It returns whether or
+// not the headers were requested.
+func (sm *SyncManager) fetchAssumeValidHeaders(peer *peerpkg.Peer, locator blockchain.BlockLocator) bool {
+	assumeValid := sm.chain.AssumeValidPending()
+	if assumeValid == nil {
+		return false
+	}
+
+	err := peer.PushGetHeadersMsg(locator, assumeValid)
+	if err != nil {
+		log.Warnf("Failed to send getheaders message to peer %s: %v",
+			peer.Addr(), err)
+		return false
+	}
+	sm.assumeValidMode = true
+	log.Infof("Downloading headers up to assumed valid block %s from "+
+		"peer %s", assumeValid, peer.Addr())
+	return true
+}
+
+// handleAssumeValidHeadersMsg handles block headers leading to the block which
+// is assumed to be valid.  More headers are requested until that block is
+// reached or the peer runs out of headers, after which the blocks are requested
+// as in normal mode.
+func (sm *SyncManager) handleAssumeValidHeadersMsg(hmsg *headersMsg) {
+	peer := hmsg.peer
+	headers := hmsg.headers.Headers
+	reached, err := sm.chain.ProcessAssumeValidHeaders(headers)
+	if err != nil {
+		log.Warnf("Received invalid headers from %s: %v -- "+
+			"disconnecting", peer.Addr(), err)
+		peer.Disconnect()
+		return
+	}
+
+	// Request the next batch of headers when the block is not reached yet
+	// and the peer might have more.
+	if !reached && len(headers) == wire.MaxBlockHeadersPerMsg {
+		finalHash := headers[len(headers)-1].BlockHash()
+		locator := blockchain.BlockLocator([]*chainhash.Hash{&finalHash})
+		if sm.fetchAssumeValidHeaders(peer, locator) {
+			return
+		}
+	}
+	if !reached {
+		log.Warnf("Peer %s does not have the assumed valid block -- "+
+			"validating all scripts", peer.Addr())
+	}
+
+	// Switch to requesting the blocks from the end of the main chain.
+	sm.assumeValidMode = false
+	locator, err := sm.chain.LatestBlockLocator()
+	if err != nil {
+		log.Errorf("Failed to get block locator for the latest block: "+
+			"%v", err)
+		return
+	}
+	err = peer.PushGetBlocksMsg(locator, &zeroHash)
+	if err != nil {
+		log.Warnf("Failed to send getblocks message to peer %s: %v",
+			peer.Addr(), err)
+	}
+}
+
 // handleHeadersMsg handles block header messages from all peers.  Headers are
 // requested when performing a headers-first sync.
 func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
@@ -1080,6 +1190,16 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 		return
 	}
 
+	if sm.headersOnlyMode {
+		sm.handleHeadersOnlyHeadersMsg(hmsg)
+		return
+	}
+
+	if sm.assumeValidMode && peer == sm.syncPeer {
+		sm.handleAssumeValidHeadersMsg(hmsg)
+		return
+	}
+
 	// The remote peer is misbehaving if we didn't request headers.
 	msg := hmsg.headers
 	numHeaders := len(msg.Headers)
@@ -1336,6 +1456,16 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 			continue
 		}
 
+		// Blocks are learned about from their headers in headers only
+		// mode, so request the headers up to the final announced block
+		// instead of the blocks.
+		if sm.headersOnlyMode && iv.Type == wire.InvTypeBlock {
+			if i == lastBlock {
+				sm.requestAnnouncedHeaders(peer, &iv.Hash)
+			}
+			continue
+		}
+
 		// Request the inventory if we don't already have it.
 		haveInv, err := sm.haveInventory(iv)
 		if err != nil {
@@ -1419,7 +1549,9 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 				state.requestedBlocks[iv.Hash] = struct{}{}
 
 				// Request a compact block if this peer supports it.
-				if sm.current() && imsg.peer.ProtocolVersion() >= wire.BIP0152Version {
+				if sm.current() && !sm.blocksOnly &&
+					imsg.peer.ProtocolVersion() >= wire.BIP0152Version {
+
 					iv.Type = wire.InvTypeCmpctBlock
 				}
 				gdmsg.AddInvVect(iv)
@@ -1479,6 +1611,15 @@ func (sm *SyncManager) blockHandler() {
 
 out:
 	for {
+		// Stop before handling further queued messages once the manager
+		// is shutting down, since there may be many queued blocks during
+		// the initial block download which would delay the shutdown.
+		select {
+		case <-sm.quit:
+			break out
+		default:
+		}
+
 		select {
 		case <-ticker.C:
 			sm.handleCheckSyncPeer()
@@ -1636,6 +1777,13 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 			}
 		}
 
+		// Weak blocks built on the previous tip are stale now.
+		sm.txMemPool.PruneWeakBlocks(block.Hash())
+
+		// Remove the transactions which have been in the pool for
+		// too long.
+		sm.txMemPool.ExpireTransactions()
+
 	// A block has been disconnected from the main block chain.
 	case blockchain.NTBlockDisconnected:
 		block, ok := notification.Data.(*bchutil.Block)
@@ -1661,6 +1809,9 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 		if sm.feeEstimator != nil {
 			sm.feeEstimator.Rollback(block.Hash())
 		}
+
+		// Weak blocks built on the disconnected block are stale now.
+		sm.txMemPool.PruneWeakBlocks(&block.MsgBlock().Header.PrevBlock)
 	}
 }
 
@@ -1828,11 +1979,18 @@ func New(config *Config) (*SyncManager, error) {
 		feeEstimator:            config.FeeEstimator,
 		minSyncPeerNetworkSpeed: config.MinSyncPeerNetworkSpeed,
 		fastSyncMode:            config.FastSyncMode,
+		headersOnlyMode:         config.HeadersOnlyMode,
+		pendingBlocks:           make(map[chainhash.Hash]*bchutil.Block),
+		blocksOnly:              config.BlocksOnly,
 		regTestSyncAnyHost:      config.RegTestSyncAnyHost,
 	}
 
+	// The headers-first mode is not used in headers only mode since the
+	// chain checks the headers against the checkpoints itself.
 	best := sm.chain.BestSnapshot()
-	if !config.DisableCheckpoints {
+	if config.HeadersOnlyMode {
+		log.Info("Syncing block headers only")
+	} else if !config.DisableCheckpoints {
 		// Initialize the next checkpoint based on the current height.
 		sm.nextCheckpoint = sm.findNextHeaderCheckpoint(best.Height)
 		if sm.nextCheckpoint != nil {
@@ -0,0 +1,224 @@
+package node
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/mempool"
+)
+
+const (
+	// DefaultDbType is the default type of the block database.
+	DefaultDbType = "ffldb"
+
+	// DefaultDBCacheSize is the default size in MiB of the database cache.
+	DefaultDBCacheSize = 500
+
+	// DefaultDBFlushInterval is the default number of seconds between
+	// database flushes.
+	DefaultDBFlushInterval = 1800
+
+	// DefaultUtxoCacheMaxSize is the default maximum size in bytes of the
+	// UTXO cache.
+	DefaultUtxoCacheMaxSize = 450 * 1024 * 1024
+
+	// DefaultPruneDepth is the default number of blocks retained when
+	// pruning is enabled.
+	DefaultPruneDepth = 4320
+
+	// DefaultMaxPeers is the default maximum number of peers.
+	DefaultMaxPeers = 125
+
+	// DefaultTargetOutbound is the default number of outbound connections
+	// maintained.
+	DefaultTargetOutbound = 8
+
+	// DefaultSigCacheMaxSize is the default maximum number of entries of
+	// the signature cache.
+	DefaultSigCacheMaxSize = 100000
+
+	// DefaultScriptCacheMaxSize is the default maximum number of entries
+	// of the script cache.
+	DefaultScriptCacheMaxSize = 100000
+
+	// DefaultExcessiveBlockSize is the default maximum size in bytes of
+	// blocks accepted by the node.
+	DefaultExcessiveBlockSize = 32000000
+
+	// defaultConnectTimeout is the timeout of outbound connections made by
+	// the default dial function.
+	defaultConnectTimeout = time.Second * 30
+
+	// connectionRetryInterval is the base amount of time to wait in
+	// between retries when connecting to persistent peers.
+	connectionRetryInterval = time.Second * 5
+
+	// minPruneDepth is the minimum number of blocks retained when pruning
+	// is enabled.
+	minPruneDepth = 288
+
+	// userAgentName is the user agent name and is used to help identify
+	// embedded nodes to other bitcoin peers.
+	userAgentName = "/bchd"
+
+	// userAgentComment identifies the node as an embedded one in the
+	// comments of the user agent.
+	userAgentComment = "embedded"
+)
+
+// Config is a descriptor containing the configuration of a node.  Only the
+// chain parameters and the data directory are required, the other fields
+// default to the defaults of bchd when they are left unset.
+type Config struct {
+	// ChainParams identifies the network the node is part of.
+	ChainParams *chaincfg.Params
+
+	// DataDir is the directory the block database and the known peer
+	// addresses are stored in.  It should be specific to the network since
+	// the data of different networks can't be stored together.
+	DataDir string
+
+	// DbType is the type of the block database.  It defaults to
+	// DefaultDbType.
+	DbType string
+
+	// DBCacheSize is the size in MiB of the database cache and
+	// DBFlushInterval is the number of seconds between database flushes.
+	DBCacheSize     uint64
+	DBFlushInterval uint32
+
+	// UtxoCacheMaxSize is the maximum size in bytes of the UTXO cache.
+	UtxoCacheMaxSize uint64
+
+	// Prune deletes historical blocks from the database, except for the
+	// last PruneDepth blocks, which makes the node a pruned node.
+	Prune      bool
+	PruneDepth uint32
+
+	// ExcessiveBlockSize is the maximum size in bytes of the blocks
+	// accepted by the node.
+	ExcessiveBlockSize uint32
+
+	// ConnectPeers are the only peers the node connects to when it is not
+	// empty.  Otherwise, peers are discovered through the DNS seeds of the
+	// network and the addresses advertised by other peers.  AddPeers are
+	// connected to in addition to the discovered peers.  The peers are
+	// specified as host:port and are reconnected to when disconnected.
+	ConnectPeers []string
+	AddPeers     []string
+
+	// Listeners are the addresses inbound connections are accepted on.
+	// No inbound connections are accepted when it is empty.
+	Listeners []string
+
+	// MaxPeers is the maximum number of inbound and outbound peers and
+	// TargetOutbound is the number of outbound connections maintained to
+	// discovered peers.
+	MaxPeers       int
+	TargetOutbound uint32
+
+	// BlocksOnly does not accept transactions from peers and only
+	// downloads full blocks.
+	BlocksOnly bool
+
+	// Policy is the policy of the memory pool.  It defaults to the policy
+	// returned by DefaultPolicy.
+	Policy *mempool.Policy
+
+	// SigCacheMaxSize and ScriptCacheMaxSize are the maximum number of
+	// entries of the signature and script verification caches.
+	SigCacheMaxSize    uint
+	ScriptCacheMaxSize uint
+
+	// UserAgentComments are appended to the user agent of the node.
+	UserAgentComments []string
+
+	// Dial connects to the passed address.  It defaults to a TCP dialer
+	// with a timeout of 30 seconds.
+	Dial func(net.Addr) (net.Conn, error)
+
+	// Lookup resolves the IPs of the passed host.  It defaults to
+	// net.LookupIP.
+	Lookup func(string) ([]net.IP, error)
+}
+
+// DefaultPolicy returns the default memory pool policy of bchd.
+func DefaultPolicy() *mempool.Policy {
+	return &mempool.Policy{
+		MaxOrphanTxs:    100,
+		MaxOrphanTxSize: 100000,
+		MaxPoolSize:     300 * 1000 * 1000,
+		LimitSigChecks:  true,
+		MinRelayTxFee:   mempool.DefaultMinRelayTxFee,
+		DustRelayFee:    mempool.DefaultDustRelayFee,
+		MaxTxSigChecks:  mempool.DefaultMaxTxSigChecks,
+		Expiry:          mempool.DefaultMempoolExpiry,
+		MaxTxVersion:    2,
+		DataCarrier:     mempool.DefaultDataCarrierPolicy,
+	}
+}
+
+// withDefaults returns a copy of the configuration with the defaults of the
+// fields which are left unset applied.  An error is returned when the
+// configuration is invalid.
+func (cfg Config) withDefaults() (*Config, error) {
+	if cfg.ChainParams == nil {
+		return nil, errors.New("chain parameters are required")
+	}
+	if cfg.DataDir == "" {
+		return nil, errors.New("data directory is required")
+	}
+	if cfg.DbType == "" {
+		cfg.DbType = DefaultDbType
+	}
+	if cfg.DBCacheSize == 0 {
+		cfg.DBCacheSize = DefaultDBCacheSize
+	}
+	if cfg.DBFlushInterval == 0 {
+		cfg.DBFlushInterval = DefaultDBFlushInterval
+	}
+	if cfg.UtxoCacheMaxSize == 0 {
+		cfg.UtxoCacheMaxSize = DefaultUtxoCacheMaxSize
+	}
+	if cfg.PruneDepth == 0 {
+		cfg.PruneDepth = DefaultPruneDepth
+	}
+	if cfg.Prune && cfg.PruneDepth < minPruneDepth {
+		return nil, errors.New("prune depth may not be less than 288")
+	}
+	if cfg.ExcessiveBlockSize == 0 {
+		cfg.ExcessiveBlockSize = DefaultExcessiveBlockSize
+	}
+	if cfg.MaxPeers == 0 {
+		cfg.MaxPeers = DefaultMaxPeers
+	}
+	if cfg.TargetOutbound == 0 {
+		cfg.TargetOutbound = DefaultTargetOutbound
+	}
+	if cfg.MaxPeers < int(cfg.TargetOutbound) {
+		cfg.TargetOutbound = uint32(cfg.MaxPeers)
+	}
+	if cfg.Policy == nil {
+		cfg.Policy = DefaultPolicy()
+	}
+	if cfg.SigCacheMaxSize == 0 {
+		cfg.SigCacheMaxSize = DefaultSigCacheMaxSize
+	}
+	if cfg.ScriptCacheMaxSize == 0 {
+		cfg.ScriptCacheMaxSize = DefaultScriptCacheMaxSize
+	}
+	cfg.UserAgentComments = append([]string{userAgentComment},
+		cfg.UserAgentComments...)
+	if cfg.Dial == nil {
+		cfg.Dial = func(addr net.Addr) (net.Conn, error) {
+			return net.DialTimeout(addr.Network(), addr.String(),
+				defaultConnectTimeout)
+		}
+	}
+	if cfg.Lookup == nil {
+		cfg.Lookup = net.LookupIP
+	}
+	return &cfg, nil
+}
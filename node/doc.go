@@ -0,0 +1,32 @@
+/*
+Package node implements a Bitcoin Cash node which is embedded in the process of
+a Go application instead of being run as the separate bchd daemon.
+
+A node is constructed from a Config, without a configuration file or command
+line flags, and is started and stopped programmatically.  It opens its block
+database in the data directory, validates the chain, keeps a memory pool of
+unconfirmed transactions and syncs with the network through the same blockchain,
+mempool and netsync packages as bchd.  The application has direct, in-process
+access to them:
+
+	n, err := node.New(&node.Config{
+		ChainParams: &chaincfg.MainNetParams,
+		DataDir:     "/var/lib/myapp/mainnet",
+		Prune:       true,
+	})
+	if err != nil {
+		return err
+	}
+	n.Start()
+	defer n.Stop()
+
+	best := n.Chain().BestSnapshot()
+
+The node is either a full node or, when pruning is enabled, a pruned node.  It
+connects to the configured peers, or to peers discovered through the DNS seeds
+and address gossip, and optionally accepts inbound connections.  Peers are
+served blocks, transactions and headers, while the features of bchd which are
+not needed to sync and validate the chain, such as the optional indexes, the
+RPC servers, bloom filters, compact filters and mining, are not included.
+*/
+package node
@@ -0,0 +1,562 @@
+package node
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gcash/bchd/addrmgr"
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/connmgr"
+	"github.com/gcash/bchd/database"
+	_ "github.com/gcash/bchd/database/ffldb" // The default block database.
+	"github.com/gcash/bchd/mempool"
+	"github.com/gcash/bchd/netsync"
+	"github.com/gcash/bchd/peer"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// Node is a Bitcoin Cash full node, or pruned node, which runs in the process
+// of the application embedding it.
+type Node struct {
+	started  int32
+	shutdown int32
+
+	cfg         *Config
+	db          database.DB
+	timeSource  blockchain.MedianTimeSource
+	chain       *blockchain.BlockChain
+	txMemPool   *mempool.TxPool
+	syncManager *netsync.SyncManager
+	addrManager *addrmgr.AddrManager
+	connManager *connmgr.ConnManager
+	services    wire.ServiceFlag
+
+	peersMtx sync.RWMutex
+	peers    map[*nodePeer]struct{}
+
+	wg   sync.WaitGroup
+	quit chan struct{}
+}
+
+// New returns a new node with the passed configuration.  It opens, or creates,
+// the block database and loads the chain, which may take a while when the
+// chain state has to be caught up with the database.  The node must be started
+// with Start to connect to peers and stopped with Stop to close the database.
+func New(config *Config) (*Node, error) {
+	cfg, err := config.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	n := Node{
+		cfg:        cfg,
+		timeSource: blockchain.NewMedianTime(),
+		services:   wire.SFNodeNetwork | wire.SFNodeBitcoinCash,
+		peers:      make(map[*nodePeer]struct{}),
+		quit:       make(chan struct{}),
+	}
+
+	n.db, err = loadBlockDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Close the database when any of the remaining setup fails since the
+	// node won't be stopped.
+	var setupErr error
+	defer func() {
+		if setupErr != nil {
+			n.db.Close()
+		}
+	}()
+
+	// The caches are shared by the chain and the memory pool, so the
+	// scripts of transactions accepted to the memory pool are not validated
+	// again when they are mined.
+	sigCache := txscript.NewSigCache(cfg.SigCacheMaxSize)
+	hashCache := txscript.NewHashCache(cfg.SigCacheMaxSize)
+	scriptCache := txscript.NewScriptCache(cfg.ScriptCacheMaxSize)
+
+	n.chain, setupErr = blockchain.New(&blockchain.Config{
+		DB:                 n.db,
+		UtxoCacheMaxSize:   cfg.UtxoCacheMaxSize,
+		Interrupt:          n.quit,
+		ChainParams:        cfg.ChainParams,
+		TimeSource:         n.timeSource,
+		SigCache:           sigCache,
+		HashCache:          hashCache,
+		ScriptCache:        scriptCache,
+		ExcessiveBlockSize: cfg.ExcessiveBlockSize,
+		Prune:              cfg.Prune,
+		PruneDepth:         cfg.PruneDepth,
+	})
+	if setupErr != nil {
+		return nil, setupErr
+	}
+	if n.chain.IsPruned() {
+		n.services &^= wire.SFNodeNetwork
+		n.services |= wire.SFNodeNetworkLimited
+	}
+
+	feeEstimator := mempool.NewFeeEstimator(
+		mempool.DefaultEstimateFeeMaxRollback,
+		mempool.DefaultEstimateFeeMinRegisteredBlocks)
+	n.txMemPool = mempool.New(&mempool.Config{
+		Policy:         *cfg.Policy,
+		ChainParams:    cfg.ChainParams,
+		FetchUtxoView:  n.chain.FetchUtxoView,
+		BestHeight:     func() int32 { return n.chain.BestSnapshot().Height },
+		MedianTimePast: func() time.Time { return n.chain.BestSnapshot().MedianTime },
+		CalcSequenceLock: func(tx *bchutil.Tx, view *blockchain.UtxoViewpoint) (*blockchain.SequenceLock, error) {
+			return n.chain.CalcSequenceLock(tx, view, true)
+		},
+		IsDeploymentActive:   n.chain.IsDeploymentActive,
+		SigCache:             sigCache,
+		HashCache:            hashCache,
+		ScriptCache:          scriptCache,
+		NextBlockScriptFlags: n.chain.NextBlockScriptFlags,
+		FeeEstimator:         feeEstimator,
+	})
+
+	n.syncManager, setupErr = netsync.New(&netsync.Config{
+		PeerNotifier: &n,
+		Chain:        n.chain,
+		TxMemPool:    n.txMemPool,
+		ChainParams:  cfg.ChainParams,
+		MaxPeers:     cfg.MaxPeers,
+		FeeEstimator: feeEstimator,
+		BlocksOnly:   cfg.BlocksOnly,
+	})
+	if setupErr != nil {
+		return nil, setupErr
+	}
+
+	n.addrManager = addrmgr.New(cfg.DataDir, cfg.Lookup)
+
+	var listeners []net.Listener
+	for _, addr := range cfg.Listeners {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			setupErr = err
+			return nil, setupErr
+		}
+		listeners = append(listeners, listener)
+	}
+
+	var newAddressFunc func() (net.Addr, error)
+	if n.discoverPeers() {
+		newAddressFunc = n.newAddress
+	}
+
+	n.connManager, setupErr = connmgr.New(&connmgr.Config{
+		Listeners:      listeners,
+		OnAccept:       n.inboundPeerConnected,
+		RetryDuration:  connectionRetryInterval,
+		TargetOutbound: cfg.TargetOutbound,
+		Dial:           cfg.Dial,
+		OnConnection:   n.outboundPeerConnected,
+		GetNewAddress:  newAddressFunc,
+	})
+	if setupErr != nil {
+		for _, l := range listeners {
+			l.Close()
+		}
+		return nil, setupErr
+	}
+
+	return &n, nil
+}
+
+// loadBlockDB opens the block database of the passed configuration, creating
+// it when it does not exist yet.
+func loadBlockDB(cfg *Config) (database.DB, error) {
+	dbPath := filepath.Join(cfg.DataDir, "blocks_"+cfg.DbType)
+	network := cfg.ChainParams.Net
+	cacheSize := cfg.DBCacheSize * 1024 * 1024
+
+	log.Infof("Loading block database from '%s'", dbPath)
+	db, err := database.Open(cfg.DbType, dbPath, network, cacheSize,
+		cfg.DBFlushInterval)
+	if err != nil {
+		// Return the error if it's not because the database doesn't
+		// exist.
+		if dbErr, ok := err.(database.Error); !ok || dbErr.ErrorCode !=
+			database.ErrDbDoesNotExist {
+
+			return nil, err
+		}
+
+		// Create the db if it does not exist.
+		if err := os.MkdirAll(cfg.DataDir, 0700); err != nil {
+			return nil, err
+		}
+		db, err = database.Create(cfg.DbType, dbPath, network, cacheSize,
+			cfg.DBFlushInterval)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// Start connects the node to the network and begins syncing the chain.
+func (n *Node) Start() {
+	// Already started?
+	if atomic.AddInt32(&n.started, 1) != 1 {
+		return
+	}
+
+	log.Trace("Starting node")
+	n.addrManager.Start()
+	n.syncManager.Start()
+
+	// Discover peers through the DNS seeds when they are not configured.
+	cfg := n.cfg
+	if n.discoverPeers() {
+		connmgr.SeedFromDNS(cfg.ChainParams, wire.SFNodeNetwork,
+			cfg.Lookup, func(addrs []*wire.NetAddress) {
+				// Bitcoind uses a lookup of the dns seeder here.
+				// This is rather strange since the values looked
+				// up by the DNS seed lookups will vary quite a lot.
+				// to replicate this behaviour we put all addresses
+				// as having come from the first one.
+				n.addrManager.AddAddresses(addrs, addrs[0])
+			})
+	}
+
+	go n.connManager.Start()
+
+	// Connect to the configured peers as persistent peers.
+	permanentPeers := cfg.ConnectPeers
+	if len(permanentPeers) == 0 {
+		permanentPeers = cfg.AddPeers
+	}
+	for _, addr := range permanentPeers {
+		netAddr, err := net.ResolveTCPAddr("tcp", addr)
+		if err != nil {
+			log.Warnf("Unable to resolve peer %s: %v", addr, err)
+			continue
+		}
+		go n.connManager.Connect(&connmgr.ConnReq{
+			Addr:      netAddr,
+			Permanent: true,
+		})
+	}
+}
+
+// Stop disconnects the node from the network, flushes the chain state and
+// closes the block database.  The node can't be restarted once it is stopped.
+func (n *Node) Stop() error {
+	// Make sure this only happens once.
+	if atomic.AddInt32(&n.shutdown, 1) != 1 {
+		return nil
+	}
+
+	log.Info("Node shutting down")
+	close(n.quit)
+
+	n.connManager.Stop()
+	if atomic.LoadInt32(&n.started) != 0 {
+		n.connManager.Wait()
+		n.peersMtx.RLock()
+		for np := range n.peers {
+			np.Disconnect()
+		}
+		n.peersMtx.RUnlock()
+		n.wg.Wait()
+
+		n.syncManager.Stop()
+		n.addrManager.Stop()
+	}
+
+	return n.db.Close()
+}
+
+// Chain returns the block chain of the node.
+func (n *Node) Chain() *blockchain.BlockChain {
+	return n.chain
+}
+
+// TxMemPool returns the memory pool of the node.
+func (n *Node) TxMemPool() *mempool.TxPool {
+	return n.txMemPool
+}
+
+// SyncManager returns the sync manager of the node, which processes the blocks
+// and transactions received from peers.
+func (n *Node) SyncManager() *netsync.SyncManager {
+	return n.syncManager
+}
+
+// ConnectedCount returns the number of peers the node is connected to.
+//
+// This function is safe for concurrent access.
+func (n *Node) ConnectedCount() int {
+	n.peersMtx.RLock()
+	defer n.peersMtx.RUnlock()
+
+	return len(n.peers)
+}
+
+// SubmitTransaction adds the passed transaction to the memory pool and
+// announces it, along with any orphans it allows to be accepted, to the peers.
+//
+// This function is safe for concurrent access.
+func (n *Node) SubmitTransaction(tx *bchutil.Tx) error {
+	acceptedTxs, err := n.txMemPool.ProcessTransaction(tx, false, false, 0)
+	if err != nil {
+		return err
+	}
+	n.AnnounceNewTransactions(acceptedTxs)
+	return nil
+}
+
+// discoverPeers returns whether the node discovers peers to connect to, which
+// it does unless it only connects to the configured peers.  Peers of the
+// regression test and simulation networks are never discovered.
+func (n *Node) discoverPeers() bool {
+	return len(n.cfg.ConnectPeers) == 0 &&
+		n.cfg.ChainParams != &chaincfg.RegressionNetParams &&
+		n.cfg.ChainParams != &chaincfg.SimNetParams
+}
+
+// newAddress returns the address of a peer discovered by the address manager
+// to connect to.
+func (n *Node) newAddress() (net.Addr, error) {
+	for tries := 0; tries < 100; tries++ {
+		addr := n.addrManager.GetAddress()
+		if addr == nil {
+			break
+		}
+		na := addr.NetAddress()
+
+		// Avoid addresses which were attempted recently and addresses
+		// with non-default ports unless there are no other options.
+		if tries < 30 && time.Since(addr.LastAttempt()) < 10*time.Minute {
+			continue
+		}
+		if tries < 50 && strconv.Itoa(int(na.Port)) !=
+			n.cfg.ChainParams.DefaultPort {
+			continue
+		}
+
+		n.addrManager.Attempt(na)
+		return net.ResolveTCPAddr("tcp", addrmgr.NetAddressKey(na))
+	}
+
+	return nil, errors.New("no valid connect address")
+}
+
+// inboundPeerConnected is invoked by the connection manager when a new inbound
+// connection is established.
+func (n *Node) inboundPeerConnected(conn net.Conn) {
+	if atomic.LoadInt32(&n.shutdown) != 0 {
+		conn.Close()
+		return
+	}
+
+	np := newNodePeer(n, nil)
+	np.Peer = peer.NewInboundPeer(np.newPeerConfig())
+	np.AssociateConnection(conn)
+	n.wg.Add(1)
+	go n.peerDoneHandler(np)
+}
+
+// outboundPeerConnected is invoked by the connection manager when a new
+// outbound connection is established.
+func (n *Node) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
+	if atomic.LoadInt32(&n.shutdown) != 0 {
+		conn.Close()
+		return
+	}
+
+	np := newNodePeer(n, c)
+	p, err := peer.NewOutboundPeer(np.newPeerConfig(), c.Addr.String())
+	if err != nil {
+		log.Debugf("Cannot create outbound peer %s: %v", c.Addr, err)
+		n.connectionDone(c)
+		return
+	}
+	np.Peer = p
+	np.AssociateConnection(conn)
+	n.wg.Add(1)
+	go n.peerDoneHandler(np)
+}
+
+// connectionDone informs the connection manager the passed outbound connection
+// is done so it is retried when persistent and replaced otherwise.
+func (n *Node) connectionDone(c *connmgr.ConnReq) {
+	if c.Permanent {
+		n.connManager.Disconnect(c.ID())
+		return
+	}
+	n.connManager.Remove(c.ID())
+	go n.connManager.NewConnReq()
+}
+
+// addPeer adds the passed peer, which completed the version handshake, to the
+// connected peers and hands it to the sync manager.  Peers exceeding the
+// maximum number of peers are disconnected.
+func (n *Node) addPeer(np *nodePeer) {
+	n.peersMtx.Lock()
+	if len(n.peers) >= n.cfg.MaxPeers || atomic.LoadInt32(&n.shutdown) != 0 {
+		n.peersMtx.Unlock()
+		log.Infof("Max peers reached [%d] - disconnecting peer %s",
+			n.cfg.MaxPeers, np)
+		np.Disconnect()
+		return
+	}
+	n.peers[np] = struct{}{}
+	n.peersMtx.Unlock()
+
+	log.Debugf("New peer %s", np)
+	n.syncManager.NewPeer(np.Peer, nil)
+
+	if !np.Inbound() {
+		n.addrManager.Good(np.NA())
+
+		// Request known addresses if the address manager needs more.
+		if n.addrManager.NeedMoreAddresses() &&
+			np.ProtocolVersion() >= wire.NetAddressTimeVersion {
+
+			np.QueueMessage(wire.NewMsgGetAddr(), nil)
+		}
+	}
+}
+
+// peerDoneHandler waits for the passed peer to disconnect and removes it from
+// the connected peers and the sync manager.
+func (n *Node) peerDoneHandler(np *nodePeer) {
+	defer n.wg.Done()
+
+	np.WaitForDisconnect()
+
+	n.peersMtx.Lock()
+	_, added := n.peers[np]
+	delete(n.peers, np)
+	n.peersMtx.Unlock()
+
+	if np.connReq != nil && atomic.LoadInt32(&n.shutdown) == 0 {
+		n.connectionDone(np.connReq)
+	}
+	if added {
+		n.syncManager.DonePeer(np.Peer, nil)
+		n.txMemPool.RemoveOrphansByTag(mempool.Tag(np.ID()))
+		log.Debugf("Removed peer %s", np)
+	}
+}
+
+// forAllPeers calls the passed function with each connected peer.
+func (n *Node) forAllPeers(f func(np *nodePeer)) {
+	n.peersMtx.RLock()
+	defer n.peersMtx.RUnlock()
+
+	for np := range n.peers {
+		f(np)
+	}
+}
+
+// AnnounceNewTransactions announces the passed transactions, which were newly
+// accepted to the memory pool, to the peers.
+//
+// This is part of the netsync.PeerNotifier interface implementation.
+func (n *Node) AnnounceNewTransactions(txns []*mempool.TxDesc) {
+	for _, txD := range txns {
+		iv := wire.NewInvVect(wire.InvTypeTx, txD.Tx.Hash())
+		n.RelayInventory(iv, txD)
+	}
+}
+
+// UpdatePeerHeights updates the heights of all peers which announced the passed
+// block, other than the peer it was received from.
+//
+// This is part of the netsync.PeerNotifier interface implementation.
+func (n *Node) UpdatePeerHeights(latestBlkHash *chainhash.Hash, latestHeight int32, updateSource *peer.Peer) {
+	n.forAllPeers(func(np *nodePeer) {
+		// The origin peer should already have the updated height.
+		if np.Peer == updateSource {
+			return
+		}
+
+		// If the peer has recently announced a block, and this block
+		// matches our newly accepted block, then update their block
+		// height.
+		lastAnnounced := np.LastAnnouncedBlock()
+		if lastAnnounced != nil && *lastAnnounced == *latestBlkHash {
+			np.UpdateLastBlockHeight(latestHeight)
+			np.UpdateLastAnnouncedBlock(nil)
+		}
+	})
+}
+
+// RelayInventory relays the passed inventory vector to all peers which are not
+// already known to have it.
+//
+// This is part of the netsync.PeerNotifier interface implementation.
+func (n *Node) RelayInventory(invVect *wire.InvVect, data interface{}) {
+	n.forAllPeers(func(np *nodePeer) {
+		if !np.Connected() {
+			return
+		}
+
+		if invVect.Type == wire.InvTypeBlock && np.WantsHeaders() {
+			block, ok := data.(*wire.MsgBlock)
+			if !ok {
+				log.Warnf("Underlying data for block inv relay "+
+					"is not a *wire.MsgBlock: %T", data)
+				return
+			}
+			msgHeaders := wire.NewMsgHeaders()
+			if err := msgHeaders.AddBlockHeader(&block.Header); err != nil {
+				log.Errorf("Failed to add block header: %v", err)
+				return
+			}
+			np.QueueMessage(msgHeaders, nil)
+			return
+		}
+
+		if invVect.Type == wire.InvTypeTx {
+			// Don't relay the transaction to the peer when it has
+			// transaction relaying disabled or its fee rate is below
+			// the fee filter of the peer.
+			if np.relayTxDisabled() {
+				return
+			}
+			txD, ok := data.(*mempool.TxDesc)
+			if !ok {
+				log.Warnf("Underlying data for tx inv relay is "+
+					"not a *mempool.TxDesc: %T", data)
+				return
+			}
+			feeFilter := atomic.LoadInt64(&np.feeFilter)
+			if feeFilter > 0 && txD.FeePerKB < feeFilter {
+				return
+			}
+		}
+
+		// Queue the inventory to be relayed with the next batch.
+		// It will be ignored if the peer is already known to
+		// have the inventory.
+		np.QueueInventory(invVect)
+	})
+}
+
+// TransactionConfirmed is invoked when a transaction the node announced was
+// confirmed.  The node does not rebroadcast transactions, so there is nothing
+// to do.
+//
+// This is part of the netsync.PeerNotifier interface implementation.
+func (n *Node) TransactionConfirmed(tx *bchutil.Tx) {}
@@ -0,0 +1,160 @@
+package node
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/integration/rpctest"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// TestConfigDefaults ensures the required fields of the configuration are
+// checked and the defaults are applied to the unset fields.
+func TestConfigDefaults(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	tests := []struct {
+		name  string
+		cfg   Config
+		valid bool
+	}{
+		{"no chain params", Config{DataDir: "data"}, false},
+		{"no data dir", Config{ChainParams: params}, false},
+		{"prune depth too low", Config{ChainParams: params,
+			DataDir: "data", Prune: true, PruneDepth: 100}, false},
+		{"minimal", Config{ChainParams: params, DataDir: "data"}, true},
+	}
+
+	for _, test := range tests {
+		cfg, err := test.cfg.withDefaults()
+		if (err == nil) != test.valid {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !test.valid {
+			continue
+		}
+		if cfg.DbType != DefaultDbType || cfg.MaxPeers != DefaultMaxPeers ||
+			cfg.PruneDepth != DefaultPruneDepth || cfg.Policy == nil ||
+			cfg.Dial == nil || cfg.Lookup == nil {
+			t.Errorf("%s: defaults not applied: %+v", test.name, cfg)
+		}
+		if len(cfg.UserAgentComments) != 1 ||
+			cfg.UserAgentComments[0] != userAgentComment {
+			t.Errorf("%s: unexpected user agent comments %v",
+				test.name, cfg.UserAgentComments)
+		}
+	}
+}
+
+// servePeer acts as the remote peer on the passed listener.  It completes the
+// version handshake and serves the passed block in response to the requests of
+// the node.
+func servePeer(t *testing.T, listener net.Listener, block *bchutil.Block) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	params := &chaincfg.RegressionNetParams
+	pver := wire.ProtocolVersion
+	write := func(msg wire.Message) error {
+		return wire.WriteMessage(conn, msg, pver, params.Net)
+	}
+
+	addr := conn.LocalAddr().(*net.TCPAddr)
+	me := wire.NewNetAddress(addr, wire.SFNodeNetwork)
+	you := wire.NewNetAddress(conn.RemoteAddr().(*net.TCPAddr), 0)
+	version := wire.NewMsgVersion(me, you, 1, block.Height())
+	version.Services = wire.SFNodeNetwork
+	if err := write(version); err != nil {
+		t.Errorf("unable to write version: %v", err)
+		return
+	}
+	if err := write(wire.NewMsgVerAck()); err != nil {
+		t.Errorf("unable to write verack: %v", err)
+		return
+	}
+
+	for {
+		msg, _, err := wire.ReadMessage(conn, pver, params.Net)
+		if err != nil {
+			return
+		}
+		switch msg := msg.(type) {
+		case *wire.MsgGetHeaders:
+			headers := wire.NewMsgHeaders()
+			headers.AddBlockHeader(&block.MsgBlock().Header)
+			err = write(headers)
+		case *wire.MsgGetBlocks:
+			inv := wire.NewMsgInv()
+			inv.AddInvVect(wire.NewInvVect(wire.InvTypeBlock,
+				block.Hash()))
+			err = write(inv)
+		case *wire.MsgGetData:
+			for _, iv := range msg.InvList {
+				if iv.Hash == *block.Hash() {
+					err = write(block.MsgBlock())
+				}
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// TestNodeSync ensures a node started from a configuration syncs the blocks of
+// the peer it connects to.
+func TestNodeSync(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	miningAddr, err := bchutil.NewAddressPubKeyHash(make([]byte, 20), params)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	block, err := rpctest.CreateBlock(nil, nil, 1, time.Time{}, miningAddr,
+		nil, params)
+	if err != nil {
+		t.Fatalf("unable to create block: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	defer listener.Close()
+	go servePeer(t, listener, block)
+
+	n, err := New(&Config{
+		ChainParams:  params,
+		DataDir:      t.TempDir(),
+		ConnectPeers: []string{listener.Addr().String()},
+	})
+	if err != nil {
+		t.Fatalf("unable to create node: %v", err)
+	}
+	n.Start()
+
+	deadline := time.Now().Add(time.Second * 30)
+	for n.Chain().BestSnapshot().Height != 1 {
+		if time.Now().After(deadline) {
+			n.Stop()
+			t.Fatalf("node did not sync block %v", block.Hash())
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	if best := n.Chain().BestSnapshot(); best.Hash != *block.Hash() {
+		t.Errorf("unexpected best block %v, want %v", best.Hash,
+			block.Hash())
+	}
+	if count := n.ConnectedCount(); count != 1 {
+		t.Errorf("unexpected connected count %d, want 1", count)
+	}
+
+	if err := n.Stop(); err != nil {
+		t.Fatalf("unable to stop node: %v", err)
+	}
+}
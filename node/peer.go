@@ -0,0 +1,341 @@
+package node
+
+import (
+	"bytes"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/connmgr"
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/peer"
+	"github.com/gcash/bchd/version"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// nodePeer extends the peer to maintain state shared by the node.
+type nodePeer struct {
+	// The following variables must only be used atomically.
+	feeFilter      int64
+	disableRelayTx int32
+
+	*peer.Peer
+
+	node           *Node
+	connReq        *connmgr.ConnReq
+	txProcessed    chan struct{}
+	blockProcessed chan struct{}
+}
+
+// newNodePeer returns a new node peer instance.  The connection request is nil
+// for inbound peers.
+func newNodePeer(n *Node, connReq *connmgr.ConnReq) *nodePeer {
+	return &nodePeer{
+		node:           n,
+		connReq:        connReq,
+		txProcessed:    make(chan struct{}, 1),
+		blockProcessed: make(chan struct{}, 1),
+	}
+}
+
+// newPeerConfig returns the configuration for the peer.
+func (np *nodePeer) newPeerConfig() *peer.Config {
+	n := np.node
+	return &peer.Config{
+		Listeners: peer.MessageListeners{
+			OnVersion:    np.OnVersion,
+			OnVerAck:     np.OnVerAck,
+			OnTx:         np.OnTx,
+			OnBlock:      np.OnBlock,
+			OnCmpctBlock: np.OnCmpctBlock,
+			OnInv:        np.OnInv,
+			OnHeaders:    np.OnHeaders,
+			OnGetData:    np.OnGetData,
+			OnGetBlocks:  np.OnGetBlocks,
+			OnGetHeaders: np.OnGetHeaders,
+			OnFeeFilter:  np.OnFeeFilter,
+			OnAddr:       np.OnAddr,
+			OnAddrV2:     np.OnAddrV2,
+		},
+		NewestBlock: func() (*chainhash.Hash, int32, error) {
+			best := n.chain.BestSnapshot()
+			return &best.Hash, best.Height, nil
+		},
+		HostToNetAddress:  n.addrManager.HostToNetAddress,
+		UserAgentName:     userAgentName,
+		UserAgentVersion:  version.String(),
+		UserAgentComments: n.cfg.UserAgentComments,
+		ChainParams:       n.cfg.ChainParams,
+		Services:          n.services,
+		DisableRelayTx:    n.cfg.BlocksOnly,
+		ProtocolVersion:   peer.MaxProtocolVersion,
+		TrickleInterval:   peer.DefaultTrickleInterval,
+		MaxKnownInventory: uint((n.cfg.ExcessiveBlockSize / 1000000) * peer.DefaultMaxKnownInventory),
+	}
+}
+
+// relayTxDisabled returns whether or not relaying of transactions to the peer
+// is disabled.
+//
+// This function is safe for concurrent access.
+func (np *nodePeer) relayTxDisabled() bool {
+	return atomic.LoadInt32(&np.disableRelayTx) != 0
+}
+
+// OnVersion is invoked when a peer receives a version bitcoin message.  Outbound
+// peers which are not full nodes are rejected.
+func (np *nodePeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) *wire.MsgReject {
+	// Ignore peers that have a protcol version that is too old.  The peer
+	// negotiation logic will disconnect it after this callback returns.
+	if msg.ProtocolVersion < int32(peer.MinAcceptableProtocolVersion) {
+		return nil
+	}
+
+	// Reject outbound peers that are not full nodes.
+	if !np.Inbound() && msg.Services&wire.SFNodeNetwork != wire.SFNodeNetwork {
+		log.Debugf("Rejecting peer %s with services %v due to not "+
+			"providing desired services %v", np.Peer, msg.Services,
+			wire.SFNodeNetwork)
+		reason := fmt.Sprintf("required services %#x not offered",
+			uint64(wire.SFNodeNetwork))
+		return wire.NewMsgReject(msg.Command(), wire.RejectNonstandard, reason)
+	}
+
+	// Add the remote peer time as a sample for creating an offset against
+	// the local clock to keep the network time in sync.
+	np.node.timeSource.AddTimeSample(np.Addr(), msg.Timestamp)
+
+	if msg.DisableRelayTx {
+		atomic.StoreInt32(&np.disableRelayTx, 1)
+	}
+	return nil
+}
+
+// OnVerAck is invoked when a peer receives a verack bitcoin message and is used
+// to add the peer to the node.
+func (np *nodePeer) OnVerAck(_ *peer.Peer, msg *wire.MsgVerAck) {
+	np.node.addPeer(np)
+}
+
+// OnTx is invoked when a peer receives a tx bitcoin message.  It blocks until
+// the transaction has been fully processed.
+func (np *nodePeer) OnTx(_ *peer.Peer, msg *wire.MsgTx) {
+	if np.node.cfg.BlocksOnly {
+		log.Tracef("Ignoring tx %v from %v - blocks only", msg.TxHash(),
+			np)
+		return
+	}
+
+	tx := bchutil.NewTx(msg)
+	np.AddKnownInventory(wire.NewInvVect(wire.InvTypeTx, tx.Hash()))
+	np.node.syncManager.QueueTx(tx, np.Peer, np.txProcessed)
+	<-np.txProcessed
+}
+
+// OnBlock is invoked when a peer receives a block bitcoin message.  It blocks
+// until the block has been fully processed.
+func (np *nodePeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
+	block := bchutil.NewBlockFromBlockAndBytes(msg, buf)
+	np.AddKnownInventory(wire.NewInvVect(wire.InvTypeBlock, block.Hash()))
+	np.node.syncManager.QueueBlock(block, np.Peer, np.blockProcessed)
+	<-np.blockProcessed
+}
+
+// OnCmpctBlock is invoked when a peer receives a cmpctblock bitcoin message.
+// The block is reconstructed from the transactions of the memory pool and the
+// full block is requested instead when any of them are missing.
+func (np *nodePeer) OnCmpctBlock(_ *peer.Peer, msg *wire.MsgCmpctBlock) {
+	blockHash := msg.BlockHash()
+	msgBlock, err := np.node.txMemPool.DecodeCompressedBlock(msg)
+	if err != nil {
+		log.Debugf("Error decoding cmpctblock %v from %v: %v",
+			blockHash, np, err)
+		np.node.syncManager.QueueBlockError(&blockHash, np.Peer)
+		return
+	}
+	for _, tx := range msgBlock.Transactions {
+		if tx != nil {
+			continue
+		}
+		getData := wire.NewMsgGetData()
+		getData.AddInvVect(wire.NewInvVect(wire.InvTypeBlock, &blockHash))
+		np.QueueMessage(getData, nil)
+		return
+	}
+
+	np.OnBlock(np.Peer, msgBlock, nil)
+}
+
+// OnInv is invoked when a peer receives an inv bitcoin message.  The inventory
+// is passed to the sync manager, which requests the blocks and transactions
+// the node does not have yet.
+func (np *nodePeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
+	if !np.node.cfg.BlocksOnly {
+		if len(msg.InvList) > 0 {
+			np.node.syncManager.QueueInv(msg, np.Peer)
+		}
+		return
+	}
+
+	newInv := wire.NewMsgInvSizeHint(uint(len(msg.InvList)))
+	for _, invVect := range msg.InvList {
+		if invVect.Type == wire.InvTypeTx {
+			continue
+		}
+		if err := newInv.AddInvVect(invVect); err != nil {
+			log.Errorf("Failed to add inventory vector: %v", err)
+			break
+		}
+	}
+	if len(newInv.InvList) > 0 {
+		np.node.syncManager.QueueInv(newInv, np.Peer)
+	}
+}
+
+// OnHeaders is invoked when a peer receives a headers bitcoin message.  The
+// message is passed down to the sync manager.
+func (np *nodePeer) OnHeaders(_ *peer.Peer, msg *wire.MsgHeaders) {
+	np.node.syncManager.QueueHeaders(msg, np.Peer)
+}
+
+// OnGetData is invoked when a peer receives a getdata bitcoin message and is
+// used to deliver the requested blocks and transactions.  It blocks until they
+// were sent so the peer doesn't queue up more data than can be sent in a
+// reasonable time.
+func (np *nodePeer) OnGetData(_ *peer.Peer, msg *wire.MsgGetData) {
+	notFound := wire.NewMsgNotFound()
+	doneChan := make(chan struct{}, 1)
+	for _, iv := range msg.InvList {
+		var dataMsg wire.Message
+		switch iv.Type {
+		case wire.InvTypeTx:
+			tx, err := np.node.txMemPool.FetchTransaction(&iv.Hash)
+			if err == nil {
+				dataMsg = tx.MsgTx()
+			}
+		case wire.InvTypeBlock:
+			dataMsg = np.node.fetchBlock(&iv.Hash)
+		}
+		if dataMsg == nil {
+			notFound.AddInvVect(iv)
+			continue
+		}
+		np.QueueMessage(dataMsg, doneChan)
+		<-doneChan
+	}
+	if len(notFound.InvList) != 0 {
+		np.QueueMessage(notFound, nil)
+	}
+}
+
+// fetchBlock returns the block with the passed hash from the database or nil
+// when it is not available.
+func (n *Node) fetchBlock(hash *chainhash.Hash) *wire.MsgBlock {
+	var blockBytes []byte
+	err := n.db.View(func(dbTx database.Tx) error {
+		var err error
+		blockBytes, err = dbTx.FetchBlock(hash)
+		return err
+	})
+	if err != nil {
+		log.Tracef("Unable to fetch requested block hash %v: %v", hash,
+			err)
+		return nil
+	}
+
+	var msgBlock wire.MsgBlock
+	if err := msgBlock.Deserialize(bytes.NewReader(blockBytes)); err != nil {
+		log.Tracef("Unable to deserialize requested block hash %v: %v",
+			hash, err)
+		return nil
+	}
+	return &msgBlock
+}
+
+// OnGetBlocks is invoked when a peer receives a getblocks bitcoin message.  It
+// sends an inventory of the blocks of the main chain after the most recent
+// block of the locator the node knows about.
+func (np *nodePeer) OnGetBlocks(_ *peer.Peer, msg *wire.MsgGetBlocks) {
+	hashList := np.node.chain.LocateBlocks(msg.BlockLocatorHashes,
+		&msg.HashStop, wire.MaxBlocksPerMsg)
+
+	invMsg := wire.NewMsgInv()
+	for i := range hashList {
+		iv := wire.NewInvVect(wire.InvTypeBlock, &hashList[i])
+		invMsg.AddInvVect(iv)
+	}
+	if len(invMsg.InvList) > 0 {
+		np.QueueMessage(invMsg, nil)
+	}
+}
+
+// OnGetHeaders is invoked when a peer receives a getheaders bitcoin message.
+// It sends the headers of the main chain after the most recent block of the
+// locator the node knows about.
+func (np *nodePeer) OnGetHeaders(_ *peer.Peer, msg *wire.MsgGetHeaders) {
+	// Ignore getheaders requests if not in sync.
+	if !np.node.syncManager.IsCurrent() {
+		return
+	}
+
+	headers := np.node.chain.LocateHeaders(msg.BlockLocatorHashes,
+		&msg.HashStop)
+	blockHeaders := make([]*wire.BlockHeader, len(headers))
+	for i := range headers {
+		blockHeaders[i] = &headers[i]
+	}
+	np.QueueMessage(&wire.MsgHeaders{Headers: blockHeaders}, nil)
+}
+
+// OnFeeFilter is invoked when a peer receives a feefilter bitcoin message and
+// is used by remote peers to request that no transactions which have a fee rate
+// lower than provided value are inventoried to them.
+func (np *nodePeer) OnFeeFilter(_ *peer.Peer, msg *wire.MsgFeeFilter) {
+	// Check that the passed minimum fee is a valid amount.
+	if msg.MinFee < 0 || msg.MinFee > bchutil.MaxSatoshi {
+		log.Debugf("Peer %v sent an invalid feefilter '%v' -- "+
+			"disconnecting", np, bchutil.Amount(msg.MinFee))
+		np.Disconnect()
+		return
+	}
+
+	atomic.StoreInt64(&np.feeFilter, msg.MinFee)
+}
+
+// OnAddr is invoked when a peer receives an addr bitcoin message and is used to
+// add the advertised addresses to the address manager.
+func (np *nodePeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
+	// Ignore old style addresses which don't include a timestamp.
+	if np.ProtocolVersion() < wire.NetAddressTimeVersion {
+		return
+	}
+
+	np.addAddresses(msg.AddrList)
+}
+
+// OnAddrV2 is invoked when a peer receives an addrv2 bitcoin message and is
+// used to add the advertised addresses to the address manager.
+func (np *nodePeer) OnAddrV2(_ *peer.Peer, msg *wire.MsgAddrV2) {
+	np.addAddresses(msg.AddrList)
+}
+
+// addAddresses adds the passed addresses advertised by the peer to the address
+// manager when the node discovers peers.
+func (np *nodePeer) addAddresses(addrList []*wire.NetAddress) {
+	if !np.node.discoverPeers() {
+		return
+	}
+
+	// Set the timestamp to 5 days ago if it's more than 10 minutes in the
+	// future so this address is one of the first to be removed when space
+	// is needed.
+	now := time.Now()
+	for _, na := range addrList {
+		if na.Timestamp.After(now.Add(time.Minute * 10)) {
+			na.Timestamp = now.Add(-1 * time.Hour * 24 * 5)
+		}
+	}
+	np.node.addrManager.AddAddresses(addrList, np.NA())
+}
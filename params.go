@@ -70,6 +70,23 @@ var chipNetParams = params{
 	gRRPPort: "18335",
 }
 
+// scaleNetParams contains parameters specific to the scaling test network
+// (wire.ScaleNet).  NOTE: The RPC port is intentionally different than the
+// reference implementation - see the mainNetParams comment for details.
+var scaleNetParams = params{
+	Params:   &chaincfg.ScaleNetParams,
+	rpcPort:  "18334",
+	gRRPPort: "18335",
+}
+
+// sigNetParams contains parameters specific to the signed test network
+// (wire.SigNet).
+var sigNetParams = params{
+	Params:   &chaincfg.SigNetParams,
+	rpcPort:  "38334",
+	gRRPPort: "38335",
+}
+
 // simNetParams contains parameters specific to the simulation test network
 // (wire.SimNet).
 var simNetParams = params{
@@ -0,0 +1,251 @@
+package paymentrequest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gcash/bchd/paymentrequest/pb"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	// PKITypeNone is the pki type of an unsigned BIP0070 payment request.
+	PKITypeNone = "none"
+
+	// PKITypeX509SHA256 is the pki type of a BIP0070 payment request
+	// signed with an X.509 certificate over its SHA-256 hash.
+	PKITypeX509SHA256 = "x509+sha256"
+
+	// BIP70RequestMimeType is the content type of a serialized BIP0070
+	// PaymentRequest message.
+	BIP70RequestMimeType = "application/bitcoincash-paymentrequest"
+
+	// BIP70PaymentMimeType is the content type of a serialized BIP0070
+	// Payment message.
+	BIP70PaymentMimeType = "application/bitcoincash-payment"
+
+	// BIP70PaymentACKMimeType is the content type of a serialized BIP0070
+	// PaymentACK message.
+	BIP70PaymentACKMimeType = "application/bitcoincash-paymentack"
+)
+
+// NewBIP70Request creates an unsigned BIP0070 payment request for the passed
+// request.  It may be signed with SignBIP70 afterwards.
+func NewBIP70Request(r *Request) (*pb.PaymentRequest, error) {
+	details := &pb.PaymentDetails{
+		Network: proto.String(r.Network),
+		Time:    proto.Uint64(uint64(r.Time.Unix())),
+	}
+	for _, out := range r.Outputs {
+		if out.Amount < 0 {
+			return nil, fmt.Errorf("negative output amount %v", out.Amount)
+		}
+		details.Outputs = append(details.Outputs, &pb.Output{
+			Amount: proto.Uint64(uint64(out.Amount)),
+			Script: out.Script,
+		})
+	}
+	if !r.Expires.IsZero() {
+		details.Expires = proto.Uint64(uint64(r.Expires.Unix()))
+	}
+	if r.Memo != "" {
+		details.Memo = proto.String(r.Memo)
+	}
+	if r.PaymentURL != "" {
+		details.PaymentUrl = proto.String(r.PaymentURL)
+	}
+	if r.MerchantData != nil {
+		details.MerchantData = r.MerchantData
+	}
+
+	serialized, err := proto.Marshal(details)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.PaymentRequest{
+		PaymentDetailsVersion:    proto.Uint32(1),
+		PkiType:                  proto.String(PKITypeNone),
+		SerializedPaymentDetails: serialized,
+	}, nil
+}
+
+// bip70SignedMessage returns the passed payment request serialized with an
+// empty signature, which is the message signed by the merchant.
+func bip70SignedMessage(req *pb.PaymentRequest) ([]byte, error) {
+	unsigned := proto.Clone(req).(*pb.PaymentRequest)
+	unsigned.Signature = []byte{}
+	return proto.Marshal(unsigned)
+}
+
+// SignBIP70 signs the passed payment request with the merchant key and
+// attaches the certificate chain of the key.  The first certificate of the
+// chain must be the certificate of the key, followed by the intermediate
+// certificates which lead to a trusted root.  RSA and ECDSA keys are
+// supported.
+func SignBIP70(req *pb.PaymentRequest, key crypto.Signer, certs []*x509.Certificate) error {
+	if len(certs) == 0 {
+		return errors.New("no certificates provided")
+	}
+	chain := &pb.X509Certificates{}
+	for _, cert := range certs {
+		chain.Certificate = append(chain.Certificate, cert.Raw)
+	}
+	pkiData, err := proto.Marshal(chain)
+	if err != nil {
+		return err
+	}
+	req.PkiType = proto.String(PKITypeX509SHA256)
+	req.PkiData = pkiData
+
+	message, err := bip70SignedMessage(req)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(message)
+	sig, err := key.Sign(rand.Reader, hash[:], crypto.SHA256)
+	if err != nil {
+		return err
+	}
+	req.Signature = sig
+	return nil
+}
+
+// VerifyBIP70 verifies the signature of the passed payment request and
+// decodes it.  The certificate chain of a signed request is verified against
+// the passed root certificates at the passed time, and the certificate of the
+// merchant is returned along with the request.  The returned certificate is
+// nil for an unsigned request, which callers must not treat as authenticated.
+func VerifyBIP70(req *pb.PaymentRequest, roots *x509.CertPool, now time.Time) (*Request, *x509.Certificate, error) {
+	if req.GetPaymentDetailsVersion() != 1 {
+		return nil, nil, fmt.Errorf("unsupported payment details version %d",
+			req.GetPaymentDetailsVersion())
+	}
+
+	var merchant *x509.Certificate
+	switch req.GetPkiType() {
+	case PKITypeNone:
+	case PKITypeX509SHA256:
+		var chain pb.X509Certificates
+		if err := proto.Unmarshal(req.GetPkiData(), &chain); err != nil {
+			return nil, nil, fmt.Errorf("invalid pki data: %v", err)
+		}
+		if len(chain.Certificate) == 0 {
+			return nil, nil, errors.New("pki data contains no certificates")
+		}
+		certs := make([]*x509.Certificate, 0, len(chain.Certificate))
+		for _, raw := range chain.Certificate {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid certificate: %v", err)
+			}
+			certs = append(certs, cert)
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			CurrentTime:   now,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid certificate chain: %v", err)
+		}
+
+		var algo x509.SignatureAlgorithm
+		switch certs[0].PublicKeyAlgorithm {
+		case x509.RSA:
+			algo = x509.SHA256WithRSA
+		case x509.ECDSA:
+			algo = x509.ECDSAWithSHA256
+		default:
+			return nil, nil, fmt.Errorf("unsupported public key algorithm %v",
+				certs[0].PublicKeyAlgorithm)
+		}
+		message, err := bip70SignedMessage(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = certs[0].CheckSignature(algo, message, req.GetSignature())
+		if err != nil {
+			return nil, nil, ErrInvalidSignature
+		}
+		merchant = certs[0]
+	default:
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnsupportedPKIType,
+			req.GetPkiType())
+	}
+
+	var details pb.PaymentDetails
+	err := proto.Unmarshal(req.GetSerializedPaymentDetails(), &details)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid payment details: %v", err)
+	}
+	r := &Request{
+		Network:      details.GetNetwork(),
+		Time:         time.Unix(int64(details.GetTime()), 0),
+		Memo:         details.GetMemo(),
+		PaymentURL:   details.GetPaymentUrl(),
+		MerchantData: details.GetMerchantData(),
+	}
+	if details.Expires != nil {
+		r.Expires = time.Unix(int64(details.GetExpires()), 0)
+	}
+	for _, out := range details.GetOutputs() {
+		if out.GetAmount() > uint64(bchutil.MaxSatoshi) {
+			return nil, nil, fmt.Errorf("output amount %d exceeds the "+
+				"maximum", out.GetAmount())
+		}
+		r.Outputs = append(r.Outputs, Output{
+			Amount: bchutil.Amount(out.GetAmount()),
+			Script: out.GetScript(),
+		})
+	}
+	return r, merchant, nil
+}
+
+// ParseBIP70Payment decodes a serialized BIP0070 Payment message.
+func ParseBIP70Payment(serialized []byte) (*pb.Payment, error) {
+	var payment pb.Payment
+	if err := proto.Unmarshal(serialized, &payment); err != nil {
+		return nil, fmt.Errorf("invalid payment: %v", err)
+	}
+	return &payment, nil
+}
+
+// ValidateBIP70Payment checks that the passed BIP0070 payment pays the
+// request at the passed time and returns its transactions.  In addition to
+// the checks of ValidatePayment, the merchant data of the payment must match
+// the merchant data of the request.
+func (r *Request) ValidateBIP70Payment(payment *pb.Payment, now time.Time) ([]*wire.MsgTx, error) {
+	if err := r.checkMerchantData(payment.GetMerchantData()); err != nil {
+		return nil, err
+	}
+	txs, err := deserializeTransactions(payment.GetTransactions())
+	if err != nil {
+		return nil, err
+	}
+	if err := r.ValidatePayment(txs, now); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// NewBIP70PaymentACK creates the acknowledgement of the passed payment with
+// an optional memo for the customer.
+func NewBIP70PaymentACK(payment *pb.Payment, memo string) *pb.PaymentACK {
+	ack := &pb.PaymentACK{Payment: payment}
+	if memo != "" {
+		ack.Memo = proto.String(memo)
+	}
+	return ack
+}
@@ -0,0 +1,220 @@
+package paymentrequest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gcash/bchd/bchec"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+const (
+	// JSONCurrency is the currency of JSON payment protocol messages.
+	JSONCurrency = "BCH"
+
+	// JSONRequestMimeType is the content type of a JSON payment protocol
+	// payment request.
+	JSONRequestMimeType = "application/payment-request"
+
+	// JSONPaymentMimeType is the content type of a JSON payment protocol
+	// payment.
+	JSONPaymentMimeType = "application/payment"
+
+	// JSONPaymentACKMimeType is the content type of a JSON payment
+	// protocol payment acknowledgement.
+	JSONPaymentACKMimeType = "application/payment-ack"
+
+	// JSONSignatureTypeECC is the value of the x-signature-type header of
+	// a payment request signed with SignJSON.
+	JSONSignatureTypeECC = "ecc"
+)
+
+// JSONOutput is an output requested by a JSON payment protocol payment
+// request.
+type JSONOutput struct {
+	Amount  int64  `json:"amount"`
+	Address string `json:"address"`
+}
+
+// JSONRequest is a JSON payment protocol payment request.
+type JSONRequest struct {
+	Network            string       `json:"network"`
+	Currency           string       `json:"currency"`
+	RequiredFeePerByte float64      `json:"requiredFeePerByte"`
+	Outputs            []JSONOutput `json:"outputs"`
+	Time               time.Time    `json:"time"`
+	Expires            *time.Time   `json:"expires,omitempty"`
+	Memo               string       `json:"memo,omitempty"`
+	PaymentURL         string       `json:"paymentUrl"`
+	PaymentID          string       `json:"paymentId"`
+}
+
+// JSONPayment is a JSON payment protocol payment.  The transactions are hex
+// encoded.
+type JSONPayment struct {
+	Currency     string   `json:"currency"`
+	Transactions []string `json:"transactions"`
+}
+
+// JSONPaymentACK is a JSON payment protocol payment acknowledgement.
+type JSONPaymentACK struct {
+	Payment JSONPayment `json:"payment"`
+	Memo    string      `json:"memo,omitempty"`
+}
+
+// NewJSONRequest creates a JSON payment protocol payment request for the
+// passed request.  Each requested output script must pay a single address of
+// the network defined by the passed parameters.  The merchant data of the
+// request is used as the payment id.
+func NewJSONRequest(r *Request, params *chaincfg.Params) (*JSONRequest, error) {
+	j := &JSONRequest{
+		Network:    r.Network,
+		Currency:   JSONCurrency,
+		Time:       r.Time.UTC(),
+		Memo:       r.Memo,
+		PaymentURL: r.PaymentURL,
+		PaymentID:  string(r.MerchantData),
+	}
+	if !r.Expires.IsZero() {
+		expires := r.Expires.UTC()
+		j.Expires = &expires
+	}
+	for _, out := range r.Outputs {
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(out.Script, params)
+		if err != nil || len(addrs) != 1 {
+			return nil, fmt.Errorf("output script %x does not pay a "+
+				"single address", out.Script)
+		}
+		j.Outputs = append(j.Outputs, JSONOutput{
+			Amount:  int64(out.Amount),
+			Address: addrs[0].String(),
+		})
+	}
+	return j, nil
+}
+
+// Request decodes the JSON payment request using the passed network
+// parameters to decode the addresses of its outputs.
+func (j *JSONRequest) Request(params *chaincfg.Params) (*Request, error) {
+	if j.Currency != JSONCurrency {
+		return nil, fmt.Errorf("unsupported currency %q", j.Currency)
+	}
+	r := &Request{
+		Network:      j.Network,
+		Time:         j.Time,
+		Memo:         j.Memo,
+		PaymentURL:   j.PaymentURL,
+		MerchantData: []byte(j.PaymentID),
+	}
+	if j.Expires != nil {
+		r.Expires = *j.Expires
+	}
+	for _, out := range j.Outputs {
+		if out.Amount < 0 || out.Amount > bchutil.MaxSatoshi {
+			return nil, fmt.Errorf("invalid output amount %d", out.Amount)
+		}
+		addr, err := bchutil.DecodeAddress(out.Address, params)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %v", out.Address,
+				err)
+		}
+		script, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, err
+		}
+		r.Outputs = append(r.Outputs, Output{
+			Amount: bchutil.Amount(out.Amount),
+			Script: script,
+		})
+	}
+	return r, nil
+}
+
+// ParseJSONRequest decodes a JSON payment protocol payment request.
+func ParseJSONRequest(body []byte) (*JSONRequest, error) {
+	var j JSONRequest
+	if err := json.Unmarshal(body, &j); err != nil {
+		return nil, fmt.Errorf("invalid payment request: %v", err)
+	}
+	return &j, nil
+}
+
+// ParseJSONPayment decodes a JSON payment protocol payment.
+func ParseJSONPayment(body []byte) (*JSONPayment, error) {
+	var payment JSONPayment
+	if err := json.Unmarshal(body, &payment); err != nil {
+		return nil, fmt.Errorf("invalid payment: %v", err)
+	}
+	return &payment, nil
+}
+
+// ValidateJSONPayment checks that the passed JSON payment protocol payment
+// pays the request at the passed time and returns its transactions.
+func (r *Request) ValidateJSONPayment(payment *JSONPayment, now time.Time) ([]*wire.MsgTx, error) {
+	if payment.Currency != JSONCurrency {
+		return nil, fmt.Errorf("unsupported currency %q", payment.Currency)
+	}
+	rawTxs := make([][]byte, 0, len(payment.Transactions))
+	for i, txHex := range payment.Transactions {
+		rawTx, err := hex.DecodeString(txHex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transaction %d: %v", i, err)
+		}
+		rawTxs = append(rawTxs, rawTx)
+	}
+	txs, err := deserializeTransactions(rawTxs)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.ValidatePayment(txs, now); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// JSONDigest returns the value of the digest header of the passed JSON
+// payment protocol message body.
+func JSONDigest(body []byte) string {
+	hash := sha256.Sum256(body)
+	return "SHA-256=" + hex.EncodeToString(hash[:])
+}
+
+// SignJSON signs the passed JSON payment protocol message body with the
+// merchant key.  The returned hex encoded DER signature of the SHA-256 hash of
+// the body is sent in the x-signature header, with the x-signature-type
+// header set to JSONSignatureTypeECC and the x-identity header set to the hex
+// encoded compressed public key of the merchant.
+func SignJSON(body []byte, key *bchec.PrivateKey) (string, error) {
+	hash := sha256.Sum256(body)
+	sig, err := key.SignECDSA(hash[:])
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sig.Serialize()), nil
+}
+
+// VerifyJSON verifies the hex encoded signature of the passed JSON payment
+// protocol message body created by SignJSON with the private key of the
+// passed public key.
+func VerifyJSON(body []byte, signature string, key *bchec.PublicKey) error {
+	sigBytes, err := hex.DecodeString(signature)
+	if err != nil {
+		return errors.New("signature is not hex encoded")
+	}
+	sig, err := bchec.ParseDERSignature(sigBytes, bchec.S256())
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	hash := sha256.Sum256(body)
+	if !sig.Verify(hash[:], key) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+package paymentrequest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/gcash/bchd/bchec"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/paymentrequest/pb"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+	"github.com/golang/protobuf/proto"
+)
+
+// testAddr is the address paid by the test requests.
+const testAddr = "qr7fzmep8g7h7ymfxy74lgc0v950j3r2959lhtxxsl"
+
+// testRequest returns a request for 1000 satoshis to the test address.
+func testRequest(t *testing.T) *Request {
+	addr, err := bchutil.DecodeAddress(testAddr, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	return &Request{
+		Network:      NetworkName(&chaincfg.MainNetParams),
+		Outputs:      []Output{{Amount: 1000, Script: script}},
+		Time:         now,
+		Expires:      now.Add(15 * time.Minute),
+		Memo:         "coffee",
+		PaymentURL:   "https://example.com/pay",
+		MerchantData: []byte("invoice-1"),
+	}
+}
+
+// payingTx returns a transaction paying the passed amounts to the passed
+// script.
+func payingTx(script []byte, amounts ...int64) *wire.MsgTx {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil))
+	for _, amount := range amounts {
+		tx.AddTxOut(wire.NewTxOut(amount, script, wire.TokenData{}))
+	}
+	return tx
+}
+
+// testCertChain returns a key and a certificate chain consisting of a leaf
+// certificate for the key issued by a root certificate, and a pool holding
+// the root.
+func testCertChain(t *testing.T) (*ecdsa.PrivateKey, []*x509.Certificate, *x509.CertPool) {
+	now := time.Unix(1700000000, 0)
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTemplate,
+		rootTemplate, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := x509.ParseCertificate(rootDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "merchant.example.com"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, root,
+		&leafKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(leafDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	return leafKey, []*x509.Certificate{leaf}, roots
+}
+
+// TestValidatePayment ensures payments are validated against requests as
+// expected.
+func TestValidatePayment(t *testing.T) {
+	r := testRequest(t)
+	script := r.Outputs[0].Script
+	other := []byte{txscript.OP_TRUE}
+	tests := []struct {
+		name string
+		txs  []*wire.MsgTx
+		now  time.Time
+		err  error
+	}{
+		{
+			name: "exact payment",
+			txs:  []*wire.MsgTx{payingTx(script, 1000)},
+			now:  r.Time,
+		},
+		{
+			name: "overpayment",
+			txs:  []*wire.MsgTx{payingTx(script, 1500)},
+			now:  r.Time,
+		},
+		{
+			name: "split over transactions",
+			txs: []*wire.MsgTx{
+				payingTx(script, 400),
+				payingTx(script, 300, 300),
+			},
+			now: r.Time,
+		},
+		{
+			name: "underpayment",
+			txs:  []*wire.MsgTx{payingTx(script, 999)},
+			now:  r.Time,
+			err:  ErrInsufficientPayment,
+		},
+		{
+			name: "wrong script",
+			txs:  []*wire.MsgTx{payingTx(other, 1000)},
+			now:  r.Time,
+			err:  ErrInsufficientPayment,
+		},
+		{
+			name: "no transactions",
+			now:  r.Time,
+			err:  ErrNoTransactions,
+		},
+		{
+			name: "expired",
+			txs:  []*wire.MsgTx{payingTx(script, 1000)},
+			now:  r.Expires.Add(time.Second),
+			err:  ErrExpired,
+		},
+	}
+	for _, test := range tests {
+		err := r.ValidatePayment(test.txs, test.now)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: unexpected error: got %v, want %v", test.name,
+				err, test.err)
+		}
+	}
+}
+
+// TestBIP70 ensures BIP0070 payment requests round trip through signing and
+// verification and that their payments are validated.
+func TestBIP70(t *testing.T) {
+	r := testRequest(t)
+	key, certs, roots := testCertChain(t)
+
+	req, err := NewBIP70Request(r)
+	if err != nil {
+		t.Fatalf("NewBIP70Request: %v", err)
+	}
+	if err := SignBIP70(req, key, certs); err != nil {
+		t.Fatalf("SignBIP70: %v", err)
+	}
+	got, merchant, err := VerifyBIP70(req, roots, r.Time)
+	if err != nil {
+		t.Fatalf("VerifyBIP70: %v", err)
+	}
+	if merchant == nil || merchant.Subject.CommonName != "merchant.example.com" {
+		t.Fatalf("unexpected merchant certificate %v", merchant)
+	}
+	if got.Memo != r.Memo || got.PaymentURL != r.PaymentURL ||
+		string(got.MerchantData) != string(r.MerchantData) ||
+		!got.Time.Equal(r.Time) || !got.Expires.Equal(r.Expires) ||
+		got.TotalAmount() != r.TotalAmount() {
+
+		t.Fatalf("decoded request mismatch: got %+v, want %+v", got, r)
+	}
+
+	// Validate a payment of the request.
+	tx := payingTx(r.Outputs[0].Script, 1000)
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+	serialized, err := proto.Marshal(&pb.Payment{
+		MerchantData: r.MerchantData,
+		Transactions: [][]byte{buf.Bytes()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, err := ParseBIP70Payment(serialized)
+	if err != nil {
+		t.Fatalf("ParseBIP70Payment: %v", err)
+	}
+	txs, err := got.ValidateBIP70Payment(payment, r.Time)
+	if err != nil {
+		t.Fatalf("ValidateBIP70Payment: %v", err)
+	}
+	if len(txs) != 1 || txs[0].TxHash() != tx.TxHash() {
+		t.Fatal("unexpected payment transactions")
+	}
+	payment.MerchantData = []byte("invoice-2")
+	_, err = got.ValidateBIP70Payment(payment, r.Time)
+	if !errors.Is(err, ErrMerchantDataMismatch) {
+		t.Fatalf("mismatched merchant data: unexpected error %v", err)
+	}
+
+	// The chain must lead to a trusted root.
+	if _, _, err := VerifyBIP70(req, x509.NewCertPool(), r.Time); err == nil {
+		t.Fatal("request with untrusted certificate verified")
+	}
+
+	// A request whose details are modified after signing must not verify.
+	req.SerializedPaymentDetails = append(req.SerializedPaymentDetails, 0)
+	if _, _, err := VerifyBIP70(req, roots, r.Time); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("tampered request: unexpected error %v", err)
+	}
+}
+
+// TestJSON ensures JSON payment protocol payment requests round trip through
+// encoding and signing and that their payments are validated.
+func TestJSON(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	r := testRequest(t)
+
+	j, err := NewJSONRequest(r, params)
+	if err != nil {
+		t.Fatalf("NewJSONRequest: %v", err)
+	}
+	if len(j.Outputs) != 1 || j.Outputs[0].Address != testAddr ||
+		j.Outputs[0].Amount != 1000 || j.PaymentID != "invoice-1" {
+
+		t.Fatalf("unexpected JSON request %+v", j)
+	}
+	body, err := json.Marshal(j)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := bchec.NewPrivateKey(bchec.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := SignJSON(body, key)
+	if err != nil {
+		t.Fatalf("SignJSON: %v", err)
+	}
+	if err := VerifyJSON(body, sig, key.PubKey()); err != nil {
+		t.Fatalf("VerifyJSON: %v", err)
+	}
+	tampered := append([]byte(nil), body...)
+	tampered[len(tampered)-2] ^= 1
+	if err := VerifyJSON(tampered, sig, key.PubKey()); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("tampered body: unexpected error %v", err)
+	}
+
+	parsed, err := ParseJSONRequest(body)
+	if err != nil {
+		t.Fatalf("ParseJSONRequest: %v", err)
+	}
+	got, err := parsed.Request(params)
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	if !bytes.Equal(got.Outputs[0].Script, r.Outputs[0].Script) ||
+		!got.Expires.Equal(r.Expires) || !got.Time.Equal(r.Time) {
+
+		t.Fatalf("decoded request mismatch: got %+v, want %+v", got, r)
+	}
+
+	tx := payingTx(r.Outputs[0].Script, 1000)
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+	payment, err := ParseJSONPayment([]byte(`{"currency":"BCH",` +
+		`"transactions":["` + hex.EncodeToString(buf.Bytes()) + `"]}`))
+	if err != nil {
+		t.Fatalf("ParseJSONPayment: %v", err)
+	}
+	if _, err := got.ValidateJSONPayment(payment, r.Time); err != nil {
+		t.Fatalf("ValidateJSONPayment: %v", err)
+	}
+	payment.Currency = "BTC"
+	if _, err := got.ValidateJSONPayment(payment, r.Time); err == nil {
+		t.Fatal("payment in another currency validated")
+	}
+}
@@ -0,0 +1,178 @@
+// Package paymentrequest implements the merchant side of the payment
+// protocols used by point of sale deployments: the BIP0070 payment protocol,
+// whose messages are protocol buffers optionally signed with an X.509
+// certificate, and the JSON payment protocol popularized by BitPay, whose
+// messages are JSON documents signed with a secp256k1 key.
+//
+// Both protocols describe a payment request as a set of outputs which must be
+// paid before an expiration time.  The protocol specific messages are decoded
+// into a Request, against which the transactions of an incoming payment are
+// validated with Request.ValidatePayment.
+package paymentrequest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+var (
+	// ErrExpired is returned when a payment is made after the expiration
+	// time of its request.
+	ErrExpired = errors.New("payment request has expired")
+
+	// ErrNoTransactions is returned when a payment does not contain any
+	// transaction.
+	ErrNoTransactions = errors.New("payment contains no transactions")
+
+	// ErrInsufficientPayment is returned when the transactions of a
+	// payment do not pay all of the outputs of its request.
+	ErrInsufficientPayment = errors.New("payment does not pay the " +
+		"requested outputs")
+
+	// ErrMerchantDataMismatch is returned when the merchant data of a
+	// payment differs from the merchant data of its request.
+	ErrMerchantDataMismatch = errors.New("merchant data does not match " +
+		"the payment request")
+
+	// ErrUnsupportedPKIType is returned when a payment request is signed
+	// with a public key infrastructure which is not supported.
+	ErrUnsupportedPKIType = errors.New("unsupported pki type")
+
+	// ErrInvalidSignature is returned when the signature of a payment
+	// request does not verify.
+	ErrInvalidSignature = errors.New("invalid payment request signature")
+)
+
+// Output is an output requested by a payment request.
+type Output struct {
+	// Amount is the amount which must be paid to the script.
+	Amount bchutil.Amount
+
+	// Script is the public key script which must be paid.
+	Script []byte
+}
+
+// Request is a payment request independent of the protocol used to transmit
+// it.
+type Request struct {
+	// Network is the name of the network of the request as used by the
+	// payment protocols, see NetworkName.
+	Network string
+
+	// Outputs are the outputs which must be paid by a payment.
+	Outputs []Output
+
+	// Time is the time the request was created.
+	Time time.Time
+
+	// Expires is the time after which the request may no longer be paid.
+	// The zero value means the request never expires.
+	Expires time.Time
+
+	// Memo is a description of the request for the customer.
+	Memo string
+
+	// PaymentURL is the URL a payment is sent to.
+	PaymentURL string
+
+	// MerchantData is arbitrary data of the merchant which must be
+	// returned unchanged in the payment.  The JSON payment protocol
+	// carries the payment id here.
+	MerchantData []byte
+}
+
+// NetworkName returns the name of the network defined by the passed
+// parameters as used by the payment protocols.  BIP0070 names the main and
+// test networks "main" and "test", other networks are referred to by the name
+// of their parameters.
+func NetworkName(params *chaincfg.Params) string {
+	switch params.Net {
+	case chaincfg.MainNetParams.Net:
+		return "main"
+	case chaincfg.TestNet3Params.Net:
+		return "test"
+	}
+	return params.Name
+}
+
+// TotalAmount returns the total amount requested by the request.
+func (r *Request) TotalAmount() bchutil.Amount {
+	var total bchutil.Amount
+	for _, out := range r.Outputs {
+		total += out.Amount
+	}
+	return total
+}
+
+// Expired returns whether the request has expired at the passed time.
+func (r *Request) Expired(now time.Time) bool {
+	return !r.Expires.IsZero() && now.After(r.Expires)
+}
+
+// ValidatePayment checks that the passed transactions pay the request at the
+// passed time.  The amount paid to each requested script is summed over all
+// of the transactions and must be at least the total amount requested for
+// that script, so a request may be paid by several transactions and a single
+// output may pay several identical requested outputs.
+//
+// Only the outputs of the transactions are examined.  It is the
+// responsibility of the caller to ensure the transactions are valid and
+// spend unspent outputs, for instance by submitting them to the mempool.
+func (r *Request) ValidatePayment(txs []*wire.MsgTx, now time.Time) error {
+	if r.Expired(now) {
+		return ErrExpired
+	}
+	if len(txs) == 0 {
+		return ErrNoTransactions
+	}
+
+	requested := make(map[string]bchutil.Amount)
+	for _, out := range r.Outputs {
+		requested[string(out.Script)] += out.Amount
+	}
+	paid := make(map[string]bchutil.Amount)
+	for _, tx := range txs {
+		for _, out := range tx.TxOut {
+			if _, ok := requested[string(out.PkScript)]; ok {
+				paid[string(out.PkScript)] += bchutil.Amount(out.Value)
+			}
+		}
+	}
+	for _, out := range r.Outputs {
+		script := string(out.Script)
+		if paid[script] < requested[script] {
+			return fmt.Errorf("%w: output script %x paid %v of %v",
+				ErrInsufficientPayment, out.Script, paid[script],
+				requested[script])
+		}
+	}
+	return nil
+}
+
+// checkMerchantData returns ErrMerchantDataMismatch when the passed merchant
+// data of a payment differs from the merchant data of the request.
+func (r *Request) checkMerchantData(merchantData []byte) error {
+	if !bytes.Equal(r.MerchantData, merchantData) {
+		return ErrMerchantDataMismatch
+	}
+	return nil
+}
+
+// deserializeTransactions deserializes the passed raw transactions.
+func deserializeTransactions(rawTxs [][]byte) ([]*wire.MsgTx, error) {
+	txs := make([]*wire.MsgTx, 0, len(rawTxs))
+	for i, rawTx := range rawTxs {
+		var tx wire.MsgTx
+		if err := tx.Deserialize(bytes.NewReader(rawTx)); err != nil {
+			return nil, fmt.Errorf("invalid transaction %d: %v", i, err)
+		}
+		txs = append(txs, &tx)
+	}
+	return txs, nil
+}
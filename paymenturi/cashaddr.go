@@ -0,0 +1,111 @@
+package paymenturi
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gcash/bchutil"
+)
+
+const (
+	// cashAddrCharset is the base32 alphabet used by cashaddr.
+	cashAddrCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+	// cashAddrTypeTokenP2PKH and cashAddrTypeTokenP2SH are the cashaddr
+	// type bits of the token-aware pay-to-pubkey-hash and pay-to-script-hash
+	// address types defined by the CashTokens specification.
+	cashAddrTypeTokenP2PKH = 2
+	cashAddrTypeTokenP2SH  = 3
+)
+
+// cashAddrPolyMod computes the cashaddr checksum of the passed 5-bit values.
+func cashAddrPolyMod(v []byte) uint64 {
+	c := uint64(1)
+	for _, d := range v {
+		c0 := byte(c >> 35)
+		c = ((c & 0x07ffffffff) << 5) ^ uint64(d)
+		if c0&0x01 != 0 {
+			c ^= 0x98f2bc8e61
+		}
+		if c0&0x02 != 0 {
+			c ^= 0x79b76d99e2
+		}
+		if c0&0x04 != 0 {
+			c ^= 0xf33e5fb3c4
+		}
+		if c0&0x08 != 0 {
+			c ^= 0xae2eabe2a8
+		}
+		if c0&0x10 != 0 {
+			c ^= 0x1e4f43e470
+		}
+	}
+	return c ^ 1
+}
+
+// encodeCashAddr returns the cashaddr encoding, without prefix, of the passed
+// hash with the passed type bits.  bchutil only encodes the original address
+// types, so this is used for token-aware addresses.
+func encodeCashAddr(prefix string, addrType byte, hash []byte) (string, error) {
+	var sizeBits byte
+	switch len(hash) {
+	case 20:
+		sizeBits = 0
+	case 32:
+		sizeBits = 3
+	default:
+		return "", errors.New("unsupported address hash size")
+	}
+	payload := append([]byte{addrType<<3 | sizeBits}, hash...)
+
+	// Regroup the payload from 8-bit to 5-bit values, padding the final
+	// group with zeros.
+	var (
+		data []byte
+		acc  uint32
+		bits uint
+	)
+	for _, b := range payload {
+		acc = acc<<8 | uint32(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			data = append(data, byte(acc>>bits)&0x1f)
+		}
+	}
+	if bits > 0 {
+		data = append(data, byte(acc<<(5-bits))&0x1f)
+	}
+
+	// The checksum covers the lower 5 bits of each prefix character, a
+	// zero separator, the data, and eight zero placeholders.
+	checksumInput := make([]byte, 0, len(prefix)+1+len(data)+8)
+	for i := 0; i < len(prefix); i++ {
+		checksumInput = append(checksumInput, prefix[i]&0x1f)
+	}
+	checksumInput = append(checksumInput, 0)
+	checksumInput = append(checksumInput, data...)
+	checksumInput = append(checksumInput, make([]byte, 8)...)
+	checksum := cashAddrPolyMod(checksumInput)
+	for i := 0; i < 8; i++ {
+		data = append(data, byte(checksum>>(5*(7-uint(i))))&0x1f)
+	}
+
+	var sb strings.Builder
+	for _, d := range data {
+		sb.WriteByte(cashAddrCharset[d])
+	}
+	return sb.String(), nil
+}
+
+// isTokenAwareCashAddr returns whether the passed cashaddr, which must include
+// its prefix, is of one of the token-aware address types.
+func isTokenAwareCashAddr(addr string) bool {
+	_, data, err := bchutil.DecodeCashAddress(addr)
+	if err != nil || len(data) == 0 {
+		return false
+	}
+	// The first 5-bit group holds the reserved bit and the type bits of
+	// the version byte.
+	return data[0] == cashAddrTypeTokenP2PKH || data[0] == cashAddrTypeTokenP2SH
+}
@@ -0,0 +1,400 @@
+// Package paymenturi implements parsing and building of bitcoincash: payment
+// URIs as described by BIP0021, including the op_return_raw parameter used to
+// request a data carrier output and a set of parameters which request CashToken
+// payments.
+//
+// A payment URI consists of the cashaddr prefix of the network as the scheme,
+// an optional address, and optional query parameters:
+//
+//	bitcoincash:qrsrvtc95gg8rrag7dge3jlnfs4j9pe0ugrmeml950?amount=0.015&label=Coffee
+//
+// The following parameters are understood:
+//
+//	amount            amount in BCH as a decimal number with up to 8 places
+//	label             label for the recipient
+//	message           message describing the payment
+//	op_return_raw     hex encoded script data of a requested OP_RETURN output
+//	token_category    hex encoded category id of the requested CashTokens
+//	token_amount      requested fungible token amount
+//	token_commitment  hex encoded commitment of the requested NFT
+//	token_capability  capability of the requested NFT: none, mutable, minting
+//
+// As required by BIP0021, a URI containing any other parameter prefixed with
+// "req-" is rejected, while other unknown parameters are preserved.
+package paymenturi
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+const (
+	paramAmount          = "amount"
+	paramLabel           = "label"
+	paramMessage         = "message"
+	paramOpReturnRaw     = "op_return_raw"
+	paramTokenCategory   = "token_category"
+	paramTokenAmount     = "token_amount"
+	paramTokenCommitment = "token_commitment"
+	paramTokenCapability = "token_capability"
+
+	// requiredParamPrefix is the prefix of parameters which must be
+	// understood for a URI to be valid.
+	requiredParamPrefix = "req-"
+)
+
+var (
+	// ErrUnsupportedRequiredParam is returned when a URI contains a
+	// required parameter which is not understood.
+	ErrUnsupportedRequiredParam = errors.New("unsupported required parameter")
+
+	// ErrTokenAddressRequired is returned when a URI requests tokens to be
+	// paid to an address which is not token-aware.
+	ErrTokenAddressRequired = errors.New("tokens must be requested with a " +
+		"token-aware address")
+
+	// capabilityNames maps NFT capabilities to their parameter values.
+	capabilityNames = map[byte]string{
+		wire.NONE:    "none",
+		wire.MUTABLE: "mutable",
+		wire.MINTING: "minting",
+	}
+)
+
+// TokenRequest describes the CashTokens requested by a payment URI.
+type TokenRequest struct {
+	// Category is the category id of the requested tokens.
+	Category chainhash.Hash
+
+	// Amount is the requested fungible token amount, or zero when no
+	// fungible tokens are requested.
+	Amount uint64
+
+	// NFT is whether a non-fungible token is requested, in which case
+	// Capability and Commitment describe it.
+	NFT        bool
+	Capability byte
+	Commitment []byte
+}
+
+// URI is a parsed payment URI.
+type URI struct {
+	// Address is the address to pay to.  It is nil when the URI does not
+	// contain one, which is allowed when the payment details are conveyed
+	// by other parameters.
+	Address bchutil.Address
+
+	// TokenAware is whether the address is encoded as a token-aware
+	// cashaddr, which signals that the recipient accepts CashTokens.
+	TokenAware bool
+
+	// Amount is the requested amount, or zero when it is not specified.
+	Amount bchutil.Amount
+
+	Label   string
+	Message string
+
+	// OpReturn is the script data of the requested OP_RETURN output, not
+	// including the OP_RETURN opcode itself, or nil when no such output is
+	// requested.
+	OpReturn []byte
+
+	// Token describes the requested tokens, or is nil when none are
+	// requested.
+	Token *TokenRequest
+
+	// Params contains any other parameters of the URI.
+	Params map[string]string
+}
+
+// Parse parses the passed payment URI for the passed network.
+func Parse(uri string, params *chaincfg.Params) (*URI, error) {
+	sep := strings.IndexByte(uri, ':')
+	if sep == -1 || !strings.EqualFold(uri[:sep], params.CashAddressPrefix) {
+		return nil, fmt.Errorf("URI scheme is not %s", params.CashAddressPrefix)
+	}
+	rest := uri[sep+1:]
+	var query string
+	if i := strings.IndexByte(rest, '?'); i != -1 {
+		rest, query = rest[:i], rest[i+1:]
+	}
+
+	u := &URI{}
+	if rest != "" {
+		addr, err := bchutil.DecodeAddress(rest, params)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %s: %v", rest, err)
+		}
+		if !addr.IsForNet(params) {
+			return nil, fmt.Errorf("address %s is not for %s", rest,
+				params.Name)
+		}
+		u.Address = addr
+		u.TokenAware = isTokenAwareCashAddr(params.CashAddressPrefix +
+			":" + rest)
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URI parameters: %v", err)
+	}
+	var token TokenRequest
+	var hasToken, hasCategory bool
+	for key, vals := range values {
+		if len(vals) > 1 {
+			return nil, fmt.Errorf("duplicate parameter %s", key)
+		}
+		val := vals[0]
+
+		switch key {
+		case paramAmount:
+			u.Amount, err = parseAmount(val)
+			if err != nil {
+				return nil, err
+			}
+
+		case paramLabel:
+			u.Label = val
+
+		case paramMessage:
+			u.Message = val
+
+		case paramOpReturnRaw:
+			u.OpReturn, err = hex.DecodeString(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %v", key, err)
+			}
+
+		case paramTokenCategory:
+			category, err := chainhash.NewHashFromStr(val)
+			if err != nil || len(val) != chainhash.MaxHashStringSize {
+				return nil, fmt.Errorf("invalid %s %s", key, val)
+			}
+			token.Category = *category
+			hasCategory = true
+
+		case paramTokenAmount:
+			token.Amount, err = strconv.ParseUint(val, 10, 64)
+			if err != nil || token.Amount == 0 ||
+				token.Amount > wire.MAX_FT_AMOUNT {
+
+				return nil, fmt.Errorf("invalid %s %s", key, val)
+			}
+			hasToken = true
+
+		case paramTokenCommitment:
+			token.Commitment, err = hex.DecodeString(val)
+			if err != nil || len(token.Commitment) > wire.MAX_COMMITMENT_LENGTH {
+				return nil, fmt.Errorf("invalid %s %s", key, val)
+			}
+			token.NFT = true
+			hasToken = true
+
+		case paramTokenCapability:
+			capability, ok := parseCapability(val)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s %s", key, val)
+			}
+			token.Capability = capability
+			token.NFT = true
+			hasToken = true
+
+		default:
+			if strings.HasPrefix(key, requiredParamPrefix) {
+				return nil, fmt.Errorf("%w %s", ErrUnsupportedRequiredParam,
+					key)
+			}
+			if u.Params == nil {
+				u.Params = make(map[string]string)
+			}
+			u.Params[key] = val
+		}
+	}
+
+	if hasToken && !hasCategory {
+		return nil, fmt.Errorf("%s is required when requesting tokens",
+			paramTokenCategory)
+	}
+	if hasCategory {
+		if u.Address != nil && !u.TokenAware {
+			return nil, ErrTokenAddressRequired
+		}
+		u.Token = &token
+	}
+	return u, nil
+}
+
+// Encode returns the URI encoded for the passed network.  The address is
+// encoded as a token-aware cashaddr when TokenAware is set or tokens are
+// requested.
+func (u *URI) Encode(params *chaincfg.Params) (string, error) {
+	var sb strings.Builder
+	sb.WriteString(params.CashAddressPrefix)
+	sb.WriteByte(':')
+	if u.Address != nil {
+		if !u.Address.IsForNet(params) {
+			return "", fmt.Errorf("address is not for %s", params.Name)
+		}
+		addr, err := encodeAddress(u.Address, u.TokenAware ||
+			u.Token != nil, params)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(addr)
+	}
+
+	var query []string
+	add := func(key, val string) {
+		query = append(query, key+"="+escape(val))
+	}
+	if u.Amount < 0 || u.Amount > bchutil.MaxSatoshi {
+		return "", fmt.Errorf("amount %d is out of range", u.Amount)
+	}
+	if u.Amount > 0 {
+		add(paramAmount, formatAmount(u.Amount))
+	}
+	if u.Label != "" {
+		add(paramLabel, u.Label)
+	}
+	if u.Message != "" {
+		add(paramMessage, u.Message)
+	}
+	if u.OpReturn != nil {
+		add(paramOpReturnRaw, hex.EncodeToString(u.OpReturn))
+	}
+	if u.Token != nil {
+		add(paramTokenCategory, u.Token.Category.String())
+		if u.Token.Amount > wire.MAX_FT_AMOUNT {
+			return "", fmt.Errorf("token amount %d is out of range",
+				u.Token.Amount)
+		}
+		if u.Token.Amount > 0 {
+			add(paramTokenAmount, strconv.FormatUint(u.Token.Amount, 10))
+		}
+		if u.Token.NFT {
+			name, ok := capabilityNames[u.Token.Capability]
+			if !ok {
+				return "", fmt.Errorf("invalid NFT capability %d",
+					u.Token.Capability)
+			}
+			if len(u.Token.Commitment) > wire.MAX_COMMITMENT_LENGTH {
+				return "", errors.New("NFT commitment is too long")
+			}
+			add(paramTokenCapability, name)
+			if len(u.Token.Commitment) > 0 {
+				add(paramTokenCommitment,
+					hex.EncodeToString(u.Token.Commitment))
+			}
+		}
+	}
+
+	// Other parameters are sorted so the encoding is deterministic.
+	keys := make([]string, 0, len(u.Params))
+	for key := range u.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch key {
+		case paramAmount, paramLabel, paramMessage, paramOpReturnRaw,
+			paramTokenCategory, paramTokenAmount, paramTokenCommitment,
+			paramTokenCapability, "":
+
+			return "", fmt.Errorf("invalid parameter %q", key)
+		}
+		add(escape(key), u.Params[key])
+	}
+
+	if len(query) > 0 {
+		sb.WriteByte('?')
+		sb.WriteString(strings.Join(query, "&"))
+	}
+	return sb.String(), nil
+}
+
+// escape percent-encodes the passed string for use in a URI parameter.
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// parseAmount parses a decimal amount in BCH without loss of precision.
+func parseAmount(s string) (bchutil.Amount, error) {
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i != -1 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if (whole == "" && frac == "") || len(frac) > 8 ||
+		strings.IndexFunc(whole+frac, func(r rune) bool {
+			return r < '0' || r > '9'
+		}) != -1 {
+
+		return 0, fmt.Errorf("invalid amount %s", s)
+	}
+
+	var amount int64
+	if whole != "" {
+		bch, err := strconv.ParseInt(whole, 10, 64)
+		if err != nil || bch > int64(bchutil.MaxSatoshi/bchutil.SatoshiPerBitcoin) {
+			return 0, fmt.Errorf("amount %s is out of range", s)
+		}
+		amount = bch * bchutil.SatoshiPerBitcoin
+	}
+	if frac != "" {
+		frac += strings.Repeat("0", 8-len(frac))
+		sat, err := strconv.ParseInt(frac, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid amount %s", s)
+		}
+		amount += sat
+	}
+	if amount > bchutil.MaxSatoshi {
+		return 0, fmt.Errorf("amount %s is out of range", s)
+	}
+	return bchutil.Amount(amount), nil
+}
+
+// formatAmount formats the passed amount as a decimal amount in BCH without
+// trailing zeros.
+func formatAmount(amount bchutil.Amount) string {
+	s := fmt.Sprintf("%d.%08d", amount/bchutil.SatoshiPerBitcoin,
+		amount%bchutil.SatoshiPerBitcoin)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// parseCapability returns the NFT capability with the passed parameter value.
+func parseCapability(s string) (byte, bool) {
+	for capability, name := range capabilityNames {
+		if name == s {
+			return capability, true
+		}
+	}
+	return 0, false
+}
+
+// encodeAddress returns the cashaddr encoding, without prefix, of the passed
+// address, which is token-aware when requested.
+func encodeAddress(addr bchutil.Address, tokenAware bool, params *chaincfg.Params) (string, error) {
+	if !tokenAware {
+		return addr.EncodeAddress(), nil
+	}
+	switch addr := addr.(type) {
+	case *bchutil.AddressPubKeyHash:
+		return encodeCashAddr(params.CashAddressPrefix,
+			cashAddrTypeTokenP2PKH, addr.ScriptAddress())
+	case *bchutil.AddressScriptHash, *bchutil.AddressScriptHash32:
+		return encodeCashAddr(params.CashAddressPrefix,
+			cashAddrTypeTokenP2SH, addr.ScriptAddress())
+	}
+	return "", fmt.Errorf("address type %T cannot receive tokens", addr)
+}
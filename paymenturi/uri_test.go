@@ -0,0 +1,274 @@
+package paymenturi
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+const (
+	// testAddr and testTokenAddr are the same pay-to-pubkey-hash address
+	// encoded as an ordinary and as a token-aware cashaddr.  They are test
+	// vectors of the CashTokens specification.
+	testAddr      = "qr7fzmep8g7h7ymfxy74lgc0v950j3r2959lhtxxsl"
+	testTokenAddr = "zr7fzmep8g7h7ymfxy74lgc0v950j3r295z4y4gq0v"
+
+	testCategory = "8473d94f604de351cdee3030f6c354d36b257861ad8e95bbc0a06fbab2a2f9cf"
+)
+
+// TestParse ensures payment URIs are parsed as expected.
+func TestParse(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	tests := []struct {
+		name       string
+		uri        string
+		amount     bchutil.Amount
+		label      string
+		message    string
+		opReturn   []byte
+		tokenAware bool
+		token      *TokenRequest
+		extra      map[string]string
+		err        bool
+	}{
+		{
+			name: "address only",
+			uri:  "bitcoincash:" + testAddr,
+		},
+		{
+			name:   "amount and label",
+			uri:    "bitcoincash:" + testAddr + "?amount=0.015&label=Coffee%20Shop",
+			amount: 1500000,
+			label:  "Coffee Shop",
+		},
+		{
+			name:    "uppercase scheme and message",
+			uri:     "BITCOINCASH:" + testAddr + "?amount=21&message=for%20pizza",
+			amount:  2100000000,
+			message: "for pizza",
+		},
+		{
+			name:     "op_return_raw",
+			uri:      "bitcoincash:" + testAddr + "?op_return_raw=0401020304",
+			opReturn: []byte{0x04, 0x01, 0x02, 0x03, 0x04},
+		},
+		{
+			name:  "unknown optional parameter",
+			uri:   "bitcoincash:" + testAddr + "?foo=bar",
+			extra: map[string]string{"foo": "bar"},
+		},
+		{
+			name:       "fungible tokens",
+			uri:        "bitcoincash:" + testTokenAddr + "?token_category=" + testCategory + "&token_amount=100",
+			tokenAware: true,
+			token:      &TokenRequest{Amount: 100},
+		},
+		{
+			name:       "mutable NFT",
+			uri:        "bitcoincash:" + testTokenAddr + "?token_category=" + testCategory + "&token_capability=mutable&token_commitment=abcd",
+			tokenAware: true,
+			token: &TokenRequest{
+				NFT:        true,
+				Capability: wire.MUTABLE,
+				Commitment: []byte{0xab, 0xcd},
+			},
+		},
+		{
+			name: "tokens to ordinary address",
+			uri:  "bitcoincash:" + testAddr + "?token_category=" + testCategory,
+			err:  true,
+		},
+		{
+			name: "token amount without category",
+			uri:  "bitcoincash:" + testTokenAddr + "?token_amount=1",
+			err:  true,
+		},
+		{
+			name: "unsupported required parameter",
+			uri:  "bitcoincash:" + testAddr + "?req-somethingyoudontunderstand=50",
+			err:  true,
+		},
+		{
+			name: "wrong scheme",
+			uri:  "bchtest:" + testAddr,
+			err:  true,
+		},
+		{
+			name: "bad checksum",
+			uri:  "bitcoincash:qr7fzmep8g7h7ymfxy74lgc0v950j3r2959lhtxxsm",
+			err:  true,
+		},
+		{
+			name: "too many decimals",
+			uri:  "bitcoincash:" + testAddr + "?amount=0.000000001",
+			err:  true,
+		},
+		{
+			name: "negative amount",
+			uri:  "bitcoincash:" + testAddr + "?amount=-1",
+			err:  true,
+		},
+		{
+			name: "amount above max",
+			uri:  "bitcoincash:" + testAddr + "?amount=21000000.00000001",
+			err:  true,
+		},
+		{
+			name: "duplicate parameter",
+			uri:  "bitcoincash:" + testAddr + "?amount=1&amount=2",
+			err:  true,
+		},
+	}
+
+	for _, test := range tests {
+		u, err := Parse(test.uri, params)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if u.Address.EncodeAddress() != testAddr {
+			t.Errorf("%s: unexpected address %s", test.name,
+				u.Address.EncodeAddress())
+		}
+		if u.Amount != test.amount || u.Label != test.label ||
+			u.Message != test.message || u.TokenAware != test.tokenAware {
+
+			t.Errorf("%s: unexpected URI %+v", test.name, u)
+		}
+		if !bytes.Equal(u.OpReturn, test.opReturn) {
+			t.Errorf("%s: unexpected op_return %x", test.name, u.OpReturn)
+		}
+		if len(u.Params) != len(test.extra) {
+			t.Errorf("%s: unexpected params %v", test.name, u.Params)
+		}
+		for k, v := range test.extra {
+			if u.Params[k] != v {
+				t.Errorf("%s: unexpected param %s=%s", test.name, k,
+					u.Params[k])
+			}
+		}
+		if (u.Token == nil) != (test.token == nil) {
+			t.Errorf("%s: unexpected token request %+v", test.name,
+				u.Token)
+			continue
+		}
+		if test.token != nil {
+			if u.Token.Category.String() != testCategory ||
+				u.Token.Amount != test.token.Amount ||
+				u.Token.NFT != test.token.NFT ||
+				u.Token.Capability != test.token.Capability ||
+				!bytes.Equal(u.Token.Commitment, test.token.Commitment) {
+
+				t.Errorf("%s: unexpected token request %+v",
+					test.name, u.Token)
+			}
+		}
+	}
+
+	_, err := Parse("bitcoincash:"+testAddr+"?req-foo=1", params)
+	if !errors.Is(err, ErrUnsupportedRequiredParam) {
+		t.Errorf("unexpected error for required parameter: %v", err)
+	}
+}
+
+// TestEncode ensures payment URIs are encoded as expected and round trip
+// through Parse.
+func TestEncode(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	addr, err := bchutil.DecodeAddress(testAddr, params)
+	if err != nil {
+		t.Fatalf("DecodeAddress: %v", err)
+	}
+	category, err := Parse("bitcoincash:"+testTokenAddr+"?token_category="+
+		testCategory, params)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		uri  *URI
+		want string
+	}{
+		{
+			name: "no address",
+			uri:  &URI{Amount: 100000000},
+			want: "bitcoincash:?amount=1",
+		},
+		{
+			name: "amount, label and message",
+			uri: &URI{
+				Address: addr,
+				Amount:  1500000,
+				Label:   "Coffee & Cake",
+				Message: "thanks!",
+			},
+			want: "bitcoincash:" + testAddr + "?amount=0.015&label=Coffee%20%26%20Cake&message=thanks%21",
+		},
+		{
+			name: "op_return and extra parameters",
+			uri: &URI{
+				Address:  addr,
+				OpReturn: []byte{0x6a},
+				Params:   map[string]string{"z": "1", "a": "2"},
+			},
+			want: "bitcoincash:" + testAddr + "?op_return_raw=6a&a=2&z=1",
+		},
+		{
+			name: "token-aware address",
+			uri:  &URI{Address: addr, TokenAware: true},
+			want: "bitcoincash:" + testTokenAddr,
+		},
+		{
+			name: "tokens",
+			uri: &URI{
+				Address: addr,
+				Token: &TokenRequest{
+					Category:   category.Token.Category,
+					Amount:     5,
+					NFT:        true,
+					Capability: wire.MINTING,
+					Commitment: []byte{0x01},
+				},
+			},
+			want: "bitcoincash:" + testTokenAddr + "?token_category=" + testCategory +
+				"&token_amount=5&token_capability=minting&token_commitment=01",
+		},
+	}
+
+	for _, test := range tests {
+		got, err := test.uri.Encode(params)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+			continue
+		}
+		u, err := Parse(got, params)
+		if err != nil {
+			t.Errorf("%s: failed to parse encoded URI: %v", test.name, err)
+			continue
+		}
+		if u.Label != test.uri.Label || u.Message != test.uri.Message ||
+			u.Amount != test.uri.Amount {
+
+			t.Errorf("%s: URI did not round trip: %+v", test.name, u)
+		}
+	}
+
+	if _, err := (&URI{Address: addr}).Encode(&chaincfg.TestNet3Params); err == nil {
+		t.Errorf("expected error encoding address for another network")
+	}
+}
@@ -153,6 +153,9 @@ func messageSummary(msg wire.Message) string {
 	case *wire.MsgVerAck:
 		// No summary.
 
+	case *wire.MsgExtVersion:
+		return fmt.Sprintf("%d entries", len(msg.Entries))
+
 	case *wire.MsgGetAddr:
 		// No summary.
 
@@ -221,6 +224,19 @@ func messageSummary(msg wire.Message) string {
 	case *wire.MsgBlockTxns:
 		return fmt.Sprintf("txs %d", len(msg.Txs))
 
+	case *wire.MsgSendTxRcncl:
+		return fmt.Sprintf("version %d", msg.Version)
+
+	case *wire.MsgReqRecon:
+		return fmt.Sprintf("set size %d, q %d", msg.SetSize, msg.Q)
+
+	case *wire.MsgSketch:
+		return fmt.Sprintf("%d bytes", len(msg.SketchData))
+
+	case *wire.MsgReconcilDiff:
+		return fmt.Sprintf("success %v, %d asks", msg.Success,
+			len(msg.AskShortIDs))
+
 	case *wire.MsgReject:
 		// Ensure the variable length strings don't contain any
 		// characters which are even remotely dangerous such as HTML
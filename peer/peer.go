@@ -43,6 +43,10 @@ const (
 	// inventory cache.
 	DefaultMaxKnownInventory = 2000
 
+	// OtherMsgCommand is the command the bytes of messages which could not
+	// be decoded are counted under in the per message statistics.
+	OtherMsgCommand = "*other*"
+
 	// outputBufferSize is the number of elements the output channels use.
 	outputBufferSize = 50
 
@@ -102,6 +106,9 @@ type MessageListeners struct {
 	// OnAddr is invoked when a peer receives an addr bitcoin message.
 	OnAddr func(p *Peer, msg *wire.MsgAddr)
 
+	// OnAddrV2 is invoked when a peer receives an addrv2 bitcoin message.
+	OnAddrV2 func(p *Peer, msg *wire.MsgAddrV2)
+
 	// OnPing is invoked when a peer receives a ping bitcoin message.
 	OnPing func(p *Peer, msg *wire.MsgPing)
 
@@ -217,6 +224,10 @@ type MessageListeners struct {
 	// message.
 	OnBlockTxns func(p *Peer, msg *wire.MsgBlockTxns)
 
+	// OnWeakBlock is invoked when a peer receives a weakblock bitcoin
+	// message.
+	OnWeakBlock func(p *Peer, msg *wire.MsgWeakBlock)
+
 	// OnRead is invoked when a peer receives a bitcoin message.  It
 	// consists of the number of bytes read, the message, and whether or not
 	// an error in the read occurred.  Typically, callers will opt to use
@@ -304,6 +315,21 @@ type Config struct {
 	// MaxKnownInventory is the maximum number of known inventory items we will hold
 	// in memory for this peer.
 	MaxKnownInventory uint
+
+	// ExtVersionEntries holds the entries of the extversion message sent to
+	// the peer during the version handshake, which happens when both peers
+	// advertise SFNodeExtVersion.  The extversion protocol version entry is
+	// always included.
+	ExtVersionEntries map[uint64][]byte
+
+	// TxReconciliation specifies whether or not transactions should be
+	// relayed to the peer by set reconciliation when the peer supports it,
+	// instead of announcing each of them with an inv message.
+	TxReconciliation bool
+
+	// TxReconciliationInterval is the duration between the reconciliation
+	// rounds started with the peer.
+	TxReconciliationInterval time.Duration
 }
 
 // newNetAddress attempts to extract the IP address and port from the passed
@@ -399,7 +425,14 @@ type StatsSnap struct {
 	LastPingNonce  uint64
 	LastPingTime   time.Time
 	LastPingMicros int64
+	MinPingMicros  int64
 	SyncPeer       bool
+
+	// BytesSentPerMsg and BytesRecvPerMsg hold the number of bytes sent
+	// and received by message command.  Messages with an unknown command
+	// are counted as OtherMsgCommand.
+	BytesSentPerMsg map[string]uint64
+	BytesRecvPerMsg map[string]uint64
 }
 
 // HashFunc is a function which returns a block hash, height and error
@@ -466,8 +499,10 @@ type Peer struct {
 	advertisedProtoVer   uint32 // protocol version advertised by remote
 	protocolVersion      uint32 // negotiated protocol version
 	sendHeadersPreferred bool   // peer sent a sendheaders message
+	addrV2Preferred      bool   // peer sent a sendaddrv2 message
 	verAckReceived       bool
 	xVersionReceived     bool
+	extVersion           *wire.MsgExtVersion // extversion sent by remote
 	syncPeer             bool
 
 	wireEncoding wire.MessageEncoding
@@ -491,6 +526,9 @@ type Peer struct {
 	lastPingNonce      uint64    // Set to nonce if we have a pending ping.
 	lastPingTime       time.Time // Time we sent last ping.
 	lastPingMicros     int64     // Time for last ping to return.
+	minPingMicros      int64     // Shortest time for a ping to return.
+	bytesSentPerMsg    map[string]uint64
+	bytesRecvPerMsg    map[string]uint64
 
 	stallControl  chan stallControlMsg
 	outputQueue   chan outMsg
@@ -506,6 +544,10 @@ type Peer struct {
 	compactBlocksPreferred    bool
 	directBlockRelayPreferred bool
 	allowDirectBlockRelay     bool
+
+	// txRecon is the transaction reconciliation state.  It is nil when
+	// transaction reconciliation is disabled.
+	txRecon *txReconState
 }
 
 // String returns the peer's address and directionality as a human-readable
@@ -617,8 +659,17 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 		LastPingNonce:  p.lastPingNonce,
 		LastPingMicros: p.lastPingMicros,
 		LastPingTime:   p.lastPingTime,
+		MinPingMicros:  p.minPingMicros,
 		SyncPeer:       p.SyncPeer(),
 	}
+	statsSnap.BytesSentPerMsg = make(map[string]uint64, len(p.bytesSentPerMsg))
+	for command, n := range p.bytesSentPerMsg {
+		statsSnap.BytesSentPerMsg[command] = n
+	}
+	statsSnap.BytesRecvPerMsg = make(map[string]uint64, len(p.bytesRecvPerMsg))
+	for command, n := range p.bytesRecvPerMsg {
+		statsSnap.BytesRecvPerMsg[command] = n
+	}
 
 	p.statsMtx.RUnlock()
 	return statsSnap
@@ -882,6 +933,66 @@ func (p *Peer) WantsHeaders() bool {
 	return sendHeadersPreferred
 }
 
+// WantsAddrV2 returns if the peer signaled support for addrv2 messages
+// during the version negotiation.
+//
+// This function is safe for concurrent access.
+func (p *Peer) WantsAddrV2() bool {
+	p.flagsMtx.Lock()
+	addrV2Preferred := p.addrV2Preferred
+	p.flagsMtx.Unlock()
+
+	return addrV2Preferred
+}
+
+// SupportsExtVersion returns whether or not the peer sent an extversion message
+// during the version negotiation.
+//
+// This function is safe for concurrent access.
+func (p *Peer) SupportsExtVersion() bool {
+	p.flagsMtx.Lock()
+	extVersion := p.extVersion
+	p.flagsMtx.Unlock()
+
+	return extVersion != nil
+}
+
+// ExtVersionEntry returns the value of the extversion entry with the passed key
+// sent by the peer.  Only keys which were sent by both peers are negotiated, so
+// it returns false when the entry is missing from either extversion message.
+//
+// This function is safe for concurrent access.
+func (p *Peer) ExtVersionEntry(key uint64) ([]byte, bool) {
+	p.flagsMtx.Lock()
+	extVersion := p.extVersion
+	p.flagsMtx.Unlock()
+
+	if extVersion == nil {
+		return nil, false
+	}
+	if _, ok := p.cfg.ExtVersionEntries[key]; !ok &&
+		key != wire.ExtVersionKeyVersion {
+
+		return nil, false
+	}
+	value, ok := extVersion.Entries[key]
+	return value, ok
+}
+
+// ExtVersionUint64 returns the value of the negotiated extversion entry with
+// the passed key sent by the peer decoded as a compact size integer.  See
+// ExtVersionEntry for details.
+//
+// This function is safe for concurrent access.
+func (p *Peer) ExtVersionUint64(key uint64) (uint64, bool) {
+	value, ok := p.ExtVersionEntry(key)
+	if !ok {
+		return 0, false
+	}
+	msg := wire.MsgExtVersion{Entries: map[uint64][]byte{key: value}}
+	return msg.Uint64(key)
+}
+
 // WantsCompactBlocks returns if the peer wants header cmpctblocks instead of
 // regular blocks.
 //
@@ -932,36 +1043,46 @@ func (p *Peer) AllowDirectBlockRelay() bool {
 // addresses.  This function is useful over manually sending the message via
 // QueueMessage since it automatically limits the addresses to the maximum
 // number allowed by the message and randomizes the chosen addresses when there
-// are too many.  It returns the addresses that were actually sent and no
-// message will be sent if there are no entries in the provided addresses slice.
+// are too many.  An addrv2 message is sent instead when the peer signaled
+// support for it, otherwise addresses which can only be relayed with addrv2
+// messages are left out.  It returns the addresses that were actually sent and
+// no message will be sent if there are no entries in the provided addresses
+// slice.
 //
 // This function is safe for concurrent access.
 func (p *Peer) PushAddrMsg(addresses []*wire.NetAddress) ([]*wire.NetAddress, error) {
-	addressCount := len(addresses)
+	addrV2 := p.WantsAddrV2()
+	addrList := make([]*wire.NetAddress, 0, len(addresses))
+	for _, na := range addresses {
+		if addrV2 || na.IsAddrV1Compatible() {
+			addrList = append(addrList, na)
+		}
+	}
+	addressCount := len(addrList)
 
 	// Nothing to send.
 	if addressCount == 0 {
 		return nil, nil
 	}
 
-	msg := wire.NewMsgAddr()
-	msg.AddrList = make([]*wire.NetAddress, addressCount)
-	copy(msg.AddrList, addresses)
-
 	// Randomize the addresses sent if there are more than the maximum allowed.
 	if addressCount > wire.MaxAddrPerMsg {
 		// Shuffle the address list.
 		for i := 0; i < wire.MaxAddrPerMsg; i++ {
 			j := i + rand.Intn(addressCount-i)
-			msg.AddrList[i], msg.AddrList[j] = msg.AddrList[j], msg.AddrList[i]
+			addrList[i], addrList[j] = addrList[j], addrList[i]
 		}
 
 		// Truncate it to the maximum size.
-		msg.AddrList = msg.AddrList[:wire.MaxAddrPerMsg]
+		addrList = addrList[:wire.MaxAddrPerMsg]
 	}
 
-	p.QueueMessage(msg, nil)
-	return msg.AddrList, nil
+	if addrV2 {
+		p.QueueMessage(&wire.MsgAddrV2{AddrList: addrList}, nil)
+	} else {
+		p.QueueMessage(&wire.MsgAddr{AddrList: addrList}, nil)
+	}
+	return addrList, nil
 }
 
 // PushGetBlocksMsg sends a getblocks message for the provided block locator
@@ -1119,16 +1240,36 @@ func (p *Peer) handlePongMsg(msg *wire.MsgPong) {
 			p.lastPingMicros = time.Since(p.lastPingTime).Nanoseconds()
 			p.lastPingMicros /= 1000 // convert to usec.
 			p.lastPingNonce = 0
+			if p.minPingMicros == 0 || p.lastPingMicros < p.minPingMicros {
+				p.minPingMicros = p.lastPingMicros
+			}
 		}
 		p.statsMtx.Unlock()
 	}
 }
 
+// addMsgBytes adds the passed number of bytes to the per message counter of the
+// command of the passed message, which may be nil when the message could not be
+// decoded.
+func (p *Peer) addMsgBytes(perMsg map[string]uint64, msg wire.Message, n int) {
+	if n == 0 {
+		return
+	}
+	command := OtherMsgCommand
+	if msg != nil {
+		command = msg.Command()
+	}
+	p.statsMtx.Lock()
+	perMsg[command] += uint64(n)
+	p.statsMtx.Unlock()
+}
+
 // readMessage reads the next bitcoin message from the peer with logging.
 func (p *Peer) readMessage(encoding wire.MessageEncoding) (wire.Message, []byte, error) {
 	n, msg, buf, err := wire.ReadMessageWithEncodingN(p.conn,
 		p.ProtocolVersion(), p.cfg.ChainParams.Net, encoding)
 	atomic.AddUint64(&p.bytesReceived, uint64(n))
+	p.addMsgBytes(p.bytesRecvPerMsg, msg, n)
 	if p.cfg.Listeners.OnRead != nil {
 		p.cfg.Listeners.OnRead(p, n, msg, err)
 	}
@@ -1192,6 +1333,7 @@ func (p *Peer) writeMessage(msg wire.Message, enc wire.MessageEncoding) error {
 	n, err := wire.WriteMessageWithEncodingN(p.conn, msg,
 		p.ProtocolVersion(), p.cfg.ChainParams.Net, enc)
 	atomic.AddUint64(&p.bytesSent, uint64(n))
+	p.addMsgBytes(p.bytesSentPerMsg, msg, n)
 	if p.cfg.Listeners.OnWrite != nil {
 		p.cfg.Listeners.OnWrite(p, n, msg, err)
 	}
@@ -1535,6 +1677,13 @@ out:
 				p.cfg.Listeners.OnXVersion(p, msg)
 			}
 
+		case *wire.MsgExtVersion:
+			// The extversion message is only allowed during the
+			// version negotiation.
+			p.PushRejectMsg(msg.Command(), wire.RejectDuplicate,
+				"extversion message after verack", nil, true)
+			break out
+
 		case *wire.MsgVerAck:
 			// Limit to one verack message per peer.
 			p.PushRejectMsg(
@@ -1553,6 +1702,17 @@ out:
 				p.cfg.Listeners.OnAddr(p, msg)
 			}
 
+		case *wire.MsgAddrV2:
+			if p.cfg.Listeners.OnAddrV2 != nil {
+				p.cfg.Listeners.OnAddrV2(p, msg)
+			}
+
+		case *wire.MsgSendAddrV2:
+			// Support for addrv2 messages must be signaled before the
+			// verack message, so later sendaddrv2 messages are ignored.
+			log.Debugf("Ignoring sendaddrv2 message received after "+
+				"verack from %v", p)
+
 		case *wire.MsgPing:
 			p.handlePingMsg(msg)
 			if p.cfg.Listeners.OnPing != nil {
@@ -1705,6 +1865,23 @@ out:
 				p.cfg.Listeners.OnBlockTxns(p, msg)
 			}
 
+		case *wire.MsgWeakBlock:
+			if p.cfg.Listeners.OnWeakBlock != nil {
+				p.cfg.Listeners.OnWeakBlock(p, msg)
+			}
+
+		case *wire.MsgSendTxRcncl:
+			p.handleSendTxRcnclMsg(msg)
+
+		case *wire.MsgReqRecon:
+			p.handleReqReconMsg(msg)
+
+		case *wire.MsgSketch:
+			p.handleSketchMsg(msg)
+
+		case *wire.MsgReconcilDiff:
+			p.handleReconcilDiffMsg(msg)
+
 		default:
 			log.Debugf("Received unhandled message of type %v "+
 				"from %v", rmsg.Command(), p)
@@ -1745,6 +1922,15 @@ func (p *Peer) queueHandler() {
 		trickleTicker = &time.Ticker{C: make(chan (time.Time))}
 	}
 
+	// The reconciliation rounds are only started by the initiator.
+	var reconTicker *time.Ticker
+	if p.txRecon != nil && p.txRecon.initiator {
+		reconTicker = time.NewTicker(p.cfg.TxReconciliationInterval)
+		defer reconTicker.Stop()
+	} else {
+		reconTicker = &time.Ticker{C: make(chan (time.Time))}
+	}
+
 	// We keep the waiting flag so that we know if we have a message queued
 	// to the outHandler or not.  We could use the presence of a head of
 	// the list for this but then we have rather racy concerns about whether
@@ -1803,6 +1989,12 @@ out:
 				continue
 			}
 
+			// If transactions are reconciled with the peer then queue
+			// the tx for the next reconciliation round.
+			if p.txRecon != nil && p.txRecon.addTx(&iv.Hash) {
+				continue
+			}
+
 			// If it's a new tx and the trickle queue is enabled then enqueue the inv.
 			if useTrickleQueue {
 				invSendQueue.PushBack(iv)
@@ -1856,6 +2048,12 @@ out:
 					pendingMsgs, waiting)
 			}
 
+		case <-reconTicker.C:
+			if atomic.LoadInt32(&p.disconnect) != 0 {
+				continue
+			}
+			p.startTxReconRound()
+
 		case <-p.quit:
 			break out
 		}
@@ -2172,9 +2370,41 @@ func (p *Peer) readRemoteVerAckMsg() error {
 	}
 
 	// We might see a sendaddrv2 message here, that is OKAY based on
-	// the spec!
-	_, ok := remoteMsg.(*wire.MsgSendAddrV2)
-	if ok {
+	// the spec!  It signals the peer wants to receive addrv2 messages.
+	// The extversion message is sent before the verack as well when both
+	// peers support it.  Each of them may only be sent once.
+	var sendAddrV2Received, extVersionReceived bool
+out:
+	for {
+		switch msg := remoteMsg.(type) {
+		case *wire.MsgSendAddrV2:
+			if sendAddrV2Received {
+				return errors.New("duplicate sendaddrv2 message")
+			}
+			sendAddrV2Received = true
+			if p.ProtocolVersion() >= wire.AddrV2Version {
+				p.flagsMtx.Lock()
+				p.addrV2Preferred = true
+				p.flagsMtx.Unlock()
+			}
+
+		case *wire.MsgExtVersion:
+			if extVersionReceived || !p.extVersionEnabled() {
+				reason := "unexpected extversion message"
+				rejectMsg := wire.NewMsgReject(msg.Command(),
+					wire.RejectMalformed, reason)
+				_ = p.writeMessage(rejectMsg, wire.LatestEncoding)
+				return errors.New(reason)
+			}
+			extVersionReceived = true
+			p.flagsMtx.Lock()
+			p.extVersion = msg
+			p.flagsMtx.Unlock()
+
+		default:
+			break out
+		}
+
 		remoteMsg, _, err = p.readMessage(wire.LatestEncoding)
 		if err != nil {
 			return err
@@ -2281,14 +2511,55 @@ func (p *Peer) writeLocalVersionMsg() error {
 	return p.writeMessage(localVerMsg, wire.LatestEncoding)
 }
 
+// writeSendAddrV2Msg signals support for addrv2 messages to the remote peer
+// when the negotiated protocol version supports them.  It must be sent before
+// our verack message.
+func (p *Peer) writeSendAddrV2Msg() error {
+	if p.ProtocolVersion() < wire.AddrV2Version {
+		return nil
+	}
+
+	return p.writeMessage(wire.NewMsgSendAddrV2(), wire.LatestEncoding)
+}
+
+// extVersionEnabled returns whether or not both peers advertised support for
+// the extversion message.
+func (p *Peer) extVersionEnabled() bool {
+	return p.cfg.Services&wire.SFNodeExtVersion == wire.SFNodeExtVersion &&
+		p.Services()&wire.SFNodeExtVersion == wire.SFNodeExtVersion
+}
+
+// writeExtVersionMsg writes our extversion message to the remote peer when both
+// peers advertised support for it.  It must be sent before our verack message.
+func (p *Peer) writeExtVersionMsg() error {
+	if !p.extVersionEnabled() {
+		return nil
+	}
+
+	msg := wire.NewMsgExtVersion()
+	for key, value := range p.cfg.ExtVersionEntries {
+		if err := msg.AddEntry(key, value); err != nil {
+			return err
+		}
+	}
+	err := msg.AddUint64(wire.ExtVersionKeyVersion,
+		wire.ExtVersionProtocolVersion)
+	if err != nil {
+		return err
+	}
+	return p.writeMessage(msg, wire.LatestEncoding)
+}
+
 // negotiateInboundProtocol performs the negotiation protocol for an inbound
 // peer. The events should occur in the following order, otherwise an error is
 // returned:
 //
 //  1. Remote peer sends their version.
 //  2. We send our version.
-//  3. We send our verack.
-//  4. Remote peer sends their verack.
+//  3. We send our extversion, if supported by both peers.
+//  4. We send our sendaddrv2, if supported.
+//  5. We send our verack.
+//  6. Remote peer sends their verack.
 func (p *Peer) negotiateInboundProtocol() error {
 	if err := p.readRemoteVersionMsg(); err != nil {
 		return err
@@ -2298,6 +2569,14 @@ func (p *Peer) negotiateInboundProtocol() error {
 		return err
 	}
 
+	if err := p.writeExtVersionMsg(); err != nil {
+		return err
+	}
+
+	if err := p.writeSendAddrV2Msg(); err != nil {
+		return err
+	}
+
 	err := p.writeMessage(wire.NewMsgVerAck(), wire.LatestEncoding)
 	if err != nil {
 		return err
@@ -2313,7 +2592,9 @@ func (p *Peer) negotiateInboundProtocol() error {
 //  1. We send our version.
 //  2. Remote peer sends their version.
 //  3. Remote peer sends their verack.
-//  4. We send our verack.
+//  4. We send our extversion, if supported by both peers.
+//  5. We send our sendaddrv2, if supported.
+//  6. We send our verack.
 func (p *Peer) negotiateOutboundProtocol() error {
 	if err := p.writeLocalVersionMsg(); err != nil {
 		return err
@@ -2327,6 +2608,14 @@ func (p *Peer) negotiateOutboundProtocol() error {
 		return err
 	}
 
+	if err := p.writeExtVersionMsg(); err != nil {
+		return err
+	}
+
+	if err := p.writeSendAddrV2Msg(); err != nil {
+		return err
+	}
+
 	return p.writeMessage(wire.NewMsgVerAck(), wire.LatestEncoding)
 }
 
@@ -2364,6 +2653,12 @@ func (p *Peer) start() error {
 	go p.outHandler()
 	go p.pingHandler()
 
+	// Signal support for transaction reconciliation to the peer.
+	if p.txRecon != nil {
+		p.QueueMessage(wire.NewMsgSendTxRcncl(wire.TxReconciliationVersion,
+			p.txRecon.localSalt), nil)
+	}
+
 	return nil
 }
 
@@ -2435,10 +2730,17 @@ func newPeerBase(origCfg *Config, inbound bool) *Peer {
 		cfg.MaxKnownInventory = DefaultMaxKnownInventory
 	}
 
+	// Set the reconciliation interval if a non-positive value is specified.
+	if cfg.TxReconciliationInterval <= 0 {
+		cfg.TxReconciliationInterval = DefaultTxReconciliationInterval
+	}
+
 	p := Peer{
 		inbound:         inbound,
 		wireEncoding:    wire.BaseEncoding,
 		knownInventory:  newMruInventoryMap(cfg.MaxKnownInventory),
+		bytesSentPerMsg: make(map[string]uint64),
+		bytesRecvPerMsg: make(map[string]uint64),
 		stallControl:    make(chan stallControlMsg, 1), // nonblocking sync
 		outputQueue:     make(chan outMsg, outputBufferSize),
 		sendQueue:       make(chan outMsg, 1),   // nonblocking sync
@@ -2453,6 +2755,11 @@ func newPeerBase(origCfg *Config, inbound bool) *Peer {
 		protocolVersion: cfg.ProtocolVersion,
 		syncPeer:        false,
 	}
+
+	// Transactions are only reconciled when they are relayed at all.
+	if cfg.TxReconciliation && !cfg.DisableRelayTx {
+		p.txRecon = newTxReconState(rand.Uint64(), !inbound)
+	}
 	return &p
 }
 
@@ -6,6 +6,7 @@
 package peer_test
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"net"
@@ -218,6 +219,21 @@ func testPeer(t *testing.T, p *peer.Peer, s peerStats) {
 		t.Errorf("testPeer: wrong LastRecv - got %v, want %v", p.LastRecv(), stats.LastRecv)
 		return
 	}
+
+	// The per message byte counters add up to the total bytes.
+	var sentPerMsg, recvPerMsg uint64
+	for _, n := range stats.BytesSentPerMsg {
+		sentPerMsg += n
+	}
+	for _, n := range stats.BytesRecvPerMsg {
+		recvPerMsg += n
+	}
+	if sentPerMsg != s.wantBytesSent || recvPerMsg != s.wantBytesReceived {
+		t.Errorf("testPeer: wrong per message bytes - got %v sent and %v "+
+			"received, want %v and %v", sentPerMsg, recvPerMsg,
+			s.wantBytesSent, s.wantBytesReceived)
+		return
+	}
 }
 
 // TestPeerConnection tests connection between inbound and outbound peers.
@@ -984,3 +1000,283 @@ func TestUpdateLastBlockHeight(t *testing.T) {
 			remotePeerHeight+1)
 	}
 }
+
+// TestAddrV2Negotiation ensures support for addrv2 messages is negotiated
+// depending on the protocol version and addresses are relayed with the
+// message the peer supports.
+func TestAddrV2Negotiation(t *testing.T) {
+	torV3, err := wire.NewNetAddressV2(time.Now(), wire.SFNodeNetwork,
+		wire.NetworkTorV3, make([]byte, 32), 8333)
+	if err != nil {
+		t.Fatalf("NewNetAddressV2: %v", err)
+	}
+	ipv4 := wire.NewNetAddressIPPort(net.ParseIP("1.2.3.4"), 8333,
+		wire.SFNodeNetwork)
+
+	tests := []struct {
+		name       string
+		pver       uint32
+		wantAddrV2 bool
+	}{
+		{"addrv2", wire.AddrV2Version, true},
+		{"addr", wire.AddrV2Version - 1, false},
+	}
+
+	for _, test := range tests {
+		verack := make(chan struct{}, 2)
+		addrs := make(chan []*wire.NetAddress, 1)
+		peerCfg := &peer.Config{
+			Listeners: peer.MessageListeners{
+				OnVerAck: func(_ *peer.Peer, _ *wire.MsgVerAck) {
+					verack <- struct{}{}
+				},
+				OnAddr: func(_ *peer.Peer, msg *wire.MsgAddr) {
+					if test.wantAddrV2 {
+						t.Errorf("%s: unexpected addr message", test.name)
+					}
+					addrs <- msg.AddrList
+				},
+				OnAddrV2: func(_ *peer.Peer, msg *wire.MsgAddrV2) {
+					if !test.wantAddrV2 {
+						t.Errorf("%s: unexpected addrv2 message", test.name)
+					}
+					addrs <- msg.AddrList
+				},
+			},
+			UserAgentName:          "peer",
+			UserAgentVersion:       "1.0",
+			ChainParams:            &chaincfg.MainNetParams,
+			ProtocolVersion:        test.pver,
+			TstAllowSelfConnection: true,
+		}
+		inConn, outConn := pipe(
+			&conn{laddr: "10.0.0.1:8333", raddr: "10.0.0.2:8333"},
+			&conn{laddr: "10.0.0.2:8333", raddr: "10.0.0.1:8333"},
+		)
+		inPeer := peer.NewInboundPeer(peerCfg)
+		inPeer.AssociateConnection(inConn)
+		outPeer, err := peer.NewOutboundPeer(peerCfg, inConn.laddr)
+		if err != nil {
+			t.Fatalf("NewOutboundPeer: unexpected err: %v", err)
+		}
+		outPeer.AssociateConnection(outConn)
+		for i := 0; i < 2; i++ {
+			select {
+			case <-verack:
+			case <-time.After(time.Second):
+				t.Fatalf("%s: verack timeout", test.name)
+			}
+		}
+
+		if inPeer.WantsAddrV2() != test.wantAddrV2 ||
+			outPeer.WantsAddrV2() != test.wantAddrV2 {
+			t.Fatalf("%s: WantsAddrV2 inbound %v outbound %v, want %v",
+				test.name, inPeer.WantsAddrV2(), outPeer.WantsAddrV2(),
+				test.wantAddrV2)
+		}
+
+		// Addresses which can only be relayed with addrv2 messages must
+		// be left out of addr messages.
+		wantCount := 1
+		if test.wantAddrV2 {
+			wantCount = 2
+		}
+		sent, err := outPeer.PushAddrMsg([]*wire.NetAddress{torV3, ipv4})
+		if err != nil {
+			t.Fatalf("%s: PushAddrMsg: %v", test.name, err)
+		}
+		if len(sent) != wantCount {
+			t.Fatalf("%s: PushAddrMsg sent %d addresses, want %d",
+				test.name, len(sent), wantCount)
+		}
+		select {
+		case received := <-addrs:
+			if len(received) != wantCount {
+				t.Fatalf("%s: received %d addresses, want %d",
+					test.name, len(received), wantCount)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s: addresses not received", test.name)
+		}
+
+		inPeer.Disconnect()
+		outPeer.Disconnect()
+	}
+}
+
+// TestTxReconciliation ensures peers which negotiated transaction
+// reconciliation only announce the transactions the other peer is missing.
+func TestTxReconciliation(t *testing.T) {
+	newPeerCfg := func(invs chan *chainhash.Hash) *peer.Config {
+		return &peer.Config{
+			Listeners: peer.MessageListeners{
+				OnInv: func(_ *peer.Peer, msg *wire.MsgInv) {
+					for _, iv := range msg.InvList {
+						invs <- &iv.Hash
+					}
+				},
+			},
+			UserAgentName:            "peer",
+			UserAgentVersion:         "1.0",
+			ChainParams:              &chaincfg.MainNetParams,
+			TxReconciliation:         true,
+			TxReconciliationInterval: 100 * time.Millisecond,
+			TstAllowSelfConnection:   true,
+		}
+	}
+	inInvs := make(chan *chainhash.Hash, 100)
+	outInvs := make(chan *chainhash.Hash, 100)
+	inConn, outConn := pipe(
+		&conn{laddr: "10.0.0.1:8333", raddr: "10.0.0.2:8333"},
+		&conn{laddr: "10.0.0.2:8333", raddr: "10.0.0.1:8333"},
+	)
+	inPeer := peer.NewInboundPeer(newPeerCfg(inInvs))
+	inPeer.AssociateConnection(inConn)
+	outPeer, err := peer.NewOutboundPeer(newPeerCfg(outInvs), inConn.laddr)
+	if err != nil {
+		t.Fatalf("NewOutboundPeer: unexpected err: %v", err)
+	}
+	outPeer.AssociateConnection(outConn)
+	defer inPeer.Disconnect()
+	defer outPeer.Disconnect()
+
+	deadline := time.Now().Add(time.Second)
+	for !inPeer.TxReconciliationEstablished() ||
+		!outPeer.TxReconciliationEstablished() {
+
+		if time.Now().After(deadline) {
+			t.Fatal("transaction reconciliation not established")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Queue a number of transactions on both peers along with a few which
+	// are only known to one of them.
+	queueTxs := func(p *peer.Peer, start, end byte) map[chainhash.Hash]struct{} {
+		hashes := make(map[chainhash.Hash]struct{})
+		for i := start; i < end; i++ {
+			hash := chainhash.Hash{0: i}
+			hashes[hash] = struct{}{}
+			p.QueueInventory(wire.NewInvVect(wire.InvTypeTx, &hash))
+		}
+		return hashes
+	}
+	queueTxs(inPeer, 0, 20)
+	queueTxs(outPeer, 0, 20)
+	wantOutInvs := queueTxs(inPeer, 20, 25)
+	wantInInvs := queueTxs(outPeer, 25, 28)
+
+	receive := func(name string, invs chan *chainhash.Hash, want map[chainhash.Hash]struct{}) {
+		t.Helper()
+		for len(want) > 0 {
+			select {
+			case hash := <-invs:
+				if _, ok := want[*hash]; !ok {
+					t.Fatalf("%s: unexpected inv %v", name, hash)
+				}
+				delete(want, *hash)
+			case <-time.After(2 * time.Second):
+				t.Fatalf("%s: missing invs %v", name, want)
+			}
+		}
+	}
+	receive("inbound", inInvs, wantInInvs)
+	receive("outbound", outInvs, wantOutInvs)
+
+	// The transactions known to both peers are never announced.
+	select {
+	case hash := <-inInvs:
+		t.Fatalf("inbound: unexpected inv %v", hash)
+	case hash := <-outInvs:
+		t.Fatalf("outbound: unexpected inv %v", hash)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+// TestExtVersionNegotiation ensures the extversion message is only exchanged
+// when both peers advertise support for it and the entries sent by both peers
+// are negotiated.
+func TestExtVersionNegotiation(t *testing.T) {
+	const key = 0x10
+	tests := []struct {
+		name         string
+		inServices   wire.ServiceFlag
+		outServices  wire.ServiceFlag
+		wantSupports bool
+	}{
+		{"both", wire.SFNodeExtVersion, wire.SFNodeExtVersion, true},
+		{"inbound only", wire.SFNodeExtVersion, 0, false},
+		{"outbound only", 0, wire.SFNodeExtVersion, false},
+	}
+
+	for _, test := range tests {
+		verack := make(chan struct{}, 2)
+		newPeerCfg := func(services wire.ServiceFlag, value []byte) *peer.Config {
+			return &peer.Config{
+				Listeners: peer.MessageListeners{
+					OnVerAck: func(_ *peer.Peer, _ *wire.MsgVerAck) {
+						verack <- struct{}{}
+					},
+				},
+				UserAgentName:          "peer",
+				UserAgentVersion:       "1.0",
+				ChainParams:            &chaincfg.MainNetParams,
+				Services:               services,
+				ExtVersionEntries:      map[uint64][]byte{key: value},
+				TstAllowSelfConnection: true,
+			}
+		}
+		inConn, outConn := pipe(
+			&conn{laddr: "10.0.0.1:8333", raddr: "10.0.0.2:8333"},
+			&conn{laddr: "10.0.0.2:8333", raddr: "10.0.0.1:8333"},
+		)
+		inPeer := peer.NewInboundPeer(newPeerCfg(test.inServices, []byte{0x01}))
+		inPeer.AssociateConnection(inConn)
+		outPeer, err := peer.NewOutboundPeer(newPeerCfg(test.outServices,
+			[]byte{0x02}), inConn.laddr)
+		if err != nil {
+			t.Fatalf("NewOutboundPeer: unexpected err: %v", err)
+		}
+		outPeer.AssociateConnection(outConn)
+		for i := 0; i < 2; i++ {
+			select {
+			case <-verack:
+			case <-time.After(time.Second):
+				t.Fatalf("%s: verack timeout", test.name)
+			}
+		}
+
+		for _, p := range []*peer.Peer{inPeer, outPeer} {
+			if p.SupportsExtVersion() != test.wantSupports {
+				t.Fatalf("%s: SupportsExtVersion for %v got %v, "+
+					"want %v", test.name, p, p.SupportsExtVersion(),
+					test.wantSupports)
+			}
+			version, ok := p.ExtVersionUint64(wire.ExtVersionKeyVersion)
+			if ok != test.wantSupports || (ok &&
+				version != wire.ExtVersionProtocolVersion) {
+
+				t.Fatalf("%s: unexpected extversion version %d (%v) "+
+					"for %v", test.name, version, ok, p)
+			}
+			if _, ok := p.ExtVersionEntry(key + 1); ok {
+				t.Fatalf("%s: unexpected entry for %v", test.name, p)
+			}
+		}
+		if test.wantSupports {
+			value, ok := inPeer.ExtVersionEntry(key)
+			if !ok || !bytes.Equal(value, []byte{0x02}) {
+				t.Fatalf("%s: unexpected inbound entry %x (%v)",
+					test.name, value, ok)
+			}
+			value, ok = outPeer.ExtVersionEntry(key)
+			if !ok || !bytes.Equal(value, []byte{0x01}) {
+				t.Fatalf("%s: unexpected outbound entry %x (%v)",
+					test.name, value, ok)
+			}
+		}
+
+		inPeer.Disconnect()
+		outPeer.Disconnect()
+	}
+}
@@ -0,0 +1,346 @@
+package peer
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/dchest/siphash"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/minisketch"
+	"github.com/gcash/bchd/wire"
+)
+
+const (
+	// DefaultTxReconciliationInterval is the default time between the
+	// transaction reconciliation rounds started with a peer.
+	DefaultTxReconciliationInterval = 8 * time.Second
+
+	// maxReconSetSize is the maximum number of transactions queued for a
+	// reconciliation round.  Transactions beyond it are announced with the
+	// inv trickle instead.
+	maxReconSetSize = 3000
+
+	// reconQ is the coefficient, scaled by 2^15-1, used to estimate the
+	// size of the set difference relative to the smaller set.
+	reconQ = 32767 / 4
+
+	// reconRoundTimeout is the number of reconciliation intervals after
+	// which a round the peer did not finish is abandoned.
+	reconRoundTimeout = 4
+)
+
+// txReconSaltTag is the tag of the hash which derives the keys of the short
+// transaction ids from the salts of both peers.
+var txReconSaltTag = []byte("Tx Relay Salting")
+
+// txReconState houses the state of the transaction reconciliation with a peer.
+// Transactions which would otherwise be announced to the peer are queued in a
+// set, and the differences between the sets of both peers are found
+// periodically by exchanging sketches of the short ids of their transactions.
+type txReconState struct {
+	mtx sync.Mutex
+
+	// localSalt is the salt sent to the peer in the sendtxrcncl message.
+	localSalt uint64
+
+	// established is set once both peers sent a sendtxrcncl message, after
+	// which k0 and k1 hold the keys of the short transaction ids.
+	established bool
+	k0, k1      uint64
+
+	// initiator is whether or not the local peer starts the rounds.  The
+	// outbound side of the connection is the initiator.
+	initiator bool
+
+	// set holds the transactions queued for the next round by short id,
+	// while snapshot holds the transactions of the round in progress.
+	set        map[uint32]chainhash.Hash
+	snapshot   map[uint32]chainhash.Hash
+	roundStart time.Time
+}
+
+// newTxReconState returns a new transaction reconciliation state with the
+// passed local salt.
+func newTxReconState(localSalt uint64, initiator bool) *txReconState {
+	return &txReconState{
+		localSalt: localSalt,
+		initiator: initiator,
+		set:       make(map[uint32]chainhash.Hash),
+	}
+}
+
+// establish derives the keys of the short transaction ids from the salts of
+// both peers and enables the reconciliation.  It returns false when the
+// reconciliation was already established.
+func (s *txReconState) establish(remoteSalt uint64) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.established {
+		return false
+	}
+
+	// The keys are the start of the hash of the salts in ascending order,
+	// tagged the same way as BIP0340 hashes.
+	salt1, salt2 := s.localSalt, remoteSalt
+	if salt1 > salt2 {
+		salt1, salt2 = salt2, salt1
+	}
+	tag := sha256.Sum256(txReconSaltTag)
+	var buf [sha256.Size*2 + 16]byte
+	copy(buf[:], tag[:])
+	copy(buf[sha256.Size:], tag[:])
+	binary.LittleEndian.PutUint64(buf[sha256.Size*2:], salt1)
+	binary.LittleEndian.PutUint64(buf[sha256.Size*2+8:], salt2)
+	h := sha256.Sum256(buf[:])
+	s.k0 = binary.LittleEndian.Uint64(h[0:8])
+	s.k1 = binary.LittleEndian.Uint64(h[8:16])
+	s.established = true
+	return true
+}
+
+// isEstablished returns whether or not the reconciliation is enabled.
+func (s *txReconState) isEstablished() bool {
+	s.mtx.Lock()
+	established := s.established
+	s.mtx.Unlock()
+	return established
+}
+
+// shortID returns the short id of the passed transaction hash.  Short ids are
+// never zero since zero can not be added to a sketch.
+//
+// This function MUST be called with the mutex held.
+func (s *txReconState) shortID(hash *chainhash.Hash) uint32 {
+	return uint32(1 + siphash.Hash(s.k0, s.k1, hash[:])%0xffffffff)
+}
+
+// addTx queues the passed transaction for the next round.  It returns false
+// when the reconciliation is not established or the set is full, in which case
+// the transaction has to be announced with the inv trickle.
+func (s *txReconState) addTx(hash *chainhash.Hash) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if !s.established || len(s.set) >= maxReconSetSize {
+		return false
+	}
+	s.set[s.shortID(hash)] = *hash
+	return true
+}
+
+// startRound moves the queued transactions into the snapshot of a new round.
+// It returns the transactions of an abandoned previous round, which have to be
+// announced with inv messages.
+func (s *txReconState) startRound() (int, []chainhash.Hash) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	abandoned := snapshotHashes(s.snapshot)
+	s.snapshot = s.set
+	s.set = make(map[uint32]chainhash.Hash)
+	s.roundStart = time.Now()
+	return len(s.snapshot), abandoned
+}
+
+// roundPending returns whether or not a round is in progress which was
+// started less than the passed duration ago.
+func (s *txReconState) roundPending(timeout time.Duration) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.snapshot != nil && time.Since(s.roundStart) < timeout
+}
+
+// sketch returns the sketch of the transactions in the snapshot of the round
+// in progress with the passed capacity.
+func (s *txReconState) sketch(capacity int) *minisketch.Sketch {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	sketch := minisketch.New(capacity)
+	for shortID := range s.snapshot {
+		sketch.Add(shortID)
+	}
+	return sketch
+}
+
+// finishRound ends the round in progress.  It returns the transactions of the
+// snapshot with the passed short ids, or all of them when all is set, and the
+// remaining short ids which are not in the snapshot.
+func (s *txReconState) finishRound(shortIDs []uint32, all bool) ([]chainhash.Hash, []uint32) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	snapshot := s.snapshot
+	s.snapshot = nil
+	if all {
+		return snapshotHashes(snapshot), nil
+	}
+
+	var hashes []chainhash.Hash
+	var missing []uint32
+	for _, shortID := range shortIDs {
+		if hash, ok := snapshot[shortID]; ok {
+			hashes = append(hashes, hash)
+			continue
+		}
+		missing = append(missing, shortID)
+	}
+	return hashes, missing
+}
+
+// snapshotHashes returns the transaction hashes of the passed snapshot.
+func snapshotHashes(snapshot map[uint32]chainhash.Hash) []chainhash.Hash {
+	if len(snapshot) == 0 {
+		return nil
+	}
+	hashes := make([]chainhash.Hash, 0, len(snapshot))
+	for _, hash := range snapshot {
+		hashes = append(hashes, hash)
+	}
+	return hashes
+}
+
+// sketchCapacity returns the capacity of a sketch which is expected to be
+// large enough to decode the difference between sets of the passed sizes.
+func sketchCapacity(localSize, remoteSize int, q uint16) int {
+	diff := localSize - remoteSize
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff + int(q)*min(localSize, remoteSize)/32767 + 1
+}
+
+// announceTxs sends inv messages for the passed transactions which are not
+// already known to the peer.
+func (p *Peer) announceTxs(hashes []chainhash.Hash) {
+	invMsg := wire.NewMsgInvSizeHint(uint(len(hashes)))
+	for i := range hashes {
+		iv := wire.NewInvVect(wire.InvTypeTx, &hashes[i])
+		if p.knownInventory.Exists(iv) {
+			continue
+		}
+		invMsg.AddInvVect(iv)
+		p.AddKnownInventory(iv)
+		if len(invMsg.InvList) >= maxInvTrickleSize {
+			p.QueueMessage(invMsg, nil)
+			invMsg = wire.NewMsgInvSizeHint(uint(len(hashes) - i))
+		}
+	}
+	if len(invMsg.InvList) > 0 {
+		p.QueueMessage(invMsg, nil)
+	}
+}
+
+// handleSendTxRcnclMsg is invoked when a peer receives a sendtxrcncl bitcoin
+// message.  The reconciliation is established when the local peer supports it
+// as well.
+func (p *Peer) handleSendTxRcnclMsg(msg *wire.MsgSendTxRcncl) {
+	if p.txRecon == nil || msg.Version < wire.TxReconciliationVersion {
+		return
+	}
+	if !p.txRecon.establish(msg.Salt) {
+		log.Debugf("Ignoring duplicate sendtxrcncl message from %s", p)
+		return
+	}
+	log.Debugf("Established transaction reconciliation with %s", p)
+}
+
+// startTxReconRound starts a reconciliation round with the peer when the local
+// peer is the initiator and no round is in progress.
+func (p *Peer) startTxReconRound() {
+	s := p.txRecon
+	if s == nil || !s.initiator || !s.isEstablished() ||
+		s.roundPending(reconRoundTimeout*p.cfg.TxReconciliationInterval) {
+		return
+	}
+
+	setSize, abandoned := s.startRound()
+	p.announceTxs(abandoned)
+	if setSize > maxReconSetSize {
+		setSize = maxReconSetSize
+	}
+	p.QueueMessage(wire.NewMsgReqRecon(uint16(setSize), reconQ), nil)
+}
+
+// handleReqReconMsg is invoked when a peer receives a reqrecon bitcoin message.
+// The responder replies with a sketch of its queued transactions, or an empty
+// sketch when the difference is expected to be too large to decode.
+func (p *Peer) handleReqReconMsg(msg *wire.MsgReqRecon) {
+	s := p.txRecon
+	if s == nil || s.initiator || !s.isEstablished() {
+		return
+	}
+
+	setSize, abandoned := s.startRound()
+	p.announceTxs(abandoned)
+	capacity := sketchCapacity(setSize, int(msg.SetSize), msg.Q)
+	if capacity > wire.MaxSketchCapacity {
+		p.QueueMessage(wire.NewMsgSketch(nil), nil)
+		return
+	}
+	p.QueueMessage(wire.NewMsgSketch(s.sketch(capacity).Serialize()), nil)
+}
+
+// handleSketchMsg is invoked when a peer receives a sketch bitcoin message.
+// The initiator combines it with the sketch of its own transactions to find
+// the difference, announces the transactions the peer is missing and asks for
+// the ones it is missing itself.  When the difference can not be decoded, all
+// transactions of the round are announced by both peers.
+func (p *Peer) handleSketchMsg(msg *wire.MsgSketch) {
+	s := p.txRecon
+	if s == nil || !s.initiator || !s.roundPending(reconRoundTimeout*
+		p.cfg.TxReconciliationInterval) {
+
+		return
+	}
+
+	var diff []uint32
+	remote, err := minisketch.Deserialize(msg.SketchData)
+	if err == nil && remote.Capacity() > 0 &&
+		remote.Capacity() <= wire.MaxSketchCapacity {
+
+		local := s.sketch(remote.Capacity())
+		if err = local.Merge(remote); err == nil {
+			diff, err = local.Decode()
+		}
+	} else if err == nil {
+		err = minisketch.ErrDecodeFailed
+	}
+	if err != nil {
+		log.Debugf("Transaction reconciliation with %s failed: %v", p,
+			err)
+		hashes, _ := s.finishRound(nil, true)
+		p.announceTxs(hashes)
+		p.QueueMessage(wire.NewMsgReconcilDiff(false, nil), nil)
+		return
+	}
+
+	hashes, asks := s.finishRound(diff, false)
+	p.announceTxs(hashes)
+	p.QueueMessage(wire.NewMsgReconcilDiff(true, asks), nil)
+}
+
+// handleReconcilDiffMsg is invoked when a peer receives a reconcildiff bitcoin
+// message.  The responder announces the transactions the peer asked for, or
+// all transactions of the round when the reconciliation failed.
+func (p *Peer) handleReconcilDiffMsg(msg *wire.MsgReconcilDiff) {
+	s := p.txRecon
+	if s == nil || s.initiator || !s.isEstablished() {
+		return
+	}
+
+	hashes, _ := s.finishRound(msg.AskShortIDs, !msg.Success)
+	p.announceTxs(hashes)
+}
+
+// TxReconciliationEstablished returns whether or not transactions are relayed
+// to the peer by set reconciliation.
+//
+// This function is safe for concurrent access.
+func (p *Peer) TxReconciliationEstablished() bool {
+	return p.txRecon != nil && p.txRecon.isEstablished()
+}
@@ -0,0 +1,121 @@
+package pst
+
+import (
+	"errors"
+)
+
+// Role identifies the role which has to act next on an input of a partially
+// signed transaction.
+type Role int
+
+// These constants define the roles in the order they act on an input.
+const (
+	RoleUpdater Role = iota
+	RoleSigner
+	RoleFinalizer
+	RoleExtractor
+)
+
+// roleStrings is a map of roles back to their constant names for pretty
+// printing.
+var roleStrings = map[Role]string{
+	RoleUpdater:   "updater",
+	RoleSigner:    "signer",
+	RoleFinalizer: "finalizer",
+	RoleExtractor: "extractor",
+}
+
+// String returns the Role as a human-readable name.
+func (r Role) String() string {
+	if s, ok := roleStrings[r]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+// InputAnalysis describes the state of an input of a partially signed
+// transaction.
+type InputAnalysis struct {
+	// Next is the role which has to act next on the input.
+	Next Role
+
+	// HasUtxo is whether the output spent by the input is known.
+	HasUtxo bool
+
+	// IsFinalized is whether the input has been finalized.
+	IsFinalized bool
+
+	// MissingSigs is the number of signatures which are still needed to
+	// finalize the input.
+	MissingSigs int
+
+	// Err describes why the input can not be finalized yet when it is
+	// not waiting for signatures, or is nil.
+	Err error
+}
+
+// Analysis describes the state of a partially signed transaction.
+type Analysis struct {
+	// Next is the role which has to act next on the partially signed
+	// transaction, which is the earliest role any of its inputs needs.
+	Next Role
+
+	// Inputs describes each input of the transaction.
+	Inputs []InputAnalysis
+
+	// Fee is the fee paid by the transaction.  It is only valid when
+	// HasFee is true, which is the case when the outputs spent by all
+	// inputs are known.
+	Fee    int64
+	HasFee bool
+}
+
+// Analyze returns the state of the passed partially signed transaction and of
+// each of its inputs.
+func Analyze(p *Packet) *Analysis {
+	a := &Analysis{
+		Next:   RoleExtractor,
+		Inputs: make([]InputAnalysis, len(p.Inputs)),
+	}
+	for i := range p.Inputs {
+		pi := &p.Inputs[i]
+		ia := &a.Inputs[i]
+		ia.HasUtxo = pi.Utxo != nil
+		ia.IsFinalized = pi.IsFinalized()
+
+		switch {
+		case ia.IsFinalized:
+			ia.Next = RoleExtractor
+
+		default:
+			_, missing, err := pi.buildSigScript()
+			switch {
+			case err == nil:
+				ia.Next = RoleFinalizer
+			case errors.Is(err, ErrNotEnoughSignatures):
+				ia.Next = RoleSigner
+				ia.MissingSigs = missing
+			case errors.Is(err, ErrMissingUtxo),
+				errors.Is(err, ErrMissingRedeemScript):
+
+				ia.Next = RoleUpdater
+				ia.Err = err
+			default:
+				// Scripts which can not be finalized here may
+				// still be signed and finalized by software
+				// which understands them.
+				ia.Next = RoleSigner
+				ia.Err = err
+			}
+		}
+		if ia.Next < a.Next {
+			a.Next = ia.Next
+		}
+	}
+
+	if fee, err := p.Fee(); err == nil {
+		a.Fee = fee
+		a.HasFee = true
+	}
+	return a
+}
@@ -0,0 +1,116 @@
+package pst
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ErrDifferentTx is returned when partially signed transactions which do not
+// sign the same transaction are combined.
+var ErrDifferentTx = errors.New("partially signed transactions sign " +
+	"different transactions")
+
+// Combine merges the passed partially signed transactions, which must all sign
+// the same unsigned transaction, into a new one which carries the information
+// of all of them.  When more than one of them carries a value for the same key,
+// the value of the first one is kept.
+func Combine(packets ...*Packet) (*Packet, error) {
+	if len(packets) == 0 {
+		return nil, errors.New("no partially signed transactions to combine")
+	}
+	for _, p := range packets {
+		if err := p.SanityCheck(); err != nil {
+			return nil, err
+		}
+	}
+
+	txHash := packets[0].UnsignedTx.TxHash()
+	combined, err := New(packets[0].UnsignedTx)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range packets {
+		if p.UnsignedTx.TxHash() != txHash {
+			return nil, ErrDifferentTx
+		}
+
+		combined.Unknowns = combineUnknowns(combined.Unknowns, p.Unknowns)
+		for i := range p.Inputs {
+			combined.Inputs[i].combine(&p.Inputs[i])
+		}
+		for i := range p.Outputs {
+			combined.Outputs[i].combine(&p.Outputs[i])
+		}
+	}
+	return combined, nil
+}
+
+// combine merges the information of the passed input into the input.
+func (pi *PInput) combine(other *PInput) {
+	if pi.Utxo == nil && other.Utxo != nil {
+		utxo := *other.Utxo
+		pi.Utxo = &utxo
+	}
+	if pi.FinalScriptSig == nil {
+		pi.FinalScriptSig = other.FinalScriptSig
+	}
+	if pi.SighashType == 0 {
+		pi.SighashType = other.SighashType
+	}
+	if pi.RedeemScript == nil {
+		pi.RedeemScript = other.RedeemScript
+	}
+
+sigs:
+	for _, sig := range other.PartialSigs {
+		for _, have := range pi.PartialSigs {
+			if bytes.Equal(have.PubKey, sig.PubKey) {
+				continue sigs
+			}
+		}
+		pi.PartialSigs = append(pi.PartialSigs, sig)
+	}
+	pi.Bip32Derivation = combineBip32Derivations(pi.Bip32Derivation,
+		other.Bip32Derivation)
+	pi.Unknowns = combineUnknowns(pi.Unknowns, other.Unknowns)
+}
+
+// combine merges the information of the passed output into the output.
+func (po *POutput) combine(other *POutput) {
+	if po.RedeemScript == nil {
+		po.RedeemScript = other.RedeemScript
+	}
+	po.Bip32Derivation = combineBip32Derivations(po.Bip32Derivation,
+		other.Bip32Derivation)
+	po.Unknowns = combineUnknowns(po.Unknowns, other.Unknowns)
+}
+
+// combineBip32Derivations returns the key derivation paths of a along with the
+// ones of b for the public keys which a does not have a path for.
+func combineBip32Derivations(a, b []*Bip32Derivation) []*Bip32Derivation {
+next:
+	for _, d := range b {
+		for _, have := range a {
+			if bytes.Equal(have.PubKey, d.PubKey) {
+				continue next
+			}
+		}
+		a = append(a, d)
+	}
+	return a
+}
+
+// combineUnknowns returns the unknown key-value pairs of a along with the ones
+// of b whose keys a does not have.
+func combineUnknowns(a, b []*Unknown) []*Unknown {
+next:
+	for _, u := range b {
+		for _, have := range a {
+			if bytes.Equal(have.Key, u.Key) {
+				continue next
+			}
+		}
+		a = append(a, u)
+	}
+	return a
+}
@@ -0,0 +1,27 @@
+/*
+Package pst implements partially signed transactions, which allow multiple
+parties to cooperatively sign a transaction without sharing their keys.
+
+The serialization follows BIP0174 as adapted for Bitcoin Cash.  Since the
+signature hashing algorithm of Bitcoin Cash commits to the amounts of the
+outputs spent by a transaction, inputs do not carry the full previous
+transaction as in BIP0174, but only the output they spend, including any
+CashTokens it carries.  There are no segwit fields.
+
+A partially signed transaction moves through the following roles:
+
+  - The creator builds a packet from an unsigned transaction with New.
+  - Updaters add the outputs spent by the inputs along with the redeem scripts
+    and key derivation paths needed to sign them.
+  - Signers add partial signatures for the keys they control.
+  - The combiner merges the packets produced by independent signers with
+    Combine.
+  - The finalizer builds the final signature script of every input which has
+    enough signatures with Finalize or MaybeFinalizeAll.
+  - The extractor produces the signed transaction with Extract, which verifies
+    the final scripts before returning it.
+
+Analyze reports how far each input has progressed through the roles, which is
+useful to decide which party has to act next.
+*/
+package pst
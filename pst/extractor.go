@@ -0,0 +1,57 @@
+package pst
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+)
+
+// ErrIncomplete is returned when a transaction is extracted from a partially
+// signed transaction which has inputs that are not finalized.
+var ErrIncomplete = errors.New("partially signed transaction is not complete")
+
+// extractVerifyFlags are the script flags the final signature scripts of an
+// extracted transaction are verified with.
+const extractVerifyFlags = txscript.StandardVerifyFlags |
+	txscript.ScriptAllowCashTokens |
+	txscript.ScriptAllowMay2025
+
+// Extract returns the signed transaction of a partially signed transaction
+// whose inputs are all finalized.  The final signature scripts are verified
+// against the outputs spent by the inputs, so those must be known.
+func Extract(p *Packet) (*wire.MsgTx, error) {
+	if err := p.SanityCheck(); err != nil {
+		return nil, err
+	}
+	if !p.IsComplete() {
+		return nil, ErrIncomplete
+	}
+
+	tx := p.UnsignedTx.Copy()
+	utxoCache := txscript.NewUtxoCache()
+	for i := range p.Inputs {
+		pi := &p.Inputs[i]
+		if pi.Utxo == nil {
+			return nil, fmt.Errorf("input %d: %w", i, ErrMissingUtxo)
+		}
+		tx.TxIn[i].SignatureScript = pi.FinalScriptSig
+		utxoCache.AddEntry(i, *pi.Utxo)
+	}
+
+	sigHashes := txscript.NewTxSigHashes(tx)
+	sigHashes.AddTxSigHashUtxoFromUtxoCache(tx, utxoCache)
+	for i := range p.Inputs {
+		utxo := p.Inputs[i].Utxo
+		vm, err := txscript.NewEngine(utxo.PkScript, tx, i,
+			extractVerifyFlags, nil, sigHashes, utxoCache, utxo.Value)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %w", i, err)
+		}
+		if err := vm.Execute(); err != nil {
+			return nil, fmt.Errorf("input %d: %w", i, err)
+		}
+	}
+	return tx, nil
+}
@@ -0,0 +1,202 @@
+package pst
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchutil"
+)
+
+// schnorrSigSize is the size of a Schnorr signature including its hash type
+// byte.  ECDSA signatures of this size are not allowed, so the size tells the
+// two apart.
+const schnorrSigSize = 65
+
+var (
+	// ErrMissingUtxo is returned when the output spent by an input is
+	// needed but not known.
+	ErrMissingUtxo = errors.New("output spent by input is not known")
+
+	// ErrMissingRedeemScript is returned when the redeem script of an
+	// input spending a pay-to-script-hash output is not known.
+	ErrMissingRedeemScript = errors.New("redeem script is not known")
+
+	// ErrUnsupportedScript is returned when an input spends a script which
+	// can not be finalized.
+	ErrUnsupportedScript = errors.New("unsupported script")
+
+	// ErrNotEnoughSignatures is returned when an input does not have
+	// enough partial signatures to be finalized.
+	ErrNotEnoughSignatures = errors.New("not enough signatures")
+)
+
+// Finalize builds the final signature script of the input with the passed
+// index from its partial signatures and removes the information which was only
+// needed to sign it.  Inputs spending pay-to-pubkey, pay-to-pubkey-hash, and
+// bare multisig scripts, as well as pay-to-script-hash outputs with any of
+// those as redeem script, can be finalized.  Finalizing an input which has
+// already been finalized is a no-op.
+func Finalize(p *Packet, i int) error {
+	if i < 0 || i >= len(p.Inputs) {
+		return fmt.Errorf("input index %d out of range", i)
+	}
+	pi := &p.Inputs[i]
+	if pi.IsFinalized() {
+		return nil
+	}
+
+	sigScript, _, err := pi.buildSigScript()
+	if err != nil {
+		return fmt.Errorf("input %d: %w", i, err)
+	}
+	pi.FinalScriptSig = sigScript
+	pi.PartialSigs = nil
+	pi.SighashType = 0
+	pi.RedeemScript = nil
+	pi.Bip32Derivation = nil
+	return nil
+}
+
+// MaybeFinalizeAll finalizes all inputs of the partially signed transaction.
+// It returns the error of the first input which can not be finalized, in which
+// case the inputs before it are finalized.
+func MaybeFinalizeAll(p *Packet) error {
+	for i := range p.Inputs {
+		if err := Finalize(p, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildSigScript returns the signature script of the input built from its
+// partial signatures.  The number of missing signatures is returned along with
+// ErrNotEnoughSignatures when there are not enough of them.
+func (pi *PInput) buildSigScript() ([]byte, int, error) {
+	if pi.Utxo == nil {
+		return nil, 0, ErrMissingUtxo
+	}
+
+	script := pi.Utxo.PkScript
+	var scriptHash []byte
+	switch {
+	case txscript.IsPayToScriptHash(script):
+		if pi.RedeemScript == nil {
+			return nil, 0, ErrMissingRedeemScript
+		}
+		scriptHash = bchutil.Hash160(pi.RedeemScript)
+	case txscript.IsPayToScriptHash32(script):
+		if pi.RedeemScript == nil {
+			return nil, 0, ErrMissingRedeemScript
+		}
+		scriptHash = bchutil.Hash256(pi.RedeemScript)
+	}
+	if scriptHash != nil {
+		pushes, err := txscript.PushedData(script)
+		if err != nil || len(pushes) != 1 ||
+			!bytes.Equal(pushes[0], scriptHash) {
+
+			return nil, 0, errors.New("redeem script does not match " +
+				"the script hash")
+		}
+		script = pi.RedeemScript
+	}
+
+	builder := txscript.NewScriptBuilder()
+	pushes, err := txscript.PushedData(script)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch txscript.GetScriptClass(script) {
+	case txscript.PubKeyHashTy:
+		sig := pi.findSig(func(pubKey []byte) bool {
+			return bytes.Equal(bchutil.Hash160(pubKey), pushes[0])
+		})
+		if sig == nil {
+			return nil, 1, ErrNotEnoughSignatures
+		}
+		builder.AddData(sig.Signature).AddData(sig.PubKey)
+
+	case txscript.PubKeyTy:
+		sig := pi.findSig(func(pubKey []byte) bool {
+			return bytes.Equal(pubKey, pushes[0])
+		})
+		if sig == nil {
+			return nil, 1, ErrNotEnoughSignatures
+		}
+		builder.AddData(sig.Signature)
+
+	case txscript.MultiSigTy:
+		numPubKeys, numSigs, err := txscript.CalcMultiSigStats(script)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// Collect the signatures in the order of the public keys they
+		// were made with, which is the order they are checked in.
+		var sigs [][]byte
+		checkBits := make([]byte, (numPubKeys+7)/8)
+		var numSchnorr int
+		for j, pubKey := range pushes {
+			if len(sigs) == numSigs {
+				break
+			}
+			sig := pi.findSig(func(k []byte) bool {
+				return bytes.Equal(k, pubKey)
+			})
+			if sig == nil {
+				continue
+			}
+			sigs = append(sigs, sig.Signature)
+			checkBits[j/8] |= 1 << (j % 8)
+			if len(sig.Signature) == schnorrSigSize {
+				numSchnorr++
+			}
+		}
+		if len(sigs) < numSigs {
+			return nil, numSigs - len(sigs), ErrNotEnoughSignatures
+		}
+
+		// Schnorr multisig signatures are selected by a bitfield of the
+		// public keys they were made with in place of the dummy
+		// element, while ECDSA ones require it to be empty.  The two
+		// can not be mixed.
+		switch numSchnorr {
+		case 0:
+			builder.AddOp(txscript.OP_0)
+		case len(sigs):
+			builder.AddData(checkBits)
+		default:
+			return nil, 0, errors.New("multisig signatures mix " +
+				"ECDSA and Schnorr")
+		}
+		for _, sig := range sigs {
+			builder.AddData(sig)
+		}
+
+	default:
+		return nil, 0, ErrUnsupportedScript
+	}
+
+	if scriptHash != nil {
+		builder.AddData(pi.RedeemScript)
+	}
+	sigScript, err := builder.Script()
+	if err != nil {
+		return nil, 0, err
+	}
+	return sigScript, 0, nil
+}
+
+// findSig returns the partial signature whose public key satisfies the passed
+// function, or nil when there is none.
+func (pi *PInput) findSig(match func(pubKey []byte) bool) *PartialSig {
+	for _, sig := range pi.PartialSigs {
+		if match(sig.PubKey) {
+			return sig
+		}
+	}
+	return nil
+}
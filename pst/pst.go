@@ -0,0 +1,593 @@
+package pst
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/gcash/bchd/bchec"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+)
+
+// magic is the magic prefix of a serialized partially signed transaction,
+// which is the ASCII string "psbt" followed by 0xff.
+var magic = [5]byte{0x70, 0x73, 0x62, 0x74, 0xff}
+
+// maxPairSize is the maximum size of a key or a value, which bounds the memory
+// allocated when reading them.  The largest values are unsigned transactions,
+// which may not exceed 1MB.
+const maxPairSize = 1000000
+
+// The key types of the global map.
+const (
+	globalUnsignedTx byte = 0x00
+)
+
+// The key types of the input maps.
+const (
+	inputUtxo            byte = 0x00
+	inputPartialSig      byte = 0x02
+	inputSighashType     byte = 0x03
+	inputRedeemScript    byte = 0x04
+	inputBip32Derivation byte = 0x06
+	inputFinalScriptSig  byte = 0x07
+)
+
+// The key types of the output maps.
+const (
+	outputRedeemScript    byte = 0x00
+	outputBip32Derivation byte = 0x02
+)
+
+var (
+	// ErrInvalidMagic is returned when a serialized partially signed
+	// transaction does not start with the expected magic bytes.
+	ErrInvalidMagic = errors.New("invalid partially signed transaction magic")
+
+	// ErrDuplicateKey is returned when a map of a serialized partially
+	// signed transaction contains the same key more than once.
+	ErrDuplicateKey = errors.New("duplicate key")
+
+	// ErrInvalidKeyData is returned when the key data of a key is not valid
+	// for its type.
+	ErrInvalidKeyData = errors.New("invalid key data")
+
+	// ErrInvalidValue is returned when the value of a key is not valid for
+	// its type.
+	ErrInvalidValue = errors.New("invalid value")
+
+	// ErrSignedTx is returned when the unsigned transaction of a partially
+	// signed transaction has signature scripts.
+	ErrSignedTx = errors.New("unsigned transaction has signature scripts")
+
+	// ErrInputCount is returned when the number of input or output maps
+	// does not match the number of inputs or outputs of the unsigned
+	// transaction.
+	ErrInputCount = errors.New("number of maps does not match the unsigned " +
+		"transaction")
+)
+
+// Unknown is a key-value pair of a type which is not understood.  Unknown
+// pairs are preserved so that they survive being passed through software which
+// does not understand them.
+type Unknown struct {
+	Key   []byte
+	Value []byte
+}
+
+// PartialSig is a signature, including its trailing hash type byte, along with
+// the public key it was made with.
+type PartialSig struct {
+	PubKey    []byte
+	Signature []byte
+}
+
+// Bip32Derivation describes the derivation path of a public key from the master
+// key with the passed fingerprint.
+type Bip32Derivation struct {
+	PubKey               []byte
+	MasterKeyFingerprint uint32
+	Path                 []uint32
+}
+
+// PInput houses the information needed to sign an input of a partially signed
+// transaction.
+type PInput struct {
+	// Utxo is the output spent by the input.
+	Utxo *wire.TxOut
+
+	// PartialSigs are the signatures added by the signers.
+	PartialSigs []*PartialSig
+
+	// SighashType is the hash type the signers should use, or zero when
+	// it is not specified.
+	SighashType txscript.SigHashType
+
+	// RedeemScript is the redeem script of a pay-to-script-hash output.
+	RedeemScript []byte
+
+	// Bip32Derivation describes the derivation paths of the keys needed
+	// to sign the input.
+	Bip32Derivation []*Bip32Derivation
+
+	// FinalScriptSig is the signature script of the input once it has
+	// been finalized.
+	FinalScriptSig []byte
+
+	Unknowns []*Unknown
+}
+
+// IsFinalized returns whether the input has been finalized.
+func (pi *PInput) IsFinalized() bool {
+	return pi.FinalScriptSig != nil
+}
+
+// POutput houses the information about an output of a partially signed
+// transaction which allows signers to identify their change.
+type POutput struct {
+	RedeemScript    []byte
+	Bip32Derivation []*Bip32Derivation
+	Unknowns        []*Unknown
+}
+
+// Packet is a partially signed transaction.
+type Packet struct {
+	// UnsignedTx is the transaction being signed, whose signature scripts
+	// are all empty.
+	UnsignedTx *wire.MsgTx
+
+	// Inputs and Outputs correspond to the inputs and outputs of the
+	// unsigned transaction.
+	Inputs  []PInput
+	Outputs []POutput
+
+	Unknowns []*Unknown
+}
+
+// New returns a new partially signed transaction for the passed unsigned
+// transaction.
+func New(tx *wire.MsgTx) (*Packet, error) {
+	for _, txIn := range tx.TxIn {
+		if len(txIn.SignatureScript) > 0 {
+			return nil, ErrSignedTx
+		}
+	}
+	return &Packet{
+		UnsignedTx: tx.Copy(),
+		Inputs:     make([]PInput, len(tx.TxIn)),
+		Outputs:    make([]POutput, len(tx.TxOut)),
+	}, nil
+}
+
+// NewFromRawBytes parses a serialized partially signed transaction from the
+// passed reader, which provides base64 when b64 is true.
+func NewFromRawBytes(r io.Reader, b64 bool) (*Packet, error) {
+	if b64 {
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	}
+
+	var m [5]byte
+	if _, err := io.ReadFull(r, m[:]); err != nil {
+		return nil, err
+	}
+	if m != magic {
+		return nil, ErrInvalidMagic
+	}
+
+	p := &Packet{}
+	err := readMap(r, func(keyType byte, keyData, value []byte) error {
+		switch keyType {
+		case globalUnsignedTx:
+			if len(keyData) != 0 {
+				return ErrInvalidKeyData
+			}
+			tx := wire.NewMsgTx(wire.TxVersion)
+			if err := tx.Deserialize(bytes.NewReader(value)); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidValue, err)
+			}
+			for _, txIn := range tx.TxIn {
+				if len(txIn.SignatureScript) > 0 {
+					return ErrSignedTx
+				}
+			}
+			p.UnsignedTx = tx
+		default:
+			p.Unknowns = append(p.Unknowns, newUnknown(keyType, keyData, value))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if p.UnsignedTx == nil {
+		return nil, errors.New("missing unsigned transaction")
+	}
+
+	p.Inputs = make([]PInput, len(p.UnsignedTx.TxIn))
+	for i := range p.Inputs {
+		if err := p.Inputs[i].deserialize(r); err != nil {
+			return nil, fmt.Errorf("input %d: %w", i, err)
+		}
+	}
+	p.Outputs = make([]POutput, len(p.UnsignedTx.TxOut))
+	for i := range p.Outputs {
+		if err := p.Outputs[i].deserialize(r); err != nil {
+			return nil, fmt.Errorf("output %d: %w", i, err)
+		}
+	}
+	return p, nil
+}
+
+// Serialize writes the partially signed transaction to the passed writer.
+func (p *Packet) Serialize(w io.Writer) error {
+	if err := p.SanityCheck(); err != nil {
+		return err
+	}
+	if _, err := w.Write(magic[:]); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := p.UnsignedTx.Serialize(&buf); err != nil {
+		return err
+	}
+	if err := writePair(w, []byte{globalUnsignedTx}, buf.Bytes()); err != nil {
+		return err
+	}
+	if err := writeUnknowns(w, p.Unknowns); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte{0x00}); err != nil {
+		return err
+	}
+
+	for i := range p.Inputs {
+		if err := p.Inputs[i].serialize(w); err != nil {
+			return err
+		}
+	}
+	for i := range p.Outputs {
+		if err := p.Outputs[i].serialize(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Bytes returns the serialized partially signed transaction.
+func (p *Packet) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := p.Serialize(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// B64Encode returns the base64 encoding of the serialized partially signed
+// transaction.
+func (p *Packet) B64Encode() (string, error) {
+	b, err := p.Bytes()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// SanityCheck returns an error when the partially signed transaction is not
+// internally consistent.
+func (p *Packet) SanityCheck() error {
+	if p.UnsignedTx == nil {
+		return errors.New("missing unsigned transaction")
+	}
+	if len(p.Inputs) != len(p.UnsignedTx.TxIn) ||
+		len(p.Outputs) != len(p.UnsignedTx.TxOut) {
+
+		return ErrInputCount
+	}
+	for _, txIn := range p.UnsignedTx.TxIn {
+		if len(txIn.SignatureScript) > 0 {
+			return ErrSignedTx
+		}
+	}
+	return nil
+}
+
+// IsComplete returns whether all inputs of the partially signed transaction
+// have been finalized.
+func (p *Packet) IsComplete() bool {
+	for i := range p.Inputs {
+		if !p.Inputs[i].IsFinalized() {
+			return false
+		}
+	}
+	return true
+}
+
+// Fee returns the fee paid by the transaction.  It returns an error when the
+// output spent by any of the inputs is not known.
+func (p *Packet) Fee() (int64, error) {
+	var fee int64
+	for i := range p.Inputs {
+		if p.Inputs[i].Utxo == nil {
+			return 0, fmt.Errorf("output spent by input %d is not "+
+				"known", i)
+		}
+		fee += p.Inputs[i].Utxo.Value
+	}
+	for _, txOut := range p.UnsignedTx.TxOut {
+		fee -= txOut.Value
+	}
+	return fee, nil
+}
+
+// deserialize reads the input map from the passed reader.
+func (pi *PInput) deserialize(r io.Reader) error {
+	return readMap(r, func(keyType byte, keyData, value []byte) error {
+		switch keyType {
+		case inputUtxo:
+			if len(keyData) != 0 {
+				return ErrInvalidKeyData
+			}
+			var txOut wire.TxOut
+			vr := bytes.NewReader(value)
+			if _, err := wire.ReadTxOut(vr, 0, wire.TxVersion, &txOut); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidValue, err)
+			}
+			if vr.Len() != 0 {
+				return ErrInvalidValue
+			}
+			pi.Utxo = &txOut
+
+		case inputPartialSig:
+			if err := checkPubKey(keyData); err != nil {
+				return err
+			}
+			if len(value) == 0 {
+				return ErrInvalidValue
+			}
+			pi.PartialSigs = append(pi.PartialSigs, &PartialSig{
+				PubKey:    keyData,
+				Signature: value,
+			})
+
+		case inputSighashType:
+			if len(keyData) != 0 {
+				return ErrInvalidKeyData
+			}
+			if len(value) != 4 {
+				return ErrInvalidValue
+			}
+			pi.SighashType = txscript.SigHashType(binary.LittleEndian.Uint32(value))
+
+		case inputRedeemScript:
+			if len(keyData) != 0 {
+				return ErrInvalidKeyData
+			}
+			pi.RedeemScript = value
+
+		case inputBip32Derivation:
+			derivation, err := readBip32Derivation(keyData, value)
+			if err != nil {
+				return err
+			}
+			pi.Bip32Derivation = append(pi.Bip32Derivation, derivation)
+
+		case inputFinalScriptSig:
+			if len(keyData) != 0 {
+				return ErrInvalidKeyData
+			}
+			pi.FinalScriptSig = value
+
+		default:
+			pi.Unknowns = append(pi.Unknowns, newUnknown(keyType, keyData, value))
+		}
+		return nil
+	})
+}
+
+// serialize writes the input map to the passed writer.
+func (pi *PInput) serialize(w io.Writer) error {
+	if pi.Utxo != nil {
+		var buf bytes.Buffer
+		if err := wire.WriteTxOut(&buf, 0, wire.TxVersion, pi.Utxo); err != nil {
+			return err
+		}
+		if err := writePair(w, []byte{inputUtxo}, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	// The final signature script makes the information needed to sign the
+	// input redundant, so it is only written until the input is
+	// finalized.
+	if !pi.IsFinalized() {
+		sigs := append([]*PartialSig(nil), pi.PartialSigs...)
+		sort.Slice(sigs, func(i, j int) bool {
+			return bytes.Compare(sigs[i].PubKey, sigs[j].PubKey) < 0
+		})
+		for _, sig := range sigs {
+			key := append([]byte{inputPartialSig}, sig.PubKey...)
+			if err := writePair(w, key, sig.Signature); err != nil {
+				return err
+			}
+		}
+		if pi.SighashType != 0 {
+			var value [4]byte
+			binary.LittleEndian.PutUint32(value[:], uint32(pi.SighashType))
+			if err := writePair(w, []byte{inputSighashType}, value[:]); err != nil {
+				return err
+			}
+		}
+		if pi.RedeemScript != nil {
+			if err := writePair(w, []byte{inputRedeemScript}, pi.RedeemScript); err != nil {
+				return err
+			}
+		}
+		if err := writeBip32Derivations(w, inputBip32Derivation, pi.Bip32Derivation); err != nil {
+			return err
+		}
+	} else {
+		if err := writePair(w, []byte{inputFinalScriptSig}, pi.FinalScriptSig); err != nil {
+			return err
+		}
+	}
+
+	if err := writeUnknowns(w, pi.Unknowns); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte{0x00})
+	return err
+}
+
+// deserialize reads the output map from the passed reader.
+func (po *POutput) deserialize(r io.Reader) error {
+	return readMap(r, func(keyType byte, keyData, value []byte) error {
+		switch keyType {
+		case outputRedeemScript:
+			if len(keyData) != 0 {
+				return ErrInvalidKeyData
+			}
+			po.RedeemScript = value
+
+		case outputBip32Derivation:
+			derivation, err := readBip32Derivation(keyData, value)
+			if err != nil {
+				return err
+			}
+			po.Bip32Derivation = append(po.Bip32Derivation, derivation)
+
+		default:
+			po.Unknowns = append(po.Unknowns, newUnknown(keyType, keyData, value))
+		}
+		return nil
+	})
+}
+
+// serialize writes the output map to the passed writer.
+func (po *POutput) serialize(w io.Writer) error {
+	if po.RedeemScript != nil {
+		if err := writePair(w, []byte{outputRedeemScript}, po.RedeemScript); err != nil {
+			return err
+		}
+	}
+	if err := writeBip32Derivations(w, outputBip32Derivation, po.Bip32Derivation); err != nil {
+		return err
+	}
+	if err := writeUnknowns(w, po.Unknowns); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte{0x00})
+	return err
+}
+
+// readMap reads the key-value pairs of a map up to its terminating separator
+// from the passed reader and invokes the passed function for each of them.
+func readMap(r io.Reader, f func(keyType byte, keyData, value []byte) error) error {
+	seen := make(map[string]struct{})
+	for {
+		key, err := wire.ReadVarBytes(r, 0, maxPairSize, "key")
+		if err != nil {
+			return err
+		}
+		if len(key) == 0 {
+			return nil
+		}
+		value, err := wire.ReadVarBytes(r, 0, maxPairSize, "value")
+		if err != nil {
+			return err
+		}
+
+		if _, ok := seen[string(key)]; ok {
+			return fmt.Errorf("%w: %x", ErrDuplicateKey, key)
+		}
+		seen[string(key)] = struct{}{}
+
+		if err := f(key[0], key[1:], value); err != nil {
+			return err
+		}
+	}
+}
+
+// writePair writes a key-value pair to the passed writer.
+func writePair(w io.Writer, key, value []byte) error {
+	if err := wire.WriteVarBytes(w, 0, key); err != nil {
+		return err
+	}
+	return wire.WriteVarBytes(w, 0, value)
+}
+
+// newUnknown returns an unknown key-value pair.
+func newUnknown(keyType byte, keyData, value []byte) *Unknown {
+	return &Unknown{
+		Key:   append([]byte{keyType}, keyData...),
+		Value: value,
+	}
+}
+
+// writeUnknowns writes the passed unknown key-value pairs to the passed
+// writer.
+func writeUnknowns(w io.Writer, unknowns []*Unknown) error {
+	for _, u := range unknowns {
+		if err := writePair(w, u.Key, u.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkPubKey returns an error when the passed key data is not a valid public
+// key.
+func checkPubKey(pubKey []byte) error {
+	if len(pubKey) != 33 && len(pubKey) != 65 {
+		return ErrInvalidKeyData
+	}
+	if _, err := bchec.ParsePubKey(pubKey, bchec.S256()); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidKeyData, err)
+	}
+	return nil
+}
+
+// readBip32Derivation parses the key data and value of a key derivation path.
+func readBip32Derivation(keyData, value []byte) (*Bip32Derivation, error) {
+	if err := checkPubKey(keyData); err != nil {
+		return nil, err
+	}
+	if len(value) < 4 || len(value)%4 != 0 {
+		return nil, ErrInvalidValue
+	}
+	derivation := &Bip32Derivation{
+		PubKey:               keyData,
+		MasterKeyFingerprint: binary.LittleEndian.Uint32(value),
+	}
+	for i := 4; i < len(value); i += 4 {
+		derivation.Path = append(derivation.Path,
+			binary.LittleEndian.Uint32(value[i:]))
+	}
+	return derivation, nil
+}
+
+// writeBip32Derivations writes the passed key derivation paths sorted by public
+// key to the passed writer.
+func writeBip32Derivations(w io.Writer, keyType byte, derivations []*Bip32Derivation) error {
+	derivations = append([]*Bip32Derivation(nil), derivations...)
+	sort.Slice(derivations, func(i, j int) bool {
+		return bytes.Compare(derivations[i].PubKey, derivations[j].PubKey) < 0
+	})
+	for _, d := range derivations {
+		value := make([]byte, 4+4*len(d.Path))
+		binary.LittleEndian.PutUint32(value, d.MasterKeyFingerprint)
+		for i, index := range d.Path {
+			binary.LittleEndian.PutUint32(value[4+4*i:], index)
+		}
+		key := append([]byte{keyType}, d.PubKey...)
+		if err := writePair(w, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package pst
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/gcash/bchd/bchec"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// newKey returns a deterministic private key derived from the passed byte.
+func newKey(b byte) *bchec.PrivateKey {
+	key, _ := bchec.PrivKeyFromBytes(bchec.S256(), bytes.Repeat([]byte{b}, 32))
+	return key
+}
+
+// testTx returns an unsigned transaction spending the passed number of inputs.
+func testTx(numInputs int) *wire.MsgTx {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	for i := 0; i < numInputs; i++ {
+		prevOut := wire.NewOutPoint(&chainhash.Hash{byte(i + 1)}, uint32(i))
+		tx.AddTxIn(wire.NewTxIn(prevOut, nil))
+	}
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}, wire.TokenData{}))
+	return tx
+}
+
+// sign returns a signature of the input with the passed index made with the
+// passed key.
+func sign(t *testing.T, p *Packet, idx int, subScript []byte, key *bchec.PrivateKey, schnorr bool) []byte {
+	t.Helper()
+
+	utxoCache := txscript.NewUtxoCache()
+	for i := range p.Inputs {
+		utxoCache.AddEntry(i, *p.Inputs[i].Utxo)
+	}
+	sigHashes := txscript.NewTxSigHashes(p.UnsignedTx)
+	sigHashes.AddTxSigHashUtxoFromUtxoCache(p.UnsignedTx, utxoCache)
+
+	hashType := txscript.SigHashAll | txscript.SigHashForkID
+	hash, _, err := txscript.CalcSignatureHash(subScript, sigHashes,
+		hashType, p.UnsignedTx, idx, p.Inputs[idx].Utxo.Value, true)
+	if err != nil {
+		t.Fatalf("CalcSignatureHash: %v", err)
+	}
+	var sig *bchec.Signature
+	if schnorr {
+		sig, err = key.SignSchnorr(hash)
+	} else {
+		sig, err = key.SignECDSA(hash)
+	}
+	if err != nil {
+		t.Fatalf("unable to sign: %v", err)
+	}
+	return append(sig.Serialize(), byte(hashType))
+}
+
+// TestSerialize ensures partially signed transactions survive a round trip
+// through their serialization and that malformed ones are rejected.
+func TestSerialize(t *testing.T) {
+	pubKey := newKey(1).PubKey().SerializeCompressed()
+	p, err := New(testTx(2))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	p.Unknowns = []*Unknown{{Key: []byte{0xfc, 0x01}, Value: []byte{0x02}}}
+	p.Inputs[0] = PInput{
+		Utxo: wire.NewTxOut(5000, []byte{txscript.OP_TRUE}, wire.TokenData{
+			CategoryID: [32]byte{0xaa},
+			Amount:     100,
+			BitField:   wire.HAS_AMOUNT,
+		}),
+		PartialSigs:  []*PartialSig{{PubKey: pubKey, Signature: []byte{0x30, 0x41}}},
+		SighashType:  txscript.SigHashAll | txscript.SigHashForkID,
+		RedeemScript: []byte{txscript.OP_TRUE},
+		Bip32Derivation: []*Bip32Derivation{{
+			PubKey:               pubKey,
+			MasterKeyFingerprint: 0x01020304,
+			Path:                 []uint32{44 | 0x80000000, 145 | 0x80000000, 0},
+		}},
+		Unknowns: []*Unknown{{Key: []byte{0xfd}, Value: []byte{0x03}}},
+	}
+	p.Inputs[1].FinalScriptSig = []byte{txscript.OP_1}
+	p.Outputs[0].Bip32Derivation = p.Inputs[0].Bip32Derivation
+
+	b64, err := p.B64Encode()
+	if err != nil {
+		t.Fatalf("B64Encode: %v", err)
+	}
+	decoded, err := NewFromRawBytes(bytes.NewReader([]byte(b64)), true)
+	if err != nil {
+		t.Fatalf("NewFromRawBytes: %v", err)
+	}
+	b1, _ := p.Bytes()
+	b2, err := decoded.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+	if !bytes.Equal(b1, b2) {
+		t.Fatalf("round trip mismatch:\n%x\n%x", b1, b2)
+	}
+	if decoded.Inputs[0].Utxo.TokenData.Amount != 100 {
+		t.Fatalf("token amount of spent output lost in round trip")
+	}
+	if decoded.Inputs[0].SighashType != p.Inputs[0].SighashType {
+		t.Fatalf("sighash type lost in round trip")
+	}
+
+	// A malformed magic and duplicate keys must be rejected.
+	bad := append([]byte{0x70, 0x73, 0x62, 0x74, 0x00}, b1[5:]...)
+	if _, err := NewFromRawBytes(bytes.NewReader(bad), false); !errors.Is(err, ErrInvalidMagic) {
+		t.Fatalf("unexpected error for invalid magic: %v", err)
+	}
+	var buf bytes.Buffer
+	buf.Write(magic[:])
+	var txBuf bytes.Buffer
+	p.UnsignedTx.Serialize(&txBuf)
+	writePair(&buf, []byte{globalUnsignedTx}, txBuf.Bytes())
+	writePair(&buf, []byte{globalUnsignedTx}, txBuf.Bytes())
+	buf.WriteByte(0x00)
+	if _, err := NewFromRawBytes(&buf, false); !errors.Is(err, ErrDuplicateKey) {
+		t.Fatalf("unexpected error for duplicate key: %v", err)
+	}
+
+	// Signed transactions can not be partially signed.
+	signed := testTx(1)
+	signed.TxIn[0].SignatureScript = []byte{txscript.OP_TRUE}
+	if _, err := New(signed); !errors.Is(err, ErrSignedTx) {
+		t.Fatalf("unexpected error for signed transaction: %v", err)
+	}
+}
+
+// TestRoles ensures a transaction can be signed by independent signers whose
+// partially signed transactions are combined, finalized, and extracted.
+func TestRoles(t *testing.T) {
+	params := &chaincfg.MainNetParams
+
+	// The first input spends a pay-to-pubkey-hash output carrying tokens,
+	// the second one a 2-of-3 multisig pay-to-script-hash output, and the
+	// third one a bare 1-of-2 multisig output.
+	pkhKey := newKey(1)
+	pkhAddr, _ := bchutil.NewAddressPubKeyHash(
+		bchutil.Hash160(pkhKey.PubKey().SerializeCompressed()), params)
+	pkhScript, _ := txscript.PayToAddrScript(pkhAddr)
+
+	msKeys := []*bchec.PrivateKey{newKey(2), newKey(3), newKey(4)}
+	var msPubKeys []*bchutil.AddressPubKey
+	for _, key := range msKeys {
+		addr, _ := bchutil.NewAddressPubKey(key.PubKey().SerializeCompressed(), params)
+		msPubKeys = append(msPubKeys, addr)
+	}
+	redeemScript, _ := txscript.MultiSigScript(msPubKeys, 2)
+	p2shAddr, _ := bchutil.NewAddressScriptHash(redeemScript, params)
+	p2shScript, _ := txscript.PayToAddrScript(p2shAddr)
+	bareScript, _ := txscript.MultiSigScript(msPubKeys[:2], 1)
+
+	p, err := New(testTx(3))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	p.UnsignedTx.TxOut[0].TokenData = wire.TokenData{
+		CategoryID: [32]byte{0xaa},
+		Amount:     100,
+		BitField:   wire.HAS_AMOUNT,
+	}
+	if a := Analyze(p); a.Next != RoleUpdater || a.HasFee {
+		t.Fatalf("unexpected analysis without spent outputs: %+v", a)
+	}
+
+	// Update.
+	p.Inputs[0].Utxo = wire.NewTxOut(10000, pkhScript, p.UnsignedTx.TxOut[0].TokenData)
+	p.Inputs[1].Utxo = wire.NewTxOut(20000, p2shScript, wire.TokenData{})
+	p.Inputs[2].Utxo = wire.NewTxOut(30000, bareScript, wire.TokenData{})
+	if a := Analyze(p); a.Next != RoleUpdater ||
+		!errors.Is(a.Inputs[1].Err, ErrMissingRedeemScript) {
+
+		t.Fatalf("unexpected analysis without redeem script: %+v", a)
+	}
+	p.Inputs[1].RedeemScript = redeemScript
+	a := Analyze(p)
+	if a.Next != RoleSigner || !a.HasFee || a.Fee != 59000 {
+		t.Fatalf("unexpected analysis before signing: %+v", a)
+	}
+	for i, missing := range []int{1, 2, 1} {
+		if a.Inputs[i].MissingSigs != missing {
+			t.Fatalf("input %d: unexpected missing signatures -- "+
+				"got %d, want %d", i, a.Inputs[i].MissingSigs, missing)
+		}
+	}
+
+	// Sign with independent signers.
+	b, err := p.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+	signers := make([]*Packet, 3)
+	for i := range signers {
+		signers[i], err = NewFromRawBytes(bytes.NewReader(b), false)
+		if err != nil {
+			t.Fatalf("NewFromRawBytes: %v", err)
+		}
+	}
+	addSig := func(s *Packet, idx int, subScript []byte, key *bchec.PrivateKey, schnorr bool) {
+		s.Inputs[idx].PartialSigs = append(s.Inputs[idx].PartialSigs, &PartialSig{
+			PubKey:    key.PubKey().SerializeCompressed(),
+			Signature: sign(t, s, idx, subScript, key, schnorr),
+		})
+	}
+	addSig(signers[0], 0, pkhScript, pkhKey, false)
+	addSig(signers[0], 1, redeemScript, msKeys[2], true)
+	addSig(signers[1], 1, redeemScript, msKeys[0], true)
+	addSig(signers[2], 2, bareScript, msKeys[1], false)
+
+	if err := Finalize(signers[0], 1); !errors.Is(err, ErrNotEnoughSignatures) {
+		t.Fatalf("unexpected error finalizing without enough "+
+			"signatures: %v", err)
+	}
+	if _, err := Extract(signers[0]); !errors.Is(err, ErrIncomplete) {
+		t.Fatalf("unexpected error extracting incomplete "+
+			"transaction: %v", err)
+	}
+
+	// Combine.
+	if _, err := Combine(signers[0], testPacket(t)); !errors.Is(err, ErrDifferentTx) {
+		t.Fatalf("unexpected error combining different "+
+			"transactions: %v", err)
+	}
+	combined, err := Combine(signers...)
+	if err != nil {
+		t.Fatalf("Combine: %v", err)
+	}
+	if a := Analyze(combined); a.Next != RoleFinalizer {
+		t.Fatalf("unexpected analysis after combining: %+v", a)
+	}
+
+	// Finalize and extract.
+	if err := MaybeFinalizeAll(combined); err != nil {
+		t.Fatalf("MaybeFinalizeAll: %v", err)
+	}
+	if a := Analyze(combined); a.Next != RoleExtractor {
+		t.Fatalf("unexpected analysis after finalizing: %+v", a)
+	}
+	if len(combined.Inputs[1].PartialSigs) != 0 || combined.Inputs[1].RedeemScript != nil {
+		t.Fatalf("finalized input retains signing information")
+	}
+	tx, err := Extract(combined)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	for i, txIn := range tx.TxIn {
+		if !bytes.Equal(txIn.SignatureScript, combined.Inputs[i].FinalScriptSig) {
+			t.Fatalf("input %d: signature script not extracted", i)
+		}
+	}
+
+	// Tampering with a finalized input must be detected by the
+	// extractor.
+	combined.Inputs[0].Utxo.Value++
+	if _, err := Extract(combined); err == nil {
+		t.Fatalf("Extract: expected error for invalid signature")
+	}
+}
+
+// testPacket returns a partially signed transaction for an unrelated
+// transaction.
+func testPacket(t *testing.T) *Packet {
+	p, err := New(testTx(4))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return p
+}
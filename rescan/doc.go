@@ -0,0 +1,24 @@
+/*
+Package rescan implements rescans of the main chain for the transactions of a
+wallet, which are used to restore wallets from their keys.
+
+A rescan looks for the transactions which pay to any of a set of output scripts
+or spend any of a set of outpoints.  Rather than reading every block, it first
+matches the scripts and outpoints against the compact filters of the blocks,
+which are read in batches from the filter index.  Only the blocks whose filters
+match are fetched and searched for the relevant transactions, so a rescan
+reads a small fraction of the chain and is orders of magnitude faster than a
+linear scan.  Blocks which don't have a filter yet, because the filter index is
+still catching up, are always fetched.
+
+The outputs paying to the scripts which are found are added to the outpoints,
+so the transactions spending them are found as well.  Filters match with a
+false positive rate of 1/2^19 per element, and the blocks matched by mistake
+are discarded once they are searched.
+
+The blocks with relevant transactions and the progress of the rescan are
+reported to the caller as the rescan proceeds.  A rescan fails when the main
+chain is reorganized past the blocks already reported, in which case it must be
+started again from before the fork.
+*/
+package rescan
@@ -0,0 +1,380 @@
+package rescan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+	"github.com/gcash/bchutil/gcs"
+	"github.com/gcash/bchutil/gcs/builder"
+)
+
+const (
+	// DefaultMaxRescans is the default number of rescans which may run at
+	// once.
+	DefaultMaxRescans = 4
+
+	// filterBatchSize is the number of filters read from the filter index
+	// at a time.  The progress of a rescan is reported after each batch.
+	filterBatchSize = 2000
+)
+
+var (
+	// ErrTooManyRescans is returned when the maximum number of rescans are
+	// already running.
+	ErrTooManyRescans = errors.New("too many rescans in progress")
+
+	// ErrNothingToRescan is returned when a rescan is requested without
+	// any scripts or outpoints.
+	ErrNothingToRescan = errors.New("no scripts or outpoints to rescan for")
+
+	// ErrReorganized is returned when the main chain is reorganized past
+	// the blocks already scanned.
+	ErrReorganized = errors.New("the main chain was reorganized during " +
+		"the rescan")
+)
+
+// Config is a descriptor containing the rescanner configuration.
+type Config struct {
+	// HeightRange returns the hashes of the blocks of the main chain from
+	// the start height up to but excluding the end height.  The end height
+	// is limited to the height of the best block.
+	HeightRange func(startHeight, endHeight int32) ([]chainhash.Hash, error)
+
+	// BestHeight returns the height of the best block of the main chain.
+	BestHeight func() int32
+
+	// MainChainHasBlock returns whether or not the block with the passed
+	// hash is in the main chain.
+	MainChainHasBlock func(hash *chainhash.Hash) bool
+
+	// FetchFilters returns the serialized regular compact filters of the
+	// blocks with the passed hashes.  The filters of the blocks which
+	// don't have one are empty.
+	FetchFilters func(hashes []*chainhash.Hash) ([][]byte, error)
+
+	// FetchBlock returns the block with the passed hash.
+	FetchBlock func(hash *chainhash.Hash) (*bchutil.Block, error)
+
+	// MaxRescans is the number of rescans which may run at once.  It
+	// defaults to DefaultMaxRescans.
+	MaxRescans int
+}
+
+// Request describes what to rescan for and where.
+type Request struct {
+	// Scripts are the output scripts to find the transactions paying to.
+	Scripts [][]byte
+
+	// OutPoints are the outpoints to find the transactions spending.
+	OutPoints []wire.OutPoint
+
+	// StartHeight is the height of the first block to scan.
+	StartHeight int32
+
+	// StopHeight is the height of the last block to scan.  The rescan
+	// stops at the best block when the rescan started when it isn't
+	// positive or past the best block.
+	StopHeight int32
+
+	// OnBlock is called with each block which has relevant transactions in
+	// the order of the blocks.  The rescan stops with the error returned
+	// when it isn't nil.
+	OnBlock func(*Match) error
+
+	// OnProgress is called with the progress of the rescan after each
+	// batch of blocks is scanned.  The rescan stops with the error
+	// returned when it isn't nil.
+	OnProgress func(*Progress) error
+}
+
+// Match describes a block with relevant transactions.
+type Match struct {
+	// Block is the block, with its height set.
+	Block *bchutil.Block
+
+	// Transactions are the transactions of the block which pay to any of
+	// the scripts or spend any of the outpoints, in the order of the block.
+	Transactions []*bchutil.Tx
+}
+
+// Progress describes how far a rescan got.
+type Progress struct {
+	// Hash and Height identify the last block scanned.  The height is one
+	// less than the start height before any block was scanned.
+	Hash   chainhash.Hash
+	Height int32
+
+	// StopHeight is the height of the last block the rescan scans.
+	StopHeight int32
+
+	// ScannedBlocks is the number of blocks which were scanned, either by
+	// their filter or by fetching them.
+	ScannedBlocks int32
+
+	// FetchedBlocks is the number of blocks which were fetched because
+	// their filter matched or they had no filter.
+	FetchedBlocks int32
+
+	// MatchedBlocks and MatchedTransactions are the number of blocks with
+	// relevant transactions and the number of relevant transactions.
+	MatchedBlocks       int32
+	MatchedTransactions int32
+}
+
+// Rescanner runs rescans of the main chain.
+type Rescanner struct {
+	cfg   Config
+	slots chan struct{}
+}
+
+// New returns a new rescanner based on the passed configuration.
+func New(cfg *Config) *Rescanner {
+	maxRescans := cfg.MaxRescans
+	if maxRescans <= 0 {
+		maxRescans = DefaultMaxRescans
+	}
+	return &Rescanner{
+		cfg:   *cfg,
+		slots: make(chan struct{}, maxRescans),
+	}
+}
+
+// watchList holds the scripts and outpoints a rescan looks for along with the
+// filter entries they match.
+type watchList struct {
+	scripts   map[string]struct{}
+	outPoints map[wire.OutPoint]struct{}
+	entries   [][]byte
+}
+
+// newWatchList returns a watch list of the passed scripts and outpoints.
+func newWatchList(scripts [][]byte, outPoints []wire.OutPoint) *watchList {
+	w := &watchList{
+		scripts:   make(map[string]struct{}, len(scripts)),
+		outPoints: make(map[wire.OutPoint]struct{}, len(outPoints)),
+	}
+	for _, script := range scripts {
+		if _, ok := w.scripts[string(script)]; ok || len(script) == 0 {
+			continue
+		}
+		w.scripts[string(script)] = struct{}{}
+		w.entries = append(w.entries, script)
+	}
+	for _, op := range outPoints {
+		w.addOutPoint(op)
+	}
+	return w
+}
+
+// addOutPoint adds the passed outpoint to the watch list.  Outpoints are
+// entered in filters in their serialized form.
+func (w *watchList) addOutPoint(op wire.OutPoint) {
+	if _, ok := w.outPoints[op]; ok {
+		return
+	}
+	w.outPoints[op] = struct{}{}
+
+	var buf bytes.Buffer
+	if err := op.Serialize(&buf); err != nil {
+		return
+	}
+	w.entries = append(w.entries, buf.Bytes())
+}
+
+// matchFilter returns whether the passed serialized filter of the block with
+// the passed hash may match any entry of the watch list.  Filters which can't
+// be decoded are treated as matching so the block is searched.
+func (w *watchList) matchFilter(hash *chainhash.Hash, serialized []byte) bool {
+	if len(serialized) == 0 {
+		return true
+	}
+	filter, err := gcs.FromNBytes(builder.DefaultP, builder.DefaultM,
+		serialized)
+	if err != nil {
+		log.Warnf("Unable to decode the filter of block %v: %v", hash,
+			err)
+		return true
+	}
+	matched, err := filter.MatchAny(builder.DeriveKey(hash), w.entries)
+	if err != nil {
+		log.Warnf("Unable to match the filter of block %v: %v", hash,
+			err)
+		return true
+	}
+	return matched
+}
+
+// searchBlock returns the transactions of the passed block which pay to any of
+// the scripts or spend any of the outpoints of the watch list.  The outputs
+// paying to the scripts are added to the watch list so the transactions
+// spending them are found as well, including in the same block.
+func (w *watchList) searchBlock(block *bchutil.Block) []*bchutil.Tx {
+	var relevant []*bchutil.Tx
+	for i, tx := range block.Transactions() {
+		var isRelevant bool
+		if i != 0 {
+			for _, txIn := range tx.MsgTx().TxIn {
+				if _, ok := w.outPoints[txIn.PreviousOutPoint]; ok {
+					isRelevant = true
+					break
+				}
+			}
+		}
+		for index, txOut := range tx.MsgTx().TxOut {
+			if _, ok := w.scripts[string(txOut.PkScript)]; !ok {
+				continue
+			}
+			isRelevant = true
+			w.addOutPoint(wire.OutPoint{
+				Hash:  *tx.Hash(),
+				Index: uint32(index),
+			})
+		}
+		if isRelevant {
+			relevant = append(relevant, tx)
+		}
+	}
+	return relevant
+}
+
+// Rescan scans the blocks of the main chain requested by the passed request
+// for the transactions which pay to any of its scripts or spend any of its
+// outpoints.  The blocks with relevant transactions and the progress are passed
+// to the callbacks of the request as the rescan proceeds, and the final
+// progress is returned once the stop height is reached.
+//
+// The rescan stops early with the error of the context when it is done,
+// ErrReorganized when the main chain is reorganized past the blocks already
+// scanned and ErrTooManyRescans when the maximum number of rescans are already
+// running.
+//
+// This function is safe for concurrent access.
+func (r *Rescanner) Rescan(ctx context.Context, req *Request) (*Progress, error) {
+	if len(req.Scripts) == 0 && len(req.OutPoints) == 0 {
+		return nil, ErrNothingToRescan
+	}
+	if req.StartHeight < 0 {
+		return nil, errors.New("the start height must not be negative")
+	}
+
+	select {
+	case r.slots <- struct{}{}:
+		defer func() { <-r.slots }()
+	default:
+		return nil, ErrTooManyRescans
+	}
+
+	stopHeight := req.StopHeight
+	if bestHeight := r.cfg.BestHeight(); stopHeight <= 0 ||
+		stopHeight > bestHeight {
+
+		stopHeight = bestHeight
+	}
+	progress := &Progress{
+		Height:     req.StartHeight - 1,
+		StopHeight: stopHeight,
+	}
+	watched := newWatchList(req.Scripts, req.OutPoints)
+	log.Debugf("Rescanning blocks %d to %d for %d scripts and %d "+
+		"outpoints", req.StartHeight, stopHeight, len(watched.scripts),
+		len(watched.outPoints))
+
+	start := time.Now()
+	var prevHash *chainhash.Hash
+	for height := req.StartHeight; height <= stopHeight; {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		endHeight := height + filterBatchSize
+		if endHeight > stopHeight+1 {
+			endHeight = stopHeight + 1
+		}
+		hashes, err := r.cfg.HeightRange(height, endHeight)
+		if err != nil {
+			return nil, err
+		}
+
+		// The main chain no longer reaches the stop height or the
+		// blocks scanned so far when it was reorganized.
+		if len(hashes) != int(endHeight-height) ||
+			prevHash != nil && !r.cfg.MainChainHasBlock(prevHash) {
+
+			return nil, ErrReorganized
+		}
+
+		hashPtrs := make([]*chainhash.Hash, 0, len(hashes))
+		for i := range hashes {
+			hashPtrs = append(hashPtrs, &hashes[i])
+		}
+		filters, err := r.cfg.FetchFilters(hashPtrs)
+		if err != nil {
+			return nil, err
+		}
+
+		// Search the blocks whose filters match in order, since the
+		// outputs found in a block may be spent by the following ones.
+		var matches []*Match
+		for i, hash := range hashPtrs {
+			if !watched.matchFilter(hash, filters[i]) {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			block, err := r.cfg.FetchBlock(hash)
+			if err != nil {
+				return nil, err
+			}
+			block.SetHeight(height + int32(i))
+			progress.FetchedBlocks++
+
+			relevant := watched.searchBlock(block)
+			if len(relevant) == 0 {
+				continue
+			}
+			matches = append(matches, &Match{
+				Block:        block,
+				Transactions: relevant,
+			})
+			progress.MatchedBlocks++
+			progress.MatchedTransactions += int32(len(relevant))
+		}
+
+		// Make sure the blocks of the batch are still part of the main
+		// chain before reporting them.
+		prevHash = hashPtrs[len(hashPtrs)-1]
+		if !r.cfg.MainChainHasBlock(prevHash) {
+			return nil, ErrReorganized
+		}
+		if req.OnBlock != nil {
+			for _, match := range matches {
+				if err := req.OnBlock(match); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		progress.Hash = *prevHash
+		progress.Height = endHeight - 1
+		progress.ScannedBlocks += int32(len(hashPtrs))
+		if req.OnProgress != nil {
+			p := *progress
+			if err := req.OnProgress(&p); err != nil {
+				return nil, err
+			}
+		}
+		height = endHeight
+	}
+
+	log.Debugf("Rescanned %d blocks in %v (%d fetched, %d with relevant "+
+		"transactions)", progress.ScannedBlocks,
+		time.Since(start).Truncate(time.Millisecond),
+		progress.FetchedBlocks, progress.MatchedBlocks)
+	return progress, nil
+}
@@ -0,0 +1,318 @@
+package rescan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+	"github.com/gcash/bchutil/gcs/builder"
+)
+
+// testChain is a chain of blocks along with their filters which serves the
+// rescanner configuration.
+type testChain struct {
+	blocks  []*bchutil.Block
+	filters map[chainhash.Hash][]byte
+	fetched map[chainhash.Hash]int
+	orphans map[chainhash.Hash]bool
+}
+
+// newTestChain returns a chain of blocks which each contain a coinbase paying
+// to a script of its own followed by the passed transactions.  Filters are
+// only built for the blocks up to the passed height.
+func newTestChain(t *testing.T, blockTxns [][]*wire.MsgTx, filteredHeight int) *testChain {
+	c := &testChain{
+		filters: make(map[chainhash.Hash][]byte),
+		fetched: make(map[chainhash.Hash]int),
+		orphans: make(map[chainhash.Hash]bool),
+	}
+	var prevHash chainhash.Hash
+	for height, txns := range blockTxns {
+		coinbase := wire.NewMsgTx(1)
+		coinbase.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{Index: wire.MaxPrevOutIndex},
+			SignatureScript:  []byte{byte(height), 0x51},
+		})
+		coinbase.AddTxOut(wire.NewTxOut(50, []byte{0x51, byte(height)}, wire.TokenData{}))
+		msgBlock := wire.NewMsgBlock(&wire.BlockHeader{
+			PrevBlock: prevHash,
+			Nonce:     uint32(height),
+		})
+		msgBlock.AddTransaction(coinbase)
+		for _, tx := range txns {
+			msgBlock.AddTransaction(tx)
+		}
+		block := bchutil.NewBlock(msgBlock)
+		c.blocks = append(c.blocks, block)
+		prevHash = *block.Hash()
+
+		if height > filteredHeight {
+			continue
+		}
+		filter, err := builder.BuildBasicFilter(msgBlock)
+		if err != nil {
+			t.Fatalf("unable to build filter: %v", err)
+		}
+		serialized, err := filter.NBytes()
+		if err != nil {
+			t.Fatalf("unable to serialize filter: %v", err)
+		}
+		c.filters[*block.Hash()] = serialized
+	}
+	return c
+}
+
+// config returns a rescanner configuration backed by the chain.
+func (c *testChain) config() *Config {
+	return &Config{
+		HeightRange: func(startHeight, endHeight int32) ([]chainhash.Hash, error) {
+			if endHeight > int32(len(c.blocks)) {
+				endHeight = int32(len(c.blocks))
+			}
+			var hashes []chainhash.Hash
+			for height := startHeight; height < endHeight; height++ {
+				hashes = append(hashes, *c.blocks[height].Hash())
+			}
+			return hashes, nil
+		},
+		BestHeight: func() int32 {
+			return int32(len(c.blocks) - 1)
+		},
+		MainChainHasBlock: func(hash *chainhash.Hash) bool {
+			return !c.orphans[*hash]
+		},
+		FetchFilters: func(hashes []*chainhash.Hash) ([][]byte, error) {
+			filters := make([][]byte, 0, len(hashes))
+			for _, hash := range hashes {
+				filters = append(filters, c.filters[*hash])
+			}
+			return filters, nil
+		},
+		FetchBlock: func(hash *chainhash.Hash) (*bchutil.Block, error) {
+			for _, block := range c.blocks {
+				if block.Hash().IsEqual(hash) {
+					c.fetched[*hash]++
+					// Return a copy so the heights which are set
+					// don't leak between rescans.
+					return bchutil.NewBlock(block.MsgBlock()), nil
+				}
+			}
+			return nil, fmt.Errorf("block %v not found", hash)
+		},
+	}
+}
+
+// spend returns a transaction which spends the passed outpoint and pays to the
+// passed script.
+func spend(op wire.OutPoint, script []byte) *wire.MsgTx {
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(&op, []byte{0x51}))
+	tx.AddTxOut(wire.NewTxOut(10, script, wire.TokenData{}))
+	return tx
+}
+
+// TestRescan ensures a rescan finds the transactions paying to the scripts and
+// the transactions spending the outputs found, fetches only the blocks whose
+// filters match or which have no filter, and reports its progress.
+func TestRescan(t *testing.T) {
+	wallet := []byte{0x76, 0xa9, 0x01}
+	other := []byte{0x76, 0xa9, 0x02}
+	watchedOp := wire.OutPoint{Hash: chainhash.Hash{0x01}, Index: 3}
+
+	// Block 2 pays to the wallet, block 4 spends that output to another
+	// script within a chain of transactions, block 6 spends the watched
+	// outpoint and block 8, which has no filter, pays to the wallet again.
+	receive := spend(wire.OutPoint{Hash: chainhash.Hash{0x02}}, wallet)
+	send := spend(wire.OutPoint{Hash: receive.TxHash()}, other)
+	unrelated := spend(wire.OutPoint{Hash: send.TxHash()}, other)
+	blockTxns := [][]*wire.MsgTx{
+		nil,
+		{spend(wire.OutPoint{Hash: chainhash.Hash{0x03}}, other)},
+		{receive},
+		nil,
+		{send, unrelated},
+		nil,
+		{spend(watchedOp, other)},
+		nil,
+		{spend(wire.OutPoint{Hash: chainhash.Hash{0x04}}, wallet)},
+		nil,
+	}
+	chain := newTestChain(t, blockTxns, 7)
+	r := New(chain.config())
+
+	var matches []*Match
+	var progress []*Progress
+	final, err := r.Rescan(context.Background(), &Request{
+		Scripts:     [][]byte{wallet},
+		OutPoints:   []wire.OutPoint{watchedOp},
+		StartHeight: 1,
+		StopHeight:  -1,
+		OnBlock: func(m *Match) error {
+			matches = append(matches, m)
+			return nil
+		},
+		OnProgress: func(p *Progress) error {
+			progress = append(progress, p)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Rescan: unexpected error: %v", err)
+	}
+
+	wantTxns := map[int32][]chainhash.Hash{
+		2: {receive.TxHash()},
+		4: {send.TxHash()},
+		6: {blockTxns[6][0].TxHash()},
+		8: {blockTxns[8][0].TxHash()},
+	}
+	if len(matches) != len(wantTxns) {
+		t.Fatalf("got %d matched blocks, want %d", len(matches),
+			len(wantTxns))
+	}
+	for _, m := range matches {
+		want, ok := wantTxns[m.Block.Height()]
+		if !ok {
+			t.Fatalf("unexpected match of block %d", m.Block.Height())
+		}
+		if len(m.Transactions) != len(want) {
+			t.Fatalf("got %d transactions in block %d, want %d",
+				len(m.Transactions), m.Block.Height(), len(want))
+		}
+		for i, tx := range m.Transactions {
+			if !tx.Hash().IsEqual(&want[i]) {
+				t.Fatalf("unexpected transaction %v in block %d",
+					tx.Hash(), m.Block.Height())
+			}
+		}
+	}
+
+	// Only the blocks with relevant transactions, barring false
+	// positives, and the blocks without a filter are fetched.
+	for height, block := range chain.blocks {
+		_, relevant := wantTxns[int32(height)]
+		fetched := chain.fetched[*block.Hash()] > 0
+		if height > 7 && !fetched {
+			t.Fatalf("block %d without a filter was not fetched",
+				height)
+		}
+		if relevant && !fetched {
+			t.Fatalf("relevant block %d was not fetched", height)
+		}
+	}
+
+	want := Progress{
+		Hash:                *chain.blocks[9].Hash(),
+		Height:              9,
+		StopHeight:          9,
+		ScannedBlocks:       9,
+		FetchedBlocks:       final.FetchedBlocks,
+		MatchedBlocks:       4,
+		MatchedTransactions: 4,
+	}
+	if *final != want {
+		t.Fatalf("unexpected final progress %+v, want %+v", final, want)
+	}
+	if final.FetchedBlocks < 5 || final.FetchedBlocks > 9 {
+		t.Fatalf("unexpected number of fetched blocks %d",
+			final.FetchedBlocks)
+	}
+	if len(progress) != 1 || *progress[0] != *final {
+		t.Fatalf("unexpected progress %+v", progress)
+	}
+}
+
+// TestRescanStopHeight ensures a rescan stops at the requested height.
+func TestRescanStopHeight(t *testing.T) {
+	wallet := []byte{0x76, 0xa9, 0x01}
+	blockTxns := make([][]*wire.MsgTx, 6)
+	blockTxns[1] = []*wire.MsgTx{spend(wire.OutPoint{}, wallet)}
+	blockTxns[4] = []*wire.MsgTx{spend(wire.OutPoint{Index: 1}, wallet)}
+	chain := newTestChain(t, blockTxns, 5)
+
+	var heights []int32
+	progress, err := New(chain.config()).Rescan(context.Background(), &Request{
+		Scripts:    [][]byte{wallet},
+		StopHeight: 3,
+		OnBlock: func(m *Match) error {
+			heights = append(heights, m.Block.Height())
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Rescan: unexpected error: %v", err)
+	}
+	if len(heights) != 1 || heights[0] != 1 {
+		t.Fatalf("unexpected matched heights %v", heights)
+	}
+	if progress.Height != 3 || progress.ScannedBlocks != 4 {
+		t.Fatalf("unexpected progress %+v", progress)
+	}
+}
+
+// TestRescanErrors ensures rescans fail when there is nothing to rescan for,
+// the context is done, a callback fails, the main chain is reorganized or too
+// many rescans are running.
+func TestRescanErrors(t *testing.T) {
+	wallet := []byte{0x76, 0xa9, 0x01}
+	blockTxns := make([][]*wire.MsgTx, 4)
+	blockTxns[2] = []*wire.MsgTx{spend(wire.OutPoint{}, wallet)}
+	chain := newTestChain(t, blockTxns, 3)
+	r := New(chain.config())
+
+	_, err := r.Rescan(context.Background(), &Request{})
+	if err != ErrNothingToRescan {
+		t.Fatalf("got error %v, want %v", err, ErrNothingToRescan)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = r.Rescan(ctx, &Request{Scripts: [][]byte{wallet}})
+	if err != context.Canceled {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+
+	errStop := errors.New("stop")
+	_, err = r.Rescan(context.Background(), &Request{
+		Scripts: [][]byte{wallet},
+		OnBlock: func(*Match) error { return errStop },
+	})
+	if err != errStop {
+		t.Fatalf("got error %v, want %v", err, errStop)
+	}
+
+	chain.orphans[*chain.blocks[3].Hash()] = true
+	_, err = r.Rescan(context.Background(), &Request{
+		Scripts: [][]byte{wallet},
+	})
+	if err != ErrReorganized {
+		t.Fatalf("got error %v, want %v", err, ErrReorganized)
+	}
+	delete(chain.orphans, *chain.blocks[3].Hash())
+
+	// Rescans beyond the maximum are rejected while the others run.
+	cfg := chain.config()
+	cfg.MaxRescans = 1
+	r = New(cfg)
+	_, err = r.Rescan(context.Background(), &Request{
+		Scripts: [][]byte{wallet},
+		OnBlock: func(*Match) error {
+			_, err := r.Rescan(context.Background(), &Request{
+				Scripts: [][]byte{wallet},
+			})
+			return err
+		},
+	})
+	if err != ErrTooManyRescans {
+		t.Fatalf("got error %v, want %v", err, ErrTooManyRescans)
+	}
+	if _, err := r.Rescan(context.Background(), &Request{
+		Scripts: [][]byte{wallet},
+	}); err != nil {
+		t.Fatalf("Rescan: unexpected error: %v", err)
+	}
+}
@@ -5,10 +5,12 @@
 package main
 
 import (
+	"net"
 	"sync/atomic"
 
 	"github.com/gcash/bchd/blockchain"
 	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/connmgr"
 	"github.com/gcash/bchd/mempool"
 	"github.com/gcash/bchd/netsync"
 	"github.com/gcash/bchd/peer"
@@ -49,7 +51,8 @@ func (p *rpcPeer) IsTxRelayDisabled() bool {
 // This function is safe for concurrent access and is part of the rpcserverPeer
 // interface implementation.
 func (p *rpcPeer) BanScore() uint32 {
-	return (*serverPeer)(p).banScore.Int()
+	sp := (*serverPeer)(p)
+	return sp.server.banManager.Score(sp.host())
 }
 
 // IsWhitelisted returns whether or not the peer is whitelisted.
@@ -69,6 +72,30 @@ func (p *rpcPeer) FeeFilter() int64 {
 	return atomic.LoadInt64(&(*serverPeer)(p).feeFilter)
 }
 
+// TxInvStats returns the number of transactions announced by the peer and how
+// many of them were not in the mempool when announced.
+//
+// This function is safe for concurrent access and is part of the rpcserverPeer
+// interface implementation.
+func (p *rpcPeer) TxInvStats() (uint64, uint64) {
+	sp := (*serverPeer)(p)
+	return atomic.LoadUint64(&sp.txInvsReceived),
+		atomic.LoadUint64(&sp.txInvsNew)
+}
+
+// CmpctBlockStats returns the number of compact blocks received from the peer
+// and how many of them were reconstructed from the mempool alone or after
+// fetching the missing transactions from the peer.
+//
+// This function is safe for concurrent access and is part of the rpcserverPeer
+// interface implementation.
+func (p *rpcPeer) CmpctBlockStats() (uint64, uint64, uint64) {
+	sp := (*serverPeer)(p)
+	return atomic.LoadUint64(&sp.cmpctBlocksReceived),
+		atomic.LoadUint64(&sp.cmpctBlocksReconstructed),
+		atomic.LoadUint64(&sp.cmpctBlocksRoundTrip)
+}
+
 // rpcConnManager provides a connection manager for use with the RPC server and
 // implements the rpcserverConnManager interface.
 type rpcConnManager struct {
@@ -155,6 +182,37 @@ func (cm *rpcConnManager) DisconnectByAddr(addr string) error {
 	return <-replyChan
 }
 
+// DisconnectBySubnet disconnects all peers with an IP address within the
+// provided subnet.  This applies to both inbound and outbound peers.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) DisconnectBySubnet(subnet *net.IPNet) {
+	cmp := func(sp *serverPeer) bool {
+		ip := net.ParseIP(sp.host())
+		return ip != nil && subnet.Contains(ip)
+	}
+	for {
+		replyChan := make(chan error)
+		cm.server.query <- disconnectNodeMsg{
+			cmp:   cmp,
+			reply: replyChan,
+		}
+		if err := <-replyChan; err != nil {
+			return
+		}
+	}
+}
+
+// UploadTarget returns the upload target bytes sent to peers are tracked
+// against.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) UploadTarget() *connmgr.UploadTarget {
+	return cm.server.uploadTarget
+}
+
 // ConnectedCount returns the number of currently connected peers.
 //
 // This function is safe for concurrent access and is part of the
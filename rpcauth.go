@@ -0,0 +1,339 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// rpcCookieUser is the username of the credential stored in the cookie
+	// file.
+	rpcCookieUser = "__cookie__"
+
+	// rpcCookieFilename is the name of the cookie file in the data
+	// directory.
+	rpcCookieFilename = ".cookie"
+
+	// rpcSaltSize is the number of random bytes of the salts generated for
+	// credentials given in plain text.
+	rpcSaltSize = 16
+)
+
+// rpcRole is a set of RPC methods a user is permitted to call.
+type rpcRole int
+
+const (
+	// rpcRoleReadOnly permits the methods which query the state of the
+	// node, including the websocket notifications, without changing it.
+	rpcRoleReadOnly rpcRole = iota
+
+	// rpcRoleWallet additionally permits relaying transactions and blocks,
+	// which is what wallets and other light clients need.  This is the set
+	// of methods available to the rpclimituser.
+	rpcRoleWallet
+
+	// rpcRoleAdmin permits all methods.
+	rpcRoleAdmin
+)
+
+// rpcRoleStrings is a map of roles back to their names as used in the
+// configuration.
+var rpcRoleStrings = map[rpcRole]string{
+	rpcRoleReadOnly: "readonly",
+	rpcRoleWallet:   "wallet",
+	rpcRoleAdmin:    "admin",
+}
+
+// String returns the rpcRole as a human-readable name.
+func (r rpcRole) String() string {
+	if str, ok := rpcRoleStrings[r]; ok {
+		return str
+	}
+	return fmt.Sprintf("Unknown rpcRole (%d)", int(r))
+}
+
+// parseRPCRole returns the role with the passed name.
+func parseRPCRole(name string) (rpcRole, error) {
+	for role, str := range rpcRoleStrings {
+		if strings.EqualFold(name, str) {
+			return role, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown role %q", name)
+}
+
+// rpcRelayMethods are the methods of the limited set which relay data to the
+// network and are therefore not available to read-only users.
+var rpcRelayMethods = map[string]struct{}{
+	"sendrawtransaction": {},
+	"submitblock":        {},
+}
+
+// allows returns whether the role permits calling the passed method.
+func (r rpcRole) allows(method string) bool {
+	switch r {
+	case rpcRoleAdmin:
+		return true
+	case rpcRoleReadOnly:
+		if _, ok := rpcRelayMethods[method]; ok {
+			return false
+		}
+	}
+	_, ok := rpcLimited[method]
+	return ok
+}
+
+// rpcCredential is a user allowed to access the RPC server.  Only an HMAC of
+// the password is kept so that credentials may be configured without revealing
+// their passwords.
+type rpcCredential struct {
+	user string
+	salt string
+	hash [sha256.Size]byte
+	role rpcRole
+
+	// whitelist, when not nil, restricts the methods the user may call
+	// beyond the ones permitted by its role.
+	whitelist map[string]struct{}
+}
+
+// rpcPasswordHMAC returns the HMAC-SHA256 of the password keyed by the salt.
+// This matches the rpcauth credentials of Bitcoin Core, so its rpcauth.py
+// script may be used to generate them.
+func rpcPasswordHMAC(salt, password string) [sha256.Size]byte {
+	var hash [sha256.Size]byte
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(password))
+	copy(hash[:], mac.Sum(nil))
+	return hash
+}
+
+// newRPCCredential returns a credential for the passed user and plain text
+// password with a random salt.
+func newRPCCredential(user, password string, role rpcRole) (*rpcCredential, error) {
+	var salt [rpcSaltSize]byte
+	if _, err := rand.Read(salt[:]); err != nil {
+		return nil, err
+	}
+	saltStr := hex.EncodeToString(salt[:])
+	return &rpcCredential{
+		user: user,
+		salt: saltStr,
+		hash: rpcPasswordHMAC(saltStr, password),
+		role: role,
+	}, nil
+}
+
+// parseRPCAuth parses a credential of the form <user>:<salt>$<hash>[:<role>],
+// where the hash is the hex encoded HMAC-SHA256 of the password keyed by the
+// salt.  The role defaults to admin.
+func parseRPCAuth(auth string) (*rpcCredential, error) {
+	fields := strings.Split(auth, ":")
+	if len(fields) < 2 || len(fields) > 3 || fields[0] == "" {
+		return nil, fmt.Errorf("credential %q is not of the form "+
+			"<user>:<salt>$<hash>[:<role>]", auth)
+	}
+	salt, hashStr, ok := strings.Cut(fields[1], "$")
+	if !ok || salt == "" {
+		return nil, fmt.Errorf("credential of user %q is missing the "+
+			"salt", fields[0])
+	}
+	hash, err := hex.DecodeString(hashStr)
+	if err != nil || len(hash) != sha256.Size {
+		return nil, fmt.Errorf("credential of user %q does not have a "+
+			"valid HMAC-SHA256 hash", fields[0])
+	}
+	credential := &rpcCredential{
+		user: fields[0],
+		salt: salt,
+		role: rpcRoleAdmin,
+	}
+	copy(credential.hash[:], hash)
+	if len(fields) == 3 {
+		credential.role, err = parseRPCRole(fields[2])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return credential, nil
+}
+
+// parseRPCWhitelist parses a whitelist of the form <user>:<method>,<method>,...
+// into the user and the set of methods.
+func parseRPCWhitelist(whitelist string) (string, map[string]struct{}, error) {
+	user, methods, ok := strings.Cut(whitelist, ":")
+	if !ok || user == "" {
+		return "", nil, fmt.Errorf("whitelist %q is not of the form "+
+			"<user>:<method>,<method>,...", whitelist)
+	}
+	set := make(map[string]struct{})
+	for _, method := range strings.Split(methods, ",") {
+		method = strings.ToLower(strings.TrimSpace(method))
+		if method != "" {
+			set[method] = struct{}{}
+		}
+	}
+	return user, set, nil
+}
+
+// restrict limits the methods the user may call to the passed ones.  When the
+// user is restricted more than once, it may only call the methods in all of
+// the sets.
+func (c *rpcCredential) restrict(methods map[string]struct{}) {
+	if c.whitelist == nil {
+		c.whitelist = methods
+		return
+	}
+	for method := range c.whitelist {
+		if _, ok := methods[method]; !ok {
+			delete(c.whitelist, method)
+		}
+	}
+}
+
+// authorized returns whether the user may call the passed method.
+func (c *rpcCredential) authorized(method string) bool {
+	if c.whitelist != nil {
+		if _, ok := c.whitelist[method]; !ok {
+			return false
+		}
+	}
+	return c.role.allows(method)
+}
+
+// matches returns whether the passed user and password match the credential.
+//
+// This check is time-constant.
+func (c *rpcCredential) matches(user, password string) bool {
+	hash := rpcPasswordHMAC(c.salt, password)
+	userCmp := subtle.ConstantTimeCompare([]byte(user), []byte(c.user))
+	hashCmp := subtle.ConstantTimeCompare(hash[:], c.hash[:])
+	return userCmp&hashCmp == 1
+}
+
+// authenticateUser returns the credential matching the passed user and
+// password, or nil when none does.  All of the credentials are checked so that
+// the time taken does not reveal which users exist.
+func authenticateUser(credentials []*rpcCredential, user, password string) *rpcCredential {
+	var match *rpcCredential
+	for _, credential := range credentials {
+		if credential.matches(user, password) {
+			match = credential
+		}
+	}
+	return match
+}
+
+// buildRPCCredentials returns the credentials of the RPC users configured by
+// the rpcuser, rpclimituser and rpcauth options along with the passed cookie
+// credential, when not nil, and restricts them to the methods of the
+// rpcwhitelist options.  The rpcuser is an admin and the rpclimituser may call
+// the limited set of methods wallets need.
+func buildRPCCredentials(cfg *config, cookie *rpcCredential) ([]*rpcCredential, error) {
+	// Make sure limited and admin users don't have the same username or
+	// password.
+	if cfg.RPCUser == cfg.RPCLimitUser && cfg.RPCUser != "" {
+		return nil, errors.New("--rpcuser and --rpclimituser must not " +
+			"specify the same username")
+	}
+	if cfg.RPCPass == cfg.RPCLimitPass && cfg.RPCPass != "" {
+		return nil, errors.New("--rpcpass and --rpclimitpass must not " +
+			"specify the same password")
+	}
+
+	var credentials []*rpcCredential
+	legacyUsers := []struct {
+		user, pass string
+		role       rpcRole
+	}{
+		{cfg.RPCUser, cfg.RPCPass, rpcRoleAdmin},
+		{cfg.RPCLimitUser, cfg.RPCLimitPass, rpcRoleWallet},
+	}
+	for _, legacyUser := range legacyUsers {
+		if legacyUser.user == "" || legacyUser.pass == "" {
+			continue
+		}
+		credential, err := newRPCCredential(legacyUser.user,
+			legacyUser.pass, legacyUser.role)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, credential)
+	}
+	for _, auth := range cfg.RPCAuth {
+		credential, err := parseRPCAuth(auth)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rpcauth: %v", err)
+		}
+		credentials = append(credentials, credential)
+	}
+
+	// The cookie credential is copied without its whitelist since it is
+	// restricted again below.
+	if cookie != nil {
+		credentials = append(credentials, &rpcCredential{
+			user: cookie.user,
+			salt: cookie.salt,
+			hash: cookie.hash,
+			role: cookie.role,
+		})
+	}
+
+	// Make sure the usernames are unique so the users can be told apart.
+	rpcUsers := make(map[string]*rpcCredential, len(credentials))
+	for _, credential := range credentials {
+		if _, ok := rpcUsers[credential.user]; ok {
+			return nil, fmt.Errorf("RPC user '%s' is specified more "+
+				"than once", credential.user)
+		}
+		rpcUsers[credential.user] = credential
+	}
+	for _, whitelist := range cfg.RPCWhitelists {
+		user, methods, err := parseRPCWhitelist(whitelist)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rpcwhitelist: %v", err)
+		}
+		credential, ok := rpcUsers[user]
+		if !ok {
+			return nil, fmt.Errorf("rpcwhitelist '%s' is for an "+
+				"unknown user", whitelist)
+		}
+		credential.restrict(methods)
+	}
+
+	return credentials, nil
+}
+
+// newRPCCookie returns a random password for the cookie credential along with
+// the credential.
+func newRPCCookie() (string, *rpcCredential, error) {
+	var secret [32]byte
+	if _, err := rand.Read(secret[:]); err != nil {
+		return "", nil, err
+	}
+	password := hex.EncodeToString(secret[:])
+	credential, err := newRPCCredential(rpcCookieUser, password, rpcRoleAdmin)
+	if err != nil {
+		return "", nil, err
+	}
+	return password, credential, nil
+}
+
+// writeRPCCookie writes the cookie credential to the passed file so that local
+// clients with access to the data directory may authenticate without a
+// configured password.  The file is only readable by the owner.
+func writeRPCCookie(path, password string) error {
+	tmpPath := path + ".tmp"
+	contents := []byte(rpcCookieUser + ":" + password)
+	if err := os.WriteFile(tmpPath, contents, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
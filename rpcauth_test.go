@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"github.com/gcash/bchlog"
+)
+
+// TestRPCAuth ensures users are authenticated with the configured credentials
+// and only authorized for the methods of their roles and whitelists.
+func TestRPCAuth(t *testing.T) {
+	oldLog := rpcsLog
+	rpcsLog = bchlog.Disabled
+	defer func() { rpcsLog = oldLog }()
+
+	// The credential is of the form generated by the rpcauth.py script of
+	// Bitcoin Core for the password "secret".
+	const salt = "cb77f0957de88ff388cf817ddbc7273"
+	hash := rpcPasswordHMAC(salt, "secret")
+	auditor, err := parseRPCAuth("auditor:" + salt + "$" +
+		hex.EncodeToString(hash[:]) + ":readonly")
+	if err != nil {
+		t.Fatalf("parseRPCAuth: unexpected error: %v", err)
+	}
+	admin, err := newRPCCredential("admin", "adminpass", rpcRoleAdmin)
+	if err != nil {
+		t.Fatalf("newRPCCredential: unexpected error: %v", err)
+	}
+	wallet, err := newRPCCredential("wallet", "walletpass", rpcRoleWallet)
+	if err != nil {
+		t.Fatalf("newRPCCredential: unexpected error: %v", err)
+	}
+	_, methods, err := parseRPCWhitelist("admin:getblock, GetBestBlockHash,stop")
+	if err != nil {
+		t.Fatalf("parseRPCWhitelist: unexpected error: %v", err)
+	}
+	admin.restrict(methods)
+	_, methods, err = parseRPCWhitelist("admin:getblock,getbestblockhash")
+	if err != nil {
+		t.Fatalf("parseRPCWhitelist: unexpected error: %v", err)
+	}
+	admin.restrict(methods)
+	s := &rpcServer{credentials: []*rpcCredential{auditor, admin, wallet}}
+
+	tests := []struct {
+		name       string
+		user       string
+		password   string
+		credential *rpcCredential
+		authorized []string
+		denied     []string
+	}{
+		{
+			name:       "rpcauth read-only user",
+			user:       "auditor",
+			password:   "secret",
+			credential: auditor,
+			authorized: []string{"getblock", "notifyblocks"},
+			denied:     []string{"sendrawtransaction", "stop"},
+		},
+		{
+			name:       "wallet user",
+			user:       "wallet",
+			password:   "walletpass",
+			credential: wallet,
+			authorized: []string{"getblock", "sendrawtransaction"},
+			denied:     []string{"generate", "stop"},
+		},
+		{
+			name:       "whitelisted admin",
+			user:       "admin",
+			password:   "adminpass",
+			credential: admin,
+			authorized: []string{"getblock", "getbestblockhash"},
+			denied:     []string{"stop", "getblockcount"},
+		},
+		{
+			name:     "wrong password",
+			user:     "wallet",
+			password: "adminpass",
+		},
+		{
+			name:     "unknown user",
+			user:     "nobody",
+			password: "secret",
+		},
+	}
+
+	for _, test := range tests {
+		r, err := http.NewRequest("POST", "/", nil)
+		if err != nil {
+			t.Fatalf("NewRequest: unexpected error: %v", err)
+		}
+		r.SetBasicAuth(test.user, test.password)
+		credential, err := s.checkAuth(r, true)
+		if credential != test.credential {
+			t.Errorf("%s: unexpected credential -- got %v, want %v",
+				test.name, credential, test.credential)
+			continue
+		}
+		if (err != nil) != (test.credential == nil) {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		for _, method := range test.authorized {
+			if !credential.authorized(method) {
+				t.Errorf("%s: not authorized for %s", test.name,
+					method)
+			}
+		}
+		for _, method := range test.denied {
+			if credential.authorized(method) {
+				t.Errorf("%s: authorized for %s", test.name, method)
+			}
+		}
+	}
+}
+
+// TestParseRPCAuth ensures malformed credentials are rejected.
+func TestParseRPCAuth(t *testing.T) {
+	hash := "0000000000000000000000000000000000000000000000000000000000000000"
+	tests := []string{
+		"user",
+		":salt$" + hash,
+		"user:" + hash,
+		"user:salt$00",
+		"user:salt$" + hash + ":superuser",
+		"user:salt$" + hash + ":admin:extra",
+	}
+	for _, auth := range tests {
+		if _, err := parseRPCAuth(auth); err == nil {
+			t.Errorf("parseRPCAuth(%q): unexpected success", auth)
+		}
+	}
+}
+
+// TestBuildRPCCredentials ensures the credentials of the configured RPC users
+// are built and restricted to their whitelists without changing the cookie
+// credential they are built with, and invalid configurations are rejected.
+func TestBuildRPCCredentials(t *testing.T) {
+	_, cookie, err := newRPCCookie()
+	if err != nil {
+		t.Fatalf("newRPCCookie: unexpected error: %v", err)
+	}
+	cfg := &config{
+		RPCUser:       "admin",
+		RPCPass:       "adminpass",
+		RPCLimitUser:  "wallet",
+		RPCLimitPass:  "walletpass",
+		RPCWhitelists: []string{rpcCookieUser + ":getblock"},
+	}
+	credentials, err := buildRPCCredentials(cfg, cookie)
+	if err != nil {
+		t.Fatalf("buildRPCCredentials: unexpected error: %v", err)
+	}
+	if len(credentials) != 3 {
+		t.Fatalf("buildRPCCredentials: got %d credentials, want 3",
+			len(credentials))
+	}
+	s := &rpcServer{}
+	s.setCredentials(credentials)
+	if c := s.authenticate("wallet", "walletpass"); c == nil ||
+		c.role != rpcRoleWallet {
+		t.Errorf("wallet user not authenticated with the wallet role")
+	}
+	restricted := s.cookieCredential()
+	if restricted == nil || restricted == cookie {
+		t.Fatalf("cookie credential not copied")
+	}
+	if restricted.authorized("stop") || !restricted.authorized("getblock") {
+		t.Errorf("cookie credential not restricted to its whitelist")
+	}
+	if !cookie.authorized("stop") {
+		t.Errorf("whitelist applied to the passed cookie credential")
+	}
+
+	tests := []struct {
+		name string
+		cfg  config
+	}{
+		{"same user", config{RPCUser: "user", RPCPass: "a",
+			RPCLimitUser: "user", RPCLimitPass: "b"}},
+		{"same password", config{RPCUser: "a", RPCPass: "pass",
+			RPCLimitUser: "b", RPCLimitPass: "pass"}},
+		{"duplicate user", config{RPCUser: rpcCookieUser,
+			RPCPass: "pass"}},
+		{"unknown whitelist user", config{RPCUser: "user",
+			RPCPass: "pass", RPCWhitelists: []string{"other:getblock"}}},
+		{"invalid rpcauth", config{RPCAuth: []string{"user"}}},
+	}
+	for _, test := range tests {
+		if _, err := buildRPCCredentials(&test.cfg, cookie); err == nil {
+			t.Errorf("%s: unexpected success", test.name)
+		}
+	}
+}
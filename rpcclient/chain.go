@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"strconv"
 
 	"github.com/gcash/bchd/btcjson"
 	"github.com/gcash/bchd/chaincfg/chainhash"
@@ -268,6 +269,52 @@ func (c *Client) GetBlockVerboseTx(blockHash *chainhash.Hash) (*btcjson.GetBlock
 	return c.GetBlockVerboseTxAsync(blockHash).Receive()
 }
 
+// FutureGetBlockVerbosePrevOutResult is a future promise to deliver the result
+// of a GetBlockVerbosePrevOutAsync RPC invocation (or an applicable error).
+type FutureGetBlockVerbosePrevOutResult chan *response
+
+// Receive waits for the response promised by the future and returns a verbose
+// version of the block including detailed information about its transactions
+// and the outputs spent by their inputs.
+func (r FutureGetBlockVerbosePrevOutResult) Receive() (*btcjson.GetBlockVerboseResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var blockResult btcjson.GetBlockVerboseResult
+	err = json.Unmarshal(res, &blockResult)
+	if err != nil {
+		return nil, err
+	}
+
+	return &blockResult, nil
+}
+
+// GetBlockVerbosePrevOutAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetBlockVerbosePrevOut for the blocking version and more details.
+func (c *Client) GetBlockVerbosePrevOutAsync(blockHash *chainhash.Hash) FutureGetBlockVerbosePrevOutResult {
+	hash := ""
+	if blockHash != nil {
+		hash = blockHash.String()
+	}
+	cmd := btcjson.NewGetBlockCmd(hash, btcjson.Verbositylevel(3))
+	return c.sendCmd(cmd)
+}
+
+// GetBlockVerbosePrevOut returns a data structure from the server with
+// information about a block and its transactions given its hash, including the
+// outputs spent by the inputs of the transactions and their fees, which allows
+// computing the fees of a block with a single call.
+//
+// See GetBlockVerboseTx if the spent outputs are not needed.
+func (c *Client) GetBlockVerbosePrevOut(blockHash *chainhash.Hash) (*btcjson.GetBlockVerboseResult, error) {
+	return c.GetBlockVerbosePrevOutAsync(blockHash).Receive()
+}
+
 // FutureGetBlockCountResult is a future promise to deliver the result of a
 // GetBlockCountAsync RPC invocation (or an applicable error).
 type FutureGetBlockCountResult chan *response
@@ -341,6 +388,47 @@ func (c *Client) GetDifficulty() (float64, error) {
 	return c.GetDifficultyAsync().Receive()
 }
 
+// FutureGetFirstSeenResult is a future promise to deliver the result of a
+// GetFirstSeenAsync RPC invocation (or an applicable error).
+type FutureGetFirstSeenResult chan *response
+
+// Receive waits for the response promised by the future and returns when and
+// from which peer the block or transaction was first seen.
+func (r FutureGetFirstSeenResult) Receive() (*btcjson.GetFirstSeenResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a getfirstseen result object.
+	var firstSeen btcjson.GetFirstSeenResult
+	err = json.Unmarshal(res, &firstSeen)
+	if err != nil {
+		return nil, err
+	}
+	return &firstSeen, nil
+}
+
+// GetFirstSeenAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See GetFirstSeen for the blocking version and more details.
+func (c *Client) GetFirstSeenAsync(hash *chainhash.Hash) FutureGetFirstSeenResult {
+	cmd := btcjson.NewGetFirstSeenCmd(hash.String())
+	return c.sendCmd(cmd)
+}
+
+// GetFirstSeen returns when and from which peer the block or transaction with
+// the given hash was first announced and when its full data was first
+// received.
+//
+// NOTE: This is a bchd extension and requires the server to be started with
+// --firstseen.
+func (c *Client) GetFirstSeen(hash *chainhash.Hash) (*btcjson.GetFirstSeenResult, error) {
+	return c.GetFirstSeenAsync(hash).Receive()
+}
+
 // FutureGetBlockChainInfoResult is a promise to deliver the result of a
 // GetBlockChainInfoAsync RPC invocation (or an applicable error).
 type FutureGetBlockChainInfoResult chan *response
@@ -516,6 +604,302 @@ func (c *Client) GetBlockHeaderVerbose(blockHash *chainhash.Hash) (*btcjson.GetB
 	return c.GetBlockHeaderVerboseAsync(blockHash).Receive()
 }
 
+// FutureGetBlockStatsResult is a future promise to deliver the result of a
+// GetBlockStatsAsync RPC invocation (or an applicable error).
+type FutureGetBlockStatsResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// statistics of the requested block.
+func (r FutureGetBlockStatsResult) Receive() (*btcjson.GetBlockStatsResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats btcjson.GetBlockStatsResult
+	err = json.Unmarshal(res, &stats)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+// GetBlockStatsAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See GetBlockStats for the blocking version and more details.
+func (c *Client) GetBlockStatsAsync(hashOrHeight btcjson.HashOrHeight, stats []string) FutureGetBlockStatsResult {
+	var statsPtr *[]string
+	if len(stats) > 0 {
+		statsPtr = &stats
+	}
+	cmd := btcjson.NewGetBlockStatsCmd(hashOrHeight, statsPtr, nil)
+	return c.sendCmd(cmd)
+}
+
+// GetBlockStats returns the passed statistics, or all of them when none are
+// passed, of the main chain block with the given hash or height.
+func (c *Client) GetBlockStats(hashOrHeight btcjson.HashOrHeight, stats []string) (*btcjson.GetBlockStatsResult, error) {
+	return c.GetBlockStatsAsync(hashOrHeight, stats).Receive()
+}
+
+// FutureGetBlockStatsRangeResult is a future promise to deliver the result of a
+// GetBlockStatsRangeAsync RPC invocation (or an applicable error).
+type FutureGetBlockStatsRangeResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// statistics of each block of the requested range.
+func (r FutureGetBlockStatsRangeResult) Receive() ([]btcjson.GetBlockStatsResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []btcjson.GetBlockStatsResult
+	err = json.Unmarshal(res, &stats)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// GetBlockStatsRangeAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GetBlockStatsRange for the blocking version and more details.
+func (c *Client) GetBlockStatsRangeAsync(startHeight, endHeight int32, stats []string) FutureGetBlockStatsRangeResult {
+	var statsPtr *[]string
+	if len(stats) > 0 {
+		statsPtr = &stats
+	}
+	hashOrHeight := btcjson.HashOrHeight(strconv.FormatInt(int64(startHeight), 10))
+	cmd := btcjson.NewGetBlockStatsCmd(hashOrHeight, statsPtr, &endHeight)
+	return c.sendCmd(cmd)
+}
+
+// GetBlockStatsRange returns the passed statistics, or all of them when none are
+// passed, of each main chain block from the start height to the end height
+// inclusive.
+func (c *Client) GetBlockStatsRange(startHeight, endHeight int32, stats []string) ([]btcjson.GetBlockStatsResult, error) {
+	return c.GetBlockStatsRangeAsync(startHeight, endHeight, stats).Receive()
+}
+
+// FutureGetBlockUndoDataResult is a future promise to deliver the result of a
+// GetBlockUndoDataAsync RPC invocation (or an applicable error).
+type FutureGetBlockUndoDataResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// outputs spent by the transactions of the requested block.
+func (r FutureGetBlockUndoDataResult) Receive() (*btcjson.GetBlockUndoDataResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var undoData btcjson.GetBlockUndoDataResult
+	err = json.Unmarshal(res, &undoData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &undoData, nil
+}
+
+// GetBlockUndoDataAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GetBlockUndoData for the blocking version and more details.
+func (c *Client) GetBlockUndoDataAsync(hashOrHeight btcjson.HashOrHeight) FutureGetBlockUndoDataResult {
+	cmd := btcjson.NewGetBlockUndoDataCmd(hashOrHeight)
+	return c.sendCmd(cmd)
+}
+
+// GetBlockUndoData returns the outputs spent by the transactions of the main
+// chain block with the given hash or height, as recorded in its undo data.
+func (c *Client) GetBlockUndoData(hashOrHeight btcjson.HashOrHeight) (*btcjson.GetBlockUndoDataResult, error) {
+	return c.GetBlockUndoDataAsync(hashOrHeight).Receive()
+}
+
+// FutureGetABLAStateResult is a future promise to deliver the result of a
+// GetABLAStateAsync RPC invocation (or an applicable error).
+type FutureGetABLAStateResult chan *response
+
+// Receive waits for the response promised by the future and returns the state
+// of the adaptive block size limit algorithm.
+func (r FutureGetABLAStateResult) Receive() (*btcjson.GetABLAStateResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var state btcjson.GetABLAStateResult
+	err = json.Unmarshal(res, &state)
+	if err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// GetABLAStateAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See GetABLAState for the blocking version and more details.
+func (c *Client) GetABLAStateAsync(blockSizes []uint64) FutureGetABLAStateResult {
+	var blockSizesPtr *[]uint64
+	if len(blockSizes) > 0 {
+		blockSizesPtr = &blockSizes
+	}
+	cmd := btcjson.NewGetABLAStateCmd(blockSizesPtr)
+	return c.sendCmd(cmd)
+}
+
+// GetABLAState returns the state of the adaptive block size limit algorithm
+// which applies to the block building on the tip of the main chain, projected
+// forward for the passed hypothetical block sizes, if any.
+func (c *Client) GetABLAState(blockSizes []uint64) (*btcjson.GetABLAStateResult, error) {
+	return c.GetABLAStateAsync(blockSizes).Receive()
+}
+
+// FutureGetChainTipsResult is a future promise to deliver the result of a
+// GetChainTipsAsync RPC invocation (or an applicable error).
+type FutureGetChainTipsResult chan *response
+
+// Receive waits for the response promised by the future and returns the tips
+// of all known branches of the block tree.
+func (r FutureGetChainTipsResult) Receive() ([]btcjson.GetChainTipsResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var tips []btcjson.GetChainTipsResult
+	err = json.Unmarshal(res, &tips)
+	if err != nil {
+		return nil, err
+	}
+
+	return tips, nil
+}
+
+// GetChainTipsAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See GetChainTips for the blocking version and more details.
+func (c *Client) GetChainTipsAsync() FutureGetChainTipsResult {
+	cmd := btcjson.NewGetChainTipsCmd()
+	return c.sendCmd(cmd)
+}
+
+// GetChainTips returns the tips of all known branches of the block tree,
+// including the main chain, along with their status.
+func (c *Client) GetChainTips() ([]btcjson.GetChainTipsResult, error) {
+	return c.GetChainTipsAsync().Receive()
+}
+
+// FutureGetCheckpointCandidatesResult is a future promise to deliver the result
+// of a GetCheckpointCandidatesAsync RPC invocation (or an applicable error).
+type FutureGetCheckpointCandidatesResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// checkpoint candidates along with a minimum chain work.
+func (r FutureGetCheckpointCandidatesResult) Receive() (*btcjson.GetCheckpointCandidatesResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates btcjson.GetCheckpointCandidatesResult
+	err = json.Unmarshal(res, &candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &candidates, nil
+}
+
+// GetCheckpointCandidatesAsync returns an instance of a type that can be used
+// to get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetCheckpointCandidates for the blocking version and more details.
+func (c *Client) GetCheckpointCandidatesAsync(count int, interval int32) FutureGetCheckpointCandidatesResult {
+	cmd := btcjson.NewGetCheckpointCandidatesCmd(&count, &interval)
+	return c.sendCmd(cmd)
+}
+
+// GetCheckpointCandidates returns up to count blocks of the main chain which are
+// good checkpoint candidates, at least interval blocks apart, along with a
+// minimum chain work for the network.
+//
+// NOTE: This is a bchd extension.
+func (c *Client) GetCheckpointCandidates(count int, interval int32) (*btcjson.GetCheckpointCandidatesResult, error) {
+	return c.GetCheckpointCandidatesAsync(count, interval).Receive()
+}
+
+// FutureGetNextBlockParamsResult is a future promise to deliver the result of
+// a GetNextBlockParamsAsync RPC invocation (or an applicable error).
+type FutureGetNextBlockParamsResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// consensus parameters of the next block.
+func (r FutureGetNextBlockParamsResult) Receive() (*btcjson.GetNextBlockParamsResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var params btcjson.GetNextBlockParamsResult
+	err = json.Unmarshal(res, &params)
+	if err != nil {
+		return nil, err
+	}
+
+	return &params, nil
+}
+
+// GetNextBlockParamsAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GetNextBlockParams for the blocking version and more details.
+func (c *Client) GetNextBlockParamsAsync(prevHash *chainhash.Hash, headers []wire.BlockHeader, timestamp *int64) FutureGetNextBlockParamsResult {
+	var prevHashStr *string
+	if prevHash != nil {
+		prevHashStr = btcjson.String(prevHash.String())
+	}
+	var headersHex *[]string
+	if len(headers) > 0 {
+		hexHeaders := make([]string, 0, len(headers))
+		for i := range headers {
+			var buf bytes.Buffer
+			if err := headers[i].Serialize(&buf); err != nil {
+				return newFutureError(err)
+			}
+			hexHeaders = append(hexHeaders, hex.EncodeToString(buf.Bytes()))
+		}
+		headersHex = &hexHeaders
+	}
+	cmd := btcjson.NewGetNextBlockParamsCmd(prevHashStr, headersHex, timestamp)
+	return c.sendCmd(cmd)
+}
+
+// GetNextBlockParams returns the consensus parameters, such as the required
+// difficulty, the median time past and the block size limit, of the block
+// building on the block with the given hash, or the tip of the main chain when
+// it is nil, extended by the given headers.  The difficulty is calculated for
+// the given timestamp, or the current time of the server when it is nil.
+func (c *Client) GetNextBlockParams(prevHash *chainhash.Hash, headers []wire.BlockHeader, timestamp *int64) (*btcjson.GetNextBlockParamsResult, error) {
+	return c.GetNextBlockParamsAsync(prevHash, headers, timestamp).Receive()
+}
+
 // FutureGetMempoolEntryResult is a future promise to deliver the result of a
 // GetMempoolEntryAsync RPC invocation (or an applicable error).
 type FutureGetMempoolEntryResult chan *response
@@ -822,6 +1206,43 @@ func (c *Client) GetTxOut(txHash *chainhash.Hash, index uint32, mempool bool) (*
 	return c.GetTxOutAsync(txHash, index, mempool).Receive()
 }
 
+// FutureGetTxOutSetInfoResult is a future promise to deliver the result of a
+// GetTxOutSetInfoAsync RPC invocation (or an applicable error).
+type FutureGetTxOutSetInfoResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// statistics about the unspent transaction output set.
+func (r FutureGetTxOutSetInfoResult) Receive() (*btcjson.GetTxOutSetInfoResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a gettxoutsetinfo result object.
+	var info btcjson.GetTxOutSetInfoResult
+	err = json.Unmarshal(res, &info)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// GetTxOutSetInfoAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GetTxOutSetInfo for the blocking version and more details.
+func (c *Client) GetTxOutSetInfoAsync() FutureGetTxOutSetInfoResult {
+	cmd := btcjson.NewGetTxOutSetInfoCmd()
+	return c.sendCmd(cmd)
+}
+
+// GetTxOutSetInfo returns statistics about the unspent transaction output set.
+func (c *Client) GetTxOutSetInfo() (*btcjson.GetTxOutSetInfoResult, error) {
+	return c.GetTxOutSetInfoAsync().Receive()
+}
+
 // FutureRescanBlocksResult is a future promise to deliver the result of a
 // RescanBlocksAsync RPC invocation (or an applicable error).
 //
@@ -1056,6 +1477,84 @@ func (c *Client) GetMempoolInfo() (*btcjson.GetMempoolInfoResult, error) {
 	return c.GetMempoolInfoAsync().Receive()
 }
 
+// FutureSetRelayPolicyResult is a future promise to deliver the result of a
+// SetRelayPolicyAsync RPC invocation (or an applicable error).
+type FutureSetRelayPolicyResult chan *response
+
+// Receive waits for the response promised by the future and returns the relay
+// policy in effect along with the transactions removed from the memory pool.
+func (r FutureSetRelayPolicyResult) Receive() (*btcjson.SetRelayPolicyResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a setrelaypolicy result object.
+	var result btcjson.SetRelayPolicyResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// SetRelayPolicyAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See SetRelayPolicy for the blocking version and more details.
+func (c *Client) SetRelayPolicyAsync(options *btcjson.RelayPolicyOptions) FutureSetRelayPolicyResult {
+	cmd := btcjson.NewSetRelayPolicyCmd(options)
+	return c.sendCmd(cmd)
+}
+
+// SetRelayPolicy updates the passed parameters of the relay policy of the
+// server, leaving the ones which are not set unchanged, and returns the policy
+// in effect.  Passing nil options only returns the policy.
+func (c *Client) SetRelayPolicy(options *btcjson.RelayPolicyOptions) (*btcjson.SetRelayPolicyResult, error) {
+	return c.SetRelayPolicyAsync(options).Receive()
+}
+
+// FutureReloadConfigResult is a future promise to deliver the result of a
+// ReloadConfigAsync RPC invocation (or an applicable error).
+type FutureReloadConfigResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// configuration options which were applied along with the transactions removed
+// from the memory pool.
+func (r FutureReloadConfigResult) Receive() (*btcjson.ReloadConfigResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a reloadconfig result object.
+	var result btcjson.ReloadConfigResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ReloadConfigAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See ReloadConfig for the blocking version and more details.
+func (c *Client) ReloadConfigAsync() FutureReloadConfigResult {
+	cmd := btcjson.NewReloadConfigCmd()
+	return c.sendCmd(cmd)
+}
+
+// ReloadConfig makes the server load its configuration again and apply the
+// options which changed and may be changed while running.
+func (c *Client) ReloadConfig() (*btcjson.ReloadConfigResult, error) {
+	return c.ReloadConfigAsync().Receive()
+}
+
 // FutureGetTxOutProofResult is a future promise to deliver the result of a
 // GetTxOutProofAsync RPC invocation (or an applicable error).
 type FutureGetTxOutProofResult chan *response
@@ -1130,3 +1629,117 @@ func (c *Client) VerifyTxOutProofAsync(proof string) FutureVerifyTxOutProofResul
 func (c *Client) VerifyTxOutProof(proof string) ([]string, error) {
 	return c.VerifyTxOutProofAsync(proof).Receive()
 }
+
+// FutureCompactDatabaseResult is a future promise to deliver the result of a
+// CompactDatabaseAsync RPC invocation (or an applicable error).
+type FutureCompactDatabaseResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// outcome of the database compaction.
+func (r FutureCompactDatabaseResult) Receive() (*btcjson.CompactDatabaseResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a compactdatabase result object.
+	var result btcjson.CompactDatabaseResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// CompactDatabaseAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See CompactDatabase for the blocking version and more details.
+func (c *Client) CompactDatabaseAsync() FutureCompactDatabaseResult {
+	cmd := btcjson.NewCompactDatabaseCmd()
+	return c.sendCmd(cmd)
+}
+
+// CompactDatabase compacts the database of the server to reclaim the disk
+// space held by deleted keys and returns how much space was reclaimed.
+func (c *Client) CompactDatabase() (*btcjson.CompactDatabaseResult, error) {
+	return c.CompactDatabaseAsync().Receive()
+}
+
+// FutureGetDatabaseInfoResult is a future promise to deliver the result of a
+// GetDatabaseInfoAsync RPC invocation (or an applicable error).
+type FutureGetDatabaseInfoResult chan *response
+
+// Receive waits for the response promised by the future and returns
+// information about the disk space used by the database.
+func (r FutureGetDatabaseInfoResult) Receive() (*btcjson.GetDatabaseInfoResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a getdatabaseinfo result object.
+	var info btcjson.GetDatabaseInfoResult
+	err = json.Unmarshal(res, &info)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// GetDatabaseInfoAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GetDatabaseInfo for the blocking version and more details.
+func (c *Client) GetDatabaseInfoAsync() FutureGetDatabaseInfoResult {
+	cmd := btcjson.NewGetDatabaseInfoCmd()
+	return c.sendCmd(cmd)
+}
+
+// GetDatabaseInfo returns information about the disk space used by the
+// database of the server.
+func (c *Client) GetDatabaseInfo() (*btcjson.GetDatabaseInfoResult, error) {
+	return c.GetDatabaseInfoAsync().Receive()
+}
+
+// FutureBackupChainstateResult is a future promise to deliver the result of a
+// BackupChainstateAsync RPC invocation (or an applicable error).
+type FutureBackupChainstateResult chan *response
+
+// Receive waits for the response promised by the future and returns
+// information about the written backup.
+func (r FutureBackupChainstateResult) Receive() (*btcjson.BackupChainstateResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a backupchainstate result object.
+	var result btcjson.BackupChainstateResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// BackupChainstateAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See BackupChainstate for the blocking version and more details.
+func (c *Client) BackupChainstateAsync(destination string, includeBlocks bool) FutureBackupChainstateResult {
+	cmd := btcjson.NewBackupChainstateCmd(destination, &includeBlocks)
+	return c.sendCmd(cmd)
+}
+
+// BackupChainstate writes a consistent snapshot of the database of the server
+// to the passed destination file on the server.
+func (c *Client) BackupChainstate(destination string, includeBlocks bool) (*btcjson.BackupChainstateResult, error) {
+	return c.BackupChainstateAsync(destination, includeBlocks).Receive()
+}
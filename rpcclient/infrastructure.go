@@ -247,6 +247,12 @@ func (c *Client) trackRegisteredNtfns(cmd interface{}) {
 	case *btcjson.NotifyBlocksCmd:
 		c.ntfnState.notifyBlocks = true
 
+	case *btcjson.NotifyChainReorgCmd:
+		c.ntfnState.notifyChainReorg = true
+
+	case *btcjson.NotifyDoubleSpendCmd:
+		c.ntfnState.notifyDoubleSpend = true
+
 	case *btcjson.NotifyNewTransactionsCmd:
 		if bcmd.Verbose != nil && *bcmd.Verbose {
 			c.ntfnState.notifyNewTxVerbose = true
@@ -519,6 +525,22 @@ func (c *Client) reregisterNtfns() error {
 		}
 	}
 
+	// Reregister notifychainreorg if needed.
+	if stateCopy.notifyChainReorg {
+		log.Debugf("Reregistering [notifychainreorg]")
+		if err := c.NotifyChainReorg(); err != nil {
+			return err
+		}
+	}
+
+	// Reregister notifydoublespend if needed.
+	if stateCopy.notifyDoubleSpend {
+		log.Debugf("Reregistering [notifydoublespend]")
+		if err := c.NotifyDoubleSpend(); err != nil {
+			return err
+		}
+	}
+
 	// Reregister notifynewtransactions if needed.
 	if stateCopy.notifyNewTx || stateCopy.notifyNewTxVerbose {
 		log.Debugf("Reregistering [notifynewtransactions] (verbose=%v)",
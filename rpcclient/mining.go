@@ -5,6 +5,7 @@
 package rpcclient
 
 import (
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -61,6 +62,65 @@ func (c *Client) Generate(numBlocks uint32) ([]*chainhash.Hash, error) {
 	return c.GenerateAsync(numBlocks).Receive()
 }
 
+// GenerateToAddressAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GenerateToAddress for the blocking version and more details.
+func (c *Client) GenerateToAddressAsync(numBlocks uint32, address bchutil.Address) FutureGenerateResult {
+	cmd := btcjson.NewGenerateToAddressCmd(numBlocks, address.EncodeAddress(), nil)
+	return c.sendCmd(cmd)
+}
+
+// GenerateToAddress generates numBlocks blocks paying to the passed address
+// and returns their hashes.
+func (c *Client) GenerateToAddress(numBlocks uint32, address bchutil.Address) ([]*chainhash.Hash, error) {
+	return c.GenerateToAddressAsync(numBlocks, address).Receive()
+}
+
+// FutureGenerateBlockResult is a future promise to deliver the result of a
+// GenerateBlockAsync RPC invocation (or an applicable error).
+type FutureGenerateBlockResult chan *response
+
+// Receive waits for the response promised by the future and returns the hash
+// of the generated block.
+func (r FutureGenerateBlockResult) Receive() (*chainhash.Hash, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var result btcjson.GenerateBlockResult
+	if err := json.Unmarshal(res, &result); err != nil {
+		return nil, err
+	}
+	return chainhash.NewHashFromStr(result.Hash)
+}
+
+// GenerateBlockAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GenerateBlock for the blocking version and more details.
+func (c *Client) GenerateBlockAsync(address bchutil.Address, txns []*bchutil.Tx) FutureGenerateBlockResult {
+	rawTxns := make([]string, 0, len(txns))
+	for _, tx := range txns {
+		var buf bytes.Buffer
+		if err := tx.MsgTx().Serialize(&buf); err != nil {
+			return newFutureError(err)
+		}
+		rawTxns = append(rawTxns, hex.EncodeToString(buf.Bytes()))
+	}
+	cmd := btcjson.NewGenerateBlockCmd(address.EncodeAddress(), rawTxns)
+	return c.sendCmd(cmd)
+}
+
+// GenerateBlock generates a block paying to the passed address which contains
+// exactly the passed transactions and returns its hash.
+func (c *Client) GenerateBlock(address bchutil.Address, txns []*bchutil.Tx) (*chainhash.Hash, error) {
+	return c.GenerateBlockAsync(address, txns).Receive()
+}
+
 // FutureGetGenerateResult is a future promise to deliver the result of a
 // GetGenerateAsync RPC invocation (or an applicable error).
 type FutureGetGenerateResult chan *response
@@ -414,4 +474,49 @@ func (c *Client) SubmitBlock(block *bchutil.Block, options *btcjson.SubmitBlockO
 	return c.SubmitBlockAsync(block, options).Receive()
 }
 
+// FutureSubmitBlockVerboseResult is a future promise to deliver the result of a
+// SubmitBlockVerboseAsync RPC invocation (or an applicable error).
+type FutureSubmitBlockVerboseResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// outcome of submitting the block, including the precise reason it was
+// rejected.
+func (r FutureSubmitBlockVerboseResult) Receive() (*btcjson.SubmitBlockResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var result btcjson.SubmitBlockResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SubmitBlockVerboseAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See SubmitBlockVerbose for the blocking version and more details.
+func (c *Client) SubmitBlockVerboseAsync(block *bchutil.Block) FutureSubmitBlockVerboseResult {
+	blockBytes, err := block.Bytes()
+	if err != nil {
+		return newFutureError(err)
+	}
+
+	options := &btcjson.SubmitBlockOptions{Verbose: true}
+	cmd := btcjson.NewSubmitBlockCmd(hex.EncodeToString(blockBytes), options)
+	return c.sendCmd(cmd)
+}
+
+// SubmitBlockVerbose submits a new block into the bitcoin network and returns
+// whether it was accepted along with the rule it violated, and the offending
+// transaction, input, and opcode when it was rejected.  Rejection is reported
+// through the result rather than the returned error.
+func (c *Client) SubmitBlockVerbose(block *bchutil.Block) (*btcjson.SubmitBlockResult, error) {
+	return c.SubmitBlockVerboseAsync(block).Receive()
+}
+
 // TODO(davec): Implement GetBlockTemplate
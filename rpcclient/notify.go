@@ -33,6 +33,8 @@ var (
 // reconnect.
 type notificationState struct {
 	notifyBlocks       bool
+	notifyChainReorg   bool
+	notifyDoubleSpend  bool
 	notifyNewTx        bool
 	notifyNewTxVerbose bool
 	notifyReceived     map[string]struct{}
@@ -43,6 +45,8 @@ type notificationState struct {
 func (s *notificationState) Copy() *notificationState {
 	var stateCopy notificationState
 	stateCopy.notifyBlocks = s.notifyBlocks
+	stateCopy.notifyChainReorg = s.notifyChainReorg
+	stateCopy.notifyDoubleSpend = s.notifyDoubleSpend
 	stateCopy.notifyNewTx = s.notifyNewTx
 	stateCopy.notifyNewTxVerbose = s.notifyNewTxVerbose
 	stateCopy.notifyReceived = make(map[string]struct{})
@@ -121,6 +125,27 @@ type NotificationHandlers struct {
 	// OnBlockDisconnected: it receives the block's height and header.
 	OnFilteredBlockDisconnected func(height int32, header *wire.BlockHeader)
 
+	// OnChainReorg is invoked when the longest (best) chain is reorganized.
+	// It receives the common ancestor of the old and new chain, the hashes
+	// of the disconnected blocks starting with the old tip, the hashes of
+	// the connected blocks ending with the new tip, and the hashes of the
+	// transactions of the disconnected blocks which are not included in the
+	// connected blocks.  It will only be invoked if a preceding call to
+	// NotifyChainReorg has been made to register for the notification and
+	// the function is non-nil.
+	OnChainReorg func(forkHash *chainhash.Hash, forkHeight int32,
+		disconnected, connected, unconfirmedTxs []*chainhash.Hash)
+
+	// OnDoubleSpend is invoked when a transaction which spends an output
+	// already spent by a transaction in the memory pool is detected.  The
+	// confirmed flag is set when the conflicting transaction was confirmed
+	// in a block, evicting the first seen transaction, and unset when it
+	// was rejected.  It will only be invoked if a preceding call to
+	// NotifyDoubleSpend has been made to register for the notification and
+	// the function is non-nil.
+	OnDoubleSpend func(outPoint *wire.OutPoint, firstSeenTx,
+		conflictTx *chainhash.Hash, confirmed bool)
+
 	// OnRecvTx is invoked when a transaction that receives funds to a
 	// registered address is received into the memory pool and also
 	// connected to the longest (best) chain.  It will only be invoked if a
@@ -324,6 +349,44 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 
 		c.ntfnHandlers.OnRedeemingTx(tx, block)
 
+	// OnChainReorg
+	case btcjson.ChainReorgNtfnMethod:
+		// Ignore the notification if the client is not interested in
+		// it.
+		if c.ntfnHandlers.OnChainReorg == nil {
+			return
+		}
+
+		forkHash, forkHeight, disconnected, connected, unconfirmedTxs,
+			err := parseChainReorgParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid chainreorg notification: %v",
+				err)
+			return
+		}
+
+		c.ntfnHandlers.OnChainReorg(forkHash, forkHeight, disconnected,
+			connected, unconfirmedTxs)
+
+	// OnDoubleSpend
+	case btcjson.DoubleSpendNtfnMethod:
+		// Ignore the notification if the client is not interested in
+		// it.
+		if c.ntfnHandlers.OnDoubleSpend == nil {
+			return
+		}
+
+		outPoint, firstSeenTx, conflictTx, confirmed, err :=
+			parseDoubleSpendParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid doublespend notification: %v",
+				err)
+			return
+		}
+
+		c.ntfnHandlers.OnDoubleSpend(outPoint, firstSeenTx, conflictTx,
+			confirmed)
+
 	// OnRelevantTxAccepted
 	case btcjson.RelevantTxAcceptedNtfnMethod:
 		// Ignore the notification if the client is not interested in
@@ -634,6 +697,108 @@ func parseRelevantTxAcceptedParams(params []json.RawMessage) (transaction []byte
 	return parseHexParam(params[0])
 }
 
+// parseHashesParam parses a JSON array of hash strings.
+func parseHashesParam(param json.RawMessage) ([]*chainhash.Hash, error) {
+	var strs []string
+	if err := json.Unmarshal(param, &strs); err != nil {
+		return nil, err
+	}
+	hashes := make([]*chainhash.Hash, 0, len(strs))
+	for _, str := range strs {
+		hash, err := chainhash.NewHashFromStr(str)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// parseChainReorgParams parses out the common ancestor, the disconnected and
+// connected blocks, and the unconfirmed transactions from the parameters of a
+// chainreorg notification.
+func parseChainReorgParams(params []json.RawMessage) (*chainhash.Hash, int32,
+	[]*chainhash.Hash, []*chainhash.Hash, []*chainhash.Hash, error) {
+
+	if len(params) != 5 {
+		return nil, 0, nil, nil, nil, wrongNumParams(len(params))
+	}
+
+	// Unmarshal first parameter as a string.
+	var forkHashStr string
+	err := json.Unmarshal(params[0], &forkHashStr)
+	if err != nil {
+		return nil, 0, nil, nil, nil, err
+	}
+	forkHash, err := chainhash.NewHashFromStr(forkHashStr)
+	if err != nil {
+		return nil, 0, nil, nil, nil, err
+	}
+
+	// Unmarshal second parameter as an integer.
+	var forkHeight int32
+	err = json.Unmarshal(params[1], &forkHeight)
+	if err != nil {
+		return nil, 0, nil, nil, nil, err
+	}
+
+	// Unmarshal the remaining parameters as arrays of hashes.
+	var hashes [3][]*chainhash.Hash
+	for i := range hashes {
+		hashes[i], err = parseHashesParam(params[i+2])
+		if err != nil {
+			return nil, 0, nil, nil, nil, err
+		}
+	}
+
+	return forkHash, forkHeight, hashes[0], hashes[1], hashes[2], nil
+}
+
+// parseDoubleSpendParams parses out the double spent outpoint, the first seen
+// and conflicting transactions, and whether the conflicting transaction was
+// confirmed from the parameters of a doublespend notification.
+func parseDoubleSpendParams(params []json.RawMessage) (*wire.OutPoint,
+	*chainhash.Hash, *chainhash.Hash, bool, error) {
+
+	if len(params) != 4 {
+		return nil, nil, nil, false, wrongNumParams(len(params))
+	}
+
+	// Unmarshal first parameter as an outpoint JSON object.
+	var op btcjson.OutPoint
+	err := json.Unmarshal(params[0], &op)
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+	opHash, err := chainhash.NewHashFromStr(op.Hash)
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+
+	// Unmarshal second and third parameters as hash strings.
+	var txHashes [2]*chainhash.Hash
+	for i := range txHashes {
+		var str string
+		if err := json.Unmarshal(params[i+1], &str); err != nil {
+			return nil, nil, nil, false, err
+		}
+		txHashes[i], err = chainhash.NewHashFromStr(str)
+		if err != nil {
+			return nil, nil, nil, false, err
+		}
+	}
+
+	// Unmarshal fourth parameter as a boolean.
+	var confirmed bool
+	err = json.Unmarshal(params[3], &confirmed)
+	if err != nil {
+		return nil, nil, nil, false, err
+	}
+
+	return wire.NewOutPoint(opHash, op.Index), txHashes[0], txHashes[1],
+		confirmed, nil
+}
+
 // parseChainTxNtfnParams parses out the transaction and optional details about
 // the block it's mined in from the parameters of recvtx and redeemingtx
 // notifications.
@@ -902,6 +1067,103 @@ func (c *Client) NotifyBlocks() error {
 	return c.NotifyBlocksAsync().Receive()
 }
 
+// FutureNotifyChainReorgResult is a future promise to deliver the result of a
+// NotifyChainReorgAsync RPC invocation (or an applicable error).
+type FutureNotifyChainReorgResult chan *response
+
+// Receive waits for the response promised by the future and returns an error
+// if the registration was not successful.
+func (r FutureNotifyChainReorgResult) Receive() error {
+	_, err := receiveFuture(r)
+	return err
+}
+
+// NotifyChainReorgAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See NotifyChainReorg for the blocking version and more details.
+//
+// NOTE: This is a bchd extension and requires a websocket connection.
+func (c *Client) NotifyChainReorgAsync() FutureNotifyChainReorgResult {
+	// Not supported in HTTP POST mode.
+	if c.config.HTTPPostMode {
+		return newFutureError(ErrWebsocketsRequired)
+	}
+
+	// Ignore the notification if the client is not interested in
+	// notifications.
+	if c.ntfnHandlers == nil {
+		return newNilFutureResult()
+	}
+
+	cmd := btcjson.NewNotifyChainReorgCmd()
+	return c.sendCmd(cmd)
+}
+
+// NotifyChainReorg registers the client to receive notifications when the main
+// chain is reorganized.  The notifications are delivered to the notification
+// handlers associated with the client.  Calling this function has no effect if
+// there are no notification handlers and will result in an error if the client
+// is configured to run in HTTP POST mode.
+//
+// The notifications delivered as a result of this call will be via
+// OnChainReorg.
+//
+// NOTE: This is a bchd extension and requires a websocket connection.
+func (c *Client) NotifyChainReorg() error {
+	return c.NotifyChainReorgAsync().Receive()
+}
+
+// FutureNotifyDoubleSpendResult is a future promise to deliver the result of a
+// NotifyDoubleSpendAsync RPC invocation (or an applicable error).
+type FutureNotifyDoubleSpendResult chan *response
+
+// Receive waits for the response promised by the future and returns an error
+// if the registration was not successful.
+func (r FutureNotifyDoubleSpendResult) Receive() error {
+	_, err := receiveFuture(r)
+	return err
+}
+
+// NotifyDoubleSpendAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See NotifyDoubleSpend for the blocking version and more details.
+//
+// NOTE: This is a bchd extension and requires a websocket connection.
+func (c *Client) NotifyDoubleSpendAsync() FutureNotifyDoubleSpendResult {
+	// Not supported in HTTP POST mode.
+	if c.config.HTTPPostMode {
+		return newFutureError(ErrWebsocketsRequired)
+	}
+
+	// Ignore the notification if the client is not interested in
+	// notifications.
+	if c.ntfnHandlers == nil {
+		return newNilFutureResult()
+	}
+
+	cmd := btcjson.NewNotifyDoubleSpendCmd()
+	return c.sendCmd(cmd)
+}
+
+// NotifyDoubleSpend registers the client to receive notifications when a
+// transaction which double spends a transaction in the memory pool is rejected
+// or confirmed.  The notifications are delivered to the notification handlers
+// associated with the client.  Calling this function has no effect if there
+// are no notification handlers and will result in an error if the client is
+// configured to run in HTTP POST mode.
+//
+// The notifications delivered as a result of this call will be via
+// OnDoubleSpend.
+//
+// NOTE: This is a bchd extension and requires a websocket connection.
+func (c *Client) NotifyDoubleSpend() error {
+	return c.NotifyDoubleSpendAsync().Receive()
+}
+
 // FutureNotifySpentResult is a future promise to deliver the result of a
 // NotifySpentAsync RPC invocation (or an applicable error).
 //
@@ -317,6 +317,100 @@ func (c *Client) SendRawSerializedTransaction(txHex string, allowHighFees bool)
 	return c.SendRawTransactionAsync(nil, txHex, allowHighFees).Receive()
 }
 
+// FutureTestMempoolAcceptResult is a future promise to deliver the result of a
+// TestMempoolAcceptAsync RPC invocation (or an applicable error).
+type FutureTestMempoolAcceptResult chan *response
+
+// Receive waits for the response promised by the future and returns whether
+// each of the tested transactions would be accepted into the memory pool.
+func (r FutureTestMempoolAcceptResult) Receive() ([]btcjson.TestMempoolAcceptResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as an array of testmempoolaccept result objects.
+	var results []btcjson.TestMempoolAcceptResult
+	err = json.Unmarshal(res, &results)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// TestMempoolAcceptAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See TestMempoolAccept for the blocking version and more details.
+func (c *Client) TestMempoolAcceptAsync(txns []*wire.MsgTx) FutureTestMempoolAcceptResult {
+	rawTxs := make([]string, 0, len(txns))
+	for _, tx := range txns {
+		// Serialize the transaction and convert to hex string.
+		buf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
+		if err := tx.Serialize(buf); err != nil {
+			return newFutureError(err)
+		}
+		rawTxs = append(rawTxs, hex.EncodeToString(buf.Bytes()))
+	}
+
+	cmd := btcjson.NewTestMempoolAcceptCmd(rawTxs)
+	return c.sendCmd(cmd)
+}
+
+// TestMempoolAccept returns whether the passed transactions would be accepted
+// into the memory pool of the server without adding them to it.  The
+// transactions are tested in order, so transactions may spend the outputs of
+// those preceding them.
+func (c *Client) TestMempoolAccept(txns []*wire.MsgTx) ([]btcjson.TestMempoolAcceptResult, error) {
+	return c.TestMempoolAcceptAsync(txns).Receive()
+}
+
+// FutureAnalyzeTransactionResult is a future promise to deliver the result of
+// an AnalyzeTransactionAsync RPC invocation (or an applicable error).
+type FutureAnalyzeTransactionResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// standardness rules the analyzed transaction violates.
+func (r FutureAnalyzeTransactionResult) Receive() (*btcjson.AnalyzeTransactionResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as an analyzetransaction result object.
+	var result btcjson.AnalyzeTransactionResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// AnalyzeTransactionAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See AnalyzeTransaction for the blocking version and more details.
+func (c *Client) AnalyzeTransactionAsync(tx *wire.MsgTx) FutureAnalyzeTransactionResult {
+	// Serialize the transaction and convert to hex string.
+	buf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
+	if err := tx.Serialize(buf); err != nil {
+		return newFutureError(err)
+	}
+
+	cmd := btcjson.NewAnalyzeTransactionCmd(hex.EncodeToString(buf.Bytes()))
+	return c.sendCmd(cmd)
+}
+
+// AnalyzeTransaction returns every standardness rule of the memory pool policy
+// of the server the passed transaction violates, without submitting it.
+func (c *Client) AnalyzeTransaction(tx *wire.MsgTx) (*btcjson.AnalyzeTransactionResult, error) {
+	return c.AnalyzeTransactionAsync(tx).Receive()
+}
+
 // FutureSignRawTransactionResult is a future promise to deliver the result
 // of one of the SignRawTransactionAsync family of RPC invocations (or an
 // applicable error).
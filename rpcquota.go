@@ -0,0 +1,292 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gcash/bchd/btcjson"
+)
+
+const (
+	// rpcQuotaPruneInterval is the interval at which the state of clients
+	// which are back within all of their limits is dropped.
+	rpcQuotaPruneInterval = time.Minute
+
+	// rpcResponseBudgetPeriod is the period the response budget is
+	// replenished over.
+	rpcResponseBudgetPeriod = time.Minute
+)
+
+var (
+	// errRPCRateLimited is returned when a client exceeds its request rate.
+	errRPCRateLimited = errors.New("request rate limit exceeded")
+
+	// errRPCTooManyRequests is returned when a client already has the
+	// maximum number of requests in progress.
+	errRPCTooManyRequests = errors.New("too many concurrent requests")
+
+	// errRPCResponseBudget is returned when a client exhausted its budget
+	// of response bytes.
+	errRPCResponseBudget = errors.New("response size budget exhausted")
+)
+
+// rpcQuotaConfig describes the limits of the requests of a single client of
+// the RPC servers.  Zero values disable the respective limit.
+type rpcQuotaConfig struct {
+	// Rate is the number of requests per second a client may make.
+	Rate float64
+
+	// Burst is the number of requests a client may make at once above the
+	// rate.
+	Burst int
+
+	// MaxConcurrent is the number of requests of a client which may be in
+	// progress at once.
+	MaxConcurrent int
+
+	// ResponseBudget is the number of response bytes a client may receive
+	// per minute.  A client which exceeds it may not make requests until
+	// the budget is replenished.
+	ResponseBudget float64
+}
+
+// tokenBucket is a bucket of tokens which is refilled at a constant rate up to
+// its capacity.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens accrued since the last refill.
+func (b *tokenBucket) refill(now time.Time, rate, capacity float64) {
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+	b.last = now
+}
+
+// rpcQuotaClient houses the usage of a single client.
+type rpcQuotaClient struct {
+	requests  tokenBucket
+	responses tokenBucket
+	inFlight  int
+}
+
+// rpcQuota enforces the limits of an rpcQuotaConfig on each client, which are
+// identified by arbitrary keys such as their address or their username.
+type rpcQuota struct {
+	mtx       sync.Mutex
+	cfg       rpcQuotaConfig
+	clients   map[string]*rpcQuotaClient
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// newRPCQuota returns a quota enforcing the passed limits.  It returns nil when
+// none of the limits are enabled, which is a valid quota that allows every
+// request.
+func newRPCQuota(cfg rpcQuotaConfig) *rpcQuota {
+	if cfg.Rate <= 0 && cfg.MaxConcurrent <= 0 && cfg.ResponseBudget <= 0 {
+		return nil
+	}
+	if cfg.Burst < 1 {
+		cfg.Burst = 1
+	}
+	return &rpcQuota{
+		cfg:       cfg,
+		clients:   make(map[string]*rpcQuotaClient),
+		lastPrune: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// responseRate returns the number of response bytes per second the budget is
+// replenished at.
+func (q *rpcQuota) responseRate() float64 {
+	return q.cfg.ResponseBudget / rpcResponseBudgetPeriod.Seconds()
+}
+
+// client returns the usage of the client with the passed key, creating it when
+// it is not known yet.
+//
+// This function MUST be called with the quota lock held.
+func (q *rpcQuota) client(key string, now time.Time) *rpcQuotaClient {
+	c, ok := q.clients[key]
+	if !ok {
+		c = &rpcQuotaClient{
+			requests: tokenBucket{
+				tokens: float64(q.cfg.Burst),
+				last:   now,
+			},
+			responses: tokenBucket{
+				tokens: q.cfg.ResponseBudget,
+				last:   now,
+			},
+		}
+		q.clients[key] = c
+	}
+	c.requests.refill(now, q.cfg.Rate, float64(q.cfg.Burst))
+	c.responses.refill(now, q.responseRate(), q.cfg.ResponseBudget)
+	return c
+}
+
+// prune drops the usage of the clients which have no requests in progress and
+// whose buckets are full again, since they are indistinguishable from new
+// clients.
+//
+// This function MUST be called with the quota lock held.
+func (q *rpcQuota) prune(now time.Time) {
+	if now.Sub(q.lastPrune) < rpcQuotaPruneInterval {
+		return
+	}
+	q.lastPrune = now
+	for key, c := range q.clients {
+		c.requests.refill(now, q.cfg.Rate, float64(q.cfg.Burst))
+		c.responses.refill(now, q.responseRate(), q.cfg.ResponseBudget)
+		if c.inFlight == 0 && c.requests.tokens >= float64(q.cfg.Burst) &&
+			c.responses.tokens >= q.cfg.ResponseBudget {
+
+			delete(q.clients, key)
+		}
+	}
+}
+
+// Acquire reserves a request of the client with the passed key.  An error is
+// returned when the client exceeds any of its limits.  Otherwise the returned
+// function must be called once the request is processed.
+//
+// This function is safe for concurrent access.
+func (q *rpcQuota) Acquire(key string) (func(), error) {
+	if q == nil {
+		return func() {}, nil
+	}
+
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	now := q.now()
+	q.prune(now)
+	c := q.client(key, now)
+	if q.cfg.ResponseBudget > 0 && c.responses.tokens <= 0 {
+		return nil, errRPCResponseBudget
+	}
+	if q.cfg.MaxConcurrent > 0 && c.inFlight >= q.cfg.MaxConcurrent {
+		return nil, errRPCTooManyRequests
+	}
+	if q.cfg.Rate > 0 {
+		if c.requests.tokens < 1 {
+			return nil, errRPCRateLimited
+		}
+		c.requests.tokens--
+	}
+
+	c.inFlight++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mtx.Lock()
+			c.inFlight--
+			q.mtx.Unlock()
+		})
+	}, nil
+}
+
+// ChargeResponse deducts the passed number of response bytes from the budget
+// of the client with the passed key.  The budget may become negative, which
+// rejects the requests of the client until it is replenished.
+//
+// This function is safe for concurrent access.
+func (q *rpcQuota) ChargeResponse(key string, n int) {
+	if q == nil || q.cfg.ResponseBudget <= 0 {
+		return
+	}
+
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	c := q.client(key, q.now())
+	c.responses.tokens -= float64(n)
+}
+
+// rpcQuotaHost returns the host of the passed remote address so that all of
+// the connections of a client share its quota.
+func rpcQuotaHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// rpcQuotas are the quotas of the clients of an RPC server, which are enforced
+// both per client address, so that a client may not evade them by opening
+// more connections, and per user across all of its addresses.
+type rpcQuotas struct {
+	addr *rpcQuota
+	user *rpcQuota
+}
+
+// Acquire reserves a request of the client with the passed remote address and
+// user from both quotas.  The user quota is not enforced when the user is
+// empty.  An error is returned when the client exceeds any of its limits.
+// Otherwise the returned function must be called once the request is
+// processed.
+//
+// This function is safe for concurrent access.
+func (q *rpcQuotas) Acquire(remoteAddr, user string) (func(), error) {
+	releaseAddr, err := q.addr.Acquire(rpcQuotaHost(remoteAddr))
+	if err != nil {
+		return nil, err
+	}
+	if user == "" {
+		return releaseAddr, nil
+	}
+	releaseUser, err := q.user.Acquire(user)
+	if err != nil {
+		releaseAddr()
+		return nil, err
+	}
+	return func() {
+		releaseUser()
+		releaseAddr()
+	}, nil
+}
+
+// ChargeResponse deducts the passed number of response bytes from the budgets
+// of the client with the passed remote address and user.
+//
+// This function is safe for concurrent access.
+func (q *rpcQuotas) ChargeResponse(remoteAddr, user string, n int) {
+	q.addr.ChargeResponse(rpcQuotaHost(remoteAddr), n)
+	if user != "" {
+		q.user.ChargeResponse(user, n)
+	}
+}
+
+// newRPCQuotas returns the quotas of the clients of an RPC server with the
+// passed limits per client address and per user.  The rates are in requests
+// per second, and the response budget is in KiB per minute.
+func newRPCQuotas(rate, userRate float64, burst, maxConcurrent int,
+	responseBudget uint64) rpcQuotas {
+
+	cfg := rpcQuotaConfig{
+		Rate:           rate,
+		Burst:          burst,
+		MaxConcurrent:  maxConcurrent,
+		ResponseBudget: float64(responseBudget * 1024),
+	}
+	userCfg := cfg
+	userCfg.Rate = userRate
+	return rpcQuotas{
+		addr: newRPCQuota(cfg),
+		user: newRPCQuota(userCfg),
+	}
+}
+
+// rpcQuotaError returns the JSON-RPC error for the passed quota error.
+func rpcQuotaError(err error) *btcjson.RPCError {
+	return btcjson.NewRPCError(btcjson.ErrRPCMisc, err.Error())
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRPCQuota ensures the request rate, concurrent requests and response
+// budget of each client are limited independently of the other clients.
+func TestRPCQuota(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	q := newRPCQuota(rpcQuotaConfig{
+		Rate:           1,
+		Burst:          2,
+		MaxConcurrent:  2,
+		ResponseBudget: 600,
+	})
+	q.now = func() time.Time { return now }
+	q.lastPrune = now
+
+	// The burst allows two requests at once, which are also the maximum
+	// number of concurrent requests.
+	release1, err := q.Acquire("a")
+	if err != nil {
+		t.Fatalf("Acquire: unexpected error: %v", err)
+	}
+	release2, err := q.Acquire("a")
+	if err != nil {
+		t.Fatalf("Acquire: unexpected error: %v", err)
+	}
+	if _, err := q.Acquire("a"); err != errRPCTooManyRequests {
+		t.Fatalf("Acquire: unexpected error -- got %v, want %v", err,
+			errRPCTooManyRequests)
+	}
+	release1()
+	release1()
+	if _, err := q.Acquire("a"); err != errRPCRateLimited {
+		t.Fatalf("Acquire: unexpected error -- got %v, want %v", err,
+			errRPCRateLimited)
+	}
+
+	// Other clients have their own limits.
+	release3, err := q.Acquire("b")
+	if err != nil {
+		t.Fatalf("Acquire: unexpected error: %v", err)
+	}
+	release3()
+
+	// The rate replenishes the requests.
+	now = now.Add(time.Second)
+	release4, err := q.Acquire("a")
+	if err != nil {
+		t.Fatalf("Acquire: unexpected error: %v", err)
+	}
+	release4()
+	release2()
+
+	// Exhausting the response budget rejects requests until a part of it
+	// is replenished.
+	now = now.Add(10 * time.Second)
+	q.ChargeResponse("a", 700)
+	if _, err := q.Acquire("a"); err != errRPCResponseBudget {
+		t.Fatalf("Acquire: unexpected error -- got %v, want %v", err,
+			errRPCResponseBudget)
+	}
+	now = now.Add(11 * time.Second)
+	release5, err := q.Acquire("a")
+	if err != nil {
+		t.Fatalf("Acquire: unexpected error: %v", err)
+	}
+	release5()
+
+	// Clients which are back within all of their limits are pruned.
+	now = now.Add(2 * rpcQuotaPruneInterval)
+	q.ChargeResponse("c", 1)
+	if _, err := q.Acquire("d"); err != nil {
+		t.Fatalf("Acquire: unexpected error: %v", err)
+	}
+	if _, ok := q.clients["a"]; ok {
+		t.Fatal("client a was not pruned")
+	}
+	if _, ok := q.clients["c"]; !ok {
+		t.Fatal("client c was pruned")
+	}
+}
+
+// TestRPCQuotas ensures requests are limited both per client address and per
+// user, and that no limits are enforced when none are configured.
+func TestRPCQuotas(t *testing.T) {
+	quotas := newRPCQuotas(0, 0, 20, 0, 0)
+	if quotas.addr != nil || quotas.user != nil {
+		t.Fatal("quotas without limits are enforced")
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := quotas.Acquire("127.0.0.1:1234", "user"); err != nil {
+			t.Fatalf("Acquire: unexpected error: %v", err)
+		}
+	}
+
+	quotas = newRPCQuotas(0, 0, 20, 1, 0)
+	release, err := quotas.Acquire("127.0.0.1:1234", "alice")
+	if err != nil {
+		t.Fatalf("Acquire: unexpected error: %v", err)
+	}
+
+	// Another connection of the same address shares its quota.
+	if _, err := quotas.Acquire("127.0.0.1:5678", "bob"); err != errRPCTooManyRequests {
+		t.Fatalf("Acquire: unexpected error -- got %v, want %v", err,
+			errRPCTooManyRequests)
+	}
+
+	// The same user from another address shares its quota, which must not
+	// leave a request of the address in progress.
+	if _, err := quotas.Acquire("127.0.0.2:1234", "alice"); err != errRPCTooManyRequests {
+		t.Fatalf("Acquire: unexpected error -- got %v, want %v", err,
+			errRPCTooManyRequests)
+	}
+	release2, err := quotas.Acquire("127.0.0.2:1234", "bob")
+	if err != nil {
+		t.Fatalf("Acquire: unexpected error: %v", err)
+	}
+	release2()
+	release()
+}
@@ -0,0 +1,543 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gcash/bchd/btcjson"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+const (
+	// maxRestHeadersCount is the maximum number of headers which may be
+	// requested from the /rest/headers endpoint.
+	maxRestHeadersCount = 2000
+
+	// maxRestGetUtxosOutpoints is the maximum number of outpoints which may
+	// be queried with a single /rest/getutxos request.
+	maxRestGetUtxosOutpoints = 15
+
+	// restMempoolHeight is the height reported by /rest/getutxos for
+	// outputs of transactions in the memory pool.
+	restMempoolHeight = 0x7fffffff
+)
+
+// restFormat is the response format requested by the extension of the last
+// path component of a REST request.
+type restFormat int
+
+const (
+	restFormatBinary restFormat = iota
+	restFormatHex
+	restFormatJSON
+)
+
+// restFormatExtensions maps the supported path extensions to their response
+// formats.
+var restFormatExtensions = map[string]restFormat{
+	"bin":  restFormatBinary,
+	"hex":  restFormatHex,
+	"json": restFormatJSON,
+}
+
+// restError is an error which is returned to the REST client along with its
+// HTTP status code.
+type restError struct {
+	status  int
+	message string
+}
+
+// Error satisfies the error interface.
+func (e *restError) Error() string {
+	return e.message
+}
+
+// newRestError returns a REST error with the passed status and message.
+func newRestError(status int, format string, args ...interface{}) *restError {
+	return &restError{status: status, message: fmt.Sprintf(format, args...)}
+}
+
+// restErrorFromRPC converts an error returned by an RPC handler to a REST
+// error with an appropriate HTTP status code.
+func restErrorFromRPC(err error) *restError {
+	var rpcErr *btcjson.RPCError
+	if !errors.As(err, &rpcErr) {
+		return newRestError(http.StatusInternalServerError, "%v", err)
+	}
+	switch rpcErr.Code {
+	// ErrRPCNoTxInfo shares its code with ErrRPCBlockNotFound.
+	case btcjson.ErrRPCNoTxInfo:
+		return newRestError(http.StatusNotFound, "%s", rpcErr.Message)
+	case btcjson.ErrRPCDecodeHexString, btcjson.ErrRPCInvalidParameter:
+		return newRestError(http.StatusBadRequest, "%s", rpcErr.Message)
+	default:
+		return newRestError(http.StatusInternalServerError, "%s",
+			rpcErr.Message)
+	}
+}
+
+// splitRestFormat splits the format extension off the passed path component
+// and returns the remaining part along with the requested format.
+func splitRestFormat(param string) (string, restFormat, error) {
+	idx := strings.LastIndexByte(param, '.')
+	if idx == -1 {
+		return "", 0, newRestError(http.StatusNotFound,
+			"output format not found (available: .bin, .hex, .json)")
+	}
+	format, ok := restFormatExtensions[param[idx+1:]]
+	if !ok {
+		return "", 0, newRestError(http.StatusNotFound,
+			"output format not found (available: .bin, .hex, .json)")
+	}
+	return param[:idx], format, nil
+}
+
+// parseRestHash parses a hash passed as a REST path component.
+func parseRestHash(str string) (*chainhash.Hash, error) {
+	if len(str) != chainhash.MaxHashStringSize {
+		return nil, newRestError(http.StatusBadRequest, "invalid hash: %s",
+			str)
+	}
+	hash, err := chainhash.NewHashFromStr(str)
+	if err != nil {
+		return nil, newRestError(http.StatusBadRequest, "invalid hash: %s",
+			str)
+	}
+	return hash, nil
+}
+
+// parseRestOutPoint parses an outpoint passed as a REST path component in the
+// <txid>-<n> format.
+func parseRestOutPoint(str string) (*wire.OutPoint, error) {
+	idx := strings.IndexByte(str, '-')
+	if idx == -1 {
+		return nil, newRestError(http.StatusBadRequest,
+			"parse error: invalid outpoint %s", str)
+	}
+	hash, err := parseRestHash(str[:idx])
+	if err != nil {
+		return nil, err
+	}
+	index, err := strconv.ParseUint(str[idx+1:], 10, 32)
+	if err != nil {
+		return nil, newRestError(http.StatusBadRequest,
+			"parse error: invalid outpoint %s", str)
+	}
+	return wire.NewOutPoint(hash, uint32(index)), nil
+}
+
+// restUtxo describes an unspent output in the JSON response of the
+// /rest/getutxos endpoint.
+type restUtxo struct {
+	Height       int32                      `json:"height"`
+	Value        float64                    `json:"value"`
+	ScriptPubKey btcjson.ScriptPubKeyResult `json:"scriptPubKey"`
+}
+
+// restGetUtxosResult is the JSON response of the /rest/getutxos endpoint.
+type restGetUtxosResult struct {
+	ChainHeight  int32      `json:"chainHeight"`
+	ChainTipHash string     `json:"chaintipHash"`
+	Bitmap       string     `json:"bitmap"`
+	Utxos        []restUtxo `json:"utxos"`
+}
+
+// restUtxoEntry is an unspent output found by the /rest/getutxos endpoint.
+type restUtxoEntry struct {
+	height int32
+	txOut  *wire.TxOut
+}
+
+// restHandler serves the unauthenticated REST interface.  The endpoints and
+// response formats mirror the REST interface of Bitcoin Core.
+func (s *rpcServer) restHandler(w http.ResponseWriter, r *http.Request) {
+	// Limit the number of connections to max allowed.
+	if s.limitConnections(w, r.RemoteAddr) {
+		return
+	}
+
+	// Keep track of the number of connected clients.
+	s.incrementClients()
+	defer s.decrementClients()
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Stop the request when the client disconnects or the server is
+	// shutting down.
+	ctx, cancel := s.requestContext(r.Context())
+	defer cancel()
+
+	path := strings.TrimPrefix(r.URL.Path, "/rest/")
+	parts := strings.Split(path, "/")
+
+	var (
+		resp   interface{}
+		format restFormat
+		err    error
+	)
+	switch parts[0] {
+	case "tx":
+		resp, format, err = s.restTx(ctx, parts[1:])
+	case "block":
+		resp, format, err = s.restBlock(ctx, parts[1:])
+	case "headers":
+		resp, format, err = s.restHeaders(ctx, parts[1:], r.URL.Query().Get("count"))
+	case "getutxos":
+		resp, format, err = s.restGetUtxos(parts[1:])
+	default:
+		if strings.HasPrefix(path, "chaininfo.") {
+			resp, format, err = s.restChainInfo(ctx, path)
+			break
+		}
+		err = newRestError(http.StatusNotFound, "not found")
+	}
+	if err != nil {
+		var restErr *restError
+		if !errors.As(err, &restErr) {
+			restErr = restErrorFromRPC(err)
+		}
+		http.Error(w, restErr.message, restErr.status)
+		return
+	}
+
+	switch format {
+	case restFormatBinary:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(resp.([]byte))
+	case restFormatHex:
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "%x\n", resp.([]byte))
+	case restFormatJSON:
+		marshalled, err := json.Marshal(resp)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(marshalled)
+		w.Write([]byte{'\n'})
+	}
+}
+
+// rawFromHexResult decodes the hex encoded result of a non-verbose RPC
+// handler.
+func rawFromHexResult(result interface{}) ([]byte, error) {
+	hexStr, ok := result.(string)
+	if !ok {
+		return nil, newRestError(http.StatusInternalServerError,
+			"unexpected result type %T", result)
+	}
+	return hex.DecodeString(hexStr)
+}
+
+// restTx serves the /rest/tx/<txid>.<format> endpoint.
+func (s *rpcServer) restTx(ctx context.Context, params []string) (interface{}, restFormat, error) {
+	if len(params) != 1 {
+		return nil, 0, newRestError(http.StatusBadRequest,
+			"invalid URI format. Expected /rest/tx/<txid>.<bin|hex|json>")
+	}
+	hashStr, format, err := splitRestFormat(params[0])
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := parseRestHash(hashStr); err != nil {
+		return nil, 0, err
+	}
+
+	verbose := btcjson.VerboseLevel(0)
+	if format == restFormatJSON {
+		verbose = 1
+	}
+	cmd := &btcjson.GetRawTransactionCmd{Txid: hashStr, Verbose: &verbose}
+	result, err := handleGetRawTransaction(s, cmd, ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	if format == restFormatJSON {
+		return result, format, nil
+	}
+	raw, err := rawFromHexResult(result)
+	return raw, format, err
+}
+
+// restBlock serves the /rest/block/<hash>.<format> and
+// /rest/block/notxdetails/<hash>.<format> endpoints.
+func (s *rpcServer) restBlock(ctx context.Context, params []string) (interface{}, restFormat, error) {
+	txDetails := true
+	if len(params) == 2 && params[0] == "notxdetails" {
+		txDetails = false
+		params = params[1:]
+	}
+	if len(params) != 1 {
+		return nil, 0, newRestError(http.StatusBadRequest,
+			"invalid URI format. Expected /rest/block/<hash>.<bin|hex|json>")
+	}
+	hashStr, format, err := splitRestFormat(params[0])
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := parseRestHash(hashStr); err != nil {
+		return nil, 0, err
+	}
+
+	verbosity := btcjson.VerbosityLevel(0)
+	if format == restFormatJSON {
+		verbosity = 1
+		if txDetails {
+			verbosity = 2
+		}
+	}
+	cmd := &btcjson.GetBlockCmd{Hash: hashStr, Verbosity: &verbosity}
+	result, err := handleGetBlock(s, cmd, ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	if format == restFormatJSON {
+		return result, format, nil
+	}
+	raw, err := rawFromHexResult(result)
+	return raw, format, err
+}
+
+// restHeaders serves the /rest/headers/<count>/<hash>.<format> endpoint as well
+// as the /rest/headers/<hash>.<format>?count=<count> form.  Up to count headers
+// of the main chain are returned starting with the passed block.
+func (s *rpcServer) restHeaders(ctx context.Context, params []string, countParam string) (interface{}, restFormat, error) {
+	var countStr string
+	switch len(params) {
+	case 1:
+		countStr = countParam
+		if countStr == "" {
+			countStr = "5"
+		}
+	case 2:
+		countStr = params[0]
+		params = params[1:]
+	default:
+		return nil, 0, newRestError(http.StatusBadRequest,
+			"invalid URI format. Expected /rest/headers/<count>/<hash>.<bin|hex|json>")
+	}
+	hashStr, format, err := splitRestFormat(params[0])
+	if err != nil {
+		return nil, 0, err
+	}
+	hash, err := parseRestHash(hashStr)
+	if err != nil {
+		return nil, 0, err
+	}
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count < 1 || count > maxRestHeadersCount {
+		return nil, 0, newRestError(http.StatusBadRequest,
+			"header count is invalid or out of acceptable range "+
+				"(1-%d): %s", maxRestHeadersCount, countStr)
+	}
+
+	// Collect the hashes of the requested headers.  A block which is not
+	// part of the main chain only returns its own header.
+	hashes := []chainhash.Hash{*hash}
+	if height, err := s.cfg.Chain.BlockHeightByHash(hash); err == nil {
+		hashes, err = s.cfg.Chain.HeightRange(height, height+int32(count))
+		if err != nil {
+			return nil, 0, err
+		}
+	} else if _, err := s.cfg.Chain.HeaderByHash(hash); err != nil {
+		hashes = nil
+	}
+
+	if format == restFormatJSON {
+		headers := make([]interface{}, 0, len(hashes))
+		verbose := true
+		for i := range hashes {
+			cmd := &btcjson.GetBlockHeaderCmd{
+				Hash:    hashes[i].String(),
+				Verbose: &verbose,
+			}
+			header, err := handleGetBlockHeader(s, cmd, ctx)
+			if err != nil {
+				return nil, 0, err
+			}
+			headers = append(headers, header)
+		}
+		return headers, format, nil
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(hashes) * wire.MaxBlockHeaderPayload)
+	for i := range hashes {
+		header, err := s.cfg.Chain.HeaderByHash(&hashes[i])
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := header.Serialize(&buf); err != nil {
+			return nil, 0, err
+		}
+	}
+	return buf.Bytes(), format, nil
+}
+
+// restChainInfo serves the /rest/chaininfo.json endpoint.
+func (s *rpcServer) restChainInfo(ctx context.Context, param string) (interface{}, restFormat, error) {
+	_, format, err := splitRestFormat(param)
+	if err != nil {
+		return nil, 0, err
+	}
+	if format != restFormatJSON {
+		return nil, 0, newRestError(http.StatusNotFound,
+			"output format not found (available: json)")
+	}
+	result, err := handleGetBlockChainInfo(s, nil, ctx)
+	return result, format, err
+}
+
+// restGetUtxos serves the /rest/getutxos[/checkmempool]/<txid>-<n>/...<format>
+// endpoint.  It reports which of the passed outpoints are unspent along with
+// the outputs they reference.  When checkmempool is given, outputs spent by
+// transactions in the memory pool are reported as spent and outputs of memory
+// pool transactions as unspent.
+func (s *rpcServer) restGetUtxos(params []string) (interface{}, restFormat, error) {
+	checkMempool := len(params) > 0 && params[0] == "checkmempool"
+	if checkMempool {
+		params = params[1:]
+	}
+	if len(params) == 0 {
+		return nil, 0, newRestError(http.StatusBadRequest,
+			"error: empty request")
+	}
+	last, format, err := splitRestFormat(params[len(params)-1])
+	if err != nil {
+		return nil, 0, err
+	}
+	params[len(params)-1] = last
+	if len(params) > maxRestGetUtxosOutpoints {
+		return nil, 0, newRestError(http.StatusBadRequest,
+			"error: max outpoints exceeded (max: %d, tried: %d)",
+			maxRestGetUtxosOutpoints, len(params))
+	}
+	outpoints := make([]*wire.OutPoint, 0, len(params))
+	for _, param := range params {
+		outpoint, err := parseRestOutPoint(param)
+		if err != nil {
+			return nil, 0, err
+		}
+		outpoints = append(outpoints, outpoint)
+	}
+
+	best := s.cfg.Chain.BestSnapshot()
+	bitmap := make([]byte, (len(outpoints)+7)/8)
+	bitmapStr := make([]byte, len(outpoints))
+	var utxos []restUtxoEntry
+	for i, outpoint := range outpoints {
+		bitmapStr[i] = '0'
+		entry, err := s.fetchRestUtxo(outpoint, checkMempool)
+		if err != nil {
+			return nil, 0, err
+		}
+		if entry == nil {
+			continue
+		}
+		bitmap[i/8] |= 1 << (uint(i) % 8)
+		bitmapStr[i] = '1'
+		utxos = append(utxos, *entry)
+	}
+
+	if format == restFormatJSON {
+		result := &restGetUtxosResult{
+			ChainHeight:  best.Height,
+			ChainTipHash: best.Hash.String(),
+			Bitmap:       string(bitmapStr),
+			Utxos:        make([]restUtxo, 0, len(utxos)),
+		}
+		for _, utxo := range utxos {
+			pkScript := utxo.txOut.PkScript
+			disbuf, _ := txscript.DisasmString(pkScript)
+			scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(
+				pkScript, s.cfg.ChainParams)
+			addresses := make([]string, len(addrs))
+			for i, addr := range addrs {
+				addresses[i] = addr.EncodeAddress()
+			}
+			result.Utxos = append(result.Utxos, restUtxo{
+				Height: utxo.height,
+				Value:  bchutil.Amount(utxo.txOut.Value).ToBCH(),
+				ScriptPubKey: btcjson.ScriptPubKeyResult{
+					Asm:       disbuf,
+					Hex:       hex.EncodeToString(pkScript),
+					ReqSigs:   int32(reqSigs),
+					Type:      scriptClass.String(),
+					Addresses: addresses,
+				},
+			})
+		}
+		return result, format, nil
+	}
+
+	// The binary format consists of the chain height, the chain tip hash,
+	// the bitmap as a variable length byte array and the list of unspent
+	// outputs, each serialized as a dummy version, its height and the
+	// output itself.
+	var buf bytes.Buffer
+	var scratch [4]byte
+	binary.LittleEndian.PutUint32(scratch[:], uint32(best.Height))
+	buf.Write(scratch[:])
+	buf.Write(best.Hash[:])
+	if err := wire.WriteVarBytes(&buf, 0, bitmap); err != nil {
+		return nil, 0, err
+	}
+	if err := wire.WriteVarInt(&buf, 0, uint64(len(utxos))); err != nil {
+		return nil, 0, err
+	}
+	for _, utxo := range utxos {
+		binary.LittleEndian.PutUint32(scratch[:], 0)
+		buf.Write(scratch[:])
+		binary.LittleEndian.PutUint32(scratch[:], uint32(utxo.height))
+		buf.Write(scratch[:])
+		if err := wire.WriteTxOut(&buf, 0, 0, utxo.txOut); err != nil {
+			return nil, 0, err
+		}
+	}
+	return buf.Bytes(), format, nil
+}
+
+// fetchRestUtxo returns the unspent output referenced by the passed outpoint
+// or nil when it does not exist or is spent.
+func (s *rpcServer) fetchRestUtxo(outpoint *wire.OutPoint, checkMempool bool) (*restUtxoEntry, error) {
+	if checkMempool && s.cfg.TxMemPool.CheckSpend(*outpoint) != nil {
+		return nil, nil
+	}
+
+	entry, err := s.cfg.Chain.FetchUtxoEntry(*outpoint)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil && !entry.IsSpent() {
+		txOut := wire.NewTxOut(entry.Amount(), entry.PkScript(),
+			entry.TokenData())
+		return &restUtxoEntry{height: entry.BlockHeight(), txOut: txOut}, nil
+	}
+
+	if !checkMempool {
+		return nil, nil
+	}
+	tx, err := s.cfg.TxMemPool.FetchTransaction(&outpoint.Hash)
+	if err != nil || outpoint.Index >= uint32(len(tx.MsgTx().TxOut)) {
+		return nil, nil
+	}
+	return &restUtxoEntry{
+		height: restMempoolHeight,
+		txOut:  tx.MsgTx().TxOut[outpoint.Index],
+	}, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+)
+
+// TestSplitRestFormat ensures the format extension of REST path components is
+// parsed as expected.
+func TestSplitRestFormat(t *testing.T) {
+	tests := []struct {
+		param  string
+		rest   string
+		format restFormat
+		err    bool
+	}{
+		{param: "abcd.bin", rest: "abcd", format: restFormatBinary},
+		{param: "abcd.hex", rest: "abcd", format: restFormatHex},
+		{param: "chaininfo.json", rest: "chaininfo", format: restFormatJSON},
+		{param: "abcd", err: true},
+		{param: "abcd.xml", err: true},
+	}
+
+	for _, test := range tests {
+		rest, format, err := splitRestFormat(test.param)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected error", test.param)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.param, err)
+			continue
+		}
+		if rest != test.rest || format != test.format {
+			t.Errorf("%s: got %s, %d - want %s, %d", test.param, rest,
+				format, test.rest, test.format)
+		}
+	}
+}
+
+// TestParseRestOutPoint ensures outpoints passed to the getutxos REST endpoint
+// are parsed as expected.
+func TestParseRestOutPoint(t *testing.T) {
+	const txid = "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"
+	tests := []struct {
+		param string
+		index uint32
+		err   bool
+	}{
+		{param: txid + "-0", index: 0},
+		{param: txid + "-4294967295", index: 4294967295},
+		{param: txid + "-4294967296", err: true},
+		{param: txid, err: true},
+		{param: txid[1:] + "-0", err: true},
+		{param: txid + "--1", err: true},
+	}
+
+	for _, test := range tests {
+		op, err := parseRestOutPoint(test.param)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected error", test.param)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.param, err)
+			continue
+		}
+		if op.Hash.String() != txid || op.Index != test.index {
+			t.Errorf("%s: unexpected outpoint %v", test.param, op)
+		}
+	}
+}
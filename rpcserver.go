@@ -6,21 +6,22 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
-	"crypto/sha256"
-	"crypto/subtle"
+	"context"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"math"
 	"math/big"
 	"math/rand"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -28,6 +29,7 @@ import (
 	"time"
 
 	"github.com/gcash/bchd/addrmgr"
+	"github.com/gcash/bchd/banmgr"
 
 	"github.com/btcsuite/websocket"
 	"github.com/gcash/bchd/bchec"
@@ -36,7 +38,9 @@ import (
 	"github.com/gcash/bchd/btcjson"
 	"github.com/gcash/bchd/chaincfg"
 	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/connmgr"
 	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/firstseen"
 	"github.com/gcash/bchd/mempool"
 	"github.com/gcash/bchd/mining"
 	"github.com/gcash/bchd/mining/cpuminer"
@@ -74,6 +78,11 @@ const (
 
 	// maxProtocolVersion is the max protocol version the server supports.
 	maxProtocolVersion = wire.ProtocolVersion
+
+	// rejectedBlocksDirName is the name of the directory within the data
+	// directory blocks rejected by the submitblock RPC are saved to when
+	// the saverejectedblocks option is set.
+	rejectedBlocksDirName = "rejectedblocks"
 )
 
 var (
@@ -122,64 +131,84 @@ var (
 	}
 )
 
-type commandHandler func(*rpcServer, interface{}, <-chan bool) (interface{}, error)
+type commandHandler func(*rpcServer, interface{}, context.Context) (interface{}, error)
 
 // rpcHandlers maps RPC command strings to appropriate handler functions.
 // This is set by init because help references rpcHandlers and thus causes
 // a dependency loop.
 var rpcHandlers map[string]commandHandler
 var rpcHandlersBeforeInit = map[string]commandHandler{
-	"addnode":               handleAddNode,
-	"createrawtransaction":  handleCreateRawTransaction,
-	"debuglevel":            handleDebugLevel,
-	"decoderawtransaction":  handleDecodeRawTransaction,
-	"decodescript":          handleDecodeScript,
-	"estimatefee":           handleEstimateFee,
-	"generate":              handleGenerate,
-	"getaddednodeinfo":      handleGetAddedNodeInfo,
-	"getbestblock":          handleGetBestBlock,
-	"getbestblockhash":      handleGetBestBlockHash,
-	"getblock":              handleGetBlock,
-	"getblockchaininfo":     handleGetBlockChainInfo,
-	"getblockcount":         handleGetBlockCount,
-	"getblockhash":          handleGetBlockHash,
-	"getblockheader":        handleGetBlockHeader,
-	"getblocktemplate":      handleGetBlockTemplate,
-	"getcfilter":            handleGetCFilter,
-	"getcfilterheader":      handleGetCFilterHeader,
-	"getconnectioncount":    handleGetConnectionCount,
-	"getcurrentnet":         handleGetCurrentNet,
-	"getdifficulty":         handleGetDifficulty,
-	"getgenerate":           handleGetGenerate,
-	"gethashespersec":       handleGetHashesPerSec,
-	"getheaders":            handleGetHeaders,
-	"getinfo":               handleGetInfo,
-	"getmempoolinfo":        handleGetMempoolInfo,
-	"getmininginfo":         handleGetMiningInfo,
-	"getnettotals":          handleGetNetTotals,
-	"getnetworkhashps":      handleGetNetworkHashPS,
-	"getnetworkinfo":        handleGetNetworkInfo,
-	"getpeerinfo":           handleGetPeerInfo,
-	"getrawmempool":         handleGetRawMempool,
-	"getrawtransaction":     handleGetRawTransaction,
-	"gettxout":              handleGetTxOut,
-	"gettxoutproof":         handleGetTxOutProof,
-	"help":                  handleHelp,
-	"invalidateblock":       handleInvalidateBlock,
-	"node":                  handleNode,
-	"ping":                  handlePing,
-	"reconsiderblock":       handleReconsiderBlock,
-	"searchrawtransactions": handleSearchRawTransactions,
-	"sendrawtransaction":    handleSendRawTransaction,
-	"setgenerate":           handleSetGenerate,
-	"stop":                  handleStop,
-	"submitblock":           handleSubmitBlock,
-	"uptime":                handleUptime,
-	"validateaddress":       handleValidateAddress,
-	"verifychain":           handleVerifyChain,
-	"verifymessage":         handleVerifyMessage,
-	"verifytxoutproof":      handleVerifyTxOutProof,
-	"version":               handleVersion,
+	"addnode":                 handleAddNode,
+	"analyzetransaction":      handleAnalyzeTransaction,
+	"backupchainstate":        handleBackupChainstate,
+	"clearbanned":             handleClearBanned,
+	"compactdatabase":         handleCompactDatabase,
+	"createrawtransaction":    handleCreateRawTransaction,
+	"debuglevel":              handleDebugLevel,
+	"decoderawtransaction":    handleDecodeRawTransaction,
+	"decodescript":            handleDecodeScript,
+	"estimatefee":             handleEstimateFee,
+	"generate":                handleGenerate,
+	"generateblock":           handleGenerateBlock,
+	"generatetoaddress":       handleGenerateToAddress,
+	"getablastate":            handleGetABLAState,
+	"getaddednodeinfo":        handleGetAddedNodeInfo,
+	"getbestblock":            handleGetBestBlock,
+	"getbestblockhash":        handleGetBestBlockHash,
+	"getblock":                handleGetBlock,
+	"getblockchaininfo":       handleGetBlockChainInfo,
+	"getblockcount":           handleGetBlockCount,
+	"getblockhash":            handleGetBlockHash,
+	"getblockheader":          handleGetBlockHeader,
+	"getblockstats":           handleGetBlockStats,
+	"getblockundodata":        handleGetBlockUndoData,
+	"getblocktemplate":        handleGetBlockTemplate,
+	"getcfilter":              handleGetCFilter,
+	"getcfilterheader":        handleGetCFilterHeader,
+	"getchaintips":            handleGetChainTips,
+	"getcheckpointcandidates": handleGetCheckpointCandidates,
+	"getconnectioncount":      handleGetConnectionCount,
+	"getcurrentnet":           handleGetCurrentNet,
+	"getdatabaseinfo":         handleGetDatabaseInfo,
+	"getdifficulty":           handleGetDifficulty,
+	"getfirstseen":            handleGetFirstSeen,
+	"getgenerate":             handleGetGenerate,
+	"gethashespersec":         handleGetHashesPerSec,
+	"getheaders":              handleGetHeaders,
+	"getinfo":                 handleGetInfo,
+	"getmempoolinfo":          handleGetMempoolInfo,
+	"getmininginfo":           handleGetMiningInfo,
+	"getnettotals":            handleGetNetTotals,
+	"getnetworkhashps":        handleGetNetworkHashPS,
+	"getnextblockparams":      handleGetNextBlockParams,
+	"getnetworkinfo":          handleGetNetworkInfo,
+	"getpeerinfo":             handleGetPeerInfo,
+	"getrawmempool":           handleGetRawMempool,
+	"getrawtransaction":       handleGetRawTransaction,
+	"gettxout":                handleGetTxOut,
+	"gettxoutsetinfo":         handleGetTxOutSetInfo,
+	"gettxoutproof":           handleGetTxOutProof,
+	"help":                    handleHelp,
+	"invalidateblock":         handleInvalidateBlock,
+	"listbanned":              handleListBanned,
+	"node":                    handleNode,
+	"ping":                    handlePing,
+	"reconsiderblock":         handleReconsiderBlock,
+	"reloadconfig":            handleReloadConfig,
+	"searchrawtransactions":   handleSearchRawTransactions,
+	"sendrawtransaction":      handleSendRawTransaction,
+	"setban":                  handleSetBan,
+	"setgenerate":             handleSetGenerate,
+	"setrelaypolicy":          handleSetRelayPolicy,
+	"stop":                    handleStop,
+	"submitblock":             handleSubmitBlock,
+	"testmempoolaccept":       handleTestMempoolAccept,
+	"uptime":                  handleUptime,
+	"validateaddress":         handleValidateAddress,
+	"verifychain":             handleVerifyChain,
+	"verifymessage":           handleVerifyMessage,
+	"verifytxoutproof":        handleVerifyTxOutProof,
+	"version":                 handleVersion,
 }
 
 // list of commands that we recognize, but for which bchd has no support because
@@ -202,7 +231,6 @@ var rpcAskWallet = map[string]struct{}{
 	"getreceivedbyaccount":   {},
 	"getreceivedbyaddress":   {},
 	"gettransaction":         {},
-	"gettxoutsetinfo":        {},
 	"getunconfirmedbalance":  {},
 	"getwalletinfo":          {},
 	"importprivkey":          {},
@@ -233,7 +261,6 @@ var rpcAskWallet = map[string]struct{}{
 // Commands that are currently unimplemented, but should ultimately be.
 var rpcUnimplemented = map[string]struct{}{
 	"estimatepriority": {},
-	"getchaintips":     {},
 	"getmempoolentry":  {},
 	"getwork":          {},
 	"preciousblock":    {},
@@ -255,24 +282,31 @@ var rpcLimited = map[string]struct{}{
 	"help": {},
 
 	// HTTP/S-only commands
+	"analyzetransaction":    {},
 	"createrawtransaction":  {},
 	"decoderawtransaction":  {},
 	"decodescript":          {},
 	"estimatefee":           {},
+	"getablastate":          {},
 	"getbestblock":          {},
 	"getbestblockhash":      {},
 	"getblock":              {},
 	"getblockcount":         {},
 	"getblockhash":          {},
 	"getblockheader":        {},
+	"getblockstats":         {},
+	"getblockundodata":      {},
 	"getcfilter":            {},
 	"getcfilterheader":      {},
+	"getchaintips":          {},
 	"getcurrentnet":         {},
 	"getdifficulty":         {},
+	"getfirstseen":          {},
 	"getheaders":            {},
 	"getinfo":               {},
 	"getnettotals":          {},
 	"getnetworkhashps":      {},
+	"getnextblockparams":    {},
 	"getrawmempool":         {},
 	"getrawtransaction":     {},
 	"gettxout":              {},
@@ -280,6 +314,7 @@ var rpcLimited = map[string]struct{}{
 	"searchrawtransactions": {},
 	"sendrawtransaction":    {},
 	"submitblock":           {},
+	"testmempoolaccept":     {},
 	"uptime":                {},
 	"validateaddress":       {},
 	"verifymessage":         {},
@@ -363,19 +398,19 @@ func newGbtWorkState(timeSource blockchain.MedianTimeSource) *gbtWorkState {
 
 // handleUnimplemented is the handler for commands that should ultimately be
 // supported but are not yet implemented.
-func handleUnimplemented(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleUnimplemented(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	return nil, ErrRPCUnimplemented
 }
 
 // handleAskWallet is the handler for commands that are recognized as valid, but
 // are unable to answer correctly since it involves wallet state.
 // These commands will be implemented in bchwallet.
-func handleAskWallet(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleAskWallet(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	return nil, ErrRPCNoWallet
 }
 
 // handleAddNode handles addnode commands.
-func handleAddNode(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleAddNode(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.AddNodeCmd)
 
 	addr := normalizeAddress(c.Addr, s.cfg.ChainParams.DefaultPort)
@@ -406,7 +441,7 @@ func handleAddNode(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (in
 }
 
 // handleNode handles node commands.
-func handleNode(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleNode(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.NodeCmd)
 
 	var addr string
@@ -523,8 +558,165 @@ func messageToHex(msg wire.Message) (string, error) {
 	return hex.EncodeToString(buf.Bytes()), nil
 }
 
+// handleAnalyzeTransaction implements the analyzetransaction command.
+func handleAnalyzeTransaction(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	c := cmd.(*btcjson.AnalyzeTransactionCmd)
+
+	hexStr := c.HexTx
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	serializedTx, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hexStr)
+	}
+	var msgTx wire.MsgTx
+	err = msgTx.Deserialize(bytes.NewReader(serializedTx))
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "TX decode failed: " + err.Error(),
+		}
+	}
+	tx := bchutil.NewTx(&msgTx)
+
+	analysis, err := s.cfg.TxMemPool.AnalyzeTransaction(tx)
+	if err != nil {
+		context := "Failed to analyze transaction"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	result := btcjson.AnalyzeTransactionResult{
+		TxID:       tx.Hash().String(),
+		Standard:   len(analysis.Violations) == 0,
+		Violations: make([]btcjson.PolicyViolationResult, 0, len(analysis.Violations)),
+	}
+	for _, v := range analysis.Violations {
+		violation := btcjson.PolicyViolationResult{
+			Rule:       string(v.Rule),
+			RejectCode: v.RejectCode.String(),
+			Reason:     v.Reason,
+		}
+		if v.Input >= 0 {
+			input := v.Input
+			violation.Input = &input
+		}
+		if v.Output >= 0 {
+			output := v.Output
+			violation.Output = &output
+		}
+		result.Violations = append(result.Violations, violation)
+	}
+	for _, outpoint := range analysis.MissingInputs {
+		result.MissingInputs = append(result.MissingInputs,
+			outpoint.String())
+	}
+
+	return result, nil
+}
+
+// handleBackupChainstate implements the backupchainstate command.
+func handleBackupChainstate(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	c := cmd.(*btcjson.BackupChainstateCmd)
+	if c.Destination == "" {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Destination must not be empty",
+		}
+	}
+	if _, err := os.Stat(c.Destination); !os.IsNotExist(err) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Destination already exists",
+		}
+	}
+
+	// Write the backup to a temporary file first so the destination only
+	// ever holds a complete backup.
+	start := time.Now()
+	f, err := os.CreateTemp(filepath.Dir(c.Destination),
+		filepath.Base(c.Destination)+".tmp")
+	if err != nil {
+		context := "Failed to create backup file"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	tempPath := f.Name()
+
+	bw := bufio.NewWriterSize(f, 1024*1024)
+	archive := database.NewBackupArchiveWriter(bw)
+	err = s.cfg.DB.Backup(archive, *c.IncludeBlocks)
+	if err == nil {
+		err = database.FinishBackupArchive(archive)
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempPath, c.Destination)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return nil, internalRPCError(err.Error(), "Failed to back up chainstate")
+	}
+
+	info, err := os.Stat(c.Destination)
+	if err != nil {
+		context := "Failed to stat backup file"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return &btcjson.BackupChainstateResult{
+		Destination: c.Destination,
+		Size:        info.Size(),
+		Duration:    time.Since(start).Seconds(),
+	}, nil
+}
+
+// handleClearBanned handles clearbanned commands.
+func handleClearBanned(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	if err := s.cfg.BanMgr.ClearBans(); err != nil {
+		return nil, internalRPCError(err.Error(), "Unable to clear bans")
+	}
+
+	// no data returned unless an error.
+	return nil, nil
+}
+
+// handleCompactDatabase implements the compactdatabase command.
+func handleCompactDatabase(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	before, err := s.cfg.DB.SpaceInfo()
+	if err != nil {
+		context := "Failed to fetch database space info"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	start := time.Now()
+	if err := s.cfg.DB.Compact(); err != nil {
+		return nil, internalRPCError(err.Error(), "Failed to compact database")
+	}
+	duration := time.Since(start)
+
+	after, err := s.cfg.DB.SpaceInfo()
+	if err != nil {
+		context := "Failed to fetch database space info"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return &btcjson.CompactDatabaseResult{
+		SizeBefore: before.MetadataSize,
+		SizeAfter:  after.MetadataSize,
+		Reclaimed:  before.MetadataSize - after.MetadataSize,
+		Duration:   duration.Seconds(),
+	}, nil
+}
+
 // handleCreateRawTransaction handles createrawtransaction commands.
-func handleCreateRawTransaction(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleCreateRawTransaction(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.CreateRawTransactionCmd)
 
 	// Validate the locktime, if given.
@@ -683,7 +875,7 @@ func handleCreateRawTransaction(s *rpcServer, cmd interface{}, closeNotifier <-c
 }
 
 // handleDebugLevel handles debuglevel commands.
-func handleDebugLevel(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleDebugLevel(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.DebugLevelCmd)
 
 	// Special show command to list supported subsystems.
@@ -779,6 +971,7 @@ func createVoutList(mtx *wire.MsgTx, chainParams *chaincfg.Params, filterAddrMap
 		vout.ScriptPubKey.Hex = hex.EncodeToString(v.PkScript)
 		vout.ScriptPubKey.Type = scriptClass.String()
 		vout.ScriptPubKey.ReqSigs = int32(reqSigs)
+		vout.TokenData = createTokenDataResult(&v.TokenData)
 
 		voutList = append(voutList, vout)
 	}
@@ -786,6 +979,195 @@ func createVoutList(mtx *wire.MsgTx, chainParams *chaincfg.Params, filterAddrMap
 	return voutList
 }
 
+// createTokenDataResult returns a JSON object for the passed CashToken data or
+// nil when the output does not carry tokens.
+func createTokenDataResult(tokenData *wire.TokenData) *btcjson.TokenDataResult {
+	if tokenData.IsEmpty() {
+		return nil
+	}
+
+	result := &btcjson.TokenDataResult{
+		Category: chainhash.Hash(tokenData.CategoryID).String(),
+		Amount:   strconv.FormatUint(tokenData.Amount, 10),
+	}
+	if tokenData.HasNFT() {
+		capability := "none"
+		switch {
+		case tokenData.IsMutableNFT():
+			capability = "mutable"
+		case tokenData.IsMintingNFT():
+			capability = "minting"
+		}
+		result.NFT = &btcjson.TokenNFTResult{
+			Capability: capability,
+			Commitment: hex.EncodeToString(tokenData.Commitment),
+		}
+	}
+	return result
+}
+
+// createSpentOutputResult returns a JSON object for the output spent by an
+// input.
+func createSpentOutputResult(entry *blockchain.UtxoEntry, chainParams *chaincfg.Params) *btcjson.SpentOutputResult {
+	pkScript := entry.PkScript()
+
+	// The disassembled string will contain [error] inline if the script
+	// doesn't fully parse, so ignore the error here.
+	disbuf, _ := txscript.DisasmString(pkScript)
+
+	// Ignore the error here since an error means the script couldn't parse
+	// and there is no additional information about it anyways.
+	scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(
+		pkScript, chainParams)
+	addresses := make([]string, len(addrs))
+	for i, addr := range addrs {
+		addresses[i] = addr.EncodeAddress()
+	}
+
+	tokenData := entry.TokenData()
+	return &btcjson.SpentOutputResult{
+		Generated: entry.IsCoinBase(),
+		Height:    entry.BlockHeight(),
+		Value:     bchutil.Amount(entry.Amount()).ToBCH(),
+		ScriptPubKey: btcjson.ScriptPubKeyResult{
+			Asm:       disbuf,
+			Hex:       hex.EncodeToString(pkScript),
+			ReqSigs:   int32(reqSigs),
+			Type:      scriptClass.String(),
+			Addresses: addresses,
+		},
+		TokenData: createTokenDataResult(&tokenData),
+	}
+}
+
+// fetchSpentOutputs returns the outputs spent by the inputs of the passed
+// non-coinbase transaction.  The outputs spent by a transaction in the block
+// with the passed hash are loaded from the spend journal of the block, while
+// the outputs spent by a transaction in the memory pool are looked up in the
+// utxo set and the memory pool.
+func fetchSpentOutputs(s *rpcServer, mtx *wire.MsgTx, blkHash *chainhash.Hash) ([]*blockchain.UtxoEntry, error) {
+	entries := make([]*blockchain.UtxoEntry, 0, len(mtx.TxIn))
+	if blkHash == nil {
+		view, err := s.cfg.TxMemPool.FetchInputUtxos(bchutil.NewTx(mtx))
+		if err != nil {
+			context := "Failed to fetch spent outputs"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		for _, txIn := range mtx.TxIn {
+			entry := view.LookupEntry(txIn.PreviousOutPoint)
+			if entry == nil || entry.IsSpent() {
+				errStr := fmt.Sprintf("unable to find output %v",
+					txIn.PreviousOutPoint)
+				return nil, internalRPCError(errStr, "")
+			}
+			entries = append(entries, entry)
+		}
+		return entries, nil
+	}
+
+	block, err := s.cfg.Chain.BlockByHash(blkHash)
+	if err != nil {
+		context := "Failed to fetch block"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	stxos, err := s.cfg.Chain.FetchSpendJournal(block)
+	if err != nil {
+		context := "Failed to fetch spend journal"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	// The spend journal contains the spent outputs of all inputs of the
+	// block in order, excluding the coinbase.
+	txHash := mtx.TxHash()
+	stxoIdx := 0
+	for _, tx := range block.Transactions()[1:] {
+		numIns := len(tx.MsgTx().TxIn)
+		if !tx.Hash().IsEqual(&txHash) {
+			stxoIdx += numIns
+			continue
+		}
+		if stxoIdx+numIns > len(stxos) {
+			break
+		}
+		for i := range stxos[stxoIdx : stxoIdx+numIns] {
+			entries = append(entries,
+				spentTxOutEntry(&stxos[stxoIdx+i]))
+		}
+		return entries, nil
+	}
+
+	errStr := fmt.Sprintf("unable to find spent outputs of transaction %v",
+		txHash)
+	return nil, internalRPCError(errStr, "")
+}
+
+// spentTxOutEntry converts the passed spend journal entry, whose public key
+// script is prefixed with the token data of the output if it has any, to a
+// utxo entry.
+func spentTxOutEntry(stxo *blockchain.SpentTxOut) *blockchain.UtxoEntry {
+	var tokenData wire.TokenData
+	pkScript, err := tokenData.SeparateTokenDataFromPKScriptIfExists(
+		stxo.PkScript, 0)
+	if err != nil {
+		pkScript = stxo.PkScript
+		tokenData = wire.TokenData{}
+	}
+	txOut := wire.NewTxOut(stxo.Amount, pkScript, tokenData)
+	return blockchain.NewUtxoEntry(txOut, stxo.Height, stxo.IsCoinBase)
+}
+
+// addSpentOutputs adds the passed outputs spent by the inputs of the passed
+// non-coinbase transaction, along with the fee of the transaction, to its raw
+// transaction JSON object.
+func addSpentOutputs(rawTxn *btcjson.TxRawResult, mtx *wire.MsgTx,
+	spent []*blockchain.UtxoEntry, chainParams *chaincfg.Params) {
+
+	fee := int64(0)
+	for i, entry := range spent {
+		rawTxn.Vin[i].PrevOut = createSpentOutputResult(entry,
+			chainParams)
+		fee += entry.Amount()
+	}
+	for _, txOut := range mtx.TxOut {
+		fee -= txOut.Value
+	}
+	feeBCH := bchutil.Amount(fee).ToBCH()
+	rawTxn.Fee = &feeBCH
+}
+
+// countSigChecks executes the input scripts of the passed transaction against
+// the passed spent outputs and returns the number of signature checks they
+// perform.
+func countSigChecks(mtx *wire.MsgTx, spent []*blockchain.UtxoEntry) (uint32, error) {
+	utxoCache := txscript.NewUtxoCache()
+	for i, entry := range spent {
+		utxoCache.AddEntry(i, *wire.NewTxOut(entry.Amount(),
+			entry.PkScript(), entry.TokenData()))
+	}
+	sigHashes := txscript.NewTxSigHashes(mtx)
+	sigHashes.AddTxSigHashUtxoFromUtxoCache(mtx, utxoCache)
+
+	flags := txscript.StandardVerifyFlags | txscript.ScriptAllowCashTokens |
+		txscript.ScriptAllowMay2025
+	var sigChecks uint32
+	for i, entry := range spent {
+		vm, err := txscript.NewEngine(entry.PkScript(), mtx, i, flags,
+			nil, sigHashes, utxoCache, entry.Amount())
+		if err != nil {
+			return 0, err
+		}
+		err = vm.Execute()
+		if err == nil {
+			sigChecks += uint32(vm.SigChecks())
+		}
+		vm.Release()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return sigChecks, nil
+}
+
 // createTxRawResult converts the passed transaction and associated parameters
 // to a raw transaction JSON object.
 func createTxRawResult(chainParams *chaincfg.Params, mtx *wire.MsgTx,
@@ -819,7 +1201,7 @@ func createTxRawResult(chainParams *chaincfg.Params, mtx *wire.MsgTx,
 }
 
 // handleDecodeRawTransaction handles decoderawtransaction commands.
-func handleDecodeRawTransaction(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleDecodeRawTransaction(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.DecodeRawTransactionCmd)
 
 	// Deserialize the transaction.
@@ -852,7 +1234,7 @@ func handleDecodeRawTransaction(s *rpcServer, cmd interface{}, closeNotifier <-c
 }
 
 // handleDecodeScript handles decodescript commands.
-func handleDecodeScript(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleDecodeScript(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.DecodeScriptCmd)
 
 	// Convert the hex script to bytes.
@@ -900,7 +1282,7 @@ func handleDecodeScript(s *rpcServer, cmd interface{}, closeNotifier <-chan bool
 }
 
 // handleEstimateFee handles estimatefee commands.
-func handleEstimateFee(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleEstimateFee(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.EstimateFeeCmd)
 
 	if s.cfg.FeeEstimator == nil {
@@ -922,7 +1304,7 @@ func handleEstimateFee(s *rpcServer, cmd interface{}, closeNotifier <-chan bool)
 }
 
 // handleGenerate handles generate commands.
-func handleGenerate(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGenerate(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	// Respond with an error if there are no addresses to pay the
 	// created blocks to.
 	if len(cfg.miningAddrs) == 0 {
@@ -933,16 +1315,8 @@ func handleGenerate(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (i
 		}
 	}
 
-	// Respond with an error if there's virtually 0 chance of mining a block
-	// with the CPU.
-	if !s.cfg.ChainParams.GenerateSupported {
-		return nil, &btcjson.RPCError{
-			Code: btcjson.ErrRPCDifficulty,
-			Message: fmt.Sprintf("No support for `generate` on "+
-				"the current network, %s, as it's unlikely to "+
-				"be possible to mine a block with the CPU.",
-				s.cfg.ChainParams.Net),
-		}
+	if err := checkGenerateSupported(s, "generate"); err != nil {
+		return nil, err
 	}
 
 	c := cmd.(*btcjson.GenerateCmd)
@@ -975,8 +1349,132 @@ func handleGenerate(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (i
 	return reply, nil
 }
 
+// checkGenerateSupported returns an error if blocks can not be generated with
+// the CPU on the current network.
+func checkGenerateSupported(s *rpcServer, method string) error {
+	// Respond with an error if there's virtually 0 chance of mining a block
+	// with the CPU.
+	if !s.cfg.ChainParams.GenerateSupported {
+		return &btcjson.RPCError{
+			Code: btcjson.ErrRPCDifficulty,
+			Message: fmt.Sprintf("No support for `%s` on "+
+				"the current network, %s, as it's unlikely to "+
+				"be possible to mine a block with the CPU.",
+				method, s.cfg.ChainParams.Net),
+		}
+	}
+	return nil
+}
+
+// decodeGenerateAddress decodes the address the coinbase of a generated block
+// pays to.
+func decodeGenerateAddress(s *rpcServer, address string) (bchutil.Address, error) {
+	addr, err := bchutil.DecodeAddress(address, s.cfg.ChainParams)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key: " + err.Error(),
+		}
+	}
+	if !addr.IsForNet(s.cfg.ChainParams) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key: address is not for " +
+				"the current network",
+		}
+	}
+	return addr, nil
+}
+
+// handleGenerateToAddress handles generatetoaddress commands.
+func handleGenerateToAddress(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	if err := checkGenerateSupported(s, "generatetoaddress"); err != nil {
+		return nil, err
+	}
+
+	c := cmd.(*btcjson.GenerateToAddressCmd)
+	if c.NumBlocks == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInternal.Code,
+			Message: "Please request a nonzero number of blocks to generate.",
+		}
+	}
+	addr, err := decodeGenerateAddress(s, c.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	blockHashes, err := s.cfg.CPUMiner.GenerateNBlocksToAddress(c.NumBlocks, addr)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInternal.Code,
+			Message: err.Error(),
+		}
+	}
+
+	reply := make([]string, len(blockHashes))
+	for i, hash := range blockHashes {
+		reply[i] = hash.String()
+	}
+	return reply, nil
+}
+
+// handleGenerateBlock handles generateblock commands.
+func handleGenerateBlock(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	if err := checkGenerateSupported(s, "generateblock"); err != nil {
+		return nil, err
+	}
+
+	c := cmd.(*btcjson.GenerateBlockCmd)
+	addr, err := decodeGenerateAddress(s, c.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each transaction is either the txid of a transaction in the mempool
+	// or a raw transaction.
+	txns := make([]*bchutil.Tx, 0, len(c.Transactions))
+	for _, txStr := range c.Transactions {
+		if len(txStr) == chainhash.MaxHashStringSize {
+			txHash, err := chainhash.NewHashFromStr(txStr)
+			if err != nil {
+				return nil, rpcDecodeHexError(txStr)
+			}
+			tx, err := s.cfg.TxMemPool.FetchTransaction(txHash)
+			if err != nil {
+				return nil, rpcNoTxInfoError(txHash)
+			}
+			txns = append(txns, tx)
+			continue
+		}
+
+		serializedTx, err := hex.DecodeString(txStr)
+		if err != nil {
+			return nil, rpcDecodeHexError(txStr)
+		}
+		var msgTx wire.MsgTx
+		if err := msgTx.Deserialize(bytes.NewReader(serializedTx)); err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCDeserialization,
+				Message: "TX decode failed: " + err.Error(),
+			}
+		}
+		txns = append(txns, bchutil.NewTx(&msgTx))
+	}
+
+	blockHash, err := s.cfg.CPUMiner.GenerateBlock(addr, txns)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCVerify,
+			Message: err.Error(),
+		}
+	}
+
+	return &btcjson.GenerateBlockResult{Hash: blockHash.String()}, nil
+}
+
 // handleGetAddedNodeInfo handles getaddednodeinfo commands.
-func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.GetAddedNodeInfoCmd)
 
 	// Retrieve a list of persistent (added) peers from the server and
@@ -1066,7 +1564,7 @@ func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeNotifier <-chan
 }
 
 // handleGetBestBlock implements the getbestblock command.
-func handleGetBestBlock(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetBestBlock(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	// All other "get block" commands give either the height, the
 	// hash, or both but require the block SHA.  This gets both for
 	// the best block.
@@ -1078,8 +1576,42 @@ func handleGetBestBlock(s *rpcServer, cmd interface{}, closeNotifier <-chan bool
 	return result, nil
 }
 
+// handleGetABLAState implements the getablastate command.
+func handleGetABLAState(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	c := cmd.(*btcjson.GetABLAStateCmd)
+
+	// The state applies to the block building on the tip of the main chain.
+	best := s.cfg.Chain.BestSnapshot()
+	state := s.cfg.Chain.ABLAState()
+	height := best.Height + 1
+	result := &btcjson.GetABLAStateResult{
+		Height:            height,
+		Active:            height > s.cfg.ChainParams.ABLAForkHeight,
+		ControlBlockSize:  state.ControlBlockSize(),
+		ElasticBufferSize: state.ElasticBufferSize(),
+		SizeLimit:         state.BlockSizeLimit(),
+	}
+
+	// Project the state forward for the hypothetical block sizes.
+	if c.BlockSizes != nil {
+		states := s.cfg.Chain.SimulateABLA(*c.BlockSizes)
+		result.Projection = make([]btcjson.ABLAStateResult, 0, len(states))
+		for i := range states {
+			height++
+			result.Projection = append(result.Projection, btcjson.ABLAStateResult{
+				Height:            height,
+				ControlBlockSize:  states[i].ControlBlockSize(),
+				ElasticBufferSize: states[i].ElasticBufferSize(),
+				SizeLimit:         states[i].BlockSizeLimit(),
+			})
+		}
+	}
+
+	return result, nil
+}
+
 // handleGetBestBlockHash implements the getbestblockhash command.
-func handleGetBestBlockHash(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetBestBlockHash(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	best := s.cfg.Chain.BestSnapshot()
 	return best.Hash.String(), nil
 }
@@ -1105,7 +1637,7 @@ func getDifficultyRatio(bits uint32, params *chaincfg.Params) float64 {
 }
 
 // handleGetBlock implements the getblock command.
-func handleGetBlock(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetBlock(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.GetBlockCmd)
 
 	// Load the raw block bytes from the database.
@@ -1187,15 +1719,47 @@ func handleGetBlock(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (i
 
 		blockReply.Tx = txNames
 	} else {
+		// Verbosity 3 additionally includes the outputs spent by the
+		// inputs and the fees of the transactions, which are loaded
+		// from the spend journal of the block.
+		var stxos []blockchain.SpentTxOut
+		includeSpent := *c.Verbosity == 3
+		if includeSpent {
+			stxos, err = s.cfg.Chain.FetchSpendJournal(blk)
+			if err != nil {
+				context := "Failed to fetch spend journal"
+				return nil, internalRPCError(err.Error(), context)
+			}
+		}
+
 		txns := blk.Transactions()
 		rawTxns := make([]btcjson.TxRawResult, len(txns))
+		stxoIdx := 0
 		for i, tx := range txns {
-			rawTxn, err := createTxRawResult(params, tx.MsgTx(),
+			mtx := tx.MsgTx()
+			rawTxn, err := createTxRawResult(params, mtx,
 				tx.Hash().String(), blockHeader, hash.String(),
 				blockHeight, best.Height)
 			if err != nil {
 				return nil, err
 			}
+
+			// The spend journal contains the spent outputs of all
+			// inputs of the block in order, excluding the coinbase.
+			if includeSpent && i > 0 {
+				numIns := len(mtx.TxIn)
+				if stxoIdx+numIns > len(stxos) {
+					errStr := fmt.Sprintf("unable to find spent "+
+						"outputs of transaction %v", tx.Hash())
+					return nil, internalRPCError(errStr, "")
+				}
+				spent := make([]*blockchain.UtxoEntry, numIns)
+				for j := range spent {
+					spent[j] = spentTxOutEntry(&stxos[stxoIdx+j])
+				}
+				stxoIdx += numIns
+				addSpentOutputs(rawTxn, mtx, spent, params)
+			}
 			rawTxns[i] = *rawTxn
 		}
 		blockReply.RawTx = rawTxns
@@ -1224,18 +1788,20 @@ func softForkStatus(state blockchain.ThresholdState) (string, error) {
 }
 
 // handleGetBlockChainInfo implements the getblockchaininfo command.
-func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	// Obtain a snapshot of the current best known blockchain state. We'll
 	// populate the response to this call primarily from this snapshot.
 	params := s.cfg.ChainParams
 	chain := s.cfg.Chain
 	chainSnapshot := chain.BestSnapshot()
 
-	// Estimate the verification (sync) progress of the node.
-	syncHeight := s.cfg.SyncMgr.SyncHeight()
-	var verifyProgress float64
-	if syncHeight > 0 {
-		verifyProgress = math.Min(float64(chainSnapshot.Height)/float64(syncHeight), 1.0)
+	// Estimate the verification (sync) progress of the node from the
+	// number of transactions in the chain.
+	progress := chain.SyncProgress()
+	spaceInfo, err := s.cfg.DB.SpaceInfo()
+	if err != nil {
+		context := "Failed to fetch database space info"
+		return nil, internalRPCError(err.Error(), context)
 	}
 
 	chainInfo := &btcjson.GetBlockChainInfoResult{
@@ -1247,8 +1813,11 @@ func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeNotifier <-chan
 		MedianTime:           chainSnapshot.MedianTime.Unix(),
 		Pruned:               false,
 		Bip9SoftForks:        make(map[string]*btcjson.Bip9SoftForkDescription),
-		VerificationProgress: verifyProgress,
-		SyncHeight:           syncHeight,
+		VerificationProgress: progress.VerificationProgress,
+		InitialBlockDownload: !progress.Current,
+		EstimatedRemaining:   int64(progress.RemainingTime / time.Second),
+		SizeOnDisk:           spaceInfo.MetadataSize + spaceInfo.BlocksSize,
+		SyncHeight:           s.cfg.SyncMgr.SyncHeight(),
 	}
 
 	// Next, populate the response with information describing the current
@@ -1340,13 +1909,13 @@ func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeNotifier <-chan
 }
 
 // handleGetBlockCount implements the getblockcount command.
-func handleGetBlockCount(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetBlockCount(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	best := s.cfg.Chain.BestSnapshot()
 	return int64(best.Height), nil
 }
 
 // handleGetBlockHash implements the getblockhash command.
-func handleGetBlockHash(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetBlockHash(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.GetBlockHashCmd)
 	hash, err := s.cfg.Chain.BlockHashByHeight(int32(c.Index))
 	if err != nil {
@@ -1360,7 +1929,7 @@ func handleGetBlockHash(s *rpcServer, cmd interface{}, closeNotifier <-chan bool
 }
 
 // handleGetBlockHeader implements the getblockheader command.
-func handleGetBlockHeader(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetBlockHeader(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.GetBlockHeaderCmd)
 
 	// Fetch the header from chain.
@@ -1427,6 +1996,432 @@ func handleGetBlockHeader(s *rpcServer, cmd interface{}, closeNotifier <-chan bo
 	return blockHeaderReply, nil
 }
 
+// blockStatNames are the names of the statistics the getblockstats RPC can
+// compute.  The statistics which are mapped to true need the outputs spent by
+// the block, which are loaded from its spend journal.
+var blockStatNames = map[string]bool{
+	"avgfee":              true,
+	"avgfeerate":          true,
+	"avgtxsize":           false,
+	"blockhash":           false,
+	"feerate_percentiles": true,
+	"height":              false,
+	"ins":                 false,
+	"maxfee":              true,
+	"maxfeerate":          true,
+	"maxtxsize":           false,
+	"medianfee":           true,
+	"mediantime":          false,
+	"mediantxsize":        false,
+	"minfee":              true,
+	"minfeerate":          true,
+	"mintxsize":           false,
+	"outs":                false,
+	"sigchecks":           true,
+	"subsidy":             false,
+	"time":                false,
+	"total_out":           false,
+	"total_size":          false,
+	"totalfee":            true,
+	"txs":                 false,
+	"utxo_increase":       false,
+	"utxo_size_inc":       true,
+}
+
+// blockStatsFeeRatePercentiles are the percentiles of the fee rates, weighted
+// by transaction size, reported by the feerate_percentiles statistic.
+var blockStatsFeeRatePercentiles = []int64{10, 25, 50, 75, 90}
+
+// utxoSizeOverhead is the number of bytes every unspent output is accounted for
+// in addition to its serialized size by the utxo_size_inc statistic, which
+// covers its outpoint and metadata.  It matches the accounting of Bitcoin Core
+// so the statistic is comparable across implementations.
+const utxoSizeOverhead = 41
+
+// handleGetBlockStats implements the getblockstats command.
+func handleGetBlockStats(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockStatsCmd)
+
+	// The first block is identified by either its height or its hash.
+	var startHeight int32
+	best := s.cfg.Chain.BestSnapshot()
+	hashOrHeight := string(c.HashOrHeight)
+	if height, err := strconv.ParseInt(hashOrHeight, 10, 32); err == nil {
+		if height < 0 || height > int64(best.Height) {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Target block height %d "+
+					"out of range", height),
+			}
+		}
+		startHeight = int32(height)
+	} else {
+		hash, err := chainhash.NewHashFromStr(hashOrHeight)
+		if err != nil {
+			return nil, rpcDecodeHexError(hashOrHeight)
+		}
+		startHeight, err = s.cfg.Chain.BlockHeightByHash(hash)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: "Block not found",
+			}
+		}
+	}
+	endHeight := startHeight
+	if c.EndHeight != nil {
+		endHeight = *c.EndHeight
+		if endHeight < startHeight || endHeight > best.Height {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("End height %d out of "+
+					"range", endHeight),
+			}
+		}
+	}
+
+	// Only the requested statistics are computed, or all of them when
+	// none are requested.
+	selected := make(map[string]bool)
+	if c.Stats != nil && len(*c.Stats) > 0 {
+		for _, name := range *c.Stats {
+			if _, ok := blockStatNames[name]; !ok {
+				return nil, &btcjson.RPCError{
+					Code: btcjson.ErrRPCInvalidParameter,
+					Message: fmt.Sprintf("Invalid selected "+
+						"statistic %s", name),
+				}
+			}
+			selected[name] = true
+		}
+	} else {
+		for name := range blockStatNames {
+			selected[name] = true
+		}
+	}
+
+	results := make([]*btcjson.GetBlockStatsResult, 0, endHeight-startHeight+1)
+	for height := startHeight; height <= endHeight; height++ {
+		select {
+		case <-ctx.Done():
+			return nil, ErrClientQuit
+		default:
+		}
+
+		result, err := blockStats(s, height, selected)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	if c.EndHeight == nil {
+		return results[0], nil
+	}
+	return results, nil
+}
+
+// blockStats computes the passed selection of statistics of the main chain
+// block at the passed height.
+func blockStats(s *rpcServer, height int32, selected map[string]bool) (*btcjson.GetBlockStatsResult, error) {
+	block, err := s.cfg.Chain.BlockByHeight(height)
+	if err != nil {
+		context := "Failed to fetch block"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	txns := block.Transactions()
+
+	// The spend journal, which holds the outputs spent by all inputs of
+	// the block except the coinbase, is only loaded when needed.
+	var stxos []blockchain.SpentTxOut
+	needSpent := false
+	for name := range selected {
+		needSpent = needSpent || blockStatNames[name]
+	}
+	if needSpent && len(txns) > 1 {
+		stxos, err = s.cfg.Chain.FetchSpendJournal(block)
+		if err != nil {
+			context := "Failed to fetch spend journal"
+			return nil, internalRPCError(err.Error(), context)
+		}
+	}
+
+	var (
+		ins, outs, totalOut, totalSize, totalFee int64
+		utxoIncrease, utxoSizeInc, sigChecks     int64
+		minFee, maxFee, minFeeRate, maxFeeRate   int64
+		minTxSize, maxTxSize                     int64
+	)
+	fees := make([]int64, 0, len(txns))
+	sizes := make([]int64, 0, len(txns))
+	feeRates := make([]blockStatsFeeRate, 0, len(txns))
+	stxoIdx := 0
+	for i, tx := range txns {
+		mtx := tx.MsgTx()
+		outs += int64(len(mtx.TxOut))
+		for _, txOut := range mtx.TxOut {
+			if txscript.IsUnspendable(txOut.PkScript) {
+				continue
+			}
+			utxoIncrease++
+			utxoSizeInc += int64(txOut.SerializeSize()) + utxoSizeOverhead
+		}
+
+		// The coinbase spends no outputs and is excluded from the
+		// transaction size and fee statistics.
+		if i == 0 {
+			continue
+		}
+
+		size := int64(mtx.SerializeSize())
+		ins += int64(len(mtx.TxIn))
+		utxoIncrease -= int64(len(mtx.TxIn))
+		totalSize += size
+		sizes = append(sizes, size)
+		if i == 1 || size < minTxSize {
+			minTxSize = size
+		}
+		if size > maxTxSize {
+			maxTxSize = size
+		}
+		for _, txOut := range mtx.TxOut {
+			totalOut += txOut.Value
+		}
+
+		if !needSpent {
+			continue
+		}
+		if stxoIdx+len(mtx.TxIn) > len(stxos) {
+			str := fmt.Sprintf("spend journal of block %v is "+
+				"incomplete", block.Hash())
+			return nil, internalRPCError(str, "")
+		}
+		spent := make([]*blockchain.UtxoEntry, 0, len(mtx.TxIn))
+		fee := int64(0)
+		for j := range mtx.TxIn {
+			entry := spentTxOutEntry(&stxos[stxoIdx+j])
+			spent = append(spent, entry)
+			fee += entry.Amount()
+			utxoSizeInc -= int64(wire.NewTxOut(entry.Amount(),
+				entry.PkScript(), entry.TokenData()).SerializeSize()) +
+				utxoSizeOverhead
+		}
+		stxoIdx += len(mtx.TxIn)
+		for _, txOut := range mtx.TxOut {
+			fee -= txOut.Value
+		}
+		feeRate := fee / size
+
+		totalFee += fee
+		fees = append(fees, fee)
+		feeRates = append(feeRates, blockStatsFeeRate{feeRate, size})
+		if i == 1 || fee < minFee {
+			minFee = fee
+		}
+		if fee > maxFee {
+			maxFee = fee
+		}
+		if i == 1 || feeRate < minFeeRate {
+			minFeeRate = feeRate
+		}
+		if feeRate > maxFeeRate {
+			maxFeeRate = feeRate
+		}
+
+		// Signature checks are only counted when requested since it
+		// requires executing the scripts.  Transactions which do not
+		// execute successfully under the current rules, such as old
+		// non-standard ones, are not counted.
+		if selected["sigchecks"] {
+			txSigChecks, err := countSigChecks(mtx, spent)
+			if err == nil {
+				sigChecks += int64(txSigChecks)
+			}
+		}
+	}
+
+	numTxns := int64(len(txns) - 1)
+	var avgFee, avgFeeRate, avgTxSize int64
+	if numTxns > 0 {
+		avgFee = totalFee / numTxns
+		avgTxSize = totalSize / numTxns
+	}
+	if totalSize > 0 {
+		avgFeeRate = totalFee / totalSize
+	}
+	txCount := int64(len(txns))
+
+	result := &btcjson.GetBlockStatsResult{}
+	set := func(name string, dst **int64, v int64) {
+		if selected[name] {
+			*dst = &v
+		}
+	}
+	set("avgfee", &result.AvgFee, avgFee)
+	set("avgfeerate", &result.AvgFeeRate, avgFeeRate)
+	set("avgtxsize", &result.AvgTxSize, avgTxSize)
+	set("ins", &result.Ins, ins)
+	set("maxfee", &result.MaxFee, maxFee)
+	set("maxfeerate", &result.MaxFeeRate, maxFeeRate)
+	set("maxtxsize", &result.MaxTxSize, maxTxSize)
+	set("medianfee", &result.MedianFee, truncatedMedian(fees))
+	set("mediantxsize", &result.MedianTxSize, truncatedMedian(sizes))
+	set("minfee", &result.MinFee, minFee)
+	set("minfeerate", &result.MinFeeRate, minFeeRate)
+	set("mintxsize", &result.MinTxSize, minTxSize)
+	set("outs", &result.Outs, outs)
+	set("sigchecks", &result.SigChecks, sigChecks)
+	set("subsidy", &result.Subsidy,
+		blockchain.CalcBlockSubsidy(height, s.cfg.ChainParams))
+	set("time", &result.Time, block.MsgBlock().Header.Timestamp.Unix())
+	set("total_out", &result.TotalOut, totalOut)
+	set("total_size", &result.TotalSize, totalSize)
+	set("totalfee", &result.TotalFee, totalFee)
+	set("txs", &result.Txs, txCount)
+	set("utxo_increase", &result.UtxoIncrease, utxoIncrease)
+	set("utxo_size_inc", &result.UtxoSizeInc, utxoSizeInc)
+	if selected["blockhash"] {
+		result.BlockHash = block.Hash().String()
+	}
+	if selected["height"] {
+		result.Height = &height
+	}
+	if selected["mediantime"] {
+		medianTime, err := s.cfg.Chain.MedianTimeByHash(block.Hash())
+		if err != nil {
+			context := "Failed to obtain median time"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		set("mediantime", &result.MedianTime, medianTime.Unix())
+	}
+	if selected["feerate_percentiles"] {
+		result.FeeRatePercentiles = feeRatePercentiles(feeRates,
+			totalSize)
+	}
+	return result, nil
+}
+
+// blockStatsFeeRate houses the fee rate and size of a transaction for the fee
+// rate percentiles of the getblockstats RPC.
+type blockStatsFeeRate struct {
+	feeRate int64
+	size    int64
+}
+
+// feeRatePercentiles returns the fee rates at the percentiles reported by the
+// getblockstats RPC, weighting each fee rate by the size of its transaction so
+// that they reflect the share of the block space paying each rate.
+func feeRatePercentiles(feeRates []blockStatsFeeRate, totalSize int64) []int64 {
+	result := make([]int64, len(blockStatsFeeRatePercentiles))
+	sort.Slice(feeRates, func(i, j int) bool {
+		return feeRates[i].feeRate < feeRates[j].feeRate
+	})
+	var cumulativeSize int64
+	p := 0
+	for _, fr := range feeRates {
+		cumulativeSize += fr.size
+		for p < len(result) && cumulativeSize*100 >=
+			totalSize*blockStatsFeeRatePercentiles[p] {
+
+			result[p] = fr.feeRate
+			p++
+		}
+	}
+	return result
+}
+
+// truncatedMedian returns the median of the passed values, truncating the mean
+// of the middle two values when there is an even number of them, or zero when
+// there are none.  The passed slice is sorted.
+func truncatedMedian(values []int64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
+
+// handleGetBlockUndoData implements the getblockundodata command.
+func handleGetBlockUndoData(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockUndoDataCmd)
+
+	// The block is identified by either its height or its hash.  The undo
+	// data of a block is only kept while it is part of the main chain.
+	var height int32
+	best := s.cfg.Chain.BestSnapshot()
+	hashOrHeight := string(c.HashOrHeight)
+	if h, err := strconv.ParseInt(hashOrHeight, 10, 32); err == nil {
+		if h < 0 || h > int64(best.Height) {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Target block height %d "+
+					"out of range", h),
+			}
+		}
+		height = int32(h)
+	} else {
+		hash, err := chainhash.NewHashFromStr(hashOrHeight)
+		if err != nil {
+			return nil, rpcDecodeHexError(hashOrHeight)
+		}
+		height, err = s.cfg.Chain.BlockHeightByHash(hash)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: "Block not found in the main chain",
+			}
+		}
+	}
+
+	block, err := s.cfg.Chain.BlockByHeight(height)
+	if err != nil {
+		context := "Failed to fetch block"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	stxos, err := s.cfg.Chain.FetchSpendJournal(block)
+	if err != nil {
+		context := "Failed to fetch spend journal"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	// The spend journal contains the spent outputs of all inputs of the
+	// block in order, excluding the coinbase.
+	txns := block.Transactions()[1:]
+	result := &btcjson.GetBlockUndoDataResult{
+		Hash:   block.Hash().String(),
+		Height: height,
+		Tx:     make([]btcjson.TxUndoResult, 0, len(txns)),
+	}
+	stxoIdx := 0
+	for _, tx := range txns {
+		numIns := len(tx.MsgTx().TxIn)
+		if stxoIdx+numIns > len(stxos) {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCMisc,
+				Message: fmt.Sprintf("Undo data of block %v is "+
+					"not available", block.Hash()),
+			}
+		}
+		prevOuts := make([]btcjson.SpentOutputResult, numIns)
+		for i := range prevOuts {
+			entry := spentTxOutEntry(&stxos[stxoIdx+i])
+			prevOuts[i] = *createSpentOutputResult(entry,
+				s.cfg.ChainParams)
+		}
+		stxoIdx += numIns
+		result.Tx = append(result.Tx, btcjson.TxUndoResult{
+			Txid:     tx.Hash().String(),
+			PrevOuts: prevOuts,
+		})
+	}
+
+	return result, nil
+}
+
 // encodeTemplateID encodes the passed details into an ID that can be used to
 // uniquely identify a block template.
 func encodeTemplateID(prevHash *chainhash.Hash, lastGenerated time.Time) string {
@@ -1680,9 +2675,7 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 			template.ValidPayAddress = true
 
 			// Update the merkle root.
-			block := bchutil.NewBlock(template.Block)
-			merkles := blockchain.BuildMerkleTreeStore(block.Transactions())
-			template.Block.Header.MerkleRoot = *merkles[len(merkles)-1]
+			template.UpdateCoinbase()
 		}
 
 		// Set locals for convenience.
@@ -1856,7 +2849,7 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 // has passed without finding a solution.
 //
 // See https://en.bitcoin.it/wiki/BIP_0022 for more details.
-func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbaseValue bool, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbaseValue bool, ctx context.Context) (interface{}, error) {
 	state := s.gbtWorkState
 	state.Lock()
 	// The state unlock is intentionally not deferred here since it needs to
@@ -1914,7 +2907,7 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 	select {
 	// When the client closes before it's time to send a reply, just return
 	// now so the goroutine doesn't hang around.
-	case <-closeNotifier:
+	case <-ctx.Done():
 		return nil, ErrClientQuit
 
 	// Wait until signal received to send the reply.
@@ -1949,7 +2942,7 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 // in regards to whether or not it supports creating its own coinbase (the
 // coinbasetxn and coinbasevalue capabilities) and modifies the returned block
 // template accordingly.
-func handleGetBlockTemplateRequest(s *rpcServer, request *btcjson.TemplateRequest, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetBlockTemplateRequest(s *rpcServer, request *btcjson.TemplateRequest, ctx context.Context) (interface{}, error) {
 	// Extract the relevant passed capabilities and restrict the result to
 	// either a coinbase value or a coinbase transaction object depending on
 	// the request.  Default to only providing a coinbase value.
@@ -2008,7 +3001,7 @@ func handleGetBlockTemplateRequest(s *rpcServer, request *btcjson.TemplateReques
 	// be replaced with a new one.
 	if request != nil && request.LongPollID != "" {
 		return handleGetBlockTemplateLongPoll(s, request.LongPollID,
-			useCoinbaseValue, closeNotifier)
+			useCoinbaseValue, ctx)
 	}
 
 	// Protect concurrent access when updating block templates.
@@ -2195,7 +3188,7 @@ func handleGetBlockTemplateProposal(s *rpcServer, request *btcjson.TemplateReque
 //
 // See https://en.bitcoin.it/wiki/BIP_0022 and
 // https://en.bitcoin.it/wiki/BIP_0023 for more details.
-func handleGetBlockTemplate(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetBlockTemplate(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.GetBlockTemplateCmd)
 	request := c.Request
 
@@ -2207,7 +3200,7 @@ func handleGetBlockTemplate(s *rpcServer, cmd interface{}, closeNotifier <-chan
 
 	switch mode {
 	case "template":
-		return handleGetBlockTemplateRequest(s, request, closeNotifier)
+		return handleGetBlockTemplateRequest(s, request, ctx)
 	case "proposal":
 		return handleGetBlockTemplateProposal(s, request)
 	}
@@ -2219,7 +3212,7 @@ func handleGetBlockTemplate(s *rpcServer, cmd interface{}, closeNotifier <-chan
 }
 
 // handleGetCFilter implements the getcfilter command.
-func handleGetCFilter(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetCFilter(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	if s.cfg.CfIndex == nil {
 		return nil, &btcjson.RPCError{
 			Code:    btcjson.ErrRPCNoCFIndex,
@@ -2248,7 +3241,7 @@ func handleGetCFilter(s *rpcServer, cmd interface{}, closeNotifier <-chan bool)
 }
 
 // handleGetCFilterHeader implements the getcfilterheader command.
-func handleGetCFilterHeader(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetCFilterHeader(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	if s.cfg.CfIndex == nil {
 		return nil, &btcjson.RPCError{
 			Code:    btcjson.ErrRPCNoCFIndex,
@@ -2278,29 +3271,153 @@ func handleGetCFilterHeader(s *rpcServer, cmd interface{}, closeNotifier <-chan
 	return hash.String(), nil
 }
 
+// handleGetChainTips implements the getchaintips command.
+func handleGetChainTips(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	tips, err := s.cfg.Chain.ChainTips()
+	if err != nil {
+		context := "Failed to load chain tips"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	results := make([]btcjson.GetChainTipsResult, 0, len(tips))
+	for _, tip := range tips {
+		result := btcjson.GetChainTipsResult{
+			Height:        tip.Height,
+			Hash:          tip.Hash.String(),
+			BranchLen:     tip.BranchLen,
+			Status:        tip.Status.String(),
+			InvalidReason: tip.InvalidReason,
+		}
+		if tip.InvalidBlock != nil {
+			result.InvalidBlock = tip.InvalidBlock.String()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// handleGetCheckpointCandidates implements the getcheckpointcandidates command.
+func handleGetCheckpointCandidates(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	c := cmd.(*btcjson.GetCheckpointCandidatesCmd)
+	if *c.Count < 1 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Count must be at least 1",
+		}
+	}
+
+	candidates, err := s.cfg.Chain.CheckpointCandidates(*c.Count, *c.Interval)
+	if err != nil {
+		context := "Failed to find checkpoint candidates"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	result := &btcjson.GetCheckpointCandidatesResult{
+		Candidates: make([]btcjson.CheckpointCandidateResult, 0, len(candidates)),
+	}
+	for _, candidate := range candidates {
+		result.Candidates = append(result.Candidates, btcjson.CheckpointCandidateResult{
+			Height:    candidate.Height,
+			Hash:      candidate.Hash.String(),
+			Time:      candidate.Timestamp.Unix(),
+			ChainWork: fmt.Sprintf("%064x", candidate.ChainWork),
+			Checkpoint: fmt.Sprintf("{Height: %d, Hash: newHashFromStr(%q)},",
+				candidate.Height, candidate.Hash.String()),
+		})
+	}
+	if len(result.Candidates) > 0 {
+		result.MinimumChainWork = result.Candidates[0].ChainWork
+	}
+	return result, nil
+}
+
 // handleGetConnectionCount implements the getconnectioncount command.
-func handleGetConnectionCount(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetConnectionCount(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	return s.cfg.ConnMgr.ConnectedCount(), nil
 }
 
 // handleGetCurrentNet implements the getcurrentnet command.
-func handleGetCurrentNet(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetCurrentNet(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	return s.cfg.ChainParams.Net, nil
 }
 
+// handleGetDatabaseInfo implements the getdatabaseinfo command.
+func handleGetDatabaseInfo(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	info, err := s.cfg.DB.SpaceInfo()
+	if err != nil {
+		context := "Failed to fetch database space info"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return &btcjson.GetDatabaseInfoResult{
+		Type:         s.cfg.DB.Type(),
+		MetadataSize: info.MetadataSize,
+		BlocksSize:   info.BlocksSize,
+		DeletedKeys:  info.DeletedKeys,
+		AutoCompact:  cfg.DbAutoCompact,
+	}, nil
+}
+
 // handleGetDifficulty implements the getdifficulty command.
-func handleGetDifficulty(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetDifficulty(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	best := s.cfg.Chain.BestSnapshot()
 	return getDifficultyRatio(best.Bits, s.cfg.ChainParams), nil
 }
 
+// handleGetFirstSeen implements the getfirstseen command.
+func handleGetFirstSeen(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	c := cmd.(*btcjson.GetFirstSeenCmd)
+
+	if s.cfg.FirstSeen == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "First seen tracking must be enabled (--firstseen)",
+		}
+	}
+
+	hash, err := chainhash.NewHashFromStr(c.Hash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Hash)
+	}
+	record := s.cfg.FirstSeen.Lookup(hash)
+	if record == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "No first seen record of the block or transaction",
+		}
+	}
+
+	toMillis := func(t time.Time) int64 {
+		return t.UnixNano() / int64(time.Millisecond)
+	}
+	result := &btcjson.GetFirstSeenResult{
+		Hash: record.Hash.String(),
+		Type: string(record.Kind),
+		Announced: btcjson.FirstSightingResult{
+			Time:   toMillis(record.Announced.Time),
+			Peer:   record.Announced.Peer,
+			Source: string(record.Announced.Source),
+		},
+	}
+	if record.Received != nil {
+		result.Received = &btcjson.FirstSightingResult{
+			Time:   toMillis(record.Received.Time),
+			Peer:   record.Received.Peer,
+			Source: string(record.Received.Source),
+		}
+		delay := result.Received.Time - result.Announced.Time
+		result.Delay = &delay
+	}
+	return result, nil
+}
+
 // handleGetGenerate implements the getgenerate command.
-func handleGetGenerate(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetGenerate(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	return s.cfg.CPUMiner.IsMining(), nil
 }
 
 // handleGetHashesPerSec implements the gethashespersec command.
-func handleGetHashesPerSec(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetHashesPerSec(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	return int64(s.cfg.CPUMiner.HashesPerSecond()), nil
 }
 
@@ -2308,7 +3425,7 @@ func handleGetHashesPerSec(s *rpcServer, cmd interface{}, closeNotifier <-chan b
 //
 // NOTE: This is a btcsuite extension originally ported from
 // github.com/decred/dcrd.
-func handleGetHeaders(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetHeaders(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.GetHeadersCmd)
 
 	// Fetch the requested headers from chain while respecting the provided
@@ -2347,7 +3464,7 @@ func handleGetHeaders(s *rpcServer, cmd interface{}, closeNotifier <-chan bool)
 
 // handleGetInfo implements the getinfo command. We only return the fields
 // that are not related to wallet functionality.
-func handleGetInfo(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetInfo(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	best := s.cfg.Chain.BestSnapshot()
 	ret := &btcjson.InfoChainResult{
 		Version:         int32(1000000*version.AppMajor + 10000*version.AppMinor + 100*version.AppPatch),
@@ -2358,14 +3475,14 @@ func handleGetInfo(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (in
 		Proxy:           cfg.Proxy,
 		Difficulty:      getDifficultyRatio(best.Bits, s.cfg.ChainParams),
 		TestNet:         cfg.TestNet3,
-		RelayFee:        cfg.minRelayTxFee.ToBCH(),
+		RelayFee:        s.cfg.TxMemPool.Policy().MinRelayTxFee.ToBCH(),
 	}
 
 	return ret, nil
 }
 
 // handleGetMempoolInfo implements the getmempoolinfo command.
-func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetMempoolInfo(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	mempoolTxns := s.cfg.TxMemPool.TxDescs()
 
 	var numBytes int64
@@ -2373,9 +3490,17 @@ func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeNotifier <-chan bo
 		numBytes += int64(txD.Tx.MsgTx().SerializeSize())
 	}
 
+	minFee := bchutil.Amount(s.cfg.TxMemPool.MinFeeRate())
+	minRelayTxFee := s.cfg.TxMemPool.Policy().MinRelayTxFee
+	if minFee < minRelayTxFee {
+		minFee = minRelayTxFee
+	}
+
 	ret := &btcjson.GetMempoolInfoResult{
-		Size:  int64(len(mempoolTxns)),
-		Bytes: numBytes,
+		Size:          int64(len(mempoolTxns)),
+		Bytes:         numBytes,
+		MaxMempool:    int64(cfg.MaxMempool) * 1000 * 1000,
+		MempoolMinFee: minFee.ToBCH(),
 	}
 
 	return ret, nil
@@ -2383,12 +3508,12 @@ func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeNotifier <-chan bo
 
 // handleGetMiningInfo implements the getmininginfo command. We only return the
 // fields that are not related to wallet functionality.
-func handleGetMiningInfo(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetMiningInfo(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	// Create a default getnetworkhashps command to use defaults and make
 	// use of the existing getnetworkhashps handler.
 	gnhpsCmd := btcjson.NewGetNetworkHashPSCmd(nil, nil)
 	networkHashesPerSecIface, err := handleGetNetworkHashPS(s, gnhpsCmd,
-		closeNotifier)
+		ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -2417,18 +3542,27 @@ func handleGetMiningInfo(s *rpcServer, cmd interface{}, closeNotifier <-chan boo
 }
 
 // handleGetNetTotals implements the getnettotals command.
-func handleGetNetTotals(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetNetTotals(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	totalBytesRecv, totalBytesSent := s.cfg.ConnMgr.NetTotals()
+	uploadTarget := s.cfg.ConnMgr.UploadTarget()
 	reply := &btcjson.GetNetTotalsResult{
 		TotalBytesRecv: totalBytesRecv,
 		TotalBytesSent: totalBytesSent,
 		TimeMillis:     time.Now().UTC().UnixNano() / int64(time.Millisecond),
+		UploadTarget: btcjson.GetNetTotalsUploadTarget{
+			TimeFrame:             int64(uploadTarget.Timeframe().Seconds()),
+			Target:                uploadTarget.Target(),
+			TargetReached:         uploadTarget.Reached(),
+			ServeHistoricalBlocks: uploadTarget.ServeHistorical(),
+			BytesLeftInCycle:      uploadTarget.BytesLeft(),
+			TimeLeftInCycle:       int64(uploadTarget.TimeLeftInCycle().Seconds()),
+		},
 	}
 	return reply, nil
 }
 
 // handleGetNetworkHashPS implements the getnetworkhashps command.
-func handleGetNetworkHashPS(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetNetworkHashPS(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	// Note: All valid error return paths should return an int64.
 	// Literal zeros are inferred as int, and won't coerce to int64
 	// because the return value is an interface{}.
@@ -2522,7 +3656,7 @@ func handleGetNetworkHashPS(s *rpcServer, cmd interface{}, closeNotifier <-chan
 }
 
 // handleGetNetworkInfo implements the getnetworkinfo command.
-func handleGetNetworkInfo(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetNetworkInfo(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	ss := s.cfg.Chain.BestSnapshot()
 	bestHeader, err := s.cfg.Chain.HeaderByHeight(ss.Height)
 	if err != nil {
@@ -2534,14 +3668,16 @@ func handleGetNetworkInfo(s *rpcServer, cmd interface{}, closeNotifier <-chan bo
 	var localAddrs []btcjson.LocalAddressesResult
 	var ipv4Reachable, ipv6Reachable bool
 	for _, addr := range s.cfg.AddrMgr.LocalAddresses() {
+		host, _, _ := net.SplitHostPort(addrmgr.NetAddressKey(addr.NA))
 		localAddrs = append(localAddrs, btcjson.LocalAddressesResult{
-			Address: addr.NA.IP.String(),
+			Address: host,
 			Port:    addr.NA.Port,
 			Score:   int32(addr.Score),
 		})
-		if addr.NA.IP.To4() != nil {
+		switch addr.NA.NetworkID() {
+		case wire.NetworkIPv4:
 			ipv4Reachable = true
-		} else {
+		case wire.NetworkIPv6:
 			ipv6Reachable = true
 		}
 	}
@@ -2565,11 +3701,12 @@ func handleGetNetworkInfo(s *rpcServer, cmd interface{}, closeNotifier <-chan bo
 		timeOffset = int64(time.Since(bestHeader.Timestamp).Seconds())
 	}
 
+	relayFee := s.cfg.TxMemPool.Policy().MinRelayTxFee.ToBCH()
 	reply := &btcjson.GetNetworkInfoResult{
 		ProtocolVersion: int32(wire.ProtocolVersion),
 		Version:         version.Numeric(),
 		Connections:     s.cfg.ConnMgr.ConnectedCount(),
-		IncrementalFee:  cfg.MinRelayTxFee,
+		IncrementalFee:  relayFee,
 		LocalAddresses:  localAddrs,
 		LocalRelay:      !cfg.BlocksOnly,
 		LocalServices:   s.cfg.Services.String(),
@@ -2590,20 +3727,86 @@ func handleGetNetworkInfo(s *rpcServer, cmd interface{}, closeNotifier <-chan bo
 
 				ProxyRandomizeCredentials: cfg.TorIsolation,
 
-				Proxy:     onionProxy,
-				Reachable: cfg.Proxy != "" || cfg.OnionProxy != "",
-			},
-		},
-		RelayFee:   cfg.MinRelayTxFee,
-		SubVersion: ver.UserAgent,
-		TimeOffset: timeOffset,
-		Warnings:   warnings,
+				Proxy:     onionProxy,
+				Reachable: cfg.Proxy != "" || cfg.OnionProxy != "",
+			},
+		},
+		RelayFee:   relayFee,
+		SubVersion: ver.UserAgent,
+		TimeOffset: timeOffset,
+		Warnings:   warnings,
+	}
+	return reply, nil
+}
+
+// handleGetNextBlockParams implements the getnextblockparams command.
+func handleGetNextBlockParams(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	c := cmd.(*btcjson.GetNextBlockParamsCmd)
+
+	// The parameters are calculated for the tip of the main chain unless a
+	// previous block is provided.
+	var prevHash *chainhash.Hash
+	if c.PrevHash != nil && *c.PrevHash != "" {
+		hash, err := chainhash.NewHashFromStr(*c.PrevHash)
+		if err != nil {
+			return nil, rpcDecodeHexError(*c.PrevHash)
+		}
+		if _, err := s.cfg.Chain.HeaderByHash(hash); err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: "Block not found",
+			}
+		}
+		prevHash = hash
+	}
+
+	// Decode the headers extending the previous block, if any.
+	var headers []wire.BlockHeader
+	if c.Headers != nil {
+		headers = make([]wire.BlockHeader, len(*c.Headers))
+		for i, headerHex := range *c.Headers {
+			serializedHeader, err := hex.DecodeString(headerHex)
+			if err != nil {
+				return nil, rpcDecodeHexError(headerHex)
+			}
+			err = headers[i].Deserialize(bytes.NewReader(serializedHeader))
+			if err != nil {
+				return nil, &btcjson.RPCError{
+					Code:    btcjson.ErrRPCDeserialization,
+					Message: "Header decode failed: " + err.Error(),
+				}
+			}
+		}
+	}
+
+	var timestamp time.Time
+	if c.Timestamp != nil {
+		timestamp = time.Unix(*c.Timestamp, 0)
 	}
-	return reply, nil
+	params, err := s.cfg.Chain.CalcNextBlockParams(prevHash, headers,
+		timestamp)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: err.Error(),
+		}
+	}
+
+	return &btcjson.GetNextBlockParamsResult{
+		PreviousHash: params.PrevHash.String(),
+		Height:       params.Height,
+		Time:         params.Timestamp.Unix(),
+		Bits:         strconv.FormatInt(int64(params.Bits), 16),
+		Target:       fmt.Sprintf("%064x", blockchain.CompactToBig(params.Bits)),
+		Difficulty:   getDifficultyRatio(params.Bits, s.cfg.ChainParams),
+		MedianTime:   params.MedianTimePast.Unix(),
+		MinTime:      params.MedianTimePast.Unix() + 1,
+		SizeLimit:    params.MaxBlockSize,
+	}, nil
 }
 
 // handleGetPeerInfo implements the getpeerinfo command.
-func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetPeerInfo(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	peers := s.cfg.ConnMgr.ConnectedPeers()
 	syncPeerID := s.cfg.SyncMgr.SyncPeerID()
 	infos := make([]*btcjson.GetPeerInfoResult, 0, len(peers))
@@ -2632,19 +3835,30 @@ func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeNotifier <-chan bool)
 			Whitelisted:    p.IsWhitelisted(),
 			FeeFilter:      p.FeeFilter(),
 			SyncNode:       statsSnap.ID == syncPeerID,
+			MinPing:        float64(statsSnap.MinPingMicros),
+
+			BytesSentPerMsg: statsSnap.BytesSentPerMsg,
+			BytesRecvPerMsg: statsSnap.BytesRecvPerMsg,
 		}
 		if p.ToPeer().LastPingNonce() != 0 {
 			wait := float64(time.Since(statsSnap.LastPingTime).Nanoseconds())
 			// We actually want microseconds.
 			info.PingWait = wait / 1000
 		}
+		info.TxInvsReceived, info.TxInvsNew = p.TxInvStats()
+		if info.TxInvsReceived > 0 {
+			info.TxInvHitRate = float64(info.TxInvsNew) /
+				float64(info.TxInvsReceived)
+		}
+		info.CmpctBlocksReceived, info.CmpctBlocksReconstructed,
+			info.CmpctBlocksRoundTrip = p.CmpctBlockStats()
 		infos = append(infos, info)
 	}
 	return infos, nil
 }
 
 // handleGetRawMempool implements the getrawmempool command.
-func handleGetRawMempool(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetRawMempool(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.GetRawMempoolCmd)
 	mp := s.cfg.TxMemPool
 
@@ -2664,7 +3878,7 @@ func handleGetRawMempool(s *rpcServer, cmd interface{}, closeNotifier <-chan boo
 }
 
 // handleGetRawTransaction implements the getrawtransaction command.
-func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetRawTransaction(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.GetRawTransactionCmd)
 
 	// Convert the provided transaction hash hex to a Hash.
@@ -2674,8 +3888,10 @@ func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeNotifier <-chan
 	}
 
 	verbose := false
+	includeSpent := false
 	if c.Verbose != nil {
 		verbose = *c.Verbose != 0
+		includeSpent = *c.Verbose >= 2
 	}
 
 	// Try to fetch the transaction from the memory pool and if that fails,
@@ -2779,11 +3995,29 @@ func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeNotifier <-chan
 	if err != nil {
 		return nil, err
 	}
+
+	// Include the spent outputs along with the fee and signature checks
+	// of the transaction when requested.
+	if includeSpent && !blockchain.IsCoinBaseTx(mtx) {
+		spent, err := fetchSpentOutputs(s, mtx, blkHash)
+		if err != nil {
+			return nil, err
+		}
+		addSpentOutputs(rawTxn, mtx, spent, s.cfg.ChainParams)
+
+		// Transactions which do not execute successfully under the
+		// current rules, such as old non-standard ones, are reported
+		// without signature checks.
+		sigChecks, err := countSigChecks(mtx, spent)
+		if err == nil {
+			rawTxn.SigChecks = &sigChecks
+		}
+	}
 	return *rawTxn, nil
 }
 
 // handleGetTxOut handles gettxout commands.
-func handleGetTxOut(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetTxOut(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.GetTxOutCmd)
 
 	// Convert the provided transaction hash hex to a Hash.
@@ -2888,8 +4122,29 @@ func handleGetTxOut(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (i
 	return txOutReply, nil
 }
 
+// handleGetTxOutSetInfo implements the gettxoutsetinfo command.
+func handleGetTxOutSetInfo(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	stats, err := s.cfg.Chain.FetchUtxoStats(ctx)
+	if ctx.Err() != nil {
+		return nil, ErrClientQuit
+	}
+	if err != nil {
+		context := "Failed to calculate UTXO set statistics"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return &btcjson.GetTxOutSetInfoResult{
+		Height:         stats.Height,
+		BestBlock:      stats.Hash.String(),
+		TxOuts:         stats.Utxos,
+		SerializedSize: stats.SerializedSize,
+		ECMH:           stats.Commitment.String(),
+		TotalAmount:    bchutil.Amount(stats.TotalAmount).ToBCH(),
+	}, nil
+}
+
 // handleGetTxOutProof implements the gettxoutproof command.
-func handleGetTxOutProof(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleGetTxOutProof(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.GetTxOutProofCmd)
 
 	// check for a excessively high number of transactions
@@ -3029,7 +4284,7 @@ func handleGetTxOutProof(s *rpcServer, cmd interface{}, closeNotifier <-chan boo
 }
 
 // handleVerifyTxOutProof implements the verifytxoutproof command.
-func handleVerifyTxOutProof(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleVerifyTxOutProof(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.VerifyTxOutProofCmd)
 
 	// decode proof from hex to []bytes
@@ -3065,45 +4320,20 @@ func handleVerifyTxOutProof(s *rpcServer, cmd interface{}, closeNotifier <-chan
 		}
 	}
 
-	// lookup the block using the merkleroot to see if it really exists
-	// use the first transaction to get the block and then compare merkleRoot
-	blockRegion, err := s.cfg.TxIndex.TxBlockRegion(mBlock.GetMatches()[0])
-
-	if err != nil || blockRegion == nil {
-		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCNoTxInfo,
-			Message: "Unable to find block using matched transaction",
-		}
+	// the proof only commits to the transactions when the merkle root of
+	// the traversed tree is the one of the block header it contains
+	if !msg.Header.MerkleRoot.IsEqual(merkleRoot) {
+		return []string{}, nil
 	}
 
-	// load block from database
-	var blkBytes []byte
-	err = s.cfg.DB.View(func(dbTx database.Tx) error {
-		var err error
-		blkBytes, err = dbTx.FetchBlock(blockRegion.Hash)
-		return err
-	})
-	if err != nil {
+	// the block must be in the main chain for the transactions to be
+	// confirmed.  since the proof contains the header, the block can be
+	// found by its hash without a transaction index.
+	blockHash := msg.Header.BlockHash()
+	if !s.cfg.Chain.MainChainHasBlock(&blockHash) {
 		return nil, &btcjson.RPCError{
 			Code:    btcjson.ErrRPCBlockNotFound,
-			Message: "Can't read block from disk",
-		}
-	}
-
-	block, err := bchutil.NewBlockFromBytes(blkBytes)
-	if err != nil {
-		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCDeserialization,
-			Message: "Failed to deserialize block",
-		}
-	}
-
-	// compare merkle root from loaded block and that returned from our tree
-	// traversal
-	if !block.MsgBlock().Header.MerkleRoot.IsEqual(merkleRoot) {
-		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCInvalidAddressOrKey,
-			Message: "Merkle root clock header check failed",
+			Message: "Block not found in chain",
 		}
 	}
 
@@ -3118,7 +4348,7 @@ func handleVerifyTxOutProof(s *rpcServer, cmd interface{}, closeNotifier <-chan
 }
 
 // handleInvalidateBlock implements the invalidateblock command
-func handleInvalidateBlock(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleInvalidateBlock(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.InvalidateBlockCmd)
 
 	hash, err := chainhash.NewHashFromStr(c.BlockHash)
@@ -3130,7 +4360,7 @@ func handleInvalidateBlock(s *rpcServer, cmd interface{}, closeNotifier <-chan b
 }
 
 // handleReconsiderBlock implements the reconsiderblock command
-func handleReconsiderBlock(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleReconsiderBlock(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.ReconsiderBlockCmd)
 
 	hash, err := chainhash.NewHashFromStr(c.BlockHash)
@@ -3141,8 +4371,21 @@ func handleReconsiderBlock(s *rpcServer, cmd interface{}, closeNotifier <-chan b
 	return nil, s.cfg.Chain.ReconsiderBlock(hash)
 }
 
+// handleReloadConfig implements the reloadconfig command.
+func handleReloadConfig(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	result, err := s.cfg.ReloadConfig()
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Unable to reload the configuration: " + err.Error(),
+		}
+	}
+
+	return result, nil
+}
+
 // handleHelp implements the help command.
-func handleHelp(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleHelp(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.HelpCmd)
 
 	// Provide a usage overview of all commands when no specific command
@@ -3180,8 +4423,26 @@ func handleHelp(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (inter
 	return help, nil
 }
 
+// handleListBanned implements the listbanned command.
+func handleListBanned(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	now := time.Now()
+	bans := s.cfg.BanMgr.Bans()
+	results := make([]btcjson.ListBannedResult, 0, len(bans))
+	for _, ban := range bans {
+		results = append(results, btcjson.ListBannedResult{
+			Address:       ban.Subnet.String(),
+			BanCreated:    ban.Created.Unix(),
+			BannedUntil:   ban.Until.Unix(),
+			BanDuration:   int64(ban.Until.Sub(ban.Created) / time.Second),
+			TimeRemaining: int64(ban.Until.Sub(now) / time.Second),
+			BanReason:     ban.Reason,
+		})
+	}
+	return results, nil
+}
+
 // handlePing implements the ping command.
-func handlePing(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handlePing(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	// Ask server to ping \o_
 	nonce, err := wire.RandomUint64()
 	if err != nil {
@@ -3411,7 +4672,7 @@ func fetchMempoolTxnsForAddress(s *rpcServer, addr bchutil.Address, numToSkip, n
 }
 
 // handleSearchRawTransactions implements the searchrawtransactions command.
-func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleSearchRawTransactions(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	// Respond with an error if the address index is not enabled.
 	addrIndex := s.cfg.AddrIndex
 	if addrIndex == nil {
@@ -3675,7 +4936,7 @@ func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeNotifier <-
 }
 
 // handleSendRawTransaction implements the sendrawtransaction command.
-func handleSendRawTransaction(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleSendRawTransaction(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.SendRawTransactionCmd)
 	// Deserialize and send off to tx relay
 	hexStr := c.HexTx
@@ -3776,8 +5037,71 @@ func handleSendRawTransaction(s *rpcServer, cmd interface{}, closeNotifier <-cha
 	return tx.Hash().String(), nil
 }
 
+// handleSetBan implements the setban command.
+func handleSetBan(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	c := cmd.(*btcjson.SetBanCmd)
+
+	subnet, err := banmgr.ParseSubnet(c.SubNet)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCClientInvalidIPOrSubnet,
+			Message: "Error: Invalid IP/Subnet",
+		}
+	}
+
+	switch c.SubCmd {
+	case btcjson.SBAdd:
+		// A ban time of zero uses the default ban duration.  The ban time
+		// is either a duration in seconds or, when absolute, the unix
+		// time the ban expires at.
+		var until time.Time
+		if c.BanTime != nil && *c.BanTime > 0 {
+			if c.Absolute != nil && *c.Absolute {
+				until = time.Unix(*c.BanTime, 0)
+			} else {
+				until = time.Now().Add(time.Duration(*c.BanTime) *
+					time.Second)
+			}
+		}
+		err = s.cfg.BanMgr.Ban(subnet, until)
+		if err == banmgr.ErrAlreadyBanned {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCClientNodeAlreadyAdded,
+				Message: "Error: IP/Subnet already banned",
+			}
+		}
+		if err != nil {
+			return nil, internalRPCError(err.Error(), "Unable to ban")
+		}
+		s.cfg.ConnMgr.DisconnectBySubnet(subnet)
+
+	case btcjson.SBRemove:
+		err = s.cfg.BanMgr.Unban(subnet)
+		if err == banmgr.ErrNotBanned {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCClientInvalidIPOrSubnet,
+				Message: "Error: Unban failed. Requested " +
+					"address/subnet was not previously " +
+					"manually banned.",
+			}
+		}
+		if err != nil {
+			return nil, internalRPCError(err.Error(), "Unable to unban")
+		}
+
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "invalid subcommand for setban",
+		}
+	}
+
+	// no data returned unless an error.
+	return nil, nil
+}
+
 // handleSetGenerate implements the setgenerate command.
-func handleSetGenerate(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleSetGenerate(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.SetGenerateCmd)
 
 	// Disable generation regardless of the provided generate flag if the
@@ -3812,8 +5136,98 @@ func handleSetGenerate(s *rpcServer, cmd interface{}, closeNotifier <-chan bool)
 	return nil, nil
 }
 
+// handleSetRelayPolicy implements the setrelaypolicy command.
+func handleSetRelayPolicy(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	c := cmd.(*btcjson.SetRelayPolicyCmd)
+	options := c.Options
+	if options == nil {
+		options = &btcjson.RelayPolicyOptions{}
+	}
+
+	// Validate all of the parameters before updating any of them.
+	var minRelayTxFee, dustRelayFee bchutil.Amount
+	var err error
+	if options.MinRelayTxFee != nil {
+		minRelayTxFee, err = bchutil.NewAmount(*options.MinRelayTxFee)
+		if err != nil {
+			return nil, rpcInvalidError("Invalid minrelaytxfee: %v", err)
+		}
+	}
+	if options.DustRelayFee != nil {
+		dustRelayFee, err = bchutil.NewAmount(*options.DustRelayFee)
+		if err != nil {
+			return nil, rpcInvalidError("Invalid dustrelayfee: %v", err)
+		}
+	}
+	limits := []struct {
+		name  string
+		value *int
+	}{
+		{"maxtxsigchecks", options.MaxTxSigChecks},
+		{"datacarriersize", options.DataCarrierSize},
+		{"maxdatacarriers", options.MaxDataCarriers},
+	}
+	for _, limit := range limits {
+		if limit.value != nil && *limit.value < 0 {
+			return nil, rpcInvalidError("The %s parameter may not "+
+				"be less than 0", limit.name)
+		}
+	}
+	var protocols [][]byte
+	if options.DataCarrierProtocols != nil {
+		for _, prefix := range *options.DataCarrierProtocols {
+			protocol, err := hex.DecodeString(prefix)
+			if err != nil || len(protocol) == 0 {
+				return nil, rpcDecodeHexError(prefix)
+			}
+			protocols = append(protocols, protocol)
+		}
+	}
+
+	removed := s.cfg.TxMemPool.UpdatePolicy(func(policy *mempool.Policy) {
+		if options.MinRelayTxFee != nil {
+			policy.MinRelayTxFee = minRelayTxFee
+		}
+		if options.DustRelayFee != nil {
+			policy.DustRelayFee = dustRelayFee
+		}
+		if options.MaxTxSigChecks != nil {
+			policy.MaxTxSigChecks = *options.MaxTxSigChecks
+		}
+		if options.DataCarrierSize != nil {
+			policy.DataCarrier.MaxSize = *options.DataCarrierSize
+		}
+		if options.MaxDataCarriers != nil {
+			policy.DataCarrier.MaxOutputs = *options.MaxDataCarriers
+		}
+		if options.DataCarrierProtocols != nil {
+			policy.DataCarrier.Protocols = protocols
+		}
+	})
+
+	policy := s.cfg.TxMemPool.Policy()
+	result := &btcjson.SetRelayPolicyResult{
+		MinRelayTxFee:        policy.MinRelayTxFee.ToBCH(),
+		DustRelayFee:         policy.DustRelayFee.ToBCH(),
+		MaxTxSigChecks:       policy.MaxTxSigChecks,
+		DataCarrierSize:      policy.DataCarrier.MaxSize,
+		MaxDataCarriers:      policy.DataCarrier.MaxOutputs,
+		DataCarrierProtocols: make([]string, 0, len(policy.DataCarrier.Protocols)),
+		Removed:              make([]string, 0, len(removed)),
+	}
+	for _, protocol := range policy.DataCarrier.Protocols {
+		result.DataCarrierProtocols = append(result.DataCarrierProtocols,
+			hex.EncodeToString(protocol))
+	}
+	for _, tx := range removed {
+		result.Removed = append(result.Removed, tx.Hash().String())
+	}
+
+	return result, nil
+}
+
 // handleStop implements the stop command.
-func handleStop(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleStop(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	select {
 	case s.requestProcessShutdown <- struct{}{}:
 	default:
@@ -3822,7 +5236,7 @@ func handleStop(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (inter
 }
 
 // handleSubmitBlock implements the submitblock command.
-func handleSubmitBlock(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleSubmitBlock(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.SubmitBlockCmd)
 
 	// Deserialize the submitted block.
@@ -3845,87 +5259,189 @@ func handleSubmitBlock(s *rpcServer, cmd interface{}, closeNotifier <-chan bool)
 
 	// Process this block using the same rules as blocks coming from other
 	// nodes.  This will in turn relay it to the network like normal.
+	verbose := c.Options != nil && c.Options.Verbose
 	_, err = s.cfg.SyncMgr.SubmitBlock(block, blockchain.BFNone)
 	if err != nil {
-		return fmt.Sprintf("rejected: %s", err.Error()), nil
+		// Blocks which are rejected only because they are already
+		// known are not saved since there is nothing wrong with them.
+		var savedFile string
+		rerr, ok := err.(blockchain.RuleError)
+		isDuplicate := ok && rerr.ErrorCode == blockchain.ErrDuplicateBlock
+		if cfg.SaveRejectedBlocks && !isDuplicate {
+			savedFile = saveRejectedBlock(block, serializedBlock)
+		}
+		if !verbose {
+			return fmt.Sprintf("rejected: %s", err.Error()), nil
+		}
+		result := submitBlockRejection(block, err)
+		result.SavedFile = savedFile
+		return result, nil
 	}
 
 	rpcsLog.Infof("Accepted block %s via submitblock", block.Hash())
+	if verbose {
+		return &btcjson.SubmitBlockResult{
+			Hash:     block.Hash().String(),
+			Accepted: true,
+		}, nil
+	}
 	return nil, nil
 }
 
-// handleUptime implements the uptime command.
-func handleUptime(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
-	return time.Now().Unix() - s.cfg.StartupTime, nil
+// submitBlockRejection returns the verbose submitblock result describing why
+// the passed block was rejected with the passed error, including the location
+// of the offending transaction, input, and opcode when the rule violation was
+// caused by one.
+func submitBlockRejection(block *bchutil.Block, err error) *btcjson.SubmitBlockResult {
+	result := &btcjson.SubmitBlockResult{
+		Hash:   block.Hash().String(),
+		Reason: err.Error(),
+	}
+	rerr, ok := err.(blockchain.RuleError)
+	if !ok {
+		return result
+	}
+	result.ErrorCode = rerr.ErrorCode.String()
+	detail := rerr.Detail
+	if detail == nil {
+		return result
+	}
+	if detail.TxIndex >= 0 {
+		txIndex := detail.TxIndex
+		result.TxIndex = &txIndex
+	}
+	result.TxID = detail.TxHash.String()
+	if detail.InputIndex >= 0 {
+		inputIndex := detail.InputIndex
+		result.InputIndex = &inputIndex
+	}
+	if detail.ScriptErr != nil {
+		if serr, ok := detail.ScriptErr.(txscript.Error); ok {
+			result.ScriptError = serr.ErrorCode.String()
+		} else {
+			result.ScriptError = detail.ScriptErr.Error()
+		}
+	}
+	if detail.ScriptIndex >= 0 {
+		scriptIndex, opcodeIndex := detail.ScriptIndex, detail.OpcodeIndex
+		result.ScriptIndex = &scriptIndex
+		result.OpcodeIndex = &opcodeIndex
+	}
+	return result
 }
 
-// handleValidateAddress implements the validateaddress command.
-func handleValidateAddress(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
-	c := cmd.(*btcjson.ValidateAddressCmd)
-
-	result := btcjson.ValidateAddressChainResult{}
-	addr, err := bchutil.DecodeAddress(c.Address, s.cfg.ChainParams)
+// saveRejectedBlock hex encodes the passed serialized block to a file named
+// after its hash in the rejected blocks directory so it can be inspected or
+// submitted again later, and returns the path of the file.  Failing to save
+// the block is only logged since it does not change the outcome of the
+// submission, in which case the returned path is empty.
+func saveRejectedBlock(block *bchutil.Block, serializedBlock []byte) string {
+	dir := filepath.Join(cfg.DataDir, rejectedBlocksDirName)
+	path := filepath.Join(dir, block.Hash().String()+".hex")
+	err := os.MkdirAll(dir, 0700)
+	if err == nil {
+		data := []byte(hex.EncodeToString(serializedBlock))
+		err = ioutil.WriteFile(path, data, 0600)
+	}
 	if err != nil {
-		// Return the default value (false) for IsValid.
-		return result, nil
+		rpcsLog.Warnf("Unable to save rejected block %s: %v",
+			block.Hash(), err)
+		return ""
 	}
+	rpcsLog.Infof("Saved block %s rejected via submitblock to %s",
+		block.Hash(), path)
+	return path
+}
 
-	result.Address = addr.EncodeAddress()
-	result.IsValid = true
+// maxTestMempoolAcceptTxns is the maximum number of transactions which may be
+// tested by a single testmempoolaccept request.
+const maxTestMempoolAcceptTxns = 25
 
-	return result, nil
-}
+// handleTestMempoolAccept implements the testmempoolaccept command.
+func handleTestMempoolAccept(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	c := cmd.(*btcjson.TestMempoolAcceptCmd)
 
-func verifyChain(s *rpcServer, level, depth int32) error {
-	best := s.cfg.Chain.BestSnapshot()
-	finishHeight := best.Height - depth
-	if finishHeight < 0 {
-		finishHeight = 0
+	if len(c.RawTxs) == 0 || len(c.RawTxs) > maxTestMempoolAcceptTxns {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Between 1 and %d transactions may be "+
+				"tested", maxTestMempoolAcceptTxns),
+		}
 	}
-	rpcsLog.Infof("Verifying chain for %d blocks at level %d",
-		best.Height-finishHeight, level)
 
-	for height := best.Height; height > finishHeight; height-- {
-		// Level 0 just looks up the block.
-		block, err := s.cfg.Chain.BlockByHeight(height)
+	txns := make([]*bchutil.Tx, 0, len(c.RawTxs))
+	for _, hexStr := range c.RawTxs {
+		if len(hexStr)%2 != 0 {
+			hexStr = "0" + hexStr
+		}
+		serializedTx, err := hex.DecodeString(hexStr)
 		if err != nil {
-			rpcsLog.Errorf("Verify is unable to fetch block at "+
-				"height %d: %v", height, err)
-			return err
+			return nil, rpcDecodeHexError(hexStr)
 		}
-
-		magneticAnomalyActive := false
-		upgrade9Active := false
-
-		prevHeight := height - 1
-		if prevHeight > 0 {
-			if height > s.cfg.ChainParams.MagneticAnonomalyForkHeight {
-				magneticAnomalyActive = true
-			}
-			if height > s.cfg.ChainParams.Upgrade9ForkHeight {
-				upgrade9Active = true
+		var msgTx wire.MsgTx
+		err = msgTx.Deserialize(bytes.NewReader(serializedTx))
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCDeserialization,
+				Message: "TX decode failed: " + err.Error(),
 			}
 		}
+		txns = append(txns, bchutil.NewTx(&msgTx))
+	}
 
-		// Level 1 does basic chain sanity checks.
-		if level > 0 {
-			err := blockchain.CheckBlockSanity(block,
-				s.cfg.ChainParams.PowLimit, s.cfg.TimeSource, magneticAnomalyActive, upgrade9Active)
-			if err != nil {
-				rpcsLog.Errorf("Verify is unable to validate "+
-					"block at hash %v height %d: %v",
-					block.Hash(), height, err)
-				return err
+	results := make([]btcjson.TestMempoolAcceptResult, 0, len(txns))
+	for _, acceptance := range s.cfg.TxMemPool.CheckAcceptance(txns) {
+		result := btcjson.TestMempoolAcceptResult{
+			TxID:    acceptance.Tx.Hash().String(),
+			Allowed: acceptance.Allowed,
+		}
+		if acceptance.Err != nil {
+			// Only rule errors mean the transaction would be
+			// rejected as opposed to something actually going
+			// wrong.
+			if _, ok := acceptance.Err.(mempool.RuleError); !ok {
+				context := "Failed to test transaction"
+				return nil, internalRPCError(acceptance.Err.Error(), context)
 			}
+			result.RejectReason = acceptance.Err.Error()
+		}
+		if acceptance.Allowed {
+			fee := bchutil.Amount(acceptance.Fee).ToBCH()
+			sigChecks := acceptance.ScriptMetrics.SigChecks
+			result.Size = int32(acceptance.Tx.MsgTx().SerializeSize())
+			result.Fee = &fee
+			result.SigChecks = &sigChecks
 		}
+		results = append(results, result)
 	}
-	rpcsLog.Infof("Chain verify completed successfully")
 
-	return nil
+	return results, nil
+}
+
+// handleUptime implements the uptime command.
+func handleUptime(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	return time.Now().Unix() - s.cfg.StartupTime, nil
+}
+
+// handleValidateAddress implements the validateaddress command.
+func handleValidateAddress(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
+	c := cmd.(*btcjson.ValidateAddressCmd)
+
+	result := btcjson.ValidateAddressChainResult{}
+	addr, err := bchutil.DecodeAddress(c.Address, s.cfg.ChainParams)
+	if err != nil {
+		// Return the default value (false) for IsValid.
+		return result, nil
+	}
+
+	result.Address = addr.EncodeAddress()
+	result.IsValid = true
+
+	return result, nil
 }
 
 // handleVerifyChain implements the verifychain command.
-func handleVerifyChain(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleVerifyChain(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.VerifyChainCmd)
 
 	var checkLevel, checkDepth int32
@@ -3936,12 +5452,15 @@ func handleVerifyChain(s *rpcServer, cmd interface{}, closeNotifier <-chan bool)
 		checkDepth = *c.CheckDepth
 	}
 
-	err := verifyChain(s, checkLevel, checkDepth)
+	err := s.cfg.Chain.VerifyChain(ctx, checkLevel, checkDepth)
+	if ctx.Err() != nil {
+		return nil, ErrClientQuit
+	}
 	return err == nil, nil
 }
 
 // handleVerifyMessage implements the verifymessage command.
-func handleVerifyMessage(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleVerifyMessage(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	c := cmd.(*btcjson.VerifyMessageCmd)
 
 	// Decode the provided address.
@@ -4006,7 +5525,7 @@ func handleVerifyMessage(s *rpcServer, cmd interface{}, closeNotifier <-chan boo
 // handleVersion implements the version command.
 //
 // NOTE: This is a btcsuite extension ported from github.com/decred/dcrd.
-func handleVersion(s *rpcServer, cmd interface{}, closeNotifier <-chan bool) (interface{}, error) {
+func handleVersion(s *rpcServer, cmd interface{}, ctx context.Context) (interface{}, error) {
 	result := map[string]btcjson.VersionResult{
 		"btcdjsonrpcapi": {
 			VersionString: jsonrpcSemverString,
@@ -4023,8 +5542,9 @@ type rpcServer struct {
 	started                int32
 	shutdown               int32
 	cfg                    rpcserverConfig
-	authsha                [sha256.Size]byte
-	limitauthsha           [sha256.Size]byte
+	credentials            []*rpcCredential
+	credentialsLock        sync.RWMutex
+	quotas                 rpcQuotas
 	ntfnMgr                *wsNotificationManager
 	numClients             int32
 	statusLines            map[int]string
@@ -4054,10 +5574,30 @@ func (s *rpcServer) Stop() error {
 	s.ntfnMgr.WaitForShutdown()
 	close(s.quit)
 	s.wg.Wait()
+	if cfg.rpcCookie != "" {
+		if err := os.Remove(cfg.RPCCookieFile); err != nil {
+			rpcsLog.Warnf("Unable to remove cookie file: %v", err)
+		}
+	}
 	rpcsLog.Infof("RPC server shutdown complete")
 	return nil
 }
 
+// requestContext returns a context derived from the passed one which is also
+// canceled once the RPC server is shutting down, so commands which take a long
+// time return promptly instead of holding up the shutdown.
+func (s *rpcServer) requestContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	go func() {
+		select {
+		case <-s.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
 // RequestedProcessShutdown returns a channel that is sent to when an authorized
 // RPC client requests the process to shutdown.  If the request can not be read
 // immediately, it is dropped.
@@ -4079,6 +5619,47 @@ func (s *rpcServer) NotifyNewTransactions(txns []*mempool.TxDesc) {
 	}
 }
 
+// NotifyDoubleSpend notifies websocket clients of the passed double spend of a
+// transaction in the mempool.
+func (s *rpcServer) NotifyDoubleSpend(ds *mempool.DoubleSpend) {
+	s.ntfnMgr.NotifyDoubleSpend(ds)
+}
+
+// authenticate returns the credential of the RPC user matching the passed
+// username and password, or nil when there is no such user.
+//
+// This function is safe for concurrent access.
+func (s *rpcServer) authenticate(user, password string) *rpcCredential {
+	s.credentialsLock.RLock()
+	defer s.credentialsLock.RUnlock()
+	return authenticateUser(s.credentials, user, password)
+}
+
+// setCredentials replaces the credentials of the RPC users.  Clients that
+// already authenticated keep the credential they authenticated with.
+//
+// This function is safe for concurrent access.
+func (s *rpcServer) setCredentials(credentials []*rpcCredential) {
+	s.credentialsLock.Lock()
+	s.credentials = credentials
+	s.credentialsLock.Unlock()
+}
+
+// cookieCredential returns the credential of the cookie stored in the cookie
+// file, or nil when the cookie is not in use.
+//
+// This function is safe for concurrent access.
+func (s *rpcServer) cookieCredential() *rpcCredential {
+	s.credentialsLock.RLock()
+	defer s.credentialsLock.RUnlock()
+	for _, credential := range s.credentials {
+		if credential.user == rpcCookieUser {
+			return credential
+		}
+	}
+	return nil
+}
+
 // limitConnections responds with a 503 service unavailable and returns true if
 // adding another client would exceed the maximum allow RPC clients.
 //
@@ -4115,45 +5696,36 @@ func (s *rpcServer) decrementClients() {
 
 // checkAuth checks the HTTP Basic authentication supplied by a wallet
 // or RPC client in the HTTP request r.  If the supplied authentication
-// does not match the username and password expected, a non-nil error is
+// does not match any of the configured users, a non-nil error is
 // returned.
 //
 // This check is time-constant.
 //
-// The first bool return value signifies auth success (true if successful) and
-// the second bool return value specifies whether the user can change the state
-// of the server (true) or whether the user is limited (false). The second is
-// always false if the first is.
-func (s *rpcServer) checkAuth(r *http.Request, require bool) (bool, bool, error) {
-	authhdr := r.Header["Authorization"]
-	if len(authhdr) <= 0 {
+// The returned credential is the authenticated user, which determines the
+// methods it may call.  It is nil when no authentication is supplied and it is
+// not required.
+func (s *rpcServer) checkAuth(r *http.Request, require bool) (*rpcCredential, error) {
+	if len(r.Header["Authorization"]) <= 0 {
 		if require {
 			rpcsLog.Warnf("RPC authentication failure from %s",
 				r.RemoteAddr)
-			return false, false, errors.New("auth failure")
+			return nil, errors.New("auth failure")
 		}
 
-		return false, false, nil
-	}
-
-	authsha := sha256.Sum256([]byte(authhdr[0]))
-
-	// Check for limited auth first as in environments with limited users, those
-	// are probably expected to have a higher volume of calls
-	limitcmp := subtle.ConstantTimeCompare(authsha[:], s.limitauthsha[:])
-	if limitcmp == 1 {
-		return true, false, nil
+		return nil, nil
 	}
 
-	// Check for admin-level auth
-	cmp := subtle.ConstantTimeCompare(authsha[:], s.authsha[:])
-	if cmp == 1 {
-		return true, true, nil
+	user, password, ok := r.BasicAuth()
+	if ok {
+		credential := s.authenticate(user, password)
+		if credential != nil {
+			return credential, nil
+		}
 	}
 
-	// Request's auth doesn't match either user
+	// Request's auth doesn't match any user
 	rpcsLog.Warnf("RPC authentication failure from %s", r.RemoteAddr)
-	return false, false, errors.New("auth failure")
+	return nil, errors.New("auth failure")
 }
 
 // parsedRPCCmd represents a JSON-RPC request object that has been parsed into
@@ -4171,7 +5743,7 @@ type parsedRPCCmd struct {
 // command and runs the appropriate handler to reply to the command.  Any
 // commands which are not recognized or not implemented will return an error
 // suitable for use in replies.
-func (s *rpcServer) standardCmdResult(cmd *parsedRPCCmd, closeNotifier <-chan bool) (interface{}, error) {
+func (s *rpcServer) standardCmdResult(cmd *parsedRPCCmd, ctx context.Context) (interface{}, error) {
 	handler, ok := rpcHandlers[cmd.method]
 	if ok {
 		goto handled
@@ -4188,7 +5760,7 @@ func (s *rpcServer) standardCmdResult(cmd *parsedRPCCmd, closeNotifier <-chan bo
 	}
 	return nil, btcjson.ErrRPCMethodNotFound
 handled:
-	return handler(s, cmd.cmd, closeNotifier)
+	return handler(s, cmd.cmd, ctx)
 }
 
 // parseCmd parses a JSON-RPC request object into known concrete command.  The
@@ -4240,15 +5812,13 @@ func createMarshalledReply(rpcVersion string, id interface{}, result interface{}
 
 // processRequest determines the incoming request type (single or batched),
 // parses it and returns a marshalled response.
-func (s *rpcServer) processRequest(request *btcjson.Request, isAdmin bool, closeNotifier <-chan bool) []byte {
+func (s *rpcServer) processRequest(request *btcjson.Request, remoteAddr string, user *rpcCredential, ctx context.Context) []byte {
 	var result interface{}
 	var jsonErr error
 
-	if !isAdmin {
-		if _, ok := rpcLimited[request.Method]; !ok {
-			jsonErr = rpcInvalidError("limited user not " +
-				"authorized for this method")
-		}
+	if !user.authorized(request.Method) {
+		jsonErr = rpcInvalidError("user not authorized for this " +
+			"method")
 	}
 
 	if jsonErr == nil {
@@ -4276,9 +5846,12 @@ func (s *rpcServer) processRequest(request *btcjson.Request, isAdmin bool, close
 		parsedCmd := parseCmd(request)
 		if parsedCmd.err != nil {
 			jsonErr = parsedCmd.err
+		} else if release, err := s.quotas.Acquire(remoteAddr, user.user); err != nil {
+			jsonErr = rpcQuotaError(err)
 		} else {
 			result, jsonErr = s.standardCmdResult(parsedCmd,
-				closeNotifier)
+				ctx)
+			release()
 		}
 	}
 
@@ -4288,17 +5861,20 @@ func (s *rpcServer) processRequest(request *btcjson.Request, isAdmin bool, close
 		rpcsLog.Errorf("Failed to marshal reply: %v", err)
 		return nil
 	}
+	s.quotas.ChargeResponse(remoteAddr, user.user, len(msg))
 	return msg
 }
 
 // jsonRPCRead handles reading and responding to RPC messages.
-func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin bool) {
+func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, user *rpcCredential) {
 	if atomic.LoadInt32(&s.shutdown) != 0 {
 		return
 	}
 
-	// Setup a close notifier to stop any long polling routines.
-	closeNotifier := w.(http.CloseNotifier).CloseNotify()
+	// Cancel long-running commands, such as long polls, when the client
+	// disconnects or the server is shutting down.
+	ctx, cancel := s.requestContext(r.Context())
+	defer cancel()
 
 	// Read and close the JSON-RPC request body from the caller.
 	body, err := ioutil.ReadAll(r.Body)
@@ -4338,7 +5914,7 @@ func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin
 		}
 
 		if err == nil {
-			resp = s.processRequest(&req, isAdmin, closeNotifier)
+			resp = s.processRequest(&req, r.RemoteAddr, user, ctx)
 		}
 
 		if resp != nil {
@@ -4427,7 +6003,7 @@ func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin
 						continue
 					}
 
-					resp = s.processRequest(&req, isAdmin, closeNotifier)
+					resp = s.processRequest(&req, r.RemoteAddr, user, ctx)
 					if resp != nil {
 						results = append(results, resp)
 					}
@@ -4506,19 +6082,24 @@ func (s *rpcServer) Start() {
 		s.incrementClients()
 		defer s.decrementClients()
 
-		_, isAdmin, err := s.checkAuth(r, true)
+		user, err := s.checkAuth(r, true)
 		if err != nil {
 			jsonAuthFail(w)
 			return
 		}
 
 		// Read and respond to the request.
-		s.jsonRPCRead(w, r, isAdmin)
+		s.jsonRPCRead(w, r, user)
 	})
 
+	// Unauthenticated REST endpoints.
+	if cfg.RPCRest {
+		rpcServeMux.HandleFunc("/rest/", s.restHandler)
+	}
+
 	// Websocket endpoint.
 	rpcServeMux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		authenticated, isAdmin, err := s.checkAuth(r, false)
+		user, err := s.checkAuth(r, false)
 		if err != nil {
 			jsonAuthFail(w)
 			return
@@ -4535,7 +6116,7 @@ func (s *rpcServer) Start() {
 			http.Error(w, "400 Bad Request.", http.StatusBadRequest)
 			return
 		}
-		s.WebsocketHandler(ws, r.RemoteAddr, authenticated, isAdmin)
+		s.WebsocketHandler(ws, r.RemoteAddr, user)
 	})
 
 	for _, listener := range s.cfg.Listeners {
@@ -4597,6 +6178,15 @@ type rpcserverPeer interface {
 	// FeeFilter returns the requested current minimum fee rate for which
 	// transactions should be announced.
 	FeeFilter() int64
+
+	// TxInvStats returns the number of transactions announced by the peer
+	// and how many of them were not in the mempool when announced.
+	TxInvStats() (received, new uint64)
+
+	// CmpctBlockStats returns the number of compact blocks received from
+	// the peer and how many of them were reconstructed from the mempool
+	// alone or after fetching the missing transactions from the peer.
+	CmpctBlockStats() (received, reconstructed, roundTrip uint64)
 }
 
 // rpcserverConnManager represents a connection manager for use with the RPC
@@ -4632,6 +6222,15 @@ type rpcserverConnManager interface {
 	// error.
 	DisconnectByAddr(addr string) error
 
+	// DisconnectBySubnet disconnects all peers with an IP address within
+	// the provided subnet.  This applies to both inbound and outbound
+	// peers.
+	DisconnectBySubnet(subnet *net.IPNet)
+
+	// UploadTarget returns the upload target bytes sent to peers are
+	// tracked against.
+	UploadTarget() *connmgr.UploadTarget
+
 	// ConnectedCount returns the number of currently connected peers.
 	ConnectedCount() int32
 
@@ -4711,6 +6310,9 @@ type rpcserverConfig struct {
 	// AddrMgr is the server's instance of the AddressManager.
 	AddrMgr *addrmgr.AddrManager
 
+	// BanMgr is the server's instance of the BanManager.
+	BanMgr *banmgr.BanManager
+
 	// SyncMgr defines the sync manager for the RPC server to use.
 	SyncMgr rpcserverSyncManager
 
@@ -4743,9 +6345,17 @@ type rpcserverConfig struct {
 	// the mempool before they are mined into blocks.
 	FeeEstimator *mempool.FeeEstimator
 
+	// FirstSeen records when blocks and transactions were first seen.  It
+	// is nil when first seen tracking is disabled.
+	FirstSeen *firstseen.Tracker
+
 	// Services represents the services supported by this node.
 	Services wire.ServiceFlag
 
+	// ReloadConfig loads the configuration again and applies the options
+	// which may be changed while running.
+	ReloadConfig func() (*btcjson.ReloadConfigResult, error)
+
 	// RPCAuthTimeout is the number of seconds a connection to the
 	// RPC server is allowed to stay open without authenticating before it
 	// is closed. With keep-alives in a protected environment, 0 can be used
@@ -4763,15 +6373,16 @@ func newRPCServer(config *rpcserverConfig) (*rpcServer, error) {
 		requestProcessShutdown: make(chan struct{}),
 		quit:                   make(chan int),
 	}
-	if cfg.RPCUser != "" && cfg.RPCPass != "" {
-		login := cfg.RPCUser + ":" + cfg.RPCPass
-		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-		rpc.authsha = sha256.Sum256([]byte(auth))
-	}
-	if cfg.RPCLimitUser != "" && cfg.RPCLimitPass != "" {
-		login := cfg.RPCLimitUser + ":" + cfg.RPCLimitPass
-		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-		rpc.limitauthsha = sha256.Sum256([]byte(auth))
+	rpc.credentials = cfg.rpcCredentials
+	rpc.quotas = newRPCQuotas(cfg.RPCRateLimit, cfg.RPCUserRateLimit,
+		cfg.RPCRateBurst, cfg.RPCMaxClientReqs, cfg.RPCResponseBudget)
+	if cfg.rpcCookie != "" {
+		err := writeRPCCookie(cfg.RPCCookieFile, cfg.rpcCookie)
+		if err != nil {
+			return nil, fmt.Errorf("unable to write cookie file: %v",
+				err)
+		}
+		rpcsLog.Infof("Wrote RPC cookie to %s", cfg.RPCCookieFile)
 	}
 	rpc.ntfnMgr = newWsNotificationManager(&rpc)
 	rpc.cfg.Chain.Subscribe(rpc.handleBlockchainNotification)
@@ -4814,6 +6425,17 @@ func (s *rpcServer) handleBlockchainNotification(notification *blockchain.Notifi
 
 		// Notify registered websocket clients.
 		s.ntfnMgr.NotifyBlockDisconnected(block)
+
+	case blockchain.NTChainReorganized:
+		reorg, ok := notification.Data.(*blockchain.ReorganizationData)
+		if !ok {
+			rpcsLog.Warnf("Chain reorganized notification is not " +
+				"reorganization data.")
+			break
+		}
+
+		// Notify registered websocket clients.
+		s.ntfnMgr.NotifyChainReorganized(reorg)
 	}
 }
 
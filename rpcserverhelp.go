@@ -34,6 +34,50 @@ var helpDescsEnUS = map[string]string{
 	"addnode-addr":      "IP address and port of the peer to operate on",
 	"addnode-subcmd":    "'add' to add a persistent peer, 'remove' to remove a persistent peer, or 'onetry' to try a single connection to a peer",
 
+	// AnalyzeTransactionCmd help.
+	"analyzetransaction--synopsis": "Reports every standardness rule a serialized, hex-encoded transaction violates, such as dust outputs, non-standard script forms, the signature check density of its inputs and the data carrier policy, without submitting it.\n" +
+		"The transaction is analyzed as though it were mined in the next block and may spend the outputs of the main chain and of the memory pool.\n" +
+		"The inputs are only checked and the scripts are only validated when all of the outputs they spend are found.",
+	"analyzetransaction-hextx": "Serialized, hex-encoded transaction",
+
+	// AnalyzeTransactionResult help.
+	"analyzetransactionresult-txid":          "The hash of the transaction",
+	"analyzetransactionresult-standard":      "Whether or not the transaction violates none of the standardness rules",
+	"analyzetransactionresult-violations":    "The standardness rules the transaction violates in the order they are checked",
+	"analyzetransactionresult-missinginputs": "The outputs spent by the transaction which were not found",
+
+	// PolicyViolationResult help.
+	"policyviolationresult-rule":       "The violated rule (version, finality, tx-size, sigscript-size, sigscript-pushonly, tokens, script-type, dust, datacarrier, input-script-type, sigchecks, tx-sigchecks or script)",
+	"policyviolationresult-input":      "The index of the input which violates the rule, if any",
+	"policyviolationresult-output":     "The index of the output which violates the rule, if any",
+	"policyviolationresult-rejectcode": "The reject code the transaction would be rejected with",
+	"policyviolationresult-reason":     "A description of the violation",
+
+	// BackupChainstateCmd help.
+	"backupchainstate--synopsis": "Writes a consistent snapshot of the database to a file on the server while the node keeps running.\n" +
+		"The backup is restored with the restore command of dbtool to bootstrap a new node.\n" +
+		"A backup without the blocks only lists the block files, which must then be copied to the new node separately.",
+	"backupchainstate-destination":   "The path of the backup file on the server, which must not exist yet",
+	"backupchainstate-includeblocks": "Include the block files in the backup",
+
+	// BackupChainstateResult help.
+	"backupchainstateresult-destination": "The path of the backup file",
+	"backupchainstateresult-size":        "The size of the backup in bytes",
+	"backupchainstateresult-duration":    "The time the backup took in seconds",
+
+	// ClearBannedCmd help.
+	"clearbanned--synopsis": "Removes all bans.",
+
+	// CompactDatabaseCmd help.
+	"compactdatabase--synopsis": "Compacts the database to reclaim the disk space held by deleted keys.\n" +
+		"The node remains usable while the database is compacted, however, compacting a large database may take a long time.",
+
+	// CompactDatabaseResult help.
+	"compactdatabaseresult-sizebefore": "The size of the database metadata on disk in bytes before the compaction",
+	"compactdatabaseresult-sizeafter":  "The size of the database metadata on disk in bytes after the compaction",
+	"compactdatabaseresult-reclaimed":  "The number of bytes reclaimed by the compaction",
+	"compactdatabaseresult-duration":   "The time the compaction took in seconds",
+
 	// NodeCmd help.
 	"node--synopsis":     "Attempts to add or remove a peer.",
 	"node-subcmd":        "'disconnect' to remove all matching non-persistent peers, 'remove' to remove a persistent peer, or 'connect' to connect to a peer",
@@ -86,6 +130,23 @@ var helpDescsEnUS = map[string]string{
 	"vin-scriptSig":   "The signature script used to redeem the origin transaction as a JSON object (non-coinbase txns only)",
 	"vin-txinwitness": "The witness used to redeem the input encoded as a string array of its items",
 	"vin-sequence":    "The script sequence number",
+	"vin-prevout":     "The output spent by the input as a JSON object (only when the spent outputs are requested)",
+
+	// SpentOutputResult help.
+	"spentoutputresult-generated":    "Whether the spent output was created by a coinbase transaction",
+	"spentoutputresult-height":       "The height of the block which created the spent output",
+	"spentoutputresult-value":        "The amount in BCH",
+	"spentoutputresult-scriptPubKey": "The public key script of the spent output as a JSON object",
+	"spentoutputresult-tokenData":    "The CashToken data of the spent output as a JSON object (only when it carries tokens)",
+
+	// TokenDataResult help.
+	"tokendataresult-category": "The hex-encoded token category ID",
+	"tokendataresult-amount":   "The fungible token amount as a string",
+	"tokendataresult-nft":      "The non-fungible token as a JSON object (only when the output carries an NFT)",
+
+	// TokenNFTResult help.
+	"tokennftresult-capability": "The capability of the non-fungible token (none, mutable or minting)",
+	"tokennftresult-commitment": "The hex-encoded commitment of the non-fungible token",
 
 	// ScriptPubKeyResult help.
 	"scriptpubkeyresult-asm":       "Disassembly of the script",
@@ -98,6 +159,7 @@ var helpDescsEnUS = map[string]string{
 	"vout-value":        "The amount in BTC",
 	"vout-n":            "The index of this transaction output",
 	"vout-scriptPubKey": "The public key script used to pay coins as a JSON object",
+	"vout-tokenData":    "The CashToken data of the output as a JSON object (only when it carries tokens)",
 
 	// TxRawDecodeResult help.
 	"txrawdecoderesult-txid":     "The hash of the transaction",
@@ -136,6 +198,23 @@ var helpDescsEnUS = map[string]string{
 	"generate-numblocks": "Number of blocks to generate",
 	"generate--result0":  "The hashes, in order, of blocks generated by the call",
 
+	// GenerateToAddressCmd help
+	"generatetoaddress--synopsis": "Generates a set number of blocks paying to the given address (simnet or regtest only)\n" +
+		" and returns a JSON array of their hashes.",
+	"generatetoaddress-numblocks": "Number of blocks to generate",
+	"generatetoaddress-address":   "The address the coinbase of each block pays to",
+	"generatetoaddress-maxtries":  "Accepted for compatibility with bitcoind and otherwise ignored",
+	"generatetoaddress--result0":  "The hashes, in order, of blocks generated by the call",
+
+	// GenerateBlockCmd help
+	"generateblock--synopsis": "Generates a block paying to the given address which contains exactly the given\n" +
+		" transactions (simnet or regtest only).",
+	"generateblock-address":      "The address the coinbase of the block pays to",
+	"generateblock-transactions": "Raw transactions or txids of mempool transactions to include in the block",
+
+	// GenerateBlockResult help.
+	"generateblockresult-hash": "The hash of the generated block",
+
 	// GetAddedNodeInfoResultAddr help.
 	"getaddednodeinforesultaddr-address":   "The ip address for this DNS entry",
 	"getaddednodeinforesultaddr-connected": "The connection 'direction' (inbound/outbound/false)",
@@ -153,6 +232,24 @@ var helpDescsEnUS = map[string]string{
 	"getaddednodeinfo--condition1": "dns=true",
 	"getaddednodeinfo--result0":    "List of added peers",
 
+	// GetABLAStateCmd help.
+	"getablastate--synopsis":  "Returns the state of the adaptive block size limit (ABLA) algorithm which applies to the block building on the tip of the main chain, optionally projected forward for hypothetical block sizes.",
+	"getablastate-blocksizes": "The sizes in bytes of hypothetical blocks following the tip of the main chain to project the state for, which are clamped to the limit of their block",
+
+	// GetABLAStateResult help.
+	"getablastateresult-height":            "The height of the block building on the tip of the main chain",
+	"getablastateresult-active":            "Whether the algorithm limits the size of the block",
+	"getablastateresult-controlblocksize":  "The control function state, which is the floor of the size limit",
+	"getablastateresult-elasticbuffersize": "The elastic buffer function state, which is the room above the control block size",
+	"getablastateresult-sizelimit":         "The maximum size of the block in bytes",
+	"getablastateresult-projection":        "The states which apply to the blocks after each of the hypothetical blocks, if any",
+
+	// ABLAStateResult help.
+	"ablastateresult-height":            "The height of the block the state applies to",
+	"ablastateresult-controlblocksize":  "The control function state, which is the floor of the size limit",
+	"ablastateresult-elasticbuffersize": "The elastic buffer function state, which is the room above the control block size",
+	"ablastateresult-sizelimit":         "The maximum size of the block in bytes",
+
 	// GetBestBlockResult help.
 	"getbestblockresult-hash":   "Hex-encoded bytes of the best block hash",
 	"getbestblockresult-height": "Height of the best block",
@@ -168,7 +265,7 @@ var helpDescsEnUS = map[string]string{
 	// GetBlockCmd help.
 	"getblock--synopsis":   "Returns information about a block given its hash.",
 	"getblock-hash":        "The hash of the block",
-	"getblock-verbosity":   "Specifies whether the block data should be returned as a hex-encoded string (0), as parsed data with a slice of TXIDs (1), or as parsed data with parsed transaction data (2) ",
+	"getblock-verbosity":   "Specifies whether the block data should be returned as a hex-encoded string (0), as parsed data with a slice of TXIDs (1), as parsed data with parsed transaction data (2), or as parsed data with parsed transaction data including the outputs spent by the inputs and the fees (3)",
 	"getblock--condition0": "verbosity=0",
 	"getblock--condition1": "verbosity=1",
 	"getblock--condition2": "verbosity=2",
@@ -186,7 +283,10 @@ var helpDescsEnUS = map[string]string{
 	"getblockchaininforesult-bestblockhash":         "The block hash for the latest block in the main chain",
 	"getblockchaininforesult-difficulty":            "The current chain difficulty",
 	"getblockchaininforesult-mediantime":            "The median time from the PoV of the best block in the chain",
-	"getblockchaininforesult-verificationprogress":  "An estimate for how much of the best chain we've verified",
+	"getblockchaininforesult-verificationprogress":  "An estimate of the ratio of the transactions of the network which were verified, based on the transaction data of the network",
+	"getblockchaininforesult-initialblockdownload":  "Whether or not the node is in the initial block download",
+	"getblockchaininforesult-remainingtime":         "The estimated number of seconds until the initial block download completes at the current verification rate, omitted when unknown",
+	"getblockchaininforesult-size_on_disk":          "The size of the database on disk in bytes, including the blocks",
 	"getblockchaininforesult-syncheight":            "The block height obtained from the best peer",
 	"getblockchaininforesult-pruned":                "A bool that indicates if the node is pruned or not",
 	"getblockchaininforesult-pruneheight":           "The lowest block retained in the current pruned chain",
@@ -217,6 +317,8 @@ var helpDescsEnUS = map[string]string{
 	"txrawresult-size":          "The size of the transaction in bytes",
 	"txrawresult-vsize":         "The virtual size of the transaction in bytes",
 	"txrawresult-hash":          "The wtxid of the transaction",
+	"txrawresult-fee":           "The fee paid by the transaction in BCH (only when the spent outputs are requested)",
+	"txrawresult-sigchecks":     "The number of signature checks performed by the inputs of the transaction (only when the spent outputs are requested from getrawtransaction)",
 
 	// SearchRawTransactionsResult help.
 	"searchrawtransactionsresult-hex":           "Hex-encoded transaction",
@@ -242,7 +344,7 @@ var helpDescsEnUS = map[string]string{
 	"getblockverboseresult-versionHex":        "The block version in hexadecimal",
 	"getblockverboseresult-merkleroot":        "Root hash of the merkle tree",
 	"getblockverboseresult-tx":                "The transaction hashes (only when verbosity=1)",
-	"getblockverboseresult-rawtx":             "The transactions as JSON objects (only when verbosity=2)",
+	"getblockverboseresult-rawtx":             "The transactions as JSON objects (only when verbosity=2 or 3)",
 	"getblockverboseresult-time":              "The block time in seconds since 1 Jan 1970 GMT",
 	"getblockverboseresult-nonce":             "The block nonce",
 	"getblockverboseresult-bits":              "The bits which represent the block difficulty",
@@ -282,6 +384,55 @@ var helpDescsEnUS = map[string]string{
 	"getblockheaderverboseresult-previousblockhash": "The hash of the previous block",
 	"getblockheaderverboseresult-nextblockhash":     "The hash of the next block (only if there is one)",
 
+	// GetBlockStatsCmd help.
+	"getblockstats--synopsis":    "Returns statistics about a block in the main chain, or about each block of a range of heights, computing only the selected statistics.",
+	"getblockstats-hashorheight": "The hash or height of the block, or of the first block of the range",
+	"getblockstats-stats":        "The names of the statistics to compute (default: all)",
+	"getblockstats-endheight":    "The height of the last block of the range, which makes the result an array of the statistics of every block from the first one",
+	"getblockstats--condition0":  "endheight not set",
+	"getblockstats--condition1":  "endheight set",
+
+	// GetBlockStatsResult help.
+	"getblockstatsresult-avgfee":              "Average fee of the transactions in satoshis",
+	"getblockstatsresult-avgfeerate":          "Average fee rate of the transactions in satoshis per byte",
+	"getblockstatsresult-avgtxsize":           "Average size of the transactions in bytes",
+	"getblockstatsresult-blockhash":           "The hash of the block",
+	"getblockstatsresult-feerate_percentiles": "The 10th, 25th, 50th, 75th and 90th percentiles of the fee rates in satoshis per byte, weighted by transaction size",
+	"getblockstatsresult-height":              "The height of the block",
+	"getblockstatsresult-ins":                 "The number of inputs, excluding the coinbase",
+	"getblockstatsresult-maxfee":              "Maximum fee of the transactions in satoshis",
+	"getblockstatsresult-maxfeerate":          "Maximum fee rate of the transactions in satoshis per byte",
+	"getblockstatsresult-maxtxsize":           "Maximum size of the transactions in bytes",
+	"getblockstatsresult-medianfee":           "Median fee of the transactions in satoshis",
+	"getblockstatsresult-mediantime":          "The median time past of the block",
+	"getblockstatsresult-mediantxsize":        "Median size of the transactions in bytes",
+	"getblockstatsresult-minfee":              "Minimum fee of the transactions in satoshis",
+	"getblockstatsresult-minfeerate":          "Minimum fee rate of the transactions in satoshis per byte",
+	"getblockstatsresult-mintxsize":           "Minimum size of the transactions in bytes",
+	"getblockstatsresult-outs":                "The number of outputs, including the coinbase outputs",
+	"getblockstatsresult-sigchecks":           "The number of signature checks performed by the input scripts",
+	"getblockstatsresult-subsidy":             "The block subsidy in satoshis",
+	"getblockstatsresult-time":                "The block time in seconds since 1 Jan 1970 GMT",
+	"getblockstatsresult-total_out":           "Total value of the outputs in satoshis, excluding the coinbase",
+	"getblockstatsresult-total_size":          "Total size of the transactions in bytes, excluding the coinbase",
+	"getblockstatsresult-totalfee":            "Total fee of the transactions in satoshis",
+	"getblockstatsresult-txs":                 "The number of transactions, including the coinbase",
+	"getblockstatsresult-utxo_increase":       "The change in the number of unspent outputs, excluding provably unspendable outputs",
+	"getblockstatsresult-utxo_size_inc":       "The change in the size of the unspent output set in bytes",
+
+	// GetBlockUndoDataCmd help.
+	"getblockundodata--synopsis":    "Returns the outputs spent by the transactions of a block in the main chain, as recorded in its undo data.",
+	"getblockundodata-hashorheight": "The hash or height of the block",
+
+	// GetBlockUndoDataResult help.
+	"getblockundodataresult-hash":   "The hash of the block",
+	"getblockundodataresult-height": "The height of the block",
+	"getblockundodataresult-tx":     "The outputs spent by each transaction of the block in block order, excluding the coinbase",
+
+	// TxUndoResult help.
+	"txundoresult-txid":     "The hash of the transaction",
+	"txundoresult-prevouts": "The outputs spent by the inputs of the transaction in the order of the inputs",
+
 	// TemplateRequest help.
 	"templaterequest-mode":         "This is 'template', 'proposal', or omitted",
 	"templaterequest-capabilities": "List of capabilities",
@@ -354,6 +505,34 @@ var helpDescsEnUS = map[string]string{
 	"getcfilterheader-hash":       "The hash of the block",
 	"getcfilterheader--result0":   "The block's gcs filter header",
 
+	// GetChainTipsCmd help.
+	"getchaintips--synopsis": "Returns the tips of all of the branches of the block tree known to the server, including the main chain, ordered by descending height.",
+
+	// GetChainTipsResult help.
+	"getchaintipsresult-height":        "The height of the tip",
+	"getchaintipsresult-hash":          "The hash of the tip",
+	"getchaintipsresult-branchlen":     "The number of blocks of the branch after the block it forks off the main chain at, which is zero for the main chain",
+	"getchaintipsresult-status":        "The status of the branch (active, valid-fork, valid-headers, headers-only or invalid)",
+	"getchaintipsresult-invalidblock":  "The hash of the block of an invalid branch which failed validation",
+	"getchaintipsresult-invalidreason": "The reason the block failed validation, if known",
+
+	// GetCheckpointCandidatesCmd help.
+	"getcheckpointcandidates--synopsis": "Returns blocks of the main chain which are good checkpoint candidates, newest first, along with a minimum chain work for the network, for developers to review before adding them to a release.\n" +
+		"Candidates are at least 2016 blocks deep, have timestamps in order with the blocks around them and only contain standard transactions.  The chain is scanned backwards from the newest block deep enough, so the blocks must be available.",
+	"getcheckpointcandidates-count":    "The maximum number of candidates to return",
+	"getcheckpointcandidates-interval": "The minimum number of blocks between candidates",
+
+	// CheckpointCandidateResult help.
+	"checkpointcandidateresult-height":     "The height of the block",
+	"checkpointcandidateresult-hash":       "The hash of the block",
+	"checkpointcandidateresult-time":       "The timestamp of the block",
+	"checkpointcandidateresult-chainwork":  "The hex encoded cumulative work of the main chain up to and including the block",
+	"checkpointcandidateresult-checkpoint": "The checkpoint in the format of the checkpoints of the network parameters",
+
+	// GetCheckpointCandidatesResult help.
+	"getcheckpointcandidatesresult-minimumchainwork": "The hex encoded chain work of the newest candidate, which is suitable as the minimum chain work of the network",
+	"getcheckpointcandidatesresult-candidates":       "The checkpoint candidates, newest first",
+
 	// GetConnectionCountCmd help.
 	"getconnectioncount--synopsis": "Returns the number of active connections to other peers.",
 	"getconnectioncount--result0":  "The number of connections",
@@ -362,10 +541,37 @@ var helpDescsEnUS = map[string]string{
 	"getcurrentnet--synopsis": "Get bitcoin network the server is running on.",
 	"getcurrentnet--result0":  "The network identifer",
 
+	// GetDatabaseInfoCmd help.
+	"getdatabaseinfo--synopsis": "Returns information about the disk space used by the database.",
+
+	// GetDatabaseInfoResult help.
+	"getdatabaseinforesult-type":         "The database driver type",
+	"getdatabaseinforesult-metadatasize": "The size of the database metadata on disk in bytes",
+	"getdatabaseinforesult-blockssize":   "The size of the stored blocks on disk in bytes",
+	"getdatabaseinforesult-deletedkeys":  "The number of keys deleted since the node was started or the database was last compacted, whose space is reclaimed by compacting the database",
+	"getdatabaseinforesult-autocompact":  "Whether or not the database is compacted in the background",
+
 	// GetDifficultyCmd help.
 	"getdifficulty--synopsis": "Returns the proof-of-work difficulty as a multiple of the minimum difficulty.",
 	"getdifficulty--result0":  "The difficulty",
 
+	// GetFirstSeenCmd help.
+	"getfirstseen--synopsis": "Returns when and from which peer a block or transaction was first announced and when its full data was first received.\n" +
+		"Requires first seen tracking to be enabled with --firstseen.  The most recent blocks and transactions are kept in memory only.",
+	"getfirstseen-hash": "The hash of the block or transaction",
+
+	// FirstSightingResult help.
+	"firstsightingresult-time":   "The time of the sighting in milliseconds since 1 Jan 1970 GMT",
+	"firstsightingresult-peer":   "The address of the peer the block or transaction was seen from",
+	"firstsightingresult-source": "The message the block or transaction was seen in (inv, headers, cmpctblock, block or tx)",
+
+	// GetFirstSeenResult help.
+	"getfirstseenresult-hash":      "The hash of the block or transaction",
+	"getfirstseenresult-type":      "Whether the hash is of a block or a transaction (block or tx)",
+	"getfirstseenresult-announced": "The first time the block or transaction was announced or received",
+	"getfirstseenresult-received":  "The first time the full block or transaction was received, omitted until then",
+	"getfirstseenresult-delay":     "The milliseconds between the announcement and the receipt, omitted until the receipt",
+
 	// GetGenerateCmd help.
 	"getgenerate--synopsis": "Returns if the server is set to generate coins (mine) or not.",
 	"getgenerate--result0":  "True if mining, false if not",
@@ -417,8 +623,10 @@ var helpDescsEnUS = map[string]string{
 	"getmempoolinfo--synopsis": "Returns memory pool information",
 
 	// GetMempoolInfoResult help.
-	"getmempoolinforesult-bytes": "Size in bytes of the mempool",
-	"getmempoolinforesult-size":  "Number of transactions in the mempool",
+	"getmempoolinforesult-bytes":         "Size in bytes of the mempool",
+	"getmempoolinforesult-size":          "Number of transactions in the mempool",
+	"getmempoolinforesult-maxmempool":    "Maximum size in bytes of the mempool, 0 if unlimited",
+	"getmempoolinforesult-mempoolminfee": "Minimum fee rate in BCH/kB for transactions to be accepted, the higher of the minimum relay fee and the fee rate required since transactions were last evicted from the full mempool",
 
 	// GetMiningInfoResult help.
 	"getmininginforesult-blocks":           "Height of the latest best block",
@@ -442,6 +650,24 @@ var helpDescsEnUS = map[string]string{
 	"getnetworkhashps-height":    "Perform estimate ending with this height or -1 for current best chain block height",
 	"getnetworkhashps--result0":  "Estimated hashes per second",
 
+	// GetNextBlockParamsCmd help.
+	"getnextblockparams--synopsis": "Returns the consensus parameters of the block building on the tip of the main chain, or on the given block extended by the given headers, " +
+		"which external miners can use to check their own implementations of the difficulty adjustment, median time past and block size limit rules.",
+	"getnextblockparams-prevhash":  "The hash of the block the next block builds on (default: the tip of the main chain)",
+	"getnextblockparams-headers":   "Hex-encoded headers extending the block in order, which need not be known to the node",
+	"getnextblockparams-timestamp": "The timestamp of the next block the difficulty is calculated for (default: the current adjusted time)",
+
+	// GetNextBlockParamsResult help.
+	"getnextblockparamsresult-previousblockhash": "The hash of the block the next block builds on",
+	"getnextblockparamsresult-height":            "The height of the next block",
+	"getnextblockparamsresult-time":              "The timestamp the difficulty was calculated for, which is bumped to mintime when it is lower",
+	"getnextblockparamsresult-bits":              "The hex-encoded difficulty bits the next block must meet",
+	"getnextblockparamsresult-target":            "The hex-encoded target the hash of the next block must not exceed",
+	"getnextblockparamsresult-difficulty":        "The difficulty of the next block as a multiple of the minimum difficulty",
+	"getnextblockparamsresult-mediantime":        "The median time past of the blocks prior to the next block",
+	"getnextblockparamsresult-mintime":           "The minimum timestamp of the next block",
+	"getnextblockparamsresult-sizelimit":         "The maximum size of the next block in bytes, omitted when it depends on the sizes of blocks which are not in the main chain",
+
 	// GetNetworkInfo help.
 	"getnetworkinfo--synopsis":       "Returns an object containing various state info regarding P2P networking.",
 	"getnetworkinfo--result0--desc":  "GetNetworkInfo object",
@@ -470,6 +696,15 @@ var helpDescsEnUS = map[string]string{
 	"getnettotalsresult-totalbytesrecv": "Total bytes received",
 	"getnettotalsresult-totalbytessent": "Total bytes sent",
 	"getnettotalsresult-timemillis":     "Number of milliseconds since 1 Jan 1970 GMT",
+	"getnettotalsresult-uploadtarget":   "The upload target",
+
+	// GetNetTotalsUploadTarget help.
+	"getnettotalsuploadtarget-timeframe":               "Length of the upload target cycle in seconds",
+	"getnettotalsuploadtarget-target":                  "Target in bytes per cycle, 0 when there is no target",
+	"getnettotalsuploadtarget-target_reached":          "Whether the target is reached",
+	"getnettotalsuploadtarget-serve_historical_blocks": "Whether historical blocks are served",
+	"getnettotalsuploadtarget-bytes_left_in_cycle":     "Bytes left in the current cycle",
+	"getnettotalsuploadtarget-time_left_in_cycle":      "Seconds left in the current cycle",
 
 	// GetPeerInfoResult help.
 	"getpeerinforesult-id":             "A unique node ID",
@@ -496,6 +731,22 @@ var helpDescsEnUS = map[string]string{
 	"getpeerinforesult-feefilter":      "The requested minimum fee a transaction must have to be announced to the peer",
 	"getpeerinforesult-syncnode":       "Whether or not the peer is the sync peer",
 
+	"getpeerinforesult-minping":                  "Number of microseconds the fastest ping took",
+	"getpeerinforesult-bytessent_per_msg":        "JSON object with the total bytes sent by message command",
+	"getpeerinforesult-bytessent_per_msg--key":   "command",
+	"getpeerinforesult-bytessent_per_msg--value": "n",
+	"getpeerinforesult-bytessent_per_msg--desc":  "Total bytes sent by message command, with undecodable messages counted as *other*",
+	"getpeerinforesult-bytesrecv_per_msg":        "JSON object with the total bytes received by message command",
+	"getpeerinforesult-bytesrecv_per_msg--key":   "command",
+	"getpeerinforesult-bytesrecv_per_msg--value": "n",
+	"getpeerinforesult-bytesrecv_per_msg--desc":  "Total bytes received by message command, with undecodable messages counted as *other*",
+	"getpeerinforesult-txinvsreceived":           "Number of transactions announced by the peer",
+	"getpeerinforesult-txinvsnew":                "Number of transactions announced by the peer which were not in the mempool yet",
+	"getpeerinforesult-txinvhitrate":             "Fraction of the transactions announced by the peer which were not in the mempool yet",
+	"getpeerinforesult-cmpctblocksreceived":      "Number of compact blocks received from the peer",
+	"getpeerinforesult-cmpctblocksreconstructed": "Number of compact blocks from the peer reconstructed from the mempool alone",
+	"getpeerinforesult-cmpctblocksroundtrip":     "Number of compact blocks from the peer reconstructed after fetching the missing transactions",
+
 	// GetPeerInfoCmd help.
 	"getpeerinfo--synopsis": "Returns data about each connected network peer as an array of json objects.",
 
@@ -519,7 +770,7 @@ var helpDescsEnUS = map[string]string{
 	// GetRawTransactionCmd help.
 	"getrawtransaction--synopsis":   "Returns information about a transaction given its hash.",
 	"getrawtransaction-txid":        "The hash of the transaction",
-	"getrawtransaction-verbose":     "Specifies the transaction is returned as a JSON object instead of a hex-encoded string",
+	"getrawtransaction-verbose":     "Specifies the transaction is returned as a JSON object instead of a hex-encoded string, 2 also includes the outputs spent by its inputs along with its fee and signature checks",
 	"getrawtransaction--condition0": "verbose=false",
 	"getrawtransaction--condition1": "verbose=true",
 	"getrawtransaction--result0":    "Hex-encoded bytes of the serialized transaction",
@@ -538,6 +789,18 @@ var helpDescsEnUS = map[string]string{
 	"gettxout-vout":           "The index of the output",
 	"gettxout-includemempool": "Include the mempool when true",
 
+	// GetTxOutSetInfoCmd help.
+	"gettxoutsetinfo--synopsis": "Returns statistics about the unspent transaction output set.\n" +
+		"Unless bchd runs with --utxostats the entire set is scanned, which can take a long time.",
+
+	// GetTxOutSetInfoResult help.
+	"gettxoutsetinforesult-height":          "The height of the best block",
+	"gettxoutsetinforesult-bestblock":       "The hash of the best block",
+	"gettxoutsetinforesult-txouts":          "The number of unspent transaction outputs",
+	"gettxoutsetinforesult-serialized_size": "The size in bytes of the unspent transaction outputs serialized in the UTXO commitment format",
+	"gettxoutsetinforesult-ecmh":            "The ECMH multiset hash of the unspent transaction outputs serialized in the UTXO commitment format",
+	"gettxoutsetinforesult-total_amount":    "The total amount of all unspent transaction outputs in BCH",
+
 	// GetTxOutProofCmd help.
 	"gettxoutproof--synopsis": "Returns hex encoded merkle proof for a given transaction set",
 	"gettxoutproof-txids":     "A list of transaction hashes to generate proof for",
@@ -557,6 +820,17 @@ var helpDescsEnUS = map[string]string{
 	"help--result0":    "List of commands",
 	"help--result1":    "Help for specified command",
 
+	// ListBannedCmd help.
+	"listbanned--synopsis": "Returns the list of all banned IP addresses and subnets.",
+
+	// ListBannedResult help.
+	"listbannedresult-address":        "The banned IP address or subnet",
+	"listbannedresult-ban_created":    "Time the ban was created in seconds since 1 Jan 1970 GMT",
+	"listbannedresult-banned_until":   "Time the ban expires in seconds since 1 Jan 1970 GMT",
+	"listbannedresult-ban_duration":   "The total duration of the ban in seconds",
+	"listbannedresult-time_remaining": "The remaining duration of the ban in seconds",
+	"listbannedresult-ban_reason":     "The reason of the ban",
+
 	// PingCmd help.
 	"ping--synopsis": "Queues a ping to be sent to each connected peer.\n" +
 		"Ping times are provided by getpeerinfo via the pingtime and pingwait fields.",
@@ -588,30 +862,102 @@ var helpDescsEnUS = map[string]string{
 	"reconsiderblock--synopsis": "Reconsider a block for validation.",
 	"reconsiderblock-blockhash": "Hash of the block you want to reconsider",
 
+	// ReloadConfigCmd help.
+	"reloadconfig--synopsis": "Loads the configuration again from the config file and the command line and applies the options which changed without restarting the server.\n" +
+		"The options which may be reloaded are debuglevel, the relay policy options, the RPC user options rpcuser, rpcpass, rpclimituser, rpclimitpass, rpcauth and rpcwhitelist, and addpeer.\n" +
+		"Nothing is applied when any of them is invalid. The configuration is also reloaded on SIGHUP on platforms which support it.",
+
+	// ReloadedOptionResult help.
+	"reloadedoptionresult-option": "The name of the option which was applied",
+	"reloadedoptionresult-value":  "The value of the option which was applied, omitted for passwords",
+
+	// ReloadConfigResult help.
+	"reloadconfigresult-applied": "The options which changed and were applied",
+	"reloadconfigresult-removed": "The hashes of the transactions removed from the memory pool because they no longer meet the relay policy",
+
 	// InvalidateBlockCmd
 	"invalidateblock--synopsis": "Invalidate a block.",
 	"invalidateblock-blockhash": "Hash of the block you want to invalidate",
 
+	// SetBanCmd help.
+	"setban--synopsis": "Attempts to add or remove an IP address or subnet from the banned list.\n" +
+		"Connected peers within a newly banned subnet are disconnected.",
+	"setban-subnet":   "The IP address or subnet (e.g. 192.168.0.0/24) to operate on",
+	"setban-subcmd":   "'add' to add an IP address or subnet to the banned list, 'remove' to remove it",
+	"setban-bantime":  "The time in seconds the IP address or subnet is banned for, or 0 for the default ban duration",
+	"setban-absolute": "Whether the ban time is an absolute time in seconds since 1 Jan 1970 GMT instead of a duration",
+
 	// SetGenerateCmd help.
 	"setgenerate--synopsis":    "Set the server to generate coins (mine) or not.",
 	"setgenerate-generate":     "Use true to enable generation, false to disable it",
 	"setgenerate-genproclimit": "The number of processors (cores) to limit generation to or -1 for default",
 
+	// SetRelayPolicyCmd help.
+	"setrelaypolicy--synopsis": "Adjusts the relay policy of the memory pool without restarting the server and returns the policy in effect.\n" +
+		"Transactions in the memory pool which no longer meet the policy are removed along with the transactions which depend on them.\n" +
+		"These are the transactions which are no longer standard, perform too many signature checks, or paid the previous minimum relay fee but not the new one.",
+	"setrelaypolicy-options": "The relay policy parameters to update, the ones which are omitted are left unchanged",
+
+	// RelayPolicyOptions help.
+	"relaypolicyoptions-minrelaytxfee":        "The minimum transaction fee in BCH/kB to be considered a non-zero fee",
+	"relaypolicyoptions-dustrelayfee":         "The fee rate in BCH/kB at which outputs whose value is less than three times the fee of spending them are dust, or 0 to disable",
+	"relaypolicyoptions-maxtxsigchecks":       "The maximum number of signature checks performed by the scripts of a transaction, or 0 to disable",
+	"relaypolicyoptions-datacarriersize":      "The maximum total size in bytes of the null data output scripts of a transaction",
+	"relaypolicyoptions-maxdatacarriers":      "The maximum number of null data outputs of a transaction, or 0 to disable",
+	"relaypolicyoptions-datacarrierprotocols": "The hex-encoded prefixes of the first data push of the null data protocols to accept, or an empty list to accept all of them",
+
+	// SetRelayPolicyResult help.
+	"setrelaypolicyresult-minrelaytxfee":        "The minimum transaction fee in BCH/kB to be considered a non-zero fee",
+	"setrelaypolicyresult-dustrelayfee":         "The fee rate in BCH/kB which determines whether outputs are dust",
+	"setrelaypolicyresult-maxtxsigchecks":       "The maximum number of signature checks performed by the scripts of a transaction",
+	"setrelaypolicyresult-datacarriersize":      "The maximum total size in bytes of the null data output scripts of a transaction",
+	"setrelaypolicyresult-maxdatacarriers":      "The maximum number of null data outputs of a transaction",
+	"setrelaypolicyresult-datacarrierprotocols": "The hex-encoded prefixes of the accepted null data protocols",
+	"setrelaypolicyresult-removed":              "The hashes of the transactions removed from the memory pool",
+
 	// StopCmd help.
 	"stop--synopsis": "Shutdown bchd.",
 	"stop--result0":  "The string 'bchd stopping.'",
 
 	// SubmitBlockOptions help.
-	"submitblockoptions-workid": "This parameter is currently ignored",
+	"submitblockoptions-workid":  "This parameter is currently ignored",
+	"submitblockoptions-verbose": "Return an object describing the outcome of the submission and the precise reason the block was rejected instead of a rejection string",
+
+	// SubmitBlockResult help.
+	"submitblockresult-hash":        "The hash of the submitted block",
+	"submitblockresult-accepted":    "Whether or not the block was accepted",
+	"submitblockresult-reason":      "The reason the block was rejected",
+	"submitblockresult-errorcode":   "The name of the rule the block violated",
+	"submitblockresult-txindex":     "The index of the offending transaction within the block",
+	"submitblockresult-txid":        "The hash of the offending transaction",
+	"submitblockresult-inputindex":  "The index of the offending input within the transaction",
+	"submitblockresult-scripterror": "The name of the error the input scripts failed with",
+	"submitblockresult-scriptindex": "The index of the script execution failed in (0 for the input script, 1 for the output script, 2 for the redeem script)",
+	"submitblockresult-opcodeindex": "The offset of the opcode within the script execution failed at",
+	"submitblockresult-savedfile":   "The file the rejected block was saved to when the saverejectedblocks option is set",
 
 	// SubmitBlockCmd help.
 	"submitblock--synopsis":   "Attempts to submit a new serialized, hex-encoded block to the network.",
 	"submitblock-hexblock":    "Serialized, hex-encoded block",
-	"submitblock-options":     "This parameter is currently ignored",
-	"submitblock--condition0": "Block successfully submitted",
-	"submitblock--condition1": "Block rejected",
+	"submitblock-options":     "Submission options",
+	"submitblock--condition0": "Block successfully submitted and verbose=false",
+	"submitblock--condition1": "Block rejected and verbose=false",
+	"submitblock--condition2": "verbose=true",
 	"submitblock--result1":    "The reason the block was rejected",
 
+	// TestMempoolAcceptCmd help.
+	"testmempoolaccept--synopsis": "Tests whether serialized, hex-encoded transactions would be accepted into the memory pool without adding them to it.\n" +
+		"Every policy and consensus check is performed.  The transactions are tested in order and those which would be accepted are treated as though they were in the memory pool when testing the ones which follow, so a package of dependent transactions can be tested as long as parents precede their children.",
+	"testmempoolaccept-rawtxs": "Serialized, hex-encoded transactions",
+
+	// TestMempoolAcceptResult help.
+	"testmempoolacceptresult-txid":          "The hash of the transaction",
+	"testmempoolacceptresult-allowed":       "Whether or not the transaction would be accepted into the memory pool",
+	"testmempoolacceptresult-size":          "The serialized size of the transaction in bytes",
+	"testmempoolacceptresult-fee":           "The fee paid by the transaction in BCH",
+	"testmempoolacceptresult-sigchecks":     "The number of signature checks performed by the input scripts",
+	"testmempoolacceptresult-reject-reason": "The reason the transaction would be rejected",
+
 	// ValidateAddressResult help.
 	"validateaddresschainresult-isvalid": "Whether or not the address is valid",
 	"validateaddresschainresult-address": "The bitcoin address (only when isvalid is true)",
@@ -625,9 +971,12 @@ var helpDescsEnUS = map[string]string{
 		"The actual checks performed by the checklevel parameter are implementation specific.\n" +
 		"For bchd this is:\n" +
 		"checklevel=0 - Look up each block and ensure it can be loaded from the database.\n" +
-		"checklevel=1 - Perform basic context-free sanity checks on each block.",
+		"checklevel=1 - Perform basic context-free sanity checks on each block.\n" +
+		"checklevel=2 - Ensure the spend journal of each block can be used to undo it.\n" +
+		"checklevel=3 - Reconnect the undone blocks with full validation including scripts.\n" +
+		"Levels 2 and 3 are limited to the blocks within the undo window.",
 	"verifychain-checklevel": "How thorough the block verification is",
-	"verifychain-checkdepth": "The number of blocks to check",
+	"verifychain-checkdepth": "The number of blocks to check, 0 for all",
 	"verifychain--result0":   "Whether or not the chain verified",
 
 	// VerifyMessageCmd help.
@@ -649,6 +998,18 @@ var helpDescsEnUS = map[string]string{
 	// StopNotifyBlocksCmd help.
 	"stopnotifyblocks--synopsis": "Cancel registered notifications for whenever a block is connected or disconnected from the main (best) chain.",
 
+	// NotifyChainReorgCmd help.
+	"notifychainreorg--synopsis": "Send a chainreorg notification whenever the main (best) chain is reorganized, including the common ancestor, the disconnected and connected blocks, and the transactions which are no longer confirmed.",
+
+	// StopNotifyChainReorgCmd help.
+	"stopnotifychainreorg--synopsis": "Cancel registered notifications for whenever the main (best) chain is reorganized.",
+
+	// NotifyDoubleSpendCmd help.
+	"notifydoublespend--synopsis": "Send a doublespend notification whenever a transaction which spends an output already spent by a transaction in the mempool is rejected or confirmed in a block.",
+
+	// StopNotifyDoubleSpendCmd help.
+	"stopnotifydoublespend--synopsis": "Cancel registered notifications for whenever a double spend of a transaction in the mempool is detected.",
+
 	// NotifyNewTransactionsCmd help.
 	"notifynewtransactions--synopsis": "Send either a txaccepted or a txacceptedverbose notification when a new transaction is accepted into the mempool.",
 	"notifynewtransactions-verbose":   "Specifies which type of notification to receive. If verbose is true, then the caller receives txacceptedverbose, otherwise the caller receives txaccepted",
@@ -725,63 +1086,87 @@ var helpDescsEnUS = map[string]string{
 // This information is used to generate the help.  Each result type must be a
 // pointer to the type (or nil to indicate no return value).
 var rpcResultTypes = map[string][]interface{}{
-	"addnode":               nil,
-	"createrawtransaction":  {(*string)(nil)},
-	"debuglevel":            {(*string)(nil), (*string)(nil)},
-	"decoderawtransaction":  {(*btcjson.TxRawDecodeResult)(nil)},
-	"decodescript":          {(*btcjson.DecodeScriptResult)(nil)},
-	"estimatefee":           {(*float64)(nil)},
-	"generate":              {(*[]string)(nil)},
-	"getaddednodeinfo":      {(*[]string)(nil), (*[]btcjson.GetAddedNodeInfoResult)(nil)},
-	"getbestblock":          {(*btcjson.GetBestBlockResult)(nil)},
-	"getbestblockhash":      {(*string)(nil)},
-	"getblock":              {(*string)(nil), (*btcjson.GetBlockVerboseResult)(nil)},
-	"getblockcount":         {(*int64)(nil)},
-	"getblockhash":          {(*string)(nil)},
-	"getblockheader":        {(*string)(nil), (*btcjson.GetBlockHeaderVerboseResult)(nil)},
-	"getblocktemplate":      {(*btcjson.GetBlockTemplateResult)(nil), (*string)(nil), nil},
-	"getblockchaininfo":     {(*btcjson.GetBlockChainInfoResult)(nil)},
-	"getcfilter":            {(*string)(nil)},
-	"getcfilterheader":      {(*string)(nil)},
-	"getconnectioncount":    {(*int32)(nil)},
-	"getcurrentnet":         {(*uint32)(nil)},
-	"getdifficulty":         {(*float64)(nil)},
-	"getgenerate":           {(*bool)(nil)},
-	"gethashespersec":       {(*float64)(nil)},
-	"getheaders":            {(*[]string)(nil)},
-	"getinfo":               {(*btcjson.InfoChainResult)(nil)},
-	"getmempoolinfo":        {(*btcjson.GetMempoolInfoResult)(nil)},
-	"getmininginfo":         {(*btcjson.GetMiningInfoResult)(nil)},
-	"getnettotals":          {(*btcjson.GetNetTotalsResult)(nil)},
-	"getnetworkhashps":      {(*float64)(nil)},
-	"getnetworkinfo":        {(*map[string]btcjson.GetNetworkInfoResult)(nil)},
-	"getpeerinfo":           {(*[]btcjson.GetPeerInfoResult)(nil)},
-	"getrawmempool":         {(*[]string)(nil), (*btcjson.GetRawMempoolVerboseResult)(nil)},
-	"getrawtransaction":     {(*string)(nil), (*btcjson.TxRawResult)(nil)},
-	"gettxout":              {(*btcjson.GetTxOutResult)(nil)},
-	"gettxoutproof":         {(*string)(nil)},
-	"node":                  nil,
-	"help":                  {(*string)(nil), (*string)(nil)},
-	"invalidateblock":       nil,
-	"ping":                  nil,
-	"reconsiderblock":       nil,
-	"searchrawtransactions": {(*string)(nil), (*[]btcjson.SearchRawTransactionsResult)(nil)},
-	"sendrawtransaction":    {(*string)(nil)},
-	"setgenerate":           nil,
-	"stop":                  {(*string)(nil)},
-	"submitblock":           {nil, (*string)(nil)},
-	"uptime":                {(*int64)(nil)},
-	"validateaddress":       {(*btcjson.ValidateAddressChainResult)(nil)},
-	"verifychain":           {(*bool)(nil)},
-	"verifymessage":         {(*bool)(nil)},
-	"verifytxoutproof":      {(*[]string)(nil)},
-	"version":               {(*map[string]btcjson.VersionResult)(nil)},
+	"addnode":                 nil,
+	"analyzetransaction":      {(*btcjson.AnalyzeTransactionResult)(nil)},
+	"backupchainstate":        {(*btcjson.BackupChainstateResult)(nil)},
+	"clearbanned":             nil,
+	"compactdatabase":         {(*btcjson.CompactDatabaseResult)(nil)},
+	"createrawtransaction":    {(*string)(nil)},
+	"debuglevel":              {(*string)(nil), (*string)(nil)},
+	"decoderawtransaction":    {(*btcjson.TxRawDecodeResult)(nil)},
+	"decodescript":            {(*btcjson.DecodeScriptResult)(nil)},
+	"estimatefee":             {(*float64)(nil)},
+	"generate":                {(*[]string)(nil)},
+	"generateblock":           {(*btcjson.GenerateBlockResult)(nil)},
+	"generatetoaddress":       {(*[]string)(nil)},
+	"getablastate":            {(*btcjson.GetABLAStateResult)(nil)},
+	"getaddednodeinfo":        {(*[]string)(nil), (*[]btcjson.GetAddedNodeInfoResult)(nil)},
+	"getbestblock":            {(*btcjson.GetBestBlockResult)(nil)},
+	"getbestblockhash":        {(*string)(nil)},
+	"getblock":                {(*string)(nil), (*btcjson.GetBlockVerboseResult)(nil)},
+	"getblockcount":           {(*int64)(nil)},
+	"getblockhash":            {(*string)(nil)},
+	"getblockheader":          {(*string)(nil), (*btcjson.GetBlockHeaderVerboseResult)(nil)},
+	"getblockstats":           {(*btcjson.GetBlockStatsResult)(nil), (*[]btcjson.GetBlockStatsResult)(nil)},
+	"getblockundodata":        {(*btcjson.GetBlockUndoDataResult)(nil)},
+	"getblocktemplate":        {(*btcjson.GetBlockTemplateResult)(nil), (*string)(nil), nil},
+	"getblockchaininfo":       {(*btcjson.GetBlockChainInfoResult)(nil)},
+	"getcfilter":              {(*string)(nil)},
+	"getcfilterheader":        {(*string)(nil)},
+	"getchaintips":            {(*[]btcjson.GetChainTipsResult)(nil)},
+	"getcheckpointcandidates": {(*btcjson.GetCheckpointCandidatesResult)(nil)},
+	"getconnectioncount":      {(*int32)(nil)},
+	"getcurrentnet":           {(*uint32)(nil)},
+	"getdatabaseinfo":         {(*btcjson.GetDatabaseInfoResult)(nil)},
+	"getdifficulty":           {(*float64)(nil)},
+	"getfirstseen":            {(*btcjson.GetFirstSeenResult)(nil)},
+	"getgenerate":             {(*bool)(nil)},
+	"gethashespersec":         {(*float64)(nil)},
+	"getheaders":              {(*[]string)(nil)},
+	"getinfo":                 {(*btcjson.InfoChainResult)(nil)},
+	"getmempoolinfo":          {(*btcjson.GetMempoolInfoResult)(nil)},
+	"getmininginfo":           {(*btcjson.GetMiningInfoResult)(nil)},
+	"getnettotals":            {(*btcjson.GetNetTotalsResult)(nil)},
+	"getnetworkhashps":        {(*float64)(nil)},
+	"getnextblockparams":      {(*btcjson.GetNextBlockParamsResult)(nil)},
+	"getnetworkinfo":          {(*map[string]btcjson.GetNetworkInfoResult)(nil)},
+	"getpeerinfo":             {(*[]btcjson.GetPeerInfoResult)(nil)},
+	"getrawmempool":           {(*[]string)(nil), (*btcjson.GetRawMempoolVerboseResult)(nil)},
+	"getrawtransaction":       {(*string)(nil), (*btcjson.TxRawResult)(nil)},
+	"gettxout":                {(*btcjson.GetTxOutResult)(nil)},
+	"gettxoutsetinfo":         {(*btcjson.GetTxOutSetInfoResult)(nil)},
+	"gettxoutproof":           {(*string)(nil)},
+	"node":                    nil,
+	"help":                    {(*string)(nil), (*string)(nil)},
+	"invalidateblock":         nil,
+	"listbanned":              {(*[]btcjson.ListBannedResult)(nil)},
+	"ping":                    nil,
+	"reconsiderblock":         nil,
+	"reloadconfig":            {(*btcjson.ReloadConfigResult)(nil)},
+	"searchrawtransactions":   {(*string)(nil), (*[]btcjson.SearchRawTransactionsResult)(nil)},
+	"sendrawtransaction":      {(*string)(nil)},
+	"setban":                  nil,
+	"setgenerate":             nil,
+	"setrelaypolicy":          {(*btcjson.SetRelayPolicyResult)(nil)},
+	"stop":                    {(*string)(nil)},
+	"submitblock":             {nil, (*string)(nil), (*btcjson.SubmitBlockResult)(nil)},
+	"testmempoolaccept":       {(*[]btcjson.TestMempoolAcceptResult)(nil)},
+	"uptime":                  {(*int64)(nil)},
+	"validateaddress":         {(*btcjson.ValidateAddressChainResult)(nil)},
+	"verifychain":             {(*bool)(nil)},
+	"verifymessage":           {(*bool)(nil)},
+	"verifytxoutproof":        {(*[]string)(nil)},
+	"version":                 {(*map[string]btcjson.VersionResult)(nil)},
 
 	// Websocket commands.
 	"loadtxfilter":              nil,
 	"session":                   {(*btcjson.SessionResult)(nil)},
 	"notifyblocks":              nil,
 	"stopnotifyblocks":          nil,
+	"notifychainreorg":          nil,
+	"stopnotifychainreorg":      nil,
+	"notifydoublespend":         nil,
+	"stopnotifydoublespend":     nil,
 	"notifynewtransactions":     nil,
 	"stopnotifynewtransactions": nil,
 	"notifyreceived":            nil,
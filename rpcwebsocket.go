@@ -8,9 +8,7 @@ package main
 import (
 	"bytes"
 	"container/list"
-	"crypto/sha256"
-	"crypto/subtle"
-	"encoding/base64"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -28,6 +26,7 @@ import (
 	"github.com/gcash/bchd/chaincfg"
 	"github.com/gcash/bchd/chaincfg/chainhash"
 	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/mempool"
 	"github.com/gcash/bchd/txscript"
 	"github.com/gcash/bchd/wire"
 	"github.com/gcash/bchutil"
@@ -67,11 +66,15 @@ var wsHandlersBeforeInit = map[string]wsCommandHandler{
 	"loadtxfilter":              handleLoadTxFilter,
 	"help":                      handleWebsocketHelp,
 	"notifyblocks":              handleNotifyBlocks,
+	"notifychainreorg":          handleNotifyChainReorg,
+	"notifydoublespend":         handleNotifyDoubleSpend,
 	"notifynewtransactions":     handleNotifyNewTransactions,
 	"notifyreceived":            handleNotifyReceived,
 	"notifyspent":               handleNotifySpent,
 	"session":                   handleSession,
 	"stopnotifyblocks":          handleStopNotifyBlocks,
+	"stopnotifychainreorg":      handleStopNotifyChainReorg,
+	"stopnotifydoublespend":     handleStopNotifyDoubleSpend,
 	"stopnotifynewtransactions": handleStopNotifyNewTransactions,
 	"stopnotifyspent":           handleStopNotifySpent,
 	"stopnotifyreceived":        handleStopNotifyReceived,
@@ -85,7 +88,7 @@ var wsHandlersBeforeInit = map[string]wsCommandHandler{
 // server handler which runs each new connection in a new goroutine thereby
 // satisfying the requirement.
 func (s *rpcServer) WebsocketHandler(conn *websocket.Conn, remoteAddr string,
-	authenticated bool, isAdmin bool) {
+	user *rpcCredential) {
 
 	// Clear the read deadline that was set before the websocket hijacked
 	// the connection.
@@ -104,7 +107,7 @@ func (s *rpcServer) WebsocketHandler(conn *websocket.Conn, remoteAddr string,
 	// Create a new websocket client to handle the new websocket connection
 	// and wait for it to shutdown.  Once it has shutdown (and hence
 	// disconnected), remove it and any notifications it registered for.
-	client, err := newWebsocketClient(s, conn, remoteAddr, authenticated, isAdmin)
+	client, err := newWebsocketClient(s, conn, remoteAddr, user)
 	if err != nil {
 		rpcsLog.Errorf("Failed to serve client %s: %v", remoteAddr, err)
 		conn.Close()
@@ -227,6 +230,33 @@ func (m *wsNotificationManager) NotifyBlockDisconnected(block *bchutil.Block) {
 	}
 }
 
+// NotifyChainReorganized passes the details of a reorganization of the main
+// chain to the notification manager for reorganization notification
+// processing.
+func (m *wsNotificationManager) NotifyChainReorganized(reorg *blockchain.ReorganizationData) {
+	// As NotifyChainReorganized will be called by the block manager
+	// and the RPC server may no longer be running, use a select
+	// statement to unblock enqueuing the notification once the RPC
+	// server has begun shutting down.
+	select {
+	case m.queueNotification <- (*notificationChainReorganized)(reorg):
+	case <-m.quit:
+	}
+}
+
+// NotifyDoubleSpend passes a double spend detected by the mempool to the
+// notification manager for double spend notification processing.
+func (m *wsNotificationManager) NotifyDoubleSpend(ds *mempool.DoubleSpend) {
+	// As NotifyDoubleSpend will be called by mempool and the RPC server
+	// may no longer be running, use a select statement to unblock
+	// enqueuing the notification once the RPC server has begun
+	// shutting down.
+	select {
+	case m.queueNotification <- (*notificationDoubleSpend)(ds):
+	case <-m.quit:
+	}
+}
+
 // NotifyMempoolTx passes a transaction accepted by mempool to the
 // notification manager for transaction notification processing.  If
 // isNew is true, the tx is is a new transaction, rather than one
@@ -448,6 +478,8 @@ func (f *wsClientFilter) removeUnspentOutPoint(op *wire.OutPoint) {
 // Notification types
 type notificationBlockConnected bchutil.Block
 type notificationBlockDisconnected bchutil.Block
+type notificationChainReorganized blockchain.ReorganizationData
+type notificationDoubleSpend mempool.DoubleSpend
 type notificationTxAcceptedByMempool struct {
 	isNew bool
 	tx    *bchutil.Tx
@@ -458,6 +490,10 @@ type notificationRegisterClient wsClient
 type notificationUnregisterClient wsClient
 type notificationRegisterBlocks wsClient
 type notificationUnregisterBlocks wsClient
+type notificationRegisterChainReorg wsClient
+type notificationUnregisterChainReorg wsClient
+type notificationRegisterDoubleSpend wsClient
+type notificationUnregisterDoubleSpend wsClient
 type notificationRegisterNewMempoolTxs wsClient
 type notificationUnregisterNewMempoolTxs wsClient
 type notificationRegisterSpent struct {
@@ -492,6 +528,8 @@ func (m *wsNotificationManager) notificationHandler() {
 	// since it is quite a bit more efficient than using the entire struct.
 	blockNotifications := make(map[chan struct{}]*wsClient)
 	txNotifications := make(map[chan struct{}]*wsClient)
+	reorgNotifications := make(map[chan struct{}]*wsClient)
+	doubleSpendNotifications := make(map[chan struct{}]*wsClient)
 	watchedOutPoints := make(map[wire.OutPoint]map[chan struct{}]*wsClient)
 	watchedAddrs := make(map[string]map[chan struct{}]*wsClient)
 
@@ -533,6 +571,18 @@ out:
 						block)
 				}
 
+			case *notificationChainReorganized:
+				if len(reorgNotifications) != 0 {
+					m.notifyChainReorganized(reorgNotifications,
+						(*blockchain.ReorganizationData)(n))
+				}
+
+			case *notificationDoubleSpend:
+				if len(doubleSpendNotifications) != 0 {
+					m.notifyDoubleSpend(doubleSpendNotifications,
+						(*mempool.DoubleSpend)(n))
+				}
+
 			case *notificationTxAcceptedByMempool:
 				if n.isNew && len(txNotifications) != 0 {
 					m.notifyForNewTx(txNotifications, n.tx)
@@ -548,6 +598,22 @@ out:
 				wsc := (*wsClient)(n)
 				delete(blockNotifications, wsc.quit)
 
+			case *notificationRegisterChainReorg:
+				wsc := (*wsClient)(n)
+				reorgNotifications[wsc.quit] = wsc
+
+			case *notificationUnregisterChainReorg:
+				wsc := (*wsClient)(n)
+				delete(reorgNotifications, wsc.quit)
+
+			case *notificationRegisterDoubleSpend:
+				wsc := (*wsClient)(n)
+				doubleSpendNotifications[wsc.quit] = wsc
+
+			case *notificationUnregisterDoubleSpend:
+				wsc := (*wsClient)(n)
+				delete(doubleSpendNotifications, wsc.quit)
+
 			case *notificationRegisterClient:
 				wsc := (*wsClient)(n)
 				clients[wsc.quit] = wsc
@@ -558,6 +624,8 @@ out:
 				// the client itself.
 				delete(blockNotifications, wsc.quit)
 				delete(txNotifications, wsc.quit)
+				delete(reorgNotifications, wsc.quit)
+				delete(doubleSpendNotifications, wsc.quit)
 				for k := range wsc.spentRequests {
 					op := k
 					m.removeSpentRequest(watchedOutPoints, wsc, &op)
@@ -626,6 +694,30 @@ func (m *wsNotificationManager) UnregisterBlockUpdates(wsc *wsClient) {
 	m.queueNotification <- (*notificationUnregisterBlocks)(wsc)
 }
 
+// RegisterChainReorgUpdates requests chain reorganization notifications to the
+// passed websocket client.
+func (m *wsNotificationManager) RegisterChainReorgUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationRegisterChainReorg)(wsc)
+}
+
+// UnregisterChainReorgUpdates removes chain reorganization notifications for
+// the passed websocket client.
+func (m *wsNotificationManager) UnregisterChainReorgUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationUnregisterChainReorg)(wsc)
+}
+
+// RegisterDoubleSpendUpdates requests double spend notifications to the passed
+// websocket client.
+func (m *wsNotificationManager) RegisterDoubleSpendUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationRegisterDoubleSpend)(wsc)
+}
+
+// UnregisterDoubleSpendUpdates removes double spend notifications for the
+// passed websocket client.
+func (m *wsNotificationManager) UnregisterDoubleSpendUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationUnregisterDoubleSpend)(wsc)
+}
+
 // subscribedClients returns the set of all websocket client quit channels that
 // are registered to receive notifications regarding tx, either due to tx
 // spending a watched output or outputting to a watched address.  Matching
@@ -731,6 +823,52 @@ func (*wsNotificationManager) notifyBlockDisconnected(clients map[chan struct{}]
 	}
 }
 
+// notifyChainReorganized notifies websocket clients that have registered for
+// chain reorganization updates when the main chain is reorganized.
+func (*wsNotificationManager) notifyChainReorganized(clients map[chan struct{}]*wsClient, reorg *blockchain.ReorganizationData) {
+	hashStrings := func(hashes []chainhash.Hash) []string {
+		strs := make([]string, 0, len(hashes))
+		for i := range hashes {
+			strs = append(strs, hashes[i].String())
+		}
+		return strs
+	}
+	ntfn := btcjson.NewChainReorgNtfn(reorg.ForkHash.String(),
+		reorg.ForkHeight, hashStrings(reorg.Disconnected),
+		hashStrings(reorg.Connected), hashStrings(reorg.Unconfirmed))
+	marshalledJSON, err := btcjson.MarshalCmd("1.0", nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal chain reorganization "+
+			"notification: %v", err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
+// notifyDoubleSpend notifies websocket clients that have registered for double
+// spend updates when a transaction which double spends a transaction in the
+// mempool is detected.
+func (*wsNotificationManager) notifyDoubleSpend(clients map[chan struct{}]*wsClient, ds *mempool.DoubleSpend) {
+	outPoint := btcjson.OutPoint{
+		Hash:  ds.OutPoint.Hash.String(),
+		Index: ds.OutPoint.Index,
+	}
+	ntfn := btcjson.NewDoubleSpendNtfn(outPoint,
+		ds.FirstSeen.Hash().String(), ds.Conflict.Hash().String(),
+		ds.Confirmed)
+	marshalledJSON, err := btcjson.MarshalCmd("1.0", nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal double spend notification: "+
+			"%v", err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
 // notifyFilteredBlockConnected notifies websocket clients that have registered for
 // block updates when a block is connected to the main chain.
 func (m *wsNotificationManager) notifyFilteredBlockConnected(clients map[chan struct{}]*wsClient,
@@ -1265,9 +1403,9 @@ type wsClient struct {
 	// and therefore is allowed to communicated over the websocket.
 	authenticated bool
 
-	// isAdmin specifies whether a client may change the state of the server;
-	// false means its access is only to the limited set of RPC calls.
-	isAdmin bool
+	// user is the credential the client authenticated with, which
+	// determines the RPC calls it may make.
+	user *rpcCredential
 
 	// sessionID is a random ID generated for each client when connected.
 	// These IDs may be queried by a client using the session RPC.  A change
@@ -1299,6 +1437,11 @@ type wsClient struct {
 	sendChan          chan wsResponse
 	quit              chan struct{}
 	wg                sync.WaitGroup
+
+	// ctx is canceled when the client disconnects or the server is
+	// shutting down and stops the long-running commands of the client.
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // inHandler handles all incoming messages for the websocket connection.  It
@@ -1412,17 +1555,14 @@ out:
 				break out
 			case !c.authenticated:
 				// Check credentials.
-				login := authCmd.Username + ":" + authCmd.Passphrase
-				auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-				authSha := sha256.Sum256([]byte(auth))
-				cmp := subtle.ConstantTimeCompare(authSha[:], c.server.authsha[:])
-				limitcmp := subtle.ConstantTimeCompare(authSha[:], c.server.limitauthsha[:])
-				if cmp != 1 && limitcmp != 1 {
+				user := c.server.authenticate(authCmd.Username,
+					authCmd.Passphrase)
+				if user == nil {
 					rpcsLog.Warnf("Auth failure.")
 					break out
 				}
 				c.authenticated = true
-				c.isAdmin = cmp == 1
+				c.user = user
 
 				// Marshal and send response.
 				reply, err = createMarshalledReply(cmd.jsonrpc, cmd.id, nil, nil)
@@ -1435,24 +1575,36 @@ out:
 				continue
 			}
 
-			// Check if the client is using limited RPC credentials and
+			// Check if the client is using restricted RPC credentials and
 			// error when not authorized to call the supplied RPC.
-			if !c.isAdmin {
-				if _, ok := rpcLimited[req.Method]; !ok {
-					jsonErr := &btcjson.RPCError{
-						Code:    btcjson.ErrRPCInvalidParams.Code,
-						Message: "limited user not authorized for this method",
-					}
-					// Marshal and send response.
-					reply, err = createMarshalledReply("", req.ID, nil, jsonErr)
-					if err != nil {
-						rpcsLog.Errorf("Failed to marshal parse failure "+
-							"reply: %v", err)
-						continue
-					}
-					c.SendMessage(reply, nil)
+			if !c.user.authorized(req.Method) {
+				jsonErr := &btcjson.RPCError{
+					Code:    btcjson.ErrRPCInvalidParams.Code,
+					Message: "user not authorized for this method",
+				}
+				// Marshal and send response.
+				reply, err = createMarshalledReply("", req.ID, nil, jsonErr)
+				if err != nil {
+					rpcsLog.Errorf("Failed to marshal parse failure "+
+						"reply: %v", err)
+					continue
+				}
+				c.SendMessage(reply, nil)
+				continue
+			}
+
+			// Error when the client exceeds its request quota.
+			release, err := c.server.quotas.Acquire(c.addr, c.user.user)
+			if err != nil {
+				reply, err = createMarshalledReply("", req.ID, nil,
+					rpcQuotaError(err))
+				if err != nil {
+					rpcsLog.Errorf("Failed to marshal quota failure "+
+						"reply: %v", err)
 					continue
 				}
+				c.SendMessage(reply, nil)
+				continue
 			}
 
 			// Asynchronously handle the request.  A semaphore is used to
@@ -1478,6 +1630,7 @@ out:
 			c.serviceRequestSem.acquire()
 			go func() {
 				c.serviceRequest(cmd)
+				release()
 				c.serviceRequestSem.release()
 			}()
 		}
@@ -1649,18 +1802,15 @@ out:
 							break out
 						case !c.authenticated:
 							// Check credentials.
-							login := authCmd.Username + ":" + authCmd.Passphrase
-							auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-							authSha := sha256.Sum256([]byte(auth))
-							cmp := subtle.ConstantTimeCompare(authSha[:], c.server.authsha[:])
-							limitcmp := subtle.ConstantTimeCompare(authSha[:], c.server.limitauthsha[:])
-							if cmp != 1 && limitcmp != 1 {
+							user := c.server.authenticate(authCmd.Username,
+								authCmd.Passphrase)
+							if user == nil {
 								rpcsLog.Warnf("Auth failure.")
 								break out
 							}
 
 							c.authenticated = true
-							c.isAdmin = cmp == 1
+							c.user = user
 
 							// Marshal and send response.
 							reply, err = createMarshalledReply(cmd.jsonrpc, cmd.id, nil, nil)
@@ -1676,27 +1826,42 @@ out:
 							continue
 						}
 
-						// Check if the client is using limited RPC credentials and
+						// Check if the client is using restricted RPC credentials and
 						// error when not authorized to call the supplied RPC.
-						if !c.isAdmin {
-							if _, ok := rpcLimited[req.Method]; !ok {
-								jsonErr := &btcjson.RPCError{
-									Code:    btcjson.ErrRPCInvalidParams.Code,
-									Message: "limited user not authorized for this method",
-								}
-								// Marshal and send response.
-								reply, err = createMarshalledReply(req.Jsonrpc, req.ID, nil, jsonErr)
-								if err != nil {
-									rpcsLog.Errorf("Failed to marshal parse failure "+
-										"reply: %v", err)
-									continue
-								}
-
-								if reply != nil {
-									results = append(results, reply)
-								}
+						if !c.user.authorized(req.Method) {
+							jsonErr := &btcjson.RPCError{
+								Code:    btcjson.ErrRPCInvalidParams.Code,
+								Message: "user not authorized for this method",
+							}
+							// Marshal and send response.
+							reply, err = createMarshalledReply(req.Jsonrpc, req.ID, nil, jsonErr)
+							if err != nil {
+								rpcsLog.Errorf("Failed to marshal parse failure "+
+									"reply: %v", err)
 								continue
 							}
+
+							if reply != nil {
+								results = append(results, reply)
+							}
+							continue
+						}
+
+						// Error when the client exceeds its request quota.
+						release, err := c.server.quotas.Acquire(c.addr, c.user.user)
+						if err != nil {
+							reply, err = createMarshalledReply(req.Jsonrpc, req.ID, nil,
+								rpcQuotaError(err))
+							if err != nil {
+								rpcsLog.Errorf("Failed to marshal quota failure "+
+									"reply: %v", err)
+								continue
+							}
+
+							if reply != nil {
+								results = append(results, reply)
+							}
+							continue
 						}
 
 						// Lookup the websocket extension for the command, if it doesn't
@@ -1706,8 +1871,9 @@ out:
 						if ok {
 							resp, err = wsHandler(c, cmd.cmd)
 						} else {
-							resp, err = c.server.standardCmdResult(cmd, nil)
+							resp, err = c.server.standardCmdResult(cmd, c.ctx)
 						}
+						release()
 
 						// Marshal request output.
 						reply, err := createMarshalledReply(cmd.jsonrpc, cmd.id, resp, err)
@@ -1716,6 +1882,7 @@ out:
 								"command: %v", cmd.method, err)
 							return
 						}
+						c.server.quotas.ChargeResponse(c.addr, c.user.user, len(reply))
 
 						if reply != nil {
 							results = append(results, reply)
@@ -1777,7 +1944,7 @@ func (c *wsClient) serviceRequest(r *parsedRPCCmd) {
 	if ok {
 		result, err = wsHandler(c, r.cmd)
 	} else {
-		result, err = c.server.standardCmdResult(r, nil)
+		result, err = c.server.standardCmdResult(r, c.ctx)
 	}
 	reply, err := createMarshalledReply(r.jsonrpc, r.id, result, err)
 	if err != nil {
@@ -1785,6 +1952,7 @@ func (c *wsClient) serviceRequest(r *parsedRPCCmd) {
 			"command: %v", r.method, err)
 		return
 	}
+	c.server.quotas.ChargeResponse(c.addr, c.user.user, len(reply))
 
 	c.SendMessage(reply, nil)
 }
@@ -1966,6 +2134,7 @@ func (c *wsClient) Disconnect() {
 
 	rpcsLog.Tracef("Disconnecting websocket client %s", c.addr)
 	close(c.quit)
+	c.cancel()
 	c.conn.Close()
 	c.disconnected = true
 }
@@ -1994,7 +2163,7 @@ func (c *wsClient) WaitForShutdown() {
 // incoming and outgoing messages in separate goroutines complete with queuing
 // and asynchrous handling for long-running operations.
 func newWebsocketClient(server *rpcServer, conn *websocket.Conn,
-	remoteAddr string, authenticated bool, isAdmin bool) (*wsClient, error) {
+	remoteAddr string, user *rpcCredential) (*wsClient, error) {
 
 	sessionID, err := wire.RandomUint64()
 	if err != nil {
@@ -2004,8 +2173,8 @@ func newWebsocketClient(server *rpcServer, conn *websocket.Conn,
 	client := &wsClient{
 		conn:              conn,
 		addr:              remoteAddr,
-		authenticated:     authenticated,
-		isAdmin:           isAdmin,
+		authenticated:     user != nil,
+		user:              user,
 		sessionID:         sessionID,
 		server:            server,
 		addrRequests:      make(map[string]struct{}),
@@ -2015,6 +2184,7 @@ func newWebsocketClient(server *rpcServer, conn *websocket.Conn,
 		sendChan:          make(chan wsResponse, websocketSendBufferSize),
 		quit:              make(chan struct{}),
 	}
+	client.ctx, client.cancel = server.requestContext(context.Background())
 	return client, nil
 }
 
@@ -2117,6 +2287,20 @@ func handleNotifyBlocks(wsc *wsClient, icmd interface{}) (interface{}, error) {
 	return nil, nil
 }
 
+// handleNotifyChainReorg implements the notifychainreorg command extension for
+// websocket connections.
+func handleNotifyChainReorg(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.RegisterChainReorgUpdates(wsc)
+	return nil, nil
+}
+
+// handleNotifyDoubleSpend implements the notifydoublespend command extension
+// for websocket connections.
+func handleNotifyDoubleSpend(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.RegisterDoubleSpendUpdates(wsc)
+	return nil, nil
+}
+
 // handleSession implements the session command extension for websocket
 // connections.
 func handleSession(wsc *wsClient, icmd interface{}) (interface{}, error) {
@@ -2130,6 +2314,20 @@ func handleStopNotifyBlocks(wsc *wsClient, icmd interface{}) (interface{}, error
 	return nil, nil
 }
 
+// handleStopNotifyChainReorg implements the stopnotifychainreorg command
+// extension for websocket connections.
+func handleStopNotifyChainReorg(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.UnregisterChainReorgUpdates(wsc)
+	return nil, nil
+}
+
+// handleStopNotifyDoubleSpend implements the stopnotifydoublespend command
+// extension for websocket connections.
+func handleStopNotifyDoubleSpend(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.UnregisterDoubleSpendUpdates(wsc)
+	return nil, nil
+}
+
 // handleNotifySpent implements the notifyspent command extension for
 // websocket connections.
 func handleNotifySpent(wsc *wsClient, icmd interface{}) (interface{}, error) {
@@ -2528,6 +2726,12 @@ func handleRescanBlocks(wsc *wsClient, icmd interface{}) (interface{}, error) {
 	params := wsc.server.cfg.ChainParams
 	var lastBlockHash *chainhash.Hash
 	for i := range blockHashes {
+		// Stop the rescan when the client disconnected or the server
+		// is shutting down.
+		if wsc.ctx.Err() != nil {
+			return nil, ErrClientQuit
+		}
+
 		block, err := bc.BlockByHash(blockHashes[i])
 		if err != nil {
 			return nil, &btcjson.RPCError{
@@ -0,0 +1,189 @@
+package seeder
+
+import (
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gcash/bchd/wire"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// maxDNSMessageSize is the maximum size of a DNS message over UDP
+	// without extensions.
+	maxDNSMessageSize = 512
+
+	// maxARecords and maxAAAARecords are the maximum number of addresses
+	// returned for a query, which keep the reply within maxDNSMessageSize.
+	maxARecords    = 25
+	maxAAAARecords = 15
+
+	// addrTTL is the time to live in seconds of the returned addresses,
+	// which is short so resolvers pick up fresh nodes often.
+	addrTTL = 60
+
+	// nsTTL is the time to live in seconds of the NS record.
+	nsTTL = 86400
+)
+
+// listenHandler answers the DNS queries received on the passed listener.  It
+// must be run as a goroutine.
+func (s *Seeder) listenHandler(listener net.PacketConn) {
+	log.Infof("DNS seeder listening on %s", listener.LocalAddr())
+	buf := make([]byte, maxDNSMessageSize)
+	for atomic.LoadInt32(&s.shutdown) == 0 {
+		n, addr, err := listener.ReadFrom(buf)
+		if err != nil {
+			// Only log the error if not forcibly shutting down.
+			if atomic.LoadInt32(&s.shutdown) == 0 {
+				log.Errorf("Can't read DNS query: %v", err)
+			}
+			continue
+		}
+
+		reply := s.handleQuery(buf[:n])
+		if reply == nil {
+			continue
+		}
+		if _, err := listener.WriteTo(reply, addr); err != nil {
+			log.Debugf("Can't send DNS reply to %s: %v", addr, err)
+		}
+	}
+
+	s.wg.Done()
+	log.Tracef("DNS seeder listener done for %s", listener.LocalAddr())
+}
+
+// parseServices returns the service flags the nodes returned for a query of
+// the passed name must offer.  It returns false when the name is neither the
+// seeder host name nor a subdomain of the form x<hex>.
+func (s *Seeder) parseServices(name string) (wire.ServiceFlag, bool) {
+	if name == s.host {
+		return wire.SFNodeNetwork, true
+	}
+	label := strings.TrimSuffix(name, "."+s.host)
+	if len(label) < 2 || label[0] != 'x' {
+		return 0, false
+	}
+	services, err := strconv.ParseUint(label[1:], 16, 64)
+	if err != nil {
+		return 0, false
+	}
+	return wire.ServiceFlag(services), true
+}
+
+// handleQuery returns the reply to the passed DNS query, or nil when the query
+// is invalid and should not be answered.
+func (s *Seeder) handleQuery(query []byte) []byte {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil || header.Response {
+		return nil
+	}
+	question, err := parser.Question()
+	if err != nil {
+		return nil
+	}
+
+	replyHeader := dnsmessage.Header{
+		ID:               header.ID,
+		Response:         true,
+		Authoritative:    true,
+		RecursionDesired: header.RecursionDesired,
+	}
+	name := strings.ToLower(question.Name.String())
+	services, ok := s.parseServices(name)
+	switch {
+	case header.OpCode != 0:
+		replyHeader.RCode = dnsmessage.RCodeNotImplemented
+	case name != s.host && !strings.HasSuffix(name, "."+s.host):
+		replyHeader.Authoritative = false
+		replyHeader.RCode = dnsmessage.RCodeRefused
+	case !ok:
+		replyHeader.RCode = dnsmessage.RCodeNameError
+	}
+
+	b := dnsmessage.NewBuilder(make([]byte, 0, maxDNSMessageSize),
+		replyHeader)
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil
+	}
+	if err := b.Question(question); err != nil {
+		return nil
+	}
+	if err := b.StartAnswers(); err != nil {
+		return nil
+	}
+	if replyHeader.RCode == dnsmessage.RCodeSuccess &&
+		question.Class == dnsmessage.ClassINET {
+
+		if err := s.addAnswers(&b, question, services); err != nil {
+			log.Debugf("Can't build DNS reply: %v", err)
+			return nil
+		}
+	}
+	reply, err := b.Finish()
+	if err != nil {
+		return nil
+	}
+	return reply
+}
+
+// addAnswers adds the resources answering the passed question to the reply
+// being built.
+func (s *Seeder) addAnswers(b *dnsmessage.Builder, question dnsmessage.Question,
+	services wire.ServiceFlag) error {
+
+	resource := dnsmessage.ResourceHeader{
+		Name:  question.Name,
+		Class: dnsmessage.ClassINET,
+		TTL:   addrTTL,
+	}
+	switch question.Type {
+	case dnsmessage.TypeA:
+		for _, ip := range s.randomAddrs(services, false, maxARecords) {
+			var a dnsmessage.AResource
+			copy(a.A[:], ip.To4())
+			if err := b.AResource(resource, a); err != nil {
+				return err
+			}
+		}
+
+	case dnsmessage.TypeAAAA:
+		for _, ip := range s.randomAddrs(services, true, maxAAAARecords) {
+			var aaaa dnsmessage.AAAAResource
+			copy(aaaa.AAAA[:], ip.To16())
+			if err := b.AAAAResource(resource, aaaa); err != nil {
+				return err
+			}
+		}
+
+	case dnsmessage.TypeNS:
+		if s.cfg.Nameserver == "" ||
+			strings.ToLower(question.Name.String()) != s.host {
+
+			return nil
+		}
+		ns, err := dnsmessage.NewName(canonicalName(s.cfg.Nameserver))
+		if err != nil {
+			return err
+		}
+		resource.TTL = nsTTL
+		return b.NSResource(resource, dnsmessage.NSResource{NS: ns})
+	}
+	return nil
+}
+
+// randomAddrs returns up to the passed number of randomly selected addresses of
+// good nodes which offer the passed services.
+func (s *Seeder) randomAddrs(services wire.ServiceFlag, ipv6 bool, limit int) []net.IP {
+	ips := s.goodAddrs(services, ipv6)
+	rand.Shuffle(len(ips), func(i, j int) {
+		ips[i], ips[j] = ips[j], ips[i]
+	})
+	return ips[:min(len(ips), limit)]
+}
@@ -0,0 +1,19 @@
+/*
+Package seeder implements a DNS seeder which crawls the network and answers DNS
+queries with the addresses of reachable nodes.
+
+The seeder takes the addresses to crawl from the caller, which typically hands
+over the contents of its address manager, and connects to each of them to
+perform the version handshake and request more addresses.  Nodes are considered
+good when the last attempt succeeded, they serve the full block chain on the
+default port of the network and are close to the best known height.  Good
+nodes are crawled again regularly while unreachable nodes are retried with an
+increasing backoff.
+
+DNS queries for A and AAAA records of the seeder host name are answered with a
+random selection of the good IPv4 and IPv6 nodes.  Like the seeders of the
+reference implementation, queries for a subdomain of the form x<hex>, such as
+x5.seed.example.com, only return nodes which offer all of the service flags
+encoded in the hex number.
+*/
+package seeder
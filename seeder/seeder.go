@@ -0,0 +1,480 @@
+package seeder
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gcash/bchd/addrmgr"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/peer"
+	"github.com/gcash/bchd/wire"
+)
+
+const (
+	// crawlInterval is the interval at which the seeder picks up new
+	// addresses and starts crawling the nodes which are due.
+	crawlInterval = 10 * time.Second
+
+	// maxConcurrentCrawls is the maximum number of nodes which are crawled
+	// at the same time.
+	maxConcurrentCrawls = 32
+
+	// goodRecrawlInterval is the time after which a reachable node is
+	// crawled again.
+	goodRecrawlInterval = 30 * time.Minute
+
+	// failedRecrawlInterval is the time after which an unreachable node
+	// is retried for the first time.  It doubles with every consecutive
+	// failure up to maxRecrawlInterval.
+	failedRecrawlInterval = time.Hour
+
+	// maxRecrawlInterval is the maximum time after which an unreachable
+	// node is retried.
+	maxRecrawlInterval = 24 * time.Hour
+
+	// handshakeTimeout is the maximum time the version handshake with a
+	// node may take.
+	handshakeTimeout = 20 * time.Second
+
+	// getAddrTimeout is the maximum time to wait for the reply to the
+	// getaddr message sent to a node.
+	getAddrTimeout = 10 * time.Second
+
+	// maxHeightLag is the maximum number of blocks a good node may be
+	// behind the best known height.
+	maxHeightLag = 144
+
+	// statsInterval is the interval at which the number of known and good
+	// nodes is logged.
+	statsInterval = 10 * time.Minute
+)
+
+var (
+	// ErrNoHost is returned when no host name is configured.
+	ErrNoHost = errors.New("seeder: no host name")
+
+	// ErrDialNil is returned when no dial function is configured.
+	ErrDialNil = errors.New("seeder: dial cannot be nil")
+
+	// ErrGetAddressesNil is returned when no function to get the addresses
+	// to crawl is configured.
+	ErrGetAddressesNil = errors.New("seeder: get addresses cannot be nil")
+)
+
+// Config is a descriptor containing the DNS seeder configuration.
+type Config struct {
+	// ChainParams identifies which chain parameters the seeder is
+	// associated with.
+	ChainParams *chaincfg.Params
+
+	// Host is the host name the seeder answers queries for, such as
+	// seed.example.com.
+	Host string
+
+	// Nameserver is the host name of the name server the seeder runs on,
+	// which is returned for NS queries.  No NS record is served when it is
+	// empty.
+	Nameserver string
+
+	// Listeners defines a slice of UDP listeners the seeder answers DNS
+	// queries on.  The seeder takes ownership of them and closes them when
+	// it is stopped.
+	Listeners []net.PacketConn
+
+	// Dial connects to the address on the named network.  It cannot be nil.
+	Dial func(net.Addr) (net.Conn, error)
+
+	// GetAddresses returns the addresses to crawl.  It is called at every
+	// crawl interval and cannot be nil.
+	GetAddresses func() []*wire.NetAddress
+
+	// OnAddresses is invoked with the addresses a crawled node advertised
+	// in reply to the getaddr message along with the node's address.  It
+	// may be nil.
+	OnAddresses func(addrs []*wire.NetAddress, srcAddr *wire.NetAddress)
+
+	// BestHeight returns the height of the best known block.  Nodes which
+	// are too far behind it are not considered good.  It may be nil.
+	BestHeight func() int32
+
+	// UserAgentName and UserAgentVersion are the user agent the seeder
+	// advertises to the crawled nodes.
+	UserAgentName    string
+	UserAgentVersion string
+}
+
+// node houses the state of a crawled node.
+type node struct {
+	addr            *wire.NetAddress
+	services        wire.ServiceFlag
+	protocolVersion uint32
+	userAgent       string
+	height          int32
+	lastAttempt     time.Time
+	lastSuccess     time.Time
+	failures        uint32
+	crawling        bool
+}
+
+// nextAttempt returns the time at which the node is due to be crawled again.
+func (n *node) nextAttempt() time.Time {
+	if n.lastAttempt.IsZero() {
+		return n.lastAttempt
+	}
+	if n.failures == 0 {
+		return n.lastAttempt.Add(goodRecrawlInterval)
+	}
+	interval := maxRecrawlInterval
+	if n.failures < 6 {
+		interval = min(failedRecrawlInterval<<(n.failures-1),
+			maxRecrawlInterval)
+	}
+	return n.lastAttempt.Add(interval)
+}
+
+// Seeder crawls the network and answers DNS queries with the addresses of the
+// good nodes it found.
+type Seeder struct {
+	started  int32
+	shutdown int32
+
+	cfg         Config
+	host        string
+	defaultPort uint16
+
+	mtx   sync.RWMutex
+	nodes map[string]*node
+
+	// allowSelfConnection is only used to allow the tests to crawl a peer
+	// in the same process.
+	allowSelfConnection bool
+
+	wg   sync.WaitGroup
+	quit chan struct{}
+}
+
+// New returns a new DNS seeder with the passed configuration.  Use Start to
+// start crawling and answering queries.
+func New(cfg *Config) (*Seeder, error) {
+	if cfg.Host == "" {
+		return nil, ErrNoHost
+	}
+	if cfg.Dial == nil {
+		return nil, ErrDialNil
+	}
+	if cfg.GetAddresses == nil {
+		return nil, ErrGetAddressesNil
+	}
+	port, err := strconv.ParseUint(cfg.ChainParams.DefaultPort, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Seeder{
+		cfg:         *cfg,
+		host:        canonicalName(cfg.Host),
+		defaultPort: uint16(port),
+		nodes:       make(map[string]*node),
+		quit:        make(chan struct{}),
+	}, nil
+}
+
+// canonicalName returns the passed DNS name in lower case and fully qualified.
+func canonicalName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, ".")) + "."
+}
+
+// crawlable returns whether or not the passed address can be crawled and served
+// in DNS replies, which is the case for routable IPv4 and IPv6 addresses.
+func crawlable(na *wire.NetAddress) bool {
+	return na.IP != nil && addrmgr.IsRoutable(na) &&
+		!addrmgr.IsOnionCatTor(na)
+}
+
+// isGood returns whether or not the passed node is good enough to be served
+// in DNS replies.
+//
+// This function MUST be called with the mutex held (for reads).
+func (s *Seeder) isGood(n *node, minHeight int32) bool {
+	return n.failures == 0 && !n.lastSuccess.IsZero() &&
+		n.addr.Port == s.defaultPort &&
+		n.services&wire.SFNodeNetwork == wire.SFNodeNetwork &&
+		n.protocolVersion >= peer.MinAcceptableProtocolVersion &&
+		n.height >= minHeight
+}
+
+// minHeight returns the minimum height a good node must be at.
+func (s *Seeder) minHeight() int32 {
+	if s.cfg.BestHeight == nil {
+		return 0
+	}
+	return s.cfg.BestHeight() - maxHeightLag
+}
+
+// goodAddrs returns the IP addresses of the good nodes which offer the passed
+// services.  Only IPv6 addresses are returned when ipv6 is set, and only IPv4
+// addresses otherwise.
+func (s *Seeder) goodAddrs(services wire.ServiceFlag, ipv6 bool) []net.IP {
+	minHeight := s.minHeight()
+
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var ips []net.IP
+	for _, n := range s.nodes {
+		if !s.isGood(n, minHeight) || n.services&services != services ||
+			addrmgr.IsIPv4(n.addr) == ipv6 {
+
+			continue
+		}
+		ips = append(ips, n.addr.IP)
+	}
+	return ips
+}
+
+// addAddresses adds the crawlable addresses among the passed ones to the set
+// of known nodes.
+func (s *Seeder) addAddresses(addrs []*wire.NetAddress) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, na := range addrs {
+		if !crawlable(na) {
+			continue
+		}
+		key := addrmgr.NetAddressKey(na)
+		if _, ok := s.nodes[key]; ok {
+			continue
+		}
+		s.nodes[key] = &node{addr: na}
+	}
+}
+
+// dueNodes marks up to the passed number of nodes which are due to be crawled
+// as being crawled and returns them.
+func (s *Seeder) dueNodes(limit int) []*node {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	now := time.Now()
+	var due []*node
+	for _, n := range s.nodes {
+		if len(due) >= limit {
+			break
+		}
+		if n.crawling || n.nextAttempt().After(now) {
+			continue
+		}
+		n.crawling = true
+		due = append(due, n)
+	}
+	return due
+}
+
+// crawl connects to the passed node, performs the version handshake and
+// requests its addresses.  The result is recorded in the node.
+func (s *Seeder) crawl(n *node) {
+	p, err := s.handshake(n.addr)
+
+	s.mtx.Lock()
+	n.crawling = false
+	n.lastAttempt = time.Now()
+	if err != nil {
+		n.failures++
+		s.mtx.Unlock()
+		log.Tracef("Failed to crawl %s: %v", addrmgr.NetAddressKey(n.addr),
+			err)
+		return
+	}
+	n.failures = 0
+	n.lastSuccess = n.lastAttempt
+	n.services = p.Services()
+	n.protocolVersion = p.ProtocolVersion()
+	n.userAgent = p.UserAgent()
+	n.height = p.StartingHeight()
+	s.mtx.Unlock()
+	log.Tracef("Crawled %s: services %v, protocol version %d, user agent "+
+		"%s, height %d", addrmgr.NetAddressKey(n.addr), n.services,
+		n.protocolVersion, n.userAgent, n.height)
+
+	// Nodes answer a single getaddr message per connection at most and
+	// may not answer at all, so don't wait on the reply for long.
+	addrs := p.addrs
+	p.QueueMessage(wire.NewMsgGetAddr(), nil)
+	select {
+	case list := <-addrs:
+		if s.cfg.OnAddresses != nil {
+			s.cfg.OnAddresses(list, n.addr)
+		}
+		s.addAddresses(list)
+	case <-time.After(getAddrTimeout):
+	case <-s.quit:
+	}
+	p.Disconnect()
+}
+
+// crawlPeer is a peer connected to a crawled node.
+type crawlPeer struct {
+	*peer.Peer
+	addrs chan []*wire.NetAddress
+}
+
+// handshake connects to the passed address and performs the version handshake.
+// The returned peer has to be disconnected by the caller.
+func (s *Seeder) handshake(na *wire.NetAddress) (*crawlPeer, error) {
+	addr := &net.TCPAddr{IP: na.IP, Port: int(na.Port)}
+	conn, err := s.cfg.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	verAck := make(chan struct{})
+	addrs := make(chan []*wire.NetAddress, 1)
+	onAddrs := func(list []*wire.NetAddress) {
+		select {
+		case addrs <- list:
+		default:
+		}
+	}
+	p, err := peer.NewOutboundPeer(&peer.Config{
+		UserAgentName:          s.cfg.UserAgentName,
+		UserAgentVersion:       s.cfg.UserAgentVersion,
+		ChainParams:            s.cfg.ChainParams,
+		DisableRelayTx:         true,
+		TstAllowSelfConnection: s.allowSelfConnection,
+		Listeners: peer.MessageListeners{
+			OnVerAck: func(*peer.Peer, *wire.MsgVerAck) {
+				close(verAck)
+			},
+			OnAddr: func(_ *peer.Peer, msg *wire.MsgAddr) {
+				onAddrs(msg.AddrList)
+			},
+			OnAddrV2: func(_ *peer.Peer, msg *wire.MsgAddrV2) {
+				onAddrs(msg.AddrList)
+			},
+		},
+	}, addr.String())
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	p.AssociateConnection(conn)
+	disconnected := make(chan struct{})
+	go func() {
+		p.WaitForDisconnect()
+		close(disconnected)
+	}()
+
+	select {
+	case <-verAck:
+		return &crawlPeer{Peer: p, addrs: addrs}, nil
+	case <-disconnected:
+		return nil, errors.New("disconnected during version handshake")
+	case <-time.After(handshakeTimeout):
+		p.Disconnect()
+		return nil, errors.New("version handshake timed out")
+	case <-s.quit:
+		p.Disconnect()
+		return nil, errors.New("seeder shutting down")
+	}
+}
+
+// crawlHandler picks up new addresses and crawls the nodes which are due.  It
+// must be run as a goroutine.
+func (s *Seeder) crawlHandler() {
+	crawlTicker := time.NewTicker(crawlInterval)
+	defer crawlTicker.Stop()
+	statsTicker := time.NewTicker(statsInterval)
+	defer statsTicker.Stop()
+
+	var crawling int32
+	crawl := func() {
+		s.addAddresses(s.cfg.GetAddresses())
+
+		limit := maxConcurrentCrawls - int(atomic.LoadInt32(&crawling))
+		for _, n := range s.dueNodes(limit) {
+			atomic.AddInt32(&crawling, 1)
+			s.wg.Add(1)
+			go func(n *node) {
+				s.crawl(n)
+				atomic.AddInt32(&crawling, -1)
+				s.wg.Done()
+			}(n)
+		}
+	}
+	crawl()
+
+out:
+	for {
+		select {
+		case <-crawlTicker.C:
+			crawl()
+
+		case <-statsTicker.C:
+			known, good := s.NodeCounts()
+			log.Infof("Seeder knows %d nodes, %d of which are good",
+				known, good)
+
+		case <-s.quit:
+			break out
+		}
+	}
+
+	s.wg.Done()
+	log.Trace("Seeder crawl handler done")
+}
+
+// NodeCounts returns the number of known nodes and how many of them are good.
+//
+// This function is safe for concurrent access.
+func (s *Seeder) NodeCounts() (int, int) {
+	minHeight := s.minHeight()
+
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var good int
+	for _, n := range s.nodes {
+		if s.isGood(n, minHeight) {
+			good++
+		}
+	}
+	return len(s.nodes), good
+}
+
+// Start begins crawling the network and answering DNS queries.
+func (s *Seeder) Start() {
+	// Already started?
+	if atomic.AddInt32(&s.started, 1) != 1 {
+		return
+	}
+
+	log.Infof("Starting DNS seeder for %s", s.host)
+	s.wg.Add(1)
+	go s.crawlHandler()
+	for _, listener := range s.cfg.Listeners {
+		s.wg.Add(1)
+		go s.listenHandler(listener)
+	}
+}
+
+// Stop gracefully shuts down the seeder.
+func (s *Seeder) Stop() {
+	if atomic.AddInt32(&s.shutdown, 1) != 1 {
+		log.Warnf("DNS seeder already stopped")
+		return
+	}
+
+	close(s.quit)
+	for _, listener := range s.cfg.Listeners {
+		// Ignore the error since this is shutdown and there is no way
+		// to recover anyways.
+		_ = listener.Close()
+	}
+	s.wg.Wait()
+}
@@ -0,0 +1,344 @@
+package seeder
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/peer"
+	"github.com/gcash/bchd/wire"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// newTestSeeder returns a seeder for the main network which knows the passed
+// good nodes.
+func newTestSeeder(t *testing.T, nodes map[string]wire.ServiceFlag) *Seeder {
+	s, err := New(&Config{
+		ChainParams: &chaincfg.MainNetParams,
+		Host:        "seed.example.com",
+		Nameserver:  "ns.example.com",
+		Dial: func(net.Addr) (net.Conn, error) {
+			return nil, net.ErrClosed
+		},
+		GetAddresses: func() []*wire.NetAddress { return nil },
+		BestHeight:   func() int32 { return 1000 },
+	})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+
+	now := time.Now()
+	for ip, services := range nodes {
+		na := wire.NewNetAddressIPPort(net.ParseIP(ip), s.defaultPort,
+			services)
+		s.nodes[ip] = &node{
+			addr:            na,
+			services:        services,
+			protocolVersion: wire.ProtocolVersion,
+			height:          1000,
+			lastAttempt:     now,
+			lastSuccess:     now,
+		}
+	}
+	return s
+}
+
+// query returns the reply of the passed seeder to a query of the passed name
+// and type.
+func query(t *testing.T, s *Seeder, name string, qtype dnsmessage.Type) *dnsmessage.Message {
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: 1234, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  dnsmessage.MustNewName(name),
+			Type:  qtype,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	q, err := msg.Pack()
+	if err != nil {
+		t.Fatalf("Pack: unexpected error: %v", err)
+	}
+	reply := s.handleQuery(q)
+	if reply == nil {
+		t.Fatalf("no reply to query of %s", name)
+	}
+	if len(reply) > maxDNSMessageSize {
+		t.Fatalf("reply to query of %s is %d bytes", name, len(reply))
+	}
+	var r dnsmessage.Message
+	if err := r.Unpack(reply); err != nil {
+		t.Fatalf("Unpack: unexpected error: %v", err)
+	}
+	if r.Header.ID != 1234 || !r.Header.Response {
+		t.Fatalf("unexpected reply header %v", r.Header)
+	}
+	return &r
+}
+
+// TestHandleQuery ensures DNS queries are answered with the good nodes which
+// offer the requested services.
+func TestHandleQuery(t *testing.T) {
+	nodes := map[string]wire.ServiceFlag{
+		"1.1.1.1":  wire.SFNodeNetwork,
+		"2.2.2.2":  wire.SFNodeNetwork | wire.SFNodeBloom,
+		"3.3.3.3":  wire.SFNodeBloom,
+		"2001::1":  wire.SFNodeNetwork,
+		"2001::2":  wire.SFNodeNetwork | wire.SFNodeCF,
+		"4.4.4.4":  wire.SFNodeNetwork,
+		"5.5.5.5":  wire.SFNodeNetwork,
+		"6.6.6.6":  wire.SFNodeNetwork,
+		"2001::3":  wire.SFNodeNetwork,
+		"2001::99": wire.SFNodeNetwork,
+	}
+	s := newTestSeeder(t, nodes)
+
+	// Nodes which failed, are behind or run on another port are not good.
+	s.nodes["4.4.4.4"].failures = 1
+	s.nodes["5.5.5.5"].height = 1000 - maxHeightLag - 1
+	s.nodes["6.6.6.6"].addr.Port++
+	s.nodes["2001::99"].protocolVersion = peer.MinAcceptableProtocolVersion - 1
+
+	tests := []struct {
+		name  string
+		qtype dnsmessage.Type
+		rcode dnsmessage.RCode
+		addrs []string
+		ns    string
+	}{{
+		name:  "seed.example.com.",
+		qtype: dnsmessage.TypeA,
+		addrs: []string{"1.1.1.1", "2.2.2.2"},
+	}, {
+		name:  "SEED.Example.com.",
+		qtype: dnsmessage.TypeA,
+		addrs: []string{"1.1.1.1", "2.2.2.2"},
+	}, {
+		name:  "seed.example.com.",
+		qtype: dnsmessage.TypeAAAA,
+		addrs: []string{"2001::1", "2001::2", "2001::3"},
+	}, {
+		name:  "x5.seed.example.com.",
+		qtype: dnsmessage.TypeA,
+		addrs: []string{"2.2.2.2"},
+	}, {
+		name:  "x4.seed.example.com.",
+		qtype: dnsmessage.TypeA,
+		addrs: []string{"2.2.2.2"},
+	}, {
+		name:  "x101.seed.example.com.",
+		qtype: dnsmessage.TypeAAAA,
+		addrs: []string{"2001::2"},
+	}, {
+		name:  "seed.example.com.",
+		qtype: dnsmessage.TypeNS,
+		ns:    "ns.example.com.",
+	}, {
+		name:  "seed.example.com.",
+		qtype: dnsmessage.TypeTXT,
+	}, {
+		name:  "foo.seed.example.com.",
+		qtype: dnsmessage.TypeA,
+		rcode: dnsmessage.RCodeNameError,
+	}, {
+		name:  "xzz.seed.example.com.",
+		qtype: dnsmessage.TypeA,
+		rcode: dnsmessage.RCodeNameError,
+	}, {
+		name:  "example.com.",
+		qtype: dnsmessage.TypeA,
+		rcode: dnsmessage.RCodeRefused,
+	}}
+
+	for _, test := range tests {
+		r := query(t, s, test.name, test.qtype)
+		if r.Header.RCode != test.rcode {
+			t.Errorf("%s %v: unexpected rcode - got %v, want %v",
+				test.name, test.qtype, r.Header.RCode, test.rcode)
+			continue
+		}
+
+		want := make(map[string]bool)
+		for _, addr := range test.addrs {
+			want[addr] = true
+		}
+		var ns string
+		for _, answer := range r.Answers {
+			switch body := answer.Body.(type) {
+			case *dnsmessage.AResource:
+				ip := net.IP(body.A[:]).String()
+				if !want[ip] {
+					t.Errorf("%s %v: unexpected address %s",
+						test.name, test.qtype, ip)
+				}
+				delete(want, ip)
+			case *dnsmessage.AAAAResource:
+				ip := net.IP(body.AAAA[:]).String()
+				if !want[ip] {
+					t.Errorf("%s %v: unexpected address %s",
+						test.name, test.qtype, ip)
+				}
+				delete(want, ip)
+			case *dnsmessage.NSResource:
+				ns = body.NS.String()
+			}
+		}
+		if len(want) != 0 {
+			t.Errorf("%s %v: missing addresses %v", test.name,
+				test.qtype, want)
+		}
+		if ns != test.ns {
+			t.Errorf("%s %v: unexpected name server - got %q, "+
+				"want %q", test.name, test.qtype, ns, test.ns)
+		}
+	}
+}
+
+// TestHandleQueryLimit ensures the number of returned addresses is limited so
+// replies fit into a single UDP datagram.
+func TestHandleQueryLimit(t *testing.T) {
+	nodes := make(map[string]wire.ServiceFlag)
+	for i := 1; i <= 100; i++ {
+		nodes[fmt.Sprintf("8.0.%d.1", i)] = wire.SFNodeNetwork
+		nodes[fmt.Sprintf("2001::%x", i)] = wire.SFNodeNetwork
+	}
+	s := newTestSeeder(t, nodes)
+
+	r := query(t, s, "seed.example.com.", dnsmessage.TypeA)
+	if len(r.Answers) != maxARecords {
+		t.Fatalf("unexpected number of A records - got %d, want %d",
+			len(r.Answers), maxARecords)
+	}
+	r = query(t, s, "seed.example.com.", dnsmessage.TypeAAAA)
+	if len(r.Answers) != maxAAAARecords {
+		t.Fatalf("unexpected number of AAAA records - got %d, want %d",
+			len(r.Answers), maxAAAARecords)
+	}
+}
+
+// TestNextAttempt ensures unreachable nodes are retried with an increasing
+// backoff.
+func TestNextAttempt(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		failures uint32
+		interval time.Duration
+	}{
+		{0, goodRecrawlInterval},
+		{1, failedRecrawlInterval},
+		{2, 2 * failedRecrawlInterval},
+		{3, 4 * failedRecrawlInterval},
+		{5, 16 * failedRecrawlInterval},
+		{6, maxRecrawlInterval},
+		{100, maxRecrawlInterval},
+	}
+	for _, test := range tests {
+		n := &node{lastAttempt: now, failures: test.failures}
+		if got := n.nextAttempt().Sub(now); got != test.interval {
+			t.Errorf("%d failures: unexpected interval - got %v, "+
+				"want %v", test.failures, got, test.interval)
+		}
+	}
+
+	if n := (&node{}); !n.nextAttempt().IsZero() {
+		t.Error("new node is not due immediately")
+	}
+}
+
+// TestCrawl ensures crawling a node records its version and passes on the
+// addresses it advertises.
+func TestCrawl(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: unexpected error: %v", err)
+	}
+	defer listener.Close()
+
+	advertised := wire.NewNetAddressIPPort(net.ParseIP("8.8.8.8"), 8333,
+		wire.SFNodeNetwork)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		p := peer.NewInboundPeer(&peer.Config{
+			UserAgentName:          "remote",
+			UserAgentVersion:       "1.0.0",
+			ChainParams:            &chaincfg.MainNetParams,
+			Services:               wire.SFNodeNetwork | wire.SFNodeBloom,
+			TstAllowSelfConnection: true,
+			NewestBlock: func() (*chainhash.Hash, int32, error) {
+				return &chainhash.Hash{}, 1000, nil
+			},
+			Listeners: peer.MessageListeners{
+				OnGetAddr: func(p *peer.Peer, _ *wire.MsgGetAddr) {
+					msg := wire.NewMsgAddr()
+					msg.AddAddress(advertised)
+					p.QueueMessage(msg, nil)
+				},
+			},
+		})
+		p.AssociateConnection(conn)
+		p.WaitForDisconnect()
+	}()
+
+	received := make(chan []*wire.NetAddress, 1)
+	s, err := New(&Config{
+		ChainParams: &chaincfg.MainNetParams,
+		Host:        "seed.example.com",
+		Dial: func(addr net.Addr) (net.Conn, error) {
+			return net.Dial("tcp", addr.String())
+		},
+		GetAddresses: func() []*wire.NetAddress { return nil },
+		OnAddresses: func(addrs []*wire.NetAddress, _ *wire.NetAddress) {
+			received <- addrs
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+
+	s.allowSelfConnection = true
+
+	tcpAddr := listener.Addr().(*net.TCPAddr)
+	n := &node{addr: wire.NewNetAddress(tcpAddr, 0)}
+	s.crawl(n)
+
+	if n.failures != 0 || n.lastSuccess.IsZero() {
+		t.Fatalf("crawl failed with %d failures", n.failures)
+	}
+	if n.services != wire.SFNodeNetwork|wire.SFNodeBloom {
+		t.Errorf("unexpected services - got %v", n.services)
+	}
+	if n.height != 1000 {
+		t.Errorf("unexpected height - got %d, want 1000", n.height)
+	}
+	if !strings.Contains(n.userAgent, "remote:1.0.0/") ||
+		n.protocolVersion != wire.ProtocolVersion {
+
+		t.Errorf("unexpected version - got %s %d", n.userAgent,
+			n.protocolVersion)
+	}
+
+	select {
+	case addrs := <-received:
+		if len(addrs) != 1 || !addrs[0].IP.Equal(advertised.IP) {
+			t.Fatalf("unexpected addresses %v", addrs)
+		}
+	default:
+		t.Fatal("advertised addresses were not received")
+	}
+	if _, ok := s.nodes["8.8.8.8:8333"]; !ok {
+		t.Fatal("advertised address was not added")
+	}
+
+	// Crawling an unreachable node counts as failure.
+	listener.Close()
+	s.crawl(n)
+	if n.failures != 1 {
+		t.Fatalf("unexpected failures - got %d, want 1", n.failures)
+	}
+}
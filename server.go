@@ -14,6 +14,7 @@ import (
 	"fmt"
 	"math"
 	"net"
+	"os"
 	"runtime"
 	"sort"
 	"strconv"
@@ -23,23 +24,33 @@ import (
 	"time"
 
 	"github.com/gcash/bchd/bchrpc"
+	"github.com/gcash/bchd/bcmr"
 
 	"github.com/gcash/bchutil/gcs/builder"
 
 	"github.com/gcash/bchd/addrmgr"
+	"github.com/gcash/bchd/banmgr"
 	"github.com/gcash/bchd/blockchain"
 	"github.com/gcash/bchd/blockchain/indexers"
 	"github.com/gcash/bchd/chaincfg"
 	"github.com/gcash/bchd/chaincfg/chainhash"
 	"github.com/gcash/bchd/connmgr"
 	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/firstseen"
+	"github.com/gcash/bchd/hotwallet"
 	"github.com/gcash/bchd/mempool"
 	"github.com/gcash/bchd/mining"
 	"github.com/gcash/bchd/mining/cpuminer"
+	"github.com/gcash/bchd/mining/stratum"
+	"github.com/gcash/bchd/nat"
 	"github.com/gcash/bchd/netsync"
 	"github.com/gcash/bchd/peer"
+	"github.com/gcash/bchd/rescan"
+	"github.com/gcash/bchd/seeder"
 	"github.com/gcash/bchd/txscript"
 	"github.com/gcash/bchd/version"
+	"github.com/gcash/bchd/watchonly"
+	"github.com/gcash/bchd/webhook"
 	"github.com/gcash/bchd/wire"
 	"github.com/gcash/bchutil"
 	"github.com/gcash/bchutil/bloom"
@@ -49,7 +60,7 @@ const (
 	// defaultServices describes the default services that are supported by
 	// the server.
 	defaultServices = wire.SFNodeNetwork | wire.SFNodeBloom |
-		wire.SFNodeCF | wire.SFNodeBitcoinCash
+		wire.SFNodeCF | wire.SFNodeBitcoinCash | wire.SFNodeExtVersion
 
 	// defaultRequiredServices describes the default services that are
 	// required to be supported by outbound peers.
@@ -67,6 +78,38 @@ const (
 	// than necessary. For this reason we cap the number of peers we
 	// allow to send us blocks directly at three.
 	maxDirectRelayPeers = 3
+
+	// maxQueuedWeakBlocks is the maximum number of weak blocks received
+	// from a peer which are queued for processing.  Weak blocks are
+	// processed one at a time per peer since completing them may require a
+	// round trip.
+	maxQueuedWeakBlocks = 8
+
+	// uploadTargetTimeframe is the timeframe of the upload target.
+	uploadTargetTimeframe = time.Hour * 24
+
+	// dbCompactInterval is the interval at which the server checks whether
+	// the database should be compacted in the background.
+	dbCompactInterval = time.Minute * 10
+
+	// dbCompactMinDeletedKeys is the number of keys which must have been
+	// deleted from the database before it is compacted in the background.
+	dbCompactMinDeletedKeys = 1000000
+
+	// historicalBlockAge is the age after which blocks are considered
+	// historical.  Historical blocks are no longer served to peers which
+	// are not whitelisted once the upload target is close to being reached.
+	historicalBlockAge = time.Hour * 24 * 7
+
+	// natMappingLifetime is the lifetime requested for the mapping of the
+	// listening port on NAT gateways.  Mappings are renewed halfway through
+	// the lifetime granted by the gateway.
+	natMappingLifetime = time.Minute * 20
+
+	// natRetryInterval is the time to wait before retrying to map the
+	// listening port after a failure, and the minimum time between
+	// renewals.
+	natRetryInterval = time.Minute
 )
 
 var (
@@ -178,7 +221,6 @@ type peerState struct {
 	outboundPeers    map[int32]*serverPeer
 	persistentPeers  map[int32]*serverPeer
 	directRelayPeers map[int32]*serverPeer
-	banned           map[string]time.Time
 	outboundGroups   map[string]int
 	connectionCount  map[string]int
 }
@@ -235,28 +277,35 @@ type server struct {
 
 	chainParams             *chaincfg.Params
 	addrManager             *addrmgr.AddrManager
+	banManager              *banmgr.BanManager
 	connManager             *connmgr.ConnManager
 	sigCache                *txscript.SigCache
 	hashCache               *txscript.HashCache
+	scriptCache             *txscript.ScriptCache
 	rpcServer               *rpcServer
 	gRPCServer              *bchrpc.GrpcServer
 	syncManager             *netsync.SyncManager
 	chain                   *blockchain.BlockChain
 	txMemPool               *mempool.TxPool
 	cpuMiner                *cpuminer.CPUMiner
+	stratumServer           *stratum.Server
+	seeder                  *seeder.Seeder
+	webhookNotifier         *webhook.Notifier
+	firstSeen               *firstseen.Tracker
+	firstSeenExport         *os.File
 	modifyRebroadcastInv    chan interface{}
 	newPeers                chan *serverPeer
 	donePeers               chan *serverPeer
-	banPeers                chan *serverPeer
 	maybeAddDirectRelayPeer chan *maybeAddDirectRelayPeerMsg
 	query                   chan interface{}
 	relayInv                chan relayMsg
 	relayCmpctBlock         chan *wire.MsgCmpctBlock
+	relayWeakBlock          chan relayWeakBlockMsg
 	broadcast               chan broadcastMsg
 	peerHeightsUpdate       chan updatePeerHeightsMsg
 	wg                      sync.WaitGroup
 	quit                    chan struct{}
-	nat                     NAT
+	nats                    []nat.NAT
 	db                      database.DB
 	timeSource              blockchain.MedianTimeSource
 	services                wire.ServiceFlag
@@ -265,10 +314,15 @@ type server struct {
 	// if the associated index is not enabled.  These fields are set during
 	// initial creation of the server and never changed afterwards, so they
 	// do not need to be protected for concurrent access.
-	txIndex   *indexers.TxIndex
-	addrIndex *indexers.AddrIndex
-	cfIndex   *indexers.CfIndex
-	slpIndex  *indexers.SlpIndex
+	txIndex    *indexers.TxIndex
+	addrIndex  *indexers.AddrIndex
+	cfIndex    *indexers.CfIndex
+	slpIndex   *indexers.SlpIndex
+	tokenIndex *indexers.TokenIndex
+	statsIndex *indexers.StatsIndex
+
+	coinAgeIndex  *indexers.CoinAgeIndex
+	watchSetIndex *indexers.WatchSetIndex
 
 	// The fee estimator keeps track of how long transactions are left in
 	// the mempool before they are mined into blocks.
@@ -286,6 +340,28 @@ type server struct {
 	// agentWhitelist is a list of whitelisted user agent substrings, no
 	// whitelisting will be applied if the list is empty or nil.
 	agentWhitelist []string
+
+	// onionTarget is the address of the listener Tor forwards connections
+	// to the onion service to.  It is empty when no onion service is
+	// requested.
+	onionTarget string
+
+	// uploadTarget tracks the bytes sent against the upload target.  The
+	// upload and download limiters limit the combined rate of all peers
+	// which are not whitelisted.  They are nil when not limited.
+	uploadTarget    *connmgr.UploadTarget
+	uploadLimiter   *connmgr.RateLimiter
+	downloadLimiter *connmgr.RateLimiter
+
+	// bloomFilterMem is the total size of the bloom filters loaded by the
+	// peers, which is limited by the maxbloomfiltermem option.
+	bloomFilterMtx sync.Mutex
+	bloomFilterMem int
+
+	// loadedCfg is the configuration the reloadable options were last
+	// loaded from.  It is replaced when the configuration is reloaded.
+	reloadMtx sync.Mutex
+	loadedCfg *config
 }
 
 // spMsg represents a message over the wire from a specific peer.
@@ -308,6 +384,18 @@ type serverPeer struct {
 	// The following variables must only be used atomically
 	feeFilter int64
 
+	// These fields track how useful the peer is.  The tx inv counters hold
+	// the number of transactions announced by the peer and how many of
+	// them were not in the mempool yet.  The compact block counters hold
+	// the number of compact blocks received from the peer and how many of
+	// them were reconstructed from the mempool alone or after fetching the
+	// missing transactions.
+	txInvsReceived           uint64
+	txInvsNew                uint64
+	cmpctBlocksReceived      uint64
+	cmpctBlocksReconstructed uint64
+	cmpctBlocksRoundTrip     uint64
+
 	*peer.Peer
 
 	connReq               *connmgr.ConnReq
@@ -321,15 +409,24 @@ type serverPeer struct {
 	cbMtx                 sync.RWMutex
 	sentAddrs             bool
 	isWhitelisted         bool
+	feeler                bool
+	blockRelayOnly        bool
+	noMerkleBlocks        bool
 	filter                *bloom.Filter
+	filterMem             int
+	filterAdds            uint32
 	addrMtx               sync.RWMutex
 	knownAddresses        map[string]struct{}
-	banScore              connmgr.DynamicBanScore
 	quit                  chan struct{}
 	// The following chans are used to sync blockmanager and server.
 	txProcessed    chan struct{}
 	blockProcessed chan struct{}
 
+	// weakBlocks queues the weak blocks received from the peer for the
+	// weak block handler, which is started along with the first one.
+	weakBlocks       chan *wire.MsgWeakBlock
+	weakBlockHandler sync.Once
+
 	recvSubscribers map[spMsgSubscription]struct{}
 	mtxSubscribers  sync.RWMutex
 }
@@ -345,6 +442,7 @@ func newServerPeer(s *server, isPersistent bool) *serverPeer {
 		quit:            make(chan struct{}),
 		txProcessed:     make(chan struct{}, 1),
 		blockProcessed:  make(chan struct{}, 1),
+		weakBlocks:      make(chan *wire.MsgWeakBlock, maxQueuedWeakBlocks),
 		recvSubscribers: make(map[spMsgSubscription]struct{}),
 	}
 }
@@ -401,6 +499,29 @@ func (sp *serverPeer) compactBlocksSupported() bool {
 	return isSupported
 }
 
+// sendsCompactBlocks returns whether or not compact blocks are negotiated with
+// the peer by sending it a sendcmpct message.  Compact blocks are not used in
+// blocks only mode since there is no mempool to reconstruct them from, nor in
+// headers only mode since blocks are not relayed at all.
+func (sp *serverPeer) sendsCompactBlocks() bool {
+	return sp.compactBlocksSupported() && !cfg.BlocksOnly &&
+		!sp.server.chain.HeadersOnly()
+}
+
+// blocksOnly returns whether or not transactions from the peer are rejected.
+// That is the case in blocks only mode and for block-relay-only connections.
+func (sp *serverPeer) blocksOnly() bool {
+	return cfg.BlocksOnly || sp.blockRelayOnly
+}
+
+// wantsWeakBlocks returns whether or not weak blocks are relayed to and from
+// the peer, which is the case when both peers negotiated the same weak block
+// target factor in their extversion messages.
+func (sp *serverPeer) wantsWeakBlocks() bool {
+	factor, ok := sp.ExtVersionUint64(wire.ExtVersionKeyWeakBlocks)
+	return ok && factor == blockchain.WeakBlockTargetFactor
+}
+
 // relayTxDisabled returns whether or not relaying of transactions for the given
 // peer is disabled.
 // It is safe for concurrent access.
@@ -431,43 +552,37 @@ func (sp *serverPeer) pushAddrMsg(addresses []*wire.NetAddress) {
 	sp.addKnownAddresses(known)
 }
 
-// addBanScore increases the persistent and decaying ban score fields by the
-// values passed as parameters. If the resulting score exceeds half of the ban
-// threshold, a warning is logged including the reason provided. Further, if
-// the score is above the ban threshold, the peer will be banned and
-// disconnected.
-func (sp *serverPeer) addBanScore(persistent, transient uint32, reason string) {
+// addBanScore increases the ban score of the peer's host by the score of the
+// passed offense.  If the score is above the ban threshold, the peer will be
+// banned and disconnected.
+func (sp *serverPeer) addBanScore(offense banmgr.Offense) {
 	// No warning is logged and no score is calculated if banning is disabled.
 	if cfg.DisableBanning {
 		return
 	}
 	if sp.isWhitelisted {
-		peerLog.Debugf("Misbehaving whitelisted peer %s: %s", sp, reason)
+		peerLog.Debugf("Misbehaving whitelisted peer %s: %s", sp,
+			offense.Name)
 		return
 	}
 
-	warnThreshold := cfg.BanThreshold >> 1
-	if transient == 0 && persistent == 0 {
-		// The score is not being increased, but a warning message is still
-		// logged if the score is above the warn threshold.
-		score := sp.banScore.Int()
-		if score > warnThreshold {
-			peerLog.Warnf("Misbehaving peer %s: %s -- ban score is %d, "+
-				"it was not increased this time", sp, reason, score)
-		}
-		return
+	_, ban := sp.server.banManager.Misbehaving(sp.host(), offense)
+	if ban {
+		peerLog.Warnf("Misbehaving peer %s -- banning and disconnecting",
+			sp)
+		sp.server.BanPeer(sp)
+		sp.Disconnect()
 	}
-	score := sp.banScore.Increase(persistent, transient)
-	if score > warnThreshold {
-		peerLog.Warnf("Misbehaving peer %s: %s -- ban score increased to %d",
-			sp, reason, score)
-		if score > cfg.BanThreshold {
-			peerLog.Warnf("Misbehaving peer %s -- banning and disconnecting",
-				sp)
-			sp.server.BanPeer(sp)
-			sp.Disconnect()
-		}
+}
+
+// host returns the host part of the peer's address, which its ban score is
+// kept for.
+func (sp *serverPeer) host() string {
+	host, _, err := net.SplitHostPort(sp.Addr())
+	if err != nil {
+		return sp.Addr()
 	}
+	return host
 }
 
 // subscribeRecvMsg handles adding OnRead subscriptions to the server peer.
@@ -549,8 +664,9 @@ func (sp *serverPeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) *wire.MsgRej
 	sp.server.timeSource.AddTimeSample(sp.Addr(), msg.Timestamp)
 
 	// Choose whether or not to relay transactions before a filter command
-	// is received.
-	sp.setDisableRelayTx(msg.DisableRelayTx)
+	// is received.  Transactions are never relayed to block-relay-only
+	// peers.
+	sp.setDisableRelayTx(msg.DisableRelayTx || sp.blockRelayOnly)
 
 	// Mark the sp as compatible with compact blocks.
 	if msg.ProtocolVersion >= int32(wire.BIP0152Version) {
@@ -567,7 +683,7 @@ func (sp *serverPeer) OnVerAck(peer *peer.Peer, msg *wire.MsgVerAck) {
 
 	// This peer supports the compact blocks version so we should
 	// send them a sendcmpt message.
-	if sp.compactBlocksSupported() {
+	if sp.sendsCompactBlocks() {
 		resp := make(chan bool)
 		sp.server.maybeAddDirectRelayPeer <- &maybeAddDirectRelayPeerMsg{response: resp, peer: sp}
 		announce := <-resp
@@ -583,10 +699,17 @@ func (sp *serverPeer) OnXVersion(_ *peer.Peer, msg *wire.MsgXVersion) {
 }
 
 // OnMemPool is invoked when a peer receives a mempool bitcoin message.
-// It creates and sends an inventory message with the contents of the memory
-// pool up to the maximum inventory allowed per message.  When the peer has a
-// bloom filter loaded, the contents are filtered accordingly.
+// It creates and sends as many inventory messages as needed to announce the
+// entire contents of the memory pool.  When the peer has a bloom filter loaded,
+// the contents are filtered accordingly.
 func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
+	// Block-relay-only peers are not told about transactions.
+	if sp.blockRelayOnly {
+		peerLog.Debugf("Ignoring mempool request from block-relay-only "+
+			"peer %v", sp)
+		return
+	}
+
 	// Only allow mempool requests if the server has bloom filtering
 	// enabled.
 	if sp.server.services&wire.SFNodeBloom != wire.SFNodeBloom {
@@ -600,31 +723,32 @@ func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 	// The ban score accumulates and passes the ban threshold if a burst of
 	// mempool messages comes from a peer. The score decays each minute to
 	// half of its value.
-	sp.addBanScore(0, 33, "mempool")
+	sp.addBanScore(banmgr.OffenseMempool)
 
-	// Generate inventory message with the available transactions in the
-	// transaction memory pool.  Limit it to the max allowed inventory
-	// per message.  The NewMsgInvSizeHint function automatically limits
-	// the passed hint to the maximum allowed, so it's safe to pass it
-	// without double checking it here.
+	// Generate inventory messages with the available transactions in the
+	// transaction memory pool, sending each one as soon as it reaches the
+	// max allowed inventory per message.  The NewMsgInvSizeHint function
+	// automatically limits the passed hint to the maximum allowed, so it's
+	// safe to pass it without double checking it here.
 	txMemPool := sp.server.txMemPool
 	txDescs := txMemPool.TxDescs()
 	invMsg := wire.NewMsgInvSizeHint(uint(len(txDescs)))
 
-	for _, txDesc := range txDescs {
+	for i, txDesc := range txDescs {
 		// Either add all transactions when there is no bloom filter,
 		// or only the transactions that match the filter when there is
 		// one.
 		if !sp.filter.IsLoaded() || sp.filter.MatchTxAndUpdate(txDesc.Tx) {
 			iv := wire.NewInvVect(wire.InvTypeTx, txDesc.Tx.Hash())
 			invMsg.AddInvVect(iv)
-			if len(invMsg.InvList)+1 > wire.MaxInvPerMsg {
-				break
+			if len(invMsg.InvList) >= wire.MaxInvPerMsg {
+				sp.QueueMessage(invMsg, nil)
+				invMsg = wire.NewMsgInvSizeHint(uint(len(txDescs) - i - 1))
 			}
 		}
 	}
 
-	// Send the inventory message if there is anything to send.
+	// Send the final inventory message if there is anything to send.
 	if len(invMsg.InvList) > 0 {
 		sp.QueueMessage(invMsg, nil)
 	}
@@ -634,6 +758,13 @@ func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 // It creates a Cfilter of node's mempool and sends it to the requesting peer in a
 // cfilter message.
 func (sp *serverPeer) OnGetCFMemPool(_ *peer.Peer, msg *wire.MsgGetCFMempool) {
+	// Block-relay-only peers are not told about transactions.
+	if sp.blockRelayOnly {
+		peerLog.Debugf("Ignoring getcfmempool request from "+
+			"block-relay-only peer %v", sp)
+		return
+	}
+
 	// Only allow getcfmempool requests if the server has nodeCF enabled
 	if sp.server.services&wire.SFNodeCF != wire.SFNodeCF {
 		peerLog.Debugf("peer %v sent getcfmempool request with NodeCF "+
@@ -646,7 +777,7 @@ func (sp *serverPeer) OnGetCFMemPool(_ *peer.Peer, msg *wire.MsgGetCFMempool) {
 	// The ban score accumulates and passes the ban threshold if a burst of
 	// getcfmempool messages comes from a peer. The score decays each minute to
 	// half of its value.
-	sp.addBanScore(0, 33, "getcfmempool")
+	sp.addBanScore(banmgr.OffenseCFMempool)
 
 	switch msg.FilterType {
 	case wire.GCSFilterRegular:
@@ -682,9 +813,17 @@ func (sp *serverPeer) OnGetCFMemPool(_ *peer.Peer, msg *wire.MsgGetCFMempool) {
 // handler this does not serialize all transactions through a single thread
 // transactions don't rely on the previous one in a linear fashion like blocks.
 func (sp *serverPeer) OnTx(_ *peer.Peer, msg *wire.MsgTx) {
-	if cfg.BlocksOnly {
-		peerLog.Tracef("Ignoring tx %v from %v - blocksonly enabled",
+	if sp.blocksOnly() {
+		peerLog.Tracef("Ignoring tx %v from %v - blocks only",
 			msg.TxHash(), sp)
+
+		// Peers which understand the relay flag of the version
+		// message were told not to send transactions.
+		if sp.ProtocolVersion() >= wire.BIP0037Version {
+			peerLog.Infof("Peer %v is sending transactions -- "+
+				"disconnecting", sp)
+			sp.Disconnect()
+		}
 		return
 	}
 
@@ -694,6 +833,8 @@ func (sp *serverPeer) OnTx(_ *peer.Peer, msg *wire.MsgTx) {
 	tx := bchutil.NewTx(msg)
 	iv := wire.NewInvVect(wire.InvTypeTx, tx.Hash())
 	sp.AddKnownInventory(iv)
+	sp.server.recordFirstSeen(firstseen.KindTx, tx.Hash(),
+		firstseen.SourceTx, sp, true)
 
 	// Queue the transaction up to be handled by the sync manager and
 	// intentionally block further receives until the transaction is fully
@@ -714,6 +855,8 @@ func (sp *serverPeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 	// Add the block to the known inventory for the peer.
 	iv := wire.NewInvVect(wire.InvTypeBlock, block.Hash())
 	sp.AddKnownInventory(iv)
+	sp.server.recordFirstSeen(firstseen.KindBlock, block.Hash(),
+		firstseen.SourceBlock, sp, true)
 
 	// Queue the block up to be handled by the block
 	// manager and intentionally block further receives
@@ -732,6 +875,11 @@ func (sp *serverPeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 
 // OnCmpctBlock is invoked when a peer receives a cmpctblock bitcoin message.
 func (sp *serverPeer) OnCmpctBlock(_ *peer.Peer, msg *wire.MsgCmpctBlock) {
+	if sp.server.firstSeen != nil {
+		blockHash := msg.BlockHash()
+		sp.server.recordFirstSeen(firstseen.KindBlock, &blockHash,
+			firstseen.SourceCmpctBlock, sp, false)
+	}
 	go sp.processCompactBlock(msg)
 }
 
@@ -742,6 +890,7 @@ func (sp *serverPeer) OnCmpctBlock(_ *peer.Peer, msg *wire.MsgCmpctBlock) {
 // a separate goroutine is wise.
 func (sp *serverPeer) processCompactBlock(msg *wire.MsgCmpctBlock) {
 	targetHash := msg.BlockHash()
+	atomic.AddUint64(&sp.cmpctBlocksReceived, 1)
 
 	// We check the header here before proceeding. For one we end up wasting
 	// round trips if it turns out to be invalid. And two we might want to
@@ -764,63 +913,16 @@ func (sp *serverPeer) processCompactBlock(msg *wire.MsgCmpctBlock) {
 	msgGetBlockTxns := wire.NewMsgGetBlockTxnsFromBlock(msgBlock)
 
 	if len(msgGetBlockTxns.Indexes) > 0 {
-		quitChan := make(chan struct{})
-		msgChan := make(chan spMsg)
-		subscription := spMsgSubscription{
-			command:  wire.CmdBlockTxns,
-			quitChan: quitChan,
-			msgChan:  msgChan,
-		}
-		sp.subscribeRecvMsg(subscription)
-		sp.QueueMessage(msgGetBlockTxns, nil)
-		timeout := time.After(time.Second * 30)
-		select {
-		case <-timeout:
-			sp.unsubscribeRecvMsgs(subscription)
-			close(quitChan)
-			peerLog.Debugf("Peer %v timed out waiting for blocktxns for cmpctblock %v",
-				sp, msg.Header.BlockHash())
+		err := sp.requestBlockTxns(msgBlock, msgGetBlockTxns)
+		if err != nil {
+			peerLog.Debugf("Unable to complete cmpctblock %v from %v: %v",
+				targetHash, sp, err)
 			sp.server.syncManager.QueueBlockError(&targetHash, sp.Peer)
 			return
-		case resp := <-msgChan:
-			sp.unsubscribeRecvMsgs(subscription)
-			blockTxns, ok := resp.msg.(*wire.MsgBlockTxns)
-			if !ok {
-				peerLog.Debugf("Unable to decode blocktxns for cmpctblock %v from peer %v",
-					msg.Header.BlockHash(), sp)
-				sp.server.syncManager.QueueBlockError(&targetHash, sp.Peer)
-				return
-			}
-			if !blockTxns.BlockHash.IsEqual(&targetHash) {
-				peerLog.Debugf("blocktxns response for cmpctblock %v from peer %v "+
-					"contained incorrect hash", msg.Header.BlockHash(), sp)
-				sp.server.syncManager.QueueBlockError(&targetHash, sp.Peer)
-				return
-			}
-			indexMap, err := blockTxns.AbsoluteIndexes(msgGetBlockTxns.Indexes)
-			if err != nil {
-				peerLog.Debugf("blocktxns response for cmpctblock %v from peer %v "+
-					"contained incorrect number of txs", msg.Header.BlockHash(), sp)
-				sp.server.syncManager.QueueBlockError(&targetHash, sp.Peer)
-				return
-			}
-			for i, tx := range indexMap {
-				if i > uint32(len(msgBlock.Transactions)-1) {
-					peerLog.Debugf("blocktxns response for cmpctblock %v from peer %v "+
-						"contained incorrect index", msg.Header.BlockHash(), sp)
-					sp.server.syncManager.QueueBlockError(&targetHash, sp.Peer)
-					return
-				}
-				msgBlock.Transactions[i] = tx
-			}
-			newBlockHash := msgBlock.BlockHash()
-			if !newBlockHash.IsEqual(&targetHash) {
-				peerLog.Debugf("decoded cmpctblock hash %v doesn't match original message"+
-					" from peer %v ", msg.Header.BlockHash(), sp)
-				sp.server.syncManager.QueueBlockError(&targetHash, sp.Peer)
-				return
-			}
 		}
+		atomic.AddUint64(&sp.cmpctBlocksRoundTrip, 1)
+	} else {
+		atomic.AddUint64(&sp.cmpctBlocksReconstructed, 1)
 	}
 
 	// Convert the raw MsgBlock to a bchutil.Block which provides some
@@ -830,6 +932,8 @@ func (sp *serverPeer) processCompactBlock(msg *wire.MsgCmpctBlock) {
 	// Add the block to the known inventory for the peer.
 	iv := wire.NewInvVect(wire.InvTypeBlock, block.Hash())
 	sp.AddKnownInventory(iv)
+	sp.server.recordFirstSeen(firstseen.KindBlock, block.Hash(),
+		firstseen.SourceCmpctBlock, sp, true)
 
 	// Relay the block to peers which want direct relay.
 	sp.server.relayCmpctBlock <- msg
@@ -857,6 +961,163 @@ func (sp *serverPeer) processCompactBlock(msg *wire.MsgCmpctBlock) {
 	sp.processBlockMtx.Unlock()
 }
 
+// requestBlockTxns requests the transactions of the passed block which could
+// not be reconstructed from the memory pool from the peer and fills them in.
+// It blocks until the peer responds or the request times out.
+func (sp *serverPeer) requestBlockTxns(msgBlock *wire.MsgBlock,
+	msgGetBlockTxns *wire.MsgGetBlockTxns) error {
+
+	quitChan := make(chan struct{})
+	msgChan := make(chan spMsg)
+	subscription := spMsgSubscription{
+		command:  wire.CmdBlockTxns,
+		quitChan: quitChan,
+		msgChan:  msgChan,
+	}
+	sp.subscribeRecvMsg(subscription)
+	defer func() {
+		sp.unsubscribeRecvMsgs(subscription)
+		close(quitChan)
+	}()
+	sp.QueueMessage(msgGetBlockTxns, nil)
+
+	// Every blocktxns message of the peer is sent to all subscriptions
+	// and a compact block may be reconstructed while a weak block is, so
+	// the responses for other blocks are skipped.
+	timeout := time.After(time.Second * 30)
+	var blockTxns *wire.MsgBlockTxns
+	for blockTxns == nil {
+		select {
+		case <-timeout:
+			return errors.New("timed out waiting for blocktxns")
+		case <-sp.quit:
+			return errors.New("peer disconnected")
+		case resp := <-msgChan:
+			msg, ok := resp.msg.(*wire.MsgBlockTxns)
+			if !ok {
+				return errors.New("unable to decode blocktxns")
+			}
+			if msg.BlockHash.IsEqual(&msgGetBlockTxns.BlockHash) {
+				blockTxns = msg
+			}
+		}
+	}
+	indexMap, err := blockTxns.AbsoluteIndexes(msgGetBlockTxns.Indexes)
+	if err != nil {
+		return errors.New("blocktxns response contained incorrect " +
+			"number of txs")
+	}
+	for i, tx := range indexMap {
+		if i > uint32(len(msgBlock.Transactions)-1) {
+			return errors.New("blocktxns response contained " +
+				"incorrect index")
+		}
+		msgBlock.Transactions[i] = tx
+	}
+	return nil
+}
+
+// OnWeakBlock is invoked when a peer receives a weakblock bitcoin message.
+// The weak block is queued for the weak block handler of the peer since the
+// missing transactions may have to be requested from the peer, whose
+// response is only read once this returns.  Weak blocks are dropped while the
+// queue is full.
+func (sp *serverPeer) OnWeakBlock(_ *peer.Peer, msg *wire.MsgWeakBlock) {
+	sp.weakBlockHandler.Do(func() {
+		go sp.handleWeakBlocks()
+	})
+
+	select {
+	case sp.weakBlocks <- msg:
+	default:
+		peerLog.Debugf("Dropping weakblock %v from %v -- too many "+
+			"queued weak blocks", msg.BlockHash(), sp)
+	}
+}
+
+// handleWeakBlocks processes the weak blocks queued by OnWeakBlock one at a
+// time until the peer disconnects.  It must be run as a goroutine.
+func (sp *serverPeer) handleWeakBlocks() {
+	for {
+		select {
+		case msg := <-sp.weakBlocks:
+			sp.processWeakBlock(msg)
+		case <-sp.quit:
+			return
+		}
+	}
+}
+
+// processWeakBlock reconstructs the weak block of the passed message the same
+// way as a compact block, stages its transactions in the memory pool and
+// relays it to the other peers which want weak blocks.  It may block while
+// the missing transactions are requested from the peer.
+func (sp *serverPeer) processWeakBlock(msg *wire.MsgWeakBlock) {
+	hash := msg.BlockHash()
+	if !sp.wantsWeakBlocks() {
+		peerLog.Debugf("Ignoring unsolicited weakblock %v from %v",
+			hash, sp)
+		sp.addBanScore(banmgr.OffenseWeakBlock)
+		return
+	}
+	if sp.server.txMemPool.HaveWeakBlock(&hash) {
+		return
+	}
+	sp.AddKnownInventory(wire.NewInvVect(wire.InvTypeBlock, &hash))
+
+	// Weak blocks found shortly before a new block are still relayed while
+	// the new block propagates, so stale weak blocks are expected.
+	err := sp.server.chain.CheckWeakBlockHeader(&msg.Header)
+	if err != nil {
+		peerLog.Debugf("Ignoring weakblock %v from %v -- invalid "+
+			"header: %v", hash, sp, err)
+		rerr, ok := err.(blockchain.RuleError)
+		if ok && rerr.ErrorCode != blockchain.ErrStaleWeakBlock {
+			sp.addBanScore(banmgr.OffenseWeakBlock)
+		}
+		return
+	}
+	if !blockchain.IsWeakBlock(&msg.Header) {
+		peerLog.Debugf("Ignoring weakblock %v from %v -- meets the "+
+			"block target", hash, sp)
+		return
+	}
+	if len(msg.PrefilledTxs) == 0 {
+		peerLog.Debugf("Ignoring weakblock %v from %v -- missing "+
+			"coinbase", hash, sp)
+		sp.addBanScore(banmgr.OffenseWeakBlock)
+		return
+	}
+
+	msgBlock, err := sp.server.txMemPool.DecodeCompressedBlock(msg)
+	if err != nil {
+		peerLog.Debugf("Error decoding weakblock %v from %v: %v", hash,
+			sp, err)
+		return
+	}
+	msgGetBlockTxns := wire.NewMsgGetBlockTxnsFromBlock(msgBlock)
+	if len(msgGetBlockTxns.Indexes) > 0 {
+		err := sp.requestBlockTxns(msgBlock, msgGetBlockTxns)
+		if err != nil {
+			peerLog.Debugf("Unable to complete weakblock %v from %v: %v",
+				hash, sp, err)
+			return
+		}
+	}
+
+	// The short IDs of transactions may collide, so make sure the
+	// reconstructed transactions are the ones the weak block commits to.
+	merkles := blockchain.BuildMerkleTreeStore(
+		bchutil.NewBlock(msgBlock).Transactions())
+	if !merkles[len(merkles)-1].IsEqual(&msgBlock.Header.MerkleRoot) {
+		peerLog.Debugf("Ignoring weakblock %v from %v -- merkle root "+
+			"mismatch", hash, sp)
+		return
+	}
+
+	sp.server.relayWeakBlockFrom(msgBlock, sp)
+}
+
 // OnGetBlockTxns is invoked when a peer receives a getblocktxns bitcoin message.
 // We block need to acquire lock to make sure the block is process first before
 // continuing.
@@ -868,14 +1129,27 @@ func (sp *serverPeer) OnGetBlockTxns(_ *peer.Peer, msg *wire.MsgGetBlockTxns) {
 	sp.processBlockMtx.Lock()
 	sp.processBlockMtx.Unlock()
 
+	// The transactions of weak blocks are served from the memory pool.
+	// They are requested for weak blocks which were relayed to the peer,
+	// so these requests don't add to the ban score.
+	hash := msg.BlockHash
+	if weakBlock, err := sp.server.txMemPool.FetchWeakBlock(&hash); err == nil {
+		requestdTxs, err := msg.RequestedTransactions(weakBlock)
+		if err != nil {
+			peerLog.Tracef("Unable to extract requested transactions: %v", err)
+			return
+		}
+		sp.QueueMessage(wire.NewMsgBlockTxns(hash, requestdTxs), nil)
+		return
+	}
+
 	// A decaying ban score increase is applied to prevent flooding.
 	// The ban score accumulates and passes the ban threshold if a burst of
 	// mempool messages comes from a peer. The score decays each minute to
 	// half of its value.
-	sp.addBanScore(0, 33, "getblocktxns")
+	sp.addBanScore(banmgr.OffenseGetBlockTxns)
 
 	// Fetch the raw block bytes from the database.
-	hash := msg.BlockHash
 	var blockBytes []byte
 	err := sp.server.db.View(func(dbTx database.Tx) error {
 		var err error
@@ -910,7 +1184,23 @@ func (sp *serverPeer) OnGetBlockTxns(_ *peer.Peer, msg *wire.MsgGetBlockTxns) {
 // accordingly.  We pass the message down to blockmanager which will call
 // QueueMessage with any appropriate responses.
 func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
-	if !cfg.BlocksOnly {
+	for _, invVect := range msg.InvList {
+		if invVect.Type == wire.InvTypeBlock {
+			sp.server.recordFirstSeen(firstseen.KindBlock,
+				&invVect.Hash, firstseen.SourceInv, sp, false)
+		}
+		if invVect.Type != wire.InvTypeTx {
+			continue
+		}
+		sp.server.recordFirstSeen(firstseen.KindTx, &invVect.Hash,
+			firstseen.SourceInv, sp, false)
+		atomic.AddUint64(&sp.txInvsReceived, 1)
+		if !sp.server.txMemPool.HaveTransaction(&invVect.Hash) {
+			atomic.AddUint64(&sp.txInvsNew, 1)
+		}
+	}
+
+	if !sp.blocksOnly() {
 		if len(msg.InvList) > 0 {
 			sp.server.syncManager.QueueInv(msg, sp.Peer)
 		}
@@ -921,7 +1211,7 @@ func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
 	for _, invVect := range msg.InvList {
 		if invVect.Type == wire.InvTypeTx {
 			peerLog.Tracef("Ignoring tx %v in inv from %v -- "+
-				"blocksonly enabled", invVect.Hash, sp)
+				"blocks only", invVect.Hash, sp)
 			if sp.ProtocolVersion() >= wire.BIP0037Version {
 				peerLog.Infof("Peer %v is announcing "+
 					"transactions -- disconnecting", sp)
@@ -945,6 +1235,13 @@ func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
 // OnHeaders is invoked when a peer receives a headers bitcoin
 // message.  The message is passed down to the sync manager.
 func (sp *serverPeer) OnHeaders(_ *peer.Peer, msg *wire.MsgHeaders) {
+	if sp.server.firstSeen != nil {
+		for _, header := range msg.Headers {
+			blockHash := header.BlockHash()
+			sp.server.recordFirstSeen(firstseen.KindBlock,
+				&blockHash, firstseen.SourceHeaders, sp, false)
+		}
+	}
 	sp.server.syncManager.QueueHeaders(msg, sp.Peer)
 }
 
@@ -962,7 +1259,23 @@ func (sp *serverPeer) OnGetData(_ *peer.Peer, msg *wire.MsgGetData) {
 	// bursts of small requests are not penalized as that would potentially ban
 	// peers performing IBD.
 	// This incremental score decays each minute to half of its value.
-	sp.addBanScore(0, uint32(length)*99/wire.MaxInvPerMsg, "getdata")
+	sp.addBanScore(banmgr.OffenseGetData(length))
+
+	// Disconnect peers which request historical blocks once the upload
+	// target is close to being reached so the remaining bytes are left for
+	// relaying new blocks.  Whitelisted peers are always served.
+	if !sp.isWhitelisted && !sp.server.uploadTarget.ServeHistorical() {
+		for _, iv := range msg.InvList {
+			if !sp.server.isHistoricalBlock(iv) {
+				continue
+			}
+			peerLog.Infof("Upload target reached -- disconnecting "+
+				"peer %s requesting historical block %v", sp,
+				iv.Hash)
+			sp.Disconnect()
+			return
+		}
+	}
 
 	// We wait on this wait channel periodically to prevent queuing
 	// far more data than we can send in a reasonable time, wasting memory.
@@ -1024,6 +1337,21 @@ func (sp *serverPeer) OnGetData(_ *peer.Peer, msg *wire.MsgGetData) {
 	}
 }
 
+// isHistoricalBlock returns whether the passed inventory vector requests a
+// block which is older than historicalBlockAge.
+func (s *server) isHistoricalBlock(iv *wire.InvVect) bool {
+	switch iv.Type {
+	case wire.InvTypeBlock, wire.InvTypeCmpctBlock, wire.InvTypeFilteredBlock:
+	default:
+		return false
+	}
+	header, err := s.chain.HeaderByHash(&iv.Hash)
+	if err != nil {
+		return false
+	}
+	return time.Since(header.Timestamp) > historicalBlockAge
+}
+
 // OnGetBlocks is invoked when a peer receives a getblocks bitcoin
 // message.
 func (sp *serverPeer) OnGetBlocks(_ *peer.Peer, msg *wire.MsgGetBlocks) {
@@ -1447,7 +1775,7 @@ func (sp *serverPeer) enforceNodeBloomFlag(cmd string) bool {
 
 			// Disconnect the peer regardless of whether it was
 			// banned.
-			sp.addBanScore(100, 0, cmd)
+			sp.addBanScore(banmgr.OffenseBloomFilter(cmd))
 			sp.Disconnect()
 			return false
 		}
@@ -1460,6 +1788,15 @@ func (sp *serverPeer) enforceNodeBloomFlag(cmd string) bool {
 		return false
 	}
 
+	// Peers from networks which are not served merkle blocks are only
+	// disconnected since the server signals support for bloom filters.
+	if sp.noMerkleBlocks {
+		peerLog.Debugf("%s sent a %s request from a network which is "+
+			"not served merkle blocks -- disconnecting", sp, cmd)
+		sp.Disconnect()
+		return false
+	}
+
 	return true
 }
 
@@ -1490,6 +1827,10 @@ func (sp *serverPeer) OnFilterAdd(_ *peer.Peer, msg *wire.MsgFilterAdd) {
 		return
 	}
 
+	// A decaying ban score increase is applied to prevent flooding since
+	// every addition makes matching transactions more expensive.
+	sp.addBanScore(banmgr.OffenseFilterAdd)
+
 	if !sp.filter.IsLoaded() {
 		peerLog.Debugf("%s sent a filteradd request with no filter "+
 			"loaded -- disconnecting", sp)
@@ -1497,6 +1838,17 @@ func (sp *serverPeer) OnFilterAdd(_ *peer.Peer, msg *wire.MsgFilterAdd) {
 		return
 	}
 
+	// Limit the number of additions to a single filter since a filter
+	// which keeps growing eventually matches every transaction.
+	sp.filterAdds++
+	if cfg.MaxBloomFilterAdds != 0 && sp.filterAdds > cfg.MaxBloomFilterAdds {
+		peerLog.Debugf("%s sent more than %d filteradd requests for "+
+			"the loaded filter -- disconnecting", sp,
+			cfg.MaxBloomFilterAdds)
+		sp.Disconnect()
+		return
+	}
+
 	sp.filter.Add(msg.Data)
 }
 
@@ -1519,6 +1871,7 @@ func (sp *serverPeer) OnFilterClear(_ *peer.Peer, msg *wire.MsgFilterClear) {
 	}
 
 	sp.filter.Unload()
+	sp.releaseFilterMem()
 }
 
 // OnFilterLoad is invoked when a peer receives a filterload bitcoin
@@ -1533,9 +1886,63 @@ func (sp *serverPeer) OnFilterLoad(_ *peer.Peer, msg *wire.MsgFilterLoad) {
 		return
 	}
 
-	sp.setDisableRelayTx(false)
+	// A decaying ban score increase is applied to prevent flooding.  The
+	// score decays each minute to half of its value.
+	sp.addBanScore(banmgr.OffenseFilterLoad)
+
+	if uint32(len(msg.Filter)) > cfg.MaxBloomFilterSize {
+		peerLog.Debugf("%s sent a filterload request with a %d byte "+
+			"filter which exceeds the limit of %d bytes -- "+
+			"disconnecting", sp, len(msg.Filter),
+			cfg.MaxBloomFilterSize)
+		sp.Disconnect()
+		return
+	}
+	if !sp.reserveFilterMem(len(msg.Filter)) {
+		peerLog.Infof("Bloom filter memory limit reached -- "+
+			"disconnecting peer %s", sp)
+		sp.Disconnect()
+		return
+	}
+
+	sp.setDisableRelayTx(sp.blockRelayOnly)
 
 	sp.filter.Reload(msg)
+	sp.filterAdds = 0
+}
+
+// reserveFilterMem accounts the memory of a bloom filter of the passed size
+// loaded by the peer in place of its current one.  It returns false when the
+// filters of all peers would exceed the maxbloomfiltermem limit or the peer is
+// already disconnected.
+func (sp *serverPeer) reserveFilterMem(size int) bool {
+	s := sp.server
+	s.bloomFilterMtx.Lock()
+	defer s.bloomFilterMtx.Unlock()
+
+	// The memory of disconnected peers is released by the peer done
+	// handler, so it must not be accounted again afterwards.
+	if !sp.Connected() {
+		return false
+	}
+
+	mem := s.bloomFilterMem - sp.filterMem + size
+	if cfg.MaxBloomFilterMem != 0 && uint64(mem) > cfg.MaxBloomFilterMem*1024 {
+		return false
+	}
+	s.bloomFilterMem = mem
+	sp.filterMem = size
+	return true
+}
+
+// releaseFilterMem releases the memory accounted for the bloom filter of the
+// peer.
+func (sp *serverPeer) releaseFilterMem() {
+	s := sp.server
+	s.bloomFilterMtx.Lock()
+	s.bloomFilterMem -= sp.filterMem
+	sp.filterMem = 0
+	s.bloomFilterMtx.Unlock()
 }
 
 // OnGetAddr is invoked when a peer receives a getaddr bitcoin message
@@ -1590,6 +1997,24 @@ func (sp *serverPeer) OnGetAddr(_ *peer.Peer, msg *wire.MsgGetAddr) {
 // OnAddr is invoked when a peer receives an addr bitcoin message and is
 // used to notify the server about advertised addresses.
 func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
+	// Ignore old style addresses which don't include a timestamp.
+	if sp.ProtocolVersion() < wire.NetAddressTimeVersion {
+		return
+	}
+
+	sp.handleAddrList(msg, msg.AddrList)
+}
+
+// OnAddrV2 is invoked when a peer receives an addrv2 bitcoin message and is
+// used to notify the server about advertised addresses, including those of
+// networks which can't be advertised with addr messages.
+func (sp *serverPeer) OnAddrV2(_ *peer.Peer, msg *wire.MsgAddrV2) {
+	sp.handleAddrList(msg, msg.AddrList)
+}
+
+// handleAddrList adds the addresses advertised by the peer in the passed addr
+// or addrv2 message to the server address manager.
+func (sp *serverPeer) handleAddrList(msg wire.Message, addrList []*wire.NetAddress) {
 	// Ignore addresses when running on the simulation and regression test
 	// networks.  This helps prevent the networks from becoming another public
 	// test network since they will not be able to learn about other peers that
@@ -1598,20 +2023,23 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 		return
 	}
 
-	// Ignore old style addresses which don't include a timestamp.
-	if sp.ProtocolVersion() < wire.NetAddressTimeVersion {
+	// Addresses are not gossiped with block-relay-only peers so they can
+	// not be identified by the addresses they relay.
+	if sp.blockRelayOnly {
+		peerLog.Debugf("Ignoring %s message from block-relay-only peer "+
+			"%v", msg.Command(), sp)
 		return
 	}
 
 	// A message that has no addresses is invalid.
-	if len(msg.AddrList) == 0 {
+	if len(addrList) == 0 {
 		peerLog.Errorf("Command [%s] from %s does not contain any addresses",
 			msg.Command(), sp.Peer)
 		sp.Disconnect()
 		return
 	}
 
-	for _, na := range msg.AddrList {
+	for _, na := range addrList {
 		// Don't add more address if we're disconnecting.
 		if !sp.Connected() {
 			return
@@ -1634,7 +2062,7 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 	// addresses, and last seen updates.
 	// XXX bitcoind gives a 2 hour time penalty here, do we want to do the
 	// same?
-	sp.server.addrManager.AddAddresses(msg.AddrList, sp.NA())
+	sp.server.addrManager.AddAddresses(addrList, sp.NA())
 }
 
 // OnReject logs all reject messages received from the remote peer.
@@ -1746,6 +2174,53 @@ func (s *server) AnnounceNewTransactions(txns []*mempool.TxDesc) {
 	}
 }
 
+// NotifyDoubleSpend notifies websocket clients and webhooks of the passed
+// double spend of a transaction in the mempool.  It is called by the mempool.
+func (s *server) NotifyDoubleSpend(ds *mempool.DoubleSpend) {
+	if s.rpcServer != nil {
+		s.rpcServer.NotifyDoubleSpend(ds)
+	}
+	if s.webhookNotifier != nil {
+		s.webhookNotifier.NotifyDoubleSpend(ds)
+	}
+}
+
+// recordFirstSeen records the announcement or, when received is set, the
+// receipt of the block or transaction with the passed hash by the passed peer
+// with the first seen tracker if it is enabled.
+func (s *server) recordFirstSeen(kind firstseen.Kind, hash *chainhash.Hash,
+	source firstseen.Source, sp *serverPeer, received bool) {
+
+	if s.firstSeen == nil {
+		return
+	}
+	if received {
+		s.firstSeen.Received(kind, hash, source, sp.Addr())
+		return
+	}
+	s.firstSeen.Announced(kind, hash, source, sp.Addr())
+}
+
+// NotifyExpired logs the transactions which expired from the mempool and
+// notifies the webhooks of them.
+func (s *server) NotifyExpired(txns []*mempool.TxDesc) {
+	for _, txD := range txns {
+		srvrLog.Infof("Transaction %v expired from the mempool after "+
+			"%v", txD.Tx.Hash(), time.Since(txD.Added).Truncate(time.Second))
+	}
+	if s.webhookNotifier != nil {
+		s.webhookNotifier.NotifyExpired(txns)
+	}
+}
+
+// NotifyPoolChange notifies the gRPC server of a transaction added to or
+// removed from the mempool.
+func (s *server) NotifyPoolChange(change *mempool.PoolChange) {
+	if s.gRPCServer != nil {
+		s.gRPCServer.NotifyMempoolChange(change)
+	}
+}
+
 // Transaction has one confirmation on the main chain. Now we can mark it as no
 // longer needing rebroadcasting.
 func (s *server) TransactionConfirmed(tx *bchutil.Tx) {
@@ -2024,20 +2499,25 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		sp.Disconnect()
 		return false
 	}
-	if banEnd, ok := state.banned[host]; ok {
-		if time.Now().Before(banEnd) {
-			srvrLog.Debugf("Peer %s is banned for another %v - disconnecting",
-				host, time.Until(banEnd))
-			sp.Disconnect()
-			return false
-		}
+	if ip := net.ParseIP(host); ip != nil && s.banManager.IsBanned(ip) {
+		srvrLog.Debugf("Peer %s is banned - disconnecting", host)
+		sp.Disconnect()
+		return false
+	}
 
-		srvrLog.Infof("Peer %s is no longer banned", host)
-		delete(state.banned, host)
+	// Feeler connections only test whether the address is reachable.  The
+	// address is marked good now that the handshake is complete and the
+	// peer is disconnected.
+	if sp.feeler {
+		srvrLog.Debugf("Feeler connection to %s succeeded", sp)
+		s.addrManager.Good(sp.NA())
+		sp.Disconnect()
+		return false
 	}
 
-	// Limit max number of total peers per ip.
-	if state.CountIP(host) >= cfg.MaxPeersPerIP {
+	// Limit max number of total peers per ip.  Peers connected through
+	// the onion service all share the loopback address.
+	if !s.isOnionInbound(sp) && state.CountIP(host) >= cfg.MaxPeersPerIP {
 		srvrLog.Infof("Max peers per IP reached [%d] - disconnecting peer %s",
 			cfg.MaxPeersPerIP, sp)
 		sp.Disconnect()
@@ -2062,7 +2542,7 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		state.inboundPeers[sp.ID()] = sp
 		state.connectionCount[host]++
 	} else {
-		state.outboundGroups[addrmgr.GroupKey(sp.NA())]++
+		state.outboundGroups[s.addrManager.GroupKey(sp.NA())]++
 
 		if sp.persistent {
 			state.persistentPeers[sp.ID()] = sp
@@ -2085,12 +2565,15 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	// remote peer for outbound connections. This is skipped when running
 	// on the simulation and regression test networks since they are only
 	// intended to connect to specified peers and actively avoid advertising
-	// and connecting to discovered peers.
+	// and connecting to discovered peers.  Addresses are not exchanged with
+	// block-relay-only peers.
 	if !cfg.SimNet && !cfg.RegressionTest && !sp.Inbound() {
 		// Advertise the local address when the server accepts incoming
 		// connections and it believes itself to be close to the best
 		// known tip.
-		if !cfg.DisableListen && s.syncManager.IsCurrent() {
+		if !cfg.DisableListen && !sp.blockRelayOnly &&
+			s.syncManager.IsCurrent() {
+
 			// Get address that best matches.
 			lna := s.addrManager.GetBestLocalAddress(sp.NA())
 			if addrmgr.IsRoutable(lna) {
@@ -2104,7 +2587,9 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		// more and the peer has a protocol version new enough to
 		// include a timestamp with addresses.
 		hasTimestamp := sp.ProtocolVersion() >= wire.NetAddressTimeVersion
-		if s.addrManager.NeedMoreAddresses() && hasTimestamp {
+		if s.addrManager.NeedMoreAddresses() && hasTimestamp &&
+			!sp.blockRelayOnly {
+
 			sp.QueueMessage(wire.NewMsgGetAddr(), nil)
 		}
 
@@ -2134,6 +2619,11 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 	if !sp.Inbound() {
 		if sp.persistent {
 			s.connManager.Disconnect(sp.connReq.ID())
+		} else if sp.feeler {
+			s.connManager.Remove(sp.connReq.ID())
+		} else if sp.blockRelayOnly {
+			s.connManager.Remove(sp.connReq.ID())
+			go s.connManager.NewBlockRelayOnlyConnReq()
 		} else {
 			s.connManager.Remove(sp.connReq.ID())
 			go s.connManager.NewConnReq()
@@ -2142,7 +2632,7 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 
 	if _, ok := list[sp.ID()]; ok {
 		if !sp.Inbound() && sp.VersionKnown() {
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		}
 
 		delete(list, sp.ID())
@@ -2161,20 +2651,6 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 	delete(state.directRelayPeers, sp.ID())
 }
 
-// handleBanPeerMsg deals with banning peers.  It is invoked from the
-// peerHandler goroutine.
-func (s *server) handleBanPeerMsg(state *peerState, sp *serverPeer) {
-	host, _, err := net.SplitHostPort(sp.Addr())
-	if err != nil {
-		srvrLog.Debugf("can't split ban peer %s %v", sp.Addr(), err)
-		return
-	}
-	direction := directionString(sp.Inbound())
-	srvrLog.Infof("Banned peer %s (%s) for %v", host, direction,
-		cfg.BanDuration)
-	state.banned[host] = time.Now().Add(cfg.BanDuration)
-}
-
 // handleRelayInvMsg deals with relaying inventory to peers that are not already
 // known to have it.  It is invoked from the peerHandler goroutine.
 func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
@@ -2294,6 +2770,63 @@ func (s *server) handleRelayCmpctBlock(state *peerState, msg *wire.MsgCmpctBlock
 	})
 }
 
+// relayWeakBlockMsg packages a weak block to relay along with the peer it was
+// received from, which is nil for weak blocks found by local miners.
+type relayWeakBlockMsg struct {
+	block *wire.MsgBlock
+	from  *serverPeer
+}
+
+// handleRelayWeakBlock deals with relaying a weak block to the peers which want
+// weak blocks.  The weak block is sent as a compact block based on the
+// transactions known to each peer.
+func (s *server) handleRelayWeakBlock(state *peerState, msg relayWeakBlockMsg) {
+	blockHash := msg.block.BlockHash()
+	iv := wire.NewInvVect(wire.InvTypeBlock, &blockHash)
+	state.forAllPeers(func(sp *serverPeer) {
+		if sp == msg.from || !sp.Connected() || !sp.wantsWeakBlocks() ||
+			sp.HasKnownInventory(iv) {
+
+			return
+		}
+		weakBlock, err := wire.NewMsgWeakBlockFromBlock(msg.block,
+			sp.GetKnownTxInventory())
+		if err != nil {
+			srvrLog.Errorf("Unable to create weakblock %v: %v",
+				blockHash, err)
+			return
+		}
+		sp.AddKnownInventory(iv)
+		sp.QueueMessage(weakBlock, nil)
+	})
+}
+
+// relayWeakBlockFrom stages the transactions of the passed weak block in the
+// memory pool and relays it to the peers which want weak blocks, except the
+// passed peer it was received from.  Weak blocks which are already known are
+// not relayed again.
+func (s *server) relayWeakBlockFrom(block *wire.MsgBlock, from *serverPeer) {
+	if !s.txMemPool.AddWeakBlock(block) {
+		return
+	}
+	srvrLog.Debugf("Staged weak block %v with %d transactions",
+		block.BlockHash(), len(block.Transactions))
+	s.relayWeakBlock <- relayWeakBlockMsg{block: block, from: from}
+}
+
+// AnnounceWeakBlock stages the transactions of the passed weak block found by
+// a local miner in the memory pool and relays it to the peers which want weak
+// blocks.  Weak blocks which don't extend the tip of the main chain any longer
+// are dropped.
+func (s *server) AnnounceWeakBlock(block *wire.MsgBlock) {
+	if err := s.chain.CheckWeakBlockHeader(&block.Header); err != nil {
+		srvrLog.Debugf("Not announcing weak block %v: %v",
+			block.BlockHash(), err)
+		return
+	}
+	s.relayWeakBlockFrom(block, nil)
+}
+
 // handleBroadcastMsg deals with broadcasting messages to peers.  It is invoked
 // from the peerHandler goroutine.
 func (s *server) handleBroadcastMsg(state *peerState, bmsg *broadcastMsg) {
@@ -2402,7 +2935,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		found := disconnectPeer(state.persistentPeers, msg.cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		})
 
 		if found {
@@ -2438,7 +2971,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		found = disconnectPeer(state.outboundPeers, msg.cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		})
 		if found {
 			// If there are multiple outbound connections to the same
@@ -2446,7 +2979,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 			// peers are found.
 			for found {
 				found = disconnectPeer(state.outboundPeers, msg.cmp, func(sp *serverPeer) {
-					state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+					state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 				})
 			}
 			msg.reply <- nil
@@ -2493,6 +3026,7 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 			OnBlock:        sp.OnBlock,
 			OnCmpctBlock:   sp.OnCmpctBlock,
 			OnGetBlockTxns: sp.OnGetBlockTxns,
+			OnWeakBlock:    sp.OnWeakBlock,
 			OnInv:          sp.OnInv,
 			OnHeaders:      sp.OnHeaders,
 			OnGetData:      sp.OnGetData,
@@ -2508,6 +3042,7 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 			OnFilterLoad:   sp.OnFilterLoad,
 			OnGetAddr:      sp.OnGetAddr,
 			OnAddr:         sp.OnAddr,
+			OnAddrV2:       sp.OnAddrV2,
 			OnRead:         sp.OnRead,
 			OnWrite:        sp.OnWrite,
 			OnReject:       sp.OnReject,
@@ -2522,13 +3057,29 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 		UserAgentComments: cfg.UserAgentComments,
 		ChainParams:       sp.server.chainParams,
 		Services:          sp.server.services,
-		DisableRelayTx:    cfg.BlocksOnly,
+		DisableRelayTx:    cfg.BlocksOnly || sp.blockRelayOnly,
 		ProtocolVersion:   peer.MaxProtocolVersion,
 		TrickleInterval:   cfg.TrickleInterval,
+		TxReconciliation:  cfg.TxReconciliation,
 		MaxKnownInventory: uint((cfg.ExcessiveBlockSize / 1000000) * peer.DefaultMaxKnownInventory),
+		ExtVersionEntries: extVersionEntries(sp),
 	}
 }
 
+// extVersionEntries returns the extversion entries sent to the passed peer,
+// which negotiate the extended features enabled by the configuration.
+func extVersionEntries(sp *serverPeer) map[uint64][]byte {
+	entries := make(map[uint64][]byte)
+
+	// Weak blocks are only relayed to peers which relay transactions.
+	if cfg.WeakBlocks && !sp.blocksOnly() {
+		var buf bytes.Buffer
+		wire.WriteVarInt(&buf, 0, blockchain.WeakBlockTargetFactor)
+		entries[wire.ExtVersionKeyWeakBlocks] = buf.Bytes()
+	}
+	return entries
+}
+
 // inboundPeerConnected is invoked by the connection manager when a new inbound
 // connection is established.  It initializes a new inbound server peer
 // instance, associates it with the connection, and starts a goroutine to wait
@@ -2536,11 +3087,31 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 func (s *server) inboundPeerConnected(conn net.Conn) {
 	sp := newServerPeer(s, false)
 	sp.isWhitelisted = isWhitelisted(conn.RemoteAddr())
+	sp.noMerkleBlocks = isInNetworks(conn.RemoteAddr(), cfg.noMerkleBlockNets)
 	sp.Peer = peer.NewInboundPeer(newPeerConfig(sp))
-	sp.AssociateConnection(conn)
+	sp.AssociateConnection(s.limitConn(conn, sp.isWhitelisted))
 	go s.peerDoneHandler(sp)
 }
 
+// limitConn returns the passed peer connection limited to the configured
+// upload and download rates.  Connections of whitelisted peers are not
+// limited.
+func (s *server) limitConn(conn net.Conn, whitelisted bool) net.Conn {
+	if whitelisted {
+		return conn
+	}
+	var peerUpload, peerDownload *connmgr.RateLimiter
+	if cfg.MaxPeerUploadRate != 0 {
+		peerUpload = connmgr.NewRateLimiter(cfg.MaxPeerUploadRate * 1024)
+	}
+	if cfg.MaxPeerDownloadRate != 0 {
+		peerDownload = connmgr.NewRateLimiter(cfg.MaxPeerDownloadRate * 1024)
+	}
+	return connmgr.NewRateLimitedConn(conn,
+		[]*connmgr.RateLimiter{peerDownload, s.downloadLimiter},
+		[]*connmgr.RateLimiter{peerUpload, s.uploadLimiter})
+}
+
 // outboundPeerConnected is invoked by the connection manager when a new
 // outbound connection is established.  It initializes a new outbound server
 // peer instance, associates it with the relevant state such as the connection
@@ -2548,11 +3119,18 @@ func (s *server) inboundPeerConnected(conn net.Conn) {
 // manager of the attempt.
 func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	sp := newServerPeer(s, c.Permanent)
+	sp.feeler = c.Feeler
+	sp.blockRelayOnly = c.BlockRelayOnly
 	p, err := peer.NewOutboundPeer(newPeerConfig(sp), c.Addr.String())
 	if err != nil {
 		srvrLog.Debugf("Cannot create outbound peer %s: %v", c.Addr, err)
 		if c.Permanent {
 			s.connManager.Disconnect(c.ID())
+		} else if c.Feeler {
+			s.connManager.Remove(c.ID())
+		} else if c.BlockRelayOnly {
+			s.connManager.Remove(c.ID())
+			go s.connManager.NewBlockRelayOnlyConnReq()
 		} else {
 			s.connManager.Remove(c.ID())
 			go s.connManager.NewConnReq()
@@ -2562,7 +3140,8 @@ func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	sp.Peer = p
 	sp.connReq = c
 	sp.isWhitelisted = isWhitelisted(conn.RemoteAddr())
-	sp.AssociateConnection(conn)
+	sp.noMerkleBlocks = isInNetworks(conn.RemoteAddr(), cfg.noMerkleBlockNets)
+	sp.AssociateConnection(s.limitConn(conn, sp.isWhitelisted))
 	go s.peerDoneHandler(sp)
 }
 
@@ -2571,9 +3150,10 @@ func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 func (s *server) peerDoneHandler(sp *serverPeer) {
 	sp.WaitForDisconnect()
 	s.donePeers <- sp
+	sp.releaseFilterMem()
 
 	// Only tell sync manager we are gone if we ever told it we existed.
-	if sp.VerAckReceived() {
+	if sp.VerAckReceived() && !sp.feeler {
 		s.syncManager.DonePeer(sp.Peer, nil)
 
 		// Evict any remaining orphans that were sent by the peer.
@@ -2606,7 +3186,6 @@ func (s *server) peerHandler() {
 		persistentPeers:  make(map[int32]*serverPeer),
 		outboundPeers:    make(map[int32]*serverPeer),
 		directRelayPeers: make(map[int32]*serverPeer),
-		banned:           make(map[string]time.Time),
 		outboundGroups:   make(map[string]int),
 		connectionCount:  make(map[string]int),
 	}
@@ -2640,10 +3219,6 @@ out:
 		case umsg := <-s.peerHeightsUpdate:
 			s.handleUpdatePeerHeights(state, umsg)
 
-		// Peer to ban.
-		case p := <-s.banPeers:
-			s.handleBanPeerMsg(state, p)
-
 		// New inventory to potentially be relayed to other peers.
 		case invMsg := <-s.relayInv:
 			s.handleRelayInvMsg(state, invMsg)
@@ -2653,6 +3228,10 @@ out:
 		case msgCmpctBlock := <-s.relayCmpctBlock:
 			s.handleRelayCmpctBlock(state, msgCmpctBlock)
 
+		// A weak block to relay to the peers which want weak blocks.
+		case msgWeakBlock := <-s.relayWeakBlock:
+			s.handleRelayWeakBlock(state, msgWeakBlock)
+
 		// Message to broadcast to all connected peers except those
 		// which are excluded by the message.
 		case bmsg := <-s.broadcast:
@@ -2670,6 +3249,10 @@ out:
 			}
 
 		case <-s.quit:
+			// Save the outbound peers connected the longest as
+			// anchors for the next start.
+			s.saveAnchors(state)
+
 			// Disconnect all peers on server shutdown.
 			state.forAllPeers(func(sp *serverPeer) {
 				srvrLog.Tracef("Shutdown peer %s", sp)
@@ -2708,6 +3291,39 @@ cleanup:
 	srvrLog.Info("Peer handler complete")
 }
 
+// saveAnchors saves the addresses of the outbound peers which have been
+// connected the longest as anchors, which are connected to first on the next
+// start.  It is invoked from the peerHandler goroutine.
+func (s *server) saveAnchors(state *peerState) {
+	// Anchors are only used when connecting to discovered peers.
+	if cfg.SimNet || cfg.RegressionTest || len(cfg.ConnectPeers) != 0 {
+		return
+	}
+
+	peers := make([]*serverPeer, 0, len(state.outboundPeers))
+	for _, sp := range state.outboundPeers {
+		if sp.Connected() && sp.VerAckReceived() && sp.NA() != nil {
+			peers = append(peers, sp)
+		}
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].TimeConnected().Before(peers[j].TimeConnected())
+	})
+	addrs := make([]*wire.NetAddress, 0, addrmgr.MaxAnchors)
+	for _, sp := range peers {
+		if len(addrs) == addrmgr.MaxAnchors {
+			break
+		}
+		addrs = append(addrs, sp.NA())
+	}
+	if err := s.addrManager.SaveAnchors(addrs); err != nil {
+		srvrLog.Warnf("Unable to save anchors: %v", err)
+		return
+	}
+	srvrLog.Debugf("Saved %d anchor %s", len(addrs),
+		pickNoun(uint64(len(addrs)), "address", "addresses"))
+}
+
 // AddPeer adds a new peer that has already been connected to the server.
 func (s *server) AddPeer(sp *serverPeer) {
 	s.newPeers <- sp
@@ -2715,7 +3331,24 @@ func (s *server) AddPeer(sp *serverPeer) {
 
 // BanPeer bans a peer that has already been connected to the server by ip.
 func (s *server) BanPeer(sp *serverPeer) {
-	s.banPeers <- sp
+	direction := directionString(sp.Inbound())
+	if s.isOnionInbound(sp) {
+		srvrLog.Infof("Not banning peer %s (%s) connected through the "+
+			"onion service", sp, direction)
+		return
+	}
+	ip := net.ParseIP(sp.host())
+	if ip == nil {
+		srvrLog.Debugf("can't ban peer %s without ip address", sp)
+		return
+	}
+	if err := s.banManager.BanHost(ip); err != nil {
+		srvrLog.Errorf("Unable to ban peer %s (%s): %v", sp, direction,
+			err)
+		return
+	}
+	srvrLog.Infof("Banned peer %s (%s) for %v", sp, direction,
+		cfg.BanDuration)
 }
 
 // RelayInventory relays the passed inventory vector to all connected peers
@@ -2752,6 +3385,7 @@ func (s *server) OutboundGroupCount(key string) int {
 // for the server.  It is safe for concurrent access.
 func (s *server) AddBytesSent(bytesSent uint64) {
 	atomic.AddUint64(&s.bytesSent, bytesSent)
+	s.uploadTarget.AddBytes(bytesSent)
 }
 
 // AddBytesReceived adds the passed number of bytes to the total bytes received
@@ -2779,6 +3413,59 @@ func (s *server) UpdatePeerHeights(latestBlkHash *chainhash.Hash, latestHeight i
 	}
 }
 
+// dbCompactionHandler compacts the database in the background once enough keys
+// were deleted from it to make reclaiming their space worthwhile.  Compacting a
+// large database competes with block validation for disk IO, so it only starts
+// while the node is idle, which is when the chain is current and no block was
+// connected since the previous check.
+//
+// NOTE: A compaction can't be interrupted, so shutting down waits for a running
+// compaction to complete.
+func (s *server) dbCompactionHandler() {
+	ticker := time.NewTicker(dbCompactInterval)
+	lastBestHash := s.chain.BestSnapshot().Hash
+
+out:
+	for {
+		select {
+		case <-ticker.C:
+			bestHash := s.chain.BestSnapshot().Hash
+			idle := bestHash == lastBestHash &&
+				s.syncManager.IsCurrent()
+			lastBestHash = bestHash
+			if !idle {
+				continue
+			}
+
+			info, err := s.db.SpaceInfo()
+			if err != nil {
+				srvrLog.Errorf("Unable to fetch database space "+
+					"info: %v", err)
+				continue
+			}
+			if info.DeletedKeys < dbCompactMinDeletedKeys {
+				continue
+			}
+
+			srvrLog.Infof("Compacting database to reclaim the space "+
+				"of %d deleted keys", info.DeletedKeys)
+			start := time.Now()
+			if err := s.db.Compact(); err != nil {
+				srvrLog.Errorf("Unable to compact database: %v", err)
+				continue
+			}
+			srvrLog.Infof("Compacted database in %v",
+				time.Since(start).Round(time.Second))
+
+		case <-s.quit:
+			break out
+		}
+	}
+
+	ticker.Stop()
+	s.wg.Done()
+}
+
 // rebroadcastHandler keeps track of user submitted inventories that we have
 // sent out but have not yet made it into a block. We periodically rebroadcast
 // them in case our peers restarted or otherwise lost track of them.
@@ -2849,9 +3536,24 @@ func (s *server) Start() {
 	s.wg.Add(1)
 	go s.peerHandler()
 
-	if s.nat != nil {
+	for _, n := range s.nats {
+		s.wg.Add(1)
+		go s.natUpdateThread(n)
+	}
+
+	if s.onionTarget != "" {
 		s.wg.Add(1)
-		go s.upnpUpdateThread()
+		go s.onionServiceHandler()
+	}
+
+	if cfg.DbAutoCompact {
+		s.wg.Add(1)
+		go s.dbCompactionHandler()
+	}
+
+	if len(reloadSignals) > 0 {
+		s.wg.Add(1)
+		go s.reloadHandler()
 	}
 
 	if !cfg.DisableRPC {
@@ -2871,6 +3573,21 @@ func (s *server) Start() {
 	if cfg.Generate {
 		s.cpuMiner.Start()
 	}
+
+	// Start the stratum server if it is enabled.
+	if s.stratumServer != nil {
+		s.stratumServer.Start()
+	}
+
+	// Start the DNS seeder if it is enabled.
+	if s.seeder != nil {
+		s.seeder.Start()
+	}
+
+	// Start the webhook notifier if it is enabled.
+	if s.webhookNotifier != nil {
+		s.webhookNotifier.Start()
+	}
 }
 
 // Stop gracefully shuts down the server by stopping and disconnecting all
@@ -2889,6 +3606,34 @@ func (s *server) Stop() error {
 	s.cpuMiner.Stop()
 	srvrLog.Info("Stopped: cpuMiner")
 
+	// Stop the stratum server if needed.
+	if s.stratumServer != nil {
+		srvrLog.Info("Stopping: stratumServer")
+		s.stratumServer.Stop()
+		srvrLog.Info("Stopped: stratumServer")
+	}
+
+	// Stop the DNS seeder if needed.
+	if s.seeder != nil {
+		srvrLog.Info("Stopping: seeder")
+		s.seeder.Stop()
+		srvrLog.Info("Stopped: seeder")
+	}
+
+	// Stop the webhook notifier if needed.
+	if s.webhookNotifier != nil {
+		srvrLog.Info("Stopping: webhookNotifier")
+		s.webhookNotifier.Stop()
+		srvrLog.Info("Stopped: webhookNotifier")
+	}
+
+	// Close the first seen export file if needed.
+	if s.firstSeenExport != nil {
+		if err := s.firstSeenExport.Close(); err != nil {
+			srvrLog.Errorf("Unable to close first seen export: %v", err)
+		}
+	}
+
 	// Shutdown the RPC server if it's not disabled.
 	if !cfg.DisableRPC {
 		srvrLog.Info("Stopping: rpcServer")
@@ -3007,44 +3752,56 @@ func parseListeners(addrs []string) ([]net.Addr, error) {
 	return netAddrs, nil
 }
 
-func (s *server) upnpUpdateThread() {
+// natUpdateThread maps the listening port on the passed NAT gateway and renews
+// the mapping before its lifetime expires.  The external address of the mapping
+// is advertised to peers and updated when the gateway changes it.  It must be
+// run as a goroutine.
+func (s *server) natUpdateThread(n nat.NAT) {
 	// Go off immediately to prevent code duplication, thereafter we renew
-	// lease every 15 minutes.
+	// the mapping halfway through its lifetime.
 	timer := time.NewTimer(0 * time.Second)
 	lport, _ := strconv.ParseInt(activeNetParams.DefaultPort, 10, 16)
-	first := true
+	externalPort := int(lport)
+	var mapped *wire.NetAddress
 out:
 	for {
 		select {
 		case <-timer.C:
-			// TODO: pick external port  more cleverly
-			// TODO: know which ports we are listening to on an external net.
-			// TODO: if specific listen port doesn't work then ask for wildcard
-			// listen port?
-			// XXX this assumes timeout is in seconds.
-			listenPort, err := s.nat.AddPortMapping("tcp", int(lport), int(lport),
-				"bchd listen port", 20*60)
+			port, lifetime, err := n.AddPortMapping("tcp", int(lport),
+				externalPort, natMappingLifetime)
 			if err != nil {
-				srvrLog.Warnf("can't add UPnP port mapping: %v", err)
+				srvrLog.Warnf("Can't add %s port mapping: %v", n, err)
+				timer.Reset(natRetryInterval)
+				continue
 			}
-			if first && err == nil {
-				// TODO: look this up periodically to see if upnp domain changed
-				// and so did ip.
-				externalip, err := s.nat.GetExternalAddress()
-				if err != nil {
-					srvrLog.Warnf("UPnP can't get external address: %v", err)
-					continue out
-				}
-				na := wire.NewNetAddressIPPort(externalip, uint16(listenPort),
-					s.services)
-				err = s.addrManager.AddLocalAddress(na, addrmgr.UpnpPrio)
-				if err != nil {
-					// XXX DeletePortMapping?
-				}
-				srvrLog.Warnf("Successfully bound via UPnP to %s", addrmgr.NetAddressKey(na))
-				first = false
+			externalPort = port
+			timer.Reset(max(lifetime/2, natRetryInterval))
+
+			externalIP, err := n.ExternalAddress()
+			if err != nil {
+				srvrLog.Warnf("%s can't get external address: %v", n,
+					err)
+				continue
 			}
-			timer.Reset(time.Minute * 15)
+			na := wire.NewNetAddressIPPort(externalIP, uint16(port),
+				s.services)
+			if mapped != nil && addrmgr.NetAddressKey(mapped) ==
+				addrmgr.NetAddressKey(na) {
+
+				continue
+			}
+			if mapped != nil {
+				s.addrManager.RemoveLocalAddress(mapped)
+			}
+			err = s.addrManager.AddLocalAddress(na, addrmgr.UpnpPrio)
+			if err != nil {
+				srvrLog.Warnf("Can't advertise %s mapped address "+
+					"%s: %v", n, addrmgr.NetAddressKey(na), err)
+			}
+			mapped = na
+			srvrLog.Infof("Mapped listening port via %s to %s", n,
+				addrmgr.NetAddressKey(na))
+
 		case <-s.quit:
 			break out
 		}
@@ -3052,10 +3809,13 @@ out:
 
 	timer.Stop()
 
-	if err := s.nat.DeletePortMapping("tcp", int(lport), int(lport)); err != nil {
-		srvrLog.Warnf("unable to remove UPnP port mapping: %v", err)
-	} else {
-		srvrLog.Debugf("successfully disestablished UPnP port mapping")
+	if mapped != nil {
+		err := n.DeletePortMapping("tcp", int(lport), externalPort)
+		if err != nil {
+			srvrLog.Warnf("Unable to remove %s port mapping: %v", n, err)
+		} else {
+			srvrLog.Debugf("Successfully removed %s port mapping", n)
+		}
 	}
 
 	s.wg.Done()
@@ -3110,6 +3870,51 @@ func setupRPCListeners() ([]net.Listener, error) {
 	return rpcListeners, nil
 }
 
+// setupStratumListeners returns a slice of listeners that are configured for
+// use with the stratum server depending on the configuration settings for
+// listen addresses.
+func setupStratumListeners() ([]net.Listener, error) {
+	stratumNetAddrs, err := parseListeners(cfg.StratumListeners)
+	if err != nil {
+		return nil, err
+	}
+
+	stratumListeners := make([]net.Listener, 0, len(stratumNetAddrs))
+	for _, addr := range stratumNetAddrs {
+		listener, err := net.Listen(addr.Network(), addr.String())
+		if err != nil {
+			minrLog.Warnf("Can't listen on %s: %v", addr, err)
+			continue
+		}
+		stratumListeners = append(stratumListeners, listener)
+	}
+
+	return stratumListeners, nil
+}
+
+// setupSeederListeners returns a slice of UDP listeners that are configured for
+// use with the DNS seeder depending on the configuration settings for listen
+// addresses.
+func setupSeederListeners() ([]net.PacketConn, error) {
+	seederNetAddrs, err := parseListeners(cfg.SeederListeners)
+	if err != nil {
+		return nil, err
+	}
+
+	seederListeners := make([]net.PacketConn, 0, len(seederNetAddrs))
+	for _, addr := range seederNetAddrs {
+		network := strings.Replace(addr.Network(), "tcp", "udp", 1)
+		listener, err := net.ListenPacket(network, addr.String())
+		if err != nil {
+			srvrLog.Warnf("Can't listen on %s: %v", addr, err)
+			continue
+		}
+		seederListeners = append(seederListeners, listener)
+	}
+
+	return seederListeners, nil
+}
+
 // newServer returns a new bchd server configured to listen on addr for the
 // bitcoin network type specified by chainParams.  Use start to begin accepting
 // connections from peers.
@@ -3123,12 +3928,27 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string, db database
 	}
 
 	amgr := addrmgr.New(cfg.DataDir, bchdLookup)
+	if cfg.ASMap != "" {
+		asmap, err := addrmgr.LoadASMap(cfg.ASMap)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load asmap: %v", err)
+		}
+		amgr.SetASMap(asmap)
+	}
+
+	banManager, err := banmgr.New(&banmgr.Config{
+		DB:          db,
+		Threshold:   cfg.BanThreshold,
+		BanDuration: cfg.BanDuration,
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	var listeners []net.Listener
-	var nat NAT
+	var nats []nat.NAT
 	if !cfg.DisableListen {
-		var err error
-		listeners, nat, err = initListeners(amgr, listenAddrs, services)
+		listeners, nats, err = initListeners(amgr, listenAddrs, services)
 		if err != nil {
 			return nil, err
 		}
@@ -3148,27 +3968,46 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string, db database
 		startupTime:             time.Now().Unix(),
 		chainParams:             chainParams,
 		addrManager:             amgr,
+		banManager:              banManager,
 		newPeers:                make(chan *serverPeer, cfg.MaxPeers),
 		donePeers:               make(chan *serverPeer, cfg.MaxPeers),
-		banPeers:                make(chan *serverPeer, cfg.MaxPeers),
 		maybeAddDirectRelayPeer: make(chan *maybeAddDirectRelayPeerMsg),
 
 		query:                make(chan interface{}),
 		relayInv:             make(chan relayMsg, cfg.MaxPeers),
 		relayCmpctBlock:      make(chan *wire.MsgCmpctBlock),
+		relayWeakBlock:       make(chan relayWeakBlockMsg),
 		broadcast:            make(chan broadcastMsg, cfg.MaxPeers),
 		quit:                 make(chan struct{}),
 		modifyRebroadcastInv: make(chan interface{}),
 		peerHeightsUpdate:    make(chan updatePeerHeightsMsg),
-		nat:                  nat,
+		nats:                 nats,
 		db:                   db,
 		timeSource:           blockchain.NewMedianTime(),
 		services:             services,
 		sigCache:             txscript.NewSigCache(cfg.SigCacheMaxSize),
 		hashCache:            txscript.NewHashCache(cfg.SigCacheMaxSize),
+		scriptCache:          txscript.NewScriptCache(cfg.ScriptCacheMaxSize),
 		cfCheckptCaches:      make(map[wire.FilterType][]cfHeaderKV),
 		agentBlacklist:       agentBlacklist,
 		agentWhitelist:       agentWhitelist,
+		loadedCfg:            cfg,
+	}
+	if cfg.ListenOnion {
+		s.onionTarget = onionTargetAddr(listeners)
+	}
+
+	// Historical blocks are only served while more than a quarter of the
+	// upload target is left so the rest is available for relaying new
+	// blocks and transactions.
+	uploadTarget := cfg.MaxUploadTarget * 1024 * 1024
+	s.uploadTarget = connmgr.NewUploadTarget(uploadTarget, uploadTarget/4,
+		uploadTargetTimeframe)
+	if cfg.MaxUploadRate != 0 {
+		s.uploadLimiter = connmgr.NewRateLimiter(cfg.MaxUploadRate * 1024)
+	}
+	if cfg.MaxDownloadRate != 0 {
+		s.downloadLimiter = connmgr.NewRateLimiter(cfg.MaxDownloadRate * 1024)
 	}
 
 	// Create the transaction and address indexes if needed.
@@ -3210,6 +4049,26 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string, db database
 		s.slpIndex = indexers.NewSlpIndex(db, slpCfg)
 		indexes = append(indexes, s.slpIndex)
 	}
+	if cfg.TokenIndex {
+		indxLog.Info("Token category index is enabled")
+		s.tokenIndex = indexers.NewTokenIndex(db, chainParams)
+		indexes = append(indexes, s.tokenIndex)
+	}
+	if cfg.StatsIndex {
+		indxLog.Info("Chain statistics index is enabled")
+		s.statsIndex = indexers.NewStatsIndex(db)
+		indexes = append(indexes, s.statsIndex)
+	}
+	if cfg.CoinAgeIndex {
+		indxLog.Info("Coin age index is enabled")
+		s.coinAgeIndex = indexers.NewCoinAgeIndex(db)
+		indexes = append(indexes, s.coinAgeIndex)
+	}
+	if cfg.WatchSetIndex {
+		indxLog.Info("Watch set index is enabled")
+		s.watchSetIndex = indexers.NewWatchSetIndex(db)
+		indexes = append(indexes, s.watchSetIndex)
+	}
 	if !cfg.FastSync && !cfg.NoCFilters {
 		indxLog.Info("Committed filter index is enabled")
 		s.cfIndex = indexers.NewCfIndex(db, chainParams)
@@ -3228,24 +4087,42 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string, db database
 		checkpoints = mergeCheckpoints(s.chainParams.Checkpoints, cfg.addCheckpoints)
 	}
 
+	// Use the minimum chain work of the network unless it is overridden.
+	minimumChainWork := s.chainParams.MinimumChainWork
+	if cfg.minimumChainWork != nil {
+		minimumChainWork = cfg.minimumChainWork
+	}
+
+	// Likewise for the block which is assumed to be valid.
+	assumeValid := s.chainParams.AssumeValid
+	if cfg.AssumeValid != "" {
+		assumeValid = cfg.assumeValid
+	}
+
 	// Create a new block chain instance with the appropriate configuration.
-	var err error
 	s.chain, err = blockchain.New(&blockchain.Config{
 		DB:                 s.db,
-		UtxoCacheMaxSize:   uint64(cfg.UtxoCacheMaxSizeMiB) * 1024 * 1024,
+		UtxoCacheMaxSize:   uint64(cfg.UtxoCacheMaxMB) * 1024 * 1024,
 		Interrupt:          interrupt,
 		ChainParams:        s.chainParams,
 		Checkpoints:        checkpoints,
+		MinimumChainWork:   minimumChainWork,
+		AssumeValid:        assumeValid,
 		TimeSource:         s.timeSource,
 		SigCache:           s.sigCache,
 		IndexManager:       indexManager,
 		HashCache:          s.hashCache,
+		ScriptCache:        s.scriptCache,
 		ExcessiveBlockSize: cfg.ExcessiveBlockSize,
 		Prune:              cfg.Prune,
 		PruneDepth:         cfg.PruneDepth,
+		UndoWindow:         cfg.UndoWindow,
 		ReIndexChainState:  cfg.ReIndexChainState,
+		ReIndex:            cfg.Reindex,
+		UtxoStats:          cfg.UtxoStats,
 		FastSync:           cfg.FastSync,
 		FastSyncDataDir:    cfg.DataDir,
+		HeadersOnly:        cfg.HeadersOnly,
 		Proxy:              cfg.Proxy,
 	})
 	if err != nil {
@@ -3257,6 +4134,12 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string, db database
 		s.services |= wire.SFNodeNetworkLimited
 	}
 
+	// A headers only node can serve the block headers and the compact
+	// filters, but neither blocks nor merkle blocks.
+	if s.chain.HeadersOnly() {
+		s.services &^= wire.SFNodeNetwork | wire.SFNodeBloom
+	}
+
 	// Search for a FeeEstimator state in the database. If none can be found
 	// or if it cannot be loaded, create a new one.
 	db.Update(func(tx database.Tx) error {
@@ -3294,9 +4177,23 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string, db database
 			FreeTxRelayLimit:     cfg.FreeTxRelayLimit,
 			MaxOrphanTxs:         cfg.MaxOrphanTxs,
 			MaxOrphanTxSize:      defaultMaxOrphanTxSize,
+			MaxPoolSize:          int64(cfg.MaxMempool) * 1000 * 1000,
+			LimitAncestorCount:   cfg.LimitAncestorCount,
+			LimitAncestorSize:    int64(cfg.LimitAncestorSize) * 1000,
+			LimitDescendantCount: cfg.LimitDescendantCount,
+			LimitDescendantSize:  int64(cfg.LimitDescendantSize) * 1000,
 			LimitSigChecks:       true,
 			MinRelayTxFee:        cfg.minRelayTxFee,
+			DustRelayFee:         cfg.dustRelayFee,
+			MaxTxSigChecks:       cfg.MaxTxSigChecks,
+			Expiry:               cfg.MempoolExpiry,
+			AllowReplacement:     cfg.MempoolReplacement,
 			MaxTxVersion:         2,
+			DataCarrier: mempool.DataCarrierPolicy{
+				MaxSize:    cfg.DataCarrierSize,
+				MaxOutputs: cfg.MaxDataCarriers,
+				Protocols:  cfg.dataCarrierProtocols,
+			},
 		},
 		ChainParams:    chainParams,
 		FetchUtxoView:  s.chain.FetchUtxoView,
@@ -3305,11 +4202,16 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string, db database
 		CalcSequenceLock: func(tx *bchutil.Tx, view *blockchain.UtxoViewpoint) (*blockchain.SequenceLock, error) {
 			return s.chain.CalcSequenceLock(tx, view, true)
 		},
-		IsDeploymentActive: s.chain.IsDeploymentActive,
-		SigCache:           s.sigCache,
-		HashCache:          s.hashCache,
-		AddrIndex:          s.addrIndex,
-		FeeEstimator:       s.feeEstimator,
+		IsDeploymentActive:   s.chain.IsDeploymentActive,
+		SigCache:             s.sigCache,
+		HashCache:            s.hashCache,
+		ScriptCache:          s.scriptCache,
+		NextBlockScriptFlags: s.chain.NextBlockScriptFlags,
+		AddrIndex:            s.addrIndex,
+		FeeEstimator:         s.feeEstimator,
+		NotifyDoubleSpend:    s.NotifyDoubleSpend,
+		NotifyPoolChange:     s.NotifyPoolChange,
+		NotifyExpired:        s.NotifyExpired,
 	}
 	s.txMemPool = mempool.New(&txC)
 
@@ -3329,6 +4231,8 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string, db database
 		FeeEstimator:            s.feeEstimator,
 		MinSyncPeerNetworkSpeed: cfg.MinSyncPeerNetworkSpeed,
 		FastSyncMode:            cfg.FastSync,
+		HeadersOnlyMode:         cfg.HeadersOnly,
+		BlocksOnly:              cfg.BlocksOnly,
 		RegTestSyncAnyHost:      cfg.RegressionTestAnyHost,
 	})
 	if err != nil {
@@ -3345,42 +4249,162 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string, db database
 		BlockMaxSize:      cfg.BlockMaxSize,
 		BlockPrioritySize: cfg.BlockPrioritySize,
 		TxMinFreeFee:      cfg.minRelayTxFee,
+		CoinbaseData:      cfg.coinbaseData,
+		CoinbaseOutputs:   cfg.coinbaseOutputs,
+		SignetKeys:        cfg.signetKeys,
 	}
 	blockTemplateGenerator := mining.NewBlkTmplGenerator(&policy,
 		s.chainParams, s.txMemPool, s.chain, s.timeSource,
 		s.sigCache, s.hashCache)
+
+	// Weak blocks found by the local miners are only announced when weak
+	// block relay is enabled.
+	var processWeakBlock func(*wire.MsgBlock)
+	if cfg.WeakBlocks {
+		processWeakBlock = s.AnnounceWeakBlock
+	}
 	s.cpuMiner = cpuminer.New(&cpuminer.Config{
 		ChainParams:            chainParams,
 		BlockTemplateGenerator: blockTemplateGenerator,
 		MiningAddrs:            cfg.miningAddrs,
 		ProcessBlock:           s.syncManager.ProcessBlock,
+		ProcessWeakBlock:       processWeakBlock,
 		ConnectedCount:         s.ConnectedCount,
 		IsCurrent:              s.syncManager.IsCurrent,
 	})
 	mining.CoinbaseFlags = cfg.CoinbaseFlags
 
+	// Setup the stratum server if listeners are configured.
+	if len(cfg.StratumListeners) > 0 {
+		stratumListeners, err := setupStratumListeners()
+		if err != nil {
+			return nil, err
+		}
+		if len(stratumListeners) == 0 {
+			return nil, errors.New("stratum: no valid listen address")
+		}
+
+		s.stratumServer, err = stratum.New(&stratum.Config{
+			ChainParams:            chainParams,
+			BlockTemplateGenerator: blockTemplateGenerator,
+			MiningAddrs:            cfg.miningAddrs,
+			ProcessBlock:           s.syncManager.ProcessBlock,
+			ProcessWeakBlock:       processWeakBlock,
+			IsCurrent:              s.syncManager.IsCurrent,
+			Listeners:              stratumListeners,
+			Password:               cfg.StratumPass,
+			MinDifficulty:          cfg.StratumDifficulty,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Setup the DNS seeder if a host name is configured.
+	if cfg.SeederHost != "" {
+		seederListeners, err := setupSeederListeners()
+		if err != nil {
+			return nil, err
+		}
+		if len(seederListeners) == 0 {
+			return nil, errors.New("seeder: no valid listen address")
+		}
+
+		s.seeder, err = seeder.New(&seeder.Config{
+			ChainParams:  chainParams,
+			Host:         cfg.SeederHost,
+			Nameserver:   cfg.SeederNameserver,
+			Listeners:    seederListeners,
+			Dial:         bchdDial,
+			GetAddresses: s.addrManager.Addresses,
+			OnAddresses:  s.addrManager.AddAddresses,
+			BestHeight: func() int32 {
+				return s.chain.BestSnapshot().Height
+			},
+			UserAgentName:    userAgentName,
+			UserAgentVersion: userAgentVersion,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Setup the webhook notifier if any webhooks are configured.
+	if len(cfg.Webhooks) > 0 {
+		s.webhookNotifier, err = webhook.New(&webhook.Config{
+			URLs:        cfg.Webhooks,
+			Addresses:   cfg.webhookAddrs,
+			ChainParams: chainParams,
+			Chain:       s.chain,
+			Dial:        cfg.dial,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Setup the first seen tracker if enabled, along with the file its
+	// records are exported to if one is configured.
+	if cfg.FirstSeen {
+		fsCfg := &firstseen.Config{}
+		if cfg.FirstSeenExport != "" {
+			s.firstSeenExport, err = os.OpenFile(cfg.FirstSeenExport,
+				os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+			if err != nil {
+				return nil, err
+			}
+			fsCfg.Export = s.firstSeenExport
+		}
+		s.firstSeen = firstseen.New(fsCfg)
+	}
+
 	// Only setup a function to return new addresses to connect to when
 	// not running in connect-only mode.  The simulation and regression networks
 	// are always in connect-only mode since they are only intended to connect
 	// to specified peers and actively avoid advertising and connecting to
 	// discovered peers in order to prevent it from becoming a public test
 	// network.
-	var newAddressFunc func() (net.Addr, error)
+	var newAddressFunc, feelerAddressFunc func() (net.Addr, error)
 	if !cfg.SimNet && !cfg.RegressionTest && len(cfg.ConnectPeers) == 0 {
+		anchors := amgr.LoadAnchors()
+		var anchorsMtx sync.Mutex
 		newAddressFunc = func() (net.Addr, error) {
+			// Reconnect to the anchors saved by the previous run
+			// before picking addresses from the address manager.
+			anchorsMtx.Lock()
+			for len(anchors) > 0 {
+				na := anchors[0]
+				anchors = anchors[1:]
+				if !isReachable(na) {
+					continue
+				}
+				anchorsMtx.Unlock()
+
+				addrString := addrmgr.NetAddressKey(na)
+				srvrLog.Infof("Connecting to anchor %s", addrString)
+				return addrStringToNetAddr(addrString)
+			}
+			anchorsMtx.Unlock()
+
 			for tries := 0; tries < 100; tries++ {
 				addr := s.addrManager.GetAddress()
 				if addr == nil {
 					break
 				}
 
+				// Skip addresses of networks we are unable to
+				// connect to.
+				if !isReachable(addr.NetAddress()) {
+					continue
+				}
+
 				// Address will not be invalid, local or unroutable
 				// because addrmanager rejects those on addition.
 				// Just check that we don't already have an address
 				// in the same group so that we are not connecting
 				// to the same network segment at the expense of
 				// others.
-				key := addrmgr.GroupKey(addr.NetAddress())
+				key := s.addrManager.GroupKey(addr.NetAddress())
 				if s.OutboundGroupCount(key) != 0 {
 					continue
 				}
@@ -3406,6 +4430,40 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string, db database
 
 			return nil, errors.New("no valid connect address")
 		}
+
+		// Feeler connections test addresses of the new table, which
+		// have not been connected to yet, and move the reachable ones
+		// to the tried table.  This keeps the tried table fresh, which
+		// makes it harder for an attacker to fill it with its own
+		// addresses.
+		feelerAddressFunc = func() (net.Addr, error) {
+			for tries := 0; tries < 100; tries++ {
+				addr := s.addrManager.GetNewAddress()
+				if addr == nil {
+					break
+				}
+				na := addr.NetAddress()
+				if !isReachable(na) {
+					continue
+				}
+
+				// Avoid groups we already have outbound
+				// connections to and addresses which were
+				// attempted recently.
+				key := s.addrManager.GroupKey(na)
+				if s.OutboundGroupCount(key) != 0 {
+					continue
+				}
+				if time.Since(addr.LastAttempt()) < 10*time.Minute {
+					continue
+				}
+
+				s.addrManager.Attempt(na)
+				return addrStringToNetAddr(addrmgr.NetAddressKey(na))
+			}
+
+			return nil, errors.New("no valid feeler address")
+		}
 	}
 
 	// Create a connection manager.
@@ -3414,13 +4472,15 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string, db database
 		targetOutbound = uint32(cfg.MaxPeers)
 	}
 	cmgr, err := connmgr.New(&connmgr.Config{
-		Listeners:      listeners,
-		OnAccept:       s.inboundPeerConnected,
-		RetryDuration:  connectionRetryInterval,
-		TargetOutbound: targetOutbound,
-		Dial:           bchdDial,
-		OnConnection:   s.outboundPeerConnected,
-		GetNewAddress:  newAddressFunc,
+		Listeners:            listeners,
+		OnAccept:             s.inboundPeerConnected,
+		RetryDuration:        connectionRetryInterval,
+		TargetOutbound:       targetOutbound,
+		TargetBlockRelayOnly: cfg.BlockRelayOnlyPeers,
+		Dial:                 bchdDial,
+		OnConnection:         s.outboundPeerConnected,
+		GetNewAddress:        newAddressFunc,
+		GetFeelerAddress:     feelerAddressFunc,
 	})
 	if err != nil {
 		return nil, err
@@ -3460,6 +4520,7 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string, db database
 			StartupTime:    s.startupTime,
 			ConnMgr:        &rpcConnManager{&s},
 			AddrMgr:        amgr,
+			BanMgr:         s.banManager,
 			SyncMgr:        &rpcSyncMgr{&s, s.syncManager},
 			TimeSource:     s.timeSource,
 			Chain:          s.chain,
@@ -3473,7 +4534,9 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string, db database
 			CfIndex:        s.cfIndex,
 			SlpIndex:       s.slpIndex,
 			FeeEstimator:   s.feeEstimator,
+			FirstSeen:      s.firstSeen,
 			Services:       s.services,
+			ReloadConfig:   s.reloadConfig,
 			RPCAuthTimeout: cfg.RPCAuthTimeout,
 		})
 		if err != nil {
@@ -3485,6 +4548,69 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string, db database
 			return nil, err
 		}
 
+		var watchOnly *watchonly.Manager
+		if cfg.WatchOnly {
+			watchOnly, err = watchonly.New(&watchonly.Config{
+				ChainParams: chainParams,
+				DB:          db,
+				Chain:       s.chain,
+				AddrIndex:   s.addrIndex,
+				TxMemPool:   s.txMemPool,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var hotWallet *hotwallet.Manager
+		if cfg.HotWallet {
+			hotWallet, err = hotwallet.New(&hotwallet.Config{
+				ChainParams: chainParams,
+				DB:          db,
+				WatchOnly:   watchOnly,
+				AuthToken:   cfg.HotWalletAuthToken,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var bcmrResolver *bcmr.Resolver
+		if cfg.Bcmr {
+			bcmrResolver, err = bcmr.New(&bcmr.Config{
+				ChainParams: chainParams,
+				DB:          db,
+				Chain:       s.chain,
+				TxIndex:     s.txIndex,
+				AddrIndex:   s.addrIndex,
+				TxMemPool:   s.txMemPool,
+				Dial:        cfg.dial,
+				IPFSGateway: cfg.BcmrIPFSGateway,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// Rescans match the compact filters of the blocks, so they
+		// require the cf index.
+		var rescanner *rescan.Rescanner
+		if s.cfIndex != nil {
+			cfIndex := s.cfIndex
+			rescanner = rescan.New(&rescan.Config{
+				HeightRange:       s.chain.HeightRange,
+				MainChainHasBlock: s.chain.MainChainHasBlock,
+				FetchBlock:        s.chain.BlockByHash,
+				BestHeight: func() int32 {
+					return s.chain.BestSnapshot().Height
+				},
+				FetchFilters: func(hashes []*chainhash.Hash) ([][]byte, error) {
+					return cfIndex.FiltersByBlockHashes(hashes,
+						wire.GCSFilterRegular)
+				},
+			})
+		}
+
 		s.gRPCServer, err = newGrpcServer(gRPCNetAddrs, &bchrpc.GrpcServerConfig{
 			TimeSource:  s.timeSource,
 			Chain:       s.chain,
@@ -3495,6 +4621,17 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string, db database
 			AddrIndex:   s.addrIndex,
 			CfIndex:     s.cfIndex,
 			SlpIndex:    s.slpIndex,
+			TokenIndex:  s.tokenIndex,
+			StatsIndex:  s.statsIndex,
+			WatchOnly:   watchOnly,
+			Bcmr:        bcmrResolver,
+			Rescanner:   rescanner,
+			HotWallet:   hotWallet,
+
+			CoinAgeIndex:  s.coinAgeIndex,
+			WatchSetIndex: s.watchSetIndex,
+
+			AllowAdminMethods: cfg.GrpcAuthToken != "" || cfg.GrpcClientCA != "",
 		}, &s)
 		if err != nil {
 			return nil, err
@@ -3511,9 +4648,10 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string, db database
 }
 
 // initListeners initializes the configured net listeners and adds any bound
-// addresses to the address manager. Returns the listeners and a NAT interface,
-// which is non-nil if UPnP is in use.
-func initListeners(amgr *addrmgr.AddrManager, listenAddrs []string, services wire.ServiceFlag) ([]net.Listener, NAT, error) {
+// addresses to the address manager. Returns the listeners and the NAT gateways
+// the listening port is to be mapped on, which are only discovered when
+// mapping is enabled.
+func initListeners(amgr *addrmgr.AddrManager, listenAddrs []string, services wire.ServiceFlag) ([]net.Listener, []nat.NAT, error) {
 	// Listen for TCP connections at the configured addresses
 	netAddrs, err := parseListeners(listenAddrs)
 	if err != nil {
@@ -3530,7 +4668,7 @@ func initListeners(amgr *addrmgr.AddrManager, listenAddrs []string, services wir
 		listeners = append(listeners, listener)
 	}
 
-	var nat NAT
+	var nats []nat.NAT
 	defaultPort, err := strconv.ParseUint(activeNetParams.DefaultPort, 10, 16)
 	if err != nil {
 		srvrLog.Errorf("Can not parse default port %s for active chain: %v",
@@ -3573,28 +4711,36 @@ func initListeners(amgr *addrmgr.AddrManager, listenAddrs []string, services wir
 		}
 	} else {
 		if cfg.Upnp {
-			var err error
-			nat, err = Discover()
+			// No gateway here is fine, just means no port mapping on
+			// the network.
+			gateway, err := nat.Discover()
 			if err != nil {
-				srvrLog.Warnf("Can't discover upnp: %v", err)
-			}
-			// nil nat here is fine, just means no upnp on network.
+				srvrLog.Warnf("Can't discover NAT gateway: %v", err)
+			} else {
+				srvrLog.Infof("Discovered %s gateway", gateway)
+				nats = append(nats, gateway)
 
-			// Found a valid external IP, make sure we use these details
-			// so peers get the correct IP information.
-			if nat != nil {
-				addr, err := nat.GetExternalAddress()
+				// Found a valid external IP, make sure we use these
+				// details so peers get the correct IP information.
+				addr, err := gateway.ExternalAddress()
 				if err == nil {
 					eport := uint16(defaultPort)
 					na, err := amgr.HostToNetAddress(addr.String(), eport, services)
-					if err == nil {
-						if addrMe == nil {
-							addrMe = na
-						}
+					if err == nil && addrMe == nil {
+						addrMe = na
 					}
-
 				}
 			}
+
+			// IPv6 gateways open pinholes in their firewall rather
+			// than translating addresses.
+			gateway, err = nat.DiscoverIPv6()
+			if err != nil {
+				srvrLog.Debugf("Can't discover IPv6 gateway: %v", err)
+			} else {
+				srvrLog.Infof("Discovered %s IPv6 gateway", gateway)
+				nats = append(nats, gateway)
+			}
 		}
 
 		// Add bound addresses to address manager to be advertised to peers.
@@ -3607,7 +4753,20 @@ func initListeners(amgr *addrmgr.AddrManager, listenAddrs []string, services wir
 		}
 	}
 
-	return listeners, nat, nil
+	return listeners, nats, nil
+}
+
+// isReachable returns whether the server is able to connect to the passed
+// address.  Connecting to I2P and CJDNS addresses is not supported, and Tor v3
+// addresses can only be reached through a proxy.
+func isReachable(na *wire.NetAddress) bool {
+	switch {
+	case addrmgr.IsI2P(na), addrmgr.IsCJDNS(na):
+		return false
+	case addrmgr.IsTorV3(na):
+		return !cfg.NoOnion && (cfg.Proxy != "" || cfg.OnionProxy != "")
+	}
+	return true
 }
 
 // addrStringToNetAddr takes an address in the form of 'host:port' and returns
@@ -3729,7 +4888,13 @@ func dynamicTickDuration(remaining time.Duration) time.Duration {
 // isWhitelisted returns whether the IP address is included in the whitelisted
 // networks and IPs.
 func isWhitelisted(addr net.Addr) bool {
-	if len(cfg.whitelists) == 0 {
+	return isInNetworks(addr, cfg.whitelists)
+}
+
+// isInNetworks returns whether the IP address is included in the passed
+// networks.
+func isInNetworks(addr net.Addr, networks []*net.IPNet) bool {
+	if len(networks) == 0 {
 		return false
 	}
 
@@ -3744,7 +4909,7 @@ func isWhitelisted(addr net.Addr) bool {
 		return false
 	}
 
-	for _, ipnet := range cfg.whitelists {
+	for _, ipnet := range networks {
 		if ipnet.Contains(ip) {
 			return true
 		}
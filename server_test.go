@@ -0,0 +1,104 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchlog"
+)
+
+// TestSendsCompactBlocks ensures compact blocks are only negotiated with peers
+// which support them when the node neither runs in blocks only nor in headers
+// only mode.
+func TestSendsCompactBlocks(t *testing.T) {
+	oldCfg := cfg
+	defer func() { cfg = oldCfg }()
+	blockchain.DisableLog()
+	defer blockchain.UseLogger(chanLog)
+
+	// newChain returns a chain which runs in headers only mode or not.
+	params := &chaincfg.RegressionNetParams
+	newChain := func(name string, headersOnly bool) *blockchain.BlockChain {
+		db, err := database.Create("ffldb",
+			filepath.Join(t.TempDir(), name), params.Net)
+		if err != nil {
+			t.Fatalf("failed to create db: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		chain, err := blockchain.New(&blockchain.Config{
+			DB:                 db,
+			ChainParams:        params,
+			TimeSource:         blockchain.NewMedianTime(),
+			ExcessiveBlockSize: 32000000,
+			HeadersOnly:        headersOnly,
+		})
+		if err != nil {
+			t.Fatalf("failed to create chain: %v", err)
+		}
+		return chain
+	}
+	fullChain := newChain("full", false)
+	headersChain := newChain("headers", true)
+
+	tests := []struct {
+		name       string
+		supported  bool
+		blocksOnly bool
+		chain      *blockchain.BlockChain
+		want       bool
+	}{
+		{"supported", true, false, fullChain, true},
+		{"unsupported", false, false, fullChain, false},
+		{"blocks only", true, true, fullChain, false},
+		{"headers only", true, false, headersChain, false},
+		{"unsupported headers only", false, false, headersChain, false},
+	}
+	for _, test := range tests {
+		cfg = &config{BlocksOnly: test.blocksOnly}
+		sp := &serverPeer{
+			server:                &server{chain: test.chain},
+			supportsCompactBlocks: test.supported,
+		}
+		if got := sp.sendsCompactBlocks(); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// TestWeakBlockQueue ensures a peer flooding weak blocks faster than they are
+// processed only gets maxQueuedWeakBlocks of them queued and the rest dropped.
+func TestWeakBlockQueue(t *testing.T) {
+	oldLog := peerLog
+	peerLog = bchlog.Disabled
+	defer func() { peerLog = oldLog }()
+
+	// Mark the weak block handler as started so nothing drains the queue.
+	sp := newServerPeer(&server{}, false)
+	sp.weakBlockHandler.Do(func() {})
+
+	const sent = maxQueuedWeakBlocks * 4
+	for i := 0; i < sent; i++ {
+		msg := &wire.MsgWeakBlock{Header: wire.BlockHeader{Nonce: uint32(i)}}
+		sp.OnWeakBlock(nil, msg)
+	}
+	if len(sp.weakBlocks) != maxQueuedWeakBlocks {
+		t.Fatalf("got %d queued weak blocks, want %d",
+			len(sp.weakBlocks), maxQueuedWeakBlocks)
+	}
+
+	// The first weak blocks are kept and the extras are dropped.
+	for i := 0; i < maxQueuedWeakBlocks; i++ {
+		msg := <-sp.weakBlocks
+		if msg.Header.Nonce != uint32(i) {
+			t.Fatalf("got queued weak block %d, want %d",
+				msg.Header.Nonce, i)
+		}
+	}
+	if len(sp.weakBlocks) != 0 {
+		t.Fatalf("got %d extra queued weak blocks", len(sp.weakBlocks))
+	}
+}
@@ -14,4 +14,5 @@ import (
 
 func init() {
 	interruptSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	reloadSignals = []os.Signal{syscall.SIGHUP}
 }
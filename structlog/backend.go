@@ -0,0 +1,373 @@
+package structlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gcash/bchlog"
+)
+
+// Format identifies the format of the log output.
+type Format uint32
+
+// These constants define the supported log output formats.
+const (
+	// FormatText writes each message as a line of text of the form
+	// 'YYYY-MM-DD hh:mm:ss.sss [LVL] TAG: message'.
+	FormatText Format = iota
+
+	// FormatJSON writes each message as a JSON record on a single line.
+	FormatJSON
+)
+
+// formatStrs maps the formats to the names they are parsed from.
+var formatStrs = [...]string{"text", "json"}
+
+// ParseFormat returns a format based on the input string s.  If the input
+// can't be interpreted as a valid format, the text format and false are
+// returned.
+func ParseFormat(s string) (Format, bool) {
+	for f, str := range formatStrs {
+		if strings.ToLower(s) == str {
+			return Format(f), true
+		}
+	}
+	return FormatText, false
+}
+
+// String returns the name of the format.
+func (f Format) String() string {
+	if int(f) >= len(formatStrs) {
+		return fmt.Sprintf("Unknown Format (%d)", uint32(f))
+	}
+	return formatStrs[f]
+}
+
+// levelStrs maps the log levels to the names used in the JSON records.
+var levelStrs = [...]string{"trace", "debug", "info", "warn", "error",
+	"critical", "off"}
+
+// record is a message in the JSON format.
+type record struct {
+	Time      string `json:"time"`
+	Level     string `json:"level"`
+	Subsystem string `json:"subsystem"`
+	Msg       string `json:"msg"`
+}
+
+// Backend is a logging backend.  Subsystem loggers created from the backend
+// write to the backend's Writer in its current format.  Backend provides
+// atomic writes to the Writer from all subsystems.
+type Backend struct {
+	// The following variables must only be used atomically.
+	format   uint32
+	sampling uint32
+
+	w    io.Writer
+	mtx  sync.Mutex // ensures atomic writes
+	text *bchlog.Backend
+	now  func() time.Time
+}
+
+// lockedWriter serializes the writes of the text backend with the writes of
+// the JSON records.
+type lockedWriter struct {
+	b *Backend
+}
+
+// Write writes p to the writer of the backend while holding its lock.
+func (w lockedWriter) Write(p []byte) (int, error) {
+	w.b.mtx.Lock()
+	defer w.b.mtx.Unlock()
+	return w.b.w.Write(p)
+}
+
+// NewBackend creates a logger backend from a Writer.  The backend writes text
+// and doesn't sample messages until configured otherwise.
+func NewBackend(w io.Writer) *Backend {
+	b := &Backend{w: w, now: time.Now}
+	b.text = bchlog.NewBackend(lockedWriter{b})
+	return b
+}
+
+// SetFormat changes the format of the messages written by all subsystem
+// loggers.
+//
+// This function is safe for concurrent access.
+func (b *Backend) SetFormat(format Format) {
+	atomic.StoreUint32(&b.format, uint32(format))
+}
+
+// SetSampling limits the number of trace, debug and info messages with the
+// same format each subsystem logger writes per second to the passed number.  A
+// limit of 0 disables sampling.
+//
+// This function is safe for concurrent access.
+func (b *Backend) SetSampling(limit uint32) {
+	atomic.StoreUint32(&b.sampling, limit)
+}
+
+// writeRecord writes the passed message as a JSON record.
+func (b *Backend) writeRecord(level bchlog.Level, tag, msg string) {
+	serialized, err := json.Marshal(&record{
+		Time:      b.now().Format(time.RFC3339Nano),
+		Level:     levelStrs[level],
+		Subsystem: tag,
+		Msg:       msg,
+	})
+	if err != nil {
+		return
+	}
+
+	b.mtx.Lock()
+	b.w.Write(append(serialized, '\n'))
+	b.mtx.Unlock()
+}
+
+// Logger returns a new logger for a particular subsystem that writes to the
+// Backend b.  A tag describes the subsystem and is included in all log
+// messages.  The logger uses the info verbosity level by default.
+func (b *Backend) Logger(subsystemTag string) bchlog.Logger {
+	text := b.text.Logger(subsystemTag)
+	text.SetLevel(bchlog.LevelTrace)
+	return &logger{
+		lvl:  uint32(bchlog.LevelInfo),
+		tag:  subsystemTag,
+		b:    b,
+		text: text,
+	}
+}
+
+// sample tracks how many messages with the same format a subsystem logger
+// logged in the current second.
+type sample struct {
+	level   bchlog.Level
+	logged  uint32
+	dropped uint32
+}
+
+// logger is a subsystem logger for a Backend.  Implements the bchlog.Logger
+// interface.
+type logger struct {
+	lvl  uint32 // atomic
+	tag  string
+	b    *Backend
+	text bchlog.Logger
+
+	// The following fields track the messages logged in the current
+	// second when sampling is enabled.
+	samplesMtx sync.Mutex
+	second     int64
+	samples    map[string]*sample
+}
+
+// enabled returns whether messages of the passed level are logged.
+func (l *logger) enabled(level bchlog.Level) bool {
+	return level >= l.Level()
+}
+
+// sampled returns whether a message of the passed level with the passed format
+// is within the sampling limit.  It reports the messages which were dropped in
+// the previous seconds before the first message of a new second is logged.
+func (l *logger) sampled(level bchlog.Level, format string) bool {
+	limit := atomic.LoadUint32(&l.b.sampling)
+	if limit == 0 || level >= bchlog.LevelWarn {
+		return true
+	}
+
+	l.samplesMtx.Lock()
+	var dropped map[string]*sample
+	second := l.b.now().Unix()
+	if second != l.second {
+		for format, s := range l.samples {
+			if s.dropped > 0 {
+				if dropped == nil {
+					dropped = make(map[string]*sample)
+				}
+				dropped[format] = s
+			}
+		}
+		l.samples = make(map[string]*sample)
+		l.second = second
+	}
+	s, ok := l.samples[format]
+	if !ok {
+		s = &sample{level: level}
+		l.samples[format] = s
+	}
+	allowed := s.logged < limit
+	if allowed {
+		s.logged++
+	} else {
+		s.dropped++
+	}
+	l.samplesMtx.Unlock()
+
+	for format, s := range dropped {
+		l.output(s.level, fmt.Sprintf("Dropped %d messages with the "+
+			"format %q by sampling", s.dropped, format))
+	}
+	return allowed
+}
+
+// output writes the passed message of the passed level in the current format
+// of the backend.
+func (l *logger) output(level bchlog.Level, msg string) {
+	if Format(atomic.LoadUint32(&l.b.format)) == FormatJSON {
+		l.b.writeRecord(level, l.tag, msg)
+		return
+	}
+
+	switch level {
+	case bchlog.LevelTrace:
+		l.text.Trace(msg)
+	case bchlog.LevelDebug:
+		l.text.Debug(msg)
+	case bchlog.LevelInfo:
+		l.text.Info(msg)
+	case bchlog.LevelWarn:
+		l.text.Warn(msg)
+	case bchlog.LevelError:
+		l.text.Error(msg)
+	case bchlog.LevelCritical:
+		l.text.Critical(msg)
+	}
+}
+
+// print logs the operands formatted using their default formats if the passed
+// level is enabled and the message is within the sampling limit.
+func (l *logger) print(level bchlog.Level, args ...interface{}) {
+	if !l.enabled(level) {
+		return
+	}
+	msg := strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+	if l.sampled(level, msg) {
+		l.output(level, msg)
+	}
+}
+
+// printf logs the parameters formatted according to the format specifier if
+// the passed level is enabled and the message is within the sampling limit.
+func (l *logger) printf(level bchlog.Level, format string, params ...interface{}) {
+	if !l.enabled(level) {
+		return
+	}
+	if l.sampled(level, format) {
+		l.output(level, fmt.Sprintf(format, params...))
+	}
+}
+
+// Trace formats message using the default formats for its operands and writes
+// to log with LevelTrace.
+//
+// This is part of the bchlog.Logger interface implementation.
+func (l *logger) Trace(args ...interface{}) {
+	l.print(bchlog.LevelTrace, args...)
+}
+
+// Tracef formats message according to format specifier and writes to log with
+// LevelTrace.
+//
+// This is part of the bchlog.Logger interface implementation.
+func (l *logger) Tracef(format string, params ...interface{}) {
+	l.printf(bchlog.LevelTrace, format, params...)
+}
+
+// Debug formats message using the default formats for its operands and writes
+// to log with LevelDebug.
+//
+// This is part of the bchlog.Logger interface implementation.
+func (l *logger) Debug(args ...interface{}) {
+	l.print(bchlog.LevelDebug, args...)
+}
+
+// Debugf formats message according to format specifier and writes to log with
+// LevelDebug.
+//
+// This is part of the bchlog.Logger interface implementation.
+func (l *logger) Debugf(format string, params ...interface{}) {
+	l.printf(bchlog.LevelDebug, format, params...)
+}
+
+// Info formats message using the default formats for its operands and writes
+// to log with LevelInfo.
+//
+// This is part of the bchlog.Logger interface implementation.
+func (l *logger) Info(args ...interface{}) {
+	l.print(bchlog.LevelInfo, args...)
+}
+
+// Infof formats message according to format specifier and writes to log with
+// LevelInfo.
+//
+// This is part of the bchlog.Logger interface implementation.
+func (l *logger) Infof(format string, params ...interface{}) {
+	l.printf(bchlog.LevelInfo, format, params...)
+}
+
+// Warn formats message using the default formats for its operands and writes
+// to log with LevelWarn.
+//
+// This is part of the bchlog.Logger interface implementation.
+func (l *logger) Warn(args ...interface{}) {
+	l.print(bchlog.LevelWarn, args...)
+}
+
+// Warnf formats message according to format specifier and writes to log with
+// LevelWarn.
+//
+// This is part of the bchlog.Logger interface implementation.
+func (l *logger) Warnf(format string, params ...interface{}) {
+	l.printf(bchlog.LevelWarn, format, params...)
+}
+
+// Error formats message using the default formats for its operands and writes
+// to log with LevelError.
+//
+// This is part of the bchlog.Logger interface implementation.
+func (l *logger) Error(args ...interface{}) {
+	l.print(bchlog.LevelError, args...)
+}
+
+// Errorf formats message according to format specifier and writes to log with
+// LevelError.
+//
+// This is part of the bchlog.Logger interface implementation.
+func (l *logger) Errorf(format string, params ...interface{}) {
+	l.printf(bchlog.LevelError, format, params...)
+}
+
+// Critical formats message using the default formats for its operands and
+// writes to log with LevelCritical.
+//
+// This is part of the bchlog.Logger interface implementation.
+func (l *logger) Critical(args ...interface{}) {
+	l.print(bchlog.LevelCritical, args...)
+}
+
+// Criticalf formats message according to format specifier and writes to log
+// with LevelCritical.
+//
+// This is part of the bchlog.Logger interface implementation.
+func (l *logger) Criticalf(format string, params ...interface{}) {
+	l.printf(bchlog.LevelCritical, format, params...)
+}
+
+// Level returns the current logging level.
+//
+// This is part of the bchlog.Logger interface implementation.
+func (l *logger) Level() bchlog.Level {
+	return bchlog.Level(atomic.LoadUint32(&l.lvl))
+}
+
+// SetLevel changes the logging level to the passed level.
+//
+// This is part of the bchlog.Logger interface implementation.
+func (l *logger) SetLevel(level bchlog.Level) {
+	atomic.StoreUint32(&l.lvl, uint32(level))
+}
@@ -0,0 +1,132 @@
+package structlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gcash/bchlog"
+)
+
+// TestParseFormat ensures the formats are parsed from their names.
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		str    string
+		format Format
+		ok     bool
+	}{
+		{"text", FormatText, true},
+		{"json", FormatJSON, true},
+		{"JSON", FormatJSON, true},
+		{"xml", FormatText, false},
+	}
+	for _, test := range tests {
+		format, ok := ParseFormat(test.str)
+		if format != test.format || ok != test.ok {
+			t.Errorf("ParseFormat(%q): got %v %v, want %v %v",
+				test.str, format, ok, test.format, test.ok)
+		}
+	}
+}
+
+// TestFormats ensures the messages are written in the format of the backend
+// and filtered by the level of their logger.
+func TestFormats(t *testing.T) {
+	var buf bytes.Buffer
+	b := NewBackend(&buf)
+	now := time.Date(2026, 10, 15, 14, 20, 5, 72000000, time.UTC)
+	b.now = func() time.Time { return now }
+	log := b.Logger("SRVR")
+
+	log.Debugf("hidden %d", 1)
+	log.Infof("shown %d", 2)
+	if !strings.HasSuffix(buf.String(), "[INF] SRVR: shown 2\n") {
+		t.Fatalf("unexpected text output %q", buf.String())
+	}
+
+	buf.Reset()
+	b.SetFormat(FormatJSON)
+	log.SetLevel(bchlog.LevelDebug)
+	log.Debug("peer", 3, "connected")
+	var got record
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("unable to decode %q: %v", buf.String(), err)
+	}
+	want := record{
+		Time:      "2026-10-15T14:20:05.072Z",
+		Level:     "debug",
+		Subsystem: "SRVR",
+		Msg:       "peer 3 connected",
+	}
+	if got != want {
+		t.Fatalf("unexpected record %+v, want %+v", got, want)
+	}
+}
+
+// TestSampling ensures trace, debug and info messages with the same format are
+// limited per second, warnings are never dropped and the dropped messages are
+// reported.
+func TestSampling(t *testing.T) {
+	var buf bytes.Buffer
+	b := NewBackend(&buf)
+	now := time.Unix(1792069598, 0)
+	b.now = func() time.Time { return now }
+	b.SetFormat(FormatJSON)
+	b.SetSampling(2)
+	log := b.Logger("PEER")
+
+	records := func() []record {
+		var records []record
+		dec := json.NewDecoder(&buf)
+		for dec.More() {
+			var r record
+			if err := dec.Decode(&r); err != nil {
+				t.Fatalf("unable to decode: %v", err)
+			}
+			records = append(records, r)
+		}
+		buf.Reset()
+		return records
+	}
+
+	for i := 0; i < 5; i++ {
+		log.Infof("received inv %d", i)
+		log.Warnf("misbehaving %d", i)
+	}
+	log.Infof("other")
+	var infos, warns int
+	for _, r := range records() {
+		switch r.Level {
+		case "info":
+			infos++
+		case "warn":
+			warns++
+		}
+	}
+	if infos != 3 || warns != 5 {
+		t.Fatalf("got %d info and %d warn records, want 3 and 5",
+			infos, warns)
+	}
+
+	// The dropped messages are reported in the next second.
+	now = now.Add(time.Second)
+	log.Infof("received inv %d", 5)
+	got := records()
+	if len(got) != 2 || got[0].Level != "info" ||
+		got[0].Msg != `Dropped 3 messages with the format "received inv %d" by sampling` ||
+		got[1].Msg != "received inv 5" {
+
+		t.Fatalf("unexpected records %+v", got)
+	}
+
+	// Nothing is dropped once sampling is disabled.
+	b.SetSampling(0)
+	for i := 0; i < 5; i++ {
+		log.Infof("received inv %d", i)
+	}
+	if got := records(); len(got) != 5 {
+		t.Fatalf("got %d records, want 5", len(got))
+	}
+}
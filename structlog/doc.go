@@ -0,0 +1,25 @@
+/*
+Package structlog implements a logging backend whose subsystem loggers satisfy
+the bchlog.Logger interface and write either the usual text lines or
+structured JSON records, so log aggregation systems can parse the output
+without knowing the text format.
+
+Each JSON record is written on a single line and holds the following fields:
+
+	time       the time the message was logged in RFC 3339 format with
+	           nanoseconds
+	level      trace, debug, info, warn, error or critical
+	subsystem  the tag of the subsystem which logged the message
+	msg        the message
+
+The output format may be changed at any time, which allows the loggers to be
+created before the configuration is loaded.  The level of each subsystem
+logger may be changed at any time as well.
+
+The backend may additionally sample messages which are logged at a high rate.
+When sampling is enabled, at most the configured number of trace, debug and
+info messages with the same format are logged per subsystem and second, while
+warnings and errors are never dropped.  The number of dropped messages is
+reported once the second is over and the subsystem logs again.
+*/
+package structlog
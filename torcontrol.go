@@ -0,0 +1,148 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gcash/bchd/addrmgr"
+	"github.com/gcash/bchd/connmgr"
+)
+
+const (
+	// onionKeyFilename is the name of the file in the data directory which
+	// stores the private key of the onion service so it keeps the same
+	// address across restarts.
+	onionKeyFilename = "onion_v3_private_key"
+
+	// torControlRetryInterval is the interval at which creating the onion
+	// service is retried after it failed or the connection to the Tor
+	// control port was lost.
+	torControlRetryInterval = time.Minute
+)
+
+// onionTargetAddr returns the address Tor forwards connections to the onion
+// service to, which is the address of the first listener with unspecified
+// addresses replaced by the loopback address.
+func onionTargetAddr(listeners []net.Listener) string {
+	if len(listeners) == 0 {
+		return ""
+	}
+	host, port, err := net.SplitHostPort(listeners[0].Addr().String())
+	if err != nil {
+		return ""
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// isOnionInbound returns whether the passed peer is an inbound peer which
+// connected through the onion service.  Tor forwards all of these connections
+// from the loopback address, so they must not be limited or banned by their
+// address.
+func (s *server) isOnionInbound(sp *serverPeer) bool {
+	if s.onionTarget == "" || !sp.Inbound() {
+		return false
+	}
+	host, _, err := net.SplitHostPort(sp.Addr())
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// createOnionService creates the onion service through the Tor control port
+// and adds its address to the local addresses advertised to peers.  The
+// private key of the service is loaded from the data directory when it exists,
+// or saved there when Tor creates a new one.  The service is removed by Tor
+// once the returned controller is closed.
+func (s *server) createOnionService() (*connmgr.TorController, error) {
+	ctrl, err := connmgr.DialTorControl(cfg.TorControl)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctrl.Authenticate(cfg.TorPassword); err != nil {
+		ctrl.Close()
+		return nil, err
+	}
+
+	keyFile := filepath.Join(cfg.DataDir, onionKeyFilename)
+	var privateKey string
+	key, err := ioutil.ReadFile(keyFile)
+	if err == nil {
+		privateKey = strings.TrimSpace(string(key))
+	} else if !os.IsNotExist(err) {
+		srvrLog.Warnf("Unable to read onion service key: %v", err)
+	}
+
+	port, err := strconv.ParseUint(activeNetParams.DefaultPort, 10, 16)
+	if err != nil {
+		ctrl.Close()
+		return nil, err
+	}
+	host, newKey, err := ctrl.AddOnion(privateKey, uint16(port),
+		s.onionTarget)
+	if err != nil {
+		ctrl.Close()
+		return nil, err
+	}
+	if newKey != privateKey {
+		err := ioutil.WriteFile(keyFile, []byte(newKey+"\n"), 0600)
+		if err != nil {
+			srvrLog.Warnf("Unable to save onion service key: %v", err)
+		}
+	}
+
+	srvrLog.Infof("Onion service available at %s",
+		net.JoinHostPort(host, activeNetParams.DefaultPort))
+	na, err := s.addrManager.HostToNetAddress(host, uint16(port), s.services)
+	if err == nil {
+		err = s.addrManager.AddLocalAddress(na, addrmgr.ManualPrio)
+	}
+	if err != nil {
+		srvrLog.Warnf("Unable to advertise onion service address: %v", err)
+	}
+	return ctrl, nil
+}
+
+// onionServiceHandler creates the onion service and keeps the connection to
+// the Tor control port open for as long as the server runs, recreating the
+// service when the connection is lost.  It must be run as a goroutine.
+func (s *server) onionServiceHandler() {
+	defer s.wg.Done()
+
+	for {
+		ctrl, err := s.createOnionService()
+		if err != nil {
+			srvrLog.Warnf("Unable to create onion service using the "+
+				"Tor control port %s: %v", cfg.TorControl, err)
+		} else {
+			done := make(chan error, 1)
+			go func() {
+				done <- ctrl.Wait()
+			}()
+			select {
+			case err := <-done:
+				srvrLog.Warnf("Lost connection to the Tor control "+
+					"port: %v", err)
+				ctrl.Close()
+			case <-s.quit:
+				ctrl.Close()
+				return
+			}
+		}
+
+		select {
+		case <-time.After(torControlRetryInterval):
+		case <-s.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,524 @@
+package txbuilder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/gcash/bchd/mempool"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil/txsort"
+)
+
+const (
+	// DefaultBytesPerSigCheck is the default number of bytes each
+	// signature check of a transaction is accounted for with when
+	// calculating its fee.  A transaction whose size is smaller than its
+	// number of signature checks times this value pays the fee of a
+	// transaction of that size instead.
+	DefaultBytesPerSigCheck = 50
+
+	// txInOverheadSize is the serialized size of an input excluding the
+	// signature script and its length.  It is the size of the outpoint and
+	// the sequence number.
+	//
+	//   32 + 4 + 4 = 40
+	txInOverheadSize = 40
+
+	// sigPushSize is the size of the push of a signature in a signature
+	// script.  It assumes a DER encoded ECDSA signature of the maximum
+	// size followed by the sighash type, which is larger than a Schnorr
+	// signature.
+	//
+	//   1 + 72 + 1 = 74
+	sigPushSize = 74
+
+	// pubKeyPushSize is the size of the push of a compressed public key in
+	// a signature script.
+	//
+	//   1 + 33 = 34
+	pubKeyPushSize = 34
+)
+
+var (
+	// ErrInsufficientFunds is returned when the provided unspent outputs
+	// are not enough to pay for the requested outputs and fee.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+
+	// ErrInsufficientTokens is returned when the provided unspent outputs
+	// do not carry the tokens needed by the requested outputs.
+	ErrInsufficientTokens = errors.New("insufficient tokens")
+)
+
+// Utxo is an unspent output which may be spent by a built transaction.
+type Utxo struct {
+	// OutPoint is the outpoint of the unspent output.
+	OutPoint wire.OutPoint
+
+	// Output is the unspent output along with the tokens it carries.
+	Output wire.TxOut
+
+	// SigScriptSize is the size of the signature script which spends the
+	// output.  When it is zero, it is estimated from the public key script
+	// of the output, which is only possible for pay-to-pubkey,
+	// pay-to-pubkey-hash, and bare multisig scripts.
+	SigScriptSize int
+
+	// SigChecks is the number of signature checks performed when spending
+	// the output.  It is estimated along with the size of the signature
+	// script when SigScriptSize is zero.
+	SigChecks int
+}
+
+// estimateSpend returns the serialized size of the input which spends the
+// unspent output and the number of signature checks it performs.
+func (u *Utxo) estimateSpend() (int, int, error) {
+	sigScriptSize, sigChecks := u.SigScriptSize, u.SigChecks
+	if sigScriptSize == 0 {
+		pkScript := u.Output.PkScript
+		switch class := txscript.GetScriptClass(pkScript); class {
+		case txscript.PubKeyHashTy:
+			sigScriptSize, sigChecks = sigPushSize+pubKeyPushSize, 1
+
+		case txscript.PubKeyTy:
+			sigScriptSize, sigChecks = sigPushSize, 1
+
+		case txscript.MultiSigTy:
+			// The signatures are preceded by the dummy element
+			// which is consumed by OP_CHECKMULTISIG.  Every public
+			// key is checked when the signatures are ECDSA ones.
+			numPubKeys, numSigs, err := txscript.CalcMultiSigStats(pkScript)
+			if err != nil {
+				return 0, 0, err
+			}
+			sigScriptSize, sigChecks = 1+numSigs*sigPushSize, numPubKeys
+
+		default:
+			return 0, 0, fmt.Errorf("unable to estimate the size of "+
+				"the signature script spending output %v with a "+
+				"%v script", u.OutPoint, class)
+		}
+	}
+	size := txInOverheadSize + wire.VarIntSerializeSize(uint64(sigScriptSize)) +
+		sigScriptSize
+	return size, sigChecks, nil
+}
+
+// Request describes the transaction to build.
+type Request struct {
+	// Utxos are the unspent outputs which may be spent by the
+	// transaction.
+	Utxos []*Utxo
+
+	// Outputs are the outputs the transaction pays, which may carry
+	// tokens.
+	Outputs []*wire.TxOut
+
+	// ChangeScript is the public key script which change and the tokens
+	// of the spent outputs which are not paid to the outputs are paid to.
+	ChangeScript []byte
+
+	// FeePerKb is the fee rate in satoshi per kilobyte.  It defaults to
+	// the default minimum relay fee when zero.
+	FeePerKb int64
+
+	// BytesPerSigCheck is the number of bytes each signature check is
+	// accounted for with when calculating the fee.  It defaults to
+	// DefaultBytesPerSigCheck when zero.
+	BytesPerSigCheck int
+}
+
+// UnsignedTransaction is a built transaction along with the information needed
+// to sign it.
+type UnsignedTransaction struct {
+	Tx *wire.MsgTx
+
+	// Inputs contains the outputs spent by the transaction in the order of
+	// the inputs which spend them.  Their amounts, scripts, and tokens are
+	// needed to sign the inputs.
+	Inputs []*Utxo
+
+	// Fee is the fee paid by the transaction.
+	Fee int64
+
+	// Size is the estimated serialized size of the signed transaction.
+	Size int
+
+	// SigChecks is the estimated number of signature checks performed by
+	// the signed transaction.
+	SigChecks int
+
+	// ChangeIndex is the index of the change output or -1 when the
+	// transaction does not have one.
+	ChangeIndex int
+
+	// TokenChangeIndexes are the indexes of the outputs which return the
+	// tokens of the spent outputs which are not paid to the requested
+	// outputs.
+	TokenChangeIndexes []int
+}
+
+// builder houses the state needed while building a transaction.
+type builder struct {
+	feePerKb         int64
+	bytesPerSigCheck int
+	changeScript     []byte
+
+	// tx is the transaction being built.  Its inputs are only added once
+	// the coins have been selected.
+	tx *wire.MsgTx
+
+	// selected are the unspent outputs selected to be spent along with
+	// the total size of the inputs spending them and the number of
+	// signature checks they perform.
+	selected   []*Utxo
+	inputsSize int
+	sigChecks  int
+	total      int64
+}
+
+// dustThreshold returns the smallest amount the passed output may pay without
+// being considered dust by the default relay policy.
+func dustThreshold(txOut *wire.TxOut) int64 {
+	// An output is dust when it costs more than a third of its value to
+	// spend it at the minimum relay fee, which assumes an input size of
+	// 148 bytes.  See isDust in the mempool package.
+	totalSize := int64(txOut.SerializeSize() + 148)
+	return (3*totalSize*int64(mempool.DefaultMinRelayTxFee) + 999) / 1000
+}
+
+// selectUtxo adds the passed unspent output to the selected ones.
+func (b *builder) selectUtxo(u *Utxo) error {
+	size, sigChecks, err := u.estimateSpend()
+	if err != nil {
+		return err
+	}
+	b.selected = append(b.selected, u)
+	b.inputsSize += size
+	b.sigChecks += sigChecks
+	b.total += u.Output.Value
+	return nil
+}
+
+// estimateSize returns the estimated serialized size of the transaction once it
+// spends the selected outputs and, when change is not nil, has the additional
+// change output.
+func (b *builder) estimateSize(change *wire.TxOut) int {
+	size := b.tx.SerializeSize() - wire.VarIntSerializeSize(uint64(len(b.tx.TxIn))) +
+		wire.VarIntSerializeSize(uint64(len(b.selected))) + b.inputsSize
+	if change != nil {
+		size += change.SerializeSize() -
+			wire.VarIntSerializeSize(uint64(len(b.tx.TxOut))) +
+			wire.VarIntSerializeSize(uint64(len(b.tx.TxOut)+1))
+	}
+	return size
+}
+
+// calcFee returns the fee of a transaction with the passed serialized size
+// which performs the passed number of signature checks.
+func (b *builder) calcFee(size, sigChecks int) int64 {
+	if sigCheckSize := sigChecks * b.bytesPerSigCheck; sigCheckSize > size {
+		size = sigCheckSize
+	}
+	return int64(size) * b.feePerKb / 1000
+}
+
+// selectTokens selects the unspent outputs carrying the tokens needed by the
+// outputs of the transaction and adds outputs which return the tokens of the
+// selected outputs which are not paid to them to the change script.  It returns
+// the added outputs.
+func (b *builder) selectTokens(utxos []*Utxo) ([]*wire.TxOut, error) {
+	// Spend the outputs with the largest fungible token amounts first.
+	var tokenUtxos []*Utxo
+	for _, u := range utxos {
+		if !u.Output.TokenData.IsEmpty() {
+			tokenUtxos = append(tokenUtxos, u)
+		}
+	}
+	sort.SliceStable(tokenUtxos, func(i, j int) bool {
+		return tokenUtxos[i].Output.TokenData.Amount >
+			tokenUtxos[j].Output.TokenData.Amount
+	})
+
+	selected := make(map[*Utxo]bool)
+	spentNFTs := make(map[*Utxo]bool)
+	var selectedTokens []*Utxo
+	selectToken := func(u *Utxo) {
+		if !selected[u] {
+			selected[u] = true
+			selectedTokens = append(selectedTokens, u)
+		}
+	}
+
+	// Find the non-fungible token each output with one is created from.
+	// Identical tokens are preferred, then minting tokens, which are not
+	// used up by minting, and finally mutable tokens, which are.
+	needed := make(map[[32]byte]uint64)
+	for _, txOut := range b.tx.TxOut {
+		token := &txOut.TokenData
+		if token.IsEmpty() {
+			continue
+		}
+		if token.HasAmount() {
+			needed[token.CategoryID] += token.Amount
+		}
+		if !token.HasNFT() {
+			continue
+		}
+
+		var source *Utxo
+		for _, u := range tokenUtxos {
+			t := &u.Output.TokenData
+			if !spentNFTs[u] && t.HasNFT() && t.CategoryID == token.CategoryID &&
+				t.GetCapability() == token.GetCapability() &&
+				bytes.Equal(t.Commitment, token.Commitment) {
+
+				source = u
+				spentNFTs[u] = true
+				break
+			}
+		}
+		for _, u := range tokenUtxos {
+			t := &u.Output.TokenData
+			if source == nil && t.IsMintingNFT() && t.CategoryID == token.CategoryID {
+				source = u
+			}
+		}
+		for _, u := range tokenUtxos {
+			t := &u.Output.TokenData
+			if source == nil && !spentNFTs[u] && t.IsMutableNFT() &&
+				t.CategoryID == token.CategoryID && !token.IsMintingNFT() {
+
+				source = u
+				spentNFTs[u] = true
+			}
+		}
+		if source == nil {
+			return nil, fmt.Errorf("%w: no spendable token of "+
+				"category %x can create a non-fungible token with "+
+				"commitment %x", ErrInsufficientTokens,
+				token.CategoryID, token.Commitment)
+		}
+		selectToken(source)
+	}
+
+	// Select outputs carrying fungible tokens of each category until they
+	// cover the amounts paid to the outputs.
+	available := make(map[[32]byte]uint64)
+	for _, u := range selectedTokens {
+		t := &u.Output.TokenData
+		available[t.CategoryID] += t.Amount
+	}
+	for _, u := range tokenUtxos {
+		t := &u.Output.TokenData
+		if !selected[u] && t.HasAmount() && available[t.CategoryID] < needed[t.CategoryID] {
+			selectToken(u)
+			available[t.CategoryID] += t.Amount
+		}
+	}
+	for category, amount := range needed {
+		if available[category] < amount {
+			return nil, fmt.Errorf("%w: %d fungible tokens of category "+
+				"%x are needed but only %d are spendable",
+				ErrInsufficientTokens, amount, category,
+				available[category])
+		}
+	}
+
+	// Return the non-fungible tokens which are not spent by the outputs
+	// and the remaining fungible tokens to the change script.  The
+	// remaining fungible tokens of a category are added to a returned
+	// non-fungible token of the category when there is one.
+	var tokenChange []*wire.TxOut
+	changeByCategory := make(map[[32]byte]*wire.TxOut)
+	for _, u := range selectedTokens {
+		t := &u.Output.TokenData
+		if !t.HasNFT() || spentNFTs[u] {
+			continue
+		}
+		token := wire.TokenData{
+			CategoryID: t.CategoryID,
+			Commitment: t.Commitment,
+			BitField:   t.BitField &^ wire.HAS_AMOUNT,
+		}
+		txOut := wire.NewTxOut(0, b.changeScript, token)
+		tokenChange = append(tokenChange, txOut)
+		if _, ok := changeByCategory[t.CategoryID]; !ok {
+			changeByCategory[t.CategoryID] = txOut
+		}
+	}
+	for _, u := range selectedTokens {
+		category := u.Output.TokenData.CategoryID
+		remaining := available[category] - needed[category]
+		if remaining == 0 {
+			continue
+		}
+		available[category] = needed[category]
+
+		txOut, ok := changeByCategory[category]
+		if !ok {
+			txOut = wire.NewTxOut(0, b.changeScript, wire.TokenData{
+				CategoryID: category,
+			})
+			tokenChange = append(tokenChange, txOut)
+		}
+		txOut.TokenData.Amount = remaining
+		txOut.TokenData.BitField |= wire.HAS_AMOUNT
+	}
+
+	// Every output carrying tokens must not be dust either.
+	for _, txOut := range tokenChange {
+		txOut.Value = dustThreshold(txOut)
+		b.tx.AddTxOut(txOut)
+	}
+	for _, u := range selectedTokens {
+		if err := b.selectUtxo(u); err != nil {
+			return nil, err
+		}
+	}
+	return tokenChange, nil
+}
+
+// Build builds an unsigned transaction as described by the passed request.
+//
+// The outputs which carry tokens are only spent when the requested outputs
+// need their tokens, in which case the tokens they carry beyond that are paid
+// to the change script.  The remaining value is paid for by the outputs which
+// do not carry tokens, largest first, and change which is not dust is paid to
+// the change script.  The inputs and outputs of the transaction are sorted as
+// described by BIP0069.
+func Build(req *Request) (*UnsignedTransaction, error) {
+	if len(req.Outputs) == 0 {
+		return nil, errors.New("no outputs specified")
+	}
+	if len(req.ChangeScript) == 0 {
+		return nil, errors.New("no change script specified")
+	}
+	b := &builder{
+		feePerKb:         req.FeePerKb,
+		bytesPerSigCheck: req.BytesPerSigCheck,
+		changeScript:     req.ChangeScript,
+		tx:               wire.NewMsgTx(wire.TxVersion),
+	}
+	if b.feePerKb == 0 {
+		b.feePerKb = int64(mempool.DefaultMinRelayTxFee)
+	}
+	if b.feePerKb < 0 {
+		return nil, fmt.Errorf("fee rate of %d is negative", b.feePerKb)
+	}
+	if b.bytesPerSigCheck == 0 {
+		b.bytesPerSigCheck = DefaultBytesPerSigCheck
+	}
+
+	var target int64
+	for _, txOut := range req.Outputs {
+		if txOut.Value <= 0 {
+			return nil, fmt.Errorf("output amount of %d is not "+
+				"positive", txOut.Value)
+		}
+		if !txOut.TokenData.IsEmpty() && !txOut.TokenData.IsValidBitfield() {
+			return nil, fmt.Errorf("output token of category %x is "+
+				"invalid", txOut.TokenData.CategoryID)
+		}
+		target += txOut.Value
+		b.tx.AddTxOut(wire.NewTxOut(txOut.Value, txOut.PkScript,
+			txOut.TokenData))
+	}
+
+	tokenChange, err := b.selectTokens(req.Utxos)
+	if err != nil {
+		return nil, err
+	}
+	for _, txOut := range tokenChange {
+		target += txOut.Value
+	}
+
+	// Select the outputs without tokens largest first until they pay for
+	// the outputs and the fee, which grows with every selected output.
+	var candidates []*Utxo
+	for _, u := range req.Utxos {
+		if u.Output.TokenData.IsEmpty() {
+			candidates = append(candidates, u)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Output.Value > candidates[j].Output.Value
+	})
+	var fee int64
+	for next := 0; ; next++ {
+		fee = b.calcFee(b.estimateSize(nil), b.sigChecks)
+		if b.total >= target+fee {
+			break
+		}
+		if next == len(candidates) {
+			return nil, ErrInsufficientFunds
+		}
+		if err := b.selectUtxo(candidates[next]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Add a change output when the remainder after paying for it is not
+	// dust, or add the remainder to the fee otherwise.
+	change := wire.NewTxOut(0, b.changeScript, wire.TokenData{})
+	feeWithChange := b.calcFee(b.estimateSize(change), b.sigChecks)
+	change.Value = b.total - target - feeWithChange
+	if change.Value >= dustThreshold(change) {
+		fee = feeWithChange
+		b.tx.AddTxOut(change)
+	} else {
+		fee = b.total - target
+		change = nil
+	}
+	size := b.estimateSize(nil)
+
+	for _, u := range b.selected {
+		op := u.OutPoint
+		b.tx.AddTxIn(wire.NewTxIn(&op, nil))
+	}
+	txsort.InPlaceSort(b.tx)
+
+	spent := make(map[wire.OutPoint]*Utxo, len(b.selected))
+	for _, u := range b.selected {
+		spent[u.OutPoint] = u
+	}
+	if len(spent) != len(b.selected) {
+		return nil, errors.New("duplicate unspent outputs specified")
+	}
+	unsigned := &UnsignedTransaction{
+		Tx:          b.tx,
+		Inputs:      make([]*Utxo, 0, len(b.tx.TxIn)),
+		Fee:         fee,
+		Size:        size,
+		SigChecks:   b.sigChecks,
+		ChangeIndex: -1,
+	}
+	utxoCache := txscript.NewUtxoCache()
+	for i, txIn := range b.tx.TxIn {
+		u := spent[txIn.PreviousOutPoint]
+		unsigned.Inputs = append(unsigned.Inputs, u)
+		utxoCache.AddEntry(i, u.Output)
+	}
+	isTokenChange := make(map[*wire.TxOut]bool, len(tokenChange))
+	for _, txOut := range tokenChange {
+		isTokenChange[txOut] = true
+	}
+	for i, txOut := range b.tx.TxOut {
+		switch {
+		case txOut == change:
+			unsigned.ChangeIndex = i
+		case isTokenChange[txOut]:
+			unsigned.TokenChangeIndexes = append(unsigned.TokenChangeIndexes, i)
+		}
+	}
+
+	// Make sure the transaction does not violate the token rules as a
+	// final sanity check.
+	if _, err := wire.RunCashTokensValidityAlgorithm(utxoCache, b.tx); err != nil {
+		return nil, fmt.Errorf("built transaction violates the token "+
+			"rules: %v", err)
+	}
+	return unsigned, nil
+}
@@ -0,0 +1,351 @@
+package txbuilder
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// payToPubKeyHash returns a pay-to-pubkey-hash script for a hash filled with
+// the passed byte.
+func payToPubKeyHash(t *testing.T, b byte) []byte {
+	var hash [20]byte
+	hash[0] = b
+	addr, err := bchutil.NewAddressPubKeyHash(hash[:], &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create script: %v", err)
+	}
+	return pkScript
+}
+
+// newUtxo returns an unspent pay-to-pubkey-hash output with the passed index,
+// value, and tokens.
+func newUtxo(t *testing.T, index uint32, value int64, token wire.TokenData) *Utxo {
+	return &Utxo{
+		OutPoint: wire.OutPoint{Hash: chainhash.Hash{0x01}, Index: index},
+		Output:   *wire.NewTxOut(value, payToPubKeyHash(t, 0x01), token),
+	}
+}
+
+// tokenTotals returns the fungible token amounts of the passed outputs and the
+// non-fungible tokens they carry by category.
+func tokenTotals(txOuts []*wire.TxOut) (map[[32]byte]uint64, map[[32]byte][]string) {
+	amounts := make(map[[32]byte]uint64)
+	nfts := make(map[[32]byte][]string)
+	for _, txOut := range txOuts {
+		token := &txOut.TokenData
+		if token.IsEmpty() {
+			continue
+		}
+		amounts[token.CategoryID] += token.Amount
+		if token.HasNFT() {
+			nfts[token.CategoryID] = append(nfts[token.CategoryID],
+				string(append([]byte{token.GetCapability()}, token.Commitment...)))
+		}
+	}
+	return amounts, nfts
+}
+
+// TestBuild ensures transactions are built with the expected inputs, change,
+// and fee.
+func TestBuild(t *testing.T) {
+	pkScript := payToPubKeyHash(t, 0x02)
+	changeScript := payToPubKeyHash(t, 0x03)
+
+	tests := []struct {
+		name      string
+		utxos     []*Utxo
+		send      int64
+		numInputs int
+		change    bool
+		err       error
+	}{
+		{
+			name:      "single input with change",
+			utxos:     []*Utxo{newUtxo(t, 0, 20000, wire.TokenData{}), newUtxo(t, 1, 100000, wire.TokenData{})},
+			send:      50000,
+			numInputs: 1,
+			change:    true,
+		},
+		{
+			name:      "multiple inputs with change",
+			utxos:     []*Utxo{newUtxo(t, 0, 20000, wire.TokenData{}), newUtxo(t, 1, 100000, wire.TokenData{})},
+			send:      110000,
+			numInputs: 2,
+			change:    true,
+		},
+		{
+			name:      "dust change added to fee",
+			utxos:     []*Utxo{newUtxo(t, 0, 100000, wire.TokenData{})},
+			send:      99700,
+			numInputs: 1,
+			change:    false,
+		},
+		{
+			name:  "insufficient funds",
+			utxos: []*Utxo{newUtxo(t, 0, 20000, wire.TokenData{}), newUtxo(t, 1, 100000, wire.TokenData{})},
+			send:  120000,
+			err:   ErrInsufficientFunds,
+		},
+	}
+
+	for _, test := range tests {
+		unsigned, err := Build(&Request{
+			Utxos:        test.utxos,
+			Outputs:      []*wire.TxOut{wire.NewTxOut(test.send, pkScript, wire.TokenData{})},
+			ChangeScript: changeScript,
+			FeePerKb:     1000,
+		})
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: unexpected error -- got %v, want %v",
+				test.name, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		tx := unsigned.Tx
+		if len(tx.TxIn) != test.numInputs || len(unsigned.Inputs) != test.numInputs {
+			t.Errorf("%s: unexpected number of inputs -- got %d, "+
+				"want %d", test.name, len(tx.TxIn), test.numInputs)
+			continue
+		}
+		if (unsigned.ChangeIndex != -1) != test.change {
+			t.Errorf("%s: unexpected change index %d", test.name,
+				unsigned.ChangeIndex)
+			continue
+		}
+
+		// The inputs must pay exactly for the outputs and fee, and the
+		// fee must cover the estimated size.
+		var in, out int64
+		for i, txIn := range tx.TxIn {
+			if unsigned.Inputs[i].OutPoint != txIn.PreviousOutPoint {
+				t.Errorf("%s: input %d does not match its spent "+
+					"output", test.name, i)
+			}
+			in += unsigned.Inputs[i].Output.Value
+		}
+		for _, txOut := range tx.TxOut {
+			out += txOut.Value
+		}
+		if in-out != unsigned.Fee {
+			t.Errorf("%s: unexpected fee -- got %d, want %d",
+				test.name, unsigned.Fee, in-out)
+		}
+		if unsigned.Fee < int64(unsigned.Size) {
+			t.Errorf("%s: fee of %d does not pay for size %d",
+				test.name, unsigned.Fee, unsigned.Size)
+		}
+		if unsigned.SigChecks != test.numInputs {
+			t.Errorf("%s: unexpected sig checks -- got %d, want %d",
+				test.name, unsigned.SigChecks, test.numInputs)
+		}
+	}
+}
+
+// TestBuildSigChecks ensures the fee of transactions performing many signature
+// checks relative to their size accounts for them.
+func TestBuildSigChecks(t *testing.T) {
+	utxo := newUtxo(t, 0, 100000, wire.TokenData{})
+	utxo.SigScriptSize = 100
+	utxo.SigChecks = 40
+
+	unsigned, err := Build(&Request{
+		Utxos:        []*Utxo{utxo},
+		Outputs:      []*wire.TxOut{wire.NewTxOut(50000, payToPubKeyHash(t, 0x02), wire.TokenData{})},
+		ChangeScript: payToPubKeyHash(t, 0x03),
+		FeePerKb:     1000,
+	})
+	if err != nil {
+		t.Fatalf("Build: unexpected error: %v", err)
+	}
+	if unsigned.SigChecks != 40 {
+		t.Fatalf("unexpected sig checks -- got %d, want 40",
+			unsigned.SigChecks)
+	}
+	if want := int64(40 * DefaultBytesPerSigCheck); unsigned.Fee != want {
+		t.Fatalf("unexpected fee -- got %d, want %d", unsigned.Fee, want)
+	}
+}
+
+// TestBuildTokens ensures the outputs carrying tokens are only spent when their
+// tokens are needed and that the tokens which are not paid to the requested
+// outputs are returned to the change script.
+func TestBuildTokens(t *testing.T) {
+	pkScript := payToPubKeyHash(t, 0x02)
+	changeScript := payToPubKeyHash(t, 0x03)
+	categoryA := [32]byte{0xaa}
+	categoryB := [32]byte{0xbb}
+
+	fungible := func(category [32]byte, amount uint64) wire.TokenData {
+		return wire.TokenData{CategoryID: category, Amount: amount,
+			BitField: wire.HAS_AMOUNT}
+	}
+	nft := func(category [32]byte, capability byte, commitment []byte) wire.TokenData {
+		token := wire.TokenData{CategoryID: category, Commitment: commitment,
+			BitField: wire.HAS_NFT | capability}
+		if len(commitment) > 0 {
+			token.BitField |= wire.HAS_COMMITMENT_LENGTH
+		}
+		return token
+	}
+
+	tests := []struct {
+		name        string
+		utxos       []wire.TokenData
+		outputs     []wire.TokenData
+		numInputs   int
+		tokenChange int
+		err         error
+	}{
+		{
+			name:      "tokens not needed",
+			utxos:     []wire.TokenData{fungible(categoryA, 100), {}},
+			outputs:   []wire.TokenData{{}},
+			numInputs: 1,
+		},
+		{
+			name:        "fungible tokens with change",
+			utxos:       []wire.TokenData{fungible(categoryA, 100), fungible(categoryA, 50), {}},
+			outputs:     []wire.TokenData{fungible(categoryA, 120)},
+			numInputs:   3,
+			tokenChange: 1,
+		},
+		{
+			name:      "exact fungible tokens",
+			utxos:     []wire.TokenData{fungible(categoryA, 100), fungible(categoryB, 50), {}},
+			outputs:   []wire.TokenData{fungible(categoryA, 100)},
+			numInputs: 2,
+		},
+		{
+			name:      "transfer nft",
+			utxos:     []wire.TokenData{nft(categoryA, wire.NONE, []byte{0x01}), {}},
+			outputs:   []wire.TokenData{nft(categoryA, wire.NONE, []byte{0x01})},
+			numInputs: 2,
+		},
+		{
+			name:        "mint nft keeps minting token",
+			utxos:       []wire.TokenData{nft(categoryA, wire.MINTING, nil), {}},
+			outputs:     []wire.TokenData{nft(categoryA, wire.NONE, []byte{0x01}), nft(categoryA, wire.NONE, []byte{0x02})},
+			numInputs:   2,
+			tokenChange: 1,
+		},
+		{
+			name:      "modify mutable nft",
+			utxos:     []wire.TokenData{nft(categoryA, wire.MUTABLE, []byte{0x01}), {}},
+			outputs:   []wire.TokenData{nft(categoryA, wire.MUTABLE, []byte{0x02})},
+			numInputs: 2,
+		},
+		{
+			name: "fungible change joins nft change",
+			utxos: []wire.TokenData{func() wire.TokenData {
+				token := nft(categoryA, wire.MINTING, nil)
+				token.Amount = 100
+				token.BitField |= wire.HAS_AMOUNT
+				return token
+			}(), {}},
+			outputs:     []wire.TokenData{fungible(categoryA, 10)},
+			numInputs:   2,
+			tokenChange: 1,
+		},
+		{
+			name:    "insufficient fungible tokens",
+			utxos:   []wire.TokenData{fungible(categoryA, 100), {}},
+			outputs: []wire.TokenData{fungible(categoryA, 101)},
+			err:     ErrInsufficientTokens,
+		},
+		{
+			name:    "immutable nft can not be modified",
+			utxos:   []wire.TokenData{nft(categoryA, wire.NONE, []byte{0x01}), {}},
+			outputs: []wire.TokenData{nft(categoryA, wire.NONE, []byte{0x02})},
+			err:     ErrInsufficientTokens,
+		},
+		{
+			name:    "mutable nft can not mint",
+			utxos:   []wire.TokenData{nft(categoryA, wire.MUTABLE, nil), {}},
+			outputs: []wire.TokenData{nft(categoryA, wire.MINTING, nil)},
+			err:     ErrInsufficientTokens,
+		},
+	}
+
+	for _, test := range tests {
+		var utxos []*Utxo
+		for i, token := range test.utxos {
+			value := int64(1000)
+			if token.IsEmpty() {
+				value = 100000
+			}
+			utxos = append(utxos, newUtxo(t, uint32(i), value, token))
+		}
+		var outputs []*wire.TxOut
+		for _, token := range test.outputs {
+			outputs = append(outputs, wire.NewTxOut(1000, pkScript, token))
+		}
+
+		unsigned, err := Build(&Request{
+			Utxos:        utxos,
+			Outputs:      outputs,
+			ChangeScript: changeScript,
+		})
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: unexpected error -- got %v, want %v",
+				test.name, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		if len(unsigned.Inputs) != test.numInputs {
+			t.Errorf("%s: unexpected number of inputs -- got %d, "+
+				"want %d", test.name, len(unsigned.Inputs),
+				test.numInputs)
+			continue
+		}
+		if len(unsigned.TokenChangeIndexes) != test.tokenChange {
+			t.Errorf("%s: unexpected number of token change outputs "+
+				"-- got %d, want %d", test.name,
+				len(unsigned.TokenChangeIndexes), test.tokenChange)
+			continue
+		}
+
+		// No fungible tokens may be burned, and the tokens which are
+		// not paid to the requested outputs must be returned to the
+		// change script.
+		var spent []*wire.TxOut
+		for _, u := range unsigned.Inputs {
+			spent = append(spent, &u.Output)
+		}
+		spentAmounts, _ := tokenTotals(spent)
+		paidAmounts, _ := tokenTotals(unsigned.Tx.TxOut)
+		for category, amount := range spentAmounts {
+			if paidAmounts[category] != amount {
+				t.Errorf("%s: %d tokens of category %x spent but "+
+					"%d paid", test.name, amount, category[:1],
+					paidAmounts[category])
+			}
+		}
+		for _, idx := range unsigned.TokenChangeIndexes {
+			txOut := unsigned.Tx.TxOut[idx]
+			if string(txOut.PkScript) != string(changeScript) {
+				t.Errorf("%s: token change output %d does not pay "+
+					"the change script", test.name, idx)
+			}
+			if txOut.Value < dustThreshold(txOut) {
+				t.Errorf("%s: token change output %d is dust",
+					test.name, idx)
+			}
+		}
+	}
+}
@@ -0,0 +1,24 @@
+/*
+Package txbuilder builds unsigned transactions from a set of unspent outputs
+provided by the caller, such as the outputs controlled by an external wallet.
+
+Coins are selected from the provided outputs largest first until they pay for
+the requested outputs and the fee.  The fee is calculated from the estimated
+size of the signed transaction, but since the number of signature checks of a
+transaction is limited relative to its size, each signature check is accounted
+for with a minimum number of bytes just like the relay policy of the network
+does.  Any change which is not dust is paid to the provided change script.
+
+Outputs which carry CashTokens are only spent when their tokens are needed by
+the requested outputs, and the tokens which they carry beyond that are returned
+to the change script, so building a transaction never burns tokens.  Fungible
+tokens are selected just like coins, while the non-fungible tokens of the
+requested outputs must either be spent from an output carrying an identical
+token, be minted by a minting token of the category, which is kept, or be
+created by modifying a mutable token of the category.  Creating new token
+categories is not supported.
+
+The outputs spent by the built transaction are returned in the order of its
+inputs, since their amounts, scripts, and tokens are needed to sign it.
+*/
+package txbuilder
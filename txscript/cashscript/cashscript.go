@@ -0,0 +1,287 @@
+package cashscript
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// Input is an input of the constructor or of a function of a contract.
+type Input struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Function is a function of a contract.
+type Function struct {
+	Name   string  `json:"name"`
+	Inputs []Input `json:"inputs"`
+}
+
+// Artifact is a contract compiled by cashc.
+type Artifact struct {
+	ContractName      string     `json:"contractName"`
+	ConstructorInputs []Input    `json:"constructorInputs"`
+	Abi               []Function `json:"abi"`
+
+	// Bytecode is the compiled contract in the assembly notation of cashc,
+	// which consists of opcode names and hex encoded data pushes separated
+	// by spaces.
+	Bytecode string `json:"bytecode"`
+
+	Source   string `json:"source"`
+	Compiler struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"compiler"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// ReadArtifact reads an artifact in the JSON format written by cashc and
+// ensures its bytecode can be parsed.
+func ReadArtifact(r io.Reader) (*Artifact, error) {
+	var a Artifact
+	if err := json.NewDecoder(r).Decode(&a); err != nil {
+		return nil, fmt.Errorf("invalid artifact: %v", err)
+	}
+	if len(a.Abi) == 0 {
+		return nil, errors.New("artifact has no functions")
+	}
+	if _, err := ParseAsm(a.Bytecode); err != nil {
+		return nil, fmt.Errorf("invalid bytecode of artifact: %v", err)
+	}
+	return &a, nil
+}
+
+// LoadArtifact reads the artifact in the file at the passed path.
+func LoadArtifact(path string) (*Artifact, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadArtifact(f)
+}
+
+// ParseAsm returns the script of the passed assembly in the notation of cashc.
+// Data is pushed with the smallest possible push operation, which is what cashc
+// does when converting the assembly to bytecode.
+func ParseAsm(asm string) ([]byte, error) {
+	builder := txscript.NewScriptBuilder()
+	for _, tok := range strings.Fields(asm) {
+		if strings.HasPrefix(tok, "OP_") {
+			opcode, ok := txscript.OpcodeByName[tok]
+			if !ok {
+				return nil, fmt.Errorf("unknown opcode %q", tok)
+			}
+			builder.AddOp(opcode)
+			continue
+		}
+		data, err := hex.DecodeString(tok)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token %q", tok)
+		}
+		builder.AddData(data)
+	}
+	return builder.Script()
+}
+
+// pushArgs adds the passed arguments for the passed inputs to the script in
+// reverse order, so the first argument ends up on top of the stack.
+func pushArgs(builder *txscript.ScriptBuilder, inputs []Input, args []interface{}) error {
+	if len(args) != len(inputs) {
+		return fmt.Errorf("got %d arguments, want %d", len(args),
+			len(inputs))
+	}
+	for i := len(inputs) - 1; i >= 0; i-- {
+		if err := pushArg(builder, inputs[i].Type, args[i]); err != nil {
+			return fmt.Errorf("argument %s: %v", inputs[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// pushArg adds the encoding of the passed argument of the passed type to the
+// script.
+func pushArg(builder *txscript.ScriptBuilder, typ string, arg interface{}) error {
+	switch typ {
+	case "int":
+		switch n := arg.(type) {
+		case int:
+			builder.AddInt64(int64(n))
+		case int64:
+			builder.AddInt64(n)
+		default:
+			return fmt.Errorf("got %T for int", arg)
+		}
+		return nil
+
+	case "bool":
+		b, ok := arg.(bool)
+		if !ok {
+			return fmt.Errorf("got %T for bool", arg)
+		}
+		if b {
+			builder.AddOp(txscript.OP_1)
+		} else {
+			builder.AddOp(txscript.OP_0)
+		}
+		return nil
+
+	case "string":
+		s, ok := arg.(string)
+		if !ok {
+			return fmt.Errorf("got %T for string", arg)
+		}
+		builder.AddData([]byte(s))
+		return nil
+	}
+
+	b, ok := arg.([]byte)
+	if !ok {
+		return fmt.Errorf("got %T for %s", arg, typ)
+	}
+	switch {
+	case typ == "bytes", typ == "sig", typ == "datasig":
+	case typ == "pubkey":
+		if len(b) != 33 {
+			return fmt.Errorf("got %d bytes for pubkey, want 33", len(b))
+		}
+	case strings.HasPrefix(typ, "bytes"):
+		n, err := strconv.Atoi(strings.TrimPrefix(typ, "bytes"))
+		if err != nil || n <= 0 {
+			return fmt.Errorf("unknown type %s", typ)
+		}
+		if len(b) != n {
+			return fmt.Errorf("got %d bytes for %s", len(b), typ)
+		}
+	default:
+		return fmt.Errorf("unknown type %s", typ)
+	}
+	builder.AddData(b)
+	return nil
+}
+
+// Contract is an instance of a contract with the arguments of its
+// constructor.
+type Contract struct {
+	artifact     *Artifact
+	redeemScript []byte
+}
+
+// NewContract returns an instance of the contract of the passed artifact with
+// the passed arguments of its constructor.
+func NewContract(artifact *Artifact, args ...interface{}) (*Contract, error) {
+	bytecode, err := ParseAsm(artifact.Bytecode)
+	if err != nil {
+		return nil, err
+	}
+	builder := txscript.NewScriptBuilder()
+	if err := pushArgs(builder, artifact.ConstructorInputs, args); err != nil {
+		return nil, fmt.Errorf("constructor of %s: %v",
+			artifact.ContractName, err)
+	}
+	script, err := builder.Script()
+	if err != nil {
+		return nil, err
+	}
+	return &Contract{
+		artifact:     artifact,
+		redeemScript: append(script, bytecode...),
+	}, nil
+}
+
+// Artifact returns the artifact of the contract.
+func (c *Contract) Artifact() *Artifact {
+	return c.artifact
+}
+
+// RedeemScript returns the redeem script of the contract, which consists of the
+// arguments of its constructor followed by its bytecode.  This is the script
+// code which signatures of the contract sign.
+func (c *Contract) RedeemScript() []byte {
+	return c.redeemScript
+}
+
+// LockingScript returns the pay-to-script-hash script of outputs which pay to
+// the contract.
+func (c *Contract) LockingScript() []byte {
+	script, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_HASH160).
+		AddData(bchutil.Hash160(c.redeemScript)).
+		AddOp(txscript.OP_EQUAL).Script()
+	return script
+}
+
+// LockingScript32 returns the 32 byte pay-to-script-hash script of outputs
+// which pay to the contract.
+func (c *Contract) LockingScript32() []byte {
+	script, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_HASH256).
+		AddData(chainhash.DoubleHashB(c.redeemScript)).
+		AddOp(txscript.OP_EQUAL).Script()
+	return script
+}
+
+// UnlockingScript returns the signature script which spends an output paying
+// to the contract by calling the named function with the passed arguments.
+// The index of the function is passed to contracts with multiple functions to
+// select it.
+func (c *Contract) UnlockingScript(function string, args ...interface{}) ([]byte, error) {
+	index := -1
+	for i := range c.artifact.Abi {
+		if c.artifact.Abi[i].Name == function {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("%s has no function %s",
+			c.artifact.ContractName, function)
+	}
+
+	builder := txscript.NewScriptBuilder()
+	if err := pushArgs(builder, c.artifact.Abi[index].Inputs, args); err != nil {
+		return nil, fmt.Errorf("function %s: %v", function, err)
+	}
+	if len(c.artifact.Abi) > 1 {
+		builder.AddInt64(int64(index))
+	}
+	return builder.AddData(c.redeemScript).Script()
+}
+
+// Execute executes the signature script of the input at the passed index of
+// the transaction against the script of the output it spends.  The outputs
+// spent by all inputs must be passed in the order of the inputs since they are
+// used by the introspection opcodes and signature checks.
+func Execute(tx *wire.MsgTx, idx int, spentOutputs []*wire.TxOut, flags txscript.ScriptFlags) error {
+	if len(spentOutputs) != len(tx.TxIn) {
+		return fmt.Errorf("got %d spent outputs for %d inputs",
+			len(spentOutputs), len(tx.TxIn))
+	}
+	if idx < 0 || idx >= len(tx.TxIn) {
+		return fmt.Errorf("input index %d out of range", idx)
+	}
+
+	utxoCache := txscript.NewUtxoCache()
+	for i, output := range spentOutputs {
+		utxoCache.AddEntry(i, *output)
+	}
+	sigHashes := txscript.NewTxSigHashes(tx)
+	sigHashes.AddTxSigHashUtxoFromUtxoCache(tx, utxoCache)
+
+	vm, err := txscript.NewEngine(spentOutputs[idx].PkScript, tx, idx,
+		flags, nil, sigHashes, utxoCache, spentOutputs[idx].Value)
+	if err != nil {
+		return err
+	}
+	return vm.Execute()
+}
@@ -0,0 +1,258 @@
+package cashscript
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gcash/bchd/bchec"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// p2pkhArtifact is the artifact of the following contract as compiled by
+// cashc:
+//
+//	contract P2PKH(bytes20 pkh) {
+//	    function spend(pubkey pk, sig s) {
+//	        require(hash160(pk) == pkh);
+//	        require(checkSig(s, pk));
+//	    }
+//	}
+const p2pkhArtifact = `{
+  "contractName": "P2PKH",
+  "constructorInputs": [{"name": "pkh", "type": "bytes20"}],
+  "abi": [
+    {
+      "name": "spend",
+      "inputs": [{"name": "pk", "type": "pubkey"}, {"name": "s", "type": "sig"}]
+    }
+  ],
+  "bytecode": "OP_OVER OP_HASH160 OP_EQUALVERIFY OP_CHECKSIG",
+  "source": "",
+  "compiler": {"name": "cashc", "version": "0.10.0"},
+  "updatedAt": "2024-01-01T00:00:00.000Z"
+}`
+
+// transferWithTimeoutArtifact is the artifact of the following contract as
+// compiled by cashc:
+//
+//	contract TransferWithTimeout(pubkey sender, pubkey recipient, int timeout) {
+//	    function transfer(sig recipientSig) {
+//	        require(checkSig(recipientSig, recipient));
+//	    }
+//	    function timeout(sig senderSig) {
+//	        require(checkSig(senderSig, sender));
+//	        require(tx.time >= timeout);
+//	    }
+//	}
+const transferWithTimeoutArtifact = `{
+  "contractName": "TransferWithTimeout",
+  "constructorInputs": [
+    {"name": "sender", "type": "pubkey"},
+    {"name": "recipient", "type": "pubkey"},
+    {"name": "timeout", "type": "int"}
+  ],
+  "abi": [
+    {"name": "transfer", "inputs": [{"name": "recipientSig", "type": "sig"}]},
+    {"name": "timeout", "inputs": [{"name": "senderSig", "type": "sig"}]}
+  ],
+  "bytecode": "OP_3 OP_PICK OP_0 OP_NUMEQUAL OP_IF OP_4 OP_ROLL OP_2 OP_ROLL OP_CHECKSIG OP_NIP OP_NIP OP_NIP OP_ELSE OP_3 OP_ROLL OP_1 OP_NUMEQUALVERIFY OP_3 OP_ROLL OP_SWAP OP_CHECKSIGVERIFY OP_SWAP OP_CHECKLOCKTIMEVERIFY OP_2DROP OP_1 OP_ENDIF",
+  "source": "",
+  "compiler": {"name": "cashc", "version": "0.10.0"},
+  "updatedAt": "2024-01-01T00:00:00.000Z"
+}`
+
+// testFlags are the flags the contracts are executed with.
+const testFlags = txscript.StandardVerifyFlags | txscript.ScriptAllowCashTokens |
+	txscript.ScriptAllowMay2025
+
+// mustReadArtifact reads the passed artifact and fails the test on error.
+func mustReadArtifact(t *testing.T, s string) *Artifact {
+	a, err := ReadArtifact(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("ReadArtifact: unexpected error: %v", err)
+	}
+	return a
+}
+
+// testKey returns a private key whose scalar consists of the passed byte.
+func testKey(b byte) *bchec.PrivateKey {
+	key, _ := bchec.PrivKeyFromBytes(bchec.S256(), bytes.Repeat([]byte{b}, 32))
+	return key
+}
+
+// spendTx returns a transaction which spends the output paying to the locking
+// script along with the spent outputs.
+func spendTx(lockingScript []byte, lockTime uint32) (*wire.MsgTx, []*wire.TxOut) {
+	tx := wire.NewMsgTx(2)
+	txIn := wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{1}}, nil)
+	txIn.Sequence = 0
+	tx.AddTxIn(txIn)
+	tx.AddTxOut(wire.NewTxOut(90000, []byte{txscript.OP_TRUE}, wire.TokenData{}))
+	tx.LockTime = lockTime
+	spent := []*wire.TxOut{wire.NewTxOut(100000, lockingScript, wire.TokenData{})}
+	return tx, spent
+}
+
+// sign returns the signature of the input of the transaction by the key for the
+// redeem script of the contract.
+func sign(t *testing.T, tx *wire.MsgTx, c *Contract, key *bchec.PrivateKey) []byte {
+	sig, err := txscript.RawTxInSchnorrSignature(tx, 0, c.RedeemScript(),
+		txscript.SigHashAll|txscript.SigHashForkID, key, 100000)
+	if err != nil {
+		t.Fatalf("RawTxInSchnorrSignature: unexpected error: %v", err)
+	}
+	return sig
+}
+
+// TestP2PKHContract ensures a contract with a single function is instantiated
+// and spent like a pay-to-pubkey-hash output wrapped in pay-to-script-hash.
+func TestP2PKHContract(t *testing.T) {
+	a := mustReadArtifact(t, p2pkhArtifact)
+	key := testKey(1)
+	pubKey := key.PubKey().SerializeCompressed()
+	c, err := NewContract(a, bchutil.Hash160(pubKey))
+	if err != nil {
+		t.Fatalf("NewContract: unexpected error: %v", err)
+	}
+
+	// The redeem script is the push of the hash followed by the bytecode.
+	want := append([]byte{txscript.OP_DATA_20}, bchutil.Hash160(pubKey)...)
+	want = append(want, txscript.OP_OVER, txscript.OP_HASH160,
+		txscript.OP_EQUALVERIFY, txscript.OP_CHECKSIG)
+	if !bytes.Equal(c.RedeemScript(), want) {
+		t.Fatalf("got redeem script %x, want %x", c.RedeemScript(), want)
+	}
+	if txscript.GetScriptClass(c.LockingScript()) != txscript.ScriptHashTy {
+		t.Fatal("locking script is not pay-to-script-hash")
+	}
+	if txscript.GetScriptClass(c.LockingScript32()) != txscript.ScriptHash32Ty {
+		t.Fatal("locking script is not 32 byte pay-to-script-hash")
+	}
+
+	for _, lockingScript := range [][]byte{c.LockingScript(), c.LockingScript32()} {
+		tx, spent := spendTx(lockingScript, 0)
+		unlock, err := c.UnlockingScript("spend", pubKey, sign(t, tx, c, key))
+		if err != nil {
+			t.Fatalf("UnlockingScript: unexpected error: %v", err)
+		}
+		tx.TxIn[0].SignatureScript = unlock
+		if err := Execute(tx, 0, spent, testFlags); err != nil {
+			t.Fatalf("Execute: unexpected error: %v", err)
+		}
+
+		// Another key fails the hash check.
+		other := testKey(2)
+		unlock, _ = c.UnlockingScript("spend",
+			other.PubKey().SerializeCompressed(), sign(t, tx, c, other))
+		tx.TxIn[0].SignatureScript = unlock
+		if err := Execute(tx, 0, spent, testFlags); err == nil {
+			t.Fatal("Execute: expected error for other key")
+		}
+	}
+}
+
+// TestMultipleFunctions ensures functions of contracts with multiple functions
+// are selected by their index.
+func TestMultipleFunctions(t *testing.T) {
+	a := mustReadArtifact(t, transferWithTimeoutArtifact)
+	sender, recipient := testKey(1), testKey(2)
+	const timeout = 500000
+	c, err := NewContract(a, sender.PubKey().SerializeCompressed(),
+		recipient.PubKey().SerializeCompressed(), timeout)
+	if err != nil {
+		t.Fatalf("NewContract: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		function string
+		key      *bchec.PrivateKey
+		lockTime uint32
+		valid    bool
+	}{
+		{"transfer", "transfer", recipient, 0, true},
+		{"transfer by sender", "transfer", sender, 0, false},
+		{"timeout", "timeout", sender, timeout, true},
+		{"timeout too early", "timeout", sender, timeout - 1, false},
+		{"timeout by recipient", "timeout", recipient, timeout, false},
+	}
+	for _, test := range tests {
+		tx, spent := spendTx(c.LockingScript(), test.lockTime)
+		unlock, err := c.UnlockingScript(test.function,
+			sign(t, tx, c, test.key))
+		if err != nil {
+			t.Fatalf("%s: UnlockingScript: unexpected error: %v",
+				test.name, err)
+		}
+		tx.TxIn[0].SignatureScript = unlock
+		err = Execute(tx, 0, spent, testFlags)
+		if (err == nil) != test.valid {
+			t.Fatalf("%s: got error %v, want valid %v", test.name,
+				err, test.valid)
+		}
+	}
+}
+
+// TestArguments ensures arguments are encoded according to their types and
+// that invalid arguments are rejected.
+func TestArguments(t *testing.T) {
+	a := &Artifact{
+		ContractName: "Args",
+		ConstructorInputs: []Input{
+			{Name: "i", Type: "int"},
+			{Name: "b", Type: "bool"},
+			{Name: "s", Type: "string"},
+			{Name: "d", Type: "bytes2"},
+		},
+		Abi:      []Function{{Name: "f"}},
+		Bytecode: "OP_2DROP OP_2DROP 0102 OP_DROP OP_1",
+	}
+	c, err := NewContract(a, 17, true, "a", []byte{0xaa, 0xbb})
+	if err != nil {
+		t.Fatalf("NewContract: unexpected error: %v", err)
+	}
+	want := []byte{
+		0x02, 0xaa, 0xbb, // d
+		0x01, 'a', // s
+		txscript.OP_1, // b
+		0x01, 0x11,    // i
+		txscript.OP_2DROP, txscript.OP_2DROP, 0x02, 0x01, 0x02,
+		txscript.OP_DROP, txscript.OP_1,
+	}
+	if !bytes.Equal(c.RedeemScript(), want) {
+		t.Fatalf("got redeem script %x, want %x", c.RedeemScript(), want)
+	}
+
+	invalid := [][]interface{}{
+		{17, true, "a"},
+		{"17", true, "a", []byte{0xaa, 0xbb}},
+		{17, 1, "a", []byte{0xaa, 0xbb}},
+		{17, true, []byte("a"), []byte{0xaa, 0xbb}},
+		{17, true, "a", []byte{0xaa}},
+	}
+	for i, args := range invalid {
+		if _, err := NewContract(a, args...); err == nil {
+			t.Fatalf("%d: NewContract: expected error", i)
+		}
+	}
+	if _, err := c.UnlockingScript("g"); err == nil {
+		t.Fatal("UnlockingScript: expected error for unknown function")
+	}
+	if _, err := c.UnlockingScript("f", 1); err == nil {
+		t.Fatal("UnlockingScript: expected error for extra argument")
+	}
+
+	if _, err := ParseAsm("OP_DUP OP_FOO"); err == nil {
+		t.Fatal("ParseAsm: expected error for unknown opcode")
+	}
+	if _, err := ParseAsm("OP_DUP 0g"); err == nil {
+		t.Fatal("ParseAsm: expected error for invalid data")
+	}
+	if _, err := ReadArtifact(strings.NewReader(`{"bytecode": "OP_1"}`)); err == nil {
+		t.Fatal("ReadArtifact: expected error for artifact without functions")
+	}
+}
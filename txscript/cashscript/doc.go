@@ -0,0 +1,42 @@
+/*
+Package cashscript instantiates contracts compiled by the CashScript compiler,
+cashc, so they can be executed by the script engine of txscript.
+
+This allows the contracts to be tested in continuous integration directly
+against the consensus rules of the node rather than a separate implementation
+of the virtual machine.
+
+An artifact, as written by cashc, holds the bytecode of a contract along with
+the inputs of its constructor and of each of its functions.  A Contract is
+instantiated from an artifact and the arguments of its constructor:
+
+	artifact, err := cashscript.LoadArtifact("p2pkh.json")
+	...
+	contract, err := cashscript.NewContract(artifact, pubKeyHash)
+	...
+
+The contract provides the redeem script, which consists of the arguments of the
+constructor followed by the bytecode, and the pay-to-script-hash locking script
+of the outputs paying to it.  UnlockingScript returns the signature script
+which spends such an output by calling a function of the contract, and Execute
+runs it through the script engine:
+
+	tx.TxIn[0].SignatureScript, err = contract.UnlockingScript("spend",
+		pubKey, sig)
+	...
+	err = cashscript.Execute(tx, 0, spentOutputs, flags)
+
+The arguments are passed as Go values which are encoded according to the type
+of the corresponding input in the artifact:
+
+  - int: int or int64, encoded as a script number
+  - bool: bool
+  - string: string, encoded as UTF-8
+  - bytes, bytesN, pubkey, sig and datasig: []byte, whose length must be N for
+    bytesN and 33 for pubkey
+
+Only the bytecode of artifacts is used, so any artifact which follows the
+format of cashc, including the ones written by other compilers based on
+libauth, can be loaded.
+*/
+package cashscript
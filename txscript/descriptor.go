@@ -0,0 +1,712 @@
+package txscript
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gcash/bchd/bchec"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchutil"
+	"github.com/gcash/bchutil/hdkeychain"
+)
+
+const (
+	// descriptorChecksumLen is the number of characters of a descriptor
+	// checksum.
+	descriptorChecksumLen = 8
+
+	// maxP2SHMultiSigKeys is the maximum number of public keys in a
+	// multisig script wrapped in a pay-to-script-hash script which keeps
+	// the redeem script within the maximum script element size.
+	maxP2SHMultiSigKeys = 15
+)
+
+const (
+	// descriptorInputCharset is the set of characters a descriptor may
+	// consist of.  The position of a character determines its value in
+	// the checksum calculation.
+	descriptorInputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+		"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+		"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+
+	// descriptorChecksumCharset is the set of characters the checksum is
+	// encoded with.
+	descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+)
+
+// descriptorPolyMod returns the checksum state which results from adding the
+// passed symbol to the passed state using the BCH code specified by BIP0380.
+func descriptorPolyMod(c uint64, val int) uint64 {
+	c0 := c >> 35
+	c = ((c & 0x7ffffffff) << 5) ^ uint64(val)
+	if c0&1 != 0 {
+		c ^= 0xf5dee51989
+	}
+	if c0&2 != 0 {
+		c ^= 0xa9fdca3312
+	}
+	if c0&4 != 0 {
+		c ^= 0x1bab10e32d
+	}
+	if c0&8 != 0 {
+		c ^= 0x3706b1677a
+	}
+	if c0&16 != 0 {
+		c ^= 0x644d626ffd
+	}
+	return c
+}
+
+// DescriptorChecksum returns the checksum of the passed descriptor, which must
+// not include a checksum itself.
+func DescriptorChecksum(desc string) (string, error) {
+	c := uint64(1)
+	cls, clsCount := 0, 0
+	for i := 0; i < len(desc); i++ {
+		pos := strings.IndexByte(descriptorInputCharset, desc[i])
+		if pos == -1 {
+			return "", fmt.Errorf("invalid descriptor character %q",
+				desc[i])
+		}
+		// Emit a symbol for the position inside the group for every
+		// character, and a symbol for the group of every three
+		// characters.
+		c = descriptorPolyMod(c, pos&31)
+		cls = cls*3 + (pos >> 5)
+		clsCount++
+		if clsCount == 3 {
+			c = descriptorPolyMod(c, cls)
+			cls, clsCount = 0, 0
+		}
+	}
+	if clsCount > 0 {
+		c = descriptorPolyMod(c, cls)
+	}
+	for i := 0; i < descriptorChecksumLen; i++ {
+		c = descriptorPolyMod(c, 0)
+	}
+	c ^= 1
+
+	var checksum [descriptorChecksumLen]byte
+	for i := range checksum {
+		checksum[i] = descriptorChecksumCharset[(c>>(5*(7-i)))&31]
+	}
+	return string(checksum[:]), nil
+}
+
+// descriptorKey is a public key expression of a descriptor.  It is either a
+// hex encoded public key or an extended public key followed by a derivation
+// path which optionally ends in a wildcard.
+type descriptorKey struct {
+	// origin is the key origin information, including the brackets, and
+	// originFingerprint and originPath are its parsed master key
+	// fingerprint and derivation path.
+	origin            string
+	originFingerprint [4]byte
+	originPath        []uint32
+
+	pubKey []byte
+
+	xpub     *hdkeychain.ExtendedKey
+	path     []uint32
+	wildcard bool
+}
+
+// parseDescriptorKey parses the passed key expression.
+func parseDescriptorKey(s string, params *chaincfg.Params) (*descriptorKey, error) {
+	key := &descriptorKey{}
+	if strings.HasPrefix(s, "[") {
+		end := strings.IndexByte(s, ']')
+		if end == -1 {
+			return nil, errors.New("key origin is missing ']'")
+		}
+		key.origin = s[:end+1]
+		if err := key.parseOrigin(s[1:end]); err != nil {
+			return nil, err
+		}
+		s = s[end+1:]
+	}
+
+	if pubKey, err := hex.DecodeString(s); err == nil {
+		if _, err := bchec.ParsePubKey(pubKey, bchec.S256()); err != nil {
+			return nil, fmt.Errorf("invalid public key %s: %v", s, err)
+		}
+		key.pubKey = pubKey
+		return key, nil
+	}
+
+	parts := strings.Split(s, "/")
+	xpub, err := hdkeychain.NewKeyFromString(parts[0])
+	if err != nil {
+		if _, err := bchutil.DecodeWIF(parts[0]); err == nil {
+			return nil, errors.New("private keys are not supported")
+		}
+		return nil, fmt.Errorf("invalid key %s", s)
+	}
+	if xpub.IsPrivate() {
+		return nil, errors.New("private keys are not supported")
+	}
+	if !xpub.IsForNet(params) {
+		return nil, fmt.Errorf("extended key %s is not for %s", parts[0],
+			params.Name)
+	}
+	key.xpub = xpub
+
+	for i, elem := range parts[1:] {
+		if elem == "*" && i == len(parts)-2 {
+			key.wildcard = true
+			break
+		}
+		if strings.HasSuffix(elem, "'") || strings.HasSuffix(elem, "h") ||
+			elem == "*'" || elem == "*h" {
+			return nil, errors.New("hardened derivation from an " +
+				"extended public key is not possible")
+		}
+		index, err := strconv.ParseUint(elem, 10, 32)
+		if err != nil || index >= hdkeychain.HardenedKeyStart {
+			return nil, fmt.Errorf("invalid derivation path "+
+				"element %q", elem)
+		}
+		key.path = append(key.path, uint32(index))
+	}
+	return key, nil
+}
+
+// parseOrigin parses the passed key origin information without the brackets,
+// which is a hex encoded master key fingerprint optionally followed by a
+// derivation path whose hardened elements end in ' or h.
+func (k *descriptorKey) parseOrigin(origin string) error {
+	parts := strings.Split(origin, "/")
+	fingerprint, err := hex.DecodeString(parts[0])
+	if err != nil || len(fingerprint) != len(k.originFingerprint) {
+		return fmt.Errorf("invalid key origin fingerprint %q", parts[0])
+	}
+	copy(k.originFingerprint[:], fingerprint)
+
+	for _, elem := range parts[1:] {
+		var offset uint32
+		if strings.HasSuffix(elem, "'") || strings.HasSuffix(elem, "h") {
+			offset = hdkeychain.HardenedKeyStart
+			elem = elem[:len(elem)-1]
+		}
+		index, err := strconv.ParseUint(elem, 10, 32)
+		if err != nil || index >= hdkeychain.HardenedKeyStart {
+			return fmt.Errorf("invalid key origin path element %q",
+				elem)
+		}
+		k.originPath = append(k.originPath, uint32(index)+offset)
+	}
+	return nil
+}
+
+// keyOrigin returns the origin of the public key at the passed index.  Keys
+// without origin information are their own master key.
+func (k *descriptorKey) keyOrigin(index uint32) (*KeyOrigin, error) {
+	pubKey, err := k.derive(index)
+	if err != nil {
+		return nil, err
+	}
+	origin := &KeyOrigin{PubKey: pubKey}
+	if k.origin != "" {
+		origin.Fingerprint = k.originFingerprint
+		origin.Path = append(origin.Path, k.originPath...)
+	} else {
+		master := k.pubKey
+		if k.xpub != nil {
+			pub, err := k.xpub.ECPubKey()
+			if err != nil {
+				return nil, err
+			}
+			master = pub.SerializeCompressed()
+		}
+		copy(origin.Fingerprint[:], bchutil.Hash160(master))
+	}
+	if k.xpub != nil {
+		origin.Path = append(origin.Path, k.path...)
+		if k.wildcard {
+			origin.Path = append(origin.Path, index)
+		}
+	}
+	return origin, nil
+}
+
+// derive returns the compressed public key at the passed index, which is only
+// used when the key ends in a wildcard.
+func (k *descriptorKey) derive(index uint32) ([]byte, error) {
+	if k.xpub == nil {
+		return k.pubKey, nil
+	}
+	child := k.xpub
+	var err error
+	for _, i := range k.path {
+		child, err = child.Child(i)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if k.wildcard {
+		if index >= hdkeychain.HardenedKeyStart {
+			return nil, fmt.Errorf("index %d is out of range", index)
+		}
+		child, err = child.Child(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	pubKey, err := child.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+	return pubKey.SerializeCompressed(), nil
+}
+
+// String returns the key expression.
+func (k *descriptorKey) String() string {
+	if k.xpub == nil {
+		return k.origin + hex.EncodeToString(k.pubKey)
+	}
+	var b strings.Builder
+	b.WriteString(k.origin)
+	b.WriteString(k.xpub.String())
+	for _, i := range k.path {
+		b.WriteString("/")
+		b.WriteString(strconv.FormatUint(uint64(i), 10))
+	}
+	if k.wildcard {
+		b.WriteString("/*")
+	}
+	return b.String()
+}
+
+// descriptorNode is a script expression of a descriptor.
+type descriptorNode struct {
+	fn string
+
+	// keys holds the keys of pk, pkh, multi, and sortedmulti expressions.
+	keys []*descriptorKey
+
+	// threshold is the number of required signatures of multi and
+	// sortedmulti expressions.
+	threshold int
+
+	// sub is the wrapped expression of sh expressions.
+	sub *descriptorNode
+
+	// addr is the address of addr expressions.
+	addr bchutil.Address
+
+	// raw is the script of raw expressions.
+	raw []byte
+}
+
+// splitDescriptorArgs splits the passed argument list on the commas which are
+// not nested inside of parentheses or brackets.
+func splitDescriptorArgs(s string) []string {
+	var (
+		args  []string
+		depth int
+		start int
+	)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(args, s[start:])
+}
+
+// parseDescriptorNode parses the passed script expression.  The top flag is
+// set for the outermost expression and the inSH flag for expressions wrapped in
+// sh.
+func parseDescriptorNode(s string, params *chaincfg.Params, top, inSH bool) (*descriptorNode, error) {
+	open := strings.IndexByte(s, '(')
+	if open == -1 || !strings.HasSuffix(s, ")") {
+		return nil, fmt.Errorf("invalid script expression %q", s)
+	}
+	node := &descriptorNode{fn: s[:open]}
+	inner := s[open+1 : len(s)-1]
+
+	switch node.fn {
+	case "pk", "pkh":
+		key, err := parseDescriptorKey(inner, params)
+		if err != nil {
+			return nil, err
+		}
+		node.keys = []*descriptorKey{key}
+
+	case "multi", "sortedmulti":
+		args := splitDescriptorArgs(inner)
+		if len(args) < 2 {
+			return nil, fmt.Errorf("%s requires a threshold and at "+
+				"least one key", node.fn)
+		}
+		threshold, err := strconv.Atoi(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid multisig threshold %q",
+				args[0])
+		}
+		maxKeys := MaxPubKeysPerMultiSig
+		if inSH {
+			maxKeys = maxP2SHMultiSigKeys
+		}
+		numKeys := len(args) - 1
+		if numKeys > maxKeys {
+			return nil, fmt.Errorf("%s has %d keys which is more "+
+				"than the maximum of %d", node.fn, numKeys, maxKeys)
+		}
+		if threshold < 1 || threshold > numKeys {
+			return nil, fmt.Errorf("multisig threshold %d is out "+
+				"of range (min: 1, max: %d)", threshold, numKeys)
+		}
+		node.threshold = threshold
+		for _, arg := range args[1:] {
+			key, err := parseDescriptorKey(arg, params)
+			if err != nil {
+				return nil, err
+			}
+			node.keys = append(node.keys, key)
+		}
+
+	case "sh":
+		if !top {
+			return nil, errors.New("sh is only allowed at the top " +
+				"level")
+		}
+		sub, err := parseDescriptorNode(inner, params, false, true)
+		if err != nil {
+			return nil, err
+		}
+		if sub.fn == "addr" || sub.fn == "raw" {
+			return nil, fmt.Errorf("%s can not be wrapped in sh",
+				sub.fn)
+		}
+		node.sub = sub
+
+	case "addr":
+		if !top {
+			return nil, errors.New("addr is only allowed at the top " +
+				"level")
+		}
+		addr, err := bchutil.DecodeAddress(inner, params)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %v", inner, err)
+		}
+		if !addr.IsForNet(params) {
+			return nil, fmt.Errorf("address %s is not for %s", inner,
+				params.Name)
+		}
+		node.addr = addr
+
+	case "raw":
+		if !top {
+			return nil, errors.New("raw is only allowed at the top " +
+				"level")
+		}
+		raw, err := hex.DecodeString(inner)
+		if err != nil {
+			return nil, fmt.Errorf("invalid script %q", inner)
+		}
+		node.raw = raw
+
+	default:
+		return nil, fmt.Errorf("unsupported script expression %q",
+			node.fn)
+	}
+	return node, nil
+}
+
+// isRange returns whether the expression contains a key ending in a wildcard.
+func (n *descriptorNode) isRange() bool {
+	if n.sub != nil {
+		return n.sub.isRange()
+	}
+	for _, key := range n.keys {
+		if key.wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// script returns the script of the expression at the passed index.
+func (n *descriptorNode) script(index uint32) ([]byte, error) {
+	pubKeys := make([][]byte, 0, len(n.keys))
+	for _, key := range n.keys {
+		pubKey, err := key.derive(index)
+		if err != nil {
+			return nil, err
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	switch n.fn {
+	case "pk":
+		return payToPubKeyScript(pubKeys[0])
+
+	case "pkh":
+		return payToPubKeyHashScript(bchutil.Hash160(pubKeys[0]))
+
+	case "multi", "sortedmulti":
+		if n.fn == "sortedmulti" {
+			sort.Slice(pubKeys, func(i, j int) bool {
+				return bytes.Compare(pubKeys[i], pubKeys[j]) < 0
+			})
+		}
+		builder := NewScriptBuilder().AddInt64(int64(n.threshold))
+		for _, pubKey := range pubKeys {
+			builder.AddData(pubKey)
+		}
+		builder.AddInt64(int64(len(pubKeys)))
+		builder.AddOp(OP_CHECKMULTISIG)
+		return builder.Script()
+
+	case "sh":
+		redeemScript, err := n.sub.script(index)
+		if err != nil {
+			return nil, err
+		}
+		return payToScriptHashScript(bchutil.Hash160(redeemScript))
+
+	case "addr":
+		return PayToAddrScript(n.addr)
+
+	case "raw":
+		return n.raw, nil
+	}
+	return nil, fmt.Errorf("unsupported script expression %q", n.fn)
+}
+
+// maxSigScriptSize returns the maximum size of a signature script which
+// satisfies the script of the expression at the passed index.
+func (n *descriptorNode) maxSigScriptSize(index uint32) (int, error) {
+	// A push of a DER encoded ECDSA signature of at most 72 bytes followed
+	// by the sighash type.
+	const maxSigPushSize = 1 + 72 + 1
+
+	switch n.fn {
+	case "pk":
+		return maxSigPushSize, nil
+
+	case "pkh":
+		pubKey, err := n.keys[0].derive(index)
+		if err != nil {
+			return 0, err
+		}
+		return maxSigPushSize + 1 + len(pubKey), nil
+
+	case "multi", "sortedmulti":
+		// The extra item consumed by OP_CHECKMULTISIG followed by
+		// the signatures.
+		return 1 + n.threshold*maxSigPushSize, nil
+
+	case "sh":
+		size, err := n.sub.maxSigScriptSize(index)
+		if err != nil {
+			return 0, err
+		}
+		redeemScript, err := n.sub.script(index)
+		if err != nil {
+			return 0, err
+		}
+		pushSize := len(redeemScript) + 1
+		switch {
+		case len(redeemScript) > 0xffff:
+			pushSize += 4
+		case len(redeemScript) > 0xff:
+			pushSize += 2
+		case len(redeemScript) >= OP_PUSHDATA1:
+			pushSize++
+		}
+		return size + pushSize, nil
+	}
+	return 0, fmt.Errorf("the signature script size of %s is unknown",
+		n.fn)
+}
+
+// String returns the script expression.
+func (n *descriptorNode) String() string {
+	var args []string
+	switch n.fn {
+	case "pk", "pkh":
+		args = []string{n.keys[0].String()}
+	case "multi", "sortedmulti":
+		args = append(args, strconv.Itoa(n.threshold))
+		for _, key := range n.keys {
+			args = append(args, key.String())
+		}
+	case "sh":
+		args = []string{n.sub.String()}
+	case "addr":
+		args = []string{n.addr.String()}
+	case "raw":
+		args = []string{hex.EncodeToString(n.raw)}
+	}
+	return n.fn + "(" + strings.Join(args, ",") + ")"
+}
+
+// KeyOrigin describes where a public key of a descriptor is derived from: the
+// fingerprint of its master key and its derivation path from the master key.
+type KeyOrigin struct {
+	PubKey      []byte
+	Fingerprint [4]byte
+	Path        []uint32
+}
+
+// Descriptor is an output script descriptor as specified by BIP0380 and the
+// related BIPs, which describes a set of output scripts.  The pk, pkh, multi,
+// sortedmulti, sh, addr, and raw script expressions are supported.  The addr
+// expression accepts cash addresses and legacy addresses.
+//
+// Keys are either hex encoded public keys or extended public keys followed by
+// an unhardened derivation path which may end in a wildcard, in which case the
+// descriptor describes a range of output scripts.  Private keys are not
+// supported.
+type Descriptor struct {
+	root   *descriptorNode
+	params *chaincfg.Params
+}
+
+// ParseDescriptor parses the passed descriptor for the passed network.  The
+// checksum is verified when present.
+func ParseDescriptor(desc string, params *chaincfg.Params) (*Descriptor, error) {
+	if i := strings.IndexByte(desc, '#'); i != -1 {
+		checksum := desc[i+1:]
+		desc = desc[:i]
+		if len(checksum) != descriptorChecksumLen {
+			return nil, fmt.Errorf("descriptor checksum must be %d "+
+				"characters", descriptorChecksumLen)
+		}
+		expected, err := DescriptorChecksum(desc)
+		if err != nil {
+			return nil, err
+		}
+		if checksum != expected {
+			return nil, fmt.Errorf("descriptor checksum mismatch: "+
+				"got %s, expected %s", checksum, expected)
+		}
+	}
+	if _, err := DescriptorChecksum(desc); err != nil {
+		return nil, err
+	}
+
+	root, err := parseDescriptorNode(desc, params, true, false)
+	if err != nil {
+		return nil, err
+	}
+	return &Descriptor{root: root, params: params}, nil
+}
+
+// String returns the descriptor along with its checksum.
+func (d *Descriptor) String() string {
+	desc := d.root.String()
+	// The checksum can not fail since the descriptor only contains
+	// characters which were parsed from a valid descriptor.
+	checksum, _ := DescriptorChecksum(desc)
+	return desc + "#" + checksum
+}
+
+// IsRange returns whether the descriptor contains a key ending in a wildcard
+// and thus describes a range of output scripts.
+func (d *Descriptor) IsRange() bool {
+	return d.root.isRange()
+}
+
+// Script returns the output script the descriptor describes at the passed
+// index.  The index is ignored by descriptors which are not ranged.
+func (d *Descriptor) Script(index uint32) ([]byte, error) {
+	return d.root.script(index)
+}
+
+// MaxSigScriptSize returns the maximum size of a signature script which spends
+// the output script the descriptor describes at the passed index, assuming
+// ECDSA signatures which are larger than Schnorr signatures.  It is intended
+// for estimating the size of transactions before they are signed.  An error is
+// returned for addr and raw descriptors since the scripts which satisfy them
+// are unknown.
+func (d *Descriptor) MaxSigScriptSize(index uint32) (int, error) {
+	return d.root.maxSigScriptSize(index)
+}
+
+// RedeemScript returns the redeem script of the pay-to-script-hash output script
+// the descriptor describes at the passed index.  It returns nil for descriptors
+// which are not wrapped in sh.
+func (d *Descriptor) RedeemScript(index uint32) ([]byte, error) {
+	if d.root.sub == nil {
+		return nil, nil
+	}
+	return d.root.sub.script(index)
+}
+
+// KeyOrigins returns the origins of the public keys of the output script the
+// descriptor describes at the passed index, in the order of the descriptor.
+// The origin of a key is taken from its key origin information, followed by
+// the derivation path of an extended public key.  Keys without key origin
+// information are treated as master keys, so their fingerprint is the one of
+// the key itself.
+func (d *Descriptor) KeyOrigins(index uint32) ([]*KeyOrigin, error) {
+	node := d.root
+	if node.sub != nil {
+		node = node.sub
+	}
+	origins := make([]*KeyOrigin, 0, len(node.keys))
+	for _, key := range node.keys {
+		origin, err := key.keyOrigin(index)
+		if err != nil {
+			return nil, err
+		}
+		origins = append(origins, origin)
+	}
+	return origins, nil
+}
+
+// DeriveAddresses returns the addresses of the output scripts the descriptor
+// describes from the start index through the end index, inclusive.  Descriptors
+// which are not ranged have a single address regardless of the range.  An
+// error is returned when the output scripts do not have an address, such as
+// pay-to-pubkey and bare multisig scripts.
+func (d *Descriptor) DeriveAddresses(start, end uint32) ([]bchutil.Address, error) {
+	if !d.IsRange() {
+		start, end = 0, 0
+	}
+	if start > end {
+		return nil, fmt.Errorf("range start %d is after end %d", start,
+			end)
+	}
+
+	addrs := make([]bchutil.Address, 0, end-start+1)
+	for i := start; ; i++ {
+		script, err := d.Script(i)
+		if err != nil {
+			return nil, err
+		}
+		class, scriptAddrs, _, err := ExtractPkScriptAddrs(script,
+			d.params)
+		if err != nil {
+			return nil, err
+		}
+		switch class {
+		case PubKeyHashTy, ScriptHashTy, ScriptHash32Ty:
+		default:
+			return nil, fmt.Errorf("descriptor %s does not have an "+
+				"address", d.root)
+		}
+		addrs = append(addrs, scriptAddrs[0])
+		if i == end {
+			break
+		}
+	}
+	return addrs, nil
+}
@@ -0,0 +1,341 @@
+package txscript
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchutil"
+	"github.com/gcash/bchutil/hdkeychain"
+)
+
+const (
+	// descTestXpub is the master extended public key of the first BIP0032
+	// test vector.
+	descTestXpub = "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8"
+
+	// descTestPubKey1 and descTestPubKey2 are the compressed public keys
+	// of the private keys 1 and 2.
+	descTestPubKey1 = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	descTestPubKey2 = "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"
+)
+
+// TestDescriptorChecksum ensures descriptor checksums are calculated and
+// verified as specified by BIP0380.
+func TestDescriptorChecksum(t *testing.T) {
+	tests := []struct {
+		desc     string
+		checksum string
+	}{
+		{"raw(deadbeef)", "89f8spxm"},
+		{"pkh(" + descTestXpub + "/0/*)", "xgqkr0nt"},
+	}
+	for _, test := range tests {
+		checksum, err := DescriptorChecksum(test.desc)
+		if err != nil {
+			t.Fatalf("DescriptorChecksum(%s): unexpected error: %v",
+				test.desc, err)
+		}
+		if checksum != test.checksum {
+			t.Fatalf("DescriptorChecksum(%s): got %s, want %s",
+				test.desc, checksum, test.checksum)
+		}
+	}
+
+	params := &chaincfg.MainNetParams
+	if _, err := ParseDescriptor("raw(deadbeef)#89f8spxm", params); err != nil {
+		t.Fatalf("ParseDescriptor: unexpected error: %v", err)
+	}
+	if _, err := ParseDescriptor("raw(deadbeef)#89f8spxn", params); err == nil {
+		t.Fatal("ParseDescriptor: expected error for bad checksum")
+	}
+	if _, err := ParseDescriptor("raw(deadbeef)#89f8", params); err == nil {
+		t.Fatal("ParseDescriptor: expected error for short checksum")
+	}
+}
+
+// TestDescriptorAddresses ensures descriptors derive the expected addresses
+// and round trip through their string form.
+func TestDescriptorAddresses(t *testing.T) {
+	params := &chaincfg.MainNetParams
+
+	xpub, err := hdkeychain.NewKeyFromString(descTestXpub)
+	if err != nil {
+		t.Fatalf("NewKeyFromString: unexpected error: %v", err)
+	}
+	xpubAddr := func(branch, index uint32) string {
+		branchKey, _ := xpub.Child(branch)
+		child, _ := branchKey.Child(index)
+		addr, _ := child.Address(params)
+		return addr.String()
+	}
+	multiAddr := func(pubKeys ...string) string {
+		var keys []*bchutil.AddressPubKey
+		for _, pubKey := range pubKeys {
+			key, err := bchutil.NewAddressPubKey(hexToBytes(pubKey), params)
+			if err != nil {
+				t.Fatalf("NewAddressPubKey: unexpected error: %v", err)
+			}
+			keys = append(keys, key)
+		}
+		script, err := MultiSigScript(keys, 1)
+		if err != nil {
+			t.Fatalf("MultiSigScript: unexpected error: %v", err)
+		}
+		addr, _ := bchutil.NewAddressScriptHash(script, params)
+		return addr.String()
+	}
+
+	tests := []struct {
+		name    string
+		desc    string
+		isRange bool
+		start   uint32
+		end     uint32
+		addrs   []string
+	}{
+		{
+			name:  "pkh with public key",
+			desc:  "pkh(" + descTestPubKey1 + ")",
+			addrs: []string{"qp63uahgrxged4z5jswyt5dn5v3lzsem6cy4spdc2h"},
+		},
+		{
+			name:  "pkh with key origin",
+			desc:  "pkh([d34db33f/44'/145'/0']" + descTestPubKey1 + ")",
+			addrs: []string{"qp63uahgrxged4z5jswyt5dn5v3lzsem6cy4spdc2h"},
+		},
+		{
+			name:    "ranged pkh",
+			desc:    "pkh(" + descTestXpub + "/1/*)",
+			isRange: true,
+			start:   3,
+			end:     5,
+			addrs:   []string{xpubAddr(1, 3), xpubAddr(1, 4), xpubAddr(1, 5)},
+		},
+		{
+			name:  "sh multi",
+			desc:  "sh(multi(1," + descTestPubKey2 + "," + descTestPubKey1 + "))",
+			addrs: []string{multiAddr(descTestPubKey2, descTestPubKey1)},
+		},
+		{
+			name:  "sh sortedmulti",
+			desc:  "sh(sortedmulti(1," + descTestPubKey2 + "," + descTestPubKey1 + "))",
+			addrs: []string{multiAddr(descTestPubKey1, descTestPubKey2)},
+		},
+		{
+			name:  "cash address",
+			desc:  "addr(bitcoincash:qp63uahgrxged4z5jswyt5dn5v3lzsem6cy4spdc2h)",
+			addrs: []string{"qp63uahgrxged4z5jswyt5dn5v3lzsem6cy4spdc2h"},
+		},
+		{
+			name:  "legacy address",
+			desc:  "addr(1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH)",
+			addrs: []string{"qp63uahgrxged4z5jswyt5dn5v3lzsem6cy4spdc2h"},
+		},
+	}
+
+	for _, test := range tests {
+		desc, err := ParseDescriptor(test.desc, params)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if desc.IsRange() != test.isRange {
+			t.Fatalf("%s: got range %v, want %v", test.name,
+				desc.IsRange(), test.isRange)
+		}
+		addrs, err := desc.DeriveAddresses(test.start, test.end)
+		if err != nil {
+			t.Fatalf("%s: DeriveAddresses: unexpected error: %v",
+				test.name, err)
+		}
+		if len(addrs) != len(test.addrs) {
+			t.Fatalf("%s: got %d addresses, want %d", test.name,
+				len(addrs), len(test.addrs))
+		}
+		for i, addr := range addrs {
+			if addr.String() != test.addrs[i] {
+				t.Fatalf("%s: address %d: got %s, want %s",
+					test.name, i, addr, test.addrs[i])
+			}
+		}
+
+		// The string form must parse to the same descriptor.
+		reparsed, err := ParseDescriptor(desc.String(), params)
+		if err != nil {
+			t.Fatalf("%s: reparse %s: unexpected error: %v",
+				test.name, desc, err)
+		}
+		if reparsed.String() != desc.String() {
+			t.Fatalf("%s: got %s after round trip, want %s",
+				test.name, reparsed, desc)
+		}
+	}
+}
+
+// TestDescriptorErrors ensures invalid descriptors are rejected.
+func TestDescriptorErrors(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	testNetPKH, _ := bchutil.NewAddressPubKeyHash(
+		bchutil.Hash160(hexToBytes(descTestPubKey1)),
+		&chaincfg.TestNet3Params)
+	testNetAddr := testNetPKH.EncodeAddress()
+	tests := []struct {
+		name string
+		desc string
+	}{
+		{"unknown function", "wpkh(" + descTestPubKey1 + ")"},
+		{"missing parenthesis", "pkh(" + descTestPubKey1},
+		{"invalid key", "pkh(02deadbeef)"},
+		{"private key", "pkh(KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn)"},
+		{"hardened derivation", "pkh(" + descTestXpub + "/0'/*)"},
+		{"hardened wildcard", "pkh(" + descTestXpub + "/0/*')"},
+		{"wildcard not last", "pkh(" + descTestXpub + "/*/0)"},
+		{"nested sh", "sh(sh(pkh(" + descTestPubKey1 + ")))"},
+		{"sh addr", "sh(addr(1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH))"},
+		{"threshold too high", "multi(3," + descTestPubKey1 + "," + descTestPubKey2 + ")"},
+		{"threshold zero", "multi(0," + descTestPubKey1 + ")"},
+		{"wrong network", "addr(" + testNetAddr + ")"},
+		{"invalid character", "raw(deadbeef)é"},
+		{"short origin fingerprint", "pkh([d34db3/0']" + descTestPubKey1 + ")"},
+		{"invalid origin path", "pkh([d34db33f/x]" + descTestPubKey1 + ")"},
+	}
+	for _, test := range tests {
+		if _, err := ParseDescriptor(test.desc, params); err == nil {
+			t.Fatalf("%s: expected error parsing %s", test.name,
+				test.desc)
+		}
+	}
+
+	// Scripts without an address can not derive addresses.
+	for _, s := range []string{
+		"pk(" + descTestPubKey1 + ")",
+		"multi(1," + descTestPubKey1 + ")",
+		"raw(deadbeef)",
+	} {
+		desc, err := ParseDescriptor(s, params)
+		if err != nil {
+			t.Fatalf("ParseDescriptor(%s): unexpected error: %v", s, err)
+		}
+		if _, err := desc.DeriveAddresses(0, 0); err == nil {
+			t.Fatalf("DeriveAddresses(%s): expected error", s)
+		}
+	}
+}
+
+// TestDescriptorMaxSigScriptSize ensures the maximum signature script sizes
+// of descriptors are calculated correctly.
+func TestDescriptorMaxSigScriptSize(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	tests := []struct {
+		desc string
+		size int
+	}{
+		// Signature push and compressed public key push.
+		{"pkh(" + descTestPubKey1 + ")", 74 + 34},
+		{"pkh(" + descTestXpub + "/0/*)", 74 + 34},
+		{"pk(" + descTestPubKey1 + ")", 74},
+		// Dummy element, signature push, and a push of the 71 byte
+		// redeem script.
+		{"sh(multi(1," + descTestPubKey1 + "," + descTestPubKey2 + "))", 1 + 74 + 72},
+	}
+	for _, test := range tests {
+		desc, err := ParseDescriptor(test.desc, params)
+		if err != nil {
+			t.Fatalf("ParseDescriptor(%s): unexpected error: %v",
+				test.desc, err)
+		}
+		size, err := desc.MaxSigScriptSize(0)
+		if err != nil {
+			t.Fatalf("MaxSigScriptSize(%s): unexpected error: %v",
+				test.desc, err)
+		}
+		if size != test.size {
+			t.Fatalf("MaxSigScriptSize(%s): got %d, want %d",
+				test.desc, size, test.size)
+		}
+	}
+}
+
+// TestDescriptorKeyOrigins ensures the redeem scripts and key origins of
+// descriptors are derived correctly.
+func TestDescriptorKeyOrigins(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	xpub, err := hdkeychain.NewKeyFromString(descTestXpub)
+	if err != nil {
+		t.Fatalf("NewKeyFromString: unexpected error: %v", err)
+	}
+	branchKey, _ := xpub.Child(1)
+	child, _ := branchKey.Child(5)
+	childPubKey, _ := child.ECPubKey()
+
+	// Keys with origin information extend its path.
+	desc, err := ParseDescriptor("pkh([d34db33f/44'/145h/0']"+descTestXpub+"/1/*)", params)
+	if err != nil {
+		t.Fatalf("ParseDescriptor: unexpected error: %v", err)
+	}
+	if redeemScript, err := desc.RedeemScript(5); err != nil || redeemScript != nil {
+		t.Fatalf("RedeemScript: got %x, %v, want no script", redeemScript, err)
+	}
+	origins, err := desc.KeyOrigins(5)
+	if err != nil {
+		t.Fatalf("KeyOrigins: unexpected error: %v", err)
+	}
+	wantPath := []uint32{
+		hdkeychain.HardenedKeyStart + 44, hdkeychain.HardenedKeyStart + 145,
+		hdkeychain.HardenedKeyStart, 1, 5,
+	}
+	if len(origins) != 1 ||
+		!bytes.Equal(origins[0].PubKey, childPubKey.SerializeCompressed()) ||
+		origins[0].Fingerprint != [4]byte{0xd3, 0x4d, 0xb3, 0x3f} ||
+		!reflect.DeepEqual(origins[0].Path, wantPath) {
+
+		t.Fatalf("unexpected key origins %+v", origins[0])
+	}
+
+	// Keys without origin information are master keys.  The fingerprint
+	// of the master key of the first BIP0032 test vector is 3442193e.
+	desc, err = ParseDescriptor("sh(sortedmulti(1,"+descTestPubKey1+","+
+		descTestXpub+"/1/*))", params)
+	if err != nil {
+		t.Fatalf("ParseDescriptor: unexpected error: %v", err)
+	}
+	redeemScript, err := desc.RedeemScript(5)
+	if err != nil {
+		t.Fatalf("RedeemScript: unexpected error: %v", err)
+	}
+	script, _ := desc.Script(5)
+	if !bytes.Equal(script, mustPayToScriptHash(t, redeemScript)) {
+		t.Fatalf("redeem script %x does not match script %x",
+			redeemScript, script)
+	}
+	origins, err = desc.KeyOrigins(5)
+	if err != nil {
+		t.Fatalf("KeyOrigins: unexpected error: %v", err)
+	}
+	var pubKey1Fingerprint [4]byte
+	copy(pubKey1Fingerprint[:], bchutil.Hash160(hexToBytes(descTestPubKey1)))
+	if len(origins) != 2 ||
+		!bytes.Equal(origins[0].PubKey, hexToBytes(descTestPubKey1)) ||
+		origins[0].Fingerprint != pubKey1Fingerprint ||
+		len(origins[0].Path) != 0 {
+
+		t.Fatalf("unexpected key origins %+v", origins[0])
+	}
+	if !bytes.Equal(origins[1].PubKey, childPubKey.SerializeCompressed()) ||
+		origins[1].Fingerprint != [4]byte{0x34, 0x42, 0x19, 0x3e} ||
+		!reflect.DeepEqual(origins[1].Path, []uint32{1, 5}) {
+
+		t.Fatalf("unexpected key origins %+v", origins[1])
+	}
+}
+
+// mustPayToScriptHash returns the pay-to-script-hash script of the passed
+// redeem script.
+func mustPayToScriptHash(t *testing.T, redeemScript []byte) []byte {
+	script, err := payToScriptHashScript(bchutil.Hash160(redeemScript))
+	if err != nil {
+		t.Fatalf("payToScriptHashScript: unexpected error: %v", err)
+	}
+	return script
+}
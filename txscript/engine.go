@@ -8,8 +8,10 @@ package txscript
 import (
 	"fmt"
 	"math/big"
+	"sync"
 
 	"github.com/gcash/bchd/bchec"
+	"github.com/gcash/bchd/chaincfg/chainhash"
 	"github.com/gcash/bchd/wire"
 )
 
@@ -167,6 +169,9 @@ type Engine struct {
 	scripts              [][]parsedOpcode
 	scriptIdx            int
 	scriptOff            int
+	lastOpIdx            int  // script index of the last executed opcode
+	lastOpOff            int  // offset of the last executed opcode
+	stepped              bool // whether any opcode has been executed
 	lastCodeSep          int
 	dstack               stack // data stack
 	astack               stack // alt stack
@@ -174,16 +179,30 @@ type Engine struct {
 	txIdx                int
 	condStack            []int
 	numOps               int
-	metrics              *ScriptExecutionMetrics
+	metrics              ScriptExecutionMetrics
 	maxScriptElementSize int
 	flags                ScriptFlags
 	sigCache             *SigCache
+	schnorrBatch         *SchnorrBatch
 	hashCache            *TxSigHashes
 	utxoCache            *UtxoCache
 	bip16                bool     // treat execution as pay-to-script-hash
 	savedFirstStack      [][]byte // stack from first script for bip16 scripts
 	inputAmount          int64
 	sigChecks            int
+
+	// parsedScripts holds the parsed scripts so their storage is reused
+	// once the engine is released.
+	parsedScripts [3][]parsedOpcode
+}
+
+// enginePool is a pool of released engines which are reused by NewEngine, so
+// validating the inputs of a block doesn't allocate the stacks and parsed
+// scripts of an engine for every input.
+var enginePool = sync.Pool{
+	New: func() interface{} {
+		return new(Engine)
+	},
 }
 
 // hasFlag returns whether the script engine instance has the passed flag set.
@@ -308,6 +327,16 @@ func (vm *Engine) DisasmPC() (string, error) {
 	return vm.disasm(scriptIdx, scriptOff), nil
 }
 
+// ScriptPosition returns the script index and opcode offset of the opcode most
+// recently executed by Step, which is the opcode execution failed at when Step
+// or Execute returns an error.  Index 0 is the signature script, 1 is the
+// public key script and 2 is the redeem script of pay-to-script-hash scripts.
+// The final return value is false when no opcode has been executed yet, such
+// as when the signature script is rejected before execution.
+func (vm *Engine) ScriptPosition() (int, int, bool) {
+	return vm.lastOpIdx, vm.lastOpOff, vm.stepped
+}
+
 // DisasmScript returns the disassembly string for the script at the requested
 // offset index.  Index 0 is the signature script and 1 is the public key
 // script.
@@ -334,6 +363,43 @@ func (vm *Engine) SigChecks() int {
 	return vm.sigChecks
 }
 
+// SetSchnorrBatch sets the batch the verification of Schnorr signatures is
+// deferred to.  Verification is only deferred when both the
+// ScriptReportSigChecks and ScriptVerifyNullFail flags are set, in which case
+// the script is only valid once the batch verified successfully.  See
+// SchnorrBatch for details.
+func (vm *Engine) SetSchnorrBatch(batch *SchnorrBatch) {
+	vm.schnorrBatch = batch
+}
+
+// verifySignature returns whether the passed signature of the passed hash is
+// valid for the passed public key.  The signature cache is consulted first and
+// updated with valid signatures when it is set.  When a Schnorr batch is set and
+// the flags allow it, the verification of Schnorr signatures is deferred to the
+// batch and they are treated as valid.
+func (vm *Engine) verifySignature(sig *bchec.Signature, hash []byte, pubKey *bchec.PublicKey) bool {
+	var sigHash chainhash.Hash
+	copy(sigHash[:], hash)
+	if vm.sigCache != nil && vm.sigCache.Exists(sigHash, sig, pubKey) {
+		return true
+	}
+
+	if vm.schnorrBatch != nil && sig.Type() == bchec.SignatureTypeSchnorr &&
+		vm.hasFlag(ScriptReportSigChecks) && vm.hasFlag(ScriptVerifyNullFail) {
+
+		vm.schnorrBatch.add(sigHash, sig, pubKey)
+		return true
+	}
+
+	if !sig.Verify(hash, pubKey) {
+		return false
+	}
+	if vm.sigCache != nil {
+		vm.sigCache.Add(sigHash, sig, pubKey)
+	}
+	return true
+}
+
 // CheckErrorCondition returns nil if the running script has ended and was
 // successful, leaving a a true boolean on the stack.  An error otherwise,
 // including if the script has not finished.
@@ -403,6 +469,7 @@ func (vm *Engine) Step() (done bool, err error) {
 	}
 
 	opcode := &vm.scripts[vm.scriptIdx][vm.scriptOff]
+	vm.lastOpIdx, vm.lastOpOff, vm.stepped = vm.scriptIdx, vm.scriptOff, true
 	vm.scriptOff++
 
 	// Execute the opcode while taking into account several things such as
@@ -461,7 +528,8 @@ func (vm *Engine) Step() (done bool, err error) {
 		vm.scriptOff = 0
 		if vm.scriptIdx == 0 && vm.bip16 {
 			vm.scriptIdx++
-			vm.savedFirstStack = vm.GetStack()
+			vm.savedFirstStack = append(vm.savedFirstStack[:0],
+				vm.dstack.stk...)
 		} else if vm.scriptIdx == 1 && vm.bip16 {
 			// Put us past the end for CheckErrorCondition()
 			vm.scriptIdx++
@@ -473,7 +541,9 @@ func (vm *Engine) Step() (done bool, err error) {
 			}
 
 			script := vm.savedFirstStack[len(vm.savedFirstStack)-1]
-			pops, err := parseScript(script)
+			pops, err := parseScriptTemplateInto(vm.parsedScripts[2][:0],
+				script, &opcodeArray)
+			vm.parsedScripts[2] = pops
 			if err != nil {
 				return false, err
 			}
@@ -894,18 +964,22 @@ func (vm *Engine) Clone() *Engine {
 		return nil
 	}
 	newVM := &Engine{
-		txIdx:       vm.txIdx,
-		scriptOff:   vm.scriptOff,
-		scriptIdx:   vm.scriptIdx,
-		numOps:      vm.numOps,
-		metrics:     vm.metrics,
-		lastCodeSep: vm.lastCodeSep,
-		tx:          vm.tx,
-		bip16:       vm.bip16,
-		flags:       vm.flags,
-		inputAmount: vm.inputAmount,
-		sigCache:    vm.sigCache,
-		hashCache:   vm.hashCache,
+		txIdx:        vm.txIdx,
+		scriptOff:    vm.scriptOff,
+		scriptIdx:    vm.scriptIdx,
+		lastOpIdx:    vm.lastOpIdx,
+		lastOpOff:    vm.lastOpOff,
+		stepped:      vm.stepped,
+		numOps:       vm.numOps,
+		metrics:      vm.metrics,
+		lastCodeSep:  vm.lastCodeSep,
+		tx:           vm.tx,
+		bip16:        vm.bip16,
+		flags:        vm.flags,
+		inputAmount:  vm.inputAmount,
+		sigCache:     vm.sigCache,
+		schnorrBatch: vm.schnorrBatch,
+		hashCache:    vm.hashCache,
 	}
 	newVM.savedFirstStack = make([][]byte, len(vm.savedFirstStack))
 	for i, stack := range vm.savedFirstStack {
@@ -941,8 +1015,11 @@ func (vm *Engine) Clone() *Engine {
 	return newVM
 }
 
+// GetMetrics returns the VM limits metrics accumulated while executing the
+// scripts so far.  Once Execute returns they describe the cost of validating
+// the input.
 func (vm *Engine) GetMetrics() *ScriptExecutionMetrics {
-	return vm.metrics
+	return &vm.metrics
 }
 
 // NewEngine returns a new script engine for the provided public key script,
@@ -977,6 +1054,26 @@ func NewEngine(scriptPubKey []byte, tx *wire.MsgTx, txIdx int, flags ScriptFlags
 			"false stack entry at end of script execution")
 	}
 
+	vm := enginePool.Get().(*Engine)
+	vm.sigCache = sigCache
+	vm.hashCache = hashCache
+	vm.utxoCache = utxoCache
+	vm.inputAmount = inputAmount
+	vm.tx = *tx
+	vm.txIdx = txIdx
+	if err := vm.init(scriptSig, scriptPubKey, flags); err != nil {
+		vm.Release()
+		return nil, err
+	}
+	return vm, nil
+}
+
+// init prepares a released engine to execute the passed scripts with the
+// passed flags.
+func (vm *Engine) init(scriptSig, scriptPubKey []byte, flags ScriptFlags) error {
+	vm.flags = flags
+	vm.metrics = makeScriptExecutionMetrics(len(scriptSig), flags.HasFlag(ScriptAllowMay2025StandardOnly))
+
 	// The clean stack flag (ScriptVerifyCleanStack) is not allowed without
 	// the pay-to-script-hash (P2SH) evaluation (ScriptBip16) flag.
 	//
@@ -985,20 +1082,15 @@ func NewEngine(scriptPubKey []byte, tx *wire.MsgTx, txIdx int, flags ScriptFlags
 	// Thus, allowing the clean stack flag without the P2SH flag would make
 	// it possible to have a situation where P2SH would not be a soft fork
 	// when it should be.
-	vm := Engine{flags: flags, sigCache: sigCache, hashCache: hashCache,
-		utxoCache: utxoCache, inputAmount: inputAmount}
-
-	vm.metrics = NewScriptExecutionMetrics(len(scriptSig), flags.HasFlag(ScriptAllowMay2025StandardOnly))
-
 	if vm.hasFlag(ScriptVerifyCleanStack) && (!vm.hasFlag(ScriptBip16)) {
-		return nil, scriptError(ErrInvalidFlags,
+		return scriptError(ErrInvalidFlags,
 			"invalid flags combination")
 	}
 
 	// The signature script must only contain data pushes when the
 	// associated flag is set.
 	if vm.hasFlag(ScriptVerifySigPushOnly) && !IsPushOnlyScript(scriptSig) {
-		return nil, scriptError(ErrNotPushOnly,
+		return scriptError(ErrNotPushOnly,
 			"signature script is not push only")
 	}
 
@@ -1007,18 +1099,19 @@ func NewEngine(scriptPubKey []byte, tx *wire.MsgTx, txIdx int, flags ScriptFlags
 	// with a pay-to-script-hash transaction, there will be ultimately be
 	// a third script to execute.
 	scripts := [][]byte{scriptSig, scriptPubKey}
-	vm.scripts = make([][]parsedOpcode, len(scripts))
 	for i, scr := range scripts {
 		if len(scr) > MaxScriptSize {
 			str := fmt.Sprintf("script size %d is larger than max "+
 				"allowed size %d", len(scr), MaxScriptSize)
-			return nil, scriptError(ErrScriptTooBig, str)
+			return scriptError(ErrScriptTooBig, str)
 		}
-		var err error
-		vm.scripts[i], err = parseScript(scr)
+		pops, err := parseScriptTemplateInto(vm.parsedScripts[i][:0],
+			scr, &opcodeArray)
+		vm.parsedScripts[i] = pops
 		if err != nil {
-			return nil, err
+			return err
 		}
+		vm.scripts = append(vm.scripts, pops)
 	}
 
 	// Advance the program counter to the public key script if the signature
@@ -1031,7 +1124,7 @@ func NewEngine(scriptPubKey []byte, tx *wire.MsgTx, txIdx int, flags ScriptFlags
 	if vm.hasFlag(ScriptBip16) && isScriptHash(vm.scripts[1]) {
 		// Only accept input scripts that push data for P2SH.
 		if !isPushOnly(vm.scripts[0]) {
-			return nil, scriptError(ErrNotPushOnly,
+			return scriptError(ErrNotPushOnly,
 				"pay to script hash is not push only")
 		}
 		vm.bip16 = true
@@ -1040,7 +1133,7 @@ func NewEngine(scriptPubKey []byte, tx *wire.MsgTx, txIdx int, flags ScriptFlags
 	if vm.hasFlag(ScriptBip16) && isScriptHash32(vm.scripts[1]) {
 		// Only accept input scripts that push data for P2SH32.
 		if !isPushOnly(vm.scripts[0]) {
-			return nil, scriptError(ErrNotPushOnly,
+			return scriptError(ErrNotPushOnly,
 				"pay to script hash is not push only")
 		}
 		vm.bip16 = true
@@ -1067,8 +1160,35 @@ func NewEngine(scriptPubKey []byte, tx *wire.MsgTx, txIdx int, flags ScriptFlags
 		vm.astack.defaultScriptNumLen = defaultSmallScriptNumLen
 	}
 
-	vm.tx = *tx
-	vm.txIdx = txIdx
+	return nil
+}
 
-	return &vm, nil
+// Release returns the engine to a pool of engines which are reused by
+// NewEngine to avoid allocating them for every input.  Neither the engine nor
+// the metrics returned by GetMetrics may be used once it is released.
+func (vm *Engine) Release() {
+	// Drop the references to the data of the scripts and stacks so they
+	// can be garbage collected while the engine is pooled, but keep their
+	// storage.  Parsed scripts are only appended to, so their storage
+	// beyond their length was already cleared when the engine was released
+	// before, while the stacks may have shrunk since.
+	var parsedScripts [3][]parsedOpcode
+	for i, pops := range vm.parsedScripts {
+		clear(pops)
+		parsedScripts[i] = pops[:0]
+	}
+	clear(vm.scripts[:cap(vm.scripts)])
+	clear(vm.dstack.stk[:cap(vm.dstack.stk)])
+	clear(vm.astack.stk[:cap(vm.astack.stk)])
+	clear(vm.savedFirstStack[:cap(vm.savedFirstStack)])
+
+	*vm = Engine{
+		scripts:         vm.scripts[:0],
+		dstack:          stack{stk: vm.dstack.stk[:0]},
+		astack:          stack{stk: vm.astack.stk[:0]},
+		condStack:       vm.condStack[:0],
+		savedFirstStack: vm.savedFirstStack[:0],
+		parsedScripts:   parsedScripts,
+	}
+	enginePool.Put(vm)
 }
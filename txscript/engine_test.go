@@ -148,6 +148,41 @@ func TestCheckErrorCondition(t *testing.T) {
 	}
 }
 
+// TestScriptPosition ensures the script engine reports the position of the
+// opcode script execution failed at.
+func TestScriptPosition(t *testing.T) {
+	t.Parallel()
+
+	tx := &wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Index: 0},
+			SignatureScript:  mustParseShortForm("TRUE 2"),
+			Sequence:         4294967295,
+		}},
+		TxOut: []*wire.TxOut{{Value: 1000000000}},
+	}
+	pkScript := mustParseShortForm("NOP 2 EQUALVERIFY NOP 3 EQUALVERIFY")
+
+	vm, err := NewEngine(pkScript, tx, 0, 0, nil, nil, nil, 0)
+	if err != nil {
+		t.Fatalf("failed to create script: %v", err)
+	}
+	if _, _, ok := vm.ScriptPosition(); ok {
+		t.Fatal("position reported before execution")
+	}
+
+	err = vm.Execute()
+	if !IsErrorCode(err, ErrEqualVerify) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	scriptIdx, opcodeIdx, ok := vm.ScriptPosition()
+	if !ok || scriptIdx != 1 || opcodeIdx != 5 {
+		t.Fatalf("unexpected position %d:%d (ok %v), want 1:5",
+			scriptIdx, opcodeIdx, ok)
+	}
+}
+
 // TestInvalidFlagCombinations ensures the script engine returns the expected
 // error when disallowed flag combinations are specified.
 func TestInvalidFlagCombinations(t *testing.T) {
@@ -863,3 +898,83 @@ func TestScriptVerifyInputSigChecks(t *testing.T) {
 		}
 	}
 }
+
+// TestEngineRelease ensures released engines don't hold on to the data of the
+// scripts they executed and that engines reused from the pool execute scripts
+// the same way new engines do.
+func TestEngineRelease(t *testing.T) {
+	t.Parallel()
+
+	// spendTx returns a transaction with the passed signature script.
+	spendTx := func(sigScript []byte) *wire.MsgTx {
+		tx := wire.NewMsgTx(1)
+		tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, sigScript))
+		tx.AddTxOut(wire.NewTxOut(0, nil, wire.TokenData{}))
+		return tx
+	}
+
+	// A pay-to-script-hash spend, which executes the most scripts, with
+	// data left on the alt stack.
+	redeemScript := mustParseShortForm("TOALTSTACK 2 EQUAL")
+	pkScript, err := payToScriptHashScript(bchutil.Hash160(redeemScript))
+	if err != nil {
+		t.Fatalf("payToScriptHashScript: unexpected error: %v", err)
+	}
+	sigScript, err := NewScriptBuilder().AddInt64(2).AddInt64(3).
+		AddData(redeemScript).Script()
+	if err != nil {
+		t.Fatalf("Script: unexpected error: %v", err)
+	}
+	const flags = StandardVerifyFlags &^ ScriptVerifyCleanStack
+	for i := 0; i < 3; i++ {
+		vm, err := NewEngine(pkScript, spendTx(sigScript), 0, flags,
+			nil, nil, nil, 0)
+		if err != nil {
+			t.Fatalf("NewEngine: unexpected error: %v", err)
+		}
+		if err := vm.Execute(); err != nil {
+			t.Fatalf("%d: Execute: unexpected error: %v", i, err)
+		}
+		if vm.GetMetrics().GetBaseOpCost() == 0 {
+			t.Fatalf("%d: no operation cost", i)
+		}
+		vm.Release()
+
+		// Every reference to the scripts and stack items is dropped.
+		if len(vm.scripts) != 0 || len(vm.tx.TxIn) != 0 {
+			t.Fatalf("%d: released engine holds scripts", i)
+		}
+		for _, pops := range vm.parsedScripts {
+			for _, pop := range pops[:cap(pops)] {
+				if pop.data != nil || pop.opcode != nil {
+					t.Fatalf("%d: released engine holds "+
+						"parsed scripts", i)
+				}
+			}
+		}
+		for _, stk := range [][][]byte{vm.dstack.stk, vm.astack.stk,
+			vm.savedFirstStack} {
+			for _, item := range stk[:cap(stk)] {
+				if item != nil {
+					t.Fatalf("%d: released engine holds "+
+						"stack items", i)
+				}
+			}
+		}
+
+		// A failing script on a reused engine still fails.
+		vm, err = NewEngine(mustParseShortForm("2 EQUAL"),
+			spendTx(mustParseShortForm("3")), 0, flags, nil, nil,
+			nil, 0)
+		if err != nil {
+			t.Fatalf("NewEngine: unexpected error: %v", err)
+		}
+		if err := vm.Execute(); !IsErrorCode(err, ErrEvalFalse) {
+			t.Fatalf("%d: got error %v, want %v", i, err, ErrEvalFalse)
+		}
+		if vm.bip16 || len(vm.scripts) != 2 {
+			t.Fatalf("%d: reused engine kept its state", i)
+		}
+		vm.Release()
+	}
+}
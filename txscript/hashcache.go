@@ -63,8 +63,18 @@ func NewHashCache(maxSize uint) *HashCache {
 // AddSigHashes computes, then adds the partial sighashes for the passed
 // transaction.
 func (h *HashCache) AddSigHashes(tx *wire.MsgTx) {
+	txid := tx.TxHash()
+	h.AddTxSigHashes(&txid, tx)
+}
+
+// AddTxSigHashes computes, then adds the partial sighashes for the passed
+// transaction with the passed txid, which avoids hashing the transaction again
+// when its txid is already known.  The sighashes are computed before the cache
+// is locked, so they may be added by multiple goroutines concurrently.
+func (h *HashCache) AddTxSigHashes(txid *chainhash.Hash, tx *wire.MsgTx) {
+	sigHashes := NewTxSigHashes(tx)
 	h.Lock()
-	h.sigHashes[tx.TxHash()] = NewTxSigHashes(tx)
+	h.sigHashes[*txid] = sigHashes
 	h.Unlock()
 }
 
@@ -122,13 +132,13 @@ func (u *UtxoCache) AddEntry(i int, output wire.TxOut) {
 	u.Unlock()
 }
 
-// GetEntry adds a utxo entry for the given input index.
+// GetEntry returns the utxo entry for the given input index.
 func (u *UtxoCache) GetEntry(i int) (wire.TxOut, error) {
 	u.RLock()
 	utxo, ok := u.utxos[i]
+	u.RUnlock()
 	if !ok {
 		return wire.TxOut{}, errors.New("not found")
 	}
-	u.RUnlock()
 	return utxo, nil
 }
@@ -2635,19 +2635,7 @@ func opcodeCheckSig(op *parsedOpcode, vm *Engine) error {
 		return nil
 	}
 
-	var valid bool
-	if vm.sigCache != nil {
-		var sigHash chainhash.Hash
-		copy(sigHash[:], hash)
-
-		valid = vm.sigCache.Exists(sigHash, signature, pubKey)
-		if !valid && signature.Verify(hash, pubKey) {
-			vm.sigCache.Add(sigHash, signature, pubKey)
-			valid = true
-		}
-	} else {
-		valid = signature.Verify(hash, pubKey)
-	}
+	valid := vm.verifySignature(signature, hash, pubKey)
 	if len(sigBytes) > 0 {
 		vm.sigChecks++
 		if !valid && vm.hasFlag(ScriptVerifyNullFail) {
@@ -2914,19 +2902,7 @@ func opcodeCheckMultiSig(op *parsedOpcode, vm *Engine) error {
 				return nil
 			}
 
-			var valid bool
-			if vm.sigCache != nil {
-				var sigHash chainhash.Hash
-				copy(sigHash[:], signatureHash)
-
-				valid = vm.sigCache.Exists(sigHash, parsedSig, parsedPubKey)
-				if !valid && parsedSig.Verify(signatureHash, parsedPubKey) {
-					vm.sigCache.Add(sigHash, parsedSig, parsedPubKey)
-					valid = true
-				}
-			} else {
-				valid = parsedSig.Verify(signatureHash, parsedPubKey)
-			}
+			valid := vm.verifySignature(parsedSig, signatureHash, parsedPubKey)
 
 			if !valid {
 				str := "not all signatures empty on failed checkmultisig"
@@ -3039,19 +3015,7 @@ func opcodeCheckMultiSig(op *parsedOpcode, vm *Engine) error {
 				return nil
 			}
 
-			var valid bool
-			if vm.sigCache != nil {
-				var sigHash chainhash.Hash
-				copy(sigHash[:], signatureHash)
-
-				valid = vm.sigCache.Exists(sigHash, parsedSig, parsedPubKey)
-				if !valid && parsedSig.Verify(signatureHash, parsedPubKey) {
-					vm.sigCache.Add(sigHash, parsedSig, parsedPubKey)
-					valid = true
-				}
-			} else {
-				valid = parsedSig.Verify(signatureHash, parsedPubKey)
-			}
+			valid := vm.verifySignature(parsedSig, signatureHash, parsedPubKey)
 
 			if valid {
 				// PubKey verified, move on to the next signature.
@@ -3161,19 +3125,7 @@ func opcodeCheckDataSig(op *parsedOpcode, vm *Engine) error {
 		return nil
 	}
 
-	var valid bool
-	if vm.sigCache != nil {
-		var sigHash chainhash.Hash
-		copy(sigHash[:], messageHash[:])
-
-		valid = vm.sigCache.Exists(sigHash, signature, pubKey)
-		if !valid && signature.Verify(messageHash[:], pubKey) {
-			vm.sigCache.Add(sigHash, signature, pubKey)
-			valid = true
-		}
-	} else {
-		valid = signature.Verify(messageHash[:], pubKey)
-	}
+	valid := vm.verifySignature(signature, messageHash[:], pubKey)
 	if len(sigBytes) > 0 {
 		vm.sigChecks++
 
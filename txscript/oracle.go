@@ -0,0 +1,145 @@
+package txscript
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gcash/bchd/bchec"
+)
+
+const (
+	// OracleMessageHeaderLen is the length of the fields which precede the
+	// data of a serialized oracle message.
+	OracleMessageHeaderLen = 12
+
+	// MaxOracleMessageField is the maximum value of the numeric fields of an
+	// oracle message.  Larger values would be negative once converted to
+	// numbers with OP_BIN2NUM by a contract.
+	MaxOracleMessageField = 1<<31 - 1
+)
+
+// OracleMessage is a message published by an oracle for contracts to verify
+// with OP_CHECKDATASIG.  It is serialized as follows, with all numbers encoded
+// as 4 byte little-endian integers which contracts split off the message with
+// OP_SPLIT and convert with OP_BIN2NUM:
+//
+//	<timestamp> <message sequence> <data sequence> <data>
+//
+// The sequence numbers allow contracts to order the messages of an oracle and
+// to reject outdated ones.
+type OracleMessage struct {
+	// Timestamp is the time the message was created at as a unix timestamp.
+	Timestamp uint32
+
+	// MessageSequence is incremented for every message the oracle signs.
+	MessageSequence uint32
+
+	// DataSequence is incremented for every message of the oracle which
+	// carries data, such as a new price.
+	DataSequence uint32
+
+	// Data is the content of the message, which is specific to the oracle.
+	Data []byte
+}
+
+// Serialize returns the serialized message which is signed by the oracle and
+// pushed to the stack of contracts.  It returns an error when a numeric field
+// exceeds MaxOracleMessageField.
+func (m *OracleMessage) Serialize() ([]byte, error) {
+	fields := []uint32{m.Timestamp, m.MessageSequence, m.DataSequence}
+	msg := make([]byte, OracleMessageHeaderLen, OracleMessageHeaderLen+len(m.Data))
+	for i, field := range fields {
+		if field > MaxOracleMessageField {
+			return nil, fmt.Errorf("oracle message field %d is %d, "+
+				"which exceeds the maximum of %d", i, field,
+				MaxOracleMessageField)
+		}
+		binary.LittleEndian.PutUint32(msg[i*4:], field)
+	}
+	return append(msg, m.Data...), nil
+}
+
+// ParseOracleMessage parses a serialized oracle message.
+func ParseOracleMessage(msg []byte) (*OracleMessage, error) {
+	if len(msg) < OracleMessageHeaderLen {
+		return nil, fmt.Errorf("oracle message is %d bytes, which is "+
+			"shorter than the %d byte header", len(msg),
+			OracleMessageHeaderLen)
+	}
+	var fields [3]uint32
+	for i := range fields {
+		fields[i] = binary.LittleEndian.Uint32(msg[i*4:])
+		if fields[i] > MaxOracleMessageField {
+			return nil, fmt.Errorf("oracle message field %d is %d, "+
+				"which exceeds the maximum of %d", i, fields[i],
+				MaxOracleMessageField)
+		}
+	}
+	return &OracleMessage{
+		Timestamp:       fields[0],
+		MessageSequence: fields[1],
+		DataSequence:    fields[2],
+		Data:            append([]byte(nil), msg[OracleMessageHeaderLen:]...),
+	}, nil
+}
+
+// SignOracleMessage returns the Schnorr signature of the passed message by the
+// passed key, which verifies with OP_CHECKDATASIG.  Unlike transaction
+// signatures, the signature signs the single SHA256 hash of the message and
+// has no hash type appended.
+func SignOracleMessage(msg []byte, key *bchec.PrivateKey) ([]byte, error) {
+	hash := sha256.Sum256(msg)
+	sig, err := key.SignSchnorr(hash[:])
+	if err != nil {
+		return nil, fmt.Errorf("cannot sign oracle message: %s", err)
+	}
+	return sig.Serialize(), nil
+}
+
+// VerifyOracleSignature returns whether the passed signature of an oracle
+// message is valid for the passed public key of the oracle, following the
+// rules OP_CHECKDATASIG applies to its arguments under the passed flags.  An
+// error is returned when the signature or public key are encoded in a way
+// which makes the script fail rather than evaluate OP_CHECKDATASIG to false,
+// including an invalid signature with ScriptVerifyNullFail.
+func VerifyOracleSignature(sig, msg, pubKey []byte, flags ScriptFlags) (bool, error) {
+	vm := Engine{flags: flags}
+	if len(sig) > 0 {
+		if err := vm.checkSignatureEncoding(sig); err != nil {
+			return false, err
+		}
+	}
+	if err := vm.checkPubKeyEncoding(pubKey); err != nil {
+		return false, err
+	}
+
+	key, err := bchec.ParsePubKey(pubKey, bchec.S256())
+	if err != nil {
+		return false, nil
+	}
+
+	var signature *bchec.Signature
+	if vm.hasFlag(ScriptVerifySchnorr) && len(sig) == 64 {
+		signature, err = bchec.ParseSchnorrSignature(sig)
+	} else if vm.hasFlag(ScriptVerifyStrictEncoding) ||
+		vm.hasFlag(ScriptVerifyDERSignatures) {
+
+		signature, err = bchec.ParseDERSignature(sig, bchec.S256())
+	} else {
+		signature, err = bchec.ParseBERSignature(sig, bchec.S256())
+	}
+	if err != nil {
+		return false, nil
+	}
+
+	hash := sha256.Sum256(msg)
+	if !signature.Verify(hash[:], key) {
+		if len(sig) > 0 && vm.hasFlag(ScriptVerifyNullFail) {
+			return false, scriptError(ErrNullFail,
+				"signature not empty on failed checksig")
+		}
+		return false, nil
+	}
+	return true, nil
+}
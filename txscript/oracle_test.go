@@ -0,0 +1,212 @@
+package txscript
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"reflect"
+	"testing"
+
+	"github.com/gcash/bchd/bchec"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/wire"
+)
+
+// TestOracleMessage ensures oracle messages round trip through their
+// serialization and that out of range fields are rejected.
+func TestOracleMessage(t *testing.T) {
+	t.Parallel()
+
+	m := &OracleMessage{
+		Timestamp:       1600000000,
+		MessageSequence: 2,
+		DataSequence:    1,
+		Data:            []byte{0x10, 0x27, 0x00, 0x00},
+	}
+	msg, err := m.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	want := []byte{
+		0x00, 0x10, 0x5e, 0x5f, // timestamp
+		0x02, 0x00, 0x00, 0x00, // message sequence
+		0x01, 0x00, 0x00, 0x00, // data sequence
+		0x10, 0x27, 0x00, 0x00, // data
+	}
+	if !bytes.Equal(msg, want) {
+		t.Fatalf("Serialize: got %x, want %x", msg, want)
+	}
+	parsed, err := ParseOracleMessage(msg)
+	if err != nil {
+		t.Fatalf("ParseOracleMessage: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, m) {
+		t.Fatalf("ParseOracleMessage: got %+v, want %+v", parsed, m)
+	}
+
+	if _, err := ParseOracleMessage(msg[:OracleMessageHeaderLen-1]); err == nil {
+		t.Fatal("ParseOracleMessage: expected error for short message")
+	}
+	msg[7] = 0x80
+	if _, err := ParseOracleMessage(msg); err == nil {
+		t.Fatal("ParseOracleMessage: expected error for negative field")
+	}
+	m.DataSequence = MaxOracleMessageField + 1
+	if _, err := m.Serialize(); err == nil {
+		t.Fatal("Serialize: expected error for negative field")
+	}
+}
+
+// checkDataSig executes <sig> <msg> <pubKey> OP_CHECKDATASIG with the passed
+// flags and returns the error of the script, if any.
+func checkDataSig(sig, msg, pubKey []byte, flags ScriptFlags) error {
+	sigScript, err := NewScriptBuilder().AddData(sig).Script()
+	if err != nil {
+		return err
+	}
+	pkScript, err := NewScriptBuilder().AddData(msg).AddData(pubKey).
+		AddOp(OP_CHECKDATASIG).Script()
+	if err != nil {
+		return err
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{1}}, sigScript))
+	tx.AddTxOut(wire.NewTxOut(0, nil, wire.TokenData{}))
+	vm, err := NewEngine(pkScript, tx, 0, flags, nil, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	return vm.Execute()
+}
+
+// TestVerifyOracleSignature ensures oracle signatures verify exactly when
+// OP_CHECKDATASIG evaluates to true for them, and that they are rejected with
+// the errors the opcode fails with.
+func TestVerifyOracleSignature(t *testing.T) {
+	t.Parallel()
+
+	key, _ := bchec.PrivKeyFromBytes(bchec.S256(), bytes.Repeat([]byte{1}, 32))
+	other, _ := bchec.PrivKeyFromBytes(bchec.S256(), bytes.Repeat([]byte{2}, 32))
+	pubKey := key.PubKey().SerializeCompressed()
+	msg, err := (&OracleMessage{Timestamp: 1600000000, Data: []byte("price")}).Serialize()
+	if err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	schnorrSig, err := SignOracleMessage(msg, key)
+	if err != nil {
+		t.Fatalf("SignOracleMessage: unexpected error: %v", err)
+	}
+	hash := sha256.Sum256(msg)
+	ecdsaSig, err := key.SignECDSA(hash[:])
+	if err != nil {
+		t.Fatalf("SignECDSA: unexpected error: %v", err)
+	}
+	otherSig, err := SignOracleMessage(msg, other)
+	if err != nil {
+		t.Fatalf("SignOracleMessage: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		sig     []byte
+		msg     []byte
+		pubKey  []byte
+		flags   ScriptFlags
+		valid   bool
+		wantErr bool
+	}{
+		{
+			name:   "schnorr",
+			sig:    schnorrSig,
+			msg:    msg,
+			pubKey: pubKey,
+			flags:  StandardVerifyFlags,
+			valid:  true,
+		},
+		{
+			name:   "ecdsa",
+			sig:    ecdsaSig.Serialize(),
+			msg:    msg,
+			pubKey: pubKey,
+			flags:  StandardVerifyFlags,
+			valid:  true,
+		},
+		{
+			name:   "uncompressed key",
+			sig:    schnorrSig,
+			msg:    msg,
+			pubKey: key.PubKey().SerializeUncompressed(),
+			flags:  StandardVerifyFlags &^ ScriptVerifyCompressedPubkey,
+			valid:  true,
+		},
+		{
+			name:   "empty signature",
+			msg:    msg,
+			pubKey: pubKey,
+			flags:  StandardVerifyFlags,
+		},
+		{
+			name:    "other key",
+			sig:     otherSig,
+			msg:     msg,
+			pubKey:  pubKey,
+			flags:   StandardVerifyFlags,
+			wantErr: true,
+		},
+		{
+			name:   "other key without nullfail",
+			sig:    otherSig,
+			msg:    msg,
+			pubKey: pubKey,
+			flags:  StandardVerifyFlags &^ ScriptVerifyNullFail,
+		},
+		{
+			name:    "other message",
+			sig:     schnorrSig,
+			msg:     append(append([]byte(nil), msg...), 0),
+			pubKey:  pubKey,
+			flags:   StandardVerifyFlags,
+			wantErr: true,
+		},
+		{
+			name:    "hash type appended",
+			sig:     append(ecdsaSig.Serialize(), byte(SigHashAll|SigHashForkID)),
+			msg:     msg,
+			pubKey:  pubKey,
+			flags:   StandardVerifyFlags,
+			wantErr: true,
+		},
+		{
+			name:    "invalid key encoding",
+			sig:     schnorrSig,
+			msg:     msg,
+			pubKey:  pubKey[1:],
+			flags:   StandardVerifyFlags,
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		valid, verifyErr := VerifyOracleSignature(test.sig, test.msg,
+			test.pubKey, test.flags)
+		if (verifyErr != nil) != test.wantErr {
+			t.Fatalf("%s: got error %v, want error %v", test.name,
+				verifyErr, test.wantErr)
+		}
+		if valid != test.valid {
+			t.Fatalf("%s: got valid %v, want %v", test.name, valid,
+				test.valid)
+		}
+
+		// The script succeeds only with valid signatures and fails with
+		// the same error as the verification.
+		err := checkDataSig(test.sig, test.msg, test.pubKey,
+			test.flags|ScriptVerifyCheckDataSig)
+		if (err == nil) != test.valid {
+			t.Fatalf("%s: script got error %v, want valid %v",
+				test.name, err, test.valid)
+		}
+		if verifyErr != nil && !IsErrorCode(err, verifyErr.(Error).ErrorCode) {
+			t.Fatalf("%s: got script error %v, want %v", test.name,
+				err, verifyErr)
+		}
+	}
+}
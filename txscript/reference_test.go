@@ -436,6 +436,10 @@ func testScripts(t *testing.T, tests [][]interface{}, useSigCache bool) {
 			int64(inputAmt))
 		if err == nil {
 			err = vm.Execute()
+
+			// Release the engine so the following tests run with
+			// reused engines.
+			vm.Release()
 		}
 
 		// Ensure there were no errors when the expected result is OK.
@@ -800,6 +804,7 @@ testloop:
 			}
 
 			err = vm.Execute()
+			vm.Release()
 			if err != nil {
 				t.Errorf("test (%d:%v:%d) failed to execute: "+
 					"%v", i, test, k, err)
@@ -927,3 +932,93 @@ func TestCalcBip143SignatureHash(t *testing.T) {
 		}
 	}
 }
+
+// TestCalcSignatureHashPreimage ensures the serialized preimages returned by
+// CalcSignatureHashPreimage hash to the expected signature hashes and that they
+// commit to the tokens of the spent output.
+func TestCalcSignatureHashPreimage(t *testing.T) {
+	file, err := ioutil.ReadFile("data/sighash_bip143.json")
+	if err != nil {
+		t.Fatalf("TestCalcSignatureHashPreimage: %v\n", err)
+	}
+
+	var tests [][]interface{}
+	err = json.Unmarshal(file, &tests)
+	if err != nil {
+		t.Fatalf("TestCalcSignatureHashPreimage couldn't Unmarshal: %v\n",
+			err)
+	}
+	for i, test := range tests[1:] {
+		hashType := SigHashType(testVecF64ToUint32(test[3].(float64)))
+		if hashType&SigHashUTXO != 0 {
+			// The vectors predate SIGHASH_UTXOS.
+			continue
+		}
+
+		var tx wire.MsgTx
+		rawTx, _ := hex.DecodeString(test[0].(string))
+		if err := tx.Deserialize(bytes.NewReader(rawTx)); err != nil {
+			t.Fatalf("test #%d: failed to parse transaction: %v", i, err)
+		}
+		subScript, _ := hex.DecodeString(test[1].(string))
+		idx := int(test[2].(float64))
+
+		preimage, err := CalcSignatureHashPreimage(subScript,
+			NewTxSigHashes(&tx), hashType, &tx, idx, 0)
+		if err != nil {
+			t.Fatalf("test #%d: CalcSignatureHashPreimage: %v", i, err)
+		}
+		if !bytes.Equal(preimage.ScriptCode, subScript) {
+			t.Fatalf("test #%d: script code mismatch", i)
+		}
+		expectedHash, _ := chainhash.NewHashFromStr(test[4].(string))
+		hash := chainhash.DoubleHashH(preimage.Bytes())
+		if hash != *expectedHash {
+			t.Fatalf("test #%d: signature hash mismatch -- got %v, "+
+				"want %v", i, hash, expectedHash)
+		}
+	}
+
+	// The preimage of an input spending an output which carries tokens
+	// commits to them, and SIGHASH_UTXOS commits to all spent outputs.
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil))
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 2}, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{OP_TRUE}, wire.TokenData{}))
+	token := wire.TokenData{CategoryID: [32]byte{0xaa}, Amount: 1,
+		BitField: wire.HAS_AMOUNT}
+	utxoCache := NewUtxoCache()
+	utxoCache.AddEntry(0, *wire.NewTxOut(2000, []byte{OP_TRUE}, token))
+	utxoCache.AddEntry(1, *wire.NewTxOut(3000, []byte{OP_TRUE}, wire.TokenData{}))
+	sigHashes := NewTxSigHashes(tx)
+	sigHashes.AddTxSigHashUtxoFromUtxoCache(tx, utxoCache)
+
+	for _, hashType := range []SigHashType{
+		SigHashAll | SigHashForkID,
+		SigHashAll | SigHashForkID | SigHashUTXO,
+	} {
+		preimage, err := CalcSignatureHashPreimage([]byte{OP_TRUE},
+			sigHashes, hashType, tx, 0, 2000)
+		if err != nil {
+			t.Fatalf("CalcSignatureHashPreimage: %v", err)
+		}
+		tokenPrefix := token.TokenDataBuffer()
+		if !bytes.Equal(preimage.TokenPrefix, tokenPrefix.Bytes()) {
+			t.Fatalf("hash type %x: token prefix mismatch", hashType)
+		}
+		if preimage.HasHashUtxos != (hashType&SigHashUTXO != 0) ||
+			preimage.HasHashUtxos && preimage.HashUtxos != sigHashes.HashUTXOS {
+
+			t.Fatalf("hash type %x: unexpected hash of spent outputs",
+				hashType)
+		}
+		hash, _, err := CalcSignatureHash([]byte{OP_TRUE}, sigHashes,
+			hashType, tx, 0, 2000, true)
+		if err != nil {
+			t.Fatalf("CalcSignatureHash: %v", err)
+		}
+		if !bytes.Equal(chainhash.DoubleHashB(preimage.Bytes()), hash) {
+			t.Fatalf("hash type %x: signature hash mismatch", hashType)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package txscript
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/gcash/bchd/bchec"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+)
+
+// schnorrBatchSize is the number of Schnorr signatures which are collected
+// before they are verified as a batch.  Larger batches are verified faster per
+// signature, while smaller ones spread the verification of a block more evenly
+// across the goroutines executing its scripts.
+const schnorrBatchSize = 128
+
+// schnorrBatchEntry houses a Schnorr signature whose verification was deferred
+// to a SchnorrBatch.
+type schnorrBatchEntry struct {
+	sigHash chainhash.Hash
+	sig     *bchec.Signature
+	pubKey  *bchec.PublicKey
+}
+
+// SchnorrBatch collects the Schnorr signatures checked by script engines so
+// they can be verified in batches, which is considerably faster than verifying
+// them one at a time.  It is safe for concurrent use by the engines executing
+// the scripts of a block in parallel.
+//
+// Verification is only deferred to the batch by engines which have both the
+// ScriptReportSigChecks and ScriptVerifyNullFail flags set.  Under those rules
+// a non-empty signature which fails to verify always makes the script invalid,
+// so a deferred signature can be treated as valid while the script executes as
+// long as Verify is invoked before the scripts are considered valid.
+type SchnorrBatch struct {
+	sigCache *SigCache
+
+	mtx     sync.Mutex
+	pending []schnorrBatchEntry
+	err     error
+}
+
+// NewSchnorrBatch returns a new empty Schnorr batch.  The signatures which are
+// verified by the batch are added to the passed signature cache, which may be
+// nil.
+func NewSchnorrBatch(sigCache *SigCache) *SchnorrBatch {
+	return &SchnorrBatch{
+		sigCache: sigCache,
+		pending:  make([]schnorrBatchEntry, 0, schnorrBatchSize),
+	}
+}
+
+// add defers the verification of the passed signature to the batch.  The
+// pending signatures are verified by the calling goroutine once there are
+// enough of them to fill a batch.
+func (b *SchnorrBatch) add(sigHash chainhash.Hash, sig *bchec.Signature, pubKey *bchec.PublicKey) {
+	b.mtx.Lock()
+	b.pending = append(b.pending, schnorrBatchEntry{sigHash, sig, pubKey})
+	var entries []schnorrBatchEntry
+	if len(b.pending) >= schnorrBatchSize {
+		entries = b.pending
+		b.pending = make([]schnorrBatchEntry, 0, schnorrBatchSize)
+	}
+	b.mtx.Unlock()
+
+	if entries != nil {
+		b.verifyEntries(entries)
+	}
+}
+
+// verifyEntries verifies the passed signatures as a batch and records an error
+// for the batch when any of them is invalid.  The valid signatures are added to
+// the signature cache.
+func (b *SchnorrBatch) verifyEntries(entries []schnorrBatchEntry) {
+	verifier := bchec.NewSchnorrBatchVerifier()
+	for i := range entries {
+		entry := &entries[i]
+		verifier.Add(entry.sig, entry.sigHash[:], entry.pubKey)
+	}
+
+	// The batch only tells whether all of the signatures are valid, so
+	// verify them individually when it fails to find the invalid one.
+	batchValid := verifier.Verify()
+	for i := range entries {
+		entry := &entries[i]
+		if !batchValid && !entry.sig.Verify(entry.sigHash[:], entry.pubKey) {
+			str := fmt.Sprintf("invalid schnorr signature %x for "+
+				"public key %x", entry.sig.Serialize(),
+				entry.pubKey.SerializeCompressed())
+			b.mtx.Lock()
+			if b.err == nil {
+				b.err = scriptError(ErrNullFail, str)
+			}
+			b.mtx.Unlock()
+			return
+		}
+		if b.sigCache != nil {
+			b.sigCache.Add(entry.sigHash, entry.sig, entry.pubKey)
+		}
+	}
+}
+
+// Verify verifies the signatures which are still pending and returns an error
+// when any of the signatures which were deferred to the batch is invalid.  It
+// must only be invoked once all of the engines using the batch have finished
+// executing.
+func (b *SchnorrBatch) Verify() error {
+	b.mtx.Lock()
+	entries := b.pending
+	b.pending = nil
+	b.mtx.Unlock()
+
+	if len(entries) > 0 {
+		b.verifyEntries(entries)
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.err
+}
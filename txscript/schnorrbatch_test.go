@@ -0,0 +1,115 @@
+package txscript
+
+import (
+	"testing"
+
+	"github.com/gcash/bchd/bchec"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/wire"
+)
+
+// TestSchnorrBatch ensures the verification of Schnorr signatures is deferred
+// to a Schnorr batch when the flags allow it and that invalid signatures are
+// detected once the batch is verified.
+func TestSchnorrBatch(t *testing.T) {
+	t.Parallel()
+
+	privKey, err := bchec.NewPrivateKey(bchec.S256())
+	if err != nil {
+		t.Fatalf("failed to create private key: %v", err)
+	}
+	pkScript, err := NewScriptBuilder().
+		AddData(privKey.PubKey().SerializeCompressed()).
+		AddOp(OP_CHECKSIG).Script()
+	if err != nil {
+		t.Fatalf("failed to create script: %v", err)
+	}
+
+	// Create a transaction with more inputs than fit in a single batch
+	// which spends pay-to-pubkey outputs with Schnorr signatures.  The
+	// signature of the input at invalidIdx commits to the wrong amount.
+	const amount = 1000
+	const numInputs = schnorrBatchSize + 10
+	const invalidIdx = 5
+	tx := wire.NewMsgTx(wire.TxVersion)
+	for i := 0; i < numInputs; i++ {
+		prevOut := wire.NewOutPoint(&chainhash.Hash{}, uint32(i))
+		tx.AddTxIn(wire.NewTxIn(prevOut, nil))
+	}
+	tx.AddTxOut(wire.NewTxOut(0, []byte{OP_RETURN}, wire.TokenData{}))
+
+	signInputs := func(invalidIdx int) {
+		for i := range tx.TxIn {
+			signAmount := int64(amount)
+			if i == invalidIdx {
+				signAmount++
+			}
+			sig, err := RawTxInSchnorrSignature(tx, i, pkScript,
+				SigHashAll, privKey, signAmount)
+			if err != nil {
+				t.Fatalf("failed to sign input %d: %v", i, err)
+			}
+			tx.TxIn[i].SignatureScript, err = NewScriptBuilder().
+				AddData(sig).Script()
+			if err != nil {
+				t.Fatalf("failed to create script: %v", err)
+			}
+		}
+	}
+
+	// executeAll executes the scripts of all of the inputs with the passed
+	// flags and Schnorr batch.
+	executeAll := func(flags ScriptFlags, batch *SchnorrBatch) error {
+		for i := range tx.TxIn {
+			vm, err := NewEngine(pkScript, tx, i, flags, nil, nil,
+				nil, amount)
+			if err != nil {
+				return err
+			}
+			vm.SetSchnorrBatch(batch)
+			if err := vm.Execute(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// All signatures are valid, so the batch verifies and adds them to
+	// the signature cache.
+	signInputs(-1)
+	sigCache := NewSigCache(numInputs)
+	batch := NewSchnorrBatch(sigCache)
+	if err := executeAll(StandardVerifyFlags, batch); err != nil {
+		t.Fatalf("unexpected error executing scripts: %v", err)
+	}
+	if err := batch.Verify(); err != nil {
+		t.Fatalf("unexpected error verifying batch: %v", err)
+	}
+	if len(sigCache.validSigs) != numInputs {
+		t.Fatalf("unexpected number of cached signatures - got %d, "+
+			"want %d", len(sigCache.validSigs), numInputs)
+	}
+
+	// The invalid signature is deferred to the batch, so the scripts
+	// execute successfully while verifying the batch fails.
+	signInputs(invalidIdx)
+	batch = NewSchnorrBatch(nil)
+	if err := executeAll(StandardVerifyFlags, batch); err != nil {
+		t.Fatalf("unexpected error executing scripts: %v", err)
+	}
+	if err := batch.Verify(); !IsErrorCode(err, ErrNullFail) {
+		t.Fatalf("unexpected error verifying batch - got %v, want %v",
+			err, ErrNullFail)
+	}
+
+	// Signatures are verified immediately without the sig checks flag.
+	batch = NewSchnorrBatch(nil)
+	flags := StandardVerifyFlags &^ ScriptReportSigChecks
+	if err := executeAll(flags, batch); !IsErrorCode(err, ErrNullFail) {
+		t.Fatalf("unexpected error executing scripts - got %v, want %v",
+			err, ErrNullFail)
+	}
+	if err := batch.Verify(); err != nil {
+		t.Fatalf("unexpected error verifying batch: %v", err)
+	}
+}
@@ -159,7 +159,14 @@ func ExtractRedeemScript(script []byte) ([]byte, error) {
 // template list for testing purposes.  When there are parse errors, it returns
 // the list of parsed opcodes up to the point of failure along with the error.
 func parseScriptTemplate(script []byte, opcodes *[256]opcode) ([]parsedOpcode, error) {
-	retScript := make([]parsedOpcode, 0, len(script))
+	return parseScriptTemplateInto(make([]parsedOpcode, 0, len(script)),
+		script, opcodes)
+}
+
+// parseScriptTemplateInto is the same as parseScriptTemplate except it appends
+// the parsed opcodes to the passed slice, which allows its storage to be
+// reused.
+func parseScriptTemplateInto(retScript []parsedOpcode, script []byte, opcodes *[256]opcode) ([]parsedOpcode, error) {
 	for i := 0; i < len(script); {
 		instr := script[i]
 		op := &opcodes[instr]
@@ -590,56 +597,127 @@ func calcLegacySignatureHash(script []parsedOpcode, hashType SigHashType, tx *wi
 	return chainhash.DoubleHashB(wbuf.Bytes()), totalBytesHashedlength, nil
 }
 
-// calcBip143SignatureHash computes the sighash digest of a transaction's
-// input using the new, optimized digest calculation algorithm defined
-// in BIP0143: https://github.com/bitcoin/bips/blob/master/bip-0143.mediawiki.
-// This function makes use of pre-calculated sighash fragments stored within
-// the passed HashCache to eliminate duplicate hashing computations when
-// calculating the final digest, reducing the complexity from O(N^2) to O(N).
-// Additionally, signatures now cover the input value of the referenced unspent
-// output. This allows offline, or hardware wallets to compute the exact amount
-// being spent, in addition to the final transaction fee. In the case the
-// wallet if fed an invalid input amount, the real sighash will differ causing
-// the produced signature to be invalid.
-func calcBip143SignatureHash(subScript []parsedOpcode, sigHashes *TxSigHashes,
-	hashType SigHashType, tx *wire.MsgTx, idx int, amt int64, scriptAllowCashTokens bool) ([]byte, int, error) {
+// SigHashPreimage houses the components of the BIP0143 signature hash preimage
+// of an input as modified by Bitcoin Cash.  The signature hash is the double
+// SHA256 of the serialized preimage.  The hashes are in the byte order they are
+// serialized in.
+type SigHashPreimage struct {
+	Version      int32
+	HashPrevOuts chainhash.Hash
+
+	// HashUtxos is the hash of the outputs spent by all inputs.  It is only
+	// part of the preimage when HasHashUtxos is true, which is the case for
+	// SIGHASH_UTXOS.
+	HashUtxos    chainhash.Hash
+	HasHashUtxos bool
+
+	HashSequence chainhash.Hash
+	PrevOut      wire.OutPoint
+
+	// TokenPrefix is the serialized token data of the spent output, or nil
+	// when it does not carry tokens.
+	TokenPrefix []byte
+
+	ScriptCode  []byte
+	Amount      int64
+	Sequence    uint32
+	HashOutputs chainhash.Hash
+	LockTime    uint32
+	HashType    SigHashType
+}
 
-	// This value is needed to calculate hash digest iterations after may 2025 upgrade.
-	totalBytesHashedlength := 0
+// Bytes returns the serialized preimage.
+func (p *SigHashPreimage) Bytes() []byte {
+	size := 4 + 32*4 + 36 + len(p.TokenPrefix) +
+		wire.VarIntSerializeSize(uint64(len(p.ScriptCode))) +
+		len(p.ScriptCode) + 8 + 4 + 4 + 4
+	if p.HasHashUtxos {
+		size += 32
+	}
+	b := make([]byte, 0, size)
+
+	b = binary.LittleEndian.AppendUint32(b, uint32(p.Version))
+	b = append(b, p.HashPrevOuts[:]...)
+	if p.HasHashUtxos {
+		b = append(b, p.HashUtxos[:]...)
+	}
+	b = append(b, p.HashSequence[:]...)
+	b = append(b, p.PrevOut.Hash[:]...)
+	b = binary.LittleEndian.AppendUint32(b, p.PrevOut.Index)
+	b = append(b, p.TokenPrefix...)
+
+	buf := bytes.NewBuffer(b)
+	wire.WriteVarBytes(buf, 0, p.ScriptCode)
+	b = buf.Bytes()
+
+	b = binary.LittleEndian.AppendUint64(b, uint64(p.Amount))
+	b = binary.LittleEndian.AppendUint32(b, p.Sequence)
+	b = append(b, p.HashOutputs[:]...)
+	b = binary.LittleEndian.AppendUint32(b, p.LockTime)
+	return binary.LittleEndian.AppendUint32(b, uint32(p.HashType))
+}
+
+// CalcSignatureHashPreimage returns the components of the BIP0143 signature
+// hash preimage of the input with the passed index, which allow external
+// signers to verify what they are signing.  The signature hash of the input
+// is the double SHA256 of the serialized preimage.
+//
+// The token data of the spent outputs and the hash of the outputs spent by all
+// inputs, which are required for SIGHASH_UTXOS, are taken from the passed
+// sighashes, so they must have been added to it with
+// AddTxSigHashUtxoFromUtxoCache.
+func CalcSignatureHashPreimage(script []byte, sigHashes *TxSigHashes, hType SigHashType,
+	tx *wire.MsgTx, idx int, amt int64) (*SigHashPreimage, error) {
+
+	parsedScript, err := parseScript(script)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse output script: %v", err)
+	}
+	if idx < 0 {
+		return nil, fmt.Errorf("idx %d is negative", idx)
+	}
+	preimage, err := calcBip143SigHashPreimage(parsedScript, sigHashes,
+		hType, tx, idx, amt, true)
+	if err != nil {
+		return nil, err
+	}
+	return &preimage, nil
+}
+
+// calcBip143SigHashPreimage returns the components of the signature hash
+// preimage of the input with the passed index as specified by BIP0143 and
+// modified by the CashTokens upgrade.
+func calcBip143SigHashPreimage(subScript []parsedOpcode, sigHashes *TxSigHashes,
+	hashType SigHashType, tx *wire.MsgTx, idx int, amt int64, scriptAllowCashTokens bool) (SigHashPreimage, error) {
 
 	// As a sanity check, ensure the passed input index for the transaction
 	// is valid.
 	if idx > len(tx.TxIn)-1 {
-		return nil, totalBytesHashedlength, fmt.Errorf("idx %d but %d txins", idx, len(tx.TxIn))
+		return SigHashPreimage{}, fmt.Errorf("idx %d but %d txins", idx, len(tx.TxIn))
 	}
 
-	// We'll utilize this buffer throughout to incrementally calculate
-	// the signature hash for this transaction.
-	var sigHash bytes.Buffer
-
-	// First write out, then encode the transaction's version number.
-	var bVersion [4]byte
-	binary.LittleEndian.PutUint32(bVersion[:], uint32(tx.Version))
-	sigHash.Write(bVersion[:])
-
-	// Next write out the possibly pre-calculated hashes for the sequence
-	// numbers of all inputs, and the hashes of the previous outs for all
-	// outputs.
-	var zeroHash chainhash.Hash
+	txIn := tx.TxIn[idx]
+	preimage := SigHashPreimage{
+		Version:  tx.Version,
+		PrevOut:  txIn.PreviousOutPoint,
+		Amount:   amt,
+		Sequence: txIn.Sequence,
+		LockTime: tx.LockTime,
+		HashType: hashType,
+	}
 
 	// If anyone can pay isn't active, then we can use the cached
 	// hashPrevOuts, otherwise we just write zeroes for the prev outs.
 	if hashType&SigHashAnyOneCanPay == 0 {
-		sigHash.Write(sigHashes.HashPrevOuts[:])
-	} else {
-		sigHash.Write(zeroHash[:])
+		preimage.HashPrevOuts = sigHashes.HashPrevOuts
 	}
 
 	// add CashTokens data here hashUtxos is a 32-byte double SHA256
 	// of the serialization of all UTXOs spent by the transaction's inputs,
 	// concatenated in input order, excluding output count.
 	if scriptAllowCashTokens && hashType&SigHashUTXO > 0 {
-		sigHash.Write(sigHashes.HashUTXOS[:])
+		preimage.HashUtxos = sigHashes.HashUTXOS
+		preimage.HasHashUtxos = true
 	}
 
 	// If the sighash isn't anyone can pay, single, or none, the use the
@@ -648,33 +726,14 @@ func calcBip143SignatureHash(subScript []parsedOpcode, sigHashes *TxSigHashes,
 	if hashType&SigHashAnyOneCanPay == 0 &&
 		hashType&sigHashMask != SigHashSingle &&
 		hashType&sigHashMask != SigHashNone {
-		sigHash.Write(sigHashes.HashSequence[:])
-	} else {
-		sigHash.Write(zeroHash[:])
+		preimage.HashSequence = sigHashes.HashSequence
 	}
 
-	// Next, write the outpoint being spent.
-	sigHash.Write(tx.TxIn[idx].PreviousOutPoint.Hash[:])
-	var bIndex [4]byte
-	binary.LittleEndian.PutUint32(bIndex[:], tx.TxIn[idx].PreviousOutPoint.Index)
-	sigHash.Write(bIndex[:])
-
 	if len(sigHashes.tokenDataList) > 0 && len(sigHashes.tokenDataList[idx]) > 0 {
-		sigHash.Write(sigHashes.tokenDataList[idx])
+		preimage.TokenPrefix = sigHashes.tokenDataList[idx]
 	}
 
-	scriptBytes, _ := unparseScript(subScript)
-
-	wire.WriteVarBytes(&sigHash, 0, scriptBytes)
-
-	// Next, add the input amount, and sequence number of the input being
-	// signed.
-	var bAmount [8]byte
-	binary.LittleEndian.PutUint64(bAmount[:], uint64(amt))
-	sigHash.Write(bAmount[:])
-	var bSequence [4]byte
-	binary.LittleEndian.PutUint32(bSequence[:], tx.TxIn[idx].Sequence)
-	sigHash.Write(bSequence[:])
+	preimage.ScriptCode, _ = unparseScript(subScript)
 
 	// If the current signature mode isn't single, or none, then we can
 	// re-use the pre-generated hashoutputs sighash fragment. Otherwise,
@@ -682,27 +741,40 @@ func calcBip143SignatureHash(subScript []parsedOpcode, sigHashes *TxSigHashes,
 	// pre-image.
 	if hashType&sigHashMask != SigHashSingle &&
 		hashType&sigHashMask != SigHashNone {
-		sigHash.Write(sigHashes.HashOutputs[:])
+		preimage.HashOutputs = sigHashes.HashOutputs
 	} else if hashType&sigHashMask == SigHashSingle && idx < len(tx.TxOut) {
 		var b bytes.Buffer
 		wire.WriteTxOut(&b, 0, 0, tx.TxOut[idx])
-		sigHash.Write(chainhash.DoubleHashB(b.Bytes()))
-	} else {
-		sigHash.Write(zeroHash[:])
+		preimage.HashOutputs = chainhash.DoubleHashH(b.Bytes())
 	}
 
-	// Finally, write out the transaction's locktime, and the sig hash
-	// type.
-	var bLockTime [4]byte
-	binary.LittleEndian.PutUint32(bLockTime[:], tx.LockTime)
-	sigHash.Write(bLockTime[:])
-	var bHashType [4]byte
-	binary.LittleEndian.PutUint32(bHashType[:], uint32(hashType))
-	sigHash.Write(bHashType[:])
+	return preimage, nil
+}
+
+// calcBip143SignatureHash computes the sighash digest of a transaction's
+// input using the new, optimized digest calculation algorithm defined
+// in BIP0143: https://github.com/bitcoin/bips/blob/master/bip-0143.mediawiki.
+// This function makes use of pre-calculated sighash fragments stored within
+// the passed HashCache to eliminate duplicate hashing computations when
+// calculating the final digest, reducing the complexity from O(N^2) to O(N).
+// Additionally, signatures now cover the input value of the referenced unspent
+// output. This allows offline, or hardware wallets to compute the exact amount
+// being spent, in addition to the final transaction fee. In the case the
+// wallet if fed an invalid input amount, the real sighash will differ causing
+// the produced signature to be invalid.
+func calcBip143SignatureHash(subScript []parsedOpcode, sigHashes *TxSigHashes,
+	hashType SigHashType, tx *wire.MsgTx, idx int, amt int64, scriptAllowCashTokens bool) ([]byte, int, error) {
 
-	totalBytesHashedlength += sigHash.Len()
+	preimage, err := calcBip143SigHashPreimage(subScript, sigHashes,
+		hashType, tx, idx, amt, scriptAllowCashTokens)
+	if err != nil {
+		return nil, 0, err
+	}
 
-	return chainhash.DoubleHashB(sigHash.Bytes()), totalBytesHashedlength, nil
+	// The serialized length is needed to calculate hash digest iterations
+	// after the May 2025 upgrade.
+	b := preimage.Bytes()
+	return chainhash.DoubleHashB(b), len(b), nil
 }
 
 // asSmallInt returns the passed opcode, which must be true according to
@@ -0,0 +1,86 @@
+package txscript
+
+import (
+	"sync"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+)
+
+// scriptCacheKey identifies the validation of the scripts of a transaction
+// under a set of script flags.
+type scriptCacheKey struct {
+	txHash chainhash.Hash
+	flags  ScriptFlags
+}
+
+// ScriptCache implements a script verification cache with a randomized entry
+// eviction policy.  It records the transactions whose scripts are known to be
+// valid under a set of script flags along with the number of signature checks
+// they perform.  Only transactions whose scripts are valid for all of their
+// inputs will be added to the cache.
+//
+// Since the scripts of a transaction only depend on the transaction itself and
+// the outputs it spends, which are committed to by its hash, the scripts of a
+// transaction which was already validated when it was accepted to the mempool
+// need not be executed again when it is included in a block validated with the
+// same script flags.
+type ScriptCache struct {
+	sync.RWMutex
+	validTxs   map[scriptCacheKey]uint32
+	maxEntries uint
+}
+
+// NewScriptCache creates and initializes a new instance of ScriptCache.  Its
+// sole parameter 'maxEntries' represents the maximum number of entries allowed
+// to exist in the ScriptCache at any particular moment.  Random entries are
+// evicted to make room for new entries that would cause the number of entries
+// in the cache to exceed the max.
+func NewScriptCache(maxEntries uint) *ScriptCache {
+	return &ScriptCache{
+		validTxs:   make(map[scriptCacheKey]uint32, maxEntries),
+		maxEntries: maxEntries,
+	}
+}
+
+// Lookup returns the number of signature checks performed by the scripts of
+// the transaction with the passed hash and whether they are known to be valid
+// under the passed script flags.
+//
+// NOTE: This function is safe for concurrent access. Readers won't be blocked
+// unless there exists a writer, adding an entry to the ScriptCache.
+func (s *ScriptCache) Lookup(txHash *chainhash.Hash, flags ScriptFlags) (uint32, bool) {
+	s.RLock()
+	sigChecks, ok := s.validTxs[scriptCacheKey{*txHash, flags}]
+	s.RUnlock()
+
+	return sigChecks, ok
+}
+
+// Add adds an entry for the transaction with the passed hash, whose scripts
+// are valid under the passed script flags and perform the passed number of
+// signature checks, to the script cache.  In the event that the ScriptCache is
+// 'full', an existing entry is randomly chosen to be evicted in order to make
+// space for the new entry.
+//
+// NOTE: This function is safe for concurrent access. Writers will block
+// simultaneous readers until function execution has concluded.
+func (s *ScriptCache) Add(txHash *chainhash.Hash, flags ScriptFlags, sigChecks uint32) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.maxEntries <= 0 {
+		return
+	}
+
+	key := scriptCacheKey{*txHash, flags}
+	if _, ok := s.validTxs[key]; !ok && uint(len(s.validTxs)+1) > s.maxEntries {
+		// Remove a random entry from the map.  See the SigCache for
+		// the rationale of relying on the random starting point of
+		// Go's map iteration.
+		for entry := range s.validTxs {
+			delete(s.validTxs, entry)
+			break
+		}
+	}
+	s.validTxs[key] = sigChecks
+}
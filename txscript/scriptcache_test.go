@@ -0,0 +1,54 @@
+package txscript
+
+import (
+	"testing"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+)
+
+// TestScriptCache ensures the script cache only reports transactions as valid
+// under the exact script flags they were added with and evicts entries once
+// it is full.
+func TestScriptCache(t *testing.T) {
+	scriptCache := NewScriptCache(2)
+
+	tx1, tx2, tx3 := chainhash.Hash{0x01}, chainhash.Hash{0x02}, chainhash.Hash{0x03}
+	flags := ScriptBip16 | ScriptVerifyStrictEncoding
+	scriptCache.Add(&tx1, flags, 4)
+
+	sigChecks, ok := scriptCache.Lookup(&tx1, flags)
+	if !ok || sigChecks != 4 {
+		t.Fatalf("Lookup: got %d sig checks (found %v), want 4 (found "+
+			"true)", sigChecks, ok)
+	}
+	if _, ok := scriptCache.Lookup(&tx1, flags|ScriptVerifyCleanStack); ok {
+		t.Fatal("Lookup: found entry for different script flags")
+	}
+	if _, ok := scriptCache.Lookup(&tx2, flags); ok {
+		t.Fatal("Lookup: found entry for unknown transaction")
+	}
+
+	// Adding an entry which is already in the cache must not evict any
+	// other entry.
+	scriptCache.Add(&tx2, flags, 1)
+	scriptCache.Add(&tx2, flags, 1)
+	if _, ok := scriptCache.Lookup(&tx1, flags); !ok {
+		t.Fatal("Lookup: entry evicted by existing entry")
+	}
+
+	scriptCache.Add(&tx3, flags, 0)
+	if len(scriptCache.validTxs) != 2 {
+		t.Fatalf("cache holds %d entries, want 2",
+			len(scriptCache.validTxs))
+	}
+	if _, ok := scriptCache.Lookup(&tx3, flags); !ok {
+		t.Fatal("Lookup: newly added entry not found")
+	}
+
+	// A cache with a max size of zero must not hold any entries.
+	scriptCache = NewScriptCache(0)
+	scriptCache.Add(&tx1, flags, 0)
+	if _, ok := scriptCache.Lookup(&tx1, flags); ok {
+		t.Fatal("Lookup: found entry in cache with max size of zero")
+	}
+}
@@ -159,9 +159,9 @@ func (s *stack) nipN(idx int32) ([]byte, error) {
 	if idx == 0 {
 		s.stk = s.stk[:sz-1]
 	} else if idx == sz-1 {
-		s1 := make([][]byte, sz-1)
-		copy(s1, s.stk[1:])
-		s.stk = s1
+		copy(s.stk, s.stk[1:])
+		s.stk[sz-1] = nil
+		s.stk = s.stk[:sz-1]
 	} else {
 		s1 := s.stk[sz-idx : sz]
 		s.stk = s.stk[:sz-idx-1]
@@ -149,9 +149,17 @@ func isNullData(pops []parsedOpcode) bool {
 	//
 	// Multiple pushes of data are allowed but the total script
 	// may not exceed MaxDataCarrierSize.
+	scriptLen, ok := nullDataScriptLen(pops)
+	return ok && scriptLen <= MaxDataCarrierSize
+}
+
+// nullDataScriptLen returns whether the passed script is an OP_RETURN followed
+// only by data pushes along with the length of the script, regardless of how
+// much data it carries.
+func nullDataScriptLen(pops []parsedOpcode) (int, bool) {
 	l := len(pops)
 	if l < 1 || pops[0].opcode.value != OP_RETURN {
-		return false
+		return 0, false
 	}
 
 	scriptLen := 1
@@ -172,10 +180,23 @@ func isNullData(pops []parsedOpcode) bool {
 			scriptLen++
 		}
 		if !isDataOpcode {
-			return false
+			return 0, false
 		}
 	}
-	return scriptLen <= MaxDataCarrierSize
+	return scriptLen, true
+}
+
+// IsNullDataScript returns whether the passed script is an OP_RETURN followed
+// only by data pushes.  Unlike GetScriptClass, which only classifies such
+// scripts as NullDataTy when they carry at most MaxDataCarrierSize bytes, it
+// does not limit the amount of data, so callers may apply their own limit.
+func IsNullDataScript(script []byte) bool {
+	pops, err := parseScript(script)
+	if err != nil {
+		return false
+	}
+	_, ok := nullDataScriptLen(pops)
+	return ok
 }
 
 // scriptType returns the type of the script being inspected from the known
@@ -1167,3 +1167,54 @@ func TestNullDataScript(t *testing.T) {
 		}
 	}
 }
+
+// TestIsNullDataScript ensures IsNullDataScript identifies null data scripts
+// regardless of the amount of data they carry.
+func TestIsNullDataScript(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		script   []byte
+		expected bool
+	}{
+		{
+			name:     "bare OP_RETURN",
+			script:   mustParseShortForm("RETURN"),
+			expected: true,
+		},
+		{
+			name:     "multiple pushes",
+			script:   mustParseShortForm("RETURN 1 DATA_2 0x6d01"),
+			expected: true,
+		},
+		{
+			name: "push over MaxDataCarrierSize",
+			script: append([]byte{OP_RETURN, OP_PUSHDATA2, 0x00, 0x01},
+				make([]byte, 256)...),
+			expected: true,
+		},
+		{
+			name:     "non push opcode",
+			script:   mustParseShortForm("RETURN DUP"),
+			expected: false,
+		},
+		{
+			name:     "no OP_RETURN",
+			script:   mustParseShortForm("DATA_2 0x6d01"),
+			expected: false,
+		},
+		{
+			name:     "truncated push",
+			script:   []byte{OP_RETURN, OP_DATA_2, 0x6d},
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		if got := IsNullDataScript(test.script); got != test.expected {
+			t.Errorf("%s: unexpected result -- got %v, want %v",
+				test.name, got, test.expected)
+		}
+	}
+}
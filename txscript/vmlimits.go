@@ -64,6 +64,11 @@ func GetDigestIterationCount(messageLength int, isDouble bool) int {
 	return iterationCount
 }
 
+// ScriptExecutionMetrics houses the costs accumulated while executing the
+// scripts of a single input which are limited by the VM limits rules.  The
+// metrics are accumulated regardless of whether the rules are enforced, so
+// callers may also use them for policy purposes, such as prioritizing
+// transactions by the cost of validating them.
 type ScriptExecutionMetrics struct {
 	// CHIP-2021-05 VM Limits: Targeted Virtual Machine Limits
 	numSigChecks            int
@@ -75,8 +80,18 @@ type ScriptExecutionMetrics struct {
 	hashDigestIterationsLimit int64
 }
 
+// NewScriptExecutionMetrics returns empty metrics for an input with a
+// signature script of the passed size, whose limits are derived from that size
+// and whether the standard limits apply.
 func NewScriptExecutionMetrics(scriptSigSize int, isStandard bool) *ScriptExecutionMetrics {
-	return &ScriptExecutionMetrics{
+	metrics := makeScriptExecutionMetrics(scriptSigSize, isStandard)
+	return &metrics
+}
+
+// makeScriptExecutionMetrics returns the same metrics as
+// NewScriptExecutionMetrics without allocating them.
+func makeScriptExecutionMetrics(scriptSigSize int, isStandard bool) ScriptExecutionMetrics {
+	return ScriptExecutionMetrics{
 		numSigChecks:            0,
 		numOpCost:               0,
 		numHashDigestIterations: 0,
@@ -86,30 +101,51 @@ func NewScriptExecutionMetrics(scriptSigSize int, isStandard bool) *ScriptExecut
 	}
 }
 
+// AddOPCost adds the passed base operation cost.
 func (scriptExecutionMetrics *ScriptExecutionMetrics) AddOPCost(cost int) {
 	scriptExecutionMetrics.numOpCost += int64(cost)
 }
 
+// AddHashCost adds the digest iterations needed to hash a message of the
+// passed length, hashing it twice if isDouble is set.
 func (scriptExecutionMetrics *ScriptExecutionMetrics) AddHashCost(messageLength int, isDouble bool) {
 	scriptExecutionMetrics.numHashDigestIterations += int64(GetDigestIterationCount(messageLength, isDouble))
 }
 
+// AddNumSigChecks adds the passed number of signature checks.
 func (scriptExecutionMetrics *ScriptExecutionMetrics) AddNumSigChecks(numScigChecks int) {
 	scriptExecutionMetrics.numSigChecks += numScigChecks
 }
 
+// GetNumSigChecks returns the number of signature checks.
+func (scriptExecutionMetrics *ScriptExecutionMetrics) GetNumSigChecks() int {
+	return scriptExecutionMetrics.numSigChecks
+}
+
+// GetBaseOpCost returns the operation cost excluding the cost of hashing and
+// signature checks.
+func (scriptExecutionMetrics *ScriptExecutionMetrics) GetBaseOpCost() int64 {
+	return scriptExecutionMetrics.numOpCost
+}
+
+// GetMaxOpCostLimit returns the composite operation cost limit of the input.
 func (scriptExecutionMetrics *ScriptExecutionMetrics) GetMaxOpCostLimit() int64 {
 	return scriptExecutionMetrics.opCostLimit
 }
 
+// GetMaxDigestIterationLimit returns the hash digest iteration limit of the
+// input.
 func (scriptExecutionMetrics *ScriptExecutionMetrics) GetMaxDigestIterationLimit() int64 {
 	return scriptExecutionMetrics.hashDigestIterationsLimit
 }
 
+// GetHashDigestIterations returns the number of hash digest iterations.
 func (scriptExecutionMetrics *ScriptExecutionMetrics) GetHashDigestIterations() int64 {
 	return scriptExecutionMetrics.numHashDigestIterations
 }
 
+// GetCompositeOPCost returns the operation cost including the cost of hashing,
+// which is higher when the standard limits apply, and signature checks.
 func (scriptExecutionMetrics *ScriptExecutionMetrics) GetCompositeOPCost(isStandard bool) int64 {
 	hashIterFactor := GetHashIterationCostFactor(isStandard)
 
@@ -125,10 +161,14 @@ func (scriptExecutionMetrics *ScriptExecutionMetrics) GetCompositeOPCost(isStand
 	return compositeCost
 }
 
+// IsOverOpCostLimit returns whether the composite operation cost exceeds the
+// limit of the input.
 func (scriptExecutionMetrics *ScriptExecutionMetrics) IsOverOpCostLimit(isStandard bool) bool {
 	return scriptExecutionMetrics.GetCompositeOPCost(isStandard) > scriptExecutionMetrics.opCostLimit
 }
 
+// IsOverHashIterationsLimit returns whether the number of hash digest
+// iterations exceeds the limit of the input.
 func (scriptExecutionMetrics *ScriptExecutionMetrics) IsOverHashIterationsLimit(isStandard bool) bool {
 	return scriptExecutionMetrics.numHashDigestIterations > scriptExecutionMetrics.hashDigestIterationsLimit
 }
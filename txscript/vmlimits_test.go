@@ -663,10 +663,15 @@ func TestVMlimitsAndBigInt2025Standard(t *testing.T) {
 				fatalf(t, "operation cost did not match. "+str, test, i)
 			}
 
-			if vm.GetMetrics().GetHashDigestIterations() != densityControl {
-				if err != nil {
-					fatalf(t, "number of hash digest iterations did not match", test, i)
-				}
+			// The density control length is the size of the input
+			// the hashing limit is derived from.
+			hashIterationsLimit := int64(txscript.GetInputHashIterationsLimit(
+				int(densityControl)-txscript.InputScriptSizeFixedCredit, true))
+			if vm.GetMetrics().GetMaxDigestIterationLimit() != hashIterationsLimit {
+				str := fmt.Sprintf("test hash iterations limit: %d, script hash iterations limit: %d",
+					hashIterationsLimit,
+					vm.GetMetrics().GetMaxDigestIterationLimit())
+				fatalf(t, "hash digest iterations limit did not match. "+str, test, i)
 			}
 
 			if vm.GetMetrics().GetMaxOpCostLimit() != maximumOperationCost {
@@ -836,10 +841,15 @@ func TestVMlimitsAndBigInt2025NonStandard(t *testing.T) {
 				fatalf(t, "operation cost did not match. "+str, test, i)
 			}
 
-			if vm.GetMetrics().GetHashDigestIterations() != densityControl {
-				if err != nil {
-					fatalf(t, "number of hash digest iterations did not match", test, i)
-				}
+			// The density control length is the size of the input
+			// the hashing limit is derived from.
+			hashIterationsLimit := int64(txscript.GetInputHashIterationsLimit(
+				int(densityControl)-txscript.InputScriptSizeFixedCredit, false))
+			if vm.GetMetrics().GetMaxDigestIterationLimit() != hashIterationsLimit {
+				str := fmt.Sprintf("test hash iterations limit: %d, script hash iterations limit: %d",
+					hashIterationsLimit,
+					vm.GetMetrics().GetMaxDigestIterationLimit())
+				fatalf(t, "hash digest iterations limit did not match. "+str, test, i)
 			}
 
 			if vm.GetMetrics().GetMaxOpCostLimit() != maximumOperationCost {
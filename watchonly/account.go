@@ -0,0 +1,394 @@
+package watchonly
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+	"github.com/gcash/bchutil/hdkeychain"
+)
+
+const (
+	// ExternalBranch is the branch of an account from which the addresses
+	// handed out to receive payments are derived.
+	ExternalBranch uint32 = 0
+
+	// InternalBranch is the branch of an account from which change
+	// addresses are derived.
+	InternalBranch uint32 = 1
+
+	// DefaultGapLimit is the default number of consecutive unused addresses
+	// on a branch after which no further addresses are watched.
+	DefaultGapLimit = 20
+
+	// MaxGapLimit is the maximum allowed gap limit of an account.
+	MaxGapLimit = 1000
+
+	// serializedAccountHeaderSize is the size of the fixed part of a
+	// serialized account: the gap limit, the next unused index of both
+	// branches, and the lengths of the extended public key and the
+	// receive descriptor.
+	serializedAccountHeaderSize = 20
+)
+
+var (
+	// byteOrder is the preferred byte order used for serializing accounts.
+	byteOrder = binary.LittleEndian
+
+	// ErrPrivateKey is returned when an extended private key is passed
+	// where an extended public key is expected.
+	ErrPrivateKey = errors.New("extended private keys are not accepted " +
+		"by watch-only accounts")
+)
+
+// address houses an address of an account along with the branch and index it
+// was derived at.
+type address struct {
+	addr     bchutil.Address
+	pkScript []byte
+	branch   uint32
+	index    uint32
+}
+
+// Account is a watch-only account which derives its addresses from a pair of
+// ranged output script descriptors.  Receive addresses are derived from the
+// descriptor of the external branch and change addresses from the descriptor
+// of the internal branch.  Accounts without a change descriptor pay change to
+// receive addresses.
+//
+// Accounts created from an extended public key, typically the one of a BIP0044
+// account (m/44'/145'/n'), use pay-to-pubkey-hash descriptors for its first
+// and second child.
+//
+// Addresses are derived up to the gap limit past the last used address of each
+// branch, so funds sent to any of them are tracked.
+type Account struct {
+	name        string
+	xpub        string
+	descriptors [2]*txscript.Descriptor
+	gapLimit    uint32
+	params      *chaincfg.Params
+
+	// nextIndex is the index following the last used or handed out address
+	// of each branch.
+	nextIndex [2]uint32
+
+	// addrs contains all of the derived addresses of each branch in order
+	// of their index.
+	addrs [2][]*address
+
+	// scripts maps the public key script of every derived address to the
+	// address.
+	scripts map[string]*address
+}
+
+// newAccount returns a new account with the passed name which derives its
+// addresses from the passed serialized extended public key.
+func newAccount(name, xpub string, gapLimit uint32, params *chaincfg.Params) (*Account, error) {
+	key, err := hdkeychain.NewKeyFromString(xpub)
+	if err != nil {
+		return nil, err
+	}
+	if key.IsPrivate() {
+		return nil, ErrPrivateKey
+	}
+	if !key.IsForNet(params) {
+		return nil, fmt.Errorf("extended public key is not for %s",
+			params.Name)
+	}
+
+	a, err := newDescriptorAccount(name,
+		fmt.Sprintf("pkh(%s/%d/*)", xpub, ExternalBranch),
+		fmt.Sprintf("pkh(%s/%d/*)", xpub, InternalBranch),
+		gapLimit, params)
+	if err != nil {
+		return nil, err
+	}
+	a.xpub = xpub
+	return a, nil
+}
+
+// multisigDescriptors returns the receive and change descriptors of an account
+// whose addresses are pay-to-script-hash addresses of multisig scripts which
+// require the passed number of signatures from the keys derived from the
+// passed serialized extended public keys.  The keys are sorted within each
+// script, so the order of the extended public keys does not matter.  They may
+// be preceded by key origin information.
+func multisigDescriptors(required int, xpubs []string) (string, string, error) {
+	if len(xpubs) == 0 {
+		return "", "", errors.New("no extended public keys specified")
+	}
+	if required < 1 || required > len(xpubs) {
+		return "", "", fmt.Errorf("number of required signatures %d is "+
+			"out of range (min: 1, max: %d)", required, len(xpubs))
+	}
+
+	var descs [2]string
+	for branch := range descs {
+		keys := make([]string, 0, len(xpubs))
+		for _, xpub := range xpubs {
+			keys = append(keys, fmt.Sprintf("%s/%d/*", xpub, branch))
+		}
+		descs[branch] = fmt.Sprintf("sh(sortedmulti(%d,%s))", required,
+			strings.Join(keys, ","))
+	}
+	return descs[ExternalBranch], descs[InternalBranch], nil
+}
+
+// newDescriptorAccount returns a new account with the passed name which derives
+// its receive addresses from the passed receive descriptor and its change
+// addresses from the passed change descriptor, which may be empty.
+func newDescriptorAccount(name, receive, change string, gapLimit uint32, params *chaincfg.Params) (*Account, error) {
+	if name == "" {
+		return nil, errors.New("account name must not be empty")
+	}
+	if gapLimit == 0 || gapLimit > MaxGapLimit {
+		return nil, fmt.Errorf("gap limit of %d is out of range "+
+			"(min: 1, max: %d)", gapLimit, MaxGapLimit)
+	}
+
+	a := &Account{
+		name:     name,
+		gapLimit: gapLimit,
+		params:   params,
+		scripts:  make(map[string]*address),
+	}
+	for branch, desc := range []string{receive, change} {
+		if desc == "" && uint32(branch) == InternalBranch {
+			continue
+		}
+		d, err := txscript.ParseDescriptor(desc, params)
+		if err != nil {
+			return nil, err
+		}
+		if !d.IsRange() {
+			return nil, fmt.Errorf("descriptor %s is not ranged", desc)
+		}
+		a.descriptors[branch] = d
+	}
+	if err := a.extend(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Name returns the name of the account.
+func (a *Account) Name() string {
+	return a.name
+}
+
+// ExtendedPublicKey returns the serialized extended public key the account was
+// created from.  It is empty for accounts created from descriptors.
+func (a *Account) ExtendedPublicKey() string {
+	return a.xpub
+}
+
+// Descriptors returns the receive and change descriptors the addresses of the
+// account are derived from.  The change descriptor is empty when change is
+// paid to receive addresses.
+func (a *Account) Descriptors() (string, string) {
+	var change string
+	if a.descriptors[InternalBranch] != nil {
+		change = a.descriptors[InternalBranch].String()
+	}
+	return a.descriptors[ExternalBranch].String(), change
+}
+
+// GapLimit returns the number of consecutive unused addresses on each branch
+// which are watched.
+func (a *Account) GapLimit() uint32 {
+	return a.gapLimit
+}
+
+// derive returns the address at the passed index of the passed branch.
+func (a *Account) derive(branch, index uint32) (*address, error) {
+	desc := a.descriptors[branch]
+	pkScript, err := desc.Script(index)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := desc.DeriveAddresses(index, index)
+	if err != nil {
+		return nil, err
+	}
+	return &address{
+		addr:     addrs[0],
+		pkScript: pkScript,
+		branch:   branch,
+		index:    index,
+	}, nil
+}
+
+// changeBranch returns the branch change addresses are derived from.
+func (a *Account) changeBranch() uint32 {
+	if a.descriptors[InternalBranch] == nil {
+		return ExternalBranch
+	}
+	return InternalBranch
+}
+
+// inputSize returns the estimated serialized size of an input which spends an
+// output paying to the passed address.
+func (a *Account) inputSize(addr *address) (int, error) {
+	sigScriptSize, err := a.descriptors[addr.branch].MaxSigScriptSize(
+		addr.index)
+	if err != nil {
+		return 0, err
+	}
+	return txInOverheadSize + wire.VarIntSerializeSize(uint64(sigScriptSize)) +
+		sigScriptSize, nil
+}
+
+// extend derives the addresses of each branch up to the gap limit past the
+// next unused index.
+func (a *Account) extend() error {
+	for branch := range a.addrs {
+		if a.descriptors[branch] == nil {
+			continue
+		}
+		end := a.nextIndex[branch] + a.gapLimit
+		for i := uint32(len(a.addrs[branch])); i < end; i++ {
+			addr, err := a.derive(uint32(branch), i)
+			if err == hdkeychain.ErrInvalidChild {
+				// Skip the invalid child as described by
+				// BIP0032, which happens with a probability of
+				// less than 1 in 2^127.
+				a.addrs[branch] = append(a.addrs[branch], nil)
+				end++
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			a.addrs[branch] = append(a.addrs[branch], addr)
+			a.scripts[string(addr.pkScript)] = addr
+		}
+	}
+	return nil
+}
+
+// markUsed records that the address at the passed index of the passed branch
+// has been used and derives additional addresses as needed to maintain the gap
+// limit.  It returns whether the next unused index has changed.
+func (a *Account) markUsed(branch, index uint32) (bool, error) {
+	if index < a.nextIndex[branch] {
+		return false, nil
+	}
+	a.nextIndex[branch] = index + 1
+	return true, a.extend()
+}
+
+// nextAddress returns the next unused address of the passed branch and marks it
+// as handed out so it is not returned again.
+func (a *Account) nextAddress(branch uint32) (*address, error) {
+	for {
+		index := a.nextIndex[branch]
+		if _, err := a.markUsed(branch, index); err != nil {
+			return nil, err
+		}
+		if addr := a.addrs[branch][index]; addr != nil {
+			return addr, nil
+		}
+	}
+}
+
+// lookupScript returns the derived address the passed public key script pays
+// to or nil when it does not belong to the account.
+func (a *Account) lookupScript(pkScript []byte) *address {
+	return a.scripts[string(pkScript)]
+}
+
+// watchedAddresses returns all of the derived addresses of the account.
+func (a *Account) watchedAddresses() []*address {
+	addrs := make([]*address, 0, len(a.scripts))
+	for branch := range a.addrs {
+		for _, addr := range a.addrs[branch] {
+			if addr != nil {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs
+}
+
+// serialize returns the account serialized for storage in the database.  The
+// name of the account is the key it is stored under, so it is not included.
+//
+// The serialized format is:
+//
+//	<gap limit><next external index><next internal index><xpub len>
+//	<receive descriptor len><xpub><receive descriptor><change descriptor>
+//
+//	Field                    Type      Size
+//	gap limit                uint32    4
+//	next external index      uint32    4
+//	next internal index      uint32    4
+//	xpub len                 uint32    4
+//	receive descriptor len   uint32    4
+//	xpub                     string    variable
+//	receive descriptor       string    variable
+//	change descriptor        string    variable
+//
+// The xpub is empty for accounts created from descriptors, and the descriptors
+// are empty for accounts created from an xpub.
+func (a *Account) serialize() []byte {
+	var receive, change string
+	if a.xpub == "" {
+		receive, change = a.Descriptors()
+	}
+	serialized := make([]byte, serializedAccountHeaderSize+len(a.xpub)+
+		len(receive)+len(change))
+	byteOrder.PutUint32(serialized[0:4], a.gapLimit)
+	byteOrder.PutUint32(serialized[4:8], a.nextIndex[ExternalBranch])
+	byteOrder.PutUint32(serialized[8:12], a.nextIndex[InternalBranch])
+	byteOrder.PutUint32(serialized[12:16], uint32(len(a.xpub)))
+	byteOrder.PutUint32(serialized[16:20], uint32(len(receive)))
+	offset := serializedAccountHeaderSize
+	offset += copy(serialized[offset:], a.xpub)
+	offset += copy(serialized[offset:], receive)
+	copy(serialized[offset:], change)
+	return serialized
+}
+
+// deserializeAccount decodes an account with the passed name from the passed
+// serialized bytes which must be in the format returned by serialize.
+func deserializeAccount(name string, serialized []byte, params *chaincfg.Params) (*Account, error) {
+	if len(serialized) < serializedAccountHeaderSize {
+		return nil, fmt.Errorf("corrupt watch-only account %q", name)
+	}
+	gapLimit := byteOrder.Uint32(serialized[0:4])
+	xpubLen := byteOrder.Uint32(serialized[12:16])
+	receiveLen := byteOrder.Uint32(serialized[16:20])
+	rest := serialized[serializedAccountHeaderSize:]
+	if uint64(xpubLen)+uint64(receiveLen) > uint64(len(rest)) {
+		return nil, fmt.Errorf("corrupt watch-only account %q", name)
+	}
+
+	var (
+		a   *Account
+		err error
+	)
+	if xpubLen > 0 {
+		a, err = newAccount(name, string(rest[:xpubLen]), gapLimit,
+			params)
+	} else {
+		receive := string(rest[:receiveLen])
+		change := string(rest[receiveLen:])
+		a, err = newDescriptorAccount(name, receive, change, gapLimit,
+			params)
+	}
+	if err != nil {
+		return nil, err
+	}
+	a.nextIndex[ExternalBranch] = byteOrder.Uint32(serialized[4:8])
+	a.nextIndex[InternalBranch] = byteOrder.Uint32(serialized[8:12])
+	if err := a.extend(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
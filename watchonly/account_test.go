@@ -0,0 +1,145 @@
+package watchonly
+
+import (
+	"testing"
+
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchutil"
+	"github.com/gcash/bchutil/hdkeychain"
+)
+
+const (
+	// testXpub is the master extended public key of the first BIP0032 test
+	// vector.
+	testXpub = "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8"
+
+	// testXprv is the master extended private key of the first BIP0032
+	// test vector.
+	testXprv = "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"
+)
+
+// TestAccountDerivation ensures that accounts derive their addresses from the
+// expected branches, maintain the gap limit, and reject invalid keys.
+func TestAccountDerivation(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	a, err := newAccount("test", testXpub, 5, params)
+	if err != nil {
+		t.Fatalf("newAccount: unexpected error: %v", err)
+	}
+
+	key, err := hdkeychain.NewKeyFromString(testXpub)
+	if err != nil {
+		t.Fatalf("NewKeyFromString: unexpected error: %v", err)
+	}
+	for _, branch := range []uint32{ExternalBranch, InternalBranch} {
+		if len(a.addrs[branch]) != 5 {
+			t.Fatalf("branch %d: got %d addresses, want 5", branch,
+				len(a.addrs[branch]))
+		}
+		branchKey, _ := key.Child(branch)
+		for i, addr := range a.addrs[branch] {
+			child, _ := branchKey.Child(uint32(i))
+			want, _ := child.Address(params)
+			if addr.addr.String() != want.String() {
+				t.Fatalf("branch %d index %d: got address %s, "+
+					"want %s", branch, i, addr.addr, want)
+			}
+			if a.lookupScript(addr.pkScript) != addr {
+				t.Fatalf("branch %d index %d: script lookup "+
+					"failed", branch, i)
+			}
+		}
+	}
+
+	// Handing out addresses must never repeat them and must extend the
+	// branch to maintain the gap limit.
+	seen := make(map[string]struct{})
+	for i := 0; i < 7; i++ {
+		addr, err := a.nextAddress(ExternalBranch)
+		if err != nil {
+			t.Fatalf("nextAddress: unexpected error: %v", err)
+		}
+		if _, ok := seen[addr.addr.String()]; ok {
+			t.Fatalf("nextAddress: address %s returned twice",
+				addr.addr)
+		}
+		seen[addr.addr.String()] = struct{}{}
+	}
+	if len(a.addrs[ExternalBranch]) != 12 {
+		t.Fatalf("got %d external addresses, want 12",
+			len(a.addrs[ExternalBranch]))
+	}
+	if len(a.addrs[InternalBranch]) != 5 {
+		t.Fatalf("got %d internal addresses, want 5",
+			len(a.addrs[InternalBranch]))
+	}
+
+	// Ensure the account survives a round trip through its serialized
+	// form.
+	b, err := deserializeAccount("test", a.serialize(), params)
+	if err != nil {
+		t.Fatalf("deserializeAccount: unexpected error: %v", err)
+	}
+	if b.ExtendedPublicKey() != testXpub || b.GapLimit() != 5 ||
+		b.nextIndex != a.nextIndex || len(b.scripts) != len(a.scripts) {
+		t.Fatalf("deserialized account does not match: got %v, want %v",
+			b.nextIndex, a.nextIndex)
+	}
+
+	// Private keys, keys for other networks, and bad gap limits must be
+	// rejected.
+	if _, err := newAccount("test", testXprv, 5, params); err != ErrPrivateKey {
+		t.Fatalf("newAccount: got error %v, want %v", err, ErrPrivateKey)
+	}
+	if _, err := newAccount("test", testXpub, 5, &chaincfg.TestNet3Params); err == nil {
+		t.Fatal("newAccount: expected error for key of other network")
+	}
+	if _, err := newAccount("test", testXpub, MaxGapLimit+1, params); err == nil {
+		t.Fatal("newAccount: expected error for gap limit out of range")
+	}
+}
+
+// TestDescriptorAccount ensures accounts created from descriptors derive their
+// addresses from the descriptors and pay change to receive addresses when they
+// do not have a change descriptor.
+func TestDescriptorAccount(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	receive := "sh(sortedmulti(1," + testXpub + "/0/*," + testXpub + "/1/*))"
+	a, err := newDescriptorAccount("multisig", receive, "", 3, params)
+	if err != nil {
+		t.Fatalf("newDescriptorAccount: unexpected error: %v", err)
+	}
+	if len(a.addrs[ExternalBranch]) != 3 || len(a.addrs[InternalBranch]) != 0 {
+		t.Fatalf("got %d external and %d internal addresses, want 3 and 0",
+			len(a.addrs[ExternalBranch]), len(a.addrs[InternalBranch]))
+	}
+	if a.changeBranch() != ExternalBranch {
+		t.Fatal("change is not paid to the external branch")
+	}
+	for _, addr := range a.addrs[ExternalBranch] {
+		if _, ok := addr.addr.(*bchutil.AddressScriptHash); !ok {
+			t.Fatalf("got address %s of type %T, want script hash",
+				addr.addr, addr.addr)
+		}
+	}
+
+	b, err := deserializeAccount("multisig", a.serialize(), params)
+	if err != nil {
+		t.Fatalf("deserializeAccount: unexpected error: %v", err)
+	}
+	gotReceive, gotChange := b.Descriptors()
+	wantReceive, _ := a.Descriptors()
+	if gotReceive != wantReceive || gotChange != "" ||
+		b.ExtendedPublicKey() != "" {
+		t.Fatalf("deserialized account does not match: got %s %q",
+			gotReceive, gotChange)
+	}
+
+	// Descriptors which are not ranged or have no address are rejected.
+	if _, err := newDescriptorAccount("bad", "pkh("+testXpub+"/0/1)", "", 3, params); err == nil {
+		t.Fatal("newDescriptorAccount: expected error for unranged descriptor")
+	}
+	if _, err := newDescriptorAccount("bad", "multi(1,"+testXpub+"/0/*)", "", 3, params); err == nil {
+		t.Fatal("newDescriptorAccount: expected error for bare multisig")
+	}
+}
@@ -0,0 +1,240 @@
+package watchonly
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/gcash/bchd/mempool"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil/txsort"
+)
+
+const (
+	// txInOverheadSize is the serialized size of an input excluding the
+	// signature script and its length.  It is the size of the outpoint and
+	// the sequence number.
+	//
+	//   32 + 4 + 4 = 40
+	txInOverheadSize = 40
+
+	// txOutOverheadSize is the serialized size of an output without tokens
+	// excluding the public key script.  It is the size of the value and
+	// the script length.
+	//
+	//   8 + 1 = 9
+	txOutOverheadSize = 9
+
+	// minChangeAmount is the smallest change output which is created.  It
+	// is the dust threshold of a pay-to-pubkey-hash output under the
+	// default relay policy, so any smaller change is added to the fee
+	// instead.
+	minChangeAmount = 546
+)
+
+var (
+	// ErrInsufficientFunds is returned when the unspent outputs of an
+	// account are not enough to pay for the requested outputs and fee.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+)
+
+// UnsignedTransaction is a transaction built from the unspent outputs of an
+// account along with the information needed to sign it.
+type UnsignedTransaction struct {
+	Tx *wire.MsgTx
+
+	// Inputs contains the outputs spent by the transaction in the order of
+	// the inputs which spend them.  Their amounts, scripts, and derivation
+	// paths are needed to sign the inputs.
+	Inputs []*UnspentOutput
+
+	// Fee is the fee paid by the transaction.
+	Fee int64
+
+	// ChangeIndex is the index of the change output or -1 when the
+	// transaction does not have one.
+	ChangeIndex int
+}
+
+// estimateSize returns the estimated serialized size of the passed transaction
+// once it spends the passed outputs and, when changeSize is not zero, has an
+// additional change output of that size.
+func estimateSize(tx *wire.MsgTx, inputs []*UnspentOutput, changeSize int) int {
+	size := tx.SerializeSize() - wire.VarIntSerializeSize(uint64(len(tx.TxIn))) +
+		wire.VarIntSerializeSize(uint64(len(inputs)))
+	for _, utxo := range inputs {
+		size += utxo.inputSize
+	}
+	if changeSize != 0 {
+		size += changeSize -
+			wire.VarIntSerializeSize(uint64(len(tx.TxOut))) +
+			wire.VarIntSerializeSize(uint64(len(tx.TxOut)+1))
+	}
+	return size
+}
+
+// calcFee returns the fee for a transaction with the passed serialized size at
+// the passed fee rate in satoshi per kilobyte.
+func calcFee(size int, feePerKb int64) int64 {
+	return int64(size) * feePerKb / 1000
+}
+
+// selectCoins selects unspent outputs from the passed candidates, in order,
+// until they pay for the outputs of the passed transaction and the fee at the
+// passed rate.  The passed change size is the serialized size of a change
+// output.  It returns the selected outputs, the fee, and the change amount,
+// which is zero when no change output is needed.
+func selectCoins(tx *wire.MsgTx, candidates []*UnspentOutput, feePerKb int64, changeSize int) ([]*UnspentOutput, int64, int64, error) {
+	var target int64
+	for _, txOut := range tx.TxOut {
+		target += txOut.Value
+	}
+
+	var (
+		selected []*UnspentOutput
+		total    int64
+	)
+	for _, utxo := range candidates {
+		selected = append(selected, utxo)
+		total += utxo.Amount
+
+		fee := calcFee(estimateSize(tx, selected, 0), feePerKb)
+		if total < target+fee {
+			continue
+		}
+
+		// Add a change output when the remainder after paying for it
+		// is not dust.
+		feeWithChange := calcFee(estimateSize(tx, selected, changeSize),
+			feePerKb)
+		if change := total - target - feeWithChange; change >= minChangeAmount {
+			return selected, feeWithChange, change, nil
+		}
+		return selected, total - target, 0, nil
+	}
+	return nil, 0, 0, ErrInsufficientFunds
+}
+
+// BuildTransaction builds an unsigned transaction which pays the passed outputs
+// from the unspent outputs of the account with the passed name.  The outputs
+// with at least the passed number of confirmations are selected largest first,
+// and any change is paid to the next unused change address of the account.
+// The fee rate is in satoshi per kilobyte and defaults to the default minimum
+// relay fee when zero.
+//
+// Outputs which carry tokens and immature coinbase outputs are never spent.
+// The inputs and outputs of the transaction are sorted as described by
+// BIP0069.
+func (m *Manager) BuildTransaction(name string, outputs []*wire.TxOut, feePerKb int64, minConf int32) (*UnsignedTransaction, error) {
+	if len(outputs) == 0 {
+		return nil, errors.New("no outputs specified")
+	}
+	if feePerKb == 0 {
+		feePerKb = int64(mempool.DefaultMinRelayTxFee)
+	}
+	if feePerKb < 0 {
+		return nil, fmt.Errorf("fee rate of %d is negative", feePerKb)
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	for _, txOut := range outputs {
+		if txOut.Value <= 0 {
+			return nil, fmt.Errorf("output amount of %d is not "+
+				"positive", txOut.Value)
+		}
+		if !txOut.TokenData.IsEmpty() {
+			return nil, errors.New("outputs with tokens are not " +
+				"supported")
+		}
+		tx.AddTxOut(wire.NewTxOut(txOut.Value, txOut.PkScript,
+			wire.TokenData{}))
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	a, err := m.account(name)
+	if err != nil {
+		return nil, err
+	}
+	utxos, err := m.unspentOutputs(a, true)
+	if err != nil {
+		return nil, err
+	}
+
+	best := m.cfg.Chain.BestSnapshot()
+	coinbaseMaturity := int32(m.cfg.ChainParams.CoinbaseMaturity)
+	candidates := make([]*UnspentOutput, 0, len(utxos))
+	for _, utxo := range utxos {
+		if !utxo.TokenData.IsEmpty() {
+			continue
+		}
+		confs := int32(0)
+		if utxo.BlockHeight != -1 {
+			confs = best.Height - utxo.BlockHeight + 1
+		}
+		if confs < minConf {
+			continue
+		}
+		if utxo.IsCoinBase && confs < coinbaseMaturity {
+			continue
+		}
+		candidates = append(candidates, utxo)
+	}
+
+	// The change outputs of an account all have the same size, so the
+	// size of any derived one is used for the estimate.
+	changeBranch := a.changeBranch()
+	var changeSize int
+	for _, addr := range a.addrs[changeBranch] {
+		if addr != nil {
+			changeSize = txOutOverheadSize + len(addr.pkScript)
+			break
+		}
+	}
+	selected, fee, change, err := selectCoins(tx, candidates, feePerKb,
+		changeSize)
+	if err != nil {
+		return nil, err
+	}
+	for _, utxo := range selected {
+		op := utxo.OutPoint
+		tx.AddTxIn(wire.NewTxIn(&op, nil))
+	}
+
+	var changeScript []byte
+	if change > 0 {
+		addr, err := a.nextAddress(changeBranch)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.putAccount(a); err != nil {
+			return nil, err
+		}
+		changeScript = addr.pkScript
+		tx.AddTxOut(wire.NewTxOut(change, changeScript, wire.TokenData{}))
+	}
+
+	txsort.InPlaceSort(tx)
+
+	spent := make(map[wire.OutPoint]*UnspentOutput, len(selected))
+	for _, utxo := range selected {
+		spent[utxo.OutPoint] = utxo
+	}
+	unsigned := &UnsignedTransaction{
+		Tx:          tx,
+		Inputs:      make([]*UnspentOutput, 0, len(tx.TxIn)),
+		Fee:         fee,
+		ChangeIndex: -1,
+	}
+	for _, txIn := range tx.TxIn {
+		unsigned.Inputs = append(unsigned.Inputs, spent[txIn.PreviousOutPoint])
+	}
+	for i, txOut := range tx.TxOut {
+		if changeScript != nil && bytes.Equal(txOut.PkScript, changeScript) {
+			unsigned.ChangeIndex = i
+			break
+		}
+	}
+	return unsigned, nil
+}
@@ -0,0 +1,112 @@
+package watchonly
+
+import (
+	"testing"
+
+	"github.com/gcash/bchd/wire"
+)
+
+// TestSelectCoins ensures coin selection pays for the outputs and fee and
+// only creates change outputs which are not dust.
+func TestSelectCoins(t *testing.T) {
+	utxos := func(amounts ...int64) []*UnspentOutput {
+		var utxos []*UnspentOutput
+		for i, amount := range amounts {
+			utxos = append(utxos, &UnspentOutput{
+				OutPoint: wire.OutPoint{Index: uint32(i)},
+				Amount:   amount,
+				// A pay-to-pubkey-hash input.
+				inputSize: 148,
+			})
+		}
+		return utxos
+	}
+
+	tests := []struct {
+		name       string
+		send       int64
+		candidates []*UnspentOutput
+		feePerKb   int64
+		numInputs  int
+		change     bool
+		err        error
+	}{
+		{
+			name:       "single input with change",
+			send:       50000,
+			candidates: utxos(100000, 20000),
+			feePerKb:   1000,
+			numInputs:  1,
+			change:     true,
+		},
+		{
+			name:       "multiple inputs with change",
+			send:       110000,
+			candidates: utxos(100000, 20000),
+			feePerKb:   1000,
+			numInputs:  2,
+			change:     true,
+		},
+		{
+			name:       "dust change added to fee",
+			send:       99700,
+			candidates: utxos(100000),
+			feePerKb:   1000,
+			numInputs:  1,
+			change:     false,
+		},
+		{
+			name:       "insufficient funds",
+			send:       120000,
+			candidates: utxos(100000, 20000),
+			feePerKb:   1000,
+			err:        ErrInsufficientFunds,
+		},
+	}
+
+	for _, test := range tests {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxOut(wire.NewTxOut(test.send, make([]byte, 25), wire.TokenData{}))
+
+		selected, fee, change, err := selectCoins(tx, test.candidates,
+			test.feePerKb, 34)
+		if err != test.err {
+			t.Fatalf("%s: got error %v, want %v", test.name, err,
+				test.err)
+		}
+		if err != nil {
+			continue
+		}
+		if len(selected) != test.numInputs {
+			t.Fatalf("%s: got %d inputs, want %d", test.name,
+				len(selected), test.numInputs)
+		}
+		if (change > 0) != test.change {
+			t.Fatalf("%s: got change %d, want change %v", test.name,
+				change, test.change)
+		}
+		if change > 0 && change < minChangeAmount {
+			t.Fatalf("%s: change %d is dust", test.name, change)
+		}
+
+		var total int64
+		for _, utxo := range selected {
+			total += utxo.Amount
+		}
+		if total != test.send+fee+change {
+			t.Fatalf("%s: inputs %d do not equal outputs %d plus "+
+				"fee %d and change %d", test.name, total,
+				test.send, fee, change)
+		}
+		changeSize := 0
+		if change > 0 {
+			changeSize = 34
+		}
+		minFee := calcFee(estimateSize(tx, selected, changeSize),
+			test.feePerKb)
+		if fee < minFee {
+			t.Fatalf("%s: fee %d is below the minimum of %d",
+				test.name, fee, minFee)
+		}
+	}
+}
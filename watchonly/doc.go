@@ -0,0 +1,28 @@
+/*
+Package watchonly implements watch-only accounts which track the funds of
+extended public keys and descriptors without access to any private keys.
+
+Each account derives its addresses from a pair of ranged output script
+descriptors as implemented by the txscript package.  Receive addresses are
+derived from the descriptor of the external branch and change addresses from
+the descriptor of the internal branch.  Accounts created from an extended
+public key, which is typically the key of a BIP0044 account, use
+pay-to-pubkey-hash descriptors for its first and second child.  Addresses are
+watched up to a gap limit past the last used address of each branch.
+
+The balances, transaction history, and unspent outputs of the accounts are not
+stored.  They are reconstructed on demand from the address index, the unspent
+transaction output set, and the memory pool, so they always reflect the current
+best chain.  Only the extended public keys or descriptors and the next unused
+index of each branch are stored in the database.
+
+Unsigned transactions can be built from the unspent outputs of an account.  The
+derivation paths of the keys which need to sign them are returned along with
+the transactions so they can be signed by an external signer.
+
+Multisig accounts are created from the extended public keys of their cosigners.
+Transactions spending from them are built as partially signed transactions as
+implemented by the pst package, which carry the redeem scripts and key
+derivation paths the cosigners need to sign them independently.
+*/
+package watchonly
@@ -0,0 +1,606 @@
+package watchonly
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/blockchain/indexers"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/database"
+	"github.com/gcash/bchd/mempool"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+const (
+	// addrTxFetchBatch is the number of transaction regions fetched from
+	// the address index at a time.
+	addrTxFetchBatch = 1000
+)
+
+var (
+	// accountsBucketName is the name of the database bucket used to house
+	// the watch-only accounts.
+	accountsBucketName = []byte("watchonlyaccounts")
+
+	// ErrAccountNotFound is returned when the requested account does not
+	// exist.
+	ErrAccountNotFound = errors.New("watch-only account not found")
+
+	// ErrAccountExists is returned when attempting to import an account
+	// with the name of an existing account.
+	ErrAccountExists = errors.New("watch-only account already exists")
+)
+
+// Config is a descriptor containing the watch-only account manager
+// configuration.
+type Config struct {
+	// ChainParams identifies which chain parameters the accounts are
+	// associated with.
+	ChainParams *chaincfg.Params
+
+	// DB is the database the accounts are stored in and the address index
+	// is read from.
+	DB database.DB
+
+	// Chain is the block chain used to look up the unspent outputs of
+	// the accounts.
+	Chain *blockchain.BlockChain
+
+	// AddrIndex is the address index used to find the transactions of the
+	// accounts.
+	AddrIndex *indexers.AddrIndex
+
+	// TxMemPool is the memory pool used to account for unconfirmed
+	// transactions.
+	TxMemPool *mempool.TxPool
+}
+
+// Transaction describes a transaction which pays to or spends from an account.
+type Transaction struct {
+	Hash chainhash.Hash
+	Tx   *wire.MsgTx
+
+	// BlockHash is the hash of the block the transaction is mined in.  It
+	// is nil when the transaction is unconfirmed.
+	BlockHash *chainhash.Hash
+
+	// BlockHeight is the height of the block the transaction is mined in
+	// or -1 when it is unconfirmed.
+	BlockHeight int32
+
+	// Received is the sum of the outputs of the transaction which pay to
+	// the account.
+	Received int64
+
+	// Sent is the sum of the outputs of the account which are spent by the
+	// transaction.
+	Sent int64
+}
+
+// UnspentOutput describes an unspent output which pays to an account.
+type UnspentOutput struct {
+	OutPoint   wire.OutPoint
+	Amount     int64
+	PkScript   []byte
+	TokenData  wire.TokenData
+	IsCoinBase bool
+
+	// BlockHeight is the height of the block which contains the output or
+	// -1 when it is unconfirmed.
+	BlockHeight int32
+
+	// Address is the address the output pays to and Branch and Index are
+	// the derivation path of its key relative to the account key.
+	Address bchutil.Address
+	Branch  uint32
+	Index   uint32
+
+	// inputSize is the estimated serialized size of an input which spends
+	// the output.
+	inputSize int
+}
+
+// Balance describes the balance of an account.
+type Balance struct {
+	// Confirmed is the sum of the unspent outputs of the account in the
+	// unspent transaction output set.
+	Confirmed int64
+
+	// Unconfirmed is the change to the confirmed balance made by the
+	// transactions in the memory pool.
+	Unconfirmed int64
+}
+
+// Manager keeps track of a set of watch-only accounts and reconstructs their
+// balances, transaction history, and unspent outputs from the address index,
+// the unspent transaction output set, and the memory pool.
+type Manager struct {
+	cfg Config
+
+	mtx      sync.Mutex
+	accounts map[string]*Account
+}
+
+// New returns a new watch-only account manager which loads the accounts stored
+// in the database.
+func New(cfg *Config) (*Manager, error) {
+	if cfg.AddrIndex == nil {
+		return nil, errors.New("watch-only accounts require the " +
+			"address index")
+	}
+
+	m := &Manager{
+		cfg:      *cfg,
+		accounts: make(map[string]*Account),
+	}
+	err := cfg.DB.Update(func(dbTx database.Tx) error {
+		bucket, err := dbTx.Metadata().CreateBucketIfNotExists(
+			accountsBucketName)
+		if err != nil {
+			return err
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			a, err := deserializeAccount(string(k), v, cfg.ChainParams)
+			if err != nil {
+				return err
+			}
+			m.accounts[a.name] = a
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Loaded %d watch-only accounts", len(m.accounts))
+	return m, nil
+}
+
+// putAccount stores the passed account in the database.
+func (m *Manager) putAccount(a *Account) error {
+	return m.cfg.DB.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(accountsBucketName)
+		return bucket.Put([]byte(a.name), a.serialize())
+	})
+}
+
+// account returns the account with the passed name after scanning it for newly
+// used addresses.
+//
+// This function MUST be called with the manager lock held.
+func (m *Manager) account(name string) (*Account, error) {
+	a, ok := m.accounts[name]
+	if !ok {
+		return nil, ErrAccountNotFound
+	}
+	if err := m.scan(a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// isUsed returns whether the passed address appears in any confirmed or
+// unconfirmed transaction.
+func (m *Manager) isUsed(addr bchutil.Address) (bool, error) {
+	if len(m.cfg.AddrIndex.UnconfirmedTxnsForAddress(addr)) > 0 {
+		return true, nil
+	}
+	var used bool
+	err := m.cfg.DB.View(func(dbTx database.Tx) error {
+		regions, _, err := m.cfg.AddrIndex.TxRegionsForAddress(dbTx,
+			addr, 0, 1, false)
+		used = len(regions) > 0
+		return err
+	})
+	return used, err
+}
+
+// scan advances the next unused index of each branch of the passed account
+// past any addresses within the gap limit which have been used since the last
+// scan and stores the account when it changed.
+//
+// This function MUST be called with the manager lock held.
+func (m *Manager) scan(a *Account) error {
+	var changed bool
+	for branch := range a.addrs {
+		// Marking an address as used extends the branch, so keep going
+		// until the end of the branch is reached.
+		for i := a.nextIndex[branch]; i < uint32(len(a.addrs[branch])); i++ {
+			addr := a.addrs[branch][i]
+			if addr == nil {
+				continue
+			}
+			used, err := m.isUsed(addr.addr)
+			if err != nil {
+				return err
+			}
+			if !used {
+				continue
+			}
+			if _, err := a.markUsed(uint32(branch), i); err != nil {
+				return err
+			}
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	log.Debugf("Watch-only account %q advanced to external index %d and "+
+		"internal index %d", a.name, a.nextIndex[ExternalBranch],
+		a.nextIndex[InternalBranch])
+	return m.putAccount(a)
+}
+
+// ImportAccount adds a new watch-only account with the passed name which
+// derives its addresses from the passed serialized extended public key.  Any
+// existing transactions of the account are found using the address index.
+func (m *Manager) ImportAccount(name, xpub string, gapLimit uint32) (*Account, error) {
+	if gapLimit == 0 {
+		gapLimit = DefaultGapLimit
+	}
+	return m.importAccount(name, func() (*Account, error) {
+		return newAccount(name, xpub, gapLimit, m.cfg.ChainParams)
+	})
+}
+
+// ImportDescriptorAccount adds a new watch-only account with the passed name
+// which derives its receive addresses from the passed ranged descriptor and
+// its change addresses from the passed ranged change descriptor.  Change is
+// paid to receive addresses when the change descriptor is empty.  Any existing
+// transactions of the account are found using the address index.
+func (m *Manager) ImportDescriptorAccount(name, receive, change string, gapLimit uint32) (*Account, error) {
+	if gapLimit == 0 {
+		gapLimit = DefaultGapLimit
+	}
+	return m.importAccount(name, func() (*Account, error) {
+		return newDescriptorAccount(name, receive, change, gapLimit,
+			m.cfg.ChainParams)
+	})
+}
+
+// ImportMultisigAccount adds a new watch-only account with the passed name whose
+// addresses are pay-to-script-hash addresses of multisig scripts which require
+// the passed number of signatures from the keys derived from the passed
+// serialized extended public keys of the cosigners.  Receive addresses use
+// the first child and change addresses the second child of each extended
+// public key, which may be preceded by key origin information.  Any existing
+// transactions of the account are found using the address index.
+func (m *Manager) ImportMultisigAccount(name string, required int, xpubs []string, gapLimit uint32) (*Account, error) {
+	receive, change, err := multisigDescriptors(required, xpubs)
+	if err != nil {
+		return nil, err
+	}
+	return m.ImportDescriptorAccount(name, receive, change, gapLimit)
+}
+
+// importAccount adds the account returned by the passed function unless an
+// account with the passed name already exists.
+func (m *Manager) importAccount(name string, newAccount func() (*Account, error)) (*Account, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if _, ok := m.accounts[name]; ok {
+		return nil, ErrAccountExists
+	}
+	a, err := newAccount()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.putAccount(a); err != nil {
+		return nil, err
+	}
+	m.accounts[name] = a
+	if err := m.scan(a); err != nil {
+		return nil, err
+	}
+
+	log.Infof("Imported watch-only account %q", name)
+	return a, nil
+}
+
+// RemoveAccount removes the watch-only account with the passed name.
+func (m *Manager) RemoveAccount(name string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if _, ok := m.accounts[name]; !ok {
+		return ErrAccountNotFound
+	}
+	err := m.cfg.DB.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(accountsBucketName)
+		return bucket.Delete([]byte(name))
+	})
+	if err != nil {
+		return err
+	}
+	delete(m.accounts, name)
+
+	log.Infof("Removed watch-only account %q", name)
+	return nil
+}
+
+// Accounts returns all of the watch-only accounts sorted by name.
+func (m *Manager) Accounts() []*Account {
+	m.mtx.Lock()
+	accounts := make([]*Account, 0, len(m.accounts))
+	for _, a := range m.accounts {
+		accounts = append(accounts, a)
+	}
+	m.mtx.Unlock()
+
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].name < accounts[j].name
+	})
+	return accounts
+}
+
+// NextAddress returns the next unused address of the account with the passed
+// name.  The address is derived from the internal branch when change is set
+// and the account has a change descriptor, and from the external branch
+// otherwise.  The returned address will not be
+// returned again.
+func (m *Manager) NextAddress(name string, change bool) (bchutil.Address, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	a, err := m.account(name)
+	if err != nil {
+		return nil, err
+	}
+	branch := ExternalBranch
+	if change {
+		branch = a.changeBranch()
+	}
+	addr, err := a.nextAddress(branch)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.putAccount(a); err != nil {
+		return nil, err
+	}
+	return addr.addr, nil
+}
+
+// transactions returns all of the confirmed and unconfirmed transactions which
+// pay to the passed account keyed by their hash.  The sent amounts are not
+// set.
+//
+// This function MUST be called with the manager lock held.
+func (m *Manager) transactions(a *Account) (map[chainhash.Hash]*Transaction, error) {
+	txns := make(map[chainhash.Hash]*Transaction)
+	addTx := func(msgTx *wire.MsgTx, blockHash *chainhash.Hash, height int32) {
+		hash := msgTx.TxHash()
+		if _, ok := txns[hash]; ok {
+			return
+		}
+		txns[hash] = &Transaction{
+			Hash:        hash,
+			Tx:          msgTx,
+			BlockHash:   blockHash,
+			BlockHeight: height,
+		}
+	}
+
+	// Only addresses before the next unused index of each branch can
+	// appear in transactions after a scan.
+	for branch := range a.addrs {
+		for _, addr := range a.addrs[branch][:a.nextIndex[branch]] {
+			if addr == nil {
+				continue
+			}
+			err := m.cfg.DB.View(func(dbTx database.Tx) error {
+				var skip uint32
+				for {
+					regions, _, err := m.cfg.AddrIndex.TxRegionsForAddress(
+						dbTx, addr.addr, skip, addrTxFetchBatch, false)
+					if err != nil {
+						return err
+					}
+					serializedTxns, err := dbTx.FetchBlockRegions(regions)
+					if err != nil {
+						return err
+					}
+					for i, serializedTx := range serializedTxns {
+						var msgTx wire.MsgTx
+						err := msgTx.Deserialize(bytes.NewReader(serializedTx))
+						if err != nil {
+							return err
+						}
+						height, err := m.cfg.Chain.BlockHeightByHash(
+							regions[i].Hash)
+						if err != nil {
+							return err
+						}
+						addTx(&msgTx, regions[i].Hash, height)
+					}
+					if len(regions) < addrTxFetchBatch {
+						return nil
+					}
+					skip += uint32(len(regions))
+				}
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			for _, tx := range m.cfg.AddrIndex.UnconfirmedTxnsForAddress(addr.addr) {
+				addTx(tx.MsgTx(), nil, -1)
+			}
+		}
+	}
+	return txns, nil
+}
+
+// History returns the confirmed and unconfirmed transactions of the account
+// with the passed name.  Unconfirmed transactions are returned first followed
+// by the confirmed transactions from the most to the least recent.
+func (m *Manager) History(name string) ([]*Transaction, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	a, err := m.account(name)
+	if err != nil {
+		return nil, err
+	}
+	txns, err := m.transactions(a)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every output spent by a transaction of the account was created by
+	// another transaction of the account, so the amounts of the spent
+	// outputs are known once the received outputs are collected.
+	owned := make(map[wire.OutPoint]int64)
+	history := make([]*Transaction, 0, len(txns))
+	for _, tx := range txns {
+		for i, txOut := range tx.Tx.TxOut {
+			if a.lookupScript(txOut.PkScript) == nil {
+				continue
+			}
+			owned[wire.OutPoint{Hash: tx.Hash, Index: uint32(i)}] = txOut.Value
+			tx.Received += txOut.Value
+		}
+		history = append(history, tx)
+	}
+	for _, tx := range history {
+		for _, txIn := range tx.Tx.TxIn {
+			tx.Sent += owned[txIn.PreviousOutPoint]
+		}
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		hi, hj := history[i].BlockHeight, history[j].BlockHeight
+		switch {
+		case hi == -1 && hj != -1:
+			return true
+		case hj == -1 && hi != -1:
+			return false
+		case hi != hj:
+			return hi > hj
+		}
+		return bytes.Compare(history[i].Hash[:], history[j].Hash[:]) < 0
+	})
+	return history, nil
+}
+
+// unspentOutputs returns the unspent outputs of the passed account.  When
+// includeMempool is set, outputs spent by transactions in the memory pool are
+// excluded and the outputs created by them are included.
+//
+// This function MUST be called with the manager lock held.
+func (m *Manager) unspentOutputs(a *Account, includeMempool bool) ([]*UnspentOutput, error) {
+	txns, err := m.transactions(a)
+	if err != nil {
+		return nil, err
+	}
+
+	var utxos []*UnspentOutput
+	for _, tx := range txns {
+		if tx.BlockHeight == -1 && !includeMempool {
+			continue
+		}
+		for i, txOut := range tx.Tx.TxOut {
+			addr := a.lookupScript(txOut.PkScript)
+			if addr == nil {
+				continue
+			}
+			op := wire.OutPoint{Hash: tx.Hash, Index: uint32(i)}
+			if includeMempool && m.cfg.TxMemPool.CheckSpend(op) != nil {
+				continue
+			}
+			inputSize, err := a.inputSize(addr)
+			if err != nil {
+				return nil, err
+			}
+			isCoinBase := false
+			if tx.BlockHeight != -1 {
+				entry, err := m.cfg.Chain.FetchUtxoEntry(op)
+				if err != nil {
+					return nil, err
+				}
+				if entry == nil || entry.IsSpent() {
+					continue
+				}
+				isCoinBase = entry.IsCoinBase()
+			}
+			utxos = append(utxos, &UnspentOutput{
+				OutPoint:    op,
+				Amount:      txOut.Value,
+				PkScript:    txOut.PkScript,
+				TokenData:   txOut.TokenData,
+				IsCoinBase:  isCoinBase,
+				BlockHeight: tx.BlockHeight,
+				Address:     addr.addr,
+				Branch:      addr.branch,
+				Index:       addr.index,
+				inputSize:   inputSize,
+			})
+		}
+	}
+
+	sort.Slice(utxos, func(i, j int) bool {
+		if utxos[i].Amount != utxos[j].Amount {
+			return utxos[i].Amount > utxos[j].Amount
+		}
+		return outPointLess(&utxos[i].OutPoint, &utxos[j].OutPoint)
+	})
+	return utxos, nil
+}
+
+// outPointLess returns whether the first passed outpoint sorts before the
+// second one.
+func outPointLess(a, b *wire.OutPoint) bool {
+	if c := bytes.Compare(a.Hash[:], b.Hash[:]); c != 0 {
+		return c < 0
+	}
+	return a.Index < b.Index
+}
+
+// UnspentOutputs returns the unspent outputs of the account with the passed
+// name sorted by descending amount.  When includeMempool is set, outputs spent
+// by transactions in the memory pool are excluded and the unconfirmed outputs
+// created by them are included.
+func (m *Manager) UnspentOutputs(name string, includeMempool bool) ([]*UnspentOutput, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	a, err := m.account(name)
+	if err != nil {
+		return nil, err
+	}
+	return m.unspentOutputs(a, includeMempool)
+}
+
+// Balance returns the balance of the account with the passed name.
+func (m *Manager) Balance(name string) (*Balance, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	a, err := m.account(name)
+	if err != nil {
+		return nil, err
+	}
+	confirmed, err := m.unspentOutputs(a, false)
+	if err != nil {
+		return nil, err
+	}
+	all, err := m.unspentOutputs(a, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var balance Balance
+	for _, utxo := range confirmed {
+		balance.Confirmed += utxo.Amount
+	}
+	for _, utxo := range all {
+		balance.Unconfirmed += utxo.Amount
+	}
+	balance.Unconfirmed -= balance.Confirmed
+	return &balance, nil
+}
@@ -0,0 +1,99 @@
+package watchonly
+
+import (
+	"encoding/binary"
+
+	"github.com/gcash/bchd/pst"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+)
+
+// BuildPst builds a partially signed transaction which pays the passed outputs
+// from the unspent outputs of the account with the passed name as described by
+// BuildTransaction.  Its inputs carry the outputs they spend along with the
+// redeem scripts and the origins of the keys needed to sign them, so that the
+// cosigners of multisig accounts can sign it independently.  The change output
+// carries the same information so that signers can recognize it.
+//
+// The unsigned transaction is returned along with the partially signed
+// transaction.
+func (m *Manager) BuildPst(name string, outputs []*wire.TxOut, feePerKb int64, minConf int32) (*pst.Packet, *UnsignedTransaction, error) {
+	unsigned, err := m.BuildTransaction(name, outputs, feePerKb, minConf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	a, ok := m.accounts[name]
+	if !ok {
+		return nil, nil, ErrAccountNotFound
+	}
+	p, err := pst.New(unsigned.Tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := a.updatePst(p, unsigned); err != nil {
+		return nil, nil, err
+	}
+	return p, unsigned, nil
+}
+
+// updatePst adds the information needed to sign the inputs of the passed
+// partially signed transaction, which is built from the passed unsigned
+// transaction of the account, and to recognize its change output.
+func (a *Account) updatePst(p *pst.Packet, unsigned *UnsignedTransaction) error {
+	for i, utxo := range unsigned.Inputs {
+		pi := &p.Inputs[i]
+		pi.Utxo = wire.NewTxOut(utxo.Amount, utxo.PkScript,
+			utxo.TokenData)
+		pi.SighashType = txscript.SigHashAll | txscript.SigHashForkID
+
+		var err error
+		pi.RedeemScript, pi.Bip32Derivation, err = a.signingInfo(
+			utxo.Branch, utxo.Index)
+		if err != nil {
+			return err
+		}
+	}
+
+	if unsigned.ChangeIndex < 0 {
+		return nil
+	}
+	change := unsigned.Tx.TxOut[unsigned.ChangeIndex]
+	addr, ok := a.scripts[string(change.PkScript)]
+	if !ok {
+		return nil
+	}
+	po := &p.Outputs[unsigned.ChangeIndex]
+	var err error
+	po.RedeemScript, po.Bip32Derivation, err = a.signingInfo(addr.branch,
+		addr.index)
+	return err
+}
+
+// signingInfo returns the redeem script of the address at the passed index of
+// the passed branch, which is nil unless it is a pay-to-script-hash address,
+// along with the derivation paths of its keys.
+func (a *Account) signingInfo(branch, index uint32) ([]byte, []*pst.Bip32Derivation, error) {
+	desc := a.descriptors[branch]
+	redeemScript, err := desc.RedeemScript(index)
+	if err != nil {
+		return nil, nil, err
+	}
+	origins, err := desc.KeyOrigins(index)
+	if err != nil {
+		return nil, nil, err
+	}
+	derivations := make([]*pst.Bip32Derivation, 0, len(origins))
+	for _, origin := range origins {
+		derivations = append(derivations, &pst.Bip32Derivation{
+			PubKey: origin.PubKey,
+			MasterKeyFingerprint: binary.LittleEndian.Uint32(
+				origin.Fingerprint[:]),
+			Path: origin.Path,
+		})
+	}
+	return redeemScript, derivations, nil
+}
@@ -0,0 +1,228 @@
+package watchonly
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/pst"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+	"github.com/gcash/bchutil/hdkeychain"
+)
+
+// testCosigner is a cosigner of a multisig account which holds its master key.
+type testCosigner struct {
+	master      *hdkeychain.ExtendedKey
+	fingerprint uint32
+}
+
+// newTestCosigner returns a cosigner whose master key is derived from a seed of
+// the passed byte.
+func newTestCosigner(t *testing.T, b byte, params *chaincfg.Params) *testCosigner {
+	master, err := hdkeychain.NewMaster(bytes.Repeat([]byte{b}, 32), params)
+	if err != nil {
+		t.Fatalf("NewMaster: unexpected error: %v", err)
+	}
+	pubKey, _ := master.ECPubKey()
+	return &testCosigner{
+		master: master,
+		fingerprint: binary.LittleEndian.Uint32(
+			bchutil.Hash160(pubKey.SerializeCompressed())),
+	}
+}
+
+// xpub returns the extended public key of the account m/45' of the cosigner
+// preceded by its key origin.
+func (c *testCosigner) xpub(t *testing.T) string {
+	account, err := c.master.Child(hdkeychain.HardenedKeyStart + 45)
+	if err != nil {
+		t.Fatalf("Child: unexpected error: %v", err)
+	}
+	pub, _ := account.Neuter()
+	var fingerprint [4]byte
+	binary.LittleEndian.PutUint32(fingerprint[:], c.fingerprint)
+	return fmt.Sprintf("[%s/45']%s", hex.EncodeToString(fingerprint[:]), pub)
+}
+
+// sign returns a copy of the passed partially signed transaction with
+// signatures of the cosigner added to every input which requires one.
+func (c *testCosigner) sign(t *testing.T, p *pst.Packet) *pst.Packet {
+	var buf bytes.Buffer
+	if err := p.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	signed, err := pst.NewFromRawBytes(&buf, false)
+	if err != nil {
+		t.Fatalf("NewFromRawBytes: unexpected error: %v", err)
+	}
+	for i := range signed.Inputs {
+		pi := &signed.Inputs[i]
+		for _, d := range pi.Bip32Derivation {
+			if d.MasterKeyFingerprint != c.fingerprint {
+				continue
+			}
+			key := c.master
+			for _, index := range d.Path {
+				key, err = key.Child(index)
+				if err != nil {
+					t.Fatalf("Child: unexpected error: %v", err)
+				}
+			}
+			privKey, _ := key.ECPrivKey()
+			if !bytes.Equal(privKey.PubKey().SerializeCompressed(), d.PubKey) {
+				t.Fatalf("input %d: derived key does not match", i)
+			}
+			sig, err := txscript.RawTxInSchnorrSignature(signed.UnsignedTx,
+				i, pi.RedeemScript, pi.SighashType, privKey,
+				pi.Utxo.Value)
+			if err != nil {
+				t.Fatalf("RawTxInSchnorrSignature: unexpected error: %v", err)
+			}
+			pi.PartialSigs = append(pi.PartialSigs, &pst.PartialSig{
+				PubKey:    d.PubKey,
+				Signature: sig,
+			})
+		}
+	}
+	return signed
+}
+
+// TestMultisigPst ensures partially signed transactions built from a multisig
+// account carry what the cosigners need to sign them independently, and that
+// the combined signatures of enough cosigners produce a valid transaction.
+func TestMultisigPst(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	cosigners := []*testCosigner{
+		newTestCosigner(t, 1, params),
+		newTestCosigner(t, 2, params),
+		newTestCosigner(t, 3, params),
+	}
+	var xpubs []string
+	for _, c := range cosigners {
+		xpubs = append(xpubs, c.xpub(t))
+	}
+	receive, change, err := multisigDescriptors(2, xpubs)
+	if err != nil {
+		t.Fatalf("multisigDescriptors: unexpected error: %v", err)
+	}
+	a, err := newDescriptorAccount("treasury", receive, change, 5, params)
+	if err != nil {
+		t.Fatalf("newDescriptorAccount: unexpected error: %v", err)
+	}
+
+	// Spend a receive and a change output of the account to another
+	// address with change.
+	tx := wire.NewMsgTx(wire.TxVersion)
+	var inputs []*UnspentOutput
+	for i, addr := range []*address{a.addrs[ExternalBranch][2], a.addrs[InternalBranch][0]} {
+		utxo := &UnspentOutput{
+			OutPoint: wire.OutPoint{Hash: chainhash.Hash{byte(i + 1)}},
+			Amount:   100000,
+			PkScript: addr.pkScript,
+			Address:  addr.addr,
+			Branch:   addr.branch,
+			Index:    addr.index,
+		}
+		inputs = append(inputs, utxo)
+		tx.AddTxIn(wire.NewTxIn(&utxo.OutPoint, nil))
+	}
+	payee, _ := bchutil.NewAddressPubKeyHash(make([]byte, 20), params)
+	payeeScript, _ := txscript.PayToAddrScript(payee)
+	tx.AddTxOut(wire.NewTxOut(150000, payeeScript, wire.TokenData{}))
+	changeAddr := a.addrs[InternalBranch][1]
+	tx.AddTxOut(wire.NewTxOut(49000, changeAddr.pkScript, wire.TokenData{}))
+	unsigned := &UnsignedTransaction{
+		Tx:          tx,
+		Inputs:      inputs,
+		Fee:         1000,
+		ChangeIndex: 1,
+	}
+
+	p, err := pst.New(tx)
+	if err != nil {
+		t.Fatalf("pst.New: unexpected error: %v", err)
+	}
+	if err := a.updatePst(p, unsigned); err != nil {
+		t.Fatalf("updatePst: unexpected error: %v", err)
+	}
+	for i, pi := range p.Inputs {
+		if pi.Utxo == nil || pi.Utxo.Value != 100000 {
+			t.Fatalf("input %d: unexpected utxo %v", i, pi.Utxo)
+		}
+		if len(pi.RedeemScript) == 0 || len(pi.Bip32Derivation) != 3 {
+			t.Fatalf("input %d: missing signing information", i)
+		}
+		for _, d := range pi.Bip32Derivation {
+			want := []uint32{hdkeychain.HardenedKeyStart + 45,
+				inputs[i].Branch, inputs[i].Index}
+			if fmt.Sprint(d.Path) != fmt.Sprint(want) {
+				t.Fatalf("input %d: got path %v, want %v", i,
+					d.Path, want)
+			}
+		}
+	}
+	if len(p.Outputs[0].Bip32Derivation) != 0 {
+		t.Fatal("payee output has derivation paths")
+	}
+	if len(p.Outputs[1].RedeemScript) == 0 || len(p.Outputs[1].Bip32Derivation) != 3 {
+		t.Fatal("change output is missing its derivation paths")
+	}
+	if fee, err := p.Fee(); err != nil || fee != 1000 {
+		t.Fatalf("Fee: got %d, %v, want 1000", fee, err)
+	}
+
+	// A single signature is not enough.
+	first := cosigners[0].sign(t, p)
+	if err := pst.MaybeFinalizeAll(first); err == nil {
+		t.Fatal("MaybeFinalizeAll: expected error with one signature")
+	}
+
+	// The independently signed transactions of two cosigners combine into
+	// a complete transaction.
+	combined, err := pst.Combine(cosigners[0].sign(t, p),
+		cosigners[2].sign(t, p))
+	if err != nil {
+		t.Fatalf("Combine: unexpected error: %v", err)
+	}
+	if err := pst.MaybeFinalizeAll(combined); err != nil {
+		t.Fatalf("MaybeFinalizeAll: unexpected error: %v", err)
+	}
+	if _, err := pst.Extract(combined); err != nil {
+		t.Fatalf("Extract: unexpected error: %v", err)
+	}
+}
+
+// TestMultisigDescriptorsErrors ensures invalid multisig accounts are rejected.
+func TestMultisigDescriptorsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		required int
+		xpubs    []string
+	}{
+		{"no keys", 1, nil},
+		{"none required", 0, []string{testXpub}},
+		{"too many required", 2, []string{testXpub}},
+	}
+	for _, test := range tests {
+		if _, _, err := multisigDescriptors(test.required, test.xpubs); err == nil {
+			t.Fatalf("%s: expected error", test.name)
+		}
+	}
+
+	// Private keys are rejected by the descriptors.
+	receive, change, err := multisigDescriptors(1, []string{testXprv})
+	if err != nil {
+		t.Fatalf("multisigDescriptors: unexpected error: %v", err)
+	}
+	_, err = newDescriptorAccount("test", receive, change, 5,
+		&chaincfg.MainNetParams)
+	if err == nil {
+		t.Fatal("newDescriptorAccount: expected error for private key")
+	}
+}
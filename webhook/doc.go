@@ -0,0 +1,25 @@
+/*
+Package webhook implements a notifier which posts JSON notifications of chain
+and mempool events to HTTP endpoints, which allows lightweight services to
+follow the chain without maintaining a websocket or gRPC stream.
+
+Every notification is posted as a JSON object to each of the configured URLs
+with a Content-Type of application/json.  The type field of the object names
+the event, and the field of the same name holds its details:
+
+	blockconnected     a block was connected to the main chain
+	blockdisconnected  a block was disconnected from the main chain
+	transaction        a transaction of a connected block pays to or spends
+	                   from one of the registered scripts
+	doublespend        a transaction double spending a mempool transaction
+	                   was detected
+	txexpired          a transaction was removed from the mempool because it
+	                   was not confirmed in time
+
+Notifications are delivered to each URL in order.  A delivery which fails, or
+which is answered with a status other than 2xx, is retried with exponential
+backoff before the notification is dropped.  Notifications are queued while a
+URL is unreachable, and dropped once the queue is full, so a slow endpoint
+never holds up the processing of blocks.
+*/
+package webhook
@@ -0,0 +1,594 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/mempool"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+const (
+	// DefaultMaxRetries is the default number of times a failed delivery
+	// is retried before the notification is dropped.
+	DefaultMaxRetries = 5
+
+	// DefaultRetryInterval is the default time waited before the first
+	// retry of a failed delivery.  It doubles with every retry.
+	DefaultRetryInterval = time.Second
+
+	// DefaultQueueSize is the default number of notifications queued for
+	// each URL while earlier ones are being delivered.
+	DefaultQueueSize = 1000
+
+	// maxRetryInterval is the maximum time waited between retries.
+	maxRetryInterval = time.Minute
+
+	// postTimeout is the time allowed to post a notification once.
+	postTimeout = 30 * time.Second
+
+	// maxResponseSize is the maximum size of a response body which is read
+	// so the connection can be reused.
+	maxResponseSize = 64 * 1024
+)
+
+// Event types name the event a notification describes.
+const (
+	EventBlockConnected    = "blockconnected"
+	EventBlockDisconnected = "blockdisconnected"
+	EventTransaction       = "transaction"
+	EventDoubleSpend       = "doublespend"
+	EventTxExpired         = "txexpired"
+)
+
+// Config is a descriptor containing the webhook notifier configuration.
+type Config struct {
+	// URLs are the endpoints notifications are posted to.
+	URLs []string
+
+	// Addresses are the addresses whose confirmed transactions are
+	// posted.  Transactions are matched when they pay to or spend from
+	// one of them.
+	Addresses []bchutil.Address
+
+	// ChainParams identifies which chain parameters the notifier is
+	// associated with.
+	ChainParams *chaincfg.Params
+
+	// Chain is the block chain whose notifications are posted.
+	Chain *blockchain.BlockChain
+
+	// Dial connects to the endpoints.  It defaults to net.DialTimeout.
+	Dial func(network, addr string, timeout time.Duration) (net.Conn, error)
+
+	// MaxRetries is the number of times a failed delivery is retried.  It
+	// defaults to DefaultMaxRetries.
+	MaxRetries int
+
+	// RetryInterval is the time waited before the first retry.  It
+	// defaults to DefaultRetryInterval.
+	RetryInterval time.Duration
+
+	// QueueSize is the number of notifications queued for each URL.  It
+	// defaults to DefaultQueueSize.
+	QueueSize int
+}
+
+// Notification is the JSON object posted to the endpoints.  Exactly one of the
+// detail fields is set, as named by the type.
+type Notification struct {
+	Type        string       `json:"type"`
+	Block       *Block       `json:"block,omitempty"`
+	Transaction *Transaction `json:"transaction,omitempty"`
+	DoubleSpend *DoubleSpend `json:"doublespend,omitempty"`
+	TxExpired   *TxExpired   `json:"txexpired,omitempty"`
+}
+
+// Block describes a block connected to or disconnected from the main chain.
+type Block struct {
+	Hash              string `json:"hash"`
+	Height            int32  `json:"height"`
+	Time              int64  `json:"time"`
+	PreviousBlockHash string `json:"previousblockhash"`
+	Size              int    `json:"size"`
+	TxCount           int    `json:"txcount"`
+}
+
+// Transaction describes a confirmed transaction which pays to or spends from
+// one of the registered addresses.
+type Transaction struct {
+	TxID        string   `json:"txid"`
+	BlockHash   string   `json:"blockhash"`
+	BlockHeight int32    `json:"blockheight"`
+	Hex         string   `json:"hex"`
+	Addresses   []string `json:"addresses"`
+}
+
+// OutPoint describes the output spent by both transactions of a double spend.
+type OutPoint struct {
+	Hash  string `json:"hash"`
+	Index uint32 `json:"index"`
+}
+
+// DoubleSpend describes a transaction double spending a mempool transaction.
+type DoubleSpend struct {
+	OutPoint    OutPoint `json:"outpoint"`
+	FirstSeenTx string   `json:"firstseentx"`
+	ConflictTx  string   `json:"conflicttx"`
+	Confirmed   bool     `json:"confirmed"`
+}
+
+// TxExpired describes a transaction which was removed from the mempool
+// because it was not confirmed in time, or because it depends on such a
+// transaction.
+type TxExpired struct {
+	TxID  string `json:"txid"`
+	Hex   string `json:"hex"`
+	Fee   int64  `json:"fee"`
+	Added int64  `json:"added"`
+}
+
+// endpoint is a URL notifications are posted to along with the queue of the
+// notifications yet to be delivered.
+type endpoint struct {
+	url   string
+	queue chan []byte
+}
+
+// Notifier posts notifications of chain and mempool events to the configured
+// URLs.
+type Notifier struct {
+	started  int32
+	shutdown int32
+
+	chainParams *chaincfg.Params
+	chain       *blockchain.BlockChain
+	addresses   map[string]struct{}
+
+	fetchSpendJournal func(*bchutil.Block) ([]blockchain.SpentTxOut, error)
+	post              func(ctx context.Context, url string, body []byte) error
+
+	maxRetries    int
+	retryInterval time.Duration
+
+	endpoints     []*endpoint
+	notifications chan interface{}
+	quit          chan struct{}
+	wg            sync.WaitGroup
+}
+
+// New returns a new webhook notifier.
+func New(cfg *Config) (*Notifier, error) {
+	if len(cfg.URLs) == 0 {
+		return nil, errors.New("no webhook URLs specified")
+	}
+
+	dial := cfg.Dial
+	if dial == nil {
+		dial = net.DialTimeout
+	}
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dial(network, addr, postTimeout)
+			},
+			TLSHandshakeTimeout: postTimeout,
+		},
+		Timeout: postTimeout,
+	}
+	n := newNotifier(cfg, func(ctx context.Context, url string, body []byte) error {
+		return httpPost(ctx, client, url, body)
+	})
+	if cfg.Chain != nil {
+		n.fetchSpendJournal = cfg.Chain.FetchSpendJournal
+	}
+	return n, nil
+}
+
+// newNotifier returns a new webhook notifier which delivers notifications with
+// the passed post function.
+func newNotifier(cfg *Config, post func(ctx context.Context, url string, body []byte) error) *Notifier {
+	maxRetries := cfg.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = DefaultMaxRetries
+	}
+	retryInterval := cfg.RetryInterval
+	if retryInterval <= 0 {
+		retryInterval = DefaultRetryInterval
+	}
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	addresses := make(map[string]struct{}, len(cfg.Addresses))
+	for _, addr := range cfg.Addresses {
+		addresses[addr.EncodeAddress()] = struct{}{}
+	}
+	endpoints := make([]*endpoint, 0, len(cfg.URLs))
+	for _, url := range cfg.URLs {
+		endpoints = append(endpoints, &endpoint{
+			url:   url,
+			queue: make(chan []byte, queueSize),
+		})
+	}
+	return &Notifier{
+		chainParams:   cfg.ChainParams,
+		chain:         cfg.Chain,
+		addresses:     addresses,
+		post:          post,
+		maxRetries:    maxRetries,
+		retryInterval: retryInterval,
+		endpoints:     endpoints,
+		notifications: make(chan interface{}, queueSize),
+		quit:          make(chan struct{}),
+	}
+}
+
+// httpPost posts the passed JSON body to the passed URL.
+func httpPost(ctx context.Context, client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url,
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseSize))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Start subscribes to the notifications of the chain and begins delivering
+// them.
+func (n *Notifier) Start() {
+	if atomic.AddInt32(&n.started, 1) != 1 {
+		return
+	}
+
+	log.Infof("Posting notifications to %d webhooks", len(n.endpoints))
+	for _, e := range n.endpoints {
+		n.wg.Add(1)
+		go n.deliveryHandler(e)
+	}
+	n.wg.Add(1)
+	go n.notificationHandler()
+	if n.chain != nil {
+		n.chain.Subscribe(n.handleBlockchainNotification)
+	}
+}
+
+// Stop stops delivering notifications.  Notifications which have not been
+// delivered yet are dropped.
+func (n *Notifier) Stop() {
+	if atomic.AddInt32(&n.shutdown, 1) != 1 {
+		return
+	}
+	close(n.quit)
+	n.wg.Wait()
+}
+
+// queueNotification queues the passed chain or mempool event to be turned
+// into notifications.  The event is dropped when the queue is full so the
+// caller is never blocked.
+func (n *Notifier) queueNotification(event interface{}) {
+	select {
+	case n.notifications <- event:
+	default:
+		log.Warnf("Webhook notification queue is full, dropping " +
+			"notification")
+	}
+}
+
+// blockConnected and blockDisconnected wrap the blocks of the chain
+// notifications so the handler can tell them apart.
+type blockConnected bchutil.Block
+type blockDisconnected bchutil.Block
+
+// handleBlockchainNotification queues the blocks connected to and
+// disconnected from the main chain.  It is called by the chain with the chain
+// lock held, so the notifications are built by the notification handler.
+func (n *Notifier) handleBlockchainNotification(notification *blockchain.Notification) {
+	switch notification.Type {
+	case blockchain.NTBlockConnected:
+		block, ok := notification.Data.(*bchutil.Block)
+		if !ok {
+			log.Warnf("Chain connected notification is not a block.")
+			break
+		}
+		n.queueNotification((*blockConnected)(block))
+
+	case blockchain.NTBlockDisconnected:
+		block, ok := notification.Data.(*bchutil.Block)
+		if !ok {
+			log.Warnf("Chain disconnected notification is not a block.")
+			break
+		}
+		n.queueNotification((*blockDisconnected)(block))
+	}
+}
+
+// NotifyDoubleSpend queues a notification of the passed double spend of a
+// mempool transaction.  It is called by the mempool.
+func (n *Notifier) NotifyDoubleSpend(ds *mempool.DoubleSpend) {
+	n.queueNotification(ds)
+}
+
+// expiredTxns wraps the transactions expired from the mempool so the handler
+// can tell them apart.
+type expiredTxns []*mempool.TxDesc
+
+// NotifyExpired queues notifications of the passed transactions which expired
+// from the mempool.  It is called by the mempool.
+func (n *Notifier) NotifyExpired(txns []*mempool.TxDesc) {
+	n.queueNotification(expiredTxns(txns))
+}
+
+// notificationHandler builds the notifications of the queued events and
+// queues them for delivery to every endpoint.  It must be run as a goroutine.
+func (n *Notifier) notificationHandler() {
+	defer n.wg.Done()
+
+	for {
+		select {
+		case event := <-n.notifications:
+			var notifications []*Notification
+			switch event := event.(type) {
+			case *blockConnected:
+				block := (*bchutil.Block)(event)
+				notifications = append(notifications,
+					blockNotification(EventBlockConnected, block))
+				notifications = append(notifications,
+					n.transactionNotifications(block)...)
+
+			case *blockDisconnected:
+				block := (*bchutil.Block)(event)
+				notifications = append(notifications,
+					blockNotification(EventBlockDisconnected, block))
+
+			case *mempool.DoubleSpend:
+				notifications = append(notifications,
+					doubleSpendNotification(event))
+
+			case expiredTxns:
+				for _, txD := range event {
+					notifications = append(notifications,
+						txExpiredNotification(txD))
+				}
+			}
+
+			for _, notification := range notifications {
+				n.dispatch(notification)
+			}
+
+		case <-n.quit:
+			return
+		}
+	}
+}
+
+// dispatch queues the passed notification for delivery to every endpoint.
+func (n *Notifier) dispatch(notification *Notification) {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		log.Errorf("Unable to marshal webhook notification: %v", err)
+		return
+	}
+	for _, e := range n.endpoints {
+		select {
+		case e.queue <- body:
+		default:
+			log.Warnf("Webhook queue of %s is full, dropping %s "+
+				"notification", e.url, notification.Type)
+		}
+	}
+}
+
+// deliveryHandler delivers the notifications queued for the passed endpoint in
+// order.  It must be run as a goroutine.
+func (n *Notifier) deliveryHandler(e *endpoint) {
+	defer n.wg.Done()
+
+	for {
+		select {
+		case body := <-e.queue:
+			if !n.deliver(e, body) {
+				return
+			}
+
+		case <-n.quit:
+			return
+		}
+	}
+}
+
+// deliver posts the passed notification to the passed endpoint, retrying with
+// exponential backoff when it fails.  It returns false when the notifier is
+// shutting down.
+func (n *Notifier) deliver(e *endpoint, body []byte) bool {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-n.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	interval := n.retryInterval
+	for attempt := 0; ; attempt++ {
+		err := n.post(ctx, e.url, body)
+		if err == nil {
+			return true
+		}
+		if attempt == n.maxRetries {
+			log.Warnf("Dropping webhook notification to %s after %d "+
+				"attempts: %v", e.url, attempt+1, err)
+			return true
+		}
+		log.Debugf("Unable to post webhook notification to %s, "+
+			"retrying in %v: %v", e.url, interval, err)
+
+		select {
+		case <-time.After(interval):
+		case <-n.quit:
+			return false
+		}
+		interval *= 2
+		if interval > maxRetryInterval {
+			interval = maxRetryInterval
+		}
+	}
+}
+
+// blockNotification returns a notification of the passed type for the passed
+// block.
+func blockNotification(eventType string, block *bchutil.Block) *Notification {
+	header := &block.MsgBlock().Header
+	return &Notification{
+		Type: eventType,
+		Block: &Block{
+			Hash:              block.Hash().String(),
+			Height:            block.Height(),
+			Time:              header.Timestamp.Unix(),
+			PreviousBlockHash: header.PrevBlock.String(),
+			Size:              block.MsgBlock().SerializeSize(),
+			TxCount:           len(block.Transactions()),
+		},
+	}
+}
+
+// transactionNotifications returns the notifications of the transactions of
+// the passed connected block which pay to or spend from one of the registered
+// addresses.
+func (n *Notifier) transactionNotifications(block *bchutil.Block) []*Notification {
+	if len(n.addresses) == 0 {
+		return nil
+	}
+
+	// The scripts of the spent outputs are only available from the spend
+	// journal.  Spends are not matched when it is not available, such as
+	// when the block was disconnected again in the meantime.
+	var stxos []blockchain.SpentTxOut
+	if n.fetchSpendJournal != nil {
+		var err error
+		stxos, err = n.fetchSpendJournal(block)
+		if err != nil {
+			log.Debugf("Unable to fetch the spend journal of block "+
+				"%v: %v", block.Hash(), err)
+			stxos = nil
+		}
+	}
+
+	var notifications []*Notification
+	stxoIndex := 0
+	for txIdx, tx := range block.Transactions() {
+		matched := make(map[string]struct{})
+		if txIdx != 0 {
+			for range tx.MsgTx().TxIn {
+				if stxoIndex < len(stxos) {
+					n.matchPkScript(stxos[stxoIndex].PkScript, matched)
+				}
+				stxoIndex++
+			}
+		}
+		for _, txOut := range tx.MsgTx().TxOut {
+			n.matchPkScript(txOut.PkScript, matched)
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		addrs := make([]string, 0, len(matched))
+		for addr := range matched {
+			addrs = append(addrs, addr)
+		}
+		sort.Strings(addrs)
+		notifications = append(notifications, &Notification{
+			Type: EventTransaction,
+			Transaction: &Transaction{
+				TxID:        tx.Hash().String(),
+				BlockHash:   block.Hash().String(),
+				BlockHeight: block.Height(),
+				Hex:         txHex(tx.MsgTx()),
+				Addresses:   addrs,
+			},
+		})
+	}
+	return notifications
+}
+
+// matchPkScript adds the registered addresses the passed script pays to to the
+// passed set.
+func (n *Notifier) matchPkScript(pkScript []byte, matched map[string]struct{}) {
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, n.chainParams)
+	if err != nil {
+		return
+	}
+	for _, addr := range addrs {
+		encoded := addr.EncodeAddress()
+		if _, ok := n.addresses[encoded]; ok {
+			matched[encoded] = struct{}{}
+		}
+	}
+}
+
+// doubleSpendNotification returns the notification of the passed double spend.
+func doubleSpendNotification(ds *mempool.DoubleSpend) *Notification {
+	return &Notification{
+		Type: EventDoubleSpend,
+		DoubleSpend: &DoubleSpend{
+			OutPoint: OutPoint{
+				Hash:  ds.OutPoint.Hash.String(),
+				Index: ds.OutPoint.Index,
+			},
+			FirstSeenTx: txHex(ds.FirstSeen.MsgTx()),
+			ConflictTx:  txHex(ds.Conflict.MsgTx()),
+			Confirmed:   ds.Confirmed,
+		},
+	}
+}
+
+// txExpiredNotification returns the notification of the passed transaction
+// which expired from the mempool.
+func txExpiredNotification(txD *mempool.TxDesc) *Notification {
+	return &Notification{
+		Type: EventTxExpired,
+		TxExpired: &TxExpired{
+			TxID:  txD.Tx.Hash().String(),
+			Hex:   txHex(txD.Tx.MsgTx()),
+			Fee:   txD.Fee,
+			Added: txD.Added.Unix(),
+		},
+	}
+}
+
+// txHex returns the hex-encoded serialization of the passed transaction.
+func txHex(tx *wire.MsgTx) string {
+	var buf bytes.Buffer
+	buf.Grow(tx.SerializeSize())
+	_ = tx.Serialize(&buf)
+	return hex.EncodeToString(buf.Bytes())
+}
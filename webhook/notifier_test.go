@@ -0,0 +1,229 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gcash/bchd/blockchain"
+	"github.com/gcash/bchd/chaincfg"
+	"github.com/gcash/bchd/chaincfg/chainhash"
+	"github.com/gcash/bchd/mempool"
+	"github.com/gcash/bchd/mining"
+	"github.com/gcash/bchd/txscript"
+	"github.com/gcash/bchd/wire"
+	"github.com/gcash/bchutil"
+)
+
+// TestNotifier ensures notifications of connected blocks, the transactions of
+// the blocks matching the registered addresses, and double spends are posted
+// in order, and that failed deliveries are retried.
+func TestNotifier(t *testing.T) {
+	params := &chaincfg.RegressionNetParams
+	addr, err := bchutil.NewAddressPubKeyHash(make([]byte, 20), params)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash: unexpected error: %v", err)
+	}
+	spentAddr, err := bchutil.NewAddressScriptHashFromHash(make([]byte, 20),
+		params)
+	if err != nil {
+		t.Fatalf("NewAddressScriptHashFromHash: unexpected error: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+	spentPkScript, err := txscript.PayToAddrScript(spentAddr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+
+	// The first delivery fails so that it is retried.
+	var mtx sync.Mutex
+	var received []Notification
+	failed := false
+	done := make(chan struct{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		if !failed {
+			failed = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var n Notification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Errorf("Decode: unexpected error: %v", err)
+		}
+		received = append(received, n)
+		done <- struct{}{}
+	}))
+	defer server.Close()
+
+	n, err := New(&Config{
+		URLs:          []string{server.URL},
+		Addresses:     []bchutil.Address{addr, spentAddr},
+		ChainParams:   params,
+		RetryInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+
+	// The block contains a coinbase, a transaction paying to the first
+	// address, a transaction spending from the second address, and a
+	// transaction matching neither.
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		nil))
+	coinbase.AddTxOut(wire.NewTxOut(5000, []byte{txscript.OP_TRUE}, wire.TokenData{}))
+	pay := wire.NewMsgTx(1)
+	pay.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{1}}, nil))
+	pay.AddTxOut(wire.NewTxOut(1000, pkScript, wire.TokenData{}))
+	spend := wire.NewMsgTx(1)
+	spend.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{2}}, nil))
+	spend.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}, wire.TokenData{}))
+	other := wire.NewMsgTx(1)
+	other.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{3}}, nil))
+	other.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}, wire.TokenData{}))
+	msgBlock := &wire.MsgBlock{
+		Header:       params.GenesisBlock.Header,
+		Transactions: []*wire.MsgTx{coinbase, pay, spend, other},
+	}
+	block := bchutil.NewBlock(msgBlock)
+	block.SetHeight(1)
+	n.fetchSpendJournal = func(*bchutil.Block) ([]blockchain.SpentTxOut, error) {
+		return []blockchain.SpentTxOut{
+			{PkScript: []byte{txscript.OP_TRUE}},
+			{PkScript: spentPkScript},
+			{PkScript: []byte{txscript.OP_TRUE}},
+		}, nil
+	}
+
+	n.Start()
+	defer n.Stop()
+	n.handleBlockchainNotification(&blockchain.Notification{
+		Type: blockchain.NTBlockConnected,
+		Data: block,
+	})
+	n.NotifyDoubleSpend(&mempool.DoubleSpend{
+		OutPoint:  wire.OutPoint{Hash: chainhash.Hash{4}, Index: 1},
+		FirstSeen: bchutil.NewTx(pay),
+		Conflict:  bchutil.NewTx(other),
+	})
+	n.NotifyExpired([]*mempool.TxDesc{{
+		TxDesc: mining.TxDesc{
+			Tx:    bchutil.NewTx(other),
+			Added: time.Unix(1600000000, 0),
+			Fee:   1000,
+		},
+	}})
+
+	for i := 0; i < 5; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for notification %d", i)
+		}
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if len(received) != 5 {
+		t.Fatalf("unexpected number of notifications -- got %d, want 5",
+			len(received))
+	}
+	if received[0].Type != EventBlockConnected ||
+		received[0].Block.Hash != block.Hash().String() ||
+		received[0].Block.Height != 1 || received[0].Block.TxCount != 4 {
+		t.Errorf("unexpected block notification %+v", received[0].Block)
+	}
+	if received[1].Type != EventTransaction ||
+		received[1].Transaction.TxID != pay.TxHash().String() ||
+		len(received[1].Transaction.Addresses) != 1 ||
+		received[1].Transaction.Addresses[0] != addr.EncodeAddress() {
+		t.Errorf("unexpected transaction notification %+v",
+			received[1].Transaction)
+	}
+	if received[2].Type != EventTransaction ||
+		received[2].Transaction.TxID != spend.TxHash().String() ||
+		len(received[2].Transaction.Addresses) != 1 ||
+		received[2].Transaction.Addresses[0] != spentAddr.EncodeAddress() {
+		t.Errorf("unexpected transaction notification %+v",
+			received[2].Transaction)
+	}
+	if received[3].Type != EventDoubleSpend ||
+		received[3].DoubleSpend.OutPoint.Index != 1 ||
+		received[3].DoubleSpend.ConflictTx != txHex(other) {
+		t.Errorf("unexpected double spend notification %+v",
+			received[3].DoubleSpend)
+	}
+	if received[4].Type != EventTxExpired ||
+		received[4].TxExpired.TxID != other.TxHash().String() ||
+		received[4].TxExpired.Fee != 1000 ||
+		received[4].TxExpired.Added != 1600000000 {
+		t.Errorf("unexpected expired transaction notification %+v",
+			received[4].TxExpired)
+	}
+}
+
+// TestNotifierDropsAfterRetries ensures a notification which can not be
+// delivered is dropped after the configured number of retries so that the
+// following notifications are still delivered.
+func TestNotifierDropsAfterRetries(t *testing.T) {
+	var mtx sync.Mutex
+	attempts := 0
+	n := newNotifier(&Config{
+		URLs:          []string{"http://127.0.0.1"},
+		MaxRetries:    2,
+		RetryInterval: time.Millisecond,
+	}, nil)
+	done := make(chan string, 10)
+	n.post = func(_ context.Context, _ string, body []byte) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+		var notification Notification
+		if err := json.Unmarshal(body, &notification); err != nil {
+			return err
+		}
+		if notification.Type == EventBlockDisconnected {
+			attempts++
+			return errors.New("unavailable")
+		}
+		done <- notification.Type
+		return nil
+	}
+
+	block := bchutil.NewBlock(chaincfg.RegressionNetParams.GenesisBlock)
+	n.Start()
+	defer n.Stop()
+	n.handleBlockchainNotification(&blockchain.Notification{
+		Type: blockchain.NTBlockDisconnected,
+		Data: block,
+	})
+	n.handleBlockchainNotification(&blockchain.Notification{
+		Type: blockchain.NTBlockConnected,
+		Data: block,
+	})
+
+	select {
+	case eventType := <-done:
+		if eventType != EventBlockConnected {
+			t.Fatalf("unexpected notification %s", eventType)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for notification")
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if attempts != 3 {
+		t.Fatalf("unexpected number of attempts -- got %d, want 3",
+			attempts)
+	}
+}
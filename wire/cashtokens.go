@@ -104,6 +104,12 @@ func (tokenData *TokenData) SeparateTokenDataFromPKScriptIfExists(buf []byte, pv
 			return nil, err
 		}
 
+		// Don't allocate more than the remaining bytes for a commitment
+		// length which could otherwise be as large as ~uint64(0).
+		if commitmentLength > uint64(r.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
 		b := scriptPool.Borrow(commitmentLength)
 		_, err = io.ReadFull(r, b)
 		if err != nil {
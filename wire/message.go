@@ -43,6 +43,7 @@ const (
 	CmdXVersion     = "xversion"
 	CmdVerAck       = "verack"
 	CmdXVerAck      = "xverack"
+	CmdExtVersion   = "extversion"
 	CmdGetAddr      = "getaddr"
 	CmdAddr         = "addr"
 	CmdGetBlocks    = "getblocks"
@@ -75,6 +76,12 @@ const (
 	CmdGetBlockTxns = "getblocktxn"
 	CmdBlockTxns    = "blocktxn"
 	CmdSendAddrV2   = "sendaddrv2"
+	CmdAddrV2       = "addrv2"
+	CmdSendTxRcncl  = "sendtxrcncl"
+	CmdReqRecon     = "reqrecon"
+	CmdSketch       = "sketch"
+	CmdReconcilDiff = "reconcildiff"
+	CmdWeakBlock    = "weakblock"
 )
 
 // MessageEncoding represents the wire message encoding format to be used.
@@ -121,12 +128,18 @@ func makeEmptyMessage(command string) (Message, error) {
 	case CmdXVerAck:
 		msg = &MsgXVerAck{}
 
+	case CmdExtVersion:
+		msg = &MsgExtVersion{}
+
 	case CmdGetAddr:
 		msg = &MsgGetAddr{}
 
 	case CmdAddr:
 		msg = &MsgAddr{}
 
+	case CmdAddrV2:
+		msg = &MsgAddrV2{}
+
 	case CmdGetBlocks:
 		msg = &MsgGetBlocks{}
 
@@ -214,6 +227,21 @@ func makeEmptyMessage(command string) (Message, error) {
 	case CmdBlockTxns:
 		msg = &MsgBlockTxns{}
 
+	case CmdSendTxRcncl:
+		msg = &MsgSendTxRcncl{}
+
+	case CmdReqRecon:
+		msg = &MsgReqRecon{}
+
+	case CmdSketch:
+		msg = &MsgSketch{}
+
+	case CmdReconcilDiff:
+		msg = &MsgReconcilDiff{}
+
+	case CmdWeakBlock:
+		msg = &MsgWeakBlock{}
+
 	default:
 		return nil, fmt.Errorf("unhandled command [%s]", command)
 	}
@@ -75,6 +75,16 @@ func TestMessage(t *testing.T) {
 		[]byte("payload"))
 	msgCFHeaders := NewMsgCFHeaders()
 	msgCFCheckpt := NewMsgCFCheckpt(GCSFilterRegular, &chainhash.Hash{}, 0)
+	msgExtVersion := NewMsgExtVersion()
+	msgExtVersion.AddUint64(ExtVersionKeyVersion, ExtVersionProtocolVersion)
+	msgSendTxRcncl := NewMsgSendTxRcncl(TxReconciliationVersion, 123123)
+	msgReqRecon := NewMsgReqRecon(12, 3276)
+	msgSketch := NewMsgSketch([]byte{0x01, 0x02, 0x03, 0x04})
+	msgReconcilDiff := NewMsgReconcilDiff(true, []uint32{1, 2})
+	msgWeakBlock, err := NewMsgWeakBlockFromBlock(&blockOne, nil)
+	if err != nil {
+		t.Fatalf("NewMsgWeakBlockFromBlock: %v", err)
+	}
 
 	tests := []struct {
 		in     Message    // Value to encode
@@ -109,6 +119,12 @@ func TestMessage(t *testing.T) {
 		{msgCFilter, msgCFilter, pver, MainNet, 65},
 		{msgCFHeaders, msgCFHeaders, pver, MainNet, 90},
 		{msgCFCheckpt, msgCFCheckpt, pver, MainNet, 58},
+		{msgExtVersion, msgExtVersion, pver, MainNet, 28},
+		{msgSendTxRcncl, msgSendTxRcncl, pver, MainNet, 36},
+		{msgReqRecon, msgReqRecon, pver, MainNet, 28},
+		{msgSketch, msgSketch, pver, MainNet, 29},
+		{msgReconcilDiff, msgReconcilDiff, pver, MainNet, 34},
+		{msgWeakBlock, msgWeakBlock, pver, MainNet, 249},
 	}
 
 	t.Logf("Running %d tests", len(tests))
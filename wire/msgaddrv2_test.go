@@ -0,0 +1,199 @@
+package wire
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestAddrV2 tests the MsgAddrV2 API.
+func TestAddrV2(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "addrv2"
+	msg := NewMsgAddrV2()
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgAddrV2: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	// Num addresses (varInt) + max allowed addresses.
+	wantPayload := uint32(537009)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure adding more than the max allowed addresses per message returns
+	// error.
+	na := NewNetAddressIPPort(net.ParseIP("127.0.0.1"), 8333, SFNodeNetwork)
+	var err error
+	for i := 0; i < MaxAddrPerMsg+1; i++ {
+		err = msg.AddAddress(na)
+	}
+	if err == nil {
+		t.Errorf("AddAddress: expected error on too many addresses " +
+			"not received")
+	}
+	msg.ClearAddresses()
+	if len(msg.AddrList) != 0 {
+		t.Errorf("ClearAddresses: address list is not empty - "+
+			"got %v, want %v", len(msg.AddrList), 0)
+	}
+}
+
+// TestAddrV2Wire tests the MsgAddrV2 wire encode and decode for addresses of
+// each BIP0155 network.
+func TestAddrV2Wire(t *testing.T) {
+	timestamp := time.Unix(0x495fab29, 0) // 2009-01-03 12:15:05 -0600 CST
+	torV3 := bytes.Repeat([]byte{0xab}, 32)
+	i2p := bytes.Repeat([]byte{0xcd}, 32)
+	cjdns := append([]byte{0xfc}, bytes.Repeat([]byte{0x01}, 15)...)
+
+	tests := []struct {
+		network NetworkID
+		addr    []byte
+		v1      bool
+		ip      net.IP
+	}{
+		{NetworkIPv4, []byte{127, 0, 0, 1}, true, net.ParseIP("127.0.0.1")},
+		{NetworkIPv6, net.ParseIP("2001:db8::1"), true, net.ParseIP("2001:db8::1")},
+		{NetworkTorV2, bytes.Repeat([]byte{0x01}, 10), true,
+			net.ParseIP("fd87:d87e:eb43:101:101:101:101:101")},
+		{NetworkTorV3, torV3, false, nil},
+		{NetworkI2P, i2p, false, nil},
+		{NetworkCJDNS, cjdns, false, net.IP(cjdns)},
+	}
+
+	for i, test := range tests {
+		na, err := NewNetAddressV2(timestamp, SFNodeNetwork,
+			test.network, test.addr, 8333)
+		if err != nil {
+			t.Errorf("NewNetAddressV2 #%d: %v", i, err)
+			continue
+		}
+		if got := na.NetworkID(); got != test.network {
+			t.Errorf("NetworkID #%d: got %v, want %v", i, got,
+				test.network)
+		}
+		if got := na.IsAddrV1Compatible(); got != test.v1 {
+			t.Errorf("IsAddrV1Compatible #%d: got %v, want %v", i,
+				got, test.v1)
+		}
+		if !na.IP.Equal(test.ip) {
+			t.Errorf("IP #%d: got %v, want %v", i, na.IP, test.ip)
+		}
+
+		msg := NewMsgAddrV2()
+		msg.AddAddress(na)
+		var buf bytes.Buffer
+		if err := msg.BchEncode(&buf, ProtocolVersion, BaseEncoding); err != nil {
+			t.Errorf("BchEncode #%d: %v", i, err)
+			continue
+		}
+
+		// Count, timestamp, services, network, address length, address,
+		// and port.
+		wantLen := 1 + 4 + 1 + 1 + 1 + len(test.addr) + 2
+		if buf.Len() != wantLen {
+			t.Errorf("BchEncode #%d: got length %d, want %d", i,
+				buf.Len(), wantLen)
+		}
+		wantAddr := append([]byte{byte(test.network), byte(len(test.addr))},
+			test.addr...)
+		if !bytes.Equal(buf.Bytes()[6:6+len(wantAddr)], wantAddr) {
+			t.Errorf("BchEncode #%d: got address %x, want %x", i,
+				buf.Bytes()[6:6+len(wantAddr)], wantAddr)
+		}
+
+		var decoded MsgAddrV2
+		err = decoded.BchDecode(&buf, ProtocolVersion, BaseEncoding)
+		if err != nil {
+			t.Errorf("BchDecode #%d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(decoded.AddrList, msg.AddrList) {
+			t.Errorf("BchDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(decoded.AddrList), spew.Sdump(msg.AddrList))
+		}
+	}
+}
+
+// TestAddrV2WireErrors performs negative tests against wire decode of
+// MsgAddrV2 to confirm error paths work correctly.
+func TestAddrV2WireErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		// Tor v3 address with an invalid length.
+		{"short torv3", []byte{
+			0x01,                   // Count
+			0x29, 0xab, 0x5f, 0x49, // Timestamp
+			0x01,       // Services
+			0x04,       // Network
+			0x02,       // Address length
+			0xab, 0xab, // Address
+			0x20, 0x8d, // Port
+		}},
+		// Address exceeding the maximum size.
+		{"oversized", []byte{
+			0x01,                   // Count
+			0x29, 0xab, 0x5f, 0x49, // Timestamp
+			0x01,             // Services
+			0x07,             // Network
+			0xfd, 0x01, 0x02, // Address length 513
+		}},
+		// Too many addresses.
+		{"count", []byte{0xfd, 0xe9, 0x03}},
+		// Truncated address.
+		{"truncated", []byte{0x01, 0x29, 0xab}},
+	}
+
+	for _, test := range tests {
+		var msg MsgAddrV2
+		err := msg.BchDecode(bytes.NewReader(test.buf), ProtocolVersion,
+			BaseEncoding)
+		if err == nil {
+			t.Errorf("BchDecode %s: expected error", test.name)
+		}
+	}
+}
+
+// TestAddrV2UnknownNetwork ensures addresses of unknown networks are decoded
+// and encoded as is so they may be relayed.
+func TestAddrV2UnknownNetwork(t *testing.T) {
+	buf := []byte{
+		0x01,                   // Count
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x01,             // Services
+		0x10,             // Network
+		0x03,             // Address length
+		0x01, 0x02, 0x03, // Address
+		0x20, 0x8d, // Port
+	}
+	var msg MsgAddrV2
+	err := msg.BchDecode(bytes.NewReader(buf), ProtocolVersion, BaseEncoding)
+	if err != nil {
+		t.Fatalf("BchDecode: %v", err)
+	}
+	na := msg.AddrList[0]
+	if na.NetworkID() != 0x10 || na.IsAddrV1Compatible() || na.Port != 8333 {
+		t.Fatalf("unexpected address %s", spew.Sdump(na))
+	}
+	var w bytes.Buffer
+	if err := msg.BchEncode(&w, ProtocolVersion, BaseEncoding); err != nil {
+		t.Fatalf("BchEncode: %v", err)
+	}
+	if !bytes.Equal(w.Bytes(), buf) {
+		t.Fatalf("BchEncode: got %x, want %x", w.Bytes(), buf)
+	}
+}
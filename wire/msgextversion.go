@@ -0,0 +1,184 @@
+package wire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	// MaxExtVersionEntries is the maximum number of entries allowed in an
+	// extversion message.
+	MaxExtVersionEntries = 256
+
+	// MaxExtVersionValueSize is the maximum size of the value of an entry
+	// in an extversion message.
+	MaxExtVersionValueSize = 256
+
+	// maxExtVersionPayload is the maximum payload size of an extversion
+	// message.
+	maxExtVersionPayload = 100000
+)
+
+// Keys of the well known extversion entries.
+const (
+	// ExtVersionKeyVersion is the key of the entry which holds the version
+	// of the extversion protocol as a compact size integer.
+	ExtVersionKeyVersion uint64 = 0x00
+
+	// ExtVersionKeyWeakBlocks is the key of the entry which signals that
+	// the peer relays weak blocks.  Its value is the factor by which the
+	// weak block target exceeds the block target as a compact size integer.
+	ExtVersionKeyWeakBlocks uint64 = 0x01
+)
+
+// ExtVersionProtocolVersion is the version of the extversion protocol
+// implemented by this package.
+const ExtVersionProtocolVersion = 1
+
+// MsgExtVersion implements the Message interface and represents a bitcoin
+// extversion message.  It is exchanged during the version handshake by peers
+// which both advertise SFNodeExtVersion and holds a set of key value entries
+// which negotiate extended features.
+type MsgExtVersion struct {
+	// Entries holds the values of the entries by key.
+	Entries map[uint64][]byte
+}
+
+// AddEntry adds an entry with the passed key and value to the message,
+// replacing any existing entry with the key.
+func (msg *MsgExtVersion) AddEntry(key uint64, value []byte) error {
+	if len(value) > MaxExtVersionValueSize {
+		str := fmt.Sprintf("extversion value too large [size %v, "+
+			"max %v]", len(value), MaxExtVersionValueSize)
+		return messageError("MsgExtVersion.AddEntry", str)
+	}
+	if _, ok := msg.Entries[key]; !ok &&
+		len(msg.Entries)+1 > MaxExtVersionEntries {
+
+		str := fmt.Sprintf("too many entries in message [max %v]",
+			MaxExtVersionEntries)
+		return messageError("MsgExtVersion.AddEntry", str)
+	}
+
+	msg.Entries[key] = value
+	return nil
+}
+
+// AddUint64 adds an entry with the passed key and a value holding the passed
+// integer encoded as a compact size integer.
+func (msg *MsgExtVersion) AddUint64(key uint64, value uint64) error {
+	var buf bytes.Buffer
+	if err := WriteVarInt(&buf, 0, value); err != nil {
+		return err
+	}
+	return msg.AddEntry(key, buf.Bytes())
+}
+
+// Uint64 returns the value of the entry with the passed key decoded as a
+// compact size integer.  It returns false when there is no such entry or its
+// value is not a valid compact size integer.
+func (msg *MsgExtVersion) Uint64(key uint64) (uint64, bool) {
+	value, ok := msg.Entries[key]
+	if !ok {
+		return 0, false
+	}
+	r := bytes.NewReader(value)
+	v, err := ReadVarInt(r, 0)
+	if err != nil || r.Len() != 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+// BchDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgExtVersion) BchDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+
+	// Limit to max entries per message.
+	if count > MaxExtVersionEntries {
+		str := fmt.Sprintf("too many entries for message "+
+			"[count %v, max %v]", count, MaxExtVersionEntries)
+		return messageError("MsgExtVersion.BchDecode", str)
+	}
+
+	msg.Entries = make(map[uint64][]byte, count)
+	for i := uint64(0); i < count; i++ {
+		key, err := ReadVarInt(r, pver)
+		if err != nil {
+			return err
+		}
+		value, err := ReadVarBytes(r, pver, MaxExtVersionValueSize,
+			"extversion value")
+		if err != nil {
+			return err
+		}
+		if _, ok := msg.Entries[key]; ok {
+			str := fmt.Sprintf("duplicate extversion key %d", key)
+			return messageError("MsgExtVersion.BchDecode", str)
+		}
+		msg.Entries[key] = value
+	}
+	return nil
+}
+
+// BchEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgExtVersion) BchEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	count := len(msg.Entries)
+	if count > MaxExtVersionEntries {
+		str := fmt.Sprintf("too many entries for message "+
+			"[count %v, max %v]", count, MaxExtVersionEntries)
+		return messageError("MsgExtVersion.BchEncode", str)
+	}
+
+	if err := WriteVarInt(w, pver, uint64(count)); err != nil {
+		return err
+	}
+
+	// The entries are encoded in order of their keys so the encoding is
+	// deterministic.
+	keys := make([]uint64, 0, count)
+	for key := range msg.Entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	for _, key := range keys {
+		value := msg.Entries[key]
+		if len(value) > MaxExtVersionValueSize {
+			str := fmt.Sprintf("extversion value too large [size "+
+				"%v, max %v]", len(value), MaxExtVersionValueSize)
+			return messageError("MsgExtVersion.BchEncode", str)
+		}
+		if err := WriteVarInt(w, pver, key); err != nil {
+			return err
+		}
+		if err := WriteVarBytes(w, pver, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgExtVersion) Command() string {
+	return CmdExtVersion
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgExtVersion) MaxPayloadLength(pver uint32) uint32 {
+	return maxExtVersionPayload
+}
+
+// NewMsgExtVersion returns a new bitcoin extversion message that conforms to
+// the Message interface.  See MsgExtVersion for details.
+func NewMsgExtVersion() *MsgExtVersion {
+	return &MsgExtVersion{Entries: make(map[uint64][]byte)}
+}
@@ -0,0 +1,99 @@
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestExtVersionWire tests the MsgExtVersion wire encode and decode.
+func TestExtVersionWire(t *testing.T) {
+	msg := NewMsgExtVersion()
+	if err := msg.AddUint64(ExtVersionKeyVersion, ExtVersionProtocolVersion); err != nil {
+		t.Fatalf("AddUint64: unexpected error: %v", err)
+	}
+	if err := msg.AddUint64(0x1000, 0x10000); err != nil {
+		t.Fatalf("AddUint64: unexpected error: %v", err)
+	}
+	if err := msg.AddEntry(0x05, []byte{0xab, 0xcd}); err != nil {
+		t.Fatalf("AddEntry: unexpected error: %v", err)
+	}
+	want := []byte{
+		0x03,             // Num entries
+		0x00, 0x01, 0x01, // Version key, value size, value
+		0x05, 0x02, 0xab, 0xcd,
+		0xfd, 0x00, 0x10, 0x05, 0xfe, 0x00, 0x00, 0x01, 0x00,
+	}
+
+	var buf bytes.Buffer
+	if err := msg.BchEncode(&buf, ProtocolVersion, BaseEncoding); err != nil {
+		t.Fatalf("BchEncode: unexpected error: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("BchEncode\n got: %s want: %s", spew.Sdump(buf.Bytes()),
+			spew.Sdump(want))
+	}
+
+	var readMsg MsgExtVersion
+	if err := readMsg.BchDecode(&buf, ProtocolVersion, BaseEncoding); err != nil {
+		t.Fatalf("BchDecode: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(&readMsg, msg) {
+		t.Fatalf("BchDecode\n got: %s want: %s", spew.Sdump(readMsg),
+			spew.Sdump(msg))
+	}
+	if v, ok := readMsg.Uint64(ExtVersionKeyVersion); !ok ||
+		v != ExtVersionProtocolVersion {
+
+		t.Fatalf("Uint64: got %d (%v), want %d", v, ok,
+			ExtVersionProtocolVersion)
+	}
+	if v, ok := readMsg.Uint64(0x1000); !ok || v != 0x10000 {
+		t.Fatalf("Uint64: got %d (%v), want %d", v, ok, 0x10000)
+	}
+	if _, ok := readMsg.Uint64(0x05); ok {
+		t.Fatal("Uint64: expected invalid compact size")
+	}
+	if _, ok := readMsg.Uint64(0x06); ok {
+		t.Fatal("Uint64: expected missing entry")
+	}
+}
+
+// TestExtVersionWireErrors performs negative tests against wire encode and
+// decode of MsgExtVersion to confirm error paths work correctly.
+func TestExtVersionWireErrors(t *testing.T) {
+	msg := NewMsgExtVersion()
+	err := msg.AddEntry(0x01, make([]byte, MaxExtVersionValueSize+1))
+	if _, ok := err.(*MessageError); !ok {
+		t.Fatalf("AddEntry: expected MessageError, got %v", err)
+	}
+	for i := uint64(0); i < MaxExtVersionEntries; i++ {
+		if err := msg.AddEntry(i, nil); err != nil {
+			t.Fatalf("AddEntry: unexpected error: %v", err)
+		}
+	}
+	err = msg.AddEntry(MaxExtVersionEntries, nil)
+	if _, ok := err.(*MessageError); !ok {
+		t.Fatalf("AddEntry: expected MessageError, got %v", err)
+	}
+
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{"too many entries", []byte{0xfd, 0x01, 0x01}},
+		{"duplicate key", []byte{0x02, 0x01, 0x00, 0x01, 0x00}},
+		{"value too large", []byte{0x01, 0x01, 0xfd, 0x01, 0x01}},
+	}
+	for _, test := range tests {
+		var readMsg MsgExtVersion
+		err := readMsg.BchDecode(bytes.NewReader(test.buf),
+			ProtocolVersion, BaseEncoding)
+		if _, ok := err.(*MessageError); !ok {
+			t.Errorf("BchDecode %s: expected MessageError, got %v",
+				test.name, err)
+		}
+	}
+}
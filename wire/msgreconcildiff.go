@@ -0,0 +1,96 @@
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+// MaxReconcilDiffAsks is the maximum number of short transaction ids which can
+// be requested in a reconcildiff message.
+const MaxReconcilDiffAsks = MaxSketchCapacity
+
+// MsgReconcilDiff implements the Message interface and represents a bitcoin
+// reconcildiff message.  It is sent by the initiator of a transaction
+// reconciliation round to finish it, either with the short ids of the
+// transactions it is missing or with a failure after which the peer announces
+// all of its queued transactions.
+type MsgReconcilDiff struct {
+	// Success is whether or not the sketch of the peer was decoded.
+	Success bool
+
+	// AskShortIDs holds the short ids of the transactions which the
+	// sender is missing.
+	AskShortIDs []uint32
+}
+
+// BchDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgReconcilDiff) BchDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	if err := readElement(r, &msg.Success); err != nil {
+		return err
+	}
+
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+
+	// Limit to max asks per message.
+	if count > MaxReconcilDiffAsks {
+		str := fmt.Sprintf("too many short ids for message "+
+			"[count %v, max %v]", count, MaxReconcilDiffAsks)
+		return messageError("MsgReconcilDiff.BchDecode", str)
+	}
+
+	msg.AskShortIDs = make([]uint32, count)
+	for i := range msg.AskShortIDs {
+		if err := readElement(r, &msg.AskShortIDs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BchEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgReconcilDiff) BchEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	count := len(msg.AskShortIDs)
+	if count > MaxReconcilDiffAsks {
+		str := fmt.Sprintf("too many short ids for message "+
+			"[count %v, max %v]", count, MaxReconcilDiffAsks)
+		return messageError("MsgReconcilDiff.BchEncode", str)
+	}
+
+	if err := writeElement(w, msg.Success); err != nil {
+		return err
+	}
+	if err := WriteVarInt(w, pver, uint64(count)); err != nil {
+		return err
+	}
+	for _, shortID := range msg.AskShortIDs {
+		if err := writeElement(w, shortID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgReconcilDiff) Command() string {
+	return CmdReconcilDiff
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgReconcilDiff) MaxPayloadLength(pver uint32) uint32 {
+	// Success flag 1 byte + num short ids (varInt) + max short ids.
+	return 1 + uint32(VarIntSerializeSize(MaxReconcilDiffAsks)) +
+		MaxReconcilDiffAsks*4
+}
+
+// NewMsgReconcilDiff returns a new bitcoin reconcildiff message that conforms
+// to the Message interface using the passed parameters.
+func NewMsgReconcilDiff(success bool, askShortIDs []uint32) *MsgReconcilDiff {
+	return &MsgReconcilDiff{Success: success, AskShortIDs: askShortIDs}
+}
@@ -0,0 +1,77 @@
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestReconcilDiffWire tests the MsgReconcilDiff wire encode and decode.
+func TestReconcilDiffWire(t *testing.T) {
+	tests := []struct {
+		in  *MsgReconcilDiff // Message to encode
+		out *MsgReconcilDiff // Expected decoded message
+		buf []byte           // Wire encoding
+	}{
+		{
+			NewMsgReconcilDiff(false, nil),
+			NewMsgReconcilDiff(false, []uint32{}),
+			[]byte{0x00, 0x00},
+		},
+		{
+			NewMsgReconcilDiff(true, []uint32{0x01020304, 0xffffffff}),
+			NewMsgReconcilDiff(true, []uint32{0x01020304, 0xffffffff}),
+			[]byte{
+				0x01, 0x02, // Success, num short ids
+				0x04, 0x03, 0x02, 0x01,
+				0xff, 0xff, 0xff, 0xff,
+			},
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BchEncode(&buf, ProtocolVersion, BaseEncoding)
+		if err != nil {
+			t.Errorf("BchEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BchEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgReconcilDiff
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BchDecode(rbuf, ProtocolVersion, BaseEncoding)
+		if err != nil {
+			t.Errorf("BchDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BchDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+
+	// Messages with too many short ids are rejected.
+	msg := NewMsgReconcilDiff(true, make([]uint32, MaxReconcilDiffAsks+1))
+	var buf bytes.Buffer
+	err := msg.BchEncode(&buf, ProtocolVersion, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Fatalf("BchEncode: expected MessageError, got %v", err)
+	}
+	buf.Reset()
+	buf.Write([]byte{0x01, 0xfd, 0x81, 0x00})
+	err = msg.BchDecode(&buf, ProtocolVersion, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Fatalf("BchDecode: expected MessageError, got %v", err)
+	}
+}
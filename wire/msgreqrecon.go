@@ -0,0 +1,50 @@
+package wire
+
+import (
+	"io"
+)
+
+// MsgReqRecon implements the Message interface and represents a bitcoin
+// reqrecon message.  It is sent by the initiator of a transaction
+// reconciliation round to request a sketch of the announcements the peer has
+// queued for it.
+type MsgReqRecon struct {
+	// SetSize is the number of transactions the sender has queued for
+	// the peer.
+	SetSize uint16
+
+	// Q is the coefficient, scaled by 2^15-1, which estimates the size of
+	// the set difference relative to the smaller set.
+	Q uint16
+}
+
+// BchDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgReqRecon) BchDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	return readElements(r, &msg.SetSize, &msg.Q)
+}
+
+// BchEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgReqRecon) BchEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	return writeElements(w, msg.SetSize, msg.Q)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgReqRecon) Command() string {
+	return CmdReqRecon
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgReqRecon) MaxPayloadLength(pver uint32) uint32 {
+	// Set size 2 bytes + q 2 bytes.
+	return 4
+}
+
+// NewMsgReqRecon returns a new bitcoin reqrecon message that conforms to the
+// Message interface using the passed parameters.
+func NewMsgReqRecon(setSize, q uint16) *MsgReqRecon {
+	return &MsgReqRecon{SetSize: setSize, Q: q}
+}
@@ -0,0 +1,53 @@
+package wire
+
+import (
+	"io"
+)
+
+// TxReconciliationVersion is the current version of the transaction
+// reconciliation protocol.
+const TxReconciliationVersion = 1
+
+// MsgSendTxRcncl implements the Message interface and represents a bitcoin
+// sendtxrcncl message.  It is sent to a peer after the version handshake to
+// signal support for relaying transactions by set reconciliation instead of
+// announcing every transaction with an inv message.
+type MsgSendTxRcncl struct {
+	// Version is the highest reconciliation protocol version supported.
+	Version uint32
+
+	// Salt is the random value contributed by the sender to the salt of
+	// the short transaction ids used by the reconciliation.
+	Salt uint64
+}
+
+// BchDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgSendTxRcncl) BchDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	return readElements(r, &msg.Version, &msg.Salt)
+}
+
+// BchEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgSendTxRcncl) BchEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	return writeElements(w, msg.Version, msg.Salt)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgSendTxRcncl) Command() string {
+	return CmdSendTxRcncl
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgSendTxRcncl) MaxPayloadLength(pver uint32) uint32 {
+	// Version 4 bytes + salt 8 bytes.
+	return 12
+}
+
+// NewMsgSendTxRcncl returns a new bitcoin sendtxrcncl message that conforms to
+// the Message interface using the passed parameters.
+func NewMsgSendTxRcncl(version uint32, salt uint64) *MsgSendTxRcncl {
+	return &MsgSendTxRcncl{Version: version, Salt: salt}
+}
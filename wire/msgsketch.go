@@ -0,0 +1,62 @@
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+// MaxSketchCapacity is the maximum capacity of a sketch sent in a sketch
+// message.
+const MaxSketchCapacity = 128
+
+// MaxSketchSize is the maximum number of bytes of the sketch data in a sketch
+// message.
+const MaxSketchSize = MaxSketchCapacity * 4
+
+// MsgSketch implements the Message interface and represents a bitcoin sketch
+// message.  It is sent in response to a reqrecon message and holds the sketch
+// of the short ids of the transactions the sender has queued for the peer.  An
+// empty sketch signals that the reconciliation failed.
+type MsgSketch struct {
+	SketchData []byte
+}
+
+// BchDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgSketch) BchDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	var err error
+	msg.SketchData, err = ReadVarBytes(r, pver, MaxSketchSize,
+		"sketch data")
+	return err
+}
+
+// BchEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgSketch) BchEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	size := len(msg.SketchData)
+	if size > MaxSketchSize {
+		str := fmt.Sprintf("sketch size too large for message "+
+			"[size %v, max %v]", size, MaxSketchSize)
+		return messageError("MsgSketch.BchEncode", str)
+	}
+
+	return WriteVarBytes(w, pver, msg.SketchData)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgSketch) Command() string {
+	return CmdSketch
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgSketch) MaxPayloadLength(pver uint32) uint32 {
+	return uint32(VarIntSerializeSize(MaxSketchSize)) + MaxSketchSize
+}
+
+// NewMsgSketch returns a new bitcoin sketch message that conforms to the
+// Message interface using the passed parameters.
+func NewMsgSketch(sketchData []byte) *MsgSketch {
+	return &MsgSketch{SketchData: sketchData}
+}
@@ -0,0 +1,45 @@
+package wire
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestSketchWire tests the MsgSketch wire encode and decode, including sketches
+// exceeding the maximum size.
+func TestSketchWire(t *testing.T) {
+	msg := NewMsgSketch([]byte{0x01, 0x02, 0x03, 0x04})
+	var buf bytes.Buffer
+	if err := msg.BchEncode(&buf, ProtocolVersion, BaseEncoding); err != nil {
+		t.Fatalf("BchEncode: unexpected error: %v", err)
+	}
+	want := []byte{0x04, 0x01, 0x02, 0x03, 0x04}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("BchEncode: got %x, want %x", buf.Bytes(), want)
+	}
+
+	var readMsg MsgSketch
+	if err := readMsg.BchDecode(&buf, ProtocolVersion, BaseEncoding); err != nil {
+		t.Fatalf("BchDecode: unexpected error: %v", err)
+	}
+	if !bytes.Equal(readMsg.SketchData, msg.SketchData) {
+		t.Fatalf("BchDecode: got %x, want %x", readMsg.SketchData,
+			msg.SketchData)
+	}
+
+	// Sketches larger than the maximum are rejected.
+	msg = NewMsgSketch(make([]byte, MaxSketchSize+1))
+	buf.Reset()
+	err := msg.BchEncode(&buf, ProtocolVersion, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Fatalf("BchEncode: expected MessageError, got %v", err)
+	}
+	buf.Reset()
+	if err := WriteVarBytes(&buf, ProtocolVersion, make([]byte, MaxSketchSize+1)); err != nil {
+		t.Fatalf("WriteVarBytes: unexpected error: %v", err)
+	}
+	err = readMsg.BchDecode(&buf, ProtocolVersion, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Fatalf("BchDecode: expected MessageError, got %v", err)
+	}
+}
@@ -668,6 +668,33 @@ func TestTxOverflowErrors(t *testing.T) {
 	}
 }
 
+// TestTxTokenCommitmentOverflow ensures an output whose token prefix claims a
+// commitment of ~uint64(0) bytes is decoded with its whole public key script
+// instead of allocating the commitment.
+func TestTxTokenCommitmentOverflow(t *testing.T) {
+	pkScript := []byte{PREFIX_BYTE}
+	pkScript = append(pkScript, make([]byte, 32)...) // Category ID
+	pkScript = append(pkScript,
+		HAS_NFT|HAS_COMMITMENT_LENGTH|MUTABLE, // Bitfield
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xff, // Varint for length of commitment
+	)
+
+	var buf bytes.Buffer
+	buf.Write([]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}) // Transaction amount
+	buf.WriteByte(byte(len(pkScript)))                                // Varint for length of public key script
+	buf.Write(pkScript)
+
+	var txOut TxOut
+	if _, err := ReadTxOut(&buf, ProtocolVersion, 1, &txOut); err != nil {
+		t.Fatalf("ReadTxOut: %v", err)
+	}
+	if !bytes.Equal(txOut.PkScript, pkScript) {
+		t.Fatalf("ReadTxOut: got public key script %x, want %x",
+			txOut.PkScript, pkScript)
+	}
+}
+
 // TestTxSerializeSizeStripped performs tests to ensure the serialize size for
 // various transactions is accurate.
 func TestTxSerializeSizeStripped(t *testing.T) {
@@ -0,0 +1,57 @@
+package wire
+
+import (
+	"io"
+
+	"github.com/gcash/bchd/chaincfg/chainhash"
+)
+
+// MsgWeakBlock implements the Message interface and represents a bitcoin
+// weakblock message.  A weak block is a block whose hash does not meet the
+// target of its bits, but a target which is easier by a fixed factor.  Miners
+// announce the weak blocks they find so peers learn about the transactions
+// which are likely to be included in the next block before it is found.
+//
+// The message is encoded like a cmpctblock message and is only exchanged with
+// peers which negotiated weak block relay with the ExtVersionKeyWeakBlocks
+// extversion entry.
+type MsgWeakBlock MsgCmpctBlock
+
+// BchDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgWeakBlock) BchDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	return (*MsgCmpctBlock)(msg).BchDecode(r, pver, enc)
+}
+
+// BchEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgWeakBlock) BchEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	return (*MsgCmpctBlock)(msg).BchEncode(w, pver, enc)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgWeakBlock) Command() string {
+	return CmdWeakBlock
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgWeakBlock) MaxPayloadLength(pver uint32) uint32 {
+	return (*MsgCmpctBlock)(msg).MaxPayloadLength(pver)
+}
+
+// BlockHash computes the block identifier hash for this weak block.
+func (msg *MsgWeakBlock) BlockHash() chainhash.Hash {
+	return msg.Header.BlockHash()
+}
+
+// NewMsgWeakBlockFromBlock builds a weakblock message from a weak block using a
+// known inventory map.  See NewMsgCmpctBlockFromBlock for details.
+func NewMsgWeakBlockFromBlock(block *MsgBlock, knownInventory map[chainhash.Hash]bool) (*MsgWeakBlock, error) {
+	msg, err := NewMsgCmpctBlockFromBlock(block, knownInventory)
+	if err != nil {
+		return nil, err
+	}
+	return (*MsgWeakBlock)(msg), nil
+}
@@ -44,6 +44,16 @@ type NetAddress struct {
 	// Port the peer is using.  This is encoded in big endian on the wire
 	// which differs from most everything else.
 	Port uint16
+
+	// Network is the BIP0155 network of the address.  It is zero for
+	// addresses whose network is derived from IP, see NetworkID.
+	// Addresses of networks which are not IP based, such as Tor v3 and
+	// I2P, hold their address in Addr and have a nil IP.  These addresses
+	// can only be relayed with addrv2 messages.
+	Network NetworkID
+
+	// Addr is the address of the peer on a network which is not IP based.
+	Addr []byte
 }
 
 // HasService returns whether the specified service is supported by the address.
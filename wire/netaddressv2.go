@@ -0,0 +1,194 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// NetworkID identifies the network of an address as defined by BIP0155.
+type NetworkID uint8
+
+// These constants define the networks of BIP0155.
+const (
+	NetworkIPv4  NetworkID = 1
+	NetworkIPv6  NetworkID = 2
+	NetworkTorV2 NetworkID = 3
+	NetworkTorV3 NetworkID = 4
+	NetworkI2P   NetworkID = 5
+	NetworkCJDNS NetworkID = 6
+)
+
+// Map of network IDs back to their constant names for pretty printing.
+var networkIDStrings = map[NetworkID]string{
+	NetworkIPv4:  "IPv4",
+	NetworkIPv6:  "IPv6",
+	NetworkTorV2: "TorV2",
+	NetworkTorV3: "TorV3",
+	NetworkI2P:   "I2P",
+	NetworkCJDNS: "CJDNS",
+}
+
+// String returns the NetworkID in human-readable form.
+func (n NetworkID) String() string {
+	if s, ok := networkIDStrings[n]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown NetworkID (%d)", uint8(n))
+}
+
+// networkAddrLen maps the known networks to the length of their addresses.
+var networkAddrLen = map[NetworkID]int{
+	NetworkIPv4:  4,
+	NetworkIPv6:  16,
+	NetworkTorV2: 10,
+	NetworkTorV3: 32,
+	NetworkI2P:   32,
+	NetworkCJDNS: 16,
+}
+
+// MaxAddrV2Size is the maximum size of an address in an addrv2 message.
+const MaxAddrV2Size = 512
+
+// onionCatPrefix is the prefix of the IPv6 range Tor v2 addresses are
+// encoded in when they are represented as IP addresses.
+var onionCatPrefix = []byte{0xfd, 0x87, 0xd8, 0x7e, 0xeb, 0x43}
+
+// maxNetAddressV2Payload returns the max payload size for a NetAddress in an
+// addrv2 message.
+func maxNetAddressV2Payload() uint32 {
+	// Timestamp 4 bytes + services varint + network 1 byte + address
+	// varint length and bytes + port 2 bytes.
+	return 4 + MaxVarIntPayload + 1 + MaxVarIntPayload + MaxAddrV2Size + 2
+}
+
+// NetworkID returns the BIP0155 network of the address.  The network of an
+// address without an explicit network is derived from its IP: IPv4, Tor v2 for
+// the IPv6 range Tor v2 addresses are encoded in, or IPv6.
+func (na *NetAddress) NetworkID() NetworkID {
+	if na.Network != 0 {
+		return na.Network
+	}
+	if na.IP.To4() != nil {
+		return NetworkIPv4
+	}
+	if len(na.IP) == net.IPv6len && bytes.HasPrefix(na.IP, onionCatPrefix) {
+		return NetworkTorV2
+	}
+	return NetworkIPv6
+}
+
+// IsAddrV1Compatible returns whether the address can be relayed with addr
+// messages, which only support IP addresses and Tor v2 addresses.
+func (na *NetAddress) IsAddrV1Compatible() bool {
+	switch na.NetworkID() {
+	case NetworkIPv4, NetworkIPv6, NetworkTorV2:
+		return true
+	}
+	return false
+}
+
+// NewNetAddressV2 returns a new NetAddress using the provided timestamp,
+// supported services, BIP0155 network, address, and port.  Addresses of IP
+// based networks and Tor v2 addresses are represented by their IP, while the
+// addresses of other networks are kept as is.  An error is returned when the
+// length of the address does not match its network.
+func NewNetAddressV2(timestamp time.Time, services ServiceFlag,
+	network NetworkID, addr []byte, port uint16) (*NetAddress, error) {
+
+	if addrLen, ok := networkAddrLen[network]; ok && len(addr) != addrLen {
+		return nil, fmt.Errorf("invalid %v address length %d, "+
+			"expected %d", network, len(addr), addrLen)
+	}
+
+	na := NewNetAddressTimestamp(timestamp, services, nil, port)
+	switch network {
+	case NetworkIPv4:
+		na.IP = net.IPv4(addr[0], addr[1], addr[2], addr[3])
+	case NetworkIPv6:
+		na.IP = net.IP(append([]byte(nil), addr...))
+	case NetworkTorV2:
+		ip := make(net.IP, 0, net.IPv6len)
+		ip = append(ip, onionCatPrefix...)
+		na.IP = append(ip, addr...)
+	case NetworkCJDNS:
+		na.Network = network
+		na.IP = net.IP(append([]byte(nil), addr...))
+	default:
+		na.Network = network
+		na.Addr = append([]byte(nil), addr...)
+	}
+	return na, nil
+}
+
+// addrV2Bytes returns the address of the passed NetAddress as encoded in an
+// addrv2 message.
+func addrV2Bytes(na *NetAddress) []byte {
+	switch na.NetworkID() {
+	case NetworkIPv4:
+		return na.IP.To4()
+	case NetworkIPv6, NetworkCJDNS:
+		return na.IP.To16()
+	case NetworkTorV2:
+		return na.IP[len(onionCatPrefix):]
+	}
+	return na.Addr
+}
+
+// readNetAddressV2 reads a NetAddress encoded as in an addrv2 message from r.
+func readNetAddressV2(r io.Reader, pver uint32, na *NetAddress) error {
+	var timestamp time.Time
+	err := readElement(r, (*uint32Time)(&timestamp))
+	if err != nil {
+		return err
+	}
+	services, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	var network NetworkID
+	if err := readElement(r, (*uint8)(&network)); err != nil {
+		return err
+	}
+	addr, err := ReadVarBytes(r, pver, MaxAddrV2Size, "addrv2 address")
+	if err != nil {
+		return err
+	}
+	// Sigh.  Bitcoin protocol mixes little and big endian.
+	port, err := binarySerializer.Uint16(r, bigEndian)
+	if err != nil {
+		return err
+	}
+
+	decoded, err := NewNetAddressV2(timestamp, ServiceFlag(services),
+		network, addr, port)
+	if err != nil {
+		return messageError("readNetAddressV2", err.Error())
+	}
+	*na = *decoded
+	return nil
+}
+
+// writeNetAddressV2 serializes a NetAddress to w as encoded in an addrv2
+// message.
+func writeNetAddressV2(w io.Writer, pver uint32, na *NetAddress) error {
+	err := writeElement(w, uint32(na.Timestamp.Unix()))
+	if err != nil {
+		return err
+	}
+	if err := WriteVarInt(w, pver, uint64(na.Services)); err != nil {
+		return err
+	}
+	if err := writeElement(w, uint8(na.NetworkID())); err != nil {
+		return err
+	}
+	if err := WriteVarBytes(w, pver, addrV2Bytes(na)); err != nil {
+		return err
+	}
+
+	// Sigh.  Bitcoin protocol mixes little and big endian.
+	return binary.Write(w, bigEndian, na.Port)
+}
@@ -61,6 +61,10 @@ const (
 	// accept compact block relay but pledge not to ban nodes which
 	// relay blocks without validating them first.
 	NoValidationRelayVersion uint32 = 70015
+
+	// AddrV2Version is the protocol version from which peers may signal
+	// support for addrv2 messages (BIP0155) with a sendaddrv2 message.
+	AddrV2Version uint32 = 70016
 )
 
 // ServiceFlag identifies services supported by a bitcoin peer.
@@ -110,6 +114,11 @@ const (
 	// to serve the last 288 blocks though it will respond to requests for earlier blocks
 	// if it has them.
 	SFNodeNetworkLimited
+
+	// SFNodeExtVersion is a flag used to indicate a peer supports the
+	// extversion message to negotiate extended features during the version
+	// handshake.
+	SFNodeExtVersion
 )
 
 // Map of service flags back to their constant names for pretty printing.
@@ -125,6 +134,7 @@ var sfStrings = map[ServiceFlag]string{
 	SFNodeCF:             "SFNodeCF",
 	SFNodeXThinner:       "SFNodeXThinner",
 	SFNodeNetworkLimited: "SFNodeNetworkLimited",
+	SFNodeExtVersion:     "SFNodeExtVersion",
 }
 
 // orderedSFStrings is an ordered list of service flags from highest to
@@ -141,6 +151,7 @@ var orderedSFStrings = []ServiceFlag{
 	SFNodeCF,
 	SFNodeXThinner,
 	SFNodeNetworkLimited,
+	SFNodeExtVersion,
 }
 
 // String returns the ServiceFlag in human-readable form.
@@ -191,8 +202,16 @@ const (
 	// ChipNet represents the chip network.
 	ChipNet BitcoinNet = 0xafdab7e2
 
+	// ScaleNet represents the scaling test network.
+	ScaleNet BitcoinNet = 0xa2e1afc3
+
 	// SimNet represents the simulation test network.
 	SimNet BitcoinNet = 0x12141c16
+
+	// SigNet represents the signed test network with the default block
+	// signing challenge.  Signed test networks with other challenges use
+	// a magic derived from their challenge.
+	SigNet BitcoinNet = 0x40cf030a
 )
 
 // bnStrings is a map of bitcoin networks back to their constant names for
@@ -202,7 +221,9 @@ var bnStrings = map[BitcoinNet]string{
 	TestNet:  "TestNet",
 	TestNet3: "TestNet3",
 	TestNet4: "TestNet4",
+	ScaleNet: "ScaleNet",
 	SimNet:   "SimNet",
+	SigNet:   "SigNet",
 }
 
 // String returns the BitcoinNet in human-readable form.